@@ -0,0 +1,260 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/access"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/quota"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("access", &command{
+		summary: "Issue access to dataset files",
+		subcommands: map[string]*command{
+			"quota": {
+				usage:   "[--user USER]",
+				summary: "Show today's restricted-data download usage against the quota",
+				run:     runAccessQuota,
+			},
+			"simulate": {
+				usage:   "--user USER --dataset DATASET [--file PATH] [--version N] [--group G]... [--orcid ID] [--email EMAIL] [--client-ip IP] [--country CC] [--json]",
+				summary: "Explain why a user would be allowed or denied access to a dataset",
+				run:     runAccessSimulate,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"url": {
+				usage:   "<dataset> <file> [--email EMAIL] [--version N] [--expires 1h] [--client-ip IP] [--country CC]",
+				summary: "Print a time-limited download URL for a file",
+				run:     runAccessURL,
+				scope:   token.ScopeDownload,
+			},
+		},
+	})
+}
+
+// accessIssuer returns an issuer that enforces data use agreements and
+// signs URLs with the configured S3 credentials, for the regional copy
+// nearest the requester where a collection has one.
+func (a *app) accessIssuer(expiry time.Duration) (*access.Issuer, error) {
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	agreements, err := a.agreements()
+	if err != nil {
+		return nil, err
+	}
+	objects, err := a.objectStore()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	quotas, err := a.quotaTracker()
+	if err != nil {
+		return nil, err
+	}
+	router, err := a.regionRouter()
+	if err != nil {
+		return nil, err
+	}
+	i := &access.Issuer{
+		Datasets:   datasets,
+		Agreements: agreements,
+		Presigner:  objects,
+		Expiry:     expiry,
+		Quota:      quotas,
+		Log:        log,
+	}
+	if router != nil {
+		i.Router = router
+	}
+	return i, nil
+}
+
+// quotaTracker returns the tracker enforcing the configured daily
+// download quotas. With the dynamodb backend, usage is shared with the
+// presigned URL function through the download quotas table.
+func (a *app) quotaTracker() (*quota.Tracker, error) {
+	limits := quota.Limits{Bytes: a.cfg.QuotaBytes, Objects: a.cfg.QuotaObjects}
+	if a.cfg.StateBackend == "dynamodb" {
+		client, err := a.dynamoDB()
+		if err != nil {
+			return nil, err
+		}
+		return quota.NewTableTracker(client, a.cfg.QuotaTable(), limits), nil
+	}
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	return quota.NewTracker(s, limits), nil
+}
+
+func runAccessURL(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("access url")
+	email := fs.String("email", "", "email address recorded in the data use agreement (default the current user)")
+	version := fs.Int("version", 0, "dataset version (default latest)")
+	expires := durationFlag(fs, "expires", access.DefaultExpiry, "how long the URL remains valid")
+	clientIP := fs.String("client-ip", "", "address the URL will be used from, for network-restricted datasets")
+	country := fs.String("country", "", "country code the URL will be used from, for country-restricted datasets")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError("access url <dataset> <file> [--email EMAIL] [--version N] [--expires 1h] [--client-ip IP] [--country CC]")
+	}
+
+	client := authz.Client{Country: strings.ToUpper(*country)}
+	if *clientIP != "" {
+		if client.IP, err = netip.ParseAddr(*clientIP); err != nil {
+			return fmt.Errorf("invalid --client-ip %q", *clientIP)
+		}
+	}
+
+	issuer, err := a.accessIssuer(*expires)
+	if err != nil {
+		return err
+	}
+	g, err := issuer.Issue(ctx, access.Request{Dataset: pos[0], Version: *version, File: pos[1], Email: *email, Client: client})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(a.out, g.URL)
+	return nil
+}
+
+func runAccessSimulate(ctx context.Context, a *app, args []string) error {
+	const usage = "access simulate --user USER --dataset DATASET [--file PATH] [--version N] [--group G]... [--orcid ID] [--email EMAIL] [--client-ip IP] [--country CC] [--json]"
+	fs := newFlagSet("access simulate")
+	user := fs.String("user", "", "user to simulate")
+	ref := fs.String("dataset", "", "dataset ID or DOI")
+	file := fs.String("file", "", "file to request (default none; file checks are skipped)")
+	version := fs.Int("version", 0, "dataset version (default latest)")
+	var groups stringsFlag
+	fs.Var(&groups, "group", "group membership of --user (repeatable)")
+	orcid := fs.String("orcid", "", "ORCID iD of --user")
+	email := fs.String("email", "", "email address the agreement was accepted with (default --user)")
+	clientIP := fs.String("client-ip", "", "address the request comes from")
+	country := fs.String("country", "", "country code the request comes from")
+	asJSON := fs.Bool("json", false, "print the simulation as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 || *user == "" || *ref == "" {
+		return usageError(usage)
+	}
+
+	client := authz.Client{Country: strings.ToUpper(*country)}
+	if *clientIP != "" {
+		if client.IP, err = netip.ParseAddr(*clientIP); err != nil {
+			return fmt.Errorf("invalid --client-ip %q", *clientIP)
+		}
+	}
+	p := identity.Principal{ID: identity.Normalize(*user), Groups: groups, ORCID: *orcid}
+	if a.cfg.IsAdmin(p.ID) {
+		p.Groups = append(p.Groups, authz.AdminGroup)
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	agreements, err := a.agreements()
+	if err != nil {
+		return err
+	}
+	quotas, err := a.quotaTracker()
+	if err != nil {
+		return err
+	}
+	issuer := &access.Issuer{Datasets: datasets, Agreements: agreements, Quota: quotas}
+	sim, err := issuer.Simulate(identity.WithPrincipal(ctx, p),
+		access.Request{Dataset: *ref, Version: *version, File: *file, Email: *email, Client: client})
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return a.printJSON(sim)
+	}
+	verdict := "DENIED"
+	if sim.Allowed {
+		verdict = "ALLOWED"
+	}
+	target := sim.DatasetID
+	if sim.File != "" {
+		target = fmt.Sprintf("%s v%d %s", sim.DatasetID, sim.Version, sim.File)
+	}
+	fmt.Fprintf(a.out, "%s: %s reading %s\n", verdict, sim.Principal, target)
+	for _, c := range sim.Checks {
+		mark := "ok  "
+		if !c.Passed {
+			mark = "FAIL"
+		}
+		fmt.Fprintf(a.out, "  %s %-10s %s\n", mark, c.Name, c.Detail)
+	}
+	return nil
+}
+
+func runAccessQuota(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("access quota")
+	user := fs.String("user", "", "user to show (default the current user)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("access quota [--user USER]")
+	}
+	if *user == "" {
+		*user = identity.FromContext(ctx).ID
+	}
+
+	quotas, err := a.quotaTracker()
+	if err != nil {
+		return err
+	}
+	u, err := quotas.Usage(ctx, identity.Normalize(*user))
+	if err != nil {
+		return err
+	}
+	limits := quotas.Limits()
+	fmt.Fprintf(a.out, "%s on %s (UTC):\n", u.User, u.Day)
+	fmt.Fprintf(a.out, "  objects: %d of %s\n", u.Objects, limitString(int64(limits.Objects)))
+	fmt.Fprintf(a.out, "  bytes:   %d of %s\n", u.Bytes, limitString(limits.Bytes))
+	return nil
+}
+
+// limitString formats a quota limit, where zero means unlimited.
+func limitString(n int64) string {
+	if n == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprint(n)
+}
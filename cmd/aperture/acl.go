@@ -0,0 +1,216 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("acl", &command{
+		summary: "Manage dataset access control lists",
+		subcommands: map[string]*command{
+			"show": {
+				usage:   "<dataset>",
+				summary: "Show who may read and manage a dataset",
+				run:     runACLShow,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"grant": {
+				usage:      "<dataset> <entry>... [--manage]",
+				summary:    "Allow users, groups, domains, or ORCID iDs to read (or manage) a dataset",
+				run:        runACLGrant,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermCurate,
+			},
+			"revoke": {
+				usage:      "<dataset> <entry>... [--manage]",
+				summary:    "Remove entries from a dataset's access control list",
+				run:        runACLRevoke,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermCurate,
+			},
+			"check": {
+				usage:   "<dataset> [--as USER] [--group G]... [--orcid ID] [--manage]",
+				summary: "Explain whether a principal may access a dataset",
+				run:     runACLCheck,
+				scope:   token.ScopeDatasetsRead,
+			},
+		},
+	})
+}
+
+func runACLShow(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("acl show")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("acl show <dataset>")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+
+	read := d.ACL.Entries(authz.ActionRead)
+	source := ""
+	if len(read) == 0 {
+		read = authz.DefaultRead(d.Access)
+		source = fmt.Sprintf(" (default for %s datasets)", d.Access)
+	}
+	fmt.Fprintf(a.out, "read:   %s%s\n", entryList(read), source)
+	fmt.Fprintf(a.out, "manage: %s (plus group:%s)\n", entryList(d.ACL.Entries(authz.ActionManage)), authz.AdminGroup)
+	return nil
+}
+
+func runACLGrant(ctx context.Context, a *app, args []string) error {
+	return updateACL(ctx, a, "grant", args)
+}
+
+func runACLRevoke(ctx context.Context, a *app, args []string) error {
+	return updateACL(ctx, a, "revoke", args)
+}
+
+// updateACL adds or removes entries after checking that the caller may
+// manage the dataset.
+func updateACL(ctx context.Context, a *app, op string, args []string) error {
+	fs := newFlagSet("acl " + op)
+	manage := fs.Bool("manage", false, "change the manage list instead of the read list")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) < 2 {
+		return usageError("acl " + op + " <dataset> <entry>... [--manage]")
+	}
+	var entries []string
+	for _, e := range pos[1:] {
+		entry, err := authz.ParseEntry(e)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+		return err
+	}
+
+	if d.ACL == nil {
+		d.ACL = &authz.ACL{}
+	}
+	list := &d.ACL.Read
+	if *manage {
+		list = &d.ACL.Manage
+	}
+	for _, e := range entries {
+		switch {
+		case op == "grant" && !slices.Contains(*list, e):
+			*list = append(*list, e)
+		case op == "revoke":
+			*list = slices.DeleteFunc(*list, func(x string) bool { return x == e })
+		}
+	}
+	if len(d.ACL.Read) == 0 && len(d.ACL.Manage) == 0 {
+		d.ACL = nil
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		return err
+	}
+
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	action := authz.ActionRead
+	if *manage {
+		action = authz.ActionManage
+	}
+	return audit.Record(ctx, log, "acl."+op, d.ID, map[string]string{
+		"action":  string(action),
+		"entries": strings.Join(entries, " "),
+	})
+}
+
+func runACLCheck(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("acl check")
+	as := fs.String("as", "", "principal to check (default the current user)")
+	var groups stringsFlag
+	fs.Var(&groups, "group", "group membership of --as (repeatable)")
+	orcid := fs.String("orcid", "", "ORCID iD of --as")
+	manage := fs.Bool("manage", false, "check manage access instead of read access")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("acl check <dataset> [--as USER] [--group G]... [--orcid ID] [--manage]")
+	}
+
+	p := identity.FromContext(ctx)
+	if *as != "" {
+		p = identity.Principal{ID: identity.Normalize(*as), Groups: groups, ORCID: *orcid}
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+
+	action := authz.ActionRead
+	if *manage {
+		action = authz.ActionManage
+	}
+	decision := authz.Decide(p, d.Resource(), action)
+	verdict := "denied"
+	if decision.Allowed {
+		verdict = "allowed"
+	}
+	fmt.Fprintf(a.out, "%s %s %s on %s: %s\n", p, verdict, action, d.ID, decision.Reason)
+	return nil
+}
+
+// entryList formats ACL entries for display.
+func entryList(entries []string) string {
+	if len(entries) == 0 {
+		return "(none)"
+	}
+	return strings.Join(entries, ", ")
+}
@@ -0,0 +1,208 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/alert"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("alert", &command{
+		summary: "Save searches and get alerts of newly published matches",
+		subcommands: map[string]*command{
+			"save": {
+				usage:   "--name NAME [TEXT...] [search filters...] [--email ADDR] [--webhook URL]",
+				summary: "Save a search, taking the filters of 'search query'",
+				run:     runAlertSave,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"list": {
+				usage:   "[--all] [--json]",
+				summary: "List your saved searches",
+				run:     runAlertList,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"delete": {
+				usage:   "<id>",
+				summary: "Delete a saved search",
+				run:     runAlertDelete,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"run": {
+				usage:      "[--json]",
+				summary:    "Run every saved search and alert owners of new matches",
+				run:        runAlertRun,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+				schedule:   time.Hour,
+			},
+		},
+	})
+}
+
+// alerts returns the saved search registry, searching the configured
+// OpenSearch index.
+func (a *app) alerts() (*alert.Registry, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	searcher, err := a.searcher()
+	if err != nil {
+		return nil, err
+	}
+	n, err := a.notifier()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	return &alert.Registry{State: s, Searcher: searcher, Notifier: n, Log: log}, nil
+}
+
+func runAlertSave(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("alert save")
+	name := fs.String("name", "", "name of the saved search")
+	query := queryFlags(fs)
+	email := fs.String("email", "", "email alerts to `ADDR` (default your address, unless --webhook is given)")
+	webhook := fs.String("webhook", "", "post alerts as JSON to the https `URL`")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *name == "" {
+		return usageError("alert save --name NAME [TEXT...] [search filters...] [--email ADDR] [--webhook URL]")
+	}
+	q, err := query(pos)
+	if err != nil {
+		return err
+	}
+	r, err := a.alerts()
+	if err != nil {
+		return err
+	}
+	al, err := r.Save(ctx, *name, q, *email, *webhook)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Saved search %s (%s)\n", al.ID, al.Name)
+	if al.Secret != "" {
+		fmt.Fprintf(a.out, "Webhook secret: %s\n", al.Secret)
+		fmt.Fprintf(a.out, "Requests carry %s: sha256=HMAC-SHA256(secret, body). Store the secret now; it is not shown again.\n", alert.SignatureHeader)
+	}
+	return nil
+}
+
+func runAlertList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("alert list")
+	all := fs.Bool("all", false, "list everyone's saved searches (administrators)")
+	asJSON := fs.Bool("json", false, "print saved searches as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	r, err := a.alerts()
+	if err != nil {
+		return err
+	}
+	alerts, err := r.List(ctx, *all)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(alerts)
+	}
+	if len(alerts) == 0 {
+		fmt.Fprintln(a.out, "No saved searches")
+		return nil
+	}
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tOWNER\tALERTS\tLAST RUN")
+	for _, al := range alerts {
+		var to []string
+		if al.Email != "" {
+			to = append(to, al.Email)
+		}
+		if al.Webhook != "" {
+			to = append(to, al.Webhook)
+		}
+		checked := "never"
+		if al.Checked != nil {
+			checked = al.Checked.Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", al.ID, clip(al.Name, 30), al.Owner, clip(strings.Join(to, ", "), 50), checked)
+	}
+	return tw.Flush()
+}
+
+func runAlertDelete(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("alert delete")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("alert delete <id>")
+	}
+	r, err := a.alerts()
+	if err != nil {
+		return err
+	}
+	if err := r.Delete(ctx, pos[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Deleted saved search %s\n", pos[0])
+	return nil
+}
+
+func runAlertRun(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("alert run")
+	asJSON := fs.Bool("json", false, "print deliveries as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	r, err := a.alerts()
+	if err != nil {
+		return err
+	}
+	deliveries, err := r.Run(ctx)
+	if *asJSON {
+		if jerr := a.printJSON(deliveries); jerr != nil {
+			return jerr
+		}
+		return err
+	}
+	sent := 0
+	for _, d := range deliveries {
+		status := "sent by " + strings.Join(d.Channels, " and ")
+		if d.Error != "" {
+			status = "failed: " + d.Error
+		} else {
+			sent++
+		}
+		fmt.Fprintf(a.out, "%-16s %-24s %d datasets, %s\n", d.Alert, d.Owner, len(d.Datasets), status)
+	}
+	fmt.Fprintf(a.out, "Sent %d alerts\n", sent)
+	return err
+}
@@ -0,0 +1,333 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/azblob"
+	"github.com/scttfrdmn/aperture/internal/config"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/dynamodb"
+	"github.com/scttfrdmn/aperture/internal/fsblob"
+	"github.com/scttfrdmn/aperture/internal/gc"
+	"github.com/scttfrdmn/aperture/internal/metrics"
+	"github.com/scttfrdmn/aperture/internal/notify"
+	"github.com/scttfrdmn/aperture/internal/premis"
+	"github.com/scttfrdmn/aperture/internal/queue"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/search"
+	"github.com/scttfrdmn/aperture/internal/sqs"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/status"
+	"github.com/scttfrdmn/aperture/internal/storage"
+	"github.com/scttfrdmn/aperture/internal/trace"
+)
+
+// app carries state shared by all commands.
+type app struct {
+	cfg *config.Config
+	in  io.Reader
+	out io.Writer
+
+	// metrics emits operational metrics; nil if they are off
+	metrics *metrics.EMF
+
+	// tracer exports spans over OTLP; nil if tracing is off
+	tracer *trace.Tracer
+}
+
+// recorder returns the metrics recorder, or nil if metrics are off.
+func (a *app) recorder() metrics.Recorder {
+	if a.metrics == nil {
+		return nil
+	}
+	return a.metrics
+}
+
+// instrument wraps the handler of server mode service h to answer
+// health checks and record request metrics and traces, if they are on.
+func (a *app) instrument(service string, h http.Handler) http.Handler {
+	h = status.Health(service, a.healthProbes(), h)
+	if a.tracer != nil {
+		h = trace.Handler(a.tracer, service, h)
+	}
+	if a.metrics != nil {
+		h = metrics.Handler(a.metrics, service, h)
+	}
+	return h
+}
+
+// auditLog returns the audit log kept in the state store, which with
+// the dynamodb backend is the one chain the platform's functions and
+// every operator append to.
+func (a *app) auditLog() (audit.Log, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	return audit.NewStoreLog(s), nil
+}
+
+// notifier returns where notifications are sent: the notify queue, if
+// background work is queued, and otherwise the deliverer.
+func (a *app) notifier() (notify.Notifier, error) {
+	q, err := a.queue()
+	if err != nil {
+		return nil, err
+	}
+	if q != nil {
+		return &notify.Queued{Queue: q}, nil
+	}
+	return a.deliverer()
+}
+
+// deliverer returns the SMTP relay if one is configured, otherwise the
+// outbox in the state directory.
+func (a *app) deliverer() (notify.Notifier, error) {
+	if a.cfg.SMTPAddr != "" {
+		return &notify.SMTP{Addr: a.cfg.SMTPAddr, From: a.cfg.MailFrom}, nil
+	}
+	return notify.NewOutbox(filepath.Join(a.cfg.StateDir, "outbox.jsonl"))
+}
+
+// queue returns the queues background work is handed to workers
+// through, or nil if it is done within the requests that cause it.
+func (a *app) queue() (queue.Queue, error) {
+	switch a.cfg.QueueBackend {
+	case "local":
+		s, err := a.store()
+		if err != nil {
+			return nil, err
+		}
+		return &queue.Local{State: s}, nil
+	case "sqs":
+		creds, err := aws.CredentialsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		client := sqs.NewClient(sqs.Options{Region: a.cfg.AWSRegion, Endpoint: a.cfg.AWSEndpoint, Credentials: creds})
+		return &queue.SQS{Client: client, Prefix: a.cfg.BucketPrefix()}, nil
+	}
+	return nil, nil
+}
+
+// store returns the state store holding Aperture records: the state
+// table of the environment with the dynamodb backend, otherwise files
+// in the state directory.
+func (a *app) store() (state.Store, error) {
+	if a.cfg.StateBackend == "dynamodb" {
+		client, err := a.dynamoDB()
+		if err != nil {
+			return nil, err
+		}
+		return dynamodb.NewStore(client, a.cfg.StateTable()), nil
+	}
+	return state.NewFileStore(filepath.Join(a.cfg.StateDir, "records"))
+}
+
+// dynamoDB returns a client of the platform's DynamoDB tables.
+func (a *app) dynamoDB() (*dynamodb.Client, error) {
+	creds, err := aws.CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return dynamodb.NewClient(dynamodb.Options{Region: a.cfg.AWSRegion, Endpoint: a.cfg.AWSEndpoint, Credentials: creds}), nil
+}
+
+// datasets returns the dataset catalog.
+func (a *app) datasets() (*dataset.Store, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	events, err := a.premisEvents(s)
+	if err != nil {
+		return nil, err
+	}
+	st := dataset.NewStore(s)
+	st.Observe(&premis.Observer{Log: events})
+	if a.cfg.OpenSearchURL != "" {
+		q, err := a.queue()
+		if err != nil {
+			return nil, err
+		}
+		if q != nil {
+			st.Observe(&search.Queued{Queue: q, Indexer: a.indexer(s)})
+		} else {
+			st.Observe(a.indexer(s))
+		}
+	}
+	return st, nil
+}
+
+// indexer returns the search indexer of the configured OpenSearch
+// domain. Requests are signed if AWS credentials are available.
+func (a *app) indexer(s state.Store) *search.Indexer {
+	creds, _ := aws.CredentialsFromEnv()
+	return &search.Indexer{
+		Index:   search.NewClient(search.ClientOptions{Endpoint: a.cfg.OpenSearchURL, Region: a.cfg.AWSRegion, Credentials: creds}),
+		Alias:   a.cfg.SearchAlias(),
+		State:   s,
+		SiteURL: a.cfg.SiteURL,
+		Metrics: a.recorder(),
+	}
+}
+
+// s3Client returns an S3 client for the configured region using
+// credentials from the environment. Buckets are addressed by path at a
+// configured endpoint, as LocalStack expects.
+func (a *app) s3Client() (*s3.Client, error) {
+	creds, err := aws.CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewClient(s3.Options{Region: a.cfg.AWSRegion, Endpoint: a.cfg.AWSEndpoint, PathStyle: a.cfg.AWSEndpoint != "",
+		Credentials: creds, Metrics: a.recorder()})
+}
+
+// mediaClient returns an S3 client of the media buckets: of the
+// S3-compatible store at APERTURE_STORAGE_ENDPOINT, with its own
+// credentials, if configured, and otherwise like s3Client.
+func (a *app) mediaClient() (*s3.Client, error) {
+	cfg := a.cfg
+	if cfg.StorageEndpoint == "" && cfg.StorageAccessKeyID == "" && cfg.StorageCredentialsFile == "" {
+		return a.s3Client()
+	}
+	creds, err := storageCredentials(cfg.StorageAccessKeyID, cfg.StorageSecretAccessKey, cfg.StorageCredentialsFile, cfg.StorageProfile)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewClient(s3.Options{
+		Region:      cmp.Or(cfg.StorageRegion, cfg.AWSRegion),
+		Endpoint:    cmp.Or(cfg.StorageEndpoint, cfg.AWSEndpoint),
+		PathStyle:   cfg.StoragePathStyle || cfg.StorageEndpoint == "" && cfg.AWSEndpoint != "",
+		Credentials: creds,
+		Metrics:     a.recorder(),
+	})
+}
+
+// storageCredentials returns the access keys given, or else those of
+// profile in credentialsFile, or else the AWS credentials of the
+// environment.
+func storageCredentials(accessKeyID, secretAccessKey, credentialsFile, profile string) (aws.Credentials, error) {
+	switch {
+	case accessKeyID != "":
+		return aws.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey}, nil
+	case credentialsFile != "":
+		return aws.CredentialsFromFile(credentialsFile, profile)
+	}
+	return aws.CredentialsFromEnv()
+}
+
+// objectStore returns the configured backend of the media buckets: the
+// primary store of primaryStore, and with hybrid tiering, the AWS
+// buckets older files tier to.
+func (a *app) objectStore() (storage.Backend, error) {
+	primary, err := a.primaryStore()
+	if err != nil || a.cfg.TierBucketPrefix == "" {
+		return primary, err
+	}
+	cloud, err := a.s3Client()
+	if err != nil {
+		return nil, err
+	}
+	return &storage.Tiered{Primary: primary, Cloud: cloud, CloudPrefix: a.cfg.TierBucketPrefix}, nil
+}
+
+// primaryStore returns the store of the primary media buckets, of the
+// backend APERTURE_STORAGE_BACKEND names.
+func (a *app) primaryStore() (storage.Backend, error) {
+	return a.backend(a.cfg.StorageBackend)
+}
+
+// backend returns the store of the media buckets with the named
+// backend: the Azure storage account, Google Cloud Storage, or local
+// directory configured for it, otherwise S3 or the S3-compatible store
+// of mediaClient.
+func (a *app) backend(name string) (storage.Backend, error) {
+	switch name {
+	case "", storage.BackendS3:
+		return a.mediaClient()
+	case storage.BackendAzure:
+		return azblob.NewClient(azblob.Options{Account: a.cfg.AzureStorageAccount, Key: a.cfg.AzureStorageSecretKey, Endpoint: a.cfg.AzureBlobEndpoint})
+	case storage.BackendGCS:
+		return a.gcsClient()
+	case storage.BackendFilesystem:
+		return a.fileStore()
+	}
+	return nil, fmt.Errorf("unknown storage backend %q (want s3, gcs, azure, or filesystem)", name)
+}
+
+// gcsClient returns a client of Google Cloud Storage's S3-compatible
+// XML API, signing with the configured HMAC key.
+func (a *app) gcsClient() (*s3.Client, error) {
+	return s3.NewClient(s3.Options{
+		Region:      "auto",
+		Endpoint:    a.cfg.GCSEndpoint,
+		PathStyle:   true,
+		Credentials: aws.Credentials{AccessKeyID: a.cfg.GCSAccessKeyID, SecretAccessKey: a.cfg.GCSSecretAccessKey},
+		Metrics:     a.recorder(),
+	})
+}
+
+// fileStore returns the filesystem backend of the media buckets.
+func (a *app) fileStore() (*fsblob.Client, error) {
+	return fsblob.NewClient(fsblob.Options{Root: a.storageRoot(), URL: a.cfg.FileServerURL, Secret: a.cfg.FileServerSecret})
+}
+
+// storageRoot returns the directory of the filesystem storage backend.
+func (a *app) storageRoot() string {
+	return cmp.Or(a.cfg.StorageRoot, filepath.Join(a.cfg.StateDir, "objects"))
+}
+
+// mediaStore is a store of the primary media buckets that storage gc
+// and fsck can scan for orphans and abandoned uploads.
+type mediaStore interface {
+	storage.Backend
+	gc.ObjectStore
+}
+
+// scanStore returns the store of the primary media buckets to scan:
+// the filesystem or Google Cloud Storage backend if configured,
+// otherwise mediaClient.
+func (a *app) scanStore() (mediaStore, error) {
+	switch a.cfg.StorageBackend {
+	case storage.BackendFilesystem:
+		return a.fileStore()
+	case storage.BackendGCS:
+		return a.gcsClient()
+	}
+	return a.mediaClient()
+}
+
+// layout returns the configured storage layout.
+func (a *app) layout() (storage.Layout, error) {
+	return storage.NewLayout(a.cfg.StorageLayout, a.cfg.BucketPrefix())
+}
+
+// printJSON writes v as indented JSON.
+func (a *app) printJSON(v any) error {
+	enc := json.NewEncoder(a.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,250 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+)
+
+func init() {
+	register("audit", &command{
+		summary: "Query and verify the audit log",
+		subcommands: map[string]*command{
+			"log": {
+				usage:      "[--privileged] [--actor USER] [--action A] [--target T] [--since T] [--until T] [--limit N] [--json]",
+				summary:    "Show audit entries, most recent last",
+				run:        runAuditLog,
+				permission: authz.PermAudit,
+			},
+			"verify": {
+				usage:      "[--json]",
+				summary:    "Check the audit log's hash chain and its anchors",
+				run:        runAuditVerify,
+				permission: authz.PermAudit,
+			},
+			"anchor": {
+				usage:      "[--json]",
+				summary:    "Anchor the head of the audit log in the Object Lock bucket",
+				run:        runAuditAnchor,
+				permission: authz.PermMaintain,
+				schedule:   24 * time.Hour,
+			},
+		},
+	})
+}
+
+// sourceIP returns the address the CLI is operated from: the client
+// of an SSH session, otherwise the host's first global address.
+func sourceIP() string {
+	if client := strings.Fields(os.Getenv("SSH_CLIENT")); len(client) > 0 {
+		return client[0]
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+	for _, addr := range addrs {
+		if n, ok := addr.(*net.IPNet); ok && n.IP.IsGlobalUnicast() {
+			return n.IP.String()
+		}
+	}
+	return ""
+}
+
+func runAuditLog(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("audit log")
+	var q audit.Query
+	fs.BoolVar(&q.Privileged, "privileged", false, "only privileged actions ("+strings.Join(audit.Privileged, ", ")+")")
+	fs.StringVar(&q.Actor, "actor", "", "only actions by, or impersonated by, this principal")
+	fs.StringVar(&q.Action, "action", "", `only this action, or actions with a prefix ending in "." (e.g. "embargo.")`)
+	fs.StringVar(&q.Target, "target", "", "only actions on this dataset, DOI, or user")
+	since := fs.String("since", "", "only entries at or after this date, timestamp, or duration ago (e.g. 7d)")
+	until := fs.String("until", "", "only entries before this date or timestamp")
+	fs.IntVar(&q.Limit, "limit", 0, "only the most recent N entries")
+	asJSON := fs.Bool("json", false, "print entries as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("audit log [--privileged] [--actor USER] [--action A] [--target T] [--since T] [--until T] [--limit N] [--json]")
+	}
+	if *since != "" {
+		if d, err := parseDuration(*since); err == nil {
+			q.Since = time.Now().Add(-d)
+		} else if q.Since, err = parseTime(*since); err != nil {
+			return err
+		}
+	}
+	if *until != "" {
+		if q.Until, err = parseTime(*until); err != nil {
+			return err
+		}
+	}
+
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	entries, err := log.Entries(ctx)
+	if err != nil {
+		return err
+	}
+	entries = audit.Filter(entries, q)
+	if *asJSON {
+		return a.printJSON(entries)
+	}
+	for _, e := range entries {
+		actor := e.Actor
+		if e.Impersonator != "" {
+			actor = e.Impersonator + " as " + e.Actor
+		}
+		fmt.Fprintf(a.out, "%s  %-22s %-28s %s", e.Time.Format(time.RFC3339), e.Action, actor, e.Target)
+		if e.SourceIP != "" {
+			fmt.Fprintf(a.out, "  from %s", e.SourceIP)
+		}
+		if e.Justification != "" {
+			fmt.Fprintf(a.out, "  %q", e.Justification)
+		}
+		fmt.Fprintln(a.out)
+	}
+	return nil
+}
+
+// auditAnchors returns the anchors of the audit log in the configured
+// Object Lock bucket, or nil if anchoring is not configured.
+func (a *app) auditAnchors() (*audit.Anchors, error) {
+	if a.cfg.AuditAnchorBucket == "" {
+		return nil, nil
+	}
+	client, err := a.s3Client()
+	if err != nil {
+		return nil, err
+	}
+	return &audit.Anchors{Objects: client, Bucket: a.cfg.AuditAnchorBucket}, nil
+}
+
+func runAuditVerify(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("audit verify")
+	asJSON := fs.Bool("json", false, "print the verification as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("audit verify [--json]")
+	}
+
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	entries, err := log.Entries(ctx)
+	if err != nil {
+		return err
+	}
+	store, err := a.auditAnchors()
+	if err != nil {
+		return err
+	}
+	var anchors []audit.Anchor
+	if store != nil {
+		if anchors, err = store.List(ctx); err != nil {
+			return err
+		}
+	}
+	v := audit.Verify(entries, anchors)
+	if *asJSON {
+		if err := a.printJSON(v); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(a.out, "Checked %d audit entries (%d from before chaining) against %d anchors\n", v.Entries, v.Unchained, v.Anchors)
+		if v.Head.Seq > 0 {
+			fmt.Fprintf(a.out, "Head: entry %d, %s\n", v.Head.Seq, v.Head.Hash)
+		}
+		if store == nil {
+			fmt.Fprintln(a.out, "Anchoring is not configured (APERTURE_AUDIT_ANCHOR_BUCKET); only the hash chain was checked")
+		}
+		for _, p := range v.Problems {
+			fmt.Fprintf(a.out, "  entry %d: %s\n", p.Seq, p.Detail)
+		}
+	}
+	if !v.OK() {
+		return fmt.Errorf("audit log failed verification with %d problems", len(v.Problems))
+	}
+	return nil
+}
+
+func runAuditAnchor(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("audit anchor")
+	asJSON := fs.Bool("json", false, "print the anchor as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("audit anchor [--json]")
+	}
+	store, err := a.auditAnchors()
+	if err != nil {
+		return err
+	}
+	if store == nil {
+		return fmt.Errorf("audit anchoring is not configured; set APERTURE_AUDIT_ANCHOR_BUCKET")
+	}
+
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	entries, err := log.Entries(ctx)
+	if err != nil {
+		return err
+	}
+	// Anchoring a broken chain would vouch for it
+	v := audit.Verify(entries, nil)
+	if !v.OK() {
+		p := v.Problems[0]
+		return fmt.Errorf("not anchoring: audit log fails verification at entry %d: %s", p.Seq, p.Detail)
+	}
+	if v.Head.Seq == 0 {
+		fmt.Fprintln(a.out, "The audit log is empty; nothing to anchor")
+		return nil
+	}
+	anchor := v.Head
+	anchor.Time = time.Now().UTC()
+	if err := store.Put(ctx, anchor); err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, log, "audit.anchor", fmt.Sprint(anchor.Seq), map[string]string{
+		"hash": anchor.Hash, "bucket": store.Bucket,
+	}); err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(anchor)
+	}
+	fmt.Fprintf(a.out, "Anchored entry %d (%s) in s3://%s\n", anchor.Seq, anchor.Hash, store.Bucket)
+	return nil
+}
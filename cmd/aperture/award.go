@@ -0,0 +1,280 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/award"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("award", &command{
+		summary: "Record the grants that fund datasets",
+		subcommands: map[string]*command{
+			"add": {
+				usage:      "--funder ROR --number N [--title T] [--program P] [--funder-name NAME] [--grant-doi DOI] [--no-verify]",
+				summary:    "Register an award, checking it against Crossref grant DOIs",
+				run:        runAwardAdd,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermCurate,
+			},
+			"list": {
+				usage:   "[--json]",
+				summary: "List registered awards",
+				run:     runAwardList,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"show": {
+				usage:   "<award> [--json]",
+				summary: "Show an award and the datasets it funded",
+				run:     runAwardShow,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"search": {
+				usage:   "<award-number>",
+				summary: "Show the Crossref grant DOIs registered for an award number",
+				run:     runAwardSearch,
+				scope:   anyScope,
+			},
+			"link": {
+				usage:      "<dataset> <award>",
+				summary:    "Record that an award funded a dataset",
+				run:        runAwardLink,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermCurate,
+			},
+			"unlink": {
+				usage:      "<dataset> <award>",
+				summary:    "Remove an award from a dataset",
+				run:        runAwardUnlink,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermCurate,
+			},
+		},
+	})
+}
+
+func (a *app) crossrefClient() *award.Crossref {
+	return award.NewCrossref(award.CrossrefOptions{BaseURL: a.cfg.CrossrefURL, Mailto: a.cfg.CrossrefMailto})
+}
+
+func (a *app) awardRegistry() (*award.Registry, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	return &award.Registry{
+		State:    s,
+		Datasets: dataset.NewStore(s),
+		Grants:   a.crossrefClient(),
+		Funders:  a.rorClient(),
+		Log:      log,
+	}, nil
+}
+
+func runAwardAdd(ctx context.Context, a *app, args []string) error {
+	const usage = "award add --funder ROR --number N [--title T] [--program P] [--funder-name NAME] [--grant-doi DOI] [--no-verify]"
+	fs := newFlagSet("award add")
+	funder := fs.String("funder", "", "ROR ID of the funder")
+	number := fs.String("number", "", "the funder's award number")
+	title := fs.String("title", "", "title of the funded project (default from the grant DOI)")
+	program := fs.String("program", "", "the funder's funding programme, e.g. H2020")
+	funderName := fs.String("funder-name", "", "name of the funder (default from ROR)")
+	grantDOI := fs.String("grant-doi", "", "Crossref grant DOI of the award (default looked up)")
+	noVerify := fs.Bool("no-verify", false, "record the award without checking Crossref")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 || *funder == "" || *number == "" {
+		return usageError(usage)
+	}
+	r, err := a.awardRegistry()
+	if err != nil {
+		return err
+	}
+	if *noVerify {
+		r.Grants = nil
+	}
+	aw, err := r.Add(ctx, award.Award{
+		FunderROR:  *funder,
+		FunderName: *funderName,
+		Number:     *number,
+		Title:      *title,
+		Program:    *program,
+		GrantDOI:   *grantDOI,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Registered award %s (%s %s)\n", aw.ID, aw.FunderName, aw.Number)
+	if aw.GrantDOI != "" {
+		fmt.Fprintf(a.out, "  Grant DOI: https://doi.org/%s\n", aw.GrantDOI)
+	} else if !*noVerify {
+		fmt.Fprintln(a.out, "  No Crossref grant DOI found; the award is recorded as given.")
+	}
+	return nil
+}
+
+func runAwardList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("award list")
+	asJSON := fs.Bool("json", false, "print awards as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	r, err := a.awardRegistry()
+	if err != nil {
+		return err
+	}
+	awards, err := r.List(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(awards)
+	}
+	if len(awards) == 0 {
+		fmt.Fprintln(a.out, "No awards registered")
+		return nil
+	}
+	for _, aw := range awards {
+		fmt.Fprintf(a.out, "%-32s %-24s %s\n", aw.ID, aw.Number, aw.FunderName)
+	}
+	return nil
+}
+
+func runAwardShow(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("award show")
+	asJSON := fs.Bool("json", false, "print the award and its datasets as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("award show <award> [--json]")
+	}
+	r, err := a.awardRegistry()
+	if err != nil {
+		return err
+	}
+	aw, err := r.Get(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	funded, err := r.Funded(ctx, aw.ID)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(map[string]any{"award": aw, "datasets": funded})
+	}
+	fmt.Fprintf(a.out, "Award:     %s\n", aw.ID)
+	fmt.Fprintf(a.out, "Funder:    %s (%s)\n", aw.FunderName, aw.FunderROR)
+	fmt.Fprintf(a.out, "Number:    %s\n", aw.Number)
+	if aw.Title != "" {
+		fmt.Fprintf(a.out, "Title:     %s\n", aw.Title)
+	}
+	if aw.Program != "" {
+		fmt.Fprintf(a.out, "Program:   %s\n", aw.Program)
+	}
+	if aw.GrantDOI != "" {
+		fmt.Fprintf(a.out, "Grant DOI: https://doi.org/%s\n", aw.GrantDOI)
+	}
+	fmt.Fprintf(a.out, "Datasets:  %d\n", len(funded))
+	for _, d := range funded {
+		fmt.Fprintf(a.out, "  %-24s %-10s %-28s %s\n", d.ID, d.State, d.DOI, d.Title)
+	}
+	return nil
+}
+
+func runAwardSearch(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("award search")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("award search <award-number>")
+	}
+	grants, err := a.crossrefClient().Search(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if len(grants) == 0 {
+		fmt.Fprintln(a.out, "No Crossref grant DOIs found")
+		return nil
+	}
+	for _, g := range grants {
+		fmt.Fprintf(a.out, "%-28s %-26s %s\n", g.DOI, g.FunderROR, g.FunderName)
+		if g.Title != "" {
+			fmt.Fprintf(a.out, "  %s\n", g.Title)
+		}
+	}
+	return nil
+}
+
+func runAwardLink(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("award link")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError("award link <dataset> <award>")
+	}
+	r, err := a.awardRegistry()
+	if err != nil {
+		return err
+	}
+	d, err := r.Link(ctx, pos[0], pos[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Linked %s to award %s\n", d.ID, pos[1])
+	if d.DOI != "" || d.ARK != "" || d.Handle != "" {
+		fmt.Fprintf(a.out, "Run 'aperture pid update %s' to register the funding with its identifier.\n", d.ID)
+	}
+	return nil
+}
+
+func runAwardUnlink(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("award unlink")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError("award unlink <dataset> <award>")
+	}
+	r, err := a.awardRegistry()
+	if err != nil {
+		return err
+	}
+	d, err := r.Unlink(ctx, pos[0], pos[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Unlinked %s from award %s\n", d.ID, pos[1])
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/citation"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("citations", &command{
+		summary: "Track works citing published datasets",
+		subcommands: map[string]*command{
+			"show": {
+				usage:   "<dataset> [--json]",
+				summary: "List the works citing a dataset",
+				run:     runCitationsShow,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"update": {
+				usage:      "[--dataset ID] [--json]",
+				summary:    "Find citations in DataCite Event Data and Crossref (default every published dataset)",
+				run:        runCitationsUpdate,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+				schedule:   7 * 24 * time.Hour,
+			},
+		},
+	})
+}
+
+// citationTracker returns a tracker finding citations in DataCite
+// Event Data and Crossref.
+func (a *app) citationTracker() (*citation.Tracker, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	crossref := citation.NewCrossref(citation.CrossrefOptions{BaseURL: a.cfg.CrossrefURL, Mailto: a.cfg.CrossrefMailto})
+	return &citation.Tracker{
+		Datasets: datasets,
+		State:    s,
+		Sources:  []citation.Source{citation.NewEventData(citation.EventDataOptions{BaseURL: a.cfg.DataCiteURL}), crossref},
+		Works:    crossref,
+		Log:      log,
+	}, nil
+}
+
+func runCitationsShow(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("citations show")
+	asJSON := fs.Bool("json", false, "print the citations as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("citations show <dataset> [--json]")
+	}
+	t, err := a.citationTracker()
+	if err != nil {
+		return err
+	}
+	rec, err := t.Get(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(rec)
+	}
+	if rec.Checked == nil {
+		fmt.Fprintf(a.out, "%s has not been checked for citations; run 'aperture citations update'\n", rec.DatasetID)
+		return nil
+	}
+	fmt.Fprintf(a.out, "%s cited by %d works (checked %s)\n", rec.DatasetID, rec.Count, rec.Checked.Format("2006-01-02"))
+	if rec.Count == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nYEAR\tDOI\tTITLE\tRELATION\tSOURCES")
+	for _, c := range rec.Citations {
+		year := "-"
+		if c.Year != 0 {
+			year = fmt.Sprint(c.Year)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", year, c.DOI, clip(c.Title, 60), c.Relation, strings.Join(c.Sources, ","))
+	}
+	return tw.Flush()
+}
+
+func runCitationsUpdate(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("citations update")
+	ref := fs.String("dataset", "", "check only dataset `ID`")
+	asJSON := fs.Bool("json", false, "print updates as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("citations update [--dataset ID] [--json]")
+	}
+	t, err := a.citationTracker()
+	if err != nil {
+		return err
+	}
+
+	var updates []citation.Update
+	if *ref != "" {
+		d, err := t.Datasets.Resolve(ctx, *ref)
+		if err != nil {
+			return err
+		}
+		if d.State != dataset.StatePublished || len(d.DOIs()) == 0 {
+			return fmt.Errorf("%s has no published DOI", d.ID)
+		}
+		up, err := t.Check(ctx, d)
+		if err != nil {
+			return err
+		}
+		updates = []citation.Update{*up}
+	} else {
+		updates, err = t.Run(ctx)
+	}
+	if *asJSON {
+		if jerr := a.printJSON(updates); jerr != nil {
+			return jerr
+		}
+		return err
+	}
+	found := 0
+	for _, up := range updates {
+		if up.Error != "" {
+			fmt.Fprintf(a.out, "%-24s failed: %s\n", up.DatasetID, up.Error)
+			continue
+		}
+		found += len(up.New)
+		fmt.Fprintf(a.out, "%-24s %d citations, %d new\n", up.DatasetID, up.Count, len(up.New))
+	}
+	fmt.Fprintf(a.out, "Found %d new citations; run 'aperture pages rebuild' to show them on landing pages\n", found)
+	return err
+}
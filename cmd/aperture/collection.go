@@ -0,0 +1,748 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/access"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/prune"
+	"github.com/scttfrdmn/aperture/internal/regions"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/storage"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+const (
+	collectionCreateUsage   = "collection create <id> --name NAME [--community] [--parent ID] [--description D] [--homepage URL] [--logo URL] [--banner URL] [--color HEX] [--steward ENTRY]..."
+	collectionUpdateUsage   = "collection update <id> [--name NAME] [--parent ID|--top-level] [--description D] [--homepage URL] [--logo URL] [--banner URL] [--color HEX] [--add-steward ENTRY]... [--remove-steward ENTRY]..."
+	collectionVersionsUsage = "collection versions <id> (--online N [--archived N] [--delete] | --none)"
+	collectionRegionsUsage  = "collection regions <id> (REGION... | --none)"
+)
+
+func init() {
+	register("collection", &command{
+		summary: "Organize datasets into communities and collections",
+		subcommands: map[string]*command{
+			"create": {
+				usage:   strings.TrimPrefix(collectionCreateUsage, "collection create "),
+				summary: "Create a collection, or with --community a community, beneath a community you steward",
+				run:     runCollectionCreate,
+				scope:   token.ScopeDatasetsWrite,
+			},
+			"update": {
+				usage:   strings.TrimPrefix(collectionUpdateUsage, "collection update "),
+				summary: "Change the metadata, branding, stewards, or community of a collection you steward",
+				run:     runCollectionUpdate,
+				scope:   token.ScopeDatasetsWrite,
+			},
+			"list": {
+				usage:   "[--json]",
+				summary: "List communities and collections as a tree",
+				run:     runCollectionList,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"show": {
+				usage:   "<id> [--json]",
+				summary: "Show a community or collection, its stewards, and its datasets",
+				run:     runCollectionShow,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"assign": {
+				usage:   "<collection> <dataset>...",
+				summary: "Move datasets into a collection you steward",
+				run:     runCollectionAssign,
+				scope:   token.ScopeDatasetsWrite,
+			},
+			"versions": {
+				usage:   strings.TrimPrefix(collectionVersionsUsage, "collection versions "),
+				summary: "Set how many old versions of a collection's datasets stay online, are archived, or are deleted",
+				run:     runCollectionVersions,
+				scope:   token.ScopeDatasetsWrite,
+			},
+			"concept": {
+				usage:   "<id> latest|versions",
+				summary: "Choose whether concept DOIs in a collection resolve to the latest version or to a listing of every version",
+				run:     runCollectionConcept,
+				scope:   token.ScopeDatasetsWrite,
+			},
+			"regions": {
+				usage:   strings.TrimPrefix(collectionRegionsUsage, "collection regions "),
+				summary: "Set the AWS regions a collection's datasets are replicated to and downloaded from, nearest the requester",
+				run:     runCollectionRegions,
+				scope:   token.ScopeDatasetsWrite,
+			},
+			"sync-regions": {
+				usage:      "[--dry-run] [--json]",
+				summary:    "Copy the files of collections with read regions to those regions",
+				run:        runCollectionSyncRegions,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+				schedule:   24 * time.Hour,
+			},
+			"prune": {
+				usage:      "[--dry-run] [--json]",
+				summary:    "Archive or delete old versions under their collections' version policies",
+				run:        runCollectionPrune,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+				schedule:   24 * time.Hour,
+			},
+			"delete": {
+				usage:   "<id>",
+				summary: "Delete an empty community or collection",
+				run:     runCollectionDelete,
+				scope:   token.ScopeDatasetsWrite,
+			},
+		},
+	})
+}
+
+func (a *app) collectionRegistry() (*collection.Registry, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	return &collection.Registry{State: s, Datasets: datasets, Log: log}, nil
+}
+
+// collectionFlags are the metadata flags shared by create and update.
+type collectionFlags struct {
+	name, parent, description, homepage, logo, banner, color *string
+}
+
+func newCollectionFlags(fs *flag.FlagSet) collectionFlags {
+	return collectionFlags{
+		name:        fs.String("name", "", "display name"),
+		parent:      fs.String("parent", "", "`ID` of the community it belongs to"),
+		description: fs.String("description", "", "what it holds"),
+		homepage:    fs.String("homepage", "", "`URL` of the department or project"),
+		logo:        fs.String("logo", "", "`URL` of its logo"),
+		banner:      fs.String("banner", "", "`URL` of its banner image"),
+		color:       fs.String("color", "", "accent color, e.g. #1a5fb4"),
+	}
+}
+
+// apply sets the fields of c whose flags were given.
+func (f collectionFlags) apply(fs *flag.FlagSet, c *collection.Collection) {
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "name":
+			c.Name = *f.name
+		case "parent":
+			c.Parent = *f.parent
+		case "description":
+			c.Description = *f.description
+		case "homepage":
+			c.Homepage = *f.homepage
+		case "logo":
+			c.Branding.LogoURL = *f.logo
+		case "banner":
+			c.Branding.BannerURL = *f.banner
+		case "color":
+			c.Branding.Color = *f.color
+		}
+	})
+}
+
+func runCollectionCreate(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("collection create")
+	flags := newCollectionFlags(fs)
+	community := fs.Bool("community", false, "create a community, which holds collections, rather than a collection")
+	var stewards stringsFlag
+	fs.Var(&stewards, "steward", "ACL entry of a steward, e.g. group:geo-curators (repeatable)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || *flags.name == "" {
+		return usageError(collectionCreateUsage)
+	}
+	c := collection.Collection{ID: pos[0], Kind: collection.KindCollection, Stewards: stewards}
+	if *community {
+		c.Kind = collection.KindCommunity
+	}
+	flags.apply(fs, &c)
+
+	r, err := a.collectionRegistry()
+	if err != nil {
+		return err
+	}
+	created, err := r.Create(ctx, c)
+	if err != nil {
+		return err
+	}
+	where := "at the top level"
+	if created.Parent != "" {
+		where = "in " + created.Parent
+	}
+	fmt.Fprintf(a.out, "Created %s %s (%s) %s\n", created.Kind, created.ID, created.Name, where)
+	return nil
+}
+
+func runCollectionUpdate(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("collection update")
+	flags := newCollectionFlags(fs)
+	topLevel := fs.Bool("top-level", false, "move it out of its community to the top level")
+	var add, remove stringsFlag
+	fs.Var(&add, "add-steward", "ACL entry of a steward to add (repeatable)")
+	fs.Var(&remove, "remove-steward", "ACL entry of a steward to remove (repeatable)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || (*topLevel && *flags.parent != "") {
+		return usageError(collectionUpdateUsage)
+	}
+	r, err := a.collectionRegistry()
+	if err != nil {
+		return err
+	}
+	c, err := r.Get(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	flags.apply(fs, c)
+	if *topLevel {
+		c.Parent = ""
+	}
+	c.Stewards = append(c.Stewards, add...)
+	for _, e := range remove {
+		entry, err := authz.ParseEntry(e)
+		if err != nil {
+			return err
+		}
+		c.Stewards = slices.DeleteFunc(c.Stewards, func(s string) bool { return s == entry })
+	}
+
+	updated, err := r.Update(ctx, *c)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Updated %s %s\n", updated.Kind, updated.ID)
+	return nil
+}
+
+func runCollectionList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("collection list")
+	asJSON := fs.Bool("json", false, "print communities and collections as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	r, err := a.collectionRegistry()
+	if err != nil {
+		return err
+	}
+	all, err := r.List(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(all)
+	}
+	if len(all) == 0 {
+		fmt.Fprintln(a.out, "No communities or collections")
+		return nil
+	}
+	datasets, err := r.Datasets.List(ctx)
+	if err != nil {
+		return err
+	}
+	counts := map[string]int{}
+	for _, d := range datasets {
+		counts[d.Collection]++
+	}
+	known := map[string]bool{}
+	for _, c := range all {
+		known[c.ID] = true
+	}
+	var walk func(parent string, depth int)
+	walk = func(parent string, depth int) {
+		for _, c := range all {
+			// Orphans, whose community is missing, are listed at the
+			// top level.
+			if c.Parent != parent && !(parent == "" && !known[c.Parent]) {
+				continue
+			}
+			label := fmt.Sprintf("%s%s", strings.Repeat("  ", depth), c.ID)
+			if c.Kind == collection.KindCommunity {
+				fmt.Fprintf(a.out, "%-40s %-10s %s\n", label, c.Kind, c.Name)
+				walk(c.ID, depth+1)
+				continue
+			}
+			fmt.Fprintf(a.out, "%-40s %-10s %s (%d datasets)\n", label, c.Kind, c.Name, counts[c.ID])
+		}
+	}
+	walk("", 0)
+	return nil
+}
+
+func runCollectionShow(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("collection show")
+	asJSON := fs.Bool("json", false, "print the collection and its datasets as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("collection show <id> [--json]")
+	}
+	r, err := a.collectionRegistry()
+	if err != nil {
+		return err
+	}
+	c, err := r.Get(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	ancestors, err := r.Ancestors(ctx, c.ID)
+	if err != nil {
+		return err
+	}
+	stewards, err := r.Stewards(ctx, c.ID)
+	if err != nil {
+		return err
+	}
+	children, err := r.Children(ctx, c.ID)
+	if err != nil {
+		return err
+	}
+	members, err := r.Members(ctx, c.ID)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(map[string]any{
+			"collection": c, "ancestors": ancestors, "stewards": stewards,
+			"children": children, "datasets": members,
+		})
+	}
+
+	path := make([]string, 0, len(ancestors)+1)
+	for _, p := range ancestors {
+		path = append(path, p.ID)
+	}
+	label := "Collection:"
+	if c.Kind == collection.KindCommunity {
+		label = "Community:"
+	}
+	fmt.Fprintf(a.out, "%-12s %s\n", label, strings.Join(append(path, c.ID), " / "))
+	fmt.Fprintf(a.out, "Name:        %s\n", c.Name)
+	if c.Description != "" {
+		fmt.Fprintf(a.out, "Description: %s\n", c.Description)
+	}
+	if c.Homepage != "" {
+		fmt.Fprintf(a.out, "Homepage:    %s\n", c.Homepage)
+	}
+	if b := c.Branding; b != (collection.Branding{}) {
+		var parts []string
+		for _, f := range []struct{ name, value string }{{"logo", b.LogoURL}, {"banner", b.BannerURL}, {"color", b.Color}} {
+			if f.value != "" {
+				parts = append(parts, f.name+" "+f.value)
+			}
+		}
+		fmt.Fprintf(a.out, "Branding:    %s\n", strings.Join(parts, ", "))
+	}
+	fmt.Fprintf(a.out, "Stewards:    %s", entryList(c.Stewards))
+	if inherited := len(stewards) - len(c.Stewards); inherited > 0 {
+		fmt.Fprintf(a.out, " (plus %s from its communities)", entryList(stewards[len(c.Stewards):]))
+	}
+	fmt.Fprintln(a.out)
+	if c.Versions != nil {
+		fmt.Fprintf(a.out, "Versions:    %s\n", c.Versions)
+	}
+	if len(c.Regions) > 0 {
+		fmt.Fprintf(a.out, "Regions:     %s\n", strings.Join(c.Regions, ", "))
+	}
+	if c.ConceptDOI != "" {
+		fmt.Fprintf(a.out, "Concept DOI: resolves to the %s\n", conceptTargets[c.ConceptDOI])
+	}
+	for _, ch := range children {
+		fmt.Fprintf(a.out, "  %-10s %-24s %s\n", ch.Kind, ch.ID, ch.Name)
+	}
+	fmt.Fprintf(a.out, "Datasets:    %d\n", len(members))
+	for _, d := range members {
+		fmt.Fprintf(a.out, "  %-24s %-10s %-16s %s\n", d.ID, d.State, d.Collection, d.Title)
+	}
+	return nil
+}
+
+func runCollectionAssign(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("collection assign")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) < 2 {
+		return usageError("collection assign <collection> <dataset>...")
+	}
+	r, err := a.collectionRegistry()
+	if err != nil {
+		return err
+	}
+	for _, ref := range pos[1:] {
+		d, err := r.Assign(ctx, pos[0], ref)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "Assigned %s to %s\n", d.ID, pos[0])
+	}
+	return nil
+}
+
+func runCollectionVersions(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("collection versions")
+	online := fs.Int("online", 0, "how many of the newest versions keep their files online, the current one included")
+	archived := fs.Int("archived", 0, "how many versions after those are archived before older ones are deleted; needs --delete")
+	del := fs.Bool("delete", false, "delete the files of older versions rather than archiving them")
+	none := fs.Bool("none", false, "keep every version online")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || *none == (*online > 0) {
+		return usageError(collectionVersionsUsage)
+	}
+	r, err := a.collectionRegistry()
+	if err != nil {
+		return err
+	}
+	c, err := r.Get(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	c.Versions = nil
+	if !*none {
+		c.Versions = &collection.VersionPolicy{Online: *online, Archived: *archived, Delete: *del}
+	}
+	updated, err := r.Update(ctx, *c)
+	if err != nil {
+		return err
+	}
+	if updated.Versions == nil {
+		fmt.Fprintf(a.out, "Every version of %s stays online\n", updated.ID)
+		return nil
+	}
+	fmt.Fprintf(a.out, "Versions of %s: %s; 'aperture collection prune' applies it\n", updated.ID, updated.Versions)
+	return nil
+}
+
+func runCollectionRegions(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("collection regions")
+	none := fs.Bool("none", false, "serve every download from the primary region")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) == 0 || *none == (len(pos) > 1) {
+		return usageError(collectionRegionsUsage)
+	}
+	r, err := a.collectionRegistry()
+	if err != nil {
+		return err
+	}
+	c, err := r.Get(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	c.Regions = pos[1:]
+	updated, err := r.Update(ctx, *c)
+	if err != nil {
+		return err
+	}
+	if len(updated.Regions) == 0 {
+		fmt.Fprintf(a.out, "Datasets in %s are downloaded from the primary region\n", updated.ID)
+		return nil
+	}
+	fmt.Fprintf(a.out, "Datasets in %s are replicated to %s; 'aperture collection sync-regions' copies them\n", updated.ID, strings.Join(updated.Regions, ", "))
+	return nil
+}
+
+func runCollectionSyncRegions(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("collection sync-regions")
+	dryRun := fs.Bool("dry-run", false, "list the files that would be copied")
+	asJSON := fs.Bool("json", false, "print the plan as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("collection sync-regions [--dry-run] [--json]")
+	}
+	if !a.regionalReads() {
+		return errors.New("read regions need the S3 storage backend on AWS")
+	}
+	r, err := a.collectionRegistry()
+	if err != nil {
+		return err
+	}
+	objects, err := a.objectStore()
+	if err != nil {
+		return err
+	}
+	s := &regions.Syncer{
+		Collections: r,
+		Source:      objects,
+		Targets:     func(region string) (regions.Target, error) { return a.regionClient(region) },
+		State:       r.State,
+		Log:         r.Log,
+	}
+	report, err := s.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := a.printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range report.Copies {
+			fmt.Fprintf(a.out, "%-16s %-20s %12d  %s -> %s\n", c.Region, c.Dataset, c.Size, c.From, c.To)
+		}
+		fmt.Fprintf(a.out, "%d files to copy (%d bytes); %d already copied\n", len(report.Copies), report.Bytes(), report.Copied)
+	}
+	if *dryRun {
+		return nil
+	}
+	res, err := s.Copy(ctx, report)
+	if !*asJSON {
+		fmt.Fprintf(a.out, "Copied %d files (%d bytes)\n", res.Copied, res.Bytes)
+	}
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, from := range slices.Sorted(maps.Keys(res.Failed)) {
+		errs = append(errs, fmt.Errorf("failed to copy %s: %s", from, res.Failed[from]))
+	}
+	return errors.Join(errs...)
+}
+
+// regionalReads reports whether downloads may be served from other
+// regions: only from AWS S3, whose buckets the regional ones mirror.
+func (a *app) regionalReads() bool {
+	cfg := a.cfg
+	return (cfg.StorageBackend == "" || cfg.StorageBackend == storage.BackendS3) && cfg.StorageEndpoint == ""
+}
+
+// regionClient returns an S3 client of the regional buckets in region.
+func (a *app) regionClient(region string) (*s3.Client, error) {
+	creds, err := aws.CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewClient(s3.Options{Region: region, Endpoint: a.cfg.AWSEndpoint, PathStyle: a.cfg.AWSEndpoint != "",
+		Credentials: creds, Metrics: a.recorder()})
+}
+
+// regionRouter returns the router serving downloads from the regional
+// copies, or nil if they are not served from other regions.
+func (a *app) regionRouter() (*regions.Router, error) {
+	if !a.regionalReads() {
+		return nil, nil
+	}
+	r, err := a.collectionRegistry()
+	if err != nil {
+		return nil, err
+	}
+	var mu sync.Mutex
+	clients := make(map[string]*s3.Client)
+	return &regions.Router{
+		Home:        cmp.Or(a.cfg.StorageRegion, a.cfg.AWSRegion),
+		Collections: r,
+		State:       r.State,
+		Presigners: func(region string) (access.Presigner, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if c, ok := clients[region]; ok {
+				return c, nil
+			}
+			c, err := a.regionClient(region)
+			if err != nil {
+				return nil, err
+			}
+			clients[region] = c
+			return c, nil
+		},
+	}, nil
+}
+
+// conceptTargets describes where concept DOIs resolve.
+var conceptTargets = map[string]string{
+	collection.ConceptLatest:   "latest version",
+	collection.ConceptVersions: "listing of every version",
+}
+
+func runCollectionConcept(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("collection concept")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 || conceptTargets[pos[1]] == "" {
+		return usageError("collection concept <id> latest|versions")
+	}
+	r, err := a.collectionRegistry()
+	if err != nil {
+		return err
+	}
+	c, err := r.Get(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	c.ConceptDOI = pos[1]
+	updated, err := r.Update(ctx, *c)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Concept DOIs in %s resolve to the %s\n", updated.ID, conceptTargets[pos[1]])
+
+	// Publish or remove the version listings and point the concept DOIs
+	// at them.
+	members, err := r.Members(ctx, updated.ID)
+	if err != nil {
+		return err
+	}
+	pages, err := a.landingBuilder()
+	if err != nil {
+		return err
+	}
+	pids, err := a.pidRegistrar()
+	if err != nil {
+		return err
+	}
+	if pids != nil {
+		if pids.Funding, err = a.awardRegistry(); err != nil {
+			return err
+		}
+	}
+	var errs []error
+	for _, d := range members {
+		if d.State != dataset.StatePublished {
+			continue
+		}
+		if _, err := pages.Publish(ctx, d.ID); err != nil {
+			errs = append(errs, fmt.Errorf("%s is updated, but %w; retry with 'aperture pages rebuild'", d.ID, err))
+			continue
+		}
+		if pids != nil && d.DOI != "" {
+			if err := pids.Update(ctx, d); err != nil {
+				errs = append(errs, fmt.Errorf("%s is updated, but %w; retry with 'aperture pid update %s'", d.ID, err, d.ID))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func runCollectionPrune(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("collection prune")
+	dryRun := fs.Bool("dry-run", false, "list the versions that would be archived or deleted")
+	asJSON := fs.Bool("json", false, "print the plan as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("collection prune [--dry-run] [--json]")
+	}
+	r, err := a.collectionRegistry()
+	if err != nil {
+		return err
+	}
+	objects, err := a.objectStore()
+	if err != nil {
+		return err
+	}
+	pages, err := a.landingBuilder()
+	if err != nil {
+		return err
+	}
+	p := &prune.Pruner{Datasets: r.Datasets, Collections: r, Objects: objects, Pages: pages, Log: r.Log}
+	pids, err := a.pidRegistrar()
+	if err != nil {
+		return err
+	}
+	if pids != nil {
+		p.PIDs = pids
+	}
+	report, err := p.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := a.printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		for _, act := range report.Actions {
+			fmt.Fprintf(a.out, "%-8s %-20s v%-4d %6d files %12d bytes  %s\n", act.Action, act.Dataset, act.Version, act.Files, act.Bytes, act.Policy)
+		}
+		archived, archivedBytes := report.Count(dataset.PruneArchive)
+		deleted, deletedBytes := report.Count(dataset.PruneDelete)
+		fmt.Fprintf(a.out, "%d versions to archive (%d bytes) and %d to delete (%d bytes) across %d datasets with a version policy\n",
+			archived, archivedBytes, deleted, deletedBytes, report.Datasets)
+	}
+	if *dryRun {
+		return nil
+	}
+	pruned, err := p.Apply(ctx, report)
+	if !*asJSON {
+		fmt.Fprintf(a.out, "Pruned %d versions\n", pruned)
+	}
+	return err
+}
+
+func runCollectionDelete(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("collection delete")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("collection delete <id>")
+	}
+	r, err := a.collectionRegistry()
+	if err != nil {
+		return err
+	}
+	if err := r.Delete(ctx, pos[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Deleted %s\n", pos[0])
+	return nil
+}
@@ -0,0 +1,310 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/metrics"
+	"github.com/scttfrdmn/aperture/internal/trace"
+)
+
+// command is a CLI command. Commands run directly, dispatch to
+// subcommands on their first argument, or both: a command with run and
+// subcommands runs itself unless its first argument names a
+// subcommand.
+type command struct {
+	// usage is the argument synopsis shown in help output
+	usage string
+
+	// summary is a one-line description shown in help output
+	summary string
+
+	// run executes the command with the remaining arguments
+	run func(ctx context.Context, a *app, args []string) error
+
+	// subcommands are dispatched on the first argument
+	subcommands map[string]*command
+
+	// scope is the machine token scope needed to run the command;
+	// commands without one are not available to machine tokens
+	scope string
+
+	// permission is the role permission needed to run the command;
+	// commands without one are governed by access control lists alone
+	permission authz.Permission
+
+	// privileged commands must be run by an authenticated person giving
+	// a justification with --reason, which is recorded with every audit
+	// entry the command writes
+	privileged bool
+
+	// schedule is how often a scheduled job runs the command; each run
+	// of a scheduled command is recorded for the status page
+	schedule time.Duration
+}
+
+// anyScope is the scope of commands every machine token may run.
+const anyScope = "*"
+
+// commands holds the top-level commands, registered from init
+// functions in the files that implement them.
+var commands = map[string]*command{}
+
+// register adds a top-level command.
+func register(name string, cmd *command) {
+	commands[name] = cmd
+}
+
+// execute runs c, dispatching to a subcommand when c has them.
+func (c *command) execute(ctx context.Context, a *app, name string, args []string) error {
+	if len(args) > 0 {
+		if sub, ok := c.subcommands[args[0]]; ok {
+			return sub.execute(ctx, a, strings.TrimSpace(name+" "+args[0]), args[1:])
+		}
+	}
+	if c.run != nil {
+		if p := identity.FromContext(ctx); p.IsMachine() && c.scope != anyScope && (c.scope == "" || !p.HasScope(c.scope)) {
+			return scopeError(p, name, c.scope)
+		}
+		if c.permission != "" {
+			if err := authz.RequirePermission(identity.FromContext(ctx), c.permission); err != nil {
+				return fmt.Errorf("'aperture %s': %w", name, err)
+			}
+		}
+		if c.privileged {
+			var err error
+			if ctx, args, err = justify(ctx, name, args); err != nil {
+				return err
+			}
+		}
+		start := time.Now()
+		ctx, span := trace.Start(ctx, "aperture "+name, trace.String("aperture.command", name))
+		err := c.run(ctx, a, args)
+		span.End(err)
+		if c.schedule != 0 {
+			a.recordRun(ctx, name, start, err)
+		}
+		if a.metrics != nil {
+			failed := 0.0
+			if err != nil {
+				failed = 1
+			}
+			a.metrics.Record(metrics.Dimensions{"Command": name},
+				metrics.Metric{Name: metrics.CommandDuration, Value: metrics.Since(start), Unit: metrics.Milliseconds},
+				metrics.Metric{Name: metrics.CommandErrors, Value: failed, Unit: metrics.Count},
+			)
+		}
+		return err
+	}
+
+	if len(args) == 0 || isHelp(args[0]) {
+		printUsage(a.out, name, c.subcommands)
+		return nil
+	}
+
+	full := strings.TrimSpace(name + " " + args[0])
+	return fmt.Errorf("unknown command %q, run '%s'", full, strings.Join(strings.Fields("aperture "+name+" help"), " "))
+}
+
+// justify takes the --reason flag of a privileged command from args
+// and returns a context recording it as the justification. Commands run
+// through sudo may rely on the impersonation's justification instead.
+func justify(ctx context.Context, name string, args []string) (context.Context, []string, error) {
+	p := identity.FromContext(ctx)
+	if p.IsZero() || p.IsMachine() {
+		return nil, nil, fmt.Errorf("%w: 'aperture %s' is privileged and must be run by a signed-in person", authz.ErrForbidden, name)
+	}
+	var reason string
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		flag := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		switch {
+		case flag == arg:
+			rest = append(rest, arg)
+		case flag == "reason" && i+1 < len(args):
+			reason = args[i+1]
+			i++
+		case strings.HasPrefix(flag, "reason="):
+			reason = strings.TrimPrefix(flag, "reason=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if strings.TrimSpace(reason) == "" {
+		if imp, ok := identity.ImpersonationFromContext(ctx); ok {
+			reason = imp.Justification
+		}
+	}
+	if strings.TrimSpace(reason) == "" {
+		return nil, nil, fmt.Errorf("'aperture %s' is privileged: give a justification with --reason TEXT", name)
+	}
+	return audit.WithJustification(ctx, reason), rest, nil
+}
+
+// scopeError explains why machine principal p may not run the named
+// command.
+func scopeError(p identity.Principal, name, scope string) error {
+	if scope == "" {
+		return fmt.Errorf("%w: 'aperture %s' is not available to machine tokens", authz.ErrForbidden, name)
+	}
+	return fmt.Errorf("%w: 'aperture %s' needs the %s scope (%s has %s)", authz.ErrForbidden, name, scope, p, strings.Join(p.Scopes, ", "))
+}
+
+// printUsage lists cmds in alphabetical order.
+func printUsage(w io.Writer, name string, cmds map[string]*command) {
+	names := make([]string, 0, len(cmds))
+	for n := range cmds {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\n", strings.TrimSpace("aperture "+name))
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	for _, n := range names {
+		synopsis := n
+		if u := cmds[n].usage; u != "" {
+			synopsis += " " + u
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", synopsis, cmds[n].summary)
+	}
+	_ = tw.Flush()
+}
+
+// isHelp reports whether arg asks for help.
+func isHelp(arg string) bool {
+	return arg == "help" || arg == "-h" || arg == "--help"
+}
+
+// newFlagSet returns a flag set for the named command that reports
+// errors instead of exiting.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("aperture "+name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+// parseArgs parses flags interleaved with positional arguments, so that
+// both "share create <doi> --expires 30d" and "share create --expires
+// 30d <doi>" work. It returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// errUsage reports incorrect command usage.
+var errUsage = errors.New("invalid usage")
+
+// usageError returns an error describing the expected usage.
+func usageError(synopsis string) error {
+	return fmt.Errorf("%w: aperture %s", errUsage, synopsis)
+}
+
+// stringsFlag is a repeatable string flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// durationValue is a flag.Value accepting Go durations plus a "d"
+// suffix for days (e.g. "30d").
+type durationValue struct {
+	d *time.Duration
+}
+
+// durationFlag defines a duration flag that accepts day suffixes.
+func durationFlag(fs *flag.FlagSet, name string, value time.Duration, usage string) *time.Duration {
+	d := value
+	fs.Var(durationValue{&d}, name, usage)
+	return &d
+}
+
+func (v durationValue) String() string {
+	if v.d == nil {
+		return ""
+	}
+	return v.d.String()
+}
+
+func (v durationValue) Set(s string) error {
+	d, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v.d = d
+	return nil
+}
+
+// parseDuration parses a Go duration or a whole number of days such as
+// "30d".
+func parseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// parseTime parses a date (YYYY-MM-DD) or an RFC 3339 timestamp.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use YYYY-MM-DD or RFC 3339", s)
+	}
+	return t, nil
+}
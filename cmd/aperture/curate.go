@@ -0,0 +1,371 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/curated"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("curate", &command{
+		summary: "Feature datasets and curate the lists shown on the homepage",
+		subcommands: map[string]*command{
+			"lists": {
+				usage:   "[--json]",
+				summary: "List the curated lists, including the featured list",
+				run:     runCurateLists,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"show": {
+				usage:   "<list> [--json]",
+				summary: "Show a list's entries in order and whether each is shown now",
+				run:     runCurateShow,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"create": {
+				usage:      "<list> --title TITLE [--description D]",
+				summary:    "Create a curated list",
+				run:        runCurateCreate,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermFeature,
+			},
+			"update": {
+				usage:      "<list> [--title TITLE] [--description D]",
+				summary:    "Change a list's title or description",
+				run:        runCurateUpdate,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermFeature,
+			},
+			"delete": {
+				usage:      "<list>",
+				summary:    "Delete a curated list",
+				run:        runCurateDelete,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermFeature,
+			},
+			"add": {
+				usage:      "<list> <dataset> [--position N] [--from TIME] [--until TIME] [--note TEXT]",
+				summary:    "Add a published dataset to a list (\"featured\" to feature it), optionally within a window",
+				run:        runCurateAdd,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermFeature,
+			},
+			"move": {
+				usage:      "<list> <dataset> <position>",
+				summary:    "Move a dataset to another position in a list",
+				run:        runCurateMove,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermFeature,
+			},
+			"remove": {
+				usage:      "<list> <dataset>",
+				summary:    "Take a dataset off a list",
+				run:        runCurateRemove,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermFeature,
+			},
+			"serve": {
+				usage:   "[--addr ADDR]",
+				summary: "Serve the lists to the homepage as JSON (GET /lists, /lists/{id}, /featured)",
+				run:     runCurateServe,
+			},
+		},
+	})
+}
+
+func (a *app) curatedRegistry() (*curated.Registry, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	return &curated.Registry{State: s, Datasets: datasets, SiteURL: a.cfg.SiteURL, Log: log}, nil
+}
+
+func runCurateLists(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("curate lists")
+	asJSON := fs.Bool("json", false, "print the lists as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	r, err := a.curatedRegistry()
+	if err != nil {
+		return err
+	}
+	lists, err := r.Lists(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(lists)
+	}
+	for _, l := range lists {
+		_, items, err := r.Shown(ctx, l.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "%-24s %-40s %d entries, %d shown\n", l.ID, l.Title, len(l.Entries), len(items))
+	}
+	return nil
+}
+
+func runCurateShow(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("curate show")
+	asJSON := fs.Bool("json", false, "print the list as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("curate show <list> [--json]")
+	}
+	r, err := a.curatedRegistry()
+	if err != nil {
+		return err
+	}
+	l, items, err := r.Shown(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(l)
+	}
+	shown := map[string]bool{}
+	for _, it := range items {
+		shown[it.ID] = true
+	}
+	fmt.Fprintf(a.out, "%s: %s\n", l.ID, l.Title)
+	if l.Description != "" {
+		fmt.Fprintf(a.out, "  %s\n", l.Description)
+	}
+	if len(l.Entries) == 0 {
+		fmt.Fprintln(a.out, "No entries")
+		return nil
+	}
+	for i, e := range l.Entries {
+		status := "shown"
+		if !shown[e.DatasetID] {
+			status = "hidden"
+		}
+		fmt.Fprintf(a.out, "%3d. %-24s %-7s %s\n", i+1, e.DatasetID, status, window(e))
+		if e.Note != "" {
+			fmt.Fprintf(a.out, "     %s\n", e.Note)
+		}
+	}
+	return nil
+}
+
+// window describes when e is shown.
+func window(e curated.Entry) string {
+	switch {
+	case e.From == nil && e.Until == nil:
+		return "always"
+	case e.From == nil:
+		return "until " + e.Until.Format(time.RFC3339)
+	case e.Until == nil:
+		return "from " + e.From.Format(time.RFC3339)
+	}
+	return e.From.Format(time.RFC3339) + " to " + e.Until.Format(time.RFC3339)
+}
+
+func runCurateCreate(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("curate create")
+	title := fs.String("title", "", "heading shown above the list")
+	description := fs.String("description", "", "introduction to the list")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || *title == "" {
+		return usageError("curate create <list> --title TITLE [--description D]")
+	}
+	r, err := a.curatedRegistry()
+	if err != nil {
+		return err
+	}
+	l, err := r.Create(ctx, pos[0], *title, *description)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Created list %s (%s)\n", l.ID, l.Title)
+	return nil
+}
+
+func runCurateUpdate(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("curate update")
+	title := fs.String("title", "", "heading shown above the list")
+	description := fs.String("description", "", "introduction to the list")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || (*title == "" && *description == "") {
+		return usageError("curate update <list> [--title TITLE] [--description D]")
+	}
+	r, err := a.curatedRegistry()
+	if err != nil {
+		return err
+	}
+	l, err := r.Describe(ctx, pos[0], *title, *description)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Updated list %s (%s)\n", l.ID, l.Title)
+	return nil
+}
+
+func runCurateDelete(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("curate delete")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("curate delete <list>")
+	}
+	r, err := a.curatedRegistry()
+	if err != nil {
+		return err
+	}
+	if err := r.Delete(ctx, pos[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Deleted list %s\n", pos[0])
+	return nil
+}
+
+func runCurateAdd(ctx context.Context, a *app, args []string) error {
+	const usage = "curate add <list> <dataset> [--position N] [--from TIME] [--until TIME] [--note TEXT]"
+	fs := newFlagSet("curate add")
+	position := fs.Int("position", 0, "1-based position in the list (default the end, or unchanged if listed)")
+	from := fs.String("from", "", "show from this date (YYYY-MM-DD) or RFC 3339 time")
+	until := fs.String("until", "", "stop showing at this date or time")
+	note := fs.String("note", "", "short blurb shown with the dataset")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError(usage)
+	}
+	p := curated.Placement{Position: *position, Note: *note}
+	if *from != "" {
+		if p.From, err = parseTime(*from); err != nil {
+			return err
+		}
+	}
+	if *until != "" {
+		if p.Until, err = parseTime(*until); err != nil {
+			return err
+		}
+	}
+	r, err := a.curatedRegistry()
+	if err != nil {
+		return err
+	}
+	l, err := r.Add(ctx, pos[0], pos[1], p)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Listed %s on %s (%d entries)\n", pos[1], l.ID, len(l.Entries))
+	return nil
+}
+
+func runCurateMove(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("curate move")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 3 {
+		return usageError("curate move <list> <dataset> <position>")
+	}
+	n, err := strconv.Atoi(pos[2])
+	if err != nil {
+		return fmt.Errorf("invalid position %q", pos[2])
+	}
+	r, err := a.curatedRegistry()
+	if err != nil {
+		return err
+	}
+	if _, err := r.Move(ctx, pos[0], pos[1], n); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Moved %s to position %d on %s\n", pos[1], n, pos[0])
+	return nil
+}
+
+func runCurateRemove(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("curate remove")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError("curate remove <list> <dataset>")
+	}
+	r, err := a.curatedRegistry()
+	if err != nil {
+		return err
+	}
+	if _, err := r.Remove(ctx, pos[0], pos[1]); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Removed %s from %s\n", pos[1], pos[0])
+	return nil
+}
+
+func runCurateServe(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("curate serve")
+	addr := fs.String("addr", "127.0.0.1:8088", "address to listen on")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("curate serve [--addr ADDR]")
+	}
+	r, err := a.curatedRegistry()
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: a.instrument("curate", curated.NewHandler(r))}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(a.out, "Serving curated lists at http://%s/lists\n", *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
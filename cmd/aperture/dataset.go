@@ -0,0 +1,327 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/deposit"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("dataset", &command{
+		summary: "Manage dataset ownership and publication",
+		subcommands: map[string]*command{
+			"owner": {
+				usage:      "<dataset> <pi-email>",
+				summary:    "Name the PI responsible for a dataset you deposit on their behalf",
+				run:        runDatasetOwner,
+				permission: authz.PermDeposit,
+			},
+			"publish": {
+				usage:      "<dataset>",
+				summary:    "Publish a dataset, or ask its owner to confirm publication",
+				run:        runDatasetPublish,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermDeposit,
+			},
+			"version": {
+				usage:      "<dataset>",
+				summary:    "Start a new version of a published dataset as a draft",
+				run:        runDatasetVersion,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermDeposit,
+			},
+			"snapshot": {
+				usage:      "<dataset> [--source s3://BUCKET/PREFIX] [--label TEXT]",
+				summary:    "Copy a dataset's changing S3 source as it is now into a new draft version",
+				run:        runDatasetSnapshot,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermDeposit,
+			},
+			"versions": {
+				usage:   "<doi|dataset> [--json]",
+				summary: "List a dataset's versions with their DOIs",
+				run:     runDatasetVersions,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"diff": {
+				usage:   "<doi|dataset>@vN <doi|dataset>@vM [--json]",
+				summary: "Show the file and metadata changes between two versions",
+				run:     runDatasetDiff,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"status": {
+				usage:   "<doi|dataset> [--json]",
+				summary: "Show the file and metadata changes staged for a dataset's next version",
+				run:     runDatasetStatus,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"confirm": {
+				usage:      "<dataset>",
+				summary:    "Confirm publication of a dataset deposited on your behalf",
+				run:        runDatasetConfirm,
+				permission: authz.PermDeposit,
+			},
+			"related": {
+				usage:   "<dataset> [--size N] [--json]",
+				summary: "Recommend datasets related by similar metadata or co-citation",
+				run:     runDatasetRelated,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"relate": {
+				usage:      "<dataset> <relation> <identifier>",
+				summary:    "Record a related work, e.g. IsCitedBy 10.1234/paper",
+				run:        runDatasetRelate,
+				permission: authz.PermCurate,
+			},
+			"unrelate": {
+				usage:      "<dataset> <identifier>",
+				summary:    "Remove a related work",
+				run:        runDatasetUnrelate,
+				permission: authz.PermCurate,
+			},
+			"language": {
+				usage:      "<dataset> [--title TAG] [--description TAG]",
+				summary:    "Tag the languages of a dataset's title and description, e.g. es or fr-CA",
+				run:        runDatasetLanguage,
+				permission: authz.PermCurate,
+			},
+			"supersede": {
+				usage:      "<old-doi|dataset> --by <new-doi|dataset> [--reason TEXT] [--warn-downloads]",
+				summary:    "Mark a published dataset obsolete and link it to its replacement",
+				run:        runDatasetSupersede,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermCurate,
+			},
+			"delete": {
+				usage:      "<dataset> --reason TEXT",
+				summary:    "Delete a draft dataset that was never published",
+				run:        runDatasetDelete,
+				permission: authz.PermDeposit,
+				privileged: true,
+			},
+		},
+	})
+}
+
+// updateDataset applies update to a dataset after checking that the
+// caller may manage it, stores it, and records the audit details update
+// returns. Observers such as the search indexer pick up the change.
+// Changes to a published dataset are staged in its workspace, and
+// update is given the staged metadata to change.
+func updateDataset(ctx context.Context, a *app, action, ref string, update func(*dataset.Dataset) map[string]string) (*dataset.Dataset, error) {
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	d, err := datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+		return nil, err
+	}
+	var details map[string]string
+	if err := d.Edit(time.Now().UTC(), func(d *dataset.Dataset) { details = update(d) }); err != nil {
+		return nil, err
+	}
+	if d.Workspace != nil {
+		details["staged"] = fmt.Sprint(d.Draft().Number)
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	if err := audit.Record(ctx, log, action, d.ID, details); err != nil {
+		return nil, err
+	}
+	printStaged(a, d)
+	return d, nil
+}
+
+// printStaged tells the caller that changes to a published dataset
+// were staged for its next version rather than applied.
+func printStaged(a *app, d *dataset.Dataset) {
+	if d.Workspace == nil {
+		return
+	}
+	fmt.Fprintf(a.out, "Staged for version %d of %s; review with 'aperture dataset status %s' and apply with 'aperture dataset publish %s'\n",
+		d.Draft().Number, d.ID, d.ID, d.ID)
+}
+
+// depositManager returns the deposit manager. Landing pages are
+// rendered on publication when withPages is set.
+func (a *app) depositManager(withPages bool) (*deposit.Manager, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := a.notifier()
+	if err != nil {
+		return nil, err
+	}
+	m := &deposit.Manager{
+		State:      s,
+		Datasets:   dataset.NewStore(s),
+		Notifier:   notifier,
+		ConfirmURL: a.cfg.APIURL,
+		Log:        log,
+	}
+	sc, err := a.scanner()
+	if err != nil {
+		return nil, err
+	}
+	if sc != nil {
+		m.Scans = sc
+	}
+	sl, err := a.sealer()
+	if err != nil {
+		return nil, err
+	}
+	if sl != nil {
+		m.Seals = sl
+	}
+	r, err := a.pidRegistrar()
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		if r.Funding, err = a.awardRegistry(); err != nil {
+			return nil, err
+		}
+		m.PIDs = r
+		m.Versions = r
+		m.Media = r
+	}
+	if a.cfg.RAiDToken != "" {
+		if m.RAiDs, err = a.raidManager(); err != nil {
+			return nil, err
+		}
+	}
+	if withPages {
+		if m.Pages, err = a.landingBuilder(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func runDatasetOwner(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset owner")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError("dataset owner <dataset> <pi-email>")
+	}
+	m, err := a.depositManager(false)
+	if err != nil {
+		return err
+	}
+	d, err := m.SetOwner(ctx, pos[0], pos[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "%s is owned by %s and deposited by %s; publishing it requires %s's confirmation\n",
+		d.ID, d.Owner, d.Depositor, d.Owner)
+	return nil
+}
+
+func runDatasetPublish(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset publish")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("dataset publish <dataset>")
+	}
+	m, err := a.depositManager(true)
+	if err != nil {
+		return err
+	}
+	d, c, err := m.Publish(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		fmt.Fprintf(a.out, "Asked %s to confirm publication of %s (by %s)\n", c.Owner, d.ID, c.Expires.Format(time.DateOnly))
+		return nil
+	}
+	if v := d.Current(); v != nil && v.Number > 1 {
+		fmt.Fprintf(a.out, "Published version %d of %s\n", v.Number, d.ID)
+		return nil
+	}
+	fmt.Fprintf(a.out, "Published %s\n", d.ID)
+	return nil
+}
+
+func runDatasetConfirm(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset confirm")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("dataset confirm <dataset>")
+	}
+	m, err := a.depositManager(true)
+	if err != nil {
+		return err
+	}
+	d, err := m.Confirm(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Published %s, deposited on your behalf by %s\n", d.ID, d.Depositor)
+	return nil
+}
+
+func runDatasetDelete(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset delete")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("dataset delete <dataset> --reason TEXT")
+	}
+	m, err := a.depositManager(false)
+	if err != nil {
+		return err
+	}
+	d, err := m.Delete(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Deleted %s; its files are removed by the next 'aperture storage gc'\n", d.ID)
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/deposit"
+)
+
+func init() {
+	register("delegation", &command{
+		summary: "Let lab managers deposit datasets on your behalf",
+		subcommands: map[string]*command{
+			"grant": {
+				usage:      "<depositor-email> [--note TEXT]",
+				summary:    "Allow a depositor to name you as the owner of datasets they deposit",
+				run:        runDelegationGrant,
+				permission: authz.PermDeposit,
+			},
+			"revoke": {
+				usage:   "<depositor-email>",
+				summary: "Withdraw a delegation",
+				run:     runDelegationRevoke,
+			},
+			"list": {
+				usage:   "[--json]",
+				summary: "List the delegations you granted or received",
+				run:     runDelegationList,
+			},
+			"serve": {
+				usage:   "[--addr ADDR]",
+				summary: "Serve the publication confirmation links emailed to owners (/deposit/confirm/{token})",
+				run:     runDelegationServe,
+			},
+		},
+	})
+}
+
+func runDelegationGrant(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("delegation grant")
+	note := fs.String("note", "", "why the delegation exists, e.g. the lab")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("delegation grant <depositor-email> [--note TEXT]")
+	}
+	m, err := a.depositManager(false)
+	if err != nil {
+		return err
+	}
+	dl, err := m.Delegate(ctx, pos[0], *note)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "%s may now deposit datasets owned by %s; you confirm each publication\n", dl.Depositor, dl.PI)
+	return nil
+}
+
+func runDelegationRevoke(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("delegation revoke")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("delegation revoke <depositor-email>")
+	}
+	m, err := a.depositManager(false)
+	if err != nil {
+		return err
+	}
+	if err := m.Undelegate(ctx, pos[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Revoked the delegation to %s\n", pos[0])
+	return nil
+}
+
+func runDelegationList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("delegation list")
+	asJSON := fs.Bool("json", false, "print delegations as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	m, err := a.depositManager(false)
+	if err != nil {
+		return err
+	}
+	list, err := m.Delegations(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(list)
+	}
+	for _, dl := range list {
+		fmt.Fprintf(a.out, "%-32s -> %-32s since %s  %s\n", dl.Depositor, dl.PI, dl.CreatedAt.Format(time.DateOnly), dl.Note)
+	}
+	return nil
+}
+
+func runDelegationServe(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("delegation serve")
+	addr := fs.String("addr", "127.0.0.1:8083", "address to listen on")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("delegation serve [--addr ADDR]")
+	}
+	m, err := a.depositManager(true)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: a.instrument("delegation", deposit.NewHandler(m))}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(a.out, "Serving publication confirmations at http://%s/deposit/confirm/{token}\n", *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/cloudformation"
+	"github.com/scttfrdmn/aperture/internal/cloudwatch"
+	"github.com/scttfrdmn/aperture/internal/deploy"
+	"github.com/scttfrdmn/aperture/internal/iam"
+	"github.com/scttfrdmn/aperture/internal/lambda"
+	"github.com/scttfrdmn/aperture/internal/preflight"
+	"github.com/scttfrdmn/aperture/internal/ssm"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+const deployUsage = "deploy [--dry-run] [--estimate] [--skip-preflight] [--var-file FILE]... [--json] --reason TEXT"
+
+func init() {
+	register("deploy", &command{
+		usage:      strings.TrimPrefix(deployUsage, "deploy "),
+		summary:    "Deploy the platform's infrastructure with the Terraform stack or CloudFormation template built into the CLI",
+		run:        runDeploy,
+		permission: authz.PermDeploy,
+		privileged: true,
+		subcommands: map[string]*command{
+			"promote": {
+				usage:      strings.TrimPrefix(promoteUsage, "deploy promote "),
+				summary:    "Promote the stack of one environment to the next, running smoke tests and rolling back on failure",
+				run:        runPromote,
+				permission: authz.PermDeploy,
+				privileged: true,
+			},
+			"preflight": {
+				usage:      "[--json]",
+				summary:    "Check the account-level prerequisites of a deployment: bucket names, service quotas, SES, DataCite, and IAM permissions",
+				run:        runPreflight,
+				permission: authz.PermDeploy,
+			},
+		},
+	})
+}
+
+// deployer returns the deployer of the configured environment with the
+// configured backend. Terraform works in a directory under the state
+// directory and keeps its state in the configured bucket, and the API
+// functions it publishes take traffic in the configured steps;
+// CloudFormation deploys the stack named by the bucket prefix.
+func (a *app) deployer(ctx context.Context) (*deploy.Deployer, error) {
+	var backend deploy.Backend
+	var traffic *deploy.TrafficShift
+	switch a.cfg.DeployBackend {
+	case "cloudformation":
+		creds, err := aws.CredentialsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		backend = &deploy.CloudFormation{
+			Stack:     aperture.CloudFormation,
+			StackName: a.cfg.BucketPrefix(),
+			Client:    cloudformation.NewClient(cloudformation.Options{Region: a.cfg.AWSRegion, Credentials: creds}),
+		}
+	default:
+		if a.cfg.TerraformStateBucket == "" {
+			return nil, fmt.Errorf("no Terraform state bucket is configured; set APERTURE_TF_STATE_BUCKET")
+		}
+		creds, err := a.credentials(ctx, "compute")
+		if err != nil {
+			return nil, fmt.Errorf("shifting API traffic to new Lambda versions needs credentials: %w", err)
+		}
+		traffic = &deploy.TrafficShift{
+			Lambda:       lambda.NewClient(lambda.Options{Region: a.cfg.AWSRegion, Credentials: creds}),
+			CloudWatch:   cloudwatch.NewClient(cloudwatch.Options{Region: a.cfg.AWSRegion, Credentials: creds}),
+			Steps:        a.cfg.TrafficSteps,
+			StepDuration: time.Duration(a.cfg.TrafficStepMinutes) * time.Minute,
+		}
+		source := a.cfg.SourceDir
+		if source == "" {
+			wd, err := os.Getwd()
+			if err != nil {
+				return nil, err
+			}
+			if source, err = deploy.SourceDir(wd); err != nil {
+				return nil, fmt.Errorf("%w; set APERTURE_SOURCE_DIR to a checkout", err)
+			}
+		}
+		backend = &deploy.Terraform{
+			Stack:  aperture.Stack,
+			Dir:    filepath.Join(a.cfg.StateDir, "deploy", a.cfg.Environment),
+			Runner: &deploy.Exec{Path: a.cfg.TerraformPath, Stderr: os.Stderr},
+			Build:  &deploy.GoBuild{Source: source, Stderr: os.Stderr},
+			StateBackend: map[string]string{
+				"bucket": a.cfg.TerraformStateBucket,
+				"key":    a.cfg.ProjectName + "/" + a.cfg.Environment + "/terraform.tfstate",
+				"region": a.cfg.AWSRegion,
+			},
+		}
+	}
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	registry, err := a.registry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &deploy.Deployer{
+		Backend:  backend,
+		Out:      a.out,
+		State:    s,
+		Datasets: datasets,
+		Traffic:  traffic,
+		Registry: registry,
+		Log:      log,
+	}, nil
+}
+
+// credentials returns the credentials deploying layer of the stack,
+// one of config.DeployLayers: those of the environment, or those of
+// the layer's deployment role, assumed with them.
+func (a *app) credentials(ctx context.Context, layer string) (aws.Credentials, error) {
+	creds, err := aws.CredentialsFromEnv()
+	role := a.cfg.DeployRoles[layer]
+	if err != nil || role == "" {
+		return creds, err
+	}
+	creds, err = iam.NewClient(iam.Options{Credentials: creds}).AssumeRole(ctx, role, a.cfg.BucketPrefix()+"-deploy")
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("failed to assume the %s deployment role: %w", layer, err)
+	}
+	return creds, nil
+}
+
+// registry returns the configured output registry, in the compute
+// account, or nil if there is none.
+func (a *app) registry(ctx context.Context) (*deploy.Registry, error) {
+	if a.cfg.OutputRegistry == "" {
+		return nil, nil
+	}
+	creds, err := a.credentials(ctx, "compute")
+	if err != nil {
+		return nil, fmt.Errorf("the output registry needs credentials: %w", err)
+	}
+	return &deploy.Registry{
+		SSM:     ssm.NewClient(ssm.Options{Region: a.cfg.AWSRegion, Credentials: creds}),
+		Project: a.cfg.ProjectName,
+	}, nil
+}
+
+// discoverOutputs sets the settings left empty from the outputs
+// published for the configured environment, if there is an output
+// registry. An environment not deployed yet has none.
+func (a *app) discoverOutputs(ctx context.Context) error {
+	r, err := a.registry(ctx)
+	if r == nil || err != nil {
+		return err
+	}
+	outputs, err := r.Outputs(ctx, a.cfg.Environment)
+	if err != nil {
+		return fmt.Errorf("failed to discover the outputs of %s: %w", a.cfg.Environment, err)
+	}
+	a.cfg.ApplyOutputs(outputs)
+	return nil
+}
+
+// deployOptions returns the options deploying the configured
+// environment.
+func (a *app) deployOptions() deploy.Options {
+	return deploy.Options{
+		Environment:  a.cfg.Environment,
+		Version:      Version,
+		Commit:       Commit,
+		Vars:         deploy.ConfigVars(a.cfg),
+		BucketPrefix: a.cfg.BucketPrefix(),
+	}
+}
+
+func runDeploy(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("deploy")
+	dryRun := fs.Bool("dry-run", false, "show the changes a deployment would make")
+	estimate := fs.Bool("estimate", false, "estimate the monthly cost of the planned stack before applying it")
+	skipPreflight := fs.Bool("skip-preflight", false, "deploy even if preflight checks fail")
+	var varFiles stringsFlag
+	fs.Var(&varFiles, "var-file", "a further Terraform variables file, e.g. with ORCID or SAML settings (repeatable; Terraform only)")
+	asJSON := fs.Bool("json", false, "print the recorded deployment as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError(deployUsage)
+	}
+	opts := a.deployOptions()
+	opts.VarFiles, opts.DryRun = varFiles, *dryRun
+	d, err := a.deployer(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		d.Out = os.Stderr
+	} else {
+		prev, err := d.Current(ctx, opts.Environment)
+		switch {
+		case errors.Is(err, state.ErrNotFound):
+			fmt.Fprintf(a.out, "Deploying %s for the first time\n", opts.Environment)
+		case err != nil:
+			return err
+		default:
+			fmt.Fprintf(a.out, "%s runs v%s (%s stack %.12s), deployed %s by %s\n",
+				opts.Environment, prev.Version, cmp.Or(prev.Backend, "terraform"), prev.StackHash, prev.DeployedAt.Format("2 Jan 2006 15:04 MST"), prev.DeployedBy)
+		}
+	}
+
+	r, err := a.preflight(ctx, d)
+	if err != nil {
+		return err
+	}
+	printPreflight(d.Out, r)
+	if r.Failed() && !*skipPreflight {
+		return fmt.Errorf("%d preflight checks of %s failed; fix them, or deploy anyway with --skip-preflight", r.Count(preflight.Fail), opts.Environment)
+	}
+
+	if err := d.Plan(ctx, opts); err != nil {
+		return err
+	}
+	if *estimate {
+		e, err := a.estimate(ctx, d, opts)
+		if err != nil {
+			return err
+		}
+		printEstimate(d.Out, opts.Environment, e)
+	}
+	if *dryRun {
+		return nil
+	}
+	dep, err := d.Apply(ctx, opts)
+	if errors.Is(err, deploy.ErrTrafficRolledBack) {
+		return fmt.Errorf("deployed v%s to %s, but its API functions still serve the previous versions: %w", dep.Version, dep.Environment, err)
+	}
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(dep)
+	}
+	fmt.Fprintf(a.out, "Deployed v%s (stack %.12s) to %s\n", dep.Version, dep.StackHash, dep.Environment)
+	for _, name := range slices.Sorted(maps.Keys(dep.Outputs)) {
+		fmt.Fprintf(a.out, "  %-40s %s\n", name, dep.Outputs[name])
+	}
+	return nil
+}
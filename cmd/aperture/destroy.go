@@ -0,0 +1,110 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/deploy"
+)
+
+const destroyUsage = "destroy [--force-delete-data] [--backup FILE] [--var-file FILE]... --reason TEXT"
+
+func init() {
+	register("destroy", &command{
+		usage:      strings.TrimPrefix(destroyUsage, "destroy "),
+		summary:    "Tear down the deployed infrastructure, refusing to delete published data unless forced",
+		run:        runDestroy,
+		permission: authz.PermDeploy,
+		privileged: true,
+	})
+}
+
+func runDestroy(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("destroy")
+	force := fs.Bool("force-delete-data", false, "delete buckets holding published datasets too, after typing the project name to confirm")
+	backupPath := fs.String("backup", "", "where to write the metadata backup (default: under the state directory)")
+	var varFiles stringsFlag
+	fs.Var(&varFiles, "var-file", "a further Terraform variables file the deployment used (repeatable; Terraform only)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError(destroyUsage)
+	}
+	opts := a.deployOptions()
+	opts.VarFiles, opts.ForceDeleteData = varFiles, *force
+	d, err := a.deployer(ctx)
+	if err != nil {
+		return err
+	}
+
+	published, err := d.Published(ctx, opts.BucketPrefix)
+	if err != nil {
+		return err
+	}
+	for _, b := range slices.Sorted(maps.Keys(published)) {
+		fmt.Fprintf(a.out, "s3://%s holds %d published datasets: %s\n", b, len(published[b]), strings.Join(published[b], ", "))
+	}
+	if len(published) > 0 && !*force {
+		return fmt.Errorf("refusing to destroy %s, whose buckets hold published datasets; rerun with --force-delete-data to delete them", opts.Environment)
+	}
+	if *force {
+		fmt.Fprintf(a.out, "This deletes every bucket of %s with the data in it. Type the project name (%s) to confirm: ", opts.Environment, a.cfg.ProjectName)
+		line, err := bufio.NewReader(a.in).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("not confirmed; nothing was destroyed")
+		}
+		if strings.TrimSpace(line) != a.cfg.ProjectName {
+			return fmt.Errorf("%q is not the project name; nothing was destroyed", strings.TrimSpace(line))
+		}
+	}
+
+	path := cmp.Or(*backupPath, filepath.Join(a.cfg.StateDir, "backups",
+		opts.Environment+"-"+time.Now().UTC().Format("20060102T150405Z")+".json"))
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create the metadata backup: %w", err)
+	}
+	err = d.Destroy(ctx, opts, f)
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to write the metadata backup: %w", cerr)
+	}
+	if errors.Is(err, deploy.ErrPublishedData) {
+		os.Remove(path)
+		return err
+	}
+	fmt.Fprintf(a.out, "Wrote the metadata backup to %s\n", path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Destroyed %s\n", opts.Environment)
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/devenv"
+)
+
+func init() {
+	register("dev", &command{
+		summary: "Develop against a local environment in LocalStack instead of AWS",
+		subcommands: map[string]*command{
+			"up": {
+				usage:   "[--endpoint URL] [--env-file FILE]",
+				summary: "Create the platform's buckets, tables and queues in LocalStack and write the settings pointing the CLI and servers at them",
+				run:     runDevUp,
+			},
+		},
+	})
+}
+
+func runDevUp(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dev up")
+	endpoint := fs.String("endpoint", cmp.Or(a.cfg.AWSEndpoint, devenv.DefaultEndpoint), "LocalStack's URL")
+	envFile := fs.String("env-file", filepath.Join(a.cfg.StateDir, "localstack.env"), "where to write the settings")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("dev up [--endpoint URL] [--env-file FILE]")
+	}
+	env := &devenv.Env{Endpoint: *endpoint, Region: a.cfg.AWSRegion, Project: a.cfg.ProjectName, Environment: a.cfg.Environment}
+	fmt.Fprintf(a.out, "Creating the buckets and tables of %s in LocalStack at %s\n", a.cfg.BucketPrefix(), env.Endpoint)
+	if err := env.Up(ctx, a.out); err != nil {
+		return err
+	}
+
+	settings := env.Settings()
+	var b strings.Builder
+	fmt.Fprintln(&b, "# Written by 'aperture dev up': points the CLI and its servers at LocalStack")
+	for _, k := range slices.Sorted(maps.Keys(settings)) {
+		fmt.Fprintf(&b, "export %s=%s\n", k, settings[k])
+	}
+	if err := os.MkdirAll(filepath.Dir(*envFile), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*envFile, []byte(b.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to write the settings: %w", err)
+	}
+	fmt.Fprintf(a.out, "Wrote the settings to %s; load them with:\n  . %s\n", *envFile, *envFile)
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("doi", &command{
+		summary: "Manage DataCite DOIs",
+		subcommands: map[string]*command{
+			"bulk-update": {
+				usage:      "--input FILE [--budget N]",
+				summary:    "Update many DOIs from a JSON Lines file",
+				run:        runDOIBulkUpdate,
+				scope:      token.ScopeDOIWrite,
+				permission: authz.PermPublish,
+			},
+		},
+	})
+}
+
+// newDataCiteClient returns a DataCite client using the configured
+// credentials and rate limits, capped at budget requests (0 for no cap).
+func (a *app) newDataCiteClient(budget int) *datacite.Client {
+	return datacite.NewClient(datacite.Options{
+		BaseURL:      a.cfg.DataCiteURL,
+		MDSURL:       a.cfg.DataCiteMDSURL,
+		RepositoryID: a.cfg.DataCiteRepositoryID,
+		Password:     a.cfg.DataCitePassword,
+		UsageToken:   a.cfg.DataCiteUsageToken,
+		Limiter: datacite.NewLimiter(datacite.LimiterOptions{
+			Rate:        a.cfg.DataCiteRateLimit,
+			Burst:       a.cfg.DataCiteConcurrency,
+			Concurrency: a.cfg.DataCiteConcurrency,
+			Budget:      budget,
+		}),
+	})
+}
+
+// printDataCiteStats reports limiter activity after a bulk operation.
+func (a *app) printDataCiteStats(c *datacite.Client) {
+	s := c.Limiter().Stats()
+	fmt.Fprintf(a.out, "DataCite requests: %d (throttled: %d, retries: %d, waited: %s)\n",
+		s.Requests, s.Throttled, s.Retries, s.Waited.Round(time.Millisecond))
+	if r := c.Limiter().Remaining(); r >= 0 {
+		fmt.Fprintf(a.out, "Budget remaining: %d\n", r)
+	}
+}
+
+func runDOIBulkUpdate(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("doi bulk-update")
+	input := fs.String("input", "", "JSON Lines file of DOI attributes, one record per line")
+	budget := fs.Int("budget", 0, "maximum number of DataCite requests (0 for no limit)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *input == "" {
+		return usageError("doi bulk-update --input FILE [--budget N]")
+	}
+
+	f, err := os.Open(*input)
+	if err != nil {
+		return fmt.Errorf("failed to open input: %w", err)
+	}
+	defer f.Close()
+
+	client := a.newDataCiteClient(*budget)
+	defer a.printDataCiteStats(client)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		updated  int
+		failures int
+	)
+	work := make(chan datacite.Attributes)
+	for i := 0; i < max(a.cfg.DataCiteConcurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for attrs := range work {
+				_, err := client.UpdateDOI(ctx, attrs.DOI, attrs)
+				mu.Lock()
+				if err != nil {
+					failures++
+					fmt.Fprintf(os.Stderr, "%s: %v\n", attrs.DOI, err)
+				} else {
+					updated++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	scanErr := feedAttributes(ctx, f, work, client.Limiter())
+	close(work)
+	wg.Wait()
+
+	fmt.Fprintf(a.out, "Updated %d DOIs, %d failed\n", updated, failures)
+	if scanErr != nil {
+		return scanErr
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d DOI updates failed", failures)
+	}
+	return nil
+}
+
+// feedAttributes decodes one record per line and sends it to work,
+// stopping early once the budget is spent.
+func feedAttributes(ctx context.Context, f *os.File, work chan<- datacite.Attributes, limiter *datacite.Limiter) error {
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var attrs datacite.Attributes
+		if err := json.Unmarshal(scanner.Bytes(), &attrs); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if attrs.DOI == "" {
+			return fmt.Errorf("line %d: missing doi", line)
+		}
+		if limiter.Remaining() == 0 {
+			return fmt.Errorf("stopped at line %d: %w", line, datacite.ErrBudgetExhausted)
+		}
+		select {
+		case work <- attrs:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	return nil
+}
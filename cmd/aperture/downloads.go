@@ -0,0 +1,415 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/badge"
+	"github.com/scttfrdmn/aperture/internal/citation"
+	"github.com/scttfrdmn/aperture/internal/counter"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/seal"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("downloads", &command{
+		summary: "Count dataset usage and report it under the COUNTER Code of Practice",
+		subcommands: map[string]*command{
+			"serve": {
+				usage:   "[--addr ADDR]",
+				summary: "Serve the counted download redirect endpoint (GET /d/{dataset}/v{n}/{file}) and usage statistics (GET /stats/...), and badges (GET /badges/..., /embed/...)",
+				run:     runDownloadsServe,
+			},
+			"report": {
+				usage:   "[--month YYYY-MM] [--dataset ID] [--robots FILE] [--json]",
+				summary: "Report COUNTER dataset investigation and request metrics",
+				run:     runDownloadsReport,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"ingest": {
+				usage:      "[--format cloudfront|s3] [--robots FILE] SOURCE...",
+				summary:    "Count requests and page views from CloudFront or S3 access logs (files, directories, or s3://bucket/prefix)",
+				run:        runDownloadsIngest,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+				schedule:   24 * time.Hour,
+			},
+			"submit": {
+				usage:      "[--month YYYY-MM] [--robots FILE] [--dry-run]",
+				summary:    "Submit a month's SUSHI usage report to the DataCite usage hub (default last month)",
+				run:        runDownloadsSubmit,
+				scope:      token.ScopeDOIWrite,
+				permission: authz.PermMaintain,
+				schedule:   31 * 24 * time.Hour,
+			},
+			"robots": {
+				summary: "Configure how robots are told apart from people",
+				subcommands: map[string]*command{
+					"show": {
+						usage:   "[--json]",
+						summary: "Show the robot rules and whether the event log is classified under them",
+						run:     runDownloadsRobotsShow,
+						scope:   token.ScopeDatasetsRead,
+					},
+					"set": {
+						usage:      "[--list FILE|--no-list] [--deny PATTERN]... [--allow PATTERN]... [--remove PATTERN]... [--max-per-minute N] [--max-per-session N]",
+						summary:    "Change the robot list, user agent rules, and rate limits",
+						run:        runDownloadsRobotsSet,
+						scope:      token.ScopeDatasetsWrite,
+						permission: authz.PermMaintain,
+					},
+				},
+			},
+			"reclassify": {
+				usage:      "[--dry-run]",
+				summary:    "Apply the current robot rules to every logged event, correcting historical counts",
+				run:        runDownloadsReclassify,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+			},
+		},
+	})
+}
+
+// downloadLog returns the download event log.
+func (a *app) downloadLog() (*counter.FileLog, error) {
+	return counter.NewFileLog(filepath.Join(a.cfg.StateDir, "downloads.log"))
+}
+
+func runDownloadsServe(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("downloads serve")
+	addr := fs.String("addr", "127.0.0.1:8082", "address to listen on")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("downloads serve [--addr ADDR]")
+	}
+	if a.cfg.MediaURL == "" {
+		return fmt.Errorf("APERTURE_MEDIA_URL must be set to the public media CDN URL")
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	log, err := a.downloadLog()
+	if err != nil {
+		return err
+	}
+	h := counter.NewHandler(datasets, log, a.cfg.MediaURL)
+	h.OnLogError = func(err error) { fmt.Fprintln(a.out, "Warning:", err) }
+	if h.Filter, err = a.robotFilter(ctx, ""); err != nil {
+		return err
+	}
+	an, err := a.analytics()
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/d/", h)
+	mux.Handle("/stats/", counter.NewStatsHandler(an))
+	tracker, err := a.citationTracker()
+	if err != nil {
+		return err
+	}
+	mux.Handle("/citations/", citation.NewHandler(tracker))
+	badges := badge.NewHandler(badge.Options{
+		Datasets:  datasets,
+		Usage:     an,
+		Citations: tracker,
+		BaseURL:   cmp.Or(a.cfg.DownloadURL, "http://"+*addr),
+		SiteURL:   a.cfg.SiteURL,
+	})
+	mux.Handle("/badges/", badges)
+	mux.Handle("/embed/", badges)
+	sealer, err := a.sealer()
+	if err != nil {
+		return err
+	}
+	if sealer != nil {
+		h.Seals = sealer
+		mux.Handle("/seals/", seal.NewHandler(sealer, datasets))
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: a.instrument("downloads", mux)}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(a.out, "Counting downloads at http://%s/d/ and redirecting to %s\n", *addr, a.cfg.MediaURL)
+	fmt.Fprintf(a.out, "Serving usage statistics at http://%s/stats/ and citations at http://%s/citations/\n", *addr, *addr)
+	fmt.Fprintf(a.out, "Serving badges at http://%s/badges/ and embeddable snippets at http://%s/embed/\n", *addr, *addr)
+	if sealer != nil {
+		fmt.Fprintf(a.out, "Serving seals at http://%s/seals/ and refusing files that no longer match them\n", *addr)
+	}
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func runDownloadsReport(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("downloads report")
+	month := fs.String("month", "", "report one month (YYYY-MM); all time if empty")
+	datasetID := fs.String("dataset", "", "report one dataset")
+	robotsFile := fs.String("robots", "", "exclude user agents matching the robots list in `FILE` instead of the saved list")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("downloads report [--month YYYY-MM] [--dataset ID] [--robots FILE] [--json]")
+	}
+	robots, err := a.robotFilter(ctx, *robotsFile)
+	if err != nil {
+		return err
+	}
+
+	var from, to time.Time
+	if *month != "" {
+		if from, err = time.Parse("2006-01", *month); err != nil {
+			return fmt.Errorf("invalid month %q (want YYYY-MM)", *month)
+		}
+		to = from.AddDate(0, 1, 0)
+	}
+
+	log, err := a.downloadLog()
+	if err != nil {
+		return err
+	}
+	events, err := log.Events(ctx)
+	if err != nil {
+		return err
+	}
+	robots.Classify(events)
+	metrics := counter.Report(events, from, to)
+	if *datasetID != "" {
+		filtered := metrics[:0]
+		for _, m := range metrics {
+			if m.DatasetID == *datasetID || m.DOI == *datasetID {
+				filtered = append(filtered, m)
+			}
+		}
+		metrics = filtered
+	}
+
+	if *asJSON {
+		return a.printJSON(metrics)
+	}
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "DATASET\tDOI\ttotal-dataset-investigations\tunique-dataset-investigations\ttotal-dataset-requests\tunique-dataset-requests\t")
+	for _, m := range metrics {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t\n", m.DatasetID, m.DOI, m.TotalInvestigations, m.UniqueInvestigations, m.TotalRequests, m.UniqueRequests)
+	}
+	return tw.Flush()
+}
+
+// robotFilter compiles the saved robot rules, with the robots list in
+// listFile in place of the saved list if listFile is not empty.
+func (a *app) robotFilter(ctx context.Context, listFile string) (*counter.Filter, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	rules, err := counter.LoadRules(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if listFile != "" {
+		if rules.List, err = counter.ReadRobotsFile(listFile); err != nil {
+			return nil, err
+		}
+	}
+	return rules.Filter()
+}
+
+func runDownloadsIngest(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("downloads ingest")
+	format := fs.String("format", counter.FormatCloudFront, "access log format: cloudfront or s3")
+	robotsFile := fs.String("robots", "", "mark user agents matching the robots list in `FILE` as robots, instead of the saved list")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) == 0 {
+		return usageError("downloads ingest [--format cloudfront|s3] [--robots FILE] SOURCE...")
+	}
+	robots, err := a.robotFilter(ctx, *robotsFile)
+	if err != nil {
+		return err
+	}
+	s, err := a.store()
+	if err != nil {
+		return err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	log, err := a.downloadLog()
+	if err != nil {
+		return err
+	}
+	in := &counter.Ingester{Datasets: datasets, State: s, Log: log, Filter: robots}
+
+	var logs, events, skipped int
+	ingest := func(name string, r io.Reader) error {
+		rec, err := in.Ingest(ctx, name, *format, r)
+		if errors.Is(err, counter.ErrIngested) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		logs++
+		events += rec.Events
+		skipped += rec.Skipped
+		return nil
+	}
+	var errs []error
+	for _, src := range pos {
+		if rest, ok := strings.CutPrefix(src, "s3://"); ok {
+			bucket, prefix, _ := strings.Cut(rest, "/")
+			errs = append(errs, a.ingestS3(ctx, bucket, prefix, ingest))
+			continue
+		}
+		errs = append(errs, filepath.WalkDir(src, func(path string, d iofs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			return ingest(abs, f)
+		}))
+	}
+	fmt.Fprintf(a.out, "Ingested %d access logs: %d events, %d unparsed lines\n", logs, events, skipped)
+	return errors.Join(errs...)
+}
+
+// ingestS3 ingests each access log under prefix in bucket, named by its
+// S3 URI.
+func (a *app) ingestS3(ctx context.Context, bucket, prefix string, ingest func(string, io.Reader) error) error {
+	client, err := a.s3Client()
+	if err != nil {
+		return err
+	}
+	return client.ListObjects(ctx, bucket, prefix, func(obj s3.ObjectInfo) error {
+		body, err := client.GetObject(ctx, bucket, obj.Key)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		return ingest("s3://"+bucket+"/"+obj.Key, body)
+	})
+}
+
+func runDownloadsSubmit(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("downloads submit")
+	month := fs.String("month", "", "report `YYYY-MM` (default last month)")
+	robotsFile := fs.String("robots", "", "exclude user agents matching the robots list in `FILE` instead of the saved list")
+	dryRun := fs.Bool("dry-run", false, "print the report instead of submitting it")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("downloads submit [--month YYYY-MM] [--robots FILE] [--dry-run]")
+	}
+	from := time.Now().UTC().AddDate(0, -1, 0)
+	from = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if *month != "" {
+		if from, err = time.Parse("2006-01", *month); err != nil {
+			return fmt.Errorf("invalid month %q (want YYYY-MM)", *month)
+		}
+	}
+	if a.cfg.Publisher == "" {
+		return fmt.Errorf("APERTURE_PUBLISHER must be set to report usage")
+	}
+	robots, err := a.robotFilter(ctx, *robotsFile)
+	if err != nil {
+		return err
+	}
+
+	s, err := a.store()
+	if err != nil {
+		return err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	log, err := a.downloadLog()
+	if err != nil {
+		return err
+	}
+	events, err := log.Events(ctx)
+	if err != nil {
+		return err
+	}
+	audit, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	sub := &counter.Submitter{
+		Datasets:     datasets,
+		State:        s,
+		Hub:          a.newDataCiteClient(0),
+		Log:          audit,
+		RepositoryID: a.cfg.DataCiteRepositoryID,
+		Publisher:    a.cfg.Publisher,
+		SiteURL:      a.cfg.SiteURL,
+	}
+	if *dryRun {
+		robots.Classify(events)
+		r, err := sub.Build(ctx, from, counter.Report(events, from, from.AddDate(0, 1, 0)))
+		if err != nil {
+			return err
+		}
+		return a.printJSON(r)
+	}
+	if a.cfg.DataCiteUsageToken == "" {
+		return fmt.Errorf("DATACITE_USAGE_TOKEN must be set to submit usage reports")
+	}
+	rec, err := sub.Submit(ctx, from, events, robots)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Submitted usage report %s for %s: %d datasets\n", rec.ReportID, rec.Month, rec.Datasets)
+	return nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/dua"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+func init() {
+	register("dua", &command{
+		summary: "Manage data use agreements",
+		subcommands: map[string]*command{
+			"attach": {
+				usage:      "<dataset> --file PATH --version V [--title TEXT]",
+				summary:    "Require acceptance of an agreement before access to a restricted dataset",
+				run:        runDUAAttach,
+				permission: authz.PermCurate,
+			},
+			"accept": {
+				usage:   "<dataset> --name NAME --email EMAIL",
+				summary: "Record acceptance of a dataset's current agreement",
+				run:     runDUAAccept,
+			},
+			"export": {
+				usage:      "[--dataset ID] [--format csv|json]",
+				summary:    "Export acceptance records for compliance audits",
+				run:        runDUAExport,
+				permission: authz.PermCurate,
+			},
+		},
+	})
+}
+
+// agreements returns the agreement acceptance registry.
+func (a *app) agreements() (*dua.Registry, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	return dua.NewRegistry(s, log), nil
+}
+
+func runDUAAttach(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dua attach")
+	file := fs.String("file", "", "agreement document to publish")
+	version := fs.String("version", "", "agreement version; changing it requires everyone to accept again")
+	title := fs.String("title", "", "agreement title shown to users")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || *file == "" || *version == "" {
+		return usageError("dua attach <dataset> --file PATH --version V [--title TEXT]")
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if d.Access != storage.AccessRestricted && (d.Embargo == nil || d.Embargo.ReleaseAccess != storage.AccessRestricted) {
+		return fmt.Errorf("data use agreements apply to restricted datasets; %s is %s", d.ID, d.Access)
+	}
+
+	body, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	ext := filepath.Ext(*file)
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	key := "datasets/" + d.ID + "/agreement-" + *version + ext
+
+	objects, err := a.s3Client()
+	if err != nil {
+		return err
+	}
+	if err := objects.PutObject(ctx, a.cfg.FrontendBucket(), key, body, contentType); err != nil {
+		return fmt.Errorf("failed to upload agreement: %w", err)
+	}
+
+	d.Agreement = &dataset.Agreement{
+		Version:  *version,
+		Title:    *title,
+		Document: "/" + key,
+		SHA256:   aws.HashPayload(body),
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		return err
+	}
+	pages, err := a.landingBuilder()
+	if err != nil {
+		return err
+	}
+	if _, err := pages.Publish(ctx, d.ID); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Attached agreement version %s to %s\n", *version, d.ID)
+	return nil
+}
+
+func runDUAAccept(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dua accept")
+	name := fs.String("name", "", "full name of the person accepting")
+	email := fs.String("email", "", "email address of the person accepting")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || *name == "" || *email == "" {
+		return usageError("dua accept <dataset> --name NAME --email EMAIL")
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	registry, err := a.agreements()
+	if err != nil {
+		return err
+	}
+	acc, err := registry.Accept(ctx, d, *name, *email)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "%s <%s> accepted version %s of the agreement for %s\n", acc.Name, acc.Email, acc.AgreementVersion, d.ID)
+	return nil
+}
+
+func runDUAExport(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dua export")
+	id := fs.String("dataset", "", "only export acceptances for this dataset")
+	format := fs.String("format", "csv", "output format: csv or json")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	registry, err := a.agreements()
+	if err != nil {
+		return err
+	}
+	datasetID := *id
+	if datasetID != "" {
+		datasets, err := a.datasets()
+		if err != nil {
+			return err
+		}
+		d, err := datasets.Resolve(ctx, datasetID)
+		if err != nil {
+			return err
+		}
+		datasetID = d.ID
+	}
+	list, err := registry.List(ctx, datasetID)
+	if err != nil {
+		return err
+	}
+	return dua.Export(a.out, list, *format)
+}
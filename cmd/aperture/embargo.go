@@ -0,0 +1,309 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/embargo"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("embargo", &command{
+		summary: "Manage dataset embargoes",
+		subcommands: map[string]*command{
+			"set": {
+				usage:      "<dataset> --until DATE",
+				summary:    "Withhold a dataset's files until DATE",
+				run:        runEmbargoSet,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermEmbargo,
+			},
+			"list": {
+				summary: "List embargoed datasets",
+				run:     runEmbargoList,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"release": {
+				usage:      "<dataset>",
+				summary:    "Release a dataset whose embargo date has passed",
+				run:        runEmbargoRelease,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermEmbargo,
+			},
+			"extend": {
+				usage:      "<dataset> --until DATE --reason TEXT",
+				summary:    "Move a dataset's release date later",
+				run:        runEmbargoExtend,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermEmbargo,
+			},
+			"lift": {
+				usage:      "<dataset> --reason TEXT",
+				summary:    "Release a dataset before its embargo date",
+				run:        runEmbargoLift,
+				permission: authz.PermLiftEmbargo,
+				privileged: true,
+			},
+			"history": {
+				usage:   "<dataset>",
+				summary: "Show a dataset's embargo history with reasons",
+				run:     runEmbargoHistory,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"release-due": {
+				usage:      "[--dry-run]",
+				summary:    "Release every dataset whose embargo date has passed",
+				run:        runEmbargoReleaseDue,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermEmbargo,
+				schedule:   time.Hour,
+			},
+		},
+	})
+}
+
+// embargoManager returns a manager that moves files with the configured
+// layout and updates landing pages and, if configured, DataCite.
+func (a *app) embargoManager() (*embargo.Manager, error) {
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	objects, err := a.objectStore()
+	if err != nil {
+		return nil, err
+	}
+	layout, err := a.layout()
+	if err != nil {
+		return nil, err
+	}
+	pages, err := a.landingBuilder()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &embargo.Manager{
+		Datasets: datasets,
+		Objects:  objects,
+		Layout:   layout,
+		Pages:    pages,
+		Log:      log,
+	}
+	if a.cfg.DataCiteRepositoryID != "" {
+		m.DOIs = a.newDataCiteClient(0)
+	}
+	return m, nil
+}
+
+func runEmbargoSet(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("embargo set")
+	until := fs.String("until", "", "release date (YYYY-MM-DD or RFC 3339)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || *until == "" {
+		return usageError("embargo set <dataset> --until DATE")
+	}
+	t, err := parseTime(*until)
+	if err != nil {
+		return err
+	}
+
+	m, err := a.embargoManager()
+	if err != nil {
+		return err
+	}
+	d, err := m.Set(ctx, pos[0], t)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Embargoed %s until %s (releases as %s)\n",
+		d.ID, d.Embargo.Until.Format(time.DateOnly), d.Embargo.ReleaseAccess)
+	return nil
+}
+
+func runEmbargoList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("embargo list")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	all, err := datasets.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, d := range all {
+		if d.Embargo == nil {
+			continue
+		}
+		status := "embargoed"
+		if !d.Embargoed(now) {
+			status = "due"
+		}
+		fmt.Fprintf(a.out, "%-20s %s  %-9s %s\n", d.ID, d.Embargo.Until.Format(time.DateOnly), status, d.Title)
+	}
+	return nil
+}
+
+func runEmbargoRelease(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("embargo release")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("embargo release <dataset>")
+	}
+
+	m, err := a.embargoManager()
+	if err != nil {
+		return err
+	}
+	d, err := m.Release(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Released %s as %s\n", d.ID, d.Access)
+	return nil
+}
+
+func runEmbargoExtend(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("embargo extend")
+	until := fs.String("until", "", "new release date (YYYY-MM-DD or RFC 3339)")
+	reason := fs.String("reason", "", "why the embargo is extended (required)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || *until == "" || *reason == "" {
+		return usageError("embargo extend <dataset> --until DATE --reason TEXT")
+	}
+	t, err := parseTime(*until)
+	if err != nil {
+		return err
+	}
+
+	m, err := a.embargoManager()
+	if err != nil {
+		return err
+	}
+	d, err := m.Extend(ctx, pos[0], t, *reason)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Extended embargo on %s until %s\n", d.ID, d.Embargo.Until.Format(time.DateOnly))
+	return nil
+}
+
+func runEmbargoLift(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("embargo lift")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("embargo lift <dataset> --reason TEXT")
+	}
+
+	m, err := a.embargoManager()
+	if err != nil {
+		return err
+	}
+	d, err := m.Lift(ctx, pos[0], audit.Justification(ctx))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Lifted embargo on %s; released as %s\n", d.ID, d.Access)
+	return nil
+}
+
+func runEmbargoHistory(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("embargo history")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("embargo history <dataset>")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+
+	for _, e := range d.History {
+		if !strings.HasPrefix(e.Action, "embargo.") {
+			continue
+		}
+		line := fmt.Sprintf("%s  %-16s %-24s until %s", e.Time.Format(time.RFC3339), e.Action, e.Actor, e.Details["until"])
+		if e.Reason != "" {
+			line += "  reason: " + e.Reason
+		}
+		fmt.Fprintln(a.out, line)
+	}
+	return nil
+}
+
+func runEmbargoReleaseDue(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("embargo release-due")
+	dryRun := fs.Bool("dry-run", false, "list datasets that would be released")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	m, err := a.embargoManager()
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		due, err := m.Due(ctx)
+		if err != nil {
+			return err
+		}
+		for _, d := range due {
+			fmt.Fprintf(a.out, "%-20s due %s\n", d.ID, d.Embargo.Until.Format(time.DateOnly))
+		}
+		fmt.Fprintf(a.out, "Would release %d datasets\n", len(due))
+		return nil
+	}
+
+	released, err := m.ReleaseDue(ctx)
+	for _, d := range released {
+		fmt.Fprintf(a.out, "%-20s released as %s\n", d.ID, d.Access)
+	}
+	fmt.Fprintf(a.out, "Released %d datasets\n", len(released))
+	return err
+}
@@ -0,0 +1,59 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/scttfrdmn/aperture/internal/deploy"
+	"github.com/scttfrdmn/aperture/internal/estimate"
+	"github.com/scttfrdmn/aperture/internal/pricing"
+)
+
+// estimate prices the resources the stack holds once the changes d
+// planned are applied, for the usage the configuration projects. The
+// OpenSearch domain the repository indexes into is priced if there is
+// one.
+func (a *app) estimate(ctx context.Context, d *deploy.Deployer, opts deploy.Options) (*estimate.Estimate, error) {
+	resources, err := d.Resources(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := a.credentials(ctx, "compute")
+	if err != nil {
+		return nil, err
+	}
+	usage := estimate.Usage{
+		StorageGB:          a.cfg.EstimateStorageGB,
+		TransferGB:         a.cfg.EstimateTransferGB,
+		Requests:           a.cfg.EstimateRequests,
+		SearchInstanceType: a.cfg.EstimateSearchInstanceType,
+	}
+	if a.cfg.OpenSearchURL != "" {
+		usage.SearchInstances = a.cfg.EstimateSearchInstances
+	}
+	return estimate.Monthly(ctx, pricing.NewClient(pricing.Options{Credentials: creds}), a.cfg.AWSRegion, resources, usage)
+}
+
+// printEstimate writes the lines of e, then its total.
+func printEstimate(w io.Writer, environment string, e *estimate.Estimate) {
+	fmt.Fprintf(w, "Estimated monthly cost of %s in %s, at on-demand prices:\n", environment, e.Region)
+	for _, l := range e.Lines {
+		fmt.Fprintf(w, "  %-11s %-48s %14.2f %-10s $%10.2f\n", l.Service, l.Item, l.Quantity, l.Unit, l.MonthlyUSD)
+	}
+	fmt.Fprintf(w, "  %-11s %-48s %14s %-10s $%10.2f\n", "Total", "", "", "", e.MonthlyUSD)
+}
@@ -0,0 +1,154 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/oaipmh"
+	"github.com/scttfrdmn/aperture/internal/search"
+)
+
+// formatDataCiteXML exports DataCite records, built from the catalog
+// because the index holds only the fields searched.
+const formatDataCiteXML = "datacite-xml"
+
+// exportSearch writes the metadata of the datasets matching q to a.out:
+// one page of them, or every match if all is set. The number exported
+// is reported on standard error, keeping the output clean for pipes.
+func exportSearch(ctx context.Context, a *app, s *search.Searcher, q search.Query, format string, all bool) error {
+	var ex search.Exporter
+	var err error
+	if format == formatDataCiteXML {
+		ex, err = a.dataCiteExporter(ctx, a.out)
+	} else {
+		ex, err = search.NewExporter(format, a.out)
+	}
+	if err != nil {
+		return err
+	}
+	n := 0
+	write := func(d *search.Document) error {
+		n++
+		return ex.Write(d)
+	}
+	if all {
+		err = s.Scan(ctx, q, write)
+	} else {
+		var res *search.Results
+		if res, err = s.Search(ctx, &q); err == nil {
+			for i := range res.Hits {
+				if err = write(&res.Hits[i].Document); err != nil {
+					break
+				}
+			}
+		}
+	}
+	if cerr := ex.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if dc, ok := ex.(*dataCiteExporter); ok {
+		n -= len(dc.skipped)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d datasets\n", n)
+	return nil
+}
+
+// dataCiteExporter writes DataCite XML records in a resources element.
+type dataCiteExporter struct {
+	ctx      context.Context
+	datasets *dataset.Store
+	oai      *oaipmh.Handler
+	w        io.Writer
+	enc      *xml.Encoder
+	started  bool
+
+	// skipped lists the datasets without a DOI, which a DataCite record
+	// requires
+	skipped []string
+}
+
+func (a *app) dataCiteExporter(ctx context.Context, w io.Writer) (*dataCiteExporter, error) {
+	if a.cfg.Publisher == "" {
+		return nil, fmt.Errorf("APERTURE_PUBLISHER must be set to export DataCite records")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	awards, err := a.awardRegistry()
+	if err != nil {
+		return nil, err
+	}
+	h := oaipmh.NewHandler(datasets, oaipmh.Options{SiteURL: a.cfg.SiteURL, Publisher: a.cfg.Publisher})
+	h.Funding = awards
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return &dataCiteExporter{ctx: ctx, datasets: datasets, oai: h, w: w, enc: enc}, nil
+}
+
+func (e *dataCiteExporter) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	if _, err := io.WriteString(e.w, xml.Header); err != nil {
+		return err
+	}
+	return e.enc.EncodeToken(xml.StartElement{Name: xml.Name{Local: "resources"}})
+}
+
+func (e *dataCiteExporter) Write(doc *search.Document) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	d, err := e.datasets.Get(e.ctx, doc.ID)
+	if err != nil {
+		return err
+	}
+	if d.DOI == "" {
+		e.skipped = append(e.skipped, d.ID)
+		return nil
+	}
+	return e.oai.WriteDataCite(e.ctx, e.enc, d)
+}
+
+func (e *dataCiteExporter) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	if err := e.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "resources"}}); err != nil {
+		return err
+	}
+	if err := e.enc.Close(); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(e.w, "\n"); err != nil {
+		return err
+	}
+	if len(e.skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d datasets without a DOI: %s\n", len(e.skipped), strings.Join(e.skipped, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/config"
+	"github.com/scttfrdmn/aperture/internal/federation"
+	"github.com/scttfrdmn/aperture/internal/oidc"
+)
+
+func init() {
+	register("federation", &command{
+		summary: "Set up institutional sign-in through a SAML identity provider",
+		subcommands: map[string]*command{
+			"sp": {
+				summary: "Print the service provider details to register with your IdP",
+				run:     runFederationSP,
+			},
+			"check": {
+				usage:   "<metadata-url|file> [--entity-id ID]",
+				summary: "Check an identity provider's SAML metadata before federating",
+				run:     runFederationCheck,
+			},
+			"groups": {
+				summary: "Show which groups each affiliation joins",
+				run:     runFederationGroups,
+			},
+		},
+	})
+}
+
+// affiliationGroups returns the configured affiliation mapping, or the
+// default one.
+func affiliationGroups(cfg *config.Config) map[string][]string {
+	if cfg.AffiliationGroups != nil {
+		return cfg.AffiliationGroups
+	}
+	return federation.DefaultAffiliationGroups
+}
+
+func runFederationSP(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("federation sp")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if a.cfg.CognitoUserPoolID == "" {
+		return fmt.Errorf("APERTURE_COGNITO_USER_POOL_ID must be set")
+	}
+	provider, err := oidc.Discover(ctx, nil, a.cfg.Issuer())
+	if err != nil {
+		return err
+	}
+	sp, err := federation.CognitoServiceProvider(a.cfg.CognitoUserPoolID, provider.AuthorizationEndpoint)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "Entity ID:   %s\n", sp.EntityID)
+	fmt.Fprintf(a.out, "ACS URL:     %s (HTTP-POST)\n", sp.ACSURL)
+	fmt.Fprintln(a.out, "\nAttributes to release:")
+	for _, attr := range federation.Attributes {
+		need := "optional"
+		if attr.Required {
+			need = "required"
+		}
+		fmt.Fprintf(a.out, "  %-28s %-36s %s\n", attr.FriendlyName, attr.Name, need)
+	}
+	return nil
+}
+
+func runFederationCheck(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("federation check")
+	entityID := fs.String("entity-id", "", "identity provider to check in an aggregate (e.g. InCommon)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("federation check <metadata-url|file> [--entity-id ID]")
+	}
+
+	data, err := readMetadata(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	idp, err := federation.FindIdP(data, *entityID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "Entity ID:   %s\n", idp.EntityID)
+	if idp.DisplayName != "" {
+		fmt.Fprintf(a.out, "Name:        %s\n", idp.DisplayName)
+	}
+	if len(idp.Scopes) > 0 {
+		fmt.Fprintf(a.out, "Scopes:      %s\n", strings.Join(idp.Scopes, ", "))
+	}
+	for _, b := range slices.Sorted(maps.Keys(idp.SSO)) {
+		fmt.Fprintf(a.out, "SSO:         %s (%s)\n", idp.SSO[b], b[strings.LastIndex(b, ":")+1:])
+	}
+	for _, c := range idp.Certificates {
+		fmt.Fprintf(a.out, "Certificate: %s, expires %s\n", c.Subject.CommonName, c.NotAfter.Format(time.DateOnly))
+	}
+
+	if problems := idp.Check(time.Now()); len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintf(a.out, "FAIL  %s\n", p)
+		}
+		return fmt.Errorf("%s is not ready for federation (%d problems)", idp.EntityID, len(problems))
+	}
+	fmt.Fprintln(a.out, "\nReady to federate. Set saml_metadata_url (or saml_metadata_file) in the cognito module.")
+	return nil
+}
+
+// readMetadata reads SAML metadata from a URL or a local file.
+func readMetadata(ctx context.Context, src string) ([]byte, error) {
+	if !strings.HasPrefix(src, "https://") && !strings.HasPrefix(src, "http://") {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata: %w", err)
+		}
+		return data, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metadata request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch metadata: HTTP %d", resp.StatusCode)
+	}
+	// Federation aggregates run to tens of megabytes.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 256<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	return data, nil
+}
+
+func runFederationGroups(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("federation groups")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	mapping := affiliationGroups(a.cfg)
+	for _, aff := range slices.Sorted(maps.Keys(mapping)) {
+		fmt.Fprintf(a.out, "%-12s %s\n", aff, strings.Join(mapping[aff], ", "))
+	}
+	return nil
+}
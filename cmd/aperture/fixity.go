@@ -0,0 +1,367 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/fixity"
+	"github.com/scttfrdmn/aperture/internal/repair"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("fixity", &command{
+		summary: "Verify stored objects against their recorded checksums",
+		subcommands: map[string]*command{
+			"run": {
+				usage:      "[--limit N] [--max-bytes N] [--dataset REF] [--interval DURATION] [--sample F] [--digest sha256|blake3] [--backfill] [--json]",
+				summary:    "Verify the objects due for a check, oldest check first",
+				run:        runFixityRun,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+				schedule:   24 * time.Hour,
+			},
+			"repair": {
+				usage:      "[--dataset REF] [--object s3://BUCKET/KEY] [--limit N] [--tier Standard|Bulk|Expedited] [--days N] [--dry-run] [--json]",
+				summary:    "Replace corrupt and missing objects with their preservation copies",
+				run:        runFixityRepair,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+			},
+			"status": {
+				usage:   "[--interval DURATION] [--json]",
+				summary: "Report the integrity of the repository from the last check of every object",
+				run:     runFixityStatus,
+				scope:   token.ScopeDatasetsRead,
+			},
+		},
+	})
+}
+
+// fixityChecker returns a checker of the repository's objects that
+// verifies each every interval.
+func (a *app) fixityChecker(interval time.Duration) (*fixity.Checker, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	objects, err := a.objectStore()
+	if err != nil {
+		return nil, err
+	}
+	events, err := a.premisEvents(s)
+	if err != nil {
+		return nil, err
+	}
+	return &fixity.Checker{Objects: objects, Datasets: datasets, State: s, Interval: interval, Events: events}, nil
+}
+
+func runFixityRun(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("fixity run")
+	limit := fs.Int("limit", 0, "verify at most `N` objects (0 for no limit)")
+	maxBytes := fs.Int64("max-bytes", 0, "read back at most `N` bytes (0 for no limit)")
+	ref := fs.String("dataset", "", "verify every object of one dataset, due or not")
+	interval := durationFlag(fs, "interval", fixity.DefaultInterval, "how often each object is verified")
+	sample := fs.Float64("sample", 0.01, "fraction of objects with stored checksums also read back")
+	digest := fs.String("digest", string(fixity.DigestBLAKE3), "read objects back with `ALGORITHM`: blake3 where recorded, or sha256 always")
+	backfill := fs.Bool("backfill", false, "record the BLAKE3 digests of objects read back that have none")
+	asJSON := fs.Bool("json", false, "print the run as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("fixity run [--limit N] [--max-bytes N] [--dataset REF] [--interval DURATION] [--sample F] [--digest sha256|blake3] [--backfill] [--json]")
+	}
+	if *sample < 0 || *sample > 1 {
+		return fmt.Errorf("--sample must be between 0 and 1")
+	}
+	algorithm, err := fixity.ParseAlgorithm(*digest)
+	if err != nil {
+		return err
+	}
+	c, err := a.fixityChecker(*interval)
+	if err != nil {
+		return err
+	}
+	c.Sample, c.Digest, c.Backfill = *sample, algorithm, *backfill
+	run, err := c.Run(ctx, fixity.Options{Limit: *limit, MaxBytes: *maxBytes, Dataset: *ref})
+	if err != nil {
+		return err
+	}
+
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	for _, f := range run.Failures {
+		if err := audit.Record(ctx, log, "fixity.failed", "s3://"+f.Bucket+"/"+f.Key, map[string]string{
+			"status":  string(f.Status),
+			"dataset": f.Refs[0].Dataset,
+			"detail":  f.Detail,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		if err := a.printJSON(run); err != nil {
+			return err
+		}
+	} else {
+		for _, f := range run.Failures {
+			printFixityResult(a, f)
+		}
+		fmt.Fprintf(a.out, "Checked %d objects (%d bytes read): %d ok, %d corrupt, %d missing, %d unverified; %d still due\n",
+			run.Checked, run.BytesRead, run.OK, run.Corrupt, run.Missing, run.Unverified, run.Remaining)
+		if run.Backfilled > 0 {
+			fmt.Fprintf(a.out, "Recorded BLAKE3 digests of %d objects\n", run.Backfilled)
+		}
+	}
+	if n := run.Corrupt + run.Missing; n > 0 {
+		return fmt.Errorf("%d objects failed verification", n)
+	}
+	return nil
+}
+
+func runFixityStatus(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("fixity status")
+	interval := durationFlag(fs, "interval", fixity.DefaultInterval, "how old a check may be before it is overdue")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("fixity status [--interval DURATION] [--json]")
+	}
+	c, err := a.fixityChecker(*interval)
+	if err != nil {
+		return err
+	}
+	rep, err := c.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(rep)
+	}
+	printFixityReport(a, fs, rep)
+	return nil
+}
+
+func runFixityRepair(ctx context.Context, a *app, args []string) error {
+	const synopsis = "fixity repair [--dataset REF] [--object s3://BUCKET/KEY] [--limit N] [--tier Standard|Bulk|Expedited] [--days N] [--dry-run] [--json]"
+	fs := newFlagSet("fixity repair")
+	ref := fs.String("dataset", "", "repair the objects of one dataset")
+	object := fs.String("object", "", "repair one object, `s3://BUCKET/KEY`")
+	limit := fs.Int("limit", 0, "replace at most `N` objects (0 for no limit)")
+	tier := fs.String("tier", s3.TierStandard, "retrieval tier of archived copies: Standard, Bulk, or Expedited")
+	days := fs.Int("days", repair.DefaultRestoreDays, "how many `days` restored copies stay readable")
+	dryRun := fs.Bool("dry-run", false, "show what would be done without restoring or replacing anything")
+	asJSON := fs.Bool("json", false, "print the repairs as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError(synopsis)
+	}
+	if !slices.Contains([]string{s3.TierStandard, s3.TierBulk, s3.TierExpedited}, *tier) {
+		return fmt.Errorf("unknown --tier %q (want Standard, Bulk, or Expedited)", *tier)
+	}
+	opts := repair.Options{Dataset: *ref, Limit: *limit, DryRun: *dryRun}
+	if *object != "" {
+		var ok bool
+		if opts.Bucket, opts.Key, ok = strings.Cut(strings.TrimPrefix(*object, "s3://"), "/"); !ok || opts.Key == "" {
+			return fmt.Errorf("invalid --object %q: want s3://BUCKET/KEY", *object)
+		}
+	}
+
+	checker, err := a.fixityChecker(fixity.DefaultInterval)
+	if err != nil {
+		return err
+	}
+	rep, err := a.replicator(0)
+	if err != nil {
+		return err
+	}
+	if len(rep.Key) == 0 {
+		return fmt.Errorf("no replica key; set APERTURE_REPLICA_KEY to decrypt the copies")
+	}
+	archive, err := a.replicaClient()
+	if err != nil {
+		return err
+	}
+	store, err := a.objectStore()
+	if err != nil {
+		return err
+	}
+	s, err := a.store()
+	if err != nil {
+		return err
+	}
+	events, err := a.premisEvents(s)
+	if err != nil {
+		return err
+	}
+	r := &repair.Repairer{
+		Checker: checker, Replica: rep, Archive: archive, Store: store, State: s,
+		Events: events, Tier: *tier, RestoreDays: *days,
+	}
+	run, err := r.Run(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if !*dryRun {
+		log, err := a.auditLog()
+		if err != nil {
+			return err
+		}
+		for _, rp := range run.Repairs {
+			if rp.Status != repair.StatusRepaired && rp.Status != repair.StatusFailed {
+				continue
+			}
+			details := map[string]string{"status": string(rp.Status), "copy": rp.Copy, "dataset": rp.Refs[0].Dataset}
+			if rp.Detail != "" {
+				details["detail"] = rp.Detail
+			}
+			if err := audit.Record(ctx, log, "fixity.repair", "s3://"+rp.Bucket+"/"+rp.Key, details); err != nil {
+				return err
+			}
+		}
+	}
+
+	if *asJSON {
+		if err := a.printJSON(run); err != nil {
+			return err
+		}
+	} else {
+		printRepairs(a, run, *tier)
+	}
+	if run.Failed > 0 {
+		return fmt.Errorf("%d objects failed to repair", run.Failed)
+	}
+	return nil
+}
+
+// repairSteps tell the operator what happens next to a repair.
+var repairSteps = map[repair.Status]string{
+	repair.StatusNoCopy:    "replace it from another source",
+	repair.StatusRestore:   "its archived copy will be restored",
+	repair.StatusRestoring: "run again once the restore completes",
+	repair.StatusReady:     "it will be replaced with its copy",
+	repair.StatusRepaired:  "replaced with its copy and verified",
+	repair.StatusFailed:    "review the error; the object was left as it was",
+}
+
+// printRepairs prints the repairs of a run and what to do next.
+func printRepairs(a *app, run *repair.Run, tier string) {
+	if len(run.Repairs) == 0 {
+		fmt.Fprintln(a.out, "No corrupt or missing objects to repair")
+		return
+	}
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OBJECT\tDAMAGE\tSTATUS\tNEXT")
+	for _, rp := range run.Repairs {
+		next := repairSteps[rp.Status]
+		if rp.Status == repair.StatusRestoring && rp.RestoreRequested != nil {
+			next = fmt.Sprintf("restore requested %s; %s", rp.RestoreRequested.Format(time.RFC3339), next)
+		}
+		fmt.Fprintf(tw, "s3://%s/%s\t%s\t%s\t%s\n", rp.Bucket, rp.Key, rp.Damage, rp.Status, next)
+	}
+	_ = tw.Flush()
+	for _, rp := range run.Repairs {
+		if rp.Detail != "" {
+			fmt.Fprintf(a.out, "s3://%s/%s: %s\n", rp.Bucket, rp.Key, rp.Detail)
+		}
+	}
+	fmt.Fprintf(a.out, "\n%d repaired, %d restoring, %d failed, %d without a copy", run.Repaired, run.Restoring, run.Failed, run.NoCopy)
+	if run.Remaining > 0 {
+		fmt.Fprintf(a.out, ", %d left by --limit", run.Remaining)
+	}
+	fmt.Fprintln(a.out)
+	if run.Restoring > 0 {
+		fmt.Fprintf(a.out, "Archived copies restore in hours at the %s tier (up to 12 hours from Deep Archive, 48 at Bulk); run 'aperture fixity repair' again to finish\n", tier)
+	}
+}
+
+// printFixityReport prints the integrity report with its problems.
+func printFixityReport(a *app, fs *flag.FlagSet, r *fixity.Report) {
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Objects:\t%d (%d bytes)\n", r.Objects, r.Bytes)
+	for _, s := range []fixity.Status{fixity.StatusOK, fixity.StatusCorrupt, fixity.StatusMissing, fixity.StatusUnverified} {
+		fmt.Fprintf(tw, "  %s:\t%d\n", s, r.Statuses[s])
+	}
+	fmt.Fprintf(tw, "  never checked:\t%d\n", r.Unchecked)
+	fmt.Fprintf(tw, "Overdue:\t%d (not checked within %s)\n", r.Overdue, fs.Lookup("interval").Value)
+	oldest := "none"
+	if r.OldestCheck != nil {
+		oldest = r.OldestCheck.Format(time.DateOnly)
+	}
+	fmt.Fprintf(tw, "Oldest check:\t%s\n", oldest)
+	if r.LastRun != nil {
+		fmt.Fprintf(tw, "Last run:\t%s, %d objects checked\n", r.LastRun.Finished.Format(time.RFC3339), r.LastRun.Checked)
+	} else {
+		fmt.Fprintf(tw, "Last run:\tnever\n")
+	}
+	_ = tw.Flush()
+
+	if len(r.Problems) == 0 {
+		return
+	}
+	fmt.Fprintln(a.out)
+	for _, p := range r.Problems {
+		printFixityResult(a, p)
+	}
+	if r.Statuses[fixity.StatusCorrupt]+r.Statuses[fixity.StatusMissing] > 0 {
+		fmt.Fprintln(a.out, "\nRepair damaged objects from their preservation copies with 'aperture fixity repair --dry-run' to plan, then 'aperture fixity repair'")
+	}
+}
+
+// printFixityResult prints an object's last check and the first
+// dataset file it backs.
+func printFixityResult(a *app, r fixity.Result) {
+	file := ""
+	if len(r.Refs) > 0 {
+		ref := r.Refs[0]
+		file = " (" + ref.Dataset + " v" + strconv.Itoa(ref.Version) + " " + ref.Path
+		if ref.Converter != "" {
+			file += ", " + ref.Converter + " copy"
+		}
+		file += ")"
+	}
+	detail := ""
+	if r.Detail != "" {
+		detail = ": " + r.Detail
+	}
+	fmt.Fprintf(a.out, "%-10s s3://%s/%s%s checked %s%s\n", r.Status, r.Bucket, r.Key, file, r.Checked.Format(time.DateOnly), detail)
+}
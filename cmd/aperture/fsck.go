@@ -0,0 +1,169 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/fsck"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+func init() {
+	register("fsck", &command{
+		usage:      "[--fix none|safe|all] [--datacite] [--min-severity LEVEL] [--json]",
+		summary:    "Check repository consistency and report problems by severity",
+		run:        runFsck,
+		permission: authz.PermMaintain,
+	})
+}
+
+func runFsck(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("fsck")
+	fixName := fs.String("fix", "none", "repair findings up to this level: none, safe (index and landing pages), or all (also orphans)")
+	checkDOIs := fs.Bool("datacite", false, "also check DOI registration with DataCite")
+	grace := durationFlag(fs, "grace", 7*24*time.Hour, "minimum age of unreferenced objects to report")
+	minName := fs.String("min-severity", "info", "only show findings at or above this severity")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	fix, err := fsck.ParseFixLevel(*fixName)
+	if err != nil {
+		return err
+	}
+	minSeverity, err := fsck.ParseSeverity(*minName)
+	if err != nil {
+		return err
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	media, err := a.scanStore()
+	if err != nil {
+		return err
+	}
+	var objects fsck.ObjectStore = media
+	if a.cfg.TierBucketPrefix != "" {
+		tiers, err := a.objectStore()
+		if err != nil {
+			return err
+		}
+		objects = tieredObjects{media, tiers}
+	}
+	layout, err := a.layout()
+	if err != nil {
+		return err
+	}
+	pages, err := a.landingBuilder()
+	if err != nil {
+		return err
+	}
+	c := &fsck.Checker{
+		Datasets: datasets,
+		Objects:  objects,
+		Buckets:  a.mediaBuckets(),
+		Grace:    *grace,
+		Layout:   layout,
+		Pages:    pages,
+
+		Prefix:      a.cfg.BucketPrefix(),
+		CloudPrefix: a.cfg.TierBucketPrefix,
+	}
+	if *checkDOIs {
+		c.DOIs = a.newDataCiteClient(0)
+	}
+
+	report, fixErr := c.Run(ctx, fix)
+	if report == nil {
+		return fixErr
+	}
+	shown := report.Findings[:0:0]
+	for _, f := range report.Findings {
+		if f.Severity >= minSeverity {
+			shown = append(shown, f)
+		}
+	}
+
+	if *asJSON {
+		out := *report
+		out.Findings = shown
+		if err := a.printJSON(out); err != nil {
+			return err
+		}
+	} else {
+		printFsckReport(a, report, shown)
+	}
+
+	if fixErr != nil {
+		return fixErr
+	}
+	if n := report.Count(fsck.SeverityError); n > 0 {
+		return fmt.Errorf("%d problems at error severity or above", n)
+	}
+	return nil
+}
+
+// printFsckReport prints findings most severe first with a summary.
+func printFsckReport(a *app, r *fsck.Report, shown []fsck.Finding) {
+	for _, f := range shown {
+		status := ""
+		switch {
+		case f.Fixed:
+			status = " [fixed]"
+		case f.Fix != fsck.FixNone:
+			status = fmt.Sprintf(" [--fix %s]", f.Fix)
+		}
+		subject := f.Dataset
+		if f.Object != "" {
+			subject = strings.TrimSpace(subject + " " + f.Object)
+		}
+		fmt.Fprintf(a.out, "%-8s %-8s %s: %s%s\n", strings.ToUpper(f.Severity.String()), f.Check, subject, f.Message, status)
+	}
+
+	counts := make([]int, fsck.SeverityCritical+1)
+	fixed := 0
+	for _, f := range r.Findings {
+		if f.Fixed {
+			fixed++
+			continue
+		}
+		counts[f.Severity]++
+	}
+	fmt.Fprintf(a.out, "\nChecked %d datasets, %d files: %d critical, %d errors, %d warnings, %d info",
+		r.Datasets, r.Files, counts[fsck.SeverityCritical], counts[fsck.SeverityError], counts[fsck.SeverityWarning], counts[fsck.SeverityInfo])
+	if fixed > 0 {
+		fmt.Fprintf(a.out, " (%d fixed)", fixed)
+	}
+	fmt.Fprintln(a.out)
+}
+
+// tieredObjects scans the primary media buckets for orphans, and checks
+// manifest entries in the tier holding them.
+type tieredObjects struct {
+	mediaStore
+	tiers storage.Backend
+}
+
+func (t tieredObjects) HeadObject(ctx context.Context, bucket, key string) (s3.ObjectInfo, error) {
+	return t.tiers.HeadObject(ctx, bucket, key)
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+func init() {
+	register("geo", &command{
+		summary: "Record the places datasets cover, for spatial search",
+		subcommands: map[string]*command{
+			"add": {
+				usage:      "<dataset> [--place NAME] [--point LAT,LON] [--bbox W,S,E,N]",
+				summary:    "Add a location to a dataset",
+				run:        runGeoAdd,
+				permission: authz.PermCurate,
+			},
+			"clear": {
+				usage:      "<dataset>",
+				summary:    "Remove all of a dataset's locations",
+				run:        runGeoClear,
+				permission: authz.PermCurate,
+			},
+			"show": {
+				usage:   "<dataset>",
+				summary: "Show a dataset's locations",
+				run:     runGeoShow,
+			},
+		},
+	})
+}
+
+func runGeoAdd(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("geo add")
+	place := fs.String("place", "", "name of the place, e.g. \"Cambridge, UK\"")
+	point := fs.String("point", "", "coordinates `LAT,LON` in decimal degrees")
+	bbox := fs.String("bbox", "", "bounding box `W,S,E,N` in decimal degrees")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("geo add <dataset> [--place NAME] [--point LAT,LON] [--bbox W,S,E,N]")
+	}
+	l := dataset.GeoLocation{Place: *place}
+	if *point != "" {
+		if l.Point, err = dataset.ParsePoint(*point); err != nil {
+			return err
+		}
+	}
+	if *bbox != "" {
+		if l.Box, err = dataset.ParseBox(*bbox); err != nil {
+			return err
+		}
+	}
+	if err := l.Validate(); err != nil {
+		return err
+	}
+	return updateGeoLocations(ctx, a, "geo.add", pos[0], func(d *dataset.Dataset) {
+		d.GeoLocations = append(d.GeoLocations, l)
+	})
+}
+
+func runGeoClear(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("geo clear")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("geo clear <dataset>")
+	}
+	return updateGeoLocations(ctx, a, "geo.clear", pos[0], func(d *dataset.Dataset) {
+		d.GeoLocations = nil
+	})
+}
+
+// updateGeoLocations changes a dataset's locations after checking that
+// the caller may manage it. The search indexer picks up the change.
+func updateGeoLocations(ctx context.Context, a *app, action, ref string, update func(*dataset.Dataset)) error {
+	d, err := updateDataset(ctx, a, action, ref, func(d *dataset.Dataset) map[string]string {
+		update(d)
+		return map[string]string{"locations": strconv.Itoa(len(d.GeoLocations))}
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Updated %s (%d locations)\n", d.ID, len(d.Staged().GeoLocations))
+	return nil
+}
+
+func runGeoShow(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("geo show")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("geo show <dataset>")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if len(d.GeoLocations) == 0 {
+		fmt.Fprintf(a.out, "%s: no locations\n", d.ID)
+		return nil
+	}
+	for i, l := range d.GeoLocations {
+		fmt.Fprintf(a.out, "%d.", i+1)
+		if l.Place != "" {
+			fmt.Fprintf(a.out, " %s", l.Place)
+		}
+		if l.Point != nil {
+			fmt.Fprintf(a.out, " point %g,%g", l.Point.Lat, l.Point.Lon)
+		}
+		if l.Box != nil {
+			fmt.Fprintf(a.out, " bbox %g,%g,%g,%g", l.Box.West, l.Box.South, l.Box.East, l.Box.North)
+		}
+		fmt.Fprintln(a.out)
+	}
+	return nil
+}
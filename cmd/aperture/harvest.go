@@ -0,0 +1,113 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/harvest"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("harvest", &command{
+		usage:      "--endpoint URL [--set SET] [--from DATE] [--until DATE] [--collection C] [--files] [--limit N] [--dry-run] [--json]",
+		summary:    "Import records from another OAI-PMH repository as draft datasets",
+		run:        runHarvest,
+		scope:      token.ScopeDatasetsWrite,
+		permission: authz.PermDeposit,
+	})
+}
+
+func runHarvest(ctx context.Context, a *app, args []string) error {
+	const usage = "harvest --endpoint URL [--set SET] [--from DATE] [--until DATE] [--collection C] [--files] [--limit N] [--dry-run] [--json]"
+	fs := newFlagSet("harvest")
+	endpoint := fs.String("endpoint", "", "OAI-PMH base URL of the repository to harvest")
+	set := fs.String("set", "", "setSpec to harvest (default the whole repository)")
+	from := fs.String("from", "", "only records modified on or after this date (YYYY-MM-DD)")
+	until := fs.String("until", "", "only records modified on or before this date (YYYY-MM-DD)")
+	collection := fs.String("collection", "", "collection of imported datasets")
+	files := fs.Bool("files", false, "fetch the files records link to (default metadata only)")
+	limit := fs.Int("limit", 0, "stop after importing this many records (0 for no limit)")
+	dryRun := fs.Bool("dry-run", false, "list what would be imported without saving anything")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 || *endpoint == "" {
+		return usageError(usage)
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	m := &harvest.Manager{Datasets: datasets, Log: log}
+	if *files {
+		if m.Objects, err = a.objectStore(); err != nil {
+			return err
+		}
+		if m.Layout, err = a.layout(); err != nil {
+			return err
+		}
+	}
+	res, err := m.Harvest(ctx, harvest.NewClient(harvest.ClientOptions{Endpoint: *endpoint}), harvest.Options{
+		Query:      harvest.Query{Set: *set, From: *from, Until: *until},
+		Collection: *collection,
+		Files:      *files,
+		Limit:      *limit,
+		DryRun:     *dryRun,
+	})
+	if res == nil {
+		return err
+	}
+	if *asJSON {
+		if perr := a.printJSON(res); perr != nil {
+			return perr
+		}
+		return err
+	}
+	counts := make(map[string]int)
+	for _, o := range res.Records {
+		counts[o.Status]++
+		switch o.Status {
+		case harvest.StatusCreated:
+			if o.Files > 0 {
+				fmt.Fprintf(a.out, "  %-8s %s -> %s (%d files)\n", o.Status, o.Identifier, o.Dataset, o.Files)
+			} else {
+				fmt.Fprintf(a.out, "  %-8s %s -> %s\n", o.Status, o.Identifier, o.Dataset)
+			}
+		case harvest.StatusExists:
+			fmt.Fprintf(a.out, "  %-8s %s (already %s)\n", o.Status, o.Identifier, o.Dataset)
+		case harvest.StatusFailed:
+			fmt.Fprintf(a.out, "  %-8s %s: %s\n", o.Status, o.Identifier, o.Error)
+		}
+	}
+	verb := "Imported"
+	if *dryRun {
+		verb = "Would import"
+	}
+	fmt.Fprintf(a.out, "%s %d of %d records from %s (%d already imported, %d deleted, %d failed)\n",
+		verb, counts[harvest.StatusCreated], len(res.Records), res.Endpoint,
+		counts[harvest.StatusExists], counts[harvest.StatusDeleted], counts[harvest.StatusFailed])
+	return err
+}
@@ -0,0 +1,167 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/search"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("index", &command{
+		summary: "Maintain the OpenSearch index of published datasets",
+		subcommands: map[string]*command{
+			"rebuild": {
+				usage:      "[--dry-run] [--json]",
+				summary:    "Reindex every published dataset into a new index and swap the alias to it",
+				run:        runIndexRebuild,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+			},
+			"retry": {
+				usage:      "[--json]",
+				summary:    "Index the changes that failed to index when they were made",
+				run:        runIndexRetry,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+			},
+			"status": {
+				usage:   "[--json]",
+				summary: "Show the index behind the alias, its document count, and pending changes",
+				run:     runIndexStatus,
+				scope:   token.ScopeDatasetsRead,
+			},
+		},
+	})
+}
+
+// searchIndexer returns the indexer and an unobserved dataset catalog,
+// so that commands index each change once.
+func (a *app) searchIndexer() (*search.Indexer, *dataset.Store, error) {
+	if a.cfg.OpenSearchURL == "" {
+		return nil, nil, fmt.Errorf("OpenSearch is not configured; set APERTURE_OPENSEARCH_URL")
+	}
+	s, err := a.store()
+	if err != nil {
+		return nil, nil, err
+	}
+	return a.indexer(s), dataset.NewStore(s), nil
+}
+
+func runIndexRebuild(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("index rebuild")
+	dryRun := fs.Bool("dry-run", false, "count the datasets to index without creating an index")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("index rebuild [--dry-run] [--json]")
+	}
+	x, datasets, err := a.searchIndexer()
+	if err != nil {
+		return err
+	}
+	res, err := x.Rebuild(ctx, datasets, *dryRun)
+	if res == nil {
+		return err
+	}
+	if *asJSON {
+		if perr := a.printJSON(res); perr != nil {
+			return perr
+		}
+		return err
+	}
+	if *dryRun {
+		fmt.Fprintf(a.out, "Would index %d datasets into %s (dry run)\n", res.Documents, res.Index)
+		return err
+	}
+	fmt.Fprintf(a.out, "Indexed %d datasets into %s; %s now points to it\n", res.Documents, res.Index, x.Alias)
+	for _, o := range res.Removed {
+		fmt.Fprintf(a.out, "  deleted %s\n", o)
+	}
+	return err
+}
+
+func runIndexRetry(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("index retry")
+	asJSON := fs.Bool("json", false, "print the changes still pending as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("index retry [--json]")
+	}
+	x, datasets, err := a.searchIndexer()
+	if err != nil {
+		return err
+	}
+	before, err := x.PendingChanges(ctx)
+	if err != nil {
+		return err
+	}
+	pending, err := x.Retry(ctx, datasets)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(pending)
+	}
+	fmt.Fprintf(a.out, "Indexed %d of %d pending changes\n", len(before)-len(pending), len(before))
+	for _, p := range pending {
+		fmt.Fprintf(a.out, "  %s: %s\n", p.DatasetID, p.Error)
+	}
+	return nil
+}
+
+func runIndexStatus(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("index status")
+	asJSON := fs.Bool("json", false, "print the status as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("index status [--json]")
+	}
+	x, _, err := a.searchIndexer()
+	if err != nil {
+		return err
+	}
+	st, err := x.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(st)
+	}
+	if len(st.Indices) == 0 {
+		fmt.Fprintf(a.out, "%s does not exist; run 'aperture index rebuild'\n", st.Alias)
+	} else {
+		fmt.Fprintf(a.out, "%s -> %v (%d documents)\n", st.Alias, st.Indices, st.Documents)
+		if !st.Current {
+			fmt.Fprintf(a.out, "  mapping is older than version %d; run 'aperture index rebuild'\n", search.MappingVersion)
+		}
+	}
+	fmt.Fprintf(a.out, "%d pending changes\n", st.Pending)
+	return nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/deploy"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func init() {
+	register("infra", &command{
+		summary: "Inspect the deployed infrastructure",
+		subcommands: map[string]*command{
+			"drift": {
+				usage:      "[--var-file FILE]... [--json]",
+				summary:    "Report resources changed outside the deployment backend, failing if any security-relevant ones did",
+				run:        runInfraDrift,
+				permission: authz.PermDeploy,
+			},
+			"test": {
+				usage:      "[--json]",
+				summary:    "Run end-to-end smoke tests against the deployment, cleaning up after them, failing if any check fails",
+				run:        runInfraTest,
+				permission: authz.PermDeploy,
+			},
+			"outputs": {
+				usage:   "[NAME] [--env ENV] [--json]",
+				summary: "Show the stack outputs of an environment, or the value of one",
+				run:     runInfraOutputs,
+			},
+		},
+	})
+}
+
+func runInfraOutputs(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("infra outputs")
+	env := fs.String("env", "", "the environment whose outputs are shown; the configured one if empty")
+	asJSON := fs.Bool("json", false, "print the outputs as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) > 1 {
+		return usageError("infra outputs [NAME] [--env ENV] [--json]")
+	}
+	environment := cmp.Or(*env, a.cfg.Environment)
+	outputs, err := a.stackOutputs(ctx, environment)
+	if err != nil {
+		return err
+	}
+
+	if len(pos) == 1 {
+		v, ok := outputs[pos[0]]
+		if !ok {
+			return fmt.Errorf("%s has no output %s", environment, pos[0])
+		}
+		fmt.Fprintln(a.out, v)
+		return nil
+	}
+	if *asJSON {
+		return a.printJSON(outputs)
+	}
+	for _, name := range slices.Sorted(maps.Keys(outputs)) {
+		fmt.Fprintf(a.out, "%-40s %s\n", name, outputs[name])
+	}
+	return nil
+}
+
+// stackOutputs returns the outputs of environment: those published to
+// the output registry if one is configured, otherwise those of its
+// recorded deployment, with string values unquoted.
+func (a *app) stackOutputs(ctx context.Context, environment string) (map[string]string, error) {
+	r, err := a.registry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		outputs, err := r.Outputs(ctx, environment)
+		if err == nil && len(outputs) == 0 {
+			err = fmt.Errorf("no outputs of %s are published under %s", environment, r.Path(environment))
+		}
+		return outputs, err
+	}
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	dep, err := (&deploy.Deployer{State: s}).Current(ctx, environment)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("%s has not been deployed", environment)
+	}
+	if err != nil {
+		return nil, err
+	}
+	outputs := make(map[string]string, len(dep.Outputs))
+	for name, raw := range dep.Outputs {
+		var v string
+		if json.Unmarshal(raw, &v) != nil {
+			v = string(raw)
+		}
+		outputs[name] = v
+	}
+	return outputs, nil
+}
+
+func runInfraDrift(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("infra drift")
+	var varFiles stringsFlag
+	fs.Var(&varFiles, "var-file", "a further Terraform variables file the deployment used (repeatable; Terraform only)")
+	asJSON := fs.Bool("json", false, "print the drifted resources as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("infra drift [--var-file FILE]... [--json]")
+	}
+	opts := a.deployOptions()
+	opts.VarFiles = varFiles
+	d, err := a.deployer(ctx)
+	if err != nil {
+		return err
+	}
+	// Only the report is of interest; the backend's own output would
+	// repeat it.
+	d.Out = io.Discard
+	r, err := d.Drift(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := a.printJSON(r); err != nil {
+			return err
+		}
+	} else {
+		for _, res := range r.Resources {
+			fmt.Fprintf(a.out, "%-9s %-7s %s", res.Severity, res.Action, res.Address)
+			if len(res.Attributes) > 0 {
+				fmt.Fprintf(a.out, " (%s)", strings.Join(res.Attributes, ", "))
+			}
+			fmt.Fprintln(a.out)
+		}
+		fmt.Fprintf(a.out, "%d resources of %s drifted: %d security-relevant, %d configuration, %d cosmetic\n", len(r.Resources), r.Environment,
+			r.Count(deploy.SeveritySecurity), r.Count(deploy.SeverityConfig), r.Count(deploy.SeverityCosmetic))
+	}
+	if n := r.Count(deploy.SeveritySecurity); n > 0 {
+		return fmt.Errorf("%d resources of %s drifted in security-relevant ways; review them, then run 'aperture deploy' to restore them", n, r.Environment)
+	}
+	return nil
+}
+
+func runInfraTest(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("infra test")
+	asJSON := fs.Bool("json", false, "print the check results as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("infra test [--json]")
+	}
+	results, err := deploy.RunChecks(ctx, a.smokeTests())
+	if *asJSON {
+		if jerr := a.printJSON(struct {
+			Environment string               `json:"environment"`
+			Checks      []deploy.CheckResult `json:"checks"`
+		}{a.cfg.Environment, results}); err == nil {
+			err = jerr
+		}
+		return err
+	}
+	for _, c := range results {
+		if c.Error != "" {
+			fmt.Fprintf(a.out, "  FAIL  %s: %s\n", c.Name, c.Error)
+		} else {
+			fmt.Fprintf(a.out, "  ok    %s\n", c.Name)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("smoke tests of %s failed: %w", a.cfg.Environment, err)
+	}
+	fmt.Fprintf(a.out, "%s passed %d smoke tests\n", a.cfg.Environment, len(results))
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// runDatasetLanguage sets the language tags of a dataset's title and
+// description. A tag of "none" removes one. Without flags it shows
+// them.
+func runDatasetLanguage(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset language")
+	title := fs.String("title", "", "BCP 47 `TAG` of the title's language, or none")
+	description := fs.String("description", "", "BCP 47 `TAG` of the description's language, or none")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("dataset language <dataset> [--title TAG] [--description TAG]")
+	}
+	tags := map[string]*string{"title": title, "description": description}
+	for _, name := range []string{"title", "description"} {
+		t := tags[name]
+		if *t == "" || *t == "none" {
+			continue
+		}
+		if *t, err = dataset.NormalizeLanguage(*t); err != nil {
+			return fmt.Errorf("invalid --%s: %w", name, err)
+		}
+	}
+
+	var d *dataset.Dataset
+	if *title == "" && *description == "" {
+		datasets, err := a.datasets()
+		if err != nil {
+			return err
+		}
+		if d, err = datasets.Resolve(ctx, pos[0]); err != nil {
+			return err
+		}
+	} else {
+		d, err = updateDataset(ctx, a, "dataset.language", pos[0], func(d *dataset.Dataset) map[string]string {
+			for _, f := range []struct {
+				tag   string
+				field *string
+			}{{*title, &d.TitleLanguage}, {*description, &d.DescriptionLanguage}} {
+				switch f.tag {
+				case "":
+				case "none":
+					*f.field = ""
+				default:
+					*f.field = f.tag
+				}
+			}
+			return map[string]string{"title": d.TitleLanguage, "description": d.DescriptionLanguage}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	m := d.Staged()
+	for _, f := range []struct{ name, tag string }{{"title", m.TitleLanguage}, {"description", m.DescriptionLanguage}} {
+		if f.tag == "" {
+			f.tag = "untagged"
+		}
+		fmt.Fprintf(a.out, "%s %s: %s\n", d.ID, f.name, f.tag)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"github.com/scttfrdmn/aperture/internal/config"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/oidc"
+)
+
+func init() {
+	register("login", &command{
+		usage:   "[--device] [--no-browser] [--port N]",
+		summary: "Sign in to the repository's identity provider",
+		run:     runLogin,
+	})
+	register("logout", &command{
+		summary: "Forget the stored login",
+		run:     runLogout,
+	})
+}
+
+// credentialsPath returns the file holding the stored login.
+func credentialsPath(cfg *config.Config) string {
+	return filepath.Join(cfg.StateDir, "credentials.json")
+}
+
+// credentialStore returns the store holding the login.
+func (a *app) credentialStore() *oidc.Store {
+	return oidc.NewStore(credentialsPath(a.cfg))
+}
+
+// oidcClient discovers the configured provider and returns the CLI's
+// OAuth client.
+func (a *app) oidcClient(ctx context.Context) (*oidc.Client, error) {
+	issuer := a.cfg.Issuer()
+	if issuer == "" {
+		return nil, fmt.Errorf("APERTURE_OIDC_ISSUER or APERTURE_COGNITO_USER_POOL_ID must be set to log in")
+	}
+	if a.cfg.OIDCClientID == "" {
+		return nil, fmt.Errorf("APERTURE_OIDC_CLIENT_ID must be set to log in")
+	}
+	provider, err := oidc.Discover(ctx, nil, issuer)
+	if err != nil {
+		return nil, err
+	}
+	return &oidc.Client{Provider: provider, ClientID: a.cfg.OIDCClientID}, nil
+}
+
+// apiClient returns an HTTP client that signs API requests with the
+// stored login, refreshing it as needed.
+func (a *app) apiClient(ctx context.Context) (*http.Client, error) {
+	store := a.credentialStore()
+	if _, err := store.Load(); err != nil {
+		return nil, err
+	}
+	c, err := a.oidcClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: &oidc.Transport{Source: &oidc.TokenSource{Client: c, Store: store}}}, nil
+}
+
+func runLogin(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("login")
+	device := fs.Bool("device", false, "use the device code flow (for machines without a browser)")
+	noBrowser := fs.Bool("no-browser", false, "print the sign-in URL instead of opening a browser")
+	port := fs.Int("port", 8976, "local port for the sign-in callback")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	c, err := a.oidcClient(ctx)
+	if err != nil {
+		return err
+	}
+	var tok *oidc.Token
+	if *device {
+		flow, err := c.StartDevice(ctx)
+		if errors.Is(err, oidc.ErrDeviceFlowUnsupported) {
+			return fmt.Errorf("%w; run 'aperture login --no-browser' and open the URL on any machine that can reach localhost:%d", err, *port)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "To sign in, visit %s and enter the code %s\n", flow.VerificationURI, flow.UserCode)
+		tok, err = c.PollDevice(ctx, flow)
+		if err != nil {
+			return err
+		}
+	} else {
+		addr := net.JoinHostPort("localhost", strconv.Itoa(*port))
+		tok, err = c.LoginBrowser(ctx, addr, func(url string) {
+			fmt.Fprintf(a.out, "To sign in, visit:\n\n  %s\n\n", url)
+			if !*noBrowser {
+				openBrowser(url)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	creds, err := a.credentialStore().Login(c, tok)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Logged in as %s\n", creds.Claims.Email)
+
+	// Signing in with ORCID authenticates the iD, so attach it to the
+	// depositor profile for use in creator metadata.
+	if creds.Claims.ORCID == "" {
+		return nil
+	}
+	profiles, err := a.profiles()
+	if err != nil {
+		return err
+	}
+	ctx = identity.WithPrincipal(ctx, loginPrincipal(a.cfg, &creds.Claims))
+	p, changed, err := profiles.LinkORCID(ctx)
+	if err != nil {
+		return err
+	}
+	if changed {
+		fmt.Fprintf(a.out, "Linked ORCID iD %s to your profile\n", p.ORCID)
+	}
+	return nil
+}
+
+// openBrowser tries to open url in the user's browser. Failures are
+// ignored because the URL has already been printed.
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err == nil {
+		go cmd.Wait()
+	}
+}
+
+func runLogout(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("logout")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if err := a.credentialStore().Delete(); err != nil {
+		return err
+	}
+	fmt.Fprintln(a.out, "Logged out")
+	return nil
+}
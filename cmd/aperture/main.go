@@ -15,10 +15,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"slices"
+	"time"
 
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
 	"github.com/scttfrdmn/aperture/internal/config"
+	"github.com/scttfrdmn/aperture/internal/federation"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/metrics"
+	"github.com/scttfrdmn/aperture/internal/oidc"
+	"github.com/scttfrdmn/aperture/internal/trace"
 )
 
 // Version is set via ldflags during build
@@ -29,17 +41,15 @@ var (
 )
 
 func main() {
-	if err := run(); err != nil {
+	if err := run(os.Args[1:]); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
-	// Display version information
-	fmt.Printf("Aperture v%s (commit: %s, built: %s)\n", Version, Commit, BuildTime)
-	fmt.Println("Opening research to the world")
-	fmt.Println()
+func run(args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
 	// Load configuration
 	cfg, err := config.Load()
@@ -47,6 +57,137 @@ func run() error {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
+	if len(args) == 0 {
+		return welcome(cfg)
+	}
+
+	a := &app{cfg: cfg, in: os.Stdin, out: os.Stdout}
+	if err := a.discoverOutputs(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if cfg.MetricsTarget != "" {
+		if a.metrics, err = metrics.Open(cfg.MetricsTarget, cfg.MetricsNamespace, metrics.Dimensions{"Environment": cfg.Environment}); err != nil {
+			return err
+		}
+		defer a.metrics.Close()
+	}
+	if cfg.TraceEndpoint != "" {
+		a.tracer = trace.New(&trace.OTLP{Endpoint: cfg.TraceEndpoint, Headers: cfg.TraceHeaders},
+			trace.String("service.name", cfg.TraceService),
+			trace.String("service.version", Version),
+			trace.String("deployment.environment", cfg.Environment),
+		)
+		a.tracer.OnError = func(err error) { fmt.Fprintf(os.Stderr, "Warning: %v\n", err) }
+		ctx = trace.WithTracer(ctx, a.tracer)
+		defer flushTraces(a.tracer)
+	}
+	p := a.principal(ctx)
+	if cfg.Token != "" {
+		if p, err = a.tokenPrincipal(ctx); err != nil {
+			return err
+		}
+	}
+	if p, err = a.withRoles(ctx, p); err != nil {
+		return err
+	}
+	ctx = identity.WithPrincipal(ctx, p)
+	ctx = audit.WithSource(ctx, sourceIP())
+
+	root := &command{subcommands: commands}
+	return root.execute(ctx, a, "", args)
+}
+
+// flushTraces exports the spans still queued at exit. It does not use
+// the command's context, which an interrupt may have cancelled.
+func flushTraces(t *trace.Tracer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := t.Flush(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to export traces: %v\n", err)
+	}
+}
+
+// principal returns the identity of the person running the CLI: the
+// stored login, once its ID token is verified against the configured
+// issuer, or APERTURE_USER in the development mode of a local emulator.
+// Without either the CLI runs anonymously, and so it does with a login
+// that fails verification, so that 'aperture login' can replace it.
+// Groups come only from the verified claims; withRoles adds those of
+// granted roles. Configured administrators are members of the admins
+// group.
+func (a *app) principal(ctx context.Context) identity.Principal {
+	var p identity.Principal
+	if a.cfg.DevIdentity {
+		p = identity.Principal{ID: identity.Normalize(a.cfg.User), ORCID: a.cfg.ORCID}
+	} else if c, err := a.loginClaims(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring the stored login: %v\n", err)
+	} else if c != nil && c.Email != "" {
+		p = loginPrincipal(a.cfg, c)
+	}
+	if !p.IsZero() && a.cfg.IsAdmin(p.ID) {
+		p.Groups = append(p.Groups, authz.AdminGroup)
+	}
+	return p
+}
+
+// loginClaims returns the claims of the stored login's ID token, or nil
+// if no one is logged in. The claims saved beside the token are not
+// used: the credentials file is the user's to edit, so the token's
+// signature, issuer, audience, and expiry are checked, and an expired
+// login is refreshed first.
+func (a *app) loginClaims(ctx context.Context) (*oidc.Claims, error) {
+	store := a.credentialStore()
+	creds, err := store.Load()
+	if errors.Is(err, oidc.ErrNotLoggedIn) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	issuer := a.cfg.Issuer()
+	if issuer == "" || a.cfg.OIDCClientID == "" {
+		return nil, fmt.Errorf("APERTURE_OIDC_ISSUER or APERTURE_COGNITO_USER_POOL_ID, and APERTURE_OIDC_CLIENT_ID, must be set to verify it")
+	}
+	if !creds.Token.Valid(time.Now()) {
+		c, err := a.oidcClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := (&oidc.TokenSource{Client: c, Store: store}).AccessToken(ctx); err != nil {
+			return nil, err
+		}
+		if creds, err = store.Load(); err != nil {
+			return nil, err
+		}
+	}
+	v := &oidc.Verifier{Issuer: issuer, ClientID: a.cfg.OIDCClientID}
+	c, err := v.Verify(ctx, creds.Token.IDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w; run 'aperture login'", err)
+	}
+	return c, nil
+}
+
+// loginPrincipal returns the principal for stored login claims. Users
+// who signed in through their institution also join the groups mapped
+// from their eduPersonAffiliation.
+func loginPrincipal(cfg *config.Config, c *oidc.Claims) identity.Principal {
+	p := identity.Principal{ID: identity.Normalize(c.Email), Groups: slices.Clone(c.Groups), ORCID: c.ORCID}
+	for _, g := range federation.Groups(federation.ParseAffiliations(c.Affiliation), affiliationGroups(cfg)) {
+		if !slices.Contains(p.Groups, g) {
+			p.Groups = append(p.Groups, g)
+		}
+	}
+	return p
+}
+
+// welcome prints version information and next steps.
+func welcome(cfg *config.Config) error {
+	// Display version information
+	fmt.Printf("Aperture v%s (commit: %s, built: %s)\n", Version, Commit, BuildTime)
+	fmt.Println("Opening research to the world")
+	fmt.Println()
+
 	fmt.Printf("Configuration loaded: %s environment\n", cfg.Environment)
 	fmt.Println()
 	fmt.Println("Aperture is ready to serve the academic research community!")
@@ -56,6 +197,7 @@ func run() error {
 	fmt.Println("  2. Run 'aperture deploy' to deploy infrastructure")
 	fmt.Println("  3. Run 'aperture upload' to upload your first dataset")
 	fmt.Println()
+	fmt.Println("Run 'aperture help' to list all commands.")
 	fmt.Println("For more information, visit: https://github.com/scttfrdmn/aperture")
 
 	return nil
@@ -0,0 +1,123 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/config"
+	"github.com/scttfrdmn/aperture/internal/oidc"
+)
+
+func TestPrincipalVerifiesLogin(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidc.Provider{
+			Issuer: srv.URL, AuthorizationEndpoint: srv.URL + "/authorize", TokenEndpoint: srv.URL + "/token", JWKSURI: srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	enc := func(v any) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	sign := func(claims map[string]any) string {
+		signed := enc(map[string]string{"alg": "RS256", "kid": "k1"}) + "." + enc(claims)
+		digest := sha256.Sum256([]byte(signed))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+	now := time.Now()
+	claims := map[string]any{
+		"iss": srv.URL, "aud": "cli", "token_use": "id", "exp": now.Add(time.Hour).Unix(),
+		"email": "jane@uni.edu", "cognito:groups": []string{"researchers"},
+	}
+	valid := sign(claims)
+	parts := strings.Split(valid, ".")
+	claims["cognito:groups"] = []string{authz.AdminGroup}
+	forged := parts[0] + "." + enc(claims) + "." + parts[2]
+
+	t.Setenv("APERTURE_STATE_DIR", t.TempDir())
+	t.Setenv("APERTURE_OIDC_ISSUER", srv.URL)
+	t.Setenv("APERTURE_OIDC_CLIENT_ID", "cli")
+	t.Setenv("APERTURE_ADMINS", "")
+	t.Setenv("APERTURE_DEV_IDENTITY", "")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &app{cfg: cfg}
+
+	tests := []struct {
+		name       string
+		idToken    string
+		expiry     time.Time
+		wantID     string
+		wantGroups []string
+	}{
+		{"verified login", valid, now.Add(time.Hour), "jane@uni.edu", []string{"researchers"}},
+		{"edited token", forged, now.Add(time.Hour), "", nil},
+		{"expired login", valid, now.Add(-time.Hour), "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The saved claims are edited to make jane an administrator;
+			// only the token's count.
+			creds := &oidc.Credentials{
+				Issuer: srv.URL, ClientID: "cli",
+				Token:  oidc.Token{AccessToken: "access", IDToken: tt.idToken, Expiry: tt.expiry},
+				Claims: oidc.Claims{Email: "jane@uni.edu", Groups: []string{authz.AdminGroup}},
+			}
+			if err := a.credentialStore().Save(creds); err != nil {
+				t.Fatal(err)
+			}
+			p := a.principal(context.Background())
+			if p.ID != tt.wantID || !slices.Equal(p.Groups, tt.wantGroups) {
+				t.Errorf("principal() = %+v, want %s in %v", p, tt.wantID, tt.wantGroups)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/metrics"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("metrics", &command{
+		summary: "Manage operational metrics in CloudWatch",
+		subcommands: map[string]*command{
+			"dashboard": {
+				usage:      "[--name NAME] [--dry-run]",
+				summary:    "Provision the standard CloudWatch dashboard of latency, errors, throughput, and queue depth",
+				run:        runMetricsDashboard,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+			},
+		},
+	})
+}
+
+func runMetricsDashboard(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("metrics dashboard")
+	name := fs.String("name", a.cfg.BucketPrefix()+"-operations", "name of the dashboard")
+	dryRun := fs.Bool("dry-run", false, "print the dashboard body instead of provisioning it")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("metrics dashboard [--name NAME] [--dry-run]")
+	}
+	d := metrics.NewDashboard(a.cfg.MetricsNamespace, a.cfg.AWSRegion, a.cfg.Environment)
+	if *dryRun {
+		return a.printJSON(d)
+	}
+
+	creds, err := aws.CredentialsFromEnv()
+	if err != nil {
+		return err
+	}
+	warnings, err := metrics.NewDashboardClient(a.cfg.AWSRegion, creds).Put(ctx, *name, d)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		fmt.Fprintln(a.out, "Warning:", w)
+	}
+	fmt.Fprintf(a.out, "Provisioned dashboard %s: https://%s.console.aws.amazon.com/cloudwatch/home?region=%s#dashboards:name=%s\n",
+		*name, a.cfg.AWSRegion, a.cfg.AWSRegion, *name)
+	if a.cfg.MetricsTarget == "" {
+		fmt.Fprintln(a.out, "Metrics are off here; set APERTURE_METRICS to stderr or a CloudWatch agent address to emit them")
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/migration"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("migration", &command{
+		summary: "Make preservation copies of files in normalized formats",
+		subcommands: map[string]*command{
+			"converters": {
+				summary: "Register the services that convert files",
+				subcommands: map[string]*command{
+					"list": {
+						usage:   "[--json]",
+						summary: "List the registered converters",
+						run:     runMigrationConvertersList,
+						scope:   token.ScopeDatasetsRead,
+					},
+					"add": {
+						usage:      "<name> --from TYPE|.EXT... --to TYPE --ext .EXT --endpoint URL [--iam] [--description TEXT]",
+						summary:    "Register or replace a converter served by Lambda or Fargate",
+						run:        runMigrationConvertersAdd,
+						scope:      token.ScopeDatasetsWrite,
+						permission: authz.PermMaintain,
+					},
+					"remove": {
+						usage:      "<name>",
+						summary:    "Unregister a converter, keeping the copies it made",
+						run:        runMigrationConvertersRemove,
+						scope:      token.ScopeDatasetsWrite,
+						permission: authz.PermMaintain,
+					},
+				},
+			},
+			"run": {
+				usage:      "[--dataset REF] [--converter NAME] [--limit N] [--force] [--json]",
+				summary:    "Convert the files that have no copy from a converter accepting them",
+				run:        runMigrationRun,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+			},
+		},
+	})
+}
+
+// migrationPipeline returns the migration pipeline. Its object store
+// and signer are set only for running conversions.
+func (a *app) migrationPipeline() (*migration.Pipeline, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	events, err := a.premisEvents(s)
+	if err != nil {
+		return nil, err
+	}
+	return &migration.Pipeline{State: s, Datasets: datasets, Events: events}, nil
+}
+
+func runMigrationConvertersList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("migration converters list")
+	asJSON := fs.Bool("json", false, "print the converters as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("migration converters list [--json]")
+	}
+	p, err := a.migrationPipeline()
+	if err != nil {
+		return err
+	}
+	converters, err := p.Converters(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(converters)
+	}
+	if len(converters) == 0 {
+		fmt.Fprintln(a.out, "No converters registered")
+		return nil
+	}
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tFROM\tTO\tENDPOINT")
+	for _, c := range converters {
+		endpoint := c.Endpoint
+		if c.IAM {
+			endpoint += " (IAM)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Name, strings.Join(c.From, ", "), c.To, endpoint)
+	}
+	return tw.Flush()
+}
+
+func runMigrationConvertersAdd(ctx context.Context, a *app, args []string) error {
+	const synopsis = "migration converters add <name> --from TYPE|.EXT... --to TYPE --ext .EXT --endpoint URL [--iam] [--description TEXT]"
+	fs := newFlagSet("migration converters add")
+	var from stringsFlag
+	fs.Var(&from, "from", "a media type or file extension the converter accepts (repeatable)")
+	to := fs.String("to", "", "media type of the copies, e.g. text/csv")
+	ext := fs.String("ext", "", "extension of the copies, e.g. .csv")
+	endpoint := fs.String("endpoint", "", "`URL` of the converter, e.g. a Lambda function URL")
+	iam := fs.Bool("iam", false, "sign requests with AWS credentials, for function URLs with AWS_IAM auth")
+	description := fs.String("description", "", "what the converter does")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError(synopsis)
+	}
+	p, err := a.migrationPipeline()
+	if err != nil {
+		return err
+	}
+	c := &migration.Converter{
+		Name: pos[0], Description: *description, From: from, To: *to,
+		Extension: *ext, Endpoint: *endpoint, IAM: *iam,
+	}
+	if err := p.SaveConverter(ctx, c); err != nil {
+		return err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, log, "migration.converter.save", c.Name, map[string]string{
+		"from": strings.Join(c.From, ","), "to": c.To, "endpoint": c.Endpoint,
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Registered converter %s: %s to %s\n", c.Name, strings.Join(c.From, ", "), c.To)
+	return nil
+}
+
+func runMigrationConvertersRemove(ctx context.Context, a *app, args []string) error {
+	pos, err := parseArgs(newFlagSet("migration converters remove"), args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("migration converters remove <name>")
+	}
+	p, err := a.migrationPipeline()
+	if err != nil {
+		return err
+	}
+	if err := p.RemoveConverter(ctx, pos[0]); err != nil {
+		return err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, log, "migration.converter.remove", pos[0], nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Removed converter %s\n", pos[0])
+	return nil
+}
+
+func runMigrationRun(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("migration run")
+	ref := fs.String("dataset", "", "convert the files of one dataset only")
+	name := fs.String("converter", "", "run one converter only")
+	limit := fs.Int("limit", 0, "run at most `N` conversions (0 for no limit)")
+	force := fs.Bool("force", false, "convert files again that already have a copy")
+	asJSON := fs.Bool("json", false, "print the run as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("migration run [--dataset REF] [--converter NAME] [--limit N] [--force] [--json]")
+	}
+	p, err := a.migrationPipeline()
+	if err != nil {
+		return err
+	}
+	if p.Objects, err = a.objectStore(); err != nil {
+		return err
+	}
+	creds, _ := aws.CredentialsFromEnv()
+	p.Signer = &aws.Signer{Credentials: creds, Region: a.cfg.AWSRegion, Service: "lambda"}
+
+	run, err := p.Run(ctx, migration.Options{Dataset: *ref, Converter: *name, Limit: *limit, Force: *force})
+	if err != nil {
+		return err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	for _, res := range run.Results {
+		if res.Copy == nil {
+			continue
+		}
+		if err := audit.Record(ctx, log, "migration.convert", res.Dataset, map[string]string{
+			"path": res.Path, "converter": res.Converter, "copy": "s3://" + res.Copy.Bucket + "/" + res.Copy.Key,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		if err := a.printJSON(run); err != nil {
+			return err
+		}
+	} else {
+		for _, res := range run.Results {
+			outcome := "converted"
+			if res.Error != "" {
+				outcome = "FAILED: " + res.Error
+			}
+			fmt.Fprintf(a.out, "%s v%d %s (%s): %s\n", res.Dataset, res.Version, res.Path, res.Converter, outcome)
+		}
+		fmt.Fprintf(a.out, "Converted %d files, %d failed, %d remaining\n", run.Converted, run.Failed, run.Remaining)
+	}
+	if run.Failed > 0 {
+		return fmt.Errorf("%d conversions failed", run.Failed)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/scttfrdmn/aperture/internal/oaipmh"
+)
+
+func init() {
+	register("oai", &command{
+		summary: "Serve dataset metadata to harvesters",
+		subcommands: map[string]*command{
+			"serve": {
+				usage:   "[--addr ADDR] [--base-url URL] [--admin-email EMAIL]",
+				summary: "Serve the OAI-PMH 2.0 endpoint (GET|POST /oai) in oai_dc and DataCite metadata",
+				run:     runOAIServe,
+			},
+		},
+	})
+}
+
+func runOAIServe(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("oai serve")
+	addr := fs.String("addr", "127.0.0.1:8085", "address to listen on")
+	baseURL := fs.String("base-url", "", "public URL of the endpoint (default http://ADDR/oai)")
+	adminEmail := fs.String("admin-email", a.cfg.MailFrom, "contact address reported to harvesters")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("oai serve [--addr ADDR] [--base-url URL] [--admin-email EMAIL]")
+	}
+	if a.cfg.SiteURL == "" || a.cfg.Publisher == "" {
+		return fmt.Errorf("APERTURE_SITE_URL and APERTURE_PUBLISHER must be set")
+	}
+	if *baseURL == "" {
+		*baseURL = "http://" + *addr + "/oai"
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	awards, err := a.awardRegistry()
+	if err != nil {
+		return err
+	}
+	h := oaipmh.NewHandler(datasets, oaipmh.Options{
+		BaseURL:        *baseURL,
+		RepositoryName: a.cfg.Publisher,
+		AdminEmail:     *adminEmail,
+		SiteURL:        a.cfg.SiteURL,
+		Publisher:      a.cfg.Publisher,
+	})
+	h.Funding = awards
+
+	mux := http.NewServeMux()
+	mux.Handle("/oai", h)
+	srv := &http.Server{Addr: *addr, Handler: a.instrument("oai", mux)}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(a.out, "Serving OAI-PMH at %s\n", *baseURL)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
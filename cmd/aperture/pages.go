@@ -0,0 +1,270 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/cloudfront"
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/conneg"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/search"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("pages", &command{
+		summary: "Manage dataset landing pages",
+		subcommands: map[string]*command{
+			"rebuild": {
+				usage:      "[--changed-since DATE] [--force] [--dry-run]",
+				summary:    "Re-render landing pages whose metadata, stats, or template changed",
+				run:        runPagesRebuild,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermPublish,
+			},
+			"signposting-policy": {
+				usage:   "[--name NAME]",
+				summary: "Print the CloudFront response headers policy adding Signposting Link headers to landing pages",
+				run:     runPagesSignpostingPolicy,
+			},
+			"serve": {
+				usage:   "[--addr ADDR]",
+				summary: "Serve landing pages with content negotiation (HTML, DataCite JSON, JSON-LD, citations)",
+				run:     runPagesServe,
+			},
+			"preview": {
+				usage:   "--template-dir DIR [--addr ADDR] [--sample] [--check]",
+				summary: "Serve landing pages rendered from a local theme with live reload",
+				run:     runPagesPreview,
+				scope:   token.ScopeDatasetsRead,
+			},
+		},
+	})
+}
+
+// landingBuilder returns a builder that publishes to the frontend
+// bucket and invalidates the configured distribution.
+func (a *app) landingBuilder() (*landing.Builder, error) {
+	store, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	renderer, err := landing.NewRenderer(landing.DefaultTemplates())
+	if err != nil {
+		return nil, err
+	}
+	objects, err := a.s3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &landing.Builder{
+		Datasets:    datasets,
+		State:       store,
+		Renderer:    renderer,
+		Publisher:   objects,
+		Bucket:      a.cfg.FrontendBucket(),
+		DownloadURL: a.cfg.DownloadURL,
+		SiteURL:     a.cfg.SiteURL,
+		Concepts:    &collection.Registry{State: store},
+	}
+	// Related datasets are found by co-citation in the catalog, and by
+	// similar metadata if search is configured.
+	related := &search.Searcher{Datasets: datasets}
+	if a.cfg.OpenSearchURL != "" {
+		x := a.indexer(store)
+		related.Index, related.Alias = x.Index, x.Alias
+	}
+	b.Related = related
+	if b.Citations, err = a.citationTracker(); err != nil {
+		return nil, err
+	}
+	if a.cfg.CloudFrontDistributionID != "" {
+		creds, err := aws.CredentialsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		b.Invalidator = cloudfront.NewClient(a.cfg.CloudFrontDistributionID, creds)
+	}
+	return b, nil
+}
+
+func runPagesRebuild(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("pages rebuild")
+	since := fs.String("changed-since", "", "only consider datasets updated since this date (YYYY-MM-DD or RFC 3339)")
+	force := fs.Bool("force", false, "re-render selected pages even if unchanged")
+	dryRun := fs.Bool("dry-run", false, "report pages that would be re-rendered")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	opts := landing.RebuildOptions{Force: *force, DryRun: *dryRun}
+	if *since != "" {
+		t, err := parseTime(*since)
+		if err != nil {
+			return err
+		}
+		opts.ChangedSince = t
+	}
+
+	b, err := a.landingBuilder()
+	if err != nil {
+		return err
+	}
+	res, err := b.Rebuild(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range res.Changes {
+		fmt.Fprintf(a.out, "%-10s %s\n", c.Reason, c.Path)
+	}
+	verb := "Rebuilt"
+	if *dryRun {
+		verb = "Would rebuild"
+	}
+	fmt.Fprintf(a.out, "%s %d pages, %d unchanged\n", verb, len(res.Changes), res.Unchanged)
+	if res.Invalidation != "" {
+		fmt.Fprintf(a.out, "CloudFront invalidation: %s\n", res.Invalidation)
+	}
+	return nil
+}
+
+func runPagesSignpostingPolicy(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("pages signposting-policy")
+	name := fs.String("name", a.cfg.BucketPrefix()+"-signposting", "name of the policy")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("pages signposting-policy [--name NAME]")
+	}
+	return a.printJSON(cloudfront.NewHeadersPolicy(*name,
+		"FAIR Signposting linkset of landing pages; attach to the /datasets/* behavior",
+		cloudfront.CustomHeader{Header: "Link", Value: landing.LinksetHeader}))
+}
+
+func runPagesPreview(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("pages preview")
+	dir := fs.String("template-dir", "", "directory containing dataset.html and any partial templates")
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	sample := fs.Bool("sample", false, "preview with sample metadata instead of the dataset catalog")
+	check := fs.Bool("check", false, "validate the templates and exit")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return usageError("pages preview --template-dir DIR [--addr ADDR] [--sample] [--check]")
+	}
+	templates := os.DirFS(*dir)
+
+	if *check {
+		if err := landing.ValidateTemplates(templates); err != nil {
+			return fmt.Errorf("templates in %s are invalid:\n%w", *dir, err)
+		}
+		fmt.Fprintf(a.out, "Templates in %s are valid\n", *dir)
+		return nil
+	}
+
+	var source landing.DatasetSource
+	if !*sample {
+		datasets, err := a.datasets()
+		if err != nil {
+			return err
+		}
+		source = func(ctx context.Context) ([]*dataset.Dataset, error) {
+			all, err := datasets.List(ctx)
+			if err != nil || len(all) > 0 {
+				return all, err
+			}
+			return []*dataset.Dataset{landing.SampleDataset()}, nil
+		}
+	}
+	if err := landing.ValidateTemplates(templates); err != nil {
+		fmt.Fprintf(a.out, "Warning: templates are invalid; fix them and the page will reload:\n%v\n", err)
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: landing.NewPreview(templates, source)}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(a.out, "Previewing %s at http://%s/ (allowed functions: %s)\n",
+		*dir, *addr, strings.Join(landing.AllowedFuncs(), ", "))
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func runPagesServe(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("pages serve")
+	addr := fs.String("addr", "127.0.0.1:8086", "address to listen on")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("pages serve [--addr ADDR]")
+	}
+	if a.cfg.SiteURL == "" || a.cfg.Publisher == "" {
+		return fmt.Errorf("APERTURE_SITE_URL and APERTURE_PUBLISHER must be set")
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	renderer, err := landing.NewRenderer(landing.DefaultTemplates())
+	if err != nil {
+		return err
+	}
+	awards, err := a.awardRegistry()
+	if err != nil {
+		return err
+	}
+	h := conneg.NewHandler(datasets, renderer, conneg.Options{
+		SiteURL:     a.cfg.SiteURL,
+		Publisher:   a.cfg.Publisher,
+		DownloadURL: a.cfg.DownloadURL,
+	})
+	h.Funding = awards
+
+	srv := &http.Server{Addr: *addr, Handler: a.instrument("pages", h)}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(a.out, "Serving landing pages at http://%s/datasets/\n", *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
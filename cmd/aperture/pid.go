@@ -0,0 +1,302 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/pidgraph"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("pid", &command{
+		summary: "Mint persistent identifiers (DOIs, ARKs, and handles)",
+		subcommands: map[string]*command{
+			"schemes": {
+				summary: "Show the identifier scheme of each collection",
+				run:     runPIDSchemes,
+				scope:   token.ScopeDOIRead,
+			},
+			"mint": {
+				usage:      "<dataset>",
+				summary:    "Mint an identifier for a dataset published without one",
+				run:        runPIDMint,
+				scope:      token.ScopeDOIWrite,
+				permission: authz.PermPublish,
+			},
+			"update": {
+				usage:      "<dataset>",
+				summary:    "Re-register a dataset's identifier metadata and target URL",
+				run:        runPIDUpdate,
+				scope:      token.ScopeDOIWrite,
+				permission: authz.PermPublish,
+			},
+			"media": {
+				usage:      "<dataset>",
+				summary:    "Register direct links to a dataset's files with its DOI for content negotiation",
+				run:        runPIDMedia,
+				scope:      token.ScopeDOIWrite,
+				permission: authz.PermPublish,
+			},
+			"graph": {
+				usage:   "[--format graph|scholix]",
+				summary: "Print the identifier graph of published datasets",
+				run:     runPIDGraph,
+				scope:   token.ScopeDOIRead,
+			},
+			"publish-graph": {
+				summary:    "Publish the identifier graph and Scholix links for aggregators",
+				run:        runPIDPublishGraph,
+				scope:      token.ScopeDOIWrite,
+				permission: authz.PermPublish,
+			},
+		},
+	})
+}
+
+// pidRegistrar returns the registrar minting identifiers in the
+// configured schemes, or nil if no scheme has credentials.
+func (a *app) pidRegistrar() (*pid.Registrar, error) {
+	r := &pid.Registrar{
+		Default:     pid.Scheme(a.cfg.PIDScheme),
+		Collections: make(map[string]pid.Scheme),
+		Minters:     make(map[pid.Scheme]pid.Minter),
+		SiteURL:     a.cfg.SiteURL,
+		Publisher:   a.cfg.Publisher,
+		DownloadURL: a.cfg.DownloadURL,
+	}
+	for coll, scheme := range a.cfg.PIDSchemes {
+		r.Collections[coll] = pid.Scheme(scheme)
+	}
+	if a.cfg.DataCitePrefix != "" && a.cfg.DataCiteRepositoryID != "" {
+		client := a.newDataCiteClient(0)
+		r.Minters[pid.SchemeDOI] = &pid.DataCite{Client: client, Media: client, Prefix: a.cfg.DataCitePrefix, Synced: a.dataCiteSynced}
+	}
+	if a.cfg.ARKShoulder != "" && a.cfg.EZIDUsername != "" {
+		r.Minters[pid.SchemeARK] = pid.NewEZID(pid.EZIDOptions{
+			BaseURL:  a.cfg.EZIDURL,
+			Username: a.cfg.EZIDUsername,
+			Password: a.cfg.EZIDPassword,
+			Shoulder: a.cfg.ARKShoulder,
+		})
+	}
+	if a.cfg.HandlePrefix != "" && a.cfg.HandleURL != "" {
+		r.Minters[pid.SchemeHandle] = pid.NewHandle(pid.HandleOptions{
+			BaseURL:  a.cfg.HandleURL,
+			Prefix:   a.cfg.HandlePrefix,
+			Admin:    a.cfg.HandleAdmin,
+			Password: a.cfg.HandlePassword,
+		})
+	}
+	if len(r.Minters) == 0 {
+		return nil, nil
+	}
+	// Collections choose where their concept DOIs resolve.
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	r.Concepts = &collection.Registry{State: s}
+	return r, nil
+}
+
+func runPIDSchemes(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("pid schemes")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	r, err := a.pidRegistrar()
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		fmt.Fprintln(a.out, "No identifier scheme is configured; datasets are published without identifiers.")
+		fmt.Fprintln(a.out, "Set DATACITE_PREFIX and DATACITE_REPOSITORY_ID for DOIs, ARK_SHOULDER and EZID_USERNAME for ARKs, or HANDLE_PREFIX and HANDLE_API_URL for handles.")
+		return nil
+	}
+	status := func(s pid.Scheme) string {
+		if _, ok := r.Minters[s]; ok {
+			return ""
+		}
+		return " (not configured)"
+	}
+	fmt.Fprintf(a.out, "%-24s %s%s\n", "(default)", r.Scheme(""), status(r.Scheme("")))
+	for _, coll := range r.Configured() {
+		s := r.Scheme(coll)
+		fmt.Fprintf(a.out, "%-24s %s%s\n", coll, s, status(s))
+	}
+	if r.SiteURL == "" {
+		fmt.Fprintln(a.out, "Warning: APERTURE_SITE_URL is not set; identifiers cannot be minted.")
+	}
+	return nil
+}
+
+func runPIDMint(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("pid mint")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("pid mint <dataset>")
+	}
+	m, err := a.depositManager(true)
+	if err != nil {
+		return err
+	}
+	d, id, err := m.AssignPID(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Minted %s for %s\n", id, d.ID)
+	return nil
+}
+
+func runPIDUpdate(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("pid update")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("pid update <dataset>")
+	}
+	m, err := a.depositManager(false)
+	if err != nil {
+		return err
+	}
+	d, err := m.UpdatePID(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Updated %s\n", pid.Identifier(d))
+	return nil
+}
+
+func runPIDMedia(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("pid media")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("pid media <dataset>")
+	}
+	r, err := a.pidRegistrar()
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		return fmt.Errorf("no identifier scheme is configured")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+		return err
+	}
+	if d.DOI == "" {
+		return fmt.Errorf("%s has no DOI", d.ID)
+	}
+	if a.cfg.DownloadURL == "" {
+		return fmt.Errorf("download URL not configured; set APERTURE_DOWNLOAD_URL")
+	}
+	media, err := r.RegisterMedia(ctx, d)
+	if err != nil {
+		return err
+	}
+	if len(media) == 0 {
+		fmt.Fprintf(a.out, "No media registered: %s has no publicly downloadable files with a media type\n", d.ID)
+		return nil
+	}
+	for _, m := range media {
+		fmt.Fprintf(a.out, "%-32s %s\n", m.MediaType, m.URL)
+	}
+	fmt.Fprintf(a.out, "Registered %d media with doi:%s\n", len(media), d.DOI)
+	return nil
+}
+
+// pidGraph builds the identifier graph of the catalog.
+func (a *app) pidGraph(ctx context.Context) (*pidgraph.Graph, error) {
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	all, err := datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pidgraph.Build(all, a.cfg.Publisher, time.Now()), nil
+}
+
+func runPIDGraph(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("pid graph")
+	format := fs.String("format", "graph", "output format: graph (nodes and edges) or scholix (links)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	g, err := a.pidGraph(ctx)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "graph":
+		return a.printJSON(g)
+	case "scholix":
+		return a.printJSON(g.Scholix())
+	}
+	return usageError("pid graph [--format graph|scholix]")
+}
+
+func runPIDPublishGraph(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("pid publish-graph")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	g, err := a.pidGraph(ctx)
+	if err != nil {
+		return err
+	}
+	b, err := a.landingBuilder()
+	if err != nil {
+		return err
+	}
+	paths, err := g.Publish(ctx, b.Publisher, b.Bucket)
+	if err != nil {
+		return err
+	}
+	if b.Invalidator != nil {
+		if _, err := b.Invalidator.Invalidate(ctx, paths); err != nil {
+			return fmt.Errorf("failed to invalidate published graph: %w", err)
+		}
+	}
+	fmt.Fprintf(a.out, "Published %d identifiers and %d relations\n", len(g.Nodes), len(g.Edges))
+	for _, p := range paths {
+		fmt.Fprintf(a.out, "  %s%s\n", a.cfg.SiteURL, p)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/cloudfront"
+	"github.com/scttfrdmn/aperture/internal/config"
+	"github.com/scttfrdmn/aperture/internal/deploy"
+	"github.com/scttfrdmn/aperture/internal/iam"
+	"github.com/scttfrdmn/aperture/internal/lambda"
+	"github.com/scttfrdmn/aperture/internal/preflight"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/servicequotas"
+	"github.com/scttfrdmn/aperture/internal/ses"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// bucketSuffixes name the buckets both stacks create, after the bucket
+// prefix. The CloudFormation template also creates the audit anchor
+// bucket.
+var bucketSuffixes = []string{
+	"public-media", "private-media", "restricted-media", "embargoed-media",
+	"processing", "logs", "frontend", "quarantine",
+}
+
+// Quotas the Terraform stack needs: the distributions it creates, and
+// the default Lambda concurrency below which the API is throttled.
+const (
+	stackDistributions = 2
+	lambdaConcurrency  = 1000
+)
+
+func runPreflight(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("deploy preflight")
+	asJSON := fs.Bool("json", false, "print the findings as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("deploy preflight [--json]")
+	}
+	d, err := a.deployer(ctx)
+	if err != nil {
+		return err
+	}
+	r, err := a.preflight(ctx, d)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if err := a.printJSON(r); err != nil {
+			return err
+		}
+	} else {
+		printPreflight(a.out, r)
+	}
+	if r.Failed() {
+		return fmt.Errorf("%d preflight checks of %s failed", r.Count(preflight.Fail), a.cfg.Environment)
+	}
+	return nil
+}
+
+// preflight runs the preflight checks of the configured environment,
+// deployed before if d has a current deployment of it.
+func (a *app) preflight(ctx context.Context, d *deploy.Deployer) (*preflight.Report, error) {
+	_, err := d.Current(ctx, a.cfg.Environment)
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		return nil, err
+	}
+	checks, err := a.preflightChecks(ctx, err == nil)
+	if err != nil {
+		return nil, err
+	}
+	return preflight.Run(ctx, checks), nil
+}
+
+// preflightChecks returns the checks of the configured backend's stack,
+// each run with the credentials of the layer it concerns. Only the
+// Terraform stack creates CloudFront distributions and Lambda
+// functions.
+func (a *app) preflightChecks(ctx context.Context, deployed bool) ([]preflight.Check, error) {
+	var checks []preflight.Check
+	creds := make(map[string]aws.Credentials)
+	for _, layer := range config.DeployLayers {
+		c, err := a.credentials(ctx, layer)
+		switch {
+		case err == nil:
+			creds[layer] = c
+		case a.cfg.DeployRoles[layer] == "":
+			return nil, err
+		default:
+			checks = append(checks, preflight.Check{Name: "Deployment role (" + layer + ")", Run: func(context.Context) preflight.Finding {
+				return preflight.Finding{Status: preflight.Fail, Detail: err.Error()}
+			}})
+		}
+	}
+
+	if c, ok := creds["storage"]; ok {
+		var buckets []string
+		for _, suffix := range bucketSuffixes {
+			buckets = append(buckets, a.cfg.BucketPrefix()+"-"+suffix)
+		}
+		if a.cfg.DeployBackend == "cloudformation" {
+			buckets = append(buckets, a.cfg.BucketPrefix()+"-audit-anchors")
+		}
+		client, err := s3.NewClient(s3.Options{Region: a.cfg.AWSRegion, Credentials: c, Metrics: a.recorder()})
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, preflight.Buckets(client, buckets, deployed))
+	}
+	if c, ok := creds["compute"]; ok {
+		var mail *ses.Client
+		if region, ok := preflight.SESRegion(a.cfg.SMTPAddr); ok {
+			mail = ses.NewClient(ses.Options{Region: region, Credentials: c})
+		}
+		checks = append(checks, preflight.Mail(mail, a.cfg.SMTPAddr))
+		if a.cfg.DeployBackend != "cloudformation" {
+			need := stackDistributions
+			if deployed {
+				need = 0
+			}
+			checks = append(checks,
+				preflight.LambdaConcurrency(lambda.NewClient(lambda.Options{Region: a.cfg.AWSRegion, Credentials: c}), lambdaConcurrency),
+				preflight.CloudFrontDistributions(
+					servicequotas.NewClient(servicequotas.Options{Region: "us-east-1", Credentials: c}),
+					cloudfront.NewClient("", c), need),
+			)
+		}
+	}
+	checks = append(checks, preflight.DataCite(a.newDataCiteClient(0), a.cfg.DataCiteRepositoryID, a.cfg.DataCitePrefix))
+
+	// The layers deployed with the same role are checked together, for
+	// the actions deploying all of them.
+	actions := a.deployActions()
+	var roles []string
+	layers := make(map[string][]string)
+	for _, layer := range config.DeployLayers {
+		if _, ok := creds[layer]; !ok {
+			continue
+		}
+		role := a.cfg.DeployRoles[layer]
+		if _, ok := layers[role]; !ok {
+			roles = append(roles, role)
+		}
+		layers[role] = append(layers[role], layer)
+	}
+	for _, role := range roles {
+		var want []string
+		for _, layer := range layers[role] {
+			want = append(want, actions[layer]...)
+		}
+		slices.Sort(want)
+		check := preflight.Permissions(iam.NewClient(iam.Options{Credentials: creds[layers[role][0]]}), slices.Compact(want))
+		if len(roles) > 1 {
+			check.Name += " (" + strings.Join(layers[role], ", ") + ")"
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// deployActions returns the IAM actions deploying each layer of the
+// configured stack takes.
+func (a *app) deployActions() map[string][]string {
+	actions := make(map[string][]string)
+	for layer, list := range preflight.DeployActions {
+		actions[layer] = slices.Clone(list)
+	}
+	if a.cfg.DeployBackend == "cloudformation" {
+		actions["compute"] = append(actions["compute"], "cloudformation:CreateChangeSet", "cloudformation:ExecuteChangeSet", "cloudformation:DescribeStacks")
+	} else {
+		actions["compute"] = append(actions["compute"], "s3:GetObject", "s3:PutObject")
+	}
+	if a.cfg.OutputRegistry == "ssm" {
+		actions["compute"] = append(actions["compute"], "ssm:GetParametersByPath", "ssm:PutParameter", "ssm:DeleteParameters")
+	}
+	if a.cfg.StorageKMSKey != "" {
+		actions["storage"] = append(actions["storage"], "kms:CreateGrant")
+	}
+	// With logs in an account of their own, the storage account's
+	// trail delivers the access logs.
+	if a.cfg.DeployRoles["logging"] != a.cfg.DeployRoles["storage"] {
+		actions["storage"] = append(actions["storage"], "cloudtrail:CreateTrail", "cloudtrail:PutEventSelectors", "cloudtrail:StartLogging")
+	}
+	return actions
+}
+
+// printPreflight writes the findings of r, then a summary.
+func printPreflight(w io.Writer, r *preflight.Report) {
+	for _, f := range r.Findings {
+		fmt.Fprintf(w, "%-4s  %-24s %s\n", strings.ToUpper(string(f.Status)), f.Check, f.Detail)
+	}
+	fmt.Fprintf(w, "Preflight: %d passed, %d warnings, %d failed, %d skipped\n",
+		r.Count(preflight.Pass), r.Count(preflight.Warn), r.Count(preflight.Fail), r.Count(preflight.Skip))
+}
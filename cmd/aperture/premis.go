@@ -0,0 +1,259 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/premis"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("premis", &command{
+		summary: "Record and export the preservation events of dataset files",
+		subcommands: map[string]*command{
+			"export": {
+				usage:   "<dataset> [--format xml|json] [--out FILE]",
+				summary: "Export a dataset's objects and their preservation events as PREMIS",
+				run:     runPremisExport,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"record": {
+				usage:      "<dataset> <path> --type migration|replication [--version N] [--outcome success|failure] [--detail TEXT] [--note TEXT] [--source URI] [--copy URI]",
+				summary:    "Record a migration or replication of a file performed outside Aperture",
+				run:        runPremisRecord,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+			},
+			"ingest": {
+				usage:      "[--dataset REF]",
+				summary:    "Record the ingestion of files stored before events were recorded",
+				run:        runPremisIngest,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+			},
+		},
+	})
+}
+
+// premisLog returns the preservation event log.
+func (a *app) premisLog() (*premis.Log, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	return a.premisEvents(s)
+}
+
+// premisEvents returns the log of the PREMIS events kept in s, which
+// appends each event to the audit log too.
+func (a *app) premisEvents(s state.Store) (*premis.Log, error) {
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	return &premis.Log{State: s, Audit: log}, nil
+}
+
+func runPremisExport(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("premis export")
+	format := fs.String("format", "xml", "export format: xml or json")
+	out := fs.String("out", "", "write the export to `FILE` instead of standard output")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("premis export <dataset> [--format xml|json] [--out FILE]")
+	}
+	if !slices.Contains(premis.Formats, *format) {
+		return fmt.Errorf("unknown format %q (want %s)", *format, strings.Join(premis.Formats, " or "))
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	log, err := a.premisLog()
+	if err != nil {
+		return err
+	}
+	ex, err := log.Export(ctx, d)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = a.out
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "json" {
+		err = premis.WriteJSON(w, ex)
+	} else {
+		err = premis.WriteXML(w, ex)
+	}
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		fmt.Fprintf(a.out, "Exported %d objects and %d events of %s to %s\n", len(ex.Objects), len(ex.Events), d.ID, *out)
+	}
+	return nil
+}
+
+func runPremisRecord(ctx context.Context, a *app, args []string) error {
+	const synopsis = "premis record <dataset> <path> --type migration|replication [--version N] [--outcome success|failure] [--detail TEXT] [--note TEXT] [--source URI] [--copy URI]"
+	fs := newFlagSet("premis record")
+	typ := fs.String("type", "", "event type: migration or replication")
+	version := fs.Int("version", 0, "the version of the file (default the latest)")
+	outcome := fs.String("outcome", string(premis.OutcomeSuccess), "event outcome: success or failure")
+	detail := fs.String("detail", "", "what was done, e.g. the tool and target format of a migration")
+	note := fs.String("note", "", "a note on the outcome")
+	source := fs.String("source", "", "`URI` of the object a migration was made from")
+	copyURI := fs.String("copy", "", "`URI` of the object a migration or replication made")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError(synopsis)
+	}
+	t, err := premis.ParseType(*typ)
+	if err != nil {
+		return err
+	}
+	if t != premis.TypeMigration && t != premis.TypeReplication {
+		return fmt.Errorf("only migration and replication events can be recorded; ingestion and fixity checks are recorded by Aperture")
+	}
+	o := premis.Outcome(*outcome)
+	if o != premis.OutcomeSuccess && o != premis.OutcomeFailure {
+		return fmt.Errorf("unknown outcome %q (want success or failure)", *outcome)
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	f, err := datasetFile(d, *version, pos[1])
+	if err != nil {
+		return err
+	}
+	e := premis.Event{Type: t, Bucket: f.Bucket, Key: f.Key, Detail: *detail, Outcome: o, OutcomeDetail: *note}
+	if *source != "" {
+		e.Links = append(e.Links, premis.Link{Role: premis.RoleSource, URI: *source})
+	}
+	if *copyURI != "" {
+		e.Links = append(e.Links, premis.Link{Role: premis.RoleOutcome, URI: *copyURI})
+	}
+	log, err := a.premisLog()
+	if err != nil {
+		return err
+	}
+	events := []premis.Event{e}
+	if err := log.Record(ctx, events...); err != nil {
+		return err
+	}
+	alog, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, alog, "premis.record", d.ID, map[string]string{
+		"event": events[0].ID, "type": string(t), "path": f.Path, "outcome": string(o),
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Recorded %s event %s for %s %s\n", t, events[0].ID, d.ID, f.Path)
+	return nil
+}
+
+// datasetFile returns the file at path in version n of d, or in its
+// latest version if n is 0.
+func datasetFile(d *dataset.Dataset, n int, path string) (*dataset.File, error) {
+	v := d.Latest()
+	if n != 0 {
+		v = d.Version(n)
+	}
+	if v == nil {
+		return nil, fmt.Errorf("%w: %s has no version %d", dataset.ErrNotFound, d.ID, n)
+	}
+	for i := range v.Files {
+		if v.Files[i].Path == path {
+			return &v.Files[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s version %d has no file %s", dataset.ErrNotFound, d.ID, v.Number, path)
+}
+
+func runPremisIngest(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("premis ingest")
+	ref := fs.String("dataset", "", "record the files of one dataset only")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("premis ingest [--dataset REF]")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	var all []*dataset.Dataset
+	if *ref != "" {
+		d, err := datasets.Resolve(ctx, *ref)
+		if err != nil {
+			return err
+		}
+		all = []*dataset.Dataset{d}
+	} else if all, err = datasets.List(ctx); err != nil {
+		return err
+	}
+	log, err := a.premisLog()
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, d := range all {
+		events, err := log.Ingest(ctx, d)
+		n += len(events)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(a.out, "Recorded the ingestion of %d objects in %d datasets\n", n, len(all))
+	return nil
+}
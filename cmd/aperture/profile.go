@@ -0,0 +1,430 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/profile"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("profile", &command{
+		summary: "Manage your depositor profile and linked ORCID iD",
+		subcommands: map[string]*command{
+			"show": {
+				usage:   "[--json]",
+				summary: "Show your profile",
+				run:     runProfileShow,
+			},
+			"set": {
+				usage:   "[--name \"Family, Given\"] [--affiliation ORG]",
+				summary: "Set the name and affiliation credited when you are a creator",
+				run:     runProfileSet,
+			},
+			"link-orcid": {
+				summary: "Link the ORCID iD you signed in with to your profile",
+				run:     runProfileLinkORCID,
+			},
+			"unlink-orcid": {
+				summary: "Remove the ORCID iD from your profile",
+				run:     runProfileUnlinkORCID,
+			},
+		},
+	})
+	register("creators", &command{
+		summary: "Manage dataset creators",
+		subcommands: map[string]*command{
+			"list": {
+				usage:   "<dataset>",
+				summary: "List a dataset's creators",
+				run:     runCreatorsList,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"add": {
+				usage:      "<dataset> (--me | --user EMAIL | --name NAME [--orcid ID] [--affiliation ORG])",
+				summary:    "Add a creator, taking name and ORCID iD from a depositor profile where possible",
+				run:        runCreatorsAdd,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermCurate,
+			},
+			"remove": {
+				usage:      "<dataset> <n>",
+				summary:    "Remove the nth creator (as numbered by 'creators list')",
+				run:        runCreatorsRemove,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermCurate,
+			},
+			"sync": {
+				usage:      "<dataset>... | --all",
+				summary:    "Refresh linked creators from their profiles' authenticated ORCID iDs",
+				run:        runCreatorsSync,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermCurate,
+			},
+		},
+	})
+}
+
+// profiles returns the depositor profile registry.
+func (a *app) profiles() (*profile.Registry, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	return profile.NewRegistry(s, log), nil
+}
+
+func runProfileShow(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("profile show")
+	asJSON := fs.Bool("json", false, "print the profile as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	profiles, err := a.profiles()
+	if err != nil {
+		return err
+	}
+	p, err := profiles.Mine(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(p)
+	}
+	printProfile(a, p)
+	return nil
+}
+
+func printProfile(a *app, p *profile.Profile) {
+	fmt.Fprintf(a.out, "user:        %s\n", p.ID)
+	fmt.Fprintf(a.out, "name:        %s\n", p.Name)
+	fmt.Fprintf(a.out, "affiliation: %s\n", p.Affiliation)
+	if p.ORCID != "" {
+		fmt.Fprintf(a.out, "orcid:       https://orcid.org/%s (linked %s)\n", p.ORCID, p.ORCIDLinkedAt.Format(time.DateOnly))
+	} else {
+		fmt.Fprintln(a.out, "orcid:       not linked (sign in with ORCID, then run 'aperture profile link-orcid')")
+	}
+}
+
+func runProfileSet(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("profile set")
+	name := fs.String("name", "", "name as credited in citations (\"Family, Given\")")
+	affiliation := fs.String("affiliation", "", "institution")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *name == "" && *affiliation == "" {
+		return usageError("profile set [--name \"Family, Given\"] [--affiliation ORG]")
+	}
+	profiles, err := a.profiles()
+	if err != nil {
+		return err
+	}
+	p, err := profiles.Update(ctx, *name, *affiliation)
+	if err != nil {
+		return err
+	}
+	printProfile(a, p)
+	return nil
+}
+
+func runProfileLinkORCID(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("profile link-orcid")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	profiles, err := a.profiles()
+	if err != nil {
+		return err
+	}
+	p, changed, err := profiles.LinkORCID(ctx)
+	if err != nil {
+		return err
+	}
+	if changed {
+		fmt.Fprintf(a.out, "Linked ORCID iD %s to %s\n", p.ORCID, p.ID)
+	} else {
+		fmt.Fprintf(a.out, "ORCID iD %s is already linked to %s\n", p.ORCID, p.ID)
+	}
+	return nil
+}
+
+func runProfileUnlinkORCID(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("profile unlink-orcid")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	profiles, err := a.profiles()
+	if err != nil {
+		return err
+	}
+	p, err := profiles.UnlinkORCID(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "No ORCID iD is linked to %s\n", p.ID)
+	return nil
+}
+
+func runCreatorsList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("creators list")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("creators list <dataset>")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	for i, c := range d.Staged().Creators {
+		line := fmt.Sprintf("%2d  %s", i+1, c.Name)
+		if c.Affiliation != "" {
+			line += " (" + c.Affiliation + ")"
+		}
+		if c.ORCID != "" {
+			line += "  orcid:" + c.ORCID
+		}
+		if c.User != "" {
+			line += "  [" + c.User + "]"
+		}
+		fmt.Fprintln(a.out, line)
+	}
+	return nil
+}
+
+// managedDataset resolves ref and checks that the caller may manage it.
+func managedDataset(ctx context.Context, datasets *dataset.Store, ref string) (*dataset.Dataset, error) {
+	d, err := datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func runCreatorsAdd(ctx context.Context, a *app, args []string) error {
+	const usage = "creators add <dataset> (--me | --user EMAIL | --name NAME [--orcid ID] [--affiliation ORG])"
+	fs := newFlagSet("creators add")
+	me := fs.Bool("me", false, "add yourself from your profile")
+	user := fs.String("user", "", "add a depositor from their profile")
+	name := fs.String("name", "", "creator name (\"Family, Given\")")
+	orcid := fs.String("orcid", "", "ORCID iD of a creator without a profile")
+	affiliation := fs.String("affiliation", "", "creator affiliation")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || (*me && *user != "") {
+		return usageError(usage)
+	}
+	if *me {
+		*user = identity.FromContext(ctx).ID
+	}
+
+	var c dataset.Creator
+	profiles, err := a.profiles()
+	if err != nil {
+		return err
+	}
+	if *user != "" {
+		p, err := profiles.Get(ctx, *user)
+		if err != nil {
+			return fmt.Errorf("%w; run 'aperture profile set' as %s first", err, *user)
+		}
+		c = p.Creator()
+		if *name != "" {
+			c.Name = *name
+		}
+		if *affiliation != "" {
+			c.Affiliation = *affiliation
+		}
+		if c.ORCID == "" && *orcid != "" {
+			return fmt.Errorf("%s has no linked ORCID iD; they must sign in with ORCID and run 'aperture profile link-orcid'", c.User)
+		}
+	} else {
+		if *name == "" {
+			return usageError(usage)
+		}
+		if *orcid != "" && !identity.ValidORCID(*orcid) {
+			return fmt.Errorf("invalid ORCID iD %q", *orcid)
+		}
+		c = dataset.Creator{Name: *name, ORCID: strings.TrimPrefix(*orcid, "https://orcid.org/"), Affiliation: *affiliation}
+	}
+	if c.Name == "" {
+		return fmt.Errorf("creator has no name; use --name or set it with 'aperture profile set'")
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := managedDataset(ctx, datasets, pos[0])
+	if err != nil {
+		return err
+	}
+	if c.User != "" && slices.ContainsFunc(d.Staged().Creators, func(x dataset.Creator) bool { return x.User == c.User }) {
+		return fmt.Errorf("%s is already a creator of %s", c.User, d.ID)
+	}
+	if err := d.Edit(time.Now().UTC(), func(d *dataset.Dataset) { d.Creators = append(d.Creators, c) }); err != nil {
+		return err
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		return err
+	}
+	if err := recordCreators(ctx, a, "dataset.creators.add", d.ID, c); err != nil {
+		return err
+	}
+	printStaged(a, d)
+	fmt.Fprintf(a.out, "Added %s to %s\n", c.Name, d.ID)
+	return nil
+}
+
+func runCreatorsRemove(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("creators remove")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError("creators remove <dataset> <n>")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := managedDataset(ctx, datasets, pos[0])
+	if err != nil {
+		return err
+	}
+	creators := d.Staged().Creators
+	n, err := strconv.Atoi(pos[1])
+	if err != nil || n < 1 || n > len(creators) {
+		return fmt.Errorf("%s has no creator %s (see 'aperture creators list %s')", d.ID, pos[1], d.ID)
+	}
+	c := creators[n-1]
+	if err := d.Edit(time.Now().UTC(), func(d *dataset.Dataset) { d.Creators = slices.Delete(d.Creators, n-1, n) }); err != nil {
+		return err
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		return err
+	}
+	if err := recordCreators(ctx, a, "dataset.creators.remove", d.ID, c); err != nil {
+		return err
+	}
+	printStaged(a, d)
+	fmt.Fprintf(a.out, "Removed %s from %s\n", c.Name, d.ID)
+	return nil
+}
+
+func runCreatorsSync(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("creators sync")
+	all := fs.Bool("all", false, "sync every dataset you may manage")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if (len(pos) == 0) == !*all {
+		return usageError("creators sync <dataset>... | --all")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	profiles, err := a.profiles()
+	if err != nil {
+		return err
+	}
+
+	var targets []*dataset.Dataset
+	if *all {
+		list, err := datasets.List(ctx)
+		if err != nil {
+			return err
+		}
+		p := identity.FromContext(ctx)
+		for _, d := range list {
+			if authz.Decide(p, d.Resource(), authz.ActionManage).Allowed {
+				targets = append(targets, d)
+			}
+		}
+	} else {
+		for _, ref := range pos {
+			d, err := managedDataset(ctx, datasets, ref)
+			if err != nil {
+				return err
+			}
+			targets = append(targets, d)
+		}
+	}
+
+	for _, d := range targets {
+		changed, err := profiles.ApplyCreators(ctx, d)
+		if err != nil {
+			return err
+		}
+		if len(changed) == 0 {
+			continue
+		}
+		if err := datasets.Put(ctx, d); err != nil {
+			return err
+		}
+		log, err := a.auditLog()
+		if err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, log, "dataset.creators.sync", d.ID, map[string]string{"creators": strings.Join(changed, "; ")}); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "%s: updated %s\n", d.ID, strings.Join(changed, "; "))
+	}
+	return nil
+}
+
+// recordCreators records a change to one creator of a dataset.
+func recordCreators(ctx context.Context, a *app, action, datasetID string, c dataset.Creator) error {
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	return audit.Record(ctx, log, action, datasetID, map[string]string{
+		"name":  c.Name,
+		"orcid": c.ORCID,
+		"user":  c.User,
+	})
+}
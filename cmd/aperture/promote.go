@@ -0,0 +1,101 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/scttfrdmn/aperture/internal/config"
+	"github.com/scttfrdmn/aperture/internal/deploy"
+)
+
+const promoteUsage = "deploy promote --from ENV --to ENV [--dry-run] [--var-file FILE]... [--json] --reason TEXT"
+
+func runPromote(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("deploy promote")
+	from := fs.String("from", "", "the environment whose stack is promoted, e.g. staging")
+	to := fs.String("to", "", "the environment promoted to, e.g. prod")
+	dryRun := fs.Bool("dry-run", false, "show the configuration differences and the changes the promotion would make")
+	var varFiles stringsFlag
+	fs.Var(&varFiles, "var-file", "a further Terraform variables file of the target environment (repeatable; Terraform only)")
+	asJSON := fs.Bool("json", false, "print the differences and the promotion as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 || *from == "" || *to == "" || *from == *to {
+		return usageError(promoteUsage)
+	}
+	fromCfg, err := a.cfg.LoadEnvironment(*from)
+	if err != nil {
+		return err
+	}
+	toCfg, err := a.cfg.LoadEnvironment(*to)
+	if err != nil {
+		return err
+	}
+	diffs := config.Diff(fromCfg, toCfg)
+
+	target := &app{cfg: toCfg, in: a.in, out: a.out, metrics: a.metrics, tracer: a.tracer}
+	if err := target.discoverOutputs(ctx); err != nil {
+		return err
+	}
+	d, err := target.deployer(ctx)
+	if err != nil {
+		return err
+	}
+	opts := target.deployOptions()
+	opts.VarFiles, opts.DryRun = varFiles, *dryRun
+	if *asJSON {
+		d.Out = os.Stderr
+	} else {
+		if len(diffs) == 0 {
+			fmt.Fprintf(a.out, "%s and %s are configured alike\n", *from, *to)
+		} else {
+			fmt.Fprintf(a.out, "Configuration differences from %s to %s:\n", *from, *to)
+			tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+			for _, diff := range diffs {
+				fmt.Fprintf(tw, "  %s\t%s\t-> %s\n", diff.Setting, diff.From, diff.To)
+			}
+			tw.Flush()
+		}
+	}
+
+	p, err := d.Promote(ctx, *from, opts, target.smokeTests())
+	if *asJSON {
+		if jerr := a.printJSON(struct {
+			Differences []config.Difference `json:"differences"`
+			*deploy.Promotion
+		}{diffs, p}); err == nil {
+			err = jerr
+		}
+		return err
+	}
+	for _, c := range p.Checks {
+		if c.Error != "" {
+			fmt.Fprintf(a.out, "  FAIL  %s: %s\n", c.Name, c.Error)
+		} else {
+			fmt.Fprintf(a.out, "  ok    %s\n", c.Name)
+		}
+	}
+	if err != nil || *dryRun {
+		return err
+	}
+	fmt.Fprintf(a.out, "Promoted v%s (stack %.12s) from %s to %s\n", p.Deployment.Version, p.Deployment.StackHash, *from, *to)
+	return nil
+}
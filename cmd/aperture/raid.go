@@ -0,0 +1,144 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/raid"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("raid", &command{
+		summary: "Link datasets to RAiD research activity identifiers",
+		subcommands: map[string]*command{
+			"link": {
+				usage:      "<dataset> <raid>",
+				summary:    "Link a dataset to a project's RAiD, adding its DOI to the RAiD record",
+				run:        runRAiDLink,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermDeposit,
+			},
+			"unlink": {
+				usage:      "<dataset> <raid>",
+				summary:    "Remove a dataset's link to a RAiD",
+				run:        runRAiDUnlink,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermDeposit,
+			},
+			"push": {
+				usage:      "<dataset>",
+				summary:    "Add a dataset's DOI to each linked RAiD record",
+				run:        runRAiDPush,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermDeposit,
+			},
+		},
+	})
+}
+
+// raidManager returns the RAiD link manager, updating RAiD records
+// only when a registration agency token is configured.
+func (a *app) raidManager() (*raid.Manager, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	m := &raid.Manager{Datasets: dataset.NewStore(s), Log: log}
+	if a.cfg.RAiDToken != "" {
+		m.Registry = raid.NewClient(raid.Options{BaseURL: a.cfg.RAiDURL, Token: a.cfg.RAiDToken})
+	}
+	return m, nil
+}
+
+func runRAiDLink(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("raid link")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError("raid link <dataset> <raid>")
+	}
+	m, err := a.raidManager()
+	if err != nil {
+		return err
+	}
+	d, err := m.Link(ctx, pos[0], pos[1])
+	if err != nil {
+		return err
+	}
+	h, _ := raid.Normalize(pos[1])
+	fmt.Fprintf(a.out, "Linked %s to %s%s\n", d.ID, raid.Resolver, h)
+	if d.DOI == "" {
+		fmt.Fprintln(a.out, "The RAiD record will list the dataset once it is published with a DOI.")
+	}
+	return nil
+}
+
+func runRAiDUnlink(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("raid unlink")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError("raid unlink <dataset> <raid>")
+	}
+	m, err := a.raidManager()
+	if err != nil {
+		return err
+	}
+	d, err := m.Unlink(ctx, pos[0], pos[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Unlinked %s from %s\n", d.ID, pos[1])
+	return nil
+}
+
+func runRAiDPush(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("raid push")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("raid push <dataset>")
+	}
+	m, err := a.raidManager()
+	if err != nil {
+		return err
+	}
+	changed, err := m.Push(ctx, pos[0])
+	for _, h := range changed {
+		fmt.Fprintf(a.out, "Updated %s%s\n", raid.Resolver, h)
+	}
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		fmt.Fprintln(a.out, "RAiD records are up to date")
+	}
+	return nil
+}
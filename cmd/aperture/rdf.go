@@ -0,0 +1,87 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/rdfdump"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("rdf", &command{
+		summary: "Publish dataset metadata as RDF for semantic-web consumers",
+		subcommands: map[string]*command{
+			"dump": {
+				usage:      "[--dry-run] [--json]",
+				summary:    "Publish an N-Quads dump of every published dataset and its VoID description",
+				run:        runRDFDump,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermPublish,
+			},
+		},
+	})
+}
+
+func runRDFDump(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("rdf dump")
+	dryRun := fs.Bool("dry-run", false, "generate the dump without publishing it")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("rdf dump [--dry-run] [--json]")
+	}
+
+	b, err := a.landingBuilder()
+	if err != nil {
+		return err
+	}
+	p := &rdfdump.Dumper{
+		Datasets:    b.Datasets,
+		Objects:     b.Publisher,
+		Bucket:      b.Bucket,
+		SiteURL:     a.cfg.SiteURL,
+		Publisher:   a.cfg.Publisher,
+		DownloadURL: a.cfg.DownloadURL,
+	}
+	res, err := p.Dump(ctx, *dryRun)
+	if err != nil {
+		return err
+	}
+	if len(res.Paths) > 0 && b.Invalidator != nil {
+		if _, err := b.Invalidator.Invalidate(ctx, res.Paths); err != nil {
+			return fmt.Errorf("failed to invalidate RDF dump: %w", err)
+		}
+	}
+
+	if *asJSON {
+		return a.printJSON(res)
+	}
+	if *dryRun {
+		fmt.Fprintf(a.out, "%d datasets, %d quads, %d bytes compressed (dry run)\n", res.Datasets, res.Quads, res.Bytes)
+		return nil
+	}
+	fmt.Fprintf(a.out, "Published %d quads describing %d datasets (%d bytes compressed)\n", res.Quads, res.Datasets, res.Bytes)
+	for _, p := range res.Paths {
+		fmt.Fprintf(a.out, "  %s%s\n", a.cfg.SiteURL, p)
+	}
+	return nil
+}
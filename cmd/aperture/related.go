@@ -0,0 +1,129 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/search"
+)
+
+func runDatasetRelated(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset related")
+	size := fs.Int("size", search.DefaultRelated, "number of datasets to recommend")
+	asJSON := fs.Bool("json", false, "print the recommendations with their scores and reasons as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("dataset related <dataset> [--size N] [--json]")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	// Without search, datasets are related by co-citation alone.
+	s := &search.Searcher{Datasets: datasets}
+	if a.cfg.OpenSearchURL != "" {
+		if s, err = a.searcher(); err != nil {
+			return err
+		}
+	}
+	recs, err := s.Recommend(ctx, d, *size)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(recs)
+	}
+	if len(recs) == 0 {
+		fmt.Fprintf(a.out, "No datasets related to %s\n", d.ID)
+		return nil
+	}
+	for _, r := range recs {
+		fmt.Fprintf(a.out, "%-24s %5.2f  %-50s %s\n", r.ID, r.Score, clip(r.Title, 50), strings.Join(r.Reasons, ", "))
+	}
+	return nil
+}
+
+func runDatasetRelate(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset relate")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 3 {
+		return usageError("dataset relate <dataset> <relation> <identifier>")
+	}
+	rel, err := dataset.NewRelatedIdentifier(pos[1], pos[2])
+	if err != nil {
+		return err
+	}
+	d, err := updateDataset(ctx, a, "dataset.relate", pos[0], func(d *dataset.Dataset) map[string]string {
+		d.Related = slices.DeleteFunc(d.Related, func(r dataset.RelatedIdentifier) bool { return r.Key() == rel.Key() })
+		d.Related = append(d.Related, rel)
+		return map[string]string{"relation": rel.Relation, "identifier": rel.Identifier, "type": rel.Type}
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "%s %s %s %s\n", d.ID, rel.Relation, rel.Type, rel.Identifier)
+	return nil
+}
+
+func runDatasetUnrelate(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset unrelate")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError("dataset unrelate <dataset> <identifier>")
+	}
+	// Any relation type will do to normalize the identifier.
+	rel, err := dataset.NewRelatedIdentifier("References", pos[1])
+	if err != nil {
+		return err
+	}
+	match := func(r dataset.RelatedIdentifier) bool { return r.Key() == rel.Key() }
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(d.Staged().Related, match) {
+		return fmt.Errorf("%s is not related to %s", d.ID, pos[1])
+	}
+	if _, err := updateDataset(ctx, a, "dataset.unrelate", d.ID, func(d *dataset.Dataset) map[string]string {
+		d.Related = slices.DeleteFunc(d.Related, match)
+		return map[string]string{"identifier": rel.Identifier}
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Removed %s from %s\n", rel.Identifier, d.ID)
+	return nil
+}
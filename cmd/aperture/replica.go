@@ -0,0 +1,250 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/replica"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("replica", &command{
+		summary: "Keep encrypted preservation copies with an independent provider",
+		subcommands: map[string]*command{
+			"run": {
+				usage:      "[--dataset REF] [--limit N] [--max-bytes N] [--max-lag DURATION] [--json]",
+				summary:    "Copy the published versions not yet copied",
+				run:        runReplicaRun,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+				schedule:   24 * time.Hour,
+			},
+			"status": {
+				usage:   "[--max-lag DURATION] [--json]",
+				summary: "Report the datasets waiting for a complete copy",
+				run:     runReplicaStatus,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"objects": {
+				usage:   "[--json]",
+				summary: "List the copies of stored objects and the keys encrypting them",
+				run:     runReplicaObjects,
+				scope:   token.ScopeDatasetsRead,
+			},
+		},
+	})
+}
+
+// replicator returns the replicator of the configured replica bucket.
+// Copies are made with the replica's own credentials if configured,
+// and in Glacier Deep Archive on AWS unless another storage class is
+// configured.
+func (a *app) replicator(maxLag time.Duration) (*replica.Replicator, error) {
+	if a.cfg.ReplicaBucket == "" {
+		return nil, fmt.Errorf("preservation copies are not configured; set APERTURE_REPLICA_BUCKET")
+	}
+	var key []byte
+	if a.cfg.ReplicaKey != "" {
+		var err error
+		if key, err = base64.StdEncoding.DecodeString(a.cfg.ReplicaKey); err != nil || len(key) != replica.KeySize {
+			return nil, fmt.Errorf("APERTURE_REPLICA_KEY must be a base64 %d-byte key", replica.KeySize)
+		}
+	}
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	source, err := a.objectStore()
+	if err != nil {
+		return nil, err
+	}
+	target, err := a.replicaClient()
+	if err != nil {
+		return nil, err
+	}
+	events, err := a.premisEvents(s)
+	if err != nil {
+		return nil, err
+	}
+	class := a.cfg.ReplicaStorageClass
+	if class == "" && a.cfg.ReplicaEndpoint == "" {
+		class = "DEEP_ARCHIVE"
+	}
+	return &replica.Replicator{
+		State: s, Datasets: datasets, Source: source, Target: target,
+		Bucket: a.cfg.ReplicaBucket, StorageClass: class, Key: key, MaxLag: maxLag,
+		Events: events, Metrics: a.recorder(),
+	}, nil
+}
+
+// replicaClient returns a client of the replica bucket's provider,
+// with the replica's own credentials, or those of its credentials file,
+// if configured.
+func (a *app) replicaClient() (*s3.Client, error) {
+	creds, err := storageCredentials(a.cfg.ReplicaAccessKeyID, a.cfg.ReplicaSecretAccessKey, a.cfg.ReplicaCredentialsFile, a.cfg.ReplicaProfile)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewClient(s3.Options{
+		Region:      cmp.Or(a.cfg.ReplicaRegion, a.cfg.AWSRegion),
+		Endpoint:    a.cfg.ReplicaEndpoint,
+		PathStyle:   a.cfg.ReplicaPathStyle,
+		Credentials: creds,
+		Metrics:     a.recorder(),
+	})
+}
+
+func runReplicaRun(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("replica run")
+	ref := fs.String("dataset", "", "copy one dataset only")
+	limit := fs.Int("limit", 0, "copy at most `N` objects (0 for no limit)")
+	maxBytes := fs.Int64("max-bytes", 0, "copy at most `N` bytes (0 for no limit)")
+	maxLag := durationFlag(fs, "max-lag", replica.DefaultMaxLag, "how long a published version may wait for a copy")
+	asJSON := fs.Bool("json", false, "print the run as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("replica run [--dataset REF] [--limit N] [--max-bytes N] [--max-lag DURATION] [--json]")
+	}
+	r, err := a.replicator(*maxLag)
+	if err != nil {
+		return err
+	}
+	if len(r.Key) == 0 {
+		return fmt.Errorf("no replica key; set APERTURE_REPLICA_KEY")
+	}
+	run, err := r.Run(ctx, replica.Options{Dataset: *ref, Limit: *limit, MaxBytes: *maxBytes})
+	if err != nil {
+		return err
+	}
+
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	for _, f := range run.Failures {
+		if err := audit.Record(ctx, log, "replica.failed", f.Dataset, map[string]string{"error": f.Error}); err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		if err := a.printJSON(run); err != nil {
+			return err
+		}
+	} else {
+		for _, f := range run.Failures {
+			fmt.Fprintf(a.out, "FAILED %s: %s\n", f.Dataset, f.Error)
+		}
+		fmt.Fprintf(a.out, "Copied %d objects (%d bytes), completing %d versions; %d failed, %d datasets waiting\n",
+			run.Objects, run.Bytes, run.Versions, run.Failed, run.Lagging)
+	}
+	if run.Failed > 0 {
+		return fmt.Errorf("%d datasets failed to copy", run.Failed)
+	}
+	return nil
+}
+
+func runReplicaStatus(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("replica status")
+	maxLag := durationFlag(fs, "max-lag", replica.DefaultMaxLag, "how long a published version may wait for a copy")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("replica status [--max-lag DURATION] [--json]")
+	}
+	r, err := a.replicator(*maxLag)
+	if err != nil {
+		return err
+	}
+	rep, err := r.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(rep)
+	}
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Replica:\t%s\n", r.URI(""))
+	fmt.Fprintf(tw, "Datasets:\t%d published, %d copied\n", rep.Datasets, rep.Copied)
+	fmt.Fprintf(tw, "Objects:\t%d (%d bytes)\n", rep.Objects, rep.Bytes)
+	fmt.Fprintf(tw, "Longest lag:\t%s\n", rep.MaxLag.Round(time.Minute))
+	fmt.Fprintf(tw, "Overdue:\t%d (waiting longer than %s)\n", rep.Overdue, fs.Lookup("max-lag").Value)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(rep.Pending) == 0 {
+		return nil
+	}
+	fmt.Fprintln(a.out)
+	tw = tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATASET\tVERSION\tCOPIED\tWAITING SINCE\tLAG\tERROR")
+	for _, l := range rep.Pending {
+		lag := l.Lag.Round(time.Minute).String()
+		if l.Overdue {
+			lag += " (overdue)"
+		}
+		fmt.Fprintf(tw, "%s\tv%d\tv%d\t%s\t%s\t%s\n", l.Dataset, l.Version, l.Copied, l.Since.Format(time.DateOnly), lag, l.Error)
+	}
+	return tw.Flush()
+}
+
+func runReplicaObjects(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("replica objects")
+	asJSON := fs.Bool("json", false, "print the copies as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("replica objects [--json]")
+	}
+	r, err := a.replicator(0)
+	if err != nil {
+		return err
+	}
+	objects, err := r.Objects(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(objects)
+	}
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OBJECT\tCOPY\tKEY\tCOPIED")
+	for _, o := range objects {
+		fmt.Fprintf(tw, "s3://%s/%s\t%s\t%s\t%s\n", o.Bucket, o.Key, r.URI(o.Copy), o.KeyID, o.CopiedAt.Format(time.DateOnly))
+	}
+	return tw.Flush()
+}
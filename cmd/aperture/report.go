@@ -0,0 +1,199 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/report"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("report", &command{
+		summary: "Compile repository reports",
+		subcommands: map[string]*command{
+			"institutional": {
+				usage:   "[--year YYYY] [--format text|csv|html|json]",
+				summary: "Report a year's deposits, storage, downloads, and citations by department, collection, and funder",
+				run:     runReportInstitutional,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"funders": {
+				usage:   "[--quarter YYYYQn | --from DATE --to DATE] [--funder ROR|NAME]... [--format text|csv|json|nih|nsf]",
+				summary: "Report deposits, storage, and downloads of funded datasets by funder and award (default last quarter)",
+				run:     runReportFunders,
+				scope:   token.ScopeDatasetsRead,
+			},
+		},
+	})
+}
+
+// reportBuilder returns a builder reading the catalog, the download
+// log, and tracked citations.
+func (a *app) reportBuilder() (*report.Builder, error) {
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	collections, err := a.collectionRegistry()
+	if err != nil {
+		return nil, err
+	}
+	awards, err := a.awardRegistry()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.downloadLog()
+	if err != nil {
+		return nil, err
+	}
+	citations, err := a.citationTracker()
+	if err != nil {
+		return nil, err
+	}
+	return &report.Builder{Datasets: datasets, Collections: collections, Awards: awards, Usage: log, Citations: citations, Repository: a.cfg.Publisher}, nil
+}
+
+func runReportInstitutional(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("report institutional")
+	year := fs.Int("year", time.Now().Year()-1, "report calendar year `YYYY`")
+	format := fs.String("format", "text", "output format: text, csv, html (print to PDF from a browser), or json")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("report institutional [--year YYYY] [--format text|csv|html|json]")
+	}
+	switch *format {
+	case "text", "csv", "html", "json":
+	default:
+		return fmt.Errorf("unknown format %q (want text, csv, html, or json)", *format)
+	}
+	b, err := a.reportBuilder()
+	if err != nil {
+		return err
+	}
+	rep, err := b.Build(ctx, *year)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "csv":
+		return report.WriteCSV(a.out, rep)
+	case "html":
+		return report.WriteHTML(a.out, rep)
+	case "json":
+		return a.printJSON(rep)
+	}
+	fmt.Fprintf(a.out, "Institutional report %d\n", rep.Year)
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	for _, sec := range []struct {
+		title string
+		rows  []report.Row
+	}{
+		{"TOTAL", []report.Row{rep.Total}},
+		{"DEPARTMENT", rep.Departments},
+		{"COLLECTION", rep.Collections},
+		{"FUNDER", rep.Funders},
+	} {
+		fmt.Fprintln(tw)
+		fmt.Fprintf(tw, "%s\tDEPOSITED\tHELD\tBYTES\tDOWNLOADS\tCITATIONS\n", sec.title)
+		for _, r := range sec.rows {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\n", clip(r.Name, 40), r.Deposits, r.Datasets, r.Bytes, r.Downloads, r.Citations)
+		}
+	}
+	return tw.Flush()
+}
+
+const reportFundersUsage = "report funders [--quarter YYYYQn | --from DATE --to DATE] [--funder ROR|NAME]... [--format text|csv|json|nih|nsf]"
+
+func runReportFunders(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("report funders")
+	quarter := fs.String("quarter", "", "report calendar quarter `YYYYQn` (default the last full quarter)")
+	fromFlag := fs.String("from", "", "report from `DATE` (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "report through `DATE` (YYYY-MM-DD), inclusive")
+	var funders stringsFlag
+	fs.Var(&funders, "funder", "report only the funder with this ROR ID or whose name contains `TEXT` (repeatable)")
+	format := fs.String("format", "text", "output format: text, csv, json, or the product listing of an NIH (nih) or NSF (nsf) progress report")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 || (*quarter != "" && (*fromFlag != "" || *toFlag != "")) || ((*fromFlag == "") != (*toFlag == "")) {
+		return usageError(reportFundersUsage)
+	}
+	switch *format {
+	case "text", "csv", "json", "nih", "nsf":
+	default:
+		return fmt.Errorf("unknown format %q (want text, csv, json, nih, or nsf)", *format)
+	}
+
+	var from, to time.Time
+	if *fromFlag != "" {
+		if from, err = time.Parse(time.DateOnly, *fromFlag); err != nil {
+			return fmt.Errorf("invalid --from date: %w", err)
+		}
+		if to, err = time.Parse(time.DateOnly, *toFlag); err != nil {
+			return fmt.Errorf("invalid --to date: %w", err)
+		}
+		to = to.AddDate(0, 0, 1)
+	} else {
+		if *quarter == "" {
+			*quarter = report.LastQuarter(time.Now())
+		}
+		if from, to, err = report.Quarter(*quarter); err != nil {
+			return err
+		}
+	}
+
+	b, err := a.reportBuilder()
+	if err != nil {
+		return err
+	}
+	rep, err := b.BuildFunders(ctx, from, to, funders...)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "csv":
+		return report.WriteFunderCSV(a.out, rep)
+	case "nih", "nsf":
+		return report.WriteProducts(a.out, rep, *format)
+	case "json":
+		return a.printJSON(rep)
+	}
+	fmt.Fprintf(a.out, "Funder report %s to %s\n", rep.From.Format(time.DateOnly), rep.To.AddDate(0, 0, -1).Format(time.DateOnly))
+	if len(rep.Funders) == 0 {
+		fmt.Fprintln(a.out, "\nNo funded datasets")
+		return nil
+	}
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw)
+	fmt.Fprintf(tw, "FUNDER / AWARD\tDEPOSITED\tHELD\tBYTES\tDOWNLOADS\tCITATIONS\n")
+	for _, f := range rep.Funders {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\n", clip(f.Name, 40), f.Deposits, f.Datasets, f.Bytes, f.Downloads, f.Citations)
+		for _, aw := range f.Awards {
+			fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\t%d\t%d\n", clip(aw.Number, 38), aw.Deposits, aw.Datasets, aw.Bytes, aw.Downloads, aw.Citations)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,91 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/resourcesync"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("resourcesync", &command{
+		summary: "Publish ResourceSync documents for mirrors and indexers",
+		subcommands: map[string]*command{
+			"publish": {
+				usage:      "[--window 90d] [--dry-run] [--json]",
+				summary:    "Record dataset changes since the last run and publish the capability, resource, and change lists",
+				run:        runResourceSyncPublish,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermPublish,
+			},
+		},
+	})
+}
+
+func runResourceSyncPublish(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("resourcesync publish")
+	window := durationFlag(fs, "window", resourcesync.DefaultWindow, "how long changes stay in the change list")
+	dryRun := fs.Bool("dry-run", false, "report changes without recording or publishing them")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("resourcesync publish [--window 90d] [--dry-run] [--json]")
+	}
+
+	b, err := a.landingBuilder()
+	if err != nil {
+		return err
+	}
+	p := &resourcesync.Publisher{
+		Datasets: b.Datasets,
+		State:    b.State,
+		Objects:  b.Publisher,
+		Bucket:   b.Bucket,
+		SiteURL:  a.cfg.SiteURL,
+		Window:   *window,
+	}
+	res, err := p.Sync(ctx, *dryRun)
+	if err != nil {
+		return err
+	}
+	if len(res.Paths) > 0 && b.Invalidator != nil {
+		if _, err := b.Invalidator.Invalidate(ctx, res.Paths); err != nil {
+			return fmt.Errorf("failed to invalidate ResourceSync documents: %w", err)
+		}
+	}
+
+	if *asJSON {
+		return a.printJSON(res)
+	}
+	for _, c := range res.Changes {
+		fmt.Fprintf(a.out, "%-8s %s\n", c.Change, c.DatasetID)
+	}
+	if *dryRun {
+		fmt.Fprintf(a.out, "%d changes (dry run)\n", len(res.Changes))
+		return nil
+	}
+	fmt.Fprintf(a.out, "Published %d resources and %d new changes\n", res.Resources, len(res.Changes))
+	for _, p := range res.Paths {
+		fmt.Fprintf(a.out, "  %s%s\n", a.cfg.SiteURL, p)
+	}
+	return nil
+}
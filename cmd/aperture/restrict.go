@@ -0,0 +1,217 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+)
+
+func init() {
+	register("restrict", &command{
+		summary: "Limit the networks and countries datasets may be downloaded from",
+		subcommands: map[string]*command{
+			"set": {
+				usage:      "<dataset> [--cidr NET]... [--country CC]...",
+				summary:    "Allow downloads only from the given networks and countries",
+				run:        runRestrictSet,
+				permission: authz.PermCurate,
+			},
+			"clear": {
+				usage:      "<dataset>",
+				summary:    "Remove a dataset's network restriction",
+				run:        runRestrictClear,
+				permission: authz.PermCurate,
+			},
+			"show": {
+				usage:   "<dataset>",
+				summary: "Show a dataset's network restriction",
+				run:     runRestrictShow,
+			},
+			"policy": {
+				usage:   "s3 [--bucket B] | cloudfront",
+				summary: "Generate the bucket policy or CloudFront function enforcing all restrictions",
+				run:     runRestrictPolicy,
+			},
+		},
+	})
+}
+
+func runRestrictSet(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("restrict set")
+	var cidrs, countries stringsFlag
+	fs.Var(&cidrs, "cidr", "allowed client network, e.g. 192.0.2.0/24 (repeatable)")
+	fs.Var(&countries, "country", "allowed ISO 3166-1 country code, e.g. US (repeatable)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || len(cidrs)+len(countries) == 0 {
+		return usageError("restrict set <dataset> [--cidr NET]... [--country CC]...")
+	}
+	r, err := authz.Restriction{CIDRs: cidrs, Countries: countries}.Normalize()
+	if err != nil {
+		return err
+	}
+	return updateRestriction(ctx, a, "restrict.set", pos[0], &r)
+}
+
+func runRestrictClear(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("restrict clear")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("restrict clear <dataset>")
+	}
+	return updateRestriction(ctx, a, "restrict.clear", pos[0], nil)
+}
+
+// updateRestriction replaces a dataset's restriction after checking that
+// the caller may manage it.
+func updateRestriction(ctx context.Context, a *app, action, ref string, r *authz.Restriction) error {
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+		return err
+	}
+	d.Restriction = r
+	if err := datasets.Put(ctx, d); err != nil {
+		return err
+	}
+
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	details := map[string]string{}
+	if r != nil {
+		details["cidrs"] = strings.Join(r.CIDRs, " ")
+		details["countries"] = strings.Join(r.Countries, " ")
+	}
+	if err := audit.Record(ctx, log, action, d.ID, details); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Updated %s; run \"aperture restrict policy\" to regenerate the bucket policy and CloudFront function\n", d.ID)
+	return nil
+}
+
+func runRestrictShow(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("restrict show")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("restrict show <dataset>")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if d.Restriction.IsZero() {
+		fmt.Fprintf(a.out, "%s: unrestricted\n", d.ID)
+		return nil
+	}
+	fmt.Fprintf(a.out, "networks:  %s\n", anyList(d.Restriction.CIDRs))
+	fmt.Fprintf(a.out, "countries: %s\n", anyList(d.Restriction.Countries))
+	return nil
+}
+
+// anyList formats a restriction list, which allows anything if empty.
+func anyList(values []string) string {
+	if len(values) == 0 {
+		return "(any)"
+	}
+	return strings.Join(values, ", ")
+}
+
+func runRestrictPolicy(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("restrict policy")
+	bucket := fs.String("bucket", "", "bucket to print the policy for (required if several are restricted)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || (pos[0] != "s3" && pos[0] != "cloudfront") {
+		return usageError("restrict policy s3 [--bucket B] | cloudfront")
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	all, err := datasets.List(ctx)
+	if err != nil {
+		return err
+	}
+	var fences []authz.Fence
+	for _, d := range all {
+		fences = append(fences, d.Fences()...)
+	}
+
+	if pos[0] == "cloudfront" {
+		src, err := authz.CloudFrontFunction(fences)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(a.out, src)
+		return nil
+	}
+
+	policies, err := authz.BucketPolicies(fences)
+	if err != nil {
+		return err
+	}
+	if *bucket == "" {
+		if len(policies) == 0 {
+			return fmt.Errorf("no datasets are restricted to networks")
+		}
+		if len(policies) > 1 {
+			buckets := make([]string, 0, len(policies))
+			for b := range policies {
+				buckets = append(buckets, b)
+			}
+			sort.Strings(buckets)
+			return fmt.Errorf("restrictions span several buckets; choose one with --bucket (%s)", strings.Join(buckets, ", "))
+		}
+		for b := range policies {
+			*bucket = b
+		}
+	}
+	doc, ok := policies[*bucket]
+	if !ok {
+		return fmt.Errorf("no restricted datasets are stored in %s", *bucket)
+	}
+	fmt.Fprintln(a.out, string(doc))
+	return nil
+}
@@ -0,0 +1,341 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/counter"
+	"github.com/scttfrdmn/aperture/internal/retention"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("retention", &command{
+		summary: "Apply retention policies and de-accession expired datasets",
+		subcommands: map[string]*command{
+			"policy": {
+				usage:      "<name> --years N [--basis published|last-access] [--description TEXT]",
+				summary:    "Create or replace a retention policy",
+				run:        runRetentionPolicy,
+				permission: authz.PermRetention,
+			},
+			"policies": {
+				summary: "List retention policies",
+				run:     runRetentionPolicies,
+			},
+			"assign": {
+				usage:      "<dataset> <policy>",
+				summary:    "Apply a retention policy to a dataset",
+				run:        runRetentionAssign,
+				permission: authz.PermRetention,
+			},
+			"unassign": {
+				usage:      "<dataset>",
+				summary:    "Remove a dataset's retention policy",
+				run:        runRetentionUnassign,
+				permission: authz.PermRetention,
+			},
+			"evaluate": {
+				summary:    "Flag datasets whose retention period has ended and notify their stewards",
+				run:        runRetentionEvaluate,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermRetention,
+				schedule:   7 * 24 * time.Hour,
+			},
+			"reviews": {
+				usage:   "[--status pending|retained|cleared|tombstoned] [--json]",
+				summary: "List retention reviews",
+				run:     runRetentionReviews,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"confirm": {
+				usage:      "<dataset> --reason TEXT",
+				summary:    "Confirm a pending review and tombstone the dataset",
+				run:        runRetentionConfirm,
+				permission: authz.PermRetention,
+				privileged: true,
+			},
+			"retain": {
+				usage:      "<dataset> --until DATE --reason TEXT",
+				summary:    "Keep a dataset with a pending review until DATE",
+				run:        runRetentionRetain,
+				permission: authz.PermRetention,
+			},
+		},
+	})
+}
+
+// retentionManager returns a manager that counts last access from the
+// audit log and the download log and notifies stewards, falling back
+// to the configured administrators.
+func (a *app) retentionManager() (*retention.Manager, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	downloads, err := a.downloadLog()
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := a.notifier()
+	if err != nil {
+		return nil, err
+	}
+	return &retention.Manager{
+		State:    s,
+		Datasets: datasets,
+		Accesses: &accessTimes{audit: log, downloads: downloads},
+		Notifier: notifier,
+		Stewards: a.cfg.Admins,
+		Log:      log,
+	}, nil
+}
+
+// accessTimes finds the last download of each dataset from issued
+// URLs and share links in the audit log and from counted downloads.
+type accessTimes struct {
+	audit     audit.Log
+	downloads counter.Log
+
+	last map[string]time.Time
+}
+
+// LastAccess implements retention.AccessLog. The logs are read once.
+func (t *accessTimes) LastAccess(ctx context.Context, datasetID string) (time.Time, error) {
+	if t.last == nil {
+		last := make(map[string]time.Time)
+		see := func(id string, at time.Time) {
+			if at.After(last[id]) {
+				last[id] = at
+			}
+		}
+		entries, err := t.audit.Entries(ctx)
+		if err != nil {
+			return time.Time{}, err
+		}
+		for _, e := range entries {
+			if e.Action == "access.grant" || e.Action == "share.resolve" {
+				see(e.Target, e.Time)
+			}
+		}
+		events, err := t.downloads.Events(ctx)
+		if err != nil {
+			return time.Time{}, err
+		}
+		for _, e := range events {
+			if !e.Robot {
+				see(e.DatasetID, e.Time)
+			}
+		}
+		t.last = last
+	}
+	return t.last[datasetID], nil
+}
+
+func runRetentionPolicy(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("retention policy")
+	years := fs.Int("years", 0, "retention period in years")
+	basis := fs.String("basis", string(retention.BasisPublished), "date the period counts from (published, last-access)")
+	description := fs.String("description", "", "where the rule comes from, e.g. a funder requirement")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || *years == 0 {
+		return usageError("retention policy <name> --years N [--basis published|last-access] [--description TEXT]")
+	}
+
+	m, err := a.retentionManager()
+	if err != nil {
+		return err
+	}
+	p := retention.Policy{Name: pos[0], Description: *description, Years: *years, Basis: retention.Basis(*basis)}
+	if err := m.PutPolicy(ctx, p); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Saved retention policy %s: %d years after %s\n", p.Name, p.Years, p.Basis)
+	return nil
+}
+
+func runRetentionPolicies(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("retention policies")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	m, err := a.retentionManager()
+	if err != nil {
+		return err
+	}
+	policies, err := m.Policies(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range policies {
+		fmt.Fprintf(a.out, "%-20s %2d years after %-12s %s\n", p.Name, p.Years, p.Basis, p.Description)
+	}
+	return nil
+}
+
+func runRetentionAssign(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("retention assign")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError("retention assign <dataset> <policy>")
+	}
+	m, err := a.retentionManager()
+	if err != nil {
+		return err
+	}
+	d, err := m.Assign(ctx, pos[0], pos[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Applied retention policy %s to %s\n", d.Retention, d.ID)
+	return nil
+}
+
+func runRetentionUnassign(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("retention unassign")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("retention unassign <dataset>")
+	}
+	m, err := a.retentionManager()
+	if err != nil {
+		return err
+	}
+	d, err := m.Assign(ctx, pos[0], "")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Removed the retention policy of %s\n", d.ID)
+	return nil
+}
+
+func runRetentionEvaluate(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("retention evaluate")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	m, err := a.retentionManager()
+	if err != nil {
+		return err
+	}
+	flagged, err := m.Evaluate(ctx)
+	for _, rv := range flagged {
+		fmt.Fprintf(a.out, "%-20s due %s under %s\n", rv.DatasetID, rv.DueAt.Format(time.DateOnly), rv.Policy)
+	}
+	fmt.Fprintf(a.out, "Flagged %d datasets for review\n", len(flagged))
+	return err
+}
+
+func runRetentionReviews(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("retention reviews")
+	status := fs.String("status", "", "only reviews with this status")
+	asJSON := fs.Bool("json", false, "print reviews as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	m, err := a.retentionManager()
+	if err != nil {
+		return err
+	}
+	reviews, err := m.Reviews(ctx, retention.Status(*status))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(reviews)
+	}
+	for _, rv := range reviews {
+		line := fmt.Sprintf("%-20s %-10s due %s  %-12s", rv.DatasetID, rv.Status, rv.DueAt.Format(time.DateOnly), rv.Policy)
+		if rv.RetainUntil != nil {
+			line += " until " + rv.RetainUntil.Format(time.DateOnly)
+		}
+		if rv.DecidedBy != "" {
+			line += fmt.Sprintf(" by %s: %s", rv.DecidedBy, rv.Reason)
+		}
+		fmt.Fprintln(a.out, strings.TrimRight(line, " "))
+	}
+	return nil
+}
+
+func runRetentionConfirm(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("retention confirm")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("retention confirm <dataset> --reason TEXT")
+	}
+	m, err := a.retentionManager()
+	if err != nil {
+		return err
+	}
+	d, err := m.Confirm(ctx, pos[0], audit.Justification(ctx))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Tombstoned %s\n", d.ID)
+	return nil
+}
+
+func runRetentionRetain(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("retention retain")
+	until := fs.String("until", "", "date to keep the dataset until (YYYY-MM-DD or RFC 3339)")
+	reason := fs.String("reason", "", "why the dataset is kept (required)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || *until == "" || *reason == "" {
+		return usageError("retention retain <dataset> --until DATE --reason TEXT")
+	}
+	t, err := parseTime(*until)
+	if err != nil {
+		return err
+	}
+	m, err := a.retentionManager()
+	if err != nil {
+		return err
+	}
+	rv, err := m.Retain(ctx, pos[0], t, *reason)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Retaining %s until %s\n", rv.DatasetID, rv.RetainUntil.Format(time.DateOnly))
+	return nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/counter"
+)
+
+const robotsSetUsage = "downloads robots set [--list FILE|--no-list] [--deny PATTERN]... [--allow PATTERN]... [--remove PATTERN]... [--max-per-minute N] [--max-per-session N]"
+
+func runDownloadsRobotsShow(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("downloads robots show")
+	asJSON := fs.Bool("json", false, "print the rules as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("downloads robots show [--json]")
+	}
+	s, err := a.store()
+	if err != nil {
+		return err
+	}
+	rules, err := counter.LoadRules(ctx, s)
+	if err != nil {
+		return err
+	}
+	classified, err := counter.Classified(ctx, s)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(struct {
+			Rules      *counter.Rules          `json:"rules"`
+			Hash       string                  `json:"hash"`
+			Classified *counter.Classification `json:"classified,omitempty"`
+		}{rules, rules.Hash(), classified})
+	}
+
+	fmt.Fprintf(a.out, "Robot list:        %d patterns\n", len(rules.List))
+	fmt.Fprintf(a.out, "Denied agents:     %s\n", orNone(rules.Deny))
+	fmt.Fprintf(a.out, "Allowed agents:    %s\n", orNone(rules.Allow))
+	fmt.Fprintf(a.out, "Max per minute:    %s\n", limitString(int64(rules.MaxPerMinute)))
+	fmt.Fprintf(a.out, "Max per session:   %s\n", limitString(int64(rules.MaxPerSession)))
+	if rules.UpdatedAt != nil {
+		fmt.Fprintf(a.out, "Updated:           %s\n", rules.UpdatedAt.Format(time.RFC3339))
+	}
+	switch {
+	case classified == nil:
+		fmt.Fprintln(a.out, "Event log:         never reclassified; run 'aperture downloads reclassify' to apply these rules to logged events")
+	case classified.Rules != rules.Hash():
+		fmt.Fprintf(a.out, "Event log:         classified under earlier rules on %s; run 'aperture downloads reclassify'\n", classified.Time.Format(time.DateOnly))
+	default:
+		fmt.Fprintf(a.out, "Event log:         classified under these rules on %s: %d of %d events from robots\n",
+			classified.Time.Format(time.DateOnly), classified.Robots, classified.Events)
+	}
+	return nil
+}
+
+func runDownloadsRobotsSet(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("downloads robots set")
+	list := fs.String("list", "", "replace the robot list with the COUNTER-Robots JSON or pattern-per-line list in `FILE`")
+	noList := fs.Bool("no-list", false, "drop the robot list, keeping the built-in patterns")
+	var deny, allow, remove stringsFlag
+	fs.Var(&deny, "deny", "user agent `PATTERN` of a robot (repeatable)")
+	fs.Var(&allow, "allow", "user agent `PATTERN` never treated as a robot (repeatable)")
+	fs.Var(&remove, "remove", "denied or allowed `PATTERN` to remove (repeatable)")
+	perMinute := fs.Int("max-per-minute", 0, "treat sessions making more than `N` requests in a minute as robots; 0 for no limit")
+	perSession := fs.Int("max-per-session", 0, "treat sessions making more than `N` requests in an hour as robots; 0 for no limit")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 || (*list != "" && *noList) {
+		return usageError(robotsSetUsage)
+	}
+	s, err := a.store()
+	if err != nil {
+		return err
+	}
+	rules, err := counter.LoadRules(ctx, s)
+	if err != nil {
+		return err
+	}
+	before := rules.Hash()
+
+	switch {
+	case *list != "":
+		if rules.List, err = counter.ReadRobotsFile(*list); err != nil {
+			return err
+		}
+	case *noList:
+		rules.List = nil
+	}
+	for _, p := range remove {
+		rules.Deny = slices.DeleteFunc(rules.Deny, func(d string) bool { return d == p })
+		rules.Allow = slices.DeleteFunc(rules.Allow, func(d string) bool { return d == p })
+	}
+	for _, p := range deny {
+		if !slices.Contains(rules.Deny, p) {
+			rules.Deny = append(rules.Deny, p)
+		}
+	}
+	for _, p := range allow {
+		if !slices.Contains(rules.Allow, p) {
+			rules.Allow = append(rules.Allow, p)
+		}
+	}
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "max-per-minute":
+			rules.MaxPerMinute = *perMinute
+		case "max-per-session":
+			rules.MaxPerSession = *perSession
+		}
+	})
+	if rules.Hash() == before {
+		fmt.Fprintln(a.out, "Robot rules unchanged")
+		return nil
+	}
+	now := time.Now().UTC()
+	rules.UpdatedAt = &now
+	if err := counter.SaveRules(ctx, s, rules); err != nil {
+		return err
+	}
+
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, log, "usage.robots", "rules", map[string]string{
+		"list":          strconv.Itoa(len(rules.List)),
+		"deny":          strings.Join(rules.Deny, "; "),
+		"allow":         strings.Join(rules.Allow, "; "),
+		"maxPerMinute":  strconv.Itoa(rules.MaxPerMinute),
+		"maxPerSession": strconv.Itoa(rules.MaxPerSession),
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintln(a.out, "Updated robot rules; run 'aperture downloads reclassify' to apply them to logged events")
+	return nil
+}
+
+func runDownloadsReclassify(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("downloads reclassify")
+	dryRun := fs.Bool("dry-run", false, "report the changes without rewriting the event log")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("downloads reclassify [--dry-run]")
+	}
+	f, err := a.robotFilter(ctx, "")
+	if err != nil {
+		return err
+	}
+	s, err := a.store()
+	if err != nil {
+		return err
+	}
+	log, err := a.downloadLog()
+	if err != nil {
+		return err
+	}
+	c, err := counter.Reclassify(ctx, s, log, f, time.Now(), *dryRun)
+	if err != nil {
+		return err
+	}
+	verb := "Reclassified"
+	if *dryRun {
+		verb = "Would reclassify"
+	}
+	fmt.Fprintf(a.out, "%s %d events: %d from robots, %d changed\n", verb, c.Events, c.Robots, c.Changed)
+	return nil
+}
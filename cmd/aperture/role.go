@@ -0,0 +1,217 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/role"
+)
+
+func init() {
+	register("role", &command{
+		summary: "Manage repository roles (depositor, curator, steward, admin)",
+		subcommands: map[string]*command{
+			"show": {
+				summary: "Show your roles and the permissions they give you",
+				run:     runRoleShow,
+			},
+			"matrix": {
+				summary: "Show the permissions of each role",
+				run:     runRoleMatrix,
+			},
+			"list": {
+				usage:      "[--json]",
+				summary:    "List role grants",
+				run:        runRoleList,
+				permission: authz.PermManageUsers,
+			},
+			"grant": {
+				usage:      "<user> <role>... --reason TEXT",
+				summary:    "Give a user or service account (svc:<name>) roles",
+				run:        runRoleGrant,
+				permission: authz.PermManageUsers,
+				privileged: true,
+			},
+			"revoke": {
+				usage:      "<user> <role>... --reason TEXT",
+				summary:    "Take roles away from a user or service account",
+				run:        runRoleRevoke,
+				permission: authz.PermManageUsers,
+				privileged: true,
+			},
+		},
+	})
+}
+
+// roleGrants returns the role grant registry.
+func (a *app) roleGrants() (*role.Registry, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	return role.NewRegistry(s, log), nil
+}
+
+// withRoles returns p with the groups of its granted roles.
+func (a *app) withRoles(ctx context.Context, p identity.Principal) (identity.Principal, error) {
+	if p.IsZero() {
+		return p, nil
+	}
+	roles, err := a.roleGrants()
+	if err != nil {
+		return p, err
+	}
+	return roles.Apply(ctx, p)
+}
+
+func runRoleShow(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("role show")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	p := identity.FromContext(ctx)
+	roles := authz.RolesOf(p)
+	if len(roles) == 0 {
+		fmt.Fprintf(a.out, "%s holds no roles\n", p)
+		return nil
+	}
+	var perms []string
+	for _, perm := range authz.PermissionsOf(p) {
+		perms = append(perms, string(perm))
+	}
+	fmt.Fprintf(a.out, "user:        %s\n", p)
+	fmt.Fprintf(a.out, "roles:       %s\n", joinRoles(roles))
+	fmt.Fprintf(a.out, "permissions: %s\n", strings.Join(perms, ", "))
+	return nil
+}
+
+func runRoleMatrix(_ context.Context, a *app, args []string) error {
+	fs := newFlagSet("role matrix")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "PERMISSION")
+	for _, r := range authz.Roles {
+		fmt.Fprintf(tw, "\t%s", strings.ToUpper(string(r)))
+	}
+	fmt.Fprintln(tw)
+	for _, perm := range authz.Permissions {
+		fmt.Fprint(tw, perm)
+		for _, r := range authz.Roles {
+			mark := "-"
+			if r.Has(perm) {
+				mark = "yes"
+			}
+			fmt.Fprintf(tw, "\t%s", mark)
+		}
+		fmt.Fprintln(tw)
+	}
+	fmt.Fprint(tw, "GROUP")
+	for _, r := range authz.Roles {
+		fmt.Fprintf(tw, "\t%s", r.Group())
+	}
+	fmt.Fprintln(tw)
+	return tw.Flush()
+}
+
+func runRoleList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("role list")
+	asJSON := fs.Bool("json", false, "print grants as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	roles, err := a.roleGrants()
+	if err != nil {
+		return err
+	}
+	grants, err := roles.List(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(grants)
+	}
+	for _, g := range grants {
+		fmt.Fprintf(a.out, "%-32s %-28s granted by %s %s\n", g.User, joinRoles(g.Roles), g.UpdatedBy, g.UpdatedAt.Format("2006-01-02"))
+	}
+	fmt.Fprintln(a.out, "Members of each role's group (see 'aperture role matrix') also hold the role.")
+	return nil
+}
+
+func runRoleGrant(ctx context.Context, a *app, args []string) error {
+	return roleAction(ctx, a, args, "grant", (*role.Registry).Grant, "Granted", "already holds")
+}
+
+func runRoleRevoke(ctx context.Context, a *app, args []string) error {
+	return roleAction(ctx, a, args, "revoke", (*role.Registry).Revoke, "Revoked", "does not hold")
+}
+
+// roleAction grants or revokes roles, reporting each change.
+func roleAction(ctx context.Context, a *app, args []string, name string,
+	fn func(*role.Registry, context.Context, string, authz.Role) (bool, error), done, unchanged string) error {
+	fs := newFlagSet("role " + name)
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) < 2 {
+		return usageError("role " + name + " <user> <role>... --reason TEXT")
+	}
+	var parsed []authz.Role
+	for _, s := range pos[1:] {
+		r, err := authz.ParseRole(s)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, r)
+	}
+	roles, err := a.roleGrants()
+	if err != nil {
+		return err
+	}
+	user := identity.Normalize(pos[0])
+	for _, r := range parsed {
+		changed, err := fn(roles, ctx, user, r)
+		if err != nil {
+			return err
+		}
+		if changed {
+			fmt.Fprintf(a.out, "%s %s role for %s\n", done, r, user)
+		} else {
+			fmt.Fprintf(a.out, "%s %s the %s role\n", user, unchanged, r)
+		}
+	}
+	return nil
+}
+
+// joinRoles formats roles as a comma-separated list.
+func joinRoles(roles []authz.Role) string {
+	names := make([]string, len(roles))
+	for i, r := range roles {
+		names[i] = string(r)
+	}
+	return strings.Join(names, ", ")
+}
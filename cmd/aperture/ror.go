@@ -0,0 +1,197 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/ror"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("ror", &command{
+		summary: "Resolve creator affiliations to ROR IDs",
+		subcommands: map[string]*command{
+			"search": {
+				usage:   "<affiliation>",
+				summary: "Show the organizations matching an affiliation",
+				run:     runRORSearch,
+				scope:   anyScope,
+			},
+			"resolve": {
+				usage:      "<dataset> [--no-prompt]",
+				summary:    "Resolve a dataset's creator affiliations, asking when a match is ambiguous",
+				run:        runRORResolve,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermDeposit,
+			},
+		},
+	})
+}
+
+func (a *app) rorClient() *ror.Client {
+	return ror.NewClient(ror.Options{BaseURL: a.cfg.RORURL, ClientID: a.cfg.RORClientID})
+}
+
+func runRORSearch(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("ror search")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) == 0 {
+		return usageError("ror search <affiliation>")
+	}
+	affiliation := strings.Join(pos, " ")
+	matches, err := a.rorClient().Match(ctx, affiliation)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		fmt.Fprintln(a.out, "No matching organizations")
+		return nil
+	}
+	best := ror.Best(affiliation, matches)
+	for _, m := range matches {
+		mark := " "
+		if best != nil && best.ID == m.Organization.ID {
+			mark = "*"
+		}
+		fmt.Fprintf(a.out, "%s %.2f  %-26s %s (%s)\n", mark, m.Score, m.Organization.ID, m.Organization.DisplayName(), m.Organization.Place())
+	}
+	return nil
+}
+
+func runRORResolve(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("ror resolve")
+	noPrompt := fs.Bool("no-prompt", false, "only accept unambiguous matches")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("ror resolve <dataset> [--no-prompt]")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+		return err
+	}
+
+	client := a.rorClient()
+	in := bufio.NewReader(a.in)
+	resolved := make(map[string]string)
+	var changed, skipped int
+	for i := range d.Creators {
+		c := &d.Creators[i]
+		if c.Affiliation == "" || c.AffiliationROR != "" {
+			continue
+		}
+		id, ok := resolved[c.Affiliation]
+		if !ok {
+			matches, err := client.Match(ctx, c.Affiliation)
+			if err != nil {
+				return err
+			}
+			if best := ror.Best(c.Affiliation, matches); best != nil {
+				id = best.ID
+			} else if !*noPrompt {
+				if id, err = a.chooseOrganization(ctx, in, client, c.Name, c.Affiliation, ror.Candidates(matches)); err != nil {
+					return err
+				}
+			}
+			resolved[c.Affiliation] = id
+		}
+		if id == "" {
+			fmt.Fprintf(a.out, "%s: %q left unresolved\n", c.Name, c.Affiliation)
+			skipped++
+			continue
+		}
+		c.AffiliationROR = id
+		fmt.Fprintf(a.out, "%s: %q is %s\n", c.Name, c.Affiliation, id)
+		changed++
+	}
+	if changed == 0 {
+		fmt.Fprintln(a.out, "No affiliations resolved")
+		return nil
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		return err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, log, "dataset.affiliations", d.ID, map[string]string{
+		"resolved":   strconv.Itoa(changed),
+		"unresolved": strconv.Itoa(skipped),
+	}); err != nil {
+		return err
+	}
+	if d.DOI != "" {
+		fmt.Fprintf(a.out, "Run 'aperture pid update %s' to register the affiliations with DataCite.\n", d.ID)
+	}
+	return nil
+}
+
+// chooseOrganization asks which candidate a creator's affiliation
+// refers to, returning the ROR ID chosen or "" to leave it unresolved.
+func (a *app) chooseOrganization(ctx context.Context, in *bufio.Reader, client *ror.Client, creator, affiliation string, candidates []ror.Match) (string, error) {
+	fmt.Fprintf(a.out, "\n%s: which organization is %q?\n", creator, affiliation)
+	for i, m := range candidates {
+		fmt.Fprintf(a.out, "  %d) %s (%s) %s\n", i+1, m.Organization.DisplayName(), m.Organization.Place(), m.Organization.ID)
+	}
+	for {
+		if len(candidates) > 0 {
+			fmt.Fprintf(a.out, "Enter 1-%d, a ROR ID, or nothing to skip: ", len(candidates))
+		} else {
+			fmt.Fprint(a.out, "No close matches. Enter a ROR ID, or nothing to skip: ")
+		}
+		line, err := in.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return "", nil
+		}
+		if n, convErr := strconv.Atoi(line); convErr == nil && n >= 1 && n <= len(candidates) {
+			return candidates[n-1].Organization.ID, nil
+		}
+		if ror.Valid(line) {
+			o, getErr := client.Get(ctx, line)
+			if getErr != nil {
+				return "", getErr
+			}
+			fmt.Fprintf(a.out, "  %s (%s)\n", o.DisplayName(), o.Place())
+			return o.ID, nil
+		}
+		if err != nil {
+			return "", nil
+		}
+		fmt.Fprintf(a.out, "%q is not a choice or a valid ROR ID.\n", line)
+	}
+}
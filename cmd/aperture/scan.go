@@ -0,0 +1,268 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/scan"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("scan", &command{
+		summary: "Scan uploaded files for malware before publication",
+		subcommands: map[string]*command{
+			"run": {
+				usage:      "[--dataset REF] [--limit N] [--json]",
+				summary:    "Scan the files not yet scanned, quarantining infected ones",
+				run:        runScanRun,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+				schedule:   15 * time.Minute,
+			},
+			"status": {
+				usage:   "[--all] [--json]",
+				summary: "List quarantined files and failed scans",
+				run:     runScanStatus,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"release": {
+				usage:      "<s3://bucket/key> --reason TEXT",
+				summary:    "Restore a quarantined file found to be a false positive",
+				run:        runScanRelease,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+			},
+		},
+	})
+}
+
+// scanner returns the malware scanner of the configured endpoint, or
+// nil if none is configured. Its object store and signer are set only
+// for scanning and releasing.
+func (a *app) scanner() (*scan.Scanner, error) {
+	if a.cfg.ScannerURL == "" {
+		return nil, nil
+	}
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := a.notifier()
+	if err != nil {
+		return nil, err
+	}
+	events, err := a.premisEvents(s)
+	if err != nil {
+		return nil, err
+	}
+	return &scan.Scanner{
+		State:      s,
+		Datasets:   datasets,
+		Endpoint:   a.cfg.ScannerURL,
+		Quarantine: a.cfg.QuarantineBucket(),
+		Events:     events,
+		Notifier:   notifier,
+		Stewards:   a.cfg.Admins,
+	}, nil
+}
+
+// activeScanner returns the scanner ready to scan or release objects.
+// Requests to Lambda function URLs are signed, for AWS_IAM
+// authentication.
+func (a *app) activeScanner() (*scan.Scanner, error) {
+	sc, err := a.scanner()
+	if err != nil {
+		return nil, err
+	}
+	if sc == nil {
+		return nil, fmt.Errorf("malware scanning is not configured; set APERTURE_SCANNER_URL")
+	}
+	if sc.Objects, err = a.objectStore(); err != nil {
+		return nil, err
+	}
+	if u, err := url.Parse(sc.Endpoint); err == nil && strings.HasSuffix(u.Hostname(), ".on.aws") {
+		creds, _ := aws.CredentialsFromEnv()
+		sc.Signer = &aws.Signer{Credentials: creds, Region: a.cfg.AWSRegion, Service: "lambda"}
+	}
+	return sc, nil
+}
+
+func runScanRun(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("scan run")
+	ref := fs.String("dataset", "", "scan the files of one dataset only")
+	limit := fs.Int("limit", 0, "scan at most `N` objects (0 for no limit)")
+	asJSON := fs.Bool("json", false, "print the run as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("scan run [--dataset REF] [--limit N] [--json]")
+	}
+	sc, err := a.activeScanner()
+	if err != nil {
+		return err
+	}
+	run, err := sc.Run(ctx, scan.Options{Dataset: *ref, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	for _, r := range run.Results {
+		if r.Status != scan.StatusInfected {
+			continue
+		}
+		if err := audit.Record(ctx, log, "scan.quarantine", r.URI(), map[string]string{
+			"dataset": r.Dataset, "path": r.Path, "signatures": strings.Join(r.Signatures, ","), "quarantine": r.Quarantine,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		if err := a.printJSON(run); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range run.Results {
+			if r.Status != scan.StatusClean {
+				printScanResult(a, r)
+			}
+		}
+		fmt.Fprintf(a.out, "Scanned %d objects: %d clean, %d infected, %d failed; %d remaining\n",
+			run.Scanned, run.Clean, run.Infected, run.Failed, run.Remaining)
+	}
+	if run.Infected > 0 {
+		return fmt.Errorf("%d infected objects quarantined", run.Infected)
+	}
+	return nil
+}
+
+func runScanStatus(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("scan status")
+	all := fs.Bool("all", false, "list every scanned object, not only problems")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("scan status [--all] [--json]")
+	}
+	sc, err := a.scanner()
+	if err != nil {
+		return err
+	}
+	if sc == nil {
+		return fmt.Errorf("malware scanning is not configured; set APERTURE_SCANNER_URL")
+	}
+	results, err := sc.Results(ctx)
+	if err != nil {
+		return err
+	}
+	counts := make(map[scan.Status]int)
+	shown := results[:0:0]
+	for _, r := range results {
+		counts[r.Status]++
+		if *all || !r.Status.Cleared() {
+			shown = append(shown, r)
+		}
+	}
+	if *asJSON {
+		return a.printJSON(shown)
+	}
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Scanned:\t%d\n", len(results))
+	for _, s := range []scan.Status{scan.StatusClean, scan.StatusInfected, scan.StatusError, scan.StatusReleased} {
+		fmt.Fprintf(tw, "  %s:\t%d\n", s, counts[s])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(shown) > 0 {
+		fmt.Fprintln(a.out)
+	}
+	for _, r := range shown {
+		printScanResult(a, r)
+	}
+	return nil
+}
+
+func runScanRelease(ctx context.Context, a *app, args []string) error {
+	const synopsis = "scan release <s3://bucket/key> --reason TEXT"
+	fs := newFlagSet("scan release")
+	reason := fs.String("reason", "", "why the file is safe to publish")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError(synopsis)
+	}
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(pos[0], "s3://"), "/")
+	if !ok || bucket == "" || key == "" {
+		return usageError(synopsis)
+	}
+	sc, err := a.activeScanner()
+	if err != nil {
+		return err
+	}
+	r, err := sc.Release(ctx, bucket, key, *reason)
+	if err != nil {
+		return err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, log, "scan.release", r.URI(), map[string]string{
+		"dataset": r.Dataset, "path": r.Path, "signatures": strings.Join(r.Signatures, ","), "reason": r.Reason,
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Released %s from quarantine\n", r.URI())
+	return nil
+}
+
+// printScanResult prints an object's verdict and the first dataset
+// file stored in it.
+func printScanResult(a *app, r scan.Result) {
+	detail := ""
+	switch {
+	case r.Error != "":
+		detail = ": " + r.Error
+	case len(r.Signatures) > 0:
+		detail = ": " + strings.Join(r.Signatures, ", ")
+	}
+	fmt.Fprintf(a.out, "%s %s (%s %s) %s%s\n", strings.ToUpper(string(r.Status)), r.URI(), r.Dataset, r.Path,
+		r.ScannedAt.Format(time.RFC3339), detail)
+}
@@ -0,0 +1,94 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/scim"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("scim", &command{
+		summary: "Provision accounts from the campus identity management system",
+		subcommands: map[string]*command{
+			"serve": {
+				usage:      "[--addr ADDR] [--group G]...",
+				summary:    "Serve the SCIM 2.0 API (" + scim.Prefix + ") over the user pool",
+				run:        runSCIMServe,
+				permission: authz.PermManageUsers,
+			},
+		},
+	})
+}
+
+func runSCIMServe(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("scim serve")
+	addr := fs.String("addr", "127.0.0.1:8084", "address to listen on")
+	var extra stringsFlag
+	fs.Var(&extra, "group", "group to provision besides the role groups (repeatable)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("scim serve [--addr ADDR] [--group G]...")
+	}
+
+	c, log, err := a.userCommand()
+	if err != nil {
+		return err
+	}
+	tokens, err := a.machineTokens()
+	if err != nil {
+		return err
+	}
+	var groups []string
+	for _, r := range authz.Roles {
+		groups = append(groups, r.Group())
+	}
+	for _, g := range extra {
+		if !slices.Contains(groups, g) {
+			groups = append(groups, g)
+		}
+	}
+	auth := func(ctx context.Context, secret string) (identity.Principal, error) {
+		t, err := tokens.Authenticate(ctx, secret)
+		if err != nil {
+			return identity.Principal{}, err
+		}
+		return t.Principal(), nil
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: a.instrument("scim", scim.NewHandler(c, groups, auth, log))}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(a.out, "Serving SCIM at http://%s%s for groups %s\n", *addr, scim.Prefix, strings.Join(groups, ", "))
+	fmt.Fprintf(a.out, "Authenticate the identity management system with: aperture token create <name> --scope %s\n", token.ScopeUsersWrite)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
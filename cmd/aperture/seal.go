@@ -0,0 +1,335 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/kms"
+	"github.com/scttfrdmn/aperture/internal/seal"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("seal", &command{
+		summary: "Sign the manifests of published versions and verify files against them",
+		subcommands: map[string]*command{
+			"sign": {
+				usage:      "<dataset> [--version N]",
+				summary:    "Seal published versions sealed neither at publication nor since",
+				run:        runSealSign,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+			},
+			"show": {
+				usage:   "<dataset> [--version N] [--json]",
+				summary: "Show the seal of a published version",
+				run:     runSealShow,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"verify": {
+				usage:   "<seal.json|URL> [--dir DIR] [--public-key FILE] [--fingerprint HEX]",
+				summary: "Check a seal's signature, and downloaded files against its manifest",
+				run:     runSealVerify,
+				scope:   anyScope,
+			},
+		},
+	})
+}
+
+// sealer returns the sealer of the configured KMS key, or nil if
+// sealing is not configured.
+func (a *app) sealer() (*seal.Sealer, error) {
+	if a.cfg.SealKeyID == "" {
+		return nil, nil
+	}
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	creds, err := aws.CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return &seal.Sealer{
+		State: s,
+		KMS:   kms.NewClient(kms.Options{Region: a.cfg.AWSRegion, Endpoint: a.cfg.AWSEndpoint, Credentials: creds}),
+		KeyID: a.cfg.SealKeyID,
+	}, nil
+}
+
+// sealedVersion returns the dataset ref and its version n, or its
+// latest published version if n is 0, with the configured sealer.
+func (a *app) sealedVersion(ctx context.Context, ref string, n int) (*seal.Sealer, *dataset.Dataset, *dataset.Version, error) {
+	sl, err := a.sealer()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if sl == nil {
+		return nil, nil, nil, fmt.Errorf("sealing is not configured; set APERTURE_SEAL_KEY_ID")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	d, err := datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var v *dataset.Version
+	if n != 0 {
+		v = d.Version(n)
+	} else {
+		for i := range d.Versions {
+			if d.Versions[i].PublishedAt != nil {
+				v = &d.Versions[i]
+			}
+		}
+	}
+	switch {
+	case n == 0 && v == nil:
+		return nil, nil, nil, fmt.Errorf("%w: %s has no published version", dataset.ErrNotFound, d.ID)
+	case v == nil || v.PublishedAt == nil:
+		return nil, nil, nil, fmt.Errorf("%w: %s has no published version %d", dataset.ErrNotFound, d.ID, n)
+	}
+	return sl, d, v, nil
+}
+
+func runSealSign(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("seal sign")
+	version := fs.Int("version", 0, "seal one version (default every unsealed published version)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("seal sign <dataset> [--version N]")
+	}
+	sl, d, v, err := a.sealedVersion(ctx, pos[0], *version)
+	if err != nil {
+		return err
+	}
+	versions := []*dataset.Version{v}
+	if *version == 0 {
+		versions = nil
+		for i := range d.Versions {
+			if d.Versions[i].PublishedAt != nil {
+				versions = append(versions, &d.Versions[i])
+			}
+		}
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	sealed := 0
+	for _, v := range versions {
+		// Resealing would sign whatever the records now say, so a
+		// version's seal is never replaced.
+		if _, err := sl.Get(ctx, d.ID, v.Number); err == nil {
+			if *version != 0 {
+				return fmt.Errorf("%s v%d is already sealed", d.ID, v.Number)
+			}
+			continue
+		} else if !errors.Is(err, seal.ErrNotSealed) {
+			return err
+		}
+		s, err := sl.Seal(ctx, d, v)
+		if err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, log, "seal.sign", d.ID, map[string]string{"version": fmt.Sprint(v.Number), "seal": s.Digest}); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "Sealed %s v%d (manifest SHA-256 %s)\n", d.ID, v.Number, s.Digest)
+		sealed++
+	}
+	if sealed == 0 {
+		fmt.Fprintf(a.out, "Every published version of %s is already sealed\n", d.ID)
+	}
+	return nil
+}
+
+func runSealShow(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("seal show")
+	version := fs.Int("version", 0, "dataset version (default the latest published)")
+	asJSON := fs.Bool("json", false, "print the seal as JSON, for 'aperture seal verify'")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("seal show <dataset> [--version N] [--json]")
+	}
+	sl, d, v, err := a.sealedVersion(ctx, pos[0], *version)
+	if err != nil {
+		return err
+	}
+	s, err := sl.Get(ctx, d.ID, v.Number)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(s)
+	}
+	pub, _, err := sl.PublicKey(ctx)
+	if err != nil {
+		return err
+	}
+	m, verr := s.Verify(pub)
+	if verr != nil {
+		if m, err = s.Manifest(); err != nil {
+			return err
+		}
+	}
+	printSeal(a.out, s, m, seal.Fingerprint(pub))
+	if verr != nil {
+		return fmt.Errorf("%s v%d: %w", d.ID, v.Number, verr)
+	}
+	fmt.Fprintln(a.out, "Signature:  valid")
+	return nil
+}
+
+func runSealVerify(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("seal verify")
+	dir := fs.String("dir", "", "check the files under `DIR` against the manifest")
+	keyFile := fs.String("public-key", "", "PEM public key to verify with (default the key in the seal)")
+	fingerprint := fs.String("fingerprint", "", "require the SHA-256 fingerprint of the key to be `HEX`")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("seal verify <seal.json|URL> [--dir DIR] [--public-key FILE] [--fingerprint HEX]")
+	}
+	data, err := readSeal(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	var s seal.Seal
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid seal: %w", err)
+	}
+	keyPEM := []byte(s.PublicKey)
+	if *keyFile != "" {
+		if keyPEM, err = os.ReadFile(*keyFile); err != nil {
+			return err
+		}
+	}
+	pub, err := seal.ParsePublicKey(keyPEM)
+	if err != nil {
+		return err
+	}
+	fp := seal.Fingerprint(pub)
+	if *fingerprint != "" && !strings.EqualFold(*fingerprint, fp) {
+		return fmt.Errorf("the signing key's fingerprint is %s, not %s", fp, *fingerprint)
+	}
+	m, err := s.Verify(pub)
+	if err != nil {
+		return err
+	}
+	printSeal(a.out, &s, m, fp)
+	fmt.Fprintln(a.out, "Signature:  valid")
+	if *keyFile == "" && *fingerprint == "" {
+		fmt.Fprintln(a.out, "Warning: verified with the key in the seal; pin the publisher's key with --public-key or --fingerprint")
+	}
+	if *dir == "" {
+		return nil
+	}
+
+	failed := 0
+	for _, f := range m.Files {
+		if err := checkSealedFile(filepath.Join(*dir, filepath.FromSlash(f.Path)), f); err != nil {
+			fmt.Fprintf(a.out, "FAIL  %s: %v\n", f.Path, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(a.out, "OK    %s\n", f.Path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files do not match the sealed manifest", failed, len(m.Files))
+	}
+	fmt.Fprintf(a.out, "All %d files match the sealed manifest\n", len(m.Files))
+	return nil
+}
+
+// printSeal prints a seal's manifest and signing key.
+func printSeal(w io.Writer, s *seal.Seal, m *seal.Manifest, fingerprint string) {
+	fmt.Fprintf(w, "Dataset:    %s v%d\n", m.Dataset, m.Version)
+	if m.DOI != "" {
+		fmt.Fprintf(w, "DOI:        %s\n", m.DOI)
+	}
+	fmt.Fprintf(w, "Published:  %s\n", m.Published.Format(time.RFC3339))
+	fmt.Fprintf(w, "Files:      %d\n", len(m.Files))
+	fmt.Fprintf(w, "Manifest:   SHA-256 %s\n", s.Digest)
+	fmt.Fprintf(w, "Signed:     %s by %s (%s)\n", s.SignedAt.Format(time.RFC3339), s.Key, s.Algorithm)
+	fmt.Fprintf(w, "Key:        SHA-256 fingerprint %s\n", fingerprint)
+}
+
+// checkSealedFile checks the file at path against its sealed entry.
+func checkSealedFile(path string, f seal.File) error {
+	r, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return err
+	}
+	if n != f.Size {
+		return fmt.Errorf("%d bytes, sealed with %d", n, f.Size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != f.SHA256 {
+		return fmt.Errorf("SHA-256 %s, sealed with %s", sum, f.SHA256)
+	}
+	return nil
+}
+
+// readSeal reads a seal from a URL or a local file.
+func readSeal(ctx context.Context, src string) ([]byte, error) {
+	if !strings.HasPrefix(src, "https://") && !strings.HasPrefix(src, "http://") {
+		return os.ReadFile(src)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create seal request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch seal: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch seal: HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+}
@@ -0,0 +1,242 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/search"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("search", &command{
+		summary: "Search published datasets",
+		subcommands: map[string]*command{
+			"query": {
+				usage:   "[TEXT...] [--creator NAME] [--subject S] [--language L] [--from DATE] [--until DATE] [--license L]... [--collection C]... [--access A]... [--bbox W,S,E,N] [--near LAT,LON [--radius 50km]] [--page N] [--size N] [--json] [--export csv|jsonl|datacite-xml [--all]]",
+				summary: "Search by text and filters, listing matches and facet counts or exporting their metadata",
+				run:     runSearchQuery,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"suggest": {
+				usage:   "PREFIX... [--type title|creator|subject]... [--size N] [--json]",
+				summary: "Complete a prefix from titles, creators, and subjects, one per line for shell completion",
+				run:     runSearchSuggest,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"serve": {
+				usage:   "[--addr ADDR]",
+				summary: "Serve the search API (GET /search, /suggest, /related/{dataset}) as JSON",
+				run:     runSearchServe,
+			},
+		},
+	})
+}
+
+// searcher returns a searcher reading the configured index.
+func (a *app) searcher() (*search.Searcher, error) {
+	if a.cfg.OpenSearchURL == "" {
+		return nil, fmt.Errorf("OpenSearch is not configured; set APERTURE_OPENSEARCH_URL")
+	}
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	x := a.indexer(s)
+	return &search.Searcher{Index: x.Index, Alias: x.Alias, Datasets: dataset.NewStore(s)}, nil
+}
+
+// queryFlags defines the search filter flags on fs. The returned
+// function reads them once fs is parsed, joining the positional
+// arguments as the query text.
+func queryFlags(fs *flag.FlagSet) func(pos []string) (search.Query, error) {
+	var q search.Query
+	var licenses, collections, access stringsFlag
+	fs.StringVar(&q.Creator, "creator", "", "only datasets with a creator whose name contains `NAME`")
+	fs.StringVar(&q.Subject, "subject", "", "only datasets with this subject")
+	fs.StringVar(&q.Language, "language", "", "only datasets with a title or description in this language, e.g. es, matched with its stemming")
+	fs.StringVar(&q.From, "from", "", "only datasets published on or after `DATE` (YYYY, YYYY-MM, or YYYY-MM-DD)")
+	fs.StringVar(&q.Until, "until", "", "only datasets published on or before `DATE`")
+	fs.Var(&licenses, "license", "only datasets under this license (repeatable)")
+	fs.Var(&collections, "collection", "only datasets in this collection (repeatable)")
+	fs.Var(&access, "access", "only datasets with this access level (repeatable)")
+	bbox := fs.String("bbox", "", "only datasets within the bounding box `W,S,E,N` in decimal degrees")
+	near := fs.String("near", "", "only datasets within --radius of `LAT,LON`")
+	radius := fs.String("radius", "", "distance from --near, e.g. 50km, 500m, or 10mi (default 10km)")
+	return func(pos []string) (search.Query, error) {
+		var err error
+		q.Text = strings.Join(pos, " ")
+		q.License, q.Collection, q.Access = licenses, collections, access
+		if *bbox != "" {
+			if q.BBox, err = dataset.ParseBox(*bbox); err != nil {
+				return q, err
+			}
+		}
+		if *near != "" {
+			if q.Near, err = dataset.ParsePoint(*near); err != nil {
+				return q, err
+			}
+		}
+		if *radius != "" {
+			if q.Radius, err = search.ParseDistance(*radius); err != nil {
+				return q, err
+			}
+		}
+		return q, nil
+	}
+}
+
+func runSearchQuery(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("search query")
+	query := queryFlags(fs)
+	page := fs.Int("page", 1, "page of results to show")
+	size := fs.Int("size", search.DefaultPageSize, "results per page")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	export := fs.String("export", "", "write the matches' metadata as `FORMAT`: csv, jsonl, or datacite-xml")
+	all := fs.Bool("all", false, "with --export, export every match rather than one page")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *all && *export == "" {
+		return usageError("search query [TEXT...] [filters...] --export csv|jsonl|datacite-xml --all")
+	}
+	q, err := query(pos)
+	if err != nil {
+		return err
+	}
+	q.Page, q.Size = *page, *size
+
+	s, err := a.searcher()
+	if err != nil {
+		return err
+	}
+	if *export != "" {
+		return exportSearch(ctx, a, s, q, *export, *all)
+	}
+	res, err := s.Search(ctx, &q)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(res)
+	}
+	if res.Total == 0 {
+		fmt.Fprintln(a.out, "No datasets match")
+		return nil
+	}
+	first := (res.Page-1)*res.Size + 1
+	fmt.Fprintf(a.out, "Datasets %d-%d of %d\n\n", first, first+len(res.Hits)-1, res.Total)
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tYEAR\tTITLE\tCREATORS")
+	for _, h := range res.Hits {
+		year := "-"
+		if h.PublicationYear != 0 {
+			year = fmt.Sprint(h.PublicationYear)
+		}
+		creators := strings.Join(h.Creators, "; ")
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", h.ID, year, clip(h.Title, 60), clip(creators, 40))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, name := range []string{"collection", "access", "resourceType", "license", "subject", "creator", "year", "language"} {
+		buckets := res.Facets[name]
+		if len(buckets) == 0 {
+			continue
+		}
+		counts := make([]string, 0, len(buckets))
+		for _, b := range buckets {
+			counts = append(counts, fmt.Sprintf("%s (%d)", b.Value, b.Count))
+		}
+		fmt.Fprintf(a.out, "\n%s: %s", name, strings.Join(counts, ", "))
+	}
+	fmt.Fprintln(a.out)
+	return nil
+}
+
+func runSearchSuggest(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("search suggest")
+	var types stringsFlag
+	fs.Var(&types, "type", "only suggest titles, creators, or subjects (repeatable)")
+	size := fs.Int("size", search.DefaultSuggestions, "number of suggestions")
+	asJSON := fs.Bool("json", false, "print the suggestions with their types and scores as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) == 0 {
+		return usageError("search suggest PREFIX... [--type title|creator|subject]... [--size N] [--json]")
+	}
+	s, err := a.searcher()
+	if err != nil {
+		return err
+	}
+	out, err := s.Suggest(ctx, &search.SuggestQuery{Prefix: strings.Join(pos, " "), Types: types, Size: *size})
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(out)
+	}
+	for _, sg := range out {
+		fmt.Fprintln(a.out, sg.Text)
+	}
+	return nil
+}
+
+// clip shortens s to at most n runes for table output.
+func clip(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func runSearchServe(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("search serve")
+	addr := fs.String("addr", "127.0.0.1:8087", "address to listen on")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("search serve [--addr ADDR]")
+	}
+	s, err := a.searcher()
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: a.instrument("search", search.NewHandler(s))}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(a.out, "Serving search at http://%s/search and /suggest\n", *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
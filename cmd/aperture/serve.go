@@ -0,0 +1,157 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/access"
+	"github.com/scttfrdmn/aperture/internal/api"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/counter"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/oidc"
+	"github.com/scttfrdmn/aperture/internal/search"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("serve", &command{
+		usage:   "[--addr ADDR]",
+		summary: "Serve the platform API (datasets, search, access requests, download URLs, and usage statistics) from one server, or as a Lambda function when run by the Lambda runtime",
+		run:     runServe,
+	})
+}
+
+// apiHandler returns the platform API. Sign-in is served only if a
+// Cognito user pool and app client are configured, and search only if
+// OpenSearch is.
+func (a *app) apiHandler() (http.Handler, error) {
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	issuer, err := a.accessIssuer(access.DefaultExpiry)
+	if err != nil {
+		return nil, err
+	}
+	agreements, err := a.agreements()
+	if err != nil {
+		return nil, err
+	}
+	an, err := a.analytics()
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := a.machineTokens()
+	if err != nil {
+		return nil, err
+	}
+	// Without API Gateway's authorizer in front, users' ID tokens are
+	// verified here against the keys the issuer publishes.
+	var idTokens *oidc.Verifier
+	if iss := a.cfg.Issuer(); iss != "" && a.cfg.OIDCClientID != "" {
+		idTokens = &oidc.Verifier{Issuer: iss, ClientID: a.cfg.OIDCClientID}
+	}
+	opts := api.Options{
+		Datasets:   datasets,
+		Issuer:     issuer,
+		Agreements: agreements,
+		Stats:      counter.NewStatsHandler(an),
+		Authenticate: func(ctx context.Context, secret string) (identity.Principal, error) {
+			if idTokens != nil && !strings.HasPrefix(secret, token.Prefix) {
+				c, err := idTokens.Verify(ctx, secret)
+				if err != nil {
+					return identity.Principal{}, err
+				}
+				return loginPrincipal(a.cfg, c), nil
+			}
+			t, err := tokens.Authenticate(ctx, secret)
+			if err != nil {
+				return identity.Principal{}, err
+			}
+			return t.Principal(), nil
+		},
+		// Requests get the groups a CLI user would: administrators
+		// and the groups of their roles.
+		Resolve: func(ctx context.Context, p identity.Principal) (identity.Principal, error) {
+			if a.cfg.IsAdmin(p.ID) {
+				p.Groups = append(p.Groups, authz.AdminGroup)
+			}
+			return a.withRoles(ctx, p)
+		},
+		OriginSecret: a.cfg.CloudFrontOriginSecret,
+	}
+	if a.cfg.CognitoUserPoolID != "" && a.cfg.OIDCClientID != "" {
+		accounts, err := a.cognitoClient()
+		if err != nil {
+			return nil, err
+		}
+		opts.Accounts, opts.ClientID = accounts, a.cfg.OIDCClientID
+	}
+	if a.cfg.OpenSearchURL != "" {
+		s, err := a.searcher()
+		if err != nil {
+			return nil, err
+		}
+		opts.Search = search.NewHandler(s)
+	}
+	return api.NewHandler(opts), nil
+}
+
+func runServe(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("serve")
+	addr := fs.String("addr", "127.0.0.1:8089", "address to listen on")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("serve [--addr ADDR]")
+	}
+	h, err := a.apiHandler()
+	if err != nil {
+		return err
+	}
+	h = a.instrument("api", h)
+
+	// The Lambda runtime names its API in the function's environment.
+	if runtimeAPI := os.Getenv("AWS_LAMBDA_RUNTIME_API"); runtimeAPI != "" {
+		return (&api.Runtime{API: runtimeAPI, Handler: h}).Run(ctx)
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: h}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(a.out, "Serving the API at http://%s/datasets, /access, /agreements, and /presign\n", *addr)
+	if a.cfg.OpenSearchURL != "" {
+		fmt.Fprintf(a.out, "Serving search at http://%s/search and /suggest\n", *addr)
+	}
+	if a.cfg.CognitoUserPoolID != "" && a.cfg.OIDCClientID != "" {
+		fmt.Fprintf(a.out, "Serving sign-in at http://%s/auth/\n", *addr)
+	}
+	fmt.Fprintf(a.out, "Serving usage statistics at http://%s/stats/\n", *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
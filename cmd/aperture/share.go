@@ -0,0 +1,234 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/share"
+)
+
+func init() {
+	register("share", &command{
+		summary: "Manage time-limited share links",
+		subcommands: map[string]*command{
+			"create": {
+				usage:      "<dataset> [--expires 30d] [--note TEXT]",
+				summary:    "Create a link giving its holder access to a dataset's files",
+				run:        runShareCreate,
+				permission: authz.PermCurate,
+			},
+			"list": {
+				usage:   "[<dataset>] [--all]",
+				summary: "List share links",
+				run:     runShareList,
+			},
+			"revoke": {
+				usage:      "<link-id>",
+				summary:    "Disable a share link immediately",
+				run:        runShareRevoke,
+				permission: authz.PermCurate,
+			},
+			"serve": {
+				usage:   "[--addr ADDR]",
+				summary: "Serve the share link endpoint (GET /share/{token})",
+				run:     runShareServe,
+			},
+		},
+	})
+}
+
+// shareLinks returns the share link registry.
+func (a *app) shareLinks() (*share.Registry, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	return share.NewRegistry(s, log), nil
+}
+
+func runShareCreate(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("share create")
+	expires := durationFlag(fs, "expires", share.DefaultExpiry, "how long the link remains valid")
+	note := fs.String("note", "", "who the link is for, shown by share list")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("share create <dataset> [--expires 30d] [--note TEXT]")
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+		return err
+	}
+	links, err := a.shareLinks()
+	if err != nil {
+		return err
+	}
+	token, l, err := links.Create(ctx, d, *expires, *note)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "%s/share/%s\n", strings.TrimRight(a.cfg.ShareURL, "/"), token)
+	fmt.Fprintf(a.out, "Link %s for %s expires %s; this URL is not shown again\n",
+		l.ID, d.ID, l.Expires.Format(time.RFC3339))
+	return nil
+}
+
+func runShareList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("share list")
+	all := fs.Bool("all", false, "include expired and revoked links")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) > 1 {
+		return usageError("share list [<dataset>] [--all]")
+	}
+
+	datasetID := ""
+	if len(pos) == 1 {
+		datasets, err := a.datasets()
+		if err != nil {
+			return err
+		}
+		d, err := datasets.Resolve(ctx, pos[0])
+		if err != nil {
+			return err
+		}
+		datasetID = d.ID
+	}
+	links, err := a.shareLinks()
+	if err != nil {
+		return err
+	}
+	list, err := links.List(ctx, datasetID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	shown := 0
+	for _, l := range list {
+		status := l.Status(now)
+		if status != "active" && !*all {
+			continue
+		}
+		fmt.Fprintf(a.out, "%s  %-20s %-8s expires %s  by %s  %s\n",
+			l.ID, l.DatasetID, status, l.Expires.Format(time.DateOnly), l.CreatedBy, l.Note)
+		shown++
+	}
+	if shown == 0 {
+		fmt.Fprintln(a.out, "No share links")
+	}
+	return nil
+}
+
+func runShareRevoke(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("share revoke")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("share revoke <link-id>")
+	}
+
+	links, err := a.shareLinks()
+	if err != nil {
+		return err
+	}
+	l, err := links.Get(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	if d, err := datasets.Get(ctx, l.DatasetID); err == nil {
+		if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+			return err
+		}
+	}
+	if l, err = links.Revoke(ctx, l.ID); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Revoked link %s for %s\n", l.ID, l.DatasetID)
+	return nil
+}
+
+func runShareServe(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("share serve")
+	addr := fs.String("addr", "127.0.0.1:8081", "address to listen on")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("share serve [--addr ADDR]")
+	}
+
+	links, err := a.shareLinks()
+	if err != nil {
+		return err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	objects, err := a.objectStore()
+	if err != nil {
+		return err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+
+	h := share.NewHandler(links, datasets, objects, log)
+	h.OriginSecret = a.cfg.CloudFrontOriginSecret
+	srv := &http.Server{Addr: *addr, Handler: a.instrument("share", h)}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(a.out, "Serving share links at http://%s/share/{token}\n", *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/deploy"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/search"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// smokeTest holds what a run of the smoke tests created, for the
+// checks that use it and for the cleanup that removes it.
+type smokeTest struct {
+	a      *app
+	client *http.Client
+
+	// id names the run: the test dataset, its page, and its DOI suffix
+	id string
+
+	file *storage.Location
+	page string
+	doi  string
+}
+
+// smokeTests returns the end-to-end checks run by 'aperture infra test'
+// and after a promotion: a test file is uploaded to the private media
+// bucket of the storage backend, a draft DOI is minted for it, a
+// landing page is published and fetched through the site, the file is
+// downloaded through a presigned URL, and search is queried. The last
+// check removes what the others created. A check with nothing to test,
+// such as the DOI check of an environment without DataCite credentials,
+// passes.
+func (a *app) smokeTests() []deploy.Check {
+	t := &smokeTest{a: a, client: &http.Client{Timeout: 30 * time.Second}, id: fmt.Sprintf("smoke-%d", time.Now().Unix())}
+	return []deploy.Check{
+		{Name: "upload", Run: t.upload},
+		{Name: "doi", Run: t.mintDOI},
+		{Name: "landing", Run: t.landingPage},
+		{Name: "presign", Run: t.presign},
+		{Name: "search", Run: t.search},
+		{Name: "cleanup", Run: t.cleanup},
+	}
+}
+
+// content is the test file and page body, naming the run so that a
+// stale copy is not mistaken for it.
+func (t *smokeTest) content() []byte {
+	return []byte("<!doctype html><title>" + t.id + "</title><p>Aperture smoke test " + t.id + "; removed when the test ends.</p>\n")
+}
+
+func (t *smokeTest) upload(ctx context.Context) error {
+	layout, err := t.a.layout()
+	if err != nil {
+		return err
+	}
+	body := t.content()
+	sum := sha256.Sum256(body)
+	loc, err := layout.Locate(storage.Object{Dataset: t.id, Version: 1, File: "smoke.txt", Access: storage.AccessPrivate, SHA256: hex.EncodeToString(sum[:])})
+	if err != nil {
+		return err
+	}
+	objects, err := t.a.objectStore()
+	if err != nil {
+		return err
+	}
+	if err := objects.PutObject(ctx, loc.Bucket, loc.Key, body, "text/plain"); err != nil {
+		return err
+	}
+	t.file = &loc
+	info, err := objects.HeadObject(ctx, loc.Bucket, loc.Key)
+	if err != nil {
+		return err
+	}
+	if info.Size != int64(len(body)) {
+		return fmt.Errorf("%s holds %d bytes, not the %d uploaded", loc, info.Size, len(body))
+	}
+	return nil
+}
+
+// mintDOI mints a draft DOI for the test dataset. Drafts are never
+// registered with the DOI system, so the DOI does not resolve and can
+// be deleted afterwards.
+func (t *smokeTest) mintDOI(ctx context.Context) error {
+	if t.a.cfg.DataCiteRepositoryID == "" || t.a.cfg.DataCitePrefix == "" {
+		return nil
+	}
+	c := t.a.newDataCiteClient(0)
+	doi, err := c.CreateDOI(ctx, datacite.Attributes{
+		Prefix:          t.a.cfg.DataCitePrefix,
+		Suffix:          t.id,
+		URL:             strings.TrimRight(t.a.cfg.SiteURL, "/") + landing.PagePath(t.id),
+		Titles:          []datacite.Title{{Title: "Aperture smoke test " + t.id}},
+		Creators:        []datacite.Creator{{Name: "Aperture", NameType: "Organizational"}},
+		Publisher:       t.a.cfg.ProjectName,
+		PublicationYear: time.Now().Year(),
+		Types:           &datacite.Types{ResourceTypeGeneral: "Dataset"},
+	})
+	if err != nil {
+		return err
+	}
+	t.doi = doi.ID
+	got, err := c.GetDOI(ctx, t.doi)
+	if err != nil {
+		return err
+	}
+	if got.Attributes.State != "draft" {
+		return fmt.Errorf("%s was minted %s, not as a draft", t.doi, got.Attributes.State)
+	}
+	return nil
+}
+
+// landingPage publishes a landing page for the test dataset and fetches
+// it through the site.
+func (t *smokeTest) landingPage(ctx context.Context) error {
+	if t.a.cfg.SiteURL == "" {
+		return nil
+	}
+	s3c, err := t.a.s3Client()
+	if err != nil {
+		return err
+	}
+	key := landing.PageKey(t.id)
+	if err := s3c.PutObject(ctx, t.a.cfg.FrontendBucket(), key, t.content(), "text/html; charset=utf-8"); err != nil {
+		return err
+	}
+	t.page = key
+	return deploy.PageCheck(t.client, strings.TrimRight(t.a.cfg.SiteURL, "/")+landing.PagePath(t.id), t.id).Run(ctx)
+}
+
+func (t *smokeTest) presign(ctx context.Context) error {
+	if t.file == nil {
+		return errors.New("no test file was uploaded to download")
+	}
+	objects, err := t.a.objectStore()
+	if err != nil {
+		return err
+	}
+	return deploy.PresignCheck(t.client, objects.PresignGetObject(t.file.Bucket, t.file.Key, 5*time.Minute)).Run(ctx)
+}
+
+func (t *smokeTest) search(ctx context.Context) error {
+	if t.a.cfg.OpenSearchURL == "" {
+		return nil
+	}
+	s, err := t.a.searcher()
+	if err != nil {
+		return err
+	}
+	_, err = s.Search(ctx, &search.Query{})
+	return err
+}
+
+// cleanup removes the test file, page, and DOI, whichever were created.
+func (t *smokeTest) cleanup(ctx context.Context) error {
+	var errs []error
+	if t.doi != "" {
+		if err := t.a.newDataCiteClient(0).DeleteDOI(ctx, t.doi); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete %s: %w", t.doi, err))
+		}
+	}
+	if t.page != "" {
+		s3c, err := t.a.s3Client()
+		if err == nil {
+			err = s3c.DeleteObject(ctx, t.a.cfg.FrontendBucket(), t.page)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete the test page: %w", err))
+		}
+	}
+	if t.file != nil {
+		objects, err := t.a.objectStore()
+		if err == nil {
+			err = objects.DeleteObject(ctx, t.file.Bucket, t.file.Key)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete %s: %w", t.file, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,125 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/software"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("software", &command{
+		summary: "Archive software releases and cite them with DOIs",
+		subcommands: map[string]*command{
+			"capture": {
+				usage:      "<owner/repo> <tag> [--dataset ID] [--creator NAME]... [--collection C] [--json]",
+				summary:    "Archive a GitHub release and mint its version DOI",
+				run:        runSoftwareCapture,
+				scope:      token.ScopeDOIWrite,
+				permission: authz.PermPublish,
+			},
+		},
+	})
+}
+
+// softwareManager returns the manager capturing releases. Software
+// DOIs are always DataCite DOIs, whatever the collection's scheme.
+func (a *app) softwareManager() (*software.Manager, error) {
+	if a.cfg.DataCitePrefix == "" || a.cfg.DataCiteRepositoryID == "" {
+		return nil, fmt.Errorf("DataCite is not configured; set DATACITE_PREFIX and DATACITE_REPOSITORY_ID")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	objects, err := a.objectStore()
+	if err != nil {
+		return nil, err
+	}
+	layout, err := a.layout()
+	if err != nil {
+		return nil, err
+	}
+	pages, err := a.landingBuilder()
+	if err != nil {
+		return nil, err
+	}
+	return &software.Manager{
+		Datasets:  datasets,
+		Source:    software.NewGitHub(software.GitHubOptions{BaseURL: a.cfg.GitHubURL, Token: a.cfg.GitHubToken}),
+		Objects:   objects,
+		Layout:    layout,
+		DOIs:      &pid.DataCite{Client: a.newDataCiteClient(0), Prefix: a.cfg.DataCitePrefix},
+		SiteURL:   a.cfg.SiteURL,
+		Publisher: a.cfg.Publisher,
+		Pages:     pages,
+		Log:       log,
+	}, nil
+}
+
+func runSoftwareCapture(ctx context.Context, a *app, args []string) error {
+	const usage = "software capture <owner/repo> <tag> [--dataset ID] [--creator NAME]... [--collection C] [--json]"
+	fs := newFlagSet("software capture")
+	id := fs.String("dataset", "", "ID of the software record (default derived from the repository)")
+	var creators stringsFlag
+	fs.Var(&creators, "creator", "creator of a new record, family name first (repeatable, in citation order)")
+	collection := fs.String("collection", "", "collection of a new record")
+	asJSON := fs.Bool("json", false, "print the record as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError(usage)
+	}
+	m, err := a.softwareManager()
+	if err != nil {
+		return err
+	}
+	opts := software.CaptureOptions{Dataset: *id, Collection: *collection}
+	for _, c := range creators {
+		if c = strings.TrimSpace(c); c != "" {
+			opts.Creators = append(opts.Creators, dataset.Creator{Name: c})
+		}
+	}
+	d, v, err := m.Capture(ctx, pos[0], pos[1], opts)
+	if d == nil {
+		return err
+	}
+	if *asJSON {
+		if perr := a.printJSON(d); perr != nil {
+			return perr
+		}
+		return err
+	}
+	fmt.Fprintf(a.out, "Captured %s as version %d of %s\n", v.Tag, v.Number, d.ID)
+	fmt.Fprintf(a.out, "  Version DOI: https://doi.org/%s\n", v.DOI)
+	fmt.Fprintf(a.out, "  Concept DOI: https://doi.org/%s (all versions)\n", d.DOI)
+	for _, f := range v.Files {
+		fmt.Fprintf(a.out, "  %s  s3://%s/%s\n", f.Path, f.Bucket, f.Key)
+	}
+	return err
+}
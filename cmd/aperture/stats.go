@@ -0,0 +1,130 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/counter"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("stats", &command{
+		usage:   "<dataset> | --collection ID [--since DATE] [--until DATE] [--interval day|month] [--json]",
+		summary: "Show views, downloads, visitors, bytes, and countries of a dataset or collection",
+		run:     runStats,
+		scope:   token.ScopeDatasetsRead,
+	})
+}
+
+// analytics returns the usage statistics of the download log.
+func (a *app) analytics() (*counter.Analytics, error) {
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	collections, err := a.collectionRegistry()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.downloadLog()
+	if err != nil {
+		return nil, err
+	}
+	return &counter.Analytics{Datasets: datasets, Collections: collections, Log: log}, nil
+}
+
+func runStats(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("stats")
+	coll := fs.String("collection", "", "show the usage of the collection or community `ID`")
+	since := fs.String("since", "", "count usage from `DATE` (YYYY, YYYY-MM, or YYYY-MM-DD)")
+	until := fs.String("until", "", "count usage through `DATE` (YYYY, YYYY-MM, or YYYY-MM-DD)")
+	interval := fs.String("interval", "", "break usage down by day or month")
+	asJSON := fs.Bool("json", false, "print statistics as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if (len(pos) == 1) == (*coll != "") || len(pos) > 1 {
+		return usageError("stats <dataset> | --collection ID [--since DATE] [--until DATE] [--interval day|month] [--json]")
+	}
+	q, err := counter.ParseStatsQuery(url.Values{"since": {*since}, "until": {*until}, "interval": {*interval}})
+	if err != nil {
+		return err
+	}
+	an, err := a.analytics()
+	if err != nil {
+		return err
+	}
+	var s *counter.Stats
+	if *coll != "" {
+		s, err = an.Collection(ctx, *coll, q)
+	} else {
+		s, err = an.Dataset(ctx, pos[0], q)
+	}
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(s)
+	}
+
+	name := s.Dataset
+	if s.DOI != "" {
+		name += " (" + s.DOI + ")"
+	}
+	if s.Collection != "" {
+		name = fmt.Sprintf("Collection %s (%d published datasets)", s.Collection, s.Datasets)
+	}
+	period := "all time"
+	switch {
+	case s.From != nil && s.To != nil:
+		period = s.From.Format(time.DateOnly) + " to " + s.To.Add(-time.Nanosecond).Format(time.DateOnly)
+	case s.From != nil:
+		period = "since " + s.From.Format(time.DateOnly)
+	case s.To != nil:
+		period = "through " + s.To.Add(-time.Nanosecond).Format(time.DateOnly)
+	}
+	fmt.Fprintf(a.out, "%s, %s\n", name, period)
+	fmt.Fprintf(a.out, "  Views:            %d\n", s.Views)
+	fmt.Fprintf(a.out, "  Downloads:        %d\n", s.Downloads)
+	fmt.Fprintf(a.out, "  Unique visitors:  %d\n", s.UniqueVisitors)
+	fmt.Fprintf(a.out, "  Bytes sent:       %d\n", s.Bytes)
+
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	if len(s.Countries) > 0 {
+		fmt.Fprintln(a.out)
+		fmt.Fprintln(tw, "COUNTRY\tVIEWS\tDOWNLOADS\t")
+		for _, c := range s.Countries {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t\n", c.Country, c.Views, c.Downloads)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(s.Series) > 0 {
+		fmt.Fprintln(a.out)
+		fmt.Fprintln(tw, "PERIOD\tVIEWS\tDOWNLOADS\tBYTES\t")
+		for _, p := range s.Series {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t\n", p.Period, p.Views, p.Downloads, p.Bytes)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,301 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/status"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+// doiResolver is the resolver the status page checks DOIs through.
+const doiResolver = "https://doi.org"
+
+// jobTitles are the public names of scheduled commands on the status
+// page; others are listed by command name.
+var jobTitles = map[string]string{
+	"alert run":           "Saved search alerts",
+	"audit anchor":        "Audit log anchoring",
+	"citations update":    "Citation tracking",
+	"downloads ingest":    "Download statistics",
+	"downloads submit":    "Usage reports to DataCite",
+	"embargo release-due": "Embargo releases",
+	"fixity run":          "Fixity checks",
+	"replica run":         "Offsite preservation copies",
+	"retention evaluate":  "Retention reviews",
+	"scan run":            "Malware scanning",
+}
+
+func init() {
+	register("status", &command{
+		summary: "Check service health and publish the public status page",
+		subcommands: map[string]*command{
+			"check": {
+				usage:   "[--json]",
+				summary: "Check resolution, the CDN, the servers, DataCite, and scheduled jobs",
+				run:     runStatusCheck,
+				scope:   token.ScopeDatasetsRead,
+			},
+			"publish": {
+				usage:      "[--out DIR]",
+				summary:    "Check health, record uptime, and publish status/index.html and status/status.json",
+				run:        runStatusPublish,
+				scope:      token.ScopeDatasetsWrite,
+				permission: authz.PermMaintain,
+			},
+		},
+	})
+}
+
+func runStatusCheck(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("status check")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("status check [--json]")
+	}
+	c, err := a.statusChecker(ctx)
+	if err != nil {
+		return err
+	}
+	r, err := c.Run(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(r)
+	}
+
+	fmt.Fprintf(a.out, "Overall: %s\n", r.Level)
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	for _, group := range status.Groups {
+		results := r.Group(group)
+		if len(results) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\n%s\n", group)
+		for _, res := range results {
+			uptime := ""
+			if res.Uptime != nil {
+				uptime = fmt.Sprintf("%.2f%%", 100**res.Uptime)
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", res.Name, res.Level, uptime, res.Detail)
+		}
+	}
+	return tw.Flush()
+}
+
+func runStatusPublish(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("status publish")
+	out := fs.String("out", "", "write the page to `DIR` instead of the frontend bucket")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("status publish [--out DIR]")
+	}
+	c, err := a.statusChecker(ctx)
+	if err != nil {
+		return err
+	}
+	r, err := c.Run(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.Record(ctx, r); err != nil {
+		return err
+	}
+
+	var page, data bytes.Buffer
+	if err := status.WriteHTML(&page, r); err != nil {
+		return err
+	}
+	if err := status.WriteJSON(&data, r); err != nil {
+		return err
+	}
+	files := []struct {
+		name, contentType string
+		body              []byte
+	}{
+		{"index.html", "text/html; charset=utf-8", page.Bytes()},
+		{"status.json", "application/json", data.Bytes()},
+	}
+
+	if *out != "" {
+		if err := os.MkdirAll(*out, 0o755); err != nil {
+			return err
+		}
+		for _, f := range files {
+			if err := os.WriteFile(filepath.Join(*out, f.name), f.body, 0o644); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(a.out, "Wrote status page to %s: %s\n", *out, r.Level)
+		return nil
+	}
+	objects, err := a.s3Client()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := objects.PutObject(ctx, a.cfg.FrontendBucket(), "status/"+f.name, f.body, f.contentType); err != nil {
+			return fmt.Errorf("failed to upload status page: %w", err)
+		}
+	}
+	fmt.Fprintf(a.out, "Published status page to s3://%s/status/: %s\n", a.cfg.FrontendBucket(), r.Level)
+	return nil
+}
+
+// statusChecker returns the checks of the status page: resolution of
+// the most recently published DOI, its landing page on the CDN, the
+// servers' health endpoints, DataCite, and every scheduled command.
+func (a *app) statusChecker(ctx context.Context) (*status.Checker, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return nil, err
+	}
+	all, err := datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var latest *dataset.Dataset
+	var published time.Time
+	for _, d := range all {
+		if d.State != dataset.StatePublished || d.DOI == "" {
+			continue
+		}
+		if v := d.Latest(); v != nil && v.PublishedAt != nil && v.PublishedAt.After(published) {
+			latest, published = d, *v.PublishedAt
+		}
+	}
+
+	client := &http.Client{}
+	c := &status.Checker{State: s}
+	var doi string
+	if latest != nil {
+		doi = latest.DOI
+	}
+	c.Checks = append(c.Checks, status.Resolution(status.NoRedirects(client), doiResolver, doi, a.cfg.SiteURL))
+	if a.cfg.SiteURL != "" {
+		page := strings.TrimSuffix(a.cfg.SiteURL, "/") + "/"
+		if latest != nil {
+			page = strings.TrimSuffix(a.cfg.SiteURL, "/") + landing.PagePath(latest.ID)
+		}
+		c.Checks = append(c.Checks, status.Endpoint(client, "Landing pages", page))
+	}
+
+	endpoints := a.cfg.StatusEndpoints
+	if endpoints == nil {
+		endpoints = make(map[string]string)
+		if a.cfg.APIURL != "" {
+			endpoints["API"] = strings.TrimSuffix(a.cfg.APIURL, "/") + status.HealthPath
+		}
+		if a.cfg.DownloadURL != "" {
+			endpoints["Downloads"] = strings.TrimSuffix(a.cfg.DownloadURL, "/") + status.HealthPath
+		}
+	}
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.Checks = append(c.Checks, status.Endpoint(client, name, endpoints[name]))
+	}
+
+	c.Checks = append(c.Checks, status.DataCite(client, a.cfg.DataCiteURL, s))
+	for _, job := range scheduledCommands("", commands) {
+		title := jobTitles[job.name]
+		if title == "" {
+			title = job.name
+		}
+		c.Checks = append(c.Checks, status.Job(s, job.name, title, job.every, time.Now))
+	}
+	return c, nil
+}
+
+// scheduledCommand is a command run by a scheduled job.
+type scheduledCommand struct {
+	name  string
+	every time.Duration
+}
+
+// scheduledCommands returns the scheduled commands under cmds, named
+// under prefix, in name order.
+func scheduledCommands(prefix string, cmds map[string]*command) []scheduledCommand {
+	var out []scheduledCommand
+	for n, c := range cmds {
+		name := strings.TrimSpace(prefix + " " + n)
+		if c.schedule != 0 {
+			out = append(out, scheduledCommand{name, c.schedule})
+		}
+		out = append(out, scheduledCommands(name, c.subcommands)...)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+// recordRun records the outcome of a run of the scheduled command
+// name for the status page. Failing to record it does not fail the run.
+func (a *app) recordRun(ctx context.Context, name string, start time.Time, err error) {
+	s, serr := a.store()
+	if serr == nil {
+		serr = status.RecordRun(ctx, s, name, start, err)
+	}
+	if serr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", serr)
+	}
+}
+
+// healthProbes returns the dependencies checked by the health endpoint
+// of every server.
+func (a *app) healthProbes() map[string]status.Probe {
+	return map[string]status.Probe{
+		"state": func(ctx context.Context) error {
+			s, err := a.store()
+			if err != nil {
+				return err
+			}
+			return status.StoreProbe(s)(ctx)
+		},
+	}
+}
+
+// dataCiteSynced records the outcome of a DataCite registration for the
+// status page.
+func (a *app) dataCiteSynced(ctx context.Context, err error) {
+	a.recordRun(ctx, status.DataCiteSync, time.Now(), err)
+}
@@ -0,0 +1,488 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dedup"
+	"github.com/scttfrdmn/aperture/internal/gc"
+	"github.com/scttfrdmn/aperture/internal/relocation"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/storage"
+	"github.com/scttfrdmn/aperture/internal/tiering"
+)
+
+func init() {
+	register("storage", &command{
+		summary: "Manage stored objects",
+		subcommands: map[string]*command{
+			"gc": {
+				usage:      "[--grace 7d] [--apply]",
+				summary:    "Remove objects not referenced by any dataset manifest",
+				run:        runStorageGC,
+				permission: authz.PermMaintain,
+			},
+			"dedup": {
+				usage:      "[--apply] [--json]",
+				summary:    "Move existing files to the content-addressed layout",
+				run:        runStorageDedup,
+				permission: authz.PermMaintain,
+			},
+			"tier": {
+				usage:      "[--after 365d] [--apply] [--json]",
+				summary:    "Move the files of older versions from the primary store to the cloud",
+				run:        runStorageTier,
+				permission: authz.PermMaintain,
+			},
+			"migrate": {
+				usage:      "--to BACKEND [--from BACKEND] [--prefix PREFIX] [--site-url URL] [--download-url URL] [--apply] [--json]",
+				summary:    "Move the stored objects to another storage backend",
+				run:        runStorageMigrate,
+				permission: authz.PermMaintain,
+			},
+			"serve": {
+				usage:      "[--addr ADDR]",
+				summary:    "Serve the download and upload URLs of the filesystem storage backend",
+				run:        runStorageServe,
+				permission: authz.PermMaintain,
+			},
+			"probe": {
+				usage:      "[--bucket NAME]... [--replica] [--json]",
+				summary:    "Detect the S3 features the storage endpoint supports",
+				run:        runStorageProbe,
+				permission: authz.PermMaintain,
+			},
+		},
+	})
+}
+
+// mediaBuckets returns the per-purpose media buckets.
+func (a *app) mediaBuckets() []string {
+	prefix := a.cfg.BucketPrefix()
+	var buckets []string
+	for _, acc := range []storage.Access{storage.AccessPublic, storage.AccessPrivate, storage.AccessRestricted, storage.AccessEmbargoed} {
+		buckets = append(buckets, storage.BucketForAccess(prefix, acc))
+	}
+	return buckets
+}
+
+func runStorageGC(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("storage gc")
+	grace := durationFlag(fs, "grace", 7*24*time.Hour, "minimum age of unreferenced objects to remove")
+	apply := fs.Bool("apply", false, "delete the objects (default is a dry-run report)")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	var buckets stringsFlag
+	fs.Var(&buckets, "bucket", "bucket to scan (repeatable; default all media buckets)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if len(buckets) == 0 {
+		buckets = a.mediaBuckets()
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	objects, err := a.scanStore()
+	if err != nil {
+		return err
+	}
+
+	c := &gc.Collector{Objects: objects, Datasets: datasets, Grace: *grace}
+	report, err := c.Plan(ctx, buckets)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := a.printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		printGCReport(a, fs, report)
+	}
+
+	if !*apply {
+		if len(report.Orphans)+len(report.Uploads) > 0 && !*asJSON {
+			fmt.Fprintln(a.out, "\nDry run: re-run with --apply to delete.")
+		}
+		return nil
+	}
+
+	removed, err := c.Apply(ctx, report)
+	fmt.Fprintf(a.out, "Removed %d objects and uploads\n", removed)
+	return err
+}
+
+// printGCReport prints a human-readable gc report.
+func printGCReport(a *app, fs *flag.FlagSet, r *gc.Report) {
+	for _, o := range r.Orphans {
+		fmt.Fprintf(a.out, "orphan  s3://%s/%s  %d bytes  %s\n", o.Bucket, o.Key, o.Size, o.LastModified.Format(time.DateOnly))
+	}
+	for _, u := range r.Uploads {
+		fmt.Fprintf(a.out, "upload  s3://%s/%s  %s  started %s\n", u.Bucket, u.Key, u.UploadID, u.Initiated.Format(time.DateOnly))
+	}
+	fmt.Fprintf(a.out, "\nScanned %d objects: %d orphans (%d bytes), %d abandoned uploads, %d within the %s grace period\n",
+		r.Scanned, len(r.Orphans), r.Bytes(), len(r.Uploads), r.InGrace, fs.Lookup("grace").Value)
+	if r.Shared > 0 {
+		fmt.Fprintf(a.out, "%d objects are shared by several files, saving %d bytes\n", r.Shared, r.SharedBytes)
+	}
+}
+
+func runStorageDedup(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("storage dedup")
+	apply := fs.Bool("apply", false, "copy the files and update the manifests (default is a dry-run report)")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	layout, err := a.layout()
+	if err != nil {
+		return err
+	}
+	content, ok := layout.(*storage.ContentLayout)
+	if !ok {
+		return fmt.Errorf("the storage layout is %s; set APERTURE_STORAGE_LAYOUT=content before moving files to it", layout.Name())
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	objects, err := a.scanStore()
+	if err != nil {
+		return err
+	}
+	m := &dedup.Migrator{Objects: objects, Datasets: datasets, Layout: content}
+	report, err := m.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := a.printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		for _, mv := range report.Moves {
+			verb := "link"
+			if mv.Copy {
+				verb = "copy"
+			}
+			fmt.Fprintf(a.out, "%s  %s v%d %s  %s -> %s\n", verb, mv.Dataset, mv.Version, mv.Path, mv.From, mv.To)
+		}
+		fmt.Fprintf(a.out, "\n%d of %d files to move, saving %d bytes; %d files without a SHA-256 digest stay where they are\n",
+			len(report.Moves), report.Files, report.SavedBytes(), report.Unhashed)
+	}
+
+	if !*apply {
+		if len(report.Moves) > 0 && !*asJSON {
+			fmt.Fprintln(a.out, "\nDry run: re-run with --apply to move.")
+		}
+		return nil
+	}
+
+	moved, err := m.Apply(ctx, report)
+	fmt.Fprintf(a.out, "Moved %d files; run 'aperture storage gc' to remove their old copies\n", moved)
+	return err
+}
+
+func runStorageTier(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("storage tier")
+	after := durationFlag(fs, "after", time.Duration(a.cfg.TierAfterDays)*24*time.Hour, "how long published versions stay in the primary store")
+	apply := fs.Bool("apply", false, "copy the files and update the manifests (default is a dry-run report)")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if a.cfg.TierBucketPrefix == "" {
+		return fmt.Errorf("hybrid tiering is not configured; set APERTURE_TIER_BUCKET_PREFIX")
+	}
+
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	objects, err := a.objectStore()
+	if err != nil {
+		return err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	t := &tiering.Tierer{
+		Datasets:     datasets,
+		Objects:      objects,
+		Prefix:       a.cfg.BucketPrefix(),
+		CloudPrefix:  a.cfg.TierBucketPrefix,
+		After:        *after,
+		StorageClass: a.cfg.TierStorageClass,
+		Log:          log,
+	}
+	report, err := t.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := a.printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		for _, mv := range report.Moves {
+			verb := "link"
+			if mv.Copy {
+				verb = "copy"
+			}
+			fmt.Fprintf(a.out, "%s  %s v%d %s  %s -> %s\n", verb, mv.Dataset, mv.Version, mv.Path, mv.From, mv.To)
+		}
+		fmt.Fprintf(a.out, "\n%d of %d published files to tier, copying %d bytes; %d files are already in the cloud\n",
+			len(report.Moves), report.Files, report.Bytes(), report.Cloud)
+	}
+
+	if !*apply {
+		if len(report.Moves) > 0 && !*asJSON {
+			fmt.Fprintln(a.out, "\nDry run: re-run with --apply to tier.")
+		}
+		return nil
+	}
+
+	tiered, err := t.Apply(ctx, report)
+	fmt.Fprintf(a.out, "Tiered %d files; run 'aperture storage gc' to remove their primary copies\n", tiered)
+	return err
+}
+
+// bucketCapabilities are the capabilities of one probed bucket.
+type bucketCapabilities struct {
+	Bucket string `json:"bucket"`
+	*s3.Capabilities
+}
+
+func runStorageProbe(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("storage probe")
+	toReplica := fs.Bool("replica", false, "probe the replica bucket instead of the media buckets")
+	asJSON := fs.Bool("json", false, "print the capabilities as JSON")
+	var buckets stringsFlag
+	fs.Var(&buckets, "bucket", "bucket to probe (repeatable; default all media buckets)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	var client *s3.Client
+	var err error
+	if *toReplica {
+		if a.cfg.ReplicaBucket == "" {
+			return fmt.Errorf("preservation copies are not configured; set APERTURE_REPLICA_BUCKET")
+		}
+		buckets = stringsFlag{a.cfg.ReplicaBucket}
+		client, err = a.replicaClient()
+	} else {
+		if len(buckets) == 0 {
+			buckets = a.mediaBuckets()
+		}
+		client, err = a.mediaClient()
+	}
+	if err != nil {
+		return err
+	}
+
+	var probed []bucketCapabilities
+	for _, bucket := range buckets {
+		caps, err := client.Probe(ctx, bucket)
+		if err != nil {
+			return fmt.Errorf("probe %s: %w", bucket, err)
+		}
+		probed = append(probed, bucketCapabilities{Bucket: bucket, Capabilities: caps})
+	}
+
+	if *asJSON {
+		return a.printJSON(probed)
+	}
+	for _, p := range probed {
+		fmt.Fprintf(a.out, "%s\n", p.Bucket)
+		fmt.Fprintf(a.out, "  storage classes    %s\n", strings.Join(p.StorageClasses, ", "))
+		fmt.Fprintf(a.out, "  object attributes  %t\n", p.ObjectAttributes)
+		fmt.Fprintf(a.out, "  versioning         %t\n", p.Versioning)
+		fmt.Fprintf(a.out, "  object lock        %t\n", p.ObjectLock)
+	}
+	return nil
+}
+
+func runStorageMigrate(ctx context.Context, a *app, args []string) error {
+	const usage = "storage migrate --to BACKEND [--from BACKEND] [--prefix PREFIX] [--site-url URL] [--download-url URL] [--apply] [--json]"
+	fs := newFlagSet("storage migrate")
+	from := fs.String("from", cmp.Or(a.cfg.StorageBackend, storage.BackendS3), "backend to move from: s3 (or aws), gcs, azure, or filesystem")
+	to := fs.String("to", "", "backend to move to")
+	prefix := fs.String("prefix", "", "bucket name prefix in the target (default the current one)")
+	siteURL := fs.String("site-url", a.cfg.SiteURL, "base URL of landing pages after the move, registered with DataCite")
+	downloadURL := fs.String("download-url", a.cfg.DownloadURL, "base URL of download links after the move")
+	apply := fs.Bool("apply", false, "copy the objects and rewrite the manifests (default is a dry-run report)")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *to == "" || len(pos) != 0 {
+		return usageError(usage)
+	}
+	backendName := func(name string) string {
+		if name == "aws" {
+			return storage.BackendS3
+		}
+		return name
+	}
+	*from, *to = backendName(*from), backendName(*to)
+	if *from == *to && *prefix == "" {
+		return fmt.Errorf("the objects are already in %s; name another backend or a new --prefix", *to)
+	}
+
+	source, err := a.backend(*from)
+	if err != nil {
+		return err
+	}
+	target, err := a.backend(*to)
+	if err != nil {
+		return err
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	s, err := a.store()
+	if err != nil {
+		return err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	r := &relocation.Relocator{
+		Source:       source,
+		Target:       target,
+		Buckets:      a.mediaBuckets(),
+		Prefix:       a.cfg.BucketPrefix(),
+		TargetPrefix: *prefix,
+		Datasets:     datasets,
+		State:        s,
+		Log:          log,
+	}
+	report, err := r.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := a.printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range report.Copies {
+			fmt.Fprintf(a.out, "copy  %s -> %s/%s/%s  %d bytes\n", c.From, *to, c.To.Bucket, c.To.Key, c.Size)
+		}
+		for _, loc := range report.Archived {
+			fmt.Fprintf(a.out, "archived  %s: restore it before moving\n", loc)
+		}
+		fmt.Fprintf(a.out, "\n%d objects to copy from %s to %s (%d bytes); %d already copied, %d archived\n",
+			len(report.Copies), *from, *to, report.Bytes(), report.Copied, len(report.Archived))
+	}
+
+	if !*apply {
+		if !*asJSON {
+			fmt.Fprintln(a.out, "\nDry run: re-run with --apply to move.")
+		}
+		return nil
+	}
+
+	res, err := r.Copy(ctx, report)
+	if res != nil {
+		fmt.Fprintf(a.out, "Copied and verified %d objects (%d bytes)\n", res.Copied, res.Bytes)
+		for obj, why := range res.Failed {
+			fmt.Fprintf(a.out, "  FAIL  %s: %s\n", obj, why)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(res.Failed) > 0 || len(report.Archived) > 0 {
+		return fmt.Errorf("%d objects are not moved yet; re-run to retry them", len(res.Failed)+len(report.Archived))
+	}
+
+	pages, err := a.landingBuilder()
+	if err != nil {
+		return err
+	}
+	pages.SiteURL, pages.DownloadURL = *siteURL, *downloadURL
+	r.Pages = pages
+	pids, err := a.pidRegistrar()
+	if err != nil {
+		return err
+	}
+	if pids != nil {
+		pids.SiteURL, pids.DownloadURL = *siteURL, *downloadURL
+		r.PIDs = pids
+	}
+	changed, err := r.Rewrite(ctx)
+	fmt.Fprintf(a.out, "Rewrote %d manifests and republished landing pages and identifiers\n", changed)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Set APERTURE_STORAGE_BACKEND=%s", *to)
+	if *prefix != "" {
+		fmt.Fprintf(a.out, ", and APERTURE_PROJECT_NAME and APERTURE_ENV so that buckets are named %s-...,", *prefix)
+	}
+	fmt.Fprintf(a.out, " to serve from the new store; the %s objects are left for you to remove\n", *from)
+	return nil
+}
+
+func runStorageServe(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("storage serve")
+	addr := fs.String("addr", "127.0.0.1:8480", "address to listen on")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError("storage serve [--addr ADDR]")
+	}
+	if a.cfg.StorageBackend != storage.BackendFilesystem {
+		return fmt.Errorf("the storage backend is %s; set APERTURE_STORAGE_BACKEND=filesystem to serve files", cmp.Or(a.cfg.StorageBackend, storage.BackendS3))
+	}
+	files, err := a.fileStore()
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: a.instrument("files", files)}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(a.out, "Serving %s at http://%s (presigned URLs point to %s)\n", a.storageRoot(), *addr, a.cfg.FileServerURL)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
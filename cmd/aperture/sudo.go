@@ -0,0 +1,106 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/impersonation"
+)
+
+const sudoUsage = "sudo --as USER --reason TEXT <command> [args...]"
+
+func init() {
+	register("sudo", &command{
+		usage:   "--as USER --reason TEXT <command>",
+		summary: "Run a command as another user (administrators only)",
+		run:     runSudo,
+	})
+}
+
+func runSudo(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("sudo")
+	as := fs.String("as", "", "user to act as")
+	reason := fs.String("reason", "", "justification recorded in the audit log and sent to the user")
+
+	// Flags after the command belong to the command, so stop at the
+	// first positional argument.
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	rest := fs.Args()
+	if *as == "" || len(rest) == 0 {
+		return usageError(sudoUsage)
+	}
+	if rest[0] == "sudo" {
+		return fmt.Errorf("nested sudo is not allowed")
+	}
+
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	notifier, err := a.notifier()
+	if err != nil {
+		return err
+	}
+
+	// The principal already holds the groups of its roles, so an
+	// administrator by role grant counts as well as one in
+	// APERTURE_ADMINS.
+	ctx, session, err := impersonation.Start(ctx, impersonation.Options{
+		Subject:       *as,
+		Justification: *reason,
+		IsAdmin:       func(p identity.Principal) bool { return slices.Contains(p.Groups, authz.AdminGroup) },
+		Resolve:       a.subjectPrincipal,
+		Log:           log,
+		Notifier:      notifier,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Acting as %s (session %s); %s has been notified.\n", *as, session.ID(), *as)
+
+	root := &command{subcommands: commands}
+	cmdErr := root.execute(ctx, a, "", rest)
+	if err := session.End(ctx, cmdErr); err != nil {
+		return fmt.Errorf("failed to record end of impersonation: %w", err)
+	}
+	return cmdErr
+}
+
+// subjectPrincipal returns the impersonated user p with the groups it
+// would hold if it signed in: its user pool groups, when a pool is
+// configured, the admins group if it is a configured administrator, and
+// the groups of its granted roles.
+func (a *app) subjectPrincipal(ctx context.Context, p identity.Principal) (identity.Principal, error) {
+	if a.cfg.CognitoUserPoolID != "" {
+		c, err := a.cognitoClient()
+		if err != nil {
+			return p, err
+		}
+		if p.Groups, err = c.GroupsForUser(ctx, p.ID); err != nil {
+			return p, err
+		}
+	}
+	if a.cfg.IsAdmin(p.ID) && !slices.Contains(p.Groups, authz.AdminGroup) {
+		p.Groups = append(p.Groups, authz.AdminGroup)
+	}
+	return a.withRoles(ctx, p)
+}
@@ -0,0 +1,70 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/config"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+)
+
+func TestSudoActsWithSubjectRoles(t *testing.T) {
+	t.Setenv("APERTURE_STATE_DIR", t.TempDir())
+	t.Setenv("APERTURE_ADMINS", "")
+	t.Setenv("APERTURE_COGNITO_USER_POOL_ID", "")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	a := &app{cfg: cfg, in: strings.NewReader(""), out: out}
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "ops@uni.edu", Groups: []string{authz.AdminGroup}})
+
+	datasets, err := a.datasets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	d := &dataset.Dataset{
+		ID: "ds-1", Title: "Survey", State: dataset.StatePublished, Depositor: "jane@uni.edu",
+		ACL:      &authz.ACL{Manage: []string{"user:jane@uni.edu"}},
+		Versions: []dataset.Version{{Number: 1, PublishedAt: &published}},
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	roles, err := a.roleGrants()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := roles.Grant(ctx, "jane@uni.edu", authz.RoleDepositor); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"--as", "jane@uni.edu", "--reason", "fix the broken draft (ticket 1234)", "dataset", "version", "ds-1"}
+	if err := runSudo(ctx, a, args); err != nil {
+		t.Fatalf("sudo dataset version error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Started version 2 of ds-1") {
+		t.Errorf("output = %q", out.String())
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/aperture/internal/supersede"
+)
+
+func runDatasetSupersede(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset supersede")
+	by := fs.String("by", "", "the replacement dataset's DOI or ID")
+	var opts supersede.Options
+	fs.StringVar(&opts.Reason, "reason", "", "why the dataset was replaced, shown on both landing pages")
+	fs.BoolVar(&opts.Warn, "warn-downloads", false, "show a warning linking the replacement before each download of the old files")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || *by == "" {
+		return usageError("dataset supersede <old-doi|dataset> --by <new-doi|dataset> [--reason TEXT] [--warn-downloads]")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	pages, err := a.landingBuilder()
+	if err != nil {
+		return err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	m := &supersede.Manager{Datasets: datasets, Pages: pages, Log: log}
+	r, err := a.pidRegistrar()
+	if err != nil {
+		return err
+	}
+	if r != nil {
+		if r.Funding, err = a.awardRegistry(); err != nil {
+			return err
+		}
+		m.PIDs = r
+	}
+
+	old, repl, err := m.Supersede(ctx, pos[0], *by, opts)
+	if old == nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "%s is superseded by %s\n", old.ID, repl.ID)
+	if opts.Warn {
+		fmt.Fprintf(a.out, "Downloads of %s's files now show a warning linking %s\n", old.ID, old.SupersededBy.URL)
+	}
+	return err
+}
@@ -0,0 +1,160 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+func init() {
+	register("token", &command{
+		summary: "Manage machine tokens for pipelines and CI systems",
+		subcommands: map[string]*command{
+			"create": {
+				usage:   "<name> --scope SCOPE[,SCOPE...] [--ttl 90d] [--note TEXT]",
+				summary: "Issue a scoped token acting as service account svc:<name>",
+				run:     runTokenCreate,
+			},
+			"list": {
+				usage:   "[--all] [--json]",
+				summary: "List the tokens you created (or every token, for administrators)",
+				run:     runTokenList,
+			},
+			"revoke": {
+				usage:   "<token-id>",
+				summary: "Disable a token immediately",
+				run:     runTokenRevoke,
+			},
+		},
+	})
+}
+
+// machineTokens returns the machine token registry.
+func (a *app) machineTokens() (*token.Registry, error) {
+	s, err := a.store()
+	if err != nil {
+		return nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	return token.NewRegistry(s, log), nil
+}
+
+// tokenPrincipal authenticates APERTURE_TOKEN and returns its service
+// account.
+func (a *app) tokenPrincipal(ctx context.Context) (identity.Principal, error) {
+	tokens, err := a.machineTokens()
+	if err != nil {
+		return identity.Principal{}, err
+	}
+	t, err := tokens.Authenticate(ctx, a.cfg.Token)
+	if err != nil {
+		return identity.Principal{}, fmt.Errorf("APERTURE_TOKEN: %w", err)
+	}
+	return t.Principal(), nil
+}
+
+func runTokenCreate(ctx context.Context, a *app, args []string) error {
+	const usage = "token create <name> --scope SCOPE[,SCOPE...] [--ttl 90d] [--note TEXT]"
+	fs := newFlagSet("token create")
+	scope := fs.String("scope", "", "comma-separated scopes: "+strings.Join(token.Scopes, ", "))
+	ttl := durationFlag(fs, "ttl", token.DefaultTTL, "how long the token remains valid")
+	note := fs.String("note", "", "what the token is for")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 || *scope == "" {
+		return usageError(usage)
+	}
+	scopes, err := token.ParseScopes(*scope)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := a.machineTokens()
+	if err != nil {
+		return err
+	}
+	secret, t, err := tokens.Create(ctx, pos[0], scopes, *ttl, *note)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Token %s for %s%s (%s), expires %s:\n\n  %s\n\n",
+		t.ID, token.AccountPrefix, t.Name, strings.Join(t.Scopes, ","), t.Expires.Format(time.DateOnly), secret)
+	fmt.Fprintln(a.out, "Store it in your pipeline's secrets as APERTURE_TOKEN; it will not be shown again.")
+	fmt.Fprintf(a.out, "Grant dataset access with: aperture acl grant <dataset> user:%s%s\n", token.AccountPrefix, t.Name)
+	return nil
+}
+
+func runTokenList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("token list")
+	all := fs.Bool("all", false, "list every token (administrators only)")
+	asJSON := fs.Bool("json", false, "print tokens as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	tokens, err := a.machineTokens()
+	if err != nil {
+		return err
+	}
+	list, err := tokens.List(ctx, *all)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(list)
+	}
+	now := time.Now()
+	for _, t := range list {
+		used := "never used"
+		if t.LastUsedAt != nil {
+			used = "used " + t.LastUsedAt.Format(time.DateOnly)
+		}
+		fmt.Fprintf(a.out, "%s  %-8s %-24s %-28s expires %s  %s  %s\n",
+			t.ID, t.Status(now), token.AccountPrefix+t.Name, strings.Join(t.Scopes, ","),
+			t.Expires.Format(time.DateOnly), used, t.CreatedBy)
+	}
+	return nil
+}
+
+func runTokenRevoke(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("token revoke")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("token revoke <token-id>")
+	}
+	tokens, err := a.machineTokens()
+	if err != nil {
+		return err
+	}
+	t, err := tokens.Revoke(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Revoked token %s for %s%s\n", t.ID, token.AccountPrefix, t.Name)
+	return nil
+}
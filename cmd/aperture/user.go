@@ -0,0 +1,253 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/cognito"
+	"github.com/scttfrdmn/aperture/internal/identity"
+)
+
+func init() {
+	register("user", &command{
+		summary: "Manage repository accounts (administrators only)",
+		subcommands: map[string]*command{
+			"create": {
+				usage:      "<email> [--name NAME] [--group G]... --reason TEXT",
+				summary:    "Create an account and email the user a temporary password",
+				run:        runUserCreate,
+				permission: authz.PermManageUsers,
+				privileged: true,
+			},
+			"list": {
+				usage:      "[--group G] [--json]",
+				summary:    "List accounts, optionally only the members of a group",
+				run:        runUserList,
+				permission: authz.PermManageUsers,
+			},
+			"disable": {
+				usage:      "<email> --reason TEXT",
+				summary:    "Prevent a user from signing in and end their sessions",
+				run:        runUserDisable,
+				permission: authz.PermManageUsers,
+				privileged: true,
+			},
+			"enable": {
+				usage:      "<email> --reason TEXT",
+				summary:    "Allow a disabled user to sign in again",
+				run:        runUserEnable,
+				permission: authz.PermManageUsers,
+				privileged: true,
+			},
+			"add-to-group": {
+				usage:      "<email> <group> --reason TEXT",
+				summary:    "Add a user to a group (e.g. researchers, curators, admins)",
+				run:        runUserAddToGroup,
+				permission: authz.PermManageUsers,
+				privileged: true,
+			},
+			"remove-from-group": {
+				usage:      "<email> <group> --reason TEXT",
+				summary:    "Remove a user from a group",
+				run:        runUserRemoveFromGroup,
+				permission: authz.PermManageUsers,
+				privileged: true,
+			},
+		},
+	})
+}
+
+// cognitoClient returns a client for the configured user pool.
+func (a *app) cognitoClient() (*cognito.Client, error) {
+	if a.cfg.CognitoUserPoolID == "" {
+		return nil, fmt.Errorf("APERTURE_COGNITO_USER_POOL_ID must be set to manage users")
+	}
+	creds, err := aws.CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return cognito.NewClient(cognito.Options{
+		Region:      a.cfg.AWSRegion,
+		Endpoint:    a.cfg.AWSEndpoint,
+		UserPoolID:  a.cfg.CognitoUserPoolID,
+		Credentials: creds,
+	})
+}
+
+// userCommand returns the user pool client and audit log.
+func (a *app) userCommand() (*cognito.Client, audit.Log, error) {
+	c, err := a.cognitoClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, log, nil
+}
+
+func runUserCreate(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("user create")
+	name := fs.String("name", "", "display name")
+	var groups stringsFlag
+	fs.Var(&groups, "group", "group to add the user to (repeatable)")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("user create <email> [--name NAME] [--group G]... --reason TEXT")
+	}
+	email := identity.Normalize(pos[0])
+
+	c, log, err := a.userCommand()
+	if err != nil {
+		return err
+	}
+	u, err := c.CreateUser(ctx, email, *name)
+	if err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, log, "user.create", email, map[string]string{"name": *name}); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Created %s; a temporary password has been emailed\n", email)
+
+	for _, g := range groups {
+		if err := c.AddUserToGroup(ctx, u.Username, g); err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, log, "user.group.add", email, map[string]string{"group": g}); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "Added %s to %s\n", email, g)
+	}
+	return nil
+}
+
+func runUserList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("user list")
+	group := fs.String("group", "", "only members of this group")
+	asJSON := fs.Bool("json", false, "print users as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	c, err := a.cognitoClient()
+	if err != nil {
+		return err
+	}
+
+	var users []cognito.User
+	if *group != "" {
+		users, err = c.ListUsersInGroup(ctx, *group)
+	} else {
+		users, err = c.ListUsers(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return a.printJSON(users)
+	}
+	for _, u := range users {
+		state := "enabled"
+		if !u.Enabled {
+			state = "disabled"
+		}
+		fmt.Fprintf(a.out, "%-32s %-8s %-22s %s  %s\n", u.Email, state, u.Status, u.Created.Format(time.DateOnly), u.Name)
+	}
+	return nil
+}
+
+func runUserDisable(ctx context.Context, a *app, args []string) error {
+	return userAction(ctx, a, args, "disable", "user.disable", func(c *cognito.Client, email string) error {
+		return c.DisableUser(ctx, email)
+	}, "Disabled %s and signed them out\n")
+}
+
+func runUserEnable(ctx context.Context, a *app, args []string) error {
+	return userAction(ctx, a, args, "enable", "user.enable", func(c *cognito.Client, email string) error {
+		return c.EnableUser(ctx, email)
+	}, "Enabled %s\n")
+}
+
+// userAction runs a single-user command: it parses <email>, applies fn,
+// records action, and prints done with the email.
+func userAction(ctx context.Context, a *app, args []string, name, action string, fn func(*cognito.Client, string) error, done string) error {
+	fs := newFlagSet("user " + name)
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("user " + name + " <email> --reason TEXT")
+	}
+	email := identity.Normalize(pos[0])
+
+	c, log, err := a.userCommand()
+	if err != nil {
+		return err
+	}
+	if err := fn(c, email); err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, log, action, email, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, done, email)
+	return nil
+}
+
+func runUserAddToGroup(ctx context.Context, a *app, args []string) error {
+	return userGroupAction(ctx, a, args, "add-to-group", "user.group.add", (*cognito.Client).AddUserToGroup, "Added %s to %s\n")
+}
+
+func runUserRemoveFromGroup(ctx context.Context, a *app, args []string) error {
+	return userGroupAction(ctx, a, args, "remove-from-group", "user.group.remove", (*cognito.Client).RemoveUserFromGroup, "Removed %s from %s\n")
+}
+
+// userGroupAction runs a group membership command for <email> <group>.
+func userGroupAction(ctx context.Context, a *app, args []string, name, action string,
+	fn func(*cognito.Client, context.Context, string, string) error, done string) error {
+	fs := newFlagSet("user " + name)
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError("user " + name + " <email> <group> --reason TEXT")
+	}
+	email, group := identity.Normalize(pos[0]), pos[1]
+
+	c, log, err := a.userCommand()
+	if err != nil {
+		return err
+	}
+	if err := fn(c, ctx, email, group); err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, log, action, email, map[string]string{"group": group}); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, done, email, group)
+	return nil
+}
@@ -0,0 +1,397 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/snapshot"
+)
+
+// versionChain is the version history of a dataset.
+type versionChain struct {
+	DatasetID string `json:"datasetId"`
+
+	// ConceptDOI resolves to the current version
+	ConceptDOI string         `json:"conceptDoi,omitempty"`
+	Versions   []versionEntry `json:"versions"`
+}
+
+// versionEntry is one version in a versionChain.
+type versionEntry struct {
+	Number      int        `json:"number"`
+	DOI         string     `json:"doi,omitempty"`
+	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+	Files       int        `json:"files"`
+	Bytes       int64      `json:"bytes"`
+	Current     bool       `json:"current,omitempty"`
+
+	// Snapshot records the source copied, for versions that are
+	// snapshots of one
+	Snapshot *dataset.Snapshot `json:"snapshot,omitempty"`
+}
+
+func runDatasetVersion(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset version")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("dataset version <dataset>")
+	}
+	m, err := a.depositManager(false)
+	if err != nil {
+		return err
+	}
+	d, v, err := m.NewVersion(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Started version %d of %s with the %d files of version %d; change it, then run 'aperture dataset publish %s'\n",
+		v.Number, d.ID, len(v.Files), d.Current().Number, d.ID)
+	return nil
+}
+
+func runDatasetSnapshot(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset snapshot")
+	var opts snapshot.Options
+	fs.StringVar(&opts.Source, "source", "", "the S3 prefix to copy, s3://BUCKET/PREFIX (default the dataset's last source)")
+	fs.StringVar(&opts.Label, "label", "", `what the snapshot captures, e.g. "as submitted to Nature"`)
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("dataset snapshot <dataset> [--source s3://BUCKET/PREFIX] [--label TEXT]")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	objects, err := a.s3Client()
+	if err != nil {
+		return err
+	}
+	layout, err := a.layout()
+	if err != nil {
+		return err
+	}
+	log, err := a.auditLog()
+	if err != nil {
+		return err
+	}
+	m := &snapshot.Manager{Datasets: datasets, Objects: objects, Layout: layout, Log: log}
+	d, v, err := m.Take(ctx, pos[0], opts)
+	if err != nil {
+		return err
+	}
+	var bytes int64
+	unversioned := 0
+	for _, f := range v.Files {
+		bytes += f.Size
+		if f.SourceVersion == "" {
+			unversioned++
+		}
+	}
+	fmt.Fprintf(a.out, "Copied %d files (%d bytes) of %s into version %d of %s; publish it with 'aperture dataset publish %s'\n",
+		len(v.Files), bytes, v.Snapshot.Source, v.Number, d.ID, d.ID)
+	if unversioned > 0 {
+		fmt.Fprintf(a.out, "%d source objects had no S3 version ID; enable versioning on the source bucket to record exactly which versions were copied\n", unversioned)
+	}
+	return nil
+}
+
+func runDatasetVersions(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset versions")
+	asJSON := fs.Bool("json", false, "print the versions as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("dataset versions <doi|dataset> [--json]")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := datasets.Resolve(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	// Drafts are shown only to those who may publish them.
+	manager := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage) == nil
+	current := d.Current()
+	chain := versionChain{DatasetID: d.ID, ConceptDOI: d.DOI, Versions: []versionEntry{}}
+	for _, v := range d.Versions {
+		if v.PublishedAt == nil && !manager {
+			continue
+		}
+		e := versionEntry{Number: v.Number, DOI: v.DOI, PublishedAt: v.PublishedAt, Files: len(v.Files), Current: v.Number == current.Number, Snapshot: v.Snapshot}
+		for _, f := range v.Files {
+			e.Bytes += f.Size
+		}
+		chain.Versions = append(chain.Versions, e)
+	}
+	slices.SortFunc(chain.Versions, func(x, y versionEntry) int { return cmp.Compare(x.Number, y.Number) })
+	if *asJSON {
+		return a.printJSON(chain)
+	}
+	if len(chain.Versions) == 0 {
+		fmt.Fprintf(a.out, "%s has no published versions\n", d.ID)
+		return nil
+	}
+	if chain.ConceptDOI != "" {
+		fmt.Fprintf(a.out, "%s: concept DOI %s resolves to version %d\n\n", d.ID, chain.ConceptDOI, current.Number)
+	}
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tPUBLISHED\tDOI\tFILES\tBYTES\t")
+	for _, e := range chain.Versions {
+		published, doi, mark := "draft", "-", ""
+		if e.PublishedAt != nil {
+			published = e.PublishedAt.Format(time.DateOnly)
+		}
+		if e.DOI != "" {
+			doi = e.DOI
+		}
+		if e.Current {
+			mark = "current"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%s\n", e.Number, published, doi, e.Files, e.Bytes, mark)
+	}
+	return tw.Flush()
+}
+
+func runDatasetDiff(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset diff")
+	asJSON := fs.Bool("json", false, "print the changes as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 2 {
+		return usageError("dataset diff <doi|dataset>@vN <doi|dataset>@vM [--json]")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	var d *dataset.Dataset
+	var versions [2]*dataset.Version
+	for i, arg := range pos {
+		ref, n, err := parseVersionRef(arg)
+		if err != nil {
+			return err
+		}
+		// The second version may name only its number.
+		if ref == "" && d != nil {
+			ref = d.ID
+		}
+		found, err := datasets.Resolve(ctx, ref)
+		if err != nil {
+			return err
+		}
+		if d != nil && found.ID != d.ID {
+			return fmt.Errorf("%s and %s are different datasets", d.ID, found.ID)
+		}
+		d = found
+		v := d.Version(n)
+		if v == nil || (v.PublishedAt == nil && authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage) != nil) {
+			return fmt.Errorf("%s has no version %d", d.ID, n)
+		}
+		versions[i] = v
+	}
+	df := dataset.DiffVersions(d, versions[0], versions[1])
+	if *asJSON {
+		return a.printJSON(df)
+	}
+	printDiff(a.out, d, versions[0], versions[1], df)
+	return nil
+}
+
+// workspaceStatus is the JSON form of 'aperture dataset status'.
+type workspaceStatus struct {
+	DatasetID string        `json:"datasetId"`
+	State     dataset.State `json:"state"`
+
+	// Current is the latest published version; 0 if none
+	Current int `json:"current,omitempty"`
+
+	// Draft is the unpublished version changes are made to; 0 if none
+	Draft int `json:"draft,omitempty"`
+
+	// StagedAt is when a metadata change was last staged
+	StagedAt *time.Time `json:"stagedAt,omitempty"`
+
+	// Changes are what publishing the draft changes; nil if the
+	// dataset was never published
+	Changes *dataset.Diff `json:"changes,omitempty"`
+}
+
+func runDatasetStatus(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dataset status")
+	asJSON := fs.Bool("json", false, "print the status as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usageError("dataset status <doi|dataset> [--json]")
+	}
+	datasets, err := a.datasets()
+	if err != nil {
+		return err
+	}
+	d, err := managedDataset(ctx, datasets, pos[0])
+	if err != nil {
+		return err
+	}
+	st := workspaceStatus{DatasetID: d.ID, State: d.State}
+	current, draft := d.Current(), d.Draft()
+	if current != nil && current.PublishedAt == nil {
+		current = nil
+	}
+	if current != nil {
+		st.Current = current.Number
+	}
+	if draft != nil {
+		st.Draft = draft.Number
+	}
+	if d.Workspace != nil {
+		st.StagedAt = &d.Workspace.UpdatedAt
+	}
+	if current != nil && draft != nil {
+		st.Changes = dataset.DiffVersions(d, current, draft)
+	}
+	if *asJSON {
+		return a.printJSON(st)
+	}
+
+	switch {
+	case current == nil && draft == nil:
+		fmt.Fprintf(a.out, "%s is %s and has no files yet\n", d.ID, d.State)
+	case current == nil:
+		var bytes int64
+		for _, f := range draft.Files {
+			bytes += f.Size
+		}
+		fmt.Fprintf(a.out, "%s has never been published; version %d has %d files (%d bytes)\n", d.ID, draft.Number, len(draft.Files), bytes)
+		fmt.Fprintf(a.out, "\nPublish it with 'aperture dataset publish %s'\n", d.ID)
+	case draft == nil:
+		fmt.Fprintf(a.out, "%s is %s at version %d with nothing staged\n", d.ID, d.State, current.Number)
+		fmt.Fprintf(a.out, "\nMetadata changes are staged for the next version; start one for file changes with 'aperture dataset version %s'\n", d.ID)
+	default:
+		fmt.Fprintf(a.out, "%s is %s at version %d; draft version %d holds the changes to publish\n\n", d.ID, d.State, current.Number, draft.Number)
+		printDiff(a.out, d, current, draft, st.Changes)
+		fmt.Fprintf(a.out, "\nPublish them as version %d with 'aperture dataset publish %s'\n", draft.Number, d.ID)
+	}
+	return nil
+}
+
+// parseVersionRef parses REF@vN, where REF is a dataset ID or one of
+// its identifiers and may be empty.
+func parseVersionRef(s string) (string, int, error) {
+	i := strings.LastIndex(s, "@")
+	if i < 0 {
+		return "", 0, fmt.Errorf("invalid version %q: want DATASET@vN, e.g. 10.1234/abc@v2", s)
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(s[i+1:]), "v"))
+	if err != nil || n < 1 {
+		return "", 0, fmt.Errorf("invalid version %q: want DATASET@vN, e.g. 10.1234/abc@v2", s)
+	}
+	return s[:i], n, nil
+}
+
+// printDiff writes df as a unified-diff-like report.
+func printDiff(w io.Writer, d *dataset.Dataset, from, to *dataset.Version, df *dataset.Diff) {
+	label := func(v *dataset.Version) string {
+		s := fmt.Sprintf("%s v%d", d.ID, v.Number)
+		switch {
+		case v.PublishedAt == nil:
+			s += " (draft)"
+		case v.DOI != "":
+			s += fmt.Sprintf(" (%s, %s)", v.DOI, v.PublishedAt.Format(time.DateOnly))
+		default:
+			s += fmt.Sprintf(" (%s)", v.PublishedAt.Format(time.DateOnly))
+		}
+		return s
+	}
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", label(from), label(to))
+	if df.Empty() && df.MetadataRecorded {
+		fmt.Fprintln(w, "No changes")
+		return
+	}
+
+	counts := map[string]int{}
+	for _, f := range df.Files {
+		counts[f.Change]++
+	}
+	fmt.Fprintf(w, "\nFiles: %d added, %d removed, %d modified, %d renamed, %d unchanged\n",
+		counts[dataset.FileAdded], counts[dataset.FileRemoved], counts[dataset.FileModified], counts[dataset.FileRenamed], df.Unchanged)
+	for _, f := range df.Files {
+		switch f.Change {
+		case dataset.FileAdded:
+			fmt.Fprintf(w, "  + %s (%d bytes, sha256 %s)\n", f.Path, f.NewSize, shortDigest(f.NewSHA256))
+		case dataset.FileRemoved:
+			fmt.Fprintf(w, "  - %s (%d bytes, sha256 %s)\n", f.Path, f.OldSize, shortDigest(f.OldSHA256))
+		case dataset.FileModified:
+			fmt.Fprintf(w, "  M %s (%d -> %d bytes, sha256 %s -> %s)\n", f.Path, f.OldSize, f.NewSize, shortDigest(f.OldSHA256), shortDigest(f.NewSHA256))
+		case dataset.FileRenamed:
+			fmt.Fprintf(w, "  R %s -> %s\n", f.From, f.Path)
+		}
+	}
+
+	if !df.MetadataRecorded {
+		fmt.Fprintln(w, "\nMetadata: not compared; it was not recorded when one of these versions was published")
+		return
+	}
+	if len(df.Metadata) == 0 {
+		fmt.Fprintln(w, "\nMetadata: unchanged")
+		return
+	}
+	fmt.Fprintln(w, "\nMetadata:")
+	for _, c := range df.Metadata {
+		fmt.Fprintf(w, "  %s:\n", c.Field)
+		for _, line := range c.Old {
+			fmt.Fprintf(w, "    - %s\n", line)
+		}
+		for _, line := range c.New {
+			fmt.Fprintf(w, "    + %s\n", line)
+		}
+	}
+}
+
+// shortDigest abbreviates a hex digest for display.
+func shortDigest(sum string) string {
+	if sum == "" {
+		return "unknown"
+	}
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+	return sum
+}
@@ -0,0 +1,255 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+)
+
+func init() {
+	register("whoami", &command{
+		usage:   "[--json] [--remote]",
+		summary: "Show who commands run as, with their roles, groups, and permissions",
+		run:     runWhoami,
+		scope:   anyScope,
+	})
+}
+
+// session describes who commands run as and what they may do.
+type session struct {
+	// User is the principal's ID
+	User string `json:"user"`
+
+	// Name and ORCID come from the stored login or configuration
+	Name  string `json:"name,omitempty"`
+	ORCID string `json:"orcid,omitempty"`
+
+	// Source is how the principal was established: "token", "login",
+	// or "config" (APERTURE_USER with APERTURE_DEV_IDENTITY)
+	Source string `json:"source"`
+
+	// TokenID is the machine token in use
+	TokenID string `json:"tokenId,omitempty"`
+
+	// Issuer is the identity provider of the stored login
+	Issuer string `json:"issuer,omitempty"`
+
+	// Expires is when the machine token or login access token expires
+	Expires *time.Time `json:"expires,omitempty"`
+
+	// Refreshable reports whether an expired login renews itself
+	Refreshable bool `json:"refreshable,omitempty"`
+
+	// Groups are the principal's group memberships, including those
+	// conferred by stored role grants
+	Groups []string `json:"groups,omitempty"`
+
+	// Roles are the roles the groups confer; Granted are those held
+	// through a stored grant rather than the identity provider
+	Roles   []authz.Role `json:"roles,omitempty"`
+	Granted []authz.Role `json:"granted,omitempty"`
+
+	// Scopes are the machine token's scopes
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Permissions are the effective permissions of the roles
+	Permissions []authz.Permission `json:"permissions,omitempty"`
+
+	// Missing are the permissions not held, with the roles that would
+	// confer them
+	Missing []missingPermission `json:"missing,omitempty"`
+}
+
+// missingPermission is a permission a session lacks.
+type missingPermission struct {
+	Permission authz.Permission `json:"permission"`
+	Roles      []authz.Role     `json:"roles"`
+}
+
+// whoami describes the session of the principal in ctx.
+func (a *app) whoami(ctx context.Context) (*session, error) {
+	p := identity.FromContext(ctx)
+	s := &session{
+		User:        p.ID,
+		ORCID:       p.ORCID,
+		Source:      "config",
+		Groups:      p.Groups,
+		Roles:       authz.RolesOf(p),
+		Scopes:      p.Scopes,
+		Permissions: authz.PermissionsOf(p),
+	}
+	for _, perm := range authz.Permissions {
+		if !authz.Can(p, perm) {
+			s.Missing = append(s.Missing, missingPermission{Permission: perm, Roles: authz.Holders(perm)})
+		}
+	}
+	if p.IsZero() {
+		return s, nil
+	}
+
+	switch {
+	case a.cfg.Token != "":
+		tokens, err := a.machineTokens()
+		if err != nil {
+			return nil, err
+		}
+		t, err := tokens.Authenticate(ctx, a.cfg.Token)
+		if err != nil {
+			return nil, fmt.Errorf("APERTURE_TOKEN: %w", err)
+		}
+		s.Source, s.TokenID, s.Expires = "token", t.ID, &t.Expires
+	case !a.cfg.DevIdentity:
+		creds, err := a.credentialStore().Load()
+		if err == nil && identity.Normalize(creds.Claims.Email) == p.ID {
+			s.Source, s.Issuer, s.Name = "login", creds.Issuer, creds.Claims.Name
+			s.Expires, s.Refreshable = &creds.Token.Expiry, creds.Token.RefreshToken != ""
+		}
+	}
+
+	grants, err := a.roleGrants()
+	if err != nil {
+		return nil, err
+	}
+	if s.Granted, err = grants.Roles(ctx, p.ID); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func runWhoami(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("whoami")
+	asJSON := fs.Bool("json", false, "print the session as JSON")
+	remote := fs.Bool("remote", false, "ask the API who the stored login belongs to")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	s, err := a.whoami(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON && !*remote {
+		return a.printJSON(s)
+	}
+	if !*asJSON {
+		printSession(a.out, s)
+	}
+	if !*remote {
+		return nil
+	}
+
+	if a.cfg.APIURL == "" {
+		return fmt.Errorf("APERTURE_API_URL must be set to use --remote")
+	}
+	hc, err := a.apiClient(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(a.cfg.APIURL, "/")+"/auth/verify", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the API: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API rejected the login: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if *asJSON {
+		return a.printJSON(struct {
+			*session
+			API string `json:"api"`
+		}{s, strings.TrimSpace(string(body))})
+	}
+	fmt.Fprintf(a.out, "API:         %s\n", strings.TrimSpace(string(body)))
+	return nil
+}
+
+// printSession writes s for a person reading a support ticket.
+func printSession(w io.Writer, s *session) {
+	if s.User == "" {
+		fmt.Fprintln(w, "Not signed in: run 'aperture login'")
+		return
+	}
+	user := s.User
+	if s.Name != "" {
+		user += " (" + s.Name + ")"
+	}
+	fmt.Fprintf(w, "user:        %s\n", user)
+	if s.ORCID != "" {
+		fmt.Fprintf(w, "orcid:       %s\n", s.ORCID)
+	}
+
+	switch s.Source {
+	case "token":
+		fmt.Fprintf(w, "signed in:   machine token %s, expires %s\n", s.TokenID, s.Expires.Format(time.RFC3339))
+	case "login":
+		renew := "sign in again when it does"
+		if s.Refreshable {
+			renew = "renewed automatically"
+		}
+		state := "expires"
+		if s.Expires.Before(time.Now()) {
+			state = "expired"
+		}
+		fmt.Fprintf(w, "signed in:   login with %s, access token %s %s (%s)\n", s.Issuer, state, s.Expires.Format(time.RFC3339), renew)
+	default:
+		fmt.Fprintln(w, "signed in:   APERTURE_USER (development identity, not verified)")
+	}
+
+	fmt.Fprintf(w, "groups:      %s\n", orNone(s.Groups))
+	roles := make([]string, len(s.Roles))
+	for i, r := range s.Roles {
+		roles[i] = string(r)
+		if slices.Contains(s.Granted, r) {
+			roles[i] += " (granted)"
+		}
+	}
+	fmt.Fprintf(w, "roles:       %s\n", orNone(roles))
+	if len(s.Scopes) > 0 {
+		fmt.Fprintf(w, "scopes:      %s\n", strings.Join(s.Scopes, ", "))
+	}
+	perms := make([]string, len(s.Permissions))
+	for i, perm := range s.Permissions {
+		perms[i] = string(perm)
+	}
+	fmt.Fprintf(w, "permissions: %s\n", orNone(perms))
+	if len(s.Missing) > 0 {
+		fmt.Fprintln(w, "missing:")
+	}
+	for _, m := range s.Missing {
+		fmt.Fprintf(w, "  %-12s  held by %s\n", m.Permission, strings.ReplaceAll(joinRoles(m.Roles), ", ", " or "))
+	}
+}
+
+// orNone joins values, or returns "none".
+func orNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,181 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/counter"
+	"github.com/scttfrdmn/aperture/internal/notify"
+	"github.com/scttfrdmn/aperture/internal/queue"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+const workerUsage = "worker [--topic TOPIC]... [--once] [--log-format cloudfront|s3] [--json]"
+
+func init() {
+	register("worker", &command{
+		usage:      strings.TrimPrefix(workerUsage, "worker "),
+		summary:    "Index datasets, deliver notifications, and ingest access logs queued for background processing",
+		run:        runWorker,
+		scope:      token.ScopeDatasetsWrite,
+		permission: authz.PermMaintain,
+	})
+}
+
+// workerIdle is how long a worker on a local queue waits between
+// passes that found nothing to do; SQS queues are long-polled instead.
+const workerIdle = 5 * time.Second
+
+// workerPoll is how long a receive from an SQS queue waits for a
+// message.
+const workerPoll = 20 * time.Second
+
+func runWorker(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("worker")
+	var topics stringsFlag
+	fs.Var(&topics, "topic", "handle only `TOPIC`: index, notify, or ingest (repeatable; default all)")
+	once := fs.Bool("once", false, "handle the messages waiting and exit")
+	logFormat := fs.String("log-format", counter.FormatCloudFront, "format of the access logs in the ingest queue: cloudfront or s3")
+	asJSON := fs.Bool("json", false, "print what --once handled as JSON")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return usageError(workerUsage)
+	}
+	for _, topic := range topics {
+		if !slices.Contains(queue.Topics, topic) {
+			return fmt.Errorf("unknown topic %q (want %s)", topic, strings.Join(queue.Topics, ", "))
+		}
+	}
+	if len(topics) == 0 {
+		topics = queue.Topics
+	}
+	q, err := a.queue()
+	if err != nil {
+		return err
+	}
+	if q == nil {
+		return fmt.Errorf("no queue is configured; set APERTURE_QUEUE_BACKEND to sqs or local")
+	}
+
+	handlers, err := a.workerHandlers(ctx, topics, *logFormat)
+	if err != nil {
+		return err
+	}
+	w := &queue.Worker{
+		Queue:    q,
+		Handlers: handlers,
+		Metrics:  a.recorder(),
+		OnError:  func(err error) { fmt.Fprintf(os.Stderr, "Warning: %v\n", err) },
+	}
+	if *once {
+		res, err := w.Drain(ctx)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return a.printJSON(res)
+		}
+		fmt.Fprintf(a.out, "Handled %d messages; %d failed, %d of them moved to the dead-letter queue\n", res.Handled, res.Failed, res.DeadLettered)
+		return nil
+	}
+
+	idle := workerIdle
+	if sq, ok := q.(*queue.SQS); ok {
+		sq.Wait, idle = workerPoll, 0
+	}
+	fmt.Fprintf(a.out, "Handling %s messages from the %s queues\n", strings.Join(slices.Sorted(maps.Keys(handlers)), ", "), a.cfg.QueueBackend)
+	return w.Run(ctx, idle)
+}
+
+// workerHandlers returns the handlers of topics. The index topic is
+// skipped without a search domain to index.
+func (a *app) workerHandlers(ctx context.Context, topics []string, logFormat string) (map[string]queue.Handler, error) {
+	handlers := make(map[string]queue.Handler)
+	for _, topic := range topics {
+		switch topic {
+		case queue.TopicIndex:
+			if a.cfg.OpenSearchURL == "" {
+				continue
+			}
+			s, err := a.store()
+			if err != nil {
+				return nil, err
+			}
+			datasets, err := a.datasets()
+			if err != nil {
+				return nil, err
+			}
+			handlers[topic] = a.indexer(s).Handler(datasets)
+		case queue.TopicNotify:
+			n, err := a.deliverer()
+			if err != nil {
+				return nil, err
+			}
+			handlers[topic] = notify.Handler(n)
+		case queue.TopicIngest:
+			robots, err := a.robotFilter(ctx, "")
+			if err != nil {
+				return nil, err
+			}
+			s, err := a.store()
+			if err != nil {
+				return nil, err
+			}
+			datasets, err := a.datasets()
+			if err != nil {
+				return nil, err
+			}
+			log, err := a.downloadLog()
+			if err != nil {
+				return nil, err
+			}
+			in := &counter.Ingester{Datasets: datasets, State: s, Log: log, Filter: robots}
+			handlers[topic] = in.Handler(&logReader{a: a}, logFormat)
+		}
+	}
+	return handlers, nil
+}
+
+// logReader reads access logs with an S3 client made on first use, so
+// that a worker without AWS credentials still handles the other topics.
+type logReader struct {
+	a      *app
+	once   sync.Once
+	client *s3.Client
+	err    error
+}
+
+// GetObject implements counter.LogReader.
+func (r *logReader) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	r.once.Do(func() { r.client, r.err = r.a.s3Client() })
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.client.GetObject(ctx, bucket, key)
+}
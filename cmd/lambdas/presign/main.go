@@ -0,0 +1,25 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command presign is the Lambda function serving the API's dataset and
+// download routes: /datasets, /access, /agreements, and /presign,
+// which checks a request against the dataset's access policy and
+// answers with a time-limited download URL.
+package main
+
+import "github.com/scttfrdmn/aperture/internal/backend"
+
+func main() {
+	backend.Main("presign", (*backend.Backend).Presign)
+}
@@ -1,6 +1,39 @@
 import axios, { AxiosInstance, AxiosRequestConfig } from 'axios';
 import { config } from '../config';
 
+// Tokens are the Cognito tokens /auth/login and /auth/refresh return.
+export interface Tokens {
+  idToken: string;
+  accessToken: string;
+  refreshToken?: string;
+  expiresIn: number;
+}
+
+// FileRequest asks for one file of a dataset; the current version if
+// version is omitted.
+export interface FileRequest {
+  dataset: string;
+  file: string;
+  version?: number;
+  email?: string;
+}
+
+// Grant is a signed download URL /presign issues.
+export interface Grant {
+  datasetId: string;
+  version: number;
+  file: string;
+  url: string;
+  expires: string;
+}
+
+// Identifier is a dataset's identifier as /doi/ registered it.
+export interface Identifier {
+  dataset: string;
+  identifier: string;
+  warning?: string;
+}
+
 class ApiService {
   private client: AxiosInstance;
 
@@ -13,10 +46,11 @@ class ApiService {
       }
     });
 
-    // Request interceptor to add auth token
+    // Request interceptor to add auth token. The API identifies users by
+    // the email claim of the ID token.
     this.client.interceptors.request.use(
       (config) => {
-        const token = localStorage.getItem('accessToken');
+        const token = localStorage.getItem('idToken');
         if (token) {
           config.headers.Authorization = `Bearer ${token}`;
         }
@@ -33,7 +67,7 @@ class ApiService {
       (error) => {
         if (error.response?.status === 401) {
           // Token expired or invalid
-          localStorage.removeItem('accessToken');
+          this.clearTokens();
           window.location.href = '/login';
         }
         return Promise.reject(error);
@@ -41,50 +75,71 @@ class ApiService {
     );
   }
 
+  private storeTokens(tokens: Tokens) {
+    localStorage.setItem('idToken', tokens.idToken);
+    localStorage.setItem('accessToken', tokens.accessToken);
+    if (tokens.refreshToken) {
+      localStorage.setItem('refreshToken', tokens.refreshToken);
+    }
+  }
+
+  private clearTokens() {
+    localStorage.removeItem('idToken');
+    localStorage.removeItem('accessToken');
+    localStorage.removeItem('refreshToken');
+  }
+
   // Authentication endpoints
-  async login(username: string, password: string) {
-    const response = await this.client.post('/auth/login', { username, password });
+  async login(username: string, password: string): Promise<Tokens> {
+    const response = await this.client.post<Tokens>('/auth/login', { username, password });
+    this.storeTokens(response.data);
     return response.data;
   }
 
-  async logout() {
-    const response = await this.client.post('/auth/logout');
+  async refresh(): Promise<Tokens> {
+    const refreshToken = localStorage.getItem('refreshToken');
+    const response = await this.client.post<Tokens>('/auth/refresh', { refreshToken });
+    this.storeTokens(response.data);
     return response.data;
   }
 
+  async logout() {
+    try {
+      await this.client.post('/auth/logout');
+    } finally {
+      this.clearTokens();
+    }
+  }
+
   async verifyToken() {
     const response = await this.client.get('/auth/verify');
     return response.data;
   }
 
   // Presigned URLs
-  async generatePresignedUrl(bucket: string, key: string, expiration?: number) {
-    const response = await this.client.post('/urls/generate', {
-      bucket,
-      key,
-      expiration: expiration || 3600
-    });
+  async presign(request: FileRequest): Promise<Grant> {
+    const response = await this.client.post<Grant>('/presign', request);
     return response.data;
   }
 
-  async generateBatchPresignedUrls(keys: Array<{ bucket: string; key: string }>) {
-    const response = await this.client.post('/urls/batch', { keys });
+  async checkAccess(request: FileRequest) {
+    const response = await this.client.post('/access', request);
     return response.data;
   }
 
-  // DOI Management
-  async mintDoi(metadata: any) {
-    const response = await this.client.post('/doi/mint', metadata);
+  async acceptAgreement(dataset: string, name: string, email?: string) {
+    const response = await this.client.post('/agreements', { dataset, name, email });
     return response.data;
   }
 
-  async updateDoi(id: string, metadata: any) {
-    const response = await this.client.put(`/doi/${id}`, metadata);
+  // DOI Management
+  async mintDoi(dataset: string): Promise<Identifier> {
+    const response = await this.client.post<Identifier>('/doi/mint', { dataset });
     return response.data;
   }
 
-  async deleteDoi(id: string) {
-    const response = await this.client.delete(`/doi/${id}`);
+  async updateDoi(dataset: string): Promise<Identifier> {
+    const response = await this.client.put<Identifier>(`/doi/${encodeURIComponent(dataset)}`);
     return response.data;
   }
 
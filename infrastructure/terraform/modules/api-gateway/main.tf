@@ -50,7 +50,7 @@ resource "aws_apigatewayv2_authorizer" "cognito" {
   name             = "${var.project_name}-${var.environment}-cognito-authorizer"
 
   jwt_configuration {
-    audience = [var.cognito_app_client_id]
+    audience = compact([var.cognito_app_client_id, var.cognito_cli_client_id])
     issuer   = "https://cognito-idp.${data.aws_region.current.name}.amazonaws.com/${var.cognito_user_pool_id}"
   }
 }
@@ -86,6 +86,15 @@ resource "aws_apigatewayv2_integration" "doi_minting" {
   payload_format_version = "2.0"
 }
 
+# Search Lambda Integration
+resource "aws_apigatewayv2_integration" "search" {
+  api_id                 = aws_apigatewayv2_api.main.id
+  integration_type       = "AWS_PROXY"
+  integration_uri        = var.search_lambda_invoke_arn
+  integration_method     = "POST"
+  payload_format_version = "2.0"
+}
+
 # Bedrock Analysis Lambda Integration
 resource "aws_apigatewayv2_integration" "bedrock_analysis" {
   api_id                 = aws_apigatewayv2_api.main.id
@@ -140,21 +149,45 @@ resource "aws_apigatewayv2_route" "auth_verify" {
   authorizer_id      = aws_apigatewayv2_authorizer.cognito.id
 }
 
+#############################################
+# Routes - Datasets (Public)
+#############################################
+
+resource "aws_apigatewayv2_route" "datasets_list" {
+  api_id    = aws_apigatewayv2_api.main.id
+  route_key = "GET /datasets"
+  target    = "integrations/${aws_apigatewayv2_integration.presigned_urls.id}"
+}
+
+resource "aws_apigatewayv2_route" "datasets_get" {
+  api_id    = aws_apigatewayv2_api.main.id
+  route_key = "GET /datasets/{ref+}"
+  target    = "integrations/${aws_apigatewayv2_integration.presigned_urls.id}"
+}
+
 #############################################
 # Routes - Presigned URLs (Protected)
 #############################################
 
-resource "aws_apigatewayv2_route" "urls_generate" {
+resource "aws_apigatewayv2_route" "access" {
+  api_id             = aws_apigatewayv2_api.main.id
+  route_key          = "POST /access"
+  target             = "integrations/${aws_apigatewayv2_integration.presigned_urls.id}"
+  authorization_type = "JWT"
+  authorizer_id      = aws_apigatewayv2_authorizer.cognito.id
+}
+
+resource "aws_apigatewayv2_route" "presign" {
   api_id             = aws_apigatewayv2_api.main.id
-  route_key          = "POST /urls/generate"
+  route_key          = "POST /presign"
   target             = "integrations/${aws_apigatewayv2_integration.presigned_urls.id}"
   authorization_type = "JWT"
   authorizer_id      = aws_apigatewayv2_authorizer.cognito.id
 }
 
-resource "aws_apigatewayv2_route" "urls_batch" {
+resource "aws_apigatewayv2_route" "agreements" {
   api_id             = aws_apigatewayv2_api.main.id
-  route_key          = "POST /urls/batch"
+  route_key          = "POST /agreements"
   target             = "integrations/${aws_apigatewayv2_integration.presigned_urls.id}"
   authorization_type = "JWT"
   authorizer_id      = aws_apigatewayv2_authorizer.cognito.id
@@ -174,18 +207,32 @@ resource "aws_apigatewayv2_route" "doi_mint" {
 
 resource "aws_apigatewayv2_route" "doi_update" {
   api_id             = aws_apigatewayv2_api.main.id
-  route_key          = "PUT /doi/{id}"
+  route_key          = "PUT /doi/{ref+}"
   target             = "integrations/${aws_apigatewayv2_integration.doi_minting.id}"
   authorization_type = "JWT"
   authorizer_id      = aws_apigatewayv2_authorizer.cognito.id
 }
 
-resource "aws_apigatewayv2_route" "doi_delete" {
-  api_id             = aws_apigatewayv2_api.main.id
-  route_key          = "DELETE /doi/{id}"
-  target             = "integrations/${aws_apigatewayv2_integration.doi_minting.id}"
-  authorization_type = "JWT"
-  authorizer_id      = aws_apigatewayv2_authorizer.cognito.id
+#############################################
+# Routes - Search (Public)
+#############################################
+
+resource "aws_apigatewayv2_route" "search" {
+  api_id    = aws_apigatewayv2_api.main.id
+  route_key = "GET /search"
+  target    = "integrations/${aws_apigatewayv2_integration.search.id}"
+}
+
+resource "aws_apigatewayv2_route" "suggest" {
+  api_id    = aws_apigatewayv2_api.main.id
+  route_key = "GET /suggest"
+  target    = "integrations/${aws_apigatewayv2_integration.search.id}"
+}
+
+resource "aws_apigatewayv2_route" "related" {
+  api_id    = aws_apigatewayv2_api.main.id
+  route_key = "GET /related/{ref+}"
+  target    = "integrations/${aws_apigatewayv2_integration.search.id}"
 }
 
 #############################################
@@ -351,6 +398,7 @@ resource "aws_lambda_permission" "api_gateway_auth" {
   statement_id  = "AllowAPIGatewayInvoke"
   action        = "lambda:InvokeFunction"
   function_name = var.auth_lambda_name
+  qualifier     = var.lambda_alias_name
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${aws_apigatewayv2_api.main.execution_arn}/*/*"
 }
@@ -360,6 +408,7 @@ resource "aws_lambda_permission" "api_gateway_presigned_urls" {
   statement_id  = "AllowAPIGatewayInvoke"
   action        = "lambda:InvokeFunction"
   function_name = var.presigned_urls_lambda_name
+  qualifier     = var.lambda_alias_name
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${aws_apigatewayv2_api.main.execution_arn}/*/*"
 }
@@ -369,6 +418,17 @@ resource "aws_lambda_permission" "api_gateway_doi_minting" {
   statement_id  = "AllowAPIGatewayInvoke"
   action        = "lambda:InvokeFunction"
   function_name = var.doi_minting_lambda_name
+  qualifier     = var.lambda_alias_name
+  principal     = "apigateway.amazonaws.com"
+  source_arn    = "${aws_apigatewayv2_api.main.execution_arn}/*/*"
+}
+
+# Search Lambda Permission
+resource "aws_lambda_permission" "api_gateway_search" {
+  statement_id  = "AllowAPIGatewayInvoke"
+  action        = "lambda:InvokeFunction"
+  function_name = var.search_lambda_name
+  qualifier     = var.lambda_alias_name
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${aws_apigatewayv2_api.main.execution_arn}/*/*"
 }
@@ -378,6 +438,7 @@ resource "aws_lambda_permission" "api_gateway_bedrock_analysis" {
   statement_id  = "AllowAPIGatewayInvoke"
   action        = "lambda:InvokeFunction"
   function_name = var.bedrock_analysis_lambda_name
+  qualifier     = var.lambda_alias_name
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${aws_apigatewayv2_api.main.execution_arn}/*/*"
 }
@@ -387,6 +448,7 @@ resource "aws_lambda_permission" "api_gateway_rag_knowledge_base" {
   statement_id  = "AllowAPIGatewayInvoke"
   action        = "lambda:InvokeFunction"
   function_name = var.rag_knowledge_base_lambda_name
+  qualifier     = var.lambda_alias_name
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${aws_apigatewayv2_api.main.execution_arn}/*/*"
 }
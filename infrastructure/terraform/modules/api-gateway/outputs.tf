@@ -100,14 +100,23 @@ output "routes" {
     auth_logout = "POST /auth/logout"
     auth_verify = "GET /auth/verify"
 
+    # Dataset routes (public)
+    datasets_list = "GET /datasets"
+    datasets_get  = "GET /datasets/{ref+}"
+
     # Presigned URLs routes (protected)
-    urls_generate = "POST /urls/generate"
-    urls_batch    = "POST /urls/batch"
+    access     = "POST /access"
+    presign    = "POST /presign"
+    agreements = "POST /agreements"
 
     # DOI management routes (protected)
     doi_mint   = "POST /doi/mint"
-    doi_update = "PUT /doi/{id}"
-    doi_delete = "DELETE /doi/{id}"
+    doi_update = "PUT /doi/{ref+}"
+
+    # Search routes (public)
+    search  = "GET /search"
+    suggest = "GET /suggest"
+    related = "GET /related/{ref+}"
 
     # AI analysis routes (protected)
     ai_analyze_image        = "POST /ai/analyze-image"
@@ -137,8 +146,8 @@ output "summary" {
     api_endpoint     = aws_apigatewayv2_api.main.api_endpoint
     stage_invoke_url = aws_apigatewayv2_stage.main.invoke_url
     stage_name       = aws_apigatewayv2_stage.main.name
-    total_routes     = 20
-    public_routes    = 2
+    total_routes     = 25
+    public_routes    = 7
     protected_routes = 18
     environment      = var.environment
     cors_enabled     = length(var.cors_allowed_origins) > 0
@@ -33,10 +33,22 @@ variable "cognito_app_client_id" {
   type        = string
 }
 
+variable "cognito_cli_client_id" {
+  description = "Cognito client ID used by 'aperture login', also accepted as JWT audience"
+  type        = string
+  default     = null
+}
+
 #############################################
 # Lambda Configuration
 #############################################
 
+variable "lambda_alias_name" {
+  description = "Alias of the Lambda functions the integrations invoke"
+  type        = string
+  default     = "live"
+}
+
 variable "auth_lambda_name" {
   description = "Name of the auth Lambda function"
   type        = string
@@ -48,7 +60,7 @@ variable "auth_lambda_arn" {
 }
 
 variable "auth_lambda_invoke_arn" {
-  description = "Invoke ARN of the auth Lambda function"
+  description = "Invoke ARN of the live alias of the auth Lambda function"
   type        = string
 }
 
@@ -63,7 +75,7 @@ variable "presigned_urls_lambda_arn" {
 }
 
 variable "presigned_urls_lambda_invoke_arn" {
-  description = "Invoke ARN of the presigned URLs Lambda function"
+  description = "Invoke ARN of the live alias of the presigned URLs Lambda function"
   type        = string
 }
 
@@ -78,7 +90,22 @@ variable "doi_minting_lambda_arn" {
 }
 
 variable "doi_minting_lambda_invoke_arn" {
-  description = "Invoke ARN of the DOI minting Lambda function"
+  description = "Invoke ARN of the live alias of the DOI minting Lambda function"
+  type        = string
+}
+
+variable "search_lambda_name" {
+  description = "Name of the search Lambda function"
+  type        = string
+}
+
+variable "search_lambda_arn" {
+  description = "ARN of the search Lambda function"
+  type        = string
+}
+
+variable "search_lambda_invoke_arn" {
+  description = "Invoke ARN of the live alias of the search Lambda function"
   type        = string
 }
 
@@ -93,7 +120,7 @@ variable "bedrock_analysis_lambda_arn" {
 }
 
 variable "bedrock_analysis_lambda_invoke_arn" {
-  description = "Invoke ARN of the Bedrock analysis Lambda function"
+  description = "Invoke ARN of the live alias of the Bedrock analysis Lambda function"
   type        = string
 }
 
@@ -108,7 +135,7 @@ variable "rag_knowledge_base_lambda_arn" {
 }
 
 variable "rag_knowledge_base_lambda_invoke_arn" {
-  description = "Invoke ARN of the RAG knowledge base Lambda function"
+  description = "Invoke ARN of the live alias of the RAG knowledge base Lambda function"
   type        = string
 }
 
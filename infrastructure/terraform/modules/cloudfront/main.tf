@@ -6,6 +6,9 @@ terraform {
     aws = {
       source  = "hashicorp/aws"
       version = "~> 5.0"
+
+      # The buckets may live in another account than the distributions
+      configuration_aliases = [aws.storage]
     }
   }
 }
@@ -250,6 +253,22 @@ resource "aws_cloudfront_distribution" "public_media" {
 # CloudFront Distribution for Frontend
 #############################################
 
+# FAIR Signposting: point landing pages at their linkset. The target is
+# relative, so it resolves to the linkset.json published next to each
+# page. Matches `aperture pages signposting-policy`.
+resource "aws_cloudfront_response_headers_policy" "signposting" {
+  name    = "${var.project_name}-${var.environment}-signposting"
+  comment = "FAIR Signposting linkset of landing pages"
+
+  custom_headers_config {
+    items {
+      header   = "Link"
+      value    = "<linkset.json>; rel=\"linkset\"; type=\"application/linkset+json\""
+      override = false
+    }
+  }
+}
+
 resource "aws_cloudfront_distribution" "frontend" {
   enabled             = true
   is_ipv6_enabled     = var.enable_ipv6
@@ -290,6 +309,29 @@ resource "aws_cloudfront_distribution" "frontend" {
     max_ttl     = 86400 # 1 day
   }
 
+  # Cache behavior for dataset landing pages, with Signposting headers
+  ordered_cache_behavior {
+    path_pattern               = "/datasets/*"
+    target_origin_id           = "S3-${var.frontend_bucket_id}"
+    viewer_protocol_policy     = "redirect-to-https"
+    allowed_methods            = ["GET", "HEAD", "OPTIONS"]
+    cached_methods             = ["GET", "HEAD", "OPTIONS"]
+    compress                   = true
+    response_headers_policy_id = aws_cloudfront_response_headers_policy.signposting.id
+
+    forwarded_values {
+      query_string = false
+
+      cookies {
+        forward = "none"
+      }
+    }
+
+    min_ttl     = 0
+    default_ttl = 3600  # 1 hour
+    max_ttl     = 86400 # 1 day
+  }
+
   # Cache behavior for static assets (JS, CSS, fonts)
   ordered_cache_behavior {
     path_pattern           = "/static/*"
@@ -384,11 +426,15 @@ resource "aws_cloudfront_distribution" "frontend" {
 
 # Allow CloudFront to access public media bucket
 resource "aws_s3_bucket_policy" "public_media_cloudfront" {
+  provider = aws.storage
+
   bucket = var.public_media_bucket_id
 
+  # The bucket takes a single policy, so access for the Lambda
+  # functions of another account is granted here too
   policy = jsonencode({
     Version = "2012-10-17"
-    Statement = [
+    Statement = concat([
       {
         Sid    = "AllowCloudFrontServicePrincipal"
         Effect = "Allow"
@@ -403,12 +449,24 @@ resource "aws_s3_bucket_policy" "public_media_cloudfront" {
           }
         }
       }
-    ]
+      ], length(var.bucket_client_account_ids) == 0 ? [] : [
+      {
+        Sid    = "AllowClientAccounts"
+        Effect = "Allow"
+        Principal = {
+          AWS = [for id in var.bucket_client_account_ids : "arn:aws:iam::${id}:root"]
+        }
+        Action   = ["s3:GetObject", "s3:PutObject", "s3:DeleteObject"]
+        Resource = "${var.public_media_bucket_arn}/*"
+      }
+    ])
   })
 }
 
 # Allow CloudFront to access frontend bucket
 resource "aws_s3_bucket_policy" "frontend_cloudfront" {
+  provider = aws.storage
+
   bucket = var.frontend_bucket_id
 
   policy = jsonencode({
@@ -165,6 +165,12 @@ variable "geo_restriction_locations" {
   default     = []
 }
 
+variable "bucket_client_account_ids" {
+  description = "Accounts other than the buckets' whose Lambda functions read and write the public media bucket"
+  type        = list(string)
+  default     = []
+}
+
 #############################################
 # Tags
 #############################################
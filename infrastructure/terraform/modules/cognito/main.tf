@@ -127,6 +127,33 @@ resource "aws_cognito_user_pool" "main" {
     }
   }
 
+  # Institutional attributes asserted by SAML identity providers
+  schema {
+    name                     = "eppn"
+    attribute_data_type      = "String"
+    required                 = false
+    mutable                  = true
+    developer_only_attribute = false
+
+    string_attribute_constraints {
+      min_length = 1
+      max_length = 256
+    }
+  }
+
+  schema {
+    name                     = "affiliation"
+    attribute_data_type      = "String"
+    required                 = false
+    mutable                  = true
+    developer_only_attribute = false
+
+    string_attribute_constraints {
+      min_length = 1
+      max_length = 2048
+    }
+  }
+
   # Account takeover prevention
   user_pool_add_ons {
     advanced_security_mode = var.advanced_security_mode
@@ -194,6 +221,39 @@ resource "aws_cognito_identity_provider" "orcid" {
   }
 }
 
+# Institutional SAML Identity Provider (Shibboleth / InCommon)
+resource "aws_cognito_identity_provider" "saml" {
+  count = var.enable_saml ? 1 : 0
+
+  user_pool_id  = aws_cognito_user_pool.main.id
+  provider_name = var.saml_provider_name
+  provider_type = "SAML"
+
+  provider_details = merge(
+    var.saml_metadata_url != "" ? { MetadataURL = var.saml_metadata_url } : { MetadataFile = var.saml_metadata_file },
+    { IDPSignout = tostring(var.saml_idp_signout) }
+  )
+
+  # Lets the hosted UI route users to their campus by email domain
+  idp_identifiers = var.saml_idp_identifiers
+
+  # eduPerson attribute OIDs (see 'aperture federation sp')
+  attribute_mapping = merge({
+    email                = "urn:oid:0.9.2342.19200300.100.1.3"
+    name                 = "urn:oid:2.16.840.1.113730.3.1.241"
+    "custom:eppn"        = "urn:oid:1.3.6.1.4.1.5923.1.1.1.6"
+    "custom:affiliation" = "urn:oid:1.3.6.1.4.1.5923.1.1.1.9"
+  }, var.saml_attribute_mapping)
+}
+
+locals {
+  identity_providers = concat(
+    ["COGNITO"],
+    var.enable_orcid ? ["ORCID"] : [],
+    var.enable_saml ? [var.saml_provider_name] : []
+  )
+}
+
 # App Client for Web Application
 resource "aws_cognito_user_pool_client" "web_app" {
   name         = "${var.project_name}-web-app-${var.environment}"
@@ -209,10 +269,15 @@ resource "aws_cognito_user_pool_client" "web_app" {
   default_redirect_uri                 = length(var.callback_urls) > 0 ? var.callback_urls[0] : null
 
   # Supported identity providers
-  supported_identity_providers = concat(
-    ["COGNITO"],
-    var.enable_orcid ? ["ORCID"] : []
-  )
+  supported_identity_providers = local.identity_providers
+
+  # The auth function signs users in with their passwords on the
+  # client's behalf
+  explicit_auth_flows = [
+    "ALLOW_ADMIN_USER_PASSWORD_AUTH",
+    "ALLOW_USER_SRP_AUTH",
+    "ALLOW_REFRESH_TOKEN_AUTH",
+  ]
 
   # Token validity
   id_token_validity      = var.id_token_validity
@@ -233,6 +298,8 @@ resource "aws_cognito_user_pool_client" "web_app" {
     "custom:orcid",
     "custom:institution",
     "custom:role",
+    "custom:eppn",
+    "custom:affiliation",
   ]
 
   write_attributes = [
@@ -251,6 +318,9 @@ resource "aws_cognito_user_pool_client" "web_app" {
 
   # Auth session validity
   auth_session_validity = 3
+
+  # Identity providers must exist before clients can offer them
+  depends_on = [aws_cognito_identity_provider.orcid, aws_cognito_identity_provider.saml]
 }
 
 # App Client for API/CLI
@@ -278,6 +348,47 @@ resource "aws_cognito_user_pool_client" "api_client" {
   enable_token_revocation       = true
 }
 
+# Public client for 'aperture login' (authorization code with PKCE)
+resource "aws_cognito_user_pool_client" "cli" {
+  count = var.create_cli_client ? 1 : 0
+
+  name         = "${var.project_name}-cli-${var.environment}"
+  user_pool_id = aws_cognito_user_pool.main.id
+
+  # No secret: the CLI runs on users' machines and uses PKCE instead
+  generate_secret                      = false
+  allowed_oauth_flows_user_pool_client = true
+  allowed_oauth_flows                  = ["code"]
+  allowed_oauth_scopes                 = ["email", "openid", "profile"]
+  callback_urls                        = var.cli_callback_urls
+
+  supported_identity_providers = local.identity_providers
+
+  id_token_validity      = var.id_token_validity
+  access_token_validity  = var.access_token_validity
+  refresh_token_validity = var.refresh_token_validity
+
+  token_validity_units {
+    id_token      = "hours"
+    access_token  = "hours"
+    refresh_token = "days"
+  }
+
+  read_attributes = [
+    "email",
+    "email_verified",
+    "name",
+    "custom:orcid",
+    "custom:eppn",
+    "custom:affiliation",
+  ]
+
+  prevent_user_existence_errors = "ENABLED"
+  enable_token_revocation       = true
+
+  depends_on = [aws_cognito_identity_provider.orcid, aws_cognito_identity_provider.saml]
+}
+
 # User Pool Groups for RBAC
 resource "aws_cognito_user_group" "admins" {
   name         = "admins"
@@ -286,6 +397,20 @@ resource "aws_cognito_user_group" "admins" {
   precedence   = 1
 }
 
+resource "aws_cognito_user_group" "stewards" {
+  name         = "stewards"
+  user_pool_id = aws_cognito_user_pool.main.id
+  description  = "Stewards who lift embargoes early and de-accession datasets"
+  precedence   = 3
+}
+
+resource "aws_cognito_user_group" "curators" {
+  name         = "curators"
+  user_pool_id = aws_cognito_user_pool.main.id
+  description  = "Curators who review, publish, and maintain datasets"
+  precedence   = 5
+}
+
 resource "aws_cognito_user_group" "researchers" {
   name         = "researchers"
   user_pool_id = aws_cognito_user_pool.main.id
@@ -55,6 +55,11 @@ output "api_client_secret" {
   sensitive   = true
 }
 
+output "cli_client_id" {
+  description = "ID of the public client used by 'aperture login' (if created)"
+  value       = try(aws_cognito_user_pool_client.cli[0].id, null)
+}
+
 # Identity Provider
 output "orcid_provider_name" {
   description = "Name of the ORCID identity provider (if enabled)"
@@ -67,6 +72,16 @@ output "admin_group_name" {
   value       = aws_cognito_user_group.admins.name
 }
 
+output "steward_group_name" {
+  description = "Name of the stewards group"
+  value       = aws_cognito_user_group.stewards.name
+}
+
+output "curator_group_name" {
+  description = "Name of the curators group"
+  value       = aws_cognito_user_group.curators.name
+}
+
 output "researcher_group_name" {
   description = "Name of the researchers group"
   value       = aws_cognito_user_group.researchers.name
@@ -109,5 +124,15 @@ output "oauth_jwks_url" {
   value       = "https://cognito-idp.${data.aws_region.current.name}.amazonaws.com/${aws_cognito_user_pool.main.id}/.well-known/jwks.json"
 }
 
+output "saml_entity_id" {
+  description = "SAML service provider entity ID to register with the institutional IdP"
+  value       = "urn:amazon:cognito:sp:${aws_cognito_user_pool.main.id}"
+}
+
+output "saml_acs_url" {
+  description = "SAML assertion consumer service URL to register with the institutional IdP"
+  value       = "https://${aws_cognito_user_pool_domain.main.domain}.auth.${data.aws_region.current.name}.amazoncognito.com/saml2/idpresponse"
+}
+
 # Data source for current AWS region
 data "aws_region" "current" {}
@@ -185,6 +185,49 @@ variable "refresh_token_validity" {
   default     = 30
 }
 
+# Institutional SAML Configuration
+variable "enable_saml" {
+  description = "Enable a SAML identity provider (Shibboleth/InCommon) for institutional sign-in"
+  type        = bool
+  default     = false
+}
+
+variable "saml_provider_name" {
+  description = "Name of the SAML identity provider shown on the hosted UI (e.g. the campus name)"
+  type        = string
+  default     = "Institution"
+}
+
+variable "saml_metadata_url" {
+  description = "URL of the IdP's SAML metadata (takes precedence over saml_metadata_file)"
+  type        = string
+  default     = ""
+}
+
+variable "saml_metadata_file" {
+  description = "IdP SAML metadata XML, e.g. file(\"idp-metadata.xml\")"
+  type        = string
+  default     = ""
+}
+
+variable "saml_idp_identifiers" {
+  description = "Email domains routed to the SAML IdP by the hosted UI"
+  type        = list(string)
+  default     = []
+}
+
+variable "saml_idp_signout" {
+  description = "Send sign-out requests to the IdP"
+  type        = bool
+  default     = false
+}
+
+variable "saml_attribute_mapping" {
+  description = "Overrides of the default eduPerson attribute mapping (user pool attribute => SAML attribute)"
+  type        = map(string)
+  default     = {}
+}
+
 # API Client Configuration
 variable "create_api_client" {
   description = "Create app client for API/CLI access"
@@ -198,6 +241,19 @@ variable "api_client_scopes" {
   default     = []
 }
 
+# CLI Client Configuration
+variable "create_cli_client" {
+  description = "Create a public app client for 'aperture login'"
+  type        = bool
+  default     = true
+}
+
+variable "cli_callback_urls" {
+  description = "Loopback callback URLs for 'aperture login'"
+  type        = list(string)
+  default     = ["http://localhost:8976/callback"]
+}
+
 # Logging
 variable "enable_cloudwatch_logs" {
   description = "Enable CloudWatch logging for Cognito events"
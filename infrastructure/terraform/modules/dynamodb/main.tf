@@ -386,6 +386,92 @@ resource "aws_dynamodb_table" "knowledge_base_embeddings" {
   )
 }
 
+# Table 6: Download Quotas
+# Tracks bytes and objects issued per user per day for restricted data
+resource "aws_dynamodb_table" "download_quotas" {
+  name           = "${var.project_name}-download-quotas-${var.environment}"
+  billing_mode   = var.billing_mode
+  read_capacity  = var.billing_mode == "PROVISIONED" ? var.quotas_read_capacity : null
+  write_capacity = var.billing_mode == "PROVISIONED" ? var.quotas_write_capacity : null
+  hash_key       = "user_id"
+  range_key      = "day"
+
+  attribute {
+    name = "user_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "day"
+    type = "S"
+  }
+
+  point_in_time_recovery {
+    enabled = var.enable_point_in_time_recovery
+  }
+
+  server_side_encryption {
+    enabled     = true
+    kms_key_arn = var.kms_key_arn
+  }
+
+  # Usage counters are only needed for the current day
+  ttl {
+    enabled        = true
+    attribute_name = "expiration_time"
+  }
+
+  tags = merge(
+    var.tags,
+    {
+      Name        = "${var.project_name}-download-quotas-${var.environment}"
+      Purpose     = "Per-user download quotas for restricted data"
+      Environment = var.environment
+    }
+  )
+}
+
+# Table 7: State
+# Aperture records (datasets, grants, agreements, DOIs) shared by the CLI
+# and the Lambda functions, one item per record keyed by record table
+# and key
+resource "aws_dynamodb_table" "state" {
+  name           = "${var.project_name}-state-${var.environment}"
+  billing_mode   = var.billing_mode
+  read_capacity  = var.billing_mode == "PROVISIONED" ? var.state_read_capacity : null
+  write_capacity = var.billing_mode == "PROVISIONED" ? var.state_write_capacity : null
+  hash_key       = "table"
+  range_key      = "key"
+
+  attribute {
+    name = "table"
+    type = "S"
+  }
+
+  attribute {
+    name = "key"
+    type = "S"
+  }
+
+  point_in_time_recovery {
+    enabled = var.enable_point_in_time_recovery
+  }
+
+  server_side_encryption {
+    enabled     = true
+    kms_key_arn = var.kms_key_arn
+  }
+
+  tags = merge(
+    var.tags,
+    {
+      Name        = "${var.project_name}-state-${var.environment}"
+      Purpose     = "Aperture records shared by the CLI and API"
+      Environment = var.environment
+    }
+  )
+}
+
 # Auto-scaling for Users table (if using PROVISIONED billing)
 resource "aws_appautoscaling_target" "users_read" {
   count              = var.billing_mode == "PROVISIONED" && var.enable_autoscaling ? 1 : 0
@@ -106,6 +106,28 @@ output "knowledge_base_embeddings_table_stream_arn" {
   value       = try(aws_dynamodb_table.knowledge_base_embeddings.stream_arn, "")
 }
 
+# Download quotas table outputs
+output "download_quotas_table_name" {
+  description = "Name of the download quotas DynamoDB table"
+  value       = aws_dynamodb_table.download_quotas.name
+}
+
+output "download_quotas_table_arn" {
+  description = "ARN of the download quotas DynamoDB table"
+  value       = aws_dynamodb_table.download_quotas.arn
+}
+
+# State table outputs
+output "state_table_name" {
+  description = "Name of the state DynamoDB table"
+  value       = aws_dynamodb_table.state.name
+}
+
+output "state_table_arn" {
+  description = "ARN of the state DynamoDB table"
+  value       = aws_dynamodb_table.state.arn
+}
+
 # Consolidated outputs
 output "all_table_names" {
   description = "List of all DynamoDB table names"
@@ -115,6 +137,8 @@ output "all_table_names" {
     aws_dynamodb_table.access_logs.name,
     aws_dynamodb_table.budget_tracking.name,
     aws_dynamodb_table.knowledge_base_embeddings.name,
+    aws_dynamodb_table.download_quotas.name,
+    aws_dynamodb_table.state.name,
   ]
 }
 
@@ -126,5 +150,7 @@ output "all_table_arns" {
     aws_dynamodb_table.access_logs.arn,
     aws_dynamodb_table.budget_tracking.arn,
     aws_dynamodb_table.knowledge_base_embeddings.arn,
+    aws_dynamodb_table.download_quotas.arn,
+    aws_dynamodb_table.state.arn,
   ]
 }
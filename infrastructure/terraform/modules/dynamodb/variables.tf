@@ -129,6 +129,32 @@ variable "embeddings_write_capacity" {
   default     = 5
 }
 
+# Download quotas table capacity settings
+variable "quotas_read_capacity" {
+  description = "Read capacity units for download quotas table (PROVISIONED mode only)"
+  type        = number
+  default     = 5
+}
+
+variable "quotas_write_capacity" {
+  description = "Write capacity units for download quotas table (PROVISIONED mode only)"
+  type        = number
+  default     = 5
+}
+
+# State table capacity settings
+variable "state_read_capacity" {
+  description = "Read capacity units for state table (PROVISIONED mode only)"
+  type        = number
+  default     = 5
+}
+
+variable "state_write_capacity" {
+  description = "Write capacity units for state table (PROVISIONED mode only)"
+  type        = number
+  default     = 5
+}
+
 variable "tags" {
   description = "Additional tags to apply to all resources"
   type        = map(string)
@@ -148,10 +148,380 @@ resource "aws_cloudwatch_event_target" "budget_report" {
   target_id = "BudgetReportLambda"
 }
 
+# Rule: Hourly embargo release
+resource "aws_cloudwatch_event_rule" "embargo_release" {
+  name                = "${var.project_name}-${var.environment}-embargo-release"
+  description         = "Release datasets whose embargo date has passed"
+  schedule_expression = var.embargo_release_schedule_expression
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${var.project_name}-${var.environment}-embargo-release"
+      Purpose = "Embargo release"
+    }
+  )
+}
+
+# Target: Embargo release Lambda (runs `aperture embargo release-due`)
+resource "aws_cloudwatch_event_target" "embargo_release" {
+  count = var.embargo_release_lambda_arn != "" ? 1 : 0
+
+  rule      = aws_cloudwatch_event_rule.embargo_release.name
+  arn       = var.embargo_release_lambda_arn
+  target_id = "EmbargoReleaseLambda"
+
+  retry_policy {
+    maximum_retry_attempts       = 2
+    maximum_event_age_in_seconds = 3600
+  }
+}
+
+# Rule: Weekly retention evaluation
+resource "aws_cloudwatch_event_rule" "retention_evaluation" {
+  name                = "${var.project_name}-${var.environment}-retention-evaluation"
+  description         = "Flag datasets whose retention period has ended for steward review"
+  schedule_expression = var.retention_evaluation_schedule_expression
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${var.project_name}-${var.environment}-retention-evaluation"
+      Purpose = "Retention review"
+    }
+  )
+}
+
+# Target: Retention evaluation Lambda (runs `aperture retention evaluate`)
+resource "aws_cloudwatch_event_target" "retention_evaluation" {
+  count = var.retention_evaluation_lambda_arn != "" ? 1 : 0
+
+  rule      = aws_cloudwatch_event_rule.retention_evaluation.name
+  arn       = var.retention_evaluation_lambda_arn
+  target_id = "RetentionEvaluationLambda"
+
+  retry_policy {
+    maximum_retry_attempts       = 2
+    maximum_event_age_in_seconds = 3600
+  }
+}
+
+# Rule: Hourly saved search alerts
+resource "aws_cloudwatch_event_rule" "search_alerts" {
+  name                = "${var.project_name}-${var.environment}-search-alerts"
+  description         = "Alert owners of saved searches to newly published matches"
+  schedule_expression = var.search_alerts_schedule_expression
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${var.project_name}-${var.environment}-search-alerts"
+      Purpose = "Saved search alerts"
+    }
+  )
+}
+
+# Target: Search alerts Lambda (runs `aperture alert run`)
+resource "aws_cloudwatch_event_target" "search_alerts" {
+  count = var.search_alerts_lambda_arn != "" ? 1 : 0
+
+  rule      = aws_cloudwatch_event_rule.search_alerts.name
+  arn       = var.search_alerts_lambda_arn
+  target_id = "SearchAlertsLambda"
+
+  retry_policy {
+    maximum_retry_attempts       = 2
+    maximum_event_age_in_seconds = 3600
+  }
+}
+
+# Rule: Daily ingest of CDN and S3 access logs into COUNTER usage events
+resource "aws_cloudwatch_event_rule" "usage_ingest" {
+  name                = "${var.project_name}-${var.environment}-usage-ingest"
+  description         = "Count dataset requests and investigations from access logs"
+  schedule_expression = var.usage_ingest_schedule_expression
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${var.project_name}-${var.environment}-usage-ingest"
+      Purpose = "COUNTER usage statistics"
+    }
+  )
+}
+
+# Target: Usage ingest Lambda (runs `aperture downloads ingest`)
+resource "aws_cloudwatch_event_target" "usage_ingest" {
+  count = var.usage_ingest_lambda_arn != "" ? 1 : 0
+
+  rule      = aws_cloudwatch_event_rule.usage_ingest.name
+  arn       = var.usage_ingest_lambda_arn
+  target_id = "UsageIngestLambda"
+
+  retry_policy {
+    maximum_retry_attempts       = 2
+    maximum_event_age_in_seconds = 3600
+  }
+}
+
+# Rule: Monthly SUSHI usage report to the DataCite usage hub
+resource "aws_cloudwatch_event_rule" "usage_report" {
+  name                = "${var.project_name}-${var.environment}-usage-report"
+  description         = "Submit last month's COUNTER usage report to DataCite"
+  schedule_expression = var.usage_report_schedule_expression
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${var.project_name}-${var.environment}-usage-report"
+      Purpose = "COUNTER usage statistics"
+    }
+  )
+}
+
+# Target: Usage report Lambda (runs `aperture downloads submit`)
+resource "aws_cloudwatch_event_target" "usage_report" {
+  count = var.usage_report_lambda_arn != "" ? 1 : 0
+
+  rule      = aws_cloudwatch_event_rule.usage_report.name
+  arn       = var.usage_report_lambda_arn
+  target_id = "UsageReportLambda"
+
+  retry_policy {
+    maximum_retry_attempts       = 2
+    maximum_event_age_in_seconds = 3600
+  }
+}
+
+# Rule: Weekly check for works citing published datasets
+resource "aws_cloudwatch_event_rule" "citation_tracking" {
+  name                = "${var.project_name}-${var.environment}-citation-tracking"
+  description         = "Find citations of dataset DOIs in DataCite Event Data and Crossref"
+  schedule_expression = var.citation_tracking_schedule_expression
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${var.project_name}-${var.environment}-citation-tracking"
+      Purpose = "Citation tracking"
+    }
+  )
+}
+
+# Target: Citation tracking Lambda (runs `aperture citations update`)
+resource "aws_cloudwatch_event_target" "citation_tracking" {
+  count = var.citation_tracking_lambda_arn != "" ? 1 : 0
+
+  rule      = aws_cloudwatch_event_rule.citation_tracking.name
+  arn       = var.citation_tracking_lambda_arn
+  target_id = "CitationTrackingLambda"
+
+  retry_policy {
+    maximum_retry_attempts       = 2
+    maximum_event_age_in_seconds = 3600
+  }
+}
+
+# Rule: Public status page refresh
+resource "aws_cloudwatch_event_rule" "status_page" {
+  name                = "${var.project_name}-${var.environment}-status-page"
+  description         = "Check service, DataCite, and job health and publish the status page"
+  schedule_expression = var.status_page_schedule_expression
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${var.project_name}-${var.environment}-status-page"
+      Purpose = "Status page"
+    }
+  )
+}
+
+# Target: Status page Lambda (runs `aperture status publish`)
+resource "aws_cloudwatch_event_target" "status_page" {
+  count = var.status_page_lambda_arn != "" ? 1 : 0
+
+  rule      = aws_cloudwatch_event_rule.status_page.name
+  arn       = var.status_page_lambda_arn
+  target_id = "StatusPageLambda"
+
+  retry_policy {
+    maximum_retry_attempts       = 0
+    maximum_event_age_in_seconds = 300
+  }
+}
+
+# Rule: Fixity verification
+resource "aws_cloudwatch_event_rule" "fixity" {
+  name                = "${var.project_name}-${var.environment}-fixity"
+  description         = "Verify stored objects against their recorded checksums"
+  schedule_expression = var.fixity_schedule_expression
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${var.project_name}-${var.environment}-fixity"
+      Purpose = "Fixity verification"
+    }
+  )
+}
+
+# Target: Fixity Lambda (runs `aperture fixity run`)
+resource "aws_cloudwatch_event_target" "fixity" {
+  count = var.fixity_lambda_arn != "" ? 1 : 0
+
+  rule      = aws_cloudwatch_event_rule.fixity.name
+  arn       = var.fixity_lambda_arn
+  target_id = "FixityLambda"
+
+  retry_policy {
+    maximum_retry_attempts       = 2
+    maximum_event_age_in_seconds = 3600
+  }
+}
+
+# Rule: Malware scanning
+resource "aws_cloudwatch_event_rule" "malware_scan" {
+  name                = "${var.project_name}-${var.environment}-malware-scan"
+  description         = "Scan uploaded files for malware before publication"
+  schedule_expression = var.malware_scan_schedule_expression
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${var.project_name}-${var.environment}-malware-scan"
+      Purpose = "Malware scanning"
+    }
+  )
+}
+
+# Target: Malware scan Lambda (runs `aperture scan run`)
+resource "aws_cloudwatch_event_target" "malware_scan" {
+  count = var.malware_scan_lambda_arn != "" ? 1 : 0
+
+  rule      = aws_cloudwatch_event_rule.malware_scan.name
+  arn       = var.malware_scan_lambda_arn
+  target_id = "MalwareScanLambda"
+
+  retry_policy {
+    maximum_retry_attempts       = 0
+    maximum_event_age_in_seconds = 600
+  }
+}
+
+# Rule: Offsite preservation copies
+resource "aws_cloudwatch_event_rule" "replica" {
+  name                = "${var.project_name}-${var.environment}-replica"
+  description         = "Copy published datasets to the independent preservation provider"
+  schedule_expression = var.replica_schedule_expression
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${var.project_name}-${var.environment}-replica"
+      Purpose = "Offsite preservation copies"
+    }
+  )
+}
+
+# Target: Replica Lambda (runs `aperture replica run`)
+resource "aws_cloudwatch_event_target" "replica" {
+  count = var.replica_lambda_arn != "" ? 1 : 0
+
+  rule      = aws_cloudwatch_event_rule.replica.name
+  arn       = var.replica_lambda_arn
+  target_id = "ReplicaLambda"
+
+  retry_policy {
+    maximum_retry_attempts       = 0
+    maximum_event_age_in_seconds = 3600
+  }
+}
+
+# Rule: Audit log anchoring
+resource "aws_cloudwatch_event_rule" "audit_anchor" {
+  name                = "${var.project_name}-${var.environment}-audit-anchor"
+  description         = "Anchor the head of the audit log's hash chain in the Object Lock bucket"
+  schedule_expression = var.audit_anchor_schedule_expression
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${var.project_name}-${var.environment}-audit-anchor"
+      Purpose = "Audit log anchoring"
+    }
+  )
+}
+
+# Target: Audit anchor Lambda (runs `aperture audit anchor`)
+resource "aws_cloudwatch_event_target" "audit_anchor" {
+  count = var.audit_anchor_lambda_arn != "" ? 1 : 0
+
+  rule      = aws_cloudwatch_event_rule.audit_anchor.name
+  arn       = var.audit_anchor_lambda_arn
+  target_id = "AuditAnchorLambda"
+
+  retry_policy {
+    maximum_retry_attempts       = 2
+    maximum_event_age_in_seconds = 3600
+  }
+}
+
+# Rule: Version pruning
+resource "aws_cloudwatch_event_rule" "version_prune" {
+  name                = "${var.project_name}-${var.environment}-version-prune"
+  description         = "Archive or delete old dataset versions under their collections' version policies"
+  schedule_expression = var.version_prune_schedule_expression
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${var.project_name}-${var.environment}-version-prune"
+      Purpose = "Version retention"
+    }
+  )
+}
+
+# Target: Version prune Lambda (runs `aperture collection prune`)
+resource "aws_cloudwatch_event_target" "version_prune" {
+  count = var.version_prune_lambda_arn != "" ? 1 : 0
+
+  rule      = aws_cloudwatch_event_rule.version_prune.name
+  arn       = var.version_prune_lambda_arn
+  target_id = "VersionPruneLambda"
+
+  retry_policy {
+    maximum_retry_attempts       = 0
+    maximum_event_age_in_seconds = 3600
+  }
+}
+
 #############################################
 # CloudWatch Alarms to EventBridge
 #############################################
 
+# Alarm: Offsite copies lagging. `aperture replica run` records how
+# long the oldest published dataset without a complete copy has
+# waited; missing data means the job has stopped running. The alarm's
+# name matches the high error rate rule below, which routes it to SNS.
+resource "aws_cloudwatch_metric_alarm" "replica_lag" {
+  count = var.replica_lambda_arn != "" ? 1 : 0
+
+  alarm_name          = "${var.project_name}-${var.environment}-replica-lag"
+  alarm_description   = "A published dataset has waited over ${var.replica_max_lag_hours} hours for its offsite copy"
+  namespace           = "Aperture"
+  metric_name         = "QueueAge"
+  dimensions          = { Environment = var.environment, Queue = "replicas" }
+  statistic           = "Maximum"
+  period              = 86400
+  evaluation_periods  = 2
+  comparison_operator = "GreaterThanThreshold"
+  threshold           = var.replica_max_lag_hours * 3600
+  treat_missing_data  = "breaching"
+
+  tags = local.common_tags
+}
+
 # Rule: High error rate alert
 resource "aws_cloudwatch_event_rule" "high_error_rate" {
   name        = "${var.project_name}-${var.environment}-high-error-rate"
@@ -38,6 +38,66 @@ output "daily_lifecycle_rule_name" {
   value       = aws_cloudwatch_event_rule.daily_lifecycle.name
 }
 
+output "embargo_release_rule_arn" {
+  description = "ARN of the embargo release event rule"
+  value       = aws_cloudwatch_event_rule.embargo_release.arn
+}
+
+output "retention_evaluation_rule_arn" {
+  description = "ARN of the retention evaluation event rule"
+  value       = aws_cloudwatch_event_rule.retention_evaluation.arn
+}
+
+output "search_alerts_rule_arn" {
+  description = "ARN of the saved search alerts event rule"
+  value       = aws_cloudwatch_event_rule.search_alerts.arn
+}
+
+output "usage_ingest_rule_arn" {
+  description = "ARN of the access log usage ingest event rule"
+  value       = aws_cloudwatch_event_rule.usage_ingest.arn
+}
+
+output "usage_report_rule_arn" {
+  description = "ARN of the monthly usage report event rule"
+  value       = aws_cloudwatch_event_rule.usage_report.arn
+}
+
+output "citation_tracking_rule_arn" {
+  description = "ARN of the citation tracking event rule"
+  value       = aws_cloudwatch_event_rule.citation_tracking.arn
+}
+
+output "status_page_rule_arn" {
+  description = "ARN of the status page event rule"
+  value       = aws_cloudwatch_event_rule.status_page.arn
+}
+
+output "fixity_rule_arn" {
+  description = "ARN of the fixity verification event rule"
+  value       = aws_cloudwatch_event_rule.fixity.arn
+}
+
+output "malware_scan_rule_arn" {
+  description = "ARN of the malware scanning event rule"
+  value       = aws_cloudwatch_event_rule.malware_scan.arn
+}
+
+output "replica_rule_arn" {
+  description = "ARN of the offsite preservation copy event rule"
+  value       = aws_cloudwatch_event_rule.replica.arn
+}
+
+output "version_prune_rule_arn" {
+  description = "ARN of the version pruning event rule"
+  value       = aws_cloudwatch_event_rule.version_prune.arn
+}
+
+output "audit_anchor_rule_arn" {
+  description = "ARN of the audit log anchoring event rule"
+  value       = aws_cloudwatch_event_rule.audit_anchor.arn
+}
+
 output "weekly_budget_report_rule_arn" {
   description = "ARN of the weekly budget report event rule"
   value       = aws_cloudwatch_event_rule.weekly_budget_report.arn
@@ -54,6 +54,78 @@ variable "budget_report_lambda_arn" {
   default     = ""
 }
 
+variable "embargo_release_lambda_arn" {
+  description = "ARN of the embargo release Lambda function"
+  type        = string
+  default     = ""
+}
+
+variable "retention_evaluation_lambda_arn" {
+  description = "ARN of the retention evaluation Lambda function"
+  type        = string
+  default     = ""
+}
+
+variable "search_alerts_lambda_arn" {
+  description = "ARN of the saved search alerts Lambda function"
+  type        = string
+  default     = ""
+}
+
+variable "usage_ingest_lambda_arn" {
+  description = "ARN of the access log usage ingest Lambda function"
+  type        = string
+  default     = ""
+}
+
+variable "usage_report_lambda_arn" {
+  description = "ARN of the monthly usage report Lambda function"
+  type        = string
+  default     = ""
+}
+
+variable "citation_tracking_lambda_arn" {
+  description = "ARN of the citation tracking Lambda function"
+  type        = string
+  default     = ""
+}
+
+variable "status_page_lambda_arn" {
+  description = "ARN of the status page Lambda function"
+  type        = string
+  default     = ""
+}
+
+variable "fixity_lambda_arn" {
+  description = "ARN of the fixity verification Lambda function"
+  type        = string
+  default     = ""
+}
+
+variable "malware_scan_lambda_arn" {
+  description = "ARN of the malware scanning Lambda function"
+  type        = string
+  default     = ""
+}
+
+variable "replica_lambda_arn" {
+  description = "ARN of the offsite preservation copy Lambda function"
+  type        = string
+  default     = ""
+}
+
+variable "version_prune_lambda_arn" {
+  description = "ARN of the version pruning Lambda function"
+  type        = string
+  default     = ""
+}
+
+variable "audit_anchor_lambda_arn" {
+  description = "ARN of the audit log anchoring Lambda function"
+  type        = string
+  default     = ""
+}
+
 variable "doi_notification_lambda_arn" {
   description = "ARN of the DOI notification Lambda function"
   type        = string
@@ -114,6 +186,132 @@ variable "budget_report_schedule_expression" {
   }
 }
 
+variable "embargo_release_schedule_expression" {
+  description = "Cron/rate expression for embargo release schedule"
+  type        = string
+  default     = "rate(1 hour)"
+  validation {
+    condition     = can(regex("^(rate|cron)\\(.*\\)$", var.embargo_release_schedule_expression))
+    error_message = "Schedule expression must be a valid cron() or rate() expression."
+  }
+}
+
+variable "retention_evaluation_schedule_expression" {
+  description = "Cron/rate expression for retention evaluation schedule"
+  type        = string
+  default     = "cron(0 6 ? * MON *)"
+  validation {
+    condition     = can(regex("^(rate|cron)\\(.*\\)$", var.retention_evaluation_schedule_expression))
+    error_message = "Schedule expression must be a valid cron() or rate() expression."
+  }
+}
+
+variable "search_alerts_schedule_expression" {
+  description = "Cron/rate expression for saved search alerts schedule"
+  type        = string
+  default     = "rate(1 hour)"
+  validation {
+    condition     = can(regex("^(rate|cron)\\(.*\\)$", var.search_alerts_schedule_expression))
+    error_message = "Schedule expression must be a valid cron() or rate() expression."
+  }
+}
+
+variable "usage_ingest_schedule_expression" {
+  description = "Cron/rate expression for access log usage ingest schedule"
+  type        = string
+  default     = "cron(30 1 * * ? *)"
+  validation {
+    condition     = can(regex("^(rate|cron)\\(.*\\)$", var.usage_ingest_schedule_expression))
+    error_message = "Schedule expression must be a valid cron() or rate() expression."
+  }
+}
+
+variable "usage_report_schedule_expression" {
+  description = "Cron/rate expression for monthly usage report schedule (after the month's logs are ingested)"
+  type        = string
+  default     = "cron(0 6 2 * ? *)"
+  validation {
+    condition     = can(regex("^(rate|cron)\\(.*\\)$", var.usage_report_schedule_expression))
+    error_message = "Schedule expression must be a valid cron() or rate() expression."
+  }
+}
+
+variable "citation_tracking_schedule_expression" {
+  description = "Cron/rate expression for citation tracking schedule"
+  type        = string
+  default     = "cron(0 4 ? * SUN *)"
+  validation {
+    condition     = can(regex("^(rate|cron)\\(.*\\)$", var.citation_tracking_schedule_expression))
+    error_message = "Schedule expression must be a valid cron() or rate() expression."
+  }
+}
+
+variable "status_page_schedule_expression" {
+  description = "Cron/rate expression for status page refresh schedule"
+  type        = string
+  default     = "rate(5 minutes)"
+  validation {
+    condition     = can(regex("^(rate|cron)\\(.*\\)$", var.status_page_schedule_expression))
+    error_message = "Schedule expression must be a valid cron() or rate() expression."
+  }
+}
+
+variable "fixity_schedule_expression" {
+  description = "Cron/rate expression for fixity verification schedule"
+  type        = string
+  default     = "cron(0 3 * * ? *)"
+  validation {
+    condition     = can(regex("^(rate|cron)\\(.*\\)$", var.fixity_schedule_expression))
+    error_message = "Schedule expression must be a valid cron() or rate() expression."
+  }
+}
+
+variable "malware_scan_schedule_expression" {
+  description = "Cron/rate expression for malware scanning schedule"
+  type        = string
+  default     = "rate(15 minutes)"
+  validation {
+    condition     = can(regex("^(rate|cron)\\(.*\\)$", var.malware_scan_schedule_expression))
+    error_message = "Schedule expression must be a valid cron() or rate() expression."
+  }
+}
+
+variable "replica_schedule_expression" {
+  description = "Cron/rate expression for offsite preservation copy schedule"
+  type        = string
+  default     = "cron(0 5 * * ? *)"
+  validation {
+    condition     = can(regex("^(rate|cron)\\(.*\\)$", var.replica_schedule_expression))
+    error_message = "Schedule expression must be a valid cron() or rate() expression."
+  }
+}
+
+variable "replica_max_lag_hours" {
+  description = "Hours a published dataset may wait for its offsite copy before the lag alarm fires"
+  type        = number
+  default     = 168
+}
+
+variable "version_prune_schedule_expression" {
+  description = "Cron/rate expression for version pruning schedule"
+  type        = string
+  default     = "cron(0 7 * * ? *)"
+  validation {
+    condition     = can(regex("^(rate|cron)\\(.*\\)$", var.version_prune_schedule_expression))
+    error_message = "Schedule expression must be a valid cron() or rate() expression."
+  }
+}
+
+variable "audit_anchor_schedule_expression" {
+  description = "Cron/rate expression for audit log anchoring schedule"
+  type        = string
+  default     = "cron(0 0 * * ? *)"
+  validation {
+    condition     = can(regex("^(rate|cron)\\(.*\\)$", var.audit_anchor_schedule_expression))
+    error_message = "Schedule expression must be a valid cron() or rate() expression."
+  }
+}
+
 #############################################
 # Event Archive
 #############################################
@@ -31,6 +31,49 @@ locals {
   lambda_runtime = "python3.11"
   lambda_timeout = 30
   lambda_memory  = 256
+
+  # The API functions are the Go executables aperture deploy builds from
+  # cmd/lambdas into lambdas/<name>/bootstrap
+  go_runtime      = "provided.al2023"
+  go_architecture = "arm64"
+
+  # Configuration the API functions load as the CLI does, sharing its
+  # records in the state table
+  aperture_environment = {
+    APERTURE_PROJECT_NAME   = var.project_name
+    APERTURE_ENV            = var.environment
+    APERTURE_STATE_BACKEND  = "dynamodb"
+    APERTURE_ADMINS         = join(",", var.admins)
+    APERTURE_SITE_URL       = var.repo_base_url
+    APERTURE_OPENSEARCH_URL = var.opensearch_url
+  }
+
+  state_table_statement = {
+    Effect = "Allow"
+    Action = [
+      "dynamodb:GetItem",
+      "dynamodb:PutItem",
+      "dynamodb:DeleteItem",
+      "dynamodb:Query"
+    ]
+    Resource = var.state_table_arn
+  }
+
+  # Functions that change datasets index them when OpenSearch is
+  # configured
+  opensearch_statements = var.opensearch_url == "" ? [] : [
+    {
+      Effect = "Allow"
+      Action = [
+        "es:ESHttpGet",
+        "es:ESHttpHead",
+        "es:ESHttpPost",
+        "es:ESHttpPut",
+        "es:ESHttpDelete"
+      ]
+      Resource = "arn:aws:es:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:domain/*"
+    }
+  ]
 }
 
 #############################################
@@ -74,12 +117,12 @@ resource "aws_iam_role_policy" "auth_lambda" {
       {
         Effect = "Allow"
         Action = [
-          "cognito-idp:InitiateAuth",
-          "cognito-idp:GetUser",
-          "cognito-idp:GlobalSignOut"
+          "cognito-idp:AdminInitiateAuth",
+          "cognito-idp:AdminUserGlobalSignOut"
         ]
         Resource = var.cognito_user_pool_arn
       },
+      local.state_table_statement,
       {
         Effect = "Allow"
         Action = [
@@ -140,22 +183,15 @@ resource "aws_iam_role_policy" "presigned_urls_lambda" {
           "${var.embargoed_media_bucket_arn}/*"
         ]
       },
+      local.state_table_statement,
       {
+        # Daily download usage is charged with conditional updates
         Effect = "Allow"
         Action = [
-          "dynamodb:PutItem"
-        ]
-        Resource = var.access_logs_table_arn
-      },
-      {
-        Effect = "Allow"
-        Action = [
-          "dynamodb:Query"
-        ]
-        Resource = [
-          var.doi_registry_table_arn,
-          "${var.doi_registry_table_arn}/index/*"
+          "dynamodb:GetItem",
+          "dynamodb:UpdateItem"
         ]
+        Resource = var.download_quotas_table_arn
       },
       {
         Effect = "Allow"
@@ -203,30 +239,56 @@ resource "aws_iam_role_policy" "doi_minting_lambda" {
 
   policy = jsonencode({
     Version = "2012-10-17"
-    Statement = [
+    Statement = concat([
+      local.state_table_statement,
       {
         Effect = "Allow"
         Action = [
-          "dynamodb:PutItem",
-          "dynamodb:UpdateItem",
-          "dynamodb:Query"
-        ]
-        Resource = [
-          var.doi_registry_table_arn,
-          "${var.doi_registry_table_arn}/index/*"
+          "logs:CreateLogGroup",
+          "logs:CreateLogStream",
+          "logs:PutLogEvents"
         ]
-      },
+        Resource = "arn:aws:logs:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:log-group:/aws/lambda/${var.project_name}-${var.environment}-doi-minting:*"
+      }
+    ], local.opensearch_statements)
+  })
+}
+
+# Search Lambda Role
+resource "aws_iam_role" "search_lambda" {
+  name = "${var.project_name}-${var.environment}-search-lambda"
+
+  assume_role_policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
       {
         Effect = "Allow"
-        Action = [
-          "s3:GetObject",
-          "s3:PutObject",
-          "s3:PutObjectAcl"
-        ]
-        Resource = [
-          "${var.public_media_bucket_arn}/*"
-        ]
-      },
+        Principal = {
+          Service = "lambda.amazonaws.com"
+        }
+        Action = "sts:AssumeRole"
+      }
+    ]
+  })
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name     = "${var.project_name}-${var.environment}-search-lambda-role"
+      Function = "search"
+    }
+  )
+}
+
+# Search Lambda Policy
+resource "aws_iam_role_policy" "search_lambda" {
+  name = "${var.project_name}-${var.environment}-search-lambda-policy"
+  role = aws_iam_role.search_lambda.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = concat([
+      local.state_table_statement,
       {
         Effect = "Allow"
         Action = [
@@ -234,9 +296,9 @@ resource "aws_iam_role_policy" "doi_minting_lambda" {
           "logs:CreateLogStream",
           "logs:PutLogEvents"
         ]
-        Resource = "arn:aws:logs:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:log-group:/aws/lambda/${var.project_name}-${var.environment}-doi-minting:*"
+        Resource = "arn:aws:logs:${data.aws_region.current.name}:${data.aws_caller_identity.current.account_id}:log-group:/aws/lambda/${var.project_name}-${var.environment}-search:*"
       }
-    ]
+    ], local.opensearch_statements)
   })
 }
 
@@ -396,24 +458,31 @@ resource "aws_iam_role_policy" "rag_knowledge_base_lambda" {
 # Auth Lambda Package
 data "archive_file" "auth_lambda" {
   type        = "zip"
-  source_dir  = "${path.root}/lambda/auth"
+  source_dir  = "${path.root}/lambdas/auth"
   output_path = "${path.module}/packages/auth.zip"
 }
 
 # Presigned URLs Lambda Package
 data "archive_file" "presigned_urls_lambda" {
   type        = "zip"
-  source_dir  = "${path.root}/lambda/presigned-urls"
+  source_dir  = "${path.root}/lambdas/presign"
   output_path = "${path.module}/packages/presigned-urls.zip"
 }
 
 # DOI Minting Lambda Package
 data "archive_file" "doi_minting_lambda" {
   type        = "zip"
-  source_dir  = "${path.root}/lambda/doi-minting"
+  source_dir  = "${path.root}/lambdas/doi"
   output_path = "${path.module}/packages/doi-minting.zip"
 }
 
+# Search Lambda Package
+data "archive_file" "search_lambda" {
+  type        = "zip"
+  source_dir  = "${path.root}/lambdas/search"
+  output_path = "${path.module}/packages/search.zip"
+}
+
 # Bedrock Analysis Lambda Package
 data "archive_file" "bedrock_analysis_lambda" {
   type        = "zip"
@@ -471,6 +540,19 @@ resource "aws_cloudwatch_log_group" "doi_minting_lambda" {
   )
 }
 
+resource "aws_cloudwatch_log_group" "search_lambda" {
+  name              = "/aws/lambda/${var.project_name}-${var.environment}-search"
+  retention_in_days = var.log_retention_days
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name     = "${var.project_name}-${var.environment}-search-logs"
+      Function = "search"
+    }
+  )
+}
+
 resource "aws_cloudwatch_log_group" "bedrock_analysis_lambda" {
   name              = "/aws/lambda/${var.project_name}-${var.environment}-bedrock-analysis"
   retention_in_days = var.log_retention_days
@@ -506,18 +588,19 @@ resource "aws_lambda_function" "auth" {
   filename         = data.archive_file.auth_lambda.output_path
   function_name    = "${var.project_name}-${var.environment}-auth"
   role             = aws_iam_role.auth_lambda.arn
-  handler          = "handler.lambda_handler"
+  handler          = "bootstrap"
   source_code_hash = data.archive_file.auth_lambda.output_base64sha256
-  runtime          = local.lambda_runtime
+  publish          = true
+  runtime          = local.go_runtime
+  architectures    = [local.go_architecture]
   timeout          = local.lambda_timeout
   memory_size      = local.lambda_memory
 
   environment {
-    variables = {
-      USER_POOL_ID  = var.cognito_user_pool_id
-      APP_CLIENT_ID = var.cognito_app_client_id
-      ENVIRONMENT   = var.environment
-    }
+    variables = merge(local.aperture_environment, {
+      APERTURE_COGNITO_USER_POOL_ID = var.cognito_user_pool_id
+      APERTURE_OIDC_CLIENT_ID       = var.cognito_app_client_id
+    })
   }
 
   tags = merge(
@@ -538,22 +621,19 @@ resource "aws_lambda_function" "presigned_urls" {
   filename         = data.archive_file.presigned_urls_lambda.output_path
   function_name    = "${var.project_name}-${var.environment}-presigned-urls"
   role             = aws_iam_role.presigned_urls_lambda.arn
-  handler          = "handler.lambda_handler"
+  handler          = "bootstrap"
   source_code_hash = data.archive_file.presigned_urls_lambda.output_base64sha256
-  runtime          = local.lambda_runtime
+  publish          = true
+  runtime          = local.go_runtime
+  architectures    = [local.go_architecture]
   timeout          = local.lambda_timeout
   memory_size      = local.lambda_memory
 
   environment {
-    variables = {
-      PUBLIC_MEDIA_BUCKET     = var.public_media_bucket_name
-      PRIVATE_MEDIA_BUCKET    = var.private_media_bucket_name
-      RESTRICTED_MEDIA_BUCKET = var.restricted_media_bucket_name
-      EMBARGOED_MEDIA_BUCKET  = var.embargoed_media_bucket_name
-      ACCESS_LOGS_TABLE       = var.access_logs_table_name
-      DOI_REGISTRY_TABLE      = var.doi_registry_table_name
-      ENVIRONMENT             = var.environment
-    }
+    variables = merge(local.aperture_environment, {
+      APERTURE_QUOTA_BYTES   = tostring(var.download_quota_bytes_per_day)
+      APERTURE_QUOTA_OBJECTS = tostring(var.download_quota_objects_per_day)
+    })
   }
 
   tags = merge(
@@ -574,23 +654,22 @@ resource "aws_lambda_function" "doi_minting" {
   filename         = data.archive_file.doi_minting_lambda.output_path
   function_name    = "${var.project_name}-${var.environment}-doi-minting"
   role             = aws_iam_role.doi_minting_lambda.arn
-  handler          = "handler.lambda_handler"
+  handler          = "bootstrap"
   source_code_hash = data.archive_file.doi_minting_lambda.output_base64sha256
-  runtime          = local.lambda_runtime
+  publish          = true
+  runtime          = local.go_runtime
+  architectures    = [local.go_architecture]
   timeout          = 60 # DOI minting may take longer
   memory_size      = 512
 
   environment {
-    variables = {
-      DATACITE_API_URL    = var.datacite_api_url
-      DATACITE_USERNAME   = var.datacite_username
-      DATACITE_PASSWORD   = var.datacite_password
-      DOI_PREFIX          = var.doi_prefix
-      DOI_REGISTRY_TABLE  = var.doi_registry_table_name
-      PUBLIC_MEDIA_BUCKET = var.public_media_bucket_name
-      REPO_BASE_URL       = var.repo_base_url
-      ENVIRONMENT         = var.environment
-    }
+    variables = merge(local.aperture_environment, {
+      DATACITE_API_URL       = var.datacite_api_url
+      DATACITE_MDS_URL       = var.datacite_mds_url
+      DATACITE_REPOSITORY_ID = var.datacite_username
+      DATACITE_PASSWORD      = var.datacite_password
+      DATACITE_PREFIX        = var.doi_prefix
+    })
   }
 
   tags = merge(
@@ -606,6 +685,36 @@ resource "aws_lambda_function" "doi_minting" {
   ]
 }
 
+# Search Lambda
+resource "aws_lambda_function" "search" {
+  filename         = data.archive_file.search_lambda.output_path
+  function_name    = "${var.project_name}-${var.environment}-search"
+  role             = aws_iam_role.search_lambda.arn
+  handler          = "bootstrap"
+  source_code_hash = data.archive_file.search_lambda.output_base64sha256
+  publish          = true
+  runtime          = local.go_runtime
+  architectures    = [local.go_architecture]
+  timeout          = local.lambda_timeout
+  memory_size      = local.lambda_memory
+
+  environment {
+    variables = local.aperture_environment
+  }
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name     = "${var.project_name}-${var.environment}-search"
+      Function = "search"
+    }
+  )
+
+  depends_on = [
+    aws_cloudwatch_log_group.search_lambda
+  ]
+}
+
 # Bedrock Analysis Lambda
 resource "aws_lambda_function" "bedrock_analysis" {
   filename         = data.archive_file.bedrock_analysis_lambda.output_path
@@ -613,6 +722,7 @@ resource "aws_lambda_function" "bedrock_analysis" {
   role             = aws_iam_role.bedrock_analysis_lambda.arn
   handler          = "handler.lambda_handler"
   source_code_hash = data.archive_file.bedrock_analysis_lambda.output_base64sha256
+  publish          = true
   runtime          = local.lambda_runtime
   timeout          = 120  # AI operations may take longer
   memory_size      = 1024 # More memory for AI processing
@@ -648,6 +758,7 @@ resource "aws_lambda_function" "rag_knowledge_base" {
   role             = aws_iam_role.rag_knowledge_base_lambda.arn
   handler          = "handler.lambda_handler"
   source_code_hash = data.archive_file.rag_knowledge_base_lambda.output_base64sha256
+  publish          = true
   runtime          = local.lambda_runtime
   timeout          = 180  # RAG operations with embeddings may take longer
   memory_size      = 1024 # More memory for embedding operations
@@ -673,6 +784,63 @@ resource "aws_lambda_function" "rag_knowledge_base" {
   ]
 }
 
+#############################################
+# Live Aliases
+#############################################
+
+# API Gateway invokes each function through its live alias. Terraform
+# creates the alias on the first published version; after that,
+# `aperture deploy` shifts it to the version each deployment publishes
+# while watching the alias's error alarm, so the alias's version and
+# routing are left to it.
+locals {
+  api_functions = {
+    auth               = aws_lambda_function.auth
+    presigned_urls     = aws_lambda_function.presigned_urls
+    doi_minting        = aws_lambda_function.doi_minting
+    search             = aws_lambda_function.search
+    bedrock_analysis   = aws_lambda_function.bedrock_analysis
+    rag_knowledge_base = aws_lambda_function.rag_knowledge_base
+  }
+}
+
+resource "aws_lambda_alias" "live" {
+  for_each = local.api_functions
+
+  name             = var.live_alias_name
+  description      = "Version serving API traffic, shifted by aperture deploy"
+  function_name    = each.value.function_name
+  function_version = each.value.version
+
+  lifecycle {
+    ignore_changes = [function_version, routing_config]
+  }
+}
+
+# A traffic shift is rolled back when its alias's errors breach this
+# alarm.
+resource "aws_cloudwatch_metric_alarm" "live_errors" {
+  for_each = local.api_functions
+
+  alarm_name          = "${each.value.function_name}-${var.live_alias_name}-errors"
+  alarm_description   = "Errors of the ${var.live_alias_name} alias of ${each.value.function_name}; rolls back a traffic shift"
+  namespace           = "AWS/Lambda"
+  metric_name         = "Errors"
+  statistic           = "Sum"
+  period              = 60
+  evaluation_periods  = 1
+  threshold           = var.live_error_threshold
+  comparison_operator = "GreaterThanOrEqualToThreshold"
+  treat_missing_data  = "notBreaching"
+
+  dimensions = {
+    FunctionName = each.value.function_name
+    Resource     = "${each.value.function_name}:${var.live_alias_name}"
+  }
+
+  tags = local.common_tags
+}
+
 #############################################
 # Lambda Permissions for API Gateway
 #############################################
@@ -683,6 +851,7 @@ resource "aws_lambda_permission" "auth_api_gateway" {
   statement_id  = "AllowAPIGatewayInvoke"
   action        = "lambda:InvokeFunction"
   function_name = aws_lambda_function.auth.function_name
+  qualifier     = aws_lambda_alias.live["auth"].name
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${var.api_gateway_execution_arn}/*"
 }
@@ -693,6 +862,7 @@ resource "aws_lambda_permission" "presigned_urls_api_gateway" {
   statement_id  = "AllowAPIGatewayInvoke"
   action        = "lambda:InvokeFunction"
   function_name = aws_lambda_function.presigned_urls.function_name
+  qualifier     = aws_lambda_alias.live["presigned_urls"].name
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${var.api_gateway_execution_arn}/*"
 }
@@ -703,6 +873,18 @@ resource "aws_lambda_permission" "doi_minting_api_gateway" {
   statement_id  = "AllowAPIGatewayInvoke"
   action        = "lambda:InvokeFunction"
   function_name = aws_lambda_function.doi_minting.function_name
+  qualifier     = aws_lambda_alias.live["doi_minting"].name
+  principal     = "apigateway.amazonaws.com"
+  source_arn    = "${var.api_gateway_execution_arn}/*"
+}
+
+resource "aws_lambda_permission" "search_api_gateway" {
+  count = var.api_gateway_execution_arn != "" ? 1 : 0
+
+  statement_id  = "AllowAPIGatewayInvoke"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.search.function_name
+  qualifier     = aws_lambda_alias.live["search"].name
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${var.api_gateway_execution_arn}/*"
 }
@@ -713,6 +895,7 @@ resource "aws_lambda_permission" "bedrock_analysis_api_gateway" {
   statement_id  = "AllowAPIGatewayInvoke"
   action        = "lambda:InvokeFunction"
   function_name = aws_lambda_function.bedrock_analysis.function_name
+  qualifier     = aws_lambda_alias.live["bedrock_analysis"].name
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${var.api_gateway_execution_arn}/*"
 }
@@ -723,6 +906,7 @@ resource "aws_lambda_permission" "rag_knowledge_base_api_gateway" {
   statement_id  = "AllowAPIGatewayInvoke"
   action        = "lambda:InvokeFunction"
   function_name = aws_lambda_function.rag_knowledge_base.function_name
+  qualifier     = aws_lambda_alias.live["rag_knowledge_base"].name
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${var.api_gateway_execution_arn}/*"
 }
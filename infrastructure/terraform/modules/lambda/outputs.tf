@@ -24,6 +24,11 @@ output "auth_lambda_qualified_arn" {
   value       = aws_lambda_function.auth.qualified_arn
 }
 
+output "auth_lambda_alias_invoke_arn" {
+  description = "Invoke ARN of the live alias of the auth Lambda function"
+  value       = aws_lambda_alias.live["auth"].invoke_arn
+}
+
 #############################################
 # Presigned URLs Lambda
 #############################################
@@ -48,6 +53,11 @@ output "presigned_urls_lambda_qualified_arn" {
   value       = aws_lambda_function.presigned_urls.qualified_arn
 }
 
+output "presigned_urls_lambda_alias_invoke_arn" {
+  description = "Invoke ARN of the live alias of the presigned URLs Lambda function"
+  value       = aws_lambda_alias.live["presigned_urls"].invoke_arn
+}
+
 #############################################
 # DOI Minting Lambda
 #############################################
@@ -72,6 +82,40 @@ output "doi_minting_lambda_qualified_arn" {
   value       = aws_lambda_function.doi_minting.qualified_arn
 }
 
+output "doi_minting_lambda_alias_invoke_arn" {
+  description = "Invoke ARN of the live alias of the DOI minting Lambda function"
+  value       = aws_lambda_alias.live["doi_minting"].invoke_arn
+}
+
+#############################################
+# Search Lambda
+#############################################
+
+output "search_lambda_arn" {
+  description = "ARN of the search Lambda function"
+  value       = aws_lambda_function.search.arn
+}
+
+output "search_lambda_name" {
+  description = "Name of the search Lambda function"
+  value       = aws_lambda_function.search.function_name
+}
+
+output "search_lambda_invoke_arn" {
+  description = "Invoke ARN of the search Lambda function"
+  value       = aws_lambda_function.search.invoke_arn
+}
+
+output "search_lambda_qualified_arn" {
+  description = "Qualified ARN of the search Lambda function"
+  value       = aws_lambda_function.search.qualified_arn
+}
+
+output "search_lambda_alias_invoke_arn" {
+  description = "Invoke ARN of the live alias of the search Lambda function"
+  value       = aws_lambda_alias.live["search"].invoke_arn
+}
+
 #############################################
 # Bedrock Analysis Lambda
 #############################################
@@ -96,6 +140,11 @@ output "bedrock_analysis_lambda_qualified_arn" {
   value       = aws_lambda_function.bedrock_analysis.qualified_arn
 }
 
+output "bedrock_analysis_lambda_alias_invoke_arn" {
+  description = "Invoke ARN of the live alias of the Bedrock analysis Lambda function"
+  value       = aws_lambda_alias.live["bedrock_analysis"].invoke_arn
+}
+
 #############################################
 # RAG Knowledge Base Lambda
 #############################################
@@ -120,6 +169,11 @@ output "rag_knowledge_base_lambda_qualified_arn" {
   value       = aws_lambda_function.rag_knowledge_base.qualified_arn
 }
 
+output "rag_knowledge_base_lambda_alias_invoke_arn" {
+  description = "Invoke ARN of the live alias of the RAG knowledge base Lambda function"
+  value       = aws_lambda_alias.live["rag_knowledge_base"].invoke_arn
+}
+
 #############################################
 # IAM Roles
 #############################################
@@ -139,6 +193,11 @@ output "doi_minting_lambda_role_arn" {
   value       = aws_iam_role.doi_minting_lambda.arn
 }
 
+output "search_lambda_role_arn" {
+  description = "ARN of the search Lambda IAM role"
+  value       = aws_iam_role.search_lambda.arn
+}
+
 output "bedrock_analysis_lambda_role_arn" {
   description = "ARN of the Bedrock analysis Lambda IAM role"
   value       = aws_iam_role.bedrock_analysis_lambda.arn
@@ -168,6 +227,11 @@ output "doi_minting_lambda_log_group_name" {
   value       = aws_cloudwatch_log_group.doi_minting_lambda.name
 }
 
+output "search_lambda_log_group_name" {
+  description = "Name of the search Lambda CloudWatch log group"
+  value       = aws_cloudwatch_log_group.search_lambda.name
+}
+
 output "bedrock_analysis_lambda_log_group_name" {
   description = "Name of the Bedrock analysis Lambda CloudWatch log group"
   value       = aws_cloudwatch_log_group.bedrock_analysis_lambda.name
@@ -178,6 +242,22 @@ output "rag_knowledge_base_lambda_log_group_name" {
   value       = aws_cloudwatch_log_group.rag_knowledge_base_lambda.name
 }
 
+#############################################
+# Traffic Shifting
+#############################################
+
+output "api_functions" {
+  description = "API functions whose live aliases aperture deploy shifts to the versions published, with their error alarms"
+  value = {
+    for name, fn in local.api_functions : name => {
+      function_name = fn.function_name
+      version       = fn.version
+      alias         = aws_lambda_alias.live[name].name
+      alarm         = aws_cloudwatch_metric_alarm.live_errors[name].alarm_name
+    }
+  }
+}
+
 #############################################
 # Summary
 #############################################
@@ -188,9 +268,10 @@ output "summary" {
     auth_lambda_name               = aws_lambda_function.auth.function_name
     presigned_urls_lambda_name     = aws_lambda_function.presigned_urls.function_name
     doi_minting_lambda_name        = aws_lambda_function.doi_minting.function_name
+    search_lambda_name             = aws_lambda_function.search.function_name
     bedrock_analysis_lambda_name   = aws_lambda_function.bedrock_analysis.function_name
     rag_knowledge_base_lambda_name = aws_lambda_function.rag_knowledge_base.function_name
-    total_functions                = 5
+    total_functions                = 6
     runtime                        = aws_lambda_function.auth.runtime
     environment                    = var.environment
   }
@@ -81,6 +81,16 @@ variable "embargoed_media_bucket_arn" {
 # DynamoDB Table Configuration
 #############################################
 
+variable "state_table_name" {
+  description = "Name of the state DynamoDB table holding the records the API functions share with the CLI"
+  type        = string
+}
+
+variable "state_table_arn" {
+  description = "ARN of the state DynamoDB table"
+  type        = string
+}
+
 variable "doi_registry_table_name" {
   description = "Name of the DOI registry DynamoDB table"
   type        = string
@@ -111,6 +121,28 @@ variable "access_logs_table_arn" {
   type        = string
 }
 
+variable "download_quotas_table_name" {
+  description = "Name of the download quotas DynamoDB table"
+  type        = string
+}
+
+variable "download_quotas_table_arn" {
+  description = "ARN of the download quotas DynamoDB table"
+  type        = string
+}
+
+variable "download_quota_bytes_per_day" {
+  description = "Maximum bytes of restricted data issued to one user per day (0 disables)"
+  type        = number
+  default     = 53687091200
+}
+
+variable "download_quota_objects_per_day" {
+  description = "Maximum restricted objects issued to one user per day (0 disables)"
+  type        = number
+  default     = 1000
+}
+
 variable "budget_tracking_table_name" {
   description = "Name of the budget tracking DynamoDB table"
   type        = string
@@ -136,9 +168,15 @@ variable "knowledge_base_embeddings_table_arn" {
 #############################################
 
 variable "datacite_api_url" {
-  description = "DataCite API URL"
+  description = "DataCite REST API root"
   type        = string
-  default     = "https://api.datacite.org/dois"
+  default     = "https://api.test.datacite.org"
+}
+
+variable "datacite_mds_url" {
+  description = "DataCite MDS API root, which registers media"
+  type        = string
+  default     = "https://mds.test.datacite.org"
 }
 
 variable "datacite_username" {
@@ -163,6 +201,22 @@ variable "repo_base_url" {
   type        = string
 }
 
+#############################################
+# Aperture Configuration
+#############################################
+
+variable "admins" {
+  description = "Principals the API functions treat as administrators (APERTURE_ADMINS)"
+  type        = list(string)
+  default     = []
+}
+
+variable "opensearch_url" {
+  description = "OpenSearch endpoint of the dataset index; the search function fails to start without one"
+  type        = string
+  default     = ""
+}
+
 #############################################
 # API Gateway Configuration
 #############################################
@@ -187,6 +241,22 @@ variable "log_retention_days" {
   }
 }
 
+#############################################
+# Traffic Shifting
+#############################################
+
+variable "live_alias_name" {
+  description = "Name of the alias API Gateway invokes, shifted between versions by aperture deploy"
+  type        = string
+  default     = "live"
+}
+
+variable "live_error_threshold" {
+  description = "Errors per minute of a live alias that fire its alarm and roll back a traffic shift"
+  type        = number
+  default     = 5
+}
+
 #############################################
 # Tags
 #############################################
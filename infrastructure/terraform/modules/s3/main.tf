@@ -6,6 +6,9 @@ terraform {
     aws = {
       source  = "hashicorp/aws"
       version = "~> 5.0"
+
+      # The logs bucket may live in an account of its own
+      configuration_aliases = [aws.logging]
     }
   }
 }
@@ -16,6 +19,19 @@ data "aws_caller_identity" "current" {}
 locals {
   bucket_prefix = "${var.project_name}-${var.environment}"
 
+  # S3 server access logs cannot be delivered to another account, so a
+  # logs bucket in a separate account receives a CloudTrail trail of
+  # the data events instead.
+  access_logging = var.enable_logging && !var.cross_account_logging
+
+  # Buckets that Lambda functions in the client accounts read and write
+  client_buckets = length(var.client_account_ids) == 0 ? {} : {
+    private_media    = aws_s3_bucket.private_media
+    restricted_media = aws_s3_bucket.restricted_media
+    embargoed_media  = aws_s3_bucket.embargoed_media
+    processing       = aws_s3_bucket.processing
+  }
+
   common_tags = merge(
     var.tags,
     {
@@ -32,7 +48,8 @@ locals {
 #############################################
 
 resource "aws_s3_bucket" "public_media" {
-  bucket = "${local.bucket_prefix}-public-media"
+  bucket        = "${local.bucket_prefix}-public-media"
+  force_destroy = var.force_destroy
 
   tags = merge(
     local.common_tags,
@@ -73,7 +90,7 @@ resource "aws_s3_bucket_public_access_block" "public_media" {
 }
 
 resource "aws_s3_bucket_logging" "public_media" {
-  count = var.enable_logging ? 1 : 0
+  count = local.access_logging ? 1 : 0
 
   bucket        = aws_s3_bucket.public_media.id
   target_bucket = aws_s3_bucket.logs.id
@@ -139,7 +156,8 @@ resource "aws_s3_bucket_lifecycle_configuration" "public_media" {
 #############################################
 
 resource "aws_s3_bucket" "private_media" {
-  bucket = "${local.bucket_prefix}-private-media"
+  bucket        = "${local.bucket_prefix}-private-media"
+  force_destroy = var.force_destroy
 
   tags = merge(
     local.common_tags,
@@ -180,7 +198,7 @@ resource "aws_s3_bucket_public_access_block" "private_media" {
 }
 
 resource "aws_s3_bucket_logging" "private_media" {
-  count = var.enable_logging ? 1 : 0
+  count = local.access_logging ? 1 : 0
 
   bucket        = aws_s3_bucket.private_media.id
   target_bucket = aws_s3_bucket.logs.id
@@ -246,7 +264,8 @@ resource "aws_s3_bucket_lifecycle_configuration" "private_media" {
 #############################################
 
 resource "aws_s3_bucket" "restricted_media" {
-  bucket = "${local.bucket_prefix}-restricted-media"
+  bucket        = "${local.bucket_prefix}-restricted-media"
+  force_destroy = var.force_destroy
 
   tags = merge(
     local.common_tags,
@@ -287,7 +306,7 @@ resource "aws_s3_bucket_public_access_block" "restricted_media" {
 }
 
 resource "aws_s3_bucket_logging" "restricted_media" {
-  count = var.enable_logging ? 1 : 0
+  count = local.access_logging ? 1 : 0
 
   bucket        = aws_s3_bucket.restricted_media.id
   target_bucket = aws_s3_bucket.logs.id
@@ -353,7 +372,8 @@ resource "aws_s3_bucket_lifecycle_configuration" "restricted_media" {
 #############################################
 
 resource "aws_s3_bucket" "embargoed_media" {
-  bucket = "${local.bucket_prefix}-embargoed-media"
+  bucket        = "${local.bucket_prefix}-embargoed-media"
+  force_destroy = var.force_destroy
 
   tags = merge(
     local.common_tags,
@@ -394,7 +414,7 @@ resource "aws_s3_bucket_public_access_block" "embargoed_media" {
 }
 
 resource "aws_s3_bucket_logging" "embargoed_media" {
-  count = var.enable_logging ? 1 : 0
+  count = local.access_logging ? 1 : 0
 
   bucket        = aws_s3_bucket.embargoed_media.id
   target_bucket = aws_s3_bucket.logs.id
@@ -460,7 +480,8 @@ resource "aws_s3_bucket_lifecycle_configuration" "embargoed_media" {
 #############################################
 
 resource "aws_s3_bucket" "processing" {
-  bucket = "${local.bucket_prefix}-processing"
+  bucket        = "${local.bucket_prefix}-processing"
+  force_destroy = var.force_destroy
 
   tags = merge(
     local.common_tags,
@@ -495,7 +516,7 @@ resource "aws_s3_bucket_public_access_block" "processing" {
 }
 
 resource "aws_s3_bucket_logging" "processing" {
-  count = var.enable_logging ? 1 : 0
+  count = local.access_logging ? 1 : 0
 
   bucket        = aws_s3_bucket.processing.id
   target_bucket = aws_s3_bucket.logs.id
@@ -522,7 +543,10 @@ resource "aws_s3_bucket_lifecycle_configuration" "processing" {
 #############################################
 
 resource "aws_s3_bucket" "logs" {
-  bucket = "${local.bucket_prefix}-logs"
+  provider = aws.logging
+
+  bucket        = "${local.bucket_prefix}-logs"
+  force_destroy = var.force_destroy
 
   tags = merge(
     local.common_tags,
@@ -535,19 +559,24 @@ resource "aws_s3_bucket" "logs" {
 
 # No versioning for logs bucket
 
+# The storage account's key cannot encrypt another account's logs
 resource "aws_s3_bucket_server_side_encryption_configuration" "logs" {
+  provider = aws.logging
+
   bucket = aws_s3_bucket.logs.id
 
   rule {
     apply_server_side_encryption_by_default {
-      sse_algorithm     = var.kms_key_id != "" ? "aws:kms" : "AES256"
-      kms_master_key_id = var.kms_key_id != "" ? var.kms_key_id : null
+      sse_algorithm     = var.kms_key_id != "" && !var.cross_account_logging ? "aws:kms" : "AES256"
+      kms_master_key_id = var.kms_key_id != "" && !var.cross_account_logging ? var.kms_key_id : null
     }
-    bucket_key_enabled = var.kms_key_id != "" ? true : false
+    bucket_key_enabled = var.kms_key_id != "" && !var.cross_account_logging ? true : false
   }
 }
 
 resource "aws_s3_bucket_public_access_block" "logs" {
+  provider = aws.logging
+
   bucket = aws_s3_bucket.logs.id
 
   block_public_acls       = true
@@ -557,6 +586,8 @@ resource "aws_s3_bucket_public_access_block" "logs" {
 }
 
 resource "aws_s3_bucket_lifecycle_configuration" "logs" {
+  provider = aws.logging
+
   bucket = aws_s3_bucket.logs.id
 
   rule {
@@ -573,6 +604,8 @@ resource "aws_s3_bucket_lifecycle_configuration" "logs" {
 
 # Allow S3 to write access logs
 resource "aws_s3_bucket_acl" "logs" {
+  provider = aws.logging
+
   bucket = aws_s3_bucket.logs.id
   acl    = "log-delivery-write"
 }
@@ -582,7 +615,8 @@ resource "aws_s3_bucket_acl" "logs" {
 #############################################
 
 resource "aws_s3_bucket" "frontend" {
-  bucket = "${local.bucket_prefix}-frontend"
+  bucket        = "${local.bucket_prefix}-frontend"
+  force_destroy = var.force_destroy
 
   tags = merge(
     local.common_tags,
@@ -621,7 +655,7 @@ resource "aws_s3_bucket_public_access_block" "frontend" {
 }
 
 resource "aws_s3_bucket_logging" "frontend" {
-  count = var.enable_logging ? 1 : 0
+  count = local.access_logging ? 1 : 0
 
   bucket        = aws_s3_bucket.frontend.id
   target_bucket = aws_s3_bucket.logs.id
@@ -669,3 +703,219 @@ resource "aws_s3_bucket_website_configuration" "frontend" {
     key = "error.html"
   }
 }
+
+#############################################
+# 8. Quarantine Bucket
+#############################################
+
+resource "aws_s3_bucket" "quarantine" {
+  bucket        = "${local.bucket_prefix}-quarantine"
+  force_destroy = var.force_destroy
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${local.bucket_prefix}-quarantine"
+      Purpose = "Files flagged by malware scanning"
+    }
+  )
+}
+
+# No versioning for quarantine bucket (files are released or expire)
+
+resource "aws_s3_bucket_server_side_encryption_configuration" "quarantine" {
+  bucket = aws_s3_bucket.quarantine.id
+
+  rule {
+    apply_server_side_encryption_by_default {
+      sse_algorithm     = var.kms_key_id != "" ? "aws:kms" : "AES256"
+      kms_master_key_id = var.kms_key_id != "" ? var.kms_key_id : null
+    }
+    bucket_key_enabled = var.kms_key_id != "" ? true : false
+  }
+}
+
+resource "aws_s3_bucket_public_access_block" "quarantine" {
+  bucket = aws_s3_bucket.quarantine.id
+
+  block_public_acls       = true
+  block_public_policy     = true
+  ignore_public_acls      = true
+  restrict_public_buckets = true
+}
+
+resource "aws_s3_bucket_logging" "quarantine" {
+  count = local.access_logging ? 1 : 0
+
+  bucket        = aws_s3_bucket.quarantine.id
+  target_bucket = aws_s3_bucket.logs.id
+  target_prefix = "quarantine/"
+}
+
+resource "aws_s3_bucket_lifecycle_configuration" "quarantine" {
+  bucket = aws_s3_bucket.quarantine.id
+
+  rule {
+    id     = "expire-quarantined-files"
+    status = "Enabled"
+
+    filter {}
+
+    expiration {
+      days = var.quarantine_expiration_days
+    }
+  }
+}
+
+#############################################
+# 9. Audit Anchor Bucket
+#############################################
+
+# Anchors of the audit log's hash chain, written by `aperture audit
+# anchor` in compliance mode, so no one can alter or delete them until
+# their retention ends. Object Lock can only be enabled on creation.
+resource "aws_s3_bucket" "audit_anchors" {
+  bucket              = "${local.bucket_prefix}-audit-anchors"
+  force_destroy       = var.force_destroy
+  object_lock_enabled = true
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name    = "${local.bucket_prefix}-audit-anchors"
+      Purpose = "Audit log anchors"
+    }
+  )
+}
+
+# Object Lock requires versioning
+resource "aws_s3_bucket_versioning" "audit_anchors" {
+  bucket = aws_s3_bucket.audit_anchors.id
+
+  versioning_configuration {
+    status = "Enabled"
+  }
+}
+
+resource "aws_s3_bucket_server_side_encryption_configuration" "audit_anchors" {
+  bucket = aws_s3_bucket.audit_anchors.id
+
+  rule {
+    apply_server_side_encryption_by_default {
+      sse_algorithm     = var.kms_key_id != "" ? "aws:kms" : "AES256"
+      kms_master_key_id = var.kms_key_id != "" ? var.kms_key_id : null
+    }
+    bucket_key_enabled = var.kms_key_id != "" ? true : false
+  }
+}
+
+resource "aws_s3_bucket_public_access_block" "audit_anchors" {
+  bucket = aws_s3_bucket.audit_anchors.id
+
+  block_public_acls       = true
+  block_public_policy     = true
+  ignore_public_acls      = true
+  restrict_public_buckets = true
+}
+
+resource "aws_s3_bucket_logging" "audit_anchors" {
+  count = local.access_logging ? 1 : 0
+
+  bucket        = aws_s3_bucket.audit_anchors.id
+  target_bucket = aws_s3_bucket.logs.id
+  target_prefix = "audit-anchors/"
+}
+
+#############################################
+# 10. Cross-Account Access
+#############################################
+
+# Lambda functions deployed to another account reach the buckets they
+# read and write through their account's IAM policies; the bucket must
+# also trust the account.
+resource "aws_s3_bucket_policy" "client_accounts" {
+  for_each = local.client_buckets
+
+  bucket = each.value.id
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Sid       = "ClientAccountObjects"
+        Effect    = "Allow"
+        Principal = { AWS = [for id in var.client_account_ids : "arn:aws:iam::${id}:root"] }
+        Action    = ["s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:GetObjectTagging", "s3:PutObjectTagging"]
+        Resource  = "${each.value.arn}/*"
+      },
+      {
+        Sid       = "ClientAccountBucket"
+        Effect    = "Allow"
+        Principal = { AWS = [for id in var.client_account_ids : "arn:aws:iam::${id}:root"] }
+        Action    = ["s3:ListBucket", "s3:GetBucketLocation"]
+        Resource  = each.value.arn
+      }
+    ]
+  })
+}
+
+# Data events of the media buckets, delivered to a logs bucket in the
+# logging account in place of server access logs
+resource "aws_s3_bucket_policy" "logs_cloudtrail" {
+  count    = var.enable_logging && var.cross_account_logging ? 1 : 0
+  provider = aws.logging
+
+  bucket = aws_s3_bucket.logs.id
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Sid       = "CloudTrailAclCheck"
+        Effect    = "Allow"
+        Principal = { Service = "cloudtrail.amazonaws.com" }
+        Action    = "s3:GetBucketAcl"
+        Resource  = aws_s3_bucket.logs.arn
+      },
+      {
+        Sid       = "CloudTrailWrite"
+        Effect    = "Allow"
+        Principal = { Service = "cloudtrail.amazonaws.com" }
+        Action    = "s3:PutObject"
+        Resource  = "${aws_s3_bucket.logs.arn}/cloudtrail/AWSLogs/${data.aws_caller_identity.current.account_id}/*"
+        Condition = {
+          StringEquals = { "s3:x-amz-acl" = "bucket-owner-full-control" }
+        }
+      }
+    ]
+  })
+}
+
+resource "aws_cloudtrail" "access_logs" {
+  count = var.enable_logging && var.cross_account_logging ? 1 : 0
+
+  name                          = "${local.bucket_prefix}-access-logs"
+  s3_bucket_name                = aws_s3_bucket.logs.id
+  s3_key_prefix                 = "cloudtrail"
+  include_global_service_events = false
+
+  event_selector {
+    read_write_type           = "All"
+    include_management_events = false
+
+    data_resource {
+      type = "AWS::S3::Object"
+      values = [for b in [
+        aws_s3_bucket.public_media,
+        aws_s3_bucket.private_media,
+        aws_s3_bucket.restricted_media,
+        aws_s3_bucket.embargoed_media,
+        aws_s3_bucket.processing,
+        aws_s3_bucket.frontend,
+        aws_s3_bucket.audit_anchors,
+      ] : "${b.arn}/"]
+    }
+  }
+
+  tags = local.common_tags
+
+  depends_on = [aws_s3_bucket_policy.logs_cloudtrail]
+}
@@ -120,6 +120,34 @@ output "processing_bucket_regional_domain_name" {
   value       = aws_s3_bucket.processing.bucket_regional_domain_name
 }
 
+#############################################
+# Quarantine Bucket
+#############################################
+
+output "quarantine_bucket_id" {
+  description = "ID of the quarantine bucket"
+  value       = aws_s3_bucket.quarantine.id
+}
+
+output "quarantine_bucket_arn" {
+  description = "ARN of the quarantine bucket"
+  value       = aws_s3_bucket.quarantine.arn
+}
+
+#############################################
+# Audit Anchor Bucket
+#############################################
+
+output "audit_anchors_bucket_id" {
+  description = "ID of the audit anchor bucket (APERTURE_AUDIT_ANCHOR_BUCKET)"
+  value       = aws_s3_bucket.audit_anchors.id
+}
+
+output "audit_anchors_bucket_arn" {
+  description = "ARN of the audit anchor bucket"
+  value       = aws_s3_bucket.audit_anchors.arn
+}
+
 #############################################
 # Logs Bucket
 #############################################
@@ -190,6 +218,8 @@ output "all_bucket_ids" {
     aws_s3_bucket.restricted_media.id,
     aws_s3_bucket.embargoed_media.id,
     aws_s3_bucket.processing.id,
+    aws_s3_bucket.quarantine.id,
+    aws_s3_bucket.audit_anchors.id,
     aws_s3_bucket.logs.id,
     aws_s3_bucket.frontend.id,
   ]
@@ -203,6 +233,8 @@ output "all_bucket_arns" {
     aws_s3_bucket.restricted_media.arn,
     aws_s3_bucket.embargoed_media.arn,
     aws_s3_bucket.processing.arn,
+    aws_s3_bucket.quarantine.arn,
+    aws_s3_bucket.audit_anchors.arn,
     aws_s3_bucket.logs.arn,
     aws_s3_bucket.frontend.arn,
   ]
@@ -26,6 +26,24 @@ variable "enable_logging" {
   default     = true
 }
 
+variable "cross_account_logging" {
+  description = "The logs bucket is in another account (the aws.logging provider's); bucket access is logged by a CloudTrail trail of data events, as S3 server access logs cannot cross accounts"
+  type        = bool
+  default     = false
+}
+
+variable "client_account_ids" {
+  description = "Accounts other than this one whose Lambda functions read and write the private, restricted, embargoed, and processing buckets"
+  type        = list(string)
+  default     = []
+}
+
+variable "force_destroy" {
+  description = "Delete buckets on destroy even if they still hold objects"
+  type        = bool
+  default     = false
+}
+
 variable "kms_key_id" {
   description = "KMS key ID for server-side encryption (leave empty for SSE-S3)"
   type        = string
@@ -48,6 +66,16 @@ variable "processing_expiration_days" {
   }
 }
 
+variable "quarantine_expiration_days" {
+  description = "Number of days before files quarantined by malware scanning expire"
+  type        = number
+  default     = 90
+  validation {
+    condition     = var.quarantine_expiration_days >= 1
+    error_message = "Quarantine expiration days must be at least 1."
+  }
+}
+
 variable "logs_retention_days" {
   description = "Number of days to retain logs (compliance: 7 years = 2555 days)"
   type        = number
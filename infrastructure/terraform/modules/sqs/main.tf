@@ -0,0 +1,98 @@
+# SQS Work Queues Module
+# Copyright 2025 Scott Friedman
+# Manages the queues background work is handed to 'aperture worker' through
+
+terraform {
+  required_version = ">= 1.0"
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+  }
+}
+
+locals {
+  queue_prefix = "${var.project_name}-${var.environment}"
+
+  # The topics of internal/queue: changed datasets to index,
+  # notifications to deliver, and access logs to ingest
+  topics = toset(["index", "notify", "ingest"])
+}
+
+# Dead-letter queues hold messages that failed max_receive_count times
+resource "aws_sqs_queue" "dead" {
+  for_each = local.topics
+
+  name                      = "${local.queue_prefix}-${each.key}-dead"
+  message_retention_seconds = 1209600
+  sqs_managed_sse_enabled   = true
+
+  tags = merge(
+    var.tags,
+    {
+      Name        = "${local.queue_prefix}-${each.key}-dead"
+      Purpose     = "Failed ${each.key} messages"
+      Environment = var.environment
+    }
+  )
+}
+
+# Work queues, one per topic, named <prefix>-<topic>
+resource "aws_sqs_queue" "work" {
+  for_each = local.topics
+
+  name                       = "${local.queue_prefix}-${each.key}"
+  visibility_timeout_seconds = var.visibility_timeout_seconds
+  message_retention_seconds  = var.message_retention_seconds
+  receive_wait_time_seconds  = 20
+  sqs_managed_sse_enabled    = true
+
+  redrive_policy = jsonencode({
+    deadLetterTargetArn = aws_sqs_queue.dead[each.key].arn
+    maxReceiveCount     = var.max_receive_count
+  })
+
+  tags = merge(
+    var.tags,
+    {
+      Name        = "${local.queue_prefix}-${each.key}"
+      Purpose     = "Background ${each.key} work"
+      Environment = var.environment
+    }
+  )
+}
+
+# The logs bucket announces each access log written to the ingest queue
+resource "aws_sqs_queue_policy" "ingest" {
+  count = var.logs_bucket_name != "" ? 1 : 0
+
+  queue_url = aws_sqs_queue.work["ingest"].id
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [{
+      Sid       = "AllowLogsBucketNotifications"
+      Effect    = "Allow"
+      Principal = { Service = "s3.amazonaws.com" }
+      Action    = "sqs:SendMessage"
+      Resource  = aws_sqs_queue.work["ingest"].arn
+      Condition = {
+        ArnEquals = { "aws:SourceArn" = "arn:aws:s3:::${var.logs_bucket_name}" }
+      }
+    }]
+  })
+}
+
+resource "aws_s3_bucket_notification" "access_logs" {
+  count = var.logs_bucket_name != "" ? 1 : 0
+
+  bucket = var.logs_bucket_name
+
+  queue {
+    queue_arn     = aws_sqs_queue.work["ingest"].arn
+    events        = ["s3:ObjectCreated:*"]
+    filter_prefix = var.access_log_prefix
+  }
+
+  depends_on = [aws_sqs_queue_policy.ingest]
+}
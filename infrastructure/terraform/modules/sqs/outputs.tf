@@ -0,0 +1,17 @@
+# SQS Module Outputs
+# Copyright 2025 Scott Friedman
+
+output "queue_urls" {
+  description = "URLs of the work queues, by topic"
+  value       = { for topic, q in aws_sqs_queue.work : topic => q.url }
+}
+
+output "queue_arns" {
+  description = "ARNs of the work queues, by topic"
+  value       = { for topic, q in aws_sqs_queue.work : topic => q.arn }
+}
+
+output "dead_letter_queue_arns" {
+  description = "ARNs of the dead-letter queues, by topic"
+  value       = { for topic, q in aws_sqs_queue.dead : topic => q.arn }
+}
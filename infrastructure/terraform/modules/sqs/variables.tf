@@ -0,0 +1,48 @@
+# SQS Module Variables
+# Copyright 2025 Scott Friedman
+
+variable "project_name" {
+  description = "Project name for resource naming"
+  type        = string
+}
+
+variable "environment" {
+  description = "Environment (dev, staging, prod)"
+  type        = string
+}
+
+variable "visibility_timeout_seconds" {
+  description = "How long a received message is hidden from other workers"
+  type        = number
+  default     = 300
+}
+
+variable "message_retention_seconds" {
+  description = "How long unhandled messages are kept"
+  type        = number
+  default     = 345600
+}
+
+variable "max_receive_count" {
+  description = "Receives before a message moves to its dead-letter queue; matches the worker's default"
+  type        = number
+  default     = 5
+}
+
+variable "logs_bucket_name" {
+  description = "Logs bucket whose new access logs are queued for ingest; none if empty"
+  type        = string
+  default     = ""
+}
+
+variable "access_log_prefix" {
+  description = "Key prefix of the access logs in the logs bucket"
+  type        = string
+  default     = ""
+}
+
+variable "tags" {
+  description = "Additional tags to apply to all resources"
+  type        = map(string)
+  default     = {}
+}
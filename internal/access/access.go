@@ -0,0 +1,262 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package access issues time-limited download URLs for dataset files
+// after checking that the requester may have them.
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/dua"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/quota"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// DefaultExpiry is how long issued URLs remain valid.
+const DefaultExpiry = time.Hour
+
+var (
+	// ErrDenied is returned when access is refused.
+	ErrDenied = errors.New("access denied")
+
+	// ErrNoFile is returned when the requested version or file does
+	// not exist.
+	ErrNoFile = errors.New("file not found")
+)
+
+// Presigner creates download URLs.
+type Presigner interface {
+	PresignGetObject(bucket, key string, expires time.Duration) string
+}
+
+// Router picks the copy of a file a download is served from.
+// *regions.Router implements it.
+type Router interface {
+	// Route returns the presigner and location of the copy of f to
+	// serve client, or a nil presigner for the stored object
+	Route(ctx context.Context, d *dataset.Dataset, f *dataset.File, client authz.Client) (Presigner, storage.Location, error)
+}
+
+// Request asks for one file of a dataset.
+type Request struct {
+	// Dataset is a dataset ID or DOI
+	Dataset string
+
+	// Version is the version number; the latest if zero
+	Version int
+
+	// File is the file path within the version
+	File string
+
+	// Email identifies the requester for agreement checks; the
+	// principal ID if empty
+	Email string
+
+	// Client is where the request came from, checked against the
+	// dataset's network restriction
+	Client authz.Client
+}
+
+// Grant is an issued download URL.
+type Grant struct {
+	DatasetID string    `json:"datasetId"`
+	Version   int       `json:"version"`
+	File      string    `json:"file"`
+	URL       string    `json:"url"`
+	Expires   time.Time `json:"expires"`
+}
+
+// Issuer checks requests and issues download URLs.
+type Issuer struct {
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Agreements holds data use agreement acceptances
+	Agreements *dua.Registry
+
+	// Presigner signs download URLs
+	Presigner Presigner
+
+	// Router serves files from the copy nearest the requester; the
+	// stored object if nil
+	Router Router
+
+	// Expiry is how long URLs remain valid; DefaultExpiry if zero
+	Expiry time.Duration
+
+	// Quota limits daily downloads of restricted datasets; skipped if
+	// nil
+	Quota *quota.Tracker
+
+	// Log records grants; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Issue returns a download URL for the requested file if the principal
+// in ctx may read the dataset, or an error wrapping ErrDenied that
+// explains why access was refused.
+func (i *Issuer) Issue(ctx context.Context, req Request) (*Grant, error) {
+	d, err := i.Datasets.Resolve(ctx, req.Dataset)
+	if err != nil {
+		return nil, err
+	}
+	now := i.now()
+
+	if d.State != dataset.StatePublished {
+		return nil, fmt.Errorf("%w: %s is not published", ErrDenied, d.ID)
+	}
+	if d.Embargoed(now) {
+		return nil, fmt.Errorf("%w: %s is under embargo until %s", ErrDenied, d.ID, d.Embargo.Until.Format(time.DateOnly))
+	}
+
+	p := identity.FromContext(ctx)
+	if decision := authz.Decide(p, d.Resource(), authz.ActionRead); !decision.Allowed {
+		return nil, fmt.Errorf("%w: %s", ErrDenied, decision.Reason)
+	}
+	if err := authz.CheckClient(d.Resource(), req.Client); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDenied, err)
+	}
+
+	v := d.Current()
+	if req.Version != 0 {
+		v = d.Version(req.Version)
+	}
+	if v == nil {
+		return nil, fmt.Errorf("%w: %s has no version %d", ErrNoFile, d.ID, req.Version)
+	}
+	// The draft of a published dataset's next version is for its managers.
+	if v.PublishedAt == nil && v != d.Current() && authz.Require(p, d.Resource(), authz.ActionManage) != nil {
+		return nil, fmt.Errorf("%w: version %d of %s is not published", ErrDenied, v.Number, d.ID)
+	}
+	file := findFile(v, req.File)
+	if file == nil {
+		return nil, fmt.Errorf("%w: %s version %d has no file %s", ErrNoFile, d.ID, v.Number, req.File)
+	}
+
+	email := req.Email
+	if email == "" {
+		email = p.ID
+	}
+	if i.Agreements != nil {
+		if _, err := i.Agreements.Check(ctx, d, email); err != nil {
+			if errors.Is(err, dua.ErrNotAccepted) {
+				return nil, fmt.Errorf("%w: %w", ErrDenied, err)
+			}
+			return nil, err
+		}
+	} else if d.Agreement != nil {
+		return nil, fmt.Errorf("%w: %s requires a data use agreement", ErrDenied, d.ID)
+	}
+
+	presigner, loc := i.Presigner, storage.Location{Bucket: file.Bucket, Key: file.Key}
+	if i.Router != nil {
+		routed, at, err := i.Router.Route(ctx, d, file, req.Client)
+		if err != nil {
+			return nil, err
+		}
+		if routed != nil {
+			presigner, loc = routed, at
+		}
+	}
+
+	// Charge the quota last, so that refused requests do not count.
+	if i.Quota != nil && d.Access == storage.AccessRestricted && !isAdmin(p) {
+		if _, err := i.Quota.Charge(ctx, quotaUser(p), file.Size); err != nil {
+			if errors.Is(err, quota.ErrExceeded) {
+				return nil, fmt.Errorf("%w: %w", ErrDenied, err)
+			}
+			return nil, err
+		}
+	}
+
+	expiry := i.Expiry
+	if expiry == 0 {
+		expiry = DefaultExpiry
+	}
+	g := &Grant{
+		DatasetID: d.ID,
+		Version:   v.Number,
+		File:      file.Path,
+		URL:       presigner.PresignGetObject(loc.Bucket, loc.Key, expiry),
+		Expires:   now.Add(expiry).UTC(),
+	}
+	if i.Log != nil {
+		err := audit.Record(ctx, i.Log, "access.grant", d.ID, map[string]string{
+			"version": fmt.Sprint(v.Number),
+			"file":    file.Path,
+			"email":   email,
+			"client":  clientString(req.Client),
+			"expires": g.Expires.Format(time.RFC3339),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// findFile returns the file at path in v, or nil.
+func findFile(v *dataset.Version, path string) *dataset.File {
+	for j := range v.Files {
+		if v.Files[j].Path == path {
+			return &v.Files[j]
+		}
+	}
+	return nil
+}
+
+// quotaUser returns the key p's downloads are charged to.
+func quotaUser(p identity.Principal) string {
+	if user := identity.Normalize(p.ID); user != "" {
+		return user
+	}
+	return "anonymous"
+}
+
+// isAdmin reports whether p bypasses quotas.
+func isAdmin(p identity.Principal) bool {
+	return slices.Contains(p.Groups, authz.AdminGroup)
+}
+
+// clientString formats c for the audit log.
+func clientString(c authz.Client) string {
+	s := ""
+	if c.IP.IsValid() {
+		s = c.IP.String()
+	}
+	if c.Country != "" {
+		s = strings.TrimSpace(s + " " + strings.ToUpper(c.Country))
+	}
+	return s
+}
+
+func (i *Issuer) now() time.Time {
+	if i.Now != nil {
+		return i.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,166 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package access
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/dua"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/quota"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+type fakePresigner struct{}
+
+func (fakePresigner) PresignGetObject(bucket, key string, expires time.Duration) string {
+	return "https://" + bucket + "/" + key + "?expires=" + expires.String()
+}
+
+func TestIssue(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "bob@example.org"})
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	err := datasets.Put(ctx, &dataset.Dataset{
+		ID:        "ds-1",
+		DOI:       "10.5555/ds-1",
+		Access:    storage.AccessRestricted,
+		State:     dataset.StatePublished,
+		Agreement: &dataset.Agreement{Version: "1"},
+		ACL:       &authz.ACL{Read: []string{"domain:example.org"}},
+		Versions: []dataset.Version{{
+			Number: 1,
+			Files:  []dataset.File{{Path: "a.csv", Bucket: "restricted", Key: "datasets/ds-1/v1/a.csv"}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	agreements := dua.NewRegistry(s, nil)
+	i := &Issuer{Datasets: datasets, Agreements: agreements, Presigner: fakePresigner{}}
+	req := Request{Dataset: "10.5555/DS-1", File: "a.csv"}
+
+	anon := context.Background()
+	if _, err := i.Issue(anon, req); !errors.Is(err, ErrDenied) || errors.Is(err, dua.ErrNotAccepted) {
+		t.Fatalf("Issue() for anonymous error = %v, want ACL denial", err)
+	}
+
+	if _, err := i.Issue(ctx, req); !errors.Is(err, ErrDenied) || !errors.Is(err, dua.ErrNotAccepted) {
+		t.Fatalf("Issue() before accepting error = %v, want ErrDenied and ErrNotAccepted", err)
+	}
+
+	d, _ := datasets.Get(ctx, "ds-1")
+	if _, err := agreements.Accept(ctx, d, "Bob", "bob@example.org"); err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	g, err := i.Issue(ctx, req)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if g.URL != "https://restricted/datasets/ds-1/v1/a.csv?expires=1h0m0s" || g.Version != 1 {
+		t.Errorf("Issue() = %+v", g)
+	}
+
+	d.Restriction = &authz.Restriction{CIDRs: []string{"192.0.2.0/24"}}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, err := i.Issue(ctx, req); !errors.Is(err, ErrDenied) || !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Issue() from unknown address error = %v, want network denial", err)
+	}
+	req.Client = authz.Client{IP: netip.MustParseAddr("192.0.2.10")}
+	if _, err := i.Issue(ctx, req); err != nil {
+		t.Errorf("Issue() from allowed network error = %v", err)
+	}
+
+	i.Quota = quota.NewTracker(s, quota.Limits{Objects: 1})
+	if _, err := i.Issue(ctx, req); err != nil {
+		t.Errorf("Issue() within quota error = %v", err)
+	}
+	if _, err := i.Issue(ctx, req); !errors.Is(err, ErrDenied) || !errors.Is(err, quota.ErrExceeded) {
+		t.Errorf("Issue() over quota error = %v, want ErrDenied and ErrExceeded", err)
+	}
+
+	req.File = "missing.csv"
+	if _, err := i.Issue(ctx, req); !errors.Is(err, ErrNoFile) {
+		t.Errorf("Issue() for missing file error = %v, want ErrNoFile", err)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	ctx := context.Background()
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	err := datasets.Put(ctx, &dataset.Dataset{
+		ID:          "ds-1",
+		Access:      storage.AccessRestricted,
+		State:       dataset.StatePublished,
+		Embargo:     &dataset.Embargo{Until: time.Now().Add(24 * time.Hour)},
+		Agreement:   &dataset.Agreement{Version: "1"},
+		ACL:         &authz.ACL{Read: []string{"domain:example.org"}},
+		Restriction: &authz.Restriction{Countries: []string{"US"}},
+		Versions:    []dataset.Version{{Number: 1, Files: []dataset.File{{Path: "a.csv", Size: 10}}}},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	i := &Issuer{
+		Datasets:   datasets,
+		Agreements: dua.NewRegistry(s, nil),
+		Presigner:  fakePresigner{},
+		Quota:      quota.NewTracker(s, quota.Limits{Bytes: 5}),
+	}
+
+	tests := []struct {
+		name   string
+		p      identity.Principal
+		failed []string
+	}{
+		{"outsider", identity.Principal{ID: "eve@evil.example"}, []string{CheckEmbargo, CheckACL, CheckNetwork, CheckAgreement, CheckQuota}},
+		{"member", identity.Principal{ID: "bob@example.org"}, []string{CheckEmbargo, CheckNetwork, CheckAgreement, CheckQuota}},
+		{"admin", identity.Principal{ID: "ops@example.org", Groups: []string{authz.AdminGroup}}, []string{CheckEmbargo, CheckNetwork, CheckAgreement}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim, err := i.Simulate(identity.WithPrincipal(ctx, tt.p), Request{Dataset: "ds-1", File: "a.csv"})
+			if err != nil {
+				t.Fatalf("Simulate() error = %v", err)
+			}
+			var failed []string
+			for _, c := range sim.Checks {
+				if !c.Passed {
+					failed = append(failed, c.Name)
+				}
+			}
+			if sim.Allowed || !slices.Equal(failed, tt.failed) {
+				t.Errorf("Simulate() failed checks = %v, want %v", failed, tt.failed)
+			}
+		})
+	}
+
+	usage, err := i.Quota.Usage(ctx, "bob@example.org")
+	if err != nil || usage.Objects != 0 {
+		t.Errorf("Simulate() charged the quota: %+v, %v", usage, err)
+	}
+}
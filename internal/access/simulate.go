@@ -0,0 +1,166 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package access
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// Check names.
+const (
+	CheckPublished = "published"
+	CheckEmbargo   = "embargo"
+	CheckACL       = "acl"
+	CheckNetwork   = "network"
+	CheckFile      = "file"
+	CheckAgreement = "agreement"
+	CheckQuota     = "quota"
+)
+
+// Check is the outcome of one rule applied by Issue.
+type Check struct {
+	// Name identifies the rule
+	Name string `json:"name"`
+
+	// Passed reports whether the rule allows the request
+	Passed bool `json:"passed"`
+
+	// Detail explains the outcome
+	Detail string `json:"detail"`
+}
+
+// Simulation explains whether a request would be granted.
+type Simulation struct {
+	Principal string  `json:"principal"`
+	DatasetID string  `json:"datasetId"`
+	Version   int     `json:"version,omitempty"`
+	File      string  `json:"file,omitempty"`
+	Allowed   bool    `json:"allowed"`
+	Checks    []Check `json:"checks"`
+}
+
+// Simulate evaluates req for the principal in ctx the way Issue would,
+// but applies every rule rather than stopping at the first refusal, so
+// that the result lists everything standing in the way. It charges no
+// quota, signs no URL, and records nothing. If req.File is empty the
+// file-specific rules are evaluated as for an empty file.
+func (i *Issuer) Simulate(ctx context.Context, req Request) (*Simulation, error) {
+	d, err := i.Datasets.Resolve(ctx, req.Dataset)
+	if err != nil {
+		return nil, err
+	}
+	now := i.now()
+	p := identity.FromContext(ctx)
+	sim := &Simulation{Principal: p.String(), DatasetID: d.ID, File: req.File, Allowed: true}
+	check := func(name string, passed bool, format string, args ...any) {
+		sim.Checks = append(sim.Checks, Check{Name: name, Passed: passed, Detail: fmt.Sprintf(format, args...)})
+		sim.Allowed = sim.Allowed && passed
+	}
+
+	if d.State == dataset.StatePublished {
+		check(CheckPublished, true, "%s is published", d.ID)
+	} else {
+		check(CheckPublished, false, "%s is %s, not published", d.ID, d.State)
+	}
+
+	switch {
+	case d.Embargoed(now):
+		check(CheckEmbargo, false, "under embargo until %s", d.Embargo.Until.Format(time.DateOnly))
+	case d.Embargo != nil:
+		check(CheckEmbargo, true, "embargo ended %s", d.Embargo.Until.Format(time.DateOnly))
+	default:
+		check(CheckEmbargo, true, "no embargo")
+	}
+
+	decision := authz.Decide(p, d.Resource(), authz.ActionRead)
+	check(CheckACL, decision.Allowed, "%s", decision.Reason)
+
+	switch err := authz.CheckClient(d.Resource(), req.Client); {
+	case err != nil:
+		check(CheckNetwork, false, "%v", err)
+	case d.Restriction.IsZero():
+		check(CheckNetwork, true, "no network or country restriction")
+	default:
+		check(CheckNetwork, true, "request from %s is within the restriction", clientString(req.Client))
+	}
+
+	v := d.Current()
+	if req.Version != 0 {
+		v = d.Version(req.Version)
+	}
+	var file *dataset.File
+	switch {
+	case v == nil:
+		check(CheckFile, false, "%s has no version %d", d.ID, req.Version)
+	case req.File == "":
+		sim.Version = v.Number
+	default:
+		sim.Version = v.Number
+		if file = findFile(v, req.File); file != nil {
+			check(CheckFile, true, "version %d has %s (%d bytes)", v.Number, file.Path, file.Size)
+		} else {
+			check(CheckFile, false, "version %d has no file %s", v.Number, req.File)
+		}
+	}
+	if v != nil && v.PublishedAt == nil && v != d.Current() && authz.Require(p, d.Resource(), authz.ActionManage) != nil {
+		check(CheckPublished, false, "version %d of %s is not published", v.Number, d.ID)
+	}
+
+	email := req.Email
+	if email == "" {
+		email = p.ID
+	}
+	switch {
+	case d.Agreement == nil:
+		check(CheckAgreement, true, "no data use agreement required")
+	case i.Agreements == nil:
+		check(CheckAgreement, false, "%s requires a data use agreement", d.ID)
+	default:
+		a, err := i.Agreements.Check(ctx, d, email)
+		if err != nil {
+			check(CheckAgreement, false, "%v", err)
+		} else {
+			check(CheckAgreement, true, "%s accepted version %s on %s", a.Email, d.Agreement.Version, a.AcceptedAt.Format(time.DateOnly))
+		}
+	}
+
+	switch {
+	case d.Access != storage.AccessRestricted:
+		check(CheckQuota, true, "quotas apply only to restricted datasets")
+	case i.Quota == nil:
+		check(CheckQuota, true, "no download quota configured")
+	case isAdmin(p):
+		check(CheckQuota, true, "administrators are exempt from quotas")
+	default:
+		var size int64
+		if file != nil {
+			size = file.Size
+		}
+		u, err := i.Quota.Check(ctx, quotaUser(p), size)
+		if err != nil {
+			check(CheckQuota, false, "%v", err)
+		} else {
+			check(CheckQuota, true, "%d objects and %d bytes downloaded today (UTC)", u.Objects, u.Bytes)
+		}
+	}
+	return sim, nil
+}
@@ -0,0 +1,501 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package alert saves searches and tells their owners when newly
+// published datasets match them.
+//
+// A saved search holds a search query and where to send alerts: an
+// email address, a webhook, or both. A scheduled run evaluates every
+// saved search against the index and sends one alert per saved search
+// listing the datasets published since it was saved that it has not
+// alerted on before. Datasets that reach the index late, for example
+// after an indexing retry, are still caught: each run looks back over
+// Lookback, and the datasets already alerted on within it are
+// remembered. A delivery that fails is retried by the next run.
+//
+// Webhook requests are signed with a secret generated for each saved
+// search, as an HMAC-SHA256 of the body in the X-Aperture-Signature
+// header, so that receivers can check that alerts came from Aperture.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/notify"
+	"github.com/scttfrdmn/aperture/internal/search"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// alertsTable holds saved searches keyed by ID.
+const alertsTable = "saved-searches"
+
+// MaxPerOwner is the number of searches one person may save.
+const MaxPerOwner = 50
+
+// Lookback is how far before the previous run each run searches, to
+// catch datasets indexed after they were published.
+const Lookback = 7 * 24 * time.Hour
+
+// SignatureHeader carries the webhook signature, "sha256=" and the hex
+// HMAC-SHA256 of the body keyed with the saved search's secret.
+const SignatureHeader = "X-Aperture-Signature"
+
+// maxMatches bounds the matches read per run, as the index pages
+// through no more than this many results.
+const maxMatches = 10000
+
+var (
+	// ErrNotFound is returned for an unknown saved search.
+	ErrNotFound = errors.New("saved search not found")
+
+	// ErrLimit is returned when an owner has saved MaxPerOwner searches.
+	ErrLimit = errors.New("too many saved searches")
+)
+
+// Alert is a saved search and where its alerts are sent.
+type Alert struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+
+	// Query is the search; its page and size are ignored
+	Query search.Query `json:"query"`
+
+	// Email receives alerts; none are emailed if empty
+	Email string `json:"email,omitempty"`
+
+	// Webhook receives alerts as signed JSON POST requests; none are
+	// posted if empty
+	Webhook string `json:"webhook,omitempty"`
+
+	// Secret signs webhook requests; cleared in listings
+	Secret string `json:"secret,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Checked is when the search was last run
+	Checked *time.Time `json:"checked,omitempty"`
+
+	// Alerted maps the datasets alerted on within Lookback to when
+	// they were published
+	Alerted map[string]time.Time `json:"alerted,omitempty"`
+}
+
+// Match is a newly published dataset matching a saved search.
+type Match struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	DOI       string    `json:"doi,omitempty"`
+	URL       string    `json:"url"`
+	Published time.Time `json:"published"`
+}
+
+// Payload is the body of a webhook request.
+type Payload struct {
+	Alert    string       `json:"alert"`
+	Name     string       `json:"name"`
+	Query    search.Query `json:"query"`
+	Datasets []Match      `json:"datasets"`
+	Sent     time.Time    `json:"sent"`
+}
+
+// Delivery is the outcome of a run for one saved search with new
+// matches.
+type Delivery struct {
+	Alert    string   `json:"alert"`
+	Owner    string   `json:"owner"`
+	Datasets []string `json:"datasets"`
+	Channels []string `json:"channels"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Searcher runs search queries. *search.Searcher implements it.
+type Searcher interface {
+	Search(ctx context.Context, q *search.Query) (*search.Results, error)
+}
+
+// Registry stores saved searches and runs them.
+type Registry struct {
+	// State holds saved searches
+	State state.Store
+
+	// Searcher runs the saved queries
+	Searcher Searcher
+
+	// Notifier sends email alerts
+	Notifier notify.Notifier
+
+	// HTTPClient posts webhooks; http.DefaultClient if nil
+	HTTPClient *http.Client
+
+	// Log records changes to saved searches; nothing is recorded if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Get returns the saved search id if the acting principal owns it or
+// is an administrator.
+func (r *Registry) Get(ctx context.Context, id string) (*Alert, error) {
+	a, err := r.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOwner(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (r *Registry) get(ctx context.Context, id string) (*Alert, error) {
+	var a Alert
+	if err := r.State.Get(ctx, alertsTable, id, &a); err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+// List returns the acting principal's saved searches, or everyone's if
+// all is set and the principal is an administrator, oldest first, with
+// their secrets cleared.
+func (r *Registry) List(ctx context.Context, all bool) ([]Alert, error) {
+	p := identity.FromContext(ctx)
+	if all {
+		if err := authz.RequirePermission(p, authz.PermMaintain); err != nil {
+			return nil, err
+		}
+	}
+	alerts, err := r.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := []Alert{}
+	for _, a := range alerts {
+		if all || a.Owner == identity.Normalize(p.ID) {
+			a.Secret = ""
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (r *Registry) list(ctx context.Context) ([]Alert, error) {
+	alerts, err := state.List[Alert](ctx, r.State, alertsTable)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].CreatedAt.Equal(alerts[j].CreatedAt) {
+			return alerts[i].CreatedAt.Before(alerts[j].CreatedAt)
+		}
+		return alerts[i].ID < alerts[j].ID
+	})
+	return alerts, nil
+}
+
+// Save saves a search for the acting principal, who must be signed in.
+// Alerts are emailed to email, or to the principal if neither email nor
+// webhook is given, and posted to webhook, which must use HTTPS. The
+// returned saved search holds the webhook secret.
+func (r *Registry) Save(ctx context.Context, name string, q search.Query, email, webhook string) (*Alert, error) {
+	p := identity.FromContext(ctx)
+	if p.IsZero() {
+		return nil, fmt.Errorf("%w: sign in to save searches", authz.ErrForbidden)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("a saved search needs a name")
+	}
+	q.Page, q.Size = 0, 0
+	check := q
+	if _, err := r.Searcher.Search(ctx, &check); err != nil && !errors.Is(err, search.ErrNoIndex) {
+		return nil, fmt.Errorf("invalid search: %w", err)
+	}
+	owner := identity.Normalize(p.ID)
+	if email == "" && webhook == "" {
+		email = owner
+	}
+	if email != "" {
+		addr, err := mail.ParseAddress(email)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email address %q", email)
+		}
+		email = addr.Address
+	}
+	if webhook != "" {
+		u, err := url.Parse(webhook)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("invalid webhook %q: must be an https URL", webhook)
+		}
+	}
+
+	alerts, err := r.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	for _, a := range alerts {
+		if a.Owner == owner {
+			n++
+		}
+	}
+	if n >= MaxPerOwner {
+		return nil, fmt.Errorf("%w: %s has saved %d; delete one first", ErrLimit, owner, n)
+	}
+
+	a := &Alert{Name: name, Owner: owner, Query: q, Email: email, Webhook: webhook, CreatedAt: r.now().UTC()}
+	if a.ID, err = randomHex(8); err != nil {
+		return nil, err
+	}
+	if webhook != "" {
+		if a.Secret, err = randomHex(32); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.State.Put(ctx, alertsTable, a.ID, a); err != nil {
+		return nil, err
+	}
+	return a, r.record(ctx, "alert.save", a.ID, map[string]string{"name": a.Name, "email": a.Email, "webhook": a.Webhook})
+}
+
+// Delete removes a saved search owned by the acting principal, or any
+// saved search if the principal is an administrator.
+func (r *Registry) Delete(ctx context.Context, id string) error {
+	a, err := r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := r.State.Delete(ctx, alertsTable, a.ID); err != nil {
+		return err
+	}
+	return r.record(ctx, "alert.delete", a.ID, map[string]string{"name": a.Name, "owner": a.Owner})
+}
+
+// Run evaluates every saved search and alerts the owners of those with
+// new matches. It returns the deliveries attempted; failed deliveries
+// are retried by the next run, and their errors are joined in the
+// returned error.
+func (r *Registry) Run(ctx context.Context) ([]Delivery, error) {
+	alerts, err := r.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := r.now().UTC()
+	var out []Delivery
+	var errs []error
+	for i := range alerts {
+		a := &alerts[i]
+		matches, err := r.matches(ctx, a, now)
+		if errors.Is(err, search.ErrNoIndex) {
+			return out, err
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("saved search %s: %w", a.ID, err))
+			continue
+		}
+		if len(matches) > 0 {
+			d := Delivery{Alert: a.ID, Owner: a.Owner}
+			for _, m := range matches {
+				d.Datasets = append(d.Datasets, m.ID)
+			}
+			if err := r.deliver(ctx, a, matches, now, &d); err != nil {
+				d.Error = err.Error()
+				out = append(out, d)
+				errs = append(errs, fmt.Errorf("saved search %s: %w", a.ID, err))
+				continue
+			}
+			out = append(out, d)
+			for _, m := range matches {
+				if a.Alerted == nil {
+					a.Alerted = map[string]time.Time{}
+				}
+				a.Alerted[m.ID] = m.Published
+			}
+		}
+		for id, published := range a.Alerted {
+			if published.Before(now.Add(-Lookback)) {
+				delete(a.Alerted, id)
+			}
+		}
+		a.Checked = &now
+		if err := r.State.Put(ctx, alertsTable, a.ID, a); err != nil {
+			return out, err
+		}
+	}
+	return out, errors.Join(errs...)
+}
+
+// matches returns the datasets matching a that were published after it
+// was saved and have not been alerted on, oldest first.
+func (r *Registry) matches(ctx context.Context, a *Alert, now time.Time) ([]Match, error) {
+	since := a.CreatedAt
+	if a.Checked != nil && a.Checked.Add(-Lookback).After(since) {
+		since = a.Checked.Add(-Lookback)
+	}
+	q := a.Query
+	// Dates compare as strings. The window's start is given no more
+	// precisely than Until, which would otherwise read as the start of
+	// its month or year and could fall before it; matches are compared
+	// with since exactly below.
+	from := since.Format("2006-01-02")
+	if q.Until != "" {
+		from = from[:min(len(from), len(q.Until))]
+		if q.Until < from {
+			return nil, nil
+		}
+	}
+	if from > q.From {
+		q.From = from
+	}
+	q.Size = search.MaxPageSize
+	var out []Match
+	for q.Page = 1; ; q.Page++ {
+		res, err := r.Searcher.Search(ctx, &q)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range res.Hits {
+			if h.Published == nil || !h.Published.After(since) || h.Published.After(now) {
+				continue
+			}
+			if _, ok := a.Alerted[h.ID]; ok {
+				continue
+			}
+			out = append(out, Match{ID: h.ID, Title: h.Title, DOI: h.DOI, URL: h.URL, Published: h.Published.UTC()})
+		}
+		if len(res.Hits) < q.Size || q.Page*q.Size >= res.Total || (q.Page+1)*q.Size > maxMatches {
+			break
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Published.Before(out[j].Published) })
+	return out, nil
+}
+
+// deliver sends an alert of matches to a's email address and webhook.
+func (r *Registry) deliver(ctx context.Context, a *Alert, matches []Match, now time.Time, d *Delivery) error {
+	if a.Email != "" {
+		if err := r.Notifier.Notify(ctx, message(a, matches)); err != nil {
+			return err
+		}
+		d.Channels = append(d.Channels, "email")
+	}
+	if a.Webhook != "" {
+		if err := r.post(ctx, a, Payload{Alert: a.ID, Name: a.Name, Query: a.Query, Datasets: matches, Sent: now}); err != nil {
+			return err
+		}
+		d.Channels = append(d.Channels, "webhook")
+	}
+	return nil
+}
+
+func message(a *Alert, matches []Match) notify.Message {
+	var b strings.Builder
+	noun := "datasets match"
+	if len(matches) == 1 {
+		noun = "dataset matches"
+	}
+	fmt.Fprintf(&b, "%d newly published %s your saved search %q:\n\n", len(matches), noun, a.Name)
+	for _, m := range matches {
+		fmt.Fprintf(&b, "- %s\n  %s\n", m.Title, m.URL)
+	}
+	fmt.Fprintf(&b, "\nTo stop these alerts, run 'aperture alert delete %s'.\n", a.ID)
+	return notify.Message{To: a.Email, Subject: "New datasets: " + a.Name, Body: b.String()}
+}
+
+// post sends p to a's webhook, signed with a's secret.
+func (r *Registry) post(ctx context.Context, a *Alert, p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(a.Secret, body))
+	client := r.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s returned HTTP %d", a.Webhook, resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature of a webhook body keyed with secret, as
+// sent in SignatureHeader.
+func Sign(secret string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
+
+// requireOwner returns an error wrapping authz.ErrForbidden unless the
+// acting principal owns a or is an administrator.
+func requireOwner(ctx context.Context, a *Alert) error {
+	res := authz.Resource{ID: a.ID, ACL: &authz.ACL{Manage: []string{"user:" + a.Owner}}}
+	return authz.Require(identity.FromContext(ctx), res, authz.ActionManage)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (r *Registry) record(ctx context.Context, action, target string, details map[string]string) error {
+	if r.Log == nil {
+		return nil
+	}
+	return audit.Record(ctx, r.Log, action, target, details)
+}
+
+func (r *Registry) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
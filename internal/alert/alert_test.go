@@ -0,0 +1,288 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/notify"
+	"github.com/scttfrdmn/aperture/internal/search"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// fakeSearcher matches documents whose title contains the query text
+// and that were published on or after its from date.
+type fakeSearcher struct {
+	mu      sync.Mutex
+	docs    []search.Document
+	queries []search.Query
+}
+
+func (f *fakeSearcher) add(id, title string, published time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.docs = append(f.docs, search.Document{ID: id, Title: title, URL: "https://data.uni.edu/datasets/" + id, Published: &published})
+}
+
+func (f *fakeSearcher) Search(_ context.Context, q *search.Query) (*search.Results, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries = append(f.queries, *q)
+	res := &search.Results{}
+	for _, d := range f.docs {
+		if !strings.Contains(strings.ToLower(d.Title), strings.ToLower(q.Text)) {
+			continue
+		}
+		if q.From != "" && d.Published.Format("2006-01-02")[:len(q.From)] < q.From {
+			continue
+		}
+		res.Hits = append(res.Hits, search.Hit{Document: d})
+	}
+	res.Total = len(res.Hits)
+	return res, nil
+}
+
+func TestSave(t *testing.T) {
+	alice := identity.WithPrincipal(context.Background(), identity.Principal{ID: "Alice@uni.edu"})
+	bob := identity.WithPrincipal(context.Background(), identity.Principal{ID: "bob@uni.edu"})
+	admin := identity.WithPrincipal(context.Background(), identity.Principal{ID: "root@uni.edu", Groups: []string{"admins"}})
+	r := &Registry{State: state.NewMemoryStore(), Searcher: &fakeSearcher{}, Log: &audit.MemoryLog{}}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		email   string
+		webhook string
+		want    string
+		wantErr bool
+	}{
+		{name: "default email", ctx: alice, want: "alice@uni.edu"},
+		{name: "email", ctx: alice, email: "Lab <lab@uni.edu>", want: "lab@uni.edu"},
+		{name: "webhook only", ctx: bob, webhook: "https://hooks.uni.edu/x"},
+		{name: "anonymous", ctx: context.Background(), wantErr: true},
+		{name: "bad email", ctx: alice, email: "not an address", wantErr: true},
+		{name: "http webhook", ctx: alice, webhook: "http://hooks.uni.edu/x", wantErr: true},
+	}
+	ids := map[string]string{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := r.Save(tt.ctx, "Soil", search.Query{Text: "soil", Page: 3}, tt.email, tt.webhook)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Save() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if a.Email != tt.want {
+				t.Errorf("Save() email = %q, want %q", a.Email, tt.want)
+			}
+			if (a.Secret != "") != (tt.webhook != "") {
+				t.Errorf("Save() secret = %q with webhook %q", a.Secret, tt.webhook)
+			}
+			if a.Query.Page != 0 {
+				t.Errorf("Save() page = %d, want 0", a.Query.Page)
+			}
+			ids[tt.name] = a.ID
+		})
+	}
+
+	mine, err := r.List(alice, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mine) != 2 {
+		t.Errorf("List() = %d saved searches, want 2", len(mine))
+	}
+	if _, err := r.List(alice, true); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("List(all) error = %v, want ErrForbidden", err)
+	}
+	all, err := r.List(admin, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range all {
+		if a.Secret != "" {
+			t.Errorf("List() secret of %s = %q, want cleared", a.ID, a.Secret)
+		}
+	}
+	if len(all) != 3 {
+		t.Errorf("List(all) = %d saved searches, want 3", len(all))
+	}
+
+	if err := r.Delete(bob, ids["default email"]); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Delete() by other user error = %v, want ErrForbidden", err)
+	}
+	if err := r.Delete(alice, ids["default email"]); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if err := r.Delete(admin, ids["webhook only"]); err != nil {
+		t.Errorf("Delete() by admin error = %v", err)
+	}
+	if _, err := r.Get(alice, ids["default email"]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	alice := identity.WithPrincipal(context.Background(), identity.Principal{ID: "alice@uni.edu"})
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var mu sync.Mutex
+	var bodies [][]byte
+	var signatures []string
+	fail := false
+	hook := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, b)
+		signatures = append(signatures, r.Header.Get(SignatureHeader))
+	}))
+	defer hook.Close()
+
+	searcher := &fakeSearcher{}
+	searcher.add("before", "Soil cores 2024", now.AddDate(0, 0, -1))
+	recorder := &notify.Recorder{}
+	r := &Registry{
+		State:      state.NewMemoryStore(),
+		Searcher:   searcher,
+		Notifier:   recorder,
+		HTTPClient: hook.Client(),
+		Now:        func() time.Time { return now },
+	}
+	a, err := r.Save(alice, "Soil", search.Query{Text: "soil"}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooked, err := r.Save(alice, "Soil hook", search.Query{Text: "soil"}, "", hook.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(wantAlerts []string, wantErr bool) []Delivery {
+		t.Helper()
+		out, err := r.Run(context.Background())
+		if (err != nil) != wantErr {
+			t.Fatalf("Run() error = %v, wantErr %v", err, wantErr)
+		}
+		var got []string
+		for _, d := range out {
+			if d.Error == "" {
+				got = append(got, d.Alert+":"+strings.Join(d.Datasets, ","))
+			}
+		}
+		// Alerts run in ID order, and IDs are random.
+		sort.Strings(got)
+		sort.Strings(wantAlerts)
+		if !reflect.DeepEqual(got, wantAlerts) {
+			t.Errorf("Run() delivered %v, want %v", got, wantAlerts)
+		}
+		return out
+	}
+
+	// Nothing was published after the searches were saved.
+	now = now.Add(time.Hour)
+	run(nil, false)
+
+	searcher.add("new", "Soil moisture", now.Add(-time.Minute))
+	searcher.add("other", "Ocean salinity", now.Add(-time.Minute))
+	now = now.Add(time.Hour)
+	run([]string{a.ID + ":new", hooked.ID + ":new"}, false)
+	if len(recorder.Messages) != 1 {
+		t.Fatalf("Run() sent %d emails, want 1", len(recorder.Messages))
+	}
+	if m := recorder.Messages[0]; m.To != "alice@uni.edu" || !strings.Contains(m.Body, "Soil moisture") || !strings.Contains(m.Body, "/datasets/new") {
+		t.Errorf("Run() email = %+v", m)
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("Run() posted %d webhooks, want 1", len(bodies))
+	}
+	if signatures[0] != Sign(hooked.Secret, bodies[0]) {
+		t.Errorf("Run() webhook signature = %q, want %q", signatures[0], Sign(hooked.Secret, bodies[0]))
+	}
+	var p Payload
+	if err := json.Unmarshal(bodies[0], &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Alert != hooked.ID || len(p.Datasets) != 1 || p.Datasets[0].ID != "new" {
+		t.Errorf("Run() webhook payload = %+v", p)
+	}
+
+	// Datasets are alerted on once, even if indexed late.
+	searcher.add("late", "Soil carbon", now.Add(-90*time.Minute))
+	now = now.Add(time.Hour)
+	run([]string{a.ID + ":late", hooked.ID + ":late"}, false)
+
+	// A failed webhook is retried by the next run.
+	fail = true
+	searcher.add("retry", "Soil nitrogen", now.Add(-time.Minute))
+	now = now.Add(time.Hour)
+	out := run([]string{a.ID + ":retry"}, true)
+	if len(out) != 2 || out[0].Error+out[1].Error == "" {
+		t.Errorf("Run() deliveries = %+v, want a failed webhook", out)
+	}
+	fail = false
+	now = now.Add(time.Hour)
+	run([]string{hooked.ID + ":retry"}, false)
+
+	// Alerted datasets are forgotten after the lookback.
+	now = now.Add(Lookback + time.Hour)
+	run(nil, false)
+	got, err := r.get(context.Background(), a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Alerted) != 0 {
+		t.Errorf("Run() kept alerted datasets %v after the lookback", got.Alerted)
+	}
+	last := searcher.queries[len(searcher.queries)-1]
+	if want := now.Add(-time.Hour - Lookback).Format("2006-01-02"); last.From != want {
+		t.Errorf("Run() searched from %q, want %q", last.From, want)
+	}
+}
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret string
+		body   string
+		want   string
+	}{
+		// RFC 4231 test case 2.
+		{"Jefe", "what do ya want for nothing?", "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+	}
+	for _, tt := range tests {
+		if got := Sign(tt.secret, []byte(tt.body)); got != tt.want {
+			t.Errorf("Sign(%q, %q) = %q, want %q", tt.secret, tt.body, got, tt.want)
+		}
+	}
+}
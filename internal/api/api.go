@@ -0,0 +1,450 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package api serves the platform's REST API as a single http.Handler:
+//
+//	GET  /datasets           the published datasets
+//	GET  /datasets/{ref...}  one dataset, by ID or DOI
+//	POST /access             whether an access request would be granted, and why
+//	POST /agreements         accept a dataset's data use agreement
+//	POST /presign            a time-limited download URL for a file
+//	GET  /search, /suggest   search (see search.Handler)
+//	GET  /stats/...          usage statistics (see counter.StatsHandler)
+//	POST /auth/login         sign in to the Cognito user pool (see auth.go)
+//	POST /auth/refresh       renew the tokens of a sign-in
+//	POST /auth/logout        end every session of the caller
+//	GET  /auth/verify        the caller's identity
+//	POST /doi/mint           mint an identifier for a published dataset (see doi.go)
+//	PUT  /doi/{ref...}       re-register a dataset's identifier metadata
+//
+// Each group of routes is served only if configured, so that a Lambda
+// function per group (see cmd/lambdas) can serve its share of the API
+// with the same handler.
+//
+// 'aperture serve' runs the handler as an HTTP server, so that a small
+// institution can run the platform on one machine without API Gateway,
+// and as a Lambda function behind API Gateway (see Runtime), so that
+// both deployments answer with the same code.
+//
+// Callers authenticate with a machine token as a bearer token, or
+// through the API Gateway JWT authorizer when run as a Lambda function;
+// everyone else is anonymous and sees what anonymous users may.
+package api
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/access"
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/cognito"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/deposit"
+	"github.com/scttfrdmn/aperture/internal/dua"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/storage"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+// maxBody bounds request bodies.
+const maxBody = 1 << 20
+
+// Authenticator resolves a bearer token to the principal it belongs to.
+type Authenticator func(ctx context.Context, secret string) (identity.Principal, error)
+
+// Options configures a Handler.
+type Options struct {
+	// Datasets is the dataset catalog; /datasets is not served if nil
+	Datasets *dataset.Store
+
+	// Issuer answers access requests and signs download URLs; /access
+	// and /presign are not served if nil
+	Issuer *access.Issuer
+
+	// Agreements records data use agreement acceptances; POST
+	// /agreements is not served if nil
+	Agreements *dua.Registry
+
+	// Search serves /search, /suggest, and /related/; not served if
+	// nil
+	Search http.Handler
+
+	// Stats serves /stats/; not served if nil
+	Stats http.Handler
+
+	// Accounts signs users in through the app client ClientID, which
+	// must allow the ADMIN_USER_PASSWORD_AUTH flow; /auth/ is not
+	// served if nil
+	Accounts *cognito.Client
+	ClientID string
+
+	// Deposits mints and re-registers identifiers; /doi/ is not served
+	// if nil
+	Deposits *deposit.Manager
+
+	// Authenticate resolves bearer tokens: machine tokens, and where no
+	// API Gateway authorizer has checked them, users' ID tokens;
+	// requests carrying one are refused if nil
+	Authenticate Authenticator
+
+	// Resolve completes an authenticated principal, e.g. with the
+	// groups of its roles; principals are used as authenticated if nil
+	Resolve func(ctx context.Context, p identity.Principal) (identity.Principal, error)
+
+	// OriginSecret is the secret a CloudFront distribution in front of
+	// the API sends with its origin requests; the viewer country is
+	// unknown without it (see authz.ClientOf)
+	OriginSecret string
+}
+
+// Record is the view of a dataset served by the API: its descriptive
+// metadata and the files of its current version, without the storage
+// locations of the files.
+type Record struct {
+	ID              string            `json:"id"`
+	DOI             string            `json:"doi,omitempty"`
+	Title           string            `json:"title"`
+	Description     string            `json:"description,omitempty"`
+	Creators        []dataset.Creator `json:"creators,omitempty"`
+	PublicationYear int               `json:"publicationYear,omitempty"`
+	Collection      string            `json:"collection,omitempty"`
+	Subjects        []string          `json:"subjects,omitempty"`
+	License         string            `json:"license,omitempty"`
+	Access          storage.Access    `json:"access"`
+	State           dataset.State     `json:"state"`
+	EmbargoUntil    *time.Time        `json:"embargoUntil,omitempty"`
+	Agreement       string            `json:"agreement,omitempty"`
+	Version         int               `json:"version,omitempty"`
+	PublishedAt     *time.Time        `json:"publishedAt,omitempty"`
+	Files           []RecordFile      `json:"files,omitempty"`
+}
+
+// RecordFile is one file of a Record.
+type RecordFile struct {
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// Request asks about or for one file of a dataset, in the body of POST
+// /access and POST /presign.
+type Request struct {
+	Dataset string `json:"dataset"`
+	Version int    `json:"version,omitempty"`
+	File    string `json:"file"`
+
+	// Email identifies the requester for agreement checks; the
+	// principal ID if empty
+	Email string `json:"email,omitempty"`
+}
+
+// Acceptance accepts a dataset's data use agreement, in the body of
+// POST /agreements.
+type Acceptance struct {
+	Dataset string `json:"dataset"`
+	Name    string `json:"name"`
+
+	// Email is the address the agreement is accepted with; the
+	// principal ID if empty
+	Email string `json:"email,omitempty"`
+}
+
+// Handler serves the API.
+type Handler struct {
+	opts Options
+	mux  *http.ServeMux
+}
+
+// NewHandler returns a handler serving the API configured by opts.
+func NewHandler(opts Options) *Handler {
+	h := &Handler{opts: opts, mux: http.NewServeMux()}
+	if opts.Datasets != nil {
+		h.mux.HandleFunc("GET /datasets", h.serveDatasets)
+		h.mux.HandleFunc("GET /datasets/{ref...}", h.serveDataset)
+	}
+	if opts.Issuer != nil {
+		h.mux.HandleFunc("POST /access", h.serveAccess)
+		h.mux.HandleFunc("POST /presign", h.servePresign)
+	}
+	if opts.Agreements != nil {
+		h.mux.HandleFunc("POST /agreements", h.serveAgreement)
+	}
+	if opts.Search != nil {
+		h.mux.Handle("GET /search", opts.Search)
+		h.mux.Handle("GET /suggest", opts.Search)
+		h.mux.Handle("GET /related/", opts.Search)
+	}
+	if opts.Stats != nil {
+		h.mux.Handle("GET /stats/", opts.Stats)
+	}
+	if opts.Accounts != nil {
+		h.mux.HandleFunc("POST /auth/login", h.serveLogin)
+		h.mux.HandleFunc("POST /auth/refresh", h.serveRefresh)
+		h.mux.HandleFunc("POST /auth/logout", h.serveLogout)
+		h.mux.HandleFunc("GET /auth/verify", h.serveVerify)
+	}
+	if opts.Deposits != nil {
+		h.mux.HandleFunc("POST /doi/mint", h.serveMint)
+		h.mux.HandleFunc("PUT /doi/{ref...}", h.serveUpdate)
+	}
+	return h
+}
+
+// ServeHTTP implements http.Handler. Every request acts as the
+// principal it authenticated as, or anonymously.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p, err := h.principal(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	ctx := identity.WithPrincipal(audit.WithRequest(r), p)
+	h.mux.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// principal returns the principal r authenticated as: the one named by
+// the claims of an API Gateway authorizer, the one Authenticate
+// resolves a bearer token to, or the anonymous principal.
+func (h *Handler) principal(r *http.Request) (identity.Principal, error) {
+	ctx := r.Context()
+	p, ok := gatewayPrincipal(ctx)
+	if !ok {
+		secret, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			return identity.Principal{}, nil
+		}
+		if h.opts.Authenticate == nil {
+			return identity.Principal{}, errors.New("bearer tokens are not accepted")
+		}
+		var err error
+		if p, err = h.opts.Authenticate(ctx, secret); err != nil {
+			return identity.Principal{}, err
+		}
+	}
+	if h.opts.Resolve != nil && !p.IsZero() {
+		return h.opts.Resolve(ctx, p)
+	}
+	return p, nil
+}
+
+func (h *Handler) serveDatasets(w http.ResponseWriter, r *http.Request) {
+	if !requireScope(w, r, token.ScopeDatasetsRead) {
+		return
+	}
+	all, err := h.opts.Datasets.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	records := []Record{}
+	for _, d := range all {
+		if d.State == dataset.StatePublished {
+			records = append(records, record(d))
+		}
+	}
+	slices.SortFunc(records, func(a, b Record) int { return strings.Compare(a.ID, b.ID) })
+	writeJSON(w, http.StatusOK, records)
+}
+
+func (h *Handler) serveDataset(w http.ResponseWriter, r *http.Request) {
+	if !requireScope(w, r, token.ScopeDatasetsRead) {
+		return
+	}
+	ctx := r.Context()
+	d, err := h.opts.Datasets.Resolve(ctx, r.PathValue("ref"))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	switch {
+	case d.State == dataset.StateTombstoned:
+		writeError(w, http.StatusGone, fmt.Errorf("%s has been withdrawn", d.ID))
+		return
+	// Drafts are for their managers; others cannot tell them apart
+	// from datasets that do not exist.
+	case d.State != dataset.StatePublished && authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage) != nil:
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", dataset.ErrNotFound, r.PathValue("ref")))
+		return
+	}
+	writeJSON(w, http.StatusOK, record(d))
+}
+
+func (h *Handler) serveAccess(w http.ResponseWriter, r *http.Request) {
+	if !requireScope(w, r, token.ScopeDatasetsRead) {
+		return
+	}
+	var req Request
+	if !readJSON(w, r, &req) {
+		return
+	}
+	sim, err := h.opts.Issuer.Simulate(r.Context(), h.access(req, r))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sim)
+}
+
+func (h *Handler) servePresign(w http.ResponseWriter, r *http.Request) {
+	if !requireScope(w, r, token.ScopeDownload) {
+		return
+	}
+	var req Request
+	if !readJSON(w, r, &req) {
+		return
+	}
+	g, err := h.opts.Issuer.Issue(r.Context(), h.access(req, r))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, g)
+}
+
+func (h *Handler) serveAgreement(w http.ResponseWriter, r *http.Request) {
+	if !requireScope(w, r, token.ScopeDownload) {
+		return
+	}
+	var req Acceptance
+	if !readJSON(w, r, &req) {
+		return
+	}
+	ctx := r.Context()
+	email := cmp.Or(req.Email, identity.FromContext(ctx).ID)
+	if strings.TrimSpace(req.Name) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("name is required to accept a data use agreement"))
+		return
+	}
+	if _, err := mail.ParseAddress(email); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid email address %q", email))
+		return
+	}
+	d, err := h.opts.Datasets.Resolve(ctx, req.Dataset)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	if d.State != dataset.StatePublished {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", dataset.ErrNotFound, req.Dataset))
+		return
+	}
+	a, err := h.opts.Agreements.Accept(ctx, d, req.Name, email)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, a)
+}
+
+// access returns req as an access request from the client of r.
+func (h *Handler) access(req Request, r *http.Request) access.Request {
+	return access.Request{
+		Dataset: req.Dataset,
+		Version: req.Version,
+		File:    req.File,
+		Email:   req.Email,
+		Client:  authz.ClientOf(r, h.opts.OriginSecret),
+	}
+}
+
+// record returns the API view of d.
+func record(d *dataset.Dataset) Record {
+	rec := Record{
+		ID:              d.ID,
+		DOI:             d.DOI,
+		Title:           d.Title,
+		Description:     d.Description,
+		Creators:        d.Creators,
+		PublicationYear: d.PublicationYear,
+		Collection:      d.Collection,
+		Subjects:        d.Subjects,
+		License:         d.License,
+		Access:          d.Access,
+		State:           d.State,
+	}
+	if d.Embargo != nil {
+		rec.EmbargoUntil = &d.Embargo.Until
+	}
+	if d.Agreement != nil {
+		rec.Agreement = d.Agreement.Version
+	}
+	v := d.Current()
+	if v == nil {
+		v = d.Latest()
+	}
+	if v != nil {
+		rec.Version, rec.PublishedAt = v.Number, v.PublishedAt
+		for _, f := range v.Files {
+			rec.Files = append(rec.Files, RecordFile{Path: f.Path, Size: f.Size, SHA256: f.SHA256, ContentType: f.ContentType})
+		}
+	}
+	return rec
+}
+
+// requireScope reports whether the principal of r may use scope,
+// answering 403 if not.
+func requireScope(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if p := identity.FromContext(r.Context()); !p.HasScope(scope) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("%s lacks the %s scope", p, scope))
+		return false
+	}
+	return true
+}
+
+// statusOf returns the HTTP status reporting err.
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, dataset.ErrNotFound), errors.Is(err, access.ErrNoFile):
+		return http.StatusNotFound
+	case errors.Is(err, access.ErrDenied), errors.Is(err, authz.ErrForbidden), errors.Is(err, cognito.ErrChallenge):
+		return http.StatusForbidden
+	case errors.Is(err, cognito.ErrNotAuthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, dua.ErrNoAgreement):
+		return http.StatusBadRequest
+	case errors.Is(err, pid.ErrNotConfigured):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,429 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/access"
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/cognito"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/deposit"
+	"github.com/scttfrdmn/aperture/internal/dua"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+type fakePresigner struct{}
+
+func (fakePresigner) PresignGetObject(bucket, key string, expires time.Duration) string {
+	return "https://" + bucket + "/" + key
+}
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	ctx := context.Background()
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	published := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	for _, d := range []*dataset.Dataset{
+		{
+			ID: "ds-open", DOI: "10.5555/open", Title: "Open", Access: storage.AccessPublic, State: dataset.StatePublished,
+			Versions: []dataset.Version{{Number: 1, PublishedAt: &published, Files: []dataset.File{{Path: "a.csv", Bucket: "public", Key: "datasets/ds-open/v1/a.csv", Size: 3}}}},
+		},
+		{
+			ID: "ds-dua", Title: "Agreement", Access: storage.AccessPrivate, State: dataset.StatePublished,
+			Agreement: &dataset.Agreement{Version: "1"},
+			ACL:       &authz.ACL{Read: []string{"domain:example.org"}},
+			Versions:  []dataset.Version{{Number: 1, PublishedAt: &published, Files: []dataset.File{{Path: "b.csv", Bucket: "private", Key: "datasets/ds-dua/v1/b.csv"}}}},
+		},
+		{ID: "ds-draft", Title: "Draft", Access: storage.AccessPublic, State: dataset.StateDraft},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	agreements := dua.NewRegistry(s, nil)
+	auth := func(ctx context.Context, secret string) (identity.Principal, error) {
+		switch secret {
+		case "reader":
+			return identity.Principal{ID: "svc:reader", Scopes: []string{token.ScopeDatasetsRead}}, nil
+		case "downloader":
+			return identity.Principal{ID: "svc:downloader", Scopes: []string{token.ScopeDownload}}, nil
+		}
+		return identity.Principal{}, token.ErrNotFound
+	}
+	return NewHandler(Options{
+		Datasets:     datasets,
+		Issuer:       &access.Issuer{Datasets: datasets, Agreements: agreements, Presigner: fakePresigner{}},
+		Agreements:   agreements,
+		Authenticate: auth,
+		Resolve: func(ctx context.Context, p identity.Principal) (identity.Principal, error) {
+			if p.ID == "root@example.org" {
+				p.Groups = append(p.Groups, authz.AdminGroup)
+			}
+			return p, nil
+		},
+	})
+}
+
+func TestHandler(t *testing.T) {
+	h := newTestHandler(t)
+	do := func(method, path, body, secret string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if secret != "" {
+			r.Header.Set("Authorization", "Bearer "+secret)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("GET", "/datasets", "", "")
+	var list []Record
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("GET /datasets = %d %s", w.Code, w.Body)
+	}
+	if len(list) != 2 || list[0].ID != "ds-dua" || list[1].ID != "ds-open" || list[1].Files[0].Path != "a.csv" {
+		t.Errorf("GET /datasets = %+v, want the two published datasets", list)
+	}
+	if strings.Contains(w.Body.String(), "datasets/ds-open") {
+		t.Errorf("GET /datasets exposes storage keys: %s", w.Body)
+	}
+
+	if w := do("GET", "/datasets/10.5555/open", "", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"id":"ds-open"`) {
+		t.Errorf("GET /datasets/{doi} = %d %s", w.Code, w.Body)
+	}
+	if w := do("GET", "/datasets/ds-draft", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET draft anonymously = %d, want 404", w.Code)
+	}
+	if w := do("GET", "/datasets/missing", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET missing dataset = %d, want 404", w.Code)
+	}
+	if w := do("GET", "/datasets", "", "downloader"); w.Code != http.StatusForbidden {
+		t.Errorf("GET /datasets without datasets:read = %d, want 403", w.Code)
+	}
+	if w := do("GET", "/datasets", "", "bogus"); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /datasets with unknown token = %d, want 401", w.Code)
+	}
+
+	w = do("POST", "/presign", `{"dataset":"ds-open","file":"a.csv"}`, "")
+	var g access.Grant
+	if err := json.Unmarshal(w.Body.Bytes(), &g); err != nil || w.Code != http.StatusOK || g.URL != "https://public/datasets/ds-open/v1/a.csv" {
+		t.Errorf("POST /presign = %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/presign", `{"dataset":"ds-open","file":"nope.csv"}`, ""); w.Code != http.StatusNotFound {
+		t.Errorf("POST /presign for missing file = %d, want 404", w.Code)
+	}
+	if w := do("POST", "/presign", `{"dataset":"ds-dua","file":"b.csv"}`, ""); w.Code != http.StatusForbidden {
+		t.Errorf("POST /presign anonymously for ACL dataset = %d, want 403", w.Code)
+	}
+	if w := do("POST", "/presign", `{`, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("POST /presign with bad body = %d, want 400", w.Code)
+	}
+
+	w = do("POST", "/access", `{"dataset":"ds-dua","file":"b.csv"}`, "reader")
+	var sim access.Simulation
+	if err := json.Unmarshal(w.Body.Bytes(), &sim); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("POST /access = %d %s", w.Code, w.Body)
+	}
+	if sim.Allowed || sim.Principal != "svc:reader" {
+		t.Errorf("POST /access = %+v, want svc:reader denied", sim)
+	}
+
+	if w := do("POST", "/agreements", `{"dataset":"ds-dua","name":"Anon"}`, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("POST /agreements without email = %d, want 400", w.Code)
+	}
+	if w := do("POST", "/agreements", `{"dataset":"ds-open","name":"Anon","email":"a@example.org"}`, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("POST /agreements for dataset without agreement = %d, want 400", w.Code)
+	}
+	if w := do("POST", "/agreements", `{"dataset":"ds-dua","name":"Anon","email":"a@example.org"}`, ""); w.Code != http.StatusCreated {
+		t.Errorf("POST /agreements = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	h := newTestHandler(t)
+	event := func(method, path, body string, claims map[string]any) *Event {
+		e := &Event{RawPath: path, Body: body, Headers: map[string]string{"content-type": "application/json"}}
+		e.RequestContext.HTTP.Method = method
+		e.RequestContext.HTTP.SourceIP = "192.0.2.1"
+		if claims != nil {
+			e.RequestContext.Authorizer = &struct {
+				JWT struct {
+					Claims map[string]any `json:"claims"`
+				} `json:"jwt"`
+			}{}
+			e.RequestContext.Authorizer.JWT.Claims = claims
+		}
+		return e
+	}
+	ctx := context.Background()
+
+	resp, err := Invoke(ctx, h, event("GET", "/datasets/ds-draft", "", nil))
+	if err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Invoke() anonymous draft = %+v, %v", resp, err)
+	}
+	// Resolve makes root an administrator, who may see drafts.
+	resp, err = Invoke(ctx, h, event("GET", "/datasets/ds-draft", "", map[string]any{"email": "Root@example.org", "cognito:groups": "[researchers]"}))
+	if err != nil || resp.StatusCode != http.StatusOK || resp.Headers["Content-Type"] != "application/json" {
+		t.Errorf("Invoke() draft as an administrator = %+v, %v", resp, err)
+	}
+
+	e := event("POST", "/presign", `{"dataset":"ds-dua","file":"b.csv"}`, map[string]any{"email": "bob@example.org"})
+	if resp, _ := Invoke(ctx, h, e); resp.StatusCode != http.StatusForbidden || !strings.Contains(resp.Body, "agreement") {
+		t.Errorf("Invoke() before accepting = %+v", resp)
+	}
+	e = event("POST", "/agreements", `{"dataset":"ds-dua","name":"Bob"}`, map[string]any{"email": "bob@example.org"})
+	if resp, _ := Invoke(ctx, h, e); resp.StatusCode != http.StatusCreated {
+		t.Errorf("Invoke() accepting = %+v", resp)
+	}
+	e = event("POST", "/presign", `{"dataset":"ds-dua","file":"b.csv"}`, map[string]any{"email": "bob@example.org"})
+	e.Body, e.IsBase64Encoded = "eyJkYXRhc2V0IjoiZHMtZHVhIiwiZmlsZSI6ImIuY3N2In0=", true
+	if resp, _ := Invoke(ctx, h, e); resp.StatusCode != http.StatusOK || !strings.Contains(resp.Body, "https://private/datasets/ds-dua/v1/b.csv") {
+		t.Errorf("Invoke() after accepting = %+v", resp)
+	}
+}
+
+func TestClaimsPrincipal(t *testing.T) {
+	p := claimsPrincipal(map[string]any{"email": " Ada@Example.org", "cognito:groups": "[b a b]", "custom:orcid": "0000-0002-1825-0097"})
+	if p.ID != "ada@example.org" || strings.Join(p.Groups, ",") != "a,b" || p.ORCID != "0000-0002-1825-0097" {
+		t.Errorf("claimsPrincipal() = %+v", p)
+	}
+	if p := claimsPrincipal(map[string]any{"email": "x@y.org", "cognito:groups": []any{"admins"}}); len(p.Groups) != 1 || p.Groups[0] != "admins" {
+		t.Errorf("claimsPrincipal() with list groups = %+v", p)
+	}
+}
+
+func TestRuntime(t *testing.T) {
+	h := newTestHandler(t)
+	events := []string{
+		`{"rawPath":"/datasets/ds-open","requestContext":{"http":{"method":"GET"}}}`,
+		`not json`,
+	}
+	deadline := strconv.FormatInt(time.Now().Add(time.Minute).UnixMilli(), 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	answers := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/2018-06-01/runtime/invocation/next":
+			if len(events) == 0 {
+				cancel()
+				<-r.Context().Done()
+				return
+			}
+			w.Header().Set("Lambda-Runtime-Aws-Request-Id", "req-"+strconv.Itoa(2-len(events)))
+			w.Header().Set("Lambda-Runtime-Deadline-Ms", deadline)
+			_, _ = io.WriteString(w, events[0])
+			events = events[1:]
+		case r.Method == "POST":
+			body, _ := io.ReadAll(r.Body)
+			answers[strings.TrimPrefix(r.URL.Path, "/2018-06-01/runtime/invocation/")] = string(body)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	rt := &Runtime{API: strings.TrimPrefix(srv.URL, "http://"), Handler: h}
+	if err := rt.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var resp Response
+	if err := json.Unmarshal([]byte(answers["req-0/response"]), &resp); err != nil || resp.StatusCode != http.StatusOK || !strings.Contains(resp.Body, `"title":"Open"`) {
+		t.Errorf("response to req-0 = %q", answers["req-0/response"])
+	}
+	if !strings.Contains(answers["req-1/error"], "InvalidEvent") {
+		t.Errorf("answers = %v, want req-1 reported as an invalid event", answers)
+	}
+
+	rt.API = "127.0.0.1:1"
+	if err := rt.Run(context.Background()); err == nil || errors.Is(err, context.Canceled) {
+		t.Errorf("Run() without runtime API error = %v", err)
+	}
+}
+
+// fakeMinter mints sequential DOIs and counts updates.
+type fakeMinter struct{ minted, updated int }
+
+func (m *fakeMinter) Scheme() pid.Scheme { return pid.SchemeDOI }
+
+func (m *fakeMinter) Mint(context.Context, pid.Record) (string, error) {
+	m.minted++
+	return "10.5555/minted" + strconv.Itoa(m.minted), nil
+}
+
+func (m *fakeMinter) Update(context.Context, string, pid.Record) error {
+	m.updated++
+	return nil
+}
+
+// personAuth authenticates bearer tokens naming a person, standing in
+// for the API Gateway authorizer.
+func personAuth(_ context.Context, secret string) (identity.Principal, error) {
+	p := identity.Principal{ID: secret}
+	if secret == "curator@example.org" {
+		p.Groups = []string{authz.RoleCurator.Group()}
+	}
+	return p, nil
+}
+
+func TestDOI(t *testing.T) {
+	ctx := context.Background()
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-old", Title: "Old", State: dataset.StatePublished, ACL: &authz.ACL{Manage: []string{"user:curator@example.org"}}},
+		{ID: "ds-draft", Title: "Draft", State: dataset.StateDraft, ACL: &authz.ACL{Manage: []string{"user:curator@example.org"}}},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	minter := &fakeMinter{}
+	r := &pid.Registrar{Minters: map[pid.Scheme]pid.Minter{pid.SchemeDOI: minter}, SiteURL: "https://data.example.org"}
+	log := &audit.MemoryLog{}
+	h := NewHandler(Options{
+		Deposits:     &deposit.Manager{State: s, Datasets: datasets, PIDs: r, Versions: r, Log: log},
+		Authenticate: personAuth,
+	})
+	do := func(method, path, body, secret string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/doi/mint", `{"dataset":"ds-old"}`, "jane@example.org"); w.Code != http.StatusForbidden {
+		t.Errorf("POST /doi/mint without the publish permission = %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/doi/mint", `{"dataset":"ds-draft"}`, "curator@example.org"); w.Code == http.StatusCreated {
+		t.Errorf("POST /doi/mint of a draft = %d %s", w.Code, w.Body)
+	}
+	w := do("POST", "/doi/mint", `{"dataset":"ds-old"}`, "curator@example.org")
+	var got Identifier
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("POST /doi/mint = %d %s", w.Code, w.Body)
+	}
+	if got.Identifier != "doi:10.5555/minted1" || got.Warning != "" {
+		t.Errorf("POST /doi/mint = %+v", got)
+	}
+	if d, _ := datasets.Get(ctx, "ds-old"); d.DOI != "10.5555/minted1" {
+		t.Errorf("minted DOI not saved: %q", d.DOI)
+	}
+
+	if w := do("PUT", "/doi/10.5555/minted1", "", "curator@example.org"); w.Code != http.StatusOK || minter.updated != 1 {
+		t.Errorf("PUT /doi/{doi} = %d %s after %d updates", w.Code, w.Body, minter.updated)
+	}
+	if w := do("PUT", "/doi/10.5555/missing", "", "curator@example.org"); w.Code != http.StatusNotFound {
+		t.Errorf("PUT /doi/{missing} = %d %s", w.Code, w.Body)
+	}
+	entries, _ := log.Entries(ctx)
+	if len(entries) != 2 || entries[0].Action != "pid.mint" || entries[1].Action != "pid.update" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	var signedOut string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			ClientId       string
+			Username       string
+			AuthParameters map[string]string
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		switch r.Header.Get("X-Amz-Target") {
+		case "AWSCognitoIdentityProviderService.AdminInitiateAuth":
+			if in.ClientId != "web" {
+				t.Errorf("ClientId = %q", in.ClientId)
+			}
+			if in.AuthParameters["PASSWORD"] == "wrong" || in.AuthParameters["REFRESH_TOKEN"] == "revoked" {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"__type":"NotAuthorizedException","message":"Incorrect username or password."}`)
+				return
+			}
+			io.WriteString(w, `{"AuthenticationResult":{"IdToken":"id","AccessToken":"access","RefreshToken":"refresh","ExpiresIn":3600}}`)
+		case "AWSCognitoIdentityProviderService.AdminUserGlobalSignOut":
+			signedOut = in.Username
+			io.WriteString(w, `{}`)
+		}
+	}))
+	defer srv.Close()
+	accounts, err := cognito.NewClient(cognito.Options{Region: "us-east-1", UserPoolID: "us-east-1_pool", Endpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(Options{Accounts: accounts, ClientID: "web", Authenticate: personAuth})
+	do := func(method, path, body, secret string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/auth/login", `{"username":"Jane@Example.org","password":"secret"}`, "")
+	var tokens cognito.Tokens
+	if err := json.Unmarshal(w.Body.Bytes(), &tokens); err != nil || w.Code != http.StatusOK || tokens.IDToken != "id" {
+		t.Errorf("POST /auth/login = %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/auth/login", `{"username":"jane@example.org","password":"wrong"}`, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("POST /auth/login with a wrong password = %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/auth/login", `{"username":"jane@example.org"}`, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("POST /auth/login without a password = %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/auth/refresh", `{"refreshToken":"refresh"}`, ""); w.Code != http.StatusOK {
+		t.Errorf("POST /auth/refresh = %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/auth/refresh", `{"refreshToken":"revoked"}`, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("POST /auth/refresh with a revoked token = %d %s", w.Code, w.Body)
+	}
+
+	if w := do("GET", "/auth/verify", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous GET /auth/verify = %d %s", w.Code, w.Body)
+	}
+	if w := do("GET", "/auth/verify", "", "curator@example.org"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"groups":["curators"]`) {
+		t.Errorf("GET /auth/verify = %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/auth/logout", "", "jane@example.org"); w.Code != http.StatusNoContent || signedOut != "jane@example.org" {
+		t.Errorf("POST /auth/logout = %d %s, signed out %q", w.Code, w.Body, signedOut)
+	}
+}
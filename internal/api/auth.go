@@ -0,0 +1,104 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/scttfrdmn/aperture/internal/identity"
+)
+
+// Login is the body of POST /auth/login. Username is the user's email
+// address.
+type Login struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Refresh is the body of POST /auth/refresh.
+type Refresh struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// serveLogin signs a user in with a password, answering with the
+// tokens of the session. API calls identify the user with the ID
+// token.
+func (h *Handler) serveLogin(w http.ResponseWriter, r *http.Request) {
+	var req Login
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Username == "" || req.Password == "" {
+		writeError(w, http.StatusBadRequest, errors.New("username and password are required"))
+		return
+	}
+	tokens, err := h.opts.Accounts.SignIn(r.Context(), h.opts.ClientID, identity.Normalize(req.Username), req.Password)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+func (h *Handler) serveRefresh(w http.ResponseWriter, r *http.Request) {
+	var req Refresh
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.RefreshToken == "" {
+		writeError(w, http.StatusBadRequest, errors.New("refreshToken is required"))
+		return
+	}
+	tokens, err := h.opts.Accounts.Refresh(r.Context(), h.opts.ClientID, req.RefreshToken)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// serveLogout revokes the caller's refresh tokens, ending all of their
+// sessions once the tokens they hold expire.
+func (h *Handler) serveLogout(w http.ResponseWriter, r *http.Request) {
+	p, ok := signedIn(w, r)
+	if !ok {
+		return
+	}
+	if err := h.opts.Accounts.SignOut(r.Context(), p.ID); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveVerify answers with the caller's identity, including the groups
+// of their roles, or 401 if they are anonymous.
+func (h *Handler) serveVerify(w http.ResponseWriter, r *http.Request) {
+	if p, ok := signedIn(w, r); ok {
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// signedIn returns the principal of r, answering 401 if it is
+// anonymous or a machine token, which has no session.
+func signedIn(w http.ResponseWriter, r *http.Request) (identity.Principal, bool) {
+	p := identity.FromContext(r.Context())
+	if p.IsZero() || p.IsMachine() {
+		writeError(w, http.StatusUnauthorized, errors.New("not signed in"))
+		return p, false
+	}
+	return p, true
+}
@@ -0,0 +1,92 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+// Mint is the body of POST /doi/mint.
+type Mint struct {
+	// Dataset is the ID of a dataset published without an identifier
+	Dataset string `json:"dataset"`
+}
+
+// Identifier is a dataset's identifier, in the response of the /doi/
+// routes.
+type Identifier struct {
+	Dataset    string `json:"dataset"`
+	Identifier string `json:"identifier"`
+
+	// Warning reports a registration that failed after the identifier
+	// was minted, such as of its media, which can be retried
+	Warning string `json:"warning,omitempty"`
+}
+
+// serveMint mints an identifier for a dataset published before
+// identifiers were configured; datasets published since get one on
+// publication.
+func (h *Handler) serveMint(w http.ResponseWriter, r *http.Request) {
+	if !requirePublish(w, r) {
+		return
+	}
+	var req Mint
+	if !readJSON(w, r, &req) {
+		return
+	}
+	d, id, err := h.opts.Deposits.AssignPID(r.Context(), req.Dataset)
+	if d == nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	// The identifier is minted and saved even if a later step failed.
+	res := Identifier{Dataset: d.ID, Identifier: id}
+	if err != nil {
+		res.Warning = err.Error()
+	}
+	writeJSON(w, http.StatusCreated, res)
+}
+
+// serveUpdate re-registers the metadata of the identifier of the
+// dataset named by ID or identifier.
+func (h *Handler) serveUpdate(w http.ResponseWriter, r *http.Request) {
+	if !requirePublish(w, r) {
+		return
+	}
+	d, err := h.opts.Deposits.UpdatePID(r.Context(), r.PathValue("ref"))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, Identifier{Dataset: d.ID, Identifier: pid.Identifier(d)})
+}
+
+// requirePublish reports whether the principal of r may register
+// identifiers, as 'aperture pid' requires, answering 403 if not.
+func requirePublish(w http.ResponseWriter, r *http.Request) bool {
+	if !requireScope(w, r, token.ScopeDOIWrite) {
+		return false
+	}
+	if err := authz.RequirePermission(identity.FromContext(r.Context()), authz.PermPublish); err != nil {
+		writeError(w, statusOf(err), err)
+		return false
+	}
+	return true
+}
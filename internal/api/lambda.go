@@ -0,0 +1,291 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/scttfrdmn/aperture/internal/identity"
+)
+
+// runtimeVersion is the version of the Lambda runtime API.
+const runtimeVersion = "2018-06-01"
+
+// Event is an API Gateway HTTP API proxy event, payload format 2.0.
+type Event struct {
+	RawPath         string            `json:"rawPath"`
+	RawQueryString  string            `json:"rawQueryString"`
+	Cookies         []string          `json:"cookies,omitempty"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+	RequestContext  struct {
+		DomainName string `json:"domainName"`
+		HTTP       struct {
+			Method   string `json:"method"`
+			SourceIP string `json:"sourceIp"`
+		} `json:"http"`
+		Authorizer *struct {
+			JWT struct {
+				Claims map[string]any `json:"claims"`
+			} `json:"jwt"`
+		} `json:"authorizer,omitempty"`
+	} `json:"requestContext"`
+}
+
+// Response answers an Event.
+type Response struct {
+	StatusCode      int               `json:"statusCode"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Cookies         []string          `json:"cookies,omitempty"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+}
+
+type contextKey int
+
+const gatewayKey contextKey = iota
+
+// withGatewayPrincipal returns a context carrying p as the principal
+// the API Gateway authorizer verified.
+func withGatewayPrincipal(ctx context.Context, p identity.Principal) context.Context {
+	return context.WithValue(ctx, gatewayKey, p)
+}
+
+// gatewayPrincipal returns the principal the API Gateway authorizer
+// verified, if any.
+func gatewayPrincipal(ctx context.Context) (identity.Principal, bool) {
+	p, ok := ctx.Value(gatewayKey).(identity.Principal)
+	return p, ok
+}
+
+// Invoke serves e with h. A request whose JWT claims were verified by
+// the API's authorizer acts as the principal they name; requests
+// without claims are anonymous.
+func Invoke(ctx context.Context, h http.Handler, e *Event) (*Response, error) {
+	body := []byte(e.Body)
+	if e.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(e.Body); err != nil {
+			return nil, fmt.Errorf("invalid event body: %w", err)
+		}
+	}
+	target := (&url.URL{Path: e.RawPath, RawQuery: e.RawQueryString}).RequestURI()
+	r, err := http.NewRequestWithContext(ctx, e.RequestContext.HTTP.Method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range e.Headers {
+		r.Header.Set(k, v)
+	}
+	if len(e.Cookies) > 0 {
+		r.Header.Set("Cookie", strings.Join(e.Cookies, "; "))
+	}
+	r.Host = e.RequestContext.DomainName
+	r.RemoteAddr = e.RequestContext.HTTP.SourceIP
+	if a := e.RequestContext.Authorizer; a != nil && len(a.JWT.Claims) > 0 {
+		r = r.WithContext(withGatewayPrincipal(ctx, claimsPrincipal(a.JWT.Claims)))
+	}
+
+	w := &responseWriter{header: make(http.Header)}
+	h.ServeHTTP(w, r)
+	resp := &Response{StatusCode: cmp.Or(w.status, http.StatusOK), Headers: make(map[string]string)}
+	for k, v := range w.header {
+		if k == "Set-Cookie" {
+			resp.Cookies = append(resp.Cookies, v...)
+			continue
+		}
+		resp.Headers[k] = strings.Join(v, ", ")
+	}
+	if utf8.Valid(w.body.Bytes()) {
+		resp.Body = w.body.String()
+	} else {
+		resp.Body, resp.IsBase64Encoded = base64.StdEncoding.EncodeToString(w.body.Bytes()), true
+	}
+	return resp, nil
+}
+
+// claimsPrincipal returns the principal named by Cognito ID token
+// claims. The authorizer passes list claims such as cognito:groups as
+// strings of the form "[a b]".
+func claimsPrincipal(claims map[string]any) identity.Principal {
+	str := func(name string) string {
+		switch v := claims[name].(type) {
+		case string:
+			return v
+		case nil:
+			return ""
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	p := identity.Principal{ID: identity.Normalize(str("email")), ORCID: str("custom:orcid")}
+	switch groups := claims["cognito:groups"].(type) {
+	case []any:
+		for _, g := range groups {
+			p.Groups = append(p.Groups, fmt.Sprint(g))
+		}
+	case string:
+		p.Groups = strings.FieldsFunc(strings.Trim(groups, "[]"), func(r rune) bool { return r == ' ' || r == ',' })
+	}
+	slices.Sort(p.Groups)
+	p.Groups = slices.Compact(p.Groups)
+	return p
+}
+
+// responseWriter buffers a response for a Response.
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header { return w.header }
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// Runtime serves the events of a Lambda function with a Handler
+// through the Lambda runtime API, so that the aperture binary runs as
+// the function's custom runtime.
+type Runtime struct {
+	// API is the host and port of the runtime API, from
+	// AWS_LAMBDA_RUNTIME_API
+	API string
+
+	// Handler serves the events
+	Handler http.Handler
+
+	// HTTPClient is used for the runtime API; http.DefaultClient if
+	// nil
+	HTTPClient *http.Client
+}
+
+// Run serves events until ctx is done.
+func (rt *Runtime) Run(ctx context.Context) error {
+	for {
+		id, deadline, payload, err := rt.next(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := rt.serve(ctx, id, deadline, payload); err != nil {
+			return err
+		}
+	}
+}
+
+// serve answers the invocation id, reporting an event the handler
+// cannot serve as an invocation error.
+func (rt *Runtime) serve(ctx context.Context, id string, deadline time.Time, payload []byte) error {
+	ictx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	var e Event
+	err := json.Unmarshal(payload, &e)
+	var resp *Response
+	if err == nil {
+		resp, err = Invoke(ictx, rt.Handler, &e)
+	}
+	if err != nil {
+		return rt.post(ctx, "invocation/"+id+"/error", map[string]string{
+			"errorMessage": err.Error(),
+			"errorType":    "InvalidEvent",
+		})
+	}
+	return rt.post(ctx, "invocation/"+id+"/response", resp)
+}
+
+// next waits for the next invocation and returns its request ID,
+// deadline, and event.
+func (rt *Runtime) next(ctx context.Context) (string, time.Time, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rt.url("invocation/next"), nil)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+	resp, err := rt.client().Do(req)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, nil, fmt.Errorf("lambda runtime API: %s: %s", resp.Status, bytes.TrimSpace(payload))
+	}
+	id := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+	ms, err := strconv.ParseInt(resp.Header.Get("Lambda-Runtime-Deadline-Ms"), 10, 64)
+	if err != nil {
+		return "", time.Time{}, nil, fmt.Errorf("lambda runtime API: invalid deadline for invocation %s", id)
+	}
+	return id, time.UnixMilli(ms), payload, nil
+}
+
+func (rt *Runtime) post(ctx context.Context, path string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rt.url(path), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := rt.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("lambda runtime API: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func (rt *Runtime) url(path string) string {
+	return "http://" + rt.API + "/" + runtimeVersion + "/runtime/" + path
+}
+
+func (rt *Runtime) client() *http.Client {
+	if rt.HTTPClient != nil {
+		return rt.HTTPClient
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,101 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/s3"
+)
+
+// anchorPrefix prefixes the keys of anchors in their bucket.
+const anchorPrefix = "audit-anchors/"
+
+// DefaultRetention is how long anchors are locked by default.
+const DefaultRetention = 10 * 365 * 24 * time.Hour
+
+// Objects stores anchors. *s3.Client implements it.
+type Objects interface {
+	Upload(ctx context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error)
+	ListObjects(ctx context.Context, bucket, prefix string, fn func(s3.ObjectInfo) error) error
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// Anchors publishes anchors to a bucket with S3 Object Lock enabled,
+// locking each in compliance mode, so that no one, the account's root
+// user included, can change or delete it until its retention ends.
+type Anchors struct {
+	Objects Objects
+	Bucket  string
+
+	// Retention is how long anchors are locked; DefaultRetention if
+	// zero
+	Retention time.Duration
+}
+
+// Put publishes a.
+func (s *Anchors) Put(ctx context.Context, a Anchor) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%s-%d.json", anchorPrefix, a.Time.UTC().Format("20060102T150405Z"), a.Seq)
+	opts := s3.PutOptions{
+		ContentType: "application/json",
+		RetainUntil: a.Time.Add(cmp.Or(s.Retention, DefaultRetention)),
+	}
+	if _, err := s.Objects.Upload(ctx, s.Bucket, key, bytes.NewReader(body), opts); err != nil {
+		return fmt.Errorf("failed to publish audit anchor: %w", err)
+	}
+	return nil
+}
+
+// List returns the published anchors, oldest first.
+func (s *Anchors) List(ctx context.Context) ([]Anchor, error) {
+	var keys []string
+	err := s.Objects.ListObjects(ctx, s.Bucket, anchorPrefix, func(o s3.ObjectInfo) error {
+		if strings.HasSuffix(o.Key, ".json") {
+			keys = append(keys, o.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit anchors: %w", err)
+	}
+	anchors := make([]Anchor, 0, len(keys))
+	for _, key := range keys {
+		r, err := s.Objects.GetObject(ctx, s.Bucket, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit anchor %s: %w", key, err)
+		}
+		var a Anchor
+		err = json.NewDecoder(r).Decode(&a)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("corrupt audit anchor %s: %w", key, err)
+		}
+		anchors = append(anchors, a)
+	}
+	slices.SortFunc(anchors, func(a, b Anchor) int { return cmp.Compare(a.Seq, b.Seq) })
+	return anchors, nil
+}
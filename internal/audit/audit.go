@@ -0,0 +1,432 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records privileged, lifecycle, and preservation
+// operations in an append-only, hash-chained log.
+//
+// Each entry carries its sequence number, the hash of the entry before
+// it, and its own hash: the SHA-256 digest of its JSON with the hash
+// left empty. Altering, removing, or reordering entries breaks the
+// chain where they were, and a chain rewritten from an altered entry
+// on no longer matches its anchors: the sequence number and hash of
+// the latest entry, published periodically to a bucket under S3 Object
+// Lock, where they cannot be changed until their retention ends.
+// Verify checks a log against its chain and anchors.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// Entry is a single audit record.
+type Entry struct {
+	// Time is when the action happened
+	Time time.Time `json:"time"`
+
+	// Actor is the principal the action was performed as
+	Actor string `json:"actor"`
+
+	// Impersonator is the administrator acting as Actor, if any
+	Impersonator string `json:"impersonator,omitempty"`
+
+	// Session links entries from the same impersonation session
+	Session string `json:"session,omitempty"`
+
+	// Action names the operation (e.g. "doi.tombstone")
+	Action string `json:"action"`
+
+	// Target is the object acted on (e.g. a DOI or user ID)
+	Target string `json:"target,omitempty"`
+
+	// Justification is the reason given for the action
+	Justification string `json:"justification,omitempty"`
+
+	// SourceIP is the address the action originated from
+	SourceIP string `json:"sourceIp,omitempty"`
+
+	// Details holds action-specific fields
+	Details map[string]string `json:"details,omitempty"`
+
+	// Seq numbers entries from 1 in the order they were appended
+	Seq int64 `json:"seq,omitempty"`
+
+	// Prev is the Hash of the entry before
+	Prev string `json:"prev,omitempty"`
+
+	// Hash is the hex SHA-256 digest of the entry's JSON with Hash
+	// empty, set when the entry is appended
+	Hash string `json:"hash,omitempty"`
+}
+
+// Log is an append-only audit log.
+type Log interface {
+	// Append records e, chaining it to the entries before it.
+	Append(ctx context.Context, e Entry) error
+
+	// Entries returns all entries in the order they were appended.
+	Entries(ctx context.Context) ([]Entry, error)
+}
+
+// Record appends an entry for action on target, filling in the actor,
+// source address, and any impersonation from ctx. The justification is
+// the one in ctx, else the "reason" detail, else the impersonation's.
+func Record(ctx context.Context, log Log, action, target string, details map[string]string) error {
+	e := Entry{
+		Time:          time.Now().UTC(),
+		Actor:         identity.FromContext(ctx).String(),
+		Action:        action,
+		Target:        target,
+		Justification: Justification(ctx),
+		SourceIP:      Source(ctx),
+		Details:       details,
+	}
+	if e.Justification == "" {
+		e.Justification = details["reason"]
+	}
+	if imp, ok := identity.ImpersonationFromContext(ctx); ok {
+		e.Impersonator = imp.Admin.String()
+		e.Session = imp.Session
+		if e.Justification == "" {
+			e.Justification = imp.Justification
+		}
+	}
+	return log.Append(ctx, e)
+}
+
+type contextKey int
+
+const (
+	sourceKey contextKey = iota
+	justificationKey
+)
+
+// WithSource returns a context whose entries originate from ip.
+func WithSource(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, sourceKey, ip)
+}
+
+// WithRequest returns the context of r, whose entries originate from
+// the client address of r.
+func WithRequest(r *http.Request) context.Context {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return WithSource(r.Context(), host)
+}
+
+// Source returns the source address in ctx, if any.
+func Source(ctx context.Context) string {
+	ip, _ := ctx.Value(sourceKey).(string)
+	return ip
+}
+
+// WithJustification returns a context whose entries record reason as
+// their justification.
+func WithJustification(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, justificationKey, strings.TrimSpace(reason))
+}
+
+// Justification returns the justification in ctx, if any.
+func Justification(ctx context.Context) string {
+	reason, _ := ctx.Value(justificationKey).(string)
+	return reason
+}
+
+// FileLog appends entries as JSON Lines to a local file.
+type FileLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileLog returns a log stored at path, creating parent directories
+// as needed.
+func NewFileLog(path string) (*FileLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	return &FileLog{path: path}, nil
+}
+
+// Append implements Log.
+func (l *FileLog) Append(_ context.Context, e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	last, err := lastEntry(f)
+	if err != nil {
+		return err
+	}
+	if last.Hash == "" {
+		// The log is empty, or was written before entries were chained
+		entries, err := readEntries(f)
+		if err != nil {
+			return err
+		}
+		last = head(entries)
+	}
+	e.link(last)
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return f.Close()
+}
+
+// maxEntrySize is the longest line of a log file.
+const maxEntrySize = 1024 * 1024
+
+// lastEntry returns the last entry of the log file f, or a zero Entry
+// if it is empty.
+func lastEntry(f *os.File) (Entry, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	n := min(info.Size(), maxEntrySize)
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, info.Size()-n); err != nil {
+		return Entry{}, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	buf = bytes.TrimRight(buf, "\n")
+	if len(buf) == 0 {
+		return Entry{}, nil
+	}
+	i := bytes.LastIndexByte(buf, '\n')
+	if i < 0 && n < info.Size() {
+		return Entry{}, fmt.Errorf("last audit entry is longer than %d bytes", maxEntrySize)
+	}
+	var e Entry
+	if err := json.Unmarshal(buf[i+1:], &e); err != nil {
+		return Entry{}, fmt.Errorf("corrupt last audit entry: %w", err)
+	}
+	return e, nil
+}
+
+// Entries implements Log.
+func (l *FileLog) Entries(_ context.Context) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+	return readEntries(f)
+}
+
+// readEntries reads the entries of a log file.
+func readEntries(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEntrySize)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("corrupt audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// Tables of a StoreLog.
+const (
+	// logTable holds the entries, keyed by their zero-padded sequence
+	// numbers so that keys sort in sequence order
+	logTable = "audit-log"
+
+	// headTable holds a hint of the latest sequence number under
+	// headKey, so that appending need not read the whole log
+	headTable = "audit-head"
+	headKey   = "head"
+)
+
+// StoreLog keeps the chain in a state.Store, so that the CLI of every
+// operator and the platform's functions append to one log when they
+// share the dynamodb state backend. Each entry is created at its
+// sequence number only if no entry is there: of processes appending at
+// once, one extends the chain and the others chain to its entry.
+type StoreLog struct {
+	s state.Store
+
+	// Mirror, if set, receives each entry once it is chained, such as
+	// a StreamLog copying entries to CloudWatch Logs
+	Mirror Log
+}
+
+// NewStoreLog returns a log kept in s.
+func NewStoreLog(s state.Store) *StoreLog {
+	return &StoreLog{s: s}
+}
+
+// Append implements Log.
+func (l *StoreLog) Append(ctx context.Context, e Entry) error {
+	last, err := l.head(ctx)
+	if err != nil {
+		return err
+	}
+	for {
+		next := e
+		next.link(last)
+		err := l.s.Create(ctx, logTable, seqKey(next.Seq), next)
+		if err == nil {
+			e = next
+			break
+		}
+		if !errors.Is(err, state.ErrExists) {
+			return fmt.Errorf("failed to write audit entry: %w", err)
+		}
+		// Another process appended this entry first
+		last = Entry{}
+		if err := l.s.Get(ctx, logTable, seqKey(next.Seq), &last); err != nil {
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+	// The hint may briefly lag or go back; head reads on from it
+	if err := l.s.Put(ctx, headTable, headKey, e.Seq); err != nil {
+		return fmt.Errorf("failed to write audit log head: %w", err)
+	}
+	if l.Mirror != nil {
+		return l.Mirror.Append(ctx, e)
+	}
+	return nil
+}
+
+// head returns the latest entry, or a zero Entry if the log is empty.
+func (l *StoreLog) head(ctx context.Context) (Entry, error) {
+	var seq int64
+	if err := l.s.Get(ctx, headTable, headKey, &seq); err != nil && !errors.Is(err, state.ErrNotFound) {
+		return Entry{}, fmt.Errorf("failed to read audit log head: %w", err)
+	}
+	var last Entry
+	if seq > 0 {
+		if err := l.s.Get(ctx, logTable, seqKey(seq), &last); err != nil {
+			return Entry{}, fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+	for {
+		var e Entry
+		err := l.s.Get(ctx, logTable, seqKey(last.Seq+1), &e)
+		if errors.Is(err, state.ErrNotFound) {
+			return last, nil
+		}
+		if err != nil {
+			return Entry{}, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		last = e
+	}
+}
+
+// Entries implements Log.
+func (l *StoreLog) Entries(ctx context.Context) ([]Entry, error) {
+	entries, err := state.List[Entry](ctx, l.s, logTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// seqKey returns the key of the entry with sequence number seq.
+func seqKey(seq int64) string {
+	return fmt.Sprintf("%020d", seq)
+}
+
+// errUnreadable is returned by the Entries of a StreamLog.
+var errUnreadable = errors.New("audit: a stream log cannot be read back")
+
+// StreamLog writes each entry to W as a line of JSON under an "audit"
+// key, for processes such as Lambda functions whose output CloudWatch
+// Logs keeps and can filter on. It does not chain entries itself; as
+// the Mirror of a StoreLog it copies entries already chained.
+type StreamLog struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+// Append implements Log.
+func (l *StreamLog) Append(_ context.Context, e Entry) error {
+	data, err := json.Marshal(struct {
+		Audit Entry `json:"audit"`
+	}{e})
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.W.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// Entries implements Log. It always fails; query where W is collected
+// instead.
+func (l *StreamLog) Entries(context.Context) ([]Entry, error) {
+	return nil, errUnreadable
+}
+
+// MemoryLog keeps entries in memory. It is intended for tests.
+type MemoryLog struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// Append implements Log.
+func (l *MemoryLog) Append(_ context.Context, e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.link(head(l.entries))
+	l.entries = append(l.entries, e)
+	return nil
+}
+
+// Entries implements Log.
+func (l *MemoryLog) Entries(_ context.Context) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Entry(nil), l.entries...), nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func TestFileLog(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "steward@uni.edu"})
+
+	log, err := NewFileLog(filepath.Join(t.TempDir(), "state", "audit.log"))
+	if err != nil {
+		t.Fatalf("NewFileLog() error = %v", err)
+	}
+
+	entries, err := log.Entries(ctx)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Entries() on empty log = %v, %v", entries, err)
+	}
+
+	if err := Record(ctx, log, "doi.tombstone", "10.5555/abc", map[string]string{"reason": "retracted"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := Record(context.Background(), log, "dataset.view", "ds-1", nil); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	entries, err = log.Entries(ctx)
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Entries() returned %d entries, want 2", len(entries))
+	}
+	if entries[0].Actor != "steward@uni.edu" || entries[0].Details["reason"] != "retracted" {
+		t.Errorf("entries[0] = %+v", entries[0])
+	}
+	if entries[1].Actor != "anonymous" {
+		t.Errorf("entries[1].Actor = %v, want anonymous", entries[1].Actor)
+	}
+}
+
+// TestStoreLog appends from several logs over one store at once, as
+// the CLI of several operators and the platform's functions do, and
+// checks that they build one unbroken chain.
+func TestStoreLog(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "steward@uni.edu"})
+	s := state.NewMemoryStore()
+
+	var wg sync.WaitGroup
+	var mirror bytes.Buffer
+	for i := range 4 {
+		log := NewStoreLog(s)
+		if i == 0 {
+			log.Mirror = &StreamLog{W: &mirror}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 10 {
+				if err := Record(ctx, log, "dataset.view", fmt.Sprintf("ds-%d-%d", i, j), nil); err != nil {
+					t.Errorf("Record() error = %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := NewStoreLog(s).Entries(ctx)
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	v := Verify(entries, nil)
+	if v.Entries != 40 || v.Head.Seq != 40 || !v.OK() {
+		t.Errorf("Verify() = %+v, want an unbroken chain of 40 entries", v)
+	}
+	if n := strings.Count(mirror.String(), "\n"); n != 10 {
+		t.Errorf("mirror received %d entries, want 10", n)
+	}
+
+	// A head hint left behind by a process that lost a race is read on
+	// from.
+	if err := s.Put(ctx, headTable, headKey, int64(12)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Record(ctx, NewStoreLog(s), "audit.anchor", "40", nil); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	entries, _ = NewStoreLog(s).Entries(ctx)
+	if v := Verify(entries, nil); v.Head.Seq != 41 || !v.OK() {
+		t.Errorf("Verify() after a stale head = %+v", v)
+	}
+}
+
+func TestRecordSource(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "admin@uni.edu"})
+	r := httptest.NewRequest(http.MethodPost, "/scim/v2/Users", nil)
+	r.RemoteAddr = "192.0.2.7:51234"
+	imp := identity.Impersonation{Admin: identity.Principal{ID: "root@uni.edu"}, Justification: "ticket 42", Session: "s1"}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		details map[string]string
+		wantIP  string
+		wantWhy string
+	}{
+		{"request", WithRequest(r.WithContext(ctx)), nil, "192.0.2.7", ""},
+		{"justification", WithJustification(WithSource(ctx, "198.51.100.1"), " offboarding "), map[string]string{"reason": "x"}, "198.51.100.1", "offboarding"},
+		{"reason detail", ctx, map[string]string{"reason": "retracted"}, "", "retracted"},
+		{"impersonation", identity.WithImpersonation(ctx, imp), nil, "", "ticket 42"},
+	}
+	for _, tt := range tests {
+		log := &MemoryLog{}
+		if err := Record(tt.ctx, log, "role.grant", "cat@uni.edu", tt.details); err != nil {
+			t.Fatalf("%s: Record() error = %v", tt.name, err)
+		}
+		entries, _ := log.Entries(tt.ctx)
+		if e := entries[0]; e.SourceIP != tt.wantIP || e.Justification != tt.wantWhy {
+			t.Errorf("%s: entry source %q, justification %q; want %q, %q", tt.name, e.SourceIP, e.Justification, tt.wantIP, tt.wantWhy)
+		}
+	}
+}
+
+func TestStreamLog(t *testing.T) {
+	var buf bytes.Buffer
+	log := &StreamLog{W: &buf}
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "cat@uni.edu"})
+	for _, target := range []string{"ds-1", "ds-2"} {
+		if err := Record(ctx, log, "pid.mint", target, nil); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var got struct{ Audit Entry }
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &got) != nil {
+		t.Fatalf("stream = %q", buf.String())
+	}
+	if got.Audit.Actor != "cat@uni.edu" || got.Audit.Target != "ds-2" || got.Audit.Hash != "" {
+		t.Errorf("entry = %+v", got.Audit)
+	}
+	if _, err := log.Entries(ctx); err == nil {
+		t.Error("Entries() of a stream log succeeded, want error")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 0, 0, 0, 0, time.UTC) }
+	entries := []Entry{
+		{Time: day(1), Actor: "cat@uni.edu", Action: "embargo.set", Target: "ds-1"},
+		{Time: day(2), Actor: "stu@uni.edu", Action: "embargo.lift", Target: "ds-1"},
+		{Time: day(3), Actor: "dee@uni.edu", Impersonator: "root@uni.edu", Action: "role.grant", Target: "cat@uni.edu"},
+		{Time: day(4), Actor: "svc:idm", Action: "user.disable", Target: "old@uni.edu"},
+	}
+	tests := []struct {
+		name string
+		q    Query
+		want int
+	}{
+		{"all", Query{}, 4},
+		{"actor", Query{Actor: "STU@uni.edu"}, 1},
+		{"impersonator", Query{Actor: "root@uni.edu"}, 1},
+		{"action", Query{Action: "embargo.lift"}, 1},
+		{"action prefix", Query{Action: "embargo."}, 2},
+		{"target", Query{Target: "ds-1"}, 2},
+		{"window", Query{Since: day(2), Until: day(4)}, 2},
+		{"privileged", Query{Privileged: true}, 3},
+		{"limit", Query{Limit: 1}, 1},
+	}
+	for _, tt := range tests {
+		if got := Filter(entries, tt.q); len(got) != tt.want {
+			t.Errorf("%s: Filter() returned %d entries, want %d", tt.name, len(got), tt.want)
+		}
+	}
+	if got := Filter(entries, Query{Limit: 1}); got[0].Action != "user.disable" {
+		t.Errorf("Filter() with Limit kept %s, want the most recent entry", got[0].Action)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// digest returns the hash of e: the hex SHA-256 digest of its JSON
+// with Hash empty.
+func (e Entry) digest() string {
+	e.Hash = ""
+	b, _ := json.Marshal(e) // an Entry always encodes
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// link chains e to prev, the entry before it.
+func (e *Entry) link(prev Entry) {
+	e.Seq = prev.Seq + 1
+	e.Prev = prev.Hash
+	e.Hash = e.digest()
+}
+
+// head returns the last of entries, or a zero Entry if there are none.
+// Entries appended before the log was chained are hashed as if they
+// had been, so the first chained entry covers them too.
+func head(entries []Entry) Entry {
+	var last Entry
+	for _, e := range entries {
+		if e.Hash == "" {
+			e.link(last)
+		}
+		last = e
+	}
+	return last
+}
+
+// Anchor records the head of a log: the sequence number and hash of
+// its latest entry at Time.
+type Anchor struct {
+	Seq  int64     `json:"seq"`
+	Hash string    `json:"hash"`
+	Time time.Time `json:"time,omitzero"`
+}
+
+// Problem is an entry that fails verification.
+type Problem struct {
+	Seq    int64  `json:"seq"`
+	Detail string `json:"detail"`
+}
+
+// Verification is the outcome of verifying a log.
+type Verification struct {
+	// Entries counts the entries checked
+	Entries int `json:"entries"`
+
+	// Unchained counts the entries appended before the log was chained,
+	// which the first chained entry covers
+	Unchained int `json:"unchained"`
+
+	// Head is the log's latest entry
+	Head Anchor `json:"head"`
+
+	// Anchors counts the anchors checked
+	Anchors int `json:"anchors"`
+
+	Problems []Problem `json:"problems,omitempty"`
+}
+
+// OK reports whether the log passed verification.
+func (v *Verification) OK() bool { return len(v.Problems) == 0 }
+
+// Verify checks that each of entries is unaltered and follows the one
+// before it, and that the log holds the entry each anchor recorded.
+// A change to an entry is reported at the entry, or, if its hash was
+// recomputed, at the entry after it; a chain rewritten from a change
+// on is reported at the anchors it no longer matches.
+func Verify(entries []Entry, anchors []Anchor) *Verification {
+	v := &Verification{Entries: len(entries)}
+	hashes := make(map[int64]string, len(entries))
+	var last Entry
+	chained := false
+	for _, e := range entries {
+		if e.Hash == "" {
+			if chained {
+				v.problem(last.Seq+1, "entry is not chained")
+			} else {
+				v.Unchained++
+			}
+			e.link(last)
+		} else {
+			chained = true
+			switch {
+			case e.Seq == last.Seq+2:
+				v.problem(e.Seq, fmt.Sprintf("entry %d before it is missing", last.Seq+1))
+			case e.Seq > last.Seq+2:
+				v.problem(e.Seq, fmt.Sprintf("entries %d to %d before it are missing", last.Seq+1, e.Seq-1))
+			case e.Seq != last.Seq+1:
+				v.problem(e.Seq, fmt.Sprintf("entry is out of order after entry %d", last.Seq))
+			case e.Prev != last.Hash:
+				v.problem(e.Seq, "entry does not chain to the entry before it")
+			case e.Hash != e.digest():
+				v.problem(e.Seq, "entry was altered after it was appended")
+			}
+		}
+		hashes[e.Seq] = e.Hash
+		last = e
+	}
+	v.Head = Anchor{Seq: last.Seq, Hash: last.Hash}
+
+	for _, a := range anchors {
+		v.Anchors++
+		hash, ok := hashes[a.Seq]
+		switch {
+		case !ok:
+			v.problem(a.Seq, fmt.Sprintf("entry anchored at %s is missing", a.Time.UTC().Format(time.RFC3339)))
+		case hash != a.Hash:
+			v.problem(a.Seq, fmt.Sprintf("entry differs from the one anchored at %s", a.Time.UTC().Format(time.RFC3339)))
+		}
+	}
+	return v
+}
+
+func (v *Verification) problem(seq int64, detail string) {
+	v.Problems = append(v.Problems, Problem{Seq: seq, Detail: detail})
+}
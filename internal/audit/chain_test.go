@@ -0,0 +1,150 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/s3"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	// Entries written before the log was chained are covered by the
+	// first chained entry.
+	legacy := `{"time":"2025-01-01T00:00:00Z","actor":"cat@uni.edu","action":"embargo.set","target":"ds-1"}` + "\n"
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	log, err := NewFileLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range []string{"embargo.lift", "doi.tombstone", "role.grant"} {
+		if err := Record(ctx, log, action, "ds-1", nil); err != nil {
+			t.Fatalf("Record(%s) error = %v", action, err)
+		}
+	}
+	entries, err := log.Entries(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[1].Seq != 2 || entries[3].Seq != 4 || entries[3].Prev != entries[2].Hash {
+		t.Fatalf("entries are not chained: %+v", entries)
+	}
+	anchor := Anchor{Seq: 3, Hash: entries[2].Hash, Time: time.Now()}
+	if v := Verify(entries, []Anchor{anchor}); !v.OK() || v.Unchained != 1 || v.Head.Seq != 4 || v.Anchors != 1 {
+		t.Fatalf("Verify() = %+v, want a clean log", v)
+	}
+
+	tamper := func(f func([]Entry) []Entry) []Entry {
+		return f(slices.Clone(entries))
+	}
+	tests := []struct {
+		name    string
+		entries []Entry
+		want    Problem
+	}{
+		{"altered", tamper(func(e []Entry) []Entry {
+			e[2].Target = "ds-2"
+			return e
+		}), Problem{Seq: 3, Detail: "entry was altered after it was appended"}},
+		{"rehashed", tamper(func(e []Entry) []Entry {
+			e[2].Target = "ds-2"
+			e[2].Hash = e[2].digest()
+			return e
+		}), Problem{Seq: 4, Detail: "entry does not chain to the entry before it"}},
+		{"legacy altered", tamper(func(e []Entry) []Entry {
+			e[0].Actor = "dee@uni.edu"
+			return e
+		}), Problem{Seq: 2, Detail: "entry does not chain to the entry before it"}},
+		{"removed", tamper(func(e []Entry) []Entry {
+			return slices.Delete(e, 2, 3)
+		}), Problem{Seq: 4, Detail: "entry 3 before it is missing"}},
+		{"inserted", tamper(func(e []Entry) []Entry {
+			return slices.Insert(e, 2, Entry{Action: "dataset.view"})
+		}), Problem{Seq: 3, Detail: "entry is not chained"}},
+		{"truncated", entries[:2], Problem{Seq: 3, Detail: "entry anchored at " + anchor.Time.UTC().Format(time.RFC3339) + " is missing"}},
+	}
+	for _, tt := range tests {
+		v := Verify(tt.entries, []Anchor{anchor})
+		if v.OK() || v.Problems[0] != tt.want {
+			t.Errorf("%s: Verify() problems = %+v, want %+v first", tt.name, v.Problems, tt.want)
+		}
+	}
+
+	// A chain rewritten from an altered entry on is caught by its anchor.
+	rewritten := tamper(func(e []Entry) []Entry {
+		e[2].Target = "ds-2"
+		e[2].link(e[1])
+		e[3].link(e[2])
+		return e
+	})
+	v := Verify(rewritten, []Anchor{anchor})
+	if len(v.Problems) != 1 || !strings.HasPrefix(v.Problems[0].Detail, "entry differs from the one anchored") {
+		t.Errorf("Verify(rewritten) problems = %+v, want an anchor mismatch", v.Problems)
+	}
+}
+
+type memObjects map[string][]byte
+
+func (m memObjects) Upload(_ context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error) {
+	if opts.RetainUntil.IsZero() {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b, err := io.ReadAll(r)
+	m[bucket+"/"+key] = b
+	return int64(len(b)), err
+}
+
+func (m memObjects) ListObjects(_ context.Context, bucket, prefix string, fn func(s3.ObjectInfo) error) error {
+	for k := range m {
+		if key, ok := strings.CutPrefix(k, bucket+"/"); ok && strings.HasPrefix(key, prefix) {
+			if err := fn(s3.ObjectInfo{Key: key}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m memObjects) GetObject(_ context.Context, bucket, key string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(m[bucket+"/"+key])), nil
+}
+
+func TestAnchors(t *testing.T) {
+	ctx := context.Background()
+	anchors := &Anchors{Objects: memObjects{}, Bucket: "anchors"}
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, a := range []Anchor{{Seq: 9, Hash: "b", Time: now.Add(time.Hour)}, {Seq: 4, Hash: "a", Time: now}} {
+		if err := anchors.Put(ctx, a); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	got, err := anchors.List(ctx)
+	if err != nil || len(got) != 2 || got[0].Seq != 4 || got[1].Hash != "b" || !got[1].Time.Equal(now.Add(time.Hour)) {
+		t.Errorf("List() = %+v, %v", got, err)
+	}
+}
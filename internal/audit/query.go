@@ -0,0 +1,99 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Privileged lists the actions that change what is published or who
+// may do what. They are taken only by an authenticated person giving a
+// justification.
+var Privileged = []string{
+	"dataset.delete",
+	"doi.tombstone",
+	"embargo.lift",
+	"impersonation.start",
+	"retention.tombstone",
+	"role.grant",
+	"role.revoke",
+	"user.create",
+	"user.disable",
+	"user.enable",
+	"user.group.add",
+	"user.group.remove",
+}
+
+// IsPrivileged reports whether action is privileged.
+func IsPrivileged(action string) bool {
+	return slices.Contains(Privileged, action)
+}
+
+// Query selects audit entries. Zero fields match every entry.
+type Query struct {
+	// Actor matches the acting or impersonating principal
+	Actor string
+
+	// Action matches an action or, ending in ".", every action with
+	// that prefix (e.g. "embargo.")
+	Action string
+
+	// Target matches the object acted on
+	Target string
+
+	// Since and Until bound the entry times, Until exclusive
+	Since, Until time.Time
+
+	// Privileged selects only privileged actions
+	Privileged bool
+
+	// Limit keeps only the most recent entries
+	Limit int
+}
+
+// Match reports whether e is selected by q.
+func (q Query) Match(e Entry) bool {
+	switch {
+	case q.Actor != "" && !strings.EqualFold(e.Actor, q.Actor) && !strings.EqualFold(e.Impersonator, q.Actor):
+		return false
+	case q.Action != "" && e.Action != q.Action && !(strings.HasSuffix(q.Action, ".") && strings.HasPrefix(e.Action, q.Action)):
+		return false
+	case q.Target != "" && !strings.EqualFold(e.Target, q.Target):
+		return false
+	case !q.Since.IsZero() && e.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !e.Time.Before(q.Until):
+		return false
+	case q.Privileged && !IsPrivileged(e.Action):
+		return false
+	}
+	return true
+}
+
+// Filter returns the entries selected by q, oldest first.
+func Filter(entries []Entry, q Query) []Entry {
+	var out []Entry
+	for _, e := range entries {
+		if q.Match(e) {
+			out = append(out, e)
+		}
+	}
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[len(out)-q.Limit:]
+	}
+	return out
+}
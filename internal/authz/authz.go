@@ -0,0 +1,230 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package authz decides who may read and manage a dataset.
+//
+// Each dataset may carry an access control list whose entries name the
+// principals allowed to act on it:
+//
+//	user:alice@example.edu     a single principal
+//	group:researchers          members of a (Cognito) group
+//	domain:example.edu         anyone with an email address in the domain
+//	orcid:0000-0002-1825-0097  the holder of an ORCID iD
+//	authenticated              any signed-in principal
+//	anyone                     everyone, including anonymous users
+//
+// Datasets without a read list fall back to the default for their
+// access level, so existing datasets keep their bucket-level behavior.
+// Members of the admins group may do anything. The same rules are
+// implemented by the presigned URL Lambda.
+//
+// Repository-wide roles (depositor, curator, steward, admin) decide
+// which kinds of action a principal may take at all, such as
+// publishing, lifting embargoes, or tombstoning; see Role.
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// AdminGroup is the group whose members bypass access control lists.
+const AdminGroup = "admins"
+
+// ErrForbidden is returned when a principal may not perform an action.
+var ErrForbidden = errors.New("forbidden")
+
+// Action is an operation on a dataset.
+type Action string
+
+// Actions.
+const (
+	// ActionRead covers downloading files
+	ActionRead Action = "read"
+
+	// ActionManage covers changing metadata and access control
+	ActionManage Action = "manage"
+)
+
+// Entry kinds.
+const (
+	KindUser          = "user"
+	KindGroup         = "group"
+	KindDomain        = "domain"
+	KindORCID         = "orcid"
+	KindAuthenticated = "authenticated"
+	KindAnyone        = "anyone"
+)
+
+// ACL lists the principals allowed to act on a dataset.
+type ACL struct {
+	// Read entries may download files
+	Read []string `json:"read,omitempty"`
+
+	// Manage entries may change metadata and the ACL itself
+	Manage []string `json:"manage,omitempty"`
+}
+
+// Entries returns the entries for action.
+func (a *ACL) Entries(action Action) []string {
+	if a == nil {
+		return nil
+	}
+	if action == ActionManage {
+		return a.Manage
+	}
+	return a.Read
+}
+
+// DefaultRead returns the read entries that apply to datasets with the
+// given access level and no explicit read list.
+func DefaultRead(access storage.Access) []string {
+	switch access {
+	case storage.AccessPublic:
+		return []string{KindAnyone}
+	case storage.AccessPrivate:
+		return []string{"group:researchers", "group:reviewers"}
+	}
+	return nil
+}
+
+// ParseEntry validates and normalizes an ACL entry.
+func ParseEntry(entry string) (string, error) {
+	entry = strings.TrimSpace(entry)
+	if entry == KindAnyone || entry == KindAuthenticated {
+		return entry, nil
+	}
+	kind, value, ok := strings.Cut(entry, ":")
+	value = strings.TrimSpace(value)
+	if !ok || value == "" {
+		return "", fmt.Errorf("invalid ACL entry %q (want user:, group:, domain:, orcid:, authenticated, or anyone)", entry)
+	}
+	switch kind {
+	case KindUser, KindDomain:
+		value = identity.Normalize(strings.TrimPrefix(value, "@"))
+	case KindGroup:
+	case KindORCID:
+		value = strings.ToUpper(strings.TrimPrefix(value, "https://orcid.org/"))
+	default:
+		return "", fmt.Errorf("unknown ACL entry kind %q in %q", kind, entry)
+	}
+	return kind + ":" + value, nil
+}
+
+// Resource is the dataset being accessed.
+type Resource struct {
+	// ID identifies the dataset
+	ID string
+
+	// Access is the dataset's access level
+	Access storage.Access
+
+	// ACL is the dataset's access control list; may be nil
+	ACL *ACL
+
+	// Restriction limits the networks and countries files may be
+	// downloaded from; may be nil
+	Restriction *Restriction
+}
+
+// Decision is the outcome of an authorization check.
+type Decision struct {
+	// Allowed reports whether the action is permitted
+	Allowed bool
+
+	// Entry is the ACL entry that granted access
+	Entry string
+
+	// Reason explains the decision
+	Reason string
+}
+
+// Decide reports whether p may perform action on r.
+func Decide(p identity.Principal, r Resource, action Action) Decision {
+	if hasGroup(p, AdminGroup) {
+		return Decision{Allowed: true, Entry: "group:" + AdminGroup, Reason: "administrator"}
+	}
+
+	entries := r.ACL.Entries(action)
+	source := "access control list"
+	if action == ActionRead && len(entries) == 0 {
+		entries = DefaultRead(r.Access)
+		source = fmt.Sprintf("default for %s datasets", r.Access)
+	}
+	for _, e := range entries {
+		if matches(p, e) {
+			return Decision{Allowed: true, Entry: e, Reason: "granted by " + e}
+		}
+	}
+
+	if len(entries) == 0 {
+		return Decision{Reason: fmt.Sprintf("%s has no %s entries", r.ID, action)}
+	}
+	return Decision{Reason: fmt.Sprintf("%s is not in the %s list of %s (%s)", p, action, r.ID, source)}
+}
+
+// Require returns an error wrapping ErrForbidden unless p may perform
+// action on r.
+func Require(p identity.Principal, r Resource, action Action) error {
+	d := Decide(p, r, action)
+	if !d.Allowed {
+		return fmt.Errorf("%w: %s", ErrForbidden, d.Reason)
+	}
+	return nil
+}
+
+// matches reports whether entry names p.
+func matches(p identity.Principal, entry string) bool {
+	entry, err := ParseEntry(entry)
+	if err != nil {
+		return false
+	}
+	switch entry {
+	case KindAnyone:
+		return true
+	case KindAuthenticated:
+		return !p.IsZero()
+	}
+	if p.IsZero() {
+		return false
+	}
+
+	kind, value, _ := strings.Cut(entry, ":")
+	id := identity.Normalize(p.ID)
+	switch kind {
+	case KindUser:
+		return id == value
+	case KindGroup:
+		return hasGroup(p, value)
+	case KindDomain:
+		_, domain, ok := strings.Cut(id, "@")
+		return ok && (domain == value || strings.HasSuffix(domain, "."+value))
+	case KindORCID:
+		return p.ORCID != "" && strings.EqualFold(p.ORCID, value)
+	}
+	return false
+}
+
+func hasGroup(p identity.Principal, group string) bool {
+	for _, g := range p.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
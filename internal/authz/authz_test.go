@@ -0,0 +1,266 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+func TestDecide(t *testing.T) {
+	alice := identity.Principal{ID: "Alice@Bio.Example.edu", Groups: []string{"lab-7"}, ORCID: "0000-0002-1694-233X"}
+	anon := identity.Principal{}
+	admin := identity.Principal{ID: "root@example.org", Groups: []string{AdminGroup}}
+
+	acl := func(read ...string) *ACL { return &ACL{Read: read} }
+	restricted := func(a *ACL) Resource { return Resource{ID: "ds-1", Access: storage.AccessRestricted, ACL: a} }
+
+	tests := []struct {
+		name   string
+		p      identity.Principal
+		r      Resource
+		action Action
+		want   bool
+	}{
+		{"public default", anon, Resource{ID: "ds-1", Access: storage.AccessPublic}, ActionRead, true},
+		{"private default member", identity.Principal{ID: "x", Groups: []string{"researchers"}}, Resource{ID: "ds-1", Access: storage.AccessPrivate}, ActionRead, true},
+		{"private default non-member", alice, Resource{ID: "ds-1", Access: storage.AccessPrivate}, ActionRead, false},
+		{"restricted default", alice, restricted(nil), ActionRead, false},
+		{"admin", admin, restricted(nil), ActionManage, true},
+		{"user", alice, restricted(acl("user:alice@bio.example.edu")), ActionRead, true},
+		{"group", alice, restricted(acl("group:lab-7")), ActionRead, true},
+		{"domain", alice, restricted(acl("domain:example.edu")), ActionRead, true},
+		{"domain suffix only", alice, restricted(acl("domain:ample.edu")), ActionRead, false},
+		{"orcid", alice, restricted(acl("orcid:https://orcid.org/0000-0002-1694-233x")), ActionRead, true},
+		{"authenticated", alice, restricted(acl("authenticated")), ActionRead, true},
+		{"authenticated anonymous", anon, restricted(acl("authenticated")), ActionRead, false},
+		{"explicit list overrides default", anon, Resource{ID: "ds-1", Access: storage.AccessPublic, ACL: acl("group:lab-7")}, ActionRead, false},
+		{"read does not grant manage", alice, restricted(acl("user:alice@bio.example.edu")), ActionManage, false},
+		{"manage", alice, restricted(&ACL{Manage: []string{"group:lab-7"}}), ActionManage, true},
+		{"invalid entry", alice, restricted(acl("team:lab-7")), ActionRead, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Decide(tt.p, tt.r, tt.action)
+			if d.Allowed != tt.want {
+				t.Errorf("Decide() = %+v, want allowed %v", d, tt.want)
+			}
+			if err := Require(tt.p, tt.r, tt.action); (err == nil) != tt.want || (err != nil && !errors.Is(err, ErrForbidden)) {
+				t.Errorf("Require() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{"user: Alice@Example.edu ", "user:alice@example.edu", false},
+		{"domain:@Example.edu", "domain:example.edu", false},
+		{"orcid:0000-0002-1694-233x", "orcid:0000-0002-1694-233X", false},
+		{"anyone", "anyone", false},
+		{"group:", "", true},
+		{"team:x", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseEntry(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseEntry(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckClient(t *testing.T) {
+	r := Resource{ID: "ds-1", Restriction: &Restriction{CIDRs: []string{"192.0.2.0/24", "2001:db8::/32"}, Countries: []string{"US"}}}
+	ip := netip.MustParseAddr
+
+	tests := []struct {
+		name string
+		r    Resource
+		c    Client
+		want bool
+	}{
+		{"unrestricted", Resource{ID: "ds-1"}, Client{}, true},
+		{"allowed", r, Client{IP: ip("192.0.2.7"), Country: "us"}, true},
+		{"allowed ipv6", r, Client{IP: ip("2001:db8::1"), Country: "US"}, true},
+		{"mapped ipv4", r, Client{IP: ip("::ffff:192.0.2.7"), Country: "US"}, true},
+		{"outside network", r, Client{IP: ip("198.51.100.1"), Country: "US"}, false},
+		{"unknown address", r, Client{Country: "US"}, false},
+		{"wrong country", r, Client{IP: ip("192.0.2.7"), Country: "FR"}, false},
+		{"unknown country", r, Client{IP: ip("192.0.2.7")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckClient(tt.r, tt.c)
+			if (err == nil) != tt.want || (err != nil && !errors.Is(err, ErrForbidden)) {
+				t.Errorf("CheckClient() error = %v, want allowed %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientOf(t *testing.T) {
+	tests := []struct {
+		name        string
+		secret      string
+		sent        string
+		wantCountry string
+	}{
+		{"through CloudFront", "s3cret", "s3cret", "US"},
+		{"wrong secret", "s3cret", "guess", ""},
+		{"no secret sent", "s3cret", "", ""},
+		{"no secret configured", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/share/abc", nil)
+			r.RemoteAddr = "192.0.2.7:51234"
+			r.Header.Set(CountryHeader, "US")
+			if tt.sent != "" {
+				r.Header.Set(OriginSecretHeader, tt.sent)
+			}
+			c := ClientOf(r, tt.secret)
+			if c.IP != netip.MustParseAddr("192.0.2.7") || c.Country != tt.wantCountry {
+				t.Errorf("ClientOf() = %+v, want country %q", c, tt.wantCountry)
+			}
+		})
+	}
+}
+
+func TestRestrictionNormalize(t *testing.T) {
+	got, err := Restriction{CIDRs: []string{"192.0.2.9/24", "198.51.100.1"}, Countries: []string{" us"}}.Normalize()
+	if err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	if strings.Join(got.CIDRs, " ") != "192.0.2.0/24 198.51.100.1/32" || strings.Join(got.Countries, " ") != "US" {
+		t.Errorf("Normalize() = %+v", got)
+	}
+	for _, bad := range []Restriction{{CIDRs: []string{"10.0.0.0/33"}}, {Countries: []string{"USA"}}} {
+		if _, err := bad.Normalize(); err == nil {
+			t.Errorf("Normalize(%+v) succeeded", bad)
+		}
+	}
+}
+
+func TestBucketPolicies(t *testing.T) {
+	fences := []Fence{
+		{DatasetID: "ds-1", Bucket: "b1", Prefix: "ab/cd/datasets/ds-1/", Restriction: Restriction{CIDRs: []string{"192.0.2.0/24"}}},
+		{DatasetID: "ds-2", Bucket: "b2", Prefix: "datasets/ds-2/", Restriction: Restriction{Countries: []string{"US"}}},
+	}
+	policies, err := BucketPolicies(fences)
+	if err != nil {
+		t.Fatalf("BucketPolicies() error = %v", err)
+	}
+	if len(policies) != 1 {
+		t.Fatalf("BucketPolicies() returned %d policies, want 1 (country limits are not bucket policies)", len(policies))
+	}
+	var doc struct {
+		Statement []struct {
+			Effect    string
+			Resource  string
+			Condition map[string]map[string][]string
+		}
+	}
+	if err := json.Unmarshal(policies["b1"], &doc); err != nil {
+		t.Fatalf("policy is not JSON: %v", err)
+	}
+	s := doc.Statement[0]
+	if s.Effect != "Deny" || s.Resource != "arn:aws:s3:::b1/ab/cd/datasets/ds-1/*" || s.Condition["NotIpAddress"]["aws:SourceIp"][0] != "192.0.2.0/24" {
+		t.Errorf("statement = %+v", s)
+	}
+
+	src, err := CloudFrontFunction(fences)
+	if err != nil {
+		t.Fatalf("CloudFrontFunction() error = %v", err)
+	}
+	if !strings.Contains(src, `"prefix":"/datasets/ds-2/"`) || strings.Contains(src, "RULES") {
+		t.Errorf("CloudFrontFunction() did not embed rules:\n%s", src)
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	depositor := identity.Principal{ID: "dee@example.edu", Groups: []string{"researchers"}}
+	curator := identity.Principal{ID: "cat@example.edu", Groups: []string{"reviewers", "curators"}}
+	steward := identity.Principal{ID: "stu@example.edu", Groups: []string{"stewards"}}
+	admin := identity.Principal{ID: "root@example.org", Groups: []string{AdminGroup}}
+	nobody := identity.Principal{ID: "eve@example.edu", Groups: []string{"reviewers"}}
+
+	tests := []struct {
+		p    identity.Principal
+		perm Permission
+		want bool
+	}{
+		{depositor, PermDeposit, true},
+		{depositor, PermPublish, false},
+		{curator, PermPublish, true},
+		{curator, PermEmbargo, true},
+		{curator, PermLiftEmbargo, false},
+		{curator, PermRetention, false},
+		{steward, PermLiftEmbargo, true},
+		{steward, PermRetention, true},
+		{steward, PermManageUsers, false},
+		{admin, PermManageUsers, true},
+		{admin, PermMaintain, true},
+		{admin, PermFeature, true},
+		{curator, PermFeature, false},
+		{nobody, PermDeposit, false},
+		{identity.Principal{}, PermDeposit, false},
+	}
+	for _, tt := range tests {
+		if got := Can(tt.p, tt.perm); got != tt.want {
+			t.Errorf("Can(%s, %s) = %v, want %v", tt.p, tt.perm, got, tt.want)
+		}
+		err := RequirePermission(tt.p, tt.perm)
+		if tt.want != (err == nil) || err != nil && !errors.Is(err, ErrForbidden) {
+			t.Errorf("RequirePermission(%s, %s) = %v", tt.p, tt.perm, err)
+		}
+	}
+
+	if got := PermissionsOf(curator); !slices.Equal(got, []Permission{PermDeposit, PermCurate, PermPublish, PermEmbargo}) {
+		t.Errorf("PermissionsOf(curator) = %v", got)
+	}
+	if got := PermissionsOf(nobody); got != nil {
+		t.Errorf("PermissionsOf(nobody) = %v, want none", got)
+	}
+	if got := Holders(PermLiftEmbargo); !slices.Equal(got, []Role{RoleSteward, RoleAdmin}) {
+		t.Errorf("Holders(%s) = %v", PermLiftEmbargo, got)
+	}
+
+	for _, r := range Roles {
+		if len(r.Permissions()) == 0 {
+			t.Errorf("role %s has no permissions", r)
+		}
+		if got, err := ParseRole(" " + strings.ToUpper(string(r))); err != nil || got != r {
+			t.Errorf("ParseRole(%s) = %q, %v", r, got, err)
+		}
+	}
+	if _, err := ParseRole("owner"); err == nil {
+		t.Error("ParseRole(owner) succeeded")
+	}
+	if got := RolesOf(curator); len(got) != 1 || got[0] != RoleCurator {
+		t.Errorf("RolesOf(curator) = %v", got)
+	}
+}
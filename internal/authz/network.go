@@ -0,0 +1,293 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// Restriction limits where a dataset's files may be downloaded from,
+// for example to satisfy export-control rules. A request must come from
+// an allowed network and, if countries are listed, an allowed country.
+type Restriction struct {
+	// CIDRs are the allowed client networks (e.g. "192.0.2.0/24")
+	CIDRs []string `json:"cidrs,omitempty"`
+
+	// Countries are the allowed ISO 3166-1 alpha-2 country codes
+	Countries []string `json:"countries,omitempty"`
+}
+
+// IsZero reports whether r places no restriction.
+func (r *Restriction) IsZero() bool {
+	return r == nil || (len(r.CIDRs) == 0 && len(r.Countries) == 0)
+}
+
+// Normalize validates r and returns it with canonical CIDRs and
+// upper-case country codes.
+func (r Restriction) Normalize() (Restriction, error) {
+	var out Restriction
+	for _, c := range r.CIDRs {
+		p, err := netip.ParsePrefix(strings.TrimSpace(c))
+		if err != nil {
+			addr, aerr := netip.ParseAddr(strings.TrimSpace(c))
+			if aerr != nil {
+				return Restriction{}, fmt.Errorf("invalid network %q", c)
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		out.CIDRs = append(out.CIDRs, p.Masked().String())
+	}
+	for _, c := range r.Countries {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if len(c) != 2 || c[0] < 'A' || c[0] > 'Z' || c[1] < 'A' || c[1] > 'Z' {
+			return Restriction{}, fmt.Errorf("invalid country code %q (want ISO 3166-1 alpha-2, e.g. US)", c)
+		}
+		out.Countries = append(out.Countries, c)
+	}
+	return out, nil
+}
+
+// Client describes where a request came from.
+type Client struct {
+	// IP is the client address; invalid if unknown
+	IP netip.Addr
+
+	// Country is the client's ISO 3166-1 alpha-2 country code, as
+	// reported by CloudFront; empty if unknown (see ClientOf)
+	Country string
+}
+
+// Headers of requests forwarded by CloudFront.
+const (
+	// CountryHeader carries the viewer's country code
+	CountryHeader = "CloudFront-Viewer-Country"
+
+	// OriginSecretHeader carries the secret the distribution adds to
+	// its origin requests, which shows that a request came through it
+	OriginSecretHeader = "X-Aperture-Origin-Secret"
+)
+
+// ClientOf returns the address of r and, if r came through the
+// CloudFront distribution, the viewer country it reported. Any client
+// can send CountryHeader, so it is believed only from requests
+// carrying secret in OriginSecretHeader; CloudFront replaces a
+// client's copy of the country header with its own. With no secret,
+// the country is unknown and CheckClient refuses country-restricted
+// resources.
+func ClientOf(r *http.Request, secret string) Client {
+	var c Client
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		c.IP = ip
+	}
+	if secret != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(OriginSecretHeader)), []byte(secret)) == 1 {
+		c.Country = r.Header.Get(CountryHeader)
+	}
+	return c
+}
+
+// CheckClient returns an error wrapping ErrForbidden if r's restriction
+// does not allow requests from c. The message says which networks or
+// countries are allowed.
+func CheckClient(r Resource, c Client) error {
+	if r.Restriction.IsZero() {
+		return nil
+	}
+	rs := r.Restriction
+
+	if len(rs.CIDRs) > 0 {
+		if !c.IP.IsValid() {
+			return fmt.Errorf("%w: %s may only be downloaded from approved networks, and the client address is unknown", ErrForbidden, r.ID)
+		}
+		allowed := false
+		for _, cidr := range rs.CIDRs {
+			if p, err := netip.ParsePrefix(cidr); err == nil && p.Contains(c.IP.Unmap()) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %s may only be downloaded from %s; this request came from %s",
+				ErrForbidden, r.ID, strings.Join(rs.CIDRs, ", "), c.IP)
+		}
+	}
+
+	if len(rs.Countries) > 0 {
+		if c.Country == "" {
+			return fmt.Errorf("%w: %s may only be downloaded from %s, and the client country is unknown",
+				ErrForbidden, r.ID, strings.Join(rs.Countries, ", "))
+		}
+		for _, cc := range rs.Countries {
+			if strings.EqualFold(cc, c.Country) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s may only be downloaded from %s; this request came from %s",
+			ErrForbidden, r.ID, strings.Join(rs.Countries, ", "), strings.ToUpper(c.Country))
+	}
+	return nil
+}
+
+// Fence is a stored location whose objects are subject to a
+// restriction.
+type Fence struct {
+	// DatasetID identifies the restricted dataset
+	DatasetID string
+
+	// Bucket holds the objects
+	Bucket string
+
+	// Prefix is the key prefix shared by the dataset's objects
+	Prefix string
+
+	// Restriction applies to every object under Prefix
+	Restriction Restriction
+}
+
+// BucketPolicies returns, for each bucket, the bucket policy statements
+// that deny downloads from outside the fenced networks. Country limits
+// cannot be expressed in S3 policies; they are enforced by the
+// CloudFront function and when presigning.
+func BucketPolicies(fences []Fence) (map[string][]byte, error) {
+	statements := make(map[string][]map[string]any)
+	for _, f := range fences {
+		if len(f.Restriction.CIDRs) == 0 {
+			continue
+		}
+		statements[f.Bucket] = append(statements[f.Bucket], map[string]any{
+			"Sid":       "RestrictNetwork" + sidSafe(f.DatasetID),
+			"Effect":    "Deny",
+			"Principal": "*",
+			"Action":    "s3:GetObject",
+			"Resource":  "arn:aws:s3:::" + f.Bucket + "/" + f.Prefix + "*",
+			"Condition": map[string]any{
+				"NotIpAddress": map[string]any{"aws:SourceIp": f.Restriction.CIDRs},
+			},
+		})
+	}
+
+	out := make(map[string][]byte, len(statements))
+	for bucket, stmts := range statements {
+		doc, err := json.MarshalIndent(map[string]any{
+			"Version":   "2012-10-17",
+			"Statement": stmts,
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		out[bucket] = doc
+	}
+	return out, nil
+}
+
+// CloudFrontFunction returns the source of a CloudFront viewer-request
+// function that rejects requests for fenced objects from disallowed
+// networks or countries. The distribution must forward the
+// CloudFront-Viewer-Country header.
+func CloudFrontFunction(fences []Fence) (string, error) {
+	type rule struct {
+		Prefix    string   `json:"prefix"`
+		Dataset   string   `json:"dataset"`
+		CIDRs     []string `json:"cidrs,omitempty"`
+		Countries []string `json:"countries,omitempty"`
+	}
+	rules := make([]rule, 0, len(fences))
+	for _, f := range fences {
+		if f.Restriction.IsZero() {
+			continue
+		}
+		rules = append(rules, rule{
+			Prefix:    "/" + f.Prefix,
+			Dataset:   f.DatasetID,
+			CIDRs:     f.Restriction.CIDRs,
+			Countries: f.Restriction.Countries,
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Prefix < rules[j].Prefix })
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(cloudFrontFunctionSource, "RULES", string(data), 1), nil
+}
+
+// sidSafe strips characters not allowed in policy statement IDs.
+func sidSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// cloudFrontFunctionSource is the viewer-request function template.
+// CloudFront functions run a restricted JavaScript runtime, so IPv4
+// CIDR matching is implemented by hand; IPv6 clients match only
+// IPv6 networks listed verbatim as ::/0.
+const cloudFrontFunctionSource = `// Generated by aperture restrict policy. Do not edit.
+var rules = RULES;
+
+function ipv4(ip) {
+  var p = ip.split(".");
+  if (p.length !== 4) return null;
+  return ((+p[0] << 24) >>> 0) + (+p[1] << 16) + (+p[2] << 8) + +p[3];
+}
+
+function inCIDR(ip, cidr) {
+  if (cidr === "::/0") return ip.indexOf(":") >= 0;
+  var parts = cidr.split("/");
+  var addr = ipv4(ip), net = ipv4(parts[0]);
+  if (addr === null || net === null) return false;
+  var bits = +parts[1];
+  var mask = bits === 0 ? 0 : (~0 << (32 - bits)) >>> 0;
+  return ((addr & mask) >>> 0) === ((net & mask) >>> 0);
+}
+
+function deny(rule, why) {
+  return {
+    statusCode: 403,
+    statusDescription: "Forbidden",
+    headers: { "content-type": { value: "text/plain" } },
+    body: rule.dataset + " may only be downloaded from " + why
+  };
+}
+
+function handler(event) {
+  var req = event.request;
+  for (var i = 0; i < rules.length; i++) {
+    var r = rules[i];
+    if (req.uri.indexOf(r.prefix) !== 0) continue;
+    if (r.cidrs && !r.cidrs.some(function (c) { return inCIDR(event.viewer.ip, c); })) {
+      return deny(r, r.cidrs.join(", "));
+    }
+    var country = req.headers["cloudfront-viewer-country"];
+    if (r.countries && (!country || r.countries.indexOf(country.value) < 0)) {
+      return deny(r, r.countries.join(", "));
+    }
+  }
+  return req;
+}
+`
@@ -0,0 +1,201 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/identity"
+)
+
+// Role is a repository-wide role. Access control lists decide which
+// datasets a principal may act on; roles decide which kinds of action
+// they may take at all. A principal holds a role by being a member of
+// the role's group, whether through Cognito or a role grant.
+type Role string
+
+// Roles.
+const (
+	// RoleDepositor deposits datasets and manages their metadata and
+	// access
+	RoleDepositor Role = "depositor"
+
+	// RoleCurator also publishes landing pages and DOIs and sets
+	// embargoes
+	RoleCurator Role = "curator"
+
+	// RoleSteward also lifts embargoes early and de-accessions datasets
+	RoleSteward Role = "steward"
+
+	// RoleAdmin may do anything, including managing users and roles
+	RoleAdmin Role = "admin"
+)
+
+// Roles lists the roles from least to most privileged.
+var Roles = []Role{RoleDepositor, RoleCurator, RoleSteward, RoleAdmin}
+
+// Permission is a kind of action governed by roles.
+type Permission string
+
+// Permissions.
+const (
+	// PermDeposit covers creating, depositing, and publishing datasets
+	PermDeposit Permission = "deposit"
+
+	// PermCurate covers changing the metadata, creators, access control
+	// lists, agreements, and share links of managed datasets
+	PermCurate Permission = "curate"
+
+	// PermPublish covers rendering landing pages and updating DOIs
+	PermPublish Permission = "publish"
+
+	// PermEmbargo covers setting, extending, and releasing embargoes
+	PermEmbargo Permission = "embargo"
+
+	// PermLiftEmbargo covers releasing embargoed datasets early
+	PermLiftEmbargo Permission = "embargo.lift"
+
+	// PermRetention covers retention policies and tombstoning datasets
+	PermRetention Permission = "retention"
+
+	// PermMaintain covers repository-wide repair and garbage collection
+	PermMaintain Permission = "maintain"
+
+	// PermManageUsers covers user accounts and role grants
+	PermManageUsers Permission = "users"
+
+	// PermAudit covers reading the audit log
+	PermAudit Permission = "audit"
+
+	// PermFeature covers featuring datasets and curating the lists
+	// shown on the homepage
+	PermFeature Permission = "feature"
+
+	// PermDeploy covers deploying the platform's infrastructure and
+	// checking it for drift
+	PermDeploy Permission = "deploy"
+)
+
+// Permissions lists every permission.
+var Permissions = []Permission{
+	PermDeposit, PermCurate, PermPublish, PermEmbargo,
+	PermLiftEmbargo, PermRetention, PermMaintain, PermManageUsers,
+	PermAudit, PermFeature, PermDeploy,
+}
+
+// groups are the Cognito groups that confer each role. Researchers
+// have always been the group that deposits datasets.
+var groups = map[Role]string{
+	RoleDepositor: "researchers",
+	RoleCurator:   "curators",
+	RoleSteward:   "stewards",
+	RoleAdmin:     AdminGroup,
+}
+
+// matrix is the permission matrix: the permissions each role holds.
+var matrix = map[Role][]Permission{
+	RoleDepositor: {PermDeposit, PermCurate},
+	RoleCurator:   {PermDeposit, PermCurate, PermPublish, PermEmbargo},
+	RoleSteward:   {PermDeposit, PermCurate, PermEmbargo, PermLiftEmbargo, PermRetention, PermAudit},
+	RoleAdmin:     Permissions,
+}
+
+// ParseRole returns the role named s.
+func ParseRole(s string) (Role, error) {
+	r := Role(strings.ToLower(strings.TrimSpace(s)))
+	if !slices.Contains(Roles, r) {
+		names := make([]string, len(Roles))
+		for i, r := range Roles {
+			names[i] = string(r)
+		}
+		return "", fmt.Errorf("unknown role %q (want %s)", s, strings.Join(names, ", "))
+	}
+	return r, nil
+}
+
+// Group returns the group whose members hold r.
+func (r Role) Group() string {
+	return groups[r]
+}
+
+// Permissions returns the permissions r holds.
+func (r Role) Permissions() []Permission {
+	return matrix[r]
+}
+
+// Has reports whether r holds perm.
+func (r Role) Has(perm Permission) bool {
+	return slices.Contains(matrix[r], perm)
+}
+
+// RolesOf returns the roles p holds through its groups.
+func RolesOf(p identity.Principal) []Role {
+	var roles []Role
+	for _, r := range Roles {
+		if hasGroup(p, r.Group()) {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
+
+// Can reports whether any of p's roles holds perm.
+func Can(p identity.Principal, perm Permission) bool {
+	for _, r := range RolesOf(p) {
+		if r.Has(perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission returns an error wrapping ErrForbidden unless p
+// holds perm through one of its roles.
+func RequirePermission(p identity.Principal, perm Permission) error {
+	if Can(p, perm) {
+		return nil
+	}
+	var holders []string
+	for _, r := range Holders(perm) {
+		holders = append(holders, string(r))
+	}
+	return fmt.Errorf("%w: %s permission requires one of the roles %s, which %s does not hold",
+		ErrForbidden, perm, strings.Join(holders, ", "), p)
+}
+
+// PermissionsOf returns the permissions p holds through its roles, in
+// the order of Permissions.
+func PermissionsOf(p identity.Principal) []Permission {
+	var perms []Permission
+	for _, perm := range Permissions {
+		if Can(p, perm) {
+			perms = append(perms, perm)
+		}
+	}
+	return perms
+}
+
+// Holders returns the roles that hold perm.
+func Holders(perm Permission) []Role {
+	var roles []Role
+	for _, r := range Roles {
+		if r.Has(perm) {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
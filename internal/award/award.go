@@ -0,0 +1,367 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package award keeps a registry of the grants that fund datasets.
+//
+// An award is recorded once, identified by its funder's ROR ID and the
+// funder's award number, and datasets refer to it by ID, so every
+// dataset funded by a grant names it the same way and the datasets of
+// a grant can be listed for reporting. Awards are checked against the
+// grant DOIs funders register with Crossref: a given grant DOI must
+// carry the same award number and funder, and without one the registry
+// looks the award number up and records the grant DOI it finds.
+package award
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/ror"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// awardsTable holds award records keyed by ID.
+const awardsTable = "awards"
+
+var (
+	// ErrNotFound is returned for an unknown award.
+	ErrNotFound = errors.New("award not found")
+
+	// ErrExists is returned when adding an award that is registered.
+	ErrExists = errors.New("award already registered")
+
+	// ErrMismatch is returned when a grant DOI is registered for a
+	// different award.
+	ErrMismatch = errors.New("grant DOI does not match award")
+)
+
+// Award is a funder's grant.
+type Award struct {
+	// ID identifies the award in dataset records
+	ID string `json:"id"`
+
+	// FunderROR is the funder's ROR ID
+	FunderROR string `json:"funderRor"`
+
+	// FunderName is the funder's name
+	FunderName string `json:"funderName,omitempty"`
+
+	// Number is the funder's award number
+	Number string `json:"number"`
+
+	// Program is the funder's funding programme, e.g. "H2020"
+	Program string `json:"program,omitempty"`
+
+	// Title is the title of the funded project
+	Title string `json:"title,omitempty"`
+
+	// GrantDOI is the award's Crossref grant DOI, if it has one
+	GrantDOI string `json:"grantDoi,omitempty"`
+
+	// CreatedBy is the principal who registered the award
+	CreatedBy string `json:"createdBy,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// GrantSource looks up grant DOIs. *Crossref implements it.
+type GrantSource interface {
+	Grant(ctx context.Context, doi string) (*Grant, error)
+	Search(ctx context.Context, number string) ([]Grant, error)
+}
+
+// FunderSource looks up funders' ROR records. *ror.Client implements
+// it.
+type FunderSource interface {
+	Get(ctx context.Context, id string) (*ror.Organization, error)
+}
+
+// Registry records awards and the datasets they fund.
+type Registry struct {
+	// State holds award records
+	State state.Store
+
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Grants validates awards against grant DOIs; awards are recorded
+	// as given if nil
+	Grants GrantSource
+
+	// Funders names funders registered without a name; the name must
+	// be given if nil
+	Funders FunderSource
+
+	// Log records award changes; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// NewID returns the ID of the award number of the funder with ROR ID
+// funderROR: the ROR ID's suffix and the number, lowercased, with
+// characters other than letters and digits replaced by hyphens.
+func NewID(funderROR, number string) string {
+	n := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '-'
+	}, strings.TrimSpace(number))
+	return strings.TrimPrefix(ror.Normalize(funderROR), ror.Resolver) + "-" + n
+}
+
+// SameNumber reports whether a and b are the same award number, up to
+// case, spacing, and punctuation, which funders write inconsistently.
+func SameNumber(a, b string) bool {
+	return NewID("", a) == NewID("", b)
+}
+
+// Add validates and records a. Its ID, timestamps, and creator are
+// assigned; a missing title, funder name, or grant DOI is filled in
+// from Crossref.
+func (r *Registry) Add(ctx context.Context, a Award) (*Award, error) {
+	if !ror.Valid(a.FunderROR) {
+		return nil, fmt.Errorf("invalid funder ROR ID %q", a.FunderROR)
+	}
+	a.FunderROR = ror.Normalize(a.FunderROR)
+	a.Number = strings.TrimSpace(a.Number)
+	if a.Number == "" {
+		return nil, fmt.Errorf("award number cannot be empty")
+	}
+	a.ID = NewID(a.FunderROR, a.Number)
+	if _, err := r.Get(ctx, a.ID); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrExists, a.ID)
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if a.GrantDOI != "" {
+		a.GrantDOI = dataset.NormalizeDOI(a.GrantDOI)
+	}
+	if r.Grants != nil {
+		if err := r.verify(ctx, &a); err != nil {
+			return nil, err
+		}
+	}
+	if a.FunderName == "" && r.Funders != nil {
+		o, err := r.Funders.Get(ctx, a.FunderROR)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up funder %s: %w", a.FunderROR, err)
+		}
+		a.FunderName = o.DisplayName()
+	}
+	if a.FunderName == "" {
+		return nil, fmt.Errorf("funder name cannot be empty")
+	}
+	now := r.now().UTC()
+	a.CreatedBy = identity.FromContext(ctx).String()
+	a.CreatedAt, a.UpdatedAt = now, now
+	if err := r.State.Put(ctx, awardsTable, a.ID, &a); err != nil {
+		return nil, err
+	}
+	details := map[string]string{"funder": a.FunderROR, "number": a.Number}
+	if a.GrantDOI != "" {
+		details["grantDoi"] = a.GrantDOI
+	}
+	return &a, r.record(ctx, "award.add", a.ID, details)
+}
+
+// verify checks a's grant DOI, or looks one up, and fills in a's
+// missing fields from it.
+func (r *Registry) verify(ctx context.Context, a *Award) error {
+	var g *Grant
+	if a.GrantDOI != "" {
+		found, err := r.Grants.Grant(ctx, a.GrantDOI)
+		if err != nil {
+			return fmt.Errorf("failed to verify grant DOI %s: %w", a.GrantDOI, err)
+		}
+		if !SameNumber(found.Number, a.Number) {
+			return fmt.Errorf("%w: %s is award %q, not %q", ErrMismatch, a.GrantDOI, found.Number, a.Number)
+		}
+		if found.FunderROR != "" && found.FunderROR != a.FunderROR {
+			return fmt.Errorf("%w: %s is funded by %s, not %s", ErrMismatch, a.GrantDOI, found.FunderROR, a.FunderROR)
+		}
+		g = found
+	} else {
+		grants, err := r.Grants.Search(ctx, a.Number)
+		if err != nil {
+			return fmt.Errorf("failed to look up award %s: %w", a.Number, err)
+		}
+		var matches []Grant
+		for _, found := range grants {
+			if found.FunderROR == a.FunderROR {
+				matches = append(matches, found)
+			}
+		}
+		// Without a unique match the award is recorded without a
+		// grant DOI, as most funders do not register them.
+		if len(matches) != 1 {
+			return nil
+		}
+		g = &matches[0]
+		a.GrantDOI = g.DOI
+	}
+	if a.Title == "" {
+		a.Title = g.Title
+	}
+	if a.FunderName == "" {
+		a.FunderName = g.FunderName
+	}
+	return nil
+}
+
+// Get returns the award with the given ID.
+func (r *Registry) Get(ctx context.Context, id string) (*Award, error) {
+	var a Award
+	if err := r.State.Get(ctx, awardsTable, id, &a); err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+// List returns all awards ordered by ID.
+func (r *Registry) List(ctx context.Context) ([]Award, error) {
+	all, err := state.List[Award](ctx, r.State, awardsTable)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all, nil
+}
+
+// Funded returns the datasets funded by award id, ordered by ID.
+func (r *Registry) Funded(ctx context.Context, id string) ([]*dataset.Dataset, error) {
+	all, err := r.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []*dataset.Dataset
+	for _, d := range all {
+		if slices.Contains(d.Awards, id) {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// Link records that the dataset ref is funded by award id.
+func (r *Registry) Link(ctx context.Context, ref, id string) (*dataset.Dataset, error) {
+	if _, err := r.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	d, err := r.managed(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(d.Awards, id) {
+		return d, nil
+	}
+	d.Awards = append(d.Awards, id)
+	r.note(ctx, d, "award.link", id)
+	if err := r.Datasets.Put(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, r.record(ctx, "award.link", d.ID, map[string]string{"award": id})
+}
+
+// Unlink removes award id from the dataset ref.
+func (r *Registry) Unlink(ctx context.Context, ref, id string) (*dataset.Dataset, error) {
+	d, err := r.managed(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	i := slices.Index(d.Awards, id)
+	if i < 0 {
+		return nil, fmt.Errorf("%s is not funded by %s", d.ID, id)
+	}
+	d.Awards = slices.Delete(d.Awards, i, i+1)
+	r.note(ctx, d, "award.unlink", id)
+	if err := r.Datasets.Put(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, r.record(ctx, "award.unlink", d.ID, map[string]string{"award": id})
+}
+
+// Funding returns the funding references of d, for registration with
+// its identifier. *Registry implements pid.FundingSource.
+func (r *Registry) Funding(ctx context.Context, d *dataset.Dataset) ([]pid.Funding, error) {
+	var out []pid.Funding
+	for _, id := range d.Awards {
+		a, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		f := pid.Funding{FunderName: a.FunderName, FunderROR: a.FunderROR, AwardNumber: a.Number, AwardTitle: a.Title, FundingStream: a.Program}
+		if a.GrantDOI != "" {
+			f.AwardURI = "https://doi.org/" + a.GrantDOI
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// managed resolves ref and checks that the acting principal may manage
+// the dataset.
+func (r *Registry) managed(ctx context.Context, ref string) (*dataset.Dataset, error) {
+	d, err := r.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// note appends a history event to d.
+func (r *Registry) note(ctx context.Context, d *dataset.Dataset, action, id string) {
+	d.History = append(d.History, dataset.Event{
+		Time:    r.now().UTC(),
+		Actor:   identity.FromContext(ctx).ID,
+		Action:  action,
+		Details: map[string]string{"award": id},
+	})
+}
+
+func (r *Registry) record(ctx context.Context, action, target string, details map[string]string) error {
+	if r.Log == nil {
+		return nil
+	}
+	return audit.Record(ctx, r.Log, action, target, details)
+}
+
+func (r *Registry) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,211 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package award
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/ror"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// nsf is the ROR ID of the U.S. National Science Foundation.
+const nsf = "https://ror.org/021nxhr62"
+
+// grantJSON is a Crossref grant work.
+func grantJSON(doi, number, funderROR string) string {
+	return fmt.Sprintf(`{"DOI":%q,"type":"grant","award":%q,"project":[{"project-title":[{"title":"Soil carbon dynamics"}],"funding":[{"funder":{"name":"National Science Foundation","id":[{"id":%q,"id-type":"ROR"}]}}]}]}`, doi, number, funderROR)
+}
+
+func fakeCrossref(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /works/{doi...}", func(w http.ResponseWriter, r *http.Request) {
+		switch doi := r.PathValue("doi"); doi {
+		case "10.46936/grant.1":
+			fmt.Fprintf(w, `{"message":%s}`, grantJSON(doi, "DEB-2145678", nsf))
+		case "10.1234/article":
+			fmt.Fprintf(w, `{"message":{"DOI":%q,"type":"journal-article"}}`, doi)
+		default:
+			http.Error(w, "Resource not found.", http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("GET /works", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("filter") != "type:grant" || r.URL.Query().Get("mailto") != "data@uni.edu" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		items := ""
+		if r.URL.Query().Get("query") == "DEB 2145678" {
+			items = grantJSON("10.46936/grant.1", "DEB-2145678", nsf) + "," + grantJSON("10.46936/grant.2", "DEB-2145679", nsf)
+		}
+		fmt.Fprintf(w, `{"message":{"items":[%s]}}`, items)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// fakeFunders names every organization.
+type fakeFunders struct{}
+
+func (fakeFunders) Get(_ context.Context, id string) (*ror.Organization, error) {
+	return &ror.Organization{ID: id, Names: []ror.Name{{Value: "Funder " + id, Types: []string{"ror_display"}}}}, nil
+}
+
+func TestNewID(t *testing.T) {
+	tests := []struct {
+		funder, number, want string
+	}{
+		{nsf, "DEB-2145678", "021nxhr62-deb-2145678"},
+		{"021nxhr62", " 2145678 ", "021nxhr62-2145678"},
+		{"ror.org/021nxhr62", "R01 GM/123", "021nxhr62-r01-gm-123"},
+	}
+	for _, tt := range tests {
+		if got := NewID(tt.funder, tt.number); got != tt.want {
+			t.Errorf("NewID(%q, %q) = %q, want %q", tt.funder, tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	srv := fakeCrossref(t)
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "curator@uni.edu"})
+	r := &Registry{
+		State:   state.NewMemoryStore(),
+		Grants:  NewCrossref(CrossrefOptions{BaseURL: srv.URL, Mailto: "data@uni.edu"}),
+		Funders: fakeFunders{},
+		Log:     &audit.MemoryLog{},
+	}
+
+	tests := []struct {
+		name     string
+		in       Award
+		wantDOI  string
+		wantName string
+		wantErr  error
+	}{
+		{"given grant DOI", Award{FunderROR: nsf, Number: "deb-2145678", GrantDOI: "https://doi.org/10.46936/grant.1"}, "10.46936/grant.1", "National Science Foundation", nil},
+		{"looked up", Award{FunderROR: nsf, Number: "DEB 2145678"}, "10.46936/grant.1", "National Science Foundation", nil},
+		{"not registered", Award{FunderROR: "https://ror.org/01cwqze88", Number: "R01-GM123"}, "", "Funder https://ror.org/01cwqze88", nil},
+		{"wrong number", Award{FunderROR: nsf, Number: "1", GrantDOI: "10.46936/grant.1"}, "", "", ErrMismatch},
+		{"wrong funder", Award{FunderROR: "https://ror.org/01cwqze88", Number: "DEB-2145678", GrantDOI: "10.46936/grant.1"}, "", "", ErrMismatch},
+		{"not a grant", Award{FunderROR: nsf, Number: "2", GrantDOI: "10.1234/article"}, "", "", ErrNoGrant},
+		{"duplicate", Award{FunderROR: nsf, Number: "R01-GM123"}, "", "", nil},
+		{"duplicate again", Award{FunderROR: nsf, Number: "r01-gm123"}, "", "", ErrExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := r.Add(ctx, tt.in)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Add() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			if tt.wantName != "" && a.FunderName != tt.wantName {
+				t.Errorf("Add() funder name = %q, want %q", a.FunderName, tt.wantName)
+			}
+			if a.GrantDOI != tt.wantDOI {
+				t.Errorf("Add() grant DOI = %q, want %q", a.GrantDOI, tt.wantDOI)
+			}
+			if a.GrantDOI != "" && a.Title != "Soil carbon dynamics" {
+				t.Errorf("Add() title = %q", a.Title)
+			}
+			// Awards are registered once; the first cases register the
+			// same award differently spelled.
+			if tt.name != "duplicate" {
+				if err := r.State.Delete(ctx, awardsTable, a.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+
+	if _, err := r.Add(ctx, Award{FunderROR: "https://ror.org/021nxhr61", Number: "1"}); err == nil {
+		t.Error("Add() with an invalid ROR ID succeeded, want error")
+	}
+	r.Funders = nil
+	if _, err := r.Add(ctx, Award{FunderROR: "https://ror.org/01cwqze88", Number: "3"}); err == nil {
+		t.Error("Add() without a funder name succeeded, want error")
+	}
+}
+
+func TestLinkAndFunding(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "lab@uni.edu"})
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", ACL: &authz.ACL{Manage: []string{"user:lab@uni.edu"}}},
+		{ID: "ds-2", ACL: &authz.ACL{Manage: []string{"user:lab@uni.edu"}}},
+		{ID: "ds-3"},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := &Registry{State: s, Datasets: datasets}
+	a, err := r.Add(ctx, Award{FunderROR: nsf, FunderName: "NSF", Number: "DEB-2145678", GrantDOI: "10.46936/grant.1"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	if _, err := r.Link(ctx, "ds-1", "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Link() to an unknown award error = %v, want ErrNotFound", err)
+	}
+	if _, err := r.Link(ctx, "ds-3", a.ID); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Link() of an unmanaged dataset error = %v, want ErrForbidden", err)
+	}
+	for _, id := range []string{"ds-1", "ds-2", "ds-2"} {
+		if _, err := r.Link(ctx, id, a.ID); err != nil {
+			t.Fatalf("Link(%s) error = %v", id, err)
+		}
+	}
+	funded, err := r.Funded(ctx, a.ID)
+	if err != nil || len(funded) != 2 {
+		t.Fatalf("Funded() = %d datasets, %v, want 2", len(funded), err)
+	}
+
+	d, _ := datasets.Get(ctx, "ds-2")
+	refs, err := r.Funding(ctx, d)
+	if err != nil || len(refs) != 1 {
+		t.Fatalf("Funding() = %v, %v", refs, err)
+	}
+	if f := refs[0]; f.FunderROR != nsf || f.AwardNumber != "DEB-2145678" || f.AwardURI != "https://doi.org/10.46936/grant.1" {
+		t.Errorf("Funding() = %+v", f)
+	}
+
+	if _, err := r.Unlink(ctx, "ds-2", a.ID); err != nil {
+		t.Fatalf("Unlink() error = %v", err)
+	}
+	if _, err := r.Unlink(ctx, "ds-2", a.ID); err == nil {
+		t.Error("Unlink() of an unlinked award succeeded, want error")
+	}
+	if funded, _ := r.Funded(ctx, a.ID); len(funded) != 1 {
+		t.Errorf("Funded() after Unlink() = %d datasets, want 1", len(funded))
+	}
+}
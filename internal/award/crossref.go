@@ -0,0 +1,222 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package award
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/ror"
+)
+
+// DefaultCrossrefURL is the Crossref REST API.
+const DefaultCrossrefURL = "https://api.crossref.org"
+
+// ErrNoGrant is returned when a DOI is not a Crossref grant.
+var ErrNoGrant = errors.New("not a Crossref grant")
+
+// CrossrefOptions configures a Crossref client.
+type CrossrefOptions struct {
+	// BaseURL is the Crossref API root
+	BaseURL string
+
+	// Mailto is a contact address, sent to be served from Crossref's
+	// polite pool; optional
+	Mailto string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Crossref looks up grant DOIs in the Crossref REST API.
+type Crossref struct {
+	baseURL string
+	mailto  string
+	http    *http.Client
+}
+
+// NewCrossref returns a client with the given options.
+func NewCrossref(opts CrossrefOptions) *Crossref {
+	c := &Crossref{
+		baseURL: strings.TrimRight(opts.BaseURL, "/"),
+		mailto:  opts.Mailto,
+		http:    opts.HTTPClient,
+	}
+	if c.baseURL == "" {
+		c.baseURL = DefaultCrossrefURL
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Grant is a grant registered with Crossref.
+type Grant struct {
+	// DOI is the grant DOI
+	DOI string
+
+	// Number is the funder's award number
+	Number string
+
+	// Title is the title of the funded project
+	Title string
+
+	// FunderName is the name of the funder
+	FunderName string
+
+	// FunderROR is the funder's ROR ID, if the registrant gave one
+	FunderROR string
+}
+
+// work is the subset of a Crossref work record read for grants.
+type work struct {
+	DOI     string `json:"DOI"`
+	Type    string `json:"type"`
+	Award   string `json:"award"`
+	Project []struct {
+		Title []struct {
+			Title string `json:"title"`
+		} `json:"project-title"`
+		Funding []struct {
+			Funder struct {
+				Name string `json:"name"`
+				ID   []struct {
+					ID     string `json:"id"`
+					IDType string `json:"id-type"`
+				} `json:"id"`
+			} `json:"funder"`
+		} `json:"funding"`
+	} `json:"project"`
+}
+
+// grant converts w to a Grant.
+func (w *work) grant() *Grant {
+	g := &Grant{DOI: dataset.NormalizeDOI(w.DOI), Number: w.Award}
+	for _, p := range w.Project {
+		if g.Title == "" && len(p.Title) > 0 {
+			g.Title = p.Title[0].Title
+		}
+		for _, f := range p.Funding {
+			if g.FunderName == "" {
+				g.FunderName = f.Funder.Name
+			}
+			for _, id := range f.Funder.ID {
+				if g.FunderROR == "" && strings.EqualFold(id.IDType, "ROR") {
+					g.FunderROR = ror.Normalize(id.ID)
+				}
+			}
+		}
+	}
+	return g
+}
+
+// APIError is returned for non-2xx responses.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error implements error.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("crossref: HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// Grant returns the grant registered under doi.
+func (c *Crossref) Grant(ctx context.Context, doi string) (*Grant, error) {
+	var out struct {
+		Message work `json:"message"`
+	}
+	doi = dataset.NormalizeDOI(doi)
+	if err := c.get(ctx, "/works/"+url.PathEscape(doi), nil, &out); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrNoGrant, doi)
+		}
+		return nil, err
+	}
+	if out.Message.Type != "grant" {
+		return nil, fmt.Errorf("%w: %s is a %s", ErrNoGrant, doi, out.Message.Type)
+	}
+	return out.Message.grant(), nil
+}
+
+// Search returns the grants with award number number.
+func (c *Crossref) Search(ctx context.Context, number string) ([]Grant, error) {
+	var out struct {
+		Message struct {
+			Items []work `json:"items"`
+		} `json:"message"`
+	}
+	q := url.Values{
+		"filter": {"type:grant"},
+		"query":  {number},
+		"rows":   {"20"},
+	}
+	if err := c.get(ctx, "/works", q, &out); err != nil {
+		return nil, err
+	}
+	var grants []Grant
+	for _, w := range out.Message.Items {
+		// The query is a free-text search; only exact award numbers
+		// are kept.
+		if SameNumber(w.Award, number) {
+			grants = append(grants, *w.grant())
+		}
+	}
+	return grants, nil
+}
+
+// get fetches path and decodes the response into out.
+func (c *Crossref) get(ctx context.Context, path string, q url.Values, out any) error {
+	if c.mailto != "" {
+		if q == nil {
+			q = url.Values{}
+		}
+		q.Set("mailto", c.mailto)
+	}
+	u := c.baseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("crossref request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package aws provides credentials and Signature Version 4 request
+// signing for calling AWS APIs with the standard library HTTP client.
+package aws
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmptyPayloadHash is the SHA-256 of an empty body.
+const EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// UnsignedPayload is used in place of the payload hash for presigned
+// S3 URLs.
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+const (
+	algorithm  = "AWS4-HMAC-SHA256"
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+)
+
+// Credentials are AWS access keys.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// CredentialsFromEnv reads credentials from the standard AWS
+// environment variables.
+func CredentialsFromEnv() (Credentials, error) {
+	c := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("AWS credentials not found: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return c, nil
+}
+
+// CredentialsFromFile reads the credentials of profile from a shared
+// credentials file in the INI format of ~/.aws/credentials, such as the
+// files S3-compatible stores (MinIO, Ceph, Wasabi) issue their keys in.
+// The profile is "default" if empty.
+func CredentialsFromFile(path, profile string) (Credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to read credentials: %w", err)
+	}
+	if profile == "" {
+		profile = "default"
+	}
+	var c Credentials
+	section := ""
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(strings.TrimPrefix(line[1:len(line)-1], "profile "))
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || section != profile {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "aws_access_key_id":
+			c.AccessKeyID = strings.TrimSpace(v)
+		case "aws_secret_access_key":
+			c.SecretAccessKey = strings.TrimSpace(v)
+		case "aws_session_token":
+			c.SessionToken = strings.TrimSpace(v)
+		}
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("%s has no access keys for profile %s", path, profile)
+	}
+	return c, nil
+}
+
+// Signer signs requests for one service in one region.
+type Signer struct {
+	Credentials Credentials
+	Region      string
+	Service     string
+
+	// Now returns the signing time; time.Now if nil
+	Now func() time.Time
+}
+
+// Sign adds SigV4 authentication headers to req. payloadHash is the
+// hex SHA-256 of the request body.
+func (s *Signer) Sign(req *http.Request, payloadHash string) {
+	now := s.now()
+	amzDate := now.Format(timeFormat)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.Credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.Credentials.SessionToken)
+	}
+	if req.Header.Get("Host") == "" {
+		req.Header.Set("Host", req.URL.Host)
+	}
+
+	headers, signed := canonicalHeaders(req.Header)
+	creq := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		headers,
+		signed,
+		payloadHash,
+	}, "\n")
+
+	scope := s.scope(now)
+	sig := s.signature(now, stringToSign(amzDate, scope, creq))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, s.Credentials.AccessKeyID, scope, signed, sig))
+	req.Header.Del("Host")
+}
+
+// Presign returns u with query-string authentication valid for
+// expires, for a request with the given method and no extra headers.
+func (s *Signer) Presign(method string, u *url.URL, expires time.Duration) *url.URL {
+	now := s.now()
+	amzDate := now.Format(timeFormat)
+	scope := s.scope(now)
+
+	out := *u
+	q := out.Query()
+	q.Set("X-Amz-Algorithm", algorithm)
+	q.Set("X-Amz-Credential", s.Credentials.AccessKeyID+"/"+scope)
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	if s.Credentials.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", s.Credentials.SessionToken)
+	}
+
+	creq := strings.Join([]string{
+		method,
+		canonicalURI(&out),
+		canonicalQuery(q),
+		"host:" + out.Host + "\n",
+		"host",
+		UnsignedPayload,
+	}, "\n")
+
+	q.Set("X-Amz-Signature", s.signature(now, stringToSign(amzDate, scope, creq)))
+	out.RawQuery = canonicalQuery(q)
+	return &out
+}
+
+// HashPayload returns the hex SHA-256 of body.
+func HashPayload(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Signer) now() time.Time {
+	if s.Now != nil {
+		return s.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func (s *Signer) scope(t time.Time) string {
+	return t.Format(dateFormat) + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+func (s *Signer) signature(t time.Time, toSign string) string {
+	key := hmacSHA256([]byte("AWS4"+s.Credentials.SecretAccessKey), t.Format(dateFormat))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func stringToSign(amzDate, scope, canonicalRequest string) string {
+	return algorithm + "\n" + amzDate + "\n" + scope + "\n" + HashPayload([]byte(canonicalRequest))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalURI returns the URI-encoded path, encoding each segment once.
+func canonicalURI(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		return "/"
+	}
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		if unescaped, err := url.PathUnescape(seg); err == nil {
+			seg = unescaped
+		}
+		segments[i] = uriEncode(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery returns the sorted, URI-encoded query string.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// canonicalHeaders returns the canonical header block and the list of
+// signed header names.
+func canonicalHeaders(h http.Header) (string, string) {
+	names := make([]string, 0, len(h))
+	values := make(map[string]string, len(h))
+	for k, v := range h {
+		name := strings.ToLower(k)
+		names = append(names, name)
+		trimmed := make([]string, len(v))
+		for i, s := range v {
+			trimmed[i] = strings.Join(strings.Fields(s), " ")
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, n := range names {
+		b.WriteString(n + ":" + values[n] + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// uriEncode percent-encodes s as required by SigV4: everything except
+// unreserved characters is encoded.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
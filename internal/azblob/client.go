@@ -0,0 +1,555 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package azblob is a minimal Azure Blob Storage client implementing
+// storage.Backend, for repositories whose data must be hosted in
+// Azure. Requests are signed with the storage account's shared key, and
+// presigned URLs are service SAS URLs. S3 storage classes map to the
+// nearest access tiers, and the SHA-256 digest of each uploaded blob is
+// kept in its metadata, where fixity checks find it.
+package azblob
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// apiVersion is the Blob service REST API version requests are made
+// with and SAS URLs are signed for.
+const apiVersion = "2021-08-06"
+
+// ErrNotFound is returned when a blob or container does not exist. It
+// is s3.ErrNotFound, so that callers check for missing objects alike
+// whichever backend holds them.
+var ErrNotFound = s3.ErrNotFound
+
+// sha256Metadata is the metadata entry holding a blob's base64-encoded
+// SHA-256 digest.
+const sha256Metadata = "X-Ms-Meta-Sha256"
+
+// tiers maps S3 storage classes to the Azure access tiers closest to
+// them.
+var tiers = map[string]string{
+	"STANDARD":            "Hot",
+	"INTELLIGENT_TIERING": "Hot",
+	"STANDARD_IA":         "Cool",
+	"ONEZONE_IA":          "Cool",
+	"GLACIER_IR":          "Cold",
+	"GLACIER":             "Archive",
+	"DEEP_ARCHIVE":        "Archive",
+}
+
+// classes maps access tiers back to the S3 storage classes reported
+// for them.
+var classes = map[string]string{
+	"Hot":     "STANDARD",
+	"Cool":    "STANDARD_IA",
+	"Cold":    "GLACIER_IR",
+	"Archive": "DEEP_ARCHIVE",
+}
+
+// Options configures a Client.
+type Options struct {
+	// Account is the storage account name
+	Account string
+
+	// Key is the base64-encoded storage account key
+	Key string
+
+	// Endpoint overrides the account's blob endpoint,
+	// https://<account>.blob.core.windows.net, e.g. for Azurite
+	Endpoint string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is an Azure Blob Storage client.
+type Client struct {
+	account  string
+	key      []byte
+	endpoint *url.URL
+	http     *http.Client
+}
+
+var _ storage.Backend = (*Client)(nil)
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) (*Client, error) {
+	if opts.Account == "" {
+		return nil, errors.New("azblob: no storage account")
+	}
+	key, err := base64.StdEncoding.DecodeString(opts.Key)
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("azblob: the storage account key is not valid base64")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cmp.Or(opts.Endpoint, "https://"+opts.Account+".blob.core.windows.net"), "/"))
+	if err != nil {
+		return nil, fmt.Errorf("azblob: invalid endpoint: %w", err)
+	}
+	c := &Client{account: opts.Account, key: key, endpoint: endpoint, http: opts.HTTPClient}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c, nil
+}
+
+// PutObject stores body at key in the container bucket.
+func (c *Client) PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error {
+	sum := sha256.Sum256(body)
+	h := http.Header{"X-Ms-Blob-Type": {"BlockBlob"}, sha256Metadata: {base64.StdEncoding.EncodeToString(sum[:])}}
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	return c.do(ctx, http.MethodPut, bucket, key, nil, h, body, nil)
+}
+
+// minBlockSize is the block size of streamed uploads, raised for blobs
+// too large to upload in maxBlocks blocks.
+const minBlockSize = 64 << 20
+
+// maxBlocks is the most blocks of a block blob.
+const maxBlocks = 50000
+
+// Upload stores the content of r at key, streaming it in blocks if it
+// is larger than one block, and returns the number of bytes stored.
+// opts.StorageClass sets the access tier, and opts.RetainUntil a locked
+// immutability policy, which the container must allow.
+func (c *Client) Upload(ctx context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error) {
+	h := http.Header{}
+	if opts.StorageClass != "" {
+		tier, ok := tiers[opts.StorageClass]
+		if !ok {
+			return 0, fmt.Errorf("azblob: no access tier matches storage class %s", opts.StorageClass)
+		}
+		h.Set("X-Ms-Access-Tier", tier)
+	}
+	if !opts.RetainUntil.IsZero() {
+		h.Set("X-Ms-Immutability-Policy-Mode", "Locked")
+		h.Set("X-Ms-Immutability-Policy-Until-Date", opts.RetainUntil.UTC().Format(http.TimeFormat))
+	}
+	sum := sha256.New()
+	buf := make([]byte, max(minBlockSize, (opts.Size+maxBlocks-1)/maxBlocks))
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		sum.Write(buf[:n])
+		h.Set("X-Ms-Blob-Type", "BlockBlob")
+		h.Set(sha256Metadata, digest(sum))
+		if opts.ContentType != "" {
+			h.Set("Content-Type", opts.ContentType)
+		}
+		return int64(n), c.do(ctx, http.MethodPut, bucket, key, nil, h, buf[:n], nil)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Blocks left uncommitted are discarded by the service after a
+	// week, so a failed upload needs no cleanup.
+	var ids []string
+	var size int64
+	for num := 1; n > 0; num++ {
+		id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", num)))
+		q := url.Values{"comp": {"block"}, "blockid": {id}}
+		if err := c.do(ctx, http.MethodPut, bucket, key, q, nil, buf[:n], nil); err != nil {
+			return 0, fmt.Errorf("failed to upload block %d: %w", num, err)
+		}
+		sum.Write(buf[:n])
+		ids = append(ids, id)
+		size += int64(n)
+		if n, err = io.ReadFull(r, buf); err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+	}
+	var list bytes.Buffer
+	list.WriteString(`<?xml version="1.0" encoding="utf-8"?><BlockList>`)
+	for _, id := range ids {
+		list.WriteString("<Latest>" + id + "</Latest>")
+	}
+	list.WriteString("</BlockList>")
+	h.Set(sha256Metadata, digest(sum))
+	if opts.ContentType != "" {
+		h.Set("X-Ms-Blob-Content-Type", opts.ContentType)
+	}
+	if err := c.do(ctx, http.MethodPut, bucket, key, url.Values{"comp": {"blocklist"}}, h, list.Bytes(), nil); err != nil {
+		return 0, fmt.Errorf("failed to commit blocks: %w", err)
+	}
+	return size, nil
+}
+
+// digest returns the base64-encoded sum of h.
+func digest(h hash.Hash) string {
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// GetObject returns the contents of a blob. The caller must close the
+// returned reader.
+func (c *Client) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, http.MethodGet, bucket, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// HeadObject returns the properties of a blob, with its access tier
+// reported as the S3 storage class closest to it. A blob of the Archive
+// tier being rehydrated is reported as restoring.
+func (c *Client) HeadObject(ctx context.Context, bucket, key string) (s3.ObjectInfo, error) {
+	var info s3.ObjectInfo
+	err := c.do(ctx, http.MethodHead, bucket, key, nil, nil, nil, func(resp *http.Response) error {
+		info = s3.ObjectInfo{
+			Key:          key,
+			Size:         resp.ContentLength,
+			ETag:         resp.Header.Get("ETag"),
+			StorageClass: classes[resp.Header.Get("X-Ms-Access-Tier")],
+			VersionID:    resp.Header.Get("X-Ms-Version-Id"),
+			ContentType:  resp.Header.Get("Content-Type"),
+		}
+		info.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+		if strings.HasPrefix(resp.Header.Get("X-Ms-Archive-Status"), "rehydrate-pending") {
+			info.Restore = `ongoing-request="true"`
+		}
+		return nil
+	})
+	return info, err
+}
+
+// GetObjectAttributes returns the size, storage class, and SHA-256
+// digest of a blob without reading it. Only blobs uploaded by this
+// client have a digest.
+func (c *Client) GetObjectAttributes(ctx context.Context, bucket, key string) (s3.Attributes, error) {
+	var attrs s3.Attributes
+	err := c.do(ctx, http.MethodHead, bucket, key, nil, nil, nil, func(resp *http.Response) error {
+		attrs.ETag = resp.Header.Get("ETag")
+		attrs.ObjectSize = resp.ContentLength
+		attrs.StorageClass = classes[resp.Header.Get("X-Ms-Access-Tier")]
+		attrs.Checksum.SHA256 = resp.Header.Get(sha256Metadata)
+		return nil
+	})
+	return attrs, err
+}
+
+// listResult is a page of a container listing.
+type listResult struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified  string `xml:"Last-Modified"`
+			ETag          string `xml:"Etag"`
+			ContentLength int64  `xml:"Content-Length"`
+			AccessTier    string `xml:"AccessTier"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// ListObjects calls fn for each blob under prefix in the container
+// bucket, following continuation markers.
+func (c *Client) ListObjects(ctx context.Context, bucket, prefix string, fn func(s3.ObjectInfo) error) error {
+	q := url.Values{"restype": {"container"}, "comp": {"list"}}
+	if prefix != "" {
+		q.Set("prefix", prefix)
+	}
+	for {
+		var page listResult
+		if err := c.do(ctx, http.MethodGet, bucket, "", q, nil, nil, decodeXML(&page)); err != nil {
+			return err
+		}
+		for _, b := range page.Blobs {
+			modified, _ := http.ParseTime(b.Properties.LastModified)
+			info := s3.ObjectInfo{Key: b.Name, Size: b.Properties.ContentLength, LastModified: modified,
+				ETag: b.Properties.ETag, StorageClass: classes[b.Properties.AccessTier]}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+		if page.NextMarker == "" {
+			return nil
+		}
+		q.Set("marker", page.NextMarker)
+	}
+}
+
+// copyPoll is how often CopyObject checks on a copy the service
+// completes asynchronously.
+var copyPoll = time.Second
+
+// CopyObject copies a blob within the account, waiting for the copy to
+// complete.
+func (c *Client) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	h := http.Header{"X-Ms-Copy-Source": {c.objectURL(srcBucket, srcKey, nil).String()}}
+	var status string
+	err := c.do(ctx, http.MethodPut, dstBucket, dstKey, nil, h, nil, func(resp *http.Response) error {
+		status = resp.Header.Get("X-Ms-Copy-Status")
+		return nil
+	})
+	for err == nil && status == "pending" {
+		select {
+		case <-time.After(copyPoll):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		err = c.do(ctx, http.MethodHead, dstBucket, dstKey, nil, nil, nil, func(resp *http.Response) error {
+			status = resp.Header.Get("X-Ms-Copy-Status")
+			if status == "failed" || status == "aborted" {
+				return fmt.Errorf("azblob: copy of %s/%s to %s/%s %s: %s", srcBucket, srcKey, dstBucket, dstKey, status, resp.Header.Get("X-Ms-Copy-Status-Description"))
+			}
+			return nil
+		})
+	}
+	return err
+}
+
+// DeleteObject deletes a blob. Deleting a missing blob succeeds, as it
+// does in S3.
+func (c *Client) DeleteObject(ctx context.Context, bucket, key string) error {
+	err := c.do(ctx, http.MethodDelete, bucket, key, nil, nil, nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// SetStorageClass moves a blob to the access tier closest to the S3
+// storage class.
+func (c *Client) SetStorageClass(ctx context.Context, bucket, key, class string) error {
+	tier, ok := tiers[class]
+	if !ok {
+		return fmt.Errorf("azblob: no access tier matches storage class %s", class)
+	}
+	return c.do(ctx, http.MethodPut, bucket, key, url.Values{"comp": {"tier"}}, http.Header{"X-Ms-Access-Tier": {tier}}, nil, nil)
+}
+
+// PresignGetObject returns a SAS URL that downloads key from the
+// container bucket until expires elapses.
+func (c *Client) PresignGetObject(bucket, key string, expires time.Duration) string {
+	return c.presign("r", bucket, key, expires)
+}
+
+// PresignPutObject returns a SAS URL that uploads key to the container
+// bucket until expires elapses. Uploads must set the x-ms-blob-type
+// header to BlockBlob.
+func (c *Client) PresignPutObject(bucket, key string, expires time.Duration) string {
+	return c.presign("cw", bucket, key, expires)
+}
+
+// presign returns the URL of key signed with a service SAS granting
+// permissions.
+func (c *Client) presign(permissions, bucket, key string, expires time.Duration) string {
+	u := c.objectURL(bucket, key, nil)
+	expiry := time.Now().UTC().Add(expires).Format(time.RFC3339)
+	protocol := "https,http"
+	if u.Scheme == "https" {
+		protocol = "https"
+	}
+	// The fields of a service SAS of version 2020-12-06 or later, in
+	// order: permissions, start, expiry, resource, identifier, IP,
+	// protocol, version, resource type, snapshot time, encryption
+	// scope, and the five response header overrides.
+	fields := []string{permissions, "", expiry, "/blob/" + c.account + "/" + bucket + "/" + key, "", "", protocol, apiVersion, "b", "", "", "", "", "", "", ""}
+	q := url.Values{
+		"sv":  {apiVersion},
+		"sp":  {permissions},
+		"se":  {expiry},
+		"sr":  {"b"},
+		"spr": {protocol},
+		"sig": {c.sign(strings.Join(fields, "\n"))},
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// objectURL returns the URL of key in the container bucket, or of the
+// container if key is empty.
+func (c *Client) objectURL(bucket, key string, q url.Values) *url.URL {
+	u := *c.endpoint
+	u.Path, u.RawPath = u.Path+"/"+bucket, u.EscapedPath()+"/"+url.PathEscape(bucket)
+	if key != "" {
+		u.Path, u.RawPath = u.Path+"/"+key, u.RawPath+"/"+escapeKey(key)
+	}
+	u.RawQuery = q.Encode()
+	return &u
+}
+
+// do sends a request and checks its response, passing it to handle if
+// not nil.
+func (c *Client) do(ctx context.Context, method, bucket, key string, q url.Values, h http.Header, body []byte, handle func(*http.Response) error) error {
+	resp, err := c.send(ctx, method, bucket, key, q, h, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if handle == nil {
+		return nil
+	}
+	return handle(resp)
+}
+
+// decodeXML returns a response handler decoding the body into out.
+func decodeXML(out any) func(*http.Response) error {
+	return func(resp *http.Response) error {
+		if err := xml.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode Azure response: %w", err)
+		}
+		return nil
+	}
+}
+
+// send signs and sends a request.
+func (c *Client) send(ctx context.Context, method, bucket, key string, q url.Values, h http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.objectURL(bucket, key, q).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure request: %w", err)
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("X-Ms-Version", apiVersion)
+	req.Header.Set("Authorization", "SharedKey "+c.account+":"+c.sign(c.stringToSign(req)))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Azure %s %s/%s failed: %w", method, bucket, key, err)
+	}
+	return resp, nil
+}
+
+// stringToSign returns the string a request's shared key signature
+// signs.
+func (c *Client) stringToSign(req *http.Request) string {
+	length := ""
+	if req.ContentLength > 0 {
+		length = strconv.FormatInt(req.ContentLength, 10)
+	}
+	var b strings.Builder
+	for _, v := range []string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		length,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, superseded by x-ms-date
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	} {
+		b.WriteString(v + "\n")
+	}
+
+	var names []string
+	for k := range req.Header {
+		if k := strings.ToLower(k); strings.HasPrefix(k, "x-ms-") {
+			names = append(names, k)
+		}
+	}
+	slices.Sort(names)
+	for _, k := range names {
+		b.WriteString(k + ":" + strings.TrimSpace(req.Header.Get(k)) + "\n")
+	}
+
+	b.WriteString("/" + c.account + req.URL.EscapedPath())
+	q := req.URL.Query()
+	params := make([]string, 0, len(q))
+	for k := range q {
+		params = append(params, k)
+	}
+	slices.Sort(params)
+	for _, k := range params {
+		v := slices.Clone(q[k])
+		slices.Sort(v)
+		b.WriteString("\n" + strings.ToLower(k) + ":" + strings.Join(v, ","))
+	}
+	return b.String()
+}
+
+// sign returns the base64-encoded HMAC-SHA256 of s under the account
+// key.
+func (c *Client) sign(s string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(s))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Error is a Blob service error response.
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("azblob: HTTP %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Unwrap maps missing blobs and containers to ErrNotFound.
+func (e *Error) Unwrap() error {
+	if e.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// checkResponse converts error statuses to *Error. Responses to HEAD
+// requests have no body, so the code is also read from the
+// x-ms-error-code header.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	defer resp.Body.Close()
+	e := &Error{StatusCode: resp.StatusCode, Code: resp.Header.Get("X-Ms-Error-Code")}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	_ = xml.Unmarshal(data, e)
+	return e
+}
+
+// escapeKey percent-encodes each segment of a blob name.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,275 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/s3"
+)
+
+// testKey is the base64-encoded account key of test clients.
+var testKey = base64.StdEncoding.EncodeToString([]byte("secret"))
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(Options{Account: "acct", Key: testKey, Endpoint: srv.URL})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return c
+}
+
+func TestNewClient(t *testing.T) {
+	c, err := NewClient(Options{Account: "acct", Key: testKey})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if got := c.objectURL("media", "datasets/a b.csv", nil).String(); got != "https://acct.blob.core.windows.net/media/datasets/a%20b.csv" {
+		t.Errorf("objectURL() = %s", got)
+	}
+	if _, err := NewClient(Options{Account: "acct", Key: "not base64!"}); err == nil {
+		t.Error("NewClient() with an invalid key succeeded")
+	}
+	if _, err := NewClient(Options{Key: testKey}); err == nil {
+		t.Error("NewClient() without an account succeeded")
+	}
+}
+
+func TestStringToSign(t *testing.T) {
+	c, _ := NewClient(Options{Account: "acct", Key: testKey, Endpoint: "http://127.0.0.1:10000/acct"})
+	req, _ := http.NewRequest(http.MethodPut, c.objectURL("media", "a.csv", url.Values{"comp": {"block"}, "blockid": {"MDE="}}).String(), strings.NewReader("hello"))
+	req.ContentLength = 5
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("X-Ms-Version", apiVersion)
+	req.Header.Set("X-Ms-Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+	want := "PUT\n\n\n5\n\ntext/csv\n\n\n\n\n\n\n" +
+		"x-ms-date:Mon, 02 Jan 2006 15:04:05 GMT\nx-ms-version:" + apiVersion + "\n" +
+		"/acct/acct/media/a.csv\nblockid:MDE=\ncomp:block"
+	if got := c.stringToSign(req); got != want {
+		t.Errorf("stringToSign() = %q, want %q", got, want)
+	}
+}
+
+func TestSignsRequests(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Ms-Version") != apiVersion || r.Header.Get("X-Ms-Date") == "" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte((&Client{account: "acct"}).stringToSign(r)))
+		if want := "SharedKey acct:" + base64.StdEncoding.EncodeToString(mac.Sum(nil)); r.Header.Get("Authorization") != want {
+			t.Errorf("Authorization = %q, want %q", r.Header.Get("Authorization"), want)
+		}
+	})
+	if err := c.PutObject(context.Background(), "media", "a.csv", []byte("a,b\n"), "text/csv"); err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestUpload(t *testing.T) {
+	var blocks []int64
+	var tier, digest, list string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPut && q.Get("comp") == "block":
+			n, _ := io.Copy(io.Discard, r.Body)
+			blocks = append(blocks, n)
+		case r.Method == http.MethodPut && q.Get("comp") == "blocklist":
+			body, _ := io.ReadAll(r.Body)
+			list = string(body)
+			tier, digest = r.Header.Get("X-Ms-Access-Tier"), r.Header.Get(sha256Metadata)
+		case r.Method == http.MethodPut:
+			if r.Header.Get("X-Ms-Blob-Type") != "BlockBlob" {
+				t.Errorf("blob type = %q", r.Header.Get("X-Ms-Blob-Type"))
+			}
+			n, _ := io.Copy(io.Discard, r.Body)
+			blocks = append(blocks, n)
+			tier, digest = r.Header.Get("X-Ms-Access-Tier"), r.Header.Get(sha256Metadata)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL)
+		}
+	})
+	ctx := context.Background()
+	opts := s3.PutOptions{StorageClass: "DEEP_ARCHIVE"}
+
+	n, err := c.Upload(ctx, "media", "small", strings.NewReader("hello"), opts)
+	if err != nil || n != 5 || len(blocks) != 1 || tier != "Archive" {
+		t.Fatalf("Upload(small) = %d, %v; blocks %v, tier %q", n, err, blocks, tier)
+	}
+	if sum := sha256.Sum256([]byte("hello")); digest != base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Errorf("digest = %q", digest)
+	}
+
+	blocks, tier = nil, ""
+	size := int64(minBlockSize + 10)
+	n, err = c.Upload(ctx, "media", "large", io.LimitReader(zeros{}, size), opts)
+	if err != nil || n != size {
+		t.Fatalf("Upload(large) = %d, %v, want %d", n, err, size)
+	}
+	if len(blocks) != 2 || blocks[0] != minBlockSize || blocks[1] != 10 || tier != "Archive" || digest == "" {
+		t.Errorf("blocks = %v, tier %q, digest %q, want a full block and 10 bytes", blocks, tier, digest)
+	}
+	if !strings.Contains(list, "<Latest>MDAwMDAwMDI=</Latest></BlockList>") {
+		t.Errorf("block list = %s", list)
+	}
+
+	if _, err := c.Upload(ctx, "media", "x", strings.NewReader("x"), s3.PutOptions{StorageClass: "REDUCED_REDUNDANCY"}); err == nil {
+		t.Error("Upload() to an unmapped storage class succeeded")
+	}
+}
+
+func TestHeadObject(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/media/gone.csv" {
+			w.Header().Set("X-Ms-Error-Code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", "42")
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Header().Set("X-Ms-Access-Tier", "Archive")
+		w.Header().Set("X-Ms-Archive-Status", "rehydrate-pending-to-hot")
+		w.Header().Set(sha256Metadata, "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=")
+	})
+	ctx := context.Background()
+	info, err := c.HeadObject(ctx, "media", "a.csv")
+	if err != nil || info.Size != 42 || info.StorageClass != "DEEP_ARCHIVE" || !info.Restoring() || info.LastModified.Year() != 2006 {
+		t.Errorf("HeadObject() = %+v, %v", info, err)
+	}
+	attrs, err := c.GetObjectAttributes(ctx, "media", "a.csv")
+	if err != nil || attrs.ObjectSize != 42 || attrs.Checksum.SHA256 != "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=" {
+		t.Errorf("GetObjectAttributes() = %+v, %v", attrs, err)
+	}
+	_, err = c.HeadObject(ctx, "media", "gone.csv")
+	var e *Error
+	if !errors.Is(err, s3.ErrNotFound) || !errors.As(err, &e) || e.Code != "BlobNotFound" {
+		t.Errorf("HeadObject() of a missing blob error = %v", err)
+	}
+	if err := c.DeleteObject(ctx, "media", "gone.csv"); err != nil {
+		t.Errorf("DeleteObject() of a missing blob error = %v", err)
+	}
+}
+
+func TestListObjects(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/media" || q.Get("restype") != "container" || q.Get("comp") != "list" || q.Get("prefix") != "datasets/" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if q.Get("marker") == "" {
+			fmt.Fprint(w, `<EnumerationResults><Blobs><Blob><Name>datasets/a</Name><Properties><Content-Length>3</Content-Length><AccessTier>Cool</AccessTier></Properties></Blob></Blobs><NextMarker>m1</NextMarker></EnumerationResults>`)
+			return
+		}
+		fmt.Fprint(w, `<EnumerationResults><Blobs><Blob><Name>datasets/b</Name><Properties><Content-Length>4</Content-Length></Properties></Blob></Blobs><NextMarker/></EnumerationResults>`)
+	})
+	var got []string
+	err := c.ListObjects(context.Background(), "media", "datasets/", func(o s3.ObjectInfo) error {
+		got = append(got, fmt.Sprintf("%s:%d:%s", o.Key, o.Size, o.StorageClass))
+		return nil
+	})
+	if err != nil || strings.Join(got, ",") != "datasets/a:3:STANDARD_IA,datasets/b:4:" {
+		t.Errorf("ListObjects() = %v, %v", got, err)
+	}
+}
+
+func TestCopyObject(t *testing.T) {
+	copyPoll = time.Millisecond
+	polls := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			if src := r.Header.Get("X-Ms-Copy-Source"); !strings.HasSuffix(src, "/private/datasets/a.csv") {
+				t.Errorf("copy source = %q", src)
+			}
+			w.Header().Set("X-Ms-Copy-Status", "pending")
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodHead:
+			polls++
+			if polls < 2 {
+				w.Header().Set("X-Ms-Copy-Status", "pending")
+			} else {
+				w.Header().Set("X-Ms-Copy-Status", "success")
+			}
+		}
+	})
+	if err := c.CopyObject(context.Background(), "private", "datasets/a.csv", "quarantine", "datasets/a.csv"); err != nil || polls != 2 {
+		t.Errorf("CopyObject() = %v after %d polls", err, polls)
+	}
+}
+
+func TestSetStorageClass(t *testing.T) {
+	var tier string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Query().Get("comp") != "tier" {
+			t.Errorf("unexpected %s %s", r.Method, r.URL)
+		}
+		tier = r.Header.Get("X-Ms-Access-Tier")
+	})
+	ctx := context.Background()
+	if err := c.SetStorageClass(ctx, "media", "a.csv", "GLACIER_IR"); err != nil || tier != "Cold" {
+		t.Errorf("SetStorageClass() = %v, tier %q", err, tier)
+	}
+	if err := c.SetStorageClass(ctx, "media", "a.csv", "OUTPOSTS"); err == nil {
+		t.Error("SetStorageClass() to an unmapped class succeeded")
+	}
+}
+
+func TestPresign(t *testing.T) {
+	c, _ := NewClient(Options{Account: "acct", Key: testKey})
+	u, err := url.Parse(c.PresignGetObject("media", "datasets/a.csv", time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != "/media/datasets/a.csv" || q.Get("sp") != "r" || q.Get("sr") != "b" || q.Get("sv") != apiVersion || q.Get("spr") != "https" {
+		t.Fatalf("PresignGetObject() = %s", u)
+	}
+	expiry, err := time.Parse(time.RFC3339, q.Get("se"))
+	if err != nil || time.Until(expiry) > time.Hour || time.Until(expiry) < 59*time.Minute {
+		t.Errorf("expiry = %q", q.Get("se"))
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("r\n\n" + q.Get("se") + "\n/blob/acct/media/datasets/a.csv\n\n\nhttps\n" + apiVersion + "\nb\n\n\n\n\n\n\n"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); q.Get("sig") != want {
+		t.Errorf("sig = %q, want %q", q.Get("sig"), want)
+	}
+	if u, _ := url.Parse(c.PresignPutObject("media", "a.csv", time.Hour)); u.Query().Get("sp") != "cw" {
+		t.Errorf("PresignPutObject() = %s", u)
+	}
+}
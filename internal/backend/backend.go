@@ -0,0 +1,324 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package backend assembles the platform's API Lambda functions (see
+// cmd/lambdas) from the configuration in their environment. Each
+// function serves one group of the routes of api.Handler against the
+// state table the CLI shares with it, so that the API answers as
+// 'aperture serve' would.
+package backend
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/scttfrdmn/aperture/internal/access"
+	"github.com/scttfrdmn/aperture/internal/api"
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/award"
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/cognito"
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/config"
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/deposit"
+	"github.com/scttfrdmn/aperture/internal/dua"
+	"github.com/scttfrdmn/aperture/internal/dynamodb"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/metrics"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/premis"
+	"github.com/scttfrdmn/aperture/internal/quota"
+	"github.com/scttfrdmn/aperture/internal/role"
+	"github.com/scttfrdmn/aperture/internal/ror"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/search"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+// Backend holds what the functions share: the configuration, the state
+// table, and the audit log.
+type Backend struct {
+	cfg   *config.Config
+	creds aws.Credentials
+	db    *dynamodb.Client
+	state state.Store
+	log   audit.Log
+}
+
+// Open returns the backend configured by cfg. Audit entries are chained
+// in the state table with the CLI's, and copied to w. Records must be
+// kept in DynamoDB, since a function's filesystem does not outlive it.
+func Open(cfg *config.Config, w io.Writer) (*Backend, error) {
+	if cfg.StateBackend != "dynamodb" {
+		return nil, fmt.Errorf("the API functions need APERTURE_STATE_BACKEND=dynamodb, not %q", cfg.StateBackend)
+	}
+	creds, err := aws.CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	client := dynamodb.NewClient(dynamodb.Options{Region: cfg.AWSRegion, Endpoint: cfg.AWSEndpoint, Credentials: creds})
+	st := dynamodb.NewStore(client, cfg.StateTable())
+	log := audit.NewStoreLog(st)
+	log.Mirror = &audit.StreamLog{W: w}
+	return &Backend{
+		cfg:   cfg,
+		creds: creds,
+		db:    client,
+		state: st,
+		log:   log,
+	}, nil
+}
+
+// Auth returns the options of the auth function, which signs users in
+// to the Cognito user pool through the app client APERTURE_OIDC_CLIENT_ID.
+func (b *Backend) Auth() (api.Options, error) {
+	if b.cfg.OIDCClientID == "" {
+		return api.Options{}, fmt.Errorf("no app client configured; set APERTURE_OIDC_CLIENT_ID")
+	}
+	accounts, err := cognito.NewClient(cognito.Options{
+		Region:      b.cfg.AWSRegion,
+		UserPoolID:  b.cfg.CognitoUserPoolID,
+		Endpoint:    b.cfg.AWSEndpoint,
+		Credentials: b.creds,
+	})
+	if err != nil {
+		return api.Options{}, err
+	}
+	return b.options(api.Options{Accounts: accounts, ClientID: b.cfg.OIDCClientID}), nil
+}
+
+// Presign returns the options of the presign function, which answers
+// access requests for files in the S3 media buckets and signs their
+// download URLs.
+func (b *Backend) Presign() (api.Options, error) {
+	if b.cfg.StorageBackend != "" && b.cfg.StorageBackend != storage.BackendS3 {
+		return api.Options{}, fmt.Errorf("the presign function signs S3 URLs, not %s URLs", b.cfg.StorageBackend)
+	}
+	creds := b.creds
+	if b.cfg.StorageAccessKeyID != "" {
+		creds = aws.Credentials{AccessKeyID: b.cfg.StorageAccessKeyID, SecretAccessKey: b.cfg.StorageSecretAccessKey}
+	}
+	endpoint := cmp.Or(b.cfg.StorageEndpoint, b.cfg.AWSEndpoint)
+	objects, err := s3.NewClient(s3.Options{
+		Region:      cmp.Or(b.cfg.StorageRegion, b.cfg.AWSRegion),
+		Endpoint:    endpoint,
+		PathStyle:   b.cfg.StoragePathStyle || endpoint != "",
+		Credentials: creds,
+	})
+	if err != nil {
+		return api.Options{}, err
+	}
+	datasets := b.datasets()
+	agreements := dua.NewRegistry(b.state, b.log)
+	return b.options(api.Options{
+		Datasets: datasets,
+		Issuer: &access.Issuer{
+			Datasets:   datasets,
+			Agreements: agreements,
+			Presigner:  objects,
+			Expiry:     access.DefaultExpiry,
+			Quota:      quota.NewTableTracker(b.db, b.cfg.QuotaTable(), quota.Limits{Bytes: b.cfg.QuotaBytes, Objects: b.cfg.QuotaObjects}),
+			Log:        b.log,
+		},
+		Agreements: agreements,
+	}), nil
+}
+
+// DOI returns the options of the doi function, which mints and
+// re-registers identifiers in the configured schemes.
+func (b *Backend) DOI() (api.Options, error) {
+	r := b.registrar()
+	if len(r.Minters) == 0 {
+		return api.Options{}, fmt.Errorf("%w: set DATACITE_PREFIX and DATACITE_REPOSITORY_ID for DOIs", pid.ErrNotConfigured)
+	}
+	datasets := b.datasets()
+	r.Funding = &award.Registry{
+		State:    b.state,
+		Datasets: datasets,
+		Grants:   award.NewCrossref(award.CrossrefOptions{BaseURL: b.cfg.CrossrefURL, Mailto: b.cfg.CrossrefMailto}),
+		Funders:  ror.NewClient(ror.Options{BaseURL: b.cfg.RORURL, ClientID: b.cfg.RORClientID}),
+		Log:      b.log,
+	}
+	return b.options(api.Options{
+		Deposits: &deposit.Manager{
+			State:    b.state,
+			Datasets: datasets,
+			PIDs:     r,
+			Versions: r,
+			Media:    r,
+			Log:      b.log,
+		},
+	}), nil
+}
+
+// Search returns the options of the search function, which queries the
+// OpenSearch domain of the dataset index.
+func (b *Backend) Search() (api.Options, error) {
+	if b.cfg.OpenSearchURL == "" {
+		return api.Options{}, fmt.Errorf("OpenSearch is not configured; set APERTURE_OPENSEARCH_URL")
+	}
+	s := &search.Searcher{
+		Index:    search.NewClient(search.ClientOptions{Endpoint: b.cfg.OpenSearchURL, Region: b.cfg.AWSRegion, Credentials: b.creds}),
+		Alias:    b.cfg.SearchAlias(),
+		Datasets: dataset.NewStore(b.state),
+	}
+	return b.options(api.Options{Search: search.NewHandler(s)}), nil
+}
+
+// options completes opts with what every function needs, as 'aperture
+// serve' does: machine tokens are accepted where the API Gateway
+// authorizer does not stand in front of a route, and principals get the
+// groups a CLI user would, administrators and the groups of their
+// roles.
+func (b *Backend) options(opts api.Options) api.Options {
+	tokens := token.NewRegistry(b.state, b.log)
+	opts.Authenticate = func(ctx context.Context, secret string) (identity.Principal, error) {
+		t, err := tokens.Authenticate(ctx, secret)
+		if err != nil {
+			return identity.Principal{}, err
+		}
+		return t.Principal(), nil
+	}
+	roles := role.NewRegistry(b.state, b.log)
+	opts.Resolve = func(ctx context.Context, p identity.Principal) (identity.Principal, error) {
+		if b.cfg.IsAdmin(p.ID) {
+			p.Groups = append(p.Groups, authz.AdminGroup)
+		}
+		return roles.Apply(ctx, p)
+	}
+	opts.OriginSecret = b.cfg.CloudFrontOriginSecret
+	return opts
+}
+
+// datasets returns the dataset catalog, recording the preservation
+// events of changes to it and, with OpenSearch configured, indexing
+// them.
+func (b *Backend) datasets() *dataset.Store {
+	st := dataset.NewStore(b.state)
+	st.Observe(&premis.Observer{Log: &premis.Log{State: b.state, Audit: b.log}})
+	if b.cfg.OpenSearchURL != "" {
+		st.Observe(&search.Indexer{
+			Index:   search.NewClient(search.ClientOptions{Endpoint: b.cfg.OpenSearchURL, Region: b.cfg.AWSRegion, Credentials: b.creds}),
+			Alias:   b.cfg.SearchAlias(),
+			State:   b.state,
+			SiteURL: b.cfg.SiteURL,
+		})
+	}
+	return st
+}
+
+// registrar returns the registrar of the configured identifier
+// schemes, like 'aperture pid'.
+func (b *Backend) registrar() *pid.Registrar {
+	cfg := b.cfg
+	r := &pid.Registrar{
+		Default:     pid.Scheme(cfg.PIDScheme),
+		Collections: make(map[string]pid.Scheme),
+		Minters:     make(map[pid.Scheme]pid.Minter),
+		SiteURL:     cfg.SiteURL,
+		Publisher:   cfg.Publisher,
+		DownloadURL: cfg.DownloadURL,
+		Concepts:    &collection.Registry{State: b.state},
+	}
+	for coll, scheme := range cfg.PIDSchemes {
+		r.Collections[coll] = pid.Scheme(scheme)
+	}
+	if cfg.DataCitePrefix != "" && cfg.DataCiteRepositoryID != "" {
+		client := datacite.NewClient(datacite.Options{
+			BaseURL:      cfg.DataCiteURL,
+			MDSURL:       cfg.DataCiteMDSURL,
+			RepositoryID: cfg.DataCiteRepositoryID,
+			Password:     cfg.DataCitePassword,
+			UsageToken:   cfg.DataCiteUsageToken,
+			Limiter: datacite.NewLimiter(datacite.LimiterOptions{
+				Rate:        cfg.DataCiteRateLimit,
+				Burst:       cfg.DataCiteConcurrency,
+				Concurrency: cfg.DataCiteConcurrency,
+			}),
+		})
+		r.Minters[pid.SchemeDOI] = &pid.DataCite{Client: client, Media: client, Prefix: cfg.DataCitePrefix}
+	}
+	if cfg.ARKShoulder != "" && cfg.EZIDUsername != "" {
+		r.Minters[pid.SchemeARK] = pid.NewEZID(pid.EZIDOptions{
+			BaseURL:  cfg.EZIDURL,
+			Username: cfg.EZIDUsername,
+			Password: cfg.EZIDPassword,
+			Shoulder: cfg.ARKShoulder,
+		})
+	}
+	if cfg.HandlePrefix != "" && cfg.HandleURL != "" {
+		r.Minters[pid.SchemeHandle] = pid.NewHandle(pid.HandleOptions{
+			BaseURL:  cfg.HandleURL,
+			Prefix:   cfg.HandlePrefix,
+			Admin:    cfg.HandleAdmin,
+			Password: cfg.HandlePassword,
+		})
+	}
+	return r
+}
+
+// Main runs the function whose options build returns under the Lambda
+// runtime API, and exits if it cannot start.
+func Main(name string, build func(*Backend) (api.Options, error)) {
+	if err := run(name, build); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(name string, build func(*Backend) (api.Options, error)) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	runtimeAPI := os.Getenv("AWS_LAMBDA_RUNTIME_API")
+	if runtimeAPI == "" {
+		return fmt.Errorf("the %s function runs in AWS Lambda; run 'aperture serve' to serve the API elsewhere", name)
+	}
+	b, err := Open(cfg, os.Stdout)
+	if err != nil {
+		return err
+	}
+	opts, err := build(b)
+	if err != nil {
+		return err
+	}
+	var h http.Handler = api.NewHandler(opts)
+	if cfg.MetricsTarget != "" {
+		m, err := metrics.Open(cfg.MetricsTarget, cfg.MetricsNamespace, metrics.Dimensions{"Environment": cfg.Environment})
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		h = metrics.Handler(m, name, h)
+	}
+	return (&api.Runtime{API: runtimeAPI, Handler: h}).Run(ctx)
+}
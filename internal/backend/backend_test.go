@@ -0,0 +1,100 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/config"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func newTestBackend(t *testing.T, cfg *config.Config) *Backend {
+	t.Helper()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	cfg.StateBackend = "dynamodb"
+	b, err := Open(cfg, io.Discard)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	// Keep records in memory rather than a table.
+	b.state = state.NewMemoryStore()
+	return b
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := Open(&config.Config{StateBackend: "files"}, io.Discard); err == nil {
+		t.Error("Open() with the files state backend succeeded, want error")
+	}
+	b := newTestBackend(t, &config.Config{ProjectName: "aperture", Environment: "prod"})
+	if b.cfg.StateTable() != "aperture-state-prod" {
+		t.Errorf("state table = %s", b.cfg.StateTable())
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	b := newTestBackend(t, &config.Config{
+		AWSRegion:         "us-east-1",
+		StorageBackend:    "filesystem",
+		CognitoUserPoolID: "us-east-1_pool",
+		Admins:            []string{"root@uni.edu"},
+	})
+	for name, build := range map[string]func(*Backend) error{
+		"auth":    func(b *Backend) error { _, err := b.Auth(); return err },
+		"presign": func(b *Backend) error { _, err := b.Presign(); return err },
+		"search":  func(b *Backend) error { _, err := b.Search(); return err },
+	} {
+		if err := build(b); err == nil {
+			t.Errorf("%s without its settings succeeded, want error", name)
+		}
+	}
+	if _, err := b.DOI(); !errors.Is(err, pid.ErrNotConfigured) {
+		t.Errorf("DOI() without a scheme error = %v, want ErrNotConfigured", err)
+	}
+
+	b.cfg.OIDCClientID = "web"
+	b.cfg.StorageBackend = "s3"
+	b.cfg.OpenSearchURL = "https://search.example.org"
+	b.cfg.DataCitePrefix, b.cfg.DataCiteRepositoryID = "10.5555", "UNI.DATA"
+	opts, err := b.Auth()
+	if err != nil || opts.Accounts == nil || opts.ClientID != "web" {
+		t.Errorf("Auth() = %+v, %v", opts, err)
+	}
+	if opts, err := b.Presign(); err != nil || opts.Issuer == nil || opts.Agreements == nil {
+		t.Errorf("Presign() = %+v, %v", opts, err)
+	}
+	if opts, err := b.DOI(); err != nil || opts.Deposits == nil {
+		t.Errorf("DOI() = %+v, %v", opts, err)
+	}
+	if opts, err := b.Search(); err != nil || opts.Search == nil {
+		t.Errorf("Search() = %+v, %v", opts, err)
+	}
+
+	p, err := opts.Resolve(context.Background(), identity.Principal{ID: "root@uni.edu"})
+	if err != nil || !slices.Contains(p.Groups, authz.AdminGroup) {
+		t.Errorf("Resolve() = %+v, %v, want an administrator", p, err)
+	}
+	if _, err := opts.Authenticate(context.Background(), "apt_unknown"); err == nil {
+		t.Error("Authenticate() of an unknown token succeeded")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package badge serves SVG badges showing the live downloads,
+// citations, version, and DOI of published datasets, and an HTML
+// snippet embedding them, for researchers to put on lab and project
+// websites.
+package badge
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+)
+
+// Badge colors.
+const (
+	labelColor = "#555"
+	Blue       = "#007ec6"
+	Green      = "#4c1"
+	Orange     = "#fe7d37"
+)
+
+// Badge is a two-part label and message badge in the style of
+// shields.io.
+type Badge struct {
+	Label   string
+	Message string
+	Color   string
+}
+
+// padding is the horizontal space around each part's text.
+const padding = 10
+
+// WriteSVG writes b as a standalone SVG image.
+func (b Badge) WriteSVG(w io.Writer) error {
+	lw, mw := textWidth(b.Label)+padding, textWidth(b.Message)+padding
+	width := lw + mw
+	label, message := html.EscapeString(b.Label), html.EscapeString(b.Message)
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[2]s: %[3]s">
+<title>%[2]s: %[3]s</title>
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="%[4]d" height="20" fill="%[6]s"/><rect x="%[4]d" width="%[5]d" height="20" fill="%[7]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%[8]s" y="15" fill="#010101" fill-opacity=".3">%[2]s</text><text x="%[8]s" y="14">%[2]s</text>
+<text x="%[9]s" y="15" fill="#010101" fill-opacity=".3">%[3]s</text><text x="%[9]s" y="14">%[3]s</text>
+</g>
+</svg>
+`, width, label, message, lw, mw, labelColor, html.EscapeString(b.Color),
+		half(lw), half(2*lw+mw))
+	return err
+}
+
+// half formats n/2 with at most one decimal place.
+func half(n int) string {
+	return strconv.FormatFloat(float64(n)/2, 'f', -1, 64)
+}
+
+// textWidth estimates the width in pixels of s in 11px Verdana.
+func textWidth(s string) int {
+	w := 0.0
+	for _, r := range s {
+		switch {
+		case r == ' ':
+			w += 3.9
+		case r == 'i' || r == 'l' || r == 'j' || r == '.' || r == ',' || r == ':' || r == ';' || r == '|' || r == '!' || r == '\'':
+			w += 3.5
+		case r == 'm' || r == 'w' || r == 'M' || r == 'W':
+			w += 10.5
+		case r == 'f' || r == 't' || r == 'r' || r == '/' || r == '(' || r == ')' || r == '-':
+			w += 4.9
+		case r >= 'A' && r <= 'Z':
+			w += 7.7
+		default:
+			w += 7
+		}
+	}
+	return int(w + 0.5)
+}
+
+// Count formats n compactly: 999, 1.2k, 12k, 1.2M.
+func Count(n int) string {
+	switch {
+	case n < 1000:
+		return strconv.Itoa(n)
+	case n < 10_000:
+		return strconv.FormatFloat(float64(n/100)/10, 'f', -1, 64) + "k"
+	case n < 1_000_000:
+		return strconv.Itoa(n/1000) + "k"
+	case n < 10_000_000:
+		return strconv.FormatFloat(float64(n/100_000)/10, 'f', -1, 64) + "M"
+	}
+	return strconv.Itoa(n/1_000_000) + "M"
+}
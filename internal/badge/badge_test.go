@@ -0,0 +1,135 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package badge
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/citation"
+	"github.com/scttfrdmn/aperture/internal/counter"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+type fakeUsage map[string]int
+
+func (f fakeUsage) Dataset(_ context.Context, ref string, _ counter.StatsQuery) (*counter.Stats, error) {
+	return &counter.Stats{Dataset: ref, Downloads: f[ref]}, nil
+}
+
+type fakeCitations map[string]int
+
+func (f fakeCitations) Get(_ context.Context, ref string) (*citation.Record, error) {
+	return &citation.Record{DatasetID: ref, Count: f[ref]}, nil
+}
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	ctx := context.Background()
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", DOI: "10.1234/abc", State: dataset.StatePublished, Versions: []dataset.Version{{Number: 1}, {Number: 2}}},
+		{ID: "ds-2", State: dataset.StatePublished, Versions: []dataset.Version{{Number: 1, Tag: "v1.0.0"}}},
+		{ID: "ds-3", DOI: "10.1234/draft", State: dataset.StateDraft},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewHandler(Options{
+		Datasets:  datasets,
+		Usage:     fakeUsage{"ds-1": 1234},
+		Citations: fakeCitations{"ds-1": 7},
+		BaseURL:   "https://dl.example.edu/",
+		SiteURL:   "https://data.example.edu",
+	})
+}
+
+func TestBadges(t *testing.T) {
+	h := newTestHandler(t)
+	for _, tt := range []struct {
+		path    string
+		code    int
+		message string
+	}{
+		{"/badges/downloads/ds-1.svg", http.StatusOK, "downloads: 1.2k"},
+		{"/badges/citations/10.1234/abc.svg", http.StatusOK, "citations: 7"},
+		{"/badges/version/ds-1.svg", http.StatusOK, "version: v2"},
+		{"/badges/version/ds-2.svg", http.StatusOK, "version: v1.0.0"},
+		{"/badges/doi/ds-1.svg", http.StatusOK, "DOI: 10.1234/abc"},
+		{"/badges/doi/ds-2.svg", http.StatusNotFound, ""},
+		{"/badges/doi/ds-3.svg", http.StatusNotFound, ""},
+		{"/badges/stars/ds-1.svg", http.StatusNotFound, ""},
+		{"/badges/doi/ds-1", http.StatusNotFound, ""},
+		{"/badges/doi/missing.svg", http.StatusNotFound, ""},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("GET %s = %d, want %d", tt.path, w.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "image/svg+xml") {
+			t.Errorf("GET %s Content-Type = %q", tt.path, ct)
+		}
+		var svg struct {
+			Title string `xml:"title"`
+		}
+		if err := xml.Unmarshal(w.Body.Bytes(), &svg); err != nil {
+			t.Errorf("GET %s is not well-formed SVG: %v", tt.path, err)
+		}
+		if svg.Title != tt.message {
+			t.Errorf("GET %s title = %q, want %q", tt.path, svg.Title, tt.message)
+		}
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	h := newTestHandler(t)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/embed/ds-2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /embed/ds-2 = %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`<a href="https://data.example.edu/datasets/ds-2/"><img src="https://dl.example.edu/badges/version/ds-2.svg" alt="version: v1.0.0"></a>`,
+		`/badges/downloads/ds-2.svg`,
+		`/badges/citations/ds-2.svg`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("snippet missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "/badges/doi/") {
+		t.Errorf("snippet of a dataset without a DOI shows a DOI badge:\n%s", body)
+	}
+}
+
+func TestCount(t *testing.T) {
+	for n, want := range map[int]string{0: "0", 999: "999", 1000: "1k", 1250: "1.2k", 12345: "12k", 999999: "999k", 1234567: "1.2M", 45000000: "45M"} {
+		if got := Count(n); got != want {
+			t.Errorf("Count(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
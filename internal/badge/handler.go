@@ -0,0 +1,223 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package badge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/citation"
+	"github.com/scttfrdmn/aperture/internal/counter"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+)
+
+// maxAge is how long clients and the CDN may cache badges, which change
+// only as access logs are ingested and citations are found.
+const maxAge = "public, max-age=900"
+
+// Kinds of badge, in the order snippets show them.
+var Kinds = []string{"doi", "version", "downloads", "citations"}
+
+// UsageSource reports the usage of a dataset. *counter.Analytics
+// implements it.
+type UsageSource interface {
+	Dataset(ctx context.Context, ref string, q counter.StatsQuery) (*counter.Stats, error)
+}
+
+// CitationSource returns the citations found for a dataset.
+// *citation.Tracker implements it.
+type CitationSource interface {
+	Get(ctx context.Context, ref string) (*citation.Record, error)
+}
+
+// Options configure a Handler.
+type Options struct {
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Usage counts downloads; downloads badges are not served if nil
+	Usage UsageSource
+
+	// Citations counts citations; citations badges are not served if
+	// nil
+	Citations CitationSource
+
+	// BaseURL is the public URL the handler is served under, used in
+	// snippets
+	BaseURL string
+
+	// SiteURL is the base URL of landing pages, which snippets link to
+	SiteURL string
+}
+
+// Handler serves badges and snippets of published datasets:
+//
+//	GET /badges/{kind}/{ref}.svg   a badge: doi, version, downloads, or citations
+//	GET /embed/{ref}               an HTML snippet linking every badge to the landing page
+//
+// ref is a dataset ID or persistent identifier.
+type Handler struct {
+	opts Options
+	mux  *http.ServeMux
+}
+
+// NewHandler returns a handler serving badges with o.
+func NewHandler(o Options) *Handler {
+	h := &Handler{opts: o, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /badges/{kind}/{ref...}", h.badge)
+	h.mux.HandleFunc("GET /embed/{ref...}", h.embed)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) badge(w http.ResponseWriter, r *http.Request) {
+	ref, ok := strings.CutSuffix(r.PathValue("ref"), ".svg")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	d, err := h.dataset(r.Context(), ref)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	b, err := h.Badge(r.Context(), d, r.PathValue("kind"))
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := b.WriteSVG(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", maxAge)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// Badge returns the badge of kind for the published dataset d. It
+// returns an error wrapping dataset.ErrNotFound for kinds the handler
+// cannot serve or d does not have, such as a DOI badge for a dataset
+// without a DOI.
+func (h *Handler) Badge(ctx context.Context, d *dataset.Dataset, kind string) (Badge, error) {
+	switch kind {
+	case "doi":
+		if d.DOI != "" {
+			return Badge{Label: "DOI", Message: d.DOI, Color: Blue}, nil
+		}
+	case "version":
+		if v := d.Current(); v != nil {
+			msg := "v" + strconv.Itoa(v.Number)
+			if v.Tag != "" {
+				msg = v.Tag
+			}
+			return Badge{Label: "version", Message: msg, Color: Blue}, nil
+		}
+	case "downloads":
+		if h.opts.Usage != nil {
+			s, err := h.opts.Usage.Dataset(ctx, d.ID, counter.StatsQuery{})
+			if err != nil {
+				return Badge{}, err
+			}
+			return Badge{Label: "downloads", Message: Count(s.Downloads), Color: Green}, nil
+		}
+	case "citations":
+		if h.opts.Citations != nil {
+			rec, err := h.opts.Citations.Get(ctx, d.ID)
+			if err != nil {
+				return Badge{}, err
+			}
+			return Badge{Label: "citations", Message: Count(rec.Count), Color: Orange}, nil
+		}
+	}
+	return Badge{}, fmt.Errorf("%w: no %s badge for %s", dataset.ErrNotFound, kind, d.ID)
+}
+
+// snippet is the HTML embedding a dataset's badges.
+var snippet = template.Must(template.New("snippet").Parse(`<span class="aperture-badges">
+{{- range .Badges}}
+<a href="{{$.Page}}"><img src="{{.Src}}" alt="{{.Alt}}"></a>
+{{- end}}
+</span>
+`))
+
+func (h *Handler) embed(w http.ResponseWriter, r *http.Request) {
+	d, err := h.dataset(r.Context(), r.PathValue("ref"))
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	type img struct{ Src, Alt string }
+	data := struct {
+		Page   string
+		Badges []img
+	}{Page: strings.TrimSuffix(h.opts.SiteURL, "/") + landing.PagePath(d.ID)}
+	for _, kind := range Kinds {
+		b, err := h.Badge(r.Context(), d, kind)
+		if errors.Is(err, dataset.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data.Badges = append(data.Badges, img{
+			Src: strings.TrimSuffix(h.opts.BaseURL, "/") + "/badges/" + kind + "/" + d.ID + ".svg",
+			Alt: b.Label + ": " + b.Message,
+		})
+	}
+	var buf bytes.Buffer
+	if err := snippet.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", maxAge)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// dataset returns the published dataset ref.
+func (h *Handler) dataset(ctx context.Context, ref string) (*dataset.Dataset, error) {
+	d, err := h.opts.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if d.State != dataset.StatePublished {
+		return nil, fmt.Errorf("%w: %s is not published", dataset.ErrNotFound, d.ID)
+	}
+	return d, nil
+}
+
+// fail answers 404 for unknown datasets and badges, and 500 otherwise.
+func (h *Handler) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, dataset.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,381 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package blake3 implements the BLAKE3 hash function with the 256-bit
+// default output, for recording fast file digests alongside SHA-256.
+//
+// Input is split into 1 KiB chunks whose chaining values are merged
+// pairwise into a binary tree, so the chunks of a large write are
+// hashed in parallel across the available CPUs. This implementation is
+// portable Go without SIMD; keyed hashing, key derivation, and extended
+// output are not supported.
+package blake3
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+	"runtime"
+	"sync"
+)
+
+// Size is the size of a BLAKE3 digest in bytes.
+const Size = 32
+
+// BlockSize is the block size of BLAKE3 in bytes.
+const BlockSize = 64
+
+// chunkLen is the number of input bytes in a chunk.
+const chunkLen = 1024
+
+// minChunksPerWorker is the fewest chunks worth hashing on another
+// goroutine.
+const minChunksPerWorker = 64
+
+// Domain separation flags.
+const (
+	flagChunkStart = 1 << 0
+	flagChunkEnd   = 1 << 1
+	flagParent     = 1 << 2
+	flagRoot       = 1 << 3
+)
+
+// iv is the initialization vector, shared with SHA-256.
+var iv = [8]uint32{
+	0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A,
+	0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19,
+}
+
+func g(a, b, c, d, mx, my uint32) (uint32, uint32, uint32, uint32) {
+	a += b + mx
+	d = bits.RotateLeft32(d^a, -16)
+	c += d
+	b = bits.RotateLeft32(b^c, -12)
+	a += b + my
+	d = bits.RotateLeft32(d^a, -8)
+	c += d
+	b = bits.RotateLeft32(b^c, -7)
+	return a, b, c, d
+}
+
+// compress runs the compression function over one block and returns
+// the full 16-word state; its first 8 words are the chaining value.
+// Each of the seven rounds applies g to the columns and then the
+// diagonals of the state, taking the message words in the order of the
+// previous round permuted by 2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5,
+// 9, 14, 15, 8.
+func compress(cv *[8]uint32, m *[16]uint32, counter uint64, blockLen, flags uint32) [16]uint32 {
+	v0, v1, v2, v3, v4, v5, v6, v7 := cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7]
+	v8, v9, v10, v11 := iv[0], iv[1], iv[2], iv[3]
+	v12, v13, v14, v15 := uint32(counter), uint32(counter>>32), blockLen, flags
+	m0, m1, m2, m3, m4, m5, m6, m7 := m[0], m[1], m[2], m[3], m[4], m[5], m[6], m[7]
+	m8, m9, m10, m11, m12, m13, m14, m15 := m[8], m[9], m[10], m[11], m[12], m[13], m[14], m[15]
+
+	// Round 1.
+	v0, v4, v8, v12 = g(v0, v4, v8, v12, m0, m1)
+	v1, v5, v9, v13 = g(v1, v5, v9, v13, m2, m3)
+	v2, v6, v10, v14 = g(v2, v6, v10, v14, m4, m5)
+	v3, v7, v11, v15 = g(v3, v7, v11, v15, m6, m7)
+	v0, v5, v10, v15 = g(v0, v5, v10, v15, m8, m9)
+	v1, v6, v11, v12 = g(v1, v6, v11, v12, m10, m11)
+	v2, v7, v8, v13 = g(v2, v7, v8, v13, m12, m13)
+	v3, v4, v9, v14 = g(v3, v4, v9, v14, m14, m15)
+
+	// Round 2.
+	v0, v4, v8, v12 = g(v0, v4, v8, v12, m2, m6)
+	v1, v5, v9, v13 = g(v1, v5, v9, v13, m3, m10)
+	v2, v6, v10, v14 = g(v2, v6, v10, v14, m7, m0)
+	v3, v7, v11, v15 = g(v3, v7, v11, v15, m4, m13)
+	v0, v5, v10, v15 = g(v0, v5, v10, v15, m1, m11)
+	v1, v6, v11, v12 = g(v1, v6, v11, v12, m12, m5)
+	v2, v7, v8, v13 = g(v2, v7, v8, v13, m9, m14)
+	v3, v4, v9, v14 = g(v3, v4, v9, v14, m15, m8)
+
+	// Round 3.
+	v0, v4, v8, v12 = g(v0, v4, v8, v12, m3, m4)
+	v1, v5, v9, v13 = g(v1, v5, v9, v13, m10, m12)
+	v2, v6, v10, v14 = g(v2, v6, v10, v14, m13, m2)
+	v3, v7, v11, v15 = g(v3, v7, v11, v15, m7, m14)
+	v0, v5, v10, v15 = g(v0, v5, v10, v15, m6, m5)
+	v1, v6, v11, v12 = g(v1, v6, v11, v12, m9, m0)
+	v2, v7, v8, v13 = g(v2, v7, v8, v13, m11, m15)
+	v3, v4, v9, v14 = g(v3, v4, v9, v14, m8, m1)
+
+	// Round 4.
+	v0, v4, v8, v12 = g(v0, v4, v8, v12, m10, m7)
+	v1, v5, v9, v13 = g(v1, v5, v9, v13, m12, m9)
+	v2, v6, v10, v14 = g(v2, v6, v10, v14, m14, m3)
+	v3, v7, v11, v15 = g(v3, v7, v11, v15, m13, m15)
+	v0, v5, v10, v15 = g(v0, v5, v10, v15, m4, m0)
+	v1, v6, v11, v12 = g(v1, v6, v11, v12, m11, m2)
+	v2, v7, v8, v13 = g(v2, v7, v8, v13, m5, m8)
+	v3, v4, v9, v14 = g(v3, v4, v9, v14, m1, m6)
+
+	// Round 5.
+	v0, v4, v8, v12 = g(v0, v4, v8, v12, m12, m13)
+	v1, v5, v9, v13 = g(v1, v5, v9, v13, m9, m11)
+	v2, v6, v10, v14 = g(v2, v6, v10, v14, m15, m10)
+	v3, v7, v11, v15 = g(v3, v7, v11, v15, m14, m8)
+	v0, v5, v10, v15 = g(v0, v5, v10, v15, m7, m2)
+	v1, v6, v11, v12 = g(v1, v6, v11, v12, m5, m3)
+	v2, v7, v8, v13 = g(v2, v7, v8, v13, m0, m1)
+	v3, v4, v9, v14 = g(v3, v4, v9, v14, m6, m4)
+
+	// Round 6.
+	v0, v4, v8, v12 = g(v0, v4, v8, v12, m9, m14)
+	v1, v5, v9, v13 = g(v1, v5, v9, v13, m11, m5)
+	v2, v6, v10, v14 = g(v2, v6, v10, v14, m8, m12)
+	v3, v7, v11, v15 = g(v3, v7, v11, v15, m15, m1)
+	v0, v5, v10, v15 = g(v0, v5, v10, v15, m13, m3)
+	v1, v6, v11, v12 = g(v1, v6, v11, v12, m0, m10)
+	v2, v7, v8, v13 = g(v2, v7, v8, v13, m2, m6)
+	v3, v4, v9, v14 = g(v3, v4, v9, v14, m4, m7)
+
+	// Round 7.
+	v0, v4, v8, v12 = g(v0, v4, v8, v12, m11, m15)
+	v1, v5, v9, v13 = g(v1, v5, v9, v13, m5, m0)
+	v2, v6, v10, v14 = g(v2, v6, v10, v14, m1, m9)
+	v3, v7, v11, v15 = g(v3, v7, v11, v15, m8, m6)
+	v0, v5, v10, v15 = g(v0, v5, v10, v15, m14, m10)
+	v1, v6, v11, v12 = g(v1, v6, v11, v12, m2, m12)
+	v2, v7, v8, v13 = g(v2, v7, v8, v13, m3, m4)
+	v3, v4, v9, v14 = g(v3, v4, v9, v14, m7, m13)
+
+	return [16]uint32{
+		v0 ^ v8, v1 ^ v9, v2 ^ v10, v3 ^ v11, v4 ^ v12, v5 ^ v13, v6 ^ v14, v7 ^ v15,
+		v8 ^ cv[0], v9 ^ cv[1], v10 ^ cv[2], v11 ^ cv[3], v12 ^ cv[4], v13 ^ cv[5], v14 ^ cv[6], v15 ^ cv[7],
+	}
+}
+
+func words(b []byte) [16]uint32 {
+	var buf [BlockSize]byte
+	copy(buf[:], b)
+	var w [16]uint32
+	for i := range w {
+		w[i] = binary.LittleEndian.Uint32(buf[4*i:])
+	}
+	return w
+}
+
+func first8(s [16]uint32) [8]uint32 {
+	return [8]uint32(s[:8])
+}
+
+// output is a node of the tree not yet compressed, which is either
+// the root or yields a chaining value to its parent.
+type output struct {
+	cv       [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+func (o *output) chainingValue() [8]uint32 {
+	return first8(compress(&o.cv, &o.block, o.counter, o.blockLen, o.flags))
+}
+
+func (o *output) root() [Size]byte {
+	s := compress(&o.cv, &o.block, 0, o.blockLen, o.flags|flagRoot)
+	var out [Size]byte
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint32(out[4*i:], s[i])
+	}
+	return out
+}
+
+func parent(left, right [8]uint32) output {
+	var block [16]uint32
+	copy(block[:8], left[:])
+	copy(block[8:], right[:])
+	return output{cv: iv, block: block, blockLen: BlockSize, flags: flagParent}
+}
+
+// chunk is the state of the chunk being hashed.
+type chunk struct {
+	cv         [8]uint32
+	counter    uint64
+	buf        [BlockSize]byte
+	bufLen     int
+	compressed int
+}
+
+func newChunk(counter uint64) chunk {
+	return chunk{cv: iv, counter: counter}
+}
+
+func (c *chunk) len() int {
+	return BlockSize*c.compressed + c.bufLen
+}
+
+func (c *chunk) startFlag() uint32 {
+	if c.compressed == 0 {
+		return flagChunkStart
+	}
+	return 0
+}
+
+func (c *chunk) write(p []byte) {
+	for len(p) > 0 {
+		// A full block is compressed only once more input arrives,
+		// since the last block of the chunk is flagged differently.
+		if c.bufLen == BlockSize {
+			block := words(c.buf[:])
+			c.cv = first8(compress(&c.cv, &block, c.counter, BlockSize, c.startFlag()))
+			c.compressed++
+			c.bufLen = 0
+		}
+		n := copy(c.buf[c.bufLen:], p)
+		c.bufLen += n
+		p = p[n:]
+	}
+}
+
+func (c *chunk) output() output {
+	return output{
+		cv:       c.cv,
+		block:    words(c.buf[:c.bufLen]),
+		counter:  c.counter,
+		blockLen: uint32(c.bufLen),
+		flags:    c.startFlag() | flagChunkEnd,
+	}
+}
+
+// digest is a BLAKE3 hash.Hash.
+type digest struct {
+	chunk chunk
+
+	// stack holds the chaining values of completed subtrees, one per
+	// set bit of the number of completed chunks
+	stack [][8]uint32
+}
+
+// New returns a hash.Hash computing the BLAKE3 digest.
+func New() hash.Hash {
+	return &digest{chunk: newChunk(0)}
+}
+
+// Sum256 returns the BLAKE3 digest of data.
+func Sum256(data []byte) [Size]byte {
+	var d digest
+	d.Reset()
+	d.Write(data)
+	return d.sum()
+}
+
+func (d *digest) Size() int      { return Size }
+func (d *digest) BlockSize() int { return BlockSize }
+
+func (d *digest) Reset() {
+	d.chunk = newChunk(0)
+	d.stack = d.stack[:0]
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		// A full chunk is added to the tree only once more input
+		// arrives, since the last chunk may be the root.
+		if d.chunk.len() == chunkLen {
+			o := d.chunk.output()
+			d.push(o.chainingValue(), d.chunk.counter+1)
+			d.chunk = newChunk(d.chunk.counter + 1)
+		}
+		if d.chunk.len() == 0 && len(p) > chunkLen {
+			whole := (len(p) - 1) / chunkLen
+			d.chunks(p[:whole*chunkLen])
+			p = p[whole*chunkLen:]
+			continue
+		}
+		take := min(chunkLen-d.chunk.len(), len(p))
+		d.chunk.write(p[:take])
+		p = p[take:]
+	}
+	return n, nil
+}
+
+// chunks adds whole chunks of p to the tree, starting at the current
+// chunk, which must be empty. Large runs of chunks are hashed in
+// parallel.
+func (d *digest) chunks(p []byte) {
+	base := d.chunk.counter
+	cvs := make([][8]uint32, len(p)/chunkLen)
+	workers := min(runtime.GOMAXPROCS(0), len(cvs)/minChunksPerWorker)
+	if workers <= 1 {
+		for i := range cvs {
+			cvs[i] = chunkCV(p[i*chunkLen:(i+1)*chunkLen], base+uint64(i))
+		}
+	} else {
+		var wg sync.WaitGroup
+		per := (len(cvs) + workers - 1) / workers
+		for lo := 0; lo < len(cvs); lo += per {
+			hi := min(lo+per, len(cvs))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := lo; i < hi; i++ {
+					cvs[i] = chunkCV(p[i*chunkLen:(i+1)*chunkLen], base+uint64(i))
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	for i, cv := range cvs {
+		d.push(cv, base+uint64(i)+1)
+	}
+	d.chunk = newChunk(base + uint64(len(cvs)))
+}
+
+// chunkCV returns the chaining value of the whole chunk p, which is
+// not the root.
+func chunkCV(p []byte, counter uint64) [8]uint32 {
+	cv := iv
+	for i := 0; i < chunkLen; i += BlockSize {
+		var flags uint32
+		switch i {
+		case 0:
+			flags = flagChunkStart
+		case chunkLen - BlockSize:
+			flags = flagChunkEnd
+		}
+		block := words(p[i : i+BlockSize])
+		cv = first8(compress(&cv, &block, counter, BlockSize, flags))
+	}
+	return cv
+}
+
+// push adds the chaining value of a completed chunk, merging the
+// subtrees it completes; total is the number of completed chunks.
+func (d *digest) push(cv [8]uint32, total uint64) {
+	for total&1 == 0 {
+		o := parent(d.stack[len(d.stack)-1], cv)
+		cv = o.chainingValue()
+		d.stack = d.stack[:len(d.stack)-1]
+		total >>= 1
+	}
+	d.stack = append(d.stack, cv)
+}
+
+func (d *digest) sum() [Size]byte {
+	o := d.chunk.output()
+	for i := len(d.stack) - 1; i >= 0; i-- {
+		o = parent(d.stack[i], o.chainingValue())
+	}
+	return o.root()
+}
+
+func (d *digest) Sum(b []byte) []byte {
+	sum := d.sum()
+	return append(b, sum[:]...)
+}
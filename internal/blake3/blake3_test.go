@@ -0,0 +1,85 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blake3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"runtime"
+	"testing"
+)
+
+// input returns the test vector input of length n: bytes 0 to 250,
+// repeating.
+func input(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i % 251)
+	}
+	return b
+}
+
+// Vectors from the BLAKE3 reference test_vectors.json.
+var vectors = []struct {
+	n    int
+	want string
+}{
+	{0, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+	{1, "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213"},
+	{1024, "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7"},
+	{1025, "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444"},
+	{2048, "e776b6028c7cd22a4d0ba182a8bf62205d2ef576467e838ed6f2529b85fba24a"},
+}
+
+func TestSum256(t *testing.T) {
+	for _, v := range vectors {
+		sum := Sum256(input(v.n))
+		if got := hex.EncodeToString(sum[:]); got != v.want {
+			t.Errorf("Sum256(%d bytes) = %s, want %s", v.n, got, v.want)
+		}
+	}
+	sum := Sum256([]byte("abc"))
+	if got := hex.EncodeToString(sum[:]); got != "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85" {
+		t.Errorf("Sum256(abc) = %s", got)
+	}
+}
+
+// TestWrite checks that digests do not depend on how input is split
+// across writes, or on whether whole chunks are hashed in parallel.
+func TestWrite(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	data := input(300*chunkLen + 17)
+	want := Sum256(data)
+	for _, size := range []int{1, 63, 64, 1000, chunkLen, chunkLen + 1, 100 * chunkLen, len(data)} {
+		h := New()
+		for rest := data; len(rest) > 0; {
+			n := min(size, len(rest))
+			h.Write(rest[:n])
+			rest = rest[n:]
+		}
+		if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+			t.Errorf("writes of %d bytes: digest %x, want %x", size, got, want)
+		}
+		// Sum does not change the state.
+		if again := h.Sum(nil); !bytes.Equal(again, want[:]) {
+			t.Errorf("writes of %d bytes: second Sum %x, want %x", size, again, want)
+		}
+		h.Reset()
+		h.Write(data[:1])
+		if got, one := h.Sum(nil), Sum256(data[:1]); !bytes.Equal(got, one[:]) {
+			t.Errorf("Reset() digest %x, want %x", got, one)
+		}
+	}
+}
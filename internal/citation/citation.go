@@ -0,0 +1,304 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package citation tracks citations of published datasets.
+//
+// Works citing a dataset are found by its DOIs, the dataset DOI and
+// its version DOIs, in DataCite Event Data, which collects links from
+// DataCite and Crossref metadata and from other sources, and in the
+// relations Crossref registrants declare in their works' metadata. The
+// citations found are stored per dataset, shown on landing pages, and
+// served as JSON, so that investigators can report the reuse of their
+// data, for example in grant renewals.
+package citation
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// citationsTable holds one Record per dataset checked for citations.
+const citationsTable = "citations"
+
+// maxShown is the number of citing works listed on a landing page.
+const maxShown = 25
+
+// citingRelations are the relations, from the citing work's side, that
+// count as citations, mapped from their inverses, the same relations
+// from the dataset's side.
+var citingRelations = map[string]string{
+	"is-cited-by":      "cites",
+	"is-referenced-by": "references",
+	"is-supplement-to": "is-supplemented-by",
+	"is-source-of":     "is-derived-from",
+}
+
+// Citing reports whether relation, a DataCite relation type in kebab
+// case, is a citation from the citing work's side.
+func Citing(relation string) bool {
+	for _, r := range citingRelations {
+		if r == relation {
+			return true
+		}
+	}
+	return false
+}
+
+// Citation is a work citing a dataset.
+type Citation struct {
+	// DOI is the citing work's DOI
+	DOI string `json:"doi"`
+
+	// Cited is the dataset or version DOI the work cites
+	Cited string `json:"cited"`
+
+	// Relation is how the work relates to the dataset, as a DataCite
+	// relation type from the citing work's side (e.g. "references")
+	Relation string `json:"relation"`
+
+	// Title, Container (the journal or repository), Year, and Type
+	// describe the citing work, if its metadata could be found
+	Title     string `json:"title,omitempty"`
+	Container string `json:"container,omitempty"`
+	Year      int    `json:"year,omitempty"`
+	Type      string `json:"type,omitempty"`
+
+	// Sources are the services that reported the citation
+	Sources []string `json:"sources"`
+
+	// Found is when the citation was first found
+	Found time.Time `json:"found"`
+}
+
+// Record holds the citations found for a dataset.
+type Record struct {
+	DatasetID string     `json:"datasetId"`
+	DOI       string     `json:"doi,omitempty"`
+	Count     int        `json:"count"`
+	Citations []Citation `json:"citations"`
+	Checked   *time.Time `json:"checked,omitempty"`
+}
+
+// Source finds works citing a DOI.
+type Source interface {
+	// Name identifies the source in Citation.Sources
+	Name() string
+
+	// Citing returns the works citing doi. Only DOI, Cited, and
+	// Relation need be set; other metadata may be.
+	Citing(ctx context.Context, doi string) ([]Citation, error)
+}
+
+// WorkSource describes citing works by DOI, filling in the metadata a
+// Source left out.
+type WorkSource interface {
+	Describe(ctx context.Context, c *Citation) error
+}
+
+// Update is the result of checking one dataset.
+type Update struct {
+	DatasetID string     `json:"datasetId"`
+	Count     int        `json:"count"`
+	New       []Citation `json:"new,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Tracker finds and stores the citations of published datasets.
+type Tracker struct {
+	Datasets *dataset.Store
+	State    state.Store
+	Sources  []Source
+
+	// Works describes citing works; their metadata is left as the
+	// sources reported it if nil
+	Works WorkSource
+
+	// Log records changes; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Get returns the citations of the dataset ref, an ID or persistent
+// identifier. A published dataset never checked has none.
+func (t *Tracker) Get(ctx context.Context, ref string) (*Record, error) {
+	d, err := t.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if d.State != dataset.StatePublished {
+		return nil, fmt.Errorf("%w: %s is not published", dataset.ErrNotFound, d.ID)
+	}
+	return t.record(ctx, d)
+}
+
+func (t *Tracker) record(ctx context.Context, d *dataset.Dataset) (*Record, error) {
+	var rec Record
+	err := t.State.Get(ctx, citationsTable, d.ID, &rec)
+	if errors.Is(err, state.ErrNotFound) {
+		return &Record{DatasetID: d.ID, DOI: d.DOI, Citations: []Citation{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Check asks every source for the works citing d's DOIs and stores
+// them, returning the citations not found before. A work citing several
+// versions is counted once. If a source fails nothing is stored, so
+// that citations are not lost to an outage.
+func (t *Tracker) Check(ctx context.Context, d *dataset.Dataset) (*Update, error) {
+	prev, err := t.record(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]*Citation, len(prev.Citations))
+	for i := range prev.Citations {
+		known[prev.Citations[i].DOI] = &prev.Citations[i]
+	}
+
+	ours := make(map[string]bool)
+	for _, doi := range d.DOIs() {
+		ours[dataset.NormalizeDOI(doi)] = true
+	}
+	now := t.now().UTC()
+	found := make(map[string]*Citation)
+	for _, doi := range d.DOIs() {
+		for _, src := range t.Sources {
+			citing, err := src.Citing(ctx, doi)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find citations of %s in %s: %w", doi, src.Name(), err)
+			}
+			for _, c := range citing {
+				c.DOI = dataset.NormalizeDOI(c.DOI)
+				if c.DOI == "" || ours[c.DOI] {
+					// A version citing its own dataset is not reuse.
+					continue
+				}
+				f := found[c.DOI]
+				if f == nil {
+					f = &c
+					f.Sources = nil
+					if k := known[c.DOI]; k != nil {
+						f.Found = k.Found
+						f.Title, f.Container, f.Year, f.Type = cmp.Or(f.Title, k.Title), cmp.Or(f.Container, k.Container), cmp.Or(f.Year, k.Year), cmp.Or(f.Type, k.Type)
+					} else {
+						f.Found = now
+					}
+					found[c.DOI] = f
+				}
+				if !slices.Contains(f.Sources, src.Name()) {
+					f.Sources = append(f.Sources, src.Name())
+				}
+			}
+		}
+	}
+
+	rec := &Record{DatasetID: d.ID, DOI: d.DOI, Citations: make([]Citation, 0, len(found)), Checked: &now}
+	up := &Update{DatasetID: d.ID}
+	for _, c := range found {
+		if t.Works != nil && c.Title == "" {
+			// A work that cannot be described is still a citation.
+			_ = t.Works.Describe(ctx, c)
+		}
+		slices.Sort(c.Sources)
+		rec.Citations = append(rec.Citations, *c)
+		if known[c.DOI] == nil {
+			up.New = append(up.New, *c)
+		}
+	}
+	sortCitations(rec.Citations)
+	sortCitations(up.New)
+	rec.Count, up.Count = len(rec.Citations), len(rec.Citations)
+	if err := t.State.Put(ctx, citationsTable, d.ID, rec); err != nil {
+		return nil, err
+	}
+	if len(up.New) > 0 && t.Log != nil {
+		if err := audit.Record(ctx, t.Log, "citation.found", d.ID, map[string]string{"new": strconv.Itoa(len(up.New)), "count": strconv.Itoa(rec.Count)}); err != nil {
+			return up, err
+		}
+	}
+	return up, nil
+}
+
+// Run checks every published dataset with a DOI, continuing past
+// datasets that fail.
+func (t *Tracker) Run(ctx context.Context) ([]Update, error) {
+	datasets, err := t.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []Update
+	var errs []error
+	for _, d := range datasets {
+		if d.State != dataset.StatePublished || len(d.DOIs()) == 0 {
+			continue
+		}
+		up, err := t.Check(ctx, d)
+		if err != nil {
+			errs = append(errs, err)
+			out = append(out, Update{DatasetID: d.ID, Error: err.Error()})
+			continue
+		}
+		out = append(out, *up)
+	}
+	return out, errors.Join(errs...)
+}
+
+// Citations implements landing.CitationSource, listing the most recent
+// citing works.
+func (t *Tracker) Citations(ctx context.Context, d *dataset.Dataset) (*landing.Citations, error) {
+	rec, err := t.record(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	out := &landing.Citations{Count: rec.Count}
+	for _, c := range rec.Citations[:min(len(rec.Citations), maxShown)] {
+		title := c.Title
+		if title == "" {
+			title = "https://doi.org/" + c.DOI
+		}
+		out.Works = append(out.Works, landing.Citation{Title: title, Container: c.Container, Year: c.Year, URL: "https://doi.org/" + c.DOI})
+	}
+	return out, nil
+}
+
+// sortCitations orders citations newest first, then by DOI.
+func sortCitations(cs []Citation) {
+	slices.SortFunc(cs, func(a, b Citation) int {
+		if a.Year != b.Year {
+			return cmp.Compare(b.Year, a.Year)
+		}
+		return cmp.Compare(a.DOI, b.DOI)
+	})
+}
+
+func (t *Tracker) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,248 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package citation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// fakeEventData serves Event Data for 10.5555/ds-1 on two pages.
+func fakeEventData(t *testing.T) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events" {
+			http.NotFound(w, r)
+			return
+		}
+		ev := func(subj, rel, obj string) map[string]any {
+			return map[string]any{"attributes": map[string]string{"subj-id": subj, "relation-type-id": rel, "obj-id": obj, "source-id": "crossref"}}
+		}
+		var data []map[string]any
+		var next string
+		switch r.URL.Query().Get("doi") {
+		case "10.5555/ds-1":
+			data = []map[string]any{
+				ev("https://doi.org/10.1000/paper-a", "references", "https://doi.org/10.5555/ds-1"),
+				ev("https://en.wikipedia.org/wiki/Soil", "references", "https://doi.org/10.5555/ds-1"),
+				ev("https://doi.org/10.5555/ds-1", "is-cited-by", "https://doi.org/10.1000/PAPER-B"),
+				ev("https://doi.org/10.5555/ds-1", "is-version-of", "https://doi.org/10.5555/ds-1.v1"),
+			}
+			// A full page would continue; a short one ends.
+			next = srv.URL + "/events?doi=10.5555/ds-1&page[cursor]=2"
+		case "10.5555/ds-1.v1":
+			data = []map[string]any{
+				ev("https://doi.org/10.1000/paper-a", "cites", "https://doi.org/10.5555/ds-1.v1"),
+				ev("https://doi.org/10.5555/ds-1", "has-version", "https://doi.org/10.5555/ds-1.v1"),
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data, "links": map[string]string{"next": next}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// fakeCrossref serves Crossref relations and works.
+func fakeCrossref(t *testing.T) *httptest.Server {
+	t.Helper()
+	works := map[string]map[string]any{
+		"10.1000/paper-a": {"DOI": "10.1000/paper-a", "type": "journal-article", "title": []string{"Soil respiration"}, "container-title": []string{"Soil Biology"}, "issued": map[string]any{"date-parts": [][]int{{2024, 3}}}},
+		"10.1000/paper-b": {"DOI": "10.1000/paper-b", "type": "journal-article", "title": []string{"Carbon budgets"}, "issued": map[string]any{"date-parts": [][]int{{2025}}}},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /works", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("mailto") != "repo@example.edu" {
+			t.Errorf("mailto = %q", r.URL.Query().Get("mailto"))
+		}
+		var items []map[string]any
+		if r.URL.Query().Get("filter") == "relation.object:10.5555/ds-1" {
+			items = []map[string]any{
+				{"DOI": "10.1000/paper-c", "type": "posted-content", "title": []string{"Preprint"}, "issued": map[string]any{"date-parts": [][]int{{2025, 1}}},
+					"relation": map[string]any{"is-supplemented-by": []map[string]string{{"id-type": "doi", "id": "10.5555/DS-1"}}}},
+				{"DOI": "10.1000/paper-d", "type": "journal-article",
+					"relation": map[string]any{"is-review-of": []map[string]string{{"id-type": "doi", "id": "10.5555/ds-1"}}}},
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"message": map[string]any{"next-cursor": "abc", "items": items}})
+	})
+	mux.HandleFunc("GET /works/{doi...}", func(w http.ResponseWriter, r *http.Request) {
+		work, ok := works[r.PathValue("doi")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"message": work})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	st := state.NewMemoryStore()
+	datasets := dataset.NewStore(st)
+	d := &dataset.Dataset{ID: "ds-1", DOI: "10.5555/ds-1", Title: "Soil cores", State: dataset.StatePublished,
+		Versions: []dataset.Version{{Number: 1, DOI: "10.5555/ds-1.v1"}}}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	crossref := NewCrossref(CrossrefOptions{BaseURL: fakeCrossref(t).URL, Mailto: "repo@example.edu"})
+	log := &audit.MemoryLog{}
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	tr := &Tracker{
+		Datasets: datasets,
+		State:    st,
+		Sources:  []Source{NewEventData(EventDataOptions{BaseURL: fakeEventData(t).URL}), crossref},
+		Works:    crossref,
+		Log:      log,
+		Now:      func() time.Time { return now },
+	}
+
+	rec, err := tr.Get(ctx, "10.5555/ds-1")
+	if err != nil || rec.Count != 0 || rec.Checked != nil {
+		t.Fatalf("Get() before Check = %+v, %v; want no citations", rec, err)
+	}
+
+	up, err := tr.Check(ctx, d)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if up.Count != 3 || len(up.New) != 3 {
+		t.Fatalf("Check() = %+v, want 3 new citations", up)
+	}
+	// Newest first; a work citing two versions is counted once.
+	want := []struct {
+		doi, title, relation, sources string
+		year                          int
+	}{
+		{"10.1000/paper-b", "Carbon budgets", "cites", "datacite", 2025},
+		{"10.1000/paper-c", "Preprint", "is-supplemented-by", "crossref", 2025},
+		{"10.1000/paper-a", "Soil respiration", "references", "datacite", 2024},
+	}
+	for i, w := range want {
+		c := up.New[i]
+		if c.DOI != w.doi || c.Title != w.title || c.Relation != w.relation || strings.Join(c.Sources, ",") != w.sources || c.Year != w.year || !c.Found.Equal(now) {
+			t.Errorf("citation %d = %+v, want %+v", i, c, w)
+		}
+	}
+	if entries, _ := log.Entries(ctx); len(entries) != 1 || entries[0].Action != "citation.found" {
+		t.Errorf("audit entries = %+v, want one citation.found", entries)
+	}
+
+	later := now.AddDate(0, 1, 0)
+	tr.Now = func() time.Time { return later }
+	up, err = tr.Check(ctx, d)
+	if err != nil {
+		t.Fatalf("second Check() error = %v", err)
+	}
+	if up.Count != 3 || len(up.New) != 0 {
+		t.Errorf("second Check() = %+v, want no new citations", up)
+	}
+	rec, err = tr.Get(ctx, "ds-1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if rec.Count != 3 || !rec.Checked.Equal(later) || !rec.Citations[0].Found.Equal(now) {
+		t.Errorf("Get() = %+v, want 3 citations first found %v", rec, now)
+	}
+
+	page, err := tr.Citations(ctx, d)
+	if err != nil {
+		t.Fatalf("Citations() error = %v", err)
+	}
+	if page.Count != 3 || page.Works[2].Title != "Soil respiration" || page.Works[2].Container != "Soil Biology" || page.Works[2].URL != "https://doi.org/10.1000/paper-a" {
+		t.Errorf("Citations() = %+v", page)
+	}
+}
+
+func TestCheckSourceFailure(t *testing.T) {
+	ctx := context.Background()
+	st := state.NewMemoryStore()
+	datasets := dataset.NewStore(st)
+	d := &dataset.Dataset{ID: "ds-1", DOI: "10.5555/ds-1", State: dataset.StatePublished}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	tr := &Tracker{
+		Datasets: datasets,
+		State:    st,
+		Sources:  []Source{NewEventData(EventDataOptions{BaseURL: fakeEventData(t).URL}), NewCrossref(CrossrefOptions{BaseURL: down.URL})},
+	}
+
+	updates, err := tr.Run(ctx)
+	if err == nil || len(updates) != 1 || !strings.Contains(updates[0].Error, "HTTP 503") {
+		t.Fatalf("Run() = %+v, %v; want ds-1 failed", updates, err)
+	}
+	if rec, _ := tr.Get(ctx, "ds-1"); rec.Checked != nil {
+		t.Errorf("failed check stored %+v", rec)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	st := state.NewMemoryStore()
+	datasets := dataset.NewStore(st)
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", DOI: "10.5555/ds-1", State: dataset.StatePublished},
+		{ID: "ds-2", State: dataset.StateDraft},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	rec := Record{DatasetID: "ds-1", DOI: "10.5555/ds-1", Count: 1, Checked: &found,
+		Citations: []Citation{{DOI: "10.1000/paper-a", Cited: "10.5555/ds-1", Relation: "references", Sources: []string{"datacite"}, Found: found}}}
+	if err := st.Put(ctx, citationsTable, "ds-1", rec); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(&Tracker{Datasets: datasets, State: st})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/citations/10.5555/ds-1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET by DOI = %d %s", w.Code, w.Body)
+	}
+	var got Record
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Count != 1 || got.Citations[0].DOI != "10.1000/paper-a" {
+		t.Errorf("GET by DOI = %+v", got)
+	}
+
+	for _, path := range []string{"/citations/ds-2", "/citations/ds-9"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, w.Code)
+		}
+	}
+}
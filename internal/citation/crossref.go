@@ -0,0 +1,225 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package citation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// DefaultCrossrefURL is the Crossref REST API.
+const DefaultCrossrefURL = "https://api.crossref.org"
+
+// crossrefRows is the page size of Crossref work queries.
+const crossrefRows = 500
+
+// CrossrefOptions configures a Crossref client.
+type CrossrefOptions struct {
+	// BaseURL is the Crossref API root
+	BaseURL string
+
+	// Mailto is a contact address, sent to be served from Crossref's
+	// polite pool; optional
+	Mailto string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Crossref finds works whose Crossref metadata declares a relation to
+// a dataset, and describes citing works registered with Crossref.
+type Crossref struct {
+	baseURL string
+	mailto  string
+	http    *http.Client
+}
+
+// NewCrossref returns a client with the given options.
+func NewCrossref(opts CrossrefOptions) *Crossref {
+	c := &Crossref{
+		baseURL: strings.TrimRight(opts.BaseURL, "/"),
+		mailto:  opts.Mailto,
+		http:    opts.HTTPClient,
+	}
+	if c.baseURL == "" {
+		c.baseURL = DefaultCrossrefURL
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// work is the subset of a Crossref work record read for citations.
+type work struct {
+	DOI       string   `json:"DOI"`
+	Type      string   `json:"type"`
+	Title     []string `json:"title"`
+	Container []string `json:"container-title"`
+	Issued    struct {
+		DateParts [][]int `json:"date-parts"`
+	} `json:"issued"`
+	Relation map[string][]struct {
+		IDType string `json:"id-type"`
+		ID     string `json:"id"`
+	} `json:"relation"`
+}
+
+// describe fills in c from w.
+func (w *work) describe(c *Citation) {
+	if len(w.Title) > 0 {
+		c.Title = w.Title[0]
+	}
+	if len(w.Container) > 0 {
+		c.Container = w.Container[0]
+	}
+	if len(w.Issued.DateParts) > 0 && len(w.Issued.DateParts[0]) > 0 {
+		c.Year = w.Issued.DateParts[0][0]
+	}
+	c.Type = w.Type
+}
+
+// APIError is returned for non-2xx responses.
+type APIError struct {
+	Service    string
+	StatusCode int
+	Body       string
+}
+
+// Error implements error.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: HTTP %d: %s", e.Service, e.StatusCode, e.Body)
+}
+
+// Name implements Source.
+func (c *Crossref) Name() string { return "crossref" }
+
+// Citing implements Source, returning the works whose relations cite
+// doi.
+func (c *Crossref) Citing(ctx context.Context, doi string) ([]Citation, error) {
+	doi = dataset.NormalizeDOI(doi)
+	q := url.Values{
+		"filter": {"relation.object:" + doi},
+		"rows":   {strconv.Itoa(crossrefRows)},
+		"cursor": {"*"},
+	}
+	var out []Citation
+	for {
+		var page struct {
+			Message struct {
+				NextCursor string `json:"next-cursor"`
+				Items      []work `json:"items"`
+			} `json:"message"`
+		}
+		if err := c.get(ctx, "/works", q, &page); err != nil {
+			return nil, err
+		}
+		for _, w := range page.Message.Items {
+			if rel := w.cites(doi); rel != "" {
+				cit := Citation{DOI: w.DOI, Cited: doi, Relation: rel}
+				w.describe(&cit)
+				out = append(out, cit)
+			}
+		}
+		// The cursor is returned even on the last page, which is
+		// short.
+		if len(page.Message.Items) < crossrefRows || page.Message.NextCursor == "" {
+			return out, nil
+		}
+		q.Set("cursor", page.Message.NextCursor)
+	}
+}
+
+// cites returns the citing relation w declares to doi, or "" if it
+// declares none.
+func (w *work) cites(doi string) string {
+	for rel, objects := range w.Relation {
+		if !Citing(rel) {
+			continue
+		}
+		for _, o := range objects {
+			if strings.EqualFold(o.IDType, "doi") && dataset.NormalizeDOI(o.ID) == doi {
+				return rel
+			}
+		}
+	}
+	return ""
+}
+
+// Describe implements WorkSource. A work not registered with Crossref
+// is left as it is.
+func (c *Crossref) Describe(ctx context.Context, cit *Citation) error {
+	var out struct {
+		Message work `json:"message"`
+	}
+	if err := c.get(ctx, "/works/"+url.PathEscape(cit.DOI), nil, &out); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+	out.Message.describe(cit)
+	return nil
+}
+
+// get fetches path and decodes the response into out.
+func (c *Crossref) get(ctx context.Context, path string, q url.Values, out any) error {
+	if c.mailto != "" {
+		if q == nil {
+			q = url.Values{}
+		}
+		q.Set("mailto", c.mailto)
+	}
+	u := c.baseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	return getJSON(ctx, c.http, "crossref", u, out)
+}
+
+// getJSON fetches u and decodes the response into out.
+func getJSON(ctx context.Context, client *http.Client, service, u string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", service, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{Service: service, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package citation
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// DefaultEventDataURL is the DataCite REST API, which serves Event
+// Data.
+const DefaultEventDataURL = "https://api.datacite.org"
+
+// eventPageSize is the page size of Event Data queries.
+const eventPageSize = 1000
+
+// EventDataOptions configures an Event Data client.
+type EventDataOptions struct {
+	// BaseURL is the DataCite REST API root
+	BaseURL string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// EventData finds citations in DataCite Event Data, which links DOIs
+// from DataCite and Crossref metadata and from sources such as
+// Wikipedia. Only links between DOIs are citations here.
+type EventData struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewEventData returns a client with the given options.
+func NewEventData(opts EventDataOptions) *EventData {
+	c := &EventData{
+		baseURL: strings.TrimRight(opts.BaseURL, "/"),
+		http:    opts.HTTPClient,
+	}
+	if c.baseURL == "" {
+		c.baseURL = DefaultEventDataURL
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// event is the subset of an Event Data event read for citations.
+type event struct {
+	Attributes struct {
+		SubjID   string `json:"subj-id"`
+		ObjID    string `json:"obj-id"`
+		Relation string `json:"relation-type-id"`
+		SourceID string `json:"source-id"`
+	} `json:"attributes"`
+}
+
+// Name implements Source.
+func (c *EventData) Name() string { return "datacite" }
+
+// Citing implements Source, returning the works linked to doi by a
+// citing relation in either direction: the work citing the dataset, or
+// the dataset cited by the work.
+func (c *EventData) Citing(ctx context.Context, doi string) ([]Citation, error) {
+	doi = dataset.NormalizeDOI(doi)
+	q := url.Values{
+		"doi":        {doi},
+		"page[size]": {strconv.Itoa(eventPageSize)},
+	}
+	next := c.baseURL + "/events?" + q.Encode()
+	var out []Citation
+	for next != "" {
+		var page struct {
+			Data  []event `json:"data"`
+			Links struct {
+				Next string `json:"next"`
+			} `json:"links"`
+		}
+		if err := getJSON(ctx, c.http, "datacite", next, &page); err != nil {
+			return nil, err
+		}
+		for _, e := range page.Data {
+			if cit, ok := e.citation(doi); ok {
+				out = append(out, cit)
+			}
+		}
+		next = page.Links.Next
+		if len(page.Data) < eventPageSize {
+			next = ""
+		}
+	}
+	return out, nil
+}
+
+// citation returns the citation of doi e records, if it records one.
+func (e *event) citation(doi string) (Citation, bool) {
+	a := e.Attributes
+	subj, obj := dataset.NormalizeDOI(a.SubjID), dataset.NormalizeDOI(a.ObjID)
+	switch {
+	case obj == doi && Citing(a.Relation):
+		return Citation{DOI: subj, Cited: doi, Relation: a.Relation}, isDOI(a.SubjID)
+	case subj == doi && citingRelations[a.Relation] != "":
+		return Citation{DOI: obj, Cited: doi, Relation: citingRelations[a.Relation]}, isDOI(a.ObjID)
+	}
+	return Citation{}, false
+}
+
+// isDOI reports whether an Event Data subject or object ID is a DOI
+// rather than another kind of URL.
+func isDOI(id string) bool {
+	return strings.HasPrefix(dataset.NormalizeDOI(id), "10.")
+}
@@ -0,0 +1,62 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package citation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// citationsMaxAge is how long clients and the CDN may cache citations,
+// which change only when the tracker runs.
+const citationsMaxAge = "public, max-age=3600"
+
+// Handler serves the citations of published datasets as JSON:
+//
+//	GET /citations/{ref}    the Record of a published dataset, by ID or DOI
+type Handler struct {
+	tracker *Tracker
+	mux     *http.ServeMux
+}
+
+// NewHandler returns a handler serving citations from t.
+func NewHandler(t *Tracker) *Handler {
+	h := &Handler{tracker: t, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /citations/{ref...}", h.get)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	rec, err := h.tracker.Get(r.Context(), r.PathValue("ref"))
+	if errors.Is(err, dataset.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", citationsMaxAge)
+	_ = json.NewEncoder(w).Encode(rec)
+}
@@ -0,0 +1,355 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudformation is a minimal AWS CloudFormation client for
+// deploying a stack through change sets and detecting its drift.
+package cloudformation
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+const apiVersion = "2010-05-15"
+
+// ErrNotFound is returned when a stack or change set does not exist.
+var ErrNotFound = errors.New("cloudformation: not found")
+
+// Options configures a Client.
+type Options struct {
+	// Region is the AWS region of the stacks
+	Region string
+
+	// Endpoint overrides the regional AWS endpoint
+	Endpoint string
+
+	// Credentials sign requests
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is a CloudFormation client.
+type Client struct {
+	endpoint string
+	signer   *aws.Signer
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://cloudformation." + opts.Region + ".amazonaws.com"
+	}
+	c := &Client{
+		endpoint: strings.TrimRight(endpoint, "/") + "/",
+		signer:   &aws.Signer{Credentials: opts.Credentials, Region: opts.Region, Service: "cloudformation"},
+		http:     opts.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// ChangeSetInput describes a change set to create.
+type ChangeSetInput struct {
+	StackName     string
+	ChangeSetName string
+
+	// Type is CREATE for a new stack or UPDATE for an existing one
+	Type string
+
+	TemplateBody string
+	Parameters   map[string]string
+
+	// Capabilities acknowledge what the template creates, e.g.
+	// CAPABILITY_NAMED_IAM
+	Capabilities []string
+
+	Tags map[string]string
+}
+
+// CreateChangeSet creates a change set and returns its ID. The change
+// set is created asynchronously; see DescribeChangeSet.
+func (c *Client) CreateChangeSet(ctx context.Context, in ChangeSetInput) (string, error) {
+	form := url.Values{
+		"StackName":     {in.StackName},
+		"ChangeSetName": {in.ChangeSetName},
+		"ChangeSetType": {in.Type},
+		"TemplateBody":  {in.TemplateBody},
+	}
+	for i, k := range slices.Sorted(maps.Keys(in.Parameters)) {
+		n := strconv.Itoa(i + 1)
+		form.Set("Parameters.member."+n+".ParameterKey", k)
+		form.Set("Parameters.member."+n+".ParameterValue", in.Parameters[k])
+	}
+	for i, capability := range in.Capabilities {
+		form.Set("Capabilities.member."+strconv.Itoa(i+1), capability)
+	}
+	for i, k := range slices.Sorted(maps.Keys(in.Tags)) {
+		n := strconv.Itoa(i + 1)
+		form.Set("Tags.member."+n+".Key", k)
+		form.Set("Tags.member."+n+".Value", in.Tags[k])
+	}
+	var out struct {
+		ID string `xml:"CreateChangeSetResult>Id"`
+	}
+	err := c.do(ctx, "CreateChangeSet", form, &out)
+	return out.ID, err
+}
+
+// Change is a change to one resource in a change set.
+type Change struct {
+	// Action is Add, Modify, Remove, Import, or Dynamic
+	Action            string `xml:"ResourceChange>Action"`
+	LogicalResourceID string `xml:"ResourceChange>LogicalResourceId"`
+	ResourceType      string `xml:"ResourceChange>ResourceType"`
+
+	// Replacement is True, False, or Conditional for modifications
+	Replacement string `xml:"ResourceChange>Replacement"`
+}
+
+// ChangeSet is the state of a change set.
+type ChangeSet struct {
+	// Status is CREATE_PENDING, CREATE_IN_PROGRESS, CREATE_COMPLETE,
+	// or FAILED
+	Status       string
+	StatusReason string
+	Changes      []Change
+}
+
+// DescribeChangeSet returns the change set name of stack and all its
+// changes.
+func (c *Client) DescribeChangeSet(ctx context.Context, stack, name string) (*ChangeSet, error) {
+	cs := &ChangeSet{}
+	next := ""
+	for {
+		form := url.Values{"StackName": {stack}, "ChangeSetName": {name}}
+		if next != "" {
+			form.Set("NextToken", next)
+		}
+		var out struct {
+			Status       string   `xml:"DescribeChangeSetResult>Status"`
+			StatusReason string   `xml:"DescribeChangeSetResult>StatusReason"`
+			Changes      []Change `xml:"DescribeChangeSetResult>Changes>member"`
+			NextToken    string   `xml:"DescribeChangeSetResult>NextToken"`
+		}
+		if err := c.do(ctx, "DescribeChangeSet", form, &out); err != nil {
+			return nil, err
+		}
+		cs.Status, cs.StatusReason = out.Status, out.StatusReason
+		cs.Changes = append(cs.Changes, out.Changes...)
+		if next = out.NextToken; next == "" {
+			return cs, nil
+		}
+	}
+}
+
+// ExecuteChangeSet starts updating stack with the change set name.
+func (c *Client) ExecuteChangeSet(ctx context.Context, stack, name string) error {
+	return c.do(ctx, "ExecuteChangeSet", url.Values{"StackName": {stack}, "ChangeSetName": {name}}, nil)
+}
+
+// DeleteChangeSet deletes the change set name of stack.
+func (c *Client) DeleteChangeSet(ctx context.Context, stack, name string) error {
+	return c.do(ctx, "DeleteChangeSet", url.Values{"StackName": {stack}, "ChangeSetName": {name}}, nil)
+}
+
+// Stack is the state of a stack.
+type Stack struct {
+	Name string
+
+	// Status is e.g. CREATE_IN_PROGRESS, UPDATE_COMPLETE, or
+	// UPDATE_ROLLBACK_COMPLETE
+	Status       string
+	StatusReason string
+
+	// Outputs maps the stack's output keys to their values
+	Outputs map[string]string
+}
+
+// DescribeStack returns the stack name, or an error wrapping
+// ErrNotFound if it does not exist.
+func (c *Client) DescribeStack(ctx context.Context, name string) (*Stack, error) {
+	var out struct {
+		Stacks []struct {
+			Name         string `xml:"StackName"`
+			Status       string `xml:"StackStatus"`
+			StatusReason string `xml:"StackStatusReason"`
+			Outputs      []struct {
+				Key   string `xml:"OutputKey"`
+				Value string `xml:"OutputValue"`
+			} `xml:"Outputs>member"`
+		} `xml:"DescribeStacksResult>Stacks>member"`
+	}
+	if err := c.do(ctx, "DescribeStacks", url.Values{"StackName": {name}}, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Stacks) == 0 {
+		return nil, fmt.Errorf("stack %s: %w", name, ErrNotFound)
+	}
+	s := out.Stacks[0]
+	stack := &Stack{Name: s.Name, Status: s.Status, StatusReason: s.StatusReason, Outputs: make(map[string]string)}
+	for _, o := range s.Outputs {
+		stack.Outputs[o.Key] = o.Value
+	}
+	return stack, nil
+}
+
+// DeleteStack starts deleting stack. Resources the template retains on
+// deletion are left in place.
+func (c *Client) DeleteStack(ctx context.Context, stack string) error {
+	return c.do(ctx, "DeleteStack", url.Values{"StackName": {stack}}, nil)
+}
+
+// DetectStackDrift starts detecting the drift of stack and returns the
+// detection's ID.
+func (c *Client) DetectStackDrift(ctx context.Context, stack string) (string, error) {
+	var out struct {
+		ID string `xml:"DetectStackDriftResult>StackDriftDetectionId"`
+	}
+	err := c.do(ctx, "DetectStackDrift", url.Values{"StackName": {stack}}, &out)
+	return out.ID, err
+}
+
+// DriftDetection is the state of a drift detection.
+type DriftDetection struct {
+	// Status is DETECTION_IN_PROGRESS, DETECTION_FAILED, or
+	// DETECTION_COMPLETE
+	Status       string `xml:"DescribeStackDriftDetectionStatusResult>DetectionStatus"`
+	StatusReason string `xml:"DescribeStackDriftDetectionStatusResult>DetectionStatusReason"`
+
+	// StackDriftStatus is DRIFTED, IN_SYNC, or UNKNOWN
+	StackDriftStatus string `xml:"DescribeStackDriftDetectionStatusResult>StackDriftStatus"`
+}
+
+// DescribeDriftDetection returns the state of the drift detection id.
+func (c *Client) DescribeDriftDetection(ctx context.Context, id string) (*DriftDetection, error) {
+	var out DriftDetection
+	if err := c.do(ctx, "DescribeStackDriftDetectionStatus", url.Values{"StackDriftDetectionId": {id}}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ResourceDrift is the drift of one resource of a stack.
+type ResourceDrift struct {
+	LogicalResourceID string `xml:"LogicalResourceId"`
+	ResourceType      string `xml:"ResourceType"`
+
+	// Status is MODIFIED, DELETED, IN_SYNC, or NOT_CHECKED
+	Status string `xml:"StackResourceDriftStatus"`
+
+	// Properties are the paths of the properties that differ, e.g.
+	// /Tags/0/Value
+	Properties []string `xml:"PropertyDifferences>member>PropertyPath"`
+}
+
+// DescribeResourceDrifts returns the drift of the resources of stack
+// found by the last drift detection, only those with one of statuses if
+// any are given.
+func (c *Client) DescribeResourceDrifts(ctx context.Context, stack string, statuses ...string) ([]ResourceDrift, error) {
+	var drifts []ResourceDrift
+	next := ""
+	for {
+		form := url.Values{"StackName": {stack}}
+		for i, s := range statuses {
+			form.Set("StackResourceDriftStatusFilters.member."+strconv.Itoa(i+1), s)
+		}
+		if next != "" {
+			form.Set("NextToken", next)
+		}
+		var out struct {
+			Drifts    []ResourceDrift `xml:"DescribeStackResourceDriftsResult>StackResourceDrifts>member"`
+			NextToken string          `xml:"DescribeStackResourceDriftsResult>NextToken"`
+		}
+		if err := c.do(ctx, "DescribeStackResourceDrifts", form, &out); err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, out.Drifts...)
+		if next = out.NextToken; next == "" {
+			return drifts, nil
+		}
+	}
+}
+
+// do calls action with form as its parameters and decodes the XML
+// response into out, unless out is nil.
+func (c *Client) do(ctx context.Context, action string, form url.Values, out any) error {
+	form.Set("Action", action)
+	form.Set("Version", apiVersion)
+	body := []byte(form.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", action, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	c.signer.Sign(req, aws.HashPayload(body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudformation %s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode, Action: action}
+		_ = xml.Unmarshal(data, e)
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	if err := xml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", action, err)
+	}
+	return nil
+}
+
+// Error is a CloudFormation error response.
+type Error struct {
+	StatusCode int
+	Action     string
+	Code       string `xml:"Error>Code"`
+	Message    string `xml:"Error>Message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("cloudformation %s: HTTP %d %s: %s", e.Action, e.StatusCode, e.Code, e.Message)
+}
+
+// Unwrap maps missing stacks and change sets to ErrNotFound.
+func (e *Error) Unwrap() error {
+	if e.Code == "ChangeSetNotFound" || e.Code == "ValidationError" && strings.Contains(e.Message, "does not exist") {
+		return ErrNotFound
+	}
+	return nil
+}
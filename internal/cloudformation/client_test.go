@@ -0,0 +1,155 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudformation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return NewClient(Options{
+		Region:      "us-east-1",
+		Endpoint:    srv.URL,
+		Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+}
+
+func TestCreateChangeSet(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "/cloudformation/aws4_request") {
+			t.Errorf("request not signed for cloudformation: %q", r.Header.Get("Authorization"))
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{
+			"Action":                             "CreateChangeSet",
+			"Version":                            apiVersion,
+			"StackName":                          "aperture-prod",
+			"ChangeSetType":                      "UPDATE",
+			"Parameters.member.1.ParameterKey":   "Environment",
+			"Parameters.member.1.ParameterValue": "prod",
+			"Parameters.member.2.ParameterKey":   "ProjectName",
+			"Capabilities.member.1":              "CAPABILITY_NAMED_IAM",
+			"Tags.member.1.Key":                  "Version",
+		}
+		for k, v := range want {
+			if got := r.PostForm.Get(k); got != v {
+				t.Errorf("%s = %q, want %q", k, got, v)
+			}
+		}
+		fmt.Fprint(w, `<CreateChangeSetResponse><CreateChangeSetResult>
+			<Id>arn:aws:cloudformation:us-east-1:1:changeSet/cs/1</Id></CreateChangeSetResult></CreateChangeSetResponse>`)
+	})
+	id, err := c.CreateChangeSet(context.Background(), ChangeSetInput{
+		StackName:     "aperture-prod",
+		ChangeSetName: "cs",
+		Type:          "UPDATE",
+		TemplateBody:  "Resources: {}",
+		Parameters:    map[string]string{"ProjectName": "aperture", "Environment": "prod"},
+		Capabilities:  []string{"CAPABILITY_NAMED_IAM"},
+		Tags:          map[string]string{"Version": "1.4.0"},
+	})
+	if err != nil || id != "arn:aws:cloudformation:us-east-1:1:changeSet/cs/1" {
+		t.Errorf("CreateChangeSet() = %q, %v", id, err)
+	}
+}
+
+func TestDescribeChangeSetPages(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("NextToken") == "" {
+			fmt.Fprint(w, `<DescribeChangeSetResponse><DescribeChangeSetResult>
+				<Status>CREATE_COMPLETE</Status><NextToken>t</NextToken>
+				<Changes><member><ResourceChange><Action>Add</Action><LogicalResourceId>Logs</LogicalResourceId>
+				<ResourceType>AWS::S3::Bucket</ResourceType></ResourceChange></member></Changes>
+				</DescribeChangeSetResult></DescribeChangeSetResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<DescribeChangeSetResponse><DescribeChangeSetResult>
+			<Status>CREATE_COMPLETE</Status>
+			<Changes><member><ResourceChange><Action>Modify</Action><LogicalResourceId>Users</LogicalResourceId>
+			<ResourceType>AWS::DynamoDB::Table</ResourceType><Replacement>False</Replacement></ResourceChange></member></Changes>
+			</DescribeChangeSetResult></DescribeChangeSetResponse>`)
+	})
+	cs, err := c.DescribeChangeSet(context.Background(), "aperture-prod", "cs")
+	if err != nil {
+		t.Fatalf("DescribeChangeSet() error = %v", err)
+	}
+	want := []Change{
+		{Action: "Add", LogicalResourceID: "Logs", ResourceType: "AWS::S3::Bucket"},
+		{Action: "Modify", LogicalResourceID: "Users", ResourceType: "AWS::DynamoDB::Table", Replacement: "False"},
+	}
+	if cs.Status != "CREATE_COMPLETE" || !reflect.DeepEqual(cs.Changes, want) {
+		t.Errorf("DescribeChangeSet() = %+v", cs)
+	}
+}
+
+func TestDescribeStack(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("StackName") == "none" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>ValidationError</Code>
+				<Message>Stack with id none does not exist</Message></Error></ErrorResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<DescribeStacksResponse><DescribeStacksResult><Stacks><member>
+			<StackName>aperture-prod</StackName><StackStatus>UPDATE_COMPLETE</StackStatus>
+			<Outputs><member><OutputKey>UsersTableName</OutputKey><OutputValue>aperture-users-prod</OutputValue></member></Outputs>
+			</member></Stacks></DescribeStacksResult></DescribeStacksResponse>`)
+	})
+	s, err := c.DescribeStack(context.Background(), "aperture-prod")
+	if err != nil || s.Status != "UPDATE_COMPLETE" || s.Outputs["UsersTableName"] != "aperture-users-prod" {
+		t.Errorf("DescribeStack() = %+v, %v", s, err)
+	}
+	if _, err := c.DescribeStack(context.Background(), "none"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DescribeStack() of a missing stack error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDescribeResourceDrifts(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if got := r.PostForm.Get("StackResourceDriftStatusFilters.member.2"); got != "DELETED" {
+			t.Errorf("second filter = %q", got)
+		}
+		fmt.Fprint(w, `<DescribeStackResourceDriftsResponse><DescribeStackResourceDriftsResult><StackResourceDrifts>
+			<member><LogicalResourceId>PublicMedia</LogicalResourceId><ResourceType>AWS::S3::Bucket</ResourceType>
+			<StackResourceDriftStatus>MODIFIED</StackResourceDriftStatus><PropertyDifferences>
+			<member><PropertyPath>/Tags/0/Value</PropertyPath><DifferenceType>NOT_EQUAL</DifferenceType></member>
+			<member><PropertyPath>/PublicAccessBlockConfiguration/BlockPublicAcls</PropertyPath></member>
+			</PropertyDifferences></member>
+			</StackResourceDrifts></DescribeStackResourceDriftsResult></DescribeStackResourceDriftsResponse>`)
+	})
+	drifts, err := c.DescribeResourceDrifts(context.Background(), "aperture-prod", "MODIFIED", "DELETED")
+	want := []ResourceDrift{{LogicalResourceID: "PublicMedia", ResourceType: "AWS::S3::Bucket", Status: "MODIFIED",
+		Properties: []string{"/Tags/0/Value", "/PublicAccessBlockConfiguration/BlockPublicAcls"}}}
+	if err != nil || !reflect.DeepEqual(drifts, want) {
+		t.Errorf("DescribeResourceDrifts() = %+v, %v", drifts, err)
+	}
+}
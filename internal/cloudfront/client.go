@@ -0,0 +1,186 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudfront is a minimal Amazon CloudFront API client.
+package cloudfront
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+const (
+	endpoint   = "https://cloudfront.amazonaws.com"
+	apiVersion = "2020-05-31"
+)
+
+// Client is a CloudFront client for one distribution.
+type Client struct {
+	// DistributionID is the distribution to operate on
+	DistributionID string
+
+	// Endpoint overrides the CloudFront API endpoint
+	Endpoint string
+
+	signer *aws.Signer
+	http   *http.Client
+}
+
+// NewClient returns a client for distributionID.
+func NewClient(distributionID string, creds aws.Credentials) *Client {
+	return &Client{
+		DistributionID: distributionID,
+		Endpoint:       endpoint,
+		// CloudFront is a global service signed in us-east-1.
+		signer: &aws.Signer{Credentials: creds, Region: "us-east-1", Service: "cloudfront"},
+		http:   http.DefaultClient,
+	}
+}
+
+type invalidationBatch struct {
+	XMLName         xml.Name `xml:"InvalidationBatch"`
+	Xmlns           string   `xml:"xmlns,attr"`
+	Paths           paths    `xml:"Paths"`
+	CallerReference string   `xml:"CallerReference"`
+}
+
+type paths struct {
+	Quantity int      `xml:"Quantity"`
+	Items    []string `xml:"Items>Path"`
+}
+
+// Invalidate creates an invalidation for paths and returns its ID.
+func (c *Client) Invalidate(ctx context.Context, p []string) (string, error) {
+	if len(p) == 0 {
+		return "", nil
+	}
+	body, err := xml.Marshal(invalidationBatch{
+		Xmlns:           "http://cloudfront.amazonaws.com/doc/" + apiVersion + "/",
+		Paths:           paths{Quantity: len(p), Items: p},
+		CallerReference: strconv.FormatInt(time.Now().UnixNano(), 10),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode invalidation: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/%s/distribution/%s/invalidation", c.Endpoint, apiVersion, c.DistributionID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create invalidation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	c.signer.Sign(req, aws.HashPayload(body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("invalidation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cloudfront: HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+
+	var out struct {
+		ID string `xml:"Id"`
+	}
+	if err := xml.Unmarshal(data, &out); err != nil {
+		return "", fmt.Errorf("failed to decode invalidation response: %w", err)
+	}
+	return out.ID, nil
+}
+
+// CountDistributions returns the number of distributions in the
+// account, whichever distribution c is for.
+func (c *Client) CountDistributions(ctx context.Context) (int, error) {
+	count, marker := 0, ""
+	for {
+		u := fmt.Sprintf("%s/%s/distribution?MaxItems=100", c.Endpoint, apiVersion)
+		if marker != "" {
+			u += "&Marker=" + url.QueryEscape(marker)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create list distributions request: %w", err)
+		}
+		c.signer.Sign(req, aws.HashPayload(nil))
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return 0, fmt.Errorf("list distributions request failed: %w", err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return 0, fmt.Errorf("cloudfront: HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+		}
+
+		var out struct {
+			Quantity    int    `xml:"Quantity"`
+			IsTruncated bool   `xml:"IsTruncated"`
+			NextMarker  string `xml:"NextMarker"`
+		}
+		if err := xml.Unmarshal(data, &out); err != nil {
+			return 0, fmt.Errorf("failed to decode list distributions response: %w", err)
+		}
+		count += out.Quantity
+		if !out.IsTruncated || out.NextMarker == "" {
+			return count, nil
+		}
+		marker = out.NextMarker
+	}
+}
+
+// ResponseHeadersPolicyConfig is a response headers policy that adds
+// custom headers, in the form accepted by
+// aws cloudfront create-response-headers-policy.
+type ResponseHeadersPolicyConfig struct {
+	Name                string              `json:"Name"`
+	Comment             string              `json:"Comment,omitempty"`
+	CustomHeadersConfig CustomHeadersConfig `json:"CustomHeadersConfig"`
+}
+
+// CustomHeadersConfig lists the headers a policy adds.
+type CustomHeadersConfig struct {
+	Quantity int            `json:"Quantity"`
+	Items    []CustomHeader `json:"Items"`
+}
+
+// CustomHeader is a header added to responses. Override replaces a
+// header of the same name sent by the origin.
+type CustomHeader struct {
+	Header   string `json:"Header"`
+	Value    string `json:"Value"`
+	Override bool   `json:"Override"`
+}
+
+// NewHeadersPolicy returns a policy named name that adds headers,
+// keeping any the origin sends.
+func NewHeadersPolicy(name, comment string, headers ...CustomHeader) ResponseHeadersPolicyConfig {
+	return ResponseHeadersPolicyConfig{
+		Name:                name,
+		Comment:             comment,
+		CustomHeadersConfig: CustomHeadersConfig{Quantity: len(headers), Items: headers},
+	}
+}
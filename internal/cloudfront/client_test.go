@@ -0,0 +1,45 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudfront
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+func TestCountDistributions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+apiVersion+"/distribution" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if r.URL.Query().Get("Marker") == "" {
+			fmt.Fprint(w, `<DistributionList><Quantity>100</Quantity><IsTruncated>true</IsTruncated><NextMarker>E2</NextMarker></DistributionList>`)
+			return
+		}
+		fmt.Fprint(w, `<DistributionList><Quantity>12</Quantity><IsTruncated>false</IsTruncated></DistributionList>`)
+	}))
+	defer srv.Close()
+	c := NewClient("", aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	c.Endpoint = srv.URL
+	n, err := c.CountDistributions(context.Background())
+	if err != nil || n != 112 {
+		t.Errorf("CountDistributions() = %d, %v, want 112", n, err)
+	}
+}
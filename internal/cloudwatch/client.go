@@ -0,0 +1,158 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudwatch is a minimal Amazon CloudWatch client for reading
+// the state of alarms.
+package cloudwatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+const apiVersion = "2010-08-01"
+
+// StateAlarm is the state of an alarm whose threshold is breached.
+const StateAlarm = "ALARM"
+
+// Options configures a Client.
+type Options struct {
+	// Region is the AWS region of the alarms
+	Region string
+
+	// Endpoint overrides the regional AWS endpoint
+	Endpoint string
+
+	// Credentials sign requests
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is a CloudWatch client.
+type Client struct {
+	endpoint string
+	signer   *aws.Signer
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://monitoring." + opts.Region + ".amazonaws.com"
+	}
+	c := &Client{
+		endpoint: strings.TrimRight(endpoint, "/") + "/",
+		signer:   &aws.Signer{Credentials: opts.Credentials, Region: opts.Region, Service: "monitoring"},
+		http:     opts.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Alarm is the state of a metric alarm.
+type Alarm struct {
+	Name string `xml:"AlarmName"`
+
+	// State is OK, ALARM, or INSUFFICIENT_DATA
+	State string `xml:"StateValue"`
+
+	// Reason explains the state
+	Reason string `xml:"StateReason"`
+}
+
+// DescribeAlarms returns the metric alarms named, in the order
+// CloudWatch lists them. Alarms that do not exist are left out.
+func (c *Client) DescribeAlarms(ctx context.Context, names ...string) ([]Alarm, error) {
+	var alarms []Alarm
+	token := ""
+	for {
+		form := url.Values{}
+		for i, name := range names {
+			form.Set("AlarmNames.member."+strconv.Itoa(i+1), name)
+		}
+		form.Set("AlarmTypes.member.1", "MetricAlarm")
+		if token != "" {
+			form.Set("NextToken", token)
+		}
+		var out struct {
+			Alarms    []Alarm `xml:"DescribeAlarmsResult>MetricAlarms>member"`
+			NextToken string  `xml:"DescribeAlarmsResult>NextToken"`
+		}
+		if err := c.do(ctx, "DescribeAlarms", form, &out); err != nil {
+			return nil, err
+		}
+		alarms = append(alarms, out.Alarms...)
+		if token = out.NextToken; token == "" {
+			return alarms, nil
+		}
+	}
+}
+
+// do calls action with form as the request and decodes the XML
+// response into out.
+func (c *Client) do(ctx context.Context, action string, form url.Values, out any) error {
+	form.Set("Action", action)
+	form.Set("Version", apiVersion)
+	body := []byte(form.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", action, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	c.signer.Sign(req, aws.HashPayload(body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudwatch %s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode, Action: action}
+		_ = xml.Unmarshal(data, e)
+		return e
+	}
+	if err := xml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", action, err)
+	}
+	return nil
+}
+
+// Error is a CloudWatch error response.
+type Error struct {
+	StatusCode int
+	Action     string
+	Code       string `xml:"Error>Code"`
+	Message    string `xml:"Error>Message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("cloudwatch %s: HTTP %d %s: %s", e.Action, e.StatusCode, e.Code, e.Message)
+}
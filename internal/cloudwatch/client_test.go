@@ -0,0 +1,83 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudwatch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return NewClient(Options{
+		Region:      "us-east-1",
+		Endpoint:    srv.URL,
+		Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+}
+
+func TestDescribeAlarms(t *testing.T) {
+	calls := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "/monitoring/aws4_request") {
+			t.Errorf("request not signed for monitoring: %q", r.Header.Get("Authorization"))
+		}
+		r.ParseForm()
+		if r.PostForm.Get("Action") != "DescribeAlarms" || r.PostForm.Get("AlarmNames.member.2") != "aperture-prod-auth-live-errors" {
+			t.Errorf("form = %v", r.PostForm)
+		}
+		calls++
+		if r.PostForm.Get("NextToken") == "" {
+			fmt.Fprint(w, `<DescribeAlarmsResponse><DescribeAlarmsResult><MetricAlarms>
+				<member><AlarmName>aperture-prod-presigned-urls-live-errors</AlarmName><StateValue>OK</StateValue><StateReason>fine</StateReason></member>
+				</MetricAlarms><NextToken>t1</NextToken></DescribeAlarmsResult></DescribeAlarmsResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<DescribeAlarmsResponse><DescribeAlarmsResult><MetricAlarms>
+			<member><AlarmName>aperture-prod-auth-live-errors</AlarmName><StateValue>ALARM</StateValue><StateReason>Threshold Crossed</StateReason></member>
+			</MetricAlarms></DescribeAlarmsResult></DescribeAlarmsResponse>`)
+	})
+	alarms, err := c.DescribeAlarms(context.Background(), "aperture-prod-presigned-urls-live-errors", "aperture-prod-auth-live-errors")
+	if err != nil {
+		t.Fatalf("DescribeAlarms() error = %v", err)
+	}
+	want := []Alarm{
+		{Name: "aperture-prod-presigned-urls-live-errors", State: "OK", Reason: "fine"},
+		{Name: "aperture-prod-auth-live-errors", State: StateAlarm, Reason: "Threshold Crossed"},
+	}
+	if calls != 2 || !reflect.DeepEqual(alarms, want) {
+		t.Errorf("DescribeAlarms() = %+v after %d calls, want %+v", alarms, calls, want)
+	}
+}
+
+func TestError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<ErrorResponse><Error><Code>AccessDenied</Code><Message>not authorized</Message></Error></ErrorResponse>`)
+	})
+	_, err := c.DescribeAlarms(context.Background(), "a")
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied: not authorized") {
+		t.Errorf("DescribeAlarms() error = %v", err)
+	}
+}
@@ -0,0 +1,433 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cognito is a minimal Amazon Cognito user pool administration
+// client. It also signs users in on behalf of an app client, for the
+// API's authentication endpoints.
+package cognito
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+// targetPrefix prefixes the X-Amz-Target of every operation.
+const targetPrefix = "AWSCognitoIdentityProviderService."
+
+var (
+	// ErrNotFound is returned when a user or group does not exist.
+	ErrNotFound = errors.New("cognito: not found")
+
+	// ErrExists is returned when creating a user that already exists.
+	ErrExists = errors.New("cognito: already exists")
+
+	// ErrNotAuthorized is returned when a password or refresh token is
+	// wrong, expired, or revoked.
+	ErrNotAuthorized = errors.New("cognito: not authorized")
+
+	// ErrChallenge is returned when signing in needs a further step,
+	// such as setting a new password, that must be completed through
+	// the hosted UI.
+	ErrChallenge = errors.New("cognito: sign-in challenge")
+)
+
+// Options configures a Client.
+type Options struct {
+	// Region is the AWS region of the user pool
+	Region string
+
+	// UserPoolID identifies the user pool
+	UserPoolID string
+
+	// Endpoint overrides the regional AWS endpoint
+	Endpoint string
+
+	// Credentials sign requests
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client administers one user pool.
+type Client struct {
+	poolID   string
+	endpoint string
+	signer   *aws.Signer
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) (*Client, error) {
+	if opts.UserPoolID == "" {
+		return nil, fmt.Errorf("no Cognito user pool configured")
+	}
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://cognito-idp." + opts.Region + ".amazonaws.com"
+	}
+	c := &Client{
+		poolID:   opts.UserPoolID,
+		endpoint: strings.TrimRight(endpoint, "/") + "/",
+		signer:   &aws.Signer{Credentials: opts.Credentials, Region: opts.Region, Service: "cognito-idp"},
+		http:     opts.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c, nil
+}
+
+// User is a user pool account.
+type User struct {
+	// Username is the pool's identifier for the user
+	Username string `json:"username"`
+
+	// Email is the user's email address
+	Email string `json:"email,omitempty"`
+
+	// Name is the user's display name
+	Name string `json:"name,omitempty"`
+
+	// Status is the account status, e.g. CONFIRMED or
+	// FORCE_CHANGE_PASSWORD
+	Status string `json:"status"`
+
+	// Enabled reports whether the user may sign in
+	Enabled bool `json:"enabled"`
+
+	// Created is when the account was created
+	Created time.Time `json:"created"`
+}
+
+// attribute is a user attribute in requests and responses.
+type attribute struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+// user is a user in API responses.
+type user struct {
+	Username             string      `json:"Username"`
+	Attributes           []attribute `json:"Attributes"`
+	UserStatus           string      `json:"UserStatus"`
+	Enabled              bool        `json:"Enabled"`
+	UserCreateDate       float64     `json:"UserCreateDate"`
+	UserLastModifiedDate float64     `json:"UserLastModifiedDate"`
+}
+
+func (u *user) toUser() User {
+	out := User{
+		Username: u.Username,
+		Status:   u.UserStatus,
+		Enabled:  u.Enabled,
+		Created:  epoch(u.UserCreateDate),
+	}
+	for _, a := range u.Attributes {
+		switch a.Name {
+		case "email":
+			out.Email = a.Value
+		case "name":
+			out.Name = a.Value
+		}
+	}
+	return out
+}
+
+// CreateUser creates an account for email, which becomes the username,
+// and emails the user a temporary password. The address is marked
+// verified because an administrator vouches for it.
+func (c *Client) CreateUser(ctx context.Context, email, name string) (*User, error) {
+	attrs := []attribute{
+		{Name: "email", Value: email},
+		{Name: "email_verified", Value: "true"},
+	}
+	if name != "" {
+		attrs = append(attrs, attribute{Name: "name", Value: name})
+	}
+	in := map[string]any{
+		"UserPoolId":             c.poolID,
+		"Username":               email,
+		"UserAttributes":         attrs,
+		"DesiredDeliveryMediums": []string{"EMAIL"},
+	}
+	var out struct {
+		User user `json:"User"`
+	}
+	if err := c.do(ctx, "AdminCreateUser", in, &out); err != nil {
+		return nil, err
+	}
+	u := out.User.toUser()
+	return &u, nil
+}
+
+// ListUsers returns every user in the pool.
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	var users []User
+	token := ""
+	for {
+		in := map[string]any{"UserPoolId": c.poolID}
+		if token != "" {
+			in["PaginationToken"] = token
+		}
+		var out struct {
+			Users           []user `json:"Users"`
+			PaginationToken string `json:"PaginationToken"`
+		}
+		if err := c.do(ctx, "ListUsers", in, &out); err != nil {
+			return nil, err
+		}
+		for i := range out.Users {
+			users = append(users, out.Users[i].toUser())
+		}
+		if out.PaginationToken == "" {
+			return users, nil
+		}
+		token = out.PaginationToken
+	}
+}
+
+// ListUsersInGroup returns the members of group.
+func (c *Client) ListUsersInGroup(ctx context.Context, group string) ([]User, error) {
+	var users []User
+	token := ""
+	for {
+		in := map[string]any{"UserPoolId": c.poolID, "GroupName": group}
+		if token != "" {
+			in["NextToken"] = token
+		}
+		var out struct {
+			Users     []user `json:"Users"`
+			NextToken string `json:"NextToken"`
+		}
+		if err := c.do(ctx, "ListUsersInGroup", in, &out); err != nil {
+			return nil, err
+		}
+		for i := range out.Users {
+			users = append(users, out.Users[i].toUser())
+		}
+		if out.NextToken == "" {
+			return users, nil
+		}
+		token = out.NextToken
+	}
+}
+
+// GroupsForUser returns the groups username belongs to.
+func (c *Client) GroupsForUser(ctx context.Context, username string) ([]string, error) {
+	var groups []string
+	token := ""
+	for {
+		in := map[string]any{"UserPoolId": c.poolID, "Username": username}
+		if token != "" {
+			in["NextToken"] = token
+		}
+		var out struct {
+			Groups []struct {
+				GroupName string `json:"GroupName"`
+			} `json:"Groups"`
+			NextToken string `json:"NextToken"`
+		}
+		if err := c.do(ctx, "AdminListGroupsForUser", in, &out); err != nil {
+			return nil, err
+		}
+		for _, g := range out.Groups {
+			groups = append(groups, g.GroupName)
+		}
+		if out.NextToken == "" {
+			return groups, nil
+		}
+		token = out.NextToken
+	}
+}
+
+// DisableUser prevents username from signing in. Existing sessions
+// are signed out.
+func (c *Client) DisableUser(ctx context.Context, username string) error {
+	in := map[string]any{"UserPoolId": c.poolID, "Username": username}
+	if err := c.do(ctx, "AdminDisableUser", in, nil); err != nil {
+		return err
+	}
+	return c.SignOut(ctx, username)
+}
+
+// SignOut revokes the refresh tokens issued to username, ending all of
+// the user's sessions once their access tokens expire.
+func (c *Client) SignOut(ctx context.Context, username string) error {
+	return c.do(ctx, "AdminUserGlobalSignOut", map[string]any{"UserPoolId": c.poolID, "Username": username}, nil)
+}
+
+// Tokens are the tokens issued when a user signs in.
+type Tokens struct {
+	// IDToken identifies the user to the API
+	IDToken string `json:"idToken"`
+
+	AccessToken string `json:"accessToken"`
+
+	// RefreshToken obtains new tokens; it is not reissued on refresh
+	RefreshToken string `json:"refreshToken,omitempty"`
+
+	// ExpiresIn is the lifetime of the ID and access tokens in seconds
+	ExpiresIn int `json:"expiresIn"`
+}
+
+// SignIn signs username in with password through app client clientID,
+// which must allow the ADMIN_USER_PASSWORD_AUTH flow.
+func (c *Client) SignIn(ctx context.Context, clientID, username, password string) (*Tokens, error) {
+	return c.initiateAuth(ctx, clientID, "ADMIN_USER_PASSWORD_AUTH", map[string]string{
+		"USERNAME": username,
+		"PASSWORD": password,
+	})
+}
+
+// Refresh issues new ID and access tokens for refreshToken.
+func (c *Client) Refresh(ctx context.Context, clientID, refreshToken string) (*Tokens, error) {
+	return c.initiateAuth(ctx, clientID, "REFRESH_TOKEN_AUTH", map[string]string{"REFRESH_TOKEN": refreshToken})
+}
+
+// initiateAuth starts an admin authentication flow, returning an error
+// wrapping ErrChallenge if the pool asks for more than params.
+func (c *Client) initiateAuth(ctx context.Context, clientID, flow string, params map[string]string) (*Tokens, error) {
+	in := map[string]any{
+		"UserPoolId":     c.poolID,
+		"ClientId":       clientID,
+		"AuthFlow":       flow,
+		"AuthParameters": params,
+	}
+	var out struct {
+		ChallengeName        string
+		AuthenticationResult struct {
+			IdToken      string
+			AccessToken  string
+			RefreshToken string
+			ExpiresIn    int
+		}
+	}
+	if err := c.do(ctx, "AdminInitiateAuth", in, &out); err != nil {
+		return nil, err
+	}
+	if out.ChallengeName != "" {
+		return nil, fmt.Errorf("%w: %s", ErrChallenge, out.ChallengeName)
+	}
+	r := out.AuthenticationResult
+	return &Tokens{IDToken: r.IdToken, AccessToken: r.AccessToken, RefreshToken: r.RefreshToken, ExpiresIn: r.ExpiresIn}, nil
+}
+
+// EnableUser allows a disabled user to sign in again.
+func (c *Client) EnableUser(ctx context.Context, username string) error {
+	return c.do(ctx, "AdminEnableUser", map[string]any{"UserPoolId": c.poolID, "Username": username}, nil)
+}
+
+// AddUserToGroup adds username to group.
+func (c *Client) AddUserToGroup(ctx context.Context, username, group string) error {
+	return c.do(ctx, "AdminAddUserToGroup", map[string]any{
+		"UserPoolId": c.poolID,
+		"Username":   username,
+		"GroupName":  group,
+	}, nil)
+}
+
+// RemoveUserFromGroup removes username from group.
+func (c *Client) RemoveUserFromGroup(ctx context.Context, username, group string) error {
+	return c.do(ctx, "AdminRemoveUserFromGroup", map[string]any{
+		"UserPoolId": c.poolID,
+		"Username":   username,
+		"GroupName":  group,
+	}, nil)
+}
+
+// do calls operation with in as the JSON request and decodes the
+// response into out, which may be nil.
+func (c *Client) do(ctx context.Context, operation string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", operation, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", operation, err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", targetPrefix+operation)
+	c.signer.Sign(req, aws.HashPayload(body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("cognito %s failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode, Operation: operation}
+		_ = json.Unmarshal(data, e)
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", operation, err)
+	}
+	return nil
+}
+
+// Error is a Cognito error response.
+type Error struct {
+	StatusCode int
+	Operation  string
+	Type       string `json:"__type"`
+	Message    string `json:"message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("cognito %s: HTTP %d %s: %s", e.Operation, e.StatusCode, e.Type, e.Message)
+}
+
+// Unwrap maps missing users and groups to ErrNotFound, duplicate
+// usernames to ErrExists, and rejected credentials to ErrNotAuthorized.
+func (e *Error) Unwrap() error {
+	switch e.Type {
+	case "UserNotFoundException", "ResourceNotFoundException":
+		return ErrNotFound
+	case "UsernameExistsException":
+		return ErrExists
+	case "NotAuthorizedException":
+		return ErrNotAuthorized
+	}
+	return nil
+}
+
+// epoch converts fractional Unix seconds to a time.
+func epoch(sec float64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC()
+}
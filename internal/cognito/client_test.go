@@ -0,0 +1,165 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cognito
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c, err := NewClient(Options{
+		Region:      "us-east-1",
+		UserPoolID:  "us-east-1_pool",
+		Endpoint:    srv.URL,
+		Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return c
+}
+
+func TestCreateUser(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Amz-Target"); got != "AWSCognitoIdentityProviderService.AdminCreateUser" {
+			t.Errorf("X-Amz-Target = %q", got)
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "/cognito-idp/aws4_request") {
+			t.Errorf("request not signed for cognito-idp: %q", r.Header.Get("Authorization"))
+		}
+		var in struct {
+			UserPoolId     string
+			Username       string
+			UserAttributes []attribute
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			t.Fatal(err)
+		}
+		if in.UserPoolId != "us-east-1_pool" || in.Username != "jane@uni.edu" || len(in.UserAttributes) != 3 {
+			t.Errorf("request = %+v", in)
+		}
+		w.Write([]byte(`{"User":{"Username":"7f1c","Attributes":[{"Name":"email","Value":"jane@uni.edu"},{"Name":"name","Value":"Jane"}],
+			"UserStatus":"FORCE_CHANGE_PASSWORD","Enabled":true,"UserCreateDate":1.7171712E9}}`))
+	})
+
+	u, err := c.CreateUser(context.Background(), "jane@uni.edu", "Jane")
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if u.Username != "7f1c" || u.Email != "jane@uni.edu" || u.Name != "Jane" || !u.Enabled || u.Created.Year() != 2024 {
+		t.Errorf("CreateUser() = %+v", u)
+	}
+}
+
+func TestListUsersPaginates(t *testing.T) {
+	calls := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		if in["PaginationToken"] == "" {
+			w.Write([]byte(`{"Users":[{"Username":"a","UserStatus":"CONFIRMED","Enabled":true}],"PaginationToken":"next"}`))
+			return
+		}
+		w.Write([]byte(`{"Users":[{"Username":"b","UserStatus":"CONFIRMED","Enabled":false}]}`))
+	})
+
+	users, err := c.ListUsers(context.Background())
+	if err != nil {
+		t.Fatalf("ListUsers() error = %v", err)
+	}
+	if calls != 2 || len(users) != 2 || users[1].Username != "b" || users[1].Enabled {
+		t.Errorf("ListUsers() = %+v after %d calls", users, calls)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		body string
+		want error
+	}{
+		{`{"__type":"UserNotFoundException","message":"User does not exist."}`, ErrNotFound},
+		{`{"__type":"com.amazonaws#UsernameExistsException","message":"exists"}`, ErrExists},
+		{`{"__type":"NotAuthorizedException","message":"Incorrect username or password."}`, ErrNotAuthorized},
+	}
+	for _, tt := range tests {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(tt.body))
+		})
+		err := c.AddUserToGroup(context.Background(), "jane@uni.edu", "curators")
+		if !errors.Is(err, tt.want) {
+			t.Errorf("AddUserToGroup() error = %v, want %v", err, tt.want)
+		}
+	}
+}
+
+func TestSignIn(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Amz-Target"); got != "AWSCognitoIdentityProviderService.AdminInitiateAuth" {
+			t.Errorf("X-Amz-Target = %q", got)
+		}
+		var in struct {
+			UserPoolId     string
+			ClientId       string
+			AuthFlow       string
+			AuthParameters map[string]string
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		switch in.AuthFlow {
+		case "ADMIN_USER_PASSWORD_AUTH":
+			if in.UserPoolId != "us-east-1_pool" || in.ClientId != "web" || in.AuthParameters["USERNAME"] != "jane@uni.edu" {
+				t.Errorf("request = %+v", in)
+			}
+			if in.AuthParameters["PASSWORD"] == "new" {
+				w.Write([]byte(`{"ChallengeName":"NEW_PASSWORD_REQUIRED","Session":"s"}`))
+				return
+			}
+			w.Write([]byte(`{"AuthenticationResult":{"IdToken":"id","AccessToken":"access","RefreshToken":"refresh","ExpiresIn":3600}}`))
+		case "REFRESH_TOKEN_AUTH":
+			if in.AuthParameters["REFRESH_TOKEN"] != "refresh" {
+				t.Errorf("request = %+v", in)
+			}
+			w.Write([]byte(`{"AuthenticationResult":{"IdToken":"id2","AccessToken":"access2","ExpiresIn":3600}}`))
+		}
+	})
+
+	ctx := context.Background()
+	tok, err := c.SignIn(ctx, "web", "jane@uni.edu", "secret")
+	if err != nil {
+		t.Fatalf("SignIn() error = %v", err)
+	}
+	if tok.IDToken != "id" || tok.RefreshToken != "refresh" || tok.ExpiresIn != 3600 {
+		t.Errorf("SignIn() = %+v", tok)
+	}
+	if _, err := c.SignIn(ctx, "web", "jane@uni.edu", "new"); !errors.Is(err, ErrChallenge) {
+		t.Errorf("SignIn() with a challenge error = %v, want ErrChallenge", err)
+	}
+	tok, err = c.Refresh(ctx, "web", "refresh")
+	if err != nil || tok.IDToken != "id2" || tok.RefreshToken != "" {
+		t.Errorf("Refresh() = %+v, %v", tok, err)
+	}
+}
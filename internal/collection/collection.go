@@ -0,0 +1,671 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package collection organizes datasets into communities and
+// collections.
+//
+// A community, such as a department, groups collections and other
+// communities; a collection groups datasets, which name it in their
+// Collection field. Each carries its own description and branding, and
+// names stewards: access control list entries (see package authz) for
+// the principals who curate it. Stewards of a community also steward
+// everything beneath it. Administrators steward every collection, and
+// only they may create top-level ones.
+package collection
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// collectionsTable holds communities and collections keyed by ID.
+const collectionsTable = "collections"
+
+var (
+	// ErrNotFound is returned for an unknown community or collection.
+	ErrNotFound = errors.New("collection not found")
+
+	// ErrExists is returned when creating a community or collection
+	// whose ID is taken.
+	ErrExists = errors.New("collection already exists")
+
+	// ErrNotEmpty is returned when deleting a community or collection
+	// that still holds collections or datasets.
+	ErrNotEmpty = errors.New("collection is not empty")
+)
+
+// Kind distinguishes communities from collections.
+type Kind string
+
+// Kinds.
+const (
+	// KindCommunity groups collections and other communities
+	KindCommunity Kind = "community"
+
+	// KindCollection groups datasets
+	KindCollection Kind = "collection"
+)
+
+// Where the concept DOIs of a collection's datasets resolve. A concept
+// DOI cites every version of a dataset; its version DOIs cite one each.
+const (
+	// ConceptLatest resolves them to the landing page of the latest
+	// version
+	ConceptLatest = "latest"
+
+	// ConceptVersions resolves them to a listing of every version
+	ConceptVersions = "versions"
+)
+
+// validID matches IDs: lowercase letters, digits, and inner hyphens,
+// so that they can name buckets and OAI-PMH sets unchanged.
+var validID = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,62}[a-z0-9])?$`)
+
+// validColor matches a CSS hex color.
+var validColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// validRegion matches an AWS region name, such as eu-west-1.
+var validRegion = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]$`)
+
+// Branding is how a community or collection presents itself.
+type Branding struct {
+	// LogoURL is the URL of its logo
+	LogoURL string `json:"logoUrl,omitempty"`
+
+	// BannerURL is the URL of its banner image
+	BannerURL string `json:"bannerUrl,omitempty"`
+
+	// Color is its accent color, as a CSS hex color
+	Color string `json:"color,omitempty"`
+}
+
+// VersionPolicy limits how many old versions of a collection's
+// datasets are kept online, for datasets republished so often, such as
+// weekly, that their old versions would otherwise dominate storage.
+// Versions are counted by age among each dataset's published versions,
+// the current version first.
+type VersionPolicy struct {
+	// Online is how many of the latest published versions stay in
+	// online storage; at least 1, the current version
+	Online int `json:"online"`
+
+	// Archived is how many versions older than those are kept in
+	// archival storage before deletion; with Delete unset, every older
+	// version is archived
+	Archived int `json:"archived,omitempty"`
+
+	// Delete deletes the files of versions older than the archived
+	// ones, leaving their manifests and tombstoned version DOIs
+	Delete bool `json:"delete,omitempty"`
+}
+
+// Validate reports whether p is well formed.
+func (p *VersionPolicy) Validate() error {
+	switch {
+	case p.Online < 1:
+		return fmt.Errorf("a version policy must keep at least the current version online")
+	case p.Archived < 0:
+		return fmt.Errorf("a version policy cannot keep %d archived versions", p.Archived)
+	case p.Archived > 0 && !p.Delete:
+		return fmt.Errorf("a version policy limits archived versions only when it deletes older ones")
+	}
+	return nil
+}
+
+// Action returns what p does with the files of the version age places
+// from the current one, which is 0: keep them online, when it returns
+// "", or dataset.PruneArchive or dataset.PruneDelete.
+func (p *VersionPolicy) Action(age int) string {
+	switch {
+	case age < p.Online:
+		return ""
+	case !p.Delete || age < p.Online+p.Archived:
+		return dataset.PruneArchive
+	}
+	return dataset.PruneDelete
+}
+
+// String describes p, e.g. "4 online, 8 archived, older deleted".
+func (p *VersionPolicy) String() string {
+	switch {
+	case !p.Delete:
+		return fmt.Sprintf("%d online, older archived", p.Online)
+	case p.Archived == 0:
+		return fmt.Sprintf("%d online, older deleted", p.Online)
+	}
+	return fmt.Sprintf("%d online, %d archived, older deleted", p.Online, p.Archived)
+}
+
+// Collection is a community or a collection.
+type Collection struct {
+	// ID identifies the collection; datasets name it in their
+	// Collection field
+	ID string `json:"id"`
+
+	// Kind is community or collection
+	Kind Kind `json:"kind"`
+
+	// Name is the display name
+	Name string `json:"name"`
+
+	// Description describes what the collection holds
+	Description string `json:"description,omitempty"`
+
+	// Homepage is the URL of the department or project it belongs to
+	Homepage string `json:"homepage,omitempty"`
+
+	// Parent is the ID of the community it belongs to; top-level if
+	// empty
+	Parent string `json:"parent,omitempty"`
+
+	// Branding is its logo, banner, and color
+	Branding Branding `json:"branding"`
+
+	// Stewards are the access control list entries of the principals
+	// who curate it
+	Stewards []string `json:"stewards,omitempty"`
+
+	// Versions limits the old versions of a collection's datasets kept
+	// online; nil keeps every version online
+	Versions *VersionPolicy `json:"versions,omitempty"`
+
+	// Regions are the AWS regions the files of a collection's datasets
+	// are replicated to, so that downloads are served from the copy
+	// nearest the requester; empty serves them from the primary region
+	Regions []string `json:"regions,omitempty"`
+
+	// ConceptDOI is where the concept DOIs of its datasets resolve:
+	// ConceptVersions, or ConceptLatest if empty
+	ConceptDOI string `json:"conceptDoi,omitempty"`
+
+	// CreatedBy is the principal who created it
+	CreatedBy string `json:"createdBy,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Registry records communities and collections.
+type Registry struct {
+	// State holds the records
+	State state.Store
+
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Log records changes; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Get returns the community or collection with the given ID.
+func (r *Registry) Get(ctx context.Context, id string) (*Collection, error) {
+	var c Collection
+	if err := r.State.Get(ctx, collectionsTable, id, &c); err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ConceptTarget returns where the concept DOIs of datasets in
+// collection id resolve: ConceptVersions if its stewards chose a
+// listing of every version, and otherwise ConceptLatest, as for
+// datasets in no collection or one that no longer exists.
+func (r *Registry) ConceptTarget(ctx context.Context, id string) (string, error) {
+	if id == "" {
+		return ConceptLatest, nil
+	}
+	c, err := r.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return ConceptLatest, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return cmp.Or(c.ConceptDOI, ConceptLatest), nil
+}
+
+// List returns every community and collection ordered by ID.
+func (r *Registry) List(ctx context.Context) ([]Collection, error) {
+	all, err := state.List[Collection](ctx, r.State, collectionsTable)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all, nil
+}
+
+// Children returns the communities and collections directly beneath
+// id, ordered by ID.
+func (r *Registry) Children(ctx context.Context, id string) ([]Collection, error) {
+	all, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []Collection
+	for _, c := range all {
+		if c.Parent == id {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// Ancestors returns the communities above id, top-level first.
+func (r *Registry) Ancestors(ctx context.Context, id string) ([]Collection, error) {
+	c, err := r.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var out []Collection
+	for parent := c.Parent; parent != ""; {
+		p, err := r.Get(ctx, parent)
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(out, func(a Collection) bool { return a.ID == p.ID }) {
+			return nil, fmt.Errorf("collection %s is in a cycle", p.ID)
+		}
+		out = append(out, *p)
+		parent = p.Parent
+	}
+	slices.Reverse(out)
+	return out, nil
+}
+
+// Stewards returns the entries of the principals who steward id: its
+// own stewards and those of the communities above it.
+func (r *Registry) Stewards(ctx context.Context, id string) ([]string, error) {
+	c, err := r.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ancestors, err := r.Ancestors(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	entries := slices.Clone(c.Stewards)
+	for _, a := range ancestors {
+		for _, e := range a.Stewards {
+			if !slices.Contains(entries, e) {
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// IsSteward reports whether p stewards id. Administrators steward
+// every collection.
+func (r *Registry) IsSteward(ctx context.Context, p identity.Principal, id string) (bool, error) {
+	d, err := r.decide(ctx, p, id)
+	if err != nil {
+		return false, err
+	}
+	return d.Allowed, nil
+}
+
+func (r *Registry) decide(ctx context.Context, p identity.Principal, id string) (authz.Decision, error) {
+	entries, err := r.Stewards(ctx, id)
+	if err != nil {
+		return authz.Decision{}, err
+	}
+	return authz.Decide(p, authz.Resource{ID: id, ACL: &authz.ACL{Manage: entries}}, authz.ActionManage), nil
+}
+
+// requireSteward returns an error wrapping authz.ErrForbidden unless
+// the acting principal stewards id, or, if id is empty, may create
+// top-level collections.
+func (r *Registry) requireSteward(ctx context.Context, id string) error {
+	p := identity.FromContext(ctx)
+	if id == "" {
+		if err := authz.RequirePermission(p, authz.PermMaintain); err != nil {
+			return fmt.Errorf("top-level communities and collections are created by administrators: %w", err)
+		}
+		return nil
+	}
+	d, err := r.decide(ctx, p, id)
+	if err != nil {
+		return err
+	}
+	if !d.Allowed {
+		return fmt.Errorf("%w: %s does not steward %s", authz.ErrForbidden, p, id)
+	}
+	return nil
+}
+
+// Create validates and records c beneath its parent, which the acting
+// principal must steward. Its timestamps and creator are assigned.
+func (r *Registry) Create(ctx context.Context, c Collection) (*Collection, error) {
+	if !validID.MatchString(c.ID) {
+		return nil, fmt.Errorf("invalid collection ID %q: use lowercase letters, digits, and hyphens", c.ID)
+	}
+	if _, err := r.Get(ctx, c.ID); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrExists, c.ID)
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if err := r.validate(ctx, &c); err != nil {
+		return nil, err
+	}
+	if err := r.requireSteward(ctx, c.Parent); err != nil {
+		return nil, err
+	}
+	now := r.now().UTC()
+	c.CreatedBy = identity.FromContext(ctx).String()
+	c.CreatedAt, c.UpdatedAt = now, now
+	if err := r.State.Put(ctx, collectionsTable, c.ID, &c); err != nil {
+		return nil, err
+	}
+	return &c, r.record(ctx, "collection.create", c.ID, map[string]string{"kind": string(c.Kind), "parent": c.Parent})
+}
+
+// Update replaces the metadata, branding, and stewards of c.ID with
+// those of c, which the acting principal must steward. Moving it to
+// another community also requires stewarding that community. Its kind
+// cannot change.
+func (r *Registry) Update(ctx context.Context, c Collection) (*Collection, error) {
+	old, err := r.Get(ctx, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	if c.Kind != old.Kind {
+		return nil, fmt.Errorf("%s is a %s; its kind cannot change", c.ID, old.Kind)
+	}
+	if err := r.validate(ctx, &c); err != nil {
+		return nil, err
+	}
+	if err := r.requireSteward(ctx, c.ID); err != nil {
+		return nil, err
+	}
+	details := map[string]string{}
+	if c.Parent != old.Parent {
+		if err := r.requireSteward(ctx, c.Parent); err != nil {
+			return nil, err
+		}
+		ancestors := []Collection{}
+		if c.Parent != "" {
+			parent, err := r.Get(ctx, c.Parent)
+			if err != nil {
+				return nil, err
+			}
+			if ancestors, err = r.Ancestors(ctx, c.Parent); err != nil {
+				return nil, err
+			}
+			ancestors = append(ancestors, *parent)
+		}
+		if slices.ContainsFunc(ancestors, func(a Collection) bool { return a.ID == c.ID }) {
+			return nil, fmt.Errorf("cannot move %s beneath itself", c.ID)
+		}
+		details["parent"] = c.Parent
+		details["oldParent"] = old.Parent
+	}
+	if policy, oldPolicy := describe(c.Versions), describe(old.Versions); policy != oldPolicy {
+		details["versions"] = policy
+		details["oldVersions"] = oldPolicy
+	}
+	if regions, oldRegions := strings.Join(c.Regions, ","), strings.Join(old.Regions, ","); regions != oldRegions {
+		details["regions"] = regions
+		details["oldRegions"] = oldRegions
+	}
+	if c.ConceptDOI != old.ConceptDOI {
+		details["conceptDoi"] = cmp.Or(c.ConceptDOI, ConceptLatest)
+		details["oldConceptDoi"] = cmp.Or(old.ConceptDOI, ConceptLatest)
+	}
+	c.CreatedBy, c.CreatedAt = old.CreatedBy, old.CreatedAt
+	c.UpdatedAt = r.now().UTC()
+	if err := r.State.Put(ctx, collectionsTable, c.ID, &c); err != nil {
+		return nil, err
+	}
+	return &c, r.record(ctx, "collection.update", c.ID, details)
+}
+
+// describe describes a version policy, or returns "every version
+// online" if p is nil.
+func describe(p *VersionPolicy) string {
+	if p == nil {
+		return "every version online"
+	}
+	return p.String()
+}
+
+// validate checks c's fields and parent, normalizing its steward
+// entries.
+func (r *Registry) validate(ctx context.Context, c *Collection) error {
+	if c.Kind != KindCommunity && c.Kind != KindCollection {
+		return fmt.Errorf("invalid kind %q (want %s or %s)", c.Kind, KindCommunity, KindCollection)
+	}
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return fmt.Errorf("a name is required")
+	}
+	if c.Branding.Color != "" && !validColor.MatchString(c.Branding.Color) {
+		return fmt.Errorf("invalid color %q: want a hex color such as #1a5fb4", c.Branding.Color)
+	}
+	for _, u := range []string{c.Homepage, c.Branding.LogoURL, c.Branding.BannerURL} {
+		if u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+			return fmt.Errorf("invalid URL %q: want an http or https URL", u)
+		}
+	}
+	if c.Versions != nil {
+		if c.Kind != KindCollection {
+			return fmt.Errorf("%s is a community; version policies apply to collections", c.ID)
+		}
+		if err := c.Versions.Validate(); err != nil {
+			return err
+		}
+	}
+	if len(c.Regions) > 0 && c.Kind != KindCollection {
+		return fmt.Errorf("%s is a community; read regions apply to collections", c.ID)
+	}
+	regions := make([]string, 0, len(c.Regions))
+	for _, region := range c.Regions {
+		if !validRegion.MatchString(region) {
+			return fmt.Errorf("invalid AWS region %q", region)
+		}
+		if !slices.Contains(regions, region) {
+			regions = append(regions, region)
+		}
+	}
+	c.Regions = regions
+	switch c.ConceptDOI {
+	case "", ConceptLatest:
+		c.ConceptDOI = ""
+	case ConceptVersions:
+		if c.Kind != KindCollection {
+			return fmt.Errorf("%s is a community; concept DOI resolution applies to collections", c.ID)
+		}
+	default:
+		return fmt.Errorf("invalid concept DOI resolution %q (want %s or %s)", c.ConceptDOI, ConceptLatest, ConceptVersions)
+	}
+	stewards := make([]string, 0, len(c.Stewards))
+	for _, e := range c.Stewards {
+		entry, err := authz.ParseEntry(e)
+		if err != nil {
+			return err
+		}
+		if entry == authz.KindAnyone || entry == authz.KindAuthenticated {
+			return fmt.Errorf("stewards must be named users, groups, domains, or ORCID iDs, not %q", entry)
+		}
+		if !slices.Contains(stewards, entry) {
+			stewards = append(stewards, entry)
+		}
+	}
+	c.Stewards = stewards
+	if c.Parent == "" {
+		return nil
+	}
+	if c.Parent == c.ID {
+		return fmt.Errorf("%s cannot belong to itself", c.ID)
+	}
+	parent, err := r.Get(ctx, c.Parent)
+	if err != nil {
+		return err
+	}
+	if parent.Kind != KindCommunity {
+		return fmt.Errorf("%s is a collection; only communities hold collections", parent.ID)
+	}
+	return nil
+}
+
+// Delete removes an empty community or collection. The acting
+// principal must steward the community it belongs to.
+func (r *Registry) Delete(ctx context.Context, id string) error {
+	c, err := r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := r.requireSteward(ctx, c.Parent); err != nil {
+		return err
+	}
+	children, err := r.Children(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return fmt.Errorf("%w: %s holds %d communities or collections", ErrNotEmpty, id, len(children))
+	}
+	members, err := r.Members(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(members) > 0 {
+		return fmt.Errorf("%w: %s holds %d datasets", ErrNotEmpty, id, len(members))
+	}
+	if err := r.State.Delete(ctx, collectionsTable, id); err != nil {
+		return err
+	}
+	return r.record(ctx, "collection.delete", id, nil)
+}
+
+// Members returns the datasets in id, or in the collections beneath it
+// if it is a community, ordered by ID.
+func (r *Registry) Members(ctx context.Context, id string) ([]*dataset.Dataset, error) {
+	all, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Collect id and everything beneath it.
+	within := map[string]bool{id: true}
+	for changed := true; changed; {
+		changed = false
+		for _, c := range all {
+			if within[c.Parent] && !within[c.ID] {
+				within[c.ID] = true
+				changed = true
+			}
+		}
+	}
+	datasets, err := r.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []*dataset.Dataset
+	for _, d := range datasets {
+		if d.Collection != "" && within[d.Collection] {
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// Assign moves the dataset ref into collection id. The acting
+// principal must steward the collection and either manage the dataset
+// or steward the collection it leaves.
+func (r *Registry) Assign(ctx context.Context, id, ref string) (*dataset.Dataset, error) {
+	c, err := r.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.Kind != KindCollection {
+		return nil, fmt.Errorf("%s is a community; datasets belong to collections", id)
+	}
+	if err := r.requireSteward(ctx, id); err != nil {
+		return nil, err
+	}
+	d, err := r.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if d.Collection == id {
+		return d, nil
+	}
+	if err := r.mayRemove(ctx, d); err != nil {
+		return nil, err
+	}
+	old := d.Collection
+	d.Collection = id
+	d.History = append(d.History, dataset.Event{
+		Time:    r.now().UTC(),
+		Actor:   identity.FromContext(ctx).ID,
+		Action:  "collection.assign",
+		Details: map[string]string{"collection": id, "from": old},
+	})
+	if err := r.Datasets.Put(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, r.record(ctx, "collection.assign", d.ID, map[string]string{"collection": id, "from": old})
+}
+
+// mayRemove checks that the acting principal may take d out of its
+// current collection.
+func (r *Registry) mayRemove(ctx context.Context, d *dataset.Dataset) error {
+	p := identity.FromContext(ctx)
+	if authz.Decide(p, d.Resource(), authz.ActionManage).Allowed {
+		return nil
+	}
+	if d.Collection != "" {
+		ok, err := r.IsSteward(ctx, p, d.Collection)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s may neither manage %s nor steward its collection", authz.ErrForbidden, p, d.ID)
+}
+
+func (r *Registry) record(ctx context.Context, action, target string, details map[string]string) error {
+	if r.Log == nil {
+		return nil
+	}
+	return audit.Record(ctx, r.Log, action, target, details)
+}
+
+func (r *Registry) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,197 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func TestRegistry(t *testing.T) {
+	s := state.NewMemoryStore()
+	log := &audit.MemoryLog{}
+	r := &Registry{State: s, Datasets: dataset.NewStore(s), Log: log}
+	admin := identity.WithPrincipal(context.Background(), identity.Principal{ID: "root@uni.edu", Groups: []string{authz.AdminGroup}})
+	geo := identity.WithPrincipal(context.Background(), identity.Principal{ID: "Head@Geo.Uni.edu"})
+	other := identity.WithPrincipal(context.Background(), identity.Principal{ID: "someone@uni.edu"})
+
+	// Only administrators create top-level communities.
+	dept := Collection{ID: "geosciences", Kind: KindCommunity, Name: "Geosciences", Stewards: []string{"user:head@geo.uni.edu"}}
+	if _, err := r.Create(geo, dept); !errors.Is(err, authz.ErrForbidden) {
+		t.Fatalf("Create() of top-level community by non-admin error = %v, want ErrForbidden", err)
+	}
+	if _, err := r.Create(admin, dept); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// Community stewards create and curate what lies beneath.
+	soil := Collection{ID: "soil-lab", Kind: KindCollection, Name: "Soil lab", Parent: "geosciences", Branding: Branding{Color: "#1a5fb4"}, Stewards: []string{"group:soil-lab"}}
+	if _, err := r.Create(geo, soil); err != nil {
+		t.Fatalf("Create() by community steward error = %v", err)
+	}
+	if _, err := r.Create(other, Collection{ID: "rogue", Kind: KindCollection, Name: "Rogue", Parent: "geosciences"}); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Create() by non-steward error = %v, want ErrForbidden", err)
+	}
+	if _, err := r.Create(admin, Collection{ID: "nested", Kind: KindCollection, Name: "Nested", Parent: "soil-lab"}); err == nil {
+		t.Error("Create() beneath a collection succeeded")
+	}
+	if _, err := r.Create(admin, soil); !errors.Is(err, ErrExists) {
+		t.Errorf("Create() of existing ID error = %v, want ErrExists", err)
+	}
+
+	stewards, err := r.Stewards(context.Background(), "soil-lab")
+	if err != nil || fmt.Sprint(stewards) != "[group:soil-lab user:head@geo.uni.edu]" {
+		t.Errorf("Stewards() = %v, %v", stewards, err)
+	}
+	ancestors, err := r.Ancestors(context.Background(), "soil-lab")
+	if err != nil || len(ancestors) != 1 || ancestors[0].ID != "geosciences" {
+		t.Errorf("Ancestors() = %+v, %v", ancestors, err)
+	}
+	lab := identity.Principal{ID: "tech@uni.edu", Groups: []string{"soil-lab"}}
+	if ok, _ := r.IsSteward(context.Background(), lab, "soil-lab"); !ok {
+		t.Error("IsSteward() of collection steward = false")
+	}
+	if ok, _ := r.IsSteward(context.Background(), lab, "geosciences"); ok {
+		t.Error("IsSteward() of collection steward on parent community = true")
+	}
+
+	// A community cannot be moved beneath itself.
+	if _, err := r.Create(admin, Collection{ID: "hydrology", Kind: KindCommunity, Name: "Hydrology", Parent: "geosciences"}); err != nil {
+		t.Fatal(err)
+	}
+	moved := dept
+	moved.Parent = "hydrology"
+	if _, err := r.Update(admin, moved); err == nil {
+		t.Error("Update() moving a community beneath itself succeeded")
+	}
+
+	// Stewards assign datasets they manage to their collections.
+	if err := r.Datasets.Put(context.Background(), &dataset.Dataset{ID: "ds-1", Title: "Cores", ACL: &authz.ACL{Manage: []string{"user:head@geo.uni.edu"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Assign(other, "soil-lab", "ds-1"); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Assign() by non-steward error = %v, want ErrForbidden", err)
+	}
+	if _, err := r.Assign(geo, "geosciences", "ds-1"); err == nil {
+		t.Error("Assign() to a community succeeded")
+	}
+	d, err := r.Assign(geo, "soil-lab", "ds-1")
+	if err != nil || d.Collection != "soil-lab" {
+		t.Fatalf("Assign() = %+v, %v", d, err)
+	}
+	members, err := r.Members(context.Background(), "geosciences")
+	if err != nil || len(members) != 1 || members[0].ID != "ds-1" {
+		t.Errorf("Members() of community = %v, %v", members, err)
+	}
+
+	// Only empty collections are deleted.
+	if err := r.Delete(geo, "soil-lab"); !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("Delete() of non-empty collection error = %v, want ErrNotEmpty", err)
+	}
+	if err := r.Delete(geo, "hydrology"); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if _, err := r.Get(context.Background(), "hydrology"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+
+	entries, _ := log.Entries(context.Background())
+	if len(entries) != 5 || entries[0].Action != "collection.create" || entries[len(entries)-1].Action != "collection.delete" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	r := &Registry{State: state.NewMemoryStore()}
+	tests := []struct {
+		name string
+		c    Collection
+		ok   bool
+	}{
+		{"valid", Collection{ID: "a", Kind: KindCollection, Name: "A", Stewards: []string{"group:x"}}, true},
+		{"bad kind", Collection{ID: "a", Kind: "shelf", Name: "A"}, false},
+		{"no name", Collection{ID: "a", Kind: KindCollection, Name: " "}, false},
+		{"bad color", Collection{ID: "a", Kind: KindCollection, Name: "A", Branding: Branding{Color: "blue"}}, false},
+		{"bad logo", Collection{ID: "a", Kind: KindCollection, Name: "A", Branding: Branding{LogoURL: "javascript:x"}}, false},
+		{"anyone steward", Collection{ID: "a", Kind: KindCollection, Name: "A", Stewards: []string{"anyone"}}, false},
+		{"missing parent", Collection{ID: "a", Kind: KindCollection, Name: "A", Parent: "b"}, false},
+		{"version policy", Collection{ID: "a", Kind: KindCollection, Name: "A", Versions: &VersionPolicy{Online: 2, Archived: 4, Delete: true}}, true},
+		{"no online versions", Collection{ID: "a", Kind: KindCollection, Name: "A", Versions: &VersionPolicy{}}, false},
+		{"archived without delete", Collection{ID: "a", Kind: KindCollection, Name: "A", Versions: &VersionPolicy{Online: 2, Archived: 4}}, false},
+		{"community version policy", Collection{ID: "a", Kind: KindCommunity, Name: "A", Versions: &VersionPolicy{Online: 2}}, false},
+		{"concept DOI", Collection{ID: "a", Kind: KindCollection, Name: "A", ConceptDOI: ConceptVersions}, true},
+		{"bad concept DOI", Collection{ID: "a", Kind: KindCollection, Name: "A", ConceptDOI: "oldest"}, false},
+		{"community concept DOI", Collection{ID: "a", Kind: KindCommunity, Name: "A", ConceptDOI: ConceptVersions}, false},
+		{"regions", Collection{ID: "a", Kind: KindCollection, Name: "A", Regions: []string{"eu-west-1", "ap-southeast-2"}}, true},
+		{"bad region", Collection{ID: "a", Kind: KindCollection, Name: "A", Regions: []string{"Europe"}}, false},
+		{"community regions", Collection{ID: "a", Kind: KindCommunity, Name: "A", Regions: []string{"eu-west-1"}}, false},
+	}
+	for _, tt := range tests {
+		err := r.validate(context.Background(), &tt.c)
+		if (err == nil) != tt.ok {
+			t.Errorf("validate(%s) error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestConceptTarget(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "root@uni.edu", Groups: []string{authz.AdminGroup}})
+	r := &Registry{State: state.NewMemoryStore()}
+	if _, err := r.Create(ctx, Collection{ID: "geo", Kind: KindCommunity, Name: "Geo"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Create(ctx, Collection{ID: "weekly", Kind: KindCollection, Name: "Weekly", Parent: "geo", ConceptDOI: ConceptVersions}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := r.Create(ctx, Collection{ID: "cores", Kind: KindCollection, Name: "Cores", Parent: "geo", ConceptDOI: ConceptLatest})
+	if err != nil || c.ConceptDOI != "" {
+		t.Fatalf("Create() = %+v, %v", c, err)
+	}
+	for id, want := range map[string]string{"weekly": ConceptVersions, "cores": ConceptLatest, "": ConceptLatest, "gone": ConceptLatest} {
+		if got, err := r.ConceptTarget(ctx, id); err != nil || got != want {
+			t.Errorf("ConceptTarget(%q) = %q, %v, want %q", id, got, err, want)
+		}
+	}
+}
+
+func TestVersionPolicy(t *testing.T) {
+	tests := []struct {
+		p    VersionPolicy
+		want string
+		ages []string
+	}{
+		{VersionPolicy{Online: 2}, "2 online, older archived", []string{"", "", "archive", "archive", "archive"}},
+		{VersionPolicy{Online: 1, Delete: true}, "1 online, older deleted", []string{"", "delete", "delete", "delete", "delete"}},
+		{VersionPolicy{Online: 2, Archived: 2, Delete: true}, "2 online, 2 archived, older deleted", []string{"", "", "archive", "archive", "delete"}},
+	}
+	for _, tt := range tests {
+		if got := tt.p.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+		for age, want := range tt.ages {
+			if got := tt.p.Action(age); got != want {
+				t.Errorf("%s: Action(%d) = %q, want %q", tt.want, age, got, want)
+			}
+		}
+	}
+}
@@ -16,8 +16,17 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"net/netip"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 // Config holds the application configuration.
@@ -28,21 +37,593 @@ type Config struct {
 	// AWSRegion is the AWS region for deployment
 	AWSRegion string
 
+	// AWSEndpoint is the URL the CLI sends AWS requests to instead of
+	// the services' regional endpoints, e.g. LocalStack's; from the
+	// standard AWS_ENDPOINT_URL
+	AWSEndpoint string
+
 	// DataCitePrefix is the DOI prefix from DataCite
 	DataCitePrefix string
 
+	// DataCiteURL is the DataCite REST API root
+	DataCiteURL string
+
+	// DataCiteMDSURL is the DataCite MDS API root, used to register
+	// DOI media
+	DataCiteMDSURL string
+
+	// DataCiteRepositoryID is the DataCite repository account
+	DataCiteRepositoryID string
+
+	// DataCitePassword is the DataCite repository account password
+	DataCitePassword string
+
+	// DataCiteUsageToken is the DataCite usage hub token, used to
+	// submit monthly COUNTER usage reports
+	DataCiteUsageToken string
+
+	// DataCiteRateLimit is the sustained DataCite requests per second
+	DataCiteRateLimit float64
+
+	// DataCiteConcurrency caps in-flight DataCite requests
+	DataCiteConcurrency int
+
+	// EZIDURL is the EZID API root, for minting ARKs
+	EZIDURL string
+
+	// EZIDUsername is the EZID account name
+	EZIDUsername string
+
+	// EZIDPassword is the EZID account password
+	EZIDPassword string
+
+	// ARKShoulder is the EZID shoulder ARKs are minted under (e.g.
+	// "ark:/99999/fk4"); ARKs cannot be minted when empty
+	ARKShoulder string
+
+	// HandleURL is the REST API root of the institution's handle
+	// server, e.g. https://hdl.example.edu:8000
+	HandleURL string
+
+	// HandlePrefix is the institution's handle prefix; handles cannot
+	// be minted when empty
+	HandlePrefix string
+
+	// HandleAdmin is the "index:handle" administrator authenticating
+	// to the handle server; 300:0.NA/<prefix> if empty
+	HandleAdmin string
+
+	// HandlePassword is the handle administrator's secret key
+	HandlePassword string
+
+	// RAiDURL is the RAiD registration agency API root
+	RAiDURL string
+
+	// RAiDToken is the bearer token of the RAiD service point account;
+	// RAiD records are not updated when empty
+	RAiDToken string
+
+	// RORURL is the ROR API root, for resolving affiliations
+	RORURL string
+
+	// RORClientID identifies the repository to the ROR API; optional
+	RORClientID string
+
+	// CrossrefURL is the Crossref API root, for validating grant DOIs
+	CrossrefURL string
+
+	// CrossrefMailto is the contact address sent to Crossref; optional
+	CrossrefMailto string
+
+	// GitHubURL is the GitHub API root, for capturing software releases
+	GitHubURL string
+
+	// GitHubToken authenticates GitHub API requests; optional for
+	// public repositories
+	GitHubToken string
+
+	// PIDScheme is the identifier scheme (doi, ark, handle) of datasets
+	// published outside PIDSchemes
+	PIDScheme string
+
+	// PIDSchemes maps collections to their identifier scheme
+	PIDSchemes map[string]string
+
+	// SiteURL is the public base URL of landing pages, which
+	// persistent identifiers resolve to
+	SiteURL string
+
+	// Publisher is the institution registered as the publisher of
+	// datasets
+	Publisher string
+
 	// ProjectName is the name of the project for resource naming
 	ProjectName string
+
+	// DeployBackend is the tool 'aperture deploy' deploys with:
+	// "terraform" (the default) or "cloudformation"
+	DeployBackend string
+
+	// TrafficSteps are the percentages of API traffic the Lambda
+	// versions a deployment publishes take in turn, each watched for
+	// TrafficStepMinutes, before they take all of it; all at once if
+	// empty
+	TrafficSteps       []int
+	TrafficStepMinutes int
+
+	// DeployRoles maps the storage, compute, and logging layers of the
+	// stack to the role deploying them in their own account; a layer
+	// without a role deploys with the caller's credentials
+	DeployRoles map[string]string
+
+	// StorageKMSKey is the KMS key, in the storage account, encrypting
+	// the buckets; S3-managed keys if empty
+	StorageKMSKey string
+
+	// OutputRegistry is where deployments publish their stack outputs
+	// and settings left empty are discovered from: "ssm" for Parameter
+	// Store, or "" for neither
+	OutputRegistry string
+
+	// TerraformPath is the terraform binary 'aperture deploy' runs;
+	// terraform on the PATH if empty
+	TerraformPath string
+
+	// SourceDir is the aperture source checkout 'aperture deploy' builds
+	// the API's Lambda functions from; the checkout holding the working
+	// directory if empty
+	SourceDir string
+
+	// TerraformStateBucket is the S3 bucket holding the Terraform state
+	// of each environment; infrastructure cannot be deployed with
+	// Terraform when empty
+	TerraformStateBucket string
+
+	// BudgetAlertEmail receives the deployment's AWS budget alerts
+	BudgetAlertEmail string
+
+	// EstimateStorageGB, EstimateTransferGB, and EstimateRequests
+	// project the monthly usage 'aperture deploy --estimate' prices:
+	// the data the buckets hold, the data CloudFront serves, and the
+	// API requests made
+	EstimateStorageGB  float64
+	EstimateTransferGB float64
+	EstimateRequests   int
+
+	// EstimateSearchInstanceType and EstimateSearchInstances size the
+	// OpenSearch domain at OpenSearchURL in cost estimates
+	EstimateSearchInstanceType string
+	EstimateSearchInstances    int
+
+	// StateDir is the directory for local state (records, outbox)
+	StateDir string
+
+	// StateBackend selects where Aperture records are kept: files, for
+	// JSON files under StateDir; or dynamodb, for the state table of
+	// the environment, shared with the platform's Lambda functions
+	StateBackend string
+
+	// EnvironmentsDir holds the configuration of each environment for
+	// promotions between them, as <environment>.env files; environments
+	// under the state directory if empty
+	EnvironmentsDir string
+
+	// DevIdentity lets the CLI act as User without signing in, for
+	// development against a local emulator; it is refused otherwise,
+	// since anyone can set User
+	DevIdentity bool
+
+	// User is the identity the CLI acts as with DevIdentity
+	User string
+
+	// ORCID is User's ORCID iD with DevIdentity, for dataset access
+	// control lists
+	ORCID string
+
+	// Admins lists the users allowed to perform administrative actions
+	Admins []string
+
+	// CognitoUserPoolID is the user pool holding repository accounts
+	CognitoUserPoolID string
+
+	// OIDCIssuer is the OpenID provider 'aperture login' signs in to;
+	// the Cognito user pool if empty
+	OIDCIssuer string
+
+	// OIDCClientID is the public OAuth client ID of the CLI
+	OIDCClientID string
+
+	// APIURL is the base URL of the Aperture API
+	APIURL string
+
+	// Token is a machine token; when set, commands run as its service
+	// account instead of User
+	Token string
+
+	// AffiliationGroups maps eduPersonAffiliation values asserted by
+	// institutional SAML sign-in to Aperture groups; the federation
+	// package defaults apply when nil
+	AffiliationGroups map[string][]string
+
+	// SMTPAddr is the host:port of the mail relay; notifications are
+	// written to the local outbox when empty
+	SMTPAddr string
+
+	// MailFrom is the sender address for notifications
+	MailFrom string
+
+	// CloudFrontDistributionID is the distribution serving landing
+	// pages; invalidations are skipped when empty
+	CloudFrontDistributionID string
+
+	// CloudFrontOriginSecret is the value a CloudFront distribution in
+	// front of the API and share link servers sends in the
+	// X-Aperture-Origin-Secret header of its origin requests. Only
+	// requests carrying it are believed about the viewer's country;
+	// without it, files restricted to countries are refused
+	CloudFrontOriginSecret string
+
+	// StorageLayout selects how dataset files map to buckets and keys
+	// (purpose, collection, hashed, content)
+	StorageLayout string
+
+	// StorageBackend selects the object store holding the media
+	// buckets (s3; gcs; azure, whose containers take the buckets'
+	// names; or filesystem, whose directories do)
+	StorageBackend string
+
+	// GCSAccessKeyID and GCSSecretAccessKey are the HMAC key of the gcs
+	// storage backend, which reaches Google Cloud Storage through its
+	// S3-compatible XML API
+	GCSAccessKeyID     string
+	GCSSecretAccessKey string
+
+	// GCSEndpoint overrides the endpoint of the XML API
+	GCSEndpoint string
+
+	// StorageRoot is the directory holding the buckets of the
+	// filesystem storage backend; objects under StateDir if empty
+	StorageRoot string
+
+	// FileServerURL is the base URL of 'aperture storage serve', which
+	// serves the presigned URLs of the filesystem storage backend
+	FileServerURL string
+
+	// FileServerSecret signs the presigned URLs of the filesystem
+	// storage backend
+	FileServerSecret string
+
+	// AzureStorageAccount and AzureStorageSecretKey are the Azure
+	// storage account of the azure storage backend and its
+	// base64-encoded key
+	AzureStorageAccount   string
+	AzureStorageSecretKey string
+
+	// AzureBlobEndpoint overrides the account's blob endpoint, e.g. for
+	// Azurite
+	AzureBlobEndpoint string
+
+	// StorageEndpoint is the endpoint of an S3-compatible store, e.g. an
+	// on-premises MinIO or Ceph cluster, holding the media buckets of
+	// the s3 storage backend; AWS if empty
+	StorageEndpoint string
+
+	// StorageRegion is the region requests to the media buckets are
+	// signed for; AWSRegion if empty
+	StorageRegion string
+
+	// StoragePathStyle addresses the media buckets as path segments
+	// rather than subdomains; the default with a StorageEndpoint
+	StoragePathStyle bool
+
+	// StorageAccessKeyID and StorageSecretAccessKey are the credentials
+	// of the media buckets' store; if empty, they are read from
+	// StorageCredentialsFile, or else are the AWS credentials of the
+	// environment
+	StorageAccessKeyID     string
+	StorageSecretAccessKey string
+
+	// StorageCredentialsFile and StorageProfile name a shared
+	// credentials file, and its profile ("default" if empty), holding
+	// the credentials of the media buckets' store
+	StorageCredentialsFile string
+	StorageProfile         string
+
+	// TierBucketPrefix prefixes the names of the AWS buckets that the
+	// files of older versions tier to from the primary store; tiering
+	// is off if empty
+	TierBucketPrefix string
+
+	// TierAfterDays is how long a version stays in the primary store
+	// after it is published
+	TierAfterDays int
+
+	// TierStorageClass is the storage class of tiered files; GLACIER_IR
+	// if empty
+	TierStorageClass string
+
+	// ShareURL is the base URL of the API serving share links
+	ShareURL string
+
+	// MediaURL is the base URL of the CDN serving public media
+	MediaURL string
+
+	// DownloadURL is the base URL of the counted download redirect
+	// endpoint linked from landing pages; files are not linked if empty
+	DownloadURL string
+
+	// QuotaBytes caps the bytes of restricted data issued to one user
+	// per day; 0 disables the limit
+	QuotaBytes int64
+
+	// QuotaObjects caps the restricted objects issued to one user per
+	// day; 0 disables the limit
+	QuotaObjects int
+
+	// OpenSearchURL is the endpoint of the OpenSearch domain indexing
+	// datasets for discovery; indexing is skipped when empty
+	OpenSearchURL string
+
+	// ScannerURL is the endpoint of the malware scanner, e.g. a Lambda
+	// function URL running ClamAV; files are not scanned, and
+	// publication does not wait for scanning, when empty
+	ScannerURL string
+
+	// ReplicaBucket holds the encrypted preservation copies of
+	// published datasets with an independent provider; datasets are not
+	// copied when empty
+	ReplicaBucket string
+
+	// ReplicaRegion is the region of the replica bucket; AWSRegion if
+	// empty
+	ReplicaRegion string
+
+	// ReplicaEndpoint is the endpoint of an S3-compatible provider,
+	// e.g. https://s3.us-west-1.wasabisys.com; AWS if empty
+	ReplicaEndpoint string
+
+	// ReplicaStorageClass is the storage class of copies; DEEP_ARCHIVE
+	// on AWS and the provider's default elsewhere if empty
+	ReplicaStorageClass string
+
+	// ReplicaAccessKeyID and ReplicaSecretAccessKey are the credentials
+	// of the replica's account; the AWS credentials of the environment
+	// are used if empty
+	ReplicaAccessKeyID     string
+	ReplicaSecretAccessKey string
+
+	// ReplicaCredentialsFile and ReplicaProfile name a shared
+	// credentials file, and its profile, holding the credentials of the
+	// replica's account when ReplicaAccessKeyID is empty
+	ReplicaCredentialsFile string
+	ReplicaProfile         string
+
+	// ReplicaPathStyle addresses the replica bucket as a path segment
+	// rather than a subdomain; the default with a ReplicaEndpoint
+	ReplicaPathStyle bool
+
+	// ReplicaKey is the base64 256-bit key copies are encrypted with
+	ReplicaKey string
+
+	// SealKeyID is the ID, ARN, or alias of the asymmetric
+	// ECC_NIST_P256 KMS key that signs the manifests of published
+	// versions; versions are not sealed when empty
+	SealKeyID string
+
+	// AuditAnchorBucket is the bucket, with S3 Object Lock enabled,
+	// that the head of the audit log is anchored in; the log is not
+	// anchored when empty
+	AuditAnchorBucket string
+
+	// QueueBackend selects where background work is queued for
+	// 'aperture worker': sqs, for Amazon SQS queues named for the
+	// bucket prefix and topic; local, for queues in the state
+	// directory; or empty, to do the work within the request that
+	// causes it
+	QueueBackend string
+
+	// MetricsTarget is where operational metrics are written in
+	// CloudWatch embedded metric format: "stderr", or a CloudWatch
+	// agent at tcp://host:port or udp://host:port; metrics are off when
+	// empty, except in Lambda, where they go to stderr
+	MetricsTarget string
+
+	// MetricsNamespace is the CloudWatch namespace of the metrics
+	MetricsNamespace string
+
+	// TraceEndpoint is the OTLP/HTTP URL spans are exported to, from
+	// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or /v1/traces under
+	// OTEL_EXPORTER_OTLP_ENDPOINT; tracing is off when empty
+	TraceEndpoint string
+
+	// TraceHeaders are sent with every export, e.g. an API key for a
+	// hosted backend
+	TraceHeaders map[string]string
+
+	// TraceService is the service.name of exported spans
+	TraceService string
+
+	// StatusEndpoints maps the public names of services to the health
+	// endpoints the status page checks; when nil, the /healthz
+	// endpoints under APIURL and DownloadURL are checked
+	StatusEndpoints map[string]string
 }
 
 // Load loads the configuration from environment variables.
 // If required variables are not set, it returns default values.
 func Load() (*Config, error) {
+	return load(os.Getenv)
+}
+
+// load loads the configuration from the variables e looks up.
+func load(e env) (*Config, error) {
 	cfg := &Config{
-		Environment:    getEnv("APERTURE_ENV", "dev"),
-		AWSRegion:      getEnv("AWS_REGION", "us-east-1"),
-		DataCitePrefix: getEnv("DATACITE_PREFIX", ""),
-		ProjectName:    getEnv("APERTURE_PROJECT_NAME", "aperture"),
+		Environment:    e.getEnv("APERTURE_ENV", "dev"),
+		AWSRegion:      e.getEnv("AWS_REGION", "us-east-1"),
+		AWSEndpoint:    e.getEnv("AWS_ENDPOINT_URL", ""),
+		DataCitePrefix: e.getEnv("DATACITE_PREFIX", ""),
+		ProjectName:    e.getEnv("APERTURE_PROJECT_NAME", "aperture"),
+		StorageLayout:  e.getEnv("APERTURE_STORAGE_LAYOUT", "purpose"),
+		StorageBackend: e.getEnv("APERTURE_STORAGE_BACKEND", "s3"),
+		StateDir:       e.getEnv("APERTURE_STATE_DIR", defaultStateDir()),
+		StateBackend:   e.getEnv("APERTURE_STATE_BACKEND", "files"),
+		User:           e.getEnv("APERTURE_USER", e("USER")),
+		ORCID:          e("APERTURE_ORCID"),
+		Admins:         e.getEnvList("APERTURE_ADMINS"),
+		SMTPAddr:       e.getEnv("APERTURE_SMTP_ADDR", ""),
+		MailFrom:       e.getEnv("APERTURE_MAIL_FROM", "aperture@localhost"),
+		ShareURL:       e.getEnv("APERTURE_SHARE_URL", "http://127.0.0.1:8081"),
+		MediaURL:       e.getEnv("APERTURE_MEDIA_URL", ""),
+		DownloadURL:    e.getEnv("APERTURE_DOWNLOAD_URL", ""),
+		OpenSearchURL:  e.getEnv("APERTURE_OPENSEARCH_URL", ""),
+		ScannerURL:     e.getEnv("APERTURE_SCANNER_URL", ""),
+
+		ReplicaBucket:          e.getEnv("APERTURE_REPLICA_BUCKET", ""),
+		ReplicaRegion:          e.getEnv("APERTURE_REPLICA_REGION", ""),
+		ReplicaEndpoint:        e.getEnv("APERTURE_REPLICA_ENDPOINT", ""),
+		ReplicaStorageClass:    e.getEnv("APERTURE_REPLICA_STORAGE_CLASS", ""),
+		ReplicaAccessKeyID:     e.getEnv("APERTURE_REPLICA_ACCESS_KEY_ID", ""),
+		ReplicaSecretAccessKey: e.getEnv("APERTURE_REPLICA_SECRET_ACCESS_KEY", ""),
+		AzureStorageAccount:    e.getEnv("AZURE_STORAGE_ACCOUNT", ""),
+		AzureStorageSecretKey:  e.getEnv("AZURE_STORAGE_KEY", ""),
+		AzureBlobEndpoint:      e.getEnv("APERTURE_AZURE_BLOB_ENDPOINT", ""),
+		GCSAccessKeyID:         e.getEnv("APERTURE_GCS_ACCESS_KEY_ID", ""),
+		GCSSecretAccessKey:     e.getEnv("APERTURE_GCS_SECRET_ACCESS_KEY", ""),
+		GCSEndpoint:            e.getEnv("APERTURE_GCS_ENDPOINT", "https://storage.googleapis.com"),
+		StorageRoot:            e.getEnv("APERTURE_STORAGE_ROOT", ""),
+		FileServerURL:          e.getEnv("APERTURE_FILE_SERVER_URL", "http://127.0.0.1:8480"),
+		FileServerSecret:       e.getEnv("APERTURE_FILE_SERVER_SECRET", ""),
+		ReplicaCredentialsFile: e.getEnv("APERTURE_REPLICA_CREDENTIALS_FILE", ""),
+		ReplicaProfile:         e.getEnv("APERTURE_REPLICA_PROFILE", ""),
+		StorageEndpoint:        e.getEnv("APERTURE_STORAGE_ENDPOINT", ""),
+		StorageRegion:          e.getEnv("APERTURE_STORAGE_REGION", ""),
+		StorageAccessKeyID:     e.getEnv("APERTURE_STORAGE_ACCESS_KEY_ID", ""),
+		StorageSecretAccessKey: e.getEnv("APERTURE_STORAGE_SECRET_ACCESS_KEY", ""),
+		StorageCredentialsFile: e.getEnv("APERTURE_STORAGE_CREDENTIALS_FILE", ""),
+		StorageProfile:         e.getEnv("APERTURE_STORAGE_PROFILE", ""),
+		TierBucketPrefix:       e.getEnv("APERTURE_TIER_BUCKET_PREFIX", ""),
+		TierStorageClass:       e.getEnv("APERTURE_TIER_STORAGE_CLASS", ""),
+		ReplicaKey:             e.getEnv("APERTURE_REPLICA_KEY", ""),
+		SealKeyID:              e.getEnv("APERTURE_SEAL_KEY_ID", ""),
+		AuditAnchorBucket:      e.getEnv("APERTURE_AUDIT_ANCHOR_BUCKET", ""),
+		QueueBackend:           e.getEnv("APERTURE_QUEUE_BACKEND", ""),
+
+		MetricsTarget:    e.getEnv("APERTURE_METRICS", defaultMetricsTarget()),
+		MetricsNamespace: e.getEnv("APERTURE_METRICS_NAMESPACE", "Aperture"),
+		TraceEndpoint:    e.traceEndpoint(),
+		TraceService:     e.getEnv("OTEL_SERVICE_NAME", "aperture"),
+
+		CloudFrontDistributionID: e.getEnv("APERTURE_CLOUDFRONT_DISTRIBUTION_ID", ""),
+		CloudFrontOriginSecret:   e.getEnv("APERTURE_CLOUDFRONT_ORIGIN_SECRET", ""),
+		CognitoUserPoolID:        e.getEnv("APERTURE_COGNITO_USER_POOL_ID", ""),
+		OIDCIssuer:               e.getEnv("APERTURE_OIDC_ISSUER", ""),
+		OIDCClientID:             e.getEnv("APERTURE_OIDC_CLIENT_ID", ""),
+		APIURL:                   e.getEnv("APERTURE_API_URL", ""),
+		Token:                    e.getEnv("APERTURE_TOKEN", ""),
+
+		DataCiteURL:          e.getEnv("DATACITE_API_URL", "https://api.test.datacite.org"),
+		DataCiteMDSURL:       e.getEnv("DATACITE_MDS_URL", "https://mds.test.datacite.org"),
+		DataCiteRepositoryID: e.getEnv("DATACITE_REPOSITORY_ID", ""),
+		DataCitePassword:     e.getEnv("DATACITE_PASSWORD", ""),
+		DataCiteUsageToken:   e.getEnv("DATACITE_USAGE_TOKEN", ""),
+
+		EZIDURL:        e.getEnv("EZID_API_URL", "https://ezid.cdlib.org"),
+		EZIDUsername:   e.getEnv("EZID_USERNAME", ""),
+		EZIDPassword:   e.getEnv("EZID_PASSWORD", ""),
+		ARKShoulder:    e.getEnv("ARK_SHOULDER", ""),
+		PIDScheme:      e.getEnv("APERTURE_PID_SCHEME", "doi"),
+		HandleURL:      e.getEnv("HANDLE_API_URL", ""),
+		HandlePrefix:   e.getEnv("HANDLE_PREFIX", ""),
+		HandleAdmin:    e.getEnv("HANDLE_ADMIN", ""),
+		HandlePassword: e.getEnv("HANDLE_PASSWORD", ""),
+		RAiDURL:        e.getEnv("RAID_API_URL", "https://api.demo.raid.org.au"),
+		RAiDToken:      e.getEnv("RAID_TOKEN", ""),
+		RORURL:         e.getEnv("ROR_API_URL", "https://api.ror.org"),
+		RORClientID:    e.getEnv("ROR_CLIENT_ID", ""),
+		CrossrefURL:    e.getEnv("CROSSREF_API_URL", "https://api.crossref.org"),
+		CrossrefMailto: e.getEnv("CROSSREF_MAILTO", ""),
+		GitHubURL:      e.getEnv("GITHUB_API_URL", "https://api.github.com"),
+		GitHubToken:    e.getEnv("GITHUB_TOKEN", ""),
+		SiteURL:        e.getEnv("APERTURE_SITE_URL", ""),
+		Publisher:      e.getEnv("APERTURE_PUBLISHER", ""),
+
+		DeployBackend:        e.getEnv("APERTURE_DEPLOY_BACKEND", "terraform"),
+		OutputRegistry:       e.getEnv("APERTURE_OUTPUT_REGISTRY", ""),
+		StorageKMSKey:        e.getEnv("APERTURE_STORAGE_KMS_KEY", ""),
+		TerraformPath:        e.getEnv("APERTURE_TERRAFORM", ""),
+		SourceDir:            e.getEnv("APERTURE_SOURCE_DIR", ""),
+		TerraformStateBucket: e.getEnv("APERTURE_TF_STATE_BUCKET", ""),
+		BudgetAlertEmail:     e.getEnv("APERTURE_BUDGET_ALERT_EMAIL", ""),
+		EnvironmentsDir:      e.getEnv("APERTURE_ENVIRONMENTS", ""),
+
+		EstimateSearchInstanceType: e.getEnv("APERTURE_ESTIMATE_SEARCH_INSTANCE_TYPE", "t3.small.search"),
+	}
+
+	var err error
+	if cfg.DataCiteRateLimit, err = e.getEnvFloat("DATACITE_RATE_LIMIT", 8); err != nil {
+		return nil, err
+	}
+	if cfg.DataCiteConcurrency, err = e.getEnvInt("DATACITE_CONCURRENCY", 4); err != nil {
+		return nil, err
+	}
+	quotaBytes, err := e.getEnvInt("APERTURE_QUOTA_BYTES", 50<<30)
+	if err != nil {
+		return nil, err
+	}
+	cfg.QuotaBytes = int64(quotaBytes)
+	if cfg.QuotaObjects, err = e.getEnvInt("APERTURE_QUOTA_OBJECTS", 1000); err != nil {
+		return nil, err
+	}
+	if steps := e.getEnv("APERTURE_TRAFFIC_STEPS", "10,50"); steps != "none" {
+		for _, step := range strings.Split(steps, ",") {
+			pct, err := strconv.Atoi(strings.TrimSpace(step))
+			if err != nil {
+				return nil, fmt.Errorf("invalid APERTURE_TRAFFIC_STEPS %q: %w", steps, err)
+			}
+			cfg.TrafficSteps = append(cfg.TrafficSteps, pct)
+		}
+	}
+	if cfg.TrafficStepMinutes, err = e.getEnvInt("APERTURE_TRAFFIC_STEP_MINUTES", 5); err != nil {
+		return nil, err
+	}
+	if cfg.EstimateStorageGB, err = e.getEnvFloat("APERTURE_ESTIMATE_STORAGE_GB", 1000); err != nil {
+		return nil, err
+	}
+	if cfg.EstimateTransferGB, err = e.getEnvFloat("APERTURE_ESTIMATE_TRANSFER_GB", 500); err != nil {
+		return nil, err
+	}
+	if cfg.EstimateRequests, err = e.getEnvInt("APERTURE_ESTIMATE_REQUESTS", 1_000_000); err != nil {
+		return nil, err
+	}
+	if cfg.EstimateSearchInstances, err = e.getEnvInt("APERTURE_ESTIMATE_SEARCH_INSTANCES", 1); err != nil {
+		return nil, err
+	}
+	if cfg.TierAfterDays, err = e.getEnvInt("APERTURE_TIER_AFTER_DAYS", 365); err != nil {
+		return nil, err
+	}
+	if cfg.StoragePathStyle, err = e.getEnvBool("APERTURE_STORAGE_PATH_STYLE", cfg.StorageEndpoint != ""); err != nil {
+		return nil, err
+	}
+	if cfg.ReplicaPathStyle, err = e.getEnvBool("APERTURE_REPLICA_PATH_STYLE", cfg.ReplicaEndpoint != ""); err != nil {
+		return nil, err
+	}
+	if cfg.DevIdentity, err = e.getEnvBool("APERTURE_DEV_IDENTITY", false); err != nil {
+		return nil, err
+	}
+	if cfg.AffiliationGroups, err = e.getEnvMultiMap("APERTURE_AFFILIATION_GROUPS"); err != nil {
+		return nil, err
+	}
+	if cfg.PIDSchemes, err = e.getEnvMap("APERTURE_PID_SCHEMES"); err != nil {
+		return nil, err
+	}
+	if cfg.TraceHeaders, err = e.getEnvMap("OTEL_EXPORTER_OTLP_HEADERS"); err != nil {
+		return nil, err
+	}
+	if cfg.StatusEndpoints, err = e.getEnvMap("APERTURE_STATUS_ENDPOINTS"); err != nil {
+		return nil, err
+	}
+	if cfg.DeployRoles, err = e.getEnvMap("APERTURE_DEPLOY_ROLES"); err != nil {
+		return nil, err
 	}
 
 	// Validate configuration
@@ -53,6 +634,97 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// LoadEnvironment loads the configuration of the named environment:
+// the environment variables, overridden by the KEY=VALUE lines of its
+// file in EnvironmentsDir, with APERTURE_ENV set to name. Blank lines
+// and lines starting with # are skipped.
+func (c *Config) LoadEnvironment(name string) (*Config, error) {
+	dir := c.EnvironmentsDir
+	if dir == "" {
+		dir = filepath.Join(c.StateDir, "environments")
+	}
+	path := filepath.Join(dir, name+".env")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("environment %s is not configured; create %s", name, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	vars := map[string]string{"APERTURE_ENV": name}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if k = strings.TrimSpace(k); !ok || k == "" {
+			return nil, fmt.Errorf("%s:%d: want KEY=VALUE", path, i+1)
+		}
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		if k != "APERTURE_ENV" {
+			vars[k] = v
+		}
+	}
+	cfg, err := load(func(key string) string {
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		return os.Getenv(key)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("environment %s: %w", name, err)
+	}
+	return cfg, nil
+}
+
+// Difference is a setting that differs between two configurations.
+type Difference struct {
+	Setting string `json:"setting"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+// Diff returns the settings that differ between from and to, in the
+// order Config declares them. Secrets are compared but shown only as
+// set or unset.
+func Diff(from, to *Config) []Difference {
+	var diffs []Difference
+	a, b := reflect.ValueOf(from).Elem(), reflect.ValueOf(to).Elem()
+	for i := range a.NumField() {
+		name := a.Type().Field(i).Name
+		if reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			continue
+		}
+		d := Difference{Setting: name, From: fmt.Sprint(a.Field(i).Interface()), To: fmt.Sprint(b.Field(i).Interface())}
+		if secret(name) {
+			d.From, d.To = redact(a.Field(i)), redact(b.Field(i))
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+// secret reports whether the setting name holds a credential.
+func secret(name string) bool {
+	for _, s := range []string{"Password", "Secret", "Token"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redact(v reflect.Value) string {
+	if v.IsZero() {
+		return "(unset)"
+	}
+	return "(set)"
+}
+
 // Validate checks if the configuration is valid.
 func (c *Config) Validate() error {
 	if c.Environment == "" {
@@ -63,13 +735,343 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("AWS region cannot be empty")
 	}
 
+	if c.DevIdentity && !localEndpoint(c.AWSEndpoint) {
+		return fmt.Errorf("APERTURE_DEV_IDENTITY trusts APERTURE_USER without signing in and is only allowed against a local emulator (AWS_ENDPOINT_URL on this machine, e.g. http://localhost:4566)")
+	}
+
+	if c.DataCiteRateLimit < 0 {
+		return fmt.Errorf("DataCite rate limit cannot be negative")
+	}
+
+	if c.DataCiteConcurrency < 0 {
+		return fmt.Errorf("DataCite concurrency cannot be negative")
+	}
+
+	if c.QuotaBytes < 0 || c.QuotaObjects < 0 {
+		return fmt.Errorf("download quotas cannot be negative")
+	}
+
+	if c.PIDScheme != "" && !validPIDScheme(c.PIDScheme) {
+		return fmt.Errorf("invalid identifier scheme %q (want doi, ark, or handle)", c.PIDScheme)
+	}
+	for coll, scheme := range c.PIDSchemes {
+		if !validPIDScheme(scheme) {
+			return fmt.Errorf("invalid identifier scheme %q for collection %s (want doi, ark, or handle)", scheme, coll)
+		}
+	}
+
+	switch c.StorageBackend {
+	case "", "s3":
+		if (c.StorageAccessKeyID == "") != (c.StorageSecretAccessKey == "") {
+			return fmt.Errorf("set both APERTURE_STORAGE_ACCESS_KEY_ID and APERTURE_STORAGE_SECRET_ACCESS_KEY, or neither")
+		}
+	case "azure":
+		if c.AzureStorageAccount == "" || c.AzureStorageSecretKey == "" {
+			return fmt.Errorf("the azure storage backend needs AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_KEY")
+		}
+	case "gcs":
+		if c.GCSAccessKeyID == "" || c.GCSSecretAccessKey == "" {
+			return fmt.Errorf("the gcs storage backend needs APERTURE_GCS_ACCESS_KEY_ID and APERTURE_GCS_SECRET_ACCESS_KEY")
+		}
+	case "filesystem":
+		if c.FileServerSecret == "" {
+			return fmt.Errorf("the filesystem storage backend needs APERTURE_FILE_SERVER_SECRET to sign download URLs")
+		}
+	default:
+		return fmt.Errorf("invalid storage backend %q (want s3, gcs, azure, or filesystem)", c.StorageBackend)
+	}
+
+	if c.TierBucketPrefix != "" {
+		if c.TierBucketPrefix == c.BucketPrefix() {
+			return fmt.Errorf("APERTURE_TIER_BUCKET_PREFIX must differ from the primary bucket prefix %s", c.BucketPrefix())
+		}
+		if c.TierAfterDays < 0 {
+			return fmt.Errorf("APERTURE_TIER_AFTER_DAYS cannot be negative")
+		}
+	}
+
+	if c.StateBackend != "" && c.StateBackend != "files" && c.StateBackend != "dynamodb" {
+		return fmt.Errorf("invalid state backend %q (want files or dynamodb)", c.StateBackend)
+	}
+
+	if c.QueueBackend != "" && c.QueueBackend != "local" && c.QueueBackend != "sqs" {
+		return fmt.Errorf("invalid queue backend %q (want sqs or local)", c.QueueBackend)
+	}
+
+	if c.DeployBackend != "" && c.DeployBackend != "terraform" && c.DeployBackend != "cloudformation" {
+		return fmt.Errorf("invalid deployment backend %q (want terraform or cloudformation)", c.DeployBackend)
+	}
+	for layer, role := range c.DeployRoles {
+		if !slices.Contains(DeployLayers, layer) {
+			return fmt.Errorf("invalid deployment layer %q (want storage, compute, or logging)", layer)
+		}
+		if !strings.HasPrefix(role, "arn:") || !strings.Contains(role, ":role/") {
+			return fmt.Errorf("invalid %s deployment role %q (want a role ARN)", layer, role)
+		}
+	}
+	if len(c.DeployRoles) > 0 && c.DeployBackend == "cloudformation" {
+		return fmt.Errorf("deploying to several accounts needs the terraform backend")
+	}
+	if c.OutputRegistry != "" && c.OutputRegistry != "ssm" {
+		return fmt.Errorf("invalid output registry %q (want ssm)", c.OutputRegistry)
+	}
+	for i, pct := range c.TrafficSteps {
+		if pct <= 0 || pct >= 100 || i > 0 && pct <= c.TrafficSteps[i-1] {
+			return fmt.Errorf("invalid traffic steps %v (want increasing percentages between 0 and 100)", c.TrafficSteps)
+		}
+	}
+	if c.EstimateStorageGB < 0 || c.EstimateTransferGB < 0 || c.EstimateRequests < 0 || c.EstimateSearchInstances < 0 {
+		return fmt.Errorf("projected usage for cost estimates must not be negative")
+	}
+	if c.TrafficStepMinutes < 0 {
+		return fmt.Errorf("invalid traffic step duration of %d minutes", c.TrafficStepMinutes)
+	}
+
 	return nil
 }
 
+// BucketPrefix returns the prefix shared by all bucket names, matching
+// the naming used by the Terraform modules.
+func (c *Config) BucketPrefix() string {
+	return c.ProjectName + "-" + c.Environment
+}
+
+// DeployLayers are the layers of the stack that can be deployed to
+// accounts of their own.
+var DeployLayers = []string{"storage", "compute", "logging"}
+
+// outputSettings maps the stack outputs settings are discovered from
+// to the settings.
+func (c *Config) outputSettings() map[string]*string {
+	return map[string]*string{
+		"api_gateway_invoke_url":              &c.APIURL,
+		"cloudfront_frontend_url":             &c.SiteURL,
+		"cloudfront_public_media_url":         &c.MediaURL,
+		"cloudfront_frontend_distribution_id": &c.CloudFrontDistributionID,
+		"cognito_user_pool_id":                &c.CognitoUserPoolID,
+		"cognito_cli_client_id":               &c.OIDCClientID,
+	}
+}
+
+// ApplyOutputs sets the settings left empty to the stack outputs of the
+// environment that provide them, and returns the outputs used. Settings
+// configured explicitly take precedence.
+func (c *Config) ApplyOutputs(outputs map[string]string) []string {
+	var used []string
+	settings := c.outputSettings()
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		if v := outputs[name]; v != "" && *settings[name] == "" {
+			*settings[name] = v
+			used = append(used, name)
+		}
+	}
+	return used
+}
+
+// FrontendBucket returns the bucket serving the frontend and landing
+// pages.
+func (c *Config) FrontendBucket() string {
+	return c.BucketPrefix() + "-frontend"
+}
+
+// QuarantineBucket returns the bucket infected files are moved to.
+func (c *Config) QuarantineBucket() string {
+	return c.BucketPrefix() + "-quarantine"
+}
+
+// StateTable returns the DynamoDB table records are kept in by the
+// dynamodb state backend, matching the naming used by the Terraform
+// modules.
+func (c *Config) StateTable() string {
+	return c.ProjectName + "-state-" + c.Environment
+}
+
+// QuotaTable returns the DynamoDB table daily download usage is kept
+// in by the dynamodb state backend.
+func (c *Config) QuotaTable() string {
+	return c.ProjectName + "-download-quotas-" + c.Environment
+}
+
+// SearchAlias returns the OpenSearch alias of the dataset index.
+func (c *Config) SearchAlias() string {
+	return c.BucketPrefix() + "-datasets"
+}
+
+// localEndpoint reports whether endpoint is an emulator on this machine:
+// a loopback address, localhost, or LocalStack's
+// localhost.localstack.cloud, which resolves to it.
+func localEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || host == "localhost.localstack.cloud" {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
+
+// Issuer returns the OpenID issuer to sign in to: OIDCIssuer if set,
+// otherwise the Cognito user pool, or "" if neither is configured.
+func (c *Config) Issuer() string {
+	if c.OIDCIssuer != "" {
+		return c.OIDCIssuer
+	}
+	if c.CognitoUserPoolID != "" {
+		return "https://cognito-idp." + c.AWSRegion + ".amazonaws.com/" + c.CognitoUserPoolID
+	}
+	return ""
+}
+
+// IsAdmin reports whether user is listed in Admins.
+func (c *Config) IsAdmin(user string) bool {
+	for _, a := range c.Admins {
+		if strings.EqualFold(a, user) {
+			return true
+		}
+	}
+	return false
+}
+
+// env looks up environment variables, returning "" for unset ones.
+type env func(key string) string
+
 // getEnv retrieves an environment variable or returns a default value.
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+func (e env) getEnv(key, defaultValue string) string {
+	if value := e(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+// getEnvList retrieves a comma-separated environment variable as a
+// list, dropping empty elements.
+func (e env) getEnvList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(e(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// getEnvMultiMap retrieves a comma-separated list of key=value pairs,
+// collecting repeated keys, or nil if the variable is unset.
+func (e env) getEnvMultiMap(key string) (map[string][]string, error) {
+	list := e.getEnvList(key)
+	if len(list) == 0 {
+		return nil, nil
+	}
+	m := make(map[string][]string)
+	for _, kv := range list {
+		k, v, ok := strings.Cut(kv, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("invalid %s entry %q, want key=value", key, kv)
+		}
+		m[k] = append(m[k], v)
+	}
+	return m, nil
+}
+
+// getEnvMap retrieves a comma-separated list of key=value pairs, or
+// nil if the variable is unset.
+func (e env) getEnvMap(key string) (map[string]string, error) {
+	multi, err := e.getEnvMultiMap(key)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]string
+	for k, vs := range multi {
+		if len(vs) > 1 {
+			return nil, fmt.Errorf("duplicate %s key %q", key, k)
+		}
+		if m == nil {
+			m = make(map[string]string)
+		}
+		m[k] = vs[0]
+	}
+	return m, nil
+}
+
+// validPIDScheme reports whether s names a persistent identifier
+// scheme.
+func validPIDScheme(s string) bool {
+	return s == "doi" || s == "ark" || s == "handle"
+}
+
+// defaultStateDir returns ~/.aperture, or .aperture in the working
+// directory when the home directory is unknown.
+func defaultStateDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".aperture"
+	}
+	return filepath.Join(home, ".aperture")
+}
+
+// defaultMetricsTarget returns stderr in Lambda, whose log streams
+// CloudWatch reads metrics from, and "" (off) elsewhere.
+func defaultMetricsTarget() string {
+	if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
+		return "stderr"
+	}
+	return ""
+}
+
+// traceEndpoint returns the OTLP traces URL from the standard
+// OpenTelemetry variables, or "" if neither is set.
+func (e env) traceEndpoint() string {
+	if u := e("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); u != "" {
+		return u
+	}
+	if u := e("OTEL_EXPORTER_OTLP_ENDPOINT"); u != "" {
+		return strings.TrimRight(u, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// getEnvInt retrieves an integer environment variable or returns a
+// default value.
+func (e env) getEnvInt(key string, defaultValue int) (int, error) {
+	value := e(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return n, nil
+}
+
+// getEnvFloat retrieves a floating-point environment variable or
+// returns a default value.
+func (e env) getEnvFloat(key string, defaultValue float64) (float64, error) {
+	value := e(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return f, nil
+}
+
+// getEnvBool retrieves a boolean environment variable or returns a
+// default value.
+func (e env) getEnvBool(key string, defaultValue bool) (bool, error) {
+	value := e(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return b, nil
+}
@@ -16,6 +16,9 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -33,28 +36,82 @@ func TestLoad(t *testing.T) {
 				"AWS_REGION":   "us-east-1",
 			},
 			want: &Config{
-				Environment: "dev",
-				AWSRegion:   "us-east-1",
-				ProjectName: "aperture",
+				Environment:   "dev",
+				AWSRegion:     "us-east-1",
+				ProjectName:   "aperture",
+				StorageLayout: "purpose",
 			},
 			wantErr: false,
 		},
 		{
 			name: "custom values",
 			envVars: map[string]string{
-				"APERTURE_ENV":          "prod",
-				"AWS_REGION":            "us-west-2",
-				"DATACITE_PREFIX":       "10.5555",
-				"APERTURE_PROJECT_NAME": "custom-aperture",
+				"APERTURE_ENV":                "prod",
+				"AWS_REGION":                  "us-west-2",
+				"DATACITE_PREFIX":             "10.5555",
+				"APERTURE_PROJECT_NAME":       "custom-aperture",
+				"APERTURE_STORAGE_LAYOUT":     "hashed",
+				"APERTURE_AFFILIATION_GROUPS": "faculty=researchers, faculty=curators,student=users",
+				"APERTURE_PID_SCHEMES":        "archives=ark, theses=doi",
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318/",
 			},
 			want: &Config{
 				Environment:    "prod",
 				AWSRegion:      "us-west-2",
 				DataCitePrefix: "10.5555",
 				ProjectName:    "custom-aperture",
+				StorageLayout:  "hashed",
+				AffiliationGroups: map[string][]string{
+					"faculty": {"researchers", "curators"},
+					"student": {"users"},
+				},
+				PIDSchemes:    map[string]string{"archives": "ark", "theses": "doi"},
+				TraceEndpoint: "http://localhost:4318/v1/traces",
 			},
 			wantErr: false,
 		},
+		{
+			name: "invalid affiliation groups",
+			envVars: map[string]string{
+				"APERTURE_AFFILIATION_GROUPS": "faculty=researchers,student",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid identifier scheme",
+			envVars: map[string]string{
+				"APERTURE_PID_SCHEMES": "archives=urn",
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate identifier scheme",
+			envVars: map[string]string{
+				"APERTURE_PID_SCHEMES": "archives=ark,archives=doi",
+			},
+			wantErr: true,
+		},
+		{
+			name: "tier bucket prefix of the primary buckets",
+			envVars: map[string]string{
+				"APERTURE_TIER_BUCKET_PREFIX": "aperture-dev",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid path style",
+			envVars: map[string]string{
+				"APERTURE_STORAGE_PATH_STYLE": "sometimes",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid rate limit",
+			envVars: map[string]string{
+				"DATACITE_RATE_LIMIT": "fast",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -90,6 +147,18 @@ func TestLoad(t *testing.T) {
 				if got.ProjectName != tt.want.ProjectName {
 					t.Errorf("Load() ProjectName = %v, want %v", got.ProjectName, tt.want.ProjectName)
 				}
+				if got.StorageLayout != tt.want.StorageLayout {
+					t.Errorf("Load() StorageLayout = %v, want %v", got.StorageLayout, tt.want.StorageLayout)
+				}
+				if !reflect.DeepEqual(got.AffiliationGroups, tt.want.AffiliationGroups) {
+					t.Errorf("Load() AffiliationGroups = %v, want %v", got.AffiliationGroups, tt.want.AffiliationGroups)
+				}
+				if !reflect.DeepEqual(got.PIDSchemes, tt.want.PIDSchemes) {
+					t.Errorf("Load() PIDSchemes = %v, want %v", got.PIDSchemes, tt.want.PIDSchemes)
+				}
+				if got.TraceEndpoint != tt.want.TraceEndpoint {
+					t.Errorf("Load() TraceEndpoint = %v, want %v", got.TraceEndpoint, tt.want.TraceEndpoint)
+				}
 			}
 		})
 	}
@@ -125,6 +194,164 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "negative DataCite rate limit",
+			config: &Config{
+				Environment:       "dev",
+				AWSRegion:         "us-east-1",
+				DataCiteRateLimit: -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown deployment backend",
+			config: &Config{
+				Environment:   "dev",
+				AWSRegion:     "us-east-1",
+				DeployBackend: "pulumi",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown deployment layer",
+			config: &Config{
+				Environment: "dev",
+				AWSRegion:   "us-east-1",
+				DeployRoles: map[string]string{"network": "arn:aws:iam::111111111111:role/deploy"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "deployment roles with CloudFormation",
+			config: &Config{
+				Environment:   "dev",
+				AWSRegion:     "us-east-1",
+				DeployBackend: "cloudformation",
+				DeployRoles:   map[string]string{"storage": "arn:aws:iam::111111111111:role/deploy"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown output registry",
+			config: &Config{
+				Environment:    "dev",
+				AWSRegion:      "us-east-1",
+				OutputRegistry: "consul",
+			},
+			wantErr: true,
+		},
+		{
+			name: "decreasing traffic steps",
+			config: &Config{
+				Environment:  "dev",
+				AWSRegion:    "us-east-1",
+				TrafficSteps: []int{50, 10},
+			},
+			wantErr: true,
+		},
+		{
+			name: "traffic step of all traffic",
+			config: &Config{
+				Environment:  "dev",
+				AWSRegion:    "us-east-1",
+				TrafficSteps: []int{10, 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative projected usage",
+			config: &Config{
+				Environment:       "dev",
+				AWSRegion:         "us-east-1",
+				EstimateStorageGB: -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown storage backend",
+			config: &Config{
+				Environment:    "dev",
+				AWSRegion:      "us-east-1",
+				StorageBackend: "gcs",
+			},
+			wantErr: true,
+		},
+		{
+			name: "azure storage backend without an account key",
+			config: &Config{
+				Environment:         "dev",
+				AWSRegion:           "us-east-1",
+				StorageBackend:      "azure",
+				AzureStorageAccount: "uniresearch",
+			},
+			wantErr: true,
+		},
+		{
+			name: "filesystem storage backend without a URL secret",
+			config: &Config{
+				Environment:    "dev",
+				AWSRegion:      "us-east-1",
+				StorageBackend: "filesystem",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown queue backend",
+			config: &Config{
+				Environment:  "dev",
+				AWSRegion:    "us-east-1",
+				QueueBackend: "nats",
+			},
+			wantErr: true,
+		},
+		{
+			name: "development identity against AWS",
+			config: &Config{
+				Environment: "dev",
+				AWSRegion:   "us-east-1",
+				DevIdentity: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "development identity against an emulator",
+			config: &Config{
+				Environment: "dev",
+				AWSRegion:   "us-east-1",
+				AWSEndpoint: "http://localhost:4566",
+				DevIdentity: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "development identity against a loopback address",
+			config: &Config{
+				Environment: "dev",
+				AWSRegion:   "us-east-1",
+				AWSEndpoint: "http://127.0.0.1:4566",
+				DevIdentity: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "development identity against a remote endpoint",
+			config: &Config{
+				Environment: "dev",
+				AWSRegion:   "us-east-1",
+				AWSEndpoint: "https://s3.us-east-1.amazonaws.com",
+				DevIdentity: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown state backend",
+			config: &Config{
+				Environment:  "dev",
+				AWSRegion:    "us-east-1",
+				StateBackend: "redis",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -136,3 +363,108 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestBucketPrefix(t *testing.T) {
+	cfg := &Config{Environment: "staging", ProjectName: "aperture"}
+	if got := cfg.BucketPrefix(); got != "aperture-staging" {
+		t.Errorf("BucketPrefix() = %v, want %v", got, "aperture-staging")
+	}
+	if got := cfg.StateTable(); got != "aperture-state-staging" {
+		t.Errorf("StateTable() = %v, want %v", got, "aperture-state-staging")
+	}
+}
+
+func TestApplyOutputs(t *testing.T) {
+	cfg := &Config{Environment: "prod", APIURL: "https://api.uni.edu/v1"}
+	used := cfg.ApplyOutputs(map[string]string{
+		"api_gateway_invoke_url":      "https://abc.execute-api.us-east-1.amazonaws.com/v1",
+		"cloudfront_public_media_url": "https://d2.cloudfront.net",
+		"cognito_user_pool_id":        "us-east-1_abc",
+		"s3_logs_bucket":              "aperture-prod-logs",
+	})
+	// The configured API URL is kept.
+	if cfg.APIURL != "https://api.uni.edu/v1" || cfg.MediaURL != "https://d2.cloudfront.net" || cfg.CognitoUserPoolID != "us-east-1_abc" {
+		t.Errorf("ApplyOutputs() config = %+v", cfg)
+	}
+	if want := []string{"cloudfront_public_media_url", "cognito_user_pool_id"}; !reflect.DeepEqual(used, want) {
+		t.Errorf("ApplyOutputs() = %v, want %v", used, want)
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	os.Setenv("APERTURE_ADMINS", "root@uni.edu, Ops@Uni.edu,")
+	defer os.Unsetenv("APERTURE_ADMINS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.Admins) != 2 {
+		t.Fatalf("Load() Admins = %v, want 2 entries", cfg.Admins)
+	}
+	if !cfg.IsAdmin("ops@uni.edu") {
+		t.Error("IsAdmin(ops@uni.edu) = false, want true")
+	}
+	if cfg.IsAdmin("jane@uni.edu") {
+		t.Error("IsAdmin(jane@uni.edu) = true, want false")
+	}
+}
+
+func TestIssuer(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{}, ""},
+		{Config{AWSRegion: "us-west-2", CognitoUserPoolID: "us-west-2_abc"}, "https://cognito-idp.us-west-2.amazonaws.com/us-west-2_abc"},
+		{Config{CognitoUserPoolID: "us-west-2_abc", OIDCIssuer: "https://login.uni.edu"}, "https://login.uni.edu"},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.Issuer(); got != tt.want {
+			t.Errorf("Issuer() = %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func TestLoadEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "prod.env"), []byte(`# production
+APERTURE_ENV=ignored
+export AWS_REGION=us-west-2
+DATACITE_PASSWORD="hunter2"
+
+APERTURE_PID_SCHEMES=archives=ark
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("DATACITE_PREFIX", "10.5555")
+	defer os.Unsetenv("DATACITE_PREFIX")
+
+	cfg := &Config{EnvironmentsDir: dir}
+	prod, err := cfg.LoadEnvironment("prod")
+	if err != nil {
+		t.Fatalf("LoadEnvironment() error = %v", err)
+	}
+	if prod.Environment != "prod" || prod.AWSRegion != "us-west-2" || prod.DataCitePassword != "hunter2" ||
+		prod.DataCitePrefix != "10.5555" || prod.PIDSchemes["archives"] != "ark" {
+		t.Errorf("LoadEnvironment() = %+v", prod)
+	}
+	if _, err := cfg.LoadEnvironment("staging"); err == nil || !strings.Contains(err.Error(), "staging.env") {
+		t.Errorf("LoadEnvironment() of an unconfigured environment error = %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := &Config{Environment: "staging", AWSRegion: "us-west-2", DataCitePassword: "a", GitHubToken: "t"}
+	b := &Config{Environment: "prod", AWSRegion: "us-west-2", DataCitePassword: "b", Admins: []string{"ops@uni.edu"}}
+	want := []Difference{
+		{Setting: "Environment", From: "staging", To: "prod"},
+		{Setting: "DataCitePassword", From: "(set)", To: "(set)"},
+		{Setting: "GitHubToken", From: "(set)", To: "(unset)"},
+		{Setting: "Admins", From: "[]", To: "[ops@uni.edu]"},
+	}
+	if got := Diff(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %+v, want %+v", got, want)
+	}
+}
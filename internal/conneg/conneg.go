@@ -0,0 +1,270 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conneg serves landing pages with content negotiation.
+//
+// DOI resolvers and reference managers ask a landing URL for metadata
+// rather than HTML by sending an Accept header, as they do when
+// resolving a DOI through doi.org. The handler answers with the landing
+// page, DataCite JSON, schema.org JSON-LD, or a formatted citation,
+// whichever the client prefers.
+package conneg
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/pid"
+)
+
+// Media types served.
+const (
+	TypeHTML         = "text/html"
+	TypeDataCite     = "application/vnd.datacite.datacite+json"
+	TypeJSONLD       = "application/ld+json"
+	TypeBibliography = "text/x-bibliography"
+)
+
+// Options configures a Handler.
+type Options struct {
+	// SiteURL is the public base URL of landing pages
+	SiteURL string
+
+	// Publisher is the institution publishing the datasets
+	Publisher string
+
+	// DownloadURL is the base URL of counted download links; files are
+	// not linked if empty
+	DownloadURL string
+}
+
+// Handler serves GET /datasets/{id}/ and the page's linkset.
+type Handler struct {
+	datasets *dataset.Store
+	renderer *landing.Renderer
+	opts     Options
+	mux      *http.ServeMux
+
+	// Funding supplies datasets' funding references; skipped if nil
+	Funding pid.FundingSource
+
+	// Stats supplies the usage counters shown on pages; skipped if nil
+	Stats landing.StatsSource
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// NewHandler returns a handler serving the published datasets in
+// datasets, rendering pages with renderer.
+func NewHandler(datasets *dataset.Store, renderer *landing.Renderer, opts Options) *Handler {
+	h := &Handler{datasets: datasets, renderer: renderer, opts: opts, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /datasets/{id}/{$}", h.servePage)
+	h.mux.HandleFunc("GET /datasets/{id}/linkset.json", h.serveLinkset)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// offers returns the media types d can be served in, in order of
+// preference. DataCite JSON describes DOIs only.
+func offers(d *dataset.Dataset) []string {
+	if d.DOI == "" {
+		return []string{TypeHTML, TypeJSONLD, TypeBibliography}
+	}
+	return []string{TypeHTML, TypeDataCite, TypeJSONLD, TypeBibliography}
+}
+
+func (h *Handler) servePage(w http.ResponseWriter, r *http.Request) {
+	d, ok := h.dataset(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Vary", "Accept")
+	w.Header().Set("Link", landing.LinksetHeader)
+	typ, params := Negotiate(r.Header.Get("Accept"), offers(d))
+	if typ == TypeBibliography && !styleSupported(params["style"]) {
+		typ = ""
+	}
+
+	var body []byte
+	var err error
+	switch typ {
+	case TypeHTML:
+		body, err = h.page(r, d)
+		typ += "; charset=utf-8"
+	case TypeDataCite:
+		body, err = h.dataCite(r, d)
+	case TypeJSONLD:
+		body, err = h.jsonLD(d)
+	case TypeBibliography:
+		body = []byte(h.citation(d) + "\n")
+		typ += "; charset=utf-8"
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusNotAcceptable)
+		_, _ = w.Write([]byte("available as " + strings.Join(offers(d), ", ") + "; citations in the apa style only\n"))
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", typ)
+	_, _ = w.Write(body)
+}
+
+func (h *Handler) serveLinkset(w http.ResponseWriter, r *http.Request) {
+	d, ok := h.dataset(w, r)
+	if !ok {
+		return
+	}
+	downloads := landing.DownloadLinks(h.opts.DownloadURL, d, h.now())
+	body, err := landing.Linkset(h.pageURL(d), landing.Signposts(d, downloads))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", landing.LinksetType)
+	_, _ = w.Write(body)
+}
+
+// dataset returns the dataset named in r, writing an error response if
+// it is not published: 410 for tombstoned datasets, 404 otherwise.
+func (h *Handler) dataset(w http.ResponseWriter, r *http.Request) (*dataset.Dataset, bool) {
+	d, err := h.datasets.Get(r.Context(), r.PathValue("id"))
+	switch {
+	case errors.Is(err, dataset.ErrNotFound):
+		http.NotFound(w, r)
+		return nil, false
+	case err != nil:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	case d.State == dataset.StateTombstoned:
+		http.Error(w, d.ID+" has been withdrawn", http.StatusGone)
+		return nil, false
+	case d.State != dataset.StatePublished:
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return d, true
+}
+
+// page renders d's landing page.
+func (h *Handler) page(r *http.Request, d *dataset.Dataset) ([]byte, error) {
+	var stats map[string]int64
+	if h.Stats != nil {
+		var err error
+		if stats, err = h.Stats.Stats(r.Context(), d.ID); err != nil {
+			return nil, err
+		}
+	}
+	downloads := landing.DownloadLinks(h.opts.DownloadURL, d, h.now())
+	return h.renderer.Render(landing.Page{Dataset: d, Version: d.Current(), Stats: stats, Downloads: downloads, Signposts: landing.Signposts(d, downloads), Versions: landing.PublishedVersions(d)})
+}
+
+// pageURL returns the URL of d's landing page.
+func (h *Handler) pageURL(d *dataset.Dataset) string {
+	return strings.TrimRight(h.opts.SiteURL, "/") + landing.PagePath(d.ID)
+}
+
+func (h *Handler) now() time.Time {
+	if h.Now != nil {
+		return h.Now()
+	}
+	return time.Now()
+}
+
+// mediaRange is one entry of an Accept header.
+type mediaRange struct {
+	typ    string
+	params map[string]string
+	q      float64
+}
+
+// matches reports whether the range matches typ, and how specifically:
+// 3 for an exact match, 2 for type/*, 1 for */*, and 0 for no match.
+func (m mediaRange) matches(typ string) int {
+	major, _, _ := strings.Cut(typ, "/")
+	switch m.typ {
+	case typ:
+		return 3
+	case major + "/*":
+		return 2
+	case "*/*":
+		return 1
+	}
+	return 0
+}
+
+// Negotiate returns the offer the Accept header prefers and the
+// parameters of the media range that selected it, or "" if no offer is
+// acceptable. An offer's quality is that of the most specific range
+// matching it; ties go to the earlier offer. An empty header accepts
+// the first offer.
+func Negotiate(accept string, offers []string) (string, map[string]string) {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0], nil
+	}
+	ranges := parseAccept(accept)
+	best, bestQ := -1, 0.0
+	var bestParams map[string]string
+	for i, offer := range offers {
+		specificity, q := 0, 0.0
+		var params map[string]string
+		for _, m := range ranges {
+			if s := m.matches(offer); s > specificity {
+				specificity, q, params = s, m.q, m.params
+			}
+		}
+		if q > bestQ {
+			best, bestQ, bestParams = i, q, params
+		}
+	}
+	if best < 0 {
+		return "", nil
+	}
+	return offers[best], bestParams
+}
+
+// parseAccept parses an Accept header, skipping malformed ranges.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		m := mediaRange{typ: typ, params: params, q: 1}
+		if v, ok := params["q"]; ok {
+			q, err := strconv.ParseFloat(v, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+			m.q = q
+			delete(params, "q")
+		}
+		ranges = append(ranges, m)
+	}
+	return ranges
+}
@@ -0,0 +1,175 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conneg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+type fakeFunding struct{}
+
+func (fakeFunding) Funding(_ context.Context, d *dataset.Dataset) ([]pid.Funding, error) {
+	return []pid.Funding{{FunderName: "NSF", FunderROR: "https://ror.org/021nxhr62", AwardNumber: "DEB-2145678"}}, nil
+}
+
+func newHandler(t *testing.T) *Handler {
+	t.Helper()
+	ctx := context.Background()
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	published := time.Date(2025, 4, 2, 9, 0, 0, 0, time.UTC)
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", DOI: "10.1234/abc", Title: "Soil cores", State: dataset.StatePublished, Access: storage.AccessPublic, PublicationYear: 2025,
+			Creators: []dataset.Creator{
+				{Name: "Lovelace, Ada Augusta", ORCID: "0000-0002-1825-0097", Affiliation: "University of Oxford", AffiliationROR: "052gg0110"},
+				{Name: "Hopper, Grace"},
+			},
+			Awards:   []string{"021nxhr62-deb-2145678"},
+			Versions: []dataset.Version{{Number: 2, PublishedAt: &published, Files: []dataset.File{{Path: "cores.csv", Size: 2048, ContentType: "text/csv"}}}}},
+		{ID: "ds-2", ARK: "ark:/99999/fk4xyz", Title: "Letters.", State: dataset.StatePublished, Creators: []dataset.Creator{{Name: "Example Lab"}}},
+		{ID: "ds-3", DOI: "10.1234/gone", Title: "Withdrawn", State: dataset.StateTombstoned},
+		{ID: "ds-4", Title: "Draft", State: dataset.StateDraft},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	renderer, err := landing.NewRenderer(landing.DefaultTemplates())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(datasets, renderer, Options{SiteURL: "https://data.uni.edu/", Publisher: "Example University", DownloadURL: "https://dl.uni.edu"})
+	h.Funding = fakeFunding{}
+	return h
+}
+
+func get(h *Handler, path, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{TypeHTML, TypeDataCite, TypeJSONLD, TypeBibliography}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", TypeHTML},
+		{"*/*", TypeHTML},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", TypeHTML},
+		{"application/vnd.datacite.datacite+json", TypeDataCite},
+		{"application/ld+json;q=0.5, application/vnd.datacite.datacite+json;q=0.9", TypeDataCite},
+		{"application/*", TypeDataCite},
+		{"text/x-bibliography; style=apa", TypeBibliography},
+		{"text/*;q=0.5, text/html;q=0.1", TypeBibliography},
+		{"*/*;q=0.1, text/html;q=0", TypeDataCite},
+		{"application/pdf", ""},
+		{"bogus;;", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := Negotiate(tt.accept, offers); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestServePage(t *testing.T) {
+	h := newHandler(t)
+
+	rec := get(h, "/datasets/ds-1/", "text/html")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") || !strings.Contains(rec.Body.String(), "<h1>Soil cores</h1>") {
+		t.Errorf("HTML page = %d %s:\n%s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	if rec.Header().Get("Vary") != "Accept" || rec.Header().Get("Link") != landing.LinksetHeader {
+		t.Errorf("HTML page headers = %v", rec.Header())
+	}
+
+	rec = get(h, "/datasets/ds-1/", TypeDataCite)
+	var doc map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil || rec.Header().Get("Content-Type") != TypeDataCite {
+		t.Fatalf("DataCite JSON = %s, %v:\n%s", rec.Header().Get("Content-Type"), err, rec.Body)
+	}
+	if doc["id"] != "https://doi.org/10.1234/abc" || doc["doi"] != "10.1234/abc" || doc["url"] != "https://data.uni.edu/datasets/ds-1/" || doc["version"] != "2" || doc["fundingReferences"] == nil {
+		t.Errorf("DataCite JSON = %v", doc)
+	}
+
+	rec = get(h, "/datasets/ds-1/", TypeJSONLD)
+	var ld thing
+	if err := json.Unmarshal(rec.Body.Bytes(), &ld); err != nil {
+		t.Fatalf("JSON-LD: %v:\n%s", err, rec.Body)
+	}
+	if ld.Type != "Dataset" || ld.ID != "https://doi.org/10.1234/abc" || ld.DatePublished != "2025-04-02" || len(ld.Creator) != 2 || ld.Creator[0].Affiliation.ID != "https://ror.org/052gg0110" {
+		t.Errorf("JSON-LD = %+v", ld)
+	}
+	if len(ld.Distribution) != 1 || ld.Distribution[0].ContentURL != "https://dl.uni.edu/d/ds-1/v2/cores.csv" || ld.Distribution[0].EncodingFormat != "text/csv" {
+		t.Errorf("JSON-LD distribution = %+v", ld.Distribution)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/datasets/ds-1/", "Lovelace, A. A., & Hopper, G. (2025). Soil cores (Version 2) [Data set]. Example University. https://doi.org/10.1234/abc\n"},
+		{"/datasets/ds-2/", "Example Lab. (n.d.). Letters [Data set]. Example University. https://n2t.net/ark:/99999/fk4xyz\n"},
+	}
+	for _, tt := range tests {
+		rec := get(h, tt.path, "text/x-bibliography")
+		if rec.Body.String() != tt.want || rec.Header().Get("Content-Type") != "text/x-bibliography; charset=utf-8" {
+			t.Errorf("%s citation = %q, want %q", tt.path, rec.Body, tt.want)
+		}
+	}
+}
+
+func TestServePageErrors(t *testing.T) {
+	h := newHandler(t)
+	tests := []struct {
+		path   string
+		accept string
+		want   int
+	}{
+		{"/datasets/ds-3/", "", http.StatusGone},
+		{"/datasets/ds-4/", "", http.StatusNotFound},
+		{"/datasets/ds-9/", "", http.StatusNotFound},
+		{"/datasets/ds-1/", "application/pdf", http.StatusNotAcceptable},
+		{"/datasets/ds-1/", "text/x-bibliography; style=ieee", http.StatusNotAcceptable},
+		{"/datasets/ds-2/", TypeDataCite, http.StatusNotAcceptable},
+	}
+	for _, tt := range tests {
+		if rec := get(h, tt.path, tt.accept); rec.Code != tt.want {
+			t.Errorf("GET %s (Accept %q) = %d, want %d", tt.path, tt.accept, rec.Code, tt.want)
+		}
+	}
+
+	rec := get(h, "/datasets/ds-1/linkset.json", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != landing.LinksetType || !strings.Contains(rec.Body.String(), `"anchor": "https://data.uni.edu/datasets/ds-1/"`) {
+		t.Errorf("linkset = %d:\n%s", rec.Code, rec.Body)
+	}
+}
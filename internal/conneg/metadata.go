@@ -0,0 +1,262 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conneg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/pid"
+)
+
+// dataciteSchema is the schema version of DataCite JSON documents.
+const dataciteSchema = "http://datacite.org/schema/kernel-4"
+
+// maxCitedAuthors is the number of authors APA lists before eliding.
+const maxCitedAuthors = 20
+
+// dataCiteDocument is a DOI's DataCite JSON, as returned by DataCite
+// content negotiation.
+type dataCiteDocument struct {
+	ID string `json:"id"`
+	datacite.Attributes
+	SchemaVersion string `json:"schemaVersion"`
+}
+
+// dataCite returns d's DataCite JSON: the metadata registered with its
+// DOI.
+func (h *Handler) dataCite(r *http.Request, d *dataset.Dataset) ([]byte, error) {
+	registrar := &pid.Registrar{SiteURL: h.opts.SiteURL, Publisher: h.opts.Publisher, Funding: h.Funding}
+	rec, err := registrar.Record(r.Context(), d)
+	if err != nil {
+		return nil, err
+	}
+	attrs := pid.Attributes(rec)
+	attrs.DOI = d.DOI
+	if v := d.Current(); v != nil {
+		attrs.Version = strconv.Itoa(v.Number)
+	}
+	return json.MarshalIndent(dataCiteDocument{ID: "https://doi.org/" + d.DOI, Attributes: attrs, SchemaVersion: dataciteSchema}, "", "  ")
+}
+
+// thing is a schema.org Dataset or SoftwareSourceCode.
+type thing struct {
+	Context        string         `json:"@context"`
+	Type           string         `json:"@type"`
+	ID             string         `json:"@id"`
+	URL            string         `json:"url"`
+	Identifier     string         `json:"identifier,omitempty"`
+	Name           string         `json:"name"`
+	Description    string         `json:"description,omitempty"`
+	Creator        []person       `json:"creator,omitempty"`
+	Publisher      *organization  `json:"publisher,omitempty"`
+	DatePublished  string         `json:"datePublished,omitempty"`
+	Version        string         `json:"version,omitempty"`
+	License        string         `json:"license,omitempty"`
+	CodeRepository string         `json:"codeRepository,omitempty"`
+	Distribution   []dataDownload `json:"distribution,omitempty"`
+}
+
+type person struct {
+	Type        string        `json:"@type"`
+	ID          string        `json:"@id,omitempty"`
+	Name        string        `json:"name"`
+	Affiliation *organization `json:"affiliation,omitempty"`
+}
+
+type organization struct {
+	Type string `json:"@type"`
+	ID   string `json:"@id,omitempty"`
+	Name string `json:"name"`
+}
+
+type dataDownload struct {
+	Type           string `json:"@type"`
+	Name           string `json:"name"`
+	ContentURL     string `json:"contentUrl"`
+	EncodingFormat string `json:"encodingFormat,omitempty"`
+	ContentSize    string `json:"contentSize,omitempty"`
+}
+
+// jsonLD returns d's schema.org JSON-LD, as embedded by search engines'
+// dataset indexes.
+func (h *Handler) jsonLD(d *dataset.Dataset) ([]byte, error) {
+	t := thing{
+		Context:     "https://schema.org",
+		Type:        "Dataset",
+		URL:         h.pageURL(d),
+		Identifier:  identifierURL(d),
+		Name:        d.Title,
+		Description: d.Description,
+	}
+	t.ID = t.Identifier
+	if t.ID == "" {
+		t.ID = t.URL
+	}
+	if d.Software != nil {
+		t.Type = "SoftwareSourceCode"
+		t.CodeRepository = d.Software.Repository
+		if d.Software.License != "" {
+			t.License = "https://spdx.org/licenses/" + d.Software.License
+		}
+	}
+	for _, c := range d.Creators {
+		p := person{Type: "Person", Name: c.Name}
+		if c.ORCID != "" {
+			p.ID = "https://orcid.org/" + strings.TrimPrefix(c.ORCID, "https://orcid.org/")
+		}
+		if c.Affiliation != "" {
+			p.Affiliation = &organization{Type: "Organization", Name: c.Affiliation}
+			if c.AffiliationROR != "" {
+				p.Affiliation.ID = "https://ror.org/" + strings.TrimPrefix(c.AffiliationROR, "https://ror.org/")
+			}
+		}
+		t.Creator = append(t.Creator, p)
+	}
+	if h.opts.Publisher != "" {
+		t.Publisher = &organization{Type: "Organization", Name: h.opts.Publisher}
+	}
+	if d.PublicationYear != 0 {
+		t.DatePublished = strconv.Itoa(d.PublicationYear)
+	}
+	if v := d.Current(); v != nil {
+		t.Version = strconv.Itoa(v.Number)
+		if v.PublishedAt != nil {
+			t.DatePublished = v.PublishedAt.UTC().Format("2006-01-02")
+		}
+		downloads := landing.DownloadLinks(h.opts.DownloadURL, d, h.now())
+		for _, f := range v.Files {
+			if href, ok := downloads[f.Path]; ok {
+				t.Distribution = append(t.Distribution, dataDownload{
+					Type:           "DataDownload",
+					Name:           f.Path,
+					ContentURL:     href,
+					EncodingFormat: f.ContentType,
+					ContentSize:    fmt.Sprintf("%d B", f.Size),
+				})
+			}
+		}
+	}
+	return json.MarshalIndent(t, "", "  ")
+}
+
+// styleSupported reports whether citations can be formatted in style.
+// Only APA is produced.
+func styleSupported(style string) bool {
+	return style == "" || strings.EqualFold(style, "apa")
+}
+
+// citation formats d's citation in APA style, e.g.
+//
+//	Lovelace, A., & Hopper, G. (2025). Soil cores (Version 2) [Data set].
+//	Example University. https://doi.org/10.1234/abc
+func (h *Handler) citation(d *dataset.Dataset) string {
+	var b strings.Builder
+	if authors := citedAuthors(d.Creators); authors != "" {
+		if !strings.HasSuffix(authors, ".") {
+			authors += "."
+		}
+		b.WriteString(authors + " ")
+	}
+	if d.PublicationYear != 0 {
+		fmt.Fprintf(&b, "(%d). ", d.PublicationYear)
+	} else {
+		b.WriteString("(n.d.). ")
+	}
+	b.WriteString(strings.TrimRight(d.Title, "."))
+	if v := d.Current(); v != nil {
+		fmt.Fprintf(&b, " (Version %d)", v.Number)
+	}
+	if d.Software != nil {
+		b.WriteString(" [Computer software].")
+	} else {
+		b.WriteString(" [Data set].")
+	}
+	if h.opts.Publisher != "" {
+		b.WriteString(" " + strings.TrimRight(h.opts.Publisher, ".") + ".")
+	}
+	if u := identifierURL(d); u != "" {
+		b.WriteString(" " + u)
+	} else {
+		b.WriteString(" " + h.pageURL(d))
+	}
+	return b.String()
+}
+
+// citedAuthors lists creators as APA does: up to 20 by family name and
+// initials, the last after an ampersand, and beyond 20 the first 19, an
+// ellipsis, and the last.
+func citedAuthors(creators []dataset.Creator) string {
+	names := make([]string, 0, len(creators))
+	for _, c := range creators {
+		names = append(names, citedName(c.Name))
+	}
+	switch n := len(names); {
+	case n == 0:
+		return ""
+	case n == 1:
+		return names[0]
+	case n > maxCitedAuthors:
+		return strings.Join(names[:maxCitedAuthors-1], ", ") + ", . . . " + names[n-1]
+	default:
+		return strings.Join(names[:n-1], ", ") + ", & " + names[n-1]
+	}
+}
+
+// citedName abbreviates a "Family, Given" name to "Family, G."; other
+// names, such as organizations, are cited in full.
+func citedName(name string) string {
+	family, given, ok := strings.Cut(name, ",")
+	if !ok {
+		return strings.TrimSpace(name)
+	}
+	var initials []string
+	for _, part := range strings.Fields(given) {
+		var hyphenated []string
+		for _, p := range strings.Split(part, "-") {
+			if r := []rune(p); len(r) > 0 && unicode.IsLetter(r[0]) {
+				hyphenated = append(hyphenated, string(unicode.ToUpper(r[0]))+".")
+			}
+		}
+		if len(hyphenated) > 0 {
+			initials = append(initials, strings.Join(hyphenated, "-"))
+		}
+	}
+	if len(initials) == 0 {
+		return strings.TrimSpace(family)
+	}
+	return strings.TrimSpace(family) + ", " + strings.Join(initials, " ")
+}
+
+// identifierURL returns the resolver URL of d's persistent identifier,
+// or "" if it has none.
+func identifierURL(d *dataset.Dataset) string {
+	switch {
+	case d.DOI != "":
+		return "https://doi.org/" + d.DOI
+	case d.ARK != "":
+		return "https://n2t.net/" + d.ARK
+	case d.Handle != "":
+		return "https://hdl.handle.net/" + d.Handle
+	}
+	return ""
+}
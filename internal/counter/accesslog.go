@@ -0,0 +1,226 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package counter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Access log formats read by ParseAccessLog.
+const (
+	// FormatCloudFront is the CloudFront standard log format
+	FormatCloudFront = "cloudfront"
+
+	// FormatS3 is the S3 server access log format
+	FormatS3 = "s3"
+)
+
+// cloudFrontFields are the fields of a CloudFront standard log, used
+// when a log has no #Fields directive.
+var cloudFrontFields = []string{
+	"date", "time", "x-edge-location", "sc-bytes", "c-ip", "cs-method", "cs(Host)", "cs-uri-stem",
+	"sc-status", "cs(Referer)", "cs(User-Agent)", "cs-uri-query",
+}
+
+// Access is one request read from an access log.
+type Access struct {
+	Time      time.Time
+	ClientIP  netip.Addr
+	Method    string
+	Path      string
+	Status    int
+	Bytes     int64
+	UserAgent string
+
+	// Country is the ISO 3166 code of the client's country, if the log
+	// has it
+	Country string
+}
+
+// Succeeded reports whether a is a GET answered with content or a
+// confirmation that the client's copy is current.
+func (a *Access) Succeeded() bool {
+	return (a.Method == "GET" || a.Method == "") && (a.Status >= 200 && a.Status <= 299 || a.Status == 304)
+}
+
+// ParseAccessLog calls fn with each request in an access log of the
+// given format, which may be gzip-compressed, until fn returns an
+// error. Lines that cannot be parsed are counted and skipped, so that
+// one malformed line does not hold back a whole log.
+func ParseAccessLog(r io.Reader, format string, fn func(Access) error) (skipped int, err error) {
+	var parse func(line string) (Access, bool)
+	fields := cloudFrontFields
+	switch format {
+	case FormatCloudFront:
+		parse = func(line string) (Access, bool) { return parseCloudFront(fields, line) }
+	case FormatS3:
+		parse = parseS3
+	default:
+		return 0, fmt.Errorf("unknown access log format %q: want %s or %s", format, FormatCloudFront, FormatS3)
+	}
+
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read compressed access log: %w", err)
+		}
+		defer zr.Close()
+		br = bufio.NewReader(zr)
+	}
+	sc := bufio.NewScanner(br)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if f, ok := strings.CutPrefix(line, "#Fields:"); ok {
+				fields = strings.Fields(f)
+			}
+			continue
+		}
+		a, ok := parse(line)
+		if !ok {
+			skipped++
+			continue
+		}
+		if err := fn(a); err != nil {
+			return skipped, err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return skipped, fmt.Errorf("failed to read access log: %w", err)
+	}
+	return skipped, nil
+}
+
+// parseCloudFront parses a tab-separated CloudFront log line with the
+// given fields. The URI stem and user agent are URL-encoded. Standard
+// logs have no country unless c-country is among the fields chosen for
+// them.
+func parseCloudFront(fields []string, line string) (Access, bool) {
+	values := strings.Split(line, "\t")
+	get := func(name string) string {
+		for i, f := range fields {
+			if f == name && i < len(values) {
+				if values[i] == "-" {
+					return ""
+				}
+				return values[i]
+			}
+		}
+		return ""
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", get("date")+" "+get("time"))
+	if err != nil {
+		return Access{}, false
+	}
+	status, err := strconv.Atoi(get("sc-status"))
+	if err != nil {
+		return Access{}, false
+	}
+	ip, _ := netip.ParseAddr(get("c-ip"))
+	bytes, _ := strconv.ParseInt(get("sc-bytes"), 10, 64)
+	return Access{
+		Time:      t,
+		ClientIP:  ip,
+		Method:    get("cs-method"),
+		Path:      unescape(get("cs-uri-stem")),
+		Status:    status,
+		Bytes:     bytes,
+		UserAgent: unescape(get("cs(User-Agent)")),
+		Country:   get("c-country"),
+	}, true
+}
+
+// parseS3 parses an S3 server access log line. Fields are separated by
+// spaces, except within a bracketed time or a quoted string; the key
+// is URL-encoded.
+func parseS3(line string) (Access, bool) {
+	var tokens []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimLeft(line, " ") {
+		end := " "
+		switch line[0] {
+		case '[':
+			end = "]"
+		case '"':
+			end = "\""
+		}
+		if end != " " {
+			i := strings.Index(line[1:], end)
+			if i < 0 {
+				return Access{}, false
+			}
+			tokens = append(tokens, line[1:i+1])
+			line = line[i+2:]
+			continue
+		}
+		token, rest, _ := strings.Cut(line, " ")
+		tokens = append(tokens, token)
+		line = rest
+	}
+	// owner bucket time ip requester request-id operation key
+	// request-uri status error bytes-sent object-size total-time
+	// turnaround referer user-agent ...
+	if len(tokens) < 17 {
+		return Access{}, false
+	}
+	t, err := time.Parse("02/Jan/2006:15:04:05 -0700", tokens[2])
+	if err != nil {
+		return Access{}, false
+	}
+	status, err := strconv.Atoi(tokens[9])
+	if err != nil {
+		return Access{}, false
+	}
+	ip, _ := netip.ParseAddr(tokens[3])
+	method, _, _ := strings.Cut(tokens[8], " ")
+	bytes, _ := strconv.ParseInt(tokens[11], 10, 64)
+	key, ua := "/"+unescape(tokens[7]), tokens[16]
+	if tokens[7] == "-" {
+		key = ""
+	}
+	if ua == "-" {
+		ua = ""
+	}
+	return Access{
+		Time:      t.UTC(),
+		ClientIP:  ip,
+		Method:    method,
+		Path:      key,
+		Status:    status,
+		Bytes:     bytes,
+		UserAgent: ua,
+	}, true
+}
+
+// unescape decodes a URL-encoded log value, leaving it as it is if it
+// is not validly encoded.
+func unescape(s string) string {
+	if u, err := url.PathUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
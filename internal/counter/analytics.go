@@ -0,0 +1,133 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package counter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// statsMaxAge is how long clients and the CDN may cache statistics,
+// which change only as access logs are ingested.
+const statsMaxAge = "public, max-age=900"
+
+// Analytics reports the usage of published datasets and collections
+// from the event log.
+type Analytics struct {
+	Datasets    *dataset.Store
+	Collections *collection.Registry
+	Log         Log
+}
+
+// Dataset returns the usage of the published dataset ref, an ID or
+// persistent identifier.
+func (an *Analytics) Dataset(ctx context.Context, ref string, q StatsQuery) (*Stats, error) {
+	d, err := an.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if d.State != dataset.StatePublished {
+		return nil, fmt.Errorf("%w: %s is not published", dataset.ErrNotFound, d.ID)
+	}
+	events, err := an.Log.Events(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Summarize(events, q, func(e *Event) bool { return e.DatasetID == d.ID })
+	if err != nil {
+		return nil, err
+	}
+	s.Dataset, s.DOI = d.ID, d.DOI
+	return s, nil
+}
+
+// Collection returns the usage of the published datasets in the
+// collection id, or in the collections beneath it if it is a community.
+func (an *Analytics) Collection(ctx context.Context, id string, q StatsQuery) (*Stats, error) {
+	if _, err := an.Collections.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	members, err := an.Collections.Members(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	within := make(map[string]bool, len(members))
+	for _, d := range members {
+		if d.State == dataset.StatePublished {
+			within[d.ID] = true
+		}
+	}
+	events, err := an.Log.Events(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Summarize(events, q, func(e *Event) bool { return within[e.DatasetID] })
+	if err != nil {
+		return nil, err
+	}
+	s.Collection, s.Datasets = id, len(within)
+	return s, nil
+}
+
+// StatsHandler serves usage statistics as JSON, taking since, until,
+// and interval parameters (see ParseStatsQuery):
+//
+//	GET /stats/datasets/{ref}      the Stats of a published dataset, by ID or DOI
+//	GET /stats/collections/{id}    the Stats of a collection or community
+type StatsHandler struct {
+	analytics *Analytics
+	mux       *http.ServeMux
+}
+
+// NewStatsHandler returns a handler serving statistics from an.
+func NewStatsHandler(an *Analytics) *StatsHandler {
+	h := &StatsHandler{analytics: an, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /stats/datasets/{ref...}", h.serve(an.Dataset))
+	h.mux.HandleFunc("GET /stats/collections/{ref}", h.serve(an.Collection))
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *StatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *StatsHandler) serve(stats func(context.Context, string, StatsQuery) (*Stats, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := ParseStatsQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s, err := stats(r.Context(), r.PathValue("ref"), q)
+		if errors.Is(err, dataset.ErrNotFound) || errors.Is(err, collection.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", statsMaxAge)
+		_ = json.NewEncoder(w).Encode(s)
+	}
+}
@@ -0,0 +1,486 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package counter counts usage of datasets under the COUNTER Code of
+// Practice for Research Data.
+//
+// Landing pages link to a redirect endpoint rather than directly to the
+// CDN. The endpoint logs a download event and then redirects to the
+// file, so public data stays downloadable without signing in while
+// still being counted. Downloads made otherwise, such as from presigned
+// URLs, and views of landing pages are read from the CloudFront and S3
+// access logs by an Ingester. Downloads are requests; every request and
+// every page view is an investigation. Reports exclude robots, collapse
+// repeated clicks within 30 seconds, and count unique requests and
+// investigations once per session, where a session is one anonymized
+// client in one clock hour. Monthly reports are submitted to the
+// DataCite usage hub as SUSHI dataset reports.
+package counter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DoubleClickWindow is the interval within which repeated requests for
+// the same file from the same session count once.
+const DoubleClickWindow = 30 * time.Second
+
+// Event kinds.
+const (
+	// KindRequest is a download of a file
+	KindRequest = "request"
+
+	// KindInvestigation is a view of a dataset's landing page or
+	// metadata
+	KindInvestigation = "investigation"
+)
+
+// Event is one request for a file or view of a dataset. Kind is
+// KindRequest if empty, as in logs written before investigations were
+// counted. Bytes is the number of bytes sent, known only for events
+// read from access logs.
+type Event struct {
+	Kind      string    `json:"kind,omitempty"`
+	Time      time.Time `json:"event_time"`
+	DatasetID string    `json:"dataset_id"`
+	DOI       string    `json:"identifier,omitempty"`
+	Version   int       `json:"version"`
+	File      string    `json:"filename"`
+	Size      int64     `json:"size"`
+	Bytes     int64     `json:"bytes,omitempty"`
+	Session   string    `json:"session_id"`
+	ClientIP  string    `json:"client_ip"`
+	Country   string    `json:"country,omitempty"`
+	UserAgent string    `json:"user_agent"`
+	Robot     bool      `json:"robot,omitempty"`
+	TargetURL string    `json:"target_url"`
+}
+
+// Log stores events.
+type Log interface {
+	Append(ctx context.Context, e Event) error
+	Events(ctx context.Context) ([]Event, error)
+}
+
+// NewEvent returns an event for a request from ip with the given user
+// agent. The address is anonymized to its /24 (IPv4) or /48 (IPv6)
+// network before it is stored or hashed into the session ID.
+func NewEvent(t time.Time, ip netip.Addr, userAgent string) Event {
+	client := ""
+	if ip.IsValid() {
+		bits := 24
+		if ip.Unmap().Is6() {
+			bits = 48
+		}
+		p, _ := ip.Unmap().Prefix(bits)
+		client = p.Addr().String()
+	}
+	t = t.UTC()
+	sum := sha256.Sum256([]byte(client + "|" + userAgent + "|" + t.Format("2006-01-02T15")))
+	return Event{
+		Time:      t,
+		Session:   hex.EncodeToString(sum[:8]),
+		ClientIP:  client,
+		UserAgent: userAgent,
+		Robot:     IsRobot(userAgent),
+	}
+}
+
+// robotPattern matches common crawler and script user agents. COUNTER
+// publishes a fuller list; this covers the bulk of automated traffic.
+var robotPattern = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|curl|wget|python-requests|httpclient|java/|go-http-client|libwww|headless|scrapy|^$`)
+
+// IsRobot reports whether userAgent looks automated.
+func IsRobot(userAgent string) bool {
+	return robotPattern.MatchString(strings.TrimSpace(userAgent))
+}
+
+// Robots is an exclusion list of user agent patterns, such as the
+// COUNTER-Robots list (https://github.com/atmire/COUNTER-Robots). A nil
+// list falls back to IsRobot.
+type Robots struct {
+	patterns []*regexp.Regexp
+}
+
+// LoadRobots reads an exclusion list, as ReadRobotsList does, and
+// compiles it.
+func LoadRobots(r io.Reader) (*Robots, error) {
+	patterns, err := ReadRobotsList(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewRobots(patterns)
+}
+
+// ReadRobotsList reads the patterns of an exclusion list: either the
+// COUNTER-Robots JSON list of {"pattern": ...} objects, or one regular
+// expression per line with blank lines and lines starting with #
+// ignored.
+func ReadRobotsList(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read robots list: %w", err)
+	}
+	var patterns []string
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var entries []struct {
+			Pattern string `json:"pattern"`
+		}
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse robots list: %w", err)
+		}
+		for _, e := range entries {
+			patterns = append(patterns, e.Pattern)
+		}
+	} else {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				patterns = append(patterns, line)
+			}
+		}
+	}
+	return patterns, nil
+}
+
+// NewRobots compiles an exclusion list of patterns, which match
+// case-insensitively anywhere in a user agent.
+func NewRobots(patterns []string) (*Robots, error) {
+	robots := &Robots{}
+	for _, p := range patterns {
+		if p == "" {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid robots pattern %q: %w", p, err)
+		}
+		robots.patterns = append(robots.patterns, re)
+	}
+	return robots, nil
+}
+
+// ReadRobotsFile reads the patterns of an exclusion list from a file.
+func ReadRobotsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open robots list: %w", err)
+	}
+	defer f.Close()
+	return ReadRobotsList(f)
+}
+
+// Len returns the number of patterns in the list.
+func (r *Robots) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.patterns)
+}
+
+// Match reports whether userAgent is a robot: one IsRobot recognizes or
+// one matching a pattern in the list.
+func (r *Robots) Match(userAgent string) bool {
+	if IsRobot(userAgent) {
+		return true
+	}
+	if r == nil {
+		return false
+	}
+	for _, re := range r.patterns {
+		if re.MatchString(userAgent) {
+			return true
+		}
+	}
+	return false
+}
+
+// Classify marks the events from robots in the list, so that a list
+// updated after events were logged applies to them too.
+func (r *Robots) Classify(events []Event) {
+	for i := range events {
+		events[i].Robot = events[i].Robot || r.Match(events[i].UserAgent)
+	}
+}
+
+// IsRequest reports whether e is a download.
+func (e *Event) IsRequest() bool {
+	return e.Kind == "" || e.Kind == KindRequest
+}
+
+// Metrics are COUNTER counts for one dataset, named by their COUNTER
+// metric types.
+type Metrics struct {
+	DatasetID            string `json:"datasetId"`
+	DOI                  string `json:"doi,omitempty"`
+	TotalInvestigations  int    `json:"total-dataset-investigations"`
+	UniqueInvestigations int    `json:"unique-dataset-investigations"`
+	TotalRequests        int    `json:"total-dataset-requests"`
+	UniqueRequests       int    `json:"unique-dataset-requests"`
+}
+
+// Report computes per-dataset metrics for events in [from, to),
+// excluding robots and double clicks, ordered by dataset ID.
+func Report(events []Event, from, to time.Time) []Metrics {
+	investigated := make(map[string]bool)
+	requested := make(map[string]bool)
+	byDataset := make(map[string]*Metrics)
+	for _, e := range countable(events, from, to) {
+		m := byDataset[e.DatasetID]
+		if m == nil {
+			m = &Metrics{DatasetID: e.DatasetID}
+			byDataset[e.DatasetID] = m
+		}
+		if e.DOI != "" {
+			m.DOI = e.DOI
+		}
+		session := e.Session + "\x00" + e.DatasetID
+		m.TotalInvestigations++
+		if !investigated[session] {
+			investigated[session] = true
+			m.UniqueInvestigations++
+		}
+		if !e.IsRequest() {
+			continue
+		}
+		m.TotalRequests++
+		if !requested[session] {
+			requested[session] = true
+			m.UniqueRequests++
+		}
+	}
+
+	out := make([]Metrics, 0, len(byDataset))
+	for _, m := range byDataset {
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DatasetID < out[j].DatasetID })
+	return out
+}
+
+// countable returns the events in [from, to) that COUNTER counts, in
+// time order: those not from robots and not repeating a click on the
+// same file or page within DoubleClickWindow. A zero to is open-ended.
+func countable(events []Event, from, to time.Time) []Event {
+	events = slices.Clone(events)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+
+	lastClick := make(map[string]time.Time)
+	out := events[:0]
+	for _, e := range events {
+		if e.Robot || e.Time.Before(from) || (!to.IsZero() && !e.Time.Before(to)) {
+			continue
+		}
+		click := e.Session + "\x00" + e.DatasetID + "\x00" + e.File + "\x00" + strconv.FormatBool(e.IsRequest())
+		if last, ok := lastClick[click]; ok && e.Time.Sub(last) < DoubleClickWindow {
+			lastClick[click] = e.Time
+			continue
+		}
+		lastClick[click] = e.Time
+		out = append(out, e)
+	}
+	return out
+}
+
+// FileLog appends events as JSON Lines to a local file.
+type FileLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileLog returns a log stored at path, creating parent directories
+// as needed.
+func NewFileLog(path string) (*FileLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create download log directory: %w", err)
+	}
+	return &FileLog{path: path}, nil
+}
+
+// Append implements Log.
+func (l *FileLog) Append(_ context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open download log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write download log: %w", err)
+	}
+	return nil
+}
+
+// AppendAll appends events in one write.
+func (l *FileLog) AppendAll(_ context.Context, events []Event) error {
+	var buf []byte
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open download log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(buf); err != nil {
+		return fmt.Errorf("failed to write download log: %w", err)
+	}
+	return nil
+}
+
+// Events implements Log.
+func (l *FileLog) Events(_ context.Context) ([]Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events, _, err := l.read()
+	return events, err
+}
+
+// read returns the events in the log and the length of the file they
+// were read from.
+func (l *FileLog) read() ([]Event, int64, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open download log: %w", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	var n int64
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		n += int64(len(sc.Bytes())) + 1
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, 0, fmt.Errorf("failed to parse download log: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, n, sc.Err()
+}
+
+// Rewrite replaces the events in the log with those fn leaves in
+// place. Events another process appends while fn runs are kept after
+// the rewritten ones.
+func (l *FileLog) Rewrite(_ context.Context, fn func(events []Event)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events, n, err := l.read()
+	if err != nil {
+		return err
+	}
+	fn(events)
+
+	var buf []byte
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	if f, err := os.Open(l.path); err == nil {
+		_, err = f.Seek(n, io.SeekStart)
+		if err == nil {
+			var tail []byte
+			if tail, err = io.ReadAll(f); err == nil {
+				buf = append(buf, tail...)
+			}
+		}
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read download log: %w", err)
+		}
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o600); err != nil {
+		return fmt.Errorf("failed to write download log: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to replace download log: %w", err)
+	}
+	return nil
+}
+
+// MemoryLog keeps events in memory. It is intended for tests.
+type MemoryLog struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// Append implements Log.
+func (l *MemoryLog) Append(_ context.Context, e Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+	return nil
+}
+
+// AppendAll appends events.
+func (l *MemoryLog) AppendAll(_ context.Context, events []Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, events...)
+	return nil
+}
+
+// Rewrite replaces the events in the log with those fn leaves in
+// place.
+func (l *MemoryLog) Rewrite(_ context.Context, fn func(events []Event)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fn(l.events)
+	return nil
+}
+
+// Events implements Log.
+func (l *MemoryLog) Events(_ context.Context) ([]Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Event(nil), l.events...), nil
+}
@@ -0,0 +1,667 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package counter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/seal"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+const browser = "Mozilla/5.0 (X11; Linux x86_64) Firefox/128.0"
+
+func TestReport(t *testing.T) {
+	t0 := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	alice := netip.MustParseAddr("192.0.2.10")
+	bob := netip.MustParseAddr("198.51.100.7")
+	event := func(at time.Duration, ip netip.Addr, ua, file string) Event {
+		e := NewEvent(t0.Add(at), ip, ua)
+		e.DatasetID, e.DOI, e.File = "ds-1", "10.5555/ds-1", file
+		return e
+	}
+
+	events := []Event{
+		event(0, alice, browser, "a.csv"),
+		event(10*time.Second, alice, browser, "a.csv"), // double click
+		event(time.Minute, alice, browser, "b.csv"),    // same session
+		event(2*time.Hour, alice, browser, "a.csv"),    // new session
+		event(0, bob, browser, "a.csv"),
+		event(0, bob, "curl/8.5.0", "a.csv"), // robot
+		event(-time.Hour*24*40, bob, browser, "a.csv"),
+	}
+
+	got := Report(events, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC))
+	if len(got) != 1 {
+		t.Fatalf("Report() = %+v, want one dataset", got)
+	}
+	if got[0].TotalRequests != 4 || got[0].UniqueRequests != 3 || got[0].DOI != "10.5555/ds-1" {
+		t.Errorf("Report() = %+v, want 4 total and 3 unique requests", got[0])
+	}
+}
+
+func TestNewEventAnonymizes(t *testing.T) {
+	e := NewEvent(time.Now(), netip.MustParseAddr("192.0.2.77"), browser)
+	if e.ClientIP != "192.0.2.0" || e.Robot {
+		t.Errorf("NewEvent() = %+v", e)
+	}
+	other := NewEvent(time.Now(), netip.MustParseAddr("192.0.2.99"), browser)
+	if e.Session != other.Session {
+		t.Error("addresses in the same /24 got different sessions")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	put := func(d *dataset.Dataset) {
+		d.State = dataset.StatePublished
+		d.Versions = []dataset.Version{{Number: 1, Files: []dataset.File{{Path: "dir/a b.csv", Key: "datasets/" + d.ID + "/v1/dir/a b.csv", Size: 3}}}}
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(&dataset.Dataset{ID: "pub", DOI: "10.5555/pub", Access: storage.AccessPublic})
+	put(&dataset.Dataset{ID: "priv", Access: storage.AccessPrivate})
+
+	log := &MemoryLog{}
+	h := NewHandler(datasets, log, "https://media.example.org/")
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{DownloadPath("pub", 1, "dir/a b.csv"), http.StatusFound},
+		{DownloadPath("priv", 1, "dir/a b.csv"), http.StatusForbidden},
+		{DownloadPath("pub", 2, "dir/a b.csv"), http.StatusNotFound},
+		{DownloadPath("pub", 1, "missing.csv"), http.StatusNotFound},
+		{DownloadPath("nope", 1, "a.csv"), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("User-Agent", browser)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("GET %s status = %d, want %d", tt.path, rec.Code, tt.status)
+		}
+	}
+
+	events, _ := log.Events(ctx)
+	if len(events) != 1 {
+		t.Fatalf("logged %d events, want 1", len(events))
+	}
+	if e := events[0]; e.DOI != "10.5555/pub" || e.File != "dir/a b.csv" || e.TargetURL != "https://media.example.org/datasets/pub/v1/dir/a%20b.csv" {
+		t.Errorf("event = %+v", e)
+	}
+}
+
+// fakeSeals fails checks of the files in changed, and of everything
+// with err when set.
+type fakeSeals struct {
+	changed map[string]bool
+	err     error
+}
+
+func (f *fakeSeals) Check(_ context.Context, d *dataset.Dataset, v *dataset.Version, file *dataset.File) (*seal.File, error) {
+	switch {
+	case f.err != nil:
+		return nil, f.err
+	case f.changed[file.Path]:
+		return nil, fmt.Errorf("%w: %s", seal.ErrMismatch, file.Path)
+	}
+	return &seal.File{Path: file.Path, Size: file.Size, SHA256: file.SHA256}, nil
+}
+
+func TestHandlerSeals(t *testing.T) {
+	ctx := context.Background()
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	d := &dataset.Dataset{ID: "pub", State: dataset.StatePublished, Access: storage.AccessPublic, Versions: []dataset.Version{
+		{Number: 1, Files: []dataset.File{{Path: "a.csv", Key: "a.csv"}, {Path: "b.csv", Key: "b.csv"}}},
+	}}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	var logErrs []error
+	h := NewHandler(datasets, &MemoryLog{}, "https://media.example.org")
+	h.OnLogError = func(err error) { logErrs = append(logErrs, err) }
+	seals := &fakeSeals{changed: map[string]bool{"b.csv": true}}
+	h.Seals = seals
+	get := func(file string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DownloadPath("pub", 1, file), nil))
+		return rec
+	}
+
+	if rec := get("a.csv"); rec.Code != http.StatusFound || rec.Header().Get("Link") != `</seals/pub/v1>; rel="describedby"` {
+		t.Errorf("GET a.csv = %d, Link %q, want a redirect linking the seal", rec.Code, rec.Header().Get("Link"))
+	}
+	if rec := get("b.csv"); rec.Code != http.StatusConflict {
+		t.Errorf("GET of a changed file = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	// Unsealed versions are served, as are files whose seals cannot be
+	// checked, reporting the failure.
+	seals.err = fmt.Errorf("%w: pub v1", seal.ErrNotSealed)
+	if rec := get("b.csv"); rec.Code != http.StatusFound || len(logErrs) != 0 {
+		t.Errorf("GET of an unsealed file = %d, errors %v", rec.Code, logErrs)
+	}
+	seals.err = errors.New("KMS unavailable")
+	if rec := get("b.csv"); rec.Code != http.StatusFound || len(logErrs) != 1 {
+		t.Errorf("GET with failing checks = %d, errors %v", rec.Code, logErrs)
+	}
+}
+
+func TestHandlerSuperseded(t *testing.T) {
+	ctx := context.Background()
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	d := &dataset.Dataset{ID: "old", Title: "Ocean temperatures", State: dataset.StatePublished, Access: storage.AccessPublic,
+		SupersededBy: &dataset.Supersession{Dataset: "new", Title: "Ocean temperatures, corrected", URL: "https://doi.org/10.5555/new", Warn: true},
+		Versions:     []dataset.Version{{Number: 1, Files: []dataset.File{{Path: "a.csv", Key: "a.csv"}}}},
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	log := &MemoryLog{}
+	h := NewHandler(datasets, log, "https://media.example.org")
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get(DownloadPath("old", 1, "a.csv"))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, `href="https://doi.org/10.5555/new"`) ||
+		!strings.Contains(body, `href="/d/old/v1/a.csv?superseded=continue"`) {
+		t.Errorf("GET of a superseded file = %d %s, want a warning linking the replacement", rec.Code, body)
+	}
+	if events, _ := log.Events(ctx); len(events) != 0 {
+		t.Errorf("warning logged %d downloads", len(events))
+	}
+
+	if rec := get(DownloadPath("old", 1, "a.csv") + "?superseded=continue"); rec.Code != http.StatusFound {
+		t.Errorf("GET after the warning = %d, want %d", rec.Code, http.StatusFound)
+	}
+	if events, _ := log.Events(ctx); len(events) != 1 {
+		t.Errorf("logged %d downloads, want 1", len(events))
+	}
+}
+
+func TestHandlerPruned(t *testing.T) {
+	ctx := context.Background()
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	t0 := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	d := &dataset.Dataset{ID: "ds-1", State: dataset.StatePublished, Access: storage.AccessPublic,
+		Versions: []dataset.Version{
+			{Number: 1, PublishedAt: &t0, Files: []dataset.File{{Path: "a.csv", Key: "a.csv"}}, Pruned: &dataset.Pruning{Action: dataset.PruneDelete, Time: t0}},
+			{Number: 2, PublishedAt: &t0, Files: []dataset.File{{Path: "a.csv", Key: "a.csv"}}, Pruned: &dataset.Pruning{Action: dataset.PruneArchive, Time: t0}},
+			{Number: 3, PublishedAt: &t0, Files: []dataset.File{{Path: "a.csv", Key: "a.csv"}}},
+		},
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(datasets, &MemoryLog{}, "https://media.example.org")
+	for n, want := range map[int]int{1: http.StatusGone, 2: http.StatusConflict, 3: http.StatusFound} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DownloadPath("ds-1", n, "a.csv"), nil))
+		if rec.Code != want {
+			t.Errorf("GET of v%d = %d, want %d", n, rec.Code, want)
+		}
+	}
+}
+
+func TestReportInvestigations(t *testing.T) {
+	t0 := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	ip := netip.MustParseAddr("192.0.2.10")
+	event := func(at time.Duration, kind, file string) Event {
+		e := NewEvent(t0.Add(at), ip, browser)
+		e.Kind, e.DatasetID, e.File = kind, "ds-1", file
+		return e
+	}
+	events := []Event{
+		event(0, KindInvestigation, ""),
+		event(5*time.Second, KindInvestigation, ""), // double click
+		event(time.Minute, KindRequest, "a.csv"),
+		event(time.Minute+time.Second, "", "a.csv"), // CDN log of the same download
+		event(2*time.Hour, KindInvestigation, ""),
+	}
+	got := Report(events, time.Time{}, time.Time{})
+	want := Metrics{DatasetID: "ds-1", TotalInvestigations: 3, UniqueInvestigations: 2, TotalRequests: 1, UniqueRequests: 1}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("Report() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadRobots(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+	}{
+		{"json", `[{"pattern": "^Mendeley", "last_changed": "2020-01-01"}, {"pattern": "archive\\.org_bot"}]`},
+		{"lines", "# COUNTER robots\n^Mendeley\n\narchive\\.org_bot\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := LoadRobots(strings.NewReader(tt.list))
+			if err != nil {
+				t.Fatalf("LoadRobots() error = %v", err)
+			}
+			if r.Len() != 2 {
+				t.Errorf("Len() = %d, want 2", r.Len())
+			}
+			for ua, want := range map[string]bool{
+				"mendeley Desktop/1.19": true,
+				"curl/8.5.0":            true,
+				browser:                 false,
+			} {
+				if got := r.Match(ua); got != want {
+					t.Errorf("Match(%q) = %v, want %v", ua, got, want)
+				}
+			}
+		})
+	}
+	if _, err := LoadRobots(strings.NewReader("(unclosed\n")); err == nil {
+		t.Error("LoadRobots() accepted an invalid pattern")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	rules := Rules{
+		Deny:          []string{"^LibraryHarvester"},
+		Allow:         []string{"^curl/.*campus-mirror"},
+		MaxPerMinute:  5,
+		MaxPerSession: 20,
+	}
+	f, err := rules.Filter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ua, want := range map[string]bool{
+		"LibraryHarvester/2.0":         true,
+		"curl/8.5.0":                   true,
+		"curl/8.5.0 (campus-mirror)":   false,
+		browser:                        false,
+		"Googlebot/2.1 (+google.com/)": true,
+	} {
+		if got := f.Match(ua); got != want {
+			t.Errorf("Match(%q) = %v, want %v", ua, got, want)
+		}
+	}
+
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	var events []Event
+	add := func(session string, n int, gap time.Duration) {
+		for i := range n {
+			events = append(events, Event{Session: session, UserAgent: browser, Time: start.Add(time.Duration(i) * gap)})
+		}
+	}
+	add("reader", 5, time.Second)      // at the per-minute limit
+	add("burst", 6, time.Second)       // over it
+	add("steady", 6, 15*time.Second)   // six requests, but spread out
+	add("marathon", 21, 2*time.Minute) // over the per-session limit
+	events = append(events, Event{Session: "robot", UserAgent: "LibraryHarvester/2.0", Time: start, Robot: false})
+	events = append(events, Event{Session: "unflagged", UserAgent: browser, Time: start, Robot: true})
+
+	if got, want := f.Classify(events), 6+21+1; got != want {
+		t.Errorf("Classify() = %d robots, want %d", got, want)
+	}
+	robots := make(map[string]bool)
+	for _, e := range events {
+		robots[e.Session] = e.Robot
+	}
+	want := map[string]bool{"reader": false, "burst": true, "steady": false, "marathon": true, "robot": true, "unflagged": false}
+	if !reflect.DeepEqual(robots, want) {
+		t.Errorf("robots = %v, want %v", robots, want)
+	}
+
+	if _, err := (&Rules{Allow: []string{"(unclosed"}}).Filter(); err == nil {
+		t.Error("Filter() accepted an invalid pattern")
+	}
+	if _, err := (&Rules{MaxPerMinute: -1}).Filter(); err == nil {
+		t.Error("Filter() accepted a negative limit")
+	}
+}
+
+func TestReclassify(t *testing.T) {
+	ctx := context.Background()
+	st := state.NewMemoryStore()
+	rules, err := LoadRules(ctx, st)
+	if err != nil || rules.MaxPerMinute != DefaultRules.MaxPerMinute {
+		t.Fatalf("LoadRules() = %+v, %v, want the defaults", rules, err)
+	}
+
+	log, err := NewFileLog(t.TempDir() + "/downloads.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	events := []Event{
+		{Session: "a", DatasetID: "ds-1", UserAgent: "LibraryHarvester/2.0", Time: now},
+		{Session: "b", DatasetID: "ds-1", UserAgent: "curl/8.5.0 (campus-mirror)", Time: now, Robot: true},
+		{Session: "c", DatasetID: "ds-1", UserAgent: browser, Time: now},
+	}
+	if err := log.AppendAll(ctx, events); err != nil {
+		t.Fatal(err)
+	}
+
+	rules.Deny = []string{"^LibraryHarvester"}
+	rules.Allow = []string{"campus-mirror"}
+	if err := SaveRules(ctx, st, rules); err != nil {
+		t.Fatal(err)
+	}
+	if rules, err = LoadRules(ctx, st); err != nil || len(rules.Deny) != 1 {
+		t.Fatalf("LoadRules() = %+v, %v", rules, err)
+	}
+	f, err := rules.Filter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Reclassify(ctx, st, log, f, now, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Events != 3 || c.Robots != 1 || c.Changed != 2 {
+		t.Errorf("dry run = %+v", c)
+	}
+	if got, _ := log.Events(ctx); !reflect.DeepEqual(got, events) {
+		t.Error("dry run rewrote the log")
+	}
+	if c, _ := Classified(ctx, st); c != nil {
+		t.Errorf("dry run recorded %+v", c)
+	}
+
+	if _, err := Reclassify(ctx, st, log, f, now, false); err != nil {
+		t.Fatal(err)
+	}
+	got, err := log.Events(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || !got[0].Robot || got[1].Robot || got[2].Robot {
+		t.Errorf("reclassified events = %+v", got)
+	}
+	c, err = Classified(ctx, st)
+	if err != nil || c == nil || c.Rules != rules.Hash() || c.Changed != 2 {
+		t.Errorf("Classified() = %+v, %v", c, err)
+	}
+
+	// Events another process appends during a rewrite survive it.
+	err = log.Rewrite(ctx, func([]Event) {
+		f, err := os.OpenFile(log.path, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		fmt.Fprintln(f, `{"event_time":"2025-06-01T11:00:00Z","dataset_id":"ds-2","session_id":"d"}`)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := log.Events(ctx); len(got) != 4 || got[3].DatasetID != "ds-2" {
+		t.Errorf("events after concurrent append = %+v", got)
+	}
+}
+
+func TestParseAccessLog(t *testing.T) {
+	cloudfront := "#Version: 1.0\n" +
+		"#Fields: date time x-edge-location sc-bytes c-ip cs-method cs(Host) cs-uri-stem sc-status cs(Referer) cs(User-Agent)\n" +
+		"2025-06-01\t10:00:00\tLAX1\t1024\t192.0.2.10\tGET\td1.cloudfront.net\t/datasets/ds-1/v1/dir/a%20b.csv\t200\t-\tMozilla/5.0%20(X11)\n" +
+		"not a log line\n"
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte(`owner bucket [01/Jun/2025:12:00:00 +0200] 192.0.2.10 - REQ1 REST.GET.OBJECT datasets/ds-1/v1/dir/a%20b.csv "GET /datasets/ds-1/v1/dir/a%20b.csv HTTP/1.1" 206 - 512 1024 10 9 "-" "python-requests/2.32" -` + "\n"))
+	_ = zw.Close()
+
+	tests := []struct {
+		format  string
+		log     io.Reader
+		want    Access
+		skipped int
+	}{
+		{FormatCloudFront, strings.NewReader(cloudfront), Access{
+			Time: time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC), ClientIP: netip.MustParseAddr("192.0.2.10"),
+			Method: "GET", Path: "/datasets/ds-1/v1/dir/a b.csv", Status: 200, Bytes: 1024, UserAgent: "Mozilla/5.0 (X11)",
+		}, 1},
+		{FormatS3, &gz, Access{
+			Time: time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC), ClientIP: netip.MustParseAddr("192.0.2.10"),
+			Method: "GET", Path: "/datasets/ds-1/v1/dir/a b.csv", Status: 206, Bytes: 512, UserAgent: "python-requests/2.32",
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var got []Access
+			skipped, err := ParseAccessLog(tt.log, tt.format, func(a Access) error {
+				got = append(got, a)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ParseAccessLog() error = %v", err)
+			}
+			if len(got) != 1 || got[0] != tt.want || skipped != tt.skipped {
+				t.Errorf("ParseAccessLog() = %+v, skipped %d, want %+v, skipped %d", got, skipped, tt.want, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestIngest(t *testing.T) {
+	ctx := context.Background()
+	st := state.NewMemoryStore()
+	datasets := dataset.NewStore(st)
+	d := &dataset.Dataset{ID: "ds-1", DOI: "10.5555/ds-1", Title: "Soil cores", PublicationYear: 2024, State: dataset.StatePublished, Access: storage.AccessPublic,
+		Versions: []dataset.Version{{Number: 1, Files: []dataset.File{{Path: "a.csv", Key: "datasets/ds-1/v1/a.csv", Size: 3}}}}}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+
+	log := &MemoryLog{}
+	in := &Ingester{Datasets: datasets, State: st, Log: log}
+	line := func(clock, path string, status int) string {
+		return fmt.Sprintf("2025-06-01\t%s\tLAX1\t3\t192.0.2.10\tGET\td1.cloudfront.net\t%s\t%d\t-\tMozilla/5.0%%20(X11)\n", clock, path, status)
+	}
+	access := line("10:00:00", "/datasets/ds-1/", 200) +
+		line("10:01:00", "/datasets/ds-1/v1/a.csv", 200) +
+		line("10:02:00", "/datasets/ds-1/v1/a.csv", 404) +
+		line("10:03:00", "/datasets/ds-1/v2/a.csv", 200) +
+		line("10:04:00", "/datasets/other/v1/a.csv", 200) +
+		line("10:05:00", "/about/", 200)
+	rec, err := in.Ingest(ctx, "s3://logs/E1.2025-06-01-10.gz", FormatCloudFront, strings.NewReader(access))
+	if err != nil {
+		t.Fatalf("Ingest() error = %v", err)
+	}
+	if rec.Events != 2 {
+		t.Errorf("Ingest() logged %d events, want 2", rec.Events)
+	}
+	if _, err := in.Ingest(ctx, "s3://logs/E1.2025-06-01-10.gz", FormatCloudFront, strings.NewReader(access)); !errors.Is(err, ErrIngested) {
+		t.Errorf("Ingest() again error = %v, want ErrIngested", err)
+	}
+
+	events, _ := log.Events(ctx)
+	got := Report(events, time.Time{}, time.Time{})
+	want := Metrics{DatasetID: "ds-1", DOI: "10.5555/ds-1", TotalInvestigations: 2, UniqueInvestigations: 1, TotalRequests: 1, UniqueRequests: 1}
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("Report() = %+v, want %+v", got, want)
+	}
+
+	hub := &fakeHub{}
+	s := &Submitter{Datasets: datasets, State: st, Hub: hub, RepositoryID: "ABC.XYZ", Publisher: "Example University", SiteURL: "https://data.example.edu"}
+	june := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	for range 2 {
+		if _, err := s.Submit(ctx, june, events, nil); err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+	}
+	if strings.Join(hub.ids, ",") != ",r-1" {
+		t.Errorf("submitted report IDs %q, want a new report then r-1", hub.ids)
+	}
+	r := hub.reports[0]
+	if r.Header.Period != (datacite.Period{Begin: "2025-06-01", End: "2025-06-30"}) || len(r.Datasets) != 1 {
+		t.Fatalf("report = %+v", r)
+	}
+	if rd := r.Datasets[0]; rd.YOP != "2024" || rd.URI != "https://data.example.edu/datasets/ds-1/" || len(rd.Performance[0].Instances) != 4 {
+		t.Errorf("report dataset = %+v", rd)
+	}
+
+	if _, err := s.Submit(ctx, june.AddDate(0, 1, 0), events, nil); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if r := hub.reports[2]; len(r.Datasets) != 0 || len(r.Header.Exceptions) != 1 || r.Header.Exceptions[0].Code != datacite.ExceptionNoUsage {
+		t.Errorf("empty report = %+v, want exception %d", r.Header, datacite.ExceptionNoUsage)
+	}
+}
+
+type fakeHub struct {
+	ids     []string
+	reports []*datacite.UsageReport
+}
+
+func (h *fakeHub) SubmitUsageReport(_ context.Context, id string, r *datacite.UsageReport) (string, error) {
+	h.ids = append(h.ids, id)
+	h.reports = append(h.reports, r)
+	return fmt.Sprintf("r-%d", len(h.reports)), nil
+}
+
+func TestSummarize(t *testing.T) {
+	t0 := time.Date(2025, 1, 31, 23, 0, 0, 0, time.UTC)
+	event := func(at time.Duration, ip, kind, country string, bytes int64) Event {
+		e := NewEvent(t0.Add(at), netip.MustParseAddr(ip), browser)
+		e.Kind, e.DatasetID, e.Country, e.Bytes, e.Size = kind, "ds-1", country, bytes, 100
+		if kind == KindRequest {
+			e.File = "a.csv"
+		}
+		return e
+	}
+	events := []Event{
+		event(0, "192.0.2.10", KindInvestigation, "NZ", 5),
+		event(time.Minute, "192.0.2.10", KindRequest, "NZ", 0),
+		event(2*time.Hour, "198.51.100.7", KindRequest, "US", 40),
+		event(2*time.Hour, "198.51.100.7", KindInvestigation, "", 5),
+		event(24*time.Hour*40, "198.51.100.7", KindRequest, "US", 40),
+	}
+	other := NewEvent(t0, netip.MustParseAddr("192.0.2.10"), browser)
+	other.DatasetID = "ds-2"
+	events = append(events, other)
+
+	q, err := ParseStatsQuery(url.Values{"since": {"2025-01"}, "until": {"2025-02"}, "interval": {"day"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Summarize(events, q, func(e *Event) bool { return e.DatasetID == "ds-1" })
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if s.Views != 2 || s.Downloads != 2 || s.UniqueVisitors != 2 || s.Bytes != 150 {
+		t.Errorf("Summarize() = %+v, want 2 views, 2 downloads, 2 visitors, 150 bytes", s)
+	}
+	wantCountries := []CountryStats{{Country: "NZ", Views: 1, Downloads: 1}, {Country: "US", Downloads: 1}}
+	if !reflect.DeepEqual(s.Countries, wantCountries) {
+		t.Errorf("Summarize() countries = %+v, want %+v", s.Countries, wantCountries)
+	}
+	if len(s.Series) != 59 || s.Series[30] != (Point{Period: "2025-01-31", Views: 1, Downloads: 1, Bytes: 105}) || s.Series[31] != (Point{Period: "2025-02-01", Views: 1, Downloads: 1, Bytes: 45}) {
+		t.Errorf("Summarize() series = %d points, %+v", len(s.Series), s.Series[30:32])
+	}
+
+	for _, v := range []url.Values{
+		{"since": {"Jan 2025"}},
+		{"since": {"2025-03"}, "until": {"2025-02"}},
+		{"interval": {"week"}},
+	} {
+		if _, err := ParseStatsQuery(v); err == nil {
+			t.Errorf("ParseStatsQuery(%v) succeeded, want error", v)
+		}
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	ctx := context.Background()
+	st := state.NewMemoryStore()
+	datasets := dataset.NewStore(st)
+	collections := &collection.Registry{State: st, Datasets: datasets}
+	admin := identity.WithPrincipal(ctx, identity.Principal{ID: "admin@uni.edu", Groups: []string{authz.AdminGroup}})
+	if _, err := collections.Create(admin, collection.Collection{ID: "geo", Name: "Geosciences", Kind: collection.KindCollection}); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", DOI: "10.5555/ds-1", Collection: "geo", State: dataset.StatePublished},
+		{ID: "ds-2", Collection: "geo", State: dataset.StatePublished},
+		{ID: "draft", Collection: "geo"},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log := &MemoryLog{}
+	for _, id := range []string{"ds-1", "ds-2", "draft"} {
+		e := NewEvent(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), netip.MustParseAddr("192.0.2.10"), browser)
+		e.DatasetID, e.File = id, "a.csv"
+		_ = log.Append(ctx, e)
+	}
+	h := NewStatsHandler(&Analytics{Datasets: datasets, Collections: collections, Log: log})
+
+	tests := []struct {
+		path      string
+		status    int
+		downloads int
+	}{
+		{"/stats/datasets/10.5555/ds-1?since=2025", http.StatusOK, 1},
+		{"/stats/datasets/ds-1?since=2025-04", http.StatusOK, 0},
+		{"/stats/collections/geo", http.StatusOK, 2},
+		{"/stats/datasets/draft", http.StatusNotFound, 0},
+		{"/stats/collections/none", http.StatusNotFound, 0},
+		{"/stats/datasets/ds-1?until=soon", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("GET %s status = %d, want %d: %s", tt.path, rec.Code, tt.status, rec.Body)
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		var s Stats
+		if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		if s.Downloads != tt.downloads {
+			t.Errorf("GET %s downloads = %d, want %d", tt.path, s.Downloads, tt.downloads)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package counter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/seal"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// DownloadPath returns the redirect endpoint path for a file.
+func DownloadPath(datasetID string, version int, file string) string {
+	segments := strings.Split(strings.TrimPrefix(file, "/"), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return fmt.Sprintf("/d/%s/v%d/%s", url.PathEscape(datasetID), version, strings.Join(segments, "/"))
+}
+
+// supersededPage warns that a file belongs to a superseded dataset
+// before it is downloaded.
+var supersededPage = template.Must(template.New("superseded").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <meta name="robots" content="noindex">
+  <title>{{.Title}} has been superseded</title>
+</head>
+<body>
+  <main>
+    <h1>This dataset has been superseded</h1>
+    <p>{{.Title}} has been replaced by <a href="{{.By.URL}}">{{.By.Title}}</a>{{if .By.Reason}}: {{.By.Reason}}{{end}}</p>
+    <p><a href="{{.By.URL}}">Go to the replacement</a> or <a href="{{.Continue}}" rel="nofollow">download {{.File}} anyway</a></p>
+  </main>
+</body>
+</html>
+`))
+
+// SealChecker checks files against the sealed manifests of their
+// versions. *seal.Sealer implements it.
+type SealChecker interface {
+	Check(ctx context.Context, d *dataset.Dataset, v *dataset.Version, f *dataset.File) (*seal.File, error)
+}
+
+// Handler serves GET /d/{dataset}/v{version}/{file}, logging a download
+// event and redirecting to the file on the CDN. Only published public
+// datasets outside an embargo are served. Files of a dataset superseded
+// with a warning are served only after a page linking the replacement,
+// whose download link adds ?superseded=continue.
+type Handler struct {
+	datasets *dataset.Store
+	log      Log
+	mediaURL string
+	mux      *http.ServeMux
+
+	// OnLogError is called if an event cannot be logged or a seal
+	// cannot be checked; the download is redirected regardless
+	OnLogError func(error)
+
+	// Seals refuses files that no longer match the sealed manifests of
+	// their versions, and links the seal of sealed versions; skipped if
+	// nil
+	Seals SealChecker
+
+	// Filter marks downloads by robots' user agents; IsRobot alone if
+	// nil. Rate limits apply when the log is reclassified.
+	Filter *Filter
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// NewHandler returns a handler that logs events to log and redirects
+// to objects under mediaURL, the public media CDN.
+func NewHandler(datasets *dataset.Store, log Log, mediaURL string) *Handler {
+	h := &Handler{datasets: datasets, log: log, mediaURL: strings.TrimRight(mediaURL, "/"), mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /d/{id}/{version}/{file...}", h.serveDownload)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) serveDownload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	d, err := h.datasets.Get(ctx, r.PathValue("id"))
+	if errors.Is(err, dataset.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	now := h.now()
+	if d.State != dataset.StatePublished || d.Access != storage.AccessPublic || d.Embargoed(now) {
+		http.Error(w, d.ID+" is not publicly downloadable; request access with a presigned URL", http.StatusForbidden)
+		return
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(r.PathValue("version"), "v"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	v := d.Version(n)
+	if v == nil || (v.PublishedAt == nil && v != d.Current()) {
+		http.NotFound(w, r)
+		return
+	}
+	var file *dataset.File
+	for i := range v.Files {
+		if v.Files[i].Path == r.PathValue("file") {
+			file = &v.Files[i]
+			break
+		}
+	}
+	if file == nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case v.Removed():
+		http.Error(w, fmt.Sprintf("the files of %s v%d were deleted on %s under its collection's retention policy; its metadata remains on the landing page", d.ID, v.Number, v.Pruned.Time.Format(time.DateOnly)), http.StatusGone)
+		return
+	case v.Archived():
+		http.Error(w, fmt.Sprintf("the files of %s v%d are in archival storage; ask the repository to restore them", d.ID, v.Number), http.StatusConflict)
+		return
+	}
+
+	if by := d.SupersededBy; by != nil && by.Warn && r.URL.Query().Get("superseded") != "continue" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		err := supersededPage.Execute(w, struct {
+			Title, File, Continue string
+			By                    *dataset.Supersession
+		}{d.Title, file.Path, r.URL.EscapedPath() + "?superseded=continue", by})
+		if err != nil && h.OnLogError != nil {
+			h.OnLogError(fmt.Errorf("failed to render the superseded warning for %s: %w", d.ID, err))
+		}
+		return
+	}
+
+	if h.Seals != nil {
+		_, err := h.Seals.Check(ctx, d, v, file)
+		switch {
+		case err == nil:
+			w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"describedby\"", seal.Path(d.ID, v.Number)))
+		case errors.Is(err, seal.ErrMismatch), errors.Is(err, seal.ErrInvalid):
+			http.Error(w, err.Error()+"; the file is withheld until it is reviewed", http.StatusConflict)
+			return
+		case !errors.Is(err, seal.ErrNotSealed) && h.OnLogError != nil:
+			h.OnLogError(fmt.Errorf("failed to check the seal of %s/%s: %w", d.ID, file.Path, err))
+		}
+	}
+
+	target := h.mediaURL + "/" + (&url.URL{Path: file.Key}).EscapedPath()
+	e := NewEvent(now, clientIP(r), r.UserAgent())
+	e.DatasetID, e.DOI, e.Version = d.ID, d.DOI, v.Number
+	e.File, e.Size, e.TargetURL = file.Path, file.Size, target
+	e.Robot = h.Filter.Match(e.UserAgent)
+	e.Country = r.Header.Get("CloudFront-Viewer-Country")
+	if err := h.log.Append(ctx, e); err != nil && h.OnLogError != nil {
+		h.OnLogError(fmt.Errorf("failed to log download of %s/%s: %w", d.ID, file.Path, err))
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (h *Handler) now() time.Time {
+	if h.Now != nil {
+		return h.Now()
+	}
+	return time.Now()
+}
+
+// clientIP returns the address of the client that made r.
+func clientIP(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip, _ := netip.ParseAddr(host)
+	return ip
+}
@@ -0,0 +1,209 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package counter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/queue"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// ingestedTable records the access logs already ingested.
+const ingestedTable = "counter-ingested"
+
+// ErrIngested is returned by Ingest for a log that was already
+// ingested.
+var ErrIngested = errors.New("access log already ingested")
+
+// BatchLog is a Log that can append many events at once.
+type BatchLog interface {
+	Log
+	AppendAll(ctx context.Context, events []Event) error
+}
+
+// Ingested records an ingested access log.
+type Ingested struct {
+	// Name identifies the log, such as its S3 URI
+	Name string `json:"name"`
+
+	// Format is the access log format
+	Format string `json:"format"`
+
+	// Time is when the log was ingested
+	Time time.Time `json:"time"`
+
+	// Events is the number of events logged from it
+	Events int `json:"events"`
+
+	// Skipped is the number of lines that could not be parsed
+	Skipped int `json:"skipped"`
+}
+
+// Ingester turns CloudFront and S3 access logs into events, counting
+// downloads that bypass the redirect endpoint and views of landing
+// pages. A download through the endpoint is also in the CDN log, a
+// second or so after the redirect; the double-click rule counts the
+// two once.
+type Ingester struct {
+	Datasets *dataset.Store
+	State    state.Store
+	Log      BatchLog
+
+	// Filter marks events from robots; IsRobot alone if nil
+	Filter *Filter
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Ingest reads the access log r, named name, and appends an event for
+// each successful request for a dataset file (a request) or landing
+// page (an investigation). A log is ingested once: ingesting it again
+// returns ErrIngested.
+func (in *Ingester) Ingest(ctx context.Context, name, format string, r io.Reader) (*Ingested, error) {
+	var rec Ingested
+	err := in.State.Get(ctx, ingestedTable, name, &rec)
+	if err == nil {
+		return &rec, ErrIngested
+	}
+	if !errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("failed to check access log %s: %w", name, err)
+	}
+
+	cache := make(map[string]*dataset.Dataset)
+	var events []Event
+	skipped, err := ParseAccessLog(r, format, func(a Access) error {
+		if !a.Succeeded() {
+			return nil
+		}
+		e, ok, err := in.event(ctx, cache, a)
+		if ok {
+			events = append(events, e)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to ingest %s: %w", name, err)
+	}
+	if len(events) > 0 {
+		in.Filter.Classify(events)
+		if err := in.Log.AppendAll(ctx, events); err != nil {
+			return nil, fmt.Errorf("failed to log events from %s: %w", name, err)
+		}
+	}
+	rec = Ingested{Name: name, Format: format, Time: in.now().UTC(), Events: len(events), Skipped: skipped}
+	if err := in.State.Put(ctx, ingestedTable, name, &rec); err != nil {
+		return nil, fmt.Errorf("failed to record access log %s: %w", name, err)
+	}
+	return &rec, nil
+}
+
+// LogReader reads access logs from the logs bucket. *s3.Client
+// implements it.
+type LogReader interface {
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// Handler returns a handler of the ingest queue that ingests the access
+// logs, in format, that the S3 event notifications it receives name,
+// reading them with logs. Logs are named by their S3 URI, as when
+// ingested by listing the bucket, so each is ingested once either way.
+func (in *Ingester) Handler(logs LogReader, format string) queue.Handler {
+	return func(ctx context.Context, body []byte) error {
+		events, err := s3.ObjectEvents(body)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			r, err := logs.GetObject(ctx, e.Bucket, e.Key)
+			if err != nil {
+				return fmt.Errorf("failed to read access log s3://%s/%s: %w", e.Bucket, e.Key, err)
+			}
+			_, err = in.Ingest(ctx, "s3://"+e.Bucket+"/"+e.Key, format, r)
+			r.Close()
+			if err != nil && !errors.Is(err, ErrIngested) {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// event returns the event for a, if it is for a dataset. Datasets are
+// looked up once per log.
+func (in *Ingester) event(ctx context.Context, cache map[string]*dataset.Dataset, a Access) (Event, bool, error) {
+	_, rest, ok := strings.Cut(a.Path, "datasets/")
+	if !ok {
+		return Event{}, false, nil
+	}
+	id, rest, _ := strings.Cut(rest, "/")
+	d, seen := cache[id]
+	if !seen {
+		var err error
+		d, err = in.Datasets.Get(ctx, id)
+		if errors.Is(err, dataset.ErrNotFound) {
+			d, err = nil, nil
+		}
+		if err != nil {
+			return Event{}, false, err
+		}
+		cache[id] = d
+	}
+	if d == nil {
+		return Event{}, false, nil
+	}
+
+	e := NewEvent(a.Time, a.ClientIP, a.UserAgent)
+	e.DatasetID, e.DOI = d.ID, d.DOI
+	e.Bytes, e.Country = a.Bytes, a.Country
+	switch rest {
+	case "", "index.html", "linkset.json":
+		e.Kind = KindInvestigation
+		return e, true, nil
+	}
+	version, file, _ := strings.Cut(rest, "/")
+	n, err := strconv.Atoi(strings.TrimPrefix(version, "v"))
+	if err != nil || !strings.HasPrefix(version, "v") {
+		return Event{}, false, nil
+	}
+	v := d.Version(n)
+	if v == nil {
+		return Event{}, false, nil
+	}
+	for _, f := range v.Files {
+		if f.Path == file {
+			e.Kind = KindRequest
+			e.Version, e.File, e.Size = v.Number, f.Path, f.Size
+			return e, true, nil
+		}
+	}
+	return Event{}, false, nil
+}
+
+func (in *Ingester) now() time.Time {
+	if in.Now != nil {
+		return in.Now()
+	}
+	return time.Now()
+}
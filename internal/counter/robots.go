@@ -0,0 +1,287 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package counter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// robotsTable holds the robot rules and the classification of the
+// event log.
+const robotsTable = "counter-robots"
+
+// Keys in robotsTable.
+const (
+	rulesKey      = "rules"
+	classifiedKey = "classified"
+)
+
+// DefaultRules are the robot rules used until others are saved: the
+// built-in user agent patterns, and sessions making more than 60
+// requests in a minute, which no reader clicks through by hand.
+var DefaultRules = Rules{MaxPerMinute: 60}
+
+// Rules configure robot detection. An event is from a robot if its
+// user agent matches the built-in patterns, List, or Deny and not
+// Allow, or if its session exceeds a rate limit.
+type Rules struct {
+	// List is a published exclusion list, such as COUNTER-Robots
+	List []string `json:"list,omitempty"`
+
+	// Deny are local user agent patterns of robots
+	Deny []string `json:"deny,omitempty"`
+
+	// Allow are user agent patterns that are never robots, e.g. a
+	// campus proxy the built-in patterns would catch
+	Allow []string `json:"allow,omitempty"`
+
+	// MaxPerMinute marks a session as a robot when it makes more
+	// than this many requests in any minute; 0 disables the limit
+	MaxPerMinute int `json:"maxPerMinute,omitempty"`
+
+	// MaxPerSession marks a session, one client in one clock hour, as
+	// a robot when it makes more than this many requests; 0 disables
+	// the limit
+	MaxPerSession int `json:"maxPerSession,omitempty"`
+
+	// UpdatedAt is when the rules were saved
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// Hash returns a digest of the rules, which changes whenever they
+// would classify some event differently.
+func (r *Rules) Hash() string {
+	data, _ := json.Marshal([]any{r.List, r.Deny, r.Allow, r.MaxPerMinute, r.MaxPerSession})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Filter compiles the rules.
+func (r *Rules) Filter() (*Filter, error) {
+	if r.MaxPerMinute < 0 || r.MaxPerSession < 0 {
+		return nil, fmt.Errorf("robot rate limits must not be negative")
+	}
+	robots, err := NewRobots(append(slices.Clone(r.List), r.Deny...))
+	if err != nil {
+		return nil, err
+	}
+	allow, err := NewRobots(r.Allow)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{
+		robots:        robots,
+		allow:         allow.patterns,
+		maxPerMinute:  r.MaxPerMinute,
+		maxPerSession: r.MaxPerSession,
+		hash:          r.Hash(),
+	}, nil
+}
+
+// LoadRules returns the saved robot rules, or DefaultRules if none
+// were saved.
+func LoadRules(ctx context.Context, s state.Store) (*Rules, error) {
+	r := DefaultRules
+	if err := s.Get(ctx, robotsTable, rulesKey, &r); err != nil && !errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("failed to read robot rules: %w", err)
+	}
+	return &r, nil
+}
+
+// SaveRules validates and saves r. Events already logged keep their
+// classification until Reclassify applies the new rules to them.
+func SaveRules(ctx context.Context, s state.Store, r *Rules) error {
+	if _, err := r.Filter(); err != nil {
+		return err
+	}
+	if err := s.Put(ctx, robotsTable, rulesKey, r); err != nil {
+		return fmt.Errorf("failed to save robot rules: %w", err)
+	}
+	return nil
+}
+
+// Filter classifies events as from robots or people. A nil filter
+// falls back to IsRobot.
+type Filter struct {
+	robots        *Robots
+	allow         []*regexp.Regexp
+	maxPerMinute  int
+	maxPerSession int
+	hash          string
+}
+
+// Hash returns the Hash of the rules f was compiled from, or "" if f
+// is nil.
+func (f *Filter) Hash() string {
+	if f == nil {
+		return ""
+	}
+	return f.hash
+}
+
+// Match reports whether userAgent is a robot's.
+func (f *Filter) Match(userAgent string) bool {
+	if f == nil {
+		return IsRobot(userAgent)
+	}
+	for _, re := range f.allow {
+		if re.MatchString(userAgent) {
+			return false
+		}
+	}
+	return f.robots.Match(userAgent)
+}
+
+// Classify sets the Robot flag of every event, replacing any earlier
+// classification, and returns the number of robot events. Rate limits
+// apply to the sessions as seen in events, so a session split across
+// batches is judged in full only when the whole log is classified.
+func (f *Filter) Classify(events []Event) int {
+	for i := range events {
+		events[i].Robot = f.Match(events[i].UserAgent)
+	}
+	for _, session := range f.fastSessions(events) {
+		for _, i := range session {
+			events[i].Robot = true
+		}
+	}
+	robots := 0
+	for _, e := range events {
+		if e.Robot {
+			robots++
+		}
+	}
+	return robots
+}
+
+// fastSessions returns the indexes of the events of each session that
+// exceeds a rate limit.
+func (f *Filter) fastSessions(events []Event) [][]int {
+	if f == nil || (f.maxPerMinute == 0 && f.maxPerSession == 0) {
+		return nil
+	}
+	sessions := make(map[string][]int)
+	for i, e := range events {
+		if !e.Robot && e.Session != "" {
+			sessions[e.Session] = append(sessions[e.Session], i)
+		}
+	}
+	var fast [][]int
+	for _, idx := range sessions {
+		if f.maxPerSession > 0 && len(idx) > f.maxPerSession {
+			fast = append(fast, idx)
+			continue
+		}
+		if f.maxPerMinute == 0 || len(idx) <= f.maxPerMinute {
+			continue
+		}
+		times := make([]time.Time, len(idx))
+		for j, i := range idx {
+			times[j] = events[i].Time
+		}
+		slices.SortFunc(times, time.Time.Compare)
+		for j := f.maxPerMinute; j < len(times); j++ {
+			if times[j].Sub(times[j-f.maxPerMinute]) < time.Minute {
+				fast = append(fast, idx)
+				break
+			}
+		}
+	}
+	return fast
+}
+
+// RewritableLog is a Log whose events can be rewritten in place.
+type RewritableLog interface {
+	Log
+	Rewrite(ctx context.Context, fn func(events []Event)) error
+}
+
+// Classification records the rules the event log was last classified
+// under.
+type Classification struct {
+	// Rules is the Hash of the rules
+	Rules string `json:"rules"`
+
+	// Time is when the log was classified
+	Time time.Time `json:"time"`
+
+	// Events is the number of events classified
+	Events int `json:"events"`
+
+	// Robots is the number found to be from robots
+	Robots int `json:"robots"`
+
+	// Changed is the number whose classification changed
+	Changed int `json:"changed"`
+}
+
+// Classified returns the last classification of the event log, or nil
+// if the log was never reclassified.
+func Classified(ctx context.Context, s state.Store) (*Classification, error) {
+	var c Classification
+	err := s.Get(ctx, robotsTable, classifiedKey, &c)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log classification: %w", err)
+	}
+	return &c, nil
+}
+
+// Reclassify applies f to every event in log, so that changed rules
+// apply to historical downloads and views as well as new ones, and
+// records the classification. With dryRun it reports what would
+// change without rewriting the log.
+func Reclassify(ctx context.Context, s state.Store, log RewritableLog, f *Filter, now time.Time, dryRun bool) (*Classification, error) {
+	c := &Classification{Rules: f.Hash(), Time: now.UTC()}
+	classify := func(events []Event) {
+		was := make([]bool, len(events))
+		for i, e := range events {
+			was[i] = e.Robot
+		}
+		c.Events, c.Robots = len(events), f.Classify(events)
+		for i, e := range events {
+			if e.Robot != was[i] {
+				c.Changed++
+			}
+		}
+	}
+	if dryRun {
+		events, err := log.Events(ctx)
+		if err != nil {
+			return nil, err
+		}
+		classify(events)
+		return c, nil
+	}
+	if err := log.Rewrite(ctx, classify); err != nil {
+		return nil, err
+	}
+	if err := s.Put(ctx, robotsTable, classifiedKey, c); err != nil {
+		return nil, fmt.Errorf("failed to record log classification: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,250 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package counter
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Series intervals.
+const (
+	IntervalDay   = "day"
+	IntervalMonth = "month"
+)
+
+// maxSeriesPoints caps a series, so that a daily series of a long range
+// cannot grow without bound.
+const maxSeriesPoints = 3660
+
+// StatsQuery selects the events summarized by Summarize.
+type StatsQuery struct {
+	// From and To bound the events, To exclusive; either may be zero
+	// for an open range
+	From time.Time
+	To   time.Time
+
+	// Interval breaks the totals down by IntervalDay or IntervalMonth;
+	// no breakdown if empty
+	Interval string
+}
+
+// Stats summarizes the usage of a dataset or collection. Views count
+// landing page views and downloads count file requests, each under the
+// same robot and double-click rules as COUNTER metrics. Unique visitors
+// are distinct anonymized clients: one network and user agent.
+type Stats struct {
+	Dataset    string     `json:"dataset,omitempty"`
+	DOI        string     `json:"doi,omitempty"`
+	Collection string     `json:"collection,omitempty"`
+	Datasets   int        `json:"datasets,omitempty"`
+	From       *time.Time `json:"from,omitempty"`
+	To         *time.Time `json:"to,omitempty"`
+
+	Views          int   `json:"views"`
+	Downloads      int   `json:"downloads"`
+	UniqueVisitors int   `json:"uniqueVisitors"`
+	Bytes          int64 `json:"bytes"`
+
+	// Countries breaks usage down by ISO 3166 country code, most used
+	// first; usage from an unknown country is not listed
+	Countries []CountryStats `json:"countries"`
+
+	// Series breaks usage down by the query's interval
+	Series []Point `json:"series,omitempty"`
+}
+
+// CountryStats is usage from one country.
+type CountryStats struct {
+	Country   string `json:"country"`
+	Views     int    `json:"views"`
+	Downloads int    `json:"downloads"`
+}
+
+// Point is usage in one day or month, named as YYYY-MM-DD or YYYY-MM.
+type Point struct {
+	Period    string `json:"period"`
+	Views     int    `json:"views"`
+	Downloads int    `json:"downloads"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// Summarize returns the usage in the events matching q for which
+// include returns true. Bytes are those sent as logged by the CDN, or
+// the file size for downloads counted only by the redirect endpoint.
+func Summarize(events []Event, q StatsQuery, include func(*Event) bool) (*Stats, error) {
+	layout := ""
+	switch q.Interval {
+	case "":
+	case IntervalDay:
+		layout = time.DateOnly
+	case IntervalMonth:
+		layout = "2006-01"
+	default:
+		return nil, fmt.Errorf("invalid interval %q: want %s or %s", q.Interval, IntervalDay, IntervalMonth)
+	}
+
+	s := &Stats{Countries: []CountryStats{}}
+	if !q.From.IsZero() {
+		from := q.From.UTC()
+		s.From = &from
+	}
+	if !q.To.IsZero() {
+		to := q.To.UTC()
+		s.To = &to
+	}
+	visitors := make(map[string]bool)
+	countries := make(map[string]*CountryStats)
+	points := make(map[string]*Point)
+	var first, last time.Time
+	for _, e := range countable(events, q.From, q.To) {
+		if !include(&e) {
+			continue
+		}
+		if first.IsZero() {
+			first = e.Time
+		}
+		last = e.Time
+		visitors[e.ClientIP+"\x00"+e.UserAgent] = true
+		bytes := e.Bytes
+		if bytes == 0 && e.IsRequest() {
+			bytes = e.Size
+		}
+		s.Bytes += bytes
+
+		var c *CountryStats
+		if e.Country != "" {
+			if c = countries[e.Country]; c == nil {
+				c = &CountryStats{Country: e.Country}
+				countries[e.Country] = c
+			}
+		}
+		var p *Point
+		if layout != "" {
+			period := e.Time.UTC().Format(layout)
+			if p = points[period]; p == nil {
+				p = &Point{Period: period}
+				points[period] = p
+			}
+			p.Bytes += bytes
+		}
+		if e.IsRequest() {
+			s.Downloads++
+			if c != nil {
+				c.Downloads++
+			}
+			if p != nil {
+				p.Downloads++
+			}
+		} else {
+			s.Views++
+			if c != nil {
+				c.Views++
+			}
+			if p != nil {
+				p.Views++
+			}
+		}
+	}
+	s.UniqueVisitors = len(visitors)
+
+	for _, c := range countries {
+		s.Countries = append(s.Countries, *c)
+	}
+	sort.Slice(s.Countries, func(i, j int) bool {
+		a, b := s.Countries[i], s.Countries[j]
+		if a.Views+a.Downloads != b.Views+b.Downloads {
+			return a.Views+a.Downloads > b.Views+b.Downloads
+		}
+		return a.Country < b.Country
+	})
+
+	// Fill the periods without usage, so that a chart of the series
+	// shows them.
+	if !q.From.IsZero() {
+		first = q.From.UTC()
+	}
+	if !q.To.IsZero() {
+		last = q.To.UTC().Add(-time.Nanosecond)
+	}
+	if layout != "" && !first.IsZero() && !last.IsZero() {
+		step := func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+		t := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+		if q.Interval == IntervalMonth {
+			step = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+			t = time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC)
+		}
+		for ; !t.After(last) && len(s.Series) < maxSeriesPoints; t = step(t) {
+			period := t.Format(layout)
+			if p := points[period]; p != nil {
+				s.Series = append(s.Series, *p)
+			} else {
+				s.Series = append(s.Series, Point{Period: period})
+			}
+		}
+	}
+	return s, nil
+}
+
+// ParsePeriod parses a year (2025), month (2025-01), or day
+// (2025-01-31) and returns its start and the start of the next.
+func ParsePeriod(s string) (start, end time.Time, err error) {
+	for _, p := range []struct {
+		layout string
+		years  int
+		months int
+		days   int
+	}{
+		{"2006", 1, 0, 0},
+		{"2006-01", 0, 1, 0},
+		{time.DateOnly, 0, 0, 1},
+	} {
+		if t, err := time.Parse(p.layout, s); err == nil {
+			return t, t.AddDate(p.years, p.months, p.days), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q: want YYYY, YYYY-MM, or YYYY-MM-DD", s)
+}
+
+// ParseStatsQuery parses since and until, dates in ParsePeriod's
+// formats, and interval from URL parameters. Until is inclusive: until
+// 2025-03 counts all of March.
+func ParseStatsQuery(v url.Values) (StatsQuery, error) {
+	var q StatsQuery
+	if s := v.Get("since"); s != "" {
+		from, _, err := ParsePeriod(s)
+		if err != nil {
+			return q, err
+		}
+		q.From = from
+	}
+	if s := v.Get("until"); s != "" {
+		_, to, err := ParsePeriod(s)
+		if err != nil {
+			return q, err
+		}
+		q.To = to
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return q, fmt.Errorf("since must be before until")
+	}
+	q.Interval = v.Get("interval")
+	if q.Interval != "" && q.Interval != IntervalDay && q.Interval != IntervalMonth {
+		return q, fmt.Errorf("invalid interval %q: want %s or %s", q.Interval, IntervalDay, IntervalMonth)
+	}
+	return q, nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package counter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// submissionsTable records the monthly reports submitted to the usage
+// hub, by month.
+const submissionsTable = "counter-submissions"
+
+// UsageHub receives usage reports; *datacite.Client implements it.
+type UsageHub interface {
+	SubmitUsageReport(ctx context.Context, id string, r *datacite.UsageReport) (string, error)
+}
+
+// Submission records a month's report submitted to the usage hub.
+type Submission struct {
+	// Month is the reporting month, as YYYY-MM
+	Month string `json:"month"`
+
+	// ReportID is the usage hub's ID for the report
+	ReportID string `json:"reportId"`
+
+	// Datasets is the number of datasets reported
+	Datasets int `json:"datasets"`
+
+	// Time is when the report was last submitted
+	Time time.Time `json:"time"`
+}
+
+// Submitter builds monthly SUSHI dataset reports and submits them to
+// the DataCite usage hub.
+type Submitter struct {
+	Datasets *dataset.Store
+	State    state.Store
+	Hub      UsageHub
+	Log      audit.Log
+
+	// RepositoryID is the DataCite repository account, which creates
+	// the reports
+	RepositoryID string
+
+	// Publisher names the repository as platform and publisher
+	Publisher string
+
+	// SiteURL is the public base URL of landing pages
+	SiteURL string
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Build returns the report of metrics for the month starting at month.
+// Only datasets with a DOI are reported, since the usage hub reports
+// by DOI, and only the metrics that are not zero.
+func (s *Submitter) Build(ctx context.Context, month time.Time, metrics []Metrics) (*datacite.UsageReport, error) {
+	period := datacite.Period{
+		Begin: month.Format(time.DateOnly),
+		End:   month.AddDate(0, 1, -1).Format(time.DateOnly),
+	}
+	r := &datacite.UsageReport{
+		Header: datacite.ReportHeader{
+			Name:       datacite.ReportName,
+			ID:         datacite.ReportID,
+			Release:    datacite.ReportRelease,
+			Created:    s.now().UTC().Format(time.RFC3339),
+			CreatedBy:  s.RepositoryID,
+			Period:     period,
+			Filters:    []datacite.TypedValue{},
+			Attributes: []datacite.TypedValue{},
+			Exceptions: []datacite.ReportException{},
+		},
+		Datasets: []datacite.ReportDataset{},
+	}
+	for _, m := range metrics {
+		if m.DOI == "" {
+			continue
+		}
+		d, err := s.Datasets.Get(ctx, m.DatasetID)
+		if errors.Is(err, dataset.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rd := datacite.ReportDataset{
+			ID:          []datacite.TypedValue{{Type: "doi", Value: m.DOI}},
+			Title:       d.Title,
+			DataType:    "dataset",
+			Platform:    s.Publisher,
+			Publisher:   s.Publisher,
+			PublisherID: []datacite.TypedValue{{Type: "client-id", Value: strings.ToLower(s.RepositoryID)}},
+		}
+		if d.PublicationYear > 0 {
+			rd.YOP = strconv.Itoa(d.PublicationYear)
+		}
+		if s.SiteURL != "" {
+			rd.URI = strings.TrimRight(s.SiteURL, "/") + "/datasets/" + d.ID + "/"
+		}
+		perf := datacite.Performance{Period: period}
+		for _, c := range []struct {
+			metric string
+			count  int
+		}{
+			{"total-dataset-investigations", m.TotalInvestigations},
+			{"unique-dataset-investigations", m.UniqueInvestigations},
+			{"total-dataset-requests", m.TotalRequests},
+			{"unique-dataset-requests", m.UniqueRequests},
+		} {
+			if c.count > 0 {
+				perf.Instances = append(perf.Instances, datacite.Instance{AccessMethod: "regular", MetricType: c.metric, Count: c.count})
+			}
+		}
+		rd.Performance = []datacite.Performance{perf}
+		r.Datasets = append(r.Datasets, rd)
+	}
+	if len(r.Datasets) == 0 {
+		r.Header.Exceptions = append(r.Header.Exceptions, datacite.ReportException{
+			Code:     datacite.ExceptionNoUsage,
+			Severity: "warning",
+			Message:  "No Usage Available for Requested Dates",
+		})
+	}
+	return r, nil
+}
+
+// Submit reports the usage in events during the month starting at
+// month to the usage hub, excluding robots as classified by f. A month
+// already submitted is resubmitted in place, so that logs ingested
+// late are reflected.
+func (s *Submitter) Submit(ctx context.Context, month time.Time, events []Event, f *Filter) (*Submission, error) {
+	month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	f.Classify(events)
+	r, err := s.Build(ctx, month, Report(events, month, month.AddDate(0, 1, 0)))
+	if err != nil {
+		return nil, err
+	}
+
+	key := month.Format("2006-01")
+	var sub Submission
+	if err := s.State.Get(ctx, submissionsTable, key, &sub); err != nil && !errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("failed to read submission of %s: %w", key, err)
+	}
+	id, err := s.Hub.SubmitUsageReport(ctx, sub.ReportID, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit usage report for %s: %w", key, err)
+	}
+	sub = Submission{Month: key, ReportID: id, Datasets: len(r.Datasets), Time: s.now().UTC()}
+	if err := s.State.Put(ctx, submissionsTable, key, &sub); err != nil {
+		return nil, fmt.Errorf("failed to record submission of %s: %w", key, err)
+	}
+	if s.Log != nil {
+		if err := audit.Record(ctx, s.Log, "usage.submit", key, map[string]string{"report": id, "datasets": strconv.Itoa(sub.Datasets)}); err != nil {
+			return &sub, err
+		}
+	}
+	return &sub, nil
+}
+
+func (s *Submitter) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
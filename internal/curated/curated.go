@@ -0,0 +1,413 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package curated keeps the featured datasets and the curated lists,
+// such as "Teaching datasets", shown on the repository's homepage.
+//
+// A list orders its datasets by hand. Each entry may be scheduled to
+// appear only within a window, so a dataset can be featured for a
+// conference week or a list rotated without further changes. The
+// featured list always exists. Only holders of authz.PermFeature
+// change lists; readers see the entries whose window is open and whose
+// datasets are published.
+package curated
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// listsTable holds curated lists keyed by ID.
+const listsTable = "curated-lists"
+
+// Featured is the ID of the list of featured datasets.
+const Featured = "featured"
+
+var (
+	// ErrNotFound is returned for an unknown list.
+	ErrNotFound = errors.New("curated list not found")
+
+	// ErrExists is returned when creating a list whose ID is taken.
+	ErrExists = errors.New("curated list already exists")
+)
+
+// validID matches list IDs, which appear in API paths.
+var validID = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,62}[a-z0-9])?$`)
+
+// Entry is a dataset on a list.
+type Entry struct {
+	// DatasetID identifies the dataset
+	DatasetID string `json:"datasetId"`
+
+	// Note is a short blurb shown with the dataset
+	Note string `json:"note,omitempty"`
+
+	// From and Until bound when the entry is shown; unbounded if nil
+	From  *time.Time `json:"from,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+
+	// AddedBy is the principal who added the entry
+	AddedBy string `json:"addedBy,omitempty"`
+
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// Shown reports whether e's window is open at now.
+func (e *Entry) Shown(now time.Time) bool {
+	return (e.From == nil || !now.Before(*e.From)) && (e.Until == nil || now.Before(*e.Until))
+}
+
+// List is a curated list of datasets.
+type List struct {
+	// ID identifies the list
+	ID string `json:"id"`
+
+	// Title is the heading shown above the list
+	Title string `json:"title"`
+
+	// Description introduces the list
+	Description string `json:"description,omitempty"`
+
+	// Entries are the list's datasets in display order
+	Entries []Entry `json:"entries"`
+
+	// CreatedBy is the principal who created the list
+	CreatedBy string `json:"createdBy,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// index returns the position of datasetID in l, or -1.
+func (l *List) index(datasetID string) int {
+	return slices.IndexFunc(l.Entries, func(e Entry) bool { return e.DatasetID == datasetID })
+}
+
+// Placement is where and when a dataset appears on a list.
+type Placement struct {
+	// Position is the 1-based position of the entry; the end of the
+	// list if zero, or its current position if already listed
+	Position int
+
+	// Note is a short blurb shown with the dataset
+	Note string
+
+	// From and Until bound when the entry is shown; unbounded if zero
+	From  time.Time
+	Until time.Time
+}
+
+// Item is a dataset as shown on a list.
+type Item struct {
+	ID              string     `json:"id"`
+	DOI             string     `json:"doi,omitempty"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description,omitempty"`
+	Creators        []string   `json:"creators,omitempty"`
+	PublicationYear int        `json:"publicationYear,omitempty"`
+	Collection      string     `json:"collection,omitempty"`
+	URL             string     `json:"url"`
+	Note            string     `json:"note,omitempty"`
+	Until           *time.Time `json:"until,omitempty"`
+}
+
+// Registry stores curated lists.
+type Registry struct {
+	// State holds the lists
+	State state.Store
+
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// SiteURL is the public base URL of landing pages; item URLs are
+	// relative if empty
+	SiteURL string
+
+	// Log records changes; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Get returns the list with the given ID. The featured list exists
+// even before anything is featured.
+func (r *Registry) Get(ctx context.Context, id string) (*List, error) {
+	var l List
+	err := r.State.Get(ctx, listsTable, id, &l)
+	if errors.Is(err, state.ErrNotFound) {
+		if id == Featured {
+			return &List{ID: Featured, Title: "Featured datasets", Entries: []Entry{}}, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Lists returns every list, the featured list first and the rest
+// ordered by ID.
+func (r *Registry) Lists(ctx context.Context) ([]List, error) {
+	all, err := state.List[List](ctx, r.State, listsTable)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(all, func(l List) bool { return l.ID == Featured }) {
+		featured, err := r.Get(ctx, Featured)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, *featured)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if (all[i].ID == Featured) != (all[j].ID == Featured) {
+			return all[i].ID == Featured
+		}
+		return all[i].ID < all[j].ID
+	})
+	return all, nil
+}
+
+// Create records a new, empty list.
+func (r *Registry) Create(ctx context.Context, id, title, description string) (*List, error) {
+	if err := authz.RequirePermission(identity.FromContext(ctx), authz.PermFeature); err != nil {
+		return nil, err
+	}
+	if !validID.MatchString(id) {
+		return nil, fmt.Errorf("invalid list ID %q: use lowercase letters, digits, and hyphens", id)
+	}
+	if id == Featured {
+		return nil, fmt.Errorf("%w: %s", ErrExists, id)
+	}
+	if _, err := r.Get(ctx, id); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrExists, id)
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if title = strings.TrimSpace(title); title == "" {
+		return nil, fmt.Errorf("a title is required")
+	}
+	now := r.now().UTC()
+	l := &List{
+		ID: id, Title: title, Description: strings.TrimSpace(description), Entries: []Entry{},
+		CreatedBy: identity.FromContext(ctx).String(), CreatedAt: now,
+	}
+	return l, r.save(ctx, l, "curated.create", nil)
+}
+
+// Describe changes the title and description of list id, keeping
+// those given empty.
+func (r *Registry) Describe(ctx context.Context, id, title, description string) (*List, error) {
+	l, err := r.change(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if title = strings.TrimSpace(title); title != "" {
+		l.Title = title
+	}
+	if description = strings.TrimSpace(description); description != "" {
+		l.Description = description
+	}
+	return l, r.save(ctx, l, "curated.update", nil)
+}
+
+// Delete removes list id. The featured list cannot be deleted.
+func (r *Registry) Delete(ctx context.Context, id string) error {
+	if _, err := r.change(ctx, id); err != nil {
+		return err
+	}
+	if id == Featured {
+		return fmt.Errorf("the featured list cannot be deleted; remove its entries instead")
+	}
+	if err := r.State.Delete(ctx, listsTable, id); err != nil {
+		return err
+	}
+	return r.record(ctx, "curated.delete", id, nil)
+}
+
+// Add places the published dataset ref on list id, or moves and
+// reschedules it if it is already listed.
+func (r *Registry) Add(ctx context.Context, id, ref string, p Placement) (*List, error) {
+	l, err := r.change(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	d, err := r.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if d.State != dataset.StatePublished {
+		return nil, fmt.Errorf("%s is %s; only published datasets can be listed", d.ID, d.State)
+	}
+	if !p.From.IsZero() && !p.Until.IsZero() && !p.Until.After(p.From) {
+		return nil, fmt.Errorf("the window must end after it starts")
+	}
+	e := Entry{DatasetID: d.ID, Note: strings.TrimSpace(p.Note), AddedBy: identity.FromContext(ctx).String(), AddedAt: r.now().UTC()}
+	if !p.From.IsZero() {
+		from := p.From.UTC()
+		e.From = &from
+	}
+	if !p.Until.IsZero() {
+		until := p.Until.UTC()
+		e.Until = &until
+	}
+	pos := len(l.Entries)
+	if i := l.index(d.ID); i >= 0 {
+		e.AddedBy, e.AddedAt = l.Entries[i].AddedBy, l.Entries[i].AddedAt
+		l.Entries = slices.Delete(l.Entries, i, i+1)
+		pos = i
+	}
+	if pos, err = position(p.Position, pos, len(l.Entries)); err != nil {
+		return nil, err
+	}
+	l.Entries = slices.Insert(l.Entries, pos, e)
+	return l, r.save(ctx, l, "curated.add", map[string]string{"dataset": d.ID, "position": fmt.Sprint(pos + 1)})
+}
+
+// Move moves datasetID to the 1-based position on list id.
+func (r *Registry) Move(ctx context.Context, id, datasetID string, pos int) (*List, error) {
+	l, err := r.change(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	i := l.index(datasetID)
+	if i < 0 {
+		return nil, fmt.Errorf("%s is not on %s", datasetID, id)
+	}
+	if pos < 1 {
+		return nil, fmt.Errorf("invalid position %d", pos)
+	}
+	e := l.Entries[i]
+	l.Entries = slices.Delete(l.Entries, i, i+1)
+	if pos, err = position(pos, i, len(l.Entries)); err != nil {
+		return nil, err
+	}
+	l.Entries = slices.Insert(l.Entries, pos, e)
+	return l, r.save(ctx, l, "curated.move", map[string]string{"dataset": datasetID, "position": fmt.Sprint(pos + 1)})
+}
+
+// position returns the index at which to insert into a list of n
+// entries given a 1-based position, or current if it is zero.
+func position(pos, current, n int) (int, error) {
+	switch {
+	case pos == 0:
+		return current, nil
+	case pos < 1 || pos > n+1:
+		return 0, fmt.Errorf("invalid position %d: the list has %d other entries", pos, n)
+	}
+	return pos - 1, nil
+}
+
+// Remove takes datasetID off list id.
+func (r *Registry) Remove(ctx context.Context, id, datasetID string) (*List, error) {
+	l, err := r.change(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	i := l.index(datasetID)
+	if i < 0 {
+		return nil, fmt.Errorf("%s is not on %s", datasetID, id)
+	}
+	l.Entries = slices.Delete(l.Entries, i, i+1)
+	return l, r.save(ctx, l, "curated.remove", map[string]string{"dataset": datasetID})
+}
+
+// Shown returns the items of list id shown now: those whose window is
+// open and whose dataset is published, in order.
+func (r *Registry) Shown(ctx context.Context, id string) (*List, []Item, error) {
+	l, err := r.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := r.now()
+	items := []Item{}
+	for _, e := range l.Entries {
+		if !e.Shown(now) {
+			continue
+		}
+		d, err := r.Datasets.Get(ctx, e.DatasetID)
+		if errors.Is(err, dataset.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if d.State != dataset.StatePublished {
+			continue
+		}
+		items = append(items, r.item(d, e))
+	}
+	return l, items, nil
+}
+
+func (r *Registry) item(d *dataset.Dataset, e Entry) Item {
+	it := Item{
+		ID: d.ID, DOI: d.DOI, Title: d.Title, Description: d.Description,
+		PublicationYear: d.PublicationYear, Collection: d.Collection,
+		URL:  strings.TrimRight(r.SiteURL, "/") + "/datasets/" + d.ID + "/",
+		Note: e.Note, Until: e.Until,
+	}
+	for _, c := range d.Creators {
+		it.Creators = append(it.Creators, c.Name)
+	}
+	return it
+}
+
+// change checks that the acting principal may change lists and
+// returns list id.
+func (r *Registry) change(ctx context.Context, id string) (*List, error) {
+	if err := authz.RequirePermission(identity.FromContext(ctx), authz.PermFeature); err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, id)
+}
+
+func (r *Registry) save(ctx context.Context, l *List, action string, details map[string]string) error {
+	l.UpdatedAt = r.now().UTC()
+	if l.CreatedAt.IsZero() {
+		l.CreatedAt = l.UpdatedAt
+	}
+	if err := r.State.Put(ctx, listsTable, l.ID, l); err != nil {
+		return err
+	}
+	return r.record(ctx, action, l.ID, details)
+}
+
+func (r *Registry) record(ctx context.Context, action, target string, details map[string]string) error {
+	if r.Log == nil {
+		return nil
+	}
+	return audit.Record(ctx, r.Log, action, target, details)
+}
+
+func (r *Registry) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,181 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package curated
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func ids(l *List) []string {
+	var out []string
+	for _, e := range l.Entries {
+		out = append(out, e.DatasetID)
+	}
+	return out
+}
+
+func TestRegistry(t *testing.T) {
+	s := state.NewMemoryStore()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := &Registry{State: s, Datasets: dataset.NewStore(s), SiteURL: "https://data.uni.edu/", Log: &audit.MemoryLog{}, Now: func() time.Time { return now }}
+	admin := identity.WithPrincipal(context.Background(), identity.Principal{ID: "root@uni.edu", Groups: []string{authz.AdminGroup}})
+	curator := identity.WithPrincipal(context.Background(), identity.Principal{ID: "cat@uni.edu", Groups: []string{"curators"}})
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", Title: "Soil cores", State: dataset.StatePublished, Creators: []dataset.Creator{{Name: "Lovelace, Ada"}}},
+		{ID: "ds-2", Title: "Letters", State: dataset.StatePublished},
+		{ID: "ds-3", Title: "Census", State: dataset.StatePublished},
+		{ID: "ds-4", Title: "Draft", State: dataset.StateDraft},
+	} {
+		if err := r.Datasets.Put(context.Background(), d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := r.Add(curator, Featured, "ds-1", Placement{}); !errors.Is(err, authz.ErrForbidden) {
+		t.Fatalf("Add() by curator error = %v, want ErrForbidden", err)
+	}
+	if _, err := r.Add(admin, Featured, "ds-4", Placement{}); err == nil {
+		t.Error("Add() of a draft succeeded")
+	}
+	for _, ref := range []string{"ds-1", "ds-2"} {
+		if _, err := r.Add(admin, Featured, ref, Placement{Note: "New"}); err != nil {
+			t.Fatalf("Add(%s) error = %v", ref, err)
+		}
+	}
+	// A scheduled entry is listed but not shown until its window opens.
+	l, err := r.Add(admin, Featured, "ds-3", Placement{Position: 1, From: now.Add(24 * time.Hour), Until: now.Add(48 * time.Hour)})
+	if got := ids(l); err != nil || len(got) != 3 || got[0] != "ds-3" {
+		t.Fatalf("Add() at position 1 = %v, %v", ids(l), err)
+	}
+	if _, err := r.Add(admin, Featured, "ds-2", Placement{From: now, Until: now}); err == nil {
+		t.Error("Add() with an empty window succeeded")
+	}
+
+	_, items, err := r.Shown(context.Background(), Featured)
+	if err != nil || len(items) != 2 || items[0].ID != "ds-1" || items[0].URL != "https://data.uni.edu/datasets/ds-1/" ||
+		items[0].Creators[0] != "Lovelace, Ada" || items[0].Note != "New" {
+		t.Errorf("Shown() = %+v, %v", items, err)
+	}
+	now = now.Add(36 * time.Hour)
+	if _, items, _ := r.Shown(context.Background(), Featured); len(items) != 3 || items[0].ID != "ds-3" || items[0].Until == nil {
+		t.Errorf("Shown() within window = %+v", items)
+	}
+	now = now.Add(24 * time.Hour)
+	if _, items, _ := r.Shown(context.Background(), Featured); len(items) != 2 {
+		t.Errorf("Shown() after window = %+v", items)
+	}
+
+	// Re-adding keeps the position and updates the note.
+	if l, err := r.Add(admin, Featured, "ds-2", Placement{Note: "Updated"}); err != nil || l.Entries[2].Note != "Updated" || l.Entries[2].DatasetID != "ds-2" {
+		t.Errorf("Add() of listed dataset = %+v, %v", l, err)
+	}
+	if l, err := r.Move(admin, Featured, "ds-2", 1); err != nil || ids(l)[0] != "ds-2" {
+		t.Errorf("Move() = %v, %v", l, err)
+	}
+	if _, err := r.Move(admin, Featured, "ds-2", 9); err == nil {
+		t.Error("Move() past the end succeeded")
+	}
+	if l, err := r.Remove(admin, Featured, "ds-3"); err != nil || len(l.Entries) != 2 {
+		t.Errorf("Remove() = %v, %v", l, err)
+	}
+	if err := r.Delete(admin, Featured); err == nil {
+		t.Error("Delete() of the featured list succeeded")
+	}
+
+	// Curated lists are created, listed after the featured list, and
+	// deleted.
+	if _, err := r.Create(admin, "teaching", "Teaching datasets", ""); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := r.Create(admin, "teaching", "Again", ""); !errors.Is(err, ErrExists) {
+		t.Errorf("Create() of existing list error = %v, want ErrExists", err)
+	}
+	if _, err := r.Create(admin, "Bad ID", "Bad", ""); err == nil {
+		t.Error("Create() with an invalid ID succeeded")
+	}
+	lists, err := r.Lists(context.Background())
+	if err != nil || len(lists) != 2 || lists[0].ID != Featured || lists[1].ID != "teaching" {
+		t.Errorf("Lists() = %+v, %v", lists, err)
+	}
+	if err := r.Delete(admin, "teaching"); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if _, err := r.Get(context.Background(), "teaching"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := state.NewMemoryStore()
+	r := &Registry{State: s, Datasets: dataset.NewStore(s)}
+	admin := identity.WithPrincipal(context.Background(), identity.Principal{ID: "root@uni.edu", Groups: []string{authz.AdminGroup}})
+	if err := r.Datasets.Put(context.Background(), &dataset.Dataset{ID: "ds-1", Title: "Soil cores", State: dataset.StatePublished}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Create(admin, "teaching", "Teaching datasets", "For coursework"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Add(admin, "teaching", "ds-1", Placement{}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewHandler(r))
+	defer srv.Close()
+
+	tests := []struct {
+		path string
+		want int
+		body string
+	}{
+		{"/lists", http.StatusOK, `[{"id":"featured","title":"Featured datasets","count":0},{"id":"teaching","title":"Teaching datasets","description":"For coursework","count":1}]`},
+		{"/featured", http.StatusOK, `{"id":"featured","title":"Featured datasets","items":[]}`},
+		{"/lists/teaching", http.StatusOK, `{"id":"teaching","title":"Teaching datasets","description":"For coursework","items":[{"id":"ds-1","title":"Soil cores","url":"/datasets/ds-1/"}]}`},
+		{"/lists/missing", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got any
+		_ = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET %s status = %d, want %d", tt.path, resp.StatusCode, tt.want)
+			continue
+		}
+		if tt.body == "" {
+			continue
+		}
+		var want any
+		_ = json.Unmarshal([]byte(tt.body), &want)
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("GET %s = %s, want %s", tt.path, gotJSON, wantJSON)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package curated
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// maxAge is how long clients may cache responses, so that scheduled
+// entries appear and expire close to their windows.
+const maxAge = "public, max-age=300"
+
+// Summary describes a list in the index of lists.
+type Summary struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Count       int    `json:"count"`
+}
+
+// Page is a list as served to the homepage.
+type Page struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Items       []Item `json:"items"`
+}
+
+// Handler serves the lists as JSON for the homepage:
+//
+//	GET /lists           every list with its number of items shown
+//	GET /lists/{id}      the items of a list shown now, in order
+//	GET /featured        the featured datasets
+type Handler struct {
+	registry *Registry
+	mux      *http.ServeMux
+}
+
+// NewHandler returns a handler serving the lists in r.
+func NewHandler(r *Registry) *Handler {
+	h := &Handler{registry: r, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /lists", h.serveLists)
+	h.mux.HandleFunc("GET /lists/{id}", h.serveList)
+	h.mux.HandleFunc("GET /featured", h.serveList)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) serveLists(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lists, err := h.registry.Lists(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	out := make([]Summary, 0, len(lists))
+	for _, l := range lists {
+		_, items, err := h.registry.Shown(ctx, l.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out = append(out, Summary{ID: l.ID, Title: l.Title, Description: l.Description, Count: len(items)})
+	}
+	writeJSON(w, out)
+}
+
+func (h *Handler) serveList(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		id = Featured
+	}
+	l, items, err := h.registry.Shown(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, Page{ID: l.ID, Title: l.Title, Description: l.Description, Items: items})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", maxAge)
+	_ = json.NewEncoder(w).Encode(v)
+}
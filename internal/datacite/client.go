@@ -0,0 +1,416 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package datacite provides a client for the DataCite REST API.
+package datacite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/trace"
+)
+
+// DefaultURL is the DataCite test API. Production deployments set
+// DATACITE_API_URL to https://api.datacite.org.
+const DefaultURL = "https://api.test.datacite.org"
+
+// DefaultMDSURL is the DataCite test MDS API, which registers DOI
+// media. Production deployments set DATACITE_MDS_URL to
+// https://mds.datacite.org.
+const DefaultMDSURL = "https://mds.test.datacite.org"
+
+// maxRetries is the number of times a throttled request is retried.
+const maxRetries = 5
+
+// Options configures a Client.
+type Options struct {
+	// BaseURL is the DataCite API root
+	BaseURL string
+
+	// MDSURL is the DataCite MDS API root, used for media
+	MDSURL string
+
+	// RepositoryID is the DataCite repository account (e.g. "ABC.XYZ")
+	RepositoryID string
+
+	// Password is the repository account password
+	Password string
+
+	// UsageToken is the JSON Web Token issued by DataCite for the usage
+	// hub, which does not accept repository passwords
+	UsageToken string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+
+	// Limiter throttles requests; unlimited if nil
+	Limiter *Limiter
+}
+
+// Client is a DataCite REST API client.
+type Client struct {
+	baseURL  string
+	mdsURL   string
+	repoID   string
+	password string
+	usage    string
+	http     *http.Client
+	limiter  *Limiter
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	c := &Client{
+		baseURL:  strings.TrimRight(opts.BaseURL, "/"),
+		mdsURL:   strings.TrimRight(opts.MDSURL, "/"),
+		repoID:   opts.RepositoryID,
+		password: opts.Password,
+		usage:    opts.UsageToken,
+		http:     opts.HTTPClient,
+		limiter:  opts.Limiter,
+	}
+	if c.baseURL == "" {
+		c.baseURL = DefaultURL
+	}
+	if c.mdsURL == "" {
+		c.mdsURL = DefaultMDSURL
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	if c.limiter == nil {
+		c.limiter = NewLimiter(LimiterOptions{})
+	}
+	return c
+}
+
+// Limiter returns the client's limiter.
+func (c *Client) Limiter() *Limiter {
+	return c.limiter
+}
+
+// Title is a DataCite title.
+type Title struct {
+	Title     string `json:"title"`
+	TitleType string `json:"titleType,omitempty"`
+	Lang      string `json:"lang,omitempty"`
+}
+
+// Creator is a DataCite creator.
+type Creator struct {
+	Name        string        `json:"name"`
+	NameType    string        `json:"nameType,omitempty"`
+	GivenName   string        `json:"givenName,omitempty"`
+	FamilyName  string        `json:"familyName,omitempty"`
+	Affiliation []Affiliation `json:"affiliation,omitempty"`
+
+	NameIdentifiers []NameIdentifier `json:"nameIdentifiers,omitempty"`
+}
+
+// NameIdentifier identifies a creator, e.g. by ORCID iD.
+type NameIdentifier struct {
+	NameIdentifier       string `json:"nameIdentifier"`
+	NameIdentifierScheme string `json:"nameIdentifierScheme"`
+	SchemeURI            string `json:"schemeUri,omitempty"`
+}
+
+// Affiliation is a creator's affiliation, identified by its ROR ID
+// when known.
+type Affiliation struct {
+	Name                        string `json:"name"`
+	AffiliationIdentifier       string `json:"affiliationIdentifier,omitempty"`
+	AffiliationIdentifierScheme string `json:"affiliationIdentifierScheme,omitempty"`
+	SchemeURI                   string `json:"schemeUri,omitempty"`
+}
+
+// Types holds the DataCite resource type.
+type Types struct {
+	ResourceTypeGeneral string `json:"resourceTypeGeneral"`
+	ResourceType        string `json:"resourceType,omitempty"`
+}
+
+// Date is a DataCite date, such as the "Available" date of an
+// embargoed dataset.
+type Date struct {
+	Date            string `json:"date"`
+	DateType        string `json:"dateType"`
+	DateInformation string `json:"dateInformation,omitempty"`
+}
+
+// RelatedIdentifier links a DOI to another identifier, such as the
+// other versions of a software release.
+type RelatedIdentifier struct {
+	RelatedIdentifier     string `json:"relatedIdentifier"`
+	RelatedIdentifierType string `json:"relatedIdentifierType"`
+	RelationType          string `json:"relationType"`
+}
+
+// FundingReference names a grant that funded the resource.
+type FundingReference struct {
+	FunderName           string `json:"funderName"`
+	FunderIdentifier     string `json:"funderIdentifier,omitempty"`
+	FunderIdentifierType string `json:"funderIdentifierType,omitempty"`
+	AwardNumber          string `json:"awardNumber,omitempty"`
+	AwardURI             string `json:"awardUri,omitempty"`
+	AwardTitle           string `json:"awardTitle,omitempty"`
+}
+
+// Attributes are the attributes of a DOI record.
+type Attributes struct {
+	DOI             string    `json:"doi,omitempty"`
+	Prefix          string    `json:"prefix,omitempty"`
+	Suffix          string    `json:"suffix,omitempty"`
+	Event           string    `json:"event,omitempty"`
+	State           string    `json:"state,omitempty"`
+	URL             string    `json:"url,omitempty"`
+	Titles          []Title   `json:"titles,omitempty"`
+	Creators        []Creator `json:"creators,omitempty"`
+	Publisher       string    `json:"publisher,omitempty"`
+	PublicationYear int       `json:"publicationYear,omitempty"`
+	Types           *Types    `json:"types,omitempty"`
+	Dates           []Date    `json:"dates,omitempty"`
+	Version         string    `json:"version,omitempty"`
+
+	RelatedIdentifiers []RelatedIdentifier `json:"relatedIdentifiers,omitempty"`
+	FundingReferences  []FundingReference  `json:"fundingReferences,omitempty"`
+}
+
+// DOI is a DataCite DOI record.
+type DOI struct {
+	ID         string     `json:"id"`
+	Attributes Attributes `json:"attributes"`
+}
+
+// document is the JSON:API envelope used by the DataCite API.
+type document struct {
+	Data struct {
+		ID         string     `json:"id,omitempty"`
+		Type       string     `json:"type"`
+		Attributes Attributes `json:"attributes"`
+	} `json:"data"`
+}
+
+// Media is a direct link to a DOI's content. DataCite content
+// negotiation on the DOI redirects requests for MediaType to URL.
+type Media struct {
+	MediaType string `json:"mediaType"`
+	URL       string `json:"url"`
+}
+
+// APIError is returned for non-2xx responses.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error implements error.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("datacite: HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// GetDOI returns the record for doi.
+func (c *Client) GetDOI(ctx context.Context, doi string) (*DOI, error) {
+	var out document
+	if err := c.do(ctx, http.MethodGet, "/dois/"+url.PathEscape(doi), nil, &out); err != nil {
+		return nil, err
+	}
+	return &DOI{ID: out.Data.ID, Attributes: out.Data.Attributes}, nil
+}
+
+// CreateDOI registers a new DOI with the given attributes.
+func (c *Client) CreateDOI(ctx context.Context, attrs Attributes) (*DOI, error) {
+	var in, out document
+	in.Data.Type = "dois"
+	in.Data.Attributes = attrs
+	if err := c.do(ctx, http.MethodPost, "/dois", &in, &out); err != nil {
+		return nil, err
+	}
+	return &DOI{ID: out.Data.ID, Attributes: out.Data.Attributes}, nil
+}
+
+// UpdateDOI updates the attributes of an existing DOI. Only non-empty
+// attributes are changed.
+func (c *Client) UpdateDOI(ctx context.Context, doi string, attrs Attributes) (*DOI, error) {
+	var in, out document
+	in.Data.ID = doi
+	in.Data.Type = "dois"
+	in.Data.Attributes = attrs
+	if err := c.do(ctx, http.MethodPut, "/dois/"+url.PathEscape(doi), &in, &out); err != nil {
+		return nil, err
+	}
+	return &DOI{ID: out.Data.ID, Attributes: out.Data.Attributes}, nil
+}
+
+// Prefixes returns the DOI prefixes assigned to the repository account.
+// It fails with an *APIError with status 401 if the account's
+// credentials are rejected.
+func (c *Client) Prefixes(ctx context.Context) ([]string, error) {
+	var out struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/prefixes?client-id="+url.QueryEscape(strings.ToLower(c.repoID)), nil, &out); err != nil {
+		return nil, err
+	}
+	var prefixes []string
+	for _, p := range out.Data {
+		prefixes = append(prefixes, p.ID)
+	}
+	return prefixes, nil
+}
+
+// DeleteDOI deletes doi, which must be a draft: findable and
+// registered DOIs cannot be deleted.
+func (c *Client) DeleteDOI(ctx context.Context, doi string) error {
+	return c.do(ctx, http.MethodDelete, "/dois/"+url.PathEscape(doi), nil, nil)
+}
+
+// SetMedia registers media with doi through the MDS API. Each media
+// type resolves to one URL: registering a type again replaces its URL.
+func (c *Client) SetMedia(ctx context.Context, doi string, media []Media) error {
+	var body strings.Builder
+	for _, m := range media {
+		fmt.Fprintf(&body, "%s=%s\n", m.MediaType, m.URL)
+	}
+	resp, err := c.retry(ctx, http.MethodPost, c.mdsURL+"/media/"+url.PathEscape(doi), "text/plain;charset=UTF-8", []byte(body.String()))
+	if err != nil {
+		return err
+	}
+	return decode(resp, nil)
+}
+
+// do sends a REST API request.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+	resp, err := c.retry(ctx, method, c.baseURL+path, "application/vnd.api+json", body)
+	if err != nil {
+		return err
+	}
+	return decode(resp, out)
+}
+
+// retry sends a request through the limiter, retrying throttled
+// requests after the delay DataCite asks for.
+func (c *Client) retry(ctx context.Context, method, rawURL, contentType string, body []byte) (_ *http.Response, err error) {
+	ctx, span := trace.StartClient(ctx, "DataCite "+method, trace.String("url.full", rawURL))
+	defer func() { span.End(err) }()
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, rawURL, contentType, body)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRetries {
+			delay := retryAfter(resp.Header.Get("Retry-After"), attempt)
+			_ = resp.Body.Close()
+			c.limiter.throttled()
+			if err := sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		span.SetAttrs(
+			trace.Int("http.response.status_code", int64(resp.StatusCode)),
+			trace.Int("http.request.resend_count", int64(attempt)),
+		)
+		return resp, nil
+	}
+}
+
+// send issues a single request while holding a limiter slot.
+func (c *Client) send(ctx context.Context, method, rawURL, contentType string, body []byte) (*http.Response, error) {
+	release, err := c.limiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", contentType)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.usage != "" && strings.HasPrefix(rawURL, c.baseURL+reportsPath) {
+		req.Header.Set("Authorization", "Bearer "+c.usage)
+	} else if c.repoID != "" {
+		req.SetBasicAuth(c.repoID, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("datacite request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// decode reads resp into out, converting error statuses to APIError.
+func decode(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// retryAfter returns the delay before retrying a throttled request,
+// honoring the Retry-After header and otherwise backing off
+// exponentially.
+func retryAfter(header string, attempt int) time.Duration {
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return time.Duration(1<<attempt) * 500 * time.Millisecond
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datacite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetDOI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dois/10.5555/abc" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "ABC.XYZ" || pass != "secret" {
+			t.Errorf("unexpected credentials %q %q", user, pass)
+		}
+		_, _ = w.Write([]byte(`{"data":{"id":"10.5555/abc","attributes":{"doi":"10.5555/abc","state":"findable"}}}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(Options{BaseURL: srv.URL, RepositoryID: "ABC.XYZ", Password: "secret"})
+	got, err := c.GetDOI(context.Background(), "10.5555/abc")
+	if err != nil {
+		t.Fatalf("GetDOI() error = %v", err)
+	}
+	if got.Attributes.State != "findable" {
+		t.Errorf("GetDOI() State = %v, want findable", got.Attributes.State)
+	}
+}
+
+func TestDeleteDOI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/dois/10.5555/draft" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := NewClient(Options{BaseURL: srv.URL, RepositoryID: "ABC.XYZ", Password: "secret"})
+	if err := c.DeleteDOI(context.Background(), "10.5555/draft"); err != nil {
+		t.Errorf("DeleteDOI() error = %v", err)
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/prefixes" || r.URL.Query().Get("client-id") != "abc.xyz" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if _, pass, _ := r.BasicAuth(); pass != "secret" {
+			http.Error(w, `{"errors":[{"title":"Bad credentials."}]}`, http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"id":"10.5555","type":"prefixes"},{"id":"10.80000","type":"prefixes"}]}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(Options{BaseURL: srv.URL, RepositoryID: "ABC.XYZ", Password: "secret"})
+	got, err := c.Prefixes(context.Background())
+	if err != nil || strings.Join(got, ",") != "10.5555,10.80000" {
+		t.Errorf("Prefixes() = %v, %v", got, err)
+	}
+
+	c = NewClient(Options{BaseURL: srv.URL, RepositoryID: "ABC.XYZ", Password: "wrong"})
+	var apiErr *APIError
+	if _, err := c.Prefixes(context.Background()); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Prefixes() with bad credentials error = %v, want APIError 401", err)
+	}
+}
+
+func TestSetMedia(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/media/10.5555/abc" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer srv.Close()
+
+	c := NewClient(Options{BaseURL: "http://rest.invalid", MDSURL: srv.URL})
+	err := c.SetMedia(context.Background(), "10.5555/abc", []Media{
+		{MediaType: "text/csv", URL: "https://example.edu/d/ds-1/1/cores.csv"},
+		{MediaType: "application/x-netcdf", URL: "https://example.edu/d/ds-1/1/grid.nc"},
+	})
+	if err != nil {
+		t.Fatalf("SetMedia() error = %v", err)
+	}
+	want := "text/csv=https://example.edu/d/ds-1/1/cores.csv\napplication/x-netcdf=https://example.edu/d/ds-1/1/grid.nc\n"
+	if body != want {
+		t.Errorf("SetMedia() body = %q, want %q", body, want)
+	}
+}
+
+func TestSubmitUsageReport(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if got := r.Header.Get("Authorization"); got != "Bearer hub-token" {
+			t.Errorf("unexpected authorization %q", got)
+		}
+		var report UsageReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil || report.Header.ID != ReportID {
+			t.Errorf("unexpected report %+v: %v", report.Header, err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"report":{"id":"r-1"}}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(Options{BaseURL: srv.URL, RepositoryID: "ABC.XYZ", Password: "secret", UsageToken: "hub-token"})
+	report := &UsageReport{Header: ReportHeader{Name: ReportName, ID: ReportID, Release: ReportRelease}}
+	id, err := c.SubmitUsageReport(context.Background(), "", report)
+	if err != nil || id != "r-1" {
+		t.Fatalf("SubmitUsageReport() = %q, %v, want r-1", id, err)
+	}
+	if _, err := c.SubmitUsageReport(context.Background(), "r-1", report); err != nil {
+		t.Fatalf("SubmitUsageReport() error = %v", err)
+	}
+	want := []string{"POST /reports", "PUT /reports/r-1"}
+	if strings.Join(requests, ", ") != strings.Join(want, ", ") {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+
+	if _, err := NewClient(Options{BaseURL: srv.URL}).SubmitUsageReport(context.Background(), "", report); err == nil {
+		t.Error("SubmitUsageReport() without a token succeeded, want error")
+	}
+}
+
+func TestUpdateDOIRetriesThrottled(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		var doc document
+		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+		}
+		if doc.Data.Attributes.URL != "https://example.edu/ds/1" {
+			t.Errorf("unexpected url %q", doc.Data.Attributes.URL)
+		}
+		_ = json.NewEncoder(w).Encode(doc)
+	}))
+	defer srv.Close()
+
+	c := NewClient(Options{BaseURL: srv.URL})
+	if _, err := c.UpdateDOI(context.Background(), "10.5555/abc", Attributes{URL: "https://example.edu/ds/1"}); err != nil {
+		t.Fatalf("UpdateDOI() error = %v", err)
+	}
+
+	s := c.Limiter().Stats()
+	if s.Requests != 2 || s.Throttled != 1 || s.Retries != 1 {
+		t.Errorf("Stats() = %+v, want 2 requests, 1 throttled, 1 retry", s)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := NewClient(Options{BaseURL: srv.URL})
+	_, err := c.GetDOI(context.Background(), "10.5555/missing")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("GetDOI() error = %v, want APIError 404", err)
+	}
+}
+
+func TestLimiterBudget(t *testing.T) {
+	l := NewLimiter(LimiterOptions{Budget: 2})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		release, err := l.Acquire(ctx)
+		if err != nil {
+			t.Fatalf("Acquire() #%d error = %v", i, err)
+		}
+		release()
+	}
+
+	if _, err := l.Acquire(ctx); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("Acquire() error = %v, want ErrBudgetExhausted", err)
+	}
+	if got := l.Remaining(); got != 0 {
+		t.Errorf("Remaining() = %d, want 0", got)
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	l := NewLimiter(LimiterOptions{Rate: 100, Burst: 1})
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		release, err := l.Acquire(ctx)
+		if err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+		release()
+	}
+
+	// One burst token, then four more at 10ms each.
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("5 requests at 100/s took %v, want at least 35ms", elapsed)
+	}
+	if l.Stats().Waited == 0 {
+		t.Error("Stats().Waited = 0, want > 0")
+	}
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	l := NewLimiter(LimiterOptions{Concurrency: 1})
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() error = %v, want DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datacite
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExhausted is returned when a request would exceed the
+// request budget configured on the limiter.
+var ErrBudgetExhausted = errors.New("datacite: request budget exhausted")
+
+// LimiterOptions configures a Limiter.
+type LimiterOptions struct {
+	// Rate is the sustained number of requests per second
+	Rate float64
+
+	// Burst is the number of requests that may be issued back to back
+	// before the sustained rate applies
+	Burst int
+
+	// Concurrency caps the number of in-flight requests
+	Concurrency int
+
+	// Budget caps the total number of requests; zero means unlimited
+	Budget int
+}
+
+// Limiter smooths DataCite requests with a token bucket, caps
+// concurrency, and enforces an overall request budget.
+type Limiter struct {
+	rate   float64
+	burst  float64
+	budget int
+	sem    chan struct{}
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	stats  Stats
+}
+
+// Stats reports limiter activity.
+type Stats struct {
+	// Requests is the number of requests admitted
+	Requests int
+
+	// Throttled is the number of responses DataCite rejected with 429
+	Throttled int
+
+	// Retries is the number of retried requests
+	Retries int
+
+	// Waited is the total time spent waiting for tokens
+	Waited time.Duration
+}
+
+// NewLimiter returns a limiter with the given options. Zero values
+// disable the corresponding limit.
+func NewLimiter(opts LimiterOptions) *Limiter {
+	l := &Limiter{
+		rate:   opts.Rate,
+		burst:  float64(opts.Burst),
+		budget: opts.Budget,
+		last:   time.Now(),
+	}
+	if l.burst < 1 {
+		l.burst = 1
+	}
+	l.tokens = l.burst
+	if opts.Concurrency > 0 {
+		l.sem = make(chan struct{}, opts.Concurrency)
+	}
+	return l
+}
+
+// Acquire blocks until a request may be issued, then returns a release
+// function that must be called when the request completes.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	if err := l.take(ctx); err != nil {
+		return nil, err
+	}
+
+	if l.sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return func() { <-l.sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// take reserves a token and a unit of budget, sleeping until the token
+// becomes available.
+func (l *Limiter) take(ctx context.Context) error {
+	l.mu.Lock()
+	if l.budget > 0 && l.stats.Requests >= l.budget {
+		l.mu.Unlock()
+		return ErrBudgetExhausted
+	}
+	l.stats.Requests++
+
+	var wait time.Duration
+	if l.rate > 0 {
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+		l.tokens--
+		if l.tokens < 0 {
+			wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+			l.stats.Waited += wait
+		}
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Remaining returns the number of requests left in the budget, or -1
+// if the limiter has no budget.
+func (l *Limiter) Remaining() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.budget <= 0 {
+		return -1
+	}
+	return l.budget - l.stats.Requests
+}
+
+// Stats returns a snapshot of limiter activity.
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// throttled records a 429 response and a retry.
+func (l *Limiter) throttled() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.Throttled++
+	l.stats.Retries++
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datacite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// reportsPath is the usage hub's endpoint for SUSHI reports.
+const reportsPath = "/reports"
+
+// SUSHI dataset report identifiers under the COUNTER Code of Practice
+// for Research Data.
+const (
+	ReportName    = "dataset report"
+	ReportID      = "DSR"
+	ReportRelease = "rd1"
+)
+
+// ExceptionNoUsage is the SUSHI exception for a period without usage,
+// which the usage hub requires of an empty report.
+const ExceptionNoUsage = 3030
+
+// UsageReport is a SUSHI dataset report submitted to the DataCite usage
+// hub.
+type UsageReport struct {
+	Header   ReportHeader    `json:"report-header"`
+	Datasets []ReportDataset `json:"report-datasets"`
+}
+
+// ReportHeader describes a usage report.
+type ReportHeader struct {
+	Name       string            `json:"report-name"`
+	ID         string            `json:"report-id"`
+	Release    string            `json:"release"`
+	Created    string            `json:"created"`
+	CreatedBy  string            `json:"created-by"`
+	Period     Period            `json:"reporting-period"`
+	Filters    []TypedValue      `json:"report-filters"`
+	Attributes []TypedValue      `json:"report-attributes"`
+	Exceptions []ReportException `json:"exceptions"`
+}
+
+// Period is a reporting period of whole days, as YYYY-MM-DD.
+type Period struct {
+	Begin string `json:"begin-date"`
+	End   string `json:"end-date"`
+}
+
+// TypedValue is a typed identifier or attribute, such as a DOI.
+type TypedValue struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// ReportException reports a condition affecting a whole report.
+type ReportException struct {
+	Code     int    `json:"code"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Data     string `json:"data,omitempty"`
+}
+
+// ReportDataset is the usage of one dataset.
+type ReportDataset struct {
+	ID          []TypedValue  `json:"dataset-id"`
+	Title       string        `json:"dataset-title"`
+	YOP         string        `json:"yop,omitempty"`
+	URI         string        `json:"uri,omitempty"`
+	DataType    string        `json:"data-type"`
+	Platform    string        `json:"platform"`
+	Publisher   string        `json:"publisher"`
+	PublisherID []TypedValue  `json:"publisher-id"`
+	Performance []Performance `json:"performance"`
+}
+
+// Performance is a dataset's usage in one period.
+type Performance struct {
+	Period    Period     `json:"period"`
+	Instances []Instance `json:"instance"`
+}
+
+// Instance is the count of one COUNTER metric.
+type Instance struct {
+	AccessMethod string `json:"access-method"`
+	MetricType   string `json:"metric-type"`
+	Count        int    `json:"count"`
+}
+
+// SubmitUsageReport submits r to the usage hub and returns its report
+// ID. An empty id creates a report; otherwise the report with that ID
+// is replaced, as when a month is resubmitted after late logs arrive.
+// The client must have a UsageToken.
+func (c *Client) SubmitUsageReport(ctx context.Context, id string, r *UsageReport) (string, error) {
+	if c.usage == "" {
+		return "", fmt.Errorf("a DataCite usage hub token is required to submit usage reports")
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode usage report: %w", err)
+	}
+	method, rawURL := http.MethodPost, c.baseURL+reportsPath
+	if id != "" {
+		method, rawURL = http.MethodPut, rawURL+"/"+url.PathEscape(id)
+	}
+	resp, err := c.retry(ctx, method, rawURL, "application/json", body)
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		Report struct {
+			ID string `json:"id"`
+		} `json:"report"`
+	}
+	if err := decode(resp, &out); err != nil {
+		return "", err
+	}
+	if out.Report.ID == "" {
+		return id, nil
+	}
+	return out.Report.ID, nil
+}
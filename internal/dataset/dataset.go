@@ -0,0 +1,655 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dataset defines dataset records and their manifests.
+package dataset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// Tables used in the state store.
+const (
+	datasetsTable = "datasets"
+	doisTable     = "dataset-dois"
+	arksTable     = "dataset-arks"
+	handlesTable  = "dataset-handles"
+)
+
+// ErrNotFound is returned when a dataset does not exist.
+var ErrNotFound = errors.New("dataset not found")
+
+// State is the lifecycle state of a dataset.
+type State string
+
+// Dataset states.
+const (
+	StateDraft      State = "draft"
+	StatePublished  State = "published"
+	StateTombstoned State = "tombstoned"
+)
+
+// Creator is a dataset author. User links a creator to a depositor
+// profile, whose verified ORCID iD is used in place of a typed one.
+type Creator struct {
+	Name           string `json:"name"`
+	ORCID          string `json:"orcid,omitempty"`
+	Affiliation    string `json:"affiliation,omitempty"`
+	AffiliationROR string `json:"affiliationRor,omitempty"`
+	User           string `json:"user,omitempty"`
+}
+
+// File is one entry in a version manifest.
+type File struct {
+	// Path is the file path relative to the dataset root
+	Path string `json:"path"`
+
+	// Bucket and Key locate the stored object
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+
+	// Size is the object size in bytes
+	Size int64 `json:"size"`
+
+	// SHA256 is the hex-encoded content digest
+	SHA256 string `json:"sha256,omitempty"`
+
+	// BLAKE3 is the hex-encoded BLAKE3 digest, recorded alongside
+	// SHA256 for faster fixity checks
+	BLAKE3 string `json:"blake3,omitempty"`
+
+	// ContentType is the media type
+	ContentType string `json:"contentType,omitempty"`
+
+	// Tier is the storage tier holding the object: empty for the
+	// primary store, TierCloud once it was tiered to AWS
+	Tier string `json:"tier,omitempty"`
+
+	// SourceVersion is the S3 version ID of the object a snapshot
+	// copied the file from; empty if its source bucket is unversioned
+	SourceVersion string `json:"sourceVersion,omitempty"`
+
+	// Derivatives are preservation copies of the file in normalized
+	// formats
+	Derivatives []Derivative `json:"derivatives,omitempty"`
+}
+
+// TierCloud is the Tier of files moved from the primary store to the
+// cloud buckets of hybrid tiering.
+const TierCloud = "cloud"
+
+// Derivative is a preservation copy of a file made by a format
+// converter, stored alongside the original.
+type Derivative struct {
+	// Converter names the converter that made the copy
+	Converter string `json:"converter"`
+
+	Bucket      string    `json:"bucket"`
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	BLAKE3      string    `json:"blake3,omitempty"`
+	ContentType string    `json:"contentType"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Version is a numbered snapshot of a dataset's files. Metadata is the
+// dataset's descriptive metadata when the version was published; nil
+// for drafts and versions published before it was recorded. Pruned is
+// set once an old version's files are archived or deleted.
+type Version struct {
+	Number      int        `json:"number"`
+	DOI         string     `json:"doi,omitempty"`
+	Tag         string     `json:"tag,omitempty"`
+	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+	Files       []File     `json:"files"`
+	Metadata    *Metadata  `json:"metadata,omitempty"`
+	Snapshot    *Snapshot  `json:"snapshot,omitempty"`
+	Pruned      *Pruning   `json:"pruned,omitempty"`
+}
+
+// Archived reports whether v's files were moved to archival storage,
+// from which they must be restored before they can be read.
+func (v *Version) Archived() bool {
+	return v.Pruned != nil && v.Pruned.Action == PruneArchive
+}
+
+// Removed reports whether v's files were deleted. Its manifest and DOI
+// remain, as a tombstone recording what it held.
+func (v *Version) Removed() bool {
+	return v.Pruned != nil && v.Pruned.Action == PruneDelete
+}
+
+// Snapshot records that a version's files are a point-in-time copy of
+// an external source that keeps changing, such as a growing S3 prefix
+// or the exports of a database.
+type Snapshot struct {
+	// Source is the S3 location copied, s3://bucket/prefix
+	Source string `json:"source"`
+
+	// Time is when the copy was taken
+	Time time.Time `json:"time"`
+
+	// Label describes the state captured, e.g. "as submitted to
+	// Nature"
+	Label string `json:"label,omitempty"`
+}
+
+// Pruning actions.
+const (
+	// PruneArchive moves a version's files to archival storage
+	PruneArchive = "archive"
+
+	// PruneDelete deletes a version's files
+	PruneDelete = "delete"
+)
+
+// Pruning records that an old version's files were archived or deleted
+// under its collection's version retention policy.
+type Pruning struct {
+	// Action is PruneArchive or PruneDelete
+	Action string `json:"action"`
+
+	Time time.Time `json:"time"`
+
+	// Policy describes the policy applied, e.g. "4 online, 8 archived,
+	// older deleted"
+	Policy string `json:"policy"`
+}
+
+// Software describes the source repository of a software record, whose
+// versions are captured releases.
+type Software struct {
+	// Repository is the repository's web URL
+	Repository string `json:"repository"`
+
+	// License is the SPDX identifier of the license
+	License string `json:"license,omitempty"`
+}
+
+// Embargo withholds a dataset's files until a release date while its
+// metadata remains findable.
+type Embargo struct {
+	// Until is when the files become accessible
+	Until time.Time `json:"until"`
+
+	// ReleaseAccess is the access level applied on release
+	ReleaseAccess storage.Access `json:"releaseAccess"`
+}
+
+// Supersession links a superseded dataset and the dataset replacing
+// it. The superseded dataset stays published and citable, but its
+// landing page points to its replacement, and the replacement's to the
+// datasets it replaces.
+type Supersession struct {
+	// Dataset is the ID of the other dataset
+	Dataset string `json:"dataset"`
+
+	// Title is the other dataset's title when it was superseded
+	Title string `json:"title"`
+
+	// URL is the resolver URL of the other dataset's identifier
+	URL string `json:"url"`
+
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+
+	// Warn shows a warning before each download of the superseded
+	// dataset's files; set on the superseded dataset only
+	Warn bool `json:"warn,omitempty"`
+}
+
+// Agreement is a data use agreement that users must accept before
+// they are given access to a dataset's files.
+type Agreement struct {
+	// Version identifies the agreement text; acceptances apply to one
+	// version only
+	Version string `json:"version"`
+
+	// Title is shown to users when they are asked to accept
+	Title string `json:"title,omitempty"`
+
+	// Document is the URL path of the agreement document
+	Document string `json:"document"`
+
+	// SHA256 is the digest of the agreement document
+	SHA256 string `json:"sha256"`
+}
+
+// Event is an entry in a dataset's history of significant changes.
+type Event struct {
+	// Time is when the change was made
+	Time time.Time `json:"time"`
+
+	// Actor is the principal who made the change
+	Actor string `json:"actor"`
+
+	// Action names the change, e.g. "embargo.extend"
+	Action string `json:"action"`
+
+	// Reason is the justification given for the change
+	Reason string `json:"reason,omitempty"`
+
+	// Details holds action-specific values
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Dataset is a dataset record. Owner is the principal investigator
+// responsible for a dataset deposited on their behalf by Depositor.
+// TitleLanguage and DescriptionLanguage are BCP 47 tags of the
+// languages the title and description are written in, if known.
+type Dataset struct {
+	ID                  string              `json:"id"`
+	DOI                 string              `json:"doi,omitempty"`
+	ARK                 string              `json:"ark,omitempty"`
+	Handle              string              `json:"handle,omitempty"`
+	Title               string              `json:"title"`
+	Description         string              `json:"description,omitempty"`
+	TitleLanguage       string              `json:"titleLanguage,omitempty"`
+	DescriptionLanguage string              `json:"descriptionLanguage,omitempty"`
+	Creators            []Creator           `json:"creators,omitempty"`
+	PublicationYear     int                 `json:"publicationYear,omitempty"`
+	Collection          string              `json:"collection,omitempty"`
+	RAiDs               []string            `json:"raids,omitempty"`
+	Awards              []string            `json:"awards,omitempty"`
+	ResourceType        string              `json:"resourceType,omitempty"`
+	Subjects            []string            `json:"subjects,omitempty"`
+	License             string              `json:"license,omitempty"`
+	GeoLocations        []GeoLocation       `json:"geoLocations,omitempty"`
+	Related             []RelatedIdentifier `json:"relatedIdentifiers,omitempty"`
+	Software            *Software           `json:"software,omitempty"`
+	Source              string              `json:"source,omitempty"`
+	Owner               string              `json:"owner,omitempty"`
+	Depositor           string              `json:"depositor,omitempty"`
+	Access              storage.Access      `json:"access"`
+	State               State               `json:"state"`
+	Embargo             *Embargo            `json:"embargo,omitempty"`
+	Agreement           *Agreement          `json:"agreement,omitempty"`
+	ACL                 *authz.ACL          `json:"acl,omitempty"`
+	Restriction         *authz.Restriction  `json:"restriction,omitempty"`
+	Retention           string              `json:"retention,omitempty"`
+	SupersededBy        *Supersession       `json:"supersededBy,omitempty"`
+	Supersedes          []Supersession      `json:"supersedes,omitempty"`
+	Workspace           *Workspace          `json:"workspace,omitempty"`
+	Versions            []Version           `json:"versions,omitempty"`
+	History             []Event             `json:"history,omitempty"`
+	CreatedAt           time.Time           `json:"createdAt"`
+	UpdatedAt           time.Time           `json:"updatedAt"`
+}
+
+// Latest returns the highest-numbered version, which may be an
+// unpublished draft, or nil if there are none.
+func (d *Dataset) Latest() *Version {
+	if len(d.Versions) == 0 {
+		return nil
+	}
+	latest := &d.Versions[0]
+	for i := range d.Versions {
+		if d.Versions[i].Number > latest.Number {
+			latest = &d.Versions[i]
+		}
+	}
+	return latest
+}
+
+// Version returns version n, or nil if it does not exist.
+func (d *Dataset) Version(n int) *Version {
+	for i := range d.Versions {
+		if d.Versions[i].Number == n {
+			return &d.Versions[i]
+		}
+	}
+	return nil
+}
+
+// Embargoed reports whether d is under an embargo that has not yet
+// been released at time now.
+func (d *Dataset) Embargoed(now time.Time) bool {
+	return d.Embargo != nil && now.Before(d.Embargo.Until)
+}
+
+// Resource returns d as an authorization resource.
+func (d *Dataset) Resource() authz.Resource {
+	return authz.Resource{ID: d.ID, Access: d.Access, ACL: d.ACL, Restriction: d.Restriction}
+}
+
+// Fences returns the stored locations covered by d's restriction: one
+// per bucket and dataset key prefix, so that policies written from them
+// follow the layout the files were actually stored with. It returns nil
+// if d is unrestricted.
+func (d *Dataset) Fences() []authz.Fence {
+	if d.Restriction.IsZero() {
+		return nil
+	}
+	marker := "datasets/" + d.ID + "/"
+	seen := make(map[[2]string]bool)
+	var out []authz.Fence
+	for _, v := range d.Versions {
+		for _, f := range v.Files {
+			prefix := f.Key
+			if i := strings.Index(f.Key, marker); i >= 0 {
+				prefix = f.Key[:i+len(marker)]
+			}
+			k := [2]string{f.Bucket, prefix}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			out = append(out, authz.Fence{DatasetID: d.ID, Bucket: f.Bucket, Prefix: prefix, Restriction: *d.Restriction})
+		}
+	}
+	return out
+}
+
+// Observer is told of every change to the catalog, such as a search
+// index kept in step with it. Observers are called after the change is
+// stored and cannot fail it; they must record their own failures for
+// retry.
+type Observer interface {
+	// Saved is called after d is stored
+	Saved(ctx context.Context, d *Dataset)
+
+	// Deleted is called after the dataset with ID id is removed
+	Deleted(ctx context.Context, id string)
+}
+
+// Store persists datasets in a state store.
+type Store struct {
+	s         state.Store
+	observers []Observer
+}
+
+// NewStore returns a dataset store backed by s.
+func NewStore(s state.Store) *Store {
+	return &Store{s: s}
+}
+
+// Observe registers o to be told of every Put and Delete.
+func (st *Store) Observe(o Observer) {
+	st.observers = append(st.observers, o)
+}
+
+// Get returns the dataset with the given ID.
+func (st *Store) Get(ctx context.Context, id string) (*Dataset, error) {
+	var d Dataset
+	if err := st.s.Get(ctx, datasetsTable, id, &d); err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ByDOI returns the dataset registered under doi, which may be the
+// dataset DOI or the DOI of one of its versions.
+func (st *Store) ByDOI(ctx context.Context, doi string) (*Dataset, error) {
+	var id string
+	if err := st.s.Get(ctx, doisTable, NormalizeDOI(doi), &id); err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, doi)
+		}
+		return nil, err
+	}
+	return st.Get(ctx, id)
+}
+
+// ByARK returns the dataset registered under ark.
+func (st *Store) ByARK(ctx context.Context, ark string) (*Dataset, error) {
+	var id string
+	if err := st.s.Get(ctx, arksTable, NormalizeARK(ark), &id); err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, ark)
+		}
+		return nil, err
+	}
+	return st.Get(ctx, id)
+}
+
+// ByHandle returns the dataset registered under handle.
+func (st *Store) ByHandle(ctx context.Context, handle string) (*Dataset, error) {
+	var id string
+	if err := st.s.Get(ctx, handlesTable, NormalizeHandle(handle), &id); err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, handle)
+		}
+		return nil, err
+	}
+	return st.Get(ctx, id)
+}
+
+// Resolve returns the dataset identified by ref, which may be a
+// dataset ID, a DOI, an ARK, or a handle.
+func (st *Store) Resolve(ctx context.Context, ref string) (*Dataset, error) {
+	d, err := st.Get(ctx, ref)
+	if !errors.Is(err, ErrNotFound) {
+		return d, err
+	}
+	if IsARK(ref) {
+		return st.ByARK(ctx, ref)
+	}
+	d, err = st.ByDOI(ctx, ref)
+	if errors.Is(err, ErrNotFound) {
+		// DOIs are handles, so a bare handle reads like a DOI.
+		return st.ByHandle(ctx, ref)
+	}
+	return d, err
+}
+
+// Put stores d and indexes its DOIs, ARK, and handle. It fails with
+// ErrImmutable if d changes a published version.
+func (st *Store) Put(ctx context.Context, d *Dataset) error {
+	if d.ID == "" {
+		return fmt.Errorf("dataset ID cannot be empty")
+	}
+	stored, err := st.Get(ctx, d.ID)
+	switch {
+	case err == nil:
+		if err := checkImmutable(stored, d); err != nil {
+			return err
+		}
+	case !errors.Is(err, ErrNotFound):
+		return err
+	}
+	now := time.Now().UTC()
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = now
+	}
+	d.UpdatedAt = now
+
+	if err := st.s.Put(ctx, datasetsTable, d.ID, d); err != nil {
+		return err
+	}
+	for _, doi := range d.DOIs() {
+		if err := st.s.Put(ctx, doisTable, NormalizeDOI(doi), d.ID); err != nil {
+			return err
+		}
+	}
+	if d.ARK != "" {
+		if err := st.s.Put(ctx, arksTable, NormalizeARK(d.ARK), d.ID); err != nil {
+			return err
+		}
+	}
+	if d.Handle != "" {
+		if err := st.s.Put(ctx, handlesTable, NormalizeHandle(d.Handle), d.ID); err != nil {
+			return err
+		}
+	}
+	for _, o := range st.observers {
+		o.Saved(ctx, d)
+	}
+	return nil
+}
+
+// Delete removes a dataset record and its identifier index entries.
+func (st *Store) Delete(ctx context.Context, id string) error {
+	d, err := st.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, doi := range d.DOIs() {
+		if err := st.s.Delete(ctx, doisTable, NormalizeDOI(doi)); err != nil {
+			return err
+		}
+	}
+	if d.ARK != "" {
+		if err := st.s.Delete(ctx, arksTable, NormalizeARK(d.ARK)); err != nil {
+			return err
+		}
+	}
+	if d.Handle != "" {
+		if err := st.s.Delete(ctx, handlesTable, NormalizeHandle(d.Handle)); err != nil {
+			return err
+		}
+	}
+	if err := st.s.Delete(ctx, datasetsTable, id); err != nil {
+		return err
+	}
+	for _, o := range st.observers {
+		o.Deleted(ctx, id)
+	}
+	return nil
+}
+
+// DOIIndex returns the DOI index, mapping each normalized DOI to the
+// ID of the dataset it was registered to.
+func (st *Store) DOIIndex(ctx context.Context) (map[string]string, error) {
+	keys, err := st.s.Keys(ctx, doisTable)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]string, len(keys))
+	for _, doi := range keys {
+		var id string
+		if err := st.s.Get(ctx, doisTable, doi, &id); err != nil {
+			return nil, err
+		}
+		index[doi] = id
+	}
+	return index, nil
+}
+
+// Unindex removes doi from the DOI index without touching any dataset.
+func (st *Store) Unindex(ctx context.Context, doi string) error {
+	return st.s.Delete(ctx, doisTable, NormalizeDOI(doi))
+}
+
+// List returns all datasets ordered by ID.
+func (st *Store) List(ctx context.Context) ([]*Dataset, error) {
+	all, err := state.List[Dataset](ctx, st.s, datasetsTable)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Dataset, len(all))
+	for i := range all {
+		out[i] = &all[i]
+	}
+	return out, nil
+}
+
+// References counts the manifest entries referring to each stored
+// object: the files of every version of every dataset and their
+// preservation copies; versions whose files were deleted refer to
+// nothing. An object shared by versions or datasets is removed only
+// when its count falls to zero.
+func (st *Store) References(ctx context.Context) (map[storage.Location]int, error) {
+	datasets, err := st.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dataset manifests: %w", err)
+	}
+	refs := make(map[storage.Location]int)
+	for _, d := range datasets {
+		for _, v := range d.Versions {
+			if v.Removed() {
+				continue
+			}
+			for _, f := range v.Files {
+				refs[storage.Location{Bucket: f.Bucket, Key: f.Key}]++
+				for _, dv := range f.Derivatives {
+					refs[storage.Location{Bucket: dv.Bucket, Key: dv.Key}]++
+				}
+			}
+		}
+	}
+	return refs, nil
+}
+
+// DOIs returns the dataset DOI and all version DOIs.
+func (d *Dataset) DOIs() []string {
+	var dois []string
+	if d.DOI != "" {
+		dois = append(dois, d.DOI)
+	}
+	for _, v := range d.Versions {
+		if v.DOI != "" {
+			dois = append(dois, v.DOI)
+		}
+	}
+	return dois
+}
+
+// NormalizeDOI strips resolver and "doi:" prefixes and lowercases doi,
+// since DOIs are case-insensitive.
+func NormalizeDOI(doi string) string {
+	doi = strings.TrimSpace(doi)
+	for _, p := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "doi:"} {
+		if len(doi) >= len(p) && strings.EqualFold(doi[:len(p)], p) {
+			doi = doi[len(p):]
+			break
+		}
+	}
+	return strings.ToLower(doi)
+}
+
+// IsARK reports whether ref is an ARK, bare or through a resolver.
+func IsARK(ref string) bool {
+	return strings.HasPrefix(strings.ToLower(NormalizeARK(ref)), "ark:")
+}
+
+// NormalizeARK strips resolver prefixes from ark and writes its label
+// as "ark:/". Unlike DOIs, ARKs are case-sensitive.
+func NormalizeARK(ark string) string {
+	ark = strings.TrimSpace(ark)
+	for _, p := range []string{"https://n2t.net/", "http://n2t.net/", "https://arks.org/", "http://arks.org/"} {
+		if len(ark) >= len(p) && strings.EqualFold(ark[:len(p)], p) {
+			ark = ark[len(p):]
+			break
+		}
+	}
+	if len(ark) >= 4 && strings.EqualFold(ark[:4], "ark:") {
+		ark = "ark:/" + strings.TrimPrefix(ark[4:], "/")
+	}
+	return ark
+}
+
+// NormalizeHandle strips resolver and "hdl:" prefixes from handle and
+// lowercases it, since handles are case-insensitive.
+func NormalizeHandle(handle string) string {
+	handle = strings.TrimSpace(handle)
+	for _, p := range []string{"https://hdl.handle.net/", "http://hdl.handle.net/", "hdl:"} {
+		if len(handle) >= len(p) && strings.EqualFold(handle[:len(p)], p) {
+			handle = handle[len(p):]
+			break
+		}
+	}
+	return strings.ToLower(handle)
+}
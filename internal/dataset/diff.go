@@ -0,0 +1,228 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataset
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// File changes between versions.
+const (
+	FileAdded    = "added"
+	FileRemoved  = "removed"
+	FileModified = "modified"
+	FileRenamed  = "renamed"
+)
+
+// FileChange is a file that differs between two versions. Files are
+// compared by path and SHA-256 digest; a file removed from one path and
+// added at another with the same digest is renamed.
+type FileChange struct {
+	Change string `json:"change"`
+	Path   string `json:"path"`
+
+	// From is the path in the earlier version of a renamed file
+	From string `json:"from,omitempty"`
+
+	OldSize   int64  `json:"oldSize,omitempty"`
+	NewSize   int64  `json:"newSize,omitempty"`
+	OldSHA256 string `json:"oldSha256,omitempty"`
+	NewSHA256 string `json:"newSha256,omitempty"`
+}
+
+// FieldChange is a metadata field that differs between two versions,
+// with its values one per line.
+type FieldChange struct {
+	Field string   `json:"field"`
+	Old   []string `json:"old,omitempty"`
+	New   []string `json:"new,omitempty"`
+}
+
+// Diff is the difference between two versions of a dataset.
+type Diff struct {
+	DatasetID string `json:"datasetId"`
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+
+	Files []FileChange `json:"files"`
+
+	// Unchanged counts the files identical in both versions
+	Unchanged int `json:"unchanged"`
+
+	// Metadata lists the changed metadata fields; nil if either
+	// version's metadata was not recorded
+	Metadata []FieldChange `json:"metadata,omitempty"`
+
+	// MetadataRecorded reports whether both versions' metadata was
+	// known, so that Metadata is meaningful
+	MetadataRecorded bool `json:"metadataRecorded"`
+}
+
+// Empty reports whether the versions have the same files and metadata.
+func (df *Diff) Empty() bool {
+	return len(df.Files) == 0 && len(df.Metadata) == 0
+}
+
+// VersionMetadata returns the metadata of version v of d: the metadata
+// recorded when it was published, or the staged metadata for the draft,
+// which is published with it. It returns nil for versions published before
+// their metadata was recorded.
+func (d *Dataset) VersionMetadata(v *Version) *Metadata {
+	switch {
+	case v.Metadata != nil:
+		return v.Metadata
+	case v.PublishedAt == nil && v == d.Draft():
+		return d.Staged()
+	}
+	return nil
+}
+
+// DiffVersions returns the changes from version a to version b of d.
+func DiffVersions(d *Dataset, a, b *Version) *Diff {
+	df := &Diff{DatasetID: d.ID, From: a.Number, To: b.Number, Files: []FileChange{}}
+	old := make(map[string]File, len(a.Files))
+	for _, f := range a.Files {
+		old[f.Path] = f
+	}
+	var added []File
+	for _, f := range b.Files {
+		prev, ok := old[f.Path]
+		delete(old, f.Path)
+		switch {
+		case !ok:
+			added = append(added, f)
+		case prev.Size == f.Size && strings.EqualFold(prev.SHA256, f.SHA256):
+			df.Unchanged++
+		default:
+			df.Files = append(df.Files, FileChange{Change: FileModified, Path: f.Path,
+				OldSize: prev.Size, NewSize: f.Size, OldSHA256: strings.ToLower(prev.SHA256), NewSHA256: strings.ToLower(f.SHA256)})
+		}
+	}
+
+	// Files that moved keep their digest.
+	removed := make(map[string][]File)
+	for _, f := range old {
+		if f.SHA256 != "" {
+			removed[strings.ToLower(f.SHA256)] = append(removed[strings.ToLower(f.SHA256)], f)
+		}
+	}
+	for _, rs := range removed {
+		slices.SortFunc(rs, func(x, y File) int { return cmp.Compare(x.Path, y.Path) })
+	}
+	slices.SortFunc(added, func(x, y File) int { return cmp.Compare(x.Path, y.Path) })
+	for _, f := range added {
+		sum := strings.ToLower(f.SHA256)
+		if rs := removed[sum]; sum != "" && len(rs) > 0 {
+			removed[sum] = rs[1:]
+			delete(old, rs[0].Path)
+			df.Files = append(df.Files, FileChange{Change: FileRenamed, Path: f.Path, From: rs[0].Path,
+				OldSize: rs[0].Size, NewSize: f.Size, OldSHA256: sum, NewSHA256: sum})
+			continue
+		}
+		df.Files = append(df.Files, FileChange{Change: FileAdded, Path: f.Path, NewSize: f.Size, NewSHA256: sum})
+	}
+	for _, f := range old {
+		df.Files = append(df.Files, FileChange{Change: FileRemoved, Path: f.Path, OldSize: f.Size, OldSHA256: strings.ToLower(f.SHA256)})
+	}
+	slices.SortFunc(df.Files, func(x, y FileChange) int { return cmp.Compare(x.Path, y.Path) })
+
+	ma, mb := d.VersionMetadata(a), d.VersionMetadata(b)
+	if ma != nil && mb != nil {
+		df.MetadataRecorded = true
+		for _, f := range metadataFields {
+			if x, y := f.values(ma), f.values(mb); !slices.Equal(x, y) {
+				df.Metadata = append(df.Metadata, FieldChange{Field: f.name, Old: x, New: y})
+			}
+		}
+	}
+	return df
+}
+
+// metadataFields are the metadata fields compared, in display order,
+// with their values one per line.
+var metadataFields = []struct {
+	name   string
+	values func(*Metadata) []string
+}{
+	{"title", func(m *Metadata) []string { return lines(m.Title) }},
+	{"titleLanguage", func(m *Metadata) []string { return lines(m.TitleLanguage) }},
+	{"creators", func(m *Metadata) []string {
+		return each(m.Creators, func(c Creator) string {
+			s := c.Name
+			if c.ORCID != "" {
+				s += " (" + c.ORCID + ")"
+			}
+			if c.Affiliation != "" {
+				s += ", " + c.Affiliation
+			}
+			return s
+		})
+	}},
+	{"description", func(m *Metadata) []string { return lines(m.Description) }},
+	{"descriptionLanguage", func(m *Metadata) []string { return lines(m.DescriptionLanguage) }},
+	{"publicationYear", func(m *Metadata) []string {
+		if m.PublicationYear == 0 {
+			return nil
+		}
+		return []string{strconv.Itoa(m.PublicationYear)}
+	}},
+	{"resourceType", func(m *Metadata) []string { return lines(m.ResourceType) }},
+	{"subjects", func(m *Metadata) []string { return m.Subjects }},
+	{"license", func(m *Metadata) []string { return lines(m.License) }},
+	{"geoLocations", func(m *Metadata) []string {
+		return each(m.GeoLocations, func(g GeoLocation) string {
+			var parts []string
+			if g.Place != "" {
+				parts = append(parts, g.Place)
+			}
+			if g.Point != nil {
+				parts = append(parts, fmt.Sprintf("point %g,%g", g.Point.Lat, g.Point.Lon))
+			}
+			if g.Box != nil {
+				parts = append(parts, fmt.Sprintf("box %g,%g,%g,%g", g.Box.West, g.Box.South, g.Box.East, g.Box.North))
+			}
+			return strings.Join(parts, " ")
+		})
+	}},
+	{"relatedIdentifiers", func(m *Metadata) []string {
+		return each(m.Related, func(r RelatedIdentifier) string { return r.Relation + " " + r.Identifier })
+	}},
+	{"awards", func(m *Metadata) []string { return m.Awards }},
+	{"raids", func(m *Metadata) []string { return m.RAiDs }},
+}
+
+// lines splits s into lines; nil if s is empty.
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(s, "\n"), "\n")
+}
+
+// each formats each of items.
+func each[T any](items []T, format func(T) string) []string {
+	if len(items) == 0 {
+		return nil
+	}
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = format(item)
+	}
+	return out
+}
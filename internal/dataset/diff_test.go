@@ -0,0 +1,79 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataset
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffVersions(t *testing.T) {
+	published := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	d := &Dataset{
+		ID:       "ds-1",
+		Title:    "Soil cores, 2024-2025",
+		Creators: []Creator{{Name: "Lovelace, Ada"}, {Name: "Hopper, Grace", ORCID: "0000-0002-1825-0097"}},
+		Subjects: []string{"soil"},
+		Versions: []Version{{
+			Number:      1,
+			PublishedAt: &published,
+			Files: []File{
+				{Path: "cores.csv", Size: 10, SHA256: "AA"},
+				{Path: "notes.txt", Size: 5, SHA256: "bb"},
+				{Path: "raw/site-1.csv", Size: 7, SHA256: "cc"},
+				{Path: "README", Size: 3, SHA256: "dd"},
+			},
+			Metadata: &Metadata{Title: "Soil cores, 2024", Creators: []Creator{{Name: "Lovelace, Ada"}}, Subjects: []string{"soil"}},
+		}},
+	}
+	v2, err := d.NewVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2.Files = []File{
+		{Path: "cores.csv", Size: 10, SHA256: "aa"},
+		{Path: "notes.txt", Size: 6, SHA256: "ee"},
+		{Path: "sites/site-1.csv", Size: 7, SHA256: "cc"},
+		{Path: "sites/site-2.csv", Size: 8, SHA256: "ff"},
+	}
+
+	df := DiffVersions(d, &d.Versions[0], d.Draft())
+	want := []FileChange{
+		{Change: FileRemoved, Path: "README", OldSize: 3, OldSHA256: "dd"},
+		{Change: FileModified, Path: "notes.txt", OldSize: 5, NewSize: 6, OldSHA256: "bb", NewSHA256: "ee"},
+		{Change: FileRenamed, Path: "sites/site-1.csv", From: "raw/site-1.csv", OldSize: 7, NewSize: 7, OldSHA256: "cc", NewSHA256: "cc"},
+		{Change: FileAdded, Path: "sites/site-2.csv", NewSize: 8, NewSHA256: "ff"},
+	}
+	if !reflect.DeepEqual(df.Files, want) || df.Unchanged != 1 {
+		t.Errorf("DiffVersions() files = %+v, unchanged %d", df.Files, df.Unchanged)
+	}
+	wantMeta := []FieldChange{
+		{Field: "title", Old: []string{"Soil cores, 2024"}, New: []string{"Soil cores, 2024-2025"}},
+		{Field: "creators", Old: []string{"Lovelace, Ada"}, New: []string{"Lovelace, Ada", "Hopper, Grace (0000-0002-1825-0097)"}},
+	}
+	if !df.MetadataRecorded || !reflect.DeepEqual(df.Metadata, wantMeta) {
+		t.Errorf("DiffVersions() metadata = %+v (recorded %v)", df.Metadata, df.MetadataRecorded)
+	}
+
+	// Versions published before metadata was recorded compare files only.
+	d.Versions[0].Metadata = nil
+	if df := DiffVersions(d, &d.Versions[0], d.Draft()); df.MetadataRecorded || df.Metadata != nil || len(df.Files) != 4 {
+		t.Errorf("DiffVersions() without recorded metadata = %+v", df)
+	}
+	if df := DiffVersions(d, d.Draft(), d.Draft()); !df.Empty() || df.Unchanged != 4 {
+		t.Errorf("DiffVersions() of a version with itself = %+v", df)
+	}
+}
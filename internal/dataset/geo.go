@@ -0,0 +1,137 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataset
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GeoLocation is a place a dataset covers, as in DataCite's
+// geoLocations: a named place, a point, a bounding box, or a
+// combination.
+type GeoLocation struct {
+	// Place names the location
+	Place string `json:"place,omitempty"`
+
+	// Point is the location's coordinates
+	Point *GeoPoint `json:"point,omitempty"`
+
+	// Box is the location's extent
+	Box *GeoBox `json:"box,omitempty"`
+}
+
+// GeoPoint is a WGS 84 position in decimal degrees.
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// GeoBox is a WGS 84 bounding box in decimal degrees. A box whose West
+// is greater than its East crosses the antimeridian.
+type GeoBox struct {
+	West  float64 `json:"west"`
+	South float64 `json:"south"`
+	East  float64 `json:"east"`
+	North float64 `json:"north"`
+}
+
+// ParsePoint parses "lat,lon".
+func ParsePoint(s string) (*GeoPoint, error) {
+	v, err := parseCoords(s, 2)
+	if err != nil {
+		return nil, fmt.Errorf("invalid point %q: want LAT,LON in decimal degrees", s)
+	}
+	p := &GeoPoint{Lat: v[0], Lon: v[1]}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParseBox parses "west,south,east,north", the order of GeoJSON and
+// OpenSearch bounding boxes.
+func ParseBox(s string) (*GeoBox, error) {
+	v, err := parseCoords(s, 4)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bounding box %q: want WEST,SOUTH,EAST,NORTH in decimal degrees", s)
+	}
+	b := &GeoBox{West: v[0], South: v[1], East: v[2], North: v[3]}
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func parseCoords(s string, n int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("want %d values", n)
+	}
+	v := make([]float64, n)
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = f
+	}
+	return v, nil
+}
+
+// Validate checks that p is on the globe.
+func (p *GeoPoint) Validate() error {
+	if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+		return fmt.Errorf("point %g,%g is off the globe: latitude must be within ±90 and longitude within ±180", p.Lat, p.Lon)
+	}
+	return nil
+}
+
+// Validate checks that b is on the globe and its south edge is not
+// north of its north edge.
+func (b *GeoBox) Validate() error {
+	for _, lat := range []float64{b.South, b.North} {
+		if lat < -90 || lat > 90 {
+			return fmt.Errorf("latitude %g is off the globe", lat)
+		}
+	}
+	for _, lon := range []float64{b.West, b.East} {
+		if lon < -180 || lon > 180 {
+			return fmt.Errorf("longitude %g is off the globe", lon)
+		}
+	}
+	if b.South > b.North {
+		return fmt.Errorf("bounding box south edge %g is north of its north edge %g", b.South, b.North)
+	}
+	return nil
+}
+
+// Validate checks that l has a place, point, or box, and that its
+// coordinates are on the globe.
+func (l *GeoLocation) Validate() error {
+	if l.Place == "" && l.Point == nil && l.Box == nil {
+		return fmt.Errorf("a location needs a place, point, or bounding box")
+	}
+	if l.Point != nil {
+		if err := l.Point.Validate(); err != nil {
+			return err
+		}
+	}
+	if l.Box != nil {
+		return l.Box.Validate()
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataset
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NormalizeLanguage returns the BCP 47 language tag s in its
+// conventional case, e.g. "es", "fr-CA", or "zh-Hant". Only the shape
+// of the tag is checked: a two- or three-letter language subtag
+// followed by script, region, or variant subtags.
+func NormalizeLanguage(s string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(strings.ReplaceAll(s, "_", "-")), "-")
+	for i, p := range parts {
+		if p == "" || len(p) > 8 || strings.Trim(strings.ToLower(p), "abcdefghijklmnopqrstuvwxyz0123456789") != "" {
+			return "", fmt.Errorf("invalid language tag %q: want e.g. en, es, or fr-CA", s)
+		}
+		switch {
+		case i == 0:
+			if len(p) < 2 || len(p) > 3 || strings.Trim(strings.ToLower(p), "abcdefghijklmnopqrstuvwxyz") != "" {
+				return "", fmt.Errorf("invalid language tag %q: want e.g. en, es, or fr-CA", s)
+			}
+			parts[i] = strings.ToLower(p)
+		case len(p) == 4:
+			parts[i] = strings.ToUpper(p[:1]) + strings.ToLower(p[1:])
+		case len(p) == 2:
+			parts[i] = strings.ToUpper(p)
+		default:
+			parts[i] = strings.ToLower(p)
+		}
+	}
+	return strings.Join(parts, "-"), nil
+}
+
+// PrimaryLanguage returns the language subtag of tag, e.g. "fr" for
+// "fr-CA".
+func PrimaryLanguage(tag string) string {
+	primary, _, _ := strings.Cut(tag, "-")
+	return strings.ToLower(primary)
+}
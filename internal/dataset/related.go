@@ -0,0 +1,90 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataset
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RelationTypes are the DataCite relation types a related identifier
+// may have.
+var RelationTypes = []string{
+	"IsCitedBy", "Cites", "IsSupplementTo", "IsSupplementedBy",
+	"IsContinuedBy", "Continues", "IsDescribedBy", "Describes",
+	"HasMetadata", "IsMetadataFor", "HasVersion", "IsVersionOf",
+	"IsNewVersionOf", "IsPreviousVersionOf", "IsPartOf", "HasPart",
+	"IsPublishedIn", "IsReferencedBy", "References", "IsDocumentedBy",
+	"Documents", "IsCompiledBy", "Compiles", "IsVariantFormOf",
+	"IsOriginalFormOf", "IsIdenticalTo", "IsReviewedBy", "Reviews",
+	"IsDerivedFrom", "IsSourceOf", "IsRequiredBy", "Requires",
+	"IsObsoletedBy", "Obsoletes", "IsCollectedBy", "Collects",
+}
+
+// RelatedIdentifier links a dataset to another work, such as the paper
+// that cites it, as in DataCite's relatedIdentifiers.
+type RelatedIdentifier struct {
+	// Relation is the DataCite relation type, e.g. "IsCitedBy"
+	Relation string `json:"relation"`
+
+	// Identifier is the related work's identifier
+	Identifier string `json:"identifier"`
+
+	// Type is the identifier type: DOI, ARK, Handle, or URL
+	Type string `json:"type"`
+}
+
+// NewRelatedIdentifier returns a relation to id, whose type is
+// inferred: DOIs, ARKs, and URLs are recognized, bare or through their
+// resolvers, and anything else is taken to be a handle.
+func NewRelatedIdentifier(relation, id string) (RelatedIdentifier, error) {
+	r := RelatedIdentifier{Relation: relation, Identifier: strings.TrimSpace(id)}
+	if i := slices.IndexFunc(RelationTypes, func(t string) bool { return strings.EqualFold(t, relation) }); i >= 0 {
+		r.Relation = RelationTypes[i]
+	} else {
+		return r, fmt.Errorf("unknown relation type %q, e.g. IsCitedBy, IsSupplementTo, or References", relation)
+	}
+	lower := strings.ToLower(r.Identifier)
+	switch {
+	case r.Identifier == "":
+		return r, fmt.Errorf("a related identifier is required")
+	case strings.HasPrefix(NormalizeDOI(r.Identifier), "10."):
+		r.Type, r.Identifier = "DOI", NormalizeDOI(r.Identifier)
+	case IsARK(r.Identifier):
+		r.Type, r.Identifier = "ARK", NormalizeARK(r.Identifier)
+	case strings.HasPrefix(lower, "https://hdl.handle.net/") || strings.HasPrefix(lower, "hdl:"):
+		r.Type, r.Identifier = "Handle", NormalizeHandle(r.Identifier)
+	case strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://"):
+		r.Type = "URL"
+	default:
+		r.Type, r.Identifier = "Handle", NormalizeHandle(r.Identifier)
+	}
+	return r, nil
+}
+
+// Key returns the identifier normalized for comparison, so that two
+// datasets related to the same work share a key.
+func (r RelatedIdentifier) Key() string {
+	switch r.Type {
+	case "DOI":
+		return "doi:" + NormalizeDOI(r.Identifier)
+	case "ARK":
+		return NormalizeARK(r.Identifier)
+	case "Handle":
+		return "hdl:" + NormalizeHandle(r.Identifier)
+	}
+	return strings.TrimRight(strings.TrimSpace(r.Identifier), "/")
+}
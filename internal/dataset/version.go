@@ -0,0 +1,174 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataset
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrImmutable is returned when saving a dataset whose published
+// versions differ from those stored.
+var ErrImmutable = errors.New("published versions cannot be changed")
+
+// Metadata is a dataset's descriptive metadata, recorded on each
+// version as it is published.
+type Metadata struct {
+	Title               string              `json:"title"`
+	Description         string              `json:"description,omitempty"`
+	TitleLanguage       string              `json:"titleLanguage,omitempty"`
+	DescriptionLanguage string              `json:"descriptionLanguage,omitempty"`
+	Creators            []Creator           `json:"creators,omitempty"`
+	PublicationYear     int                 `json:"publicationYear,omitempty"`
+	ResourceType        string              `json:"resourceType,omitempty"`
+	Subjects            []string            `json:"subjects,omitempty"`
+	License             string              `json:"license,omitempty"`
+	GeoLocations        []GeoLocation       `json:"geoLocations,omitempty"`
+	Related             []RelatedIdentifier `json:"relatedIdentifiers,omitempty"`
+	Awards              []string            `json:"awards,omitempty"`
+	RAiDs               []string            `json:"raids,omitempty"`
+}
+
+// Metadata returns a copy of d's descriptive metadata.
+func (d *Dataset) Metadata() *Metadata {
+	return &Metadata{
+		Title:               d.Title,
+		Description:         d.Description,
+		TitleLanguage:       d.TitleLanguage,
+		DescriptionLanguage: d.DescriptionLanguage,
+		Creators:            slices.Clone(d.Creators),
+		PublicationYear:     d.PublicationYear,
+		ResourceType:        d.ResourceType,
+		Subjects:            slices.Clone(d.Subjects),
+		License:             d.License,
+		GeoLocations:        slices.Clone(d.GeoLocations),
+		Related:             slices.Clone(d.Related),
+		Awards:              slices.Clone(d.Awards),
+		RAiDs:               slices.Clone(d.RAiDs),
+	}
+}
+
+// Current returns the version shown to the public: the latest
+// published version, or the latest version of a dataset never
+// published. It returns nil if there are none.
+func (d *Dataset) Current() *Version {
+	var current *Version
+	for i := range d.Versions {
+		v := &d.Versions[i]
+		if v.PublishedAt != nil && (current == nil || v.Number > current.Number) {
+			current = v
+		}
+	}
+	if current == nil {
+		return d.Latest()
+	}
+	return current
+}
+
+// Draft returns the unpublished version changes are made to, or nil if
+// every version is published.
+func (d *Dataset) Draft() *Version {
+	if v := d.Latest(); v != nil && v.PublishedAt == nil {
+		return v
+	}
+	return nil
+}
+
+// NewVersion starts version N+1 of a published dataset as a draft
+// holding the files of version N, and returns it. The draft is changed
+// and published like a new dataset, and until then the dataset and its
+// identifiers keep resolving to version N.
+func (d *Dataset) NewVersion() (*Version, error) {
+	if v := d.Draft(); v != nil {
+		return nil, fmt.Errorf("%s already has draft version %d", d.ID, v.Number)
+	}
+	latest := d.Latest()
+	if latest == nil {
+		return nil, fmt.Errorf("%s has no version to start from", d.ID)
+	}
+	files := make([]File, len(latest.Files))
+	for i, f := range latest.Files {
+		f.Derivatives = slices.Clone(f.Derivatives)
+		files[i] = f
+	}
+	d.Versions = append(d.Versions, Version{Number: latest.Number + 1, Files: files})
+	return &d.Versions[len(d.Versions)-1], nil
+}
+
+// checkImmutable returns an error wrapping ErrImmutable if d changes a
+// version published in stored. A published version keeps its
+// publication time, its DOI and metadata once it has them, its
+// snapshot record, its files' paths, sizes, digests, and source
+// versions, and stays deleted once its files are; where the files are
+// stored, their derivatives, and digests recorded later may change.
+func checkImmutable(stored, d *Dataset) error {
+	for _, old := range stored.Versions {
+		if old.PublishedAt == nil {
+			continue
+		}
+		v := d.Version(old.Number)
+		switch {
+		case v == nil:
+			return fmt.Errorf("%w: %s v%d was removed", ErrImmutable, d.ID, old.Number)
+		case v.PublishedAt == nil || !v.PublishedAt.Equal(*old.PublishedAt):
+			return fmt.Errorf("%w: the publication time of %s v%d changed", ErrImmutable, d.ID, old.Number)
+		case old.DOI != "" && v.DOI != old.DOI:
+			return fmt.Errorf("%w: the DOI of %s v%d changed", ErrImmutable, d.ID, old.Number)
+		case old.Metadata != nil && !sameJSON(old.Metadata, v.Metadata):
+			return fmt.Errorf("%w: the metadata of %s v%d changed", ErrImmutable, d.ID, old.Number)
+		case old.Removed() && !v.Removed():
+			return fmt.Errorf("%w: the files of %s v%d were deleted", ErrImmutable, d.ID, old.Number)
+		case !sameJSON(old.Snapshot, v.Snapshot):
+			return fmt.Errorf("%w: the snapshot record of %s v%d changed", ErrImmutable, d.ID, old.Number)
+		case !slices.Equal(contents(old.Files), contents(v.Files)):
+			return fmt.Errorf("%w: the files of %s v%d changed", ErrImmutable, d.ID, old.Number)
+		}
+	}
+	return nil
+}
+
+// content is what a published file may not change.
+type content struct {
+	path          string
+	size          int64
+	sha256        string
+	sourceVersion string
+}
+
+// contents returns the content of files, sorted by path.
+func contents(files []File) []content {
+	out := make([]content, len(files))
+	for i, f := range files {
+		out[i] = content{f.Path, f.Size, strings.ToLower(f.SHA256), f.SourceVersion}
+	}
+	slices.SortFunc(out, func(a, b content) int { return cmp.Compare(a.path, b.path) })
+	return out
+}
+
+// sameJSON reports whether a and b encode to the same JSON, so that
+// empty and nil slices compare equal.
+func sameJSON(a, b any) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	return err == nil && bytes.Equal(x, y)
+}
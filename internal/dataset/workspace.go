@@ -0,0 +1,149 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataset
+
+import (
+	"slices"
+	"time"
+)
+
+// Workspace holds the metadata changes staged for a published
+// dataset's next version. They accumulate alongside the file changes
+// of the draft version and are applied together when it is published;
+// until then the dataset keeps showing its published metadata.
+type Workspace struct {
+	// Base is the dataset's metadata when a change was last staged;
+	// fields where Metadata matches it are left as they are at
+	// publication
+	Base *Metadata `json:"base"`
+
+	// Metadata is the metadata the next version is published with
+	Metadata *Metadata `json:"metadata"`
+
+	// UpdatedAt is when a change was last staged
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Staged returns the metadata d's next version will be published
+// with: d's metadata with the workspace's changes applied, so that
+// fields changed in place since, such as linked awards, are kept.
+func (d *Dataset) Staged() *Metadata {
+	live := d.Metadata()
+	w := d.Workspace
+	if w == nil || w.Base == nil || w.Metadata == nil {
+		return live
+	}
+	b, s := w.Base, w.Metadata
+	return &Metadata{
+		Title:               pick(b.Title, s.Title, live.Title),
+		Description:         pick(b.Description, s.Description, live.Description),
+		TitleLanguage:       pick(b.TitleLanguage, s.TitleLanguage, live.TitleLanguage),
+		DescriptionLanguage: pick(b.DescriptionLanguage, s.DescriptionLanguage, live.DescriptionLanguage),
+		Creators:            pick(b.Creators, slices.Clone(s.Creators), live.Creators),
+		PublicationYear:     pick(b.PublicationYear, s.PublicationYear, live.PublicationYear),
+		ResourceType:        pick(b.ResourceType, s.ResourceType, live.ResourceType),
+		Subjects:            pick(b.Subjects, slices.Clone(s.Subjects), live.Subjects),
+		License:             pick(b.License, s.License, live.License),
+		GeoLocations:        pick(b.GeoLocations, slices.Clone(s.GeoLocations), live.GeoLocations),
+		Related:             pick(b.Related, slices.Clone(s.Related), live.Related),
+		Awards:              pick(b.Awards, slices.Clone(s.Awards), live.Awards),
+		RAiDs:               pick(b.RAiDs, slices.Clone(s.RAiDs), live.RAiDs),
+	}
+}
+
+// pick returns staged if it differs from base, and live otherwise.
+func pick[T any](base, staged, live T) T {
+	if sameJSON(base, staged) {
+		return live
+	}
+	return staged
+}
+
+// Edit applies a metadata change made by edit. Datasets never
+// published are changed in place. The metadata of a published dataset
+// is citable as it is, so edit is instead given a copy of the dataset
+// holding its staged metadata, and the result is staged in d's
+// workspace, starting a draft version if there is none, to be applied
+// when the draft is published. Edit must change only metadata fields.
+func (d *Dataset) Edit(now time.Time, edit func(*Dataset)) error {
+	if d.State != StatePublished {
+		edit(d)
+		return nil
+	}
+	if d.Draft() == nil {
+		if _, err := d.NewVersion(); err != nil {
+			return err
+		}
+	}
+	w := *d
+	w.setMetadata(d.Staged())
+	edit(&w)
+	staged, live := w.Metadata(), d.Metadata()
+	if sameJSON(staged, live) {
+		d.Workspace = nil
+		return nil
+	}
+	d.Workspace = &Workspace{Base: live, Metadata: staged, UpdatedAt: now}
+	return nil
+}
+
+// Amend applies a metadata change made by edit to d in place, even if
+// it is published, and to the metadata staged in its workspace, so
+// that publishing the staged changes keeps it. It is for changes that
+// take effect at once, such as marking a dataset superseded. Amend
+// must change only metadata fields.
+func (d *Dataset) Amend(edit func(*Dataset)) {
+	edit(d)
+	if w := d.Workspace; w != nil {
+		for _, m := range []*Metadata{w.Base, w.Metadata} {
+			if m == nil {
+				continue
+			}
+			var x Dataset
+			x.setMetadata(m)
+			edit(&x)
+			*m = *x.Metadata()
+		}
+	}
+}
+
+// ApplyWorkspace replaces d's metadata with its staged metadata and
+// empties the workspace; the caller publishes d's draft version with
+// it. It reports whether any changes were staged.
+func (d *Dataset) ApplyWorkspace() bool {
+	if d.Workspace == nil {
+		return false
+	}
+	d.setMetadata(d.Staged())
+	d.Workspace = nil
+	return true
+}
+
+// setMetadata sets d's descriptive metadata to m.
+func (d *Dataset) setMetadata(m *Metadata) {
+	d.Title = m.Title
+	d.Description = m.Description
+	d.TitleLanguage = m.TitleLanguage
+	d.DescriptionLanguage = m.DescriptionLanguage
+	d.Creators = slices.Clone(m.Creators)
+	d.PublicationYear = m.PublicationYear
+	d.ResourceType = m.ResourceType
+	d.Subjects = slices.Clone(m.Subjects)
+	d.License = m.License
+	d.GeoLocations = slices.Clone(m.GeoLocations)
+	d.Related = slices.Clone(m.Related)
+	d.Awards = slices.Clone(m.Awards)
+	d.RAiDs = slices.Clone(m.RAiDs)
+}
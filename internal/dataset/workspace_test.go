@@ -0,0 +1,77 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataset
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEdit(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// Drafts are changed in place.
+	d := &Dataset{ID: "ds-1", Title: "Soil cores", State: StateDraft}
+	if err := d.Edit(now, func(d *Dataset) { d.Title = "Soil cores, 2024" }); err != nil || d.Title != "Soil cores, 2024" || d.Workspace != nil {
+		t.Fatalf("Edit() of a draft = %+v, %v", d, err)
+	}
+
+	// Published datasets stage changes in a new draft version.
+	d.State = StatePublished
+	d.Versions = []Version{{Number: 1, PublishedAt: &now, Files: []File{{Path: "a.csv"}}}}
+	if err := d.Edit(now, func(d *Dataset) { d.Subjects = append(d.Subjects, "soil") }); err != nil {
+		t.Fatalf("Edit() error = %v", err)
+	}
+	if err := d.Edit(now, func(d *Dataset) { d.Title = "Soil cores, 2024-2025" }); err != nil {
+		t.Fatalf("second Edit() error = %v", err)
+	}
+	if d.Title != "Soil cores, 2024" || d.Subjects != nil {
+		t.Errorf("Edit() changed the published metadata: %+v", d)
+	}
+	if v := d.Draft(); v == nil || v.Number != 2 || len(v.Files) != 1 {
+		t.Fatalf("draft = %+v", v)
+	}
+	if m := d.Staged(); m.Title != "Soil cores, 2024-2025" || fmt.Sprint(m.Subjects) != "[soil]" {
+		t.Errorf("Staged() = %+v", m)
+	}
+	df := DiffVersions(d, d.Version(1), d.Draft())
+	if len(df.Metadata) != 0 {
+		t.Errorf("metadata diff against an unrecorded version = %+v", df.Metadata)
+	}
+
+	// Changes made in place since are kept, and survive publication.
+	d.Awards = []string{"award-1"}
+	d.Amend(func(d *Dataset) {
+		d.Related = append(d.Related, RelatedIdentifier{"IsObsoletedBy", "10.5555/new", "DOI"})
+	})
+	if !d.ApplyWorkspace() || d.Workspace != nil {
+		t.Fatal("ApplyWorkspace() found nothing staged")
+	}
+	if d.Title != "Soil cores, 2024-2025" || fmt.Sprint(d.Subjects) != "[soil]" || fmt.Sprint(d.Awards) != "[award-1]" || len(d.Related) != 1 {
+		t.Errorf("metadata after ApplyWorkspace() = %+v", d)
+	}
+	if d.ApplyWorkspace() {
+		t.Error("ApplyWorkspace() applied an empty workspace")
+	}
+
+	// Undoing every staged change empties the workspace.
+	if err := d.Edit(now, func(d *Dataset) { d.License = "CC-BY-4.0" }); err != nil || d.Workspace == nil {
+		t.Fatalf("Edit() = %+v, %v", d.Workspace, err)
+	}
+	if err := d.Edit(now, func(d *Dataset) { d.License = "" }); err != nil || d.Workspace != nil {
+		t.Errorf("Edit() undoing the change left %+v, %v", d.Workspace, err)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dedup moves the files of existing datasets to the
+// content-addressed layout, so that content stored once per version or
+// dataset is stored once in total. Each file is copied to the key of
+// its SHA-256 digest unless an object is already there, and its
+// manifest is updated to refer to it. The old objects are left for
+// storage gc, which removes them once no manifest refers to them.
+package dedup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// ObjectStore copies stored objects.
+type ObjectStore interface {
+	HeadObject(ctx context.Context, bucket, key string) (s3.ObjectInfo, error)
+	CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
+}
+
+// Move is a file to be moved to its content address.
+type Move struct {
+	Dataset string           `json:"dataset"`
+	Version int              `json:"version"`
+	Path    string           `json:"path"`
+	Size    int64            `json:"size"`
+	From    storage.Location `json:"from"`
+	To      storage.Location `json:"to"`
+
+	// Copy reports whether the content must be copied; false if another
+	// file already has it at To
+	Copy bool `json:"copy"`
+}
+
+// Report describes a migration.
+type Report struct {
+	Moves []Move `json:"moves"`
+
+	// Files counts the files examined
+	Files int `json:"files"`
+
+	// Unhashed counts files without a SHA-256 digest, which stay where
+	// they are until 'aperture fixity' records one
+	Unhashed int `json:"unhashed"`
+}
+
+// SavedBytes returns the storage saved once the old objects are
+// removed: the size of every moved file whose content is not copied.
+func (r *Report) SavedBytes() int64 {
+	var n int64
+	for _, m := range r.Moves {
+		if !m.Copy {
+			n += m.Size
+		}
+	}
+	return n
+}
+
+// Migrator moves dataset files to the content-addressed layout.
+type Migrator struct {
+	Objects  ObjectStore
+	Datasets *dataset.Store
+	Layout   *storage.ContentLayout
+}
+
+// Plan returns the files that are not yet at their content address.
+// Nothing is copied or saved.
+func (m *Migrator) Plan(ctx context.Context) (*Report, error) {
+	datasets, err := m.Datasets.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dataset manifests: %w", err)
+	}
+	r := &Report{Moves: []Move{}}
+	planned := make(map[storage.Location]bool)
+	for _, d := range datasets {
+		for _, v := range d.Versions {
+			if v.Pruned != nil {
+				continue
+			}
+			for _, f := range v.Files {
+				r.Files++
+				// Files tiered to the cloud stay where tiering put them.
+				if f.Tier == dataset.TierCloud {
+					continue
+				}
+				if f.SHA256 == "" {
+					r.Unhashed++
+					continue
+				}
+				to, err := m.Layout.Locate(storage.Object{Dataset: d.ID, Version: v.Number, File: f.Path, Access: d.Access, SHA256: f.SHA256})
+				if err != nil {
+					return nil, fmt.Errorf("%s v%d %s: %w", d.ID, v.Number, f.Path, err)
+				}
+				from := storage.Location{Bucket: f.Bucket, Key: f.Key}
+				if from == to {
+					planned[to] = true
+					continue
+				}
+				r.Moves = append(r.Moves, Move{Dataset: d.ID, Version: v.Number, Path: f.Path, Size: f.Size, From: from, To: to, Copy: !planned[to]})
+				planned[to] = true
+			}
+		}
+	}
+	return r, nil
+}
+
+// Apply copies the content of the moves in r and updates the manifests
+// to refer to it, returning the number of files moved. Content already
+// at its address is not copied again, so Apply may be re-run after an
+// interruption.
+func (m *Migrator) Apply(ctx context.Context, r *Report) (int, error) {
+	byDataset := make(map[string][]Move)
+	var order []string
+	for _, mv := range r.Moves {
+		if byDataset[mv.Dataset] == nil {
+			order = append(order, mv.Dataset)
+		}
+		byDataset[mv.Dataset] = append(byDataset[mv.Dataset], mv)
+	}
+
+	moved := 0
+	for _, id := range order {
+		d, err := m.Datasets.Get(ctx, id)
+		if err != nil {
+			return moved, err
+		}
+		n := 0
+		for _, mv := range byDataset[id] {
+			v := d.Version(mv.Version)
+			if v == nil {
+				continue
+			}
+			f := file(v, mv.Path)
+			// Skip files changed since the plan was made.
+			if f == nil || f.Bucket != mv.From.Bucket || f.Key != mv.From.Key {
+				continue
+			}
+			if err := m.place(ctx, mv); err != nil {
+				return moved, err
+			}
+			f.Bucket, f.Key = mv.To.Bucket, mv.To.Key
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		if err := m.Datasets.Put(ctx, d); err != nil {
+			return moved, err
+		}
+		moved += n
+	}
+	return moved, nil
+}
+
+// file returns the file of v at path, or nil.
+func file(v *dataset.Version, path string) *dataset.File {
+	for i := range v.Files {
+		if v.Files[i].Path == path {
+			return &v.Files[i]
+		}
+	}
+	return nil
+}
+
+// place copies the content of mv to its address unless it is there.
+func (m *Migrator) place(ctx context.Context, mv Move) error {
+	_, err := m.Objects.HeadObject(ctx, mv.To.Bucket, mv.To.Key)
+	switch {
+	case err == nil:
+		return nil
+	case !errors.Is(err, s3.ErrNotFound):
+		return fmt.Errorf("failed to check %s: %w", mv.To, err)
+	}
+	if err := m.Objects.CopyObject(ctx, mv.From.Bucket, mv.From.Key, mv.To.Bucket, mv.To.Key); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", mv.From, mv.To, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// fakeStore is an in-memory ObjectStore keyed by bucket/key.
+type fakeStore struct {
+	objects map[string]bool
+	copies  []string
+}
+
+func (f *fakeStore) HeadObject(_ context.Context, bucket, key string) (s3.ObjectInfo, error) {
+	if !f.objects[bucket+"/"+key] {
+		return s3.ObjectInfo{}, s3.ErrNotFound
+	}
+	return s3.ObjectInfo{Key: key}, nil
+}
+
+func (f *fakeStore) CopyObject(_ context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	f.objects[dstBucket+"/"+dstKey] = true
+	f.copies = append(f.copies, srcBucket+"/"+srcKey+" -> "+dstBucket+"/"+dstKey)
+	return nil
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	sumA, sumB := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	d := &dataset.Dataset{
+		ID:     "ds-1",
+		Title:  "Ocean temperatures",
+		Access: storage.AccessPublic,
+		Versions: []dataset.Version{
+			{Number: 1, Files: []dataset.File{
+				{Path: "a.csv", Size: 10, SHA256: sumA, Bucket: "ap-public-media", Key: "ds-1/v1/a.csv"},
+				{Path: "notes.txt", Size: 3, Bucket: "ap-public-media", Key: "ds-1/v1/notes.txt"},
+			}},
+			{Number: 2, Files: []dataset.File{
+				{Path: "a.csv", Size: 10, SHA256: strings.ToUpper(sumA), Bucket: "ap-public-media", Key: "ds-1/v2/a.csv"},
+				{Path: "b.csv", Size: 20, SHA256: sumB, Bucket: "ap-public-media", Key: "ds-1/v2/b.csv"},
+			}},
+		},
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+
+	objects := &fakeStore{objects: map[string]bool{}}
+	m := &Migrator{Objects: objects, Datasets: datasets, Layout: &storage.ContentLayout{Prefix: "ap"}}
+	r, err := m.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(r.Moves) != 3 || r.Files != 4 || r.Unhashed != 1 || r.SavedBytes() != 10 {
+		t.Fatalf("Plan() = %+v, saving %d bytes", r, r.SavedBytes())
+	}
+	if len(objects.copies) != 0 {
+		t.Errorf("Plan() copied %v", objects.copies)
+	}
+
+	moved, err := m.Apply(ctx, r)
+	if err != nil || moved != 3 {
+		t.Fatalf("Apply() = %d, %v; want 3 moved", moved, err)
+	}
+	if len(objects.copies) != 2 {
+		t.Errorf("copies = %v, want one per content", objects.copies)
+	}
+	got, err := datasets.Get(ctx, "ds-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyA := storage.ContentKey(sumA)
+	if v1, v2 := got.Version(1), got.Version(2); v1.Files[0].Key != keyA || v2.Files[0].Key != keyA ||
+		v2.Files[1].Key != storage.ContentKey(sumB) || v1.Files[1].Key != "ds-1/v1/notes.txt" {
+		t.Errorf("manifest after Apply() = %+v", got.Versions)
+	}
+
+	// Once migrated, nothing is left to move.
+	if r, err := m.Plan(ctx); err != nil || len(r.Moves) != 0 {
+		t.Errorf("Plan() after Apply() = %+v, %v", r, err)
+	}
+}
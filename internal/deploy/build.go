@@ -0,0 +1,106 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Functions are the API's Lambda functions. Each is built from
+// cmd/lambdas/<name> into lambdas/<name>/bootstrap in the working
+// directory, where the stack packages it.
+var Functions = []string{"auth", "presign", "doi", "search"}
+
+// Builder builds a Lambda function's executable at out. *GoBuild
+// implements it.
+type Builder interface {
+	Build(ctx context.Context, function, out string) error
+}
+
+// GoBuild builds the functions from the module's source with the go
+// tool, for the arm64 provided.al2023 runtime the stack deploys them
+// to.
+type GoBuild struct {
+	// Source is the module's source directory, holding its go.mod
+	Source string
+
+	// Path is the go binary; go on the PATH if empty
+	Path string
+
+	// Stderr receives the compiler's diagnostics; os.Stderr if nil
+	Stderr io.Writer
+}
+
+// Build implements Builder.
+func (g *GoBuild) Build(ctx context.Context, function, out string) error {
+	cmd := exec.CommandContext(ctx, cmp.Or(g.Path, "go"), "build", "-trimpath", "-ldflags=-s -w", "-o", out, "./cmd/lambdas/"+function)
+	cmd.Dir = g.Source
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=arm64", "CGO_ENABLED=0")
+	cmd.Stderr = g.Stderr
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to build the %s function: %w", function, err)
+	}
+	return nil
+}
+
+// build builds each of the functions into Dir.
+func (t *Terraform) build(ctx context.Context) error {
+	if t.Build == nil {
+		return nil
+	}
+	for _, name := range Functions {
+		out := filepath.Join(t.Dir, "lambdas", name, "bootstrap")
+		if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+			return err
+		}
+		if err := t.Build.Build(ctx, name, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SourceDir returns the aperture module's source directory: dir or the
+// nearest of its parents holding the module's go.mod.
+func SourceDir(dir string) (string, error) {
+	for d := dir; ; d = filepath.Dir(d) {
+		if mod, err := os.ReadFile(filepath.Join(d, "go.mod")); err == nil && modulePath(mod) == "github.com/scttfrdmn/aperture" {
+			return d, nil
+		}
+		if filepath.Dir(d) == d {
+			return "", fmt.Errorf("%s is not in the aperture source, which the API's Lambda functions are built from", dir)
+		}
+	}
+}
+
+// modulePath returns the module path a go.mod declares.
+func modulePath(mod []byte) string {
+	for _, line := range strings.Split(string(mod), "\n") {
+		if path, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(path), `"`)
+		}
+	}
+	return ""
+}
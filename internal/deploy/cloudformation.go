@@ -0,0 +1,349 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/cloudformation"
+)
+
+// TemplatePath is the path of the CloudFormation template in its stack.
+const TemplatePath = "infrastructure/cloudformation/aperture.yaml"
+
+// changeSetName names the change set Plan creates and Apply executes.
+const changeSetName = "aperture-deploy"
+
+// templateParameters maps the input variables the template takes to
+// its parameters. Other variables configure resources only the
+// Terraform stack has.
+var templateParameters = map[string]string{
+	"project_name":         "ProjectName",
+	"environment":          "Environment",
+	"cors_allowed_origins": "CorsAllowedOrigins",
+}
+
+// CloudFormation deploys the CloudFormation template through a change
+// set: Plan creates the change set and shows its changes, and Apply
+// executes it and waits for the stack to settle.
+type CloudFormation struct {
+	// Stack holds the template at TemplatePath
+	Stack fs.FS
+
+	// StackName names the CloudFormation stack, e.g. aperture-prod
+	StackName string
+
+	// Client calls CloudFormation
+	Client *cloudformation.Client
+
+	// PollInterval is how often change sets, stacks, and drift
+	// detections are checked; 5 seconds if zero
+	PollInterval time.Duration
+}
+
+// Name implements Backend.
+func (c *CloudFormation) Name() string { return "cloudformation" }
+
+// Source implements Backend.
+func (c *CloudFormation) Source() fs.FS { return c.Stack }
+
+// WithSource implements Backend.
+func (c *CloudFormation) WithSource(stack fs.FS) Backend {
+	cp := *c
+	cp.Stack = stack
+	return &cp
+}
+
+// Plan implements Backend, keeping the change set for Apply unless
+// opts.DryRun is set.
+func (c *CloudFormation) Plan(ctx context.Context, opts Options, out io.Writer) error {
+	if len(opts.VarFiles) > 0 {
+		return fmt.Errorf("variable files are Terraform's; the CloudFormation backend takes its parameters from the configuration")
+	}
+	template, err := fs.ReadFile(c.Stack, TemplatePath)
+	if err != nil {
+		return err
+	}
+	typ := "UPDATE"
+	stack, err := c.Client.DescribeStack(ctx, c.StackName)
+	switch {
+	case errors.Is(err, cloudformation.ErrNotFound):
+		typ = "CREATE"
+	case err != nil:
+		return err
+	case stack.Status == "REVIEW_IN_PROGRESS":
+		// An earlier change set creating the stack was never executed.
+		typ = "CREATE"
+	case strings.HasSuffix(stack.Status, "_IN_PROGRESS"):
+		return fmt.Errorf("stack %s is busy (%s); try again once it settles", c.StackName, stack.Status)
+	}
+	if err := c.Client.DeleteChangeSet(ctx, c.StackName, changeSetName); err != nil && !errors.Is(err, cloudformation.ErrNotFound) {
+		return err
+	}
+
+	params := make(map[string]string)
+	for name, param := range templateParameters {
+		switch v := opts.Vars.Values[name].(type) {
+		case nil:
+		case []string:
+			params[param] = strings.Join(v, ",")
+		default:
+			params[param] = fmt.Sprint(v)
+		}
+	}
+	fmt.Fprintf(out, "Creating change set for %s stack %s\n", strings.ToLower(typ), c.StackName)
+	if _, err := c.Client.CreateChangeSet(ctx, cloudformation.ChangeSetInput{
+		StackName:     c.StackName,
+		ChangeSetName: changeSetName,
+		Type:          typ,
+		TemplateBody:  string(template),
+		Parameters:    params,
+	}); err != nil {
+		return err
+	}
+	cs, err := c.changeSet(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case noChanges(cs):
+		fmt.Fprintln(out, "No changes.")
+	case cs.Status != "CREATE_COMPLETE":
+		return fmt.Errorf("change set of stack %s failed: %s", c.StackName, cs.StatusReason)
+	}
+	for _, ch := range cs.Changes {
+		fmt.Fprintf(out, "  %-7s %s (%s)", ch.Action, ch.LogicalResourceID, ch.ResourceType)
+		if ch.Replacement == "True" || ch.Replacement == "Conditional" {
+			fmt.Fprintf(out, ", replacement: %s", ch.Replacement)
+		}
+		fmt.Fprintln(out)
+	}
+	if opts.DryRun {
+		return c.Client.DeleteChangeSet(ctx, c.StackName, changeSetName)
+	}
+	return nil
+}
+
+// Resources implements Backend with the resources the template
+// declares, all of which the stack holds once a change set is executed.
+func (c *CloudFormation) Resources(ctx context.Context, opts Options, out io.Writer) ([]Resource, error) {
+	template, err := fs.ReadFile(c.Stack, TemplatePath)
+	if err != nil {
+		return nil, err
+	}
+	return templateResources(template), nil
+}
+
+// templateResources returns the resources declared by the Resources
+// section of a YAML template, by the logical IDs indented one level
+// and their Type properties.
+func templateResources(template []byte) []Resource {
+	var resources []Resource
+	in := false
+	for line := range strings.Lines(string(template)) {
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "" || strings.HasPrefix(strings.TrimSpace(line), "#"):
+		case !strings.HasPrefix(line, " "):
+			in = line == "Resources:"
+		case !in:
+		case strings.HasPrefix(line, "  ") && line[2] != ' ' && strings.HasSuffix(line, ":"):
+			resources = append(resources, Resource{Address: strings.TrimSuffix(line[2:], ":")})
+		case strings.HasPrefix(line, "    Type: ") && len(resources) > 0:
+			resources[len(resources)-1].Type = strings.TrimSpace(strings.TrimPrefix(line, "    Type: "))
+		}
+	}
+	return resources
+}
+
+// changeSet waits for the change set to be created and returns it.
+func (c *CloudFormation) changeSet(ctx context.Context) (*cloudformation.ChangeSet, error) {
+	for {
+		cs, err := c.Client.DescribeChangeSet(ctx, c.StackName, changeSetName)
+		if err != nil {
+			return nil, err
+		}
+		if cs.Status == "CREATE_COMPLETE" || cs.Status == "FAILED" {
+			return cs, nil
+		}
+		if err := c.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// noChanges reports whether cs failed only because the stack already
+// matches the template.
+func noChanges(cs *cloudformation.ChangeSet) bool {
+	return cs.Status == "FAILED" && (strings.Contains(cs.StatusReason, "didn't contain changes") ||
+		strings.Contains(cs.StatusReason, "No updates are to be performed"))
+}
+
+// Apply implements Backend, executing the change set created by Plan
+// and waiting for the stack to settle. Outputs are returned as strings.
+func (c *CloudFormation) Apply(ctx context.Context, opts Options, out io.Writer) (map[string]json.RawMessage, error) {
+	cs, err := c.Client.DescribeChangeSet(ctx, c.StackName, changeSetName)
+	if errors.Is(err, cloudformation.ErrNotFound) {
+		return nil, fmt.Errorf("stack %s has no change set to execute; plan it first", c.StackName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if noChanges(cs) {
+		if err := c.Client.DeleteChangeSet(ctx, c.StackName, changeSetName); err != nil {
+			return nil, err
+		}
+	} else if err := c.Client.ExecuteChangeSet(ctx, c.StackName, changeSetName); err != nil {
+		return nil, err
+	}
+
+	last := ""
+	for {
+		stack, err := c.Client.DescribeStack(ctx, c.StackName)
+		if err != nil {
+			return nil, err
+		}
+		if stack.Status != last {
+			fmt.Fprintf(out, "%s: %s\n", c.StackName, stack.Status)
+			last = stack.Status
+		}
+		switch {
+		case strings.HasSuffix(stack.Status, "_IN_PROGRESS"):
+			if err := c.wait(ctx); err != nil {
+				return nil, err
+			}
+			continue
+		case stack.Status != "CREATE_COMPLETE" && stack.Status != "UPDATE_COMPLETE":
+			return nil, fmt.Errorf("stack %s did not deploy (%s): %s", c.StackName, stack.Status, stack.StatusReason)
+		}
+		outputs := make(map[string]json.RawMessage)
+		for k, v := range stack.Outputs {
+			if outputs[k], err = json.Marshal(v); err != nil {
+				return nil, err
+			}
+		}
+		return outputs, nil
+	}
+}
+
+// Drift implements Backend with a CloudFormation drift detection, which
+// compares the stack's resources with the template's properties.
+// Nothing is changed.
+func (c *CloudFormation) Drift(ctx context.Context, opts Options, out io.Writer) ([]Drifted, error) {
+	id, err := c.Client.DetectStackDrift(ctx, c.StackName)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(out, "Detecting drift of stack %s\n", c.StackName)
+	for {
+		det, err := c.Client.DescribeDriftDetection(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if det.Status == "DETECTION_FAILED" {
+			return nil, fmt.Errorf("drift detection of stack %s failed: %s", c.StackName, det.StatusReason)
+		}
+		if det.Status == "DETECTION_COMPLETE" {
+			break
+		}
+		if err := c.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	drifts, err := c.Client.DescribeResourceDrifts(ctx, c.StackName, "MODIFIED", "DELETED")
+	if err != nil {
+		return nil, err
+	}
+	var drifted []Drifted
+	for _, rd := range drifts {
+		action := "update"
+		var changed []string
+		if rd.Status == "DELETED" {
+			action = "delete"
+		} else {
+			for _, p := range rd.Properties {
+				name, _, _ := strings.Cut(strings.TrimPrefix(p, "/"), "/")
+				if !slices.Contains(changed, name) {
+					changed = append(changed, name)
+				}
+			}
+			slices.Sort(changed)
+		}
+		drifted = append(drifted, Drifted{
+			Address:    rd.LogicalResourceID,
+			Type:       rd.ResourceType,
+			Action:     action,
+			Attributes: changed,
+			Severity:   Severity(rd.ResourceType, action, changed),
+		})
+	}
+	return drifted, nil
+}
+
+// Destroy implements Backend by deleting the stack and waiting for it
+// to go. The template retains its buckets and tables, whatever
+// opts.ForceDeleteData says, so their data outlives the stack.
+func (c *CloudFormation) Destroy(ctx context.Context, opts Options, out io.Writer) error {
+	if err := c.Client.DeleteStack(ctx, c.StackName); err != nil {
+		return err
+	}
+	last := ""
+	for {
+		stack, err := c.Client.DescribeStack(ctx, c.StackName)
+		if errors.Is(err, cloudformation.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if stack.Status != last {
+			fmt.Fprintf(out, "%s: %s\n", c.StackName, stack.Status)
+			last = stack.Status
+		}
+		if stack.Status == "DELETE_COMPLETE" {
+			break
+		}
+		if !strings.HasSuffix(stack.Status, "_IN_PROGRESS") {
+			return fmt.Errorf("stack %s was not deleted (%s): %s", c.StackName, stack.Status, stack.StatusReason)
+		}
+		if err := c.wait(ctx); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, "Deleted stack %s; its buckets and tables are retained, to be emptied and deleted by hand once their data is no longer needed\n", c.StackName)
+	return nil
+}
+
+// wait waits PollInterval, or until ctx is done.
+func (c *CloudFormation) wait(ctx context.Context) error {
+	t := time.NewTimer(cmp.Or(c.PollInterval, 5*time.Second))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/cloudformation"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// fakeCloudFormation is a CloudFormation API holding one stack.
+type fakeCloudFormation struct {
+	actions []string
+	created url.Values
+
+	// status is the stack's status; empty until a change set is
+	// executed
+	status string
+}
+
+func (f *fakeCloudFormation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	action := r.PostForm.Get("Action")
+	f.actions = append(f.actions, action)
+	switch action {
+	case "DescribeStacks":
+		switch f.status {
+		case "":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `<ErrorResponse><Error><Code>ValidationError</Code><Message>Stack with id aperture-prod does not exist</Message></Error></ErrorResponse>`)
+		case "CREATE_IN_PROGRESS":
+			f.status = "CREATE_COMPLETE"
+			fmt.Fprint(w, `<DescribeStacksResponse><DescribeStacksResult><Stacks><member><StackStatus>CREATE_IN_PROGRESS</StackStatus></member></Stacks></DescribeStacksResult></DescribeStacksResponse>`)
+		default:
+			fmt.Fprintf(w, `<DescribeStacksResponse><DescribeStacksResult><Stacks><member><StackStatus>%s</StackStatus>
+				<Outputs><member><OutputKey>UsersTableName</OutputKey><OutputValue>aperture-users-prod</OutputValue></member></Outputs>
+				</member></Stacks></DescribeStacksResult></DescribeStacksResponse>`, f.status)
+		}
+	case "DeleteChangeSet":
+		if f.created == nil {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<ErrorResponse><Error><Code>ChangeSetNotFound</Code><Message>ChangeSet [aperture-deploy] does not exist</Message></Error></ErrorResponse>`)
+			return
+		}
+		f.created = nil
+		fmt.Fprint(w, `<DeleteChangeSetResponse/>`)
+	case "CreateChangeSet":
+		f.created = r.PostForm
+		fmt.Fprint(w, `<CreateChangeSetResponse><CreateChangeSetResult><Id>cs</Id></CreateChangeSetResult></CreateChangeSetResponse>`)
+	case "DescribeChangeSet":
+		fmt.Fprint(w, `<DescribeChangeSetResponse><DescribeChangeSetResult><Status>CREATE_COMPLETE</Status><Changes>
+			<member><ResourceChange><Action>Add</Action><LogicalResourceId>UsersTable</LogicalResourceId><ResourceType>AWS::DynamoDB::Table</ResourceType></ResourceChange></member>
+			</Changes></DescribeChangeSetResult></DescribeChangeSetResponse>`)
+	case "ExecuteChangeSet":
+		f.status = "CREATE_IN_PROGRESS"
+		fmt.Fprint(w, `<ExecuteChangeSetResponse/>`)
+	case "DeleteStack":
+		f.status = ""
+		fmt.Fprint(w, `<DeleteStackResponse/>`)
+	case "DetectStackDrift":
+		fmt.Fprint(w, `<DetectStackDriftResponse><DetectStackDriftResult><StackDriftDetectionId>d1</StackDriftDetectionId></DetectStackDriftResult></DetectStackDriftResponse>`)
+	case "DescribeStackDriftDetectionStatus":
+		fmt.Fprint(w, `<DescribeStackDriftDetectionStatusResponse><DescribeStackDriftDetectionStatusResult>
+			<DetectionStatus>DETECTION_COMPLETE</DetectionStatus><StackDriftStatus>DRIFTED</StackDriftStatus>
+			</DescribeStackDriftDetectionStatusResult></DescribeStackDriftDetectionStatusResponse>`)
+	case "DescribeStackResourceDrifts":
+		fmt.Fprint(w, `<DescribeStackResourceDriftsResponse><DescribeStackResourceDriftsResult><StackResourceDrifts>
+			<member><LogicalResourceId>PublicMediaBucket</LogicalResourceId><ResourceType>AWS::S3::Bucket</ResourceType>
+				<StackResourceDriftStatus>MODIFIED</StackResourceDriftStatus><PropertyDifferences>
+				<member><PropertyPath>/VersioningConfiguration/Status</PropertyPath></member>
+				<member><PropertyPath>/Tags/0/Value</PropertyPath></member>
+				</PropertyDifferences></member>
+			<member><LogicalResourceId>UsersTable</LogicalResourceId><ResourceType>AWS::DynamoDB::Table</ResourceType>
+				<StackResourceDriftStatus>MODIFIED</StackResourceDriftStatus><PropertyDifferences>
+				<member><PropertyPath>/Tags/1/Value</PropertyPath></member>
+				</PropertyDifferences></member>
+			</StackResourceDrifts></DescribeStackResourceDriftsResult></DescribeStackResourceDriftsResponse>`)
+	default:
+		http.Error(w, "unexpected action "+action, http.StatusBadRequest)
+	}
+}
+
+func newTestCloudFormation(t *testing.T) (*CloudFormation, *fakeCloudFormation) {
+	t.Helper()
+	f := &fakeCloudFormation{}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return &CloudFormation{
+		Stack:     fstest.MapFS{TemplatePath: {Data: []byte("Resources: {}")}},
+		StackName: "aperture-prod",
+		Client: cloudformation.NewClient(cloudformation.Options{
+			Region:      "us-west-2",
+			Endpoint:    srv.URL,
+			Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		}),
+		PollInterval: time.Millisecond,
+	}, f
+}
+
+func TestCloudFormationDeploy(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "ops@uni.edu"})
+	backend, f := newTestCloudFormation(t)
+	d := &Deployer{Backend: backend, State: state.NewMemoryStore()}
+	opts := Options{
+		Environment: "prod",
+		Version:     "1.4.0",
+		Vars: Vars{Values: map[string]any{"project_name": "aperture", "environment": "prod",
+			"cors_allowed_origins": []string{"https://data.uni.edu", "https://uni.edu"}, "budget_alert_email": "ops@uni.edu"}},
+	}
+
+	if err := d.Plan(ctx, opts); err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	params := make(map[string]string)
+	for i := 1; f.created.Get(fmt.Sprintf("Parameters.member.%d.ParameterKey", i)) != ""; i++ {
+		params[f.created.Get(fmt.Sprintf("Parameters.member.%d.ParameterKey", i))] = f.created.Get(fmt.Sprintf("Parameters.member.%d.ParameterValue", i))
+	}
+	want := map[string]string{"ProjectName": "aperture", "Environment": "prod", "CorsAllowedOrigins": "https://data.uni.edu,https://uni.edu"}
+	if f.created.Get("ChangeSetType") != "CREATE" || !reflect.DeepEqual(params, want) {
+		t.Errorf("change set = %v", f.created)
+	}
+
+	dep, err := d.Apply(ctx, opts)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	var table string
+	if dep.Backend != "cloudformation" || json.Unmarshal(dep.Outputs["UsersTableName"], &table) != nil || table != "aperture-users-prod" {
+		t.Errorf("Apply() = %+v", dep)
+	}
+	if !slices.Contains(f.actions, "ExecuteChangeSet") {
+		t.Errorf("actions = %v", f.actions)
+	}
+
+	// A dry run deletes its change set.
+	opts.DryRun = true
+	if err := d.Plan(ctx, opts); err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if f.created != nil {
+		t.Error("dry-run change set kept")
+	}
+	if opts.VarFiles = []string{"orcid.tfvars"}; d.Plan(ctx, opts) == nil {
+		t.Error("Plan() with a Terraform variables file succeeded")
+	}
+}
+
+func TestCloudFormationDrift(t *testing.T) {
+	backend, _ := newTestCloudFormation(t)
+	d := &Deployer{Backend: backend, State: state.NewMemoryStore()}
+	r, err := d.Drift(context.Background(), Options{Environment: "prod"})
+	if err != nil {
+		t.Fatalf("Drift() error = %v", err)
+	}
+	want := []Drifted{
+		{Address: "PublicMediaBucket", Type: "AWS::S3::Bucket", Action: "update", Attributes: []string{"Tags", "VersioningConfiguration"}, Severity: SeveritySecurity},
+		{Address: "UsersTable", Type: "AWS::DynamoDB::Table", Action: "update", Attributes: []string{"Tags"}, Severity: SeverityCosmetic},
+	}
+	if !reflect.DeepEqual(r.Resources, want) {
+		t.Errorf("Drift() = %+v, want %+v", r.Resources, want)
+	}
+}
+
+func TestCloudFormationDestroy(t *testing.T) {
+	backend, f := newTestCloudFormation(t)
+	f.status = "UPDATE_COMPLETE"
+	if err := backend.Destroy(context.Background(), Options{Environment: "prod"}, io.Discard); err != nil {
+		t.Fatalf("Destroy() error = %v", err)
+	}
+	if !slices.Equal(f.actions, []string{"DeleteStack", "DescribeStacks"}) {
+		t.Errorf("actions = %v", f.actions)
+	}
+}
+
+func TestTemplateResources(t *testing.T) {
+	template := `Parameters:
+  Environment:
+    Type: String
+Resources:
+  # Buckets
+  LogsBucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Sub aperture-${Environment}-logs
+
+  UsersTable:
+    DeletionPolicy: Retain
+    Type: AWS::DynamoDB::Table
+    Properties:
+      AttributeDefinitions:
+        - AttributeName: pk
+          AttributeType: S
+Outputs:
+  UsersTableName:
+    Value: !Ref UsersTable
+`
+	want := []Resource{{Address: "LogsBucket", Type: "AWS::S3::Bucket"}, {Address: "UsersTable", Type: "AWS::DynamoDB::Table"}}
+	if got := templateResources([]byte(template)); !reflect.DeepEqual(got, want) {
+		t.Errorf("templateResources() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package deploy provisions the platform's AWS infrastructure with the
+// stacks built into the CLI.
+//
+// A Backend deploys a stack with a particular tool: Terraform, which
+// runs terraform init, plan, and apply on the Terraform stack, or
+// CloudFormation, which deploys a template through a change set for
+// institutions whose cloud teams do not allow Terraform. Either way the
+// input variables derived from the configuration are passed to the
+// stack and its changes planned before they are applied. Each applied
+// deployment is recorded with the backend, the version of the stack,
+// and its outputs, one record per environment.
+package deploy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/config"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+const deploymentsTable = "deployments"
+
+// stacksTable archives the files of each stack applied, by StackHash,
+// for Rollback.
+const stacksTable = "deployment-stacks"
+
+// Backend deploys a stack with a particular tool. *Terraform and
+// *CloudFormation implement it.
+type Backend interface {
+	// Name identifies the backend, e.g. "terraform"
+	Name() string
+
+	// Source returns the files of the stack deployed
+	Source() fs.FS
+
+	// WithSource returns a copy of the backend deploying stack instead
+	WithSource(stack fs.FS) Backend
+
+	// Plan shows the changes a deployment makes on out and prepares
+	// them for Apply, unless opts.DryRun is set
+	Plan(ctx context.Context, opts Options, out io.Writer) error
+
+	// Resources returns the resources the stack holds once the changes
+	// Plan shows are applied
+	Resources(ctx context.Context, opts Options, out io.Writer) ([]Resource, error)
+
+	// Apply makes the changes prepared by Plan and returns the stack's
+	// outputs, except sensitive ones
+	Apply(ctx context.Context, opts Options, out io.Writer) (map[string]json.RawMessage, error)
+
+	// Drift returns the deployed resources changed outside the backend,
+	// with their severities
+	Drift(ctx context.Context, opts Options, out io.Writer) ([]Drifted, error)
+
+	// Destroy deletes the deployed resources, deleting buckets that
+	// still hold objects only if opts.ForceDeleteData is set
+	Destroy(ctx context.Context, opts Options, out io.Writer) error
+}
+
+// Vars are the input variables of the stack.
+type Vars struct {
+	// Values are written to Terraform's VarsFile, or passed as
+	// CloudFormation template parameters
+	Values map[string]any
+
+	// Secrets are passed to Terraform as TF_VAR_ environment variables;
+	// empty ones are left out. The CloudFormation template takes none.
+	Secrets map[string]string
+}
+
+// ConfigVars returns the input variables derived from cfg. Variables
+// cfg has no setting for keep the stack's defaults, or are supplied in
+// extra variable files.
+func ConfigVars(cfg *config.Config) Vars {
+	v := Vars{
+		Values: map[string]any{
+			"aws_region":       cfg.AWSRegion,
+			"environment":      cfg.Environment,
+			"project_name":     cfg.ProjectName,
+			"datacite_prefix":  cfg.DataCitePrefix,
+			"datacite_api_url": cfg.DataCiteURL,
+			"datacite_mds_url": cfg.DataCiteMDSURL,
+		},
+		Secrets: map[string]string{
+			"datacite_username": cfg.DataCiteRepositoryID,
+			"datacite_password": cfg.DataCitePassword,
+		},
+	}
+	if cfg.BudgetAlertEmail != "" {
+		v.Values["budget_alert_email"] = cfg.BudgetAlertEmail
+	}
+	for layer, role := range cfg.DeployRoles {
+		v.Values[layer+"_role_arn"] = role
+	}
+	if cfg.StorageKMSKey != "" {
+		v.Values["storage_kms_key_arn"] = cfg.StorageKMSKey
+	}
+	if len(cfg.Admins) > 0 {
+		v.Values["admins"] = cfg.Admins
+	}
+	if cfg.OpenSearchURL != "" {
+		v.Values["opensearch_url"] = cfg.OpenSearchURL
+	}
+	if u, err := url.Parse(cfg.SiteURL); err == nil && u.Host != "" {
+		v.Values["domain_name"] = u.Hostname()
+		v.Values["cors_allowed_origins"] = []string{u.Scheme + "://" + u.Host}
+		v.Values["cognito_callback_urls"] = []string{strings.TrimRight(cfg.SiteURL, "/") + "/callback"}
+	}
+	return v
+}
+
+// Resource is a resource of a stack.
+type Resource struct {
+	// Address is the resource's Terraform address or CloudFormation
+	// logical ID
+	Address string `json:"address"`
+	Type    string `json:"type"`
+
+	// Values are the resource's planned attributes; the CloudFormation
+	// backend leaves them out
+	Values map[string]any `json:"values,omitempty"`
+}
+
+// Options describe a deployment.
+type Options struct {
+	// Environment is the environment deployed
+	Environment string
+
+	// Version and Commit identify the build whose stack is deployed
+	Version string
+	Commit  string
+
+	// Vars are the stack's input variables
+	Vars Vars
+
+	// VarFiles are further Terraform variable files, e.g. with ORCID
+	// or SAML settings
+	VarFiles []string
+
+	// DryRun plans without preparing the changes to be applied
+	DryRun bool
+
+	// BucketPrefix names the environment's buckets, which Destroy
+	// protects
+	BucketPrefix string
+
+	// ForceDeleteData lets Destroy delete buckets holding published
+	// datasets
+	ForceDeleteData bool
+}
+
+// Deployment records a deployment applied to an environment.
+type Deployment struct {
+	Environment string `json:"environment"`
+	Version     string `json:"version"`
+	Commit      string `json:"commit,omitempty"`
+
+	// Backend names the backend that applied the deployment
+	Backend string `json:"backend,omitempty"`
+
+	// StackHash is the SHA-256 of the stack's files
+	StackHash string `json:"stackHash"`
+
+	// Outputs are the stack's outputs, except sensitive ones
+	Outputs map[string]json.RawMessage `json:"outputs"`
+
+	DeployedBy string    `json:"deployedBy"`
+	DeployedAt time.Time `json:"deployedAt"`
+}
+
+// Deployer deploys the stack of its backend and records deployments.
+type Deployer struct {
+	// Backend deploys the stack
+	Backend Backend
+
+	// Out receives the backend's progress; io.Discard if nil
+	Out io.Writer
+
+	// State holds the deployment records
+	State state.Store
+
+	// Datasets is the catalog Destroy protects and backs up
+	Datasets *dataset.Store
+
+	// Traffic shifts the aliases of the API functions to the versions
+	// an applied deployment published; they are left alone if nil
+	Traffic *TrafficShift
+
+	// Registry publishes the outputs of applied deployments and
+	// removes those of destroyed ones; skipped if nil
+	Registry *Registry
+
+	// Log records applied and destroyed deployments; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Plan plans the changes a deployment makes, preparing them for Apply
+// unless opts.DryRun is set.
+func (d *Deployer) Plan(ctx context.Context, opts Options) error {
+	return d.Backend.Plan(ctx, opts, d.out())
+}
+
+// Resources returns the resources the stack holds once the planned
+// changes are applied.
+func (d *Deployer) Resources(ctx context.Context, opts Options) ([]Resource, error) {
+	return d.Backend.Resources(ctx, opts, d.out())
+}
+
+// Apply applies the changes prepared by Plan, records the deployment,
+// publishes its outputs to the registry, and shifts traffic to the API functions' new versions. If an alarm
+// fires during the shift, traffic is moved back and an error wrapping
+// ErrTrafficRolledBack is returned with the deployment.
+func (d *Deployer) Apply(ctx context.Context, opts Options) (*Deployment, error) {
+	outputs, err := d.Backend.Apply(ctx, opts, d.out())
+	if err != nil {
+		return nil, err
+	}
+	hash, err := StackHash(d.Backend.Source())
+	if err != nil {
+		return nil, err
+	}
+	if err := d.archive(ctx, hash); err != nil {
+		return nil, fmt.Errorf("%s is deployed, but failed to archive its stack: %w", opts.Environment, err)
+	}
+
+	dep := &Deployment{
+		Environment: opts.Environment,
+		Version:     opts.Version,
+		Commit:      opts.Commit,
+		Backend:     d.Backend.Name(),
+		StackHash:   hash,
+		Outputs:     outputs,
+		DeployedBy:  identity.FromContext(ctx).String(),
+		DeployedAt:  d.now().UTC(),
+	}
+	if dep.Outputs == nil {
+		dep.Outputs = make(map[string]json.RawMessage)
+	}
+	if err := d.State.Put(ctx, deploymentsTable, opts.Environment, dep); err != nil {
+		return nil, fmt.Errorf("%s is deployed, but failed to record it: %w", opts.Environment, err)
+	}
+	if d.Registry != nil {
+		if err := d.Registry.Publish(ctx, opts.Environment, dep.Outputs); err != nil {
+			return nil, fmt.Errorf("%s is deployed, but failed to publish its outputs: %w", opts.Environment, err)
+		}
+	}
+	if d.Log != nil {
+		details := map[string]string{"backend": dep.Backend, "version": dep.Version, "stack": dep.StackHash}
+		if dep.Commit != "" {
+			details["commit"] = dep.Commit
+		}
+		if err := audit.Record(ctx, d.Log, "deploy.apply", opts.Environment, details); err != nil {
+			return dep, err
+		}
+	}
+	return dep, d.shiftTraffic(ctx, dep)
+}
+
+// Current returns the deployment last applied to environment, or an
+// error wrapping state.ErrNotFound if there is none.
+func (d *Deployer) Current(ctx context.Context, environment string) (*Deployment, error) {
+	var dep Deployment
+	if err := d.State.Get(ctx, deploymentsTable, environment, &dep); err != nil {
+		return nil, err
+	}
+	return &dep, nil
+}
+
+// StackHash returns the SHA-256 of the paths and contents of the files
+// in stack, identifying the stack a deployment applied.
+func StackHash(stack fs.FS) (string, error) {
+	h := sha256.New()
+	err := fs.WalkDir(stack, ".", func(path string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(stack, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", path, len(data))
+		h.Write(data)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (d *Deployer) out() io.Writer {
+	if d.Out != nil {
+		return d.Out
+	}
+	return io.Discard
+}
+
+func (d *Deployer) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+	return time.Now()
+}
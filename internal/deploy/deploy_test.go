@@ -0,0 +1,363 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/config"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// fakeTerraform records the commands run and answers show -json and
+// output -json.
+type fakeTerraform struct {
+	runs [][]string
+	env  []string
+}
+
+func (f *fakeTerraform) Run(_ context.Context, _ string, env []string, stdout io.Writer, args ...string) error {
+	f.runs = append(f.runs, args)
+	f.env = env
+	if args[0] == "show" {
+		io.WriteString(stdout, `{"planned_values": {"root_module": {
+			"resources": [{"address": "aws_kms_grant.storage", "mode": "managed", "type": "aws_kms_grant", "values": {"name": "g"}}],
+			"child_modules": [{"resources": [
+				{"address": "module.s3.data.aws_region.current", "mode": "data", "type": "aws_region"},
+				{"address": "module.s3.aws_s3_bucket.frontend", "mode": "managed", "type": "aws_s3_bucket", "values": {"bucket": "f"}}
+			]}]
+		}}, "resource_drift": [
+			{"address": "module.s3.aws_s3_bucket.frontend", "type": "aws_s3_bucket",
+				"change": {"actions": ["update"], "before": {"bucket": "f", "tags": {"a": "1"}}, "after": {"bucket": "f", "tags": {"a": "2"}}}},
+			{"address": "module.s3.aws_s3_bucket_public_access_block.frontend", "type": "aws_s3_bucket_public_access_block",
+				"change": {"actions": ["update"], "before": {"block_public_acls": true}, "after": {"block_public_acls": false}}},
+			{"address": "module.lambda.aws_cloudwatch_log_group.auth", "type": "aws_cloudwatch_log_group",
+				"change": {"actions": ["delete"], "before": {"name": "auth"}, "after": null}}
+		]}`)
+	}
+	if args[0] == "output" {
+		io.WriteString(stdout, `{"site_url": {"sensitive": false, "type": "string", "value": "https://d1.cloudfront.net"},
+			"client_secret": {"sensitive": true, "type": "string", "value": "hush"}}`)
+	}
+	return nil
+}
+
+func TestConfigVars(t *testing.T) {
+	cfg := &config.Config{Environment: "prod", AWSRegion: "us-west-2", ProjectName: "aperture", DataCitePrefix: "10.5555",
+		DataCiteRepositoryID: "UNI.REPO", DataCitePassword: "secret", SiteURL: "https://data.uni.edu/", BudgetAlertEmail: "ops@uni.edu",
+		DeployRoles: map[string]string{"storage": "arn:aws:iam::111111111111:role/aperture-deploy"},
+		Admins:      []string{"ops@uni.edu"}, OpenSearchURL: "https://search.uni.edu"}
+	v := ConfigVars(cfg)
+	if v.Values["domain_name"] != "data.uni.edu" || v.Values["budget_alert_email"] != "ops@uni.edu" ||
+		v.Values["opensearch_url"] != "https://search.uni.edu" || !slices.Equal(v.Values["admins"].([]string), []string{"ops@uni.edu"}) ||
+		v.Values["storage_role_arn"] != "arn:aws:iam::111111111111:role/aperture-deploy" || v.Values["compute_role_arn"] != nil ||
+		!slices.Equal(v.Values["cognito_callback_urls"].([]string), []string{"https://data.uni.edu/callback"}) {
+		t.Errorf("Values = %v", v.Values)
+	}
+	if _, ok := v.Values["datacite_password"]; ok {
+		t.Error("secret written to the variables file")
+	}
+	want := []string{"TF_IN_AUTOMATION=1", "TF_VAR_datacite_password=secret", "TF_VAR_datacite_username=UNI.REPO"}
+	if got := v.env(); !slices.Equal(got, want) {
+		t.Errorf("env() = %v, want %v", got, want)
+	}
+}
+
+// fakeBuilder records the functions built and writes a placeholder
+// executable for each.
+type fakeBuilder struct {
+	built []string
+}
+
+func (f *fakeBuilder) Build(_ context.Context, function, out string) error {
+	f.built = append(f.built, function)
+	return os.WriteFile(out, []byte(function), 0o755)
+}
+
+func TestDeploy(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "ops@uni.edu"})
+	stack := fstest.MapFS{
+		"main.tf": {Data: []byte(`module "s3" { source = "./infrastructure/terraform/modules/s3" }`)},
+		"infrastructure/terraform/modules/s3/main.tf": {Data: []byte(`resource "aws_s3_bucket" "b" {}`)},
+	}
+	dir := t.TempDir()
+	// A module dropped from the stack is removed from the working
+	// directory.
+	stale := filepath.Join(dir, "infrastructure/terraform/modules/old/main.tf")
+	if err := os.MkdirAll(filepath.Dir(stale), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tf := &fakeTerraform{}
+	build := &fakeBuilder{}
+	s := state.NewMemoryStore()
+	log := &audit.MemoryLog{}
+	backend := &Terraform{Stack: stack, Dir: dir, Runner: tf, Build: build,
+		StateBackend: map[string]string{"bucket": "uni-tfstate", "key": "aperture/prod.tfstate", "region": "us-west-2"}}
+	d := &Deployer{Backend: backend, State: s, Log: log,
+		Now: func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) }}
+	opts := Options{
+		Environment: "prod",
+		Version:     "1.4.0",
+		Commit:      "abc1234",
+		Vars:        Vars{Values: map[string]any{"environment": "prod"}, Secrets: map[string]string{"datacite_password": "secret"}},
+		VarFiles:    []string{"/etc/aperture/orcid.tfvars"},
+	}
+
+	if err := d.Plan(ctx, opts); err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "infrastructure/terraform/modules/s3/main.tf")); err != nil {
+		t.Errorf("module not extracted: %v", err)
+	}
+	if _, err := os.Stat(stale); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stale module kept: %v", err)
+	}
+	if !slices.Equal(build.built, Functions) {
+		t.Errorf("built %v, want %v", build.built, Functions)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "lambdas/doi/bootstrap")); err != nil || string(data) != "doi" {
+		t.Errorf("lambdas/doi/bootstrap = %q, %v", data, err)
+	}
+	vars, err := os.ReadFile(filepath.Join(dir, VarsFile))
+	if err != nil || strings.Contains(string(vars), "secret") || !strings.Contains(string(vars), `"environment": "prod"`) {
+		t.Errorf("%s = %s, %v", VarsFile, vars, err)
+	}
+	want := [][]string{
+		{"init", "-input=false", "-reconfigure", "-backend-config=bucket=uni-tfstate", "-backend-config=key=aperture/prod.tfstate", "-backend-config=region=us-west-2"},
+		{"plan", "-input=false", "-out=" + PlanFile, "-var-file=/etc/aperture/orcid.tfvars"},
+	}
+	if !slices.EqualFunc(tf.runs, want, slices.Equal) {
+		t.Errorf("runs = %v, want %v", tf.runs, want)
+	}
+	if !slices.Contains(tf.env, "TF_VAR_datacite_password=secret") {
+		t.Errorf("env = %v", tf.env)
+	}
+
+	dep, err := d.Apply(ctx, opts)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got := tf.runs[2]; !slices.Equal(got, []string{"apply", "-input=false", PlanFile}) {
+		t.Errorf("apply run = %v", got)
+	}
+	hash, _ := StackHash(stack)
+	if dep.Version != "1.4.0" || dep.Backend != "terraform" || dep.StackHash != hash || dep.DeployedBy != "ops@uni.edu" || len(dep.Outputs) != 1 {
+		t.Errorf("Apply() = %+v", dep)
+	}
+	var site string
+	if err := json.Unmarshal(dep.Outputs["site_url"], &site); err != nil || site != "https://d1.cloudfront.net" {
+		t.Errorf("site_url output = %s", dep.Outputs["site_url"])
+	}
+	current, err := d.Current(ctx, "prod")
+	if err != nil || current.StackHash != hash || !current.DeployedAt.Equal(dep.DeployedAt) {
+		t.Errorf("Current() = %+v, %v", current, err)
+	}
+	if entries, _ := log.Entries(ctx); len(entries) != 1 || entries[0].Action != "deploy.apply" {
+		t.Errorf("audit = %+v", entries)
+	}
+	if _, err := d.Current(ctx, "dev"); !errors.Is(err, state.ErrNotFound) {
+		t.Errorf("Current() of an undeployed environment error = %v", err)
+	}
+
+	// A dry run does not save a plan.
+	tf.runs = nil
+	opts.DryRun = true
+	if err := d.Plan(ctx, opts); err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if got := tf.runs[1]; slices.Contains(got, "-out="+PlanFile) {
+		t.Errorf("dry-run plan = %v", got)
+	}
+}
+
+func TestSourceDir(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module github.com/scttfrdmn/aperture\n\ngo 1.25\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(root, "cmd", "aperture")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := SourceDir(nested); err != nil || got != root {
+		t.Errorf("SourceDir() = %q, %v, want %q", got, err, root)
+	}
+
+	other := t.TempDir()
+	if err := os.WriteFile(filepath.Join(other, "go.mod"), []byte("module example.org/site\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SourceDir(other); err == nil {
+		t.Error("SourceDir() of another module succeeded")
+	}
+}
+
+func TestResources(t *testing.T) {
+	ctx := context.Background()
+	tf := &fakeTerraform{}
+	d := &Deployer{Backend: &Terraform{Stack: fstest.MapFS{"main.tf": {}}, Dir: t.TempDir(), Runner: tf}, State: state.NewMemoryStore()}
+	got, err := d.Resources(ctx, Options{Environment: "prod"})
+	if err != nil {
+		t.Fatalf("Resources() error = %v", err)
+	}
+	want := []Resource{
+		{Address: "aws_kms_grant.storage", Type: "aws_kms_grant", Values: map[string]any{"name": "g"}},
+		{Address: "module.s3.aws_s3_bucket.frontend", Type: "aws_s3_bucket", Values: map[string]any{"bucket": "f"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Resources() = %+v, want %+v", got, want)
+	}
+	if len(tf.runs) != 1 || !slices.Equal(tf.runs[0], []string{"show", "-json", PlanFile}) {
+		t.Errorf("runs = %v", tf.runs)
+	}
+
+	// A dry run saved no plan to read back.
+	tf.runs = nil
+	if _, err := d.Resources(ctx, Options{Environment: "prod", DryRun: true}); err != nil {
+		t.Fatalf("Resources() error = %v", err)
+	}
+	if len(tf.runs) != 2 || !slices.Equal(tf.runs[0], []string{"plan", "-input=false", "-out=" + resourcesPlanFile}) {
+		t.Errorf("dry-run runs = %v", tf.runs)
+	}
+}
+
+func TestDrift(t *testing.T) {
+	ctx := context.Background()
+	tf := &fakeTerraform{}
+	d := &Deployer{Backend: &Terraform{Stack: fstest.MapFS{"main.tf": {}}, Dir: t.TempDir(), Runner: tf}, State: state.NewMemoryStore()}
+	r, err := d.Drift(ctx, Options{Environment: "prod"})
+	if err != nil {
+		t.Fatalf("Drift() error = %v", err)
+	}
+	if got := tf.runs[1]; !slices.Equal(got, []string{"plan", "-refresh-only", "-input=false", "-out=" + driftPlanFile}) {
+		t.Errorf("plan run = %v", got)
+	}
+	want := []Drifted{
+		{Address: "module.s3.aws_s3_bucket_public_access_block.frontend", Type: "aws_s3_bucket_public_access_block", Action: "update",
+			Attributes: []string{"block_public_acls"}, Severity: SeveritySecurity},
+		{Address: "module.lambda.aws_cloudwatch_log_group.auth", Type: "aws_cloudwatch_log_group", Action: "delete", Severity: SeverityConfig},
+		{Address: "module.s3.aws_s3_bucket.frontend", Type: "aws_s3_bucket", Action: "update", Attributes: []string{"tags"}, Severity: SeverityCosmetic},
+	}
+	if !reflect.DeepEqual(r.Resources, want) {
+		t.Errorf("Drift() = %+v, want %+v", r.Resources, want)
+	}
+	if r.Count(SeveritySecurity) != 1 {
+		t.Errorf("Count(security) = %d", r.Count(SeveritySecurity))
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	tests := []struct {
+		typ, action string
+		attributes  []string
+		want        string
+	}{
+		{"aws_iam_role_policy", "update", []string{"name"}, SeveritySecurity},
+		{"aws_cognito_user_pool", "delete", nil, SeveritySecurity},
+		{"aws_apigatewayv2_route", "update", []string{"authorization_type"}, SeveritySecurity},
+		{"aws_cloudfront_distribution", "update", []string{"viewer_certificate", "comment"}, SeveritySecurity},
+		{"aws_lambda_function", "update", []string{"memory_size", "tags"}, SeverityConfig},
+		{"aws_dynamodb_table", "update", []string{"tags", "tags_all"}, SeverityCosmetic},
+		{"AWS::IAM::Role", "update", []string{"MaxSessionDuration"}, SeveritySecurity},
+		{"AWS::S3::Bucket", "update", []string{"LoggingConfiguration"}, SeveritySecurity},
+		{"AWS::DynamoDB::Table", "update", []string{"SSESpecification"}, SeveritySecurity},
+		{"AWS::DynamoDB::Table", "update", []string{"BillingMode", "Tags"}, SeverityConfig},
+	}
+	for _, tt := range tests {
+		if got := Severity(tt.typ, tt.action, tt.attributes); got != tt.want {
+			t.Errorf("Severity(%s, %s, %v) = %s, want %s", tt.typ, tt.action, tt.attributes, got, tt.want)
+		}
+	}
+}
+
+func TestDestroy(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "ops@uni.edu"})
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	published := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", Versions: []dataset.Version{{Number: 1, PublishedAt: &published,
+			Files: []dataset.File{{Path: "a.csv", Bucket: "aperture-prod-public-media", Key: "ds-1/a.csv"}}}}},
+		// Drafts and datasets of other environments are not protected.
+		{ID: "ds-2", Versions: []dataset.Version{{Number: 1,
+			Files: []dataset.File{{Path: "b.csv", Bucket: "aperture-prod-private-media", Key: "ds-2/b.csv"}}}}},
+		{ID: "ds-3", Versions: []dataset.Version{{Number: 1, PublishedAt: &published,
+			Files: []dataset.File{{Path: "c.csv", Bucket: "aperture-dev-public-media", Key: "ds-3/c.csv"}}}}},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Put(ctx, deploymentsTable, "prod", &Deployment{Environment: "prod", Version: "1.4.0"}); err != nil {
+		t.Fatal(err)
+	}
+	tf := &fakeTerraform{}
+	log := &audit.MemoryLog{}
+	d := &Deployer{Backend: &Terraform{Stack: fstest.MapFS{"main.tf": {}}, Dir: t.TempDir(), Runner: tf},
+		State: s, Datasets: datasets, Log: log}
+	opts := Options{Environment: "prod", BucketPrefix: "aperture-prod"}
+
+	buckets, err := d.Published(ctx, opts.BucketPrefix)
+	if err != nil || !reflect.DeepEqual(buckets, map[string][]string{"aperture-prod-public-media": {"ds-1"}}) {
+		t.Errorf("Published() = %v, %v", buckets, err)
+	}
+	var backup strings.Builder
+	if err := d.Destroy(ctx, opts, &backup); !errors.Is(err, ErrPublishedData) {
+		t.Fatalf("Destroy() error = %v, want ErrPublishedData", err)
+	}
+	if len(tf.runs) != 0 || backup.Len() != 0 {
+		t.Errorf("refused destroy ran %v and wrote %q", tf.runs, backup.String())
+	}
+
+	opts.ForceDeleteData = true
+	if err := d.Destroy(ctx, opts, &backup); err != nil {
+		t.Fatalf("Destroy() error = %v", err)
+	}
+	var b Backup
+	if err := json.Unmarshal([]byte(backup.String()), &b); err != nil || len(b.Datasets) != 3 || b.Deployment == nil {
+		t.Errorf("backup = %+v, %v", b, err)
+	}
+	want := [][]string{
+		{"init", "-input=false", "-reconfigure"},
+		{"apply", "-input=false", "-auto-approve", "-target=module.s3_buckets", "-var=force_destroy=true"},
+		{"destroy", "-input=false", "-auto-approve", "-var=force_destroy=true"},
+	}
+	if !slices.EqualFunc(tf.runs, want, slices.Equal) {
+		t.Errorf("runs = %v, want %v", tf.runs, want)
+	}
+	if _, err := d.Current(ctx, "prod"); !errors.Is(err, state.ErrNotFound) {
+		t.Errorf("deployment record kept: %v", err)
+	}
+	if entries, _ := log.Entries(ctx); len(entries) != 1 || entries[0].Action != "deploy.destroy" || entries[0].Details["published"] != "aperture-prod-public-media" {
+		t.Errorf("audit = %+v", entries)
+	}
+}
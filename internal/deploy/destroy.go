@@ -0,0 +1,134 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// ErrPublishedData is returned by Destroy when the environment's
+// buckets hold published datasets and deleting them was not forced.
+var ErrPublishedData = errors.New("buckets hold published datasets")
+
+// Backup is the metadata exported before an environment is destroyed:
+// every dataset record, with its versions and manifests, and the
+// deployment destroyed.
+type Backup struct {
+	Environment string             `json:"environment"`
+	ExportedAt  time.Time          `json:"exportedAt"`
+	Deployment  *Deployment        `json:"deployment,omitempty"`
+	Datasets    []*dataset.Dataset `json:"datasets"`
+}
+
+// Published returns the buckets named with prefix that hold files of
+// published dataset versions, each with the IDs of those datasets.
+// Versions whose files were deleted hold nothing.
+func (d *Deployer) Published(ctx context.Context, prefix string) (map[string][]string, error) {
+	datasets, err := d.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	buckets := make(map[string][]string)
+	add := func(bucket, id string) {
+		if strings.HasPrefix(bucket, prefix+"-") && !slices.Contains(buckets[bucket], id) {
+			buckets[bucket] = append(buckets[bucket], id)
+		}
+	}
+	for _, ds := range datasets {
+		for _, v := range ds.Versions {
+			if v.PublishedAt == nil || v.Removed() {
+				continue
+			}
+			for _, f := range v.Files {
+				add(f.Bucket, ds.ID)
+				for _, dv := range f.Derivatives {
+					add(dv.Bucket, ds.ID)
+				}
+			}
+		}
+	}
+	for _, ids := range buckets {
+		slices.Sort(ids)
+	}
+	return buckets, nil
+}
+
+// Destroy deletes the deployed infrastructure of opts.Environment, its
+// deployment record, and its published outputs. It refuses with ErrPublishedData if buckets
+// named with opts.BucketPrefix hold published datasets, unless
+// opts.ForceDeleteData is set; otherwise it writes a Backup of the
+// catalog to backup before anything is deleted.
+func (d *Deployer) Destroy(ctx context.Context, opts Options, backup io.Writer) error {
+	published, err := d.Published(ctx, opts.BucketPrefix)
+	if err != nil {
+		return err
+	}
+	if len(published) > 0 && !opts.ForceDeleteData {
+		var held []string
+		for _, b := range slices.Sorted(maps.Keys(published)) {
+			held = append(held, fmt.Sprintf("%s (%d)", b, len(published[b])))
+		}
+		return fmt.Errorf("refusing to destroy %s: %w: %s", opts.Environment, ErrPublishedData, strings.Join(held, ", "))
+	}
+
+	b := Backup{Environment: opts.Environment, ExportedAt: d.now().UTC()}
+	if b.Datasets, err = d.Datasets.List(ctx); err != nil {
+		return err
+	}
+	if b.Deployment, err = d.Current(ctx, opts.Environment); err != nil && !errors.Is(err, state.ErrNotFound) {
+		return err
+	}
+	enc := json.NewEncoder(backup)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b); err != nil {
+		return fmt.Errorf("failed to write the metadata backup: %w", err)
+	}
+
+	if err := d.Backend.Destroy(ctx, opts, d.out()); err != nil {
+		return err
+	}
+	if err := d.State.Delete(ctx, deploymentsTable, opts.Environment); err != nil {
+		return fmt.Errorf("%s is destroyed, but failed to remove its deployment record: %w", opts.Environment, err)
+	}
+	if d.Registry != nil {
+		if err := d.Registry.Remove(ctx, opts.Environment); err != nil {
+			return fmt.Errorf("%s is destroyed, but failed to remove its published outputs: %w", opts.Environment, err)
+		}
+	}
+	if d.Log == nil {
+		return nil
+	}
+	details := map[string]string{"backend": d.Backend.Name(), "datasets": strconv.Itoa(len(b.Datasets))}
+	if opts.ForceDeleteData {
+		details["forceDeleteData"] = "true"
+	}
+	if len(published) > 0 {
+		details["published"] = strings.Join(slices.Sorted(maps.Keys(published)), " ")
+	}
+	return audit.Record(ctx, d.Log, "deploy.destroy", opts.Environment, details)
+}
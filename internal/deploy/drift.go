@@ -0,0 +1,163 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Drift severities, from most to least urgent.
+const (
+	// SeveritySecurity is drift in access control, encryption, public
+	// access, authentication, or logging
+	SeveritySecurity = "security"
+
+	// SeverityConfig is any other drift in behavior
+	SeverityConfig = "config"
+
+	// SeverityCosmetic is drift in tags and descriptions alone
+	SeverityCosmetic = "cosmetic"
+)
+
+var severityOrder = []string{SeveritySecurity, SeverityConfig, SeverityCosmetic}
+
+// securityTypes are the Terraform and CloudFormation resource types
+// whose every change is security-relevant, by prefix.
+var securityTypes = []string{
+	"aws_iam_",
+	"aws_kms_",
+	"aws_security_group",
+	"aws_cognito_",
+	"aws_lambda_permission",
+	"aws_s3_bucket_acl",
+	"aws_s3_bucket_cors_configuration",
+	"aws_s3_bucket_logging",
+	"aws_s3_bucket_policy",
+	"aws_s3_bucket_public_access_block",
+	"aws_s3_bucket_server_side_encryption_configuration",
+	"aws_s3_bucket_versioning",
+	"aws_cloudfront_origin_access_control",
+	"aws_cloudfront_response_headers_policy",
+	"aws_apigatewayv2_authorizer",
+	"aws_cloudwatch_log_resource_policy",
+	"AWS::IAM::",
+	"AWS::KMS::",
+	"AWS::EC2::SecurityGroup",
+	"AWS::Cognito::",
+	"AWS::Lambda::Permission",
+	"AWS::S3::BucketPolicy",
+	"AWS::CloudFront::OriginAccessControl",
+	"AWS::CloudFront::ResponseHeadersPolicy",
+	"AWS::ApiGatewayV2::Authorizer",
+	"AWS::Logs::ResourcePolicy",
+}
+
+// securityAttributes mark changes to attributes of other resources as
+// security-relevant, by substring of the lowercased attribute name.
+// CloudFormation keeps settings Terraform splits into separate
+// resources, such as a bucket's logging and versioning, as properties.
+var securityAttributes = []string{"acl", "accesscontrol", "auth", "certificate", "cors", "encrypt", "kms", "logging",
+	"policy", "public", "role", "ssespecification", "versioning"}
+
+// cosmeticAttributes are the attributes whose changes alone are
+// cosmetic.
+var cosmeticAttributes = []string{"comment", "description", "tags", "tags_all"}
+
+// Drifted is a resource changed outside the backend that deployed it.
+type Drifted struct {
+	// Address is the resource's Terraform address or CloudFormation
+	// logical ID
+	Address string `json:"address"`
+	Type    string `json:"type"`
+
+	// Action is "update", or "delete" if the resource no longer exists
+	Action string `json:"action"`
+
+	// Attributes lists the top-level attributes or properties that
+	// changed
+	Attributes []string `json:"attributes,omitempty"`
+
+	Severity string `json:"severity"`
+}
+
+// DriftReport is the outcome of a drift check.
+type DriftReport struct {
+	Environment string    `json:"environment"`
+	CheckedAt   time.Time `json:"checkedAt"`
+
+	// Resources are ordered by severity, then address
+	Resources []Drifted `json:"resources"`
+}
+
+// Count returns how many resources in r drifted with severity.
+func (r *DriftReport) Count(severity string) int {
+	n := 0
+	for _, d := range r.Resources {
+		if d.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// Drift returns the deployed resources changed outside the backend.
+// Nothing is changed.
+func (d *Deployer) Drift(ctx context.Context, opts Options) (*DriftReport, error) {
+	drifted, err := d.Backend.Drift(ctx, opts, d.out())
+	if err != nil {
+		return nil, err
+	}
+	r := &DriftReport{Environment: opts.Environment, CheckedAt: d.now().UTC(), Resources: drifted}
+	if r.Resources == nil {
+		r.Resources = []Drifted{}
+	}
+	slices.SortFunc(r.Resources, func(a, b Drifted) int {
+		return cmp.Or(
+			cmp.Compare(slices.Index(severityOrder, a.Severity), slices.Index(severityOrder, b.Severity)),
+			cmp.Compare(a.Address, b.Address),
+		)
+	})
+	return r, nil
+}
+
+// Severity classifies drift of a resource of type typ: the action
+// the backend would take to record it, and the attributes changed.
+func Severity(typ, action string, attributes []string) string {
+	for _, prefix := range securityTypes {
+		if strings.HasPrefix(typ, prefix) {
+			return SeveritySecurity
+		}
+	}
+	if action == "delete" {
+		return SeverityConfig
+	}
+	severity := SeverityCosmetic
+	for _, attr := range attributes {
+		attr = strings.ToLower(attr)
+		for _, s := range securityAttributes {
+			if strings.Contains(attr, s) {
+				return SeveritySecurity
+			}
+		}
+		if !slices.Contains(cosmeticAttributes, attr) {
+			severity = SeverityConfig
+		}
+	}
+	return severity
+}
@@ -0,0 +1,283 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// Check is a smoke test of a deployed environment.
+type Check struct {
+	// Name identifies the check, e.g. "doi"
+	Name string
+
+	// Run returns an error if the environment fails the check
+	Run func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of a Check.
+type CheckResult struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// Promotion is the outcome of Promote.
+type Promotion struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// Deployment is the deployment applied to To, if any
+	Deployment *Deployment `json:"deployment,omitempty"`
+
+	// Checks are the smoke tests run once it was applied
+	Checks []CheckResult `json:"checks,omitempty"`
+
+	// RolledBack is the earlier deployment restored after the
+	// promotion failed
+	RolledBack *Deployment `json:"rolledBack,omitempty"`
+}
+
+// Promote deploys the stack running in the from environment to
+// opts.Environment and runs checks against it. Environments are
+// promoted in order: from must run this deployer's stack already. If
+// the deployment or a check fails, the environment's previous
+// deployment is restored with Rollback.
+func (d *Deployer) Promote(ctx context.Context, from string, opts Options, checks []Check) (*Promotion, error) {
+	p := &Promotion{From: from, To: opts.Environment}
+	src, err := d.Current(ctx, from)
+	if errors.Is(err, state.ErrNotFound) {
+		return p, fmt.Errorf("nothing is deployed to %s to promote", from)
+	}
+	if err != nil {
+		return p, err
+	}
+	hash, err := StackHash(d.Backend.Source())
+	if err != nil {
+		return p, err
+	}
+	if src.StackHash != hash {
+		return p, fmt.Errorf("%s runs stack %.12s, not this CLI's stack %.12s; deploy it to %s first", from, src.StackHash, hash, from)
+	}
+	prev, err := d.Current(ctx, opts.Environment)
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		return p, err
+	}
+
+	if err := d.Plan(ctx, opts); err != nil || opts.DryRun {
+		return p, err
+	}
+	p.Deployment, err = d.Apply(ctx, opts)
+	if err == nil {
+		p.Checks, err = RunChecks(ctx, checks)
+	}
+	details := map[string]string{"from": from, "stack": hash, "version": opts.Version}
+	if err != nil {
+		details["error"] = err.Error()
+		err = fmt.Errorf("promotion of %s to %s failed: %w", from, opts.Environment, err)
+		if prev == nil {
+			err = fmt.Errorf("%w; %s had no earlier deployment to roll back to", err, opts.Environment)
+		} else if rolledBack, rerr := d.Rollback(ctx, opts, prev); rerr != nil {
+			err = fmt.Errorf("%w; rolling back to v%s failed: %v", err, prev.Version, rerr)
+		} else {
+			p.RolledBack = rolledBack
+			details["rolledBack"] = prev.StackHash
+			err = fmt.Errorf("%w; rolled back to v%s", err, prev.Version)
+		}
+	}
+	if d.Log != nil {
+		if lerr := audit.Record(ctx, d.Log, "deploy.promote", opts.Environment, details); err == nil {
+			err = lerr
+		}
+	}
+	return p, err
+}
+
+// Rollback redeploys the stack of prev, an earlier deployment of
+// opts.Environment, from the archive Apply keeps, with prev's version
+// and commit.
+func (d *Deployer) Rollback(ctx context.Context, opts Options, prev *Deployment) (*Deployment, error) {
+	if prev.Backend != "" && prev.Backend != d.Backend.Name() {
+		return nil, fmt.Errorf("v%s was deployed with %s, not %s", prev.Version, prev.Backend, d.Backend.Name())
+	}
+	var files map[string][]byte
+	err := d.State.Get(ctx, stacksTable, prev.StackHash, &files)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("the stack of v%s (%.12s) was not archived; deploy it with that version of the CLI", prev.Version, prev.StackHash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "aperture-stack-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	for path, data := range files {
+		dst := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(dst, data, 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	r := *d
+	r.Backend = d.Backend.WithSource(os.DirFS(dir))
+	opts.Version, opts.Commit, opts.DryRun = prev.Version, prev.Commit, false
+	if err := r.Plan(ctx, opts); err != nil {
+		return nil, err
+	}
+	dep, err := r.Apply(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if d.Log == nil {
+		return dep, nil
+	}
+	return dep, audit.Record(ctx, d.Log, "deploy.rollback", opts.Environment,
+		map[string]string{"version": dep.Version, "stack": dep.StackHash})
+}
+
+// archive keeps the files of the backend's stack, whose StackHash is
+// hash, for Rollback.
+func (d *Deployer) archive(ctx context.Context, hash string) error {
+	stack := d.Backend.Source()
+	files := make(map[string][]byte)
+	err := fs.WalkDir(stack, ".", func(path string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+		files[path], err = fs.ReadFile(stack, path)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return d.State.Put(ctx, stacksTable, hash, files)
+}
+
+// RunChecks runs checks in order, each whether or not those before it
+// passed, and returns their results and an error naming the first that
+// failed.
+func RunChecks(ctx context.Context, checks []Check) ([]CheckResult, error) {
+	var (
+		results []CheckResult
+		err     error
+	)
+	for _, c := range checks {
+		r := CheckResult{Name: c.Name}
+		if cerr := c.Run(ctx); cerr != nil {
+			r.Error = cerr.Error()
+			if err == nil {
+				err = fmt.Errorf("check %s failed: %w", c.Name, cerr)
+			}
+		}
+		results = append(results, r)
+	}
+	return results, err
+}
+
+// DOICheck checks that doi resolves through resolver, e.g.
+// https://doi.org/, to a page of siteURL's host.
+func DOICheck(client *http.Client, resolver, doi, siteURL string) Check {
+	return Check{Name: "doi", Run: func(ctx context.Context) error {
+		site, err := url.Parse(siteURL)
+		if err != nil {
+			return err
+		}
+		resp, err := get(ctx, client, strings.TrimRight(resolver, "/")+"/"+doi, nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s resolved to %s: %s", doi, resp.Request.URL, resp.Status)
+		}
+		if resp.Request.URL.Host != site.Host {
+			return fmt.Errorf("%s resolved to %s, not %s", doi, resp.Request.URL, site.Host)
+		}
+		return nil
+	}}
+}
+
+// PresignCheck checks that the presigned URL u downloads, fetching its
+// first byte.
+func PresignCheck(client *http.Client, u string) Check {
+	return Check{Name: "presign", Run: func(ctx context.Context) error {
+		resp, err := get(ctx, client, u, http.Header{"Range": {"bytes=0-0"}})
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+			return fmt.Errorf("presigned download failed: %s", resp.Status)
+		}
+		return nil
+	}}
+}
+
+// PageCheck checks that the page at u is served and contains marker.
+func PageCheck(client *http.Client, u, marker string) Check {
+	return Check{Name: "landing", Run: func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s: %s", u, resp.Status)
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(string(body), marker) {
+			return fmt.Errorf("%s does not serve the page published", u)
+		}
+		return nil
+	}}
+}
+
+// get fetches u, discarding the body.
+func get(ctx context.Context, client *http.Client, u string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
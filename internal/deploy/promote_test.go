@@ -0,0 +1,158 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func TestPromote(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "ops@uni.edu"})
+	s := state.NewMemoryStore()
+	log := &audit.MemoryLog{}
+	old := fstest.MapFS{"main.tf": {Data: []byte("# 1.3.0")}, "infrastructure/terraform/modules/s3/main.tf": {Data: []byte("# s3")}}
+	stack := fstest.MapFS{"main.tf": {Data: []byte("# 1.4.0")}}
+	deployer := func(stack fstest.MapFS) *Deployer {
+		return &Deployer{Backend: &Terraform{Stack: stack, Dir: t.TempDir(), Runner: &fakeTerraform{}}, State: s, Log: log}
+	}
+	deploy := func(d *Deployer, env, version string) {
+		t.Helper()
+		opts := Options{Environment: env, Version: version}
+		if err := d.Plan(ctx, opts); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.Apply(ctx, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deploy(deployer(old), "prod", "1.3.0")
+	d := deployer(stack)
+	opts := Options{Environment: "prod", Version: "1.4.0"}
+
+	// Staging must run the stack first.
+	if _, err := d.Promote(ctx, "staging", opts, nil); err == nil {
+		t.Error("Promote() from an undeployed environment succeeded")
+	}
+	deploy(deployer(old), "staging", "1.3.0")
+	if _, err := d.Promote(ctx, "staging", opts, nil); err == nil || !strings.Contains(err.Error(), "deploy it to staging first") {
+		t.Errorf("Promote() of a stack staging does not run error = %v", err)
+	}
+	deploy(d, "staging", "1.4.0")
+
+	failing := []Check{
+		{Name: "doi", Run: func(context.Context) error { return nil }},
+		{Name: "search", Run: func(context.Context) error { return errors.New("503 Service Unavailable") }},
+	}
+	p, err := d.Promote(ctx, "staging", opts, failing)
+	if err == nil || !strings.Contains(err.Error(), "rolled back to v1.3.0") {
+		t.Fatalf("Promote() error = %v", err)
+	}
+	if p.RolledBack == nil || len(p.Checks) != 2 || p.Checks[0].Error != "" || p.Checks[1].Error == "" {
+		t.Errorf("Promote() = %+v", p)
+	}
+	oldHash, _ := StackHash(old)
+	if cur, _ := d.Current(ctx, "prod"); cur.Version != "1.3.0" || cur.StackHash != oldHash {
+		t.Errorf("prod after rollback = %+v", cur)
+	}
+
+	p, err = d.Promote(ctx, "staging", opts, failing[:1])
+	if err != nil || p.RolledBack != nil || p.Deployment.Version != "1.4.0" {
+		t.Errorf("Promote() = %+v, %v", p, err)
+	}
+	entries, _ := log.Entries(ctx)
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	if !slices.Contains(actions, "deploy.rollback") || actions[len(actions)-1] != "deploy.promote" {
+		t.Errorf("audit = %v", actions)
+	}
+
+	// A deployment whose stack was not archived cannot be restored.
+	if _, err := d.Rollback(ctx, opts, &Deployment{Version: "1.0.0", StackHash: "unknown"}); err == nil {
+		t.Error("Rollback() to an unarchived stack succeeded")
+	}
+}
+
+func TestChecks(t *testing.T) {
+	ctx := context.Background()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/datasets/ds-1":
+		case "/datasets/smoke-1/":
+			_, _ = w.Write([]byte("<title>smoke-1</title>"))
+		case "/files/data.csv":
+			if r.Header.Get("Range") != "bytes=0-0" {
+				t.Errorf("Range = %q", r.Header.Get("Range"))
+			}
+			w.WriteHeader(http.StatusPartialContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer site.Close()
+	resolver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/10.5555/ds-1" {
+			http.Redirect(w, r, site.URL+"/datasets/ds-1", http.StatusFound)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer resolver.Close()
+
+	if err := DOICheck(http.DefaultClient, resolver.URL, "10.5555/ds-1", site.URL).Run(ctx); err != nil {
+		t.Errorf("DOI check error = %v", err)
+	}
+	if err := DOICheck(http.DefaultClient, resolver.URL, "10.5555/ds-2", site.URL).Run(ctx); err == nil {
+		t.Error("DOI check of an unregistered DOI passed")
+	}
+	if err := DOICheck(http.DefaultClient, resolver.URL, "10.5555/ds-1", "https://data.uni.edu").Run(ctx); err == nil {
+		t.Error("DOI check resolving to another site passed")
+	}
+	if err := PresignCheck(http.DefaultClient, site.URL+"/files/data.csv").Run(ctx); err != nil {
+		t.Errorf("presign check error = %v", err)
+	}
+	if err := PresignCheck(http.DefaultClient, site.URL+"/files/gone.csv").Run(ctx); err == nil {
+		t.Error("presign check of a missing object passed")
+	}
+	if err := PageCheck(http.DefaultClient, site.URL+"/datasets/smoke-1/", "smoke-1").Run(ctx); err != nil {
+		t.Errorf("page check error = %v", err)
+	}
+	if err := PageCheck(http.DefaultClient, site.URL+"/datasets/smoke-1/", "smoke-2").Run(ctx); err == nil {
+		t.Error("page check of a stale page passed")
+	}
+	if err := PageCheck(http.DefaultClient, site.URL+"/datasets/smoke-2/", "smoke-2").Run(ctx); err == nil {
+		t.Error("page check of a missing page passed")
+	}
+
+	results, err := RunChecks(ctx, []Check{
+		{Name: "upload", Run: func(context.Context) error { return errors.New("access denied") }},
+		{Name: "cleanup", Run: func(context.Context) error { return nil }},
+	})
+	if err == nil || !strings.Contains(err.Error(), "check upload failed") || len(results) != 2 || results[1].Error != "" {
+		t.Errorf("RunChecks() = %+v, %v", results, err)
+	}
+}
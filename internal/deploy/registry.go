@@ -0,0 +1,90 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/ssm"
+)
+
+// Registry publishes the outputs of applied deployments to Parameter
+// Store, where the CLI, the API functions, and jobs look up the
+// endpoints, user pool, and buckets of an environment instead of each
+// being configured with them. Outputs are published under
+// /<project>/<environment>/outputs/<name>. Only outputs whose values
+// are strings are published; summaries and other structured outputs
+// stay in the deployment record.
+type Registry struct {
+	SSM *ssm.Client
+
+	// Project is the project name heading the parameter path
+	Project string
+}
+
+// Path returns the parameter path of the outputs of environment.
+func (r *Registry) Path(environment string) string {
+	return "/" + r.Project + "/" + environment + "/outputs/"
+}
+
+// Publish replaces the outputs of environment with outputs.
+func (r *Registry) Publish(ctx context.Context, environment string, outputs map[string]json.RawMessage) error {
+	path := r.Path(environment)
+	stale, err := r.SSM.GetParametersByPath(ctx, path)
+	if err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(outputs)) {
+		var value string
+		if json.Unmarshal(outputs[name], &value) != nil || value == "" {
+			continue
+		}
+		if stale[path+name] != value {
+			if err := r.SSM.PutParameter(ctx, path+name, value); err != nil {
+				return err
+			}
+		}
+		delete(stale, path+name)
+	}
+	return r.SSM.DeleteParameters(ctx, slices.Sorted(maps.Keys(stale))...)
+}
+
+// Outputs returns the published outputs of environment by name; none
+// if it was not deployed.
+func (r *Registry) Outputs(ctx context.Context, environment string) (map[string]string, error) {
+	path := r.Path(environment)
+	params, err := r.SSM.GetParametersByPath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make(map[string]string, len(params))
+	for name, value := range params {
+		outputs[strings.TrimPrefix(name, path)] = value
+	}
+	return outputs, nil
+}
+
+// Remove deletes the published outputs of environment.
+func (r *Registry) Remove(ctx context.Context, environment string) error {
+	params, err := r.SSM.GetParametersByPath(ctx, r.Path(environment))
+	if err != nil {
+		return err
+	}
+	return r.SSM.DeleteParameters(ctx, slices.Sorted(maps.Keys(params))...)
+}
@@ -0,0 +1,110 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/ssm"
+)
+
+// fakeParameters is a Parameter Store holding params, recording the
+// operations that change them.
+type fakeParameters struct {
+	params map[string]string
+	writes []string
+}
+
+func (f *fakeParameters) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Name, Value, Path string
+		Names             []string
+	}
+	json.NewDecoder(r.Body).Decode(&in)
+	op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "AmazonSSM.")
+	var out any = map[string]any{}
+	switch op {
+	case "PutParameter":
+		f.params[in.Name] = in.Value
+		f.writes = append(f.writes, "put "+in.Name)
+	case "DeleteParameters":
+		for _, name := range in.Names {
+			delete(f.params, name)
+			f.writes = append(f.writes, "delete "+name)
+		}
+	case "GetParametersByPath":
+		var params []map[string]string
+		for name, value := range f.params {
+			if strings.HasPrefix(name, in.Path) {
+				params = append(params, map[string]string{"Name": name, "Value": value})
+			}
+		}
+		out = map[string]any{"Parameters": params}
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeParameters{params: map[string]string{
+		"/aperture/prod/outputs/api_gateway_invoke_url": "https://api.example.edu/v1",
+		"/aperture/prod/outputs/dropped":                "x",
+		"/aperture/dev/outputs/api_gateway_invoke_url":  "https://dev.example.edu/v1",
+	}}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	r := &Registry{
+		SSM:     ssm.NewClient(ssm.Options{Region: "us-east-1", Endpoint: srv.URL, Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}}),
+		Project: "aperture",
+	}
+
+	outputs := map[string]json.RawMessage{
+		"api_gateway_invoke_url": json.RawMessage(`"https://api.example.edu/v1"`),
+		"cognito_user_pool_id":   json.RawMessage(`"us-east-1_abc"`),
+		"lambda_summary":         json.RawMessage(`{"auth": "aperture-prod-auth"}`),
+	}
+	if err := r.Publish(ctx, "prod", outputs); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	// Unchanged values are not written again, and structured outputs
+	// are left out.
+	want := []string{"put /aperture/prod/outputs/cognito_user_pool_id", "delete /aperture/prod/outputs/dropped"}
+	if !reflect.DeepEqual(f.writes, want) {
+		t.Errorf("writes = %v, want %v", f.writes, want)
+	}
+
+	got, err := r.Outputs(ctx, "prod")
+	wantOutputs := map[string]string{"api_gateway_invoke_url": "https://api.example.edu/v1", "cognito_user_pool_id": "us-east-1_abc"}
+	if err != nil || !reflect.DeepEqual(got, wantOutputs) {
+		t.Errorf("Outputs() = %v, %v, want %v", got, err, wantOutputs)
+	}
+
+	if err := r.Remove(ctx, "prod"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if got, err := r.Outputs(ctx, "prod"); err != nil || len(got) != 0 {
+		t.Errorf("Outputs() of a removed environment = %v, %v", got, err)
+	}
+	if _, ok := f.params["/aperture/dev/outputs/api_gateway_invoke_url"]; !ok {
+		t.Error("Remove() deleted the outputs of another environment")
+	}
+}
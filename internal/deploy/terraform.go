@@ -0,0 +1,374 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+)
+
+// VarsFile is the variables file written into the working directory;
+// Terraform loads it without being told to.
+const VarsFile = "aperture.auto.tfvars.json"
+
+// PlanFile is the saved plan that Apply applies.
+const PlanFile = "aperture.tfplan"
+
+// driftPlanFile is the saved refresh-only plan Drift reads back.
+const driftPlanFile = "drift.tfplan"
+
+// resourcesPlanFile is the plan Resources reads back when Plan saved
+// none.
+const resourcesPlanFile = "resources.tfplan"
+
+// Runner runs terraform in a working directory, with env added to its
+// environment and its standard output written to stdout. *Exec
+// implements it.
+type Runner interface {
+	Run(ctx context.Context, dir string, env []string, stdout io.Writer, args ...string) error
+}
+
+// Exec runs the terraform binary.
+type Exec struct {
+	// Path is the binary; terraform on the PATH if empty
+	Path string
+
+	// Stderr receives terraform's diagnostics; os.Stderr if nil
+	Stderr io.Writer
+}
+
+// Run implements Runner.
+func (t *Exec) Run(ctx context.Context, dir string, env []string, stdout io.Writer, args ...string) error {
+	cmd := exec.CommandContext(ctx, cmp.Or(t.Path, "terraform"), args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = stdout
+	cmd.Stderr = t.Stderr
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("terraform %s failed: %w", args[0], err)
+	}
+	return nil
+}
+
+// Terraform deploys the Terraform stack. Plan extracts the stack into a
+// working directory, writes the input variables, and runs terraform
+// init and plan; Apply applies the saved plan.
+type Terraform struct {
+	// Stack holds the root module and the modules it uses
+	Stack fs.FS
+
+	// Dir is the working directory the stack is extracted to; it keeps
+	// Terraform's providers and plans between deployments
+	Dir string
+
+	// Runner runs terraform
+	Runner Runner
+
+	// StateBackend configures the S3 state backend: its bucket, key,
+	// and region
+	StateBackend map[string]string
+
+	// Build builds the API's Lambda functions into Dir for the stack to
+	// package; they are not built if nil
+	Build Builder
+}
+
+// Name implements Backend.
+func (t *Terraform) Name() string { return "terraform" }
+
+// Source implements Backend.
+func (t *Terraform) Source() fs.FS { return t.Stack }
+
+// WithSource implements Backend.
+func (t *Terraform) WithSource(stack fs.FS) Backend {
+	c := *t
+	c.Stack = stack
+	return &c
+}
+
+// env returns the TF_VAR_ environment variables of v's secrets.
+func (v Vars) env() []string {
+	env := []string{"TF_IN_AUTOMATION=1"}
+	for _, name := range slices.Sorted(maps.Keys(v.Secrets)) {
+		if v.Secrets[name] != "" {
+			env = append(env, "TF_VAR_"+name+"="+v.Secrets[name])
+		}
+	}
+	return env
+}
+
+// Plan implements Backend, saving the plan for Apply unless opts.DryRun
+// is set.
+func (t *Terraform) Plan(ctx context.Context, opts Options, out io.Writer) error {
+	if err := t.init(ctx, opts, out); err != nil {
+		return err
+	}
+	args := []string{"plan", "-input=false"}
+	if !opts.DryRun {
+		args = append(args, "-out="+PlanFile)
+	}
+	varFiles, err := opts.varFileArgs()
+	if err != nil {
+		return err
+	}
+	return t.Runner.Run(ctx, t.Dir, opts.Vars.env(), out, append(args, varFiles...)...)
+}
+
+// Resources implements Backend by reading back the plan saved by Plan.
+// A dry run saves none, so the changes are planned again.
+func (t *Terraform) Resources(ctx context.Context, opts Options, out io.Writer) ([]Resource, error) {
+	env := opts.Vars.env()
+	plan := PlanFile
+	if opts.DryRun {
+		plan = resourcesPlanFile
+		defer os.Remove(filepath.Join(t.Dir, plan))
+		varFiles, err := opts.varFileArgs()
+		if err != nil {
+			return nil, err
+		}
+		args := append([]string{"plan", "-input=false", "-out=" + plan}, varFiles...)
+		if err := t.Runner.Run(ctx, t.Dir, env, out, args...); err != nil {
+			return nil, err
+		}
+	}
+	var raw bytes.Buffer
+	if err := t.Runner.Run(ctx, t.Dir, env, &raw, "show", "-json", plan); err != nil {
+		return nil, err
+	}
+	var shown struct {
+		PlannedValues struct {
+			RootModule plannedModule `json:"root_module"`
+		} `json:"planned_values"`
+	}
+	if err := json.Unmarshal(raw.Bytes(), &shown); err != nil {
+		return nil, fmt.Errorf("failed to decode the plan: %w", err)
+	}
+	return shown.PlannedValues.RootModule.resources(), nil
+}
+
+// plannedModule is a module in the planned values of a plan.
+type plannedModule struct {
+	Resources []struct {
+		Address string         `json:"address"`
+		Mode    string         `json:"mode"`
+		Type    string         `json:"type"`
+		Values  map[string]any `json:"values"`
+	} `json:"resources"`
+	ChildModules []plannedModule `json:"child_modules"`
+}
+
+// resources returns the managed resources of m and its child modules.
+func (m plannedModule) resources() []Resource {
+	var resources []Resource
+	for _, r := range m.Resources {
+		if r.Mode == "managed" {
+			resources = append(resources, Resource{Address: r.Address, Type: r.Type, Values: r.Values})
+		}
+	}
+	for _, child := range m.ChildModules {
+		resources = append(resources, child.resources()...)
+	}
+	return resources
+}
+
+// init extracts the stack into Dir with opts' variables, builds the
+// functions it packages, and initializes it.
+func (t *Terraform) init(ctx context.Context, opts Options, out io.Writer) error {
+	if err := t.extract(); err != nil {
+		return err
+	}
+	if err := t.build(ctx); err != nil {
+		return err
+	}
+	values, err := json.MarshalIndent(opts.Vars.Values, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(t.Dir, VarsFile), values, 0o600); err != nil {
+		return fmt.Errorf("failed to write Terraform variables: %w", err)
+	}
+
+	args := []string{"init", "-input=false", "-reconfigure"}
+	for _, k := range slices.Sorted(maps.Keys(t.StateBackend)) {
+		args = append(args, "-backend-config="+k+"="+t.StateBackend[k])
+	}
+	return t.Runner.Run(ctx, t.Dir, opts.Vars.env(), out, args...)
+}
+
+// varFileArgs returns the -var-file arguments of opts' variable files.
+func (opts Options) varFileArgs() ([]string, error) {
+	var args []string
+	for _, f := range opts.VarFiles {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-var-file="+abs)
+	}
+	return args, nil
+}
+
+// Apply implements Backend, applying the plan saved by Plan. The saved
+// plan, which holds the secrets it was planned with, is removed.
+func (t *Terraform) Apply(ctx context.Context, opts Options, out io.Writer) (map[string]json.RawMessage, error) {
+	defer os.Remove(filepath.Join(t.Dir, PlanFile))
+	env := opts.Vars.env()
+	if err := t.Runner.Run(ctx, t.Dir, env, out, "apply", "-input=false", PlanFile); err != nil {
+		return nil, err
+	}
+	var raw bytes.Buffer
+	if err := t.Runner.Run(ctx, t.Dir, env, &raw, "output", "-json"); err != nil {
+		return nil, err
+	}
+	var outputs map[string]struct {
+		Sensitive bool            `json:"sensitive"`
+		Value     json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(raw.Bytes(), &outputs); err != nil {
+		return nil, fmt.Errorf("failed to decode Terraform outputs: %w", err)
+	}
+	values := make(map[string]json.RawMessage)
+	for name, o := range outputs {
+		if !o.Sensitive {
+			values[name] = o.Value
+		}
+	}
+	return values, nil
+}
+
+// Drift implements Backend by planning a refresh-only run of the
+// deployed stack, which compares the recorded Terraform state with the
+// infrastructure as it is. Nothing is changed.
+func (t *Terraform) Drift(ctx context.Context, opts Options, out io.Writer) ([]Drifted, error) {
+	if err := t.init(ctx, opts, out); err != nil {
+		return nil, err
+	}
+	defer os.Remove(filepath.Join(t.Dir, driftPlanFile))
+	env := opts.Vars.env()
+	varFiles, err := opts.varFileArgs()
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"plan", "-refresh-only", "-input=false", "-out=" + driftPlanFile}, varFiles...)
+	if err := t.Runner.Run(ctx, t.Dir, env, out, args...); err != nil {
+		return nil, err
+	}
+	var raw bytes.Buffer
+	if err := t.Runner.Run(ctx, t.Dir, env, &raw, "show", "-json", driftPlanFile); err != nil {
+		return nil, err
+	}
+	var plan struct {
+		ResourceDrift []struct {
+			Address string `json:"address"`
+			Type    string `json:"type"`
+			Change  struct {
+				Actions []string       `json:"actions"`
+				Before  map[string]any `json:"before"`
+				After   map[string]any `json:"after"`
+			} `json:"change"`
+		} `json:"resource_drift"`
+	}
+	if err := json.Unmarshal(raw.Bytes(), &plan); err != nil {
+		return nil, fmt.Errorf("failed to decode the refresh-only plan: %w", err)
+	}
+
+	var drifted []Drifted
+	for _, rd := range plan.ResourceDrift {
+		c := rd.Change
+		action := "update"
+		if slices.Contains(c.Actions, "delete") {
+			action = "delete"
+		}
+		var changed []string
+		if action == "update" {
+			keys := make(map[string]any)
+			maps.Copy(keys, c.Before)
+			maps.Copy(keys, c.After)
+			for _, k := range slices.Sorted(maps.Keys(keys)) {
+				if !reflect.DeepEqual(c.Before[k], c.After[k]) {
+					changed = append(changed, k)
+				}
+			}
+		}
+		drifted = append(drifted, Drifted{
+			Address:    rd.Address,
+			Type:       rd.Type,
+			Action:     action,
+			Attributes: changed,
+			Severity:   Severity(rd.Type, action, changed),
+		})
+	}
+	return drifted, nil
+}
+
+// Destroy implements Backend with terraform destroy. Buckets that still
+// hold objects are deleted only if opts.ForceDeleteData is set: the
+// buckets' force_destroy setting is kept in the state, so it is applied
+// to them first.
+func (t *Terraform) Destroy(ctx context.Context, opts Options, out io.Writer) error {
+	if err := t.init(ctx, opts, out); err != nil {
+		return err
+	}
+	env := opts.Vars.env()
+	varFiles, err := opts.varFileArgs()
+	if err != nil {
+		return err
+	}
+	args := []string{"destroy", "-input=false", "-auto-approve"}
+	if opts.ForceDeleteData {
+		force := append([]string{"apply", "-input=false", "-auto-approve", "-target=module.s3_buckets", "-var=force_destroy=true"}, varFiles...)
+		if err := t.Runner.Run(ctx, t.Dir, env, out, force...); err != nil {
+			return err
+		}
+		args = append(args, "-var=force_destroy=true")
+	}
+	return t.Runner.Run(ctx, t.Dir, env, out, append(args, varFiles...)...)
+}
+
+// extract writes the stack's files into Dir, replacing the modules of
+// an earlier stack.
+func (t *Terraform) extract() error {
+	if err := os.RemoveAll(filepath.Join(t.Dir, "infrastructure")); err != nil {
+		return err
+	}
+	return fs.WalkDir(t.Stack, ".", func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(t.Dir, filepath.FromSlash(path))
+		if e.IsDir() {
+			return os.MkdirAll(dst, 0o755)
+		}
+		data, err := fs.ReadFile(t.Stack, path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(dst, data, 0o644)
+	})
+}
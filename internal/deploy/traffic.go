@@ -0,0 +1,226 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/cloudwatch"
+	"github.com/scttfrdmn/aperture/internal/lambda"
+)
+
+// FunctionsOutput is the stack output listing the API functions whose
+// aliases are shifted to the versions a deployment publishes.
+const FunctionsOutput = "api_functions"
+
+// ErrTrafficRolledBack is returned when an alarm fired while traffic
+// was being shifted to new versions, and was moved back.
+var ErrTrafficRolledBack = errors.New("traffic rolled back")
+
+// Function is an API function as FunctionsOutput lists it.
+type Function struct {
+	// Name is the function's name
+	Name string `json:"function_name"`
+
+	// Version is the version the deployment published
+	Version string `json:"version"`
+
+	// Alias is the alias API Gateway invokes
+	Alias string `json:"alias"`
+
+	// Alarm is the CloudWatch alarm on the alias's errors
+	Alarm string `json:"alarm"`
+}
+
+// TrafficShift moves the aliases of API functions to new versions
+// gradually: the new versions take a growing share of the traffic,
+// step by step, while the functions' alarms are watched. If an alarm
+// fires, every alias is moved back to the version it had.
+type TrafficShift struct {
+	// Lambda updates the aliases
+	Lambda *lambda.Client
+
+	// CloudWatch reads the alarms
+	CloudWatch *cloudwatch.Client
+
+	// Steps are the percentages of traffic the new versions take in
+	// turn before they take all of it; all of it at once if empty
+	Steps []int
+
+	// StepDuration is how long each step is watched
+	StepDuration time.Duration
+
+	// PollInterval is how often alarms are checked during a step; 30
+	// seconds if zero
+	PollInterval time.Duration
+}
+
+// shifting is a function whose alias moves from old to the new version.
+type shifting struct {
+	Function
+	old string
+}
+
+// Shift moves the aliases of fns to their versions. Aliases already on
+// their version are left alone, and nothing is shifted while an alarm
+// of the others is firing. An alias a shift was interrupted on still
+// routes most traffic to its old version, and is shifted again.
+func (t *TrafficShift) Shift(ctx context.Context, fns []Function, out io.Writer) error {
+	var moving []shifting
+	var alarms []string
+	for _, fn := range fns {
+		a, err := t.Lambda.GetAlias(ctx, fn.Name, fn.Alias)
+		if err != nil {
+			return err
+		}
+		if a.Version != fn.Version {
+			moving = append(moving, shifting{Function: fn, old: a.Version})
+			alarms = append(alarms, fn.Alarm)
+		}
+	}
+	if len(moving) == 0 {
+		return nil
+	}
+	if firing, err := t.firing(ctx, alarms); err != nil || firing != nil {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("alarm %s is firing (%s); traffic stays on the current versions", firing.Name, firing.Reason)
+	}
+
+	for _, pct := range t.Steps {
+		var names []string
+		for _, fn := range moving {
+			w := map[string]float64{fn.Version: float64(pct) / 100}
+			if err := t.Lambda.UpdateAlias(ctx, fn.Name, fn.Alias, lambda.Alias{Version: fn.old, Weights: w}); err != nil {
+				return t.rollback(ctx, moving, err)
+			}
+			names = append(names, fn.Name+" v"+fn.Version)
+		}
+		fmt.Fprintf(out, "Shifted %d%% of traffic to %s\n", pct, strings.Join(names, ", "))
+		if err := t.watch(ctx, alarms); err != nil {
+			return t.rollback(ctx, moving, fmt.Errorf("%w at %d%%", err, pct))
+		}
+	}
+	for _, fn := range moving {
+		if err := t.Lambda.UpdateAlias(ctx, fn.Name, fn.Alias, lambda.Alias{Version: fn.Version}); err != nil {
+			return t.rollback(ctx, moving, err)
+		}
+	}
+	fmt.Fprintf(out, "Shifted all traffic to the new versions of %d functions\n", len(moving))
+	return nil
+}
+
+// watch checks alarms until StepDuration has passed, returning an
+// error once one fires.
+func (t *TrafficShift) watch(ctx context.Context, alarms []string) error {
+	deadline := time.Now().Add(t.StepDuration)
+	for {
+		firing, err := t.firing(ctx, alarms)
+		if err != nil {
+			return err
+		}
+		if firing != nil {
+			return fmt.Errorf("alarm %s fired (%s)", firing.Name, firing.Reason)
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(min(left, cmp.Or(t.PollInterval, 30*time.Second)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// firing returns the first of alarms in the ALARM state, or nil.
+func (t *TrafficShift) firing(ctx context.Context, alarms []string) (*cloudwatch.Alarm, error) {
+	states, err := t.CloudWatch.DescribeAlarms(ctx, alarms...)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range states {
+		if a.State == cloudwatch.StateAlarm {
+			return &a, nil
+		}
+	}
+	return nil, nil
+}
+
+// rollback moves the aliases of moving back to their old versions
+// after cause stopped the shift.
+func (t *TrafficShift) rollback(ctx context.Context, moving []shifting, cause error) error {
+	// The rollback runs even if the shift was cancelled.
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	for _, fn := range moving {
+		if err := t.Lambda.UpdateAlias(ctx, fn.Name, fn.Alias, lambda.Alias{Version: fn.old}); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", fn.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v, and moving traffic back failed: %w", cause, errors.Join(errs...))
+	}
+	return fmt.Errorf("%w: %v", ErrTrafficRolledBack, cause)
+}
+
+// shiftTraffic shifts the API functions listed by dep's outputs to the
+// versions it published, if d shifts traffic.
+func (d *Deployer) shiftTraffic(ctx context.Context, dep *Deployment) error {
+	raw, ok := dep.Outputs[FunctionsOutput]
+	if d.Traffic == nil || !ok {
+		return nil
+	}
+	var byName map[string]Function
+	if err := json.Unmarshal(raw, &byName); err != nil {
+		return fmt.Errorf("failed to decode the %s output: %w", FunctionsOutput, err)
+	}
+	var fns []Function
+	for _, name := range slices.Sorted(maps.Keys(byName)) {
+		fns = append(fns, byName[name])
+	}
+	err := d.Traffic.Shift(ctx, fns, d.out())
+	if d.Log == nil {
+		return err
+	}
+	details := map[string]string{"functions": strconv.Itoa(len(fns)), "result": "shifted"}
+	switch {
+	case errors.Is(err, ErrTrafficRolledBack):
+		details["result"] = "rolledBack"
+		details["reason"] = err.Error()
+	case err != nil:
+		details["result"] = "failed"
+		details["reason"] = err.Error()
+	}
+	if lerr := audit.Record(ctx, d.Log, "deploy.traffic", dep.Environment, details); err == nil {
+		err = lerr
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/cloudwatch"
+	"github.com/scttfrdmn/aperture/internal/lambda"
+)
+
+// fakeAliases serves the Lambda aliases and CloudWatch alarms of a
+// traffic shift.
+type fakeAliases struct {
+	// versions maps a function to the version its alias routes to
+	versions map[string]string
+
+	// updates are the aliases set, as version and weights
+	updates []string
+
+	// alarm is the state of every alarm; alarmAt makes it ALARM once
+	// that many updates were made
+	alarm   string
+	alarmAt int
+}
+
+func (f *fakeAliases) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		r.ParseForm()
+		state := f.alarm
+		if f.alarmAt > 0 && len(f.updates) >= f.alarmAt {
+			state = "ALARM"
+		}
+		fmt.Fprint(w, `<DescribeAlarmsResponse><DescribeAlarmsResult><MetricAlarms>`)
+		for i := 1; r.PostForm.Get(fmt.Sprintf("AlarmNames.member.%d", i)) != ""; i++ {
+			fmt.Fprintf(w, `<member><AlarmName>%s</AlarmName><StateValue>%s</StateValue><StateReason>errors</StateReason></member>`,
+				r.PostForm.Get(fmt.Sprintf("AlarmNames.member.%d", i)), state)
+		}
+		fmt.Fprint(w, `</MetricAlarms></DescribeAlarmsResult></DescribeAlarmsResponse>`)
+		return
+	}
+	fn := strings.Split(r.URL.Path, "/")[3]
+	if r.Method == http.MethodPut {
+		var in struct {
+			FunctionVersion string
+			RoutingConfig   struct{ AdditionalVersionWeights map[string]float64 }
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		f.versions[fn] = in.FunctionVersion
+		f.updates = append(f.updates, fmt.Sprintf("%s %s %v", fn, in.FunctionVersion, in.RoutingConfig.AdditionalVersionWeights))
+	}
+	fmt.Fprintf(w, `{"Name": "live", "FunctionVersion": %q}`, f.versions[fn])
+}
+
+func newTestTrafficShift(t *testing.T, f *fakeAliases) *TrafficShift {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	creds := aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	return &TrafficShift{
+		Lambda:       lambda.NewClient(lambda.Options{Region: "us-west-2", Endpoint: srv.URL, Credentials: creds}),
+		CloudWatch:   cloudwatch.NewClient(cloudwatch.Options{Region: "us-west-2", Endpoint: srv.URL, Credentials: creds}),
+		Steps:        []int{10, 50},
+		StepDuration: 5 * time.Millisecond,
+		PollInterval: time.Millisecond,
+	}
+}
+
+var testFunctions = []Function{
+	{Name: "aperture-prod-auth", Version: "4", Alias: "live", Alarm: "aperture-prod-auth-live-errors"},
+	{Name: "aperture-prod-presigned-urls", Version: "7", Alias: "live", Alarm: "aperture-prod-presigned-urls-live-errors"},
+}
+
+func TestTrafficShift(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeAliases{versions: map[string]string{"aperture-prod-auth": "3", "aperture-prod-presigned-urls": "7"}, alarm: "OK"}
+	ts := newTestTrafficShift(t, f)
+	if err := ts.Shift(ctx, testFunctions, io.Discard); err != nil {
+		t.Fatalf("Shift() error = %v", err)
+	}
+	// The presigned URLs function is on its version already.
+	want := []string{
+		"aperture-prod-auth 3 map[4:0.1]",
+		"aperture-prod-auth 3 map[4:0.5]",
+		"aperture-prod-auth 4 map[]",
+	}
+	if !reflect.DeepEqual(f.updates, want) {
+		t.Errorf("updates = %v, want %v", f.updates, want)
+	}
+
+	// An alarm firing part way moves traffic back.
+	f = &fakeAliases{versions: map[string]string{"aperture-prod-auth": "3", "aperture-prod-presigned-urls": "6"}, alarm: "OK", alarmAt: 3}
+	ts = newTestTrafficShift(t, f)
+	err := ts.Shift(ctx, testFunctions, io.Discard)
+	if !errors.Is(err, ErrTrafficRolledBack) || !strings.Contains(err.Error(), "at 50%") {
+		t.Fatalf("Shift() error = %v, want ErrTrafficRolledBack", err)
+	}
+	if f.versions["aperture-prod-auth"] != "3" || f.versions["aperture-prod-presigned-urls"] != "6" ||
+		!strings.HasSuffix(f.updates[len(f.updates)-1], "6 map[]") {
+		t.Errorf("after rollback versions = %v, updates = %v", f.versions, f.updates)
+	}
+
+	// Nothing is shifted while an alarm is firing.
+	f = &fakeAliases{versions: map[string]string{"aperture-prod-auth": "3"}, alarm: "ALARM"}
+	ts = newTestTrafficShift(t, f)
+	if err := ts.Shift(ctx, testFunctions[:1], io.Discard); err == nil || len(f.updates) != 0 {
+		t.Errorf("Shift() with a firing alarm = %v, updates = %v", err, f.updates)
+	}
+}
+
+func TestDeployerShiftsTraffic(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeAliases{versions: map[string]string{"aperture-prod-auth": "3", "aperture-prod-presigned-urls": "7"}, alarm: "OK"}
+	log := &audit.MemoryLog{}
+	d := &Deployer{Traffic: newTestTrafficShift(t, f), Log: log}
+	d.Traffic.Steps = nil
+	fns, _ := json.Marshal(map[string]Function{"auth": testFunctions[0], "presigned_urls": testFunctions[1]})
+	dep := &Deployment{Environment: "prod", Outputs: map[string]json.RawMessage{FunctionsOutput: fns}}
+	if err := d.shiftTraffic(ctx, dep); err != nil {
+		t.Fatalf("shiftTraffic() error = %v", err)
+	}
+	if !reflect.DeepEqual(f.updates, []string{"aperture-prod-auth 4 map[]"}) {
+		t.Errorf("updates = %v", f.updates)
+	}
+	entries, _ := log.Entries(ctx)
+	if len(entries) != 1 || entries[0].Action != "deploy.traffic" || entries[0].Details["result"] != "shifted" {
+		t.Errorf("audit = %+v", entries)
+	}
+
+	// Stacks without API functions, like the CloudFormation template's,
+	// shift nothing.
+	if err := d.shiftTraffic(ctx, &Deployment{Environment: "prod"}); err != nil {
+		t.Errorf("shiftTraffic() without functions error = %v", err)
+	}
+}
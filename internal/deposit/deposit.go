@@ -0,0 +1,747 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package deposit publishes datasets, including datasets deposited on
+// behalf of the principal investigators responsible for them.
+//
+// A PI delegates deposit to a lab manager with a delegation record. The
+// lab manager may then name the PI as the owner of datasets they
+// manage. Publishing a dataset whose owner is someone else waits for
+// the owner's confirmation, given from the CLI or through a link
+// emailed to them, and the dataset's history records both the
+// depositor and the owner who confirmed.
+package deposit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/notify"
+	"github.com/scttfrdmn/aperture/internal/seal"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/trace"
+)
+
+// Tables used in the state store.
+const (
+	delegationsTable   = "delegations"
+	confirmationsTable = "publish-confirmations"
+)
+
+// ConfirmationExpiry is how long an owner has to confirm publication.
+const ConfirmationExpiry = 14 * 24 * time.Hour
+
+var (
+	// ErrNotDelegated is returned when naming an owner who has not
+	// delegated deposit to the acting principal.
+	ErrNotDelegated = errors.New("no delegation from owner")
+
+	// ErrNoConfirmation is returned when confirming a dataset that is
+	// not awaiting confirmation, or with an unknown or expired link.
+	ErrNoConfirmation = errors.New("no pending publication confirmation")
+
+	// ErrPublished is returned when publishing a published dataset
+	// with no draft of a new version.
+	ErrPublished = errors.New("dataset is already published")
+)
+
+// PagePublisher renders a dataset's landing page.
+type PagePublisher interface {
+	Publish(ctx context.Context, datasetID string) (*landing.Change, error)
+}
+
+// PIDAssigner mints persistent identifiers. *pid.Registrar implements
+// it.
+type PIDAssigner interface {
+	Assign(ctx context.Context, d *dataset.Dataset) (string, error)
+}
+
+// VersionRegistrar mints DOIs for the versions of datasets with a
+// concept DOI and links them to each other. *pid.Registrar implements
+// it.
+type VersionRegistrar interface {
+	AssignVersion(ctx context.Context, d *dataset.Dataset, v *dataset.Version) (string, error)
+	Update(ctx context.Context, d *dataset.Dataset) error
+}
+
+// MediaRegistrar registers direct links to published datasets' files
+// with their DOIs. *pid.Registrar implements it.
+type MediaRegistrar interface {
+	RegisterMedia(ctx context.Context, d *dataset.Dataset) ([]datacite.Media, error)
+}
+
+// RAiDSyncer adds published datasets to the RAiD records they are
+// linked to. *raid.Manager implements it.
+type RAiDSyncer interface {
+	Sync(ctx context.Context, d *dataset.Dataset) ([]string, error)
+}
+
+// ScanGate holds publication until a dataset's files pass malware
+// scanning. *scan.Scanner implements it.
+type ScanGate interface {
+	Cleared(ctx context.Context, d *dataset.Dataset) error
+}
+
+// Sealer signs the manifest of a version as it is published.
+// *seal.Sealer implements it.
+type Sealer interface {
+	Seal(ctx context.Context, d *dataset.Dataset, v *dataset.Version) (*seal.Seal, error)
+}
+
+// Delegation allows Depositor to deposit datasets owned by PI.
+type Delegation struct {
+	PI        string    `json:"pi"`
+	Depositor string    `json:"depositor"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Confirmation is a publication awaiting its owner's confirmation.
+type Confirmation struct {
+	DatasetID   string    `json:"datasetId"`
+	TokenSHA256 string    `json:"tokenSha256"`
+	Owner       string    `json:"owner"`
+	Depositor   string    `json:"depositor"`
+	RequestedAt time.Time `json:"requestedAt"`
+	Expires     time.Time `json:"expires"`
+}
+
+// Manager records delegations and publishes datasets.
+type Manager struct {
+	// State holds delegations and pending confirmations
+	State state.Store
+
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Pages renders landing pages on publication; skipped if nil
+	Pages PagePublisher
+
+	// PIDs mints an identifier for datasets published without one;
+	// skipped if nil
+	PIDs PIDAssigner
+
+	// Versions mints a DOI for each version published of a dataset
+	// with a concept DOI; skipped if nil
+	Versions VersionRegistrar
+
+	// Media registers published datasets' files with their DOIs;
+	// skipped if nil
+	Media MediaRegistrar
+
+	// RAiDs adds published datasets to their linked RAiD records;
+	// skipped if nil
+	RAiDs RAiDSyncer
+
+	// Scans holds publication until the files of the version being
+	// published pass malware scanning; skipped if nil
+	Scans ScanGate
+
+	// Seals signs the manifest of each version as it is published, and
+	// publication fails if it cannot; skipped if nil
+	Seals Sealer
+
+	// Notifier asks owners for confirmation; skipped if nil
+	Notifier notify.Notifier
+
+	// ConfirmURL is the base URL of the endpoint serving confirmation
+	// links; emails give only the CLI command if empty
+	ConfirmURL string
+
+	// Log records delegations and publications; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Delegate allows depositor to deposit datasets owned by the acting
+// principal.
+func (m *Manager) Delegate(ctx context.Context, depositor, note string) (*Delegation, error) {
+	pi, err := person(ctx)
+	if err != nil {
+		return nil, err
+	}
+	depositor = identity.Normalize(depositor)
+	if depositor == "" || depositor == pi {
+		return nil, fmt.Errorf("invalid depositor %q", depositor)
+	}
+	dl := &Delegation{PI: pi, Depositor: depositor, Note: strings.TrimSpace(note), CreatedAt: m.now().UTC()}
+	if err := m.State.Put(ctx, delegationsTable, delegationKey(pi, depositor), dl); err != nil {
+		return nil, err
+	}
+	return dl, m.record(ctx, "delegation.grant", depositor, map[string]string{"pi": pi})
+}
+
+// Undelegate withdraws the acting principal's delegation to depositor.
+// Datasets already naming the principal as owner keep doing so, but
+// can no longer be published without the owner.
+func (m *Manager) Undelegate(ctx context.Context, depositor string) error {
+	pi, err := person(ctx)
+	if err != nil {
+		return err
+	}
+	depositor = identity.Normalize(depositor)
+	if _, err := m.delegation(ctx, pi, depositor); err != nil {
+		return err
+	}
+	if err := m.State.Delete(ctx, delegationsTable, delegationKey(pi, depositor)); err != nil {
+		return err
+	}
+	return m.record(ctx, "delegation.revoke", depositor, map[string]string{"pi": pi})
+}
+
+// Delegations returns the delegations the acting principal granted or
+// received, or every delegation for administrators.
+func (m *Manager) Delegations(ctx context.Context) ([]Delegation, error) {
+	all, err := state.List[Delegation](ctx, m.State, delegationsTable)
+	if err != nil {
+		return nil, err
+	}
+	p := identity.FromContext(ctx)
+	id := identity.Normalize(p.ID)
+	admin := slices.Contains(p.Groups, authz.AdminGroup)
+	out := all[:0]
+	for _, dl := range all {
+		if admin || dl.PI == id || dl.Depositor == id {
+			out = append(out, dl)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].PI != out[j].PI {
+			return out[i].PI < out[j].PI
+		}
+		return out[i].Depositor < out[j].Depositor
+	})
+	return out, nil
+}
+
+// SetOwner names pi as the owner of a dataset the acting principal
+// manages and records the principal as its depositor. Unless pi is the
+// principal, pi must have delegated deposit to them. The owner is
+// added to the dataset's manage list.
+func (m *Manager) SetOwner(ctx context.Context, ref, pi string) (*dataset.Dataset, error) {
+	depositor, err := person(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d, err := m.managed(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if d.State != dataset.StateDraft && d.State != "" {
+		return nil, fmt.Errorf("%s is %s; owners are set before publication", d.ID, d.State)
+	}
+	pi = identity.Normalize(pi)
+	if pi != depositor {
+		if _, err := m.delegation(ctx, pi, depositor); err != nil {
+			return nil, err
+		}
+	}
+
+	d.Owner, d.Depositor = pi, depositor
+	if d.ACL == nil {
+		d.ACL = &authz.ACL{}
+	}
+	if entry := authz.KindUser + ":" + pi; !slices.Contains(d.ACL.Manage, entry) {
+		d.ACL.Manage = append(d.ACL.Manage, entry)
+	}
+	details := map[string]string{"owner": pi, "depositor": depositor}
+	m.note(ctx, d, "deposit.owner", details)
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, m.record(ctx, "deposit.owner", d.ID, details)
+}
+
+// Publish publishes a dataset the acting principal manages. If the
+// dataset is owned by someone else, it is not published; instead the
+// owner is asked to confirm and the pending confirmation is returned.
+func (m *Manager) Publish(ctx context.Context, ref string) (*dataset.Dataset, *Confirmation, error) {
+	actor, err := person(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := m.managed(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := m.publishable(ctx, d); err != nil {
+		return nil, nil, err
+	}
+	if d.Owner == "" || d.Owner == actor {
+		details := map[string]string{}
+		if d.Owner != "" {
+			details["owner"] = d.Owner
+			details["depositor"] = d.Depositor
+		}
+		return d, nil, m.publish(ctx, d, details)
+	}
+	if _, err := m.delegation(ctx, d.Owner, actor); err != nil {
+		return nil, nil, err
+	}
+	c, err := m.request(ctx, d, actor)
+	return d, c, err
+}
+
+// NewVersion starts a new version of a published dataset the acting
+// principal manages, as a draft holding the files of the latest
+// version. The draft is changed and then published with Publish; until
+// then the dataset and its concept DOI resolve to the latest published
+// version.
+func (m *Manager) NewVersion(ctx context.Context, ref string) (*dataset.Dataset, *dataset.Version, error) {
+	d, err := m.managed(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.State != dataset.StatePublished {
+		return nil, nil, fmt.Errorf("%s is %s; only published datasets are versioned", d.ID, d.State)
+	}
+	v, err := d.NewVersion()
+	if err != nil {
+		return nil, nil, err
+	}
+	details := map[string]string{"version": fmt.Sprint(v.Number)}
+	m.note(ctx, d, "dataset.version", details)
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return nil, nil, err
+	}
+	if err := m.record(ctx, "dataset.version", d.ID, details); err != nil {
+		return nil, nil, err
+	}
+	return d, d.Draft(), nil
+}
+
+// Delete removes a draft dataset the acting principal manages, with
+// any publication awaiting confirmation. Published datasets are never
+// deleted, so that their identifiers keep resolving; they are
+// tombstoned through retention review instead. Uploaded files are left
+// for storage garbage collection.
+func (m *Manager) Delete(ctx context.Context, ref string) (*dataset.Dataset, error) {
+	d, err := m.managed(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if d.State != dataset.StateDraft || slices.ContainsFunc(d.Versions, func(v dataset.Version) bool { return v.PublishedAt != nil }) {
+		return nil, fmt.Errorf("%w: %s has been published; tombstone it instead", ErrPublished, d.ID)
+	}
+	if err := m.State.Delete(ctx, confirmationsTable, d.ID); err != nil {
+		return nil, err
+	}
+	if err := m.Datasets.Delete(ctx, d.ID); err != nil {
+		return nil, err
+	}
+	details := map[string]string{"title": d.Title, "versions": strconv.Itoa(len(d.Versions))}
+	if d.Owner != "" {
+		details["owner"] = d.Owner
+	}
+	if err := m.record(ctx, "dataset.delete", d.ID, details); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Pending returns the confirmation the dataset is waiting for.
+func (m *Manager) Pending(ctx context.Context, datasetID string) (*Confirmation, error) {
+	var c Confirmation
+	err := m.State.Get(ctx, confirmationsTable, datasetID, &c)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("%w for %s", ErrNoConfirmation, datasetID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !m.now().Before(c.Expires) {
+		return nil, fmt.Errorf("%w for %s (the request expired on %s)", ErrNoConfirmation, datasetID, c.Expires.Format(time.DateOnly))
+	}
+	return &c, nil
+}
+
+// Confirm publishes a dataset awaiting the acting principal's
+// confirmation as its owner.
+func (m *Manager) Confirm(ctx context.Context, ref string) (*dataset.Dataset, error) {
+	owner, err := person(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d, err := m.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	c, err := m.Pending(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	if c.Owner != owner {
+		return nil, fmt.Errorf("%w: only %s may confirm publication of %s", authz.ErrForbidden, c.Owner, d.ID)
+	}
+	return d, m.confirm(ctx, d, c, "cli")
+}
+
+// ConfirmToken publishes the dataset awaiting confirmation with the
+// emailed token. Holding the token stands in for signing in as the
+// owner, to whom the publication is attributed.
+func (m *Manager) ConfirmToken(ctx context.Context, token string) (*dataset.Dataset, error) {
+	c, err := m.byToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	d, err := m.Datasets.Get(ctx, c.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = identity.WithPrincipal(ctx, identity.Principal{ID: c.Owner})
+	return d, m.confirm(ctx, d, c, "email")
+}
+
+// Lookup returns the pending confirmation for token and its dataset.
+func (m *Manager) Lookup(ctx context.Context, token string) (*Confirmation, *dataset.Dataset, error) {
+	c, err := m.byToken(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := m.Datasets.Get(ctx, c.DatasetID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, d, nil
+}
+
+func (m *Manager) byToken(ctx context.Context, token string) (*Confirmation, error) {
+	hash := hashToken(strings.TrimSpace(token))
+	all, err := state.List[Confirmation](ctx, m.State, confirmationsTable)
+	if err != nil {
+		return nil, err
+	}
+	for i := range all {
+		if all[i].TokenSHA256 == hash && m.now().Before(all[i].Expires) {
+			return &all[i], nil
+		}
+	}
+	return nil, ErrNoConfirmation
+}
+
+// confirm publishes d on c's owner's confirmation via the given channel.
+func (m *Manager) confirm(ctx context.Context, d *dataset.Dataset, c *Confirmation, via string) error {
+	if err := m.publishable(ctx, d); err != nil {
+		return err
+	}
+	if d.Owner != c.Owner {
+		return fmt.Errorf("%w: the owner of %s changed to %s", ErrNoConfirmation, d.ID, d.Owner)
+	}
+	details := map[string]string{"owner": c.Owner, "depositor": c.Depositor, "confirmedVia": via}
+	if err := m.publish(ctx, d, details); err != nil {
+		return err
+	}
+	return m.State.Delete(ctx, confirmationsTable, d.ID)
+}
+
+// request records a confirmation for d's owner, replacing any earlier
+// one, and emails the owner.
+func (m *Manager) request(ctx context.Context, d *dataset.Dataset, depositor string) (*Confirmation, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	now := m.now().UTC()
+	c := &Confirmation{
+		DatasetID:   d.ID,
+		TokenSHA256: hashToken(token),
+		Owner:       d.Owner,
+		Depositor:   depositor,
+		RequestedAt: now,
+		Expires:     now.Add(ConfirmationExpiry),
+	}
+	if err := m.State.Put(ctx, confirmationsTable, d.ID, c); err != nil {
+		return nil, err
+	}
+
+	if m.Notifier != nil {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s has deposited the dataset %q (%s) on your behalf and asks you to confirm its publication.\n\n",
+			depositor, d.Title, d.ID)
+		if m.ConfirmURL != "" {
+			fmt.Fprintf(&b, "Review and confirm: %s/deposit/confirm/%s\n\n", strings.TrimSuffix(m.ConfirmURL, "/"), token)
+		}
+		fmt.Fprintf(&b, "Or run: aperture dataset confirm %s\n\n", d.ID)
+		fmt.Fprintf(&b, "The request expires on %s. Nothing is published until you confirm.\n", c.Expires.Format(time.DateOnly))
+		err := m.Notifier.Notify(ctx, notify.Message{
+			To:      d.Owner,
+			Subject: "Confirm publication of " + d.ID,
+			Body:    b.String(),
+			Time:    now,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to notify %s: %w", d.Owner, err)
+		}
+	}
+	return c, m.record(ctx, "deposit.request", d.ID, map[string]string{"owner": d.Owner, "depositor": depositor})
+}
+
+// publish marks d and its latest version published, applying the
+// metadata changes staged in d's workspace and recording d's metadata
+// on the version, renders its landing page, and records the
+// publication with details. A dataset already
+// published gains the version as its current one.
+func (m *Manager) publish(ctx context.Context, d *dataset.Dataset, details map[string]string) (err error) {
+	ctx, span := trace.Start(ctx, "deposit.publish", trace.String("dataset.id", d.ID))
+	defer func() { span.End(err) }()
+	now := m.now().UTC()
+	d.State = dataset.StatePublished
+	var published *dataset.Version
+	if v := d.Latest(); v != nil && v.PublishedAt == nil {
+		v.PublishedAt = &now
+		details["version"] = fmt.Sprint(v.Number)
+		published = v
+		if d.ApplyWorkspace() {
+			details["metadata"] = "staged"
+		}
+	}
+	if d.PublicationYear == 0 {
+		d.PublicationYear = now.Year()
+	}
+	if published != nil {
+		published.Metadata = d.Metadata()
+	}
+	if m.PIDs != nil && d.DOI == "" && d.ARK == "" && d.Handle == "" {
+		id, err := m.PIDs.Assign(ctx, d)
+		if err != nil {
+			return err
+		}
+		details["pid"] = id
+	}
+	versioned := false
+	if m.Versions != nil && published != nil && d.DOI != "" {
+		doi, err := m.Versions.AssignVersion(ctx, d, published)
+		if err != nil {
+			return err
+		}
+		details["versionDoi"] = doi
+		versioned = true
+	}
+	if m.Seals != nil && published != nil {
+		sl, err := m.Seals.Seal(ctx, d, published)
+		if err != nil {
+			return err
+		}
+		details["seal"] = sl.Digest
+	}
+	m.note(ctx, d, "dataset.publish", details)
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return err
+	}
+	if m.Pages != nil {
+		if _, err := m.Pages.Publish(ctx, d.ID); err != nil {
+			return err
+		}
+	}
+	if err := m.record(ctx, "dataset.publish", d.ID, details); err != nil {
+		return err
+	}
+	var linked error
+	if versioned {
+		linked = m.linkVersions(ctx, d)
+	}
+	return errors.Join(linked, m.registerMedia(ctx, d), m.syncRAiDs(ctx, d))
+}
+
+// linkVersions updates d's concept DOI and earlier version DOIs to
+// point at the version DOI just minted. Failures leave d published and
+// are reported so the update can be retried.
+func (m *Manager) linkVersions(ctx context.Context, d *dataset.Dataset) error {
+	if err := m.Versions.Update(ctx, d); err != nil {
+		return fmt.Errorf("%s is published, but %w; retry with 'aperture pid update %s'", d.ID, err, d.ID)
+	}
+	return nil
+}
+
+// registerMedia registers d's files with its DOI. Failures leave d
+// published and are reported so the registration can be retried.
+func (m *Manager) registerMedia(ctx context.Context, d *dataset.Dataset) error {
+	if m.Media == nil {
+		return nil
+	}
+	if _, err := m.Media.RegisterMedia(ctx, d); err != nil {
+		return fmt.Errorf("%s is published, but %w; retry with 'aperture pid media %s'", d.ID, err, d.ID)
+	}
+	return nil
+}
+
+// syncRAiDs adds d to its linked RAiD records. Failures leave d
+// published and are reported so the update can be retried.
+func (m *Manager) syncRAiDs(ctx context.Context, d *dataset.Dataset) error {
+	if m.RAiDs == nil {
+		return nil
+	}
+	if _, err := m.RAiDs.Sync(ctx, d); err != nil {
+		return fmt.Errorf("%s is published, but %w; retry with 'aperture raid push %s'", d.ID, err, d.ID)
+	}
+	return nil
+}
+
+// AssignPID mints an identifier for a dataset published without one,
+// such as one published before identifiers were configured.
+func (m *Manager) AssignPID(ctx context.Context, ref string) (*dataset.Dataset, string, error) {
+	if m.PIDs == nil {
+		return nil, "", fmt.Errorf("no identifier scheme is configured")
+	}
+	d, err := m.managed(ctx, ref)
+	if err != nil {
+		return nil, "", err
+	}
+	if d.State != dataset.StatePublished {
+		return nil, "", fmt.Errorf("%s is %s; identifiers are minted on publication", d.ID, d.State)
+	}
+	id, err := m.PIDs.Assign(ctx, d)
+	if err != nil {
+		return nil, "", err
+	}
+	details := map[string]string{"pid": id}
+	m.note(ctx, d, "pid.mint", details)
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return nil, "", err
+	}
+	if m.Pages != nil {
+		if _, err := m.Pages.Publish(ctx, d.ID); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := m.record(ctx, "pid.mint", d.ID, details); err != nil {
+		return nil, "", err
+	}
+	return d, id, errors.Join(m.registerMedia(ctx, d), m.syncRAiDs(ctx, d))
+}
+
+// UpdatePID re-registers the metadata and target URL of a dataset's
+// identifier and of its versions' DOIs, such as after its description
+// is edited.
+func (m *Manager) UpdatePID(ctx context.Context, ref string) (*dataset.Dataset, error) {
+	if m.Versions == nil {
+		return nil, fmt.Errorf("no identifier scheme is configured")
+	}
+	d, err := m.managed(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Versions.Update(ctx, d); err != nil {
+		return nil, err
+	}
+	if err := m.record(ctx, "pid.update", d.ID, nil); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// managed resolves ref and checks that the acting principal may manage
+// the dataset.
+func (m *Manager) managed(ctx context.Context, ref string) (*dataset.Dataset, error) {
+	d, err := m.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (m *Manager) delegation(ctx context.Context, pi, depositor string) (*Delegation, error) {
+	var dl Delegation
+	err := m.State.Get(ctx, delegationsTable, delegationKey(pi, depositor), &dl)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s has not delegated deposit to %s", ErrNotDelegated, pi, depositor)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dl, nil
+}
+
+// publishable returns an error if d cannot be published.
+func (m *Manager) publishable(ctx context.Context, d *dataset.Dataset) error {
+	switch d.State {
+	case dataset.StatePublished:
+		if d.Draft() == nil {
+			return fmt.Errorf("%w: %s; start a new version with 'aperture dataset version %s'", ErrPublished, d.ID, d.ID)
+		}
+	case dataset.StateTombstoned:
+		return fmt.Errorf("%s has been tombstoned", d.ID)
+	}
+	if m.Scans != nil {
+		return m.Scans.Cleared(ctx, d)
+	}
+	return nil
+}
+
+// person returns the normalized ID of the acting principal, which must
+// be a signed-in person rather than a machine token.
+func person(ctx context.Context) (string, error) {
+	p := identity.FromContext(ctx)
+	if p.IsZero() || p.IsMachine() {
+		return "", fmt.Errorf("%w: deposits on behalf of others must be made by a signed-in person", authz.ErrForbidden)
+	}
+	return identity.Normalize(p.ID), nil
+}
+
+// note appends an event to d's history; the caller saves d.
+func (m *Manager) note(ctx context.Context, d *dataset.Dataset, action string, details map[string]string) {
+	d.History = append(d.History, dataset.Event{
+		Time:    m.now().UTC(),
+		Actor:   identity.FromContext(ctx).String(),
+		Action:  action,
+		Details: maps.Clone(details),
+	})
+}
+
+func (m *Manager) record(ctx context.Context, action, target string, details map[string]string) error {
+	if m.Log == nil {
+		return nil
+	}
+	return audit.Record(ctx, m.Log, action, target, details)
+}
+
+func (m *Manager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
+
+func delegationKey(pi, depositor string) string {
+	return pi + " " + depositor
+}
+
+// hashToken returns the hex-encoded SHA-256 of token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,528 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deposit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/notify"
+	"github.com/scttfrdmn/aperture/internal/seal"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+type fakeNotifier struct{ sent []notify.Message }
+
+func (n *fakeNotifier) Notify(_ context.Context, m notify.Message) error {
+	n.sent = append(n.sent, m)
+	return nil
+}
+
+// fakePIDs mints sequential ARKs, failing when fail is set.
+type fakePIDs struct {
+	minted int
+	fail   bool
+}
+
+func (f *fakePIDs) Assign(_ context.Context, d *dataset.Dataset) (string, error) {
+	if f.fail {
+		return "", errors.New("minting unavailable")
+	}
+	f.minted++
+	d.ARK = fmt.Sprintf("ark:/99999/fk4%d", f.minted)
+	return d.ARK, nil
+}
+
+// fakeVersions mints version DOIs under the concept DOI, failing to
+// update them when fail is set.
+type fakeVersions struct {
+	updated int
+	fail    bool
+}
+
+func (f *fakeVersions) AssignVersion(_ context.Context, d *dataset.Dataset, v *dataset.Version) (string, error) {
+	v.DOI = fmt.Sprintf("%s.v%d", d.DOI, v.Number)
+	return v.DOI, nil
+}
+
+func (f *fakeVersions) Update(context.Context, *dataset.Dataset) error {
+	if f.fail {
+		return errors.New("DataCite unavailable")
+	}
+	f.updated++
+	return nil
+}
+
+// fakeMedia fails to register media when fail is set.
+type fakeMedia struct {
+	registered []string
+	fail       bool
+}
+
+func (f *fakeMedia) RegisterMedia(_ context.Context, d *dataset.Dataset) ([]datacite.Media, error) {
+	if f.fail {
+		return nil, errors.New("media API unavailable")
+	}
+	f.registered = append(f.registered, d.ID)
+	return nil, nil
+}
+
+// fakeScans holds publication of the datasets in blocked.
+type fakeScans struct{ blocked map[string]bool }
+
+func (f *fakeScans) Cleared(_ context.Context, d *dataset.Dataset) error {
+	if f.blocked[d.ID] {
+		return fmt.Errorf("%s: malware scan pending", d.ID)
+	}
+	return nil
+}
+
+// fakeSeals records the versions sealed, failing when fail is set.
+type fakeSeals struct {
+	sealed []string
+	fail   bool
+}
+
+func (f *fakeSeals) Seal(_ context.Context, d *dataset.Dataset, v *dataset.Version) (*seal.Seal, error) {
+	if f.fail {
+		return nil, errors.New("signing unavailable")
+	}
+	f.sealed = append(f.sealed, fmt.Sprintf("%s v%d", d.ID, v.Number))
+	return &seal.Seal{Digest: "abc"}, nil
+}
+
+func as(id string) context.Context {
+	return identity.WithPrincipal(context.Background(), identity.Principal{ID: id})
+}
+
+func newManager(t *testing.T) (*Manager, *fakeNotifier) {
+	t.Helper()
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	for _, id := range []string{"ds-1", "ds-2"} {
+		d := &dataset.Dataset{
+			ID:       id,
+			Title:    "Soil cores " + id,
+			State:    dataset.StateDraft,
+			ACL:      &authz.ACL{Manage: []string{"user:lab@uni.edu"}},
+			Versions: []dataset.Version{{Number: 1}},
+		}
+		if err := datasets.Put(context.Background(), d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := &fakeNotifier{}
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	return &Manager{
+		State:      s,
+		Datasets:   datasets,
+		Notifier:   n,
+		ConfirmURL: "https://api.uni.edu/",
+		Log:        &audit.MemoryLog{},
+		Now:        func() time.Time { return now },
+	}, n
+}
+
+func TestDelegatedPublish(t *testing.T) {
+	m, n := newManager(t)
+	lab, pi := as("lab@uni.edu"), as("pi@uni.edu")
+
+	if _, err := m.SetOwner(lab, "ds-1", "pi@uni.edu"); !errors.Is(err, ErrNotDelegated) {
+		t.Errorf("SetOwner() without delegation error = %v, want ErrNotDelegated", err)
+	}
+	if _, err := m.Delegate(pi, "Lab@uni.edu", "Smith lab"); err != nil {
+		t.Fatalf("Delegate() error = %v", err)
+	}
+	if got, _ := m.Delegations(lab); len(got) != 1 || got[0].PI != "pi@uni.edu" {
+		t.Errorf("Delegations() = %+v", got)
+	}
+	d, err := m.SetOwner(lab, "ds-1", "pi@uni.edu")
+	if err != nil {
+		t.Fatalf("SetOwner() error = %v", err)
+	}
+	if d.Owner != "pi@uni.edu" || d.Depositor != "lab@uni.edu" || len(d.ACL.Manage) != 2 {
+		t.Errorf("SetOwner() = owner %s, depositor %s, manage %v", d.Owner, d.Depositor, d.ACL.Manage)
+	}
+
+	d, c, err := m.Publish(lab, "ds-1")
+	if err != nil || c == nil {
+		t.Fatalf("Publish() by depositor = %v, %v; want pending confirmation", c, err)
+	}
+	if d.State != dataset.StateDraft {
+		t.Errorf("Publish() by depositor state = %s, want draft until confirmed", d.State)
+	}
+	if len(n.sent) != 1 || n.sent[0].To != "pi@uni.edu" || !strings.Contains(n.sent[0].Body, "aperture dataset confirm ds-1") {
+		t.Fatalf("notifications = %+v", n.sent)
+	}
+
+	if _, err := m.Confirm(lab, "ds-1"); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Confirm() by depositor error = %v, want ErrForbidden", err)
+	}
+	d, err = m.Confirm(pi, "ds-1")
+	if err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	if d.State != dataset.StatePublished || d.Latest().PublishedAt == nil {
+		t.Errorf("Confirm() state = %s, published at %v", d.State, d.Latest().PublishedAt)
+	}
+	e := d.History[len(d.History)-1]
+	if e.Action != "dataset.publish" || e.Actor != "pi@uni.edu" || e.Details["depositor"] != "lab@uni.edu" || e.Details["confirmedVia"] != "cli" {
+		t.Errorf("publication event = %+v", e)
+	}
+	if _, err := m.Confirm(pi, "ds-1"); !errors.Is(err, ErrNoConfirmation) {
+		t.Errorf("Confirm() again error = %v, want ErrNoConfirmation", err)
+	}
+	if _, _, err := m.Publish(lab, "ds-1"); !errors.Is(err, ErrPublished) {
+		t.Errorf("Publish() of published dataset error = %v, want ErrPublished", err)
+	}
+
+	if err := m.Undelegate(pi, "lab@uni.edu"); err != nil {
+		t.Fatalf("Undelegate() error = %v", err)
+	}
+	if _, err := m.SetOwner(lab, "ds-2", "pi@uni.edu"); !errors.Is(err, ErrNotDelegated) {
+		t.Errorf("SetOwner() after Undelegate() error = %v, want ErrNotDelegated", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	m, _ := newManager(t)
+	lab, pi := as("lab@uni.edu"), as("pi@uni.edu")
+
+	if _, err := m.Delete(pi, "ds-1"); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Delete() by non-manager error = %v, want ErrForbidden", err)
+	}
+	d, err := m.Delete(lab, "ds-1")
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := m.Datasets.Get(lab, d.ID); err == nil {
+		t.Error("Delete() left the dataset in the catalog")
+	}
+	entries, _ := m.Log.Entries(lab)
+	if e := entries[len(entries)-1]; e.Action != "dataset.delete" || e.Target != "ds-1" || e.Actor != "lab@uni.edu" {
+		t.Errorf("audit entry = %+v", e)
+	}
+
+	if _, _, err := m.Publish(lab, "ds-2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Delete(lab, "ds-2"); !errors.Is(err, ErrPublished) {
+		t.Errorf("Delete() of published dataset error = %v, want ErrPublished", err)
+	}
+}
+
+func TestPublishMintsPID(t *testing.T) {
+	m, _ := newManager(t)
+	lab := as("lab@uni.edu")
+
+	if _, _, err := m.AssignPID(lab, "ds-1"); err == nil {
+		t.Error("AssignPID() without a scheme succeeded, want error")
+	}
+	pids := &fakePIDs{fail: true}
+	m.PIDs = pids
+	if _, _, err := m.Publish(lab, "ds-1"); err == nil {
+		t.Fatal("Publish() with failing minter succeeded, want error")
+	}
+	if d, _ := m.Datasets.Get(lab, "ds-1"); d.State != dataset.StateDraft {
+		t.Errorf("Publish() with failing minter left state %s, want draft", d.State)
+	}
+
+	pids.fail = false
+	d, _, err := m.Publish(lab, "ds-1")
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if d.ARK != "ark:/99999/fk41" || d.History[len(d.History)-1].Details["pid"] != d.ARK {
+		t.Errorf("Publish() ARK = %q, history %+v", d.ARK, d.History)
+	}
+	if got, err := m.Datasets.Resolve(lab, d.ARK); err != nil || got.ID != "ds-1" {
+		t.Errorf("Resolve(%q) = %v, %v", d.ARK, got, err)
+	}
+	if _, _, err := m.AssignPID(lab, "ds-2"); err == nil {
+		t.Error("AssignPID() of a draft succeeded, want error")
+	}
+
+	// A dataset published before identifiers were configured.
+	m.PIDs = nil
+	if _, _, err := m.Publish(lab, "ds-2"); err != nil {
+		t.Fatal(err)
+	}
+	m.PIDs = pids
+	d, id, err := m.AssignPID(lab, "ds-2")
+	if err != nil {
+		t.Fatalf("AssignPID() error = %v", err)
+	}
+	if id != "ark:/99999/fk42" || d.ARK != id {
+		t.Errorf("AssignPID() = %q, ARK %q", id, d.ARK)
+	}
+	entries, _ := m.Log.Entries(lab)
+	if e := entries[len(entries)-1]; e.Action != "pid.mint" || e.Target != "ds-2" {
+		t.Errorf("audit entry = %+v", e)
+	}
+}
+
+func TestUpdatePID(t *testing.T) {
+	m, _ := newManager(t)
+	lab := as("lab@uni.edu")
+	if _, err := m.UpdatePID(lab, "ds-1"); err == nil {
+		t.Error("UpdatePID() without a scheme succeeded, want error")
+	}
+	versions := &fakeVersions{}
+	m.Versions = versions
+	if _, err := m.UpdatePID(as("stranger@uni.edu"), "ds-1"); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("UpdatePID() by a stranger error = %v, want ErrForbidden", err)
+	}
+	d, err := m.UpdatePID(lab, "ds-1")
+	if err != nil || d.ID != "ds-1" || versions.updated != 1 {
+		t.Fatalf("UpdatePID() = %v, %v after %d updates", d, err, versions.updated)
+	}
+	entries, _ := m.Log.Entries(lab)
+	if e := entries[len(entries)-1]; e.Action != "pid.update" || e.Target != "ds-1" {
+		t.Errorf("audit entry = %+v", e)
+	}
+}
+
+func TestPublishNewVersion(t *testing.T) {
+	m, _ := newManager(t)
+	lab := as("lab@uni.edu")
+	versions := &fakeVersions{}
+	m.Versions = versions
+	d, _ := m.Datasets.Get(lab, "ds-1")
+	d.DOI = "10.1234/ds-1"
+	d.Versions[0].Files = []dataset.File{{Path: "cores.csv", Size: 10, SHA256: "aa"}}
+	if err := m.Datasets.Put(lab, d); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := m.NewVersion(lab, "ds-1"); err == nil {
+		t.Error("NewVersion() of a draft dataset succeeded, want error")
+	}
+	d, _, err := m.Publish(lab, "ds-1")
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if d.Versions[0].DOI != "10.1234/ds-1.v1" || versions.updated != 1 {
+		t.Errorf("Publish() version DOI = %q, updated %d times", d.Versions[0].DOI, versions.updated)
+	}
+	if md := d.Versions[0].Metadata; md == nil || md.Title != "Soil cores ds-1" || md.PublicationYear != 2025 {
+		t.Errorf("Publish() recorded metadata %+v", md)
+	}
+	if _, _, err := m.Publish(lab, "ds-1"); !errors.Is(err, ErrPublished) || !strings.Contains(err.Error(), "aperture dataset version ds-1") {
+		t.Errorf("Publish() without a draft error = %v, want ErrPublished", err)
+	}
+
+	if _, _, err := m.NewVersion(as("pi@uni.edu"), "ds-1"); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("NewVersion() by non-manager error = %v, want ErrForbidden", err)
+	}
+	d, v, err := m.NewVersion(lab, "ds-1")
+	if err != nil {
+		t.Fatalf("NewVersion() error = %v", err)
+	}
+	if v.Number != 2 || v.PublishedAt != nil || len(v.Files) != 1 || d.Current().Number != 1 {
+		t.Errorf("NewVersion() = %+v, current version %d", v, d.Current().Number)
+	}
+	if _, _, err := m.NewVersion(lab, "ds-1"); err == nil {
+		t.Error("NewVersion() with a draft succeeded, want error")
+	}
+
+	// Published versions cannot be changed; the draft can.
+	d.Versions[0].Files[0].Size = 11
+	if err := m.Datasets.Put(lab, d); !errors.Is(err, dataset.ErrImmutable) {
+		t.Errorf("Put() changing version 1 error = %v, want ErrImmutable", err)
+	}
+	d, _ = m.Datasets.Get(lab, "ds-1")
+	d.Draft().Files = append(d.Draft().Files, dataset.File{Path: "cores-2026.csv", Size: 12, SHA256: "bb"})
+	if err := m.Datasets.Put(lab, d); err != nil {
+		t.Fatalf("Put() changing the draft error = %v", err)
+	}
+
+	// Failing to link the version DOIs leaves the version published.
+	versions.fail = true
+	_, _, err = m.Publish(lab, "ds-1")
+	if err == nil || !strings.Contains(err.Error(), "aperture pid update ds-1") {
+		t.Errorf("Publish() with failing DOI update = %v", err)
+	}
+	d, _ = m.Datasets.Get(lab, "ds-1")
+	if v := d.Current(); v.Number != 2 || v.DOI != "10.1234/ds-1.v2" || len(v.Files) != 2 || d.Draft() != nil {
+		t.Errorf("current version after publishing = %+v", v)
+	}
+	if e := d.History[len(d.History)-1]; e.Action != "dataset.publish" || e.Details["version"] != "2" || e.Details["versionDoi"] != "10.1234/ds-1.v2" {
+		t.Errorf("publication event = %+v", e)
+	}
+}
+
+func TestPublishStagedMetadata(t *testing.T) {
+	m, _ := newManager(t)
+	lab := as("lab@uni.edu")
+	d, _, err := m.Publish(lab, "ds-1")
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if err := d.Edit(m.now(), func(d *dataset.Dataset) { d.Title = "Soil cores, corrected" }); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Datasets.Put(lab, d); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := m.Datasets.Get(lab, "ds-1"); d.Title != "Soil cores ds-1" || d.Draft() == nil {
+		t.Fatalf("staged edit changed the published dataset: %q, draft %v", d.Title, d.Draft())
+	}
+
+	d, _, err = m.Publish(lab, "ds-1")
+	if err != nil {
+		t.Fatalf("Publish() of staged changes error = %v", err)
+	}
+	v := d.Current()
+	if d.Title != "Soil cores, corrected" || d.Workspace != nil || v.Number != 2 || v.Metadata.Title != d.Title {
+		t.Errorf("Publish() = %q, workspace %+v, version %+v", d.Title, d.Workspace, v)
+	}
+	if e := d.History[len(d.History)-1]; e.Details["metadata"] != "staged" {
+		t.Errorf("publication event = %+v", e)
+	}
+	if d.Version(1).Metadata.Title != "Soil cores ds-1" {
+		t.Errorf("version 1 metadata = %+v", d.Version(1).Metadata)
+	}
+}
+
+func TestPublishRegistersMedia(t *testing.T) {
+	m, _ := newManager(t)
+	lab := as("lab@uni.edu")
+	media := &fakeMedia{}
+	m.Media = media
+	if _, _, err := m.Publish(lab, "ds-1"); err != nil || len(media.registered) != 1 {
+		t.Fatalf("Publish() = %v, registered %v", err, media.registered)
+	}
+
+	// Failing registration leaves the dataset published.
+	media.fail = true
+	_, _, err := m.Publish(lab, "ds-2")
+	if err == nil || !strings.Contains(err.Error(), "aperture pid media ds-2") {
+		t.Errorf("Publish() with failing media registration = %v", err)
+	}
+	if d, _ := m.Datasets.Get(lab, "ds-2"); d.State != dataset.StatePublished {
+		t.Errorf("Publish() with failing media registration left state %s, want published", d.State)
+	}
+}
+
+func TestPublishWaitsForScans(t *testing.T) {
+	m, _ := newManager(t)
+	lab := as("lab@uni.edu")
+	scans := &fakeScans{blocked: map[string]bool{"ds-1": true}}
+	m.Scans = scans
+	if _, _, err := m.Publish(lab, "ds-1"); err == nil || !strings.Contains(err.Error(), "malware scan pending") {
+		t.Fatalf("Publish() before scanning = %v, want the scan error", err)
+	}
+	if d, _ := m.Datasets.Get(lab, "ds-1"); d.State != dataset.StateDraft {
+		t.Errorf("Publish() before scanning left state %s, want draft", d.State)
+	}
+
+	// Confirmation by the owner is held too.
+	if _, err := m.Delegate(as("pi@uni.edu"), "lab@uni.edu", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetOwner(lab, "ds-2", "pi@uni.edu"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Publish(lab, "ds-2"); err != nil {
+		t.Fatalf("Publish(ds-2) error = %v", err)
+	}
+	scans.blocked["ds-2"] = true
+	if _, err := m.Confirm(as("pi@uni.edu"), "ds-2"); err == nil {
+		t.Error("Confirm() before scanning succeeded")
+	}
+
+	delete(scans.blocked, "ds-1")
+	if _, _, err := m.Publish(lab, "ds-1"); err != nil {
+		t.Errorf("Publish() after scanning = %v", err)
+	}
+}
+
+func TestPublishSeals(t *testing.T) {
+	m, _ := newManager(t)
+	lab := as("lab@uni.edu")
+	seals := &fakeSeals{fail: true}
+	m.Seals = seals
+	if _, _, err := m.Publish(lab, "ds-1"); err == nil || !strings.Contains(err.Error(), "signing unavailable") {
+		t.Fatalf("Publish() with failing sealing = %v, want the signing error", err)
+	}
+	if d, _ := m.Datasets.Get(lab, "ds-1"); d.State != dataset.StateDraft {
+		t.Errorf("Publish() with failing sealing left state %s, want draft", d.State)
+	}
+
+	seals.fail = false
+	if _, _, err := m.Publish(lab, "ds-1"); err != nil || len(seals.sealed) != 1 || seals.sealed[0] != "ds-1 v1" {
+		t.Fatalf("Publish() = %v, sealed %v", err, seals.sealed)
+	}
+	entries, _ := m.Log.(*audit.MemoryLog).Entries(lab)
+	if e := entries[len(entries)-1]; e.Action != "dataset.publish" || e.Details["seal"] != "abc" {
+		t.Errorf("last audit event = %+v, want the publication with its seal", e)
+	}
+}
+
+func TestConfirmLink(t *testing.T) {
+	m, n := newManager(t)
+	lab, pi := as("lab@uni.edu"), as("pi@uni.edu")
+	if _, err := m.Delegate(pi, "lab@uni.edu", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetOwner(lab, "ds-2", "pi@uni.edu"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Publish(lab, "ds-2"); err != nil {
+		t.Fatal(err)
+	}
+	link := regexp.MustCompile(`https://api\.uni\.edu/(deposit/confirm/\S+)`).FindStringSubmatch(n.sent[0].Body)
+	if link == nil {
+		t.Fatalf("no confirmation link in %q", n.sent[0].Body)
+	}
+	h := NewHandler(m)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+link[1], nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "lab@uni.edu") || !strings.Contains(rec.Body.String(), `method="post"`) {
+		t.Errorf("GET = %d %s", rec.Code, rec.Body)
+	}
+	if d, _ := m.Datasets.Get(lab, "ds-2"); d.State != dataset.StateDraft {
+		t.Error("GET published the dataset")
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/"+link[1], nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST = %d %s", rec.Code, rec.Body)
+	}
+	d, _ := m.Datasets.Get(lab, "ds-2")
+	if e := d.History[len(d.History)-1]; d.State != dataset.StatePublished || e.Actor != "pi@uni.edu" || e.Details["confirmedVia"] != "email" {
+		t.Errorf("after POST state = %s, event = %+v", d.State, e)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/"+link[1], nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("second POST = %d, want 404", rec.Code)
+	}
+}
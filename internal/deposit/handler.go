@@ -0,0 +1,113 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deposit
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// page is the confirmation page. Confirmation takes a POST so that
+// mail scanners following the emailed link cannot publish by accident.
+var page = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>{{.Heading}}</title></head>
+<body>
+<h1>{{.Heading}}</h1>
+{{with .Dataset}}<p><strong>{{.Title}}</strong> ({{.ID}})</p>{{end}}
+{{with .Confirmation}}<p>Deposited on behalf of {{.Owner}} by {{.Depositor}}.</p>{{end}}
+<p>{{.Message}}</p>
+{{if .Form}}<form method="post"><button type="submit">Confirm publication</button></form>{{end}}
+</body>
+</html>
+`))
+
+// Handler serves GET and POST /deposit/confirm/{token}: the page an
+// owner reaches from the emailed link, and its confirmation.
+type Handler struct {
+	m   *Manager
+	mux *http.ServeMux
+}
+
+// NewHandler returns a handler confirming publications with m.
+func NewHandler(m *Manager) *Handler {
+	h := &Handler{m: m, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /deposit/confirm/{token}", h.show)
+	h.mux.HandleFunc("POST /deposit/confirm/{token}", h.confirm)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+type pageData struct {
+	Heading      string
+	Message      string
+	Dataset      *dataset.Dataset
+	Confirmation *Confirmation
+	Form         bool
+}
+
+func (h *Handler) show(w http.ResponseWriter, r *http.Request) {
+	c, d, err := h.m.Lookup(r.Context(), r.PathValue("token"))
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	render(w, http.StatusOK, pageData{
+		Heading:      "Confirm publication",
+		Message:      "Publishing makes the dataset's landing page public and cannot be undone without a tombstone.",
+		Dataset:      d,
+		Confirmation: c,
+		Form:         true,
+	})
+}
+
+func (h *Handler) confirm(w http.ResponseWriter, r *http.Request) {
+	d, err := h.m.ConfirmToken(audit.WithRequest(r), r.PathValue("token"))
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	render(w, http.StatusOK, pageData{
+		Heading: "Published",
+		Message: "Thank you. The dataset has been published.",
+		Dataset: d,
+	})
+}
+
+func (h *Handler) fail(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNoConfirmation):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrPublished):
+		status = http.StatusConflict
+	}
+	render(w, status, pageData{Heading: "Cannot confirm publication", Message: err.Error()})
+}
+
+func render(w http.ResponseWriter, status int, data pageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = page.Execute(w, data)
+}
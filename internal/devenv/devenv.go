@@ -0,0 +1,239 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package devenv provisions the platform's buckets, tables, and work
+// queues in LocalStack, so that the ingest and publish pipelines and
+// their background workers can be developed and tried out without an
+// AWS account.
+package devenv
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/dynamodb"
+	"github.com/scttfrdmn/aperture/internal/queue"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/sqs"
+)
+
+// DefaultEndpoint is where LocalStack listens by default.
+const DefaultEndpoint = "http://localhost:4566"
+
+// account is the AWS account ID of LocalStack's resources.
+const account = "000000000000"
+
+// Credentials are the credentials LocalStack accepts; any will do, but
+// these are the ones its documentation uses.
+var Credentials = aws.Credentials{AccessKeyID: "test", SecretAccessKey: "test"}
+
+// services are the LocalStack services the environment needs.
+var services = []string{"s3", "dynamodb", "sqs"}
+
+// bucketSuffixes name the stack's buckets, after the bucket prefix.
+var bucketSuffixes = []string{
+	"public-media", "private-media", "restricted-media", "embargoed-media",
+	"processing", "logs", "frontend", "quarantine", "audit-anchors",
+}
+
+// Buckets returns the names of the stack's buckets with prefix.
+func Buckets(prefix string) []string {
+	var names []string
+	for _, suffix := range bucketSuffixes {
+		names = append(names, prefix+"-"+suffix)
+	}
+	return names
+}
+
+// Tables returns the stack's tables in the environment of project,
+// keyed and indexed as the stack declares them.
+func Tables(project, environment string) []dynamodb.Table {
+	name := func(table string) string { return project + "-" + table + "-" + environment }
+	return []dynamodb.Table{
+		{
+			Name: name("users"), HashKey: "user_id", RangeKey: "created_at",
+			Attributes: map[string]string{"user_id": "S", "created_at": "S", "email": "S", "orcid": "S"},
+			Indexes:    []dynamodb.Index{{Name: "EmailIndex", HashKey: "email"}, {Name: "OrcidIndex", HashKey: "orcid"}},
+		},
+		{
+			Name: name("doi-registry"), HashKey: "doi", RangeKey: "version",
+			Attributes: map[string]string{"doi": "S", "version": "N", "dataset_id": "S", "status": "S", "minted_at": "S"},
+			Indexes: []dynamodb.Index{
+				{Name: "DatasetIndex", HashKey: "dataset_id", RangeKey: "minted_at"},
+				{Name: "StatusIndex", HashKey: "status", RangeKey: "minted_at"},
+			},
+		},
+		{
+			Name: name("access-logs"), HashKey: "dataset_id", RangeKey: "timestamp",
+			Attributes: map[string]string{"dataset_id": "S", "timestamp": "S", "user_id": "S", "date": "S"},
+			Indexes: []dynamodb.Index{
+				{Name: "UserAccessIndex", HashKey: "user_id", RangeKey: "timestamp"},
+				{Name: "DateIndex", HashKey: "date", RangeKey: "dataset_id"},
+			},
+		},
+		{
+			Name: name("budget"), HashKey: "resource_id", RangeKey: "month",
+			Attributes: map[string]string{"resource_id": "S", "month": "S", "account_id": "S", "cost_category": "S"},
+			Indexes: []dynamodb.Index{
+				{Name: "AccountCostIndex", HashKey: "account_id", RangeKey: "month"},
+				{Name: "CategoryIndex", HashKey: "cost_category", RangeKey: "month"},
+			},
+		},
+		{
+			Name: name("knowledge-base-embeddings"), HashKey: "embedding_id", RangeKey: "created_at",
+			Attributes: map[string]string{"embedding_id": "S", "created_at": "S", "dataset_id": "S", "content_type": "S"},
+			Indexes: []dynamodb.Index{
+				{Name: "DatasetIdIndex", HashKey: "dataset_id", RangeKey: "created_at"},
+				{Name: "ContentTypeIndex", HashKey: "content_type", RangeKey: "created_at"},
+			},
+		},
+		{
+			Name: name("download-quotas"), HashKey: "user_id", RangeKey: "day",
+			Attributes: map[string]string{"user_id": "S", "day": "S"},
+		},
+		dynamodb.StateTable(name("state")),
+	}
+}
+
+// Env is a development environment in LocalStack.
+type Env struct {
+	// Endpoint is LocalStack's URL
+	Endpoint string
+
+	Region      string
+	Project     string
+	Environment string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Health returns the status of each of LocalStack's services, e.g.
+// "available" or "running".
+func (e *Env) Health(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(e.Endpoint, "/")+"/_localstack/health", nil)
+	if err != nil {
+		return nil, err
+	}
+	client := e.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("LocalStack is not running at %s: %w", e.Endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("LocalStack health check at %s: HTTP %d", e.Endpoint, resp.StatusCode)
+	}
+	var health struct {
+		Services map[string]string `json:"services"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&health); err != nil {
+		return nil, fmt.Errorf("failed to decode LocalStack health: %w", err)
+	}
+	return health.Services, nil
+}
+
+// Up creates the stack's buckets, tables, and queues that do not exist
+// yet, reporting each on out. It is safe to run again.
+func (e *Env) Up(ctx context.Context, out io.Writer) error {
+	health, err := e.Health(ctx)
+	if err != nil {
+		return err
+	}
+	for _, service := range services {
+		if status := health[service]; status != "available" && status != "running" {
+			return fmt.Errorf("LocalStack service %s is %s; enable it with SERVICES=%s", service, cmp.Or(status, "disabled"), strings.Join(services, ","))
+		}
+	}
+
+	buckets, err := s3.NewClient(s3.Options{Region: e.Region, Endpoint: e.Endpoint, PathStyle: true, Credentials: Credentials, HTTPClient: e.HTTPClient})
+	if err != nil {
+		return err
+	}
+	for _, name := range Buckets(e.Project + "-" + e.Environment) {
+		if err := buckets.CreateBucket(ctx, name); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", name, err)
+		}
+		fmt.Fprintf(out, "  bucket %s\n", name)
+	}
+
+	tables := dynamodb.NewClient(dynamodb.Options{Region: e.Region, Endpoint: e.Endpoint, Credentials: Credentials, HTTPClient: e.HTTPClient})
+	for _, t := range Tables(e.Project, e.Environment) {
+		err := tables.CreateTable(ctx, t)
+		switch {
+		case errors.Is(err, dynamodb.ErrExists):
+		case err != nil:
+			return fmt.Errorf("failed to create table %s: %w", t.Name, err)
+		}
+		fmt.Fprintf(out, "  table  %s\n", t.Name)
+	}
+
+	queues := sqs.NewClient(sqs.Options{Region: e.Region, Endpoint: e.Endpoint, Credentials: Credentials, HTTPClient: e.HTTPClient})
+	for _, topic := range queue.Topics {
+		name := queue.QueueName(e.Project+"-"+e.Environment, topic)
+		if err := e.createQueue(ctx, queues, name); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "  queue  %s\n", name)
+	}
+	return nil
+}
+
+// createQueue creates the queue name with a dead-letter queue that its
+// redrive policy moves messages received queue.DefaultMaxReceives times
+// to, as the stack does.
+func (e *Env) createQueue(ctx context.Context, queues *sqs.Client, name string) error {
+	if _, err := queues.CreateQueue(ctx, name+"-dead", nil); err != nil {
+		return fmt.Errorf("failed to create queue %s-dead: %w", name, err)
+	}
+	redrive, _ := json.Marshal(map[string]any{
+		"deadLetterTargetArn": "arn:aws:sqs:" + e.Region + ":" + account + ":" + name + "-dead",
+		"maxReceiveCount":     queue.DefaultMaxReceives,
+	})
+	attributes := map[string]string{
+		"VisibilityTimeout": strconv.Itoa(int(queue.DefaultVisibility / time.Second)),
+		"RedrivePolicy":     string(redrive),
+	}
+	if _, err := queues.CreateQueue(ctx, name, attributes); err != nil {
+		return fmt.Errorf("failed to create queue %s: %w", name, err)
+	}
+	return nil
+}
+
+// Settings returns the environment variables that point the CLI and
+// its servers at e.
+func (e *Env) Settings() map[string]string {
+	return map[string]string{
+		"AWS_ENDPOINT_URL":       e.Endpoint,
+		"AWS_REGION":             e.Region,
+		"AWS_ACCESS_KEY_ID":      Credentials.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY":  Credentials.SecretAccessKey,
+		"APERTURE_ENV":           e.Environment,
+		"APERTURE_PROJECT_NAME":  e.Project,
+		"APERTURE_QUEUE_BACKEND": "sqs",
+		"APERTURE_DEV_IDENTITY":  "true",
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devenv
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture"
+	"github.com/scttfrdmn/aperture/internal/queue"
+)
+
+// fakeLocalStack serves the health check, and records the buckets,
+// tables, and queues created.
+type fakeLocalStack struct {
+	services map[string]string
+	buckets  []string
+	tables   []string
+	queues   map[string]map[string]string
+}
+
+func (f *fakeLocalStack) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/_localstack/health":
+		json.NewEncoder(w).Encode(map[string]any{"services": f.services})
+	case r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.CreateTable":
+		var in struct{ TableName string }
+		json.NewDecoder(r.Body).Decode(&in)
+		if slices.Contains(f.tables, in.TableName) {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"__type": "com.amazonaws.dynamodb.v20120810#ResourceInUseException", "message": "exists"}`)
+			return
+		}
+		f.tables = append(f.tables, in.TableName)
+		io.WriteString(w, `{}`)
+	case r.Header.Get("X-Amz-Target") == "AmazonSQS.CreateQueue":
+		var in struct {
+			QueueName  string
+			Attributes map[string]string
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		f.queues[in.QueueName] = in.Attributes
+		json.NewEncoder(w).Encode(map[string]string{"QueueUrl": "http://localhost:4566/000000000000/" + in.QueueName})
+	case r.Method == http.MethodPut:
+		name := strings.Trim(r.URL.Path, "/")
+		if slices.Contains(f.buckets, name) {
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, `<Error><Code>BucketAlreadyOwnedByYou</Code></Error>`)
+			return
+		}
+		f.buckets = append(f.buckets, name)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func TestUp(t *testing.T) {
+	f := &fakeLocalStack{services: map[string]string{"s3": "running", "dynamodb": "available", "sqs": "running", "lambda": "disabled"}, queues: map[string]map[string]string{}}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	e := &Env{Endpoint: srv.URL, Region: "us-east-1", Project: "aperture", Environment: "dev"}
+	ctx := context.Background()
+	var out strings.Builder
+	if err := e.Up(ctx, &out); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if len(f.buckets) != len(bucketSuffixes) || f.buckets[0] != "aperture-dev-public-media" {
+		t.Errorf("buckets = %v", f.buckets)
+	}
+	if len(f.tables) != 7 || f.tables[0] != "aperture-users-dev" {
+		t.Errorf("tables = %v", f.tables)
+	}
+	if len(f.queues) != 6 || !strings.Contains(f.queues["aperture-dev-index"]["RedrivePolicy"], "aperture-dev-index-dead") {
+		t.Errorf("queues = %v", f.queues)
+	}
+	if !strings.Contains(out.String(), "table  aperture-doi-registry-dev") {
+		t.Errorf("output = %s", out.String())
+	}
+
+	// Running it again finds everything in place.
+	if err := e.Up(ctx, io.Discard); err != nil {
+		t.Fatalf("second Up() error = %v", err)
+	}
+	if len(f.buckets) != len(bucketSuffixes) || len(f.tables) != 7 {
+		t.Errorf("second Up() created more: %v, %v", f.buckets, f.tables)
+	}
+
+	f.services["dynamodb"] = "disabled"
+	if err := e.Up(ctx, io.Discard); err == nil || !strings.Contains(err.Error(), "dynamodb is disabled") {
+		t.Errorf("Up() without DynamoDB error = %v", err)
+	}
+	srv.Close()
+	if err := e.Up(ctx, io.Discard); err == nil || !strings.Contains(err.Error(), "not running") {
+		t.Errorf("Up() without LocalStack error = %v", err)
+	}
+}
+
+// TestStack checks that the environment mirrors the buckets, tables, and
+// queues of the Terraform stack.
+func TestStack(t *testing.T) {
+	read := func(module string) string {
+		data, err := fs.ReadFile(aperture.Stack, "infrastructure/terraform/modules/"+module+"/main.tf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	s3, tables, queues := read("s3"), read("dynamodb"), read("sqs")
+	for _, suffix := range bucketSuffixes {
+		if !strings.Contains(s3, `"${local.bucket_prefix}-`+suffix+`"`) {
+			t.Errorf("the stack has no %s bucket", suffix)
+		}
+	}
+	if n := strings.Count(tables, `resource "aws_dynamodb_table"`); n != len(Tables("p", "e")) {
+		t.Errorf("the stack has %d tables, the environment %d", n, len(Tables("p", "e")))
+	}
+	for _, table := range Tables("${var.project_name}", "${var.environment}") {
+		if !strings.Contains(tables, `"`+table.Name+`"`) {
+			t.Errorf("the stack has no table %s", table.Name)
+		}
+		if !strings.Contains(tables, `hash_key       = "`+table.HashKey+`"`) {
+			t.Errorf("the stack has no table keyed by %s", table.HashKey)
+		}
+	}
+	for _, topic := range queue.Topics {
+		if !strings.Contains(queues, `"`+topic+`"`) {
+			t.Errorf("the stack has no %s queue", topic)
+		}
+	}
+}
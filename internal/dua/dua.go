@@ -0,0 +1,201 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dua records acceptance of data use agreements (DUAs).
+//
+// A restricted dataset may carry a dataset.Agreement. Before access to
+// its files is granted, the requester must have accepted the current
+// version of that agreement; each acceptance records who accepted,
+// when, and which version, and can be exported for compliance audits.
+package dua
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// acceptancesTable holds one Acceptance per dataset, agreement version,
+// and email address.
+const acceptancesTable = "dua-acceptances"
+
+var (
+	// ErrNoAgreement is returned when accepting an agreement for a
+	// dataset that has none.
+	ErrNoAgreement = errors.New("dataset has no data use agreement")
+
+	// ErrNotAccepted is returned when the current agreement has not
+	// been accepted.
+	ErrNotAccepted = errors.New("data use agreement not accepted")
+)
+
+// Acceptance records that a person accepted a data use agreement.
+type Acceptance struct {
+	DatasetID        string    `json:"datasetId"`
+	AgreementVersion string    `json:"agreementVersion"`
+	DocumentSHA256   string    `json:"documentSha256"`
+	Name             string    `json:"name"`
+	Email            string    `json:"email"`
+	Principal        string    `json:"principal"`
+	AcceptedAt       time.Time `json:"acceptedAt"`
+}
+
+// Registry stores acceptances.
+type Registry struct {
+	s   state.Store
+	log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// NewRegistry returns a registry backed by s that records acceptances
+// in log, which may be nil.
+func NewRegistry(s state.Store, log audit.Log) *Registry {
+	return &Registry{s: s, log: log}
+}
+
+// Accept records that name <email> accepted the current agreement of d.
+func (r *Registry) Accept(ctx context.Context, d *dataset.Dataset, name, email string) (*Acceptance, error) {
+	if d.Agreement == nil {
+		return nil, fmt.Errorf("%s: %w", d.ID, ErrNoAgreement)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required to accept a data use agreement")
+	}
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Acceptance{
+		DatasetID:        d.ID,
+		AgreementVersion: d.Agreement.Version,
+		DocumentSHA256:   d.Agreement.SHA256,
+		Name:             name,
+		Email:            email,
+		Principal:        identity.FromContext(ctx).String(),
+		AcceptedAt:       r.now().UTC(),
+	}
+	if err := r.s.Put(ctx, acceptancesTable, key(d.ID, a.AgreementVersion, email), a); err != nil {
+		return nil, err
+	}
+	if r.log != nil {
+		err := audit.Record(ctx, r.log, "dua.accept", d.ID, map[string]string{
+			"version": a.AgreementVersion,
+			"email":   email,
+			"name":    name,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Check returns the acceptance that lets email access d's files. It
+// returns nil and no error if d has no agreement, and ErrNotAccepted if
+// the current version has not been accepted.
+func (r *Registry) Check(ctx context.Context, d *dataset.Dataset, email string) (*Acceptance, error) {
+	if d.Agreement == nil {
+		return nil, nil
+	}
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotAccepted, err)
+	}
+	var a Acceptance
+	err = r.s.Get(ctx, acceptancesTable, key(d.ID, d.Agreement.Version, email), &a)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s has not accepted version %s of the agreement for %s",
+			ErrNotAccepted, email, d.Agreement.Version, d.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// List returns acceptances for datasetID, or for all datasets if it is
+// empty, ordered by acceptance time.
+func (r *Registry) List(ctx context.Context, datasetID string) ([]Acceptance, error) {
+	all, err := state.List[Acceptance](ctx, r.s, acceptancesTable)
+	if err != nil {
+		return nil, err
+	}
+	out := all[:0]
+	for _, a := range all {
+		if datasetID == "" || a.DatasetID == datasetID {
+			out = append(out, a)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].AcceptedAt.Before(out[j].AcceptedAt) })
+	return out, nil
+}
+
+// Export writes acceptances as "csv" or "json".
+func Export(w io.Writer, acceptances []Acceptance, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(acceptances)
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"dataset", "agreement_version", "document_sha256", "name", "email", "principal", "accepted_at"})
+		for _, a := range acceptances {
+			_ = cw.Write([]string{
+				a.DatasetID, a.AgreementVersion, a.DocumentSHA256, a.Name, a.Email, a.Principal,
+				a.AcceptedAt.Format(time.RFC3339),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown export format %q (want csv or json)", format)
+}
+
+func (r *Registry) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// key identifies an acceptance.
+func key(datasetID, version, email string) string {
+	return datasetID + "/" + version + "/" + email
+}
+
+// normalizeEmail validates email and returns its lowercased address.
+func normalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return "", fmt.Errorf("invalid email address %q", email)
+	}
+	return strings.ToLower(addr.Address), nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dua
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func TestAcceptAndCheck(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "alice"})
+	log := &audit.MemoryLog{}
+	r := NewRegistry(state.NewMemoryStore(), log)
+	r.Now = func() time.Time { return time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC) }
+
+	d := &dataset.Dataset{ID: "ds-1"}
+	if _, err := r.Check(ctx, d, "alice@example.edu"); err != nil {
+		t.Errorf("Check() without agreement error = %v, want nil", err)
+	}
+	if _, err := r.Accept(ctx, d, "Alice", "alice@example.edu"); !errors.Is(err, ErrNoAgreement) {
+		t.Errorf("Accept() without agreement error = %v, want ErrNoAgreement", err)
+	}
+
+	d.Agreement = &dataset.Agreement{Version: "1", Document: "/datasets/ds-1/agreement-1.pdf", SHA256: "abc"}
+	if _, err := r.Check(ctx, d, "alice@example.edu"); !errors.Is(err, ErrNotAccepted) {
+		t.Errorf("Check() before accepting error = %v, want ErrNotAccepted", err)
+	}
+	if _, err := r.Accept(ctx, d, "Alice", "not an email"); err == nil {
+		t.Error("Accept() with invalid email succeeded")
+	}
+	if _, err := r.Accept(ctx, d, "Alice", "Alice <Alice@Example.edu>"); err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	a, err := r.Check(ctx, d, "alice@example.edu")
+	if err != nil {
+		t.Fatalf("Check() after accepting error = %v", err)
+	}
+	if a.Principal != "alice" || a.AgreementVersion != "1" || a.DocumentSHA256 != "abc" {
+		t.Errorf("Check() = %+v", a)
+	}
+
+	// A new agreement version must be accepted again.
+	d.Agreement.Version = "2"
+	if _, err := r.Check(ctx, d, "alice@example.edu"); !errors.Is(err, ErrNotAccepted) {
+		t.Errorf("Check() after version change error = %v, want ErrNotAccepted", err)
+	}
+
+	entries, _ := log.Entries(ctx)
+	if len(entries) != 1 || entries[0].Action != "dua.accept" {
+		t.Errorf("audit entries = %+v, want one dua.accept", entries)
+	}
+
+	list, err := r.List(ctx, "ds-1")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List() = %v, %v; want one acceptance", list, err)
+	}
+	var buf bytes.Buffer
+	if err := Export(&buf, list, "csv"); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	want := "ds-1,1,abc,Alice,alice@example.edu,alice,2025-06-01T12:00:00Z"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Export() = %q, want row %q", buf.String(), want)
+	}
+}
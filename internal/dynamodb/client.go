@@ -0,0 +1,322 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dynamodb is a minimal DynamoDB client for creating the
+// platform's tables and keeping Aperture state in them.
+package dynamodb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+// targetPrefix prefixes the X-Amz-Target of every operation.
+const targetPrefix = "DynamoDB_20120810."
+
+// ErrExists is returned when creating a table that already exists.
+var ErrExists = errors.New("dynamodb: table exists")
+
+// ErrConditionFailed is returned when the condition of a write does
+// not hold.
+var ErrConditionFailed = errors.New("dynamodb: condition failed")
+
+// Options configures a Client.
+type Options struct {
+	// Region is the AWS region of the tables
+	Region string
+
+	// Endpoint overrides the regional AWS endpoint
+	Endpoint string
+
+	// Credentials sign requests
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is a DynamoDB client.
+type Client struct {
+	endpoint string
+	signer   *aws.Signer
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://dynamodb." + opts.Region + ".amazonaws.com"
+	}
+	c := &Client{
+		endpoint: strings.TrimRight(endpoint, "/") + "/",
+		signer:   &aws.Signer{Credentials: opts.Credentials, Region: opts.Region, Service: "dynamodb"},
+		http:     opts.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Table describes a table with on-demand capacity.
+type Table struct {
+	Name string
+
+	// HashKey and RangeKey name the key attributes; RangeKey is empty
+	// for a table keyed by its hash key alone
+	HashKey  string
+	RangeKey string
+
+	// Attributes maps the key attributes of the table and its indexes
+	// to their types: S, N, or B
+	Attributes map[string]string
+
+	// Indexes are global secondary indexes projecting every attribute
+	Indexes []Index
+}
+
+// Index is a global secondary index.
+type Index struct {
+	Name     string
+	HashKey  string
+	RangeKey string
+}
+
+// keySchema returns the key schema of a hash key and optional range
+// key.
+func keySchema(hash, rng string) []map[string]string {
+	schema := []map[string]string{{"AttributeName": hash, "KeyType": "HASH"}}
+	if rng != "" {
+		schema = append(schema, map[string]string{"AttributeName": rng, "KeyType": "RANGE"})
+	}
+	return schema
+}
+
+// CreateTable starts creating t. It returns an error wrapping ErrExists
+// if a table of the same name exists.
+func (c *Client) CreateTable(ctx context.Context, t Table) error {
+	in := map[string]any{
+		"TableName":   t.Name,
+		"BillingMode": "PAY_PER_REQUEST",
+		"KeySchema":   keySchema(t.HashKey, t.RangeKey),
+	}
+	var attrs []map[string]string
+	for _, name := range slices.Sorted(maps.Keys(t.Attributes)) {
+		attrs = append(attrs, map[string]string{"AttributeName": name, "AttributeType": t.Attributes[name]})
+	}
+	in["AttributeDefinitions"] = attrs
+	if len(t.Indexes) > 0 {
+		var indexes []map[string]any
+		for _, idx := range t.Indexes {
+			indexes = append(indexes, map[string]any{
+				"IndexName":  idx.Name,
+				"KeySchema":  keySchema(idx.HashKey, idx.RangeKey),
+				"Projection": map[string]string{"ProjectionType": "ALL"},
+			})
+		}
+		in["GlobalSecondaryIndexes"] = indexes
+	}
+	return c.do(ctx, "CreateTable", in, &struct{}{})
+}
+
+// Item is a DynamoDB item of scalar attributes in the low-level
+// attribute value format, such as {"key": {"S": "v1"}}.
+type Item map[string]map[string]string
+
+// GetItem returns the item of table with the given key, or nil if there
+// is none. Reads are strongly consistent.
+func (c *Client) GetItem(ctx context.Context, table string, key Item) (Item, error) {
+	var out struct{ Item Item }
+	in := map[string]any{"TableName": table, "Key": key, "ConsistentRead": true}
+	if err := c.do(ctx, "GetItem", in, &out); err != nil {
+		return nil, err
+	}
+	return out.Item, nil
+}
+
+// PutItem stores item in table, replacing any item with the same key.
+func (c *Client) PutItem(ctx context.Context, table string, item Item) error {
+	return c.do(ctx, "PutItem", map[string]any{"TableName": table, "Item": item}, &struct{}{})
+}
+
+// CreateItem stores item in table if no item has its key, and
+// otherwise returns an error wrapping ErrConditionFailed. hashKey names
+// the table's hash key attribute.
+func (c *Client) CreateItem(ctx context.Context, table string, item Item, hashKey string) error {
+	return c.do(ctx, "PutItem", map[string]any{
+		"TableName":                table,
+		"Item":                     item,
+		"ConditionExpression":      "attribute_not_exists(#k)",
+		"ExpressionAttributeNames": map[string]string{"#k": hashKey},
+	}, &struct{}{})
+}
+
+// DeleteItem removes the item of table with the given key. Deleting a
+// missing item is not an error.
+func (c *Client) DeleteItem(ctx context.Context, table string, key Item) error {
+	return c.do(ctx, "DeleteItem", map[string]any{"TableName": table, "Key": key}, &struct{}{})
+}
+
+// Update is a conditional update of one item.
+type Update struct {
+	// Expression is the update expression, such as "ADD #n :one"
+	Expression string
+
+	// Condition must hold for the update to be applied; empty for
+	// none
+	Condition string
+
+	// Names and Values are the attribute names and values the
+	// expressions refer to
+	Names  map[string]string
+	Values Item
+}
+
+// UpdateItem applies u to the item of table with the given key,
+// creating it if it is missing, and returns the updated item. It
+// returns an error wrapping ErrConditionFailed, and changes nothing, if
+// the condition does not hold. The check and the update are one atomic
+// operation.
+func (c *Client) UpdateItem(ctx context.Context, table string, key Item, u Update) (Item, error) {
+	in := map[string]any{
+		"TableName":        table,
+		"Key":              key,
+		"UpdateExpression": u.Expression,
+		"ReturnValues":     "ALL_NEW",
+	}
+	if u.Condition != "" {
+		in["ConditionExpression"] = u.Condition
+	}
+	if len(u.Names) > 0 {
+		in["ExpressionAttributeNames"] = u.Names
+	}
+	if len(u.Values) > 0 {
+		in["ExpressionAttributeValues"] = u.Values
+	}
+	var out struct{ Attributes Item }
+	if err := c.do(ctx, "UpdateItem", in, &out); err != nil {
+		return nil, err
+	}
+	return out.Attributes, nil
+}
+
+// Query returns the items of table whose hash key attribute equals
+// value, projecting only the named attributes, or every attribute if
+// none are named. It follows pagination until every item is read.
+func (c *Client) Query(ctx context.Context, table, hashKey, value string, attributes ...string) ([]Item, error) {
+	in := map[string]any{
+		"TableName":                 table,
+		"KeyConditionExpression":    "#h = :h",
+		"ExpressionAttributeNames":  map[string]string{"#h": hashKey},
+		"ExpressionAttributeValues": Item{":h": {"S": value}},
+		"ConsistentRead":            true,
+	}
+	if len(attributes) > 0 {
+		names := in["ExpressionAttributeNames"].(map[string]string)
+		var projection []string
+		for i, a := range attributes {
+			ref := fmt.Sprintf("#p%d", i)
+			names[ref] = a
+			projection = append(projection, ref)
+		}
+		in["ProjectionExpression"] = strings.Join(projection, ", ")
+	}
+	var items []Item
+	for {
+		var out struct {
+			Items            []Item
+			LastEvaluatedKey Item
+		}
+		if err := c.do(ctx, "Query", in, &out); err != nil {
+			return nil, err
+		}
+		items = append(items, out.Items...)
+		if len(out.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		in["ExclusiveStartKey"] = out.LastEvaluatedKey
+	}
+}
+
+// do calls operation with in as the JSON request and decodes the
+// response into out.
+func (c *Client) do(ctx context.Context, operation string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", operation, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", operation, err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", targetPrefix+operation)
+	c.signer.Sign(req, aws.HashPayload(body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("dynamodb %s failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode, Operation: operation}
+		_ = json.Unmarshal(data, e)
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		return e
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", operation, err)
+	}
+	return nil
+}
+
+// Error is a DynamoDB error response.
+type Error struct {
+	StatusCode int
+	Operation  string
+	Type       string `json:"__type"`
+	Message    string `json:"message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("dynamodb %s: HTTP %d %s: %s", e.Operation, e.StatusCode, e.Type, e.Message)
+}
+
+// Unwrap maps tables in use to ErrExists and failed conditions to
+// ErrConditionFailed.
+func (e *Error) Unwrap() error {
+	switch e.Type {
+	case "ResourceInUseException":
+		return ErrExists
+	case "ConditionalCheckFailedException":
+		return ErrConditionFailed
+	}
+	return nil
+}
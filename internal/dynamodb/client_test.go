@@ -0,0 +1,139 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return NewClient(Options{
+		Region:      "us-east-1",
+		Endpoint:    srv.URL,
+		Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+}
+
+func TestCreateTable(t *testing.T) {
+	var got map[string]any
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != targetPrefix+"CreateTable" {
+			t.Errorf("target = %s", r.Header.Get("X-Amz-Target"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{"TableDescription": {"TableStatus": "CREATING"}}`)
+	})
+	err := c.CreateTable(context.Background(), Table{
+		Name:       "aperture-doi-registry-dev",
+		HashKey:    "doi",
+		RangeKey:   "version",
+		Attributes: map[string]string{"doi": "S", "version": "N", "status": "S"},
+		Indexes:    []Index{{Name: "StatusIndex", HashKey: "status"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateTable() error = %v", err)
+	}
+	want := map[string]any{
+		"TableName":   "aperture-doi-registry-dev",
+		"BillingMode": "PAY_PER_REQUEST",
+		"KeySchema": []any{
+			map[string]any{"AttributeName": "doi", "KeyType": "HASH"},
+			map[string]any{"AttributeName": "version", "KeyType": "RANGE"},
+		},
+		"AttributeDefinitions": []any{
+			map[string]any{"AttributeName": "doi", "AttributeType": "S"},
+			map[string]any{"AttributeName": "status", "AttributeType": "S"},
+			map[string]any{"AttributeName": "version", "AttributeType": "N"},
+		},
+		"GlobalSecondaryIndexes": []any{map[string]any{
+			"IndexName":  "StatusIndex",
+			"KeySchema":  []any{map[string]any{"AttributeName": "status", "KeyType": "HASH"}},
+			"Projection": map[string]any{"ProjectionType": "ALL"},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request = %v, want %v", got, want)
+	}
+}
+
+func TestCreateTableExists(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type": "com.amazonaws.dynamodb.v20120810#ResourceInUseException", "message": "Table already exists"}`)
+	})
+	err := c.CreateTable(context.Background(), Table{Name: "t", HashKey: "id", Attributes: map[string]string{"id": "S"}})
+	var e *Error
+	if !errors.Is(err, ErrExists) || !errors.As(err, &e) || e.Operation != "CreateTable" {
+		t.Errorf("CreateTable() error = %v, want ErrExists", err)
+	}
+}
+
+func TestUpdateItem(t *testing.T) {
+	var got map[string]any
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != targetPrefix+"UpdateItem" {
+			t.Errorf("target = %s", r.Header.Get("X-Amz-Target"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{"Attributes": {"id": {"S": "a"}, "n": {"N": "2"}}}`)
+	})
+	item, err := c.UpdateItem(context.Background(), "t", Item{"id": {"S": "a"}}, Update{
+		Expression: "ADD #n :one",
+		Condition:  "attribute_not_exists(#n) OR #n < :max",
+		Names:      map[string]string{"#n": "n"},
+		Values:     Item{":one": {"N": "1"}, ":max": {"N": "3"}},
+	})
+	if err != nil {
+		t.Fatalf("UpdateItem() error = %v", err)
+	}
+	if item["n"]["N"] != "2" {
+		t.Errorf("UpdateItem() = %v, want n = 2", item)
+	}
+	want := map[string]any{
+		"TableName":                 "t",
+		"Key":                       map[string]any{"id": map[string]any{"S": "a"}},
+		"UpdateExpression":          "ADD #n :one",
+		"ConditionExpression":       "attribute_not_exists(#n) OR #n < :max",
+		"ExpressionAttributeNames":  map[string]any{"#n": "n"},
+		"ExpressionAttributeValues": map[string]any{":one": map[string]any{"N": "1"}, ":max": map[string]any{"N": "3"}},
+		"ReturnValues":              "ALL_NEW",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request = %v, want %v", got, want)
+	}
+}
+
+func TestUpdateItemConditionFailed(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type": "com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException", "message": "The conditional request failed"}`)
+	})
+	_, err := c.UpdateItem(context.Background(), "t", Item{"id": {"S": "a"}}, Update{Expression: "ADD #n :one"})
+	if !errors.Is(err, ErrConditionFailed) {
+		t.Errorf("UpdateItem() error = %v, want ErrConditionFailed", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// Attribute names of the state table. Every record is one item whose
+// hash key is its table, range key its key, and value its JSON
+// encoding.
+const (
+	tableAttr = "table"
+	keyAttr   = "key"
+	valueAttr = "value"
+)
+
+// StateTable returns the definition of a state table for Store.
+func StateTable(name string) Table {
+	return Table{
+		Name:       name,
+		HashKey:    tableAttr,
+		RangeKey:   keyAttr,
+		Attributes: map[string]string{tableAttr: "S", keyAttr: "S"},
+	}
+}
+
+// Store is a state.Store kept in a single DynamoDB table, so that the
+// CLI and the platform's Lambda functions share one set of records.
+type Store struct {
+	c     *Client
+	table string
+}
+
+// NewStore returns a store over table, which must have the layout of
+// StateTable.
+func NewStore(c *Client, table string) *Store {
+	return &Store{c: c, table: table}
+}
+
+// itemKey returns the key of the item holding table/key.
+func itemKey(table, key string) Item {
+	return Item{tableAttr: {"S": table}, keyAttr: {"S": key}}
+}
+
+// Get implements state.Store.
+func (s *Store) Get(ctx context.Context, table, key string, v any) error {
+	item, err := s.c.GetItem(ctx, s.table, itemKey(table, key))
+	if err != nil {
+		return fmt.Errorf("failed to read %s %q: %w", table, key, err)
+	}
+	if item == nil {
+		return fmt.Errorf("%s %q: %w", table, key, state.ErrNotFound)
+	}
+	if err := json.Unmarshal([]byte(item[valueAttr]["S"]), v); err != nil {
+		return fmt.Errorf("corrupt %s record %q: %w", table, key, err)
+	}
+	return nil
+}
+
+// Put implements state.Store.
+func (s *Store) Put(ctx context.Context, table, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s %q: %w", table, key, err)
+	}
+	item := itemKey(table, key)
+	item[valueAttr] = map[string]string{"S": string(data)}
+	if err := s.c.PutItem(ctx, s.table, item); err != nil {
+		return fmt.Errorf("failed to write %s %q: %w", table, key, err)
+	}
+	return nil
+}
+
+// Create implements state.Store with a conditional put, so the check
+// and the write are one operation.
+func (s *Store) Create(ctx context.Context, table, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s %q: %w", table, key, err)
+	}
+	item := itemKey(table, key)
+	item[valueAttr] = map[string]string{"S": string(data)}
+	err = s.c.CreateItem(ctx, s.table, item, tableAttr)
+	if errors.Is(err, ErrConditionFailed) {
+		return fmt.Errorf("%s %q: %w", table, key, state.ErrExists)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s %q: %w", table, key, err)
+	}
+	return nil
+}
+
+// Delete implements state.Store.
+func (s *Store) Delete(ctx context.Context, table, key string) error {
+	if err := s.c.DeleteItem(ctx, s.table, itemKey(table, key)); err != nil {
+		return fmt.Errorf("failed to delete %s %q: %w", table, key, err)
+	}
+	return nil
+}
+
+// Keys implements state.Store. DynamoDB returns range keys in byte
+// order, which is the sorted order of Go strings.
+func (s *Store) Keys(ctx context.Context, table string) ([]string, error) {
+	items, err := s.c.Query(ctx, s.table, tableAttr, table, keyAttr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", table, err)
+	}
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, item[keyAttr]["S"])
+	}
+	return keys, nil
+}
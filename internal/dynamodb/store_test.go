@@ -0,0 +1,146 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// fakeTable serves the item operations of Store against one in-memory
+// table, returning Query results one item per page.
+type fakeTable struct {
+	mu    sync.Mutex
+	items map[[2]string]Item
+}
+
+func (f *fakeTable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var in struct {
+		Key                       Item
+		Item                      Item
+		ConditionExpression       string
+		ExpressionAttributeValues Item
+		ExclusiveStartKey         Item
+	}
+	json.NewDecoder(r.Body).Decode(&in)
+	id := func(it Item) [2]string { return [2]string{it[tableAttr]["S"], it[keyAttr]["S"]} }
+
+	out := map[string]any{}
+	switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), targetPrefix) {
+	case "GetItem":
+		if it, ok := f.items[id(in.Key)]; ok {
+			out["Item"] = it
+		}
+	case "PutItem":
+		if _, ok := f.items[id(in.Item)]; ok && in.ConditionExpression == "attribute_not_exists(#k)" {
+			http.Error(w, `{"__type": "com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException"}`, http.StatusBadRequest)
+			return
+		}
+		f.items[id(in.Item)] = in.Item
+	case "DeleteItem":
+		delete(f.items, id(in.Key))
+	case "Query":
+		var keys []string
+		for k := range f.items {
+			if k[0] == in.ExpressionAttributeValues[":h"]["S"] {
+				keys = append(keys, k[1])
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			if in.ExclusiveStartKey != nil && k <= in.ExclusiveStartKey[keyAttr]["S"] {
+				continue
+			}
+			it := Item{keyAttr: {"S": k}}
+			out["Items"] = []Item{it}
+			out["LastEvaluatedKey"] = itemKey(in.ExpressionAttributeValues[":h"]["S"], k)
+			break
+		}
+	default:
+		http.Error(w, `{"__type": "UnknownOperationException"}`, http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(newTestClient(t, (&fakeTable{items: map[[2]string]Item{}}).ServeHTTP), "aperture-state-dev")
+
+	type rec struct{ Title string }
+	var got rec
+	if err := s.Get(ctx, "datasets", "10.1/a", &got); !errors.Is(err, state.ErrNotFound) {
+		t.Fatalf("Get() missing error = %v, want ErrNotFound", err)
+	}
+	for _, key := range []string{"10.1/b", "10.1/a", "10.1/c"} {
+		if err := s.Put(ctx, "datasets", key, rec{Title: key}); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+	if err := s.Create(ctx, "grants", "g1", rec{Title: "g1"}); err != nil {
+		t.Fatalf("Create(grants) error = %v", err)
+	}
+	if err := s.Create(ctx, "grants", "g1", rec{}); !errors.Is(err, state.ErrExists) {
+		t.Fatalf("Create(grants) again error = %v, want ErrExists", err)
+	}
+	if err := s.Get(ctx, "grants", "g1", &got); err != nil || got.Title != "g1" {
+		t.Fatalf("Get() after failed Create() = %+v, %v", got, err)
+	}
+	if err := s.Get(ctx, "datasets", "10.1/a", &got); err != nil || got.Title != "10.1/a" {
+		t.Fatalf("Get() = %+v, %v", got, err)
+	}
+	if err := s.Delete(ctx, "datasets", "10.1/c"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	keys, err := s.Keys(ctx, "datasets")
+	if err != nil {
+		t.Fatalf("Keys() error = %v", err)
+	}
+	if want := []string{"10.1/a", "10.1/b"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Keys() = %v, want %v", keys, want)
+	}
+	all, err := state.List[rec](ctx, s, "datasets")
+	if err != nil || len(all) != 2 {
+		t.Errorf("List() = %v, %v", all, err)
+	}
+}
+
+func TestStoreError(t *testing.T) {
+	s := NewStore(newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type": "com.amazonaws.dynamodb.v20120810#ResourceNotFoundException", "message": "Requested resource not found"}`))
+	}), "missing")
+	var v any
+	err := s.Get(context.Background(), "datasets", "a", &v)
+	var e *Error
+	if !errors.As(err, &e) || e.Type != "ResourceNotFoundException" {
+		t.Fatalf("Get() error = %v, want ResourceNotFoundException", err)
+	}
+	if errors.Is(err, state.ErrNotFound) {
+		t.Error("a missing table reported as a missing record")
+	}
+}
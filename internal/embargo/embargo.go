@@ -0,0 +1,420 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package embargo withholds dataset files until a release date and
+// releases them when the date passes.
+//
+// An embargoed dataset keeps its landing page and DOI so that it stays
+// findable, but its files live in the embargoed bucket, which is never
+// served. Release moves the files to the bucket for the dataset's
+// release access level, republishes the landing page, and records the
+// availability date with DataCite. Extensions and early releases must
+// give a reason, which is kept in the dataset's history.
+package embargo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+var (
+	// ErrNotEmbargoed is returned when releasing a dataset with no embargo.
+	ErrNotEmbargoed = errors.New("dataset is not under embargo")
+
+	// ErrReasonRequired is returned when extending or lifting an embargo
+	// without a reason.
+	ErrReasonRequired = errors.New("a reason is required")
+)
+
+// ObjectMover copies and deletes stored objects.
+type ObjectMover interface {
+	CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
+	DeleteObject(ctx context.Context, bucket, key string) error
+}
+
+// PagePublisher re-renders a dataset's landing page.
+type PagePublisher interface {
+	Publish(ctx context.Context, datasetID string) (*landing.Change, error)
+}
+
+// DOIUpdater reads and updates DataCite records.
+type DOIUpdater interface {
+	GetDOI(ctx context.Context, doi string) (*datacite.DOI, error)
+	UpdateDOI(ctx context.Context, doi string, attrs datacite.Attributes) (*datacite.DOI, error)
+}
+
+// Manager places and releases embargoes.
+type Manager struct {
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Objects moves files between buckets
+	Objects ObjectMover
+
+	// Layout locates objects for each access level
+	Layout storage.Layout
+
+	// Pages republishes landing pages; skipped if nil
+	Pages PagePublisher
+
+	// DOIs updates DataCite records; skipped if nil
+	DOIs DOIUpdater
+
+	// Log records embargo changes; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Set places d under embargo until the given time, moving its files to
+// the embargoed bucket. Setting an embargo on an embargoed dataset
+// changes its release date.
+func (m *Manager) Set(ctx context.Context, ref string, until time.Time) (*dataset.Dataset, error) {
+	d, err := m.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if !until.After(m.now()) {
+		return nil, fmt.Errorf("embargo date %s is not in the future", until.Format(time.DateOnly))
+	}
+
+	if d.Embargo == nil {
+		release := d.Access
+		if release == "" || release == storage.AccessEmbargoed {
+			release = storage.AccessPublic
+		}
+		d.Embargo = &dataset.Embargo{ReleaseAccess: release}
+	}
+	d.Embargo.Until = until.UTC()
+
+	details := map[string]string{
+		"until":         d.Embargo.Until.Format(time.RFC3339),
+		"releaseAccess": string(d.Embargo.ReleaseAccess),
+	}
+	m.note(ctx, d, "embargo.set", "", details)
+	if err := m.move(ctx, d, storage.AccessEmbargoed); err != nil {
+		return nil, err
+	}
+	if err := m.announce(ctx, d, until, embargoInfo(until)); err != nil {
+		return nil, err
+	}
+	if err := m.record(ctx, "embargo.set", d, details); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Extend moves d's release date later, recording reason in its history.
+func (m *Manager) Extend(ctx context.Context, ref string, until time.Time, reason string) (*dataset.Dataset, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("%w to extend an embargo", ErrReasonRequired)
+	}
+	d, err := m.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if d.Embargo == nil {
+		return nil, fmt.Errorf("%s: %w", d.ID, ErrNotEmbargoed)
+	}
+	if !until.After(d.Embargo.Until) {
+		return nil, fmt.Errorf("new date %s is not after the current release date %s",
+			until.Format(time.DateOnly), d.Embargo.Until.Format(time.DateOnly))
+	}
+
+	details := map[string]string{
+		"from":  d.Embargo.Until.Format(time.RFC3339),
+		"until": until.UTC().Format(time.RFC3339),
+	}
+	d.Embargo.Until = until.UTC()
+	m.note(ctx, d, "embargo.extend", reason, details)
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return nil, err
+	}
+	if err := m.announce(ctx, d, until, embargoInfo(until)); err != nil {
+		return nil, err
+	}
+	details["reason"] = reason
+	if err := m.record(ctx, "embargo.extend", d, details); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Lift releases d before its scheduled date, recording reason in its
+// history. The principal in ctx must hold a role that may lift
+// embargoes.
+func (m *Manager) Lift(ctx context.Context, ref, reason string) (*dataset.Dataset, error) {
+	if err := authz.RequirePermission(identity.FromContext(ctx), authz.PermLiftEmbargo); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("%w to lift an embargo early", ErrReasonRequired)
+	}
+	d, err := m.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return m.release(ctx, d, "embargo.lift", reason)
+}
+
+// Release ends d's embargo once its release date has passed, moving its
+// files to the bucket for its release access level. Use Lift to release
+// before the scheduled date.
+func (m *Manager) Release(ctx context.Context, ref string) (*dataset.Dataset, error) {
+	d, err := m.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if d.Embargoed(m.now()) {
+		return nil, fmt.Errorf("%s is embargoed until %s; lifting it early requires a reason",
+			d.ID, d.Embargo.Until.Format(time.DateOnly))
+	}
+	return m.release(ctx, d, "embargo.release", "")
+}
+
+// release ends d's embargo and records action with reason.
+func (m *Manager) release(ctx context.Context, d *dataset.Dataset, action, reason string) (*dataset.Dataset, error) {
+	if d.Embargo == nil {
+		return nil, fmt.Errorf("%s: %w", d.ID, ErrNotEmbargoed)
+	}
+
+	until := d.Embargo.Until
+	release := d.Embargo.ReleaseAccess
+	details := map[string]string{
+		"until":  until.Format(time.RFC3339),
+		"access": string(release),
+	}
+	d.Embargo = nil
+	m.note(ctx, d, action, reason, details)
+	if err := m.move(ctx, d, release); err != nil {
+		return nil, err
+	}
+
+	// The availability date is the scheduled one unless released early.
+	available := m.now()
+	info := ""
+	if until.Before(available) {
+		available = until
+	} else {
+		info = "Embargo lifted early"
+	}
+	if err := m.announce(ctx, d, available, info); err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		details["reason"] = reason
+	}
+	if err := m.record(ctx, action, d, details); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Due returns the embargoed datasets whose release date has passed,
+// oldest first.
+func (m *Manager) Due(ctx context.Context) ([]*dataset.Dataset, error) {
+	all, err := m.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	var due []*dataset.Dataset
+	for _, d := range all {
+		if d.Embargo != nil && !d.Embargoed(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].Embargo.Until.Before(due[j].Embargo.Until)
+	})
+	return due, nil
+}
+
+// ReleaseDue releases every dataset whose embargo has passed. A failed
+// release does not stop the others; it is retried on the next run.
+func (m *Manager) ReleaseDue(ctx context.Context) ([]*dataset.Dataset, error) {
+	due, err := m.Due(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var released []*dataset.Dataset
+	var errs []error
+	for _, d := range due {
+		r, err := m.Release(ctx, d.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to release %s: %w", d.ID, err))
+			continue
+		}
+		released = append(released, r)
+	}
+	return released, errors.Join(errs...)
+}
+
+// move relocates every file of d to the location for access, saves the
+// updated manifest, and then removes the old copies no manifest still
+// refers to; under a content-addressed layout they may be shared with
+// other versions or datasets. The manifest is saved before deleting so
+// that an interrupted move leaves orphans for storage gc rather than a
+// manifest pointing at missing objects.
+func (m *Manager) move(ctx context.Context, d *dataset.Dataset, access storage.Access) error {
+	var stale []storage.Location
+	copied := make(map[storage.Location]bool)
+	for i := range d.Versions {
+		v := &d.Versions[i]
+		if v.Removed() {
+			continue
+		}
+		for j := range v.Files {
+			f := &v.Files[j]
+			dst, err := m.Layout.Locate(storage.Object{
+				Dataset:    d.ID,
+				Version:    v.Number,
+				File:       f.Path,
+				Collection: d.Collection,
+				Access:     access,
+				SHA256:     f.SHA256,
+			})
+			if err != nil {
+				return err
+			}
+			src := storage.Location{Bucket: f.Bucket, Key: f.Key}
+			if src == dst {
+				continue
+			}
+			if !copied[src] {
+				if err := m.Objects.CopyObject(ctx, src.Bucket, src.Key, dst.Bucket, dst.Key); err != nil {
+					return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+				}
+				copied[src] = true
+				stale = append(stale, src)
+			}
+			f.Bucket, f.Key = dst.Bucket, dst.Key
+		}
+	}
+
+	d.Access = access
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	refs, err := m.Datasets.References(ctx)
+	if err != nil {
+		return err
+	}
+	for _, loc := range stale {
+		if refs[loc] > 0 {
+			continue
+		}
+		if err := m.Objects.DeleteObject(ctx, loc.Bucket, loc.Key); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", loc, err)
+		}
+	}
+	return nil
+}
+
+// announce republishes d's landing page and records its availability
+// date, with optional date information, with DataCite.
+func (m *Manager) announce(ctx context.Context, d *dataset.Dataset, available time.Time, info string) error {
+	if m.Pages != nil && d.State == dataset.StatePublished {
+		if _, err := m.Pages.Publish(ctx, d.ID); err != nil {
+			return err
+		}
+	}
+	if m.DOIs != nil && d.DOI != "" {
+		// DataCite replaces the whole list of dates, so the record's
+		// other dates are sent back with the new Available date
+		rec, err := m.DOIs.GetDOI(ctx, d.DOI)
+		if err != nil {
+			return fmt.Errorf("failed to read DOI %s: %w", d.DOI, err)
+		}
+		attrs := datacite.Attributes{Dates: withAvailable(rec.Attributes.Dates, datacite.Date{
+			Date:            available.UTC().Format(time.DateOnly),
+			DateType:        "Available",
+			DateInformation: info,
+		})}
+		if _, err := m.DOIs.UpdateDOI(ctx, d.DOI, attrs); err != nil {
+			return fmt.Errorf("failed to update DOI %s: %w", d.DOI, err)
+		}
+	}
+	return nil
+}
+
+// withAvailable returns dates with their Available date replaced by
+// available, or with available added if they have none.
+func withAvailable(dates []datacite.Date, available datacite.Date) []datacite.Date {
+	out := make([]datacite.Date, 0, len(dates)+1)
+	replaced := false
+	for _, d := range dates {
+		if strings.EqualFold(d.DateType, available.DateType) {
+			if !replaced {
+				out = append(out, available)
+				replaced = true
+			}
+			continue
+		}
+		out = append(out, d)
+	}
+	if !replaced {
+		out = append(out, available)
+	}
+	return out
+}
+
+// note appends an event to d's history; the caller saves d.
+func (m *Manager) note(ctx context.Context, d *dataset.Dataset, action, reason string, details map[string]string) {
+	d.History = append(d.History, dataset.Event{
+		Time:    m.now().UTC(),
+		Actor:   identity.FromContext(ctx).String(),
+		Action:  action,
+		Reason:  strings.TrimSpace(reason),
+		Details: maps.Clone(details),
+	})
+}
+
+// embargoInfo describes an embargo for DataCite date information.
+func embargoInfo(until time.Time) string {
+	return "Embargoed until " + until.UTC().Format(time.DateOnly)
+}
+
+// record appends an audit entry if a log is configured.
+func (m *Manager) record(ctx context.Context, action string, d *dataset.Dataset, details map[string]string) error {
+	if m.Log == nil {
+		return nil
+	}
+	return audit.Record(ctx, m.Log, action, d.ID, details)
+}
+
+func (m *Manager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,290 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embargo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// fakeObjects is an in-memory ObjectMover keyed by "bucket/key".
+type fakeObjects map[string]bool
+
+func (f fakeObjects) CopyObject(_ context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	if !f[srcBucket+"/"+srcKey] {
+		return errors.New("no such object")
+	}
+	f[dstBucket+"/"+dstKey] = true
+	return nil
+}
+
+func (f fakeObjects) DeleteObject(_ context.Context, bucket, key string) error {
+	delete(f, bucket+"/"+key)
+	return nil
+}
+
+type fakePages struct{ published []string }
+
+func (p *fakePages) Publish(_ context.Context, id string) (*landing.Change, error) {
+	p.published = append(p.published, id)
+	return &landing.Change{DatasetID: id}, nil
+}
+
+type fakeDOIs map[string]datacite.Attributes
+
+func (f fakeDOIs) GetDOI(_ context.Context, doi string) (*datacite.DOI, error) {
+	return &datacite.DOI{ID: doi, Attributes: f[doi]}, nil
+}
+
+func (f fakeDOIs) UpdateDOI(_ context.Context, doi string, attrs datacite.Attributes) (*datacite.DOI, error) {
+	f[doi] = attrs
+	return &datacite.DOI{ID: doi, Attributes: attrs}, nil
+}
+
+func TestSetAndReleaseDue(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	err := datasets.Put(ctx, &dataset.Dataset{
+		ID:     "ds-1",
+		DOI:    "10.5555/ds-1",
+		Access: storage.AccessPublic,
+		State:  dataset.StatePublished,
+		Versions: []dataset.Version{{
+			Number: 1,
+			Files: []dataset.File{{
+				Path:   "a.csv",
+				Bucket: "ap-prod-public-media",
+				Key:    "datasets/ds-1/v1/a.csv",
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	objects := fakeObjects{"ap-prod-public-media/datasets/ds-1/v1/a.csv": true}
+	pages := &fakePages{}
+	dois := fakeDOIs{}
+	log := &audit.MemoryLog{}
+	m := &Manager{
+		Datasets: datasets,
+		Objects:  objects,
+		Layout:   &storage.PurposeLayout{Prefix: "ap-prod"},
+		Pages:    pages,
+		DOIs:     dois,
+		Log:      log,
+		Now:      func() time.Time { return now },
+	}
+
+	if _, err := m.Set(ctx, "ds-1", now.Add(-time.Hour)); err == nil {
+		t.Error("Set() with past date succeeded, want error")
+	}
+
+	until := now.Add(30 * 24 * time.Hour)
+	d, err := m.Set(ctx, "ds-1", until)
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if d.Access != storage.AccessEmbargoed || d.Embargo.ReleaseAccess != storage.AccessPublic {
+		t.Errorf("Set() access = %s, release = %s", d.Access, d.Embargo.ReleaseAccess)
+	}
+	if !objects["ap-prod-embargoed-media/datasets/ds-1/v1/a.csv"] || objects["ap-prod-public-media/datasets/ds-1/v1/a.csv"] {
+		t.Errorf("objects after Set() = %v, want file only in embargoed bucket", objects)
+	}
+
+	due, err := m.ReleaseDue(ctx)
+	if err != nil || len(due) != 0 {
+		t.Fatalf("ReleaseDue() before date = %v, %v; want nothing", due, err)
+	}
+
+	now = until.Add(time.Minute)
+	released, err := m.ReleaseDue(ctx)
+	if err != nil {
+		t.Fatalf("ReleaseDue() error = %v", err)
+	}
+	if len(released) != 1 {
+		t.Fatalf("ReleaseDue() released %d datasets, want 1", len(released))
+	}
+
+	d, err = datasets.Get(ctx, "ds-1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if d.Embargo != nil || d.Access != storage.AccessPublic {
+		t.Errorf("after release embargo = %v, access = %s", d.Embargo, d.Access)
+	}
+	if f := d.Versions[0].Files[0]; f.Bucket != "ap-prod-public-media" {
+		t.Errorf("manifest bucket = %s, want ap-prod-public-media", f.Bucket)
+	}
+	if !objects["ap-prod-public-media/datasets/ds-1/v1/a.csv"] || len(objects) != 1 {
+		t.Errorf("objects after release = %v, want file only in public bucket", objects)
+	}
+	if got := dois["10.5555/ds-1"].Dates; len(got) != 1 || got[0].Date != "2025-07-01" || got[0].DateType != "Available" {
+		t.Errorf("DOI dates = %v, want Available 2025-07-01", got)
+	}
+	if len(pages.published) != 2 {
+		t.Errorf("pages published %d times, want 2", len(pages.published))
+	}
+
+	entries, _ := log.Entries(ctx)
+	if len(entries) != 2 || entries[0].Action != "embargo.set" || entries[1].Action != "embargo.release" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+
+	if _, err := m.Release(ctx, "ds-1"); !errors.Is(err, ErrNotEmbargoed) {
+		t.Errorf("Release() of released dataset error = %v, want ErrNotEmbargoed", err)
+	}
+}
+
+// TestAnnounceKeepsDates checks that announcing an embargo keeps the
+// record's other dates, since DataCite replaces the whole list.
+func TestAnnounceKeepsDates(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	err := datasets.Put(ctx, &dataset.Dataset{
+		ID:     "ds-1",
+		DOI:    "10.5555/ds-1",
+		Access: storage.AccessPublic,
+		State:  dataset.StatePublished,
+		Versions: []dataset.Version{{
+			Number: 1,
+			Files:  []dataset.File{{Path: "a.csv", Bucket: "ap-prod-public-media", Key: "datasets/ds-1/v1/a.csv"}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	dois := fakeDOIs{"10.5555/ds-1": {Dates: []datacite.Date{
+		{Date: "2024-03-01/2024-09-30", DateType: "Collected"},
+		{Date: "2025-01-15", DateType: "Available"},
+		{Date: "2025-05-20", DateType: "Updated"},
+	}}}
+	m := &Manager{
+		Datasets: datasets,
+		Objects:  fakeObjects{"ap-prod-public-media/datasets/ds-1/v1/a.csv": true},
+		Layout:   &storage.PurposeLayout{Prefix: "ap-prod"},
+		DOIs:     dois,
+		Now:      func() time.Time { return now },
+	}
+
+	if _, err := m.Set(ctx, "ds-1", now.Add(30*24*time.Hour)); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	want := []datacite.Date{
+		{Date: "2024-03-01/2024-09-30", DateType: "Collected"},
+		{Date: "2025-07-01", DateType: "Available", DateInformation: "Embargoed until 2025-07-01"},
+		{Date: "2025-05-20", DateType: "Updated"},
+	}
+	if got := dois["10.5555/ds-1"].Dates; !reflect.DeepEqual(got, want) {
+		t.Errorf("DOI dates = %+v, want %+v", got, want)
+	}
+}
+
+func TestExtendAndLift(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	err := datasets.Put(ctx, &dataset.Dataset{
+		ID:     "ds-1",
+		DOI:    "10.5555/ds-1",
+		Access: storage.AccessPublic,
+		State:  dataset.StatePublished,
+		Versions: []dataset.Version{{
+			Number: 1,
+			Files:  []dataset.File{{Path: "a.csv", Bucket: "ap-prod-public-media", Key: "datasets/ds-1/v1/a.csv"}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	dois := fakeDOIs{}
+	m := &Manager{
+		Datasets: datasets,
+		Objects:  fakeObjects{"ap-prod-public-media/datasets/ds-1/v1/a.csv": true},
+		Layout:   &storage.PurposeLayout{Prefix: "ap-prod"},
+		DOIs:     dois,
+		Log:      &audit.MemoryLog{},
+		Now:      func() time.Time { return now },
+	}
+
+	until := now.Add(30 * 24 * time.Hour)
+	if _, err := m.Set(ctx, "ds-1", until); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	later := until.Add(90 * 24 * time.Hour)
+	if _, err := m.Extend(ctx, "ds-1", later, ""); !errors.Is(err, ErrReasonRequired) {
+		t.Errorf("Extend() without reason error = %v, want ErrReasonRequired", err)
+	}
+	if _, err := m.Extend(ctx, "ds-1", until.Add(-time.Hour), "patent filing"); err == nil {
+		t.Error("Extend() to an earlier date succeeded")
+	}
+	d, err := m.Extend(ctx, "ds-1", later, "patent filing")
+	if err != nil {
+		t.Fatalf("Extend() error = %v", err)
+	}
+	if !d.Embargo.Until.Equal(later) {
+		t.Errorf("Extend() until = %s, want %s", d.Embargo.Until, later)
+	}
+	if got := dois["10.5555/ds-1"].Dates; len(got) != 1 || got[0].Date != "2025-09-29" {
+		t.Errorf("DOI dates after Extend() = %v, want Available 2025-09-29", got)
+	}
+
+	if _, err := m.Lift(ctx, "ds-1", "paper accepted"); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Lift() without the steward role error = %v, want ErrForbidden", err)
+	}
+	ctx = identity.WithPrincipal(ctx, identity.Principal{ID: "stu@uni.edu", Groups: []string{authz.RoleSteward.Group()}})
+	if _, err := m.Lift(ctx, "ds-1", " "); !errors.Is(err, ErrReasonRequired) {
+		t.Errorf("Lift() without reason error = %v, want ErrReasonRequired", err)
+	}
+	d, err = m.Lift(ctx, "ds-1", "paper accepted")
+	if err != nil {
+		t.Fatalf("Lift() error = %v", err)
+	}
+	if d.Embargo != nil || d.Access != storage.AccessPublic {
+		t.Errorf("after Lift() embargo = %v, access = %s", d.Embargo, d.Access)
+	}
+	if got := dois["10.5555/ds-1"].Dates; got[0].Date != "2025-06-01" || got[0].DateInformation == "" {
+		t.Errorf("DOI dates after Lift() = %v, want early availability", got)
+	}
+
+	d, _ = datasets.Get(ctx, "ds-1")
+	var actions, reasons []string
+	for _, e := range d.History {
+		actions = append(actions, e.Action)
+		reasons = append(reasons, e.Reason)
+	}
+	if strings.Join(actions, " ") != "embargo.set embargo.extend embargo.lift" || reasons[1] != "patent filing" || reasons[2] != "paper accepted" {
+		t.Errorf("history = %+v", d.History)
+	}
+}
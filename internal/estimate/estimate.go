@@ -0,0 +1,208 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package estimate prices the resources a deployment leaves a stack
+// holding at AWS's on-demand prices, for a projected month of usage,
+// so that what an environment costs to run is known before it is
+// deployed.
+package estimate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/aperture/internal/deploy"
+	"github.com/scttfrdmn/aperture/internal/pricing"
+)
+
+// Assumptions about the work an API request does, where the
+// configuration does not say.
+const (
+	// requestSeconds is the time a function takes to answer a request
+	requestSeconds = 0.2
+
+	// readsPerRequest and writesPerRequest are the DynamoDB request
+	// units a request uses
+	readsPerRequest  = 2
+	writesPerRequest = 0.1
+
+	// hoursPerMonth is the average month, as AWS bills instances
+	hoursPerMonth = 730
+
+	// defaultMemoryMB is the memory of functions whose planned values
+	// leave it out, Lambda's default
+	defaultMemoryMB = 128
+)
+
+// Usage is the projected usage of a month.
+type Usage struct {
+	// StorageGB is the data the buckets hold
+	StorageGB float64
+
+	// TransferGB is the data CloudFront serves
+	TransferGB float64
+
+	// Requests is the number of API requests
+	Requests int
+
+	// SearchInstanceType and SearchInstances size an OpenSearch domain
+	// the stack does not create, but the repository indexes into; none
+	// if SearchInstances is zero. Domains the stack creates are sized
+	// by their planned values.
+	SearchInstanceType string
+	SearchInstances    int
+}
+
+// Line is a priced item of an estimate.
+type Line struct {
+	Service    string  `json:"service"`
+	Item       string  `json:"item"`
+	Quantity   float64 `json:"quantity"`
+	Unit       string  `json:"unit"`
+	UnitUSD    float64 `json:"unitUSD"`
+	MonthlyUSD float64 `json:"monthlyUSD"`
+}
+
+// Estimate is the projected monthly cost of a stack.
+type Estimate struct {
+	Region     string  `json:"region"`
+	Lines      []Line  `json:"lines"`
+	MonthlyUSD float64 `json:"monthlyUSD"`
+}
+
+// Services, by the resource types that incur their charges.
+var services = map[string]string{
+	"aws_s3_bucket":                  "S3",
+	"AWS::S3::Bucket":                "S3",
+	"aws_cloudfront_distribution":    "CloudFront",
+	"AWS::CloudFront::Distribution":  "CloudFront",
+	"aws_lambda_function":            "Lambda",
+	"AWS::Lambda::Function":          "Lambda",
+	"aws_dynamodb_table":             "DynamoDB",
+	"AWS::DynamoDB::Table":           "DynamoDB",
+	"aws_opensearch_domain":          "OpenSearch",
+	"aws_elasticsearch_domain":       "OpenSearch",
+	"AWS::OpenSearchService::Domain": "OpenSearch",
+}
+
+// estimator accumulates the lines of an estimate.
+type estimator struct {
+	client *pricing.Client
+	e      *Estimate
+}
+
+// add prices quantity of the product of code matching filters.
+func (est *estimator) add(ctx context.Context, service, item, code string, filters map[string]string, quantity float64) error {
+	p, err := est.client.Price(ctx, code, filters)
+	if err != nil {
+		return fmt.Errorf("failed to price %s %s: %w", service, item, err)
+	}
+	cost := quantity * p.USD
+	est.e.Lines = append(est.e.Lines, Line{Service: service, Item: item, Quantity: quantity, Unit: p.Unit, UnitUSD: p.USD, MonthlyUSD: cost})
+	est.e.MonthlyUSD += cost
+	return nil
+}
+
+// Monthly estimates the monthly cost in region of a stack holding
+// resources, for usage. Only the services the stack uses are priced:
+// S3 storage and reads, CloudFront transfer, Lambda requests and
+// compute, DynamoDB on-demand requests, and OpenSearch instances.
+// Usage is charged however many buckets, functions, or tables serve
+// it, so each service's usage is priced once.
+func Monthly(ctx context.Context, client *pricing.Client, region string, resources []deploy.Resource, usage Usage) (*Estimate, error) {
+	held := make(map[string][]deploy.Resource)
+	for _, r := range resources {
+		if service, ok := services[r.Type]; ok {
+			held[service] = append(held[service], r)
+		}
+	}
+	est := &estimator{client: client, e: &Estimate{Region: region, Lines: []Line{}}}
+	requests := float64(usage.Requests)
+
+	if len(held["S3"]) > 0 {
+		if err := est.add(ctx, "S3", "Standard storage", "AmazonS3",
+			map[string]string{"regionCode": region, "volumeType": "Standard", "storageClass": "General Purpose"}, usage.StorageGB); err != nil {
+			return nil, err
+		}
+		if err := est.add(ctx, "S3", "GET requests", "AmazonS3",
+			map[string]string{"regionCode": region, "group": "S3-API-Tier2"}, requests); err != nil {
+			return nil, err
+		}
+	}
+	if len(held["CloudFront"]) > 0 {
+		if err := est.add(ctx, "CloudFront", "Data transfer out", "AmazonCloudFront",
+			map[string]string{"transferType": "CloudFront Outbound", "fromLocation": "United States"}, usage.TransferGB); err != nil {
+			return nil, err
+		}
+	}
+	if fns := held["Lambda"]; len(fns) > 0 {
+		var memory float64
+		for _, fn := range fns {
+			mb, ok := fn.Values["memory_size"].(float64)
+			if !ok {
+				mb = defaultMemoryMB
+			}
+			memory += mb
+		}
+		gbSeconds := requests * requestSeconds * memory / float64(len(fns)) / 1024
+		if err := est.add(ctx, "Lambda", "Requests", "AWSLambda",
+			map[string]string{"regionCode": region, "group": "AWS-Lambda-Requests"}, requests); err != nil {
+			return nil, err
+		}
+		if err := est.add(ctx, "Lambda", "Compute", "AWSLambda",
+			map[string]string{"regionCode": region, "group": "AWS-Lambda-Duration"}, gbSeconds); err != nil {
+			return nil, err
+		}
+	}
+	if len(held["DynamoDB"]) > 0 {
+		if err := est.add(ctx, "DynamoDB", "On-demand reads", "AmazonDynamoDB",
+			map[string]string{"regionCode": region, "group": "DDB-ReadUnits"}, requests*readsPerRequest); err != nil {
+			return nil, err
+		}
+		if err := est.add(ctx, "DynamoDB", "On-demand writes", "AmazonDynamoDB",
+			map[string]string{"regionCode": region, "group": "DDB-WriteUnits"}, requests*writesPerRequest); err != nil {
+			return nil, err
+		}
+	}
+	domains := held["OpenSearch"]
+	if len(domains) == 0 && usage.SearchInstances > 0 {
+		domains = []deploy.Resource{{Address: "existing domain"}}
+	}
+	for _, d := range domains {
+		typ, n := searchCluster(d, usage)
+		if err := est.add(ctx, "OpenSearch", fmt.Sprintf("%d × %s (%s)", n, typ, d.Address), "AmazonES",
+			map[string]string{"regionCode": region, "instanceType": typ}, float64(n*hoursPerMonth)); err != nil {
+			return nil, err
+		}
+	}
+	return est.e, nil
+}
+
+// searchCluster returns the instance type and count of the data nodes
+// of domain, as planned or, if the plan leaves them out, as projected.
+func searchCluster(domain deploy.Resource, usage Usage) (string, int) {
+	typ, n := usage.SearchInstanceType, max(usage.SearchInstances, 1)
+	clusters, _ := domain.Values["cluster_config"].([]any)
+	if len(clusters) == 0 {
+		return typ, n
+	}
+	cluster, _ := clusters[0].(map[string]any)
+	if t, ok := cluster["instance_type"].(string); ok && t != "" {
+		typ = t
+	}
+	if count, ok := cluster["instance_count"].(float64); ok && count > 0 {
+		n = int(count)
+	}
+	return typ, n
+}
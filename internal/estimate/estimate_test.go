@@ -0,0 +1,140 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package estimate
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/deploy"
+	"github.com/scttfrdmn/aperture/internal/pricing"
+)
+
+// prices serves a Price List API answering each query with the price
+// of usd keyed by its service code and the value of its group,
+// instanceType, volumeType, or transferType filter.
+func prices(t *testing.T, usd map[string]float64) *pricing.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			ServiceCode string
+			Filters     []struct{ Field, Value string }
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		key := in.ServiceCode
+		for _, f := range in.Filters {
+			switch f.Field {
+			case "group", "instanceType", "volumeType", "transferType":
+				key += "/" + f.Value
+			}
+		}
+		price, ok := usd[key]
+		if !ok {
+			t.Errorf("unexpected price query %s", key)
+		}
+		entry, _ := json.Marshal(map[string]any{
+			"product": map[string]any{"productFamily": "x"},
+			"terms": map[string]any{"OnDemand": map[string]any{"T": map[string]any{"priceDimensions": map[string]any{
+				"D": map[string]any{"unit": "u", "beginRange": "0", "endRange": "Inf",
+					"pricePerUnit": map[string]string{"USD": strconv.FormatFloat(price, 'f', -1, 64)}},
+			}}}},
+		})
+		json.NewEncoder(w).Encode(map[string]any{"PriceList": []string{string(entry)}})
+	}))
+	t.Cleanup(srv.Close)
+	return pricing.NewClient(pricing.Options{Endpoint: srv.URL, Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}})
+}
+
+func TestMonthly(t *testing.T) {
+	client := prices(t, map[string]float64{
+		"AmazonS3/Standard":                    0.023,
+		"AmazonS3/S3-API-Tier2":                0.0000004,
+		"AmazonCloudFront/CloudFront Outbound": 0.085,
+		"AWSLambda/AWS-Lambda-Requests":        0.0000002,
+		"AWSLambda/AWS-Lambda-Duration":        0.0000166667,
+		"AmazonDynamoDB/DDB-ReadUnits":         0.00000025,
+		"AmazonDynamoDB/DDB-WriteUnits":        0.00000125,
+		"AmazonES/r6g.large.search":            0.167,
+	})
+	resources := []deploy.Resource{
+		{Address: "module.s3.aws_s3_bucket.public", Type: "aws_s3_bucket"},
+		{Address: "module.s3.aws_s3_bucket.private", Type: "aws_s3_bucket"},
+		{Address: "module.cdn.aws_cloudfront_distribution.media", Type: "aws_cloudfront_distribution"},
+		{Address: "module.fn.aws_lambda_function.auth", Type: "aws_lambda_function", Values: map[string]any{"memory_size": 512.0}},
+		{Address: "module.fn.aws_lambda_function.doi", Type: "aws_lambda_function", Values: map[string]any{"memory_size": 1536.0}},
+		{Address: "module.db.aws_dynamodb_table.users", Type: "aws_dynamodb_table"},
+		{Address: "aws_opensearch_domain.search", Type: "aws_opensearch_domain",
+			Values: map[string]any{"cluster_config": []any{map[string]any{"instance_type": "r6g.large.search", "instance_count": 3.0}}}},
+		{Address: "aws_iam_role.auth", Type: "aws_iam_role"},
+	}
+	usage := Usage{StorageGB: 1000, TransferGB: 500, Requests: 1_000_000, SearchInstanceType: "t3.small.search", SearchInstances: 1}
+	e, err := Monthly(context.Background(), client, "us-west-2", resources, usage)
+	if err != nil {
+		t.Fatalf("Monthly() error = %v", err)
+	}
+	got := make(map[string]float64)
+	var total float64
+	for _, l := range e.Lines {
+		got[l.Service+" "+l.Item] = l.Quantity
+		total += l.MonthlyUSD
+	}
+	want := map[string]float64{
+		"S3 Standard storage":          1000,
+		"S3 GET requests":              1_000_000,
+		"CloudFront Data transfer out": 500,
+		"Lambda Requests":              1_000_000,
+		// The average function's 1 GB for 0.2 s a request
+		"Lambda Compute":            200_000,
+		"DynamoDB On-demand reads":  2_000_000,
+		"DynamoDB On-demand writes": 100_000,
+		"OpenSearch 3 × r6g.large.search (aws_opensearch_domain.search)": 3 * 730,
+	}
+	if len(got) != len(want) {
+		t.Errorf("Monthly() lines = %v", got)
+	}
+	for item, q := range want {
+		if math.Abs(got[item]-q) > 1e-6 {
+			t.Errorf("%s = %v, want %v", item, got[item], q)
+		}
+	}
+	if math.Abs(e.MonthlyUSD-total) > 1e-9 || e.MonthlyUSD < 400 || e.Region != "us-west-2" {
+		t.Errorf("Monthly() = $%.2f in %s", e.MonthlyUSD, e.Region)
+	}
+}
+
+func TestMonthlyExistingSearchDomain(t *testing.T) {
+	// The stack holds no priced resources, but the repository indexes
+	// into a domain of its own.
+	client := prices(t, map[string]float64{"AmazonES/t3.small.search": 0.036})
+	e, err := Monthly(context.Background(), client, "us-east-1", []deploy.Resource{{Address: "AuditKey", Type: "AWS::KMS::Key"}},
+		Usage{SearchInstanceType: "t3.small.search", SearchInstances: 2})
+	if err != nil {
+		t.Fatalf("Monthly() error = %v", err)
+	}
+	if len(e.Lines) != 1 || e.Lines[0].Quantity != 2*730 || math.Abs(e.MonthlyUSD-2*730*0.036) > 1e-9 {
+		t.Errorf("Monthly() = %+v", e)
+	}
+
+	e, err = Monthly(context.Background(), client, "us-east-1", nil, Usage{})
+	if err != nil || len(e.Lines) != 0 || e.MonthlyUSD != 0 {
+		t.Errorf("Monthly() of nothing = %+v, %v", e, err)
+	}
+}
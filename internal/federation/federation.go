@@ -0,0 +1,133 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package federation supports signing in through institutional SAML
+// identity providers (Shibboleth, InCommon, eduGAIN) federated with the
+// Cognito user pool.
+package federation
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// SAML attribute names (OIDs) requested from identity providers and
+// mapped to user pool attributes.
+const (
+	// AttrEPPN is eduPersonPrincipalName, the campus login (jdoe@uni.edu)
+	AttrEPPN = "urn:oid:1.3.6.1.4.1.5923.1.1.1.6"
+
+	// AttrScopedAffiliation is eduPersonScopedAffiliation
+	// (faculty@uni.edu)
+	AttrScopedAffiliation = "urn:oid:1.3.6.1.4.1.5923.1.1.1.9"
+
+	// AttrMail is the mail attribute
+	AttrMail = "urn:oid:0.9.2342.19200300.100.1.3"
+
+	// AttrDisplayName is displayName
+	AttrDisplayName = "urn:oid:2.16.840.1.113730.3.1.241"
+)
+
+// Attribute is a SAML attribute the repository asks identity providers
+// to release.
+type Attribute struct {
+	// Name is the attribute OID
+	Name string
+
+	// FriendlyName is the conventional short name
+	FriendlyName string
+
+	// PoolAttribute is the user pool attribute it is mapped to
+	PoolAttribute string
+
+	// Required reports whether sign-in is useless without it
+	Required bool
+}
+
+// Attributes lists the attributes requested from identity providers,
+// matching the attribute mapping in the Terraform cognito module.
+var Attributes = []Attribute{
+	{Name: AttrMail, FriendlyName: "mail", PoolAttribute: "email", Required: true},
+	{Name: AttrDisplayName, FriendlyName: "displayName", PoolAttribute: "name"},
+	{Name: AttrEPPN, FriendlyName: "eduPersonPrincipalName", PoolAttribute: "custom:eppn", Required: true},
+	{Name: AttrScopedAffiliation, FriendlyName: "eduPersonScopedAffiliation", PoolAttribute: "custom:affiliation"},
+}
+
+// DefaultAffiliationGroups maps eduPersonAffiliation values to the
+// groups their holders join.
+var DefaultAffiliationGroups = map[string][]string{
+	"faculty":   {"researchers"},
+	"staff":     {"researchers"},
+	"employee":  {"researchers"},
+	"student":   {"users"},
+	"member":    {"users"},
+	"affiliate": {"users"},
+}
+
+// ServiceProvider describes the user pool as a SAML service provider,
+// as registered with an identity provider or federation.
+type ServiceProvider struct {
+	// EntityID is the SP entity ID (audience)
+	EntityID string
+
+	// ACSURL is the assertion consumer service endpoint
+	ACSURL string
+}
+
+// CognitoServiceProvider returns the SAML service provider for the user
+// pool poolID whose hosted UI serves authorizeURL (the OpenID
+// authorization endpoint, on the same host as the ACS).
+func CognitoServiceProvider(poolID, authorizeURL string) (*ServiceProvider, error) {
+	u, err := url.Parse(authorizeURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid authorization endpoint %q", authorizeURL)
+	}
+	return &ServiceProvider{
+		EntityID: "urn:amazon:cognito:sp:" + poolID,
+		ACSURL:   "https://" + u.Host + "/saml2/idpresponse",
+	}, nil
+}
+
+// ParseAffiliations splits an affiliation attribute value into
+// unscoped, lower-case affiliations. Cognito stores multi-valued SAML
+// attributes as a bracketed, comma-separated list ("[faculty@uni.edu,
+// member@uni.edu]").
+func ParseAffiliations(value string) []string {
+	value = strings.Trim(strings.TrimSpace(value), "[]")
+	var out []string
+	for _, v := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' || r == ' ' }) {
+		v, _, _ = strings.Cut(strings.ToLower(v), "@")
+		if v != "" && !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Groups returns the sorted groups that holders of affiliations join
+// under mapping.
+func Groups(affiliations []string, mapping map[string][]string) []string {
+	var out []string
+	for _, a := range affiliations {
+		for _, g := range mapping[a] {
+			if !slices.Contains(out, g) {
+				out = append(out, g)
+			}
+		}
+	}
+	slices.Sort(out)
+	return out
+}
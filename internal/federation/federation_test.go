@@ -0,0 +1,143 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package federation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseAffiliations(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"faculty@uni.edu", []string{"faculty"}},
+		{"[Faculty@uni.edu, member@uni.edu, faculty@med.uni.edu]", []string{"faculty", "member"}},
+		{"student;member", []string{"student", "member"}},
+	}
+	for _, tt := range tests {
+		if got := ParseAffiliations(tt.value); !slices.Equal(got, tt.want) {
+			t.Errorf("ParseAffiliations(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestGroups(t *testing.T) {
+	got := Groups([]string{"student", "staff", "member", "library-walk-in"}, DefaultAffiliationGroups)
+	if want := []string{"researchers", "users"}; !slices.Equal(got, want) {
+		t.Errorf("Groups() = %v, want %v", got, want)
+	}
+}
+
+func TestCognitoServiceProvider(t *testing.T) {
+	sp, err := CognitoServiceProvider("us-east-1_abc", "https://aperture-prod.auth.us-east-1.amazoncognito.com/oauth2/authorize")
+	if err != nil {
+		t.Fatalf("CognitoServiceProvider() error = %v", err)
+	}
+	if sp.EntityID != "urn:amazon:cognito:sp:us-east-1_abc" || sp.ACSURL != "https://aperture-prod.auth.us-east-1.amazoncognito.com/saml2/idpresponse" {
+		t.Errorf("CognitoServiceProvider() = %+v", sp)
+	}
+}
+
+// certificate returns a base64 DER certificate expiring at notAfter.
+func certificate(t *testing.T, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.uni.edu"},
+		NotBefore:    notAfter.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+const aggregate = `<?xml version="1.0"?>
+<md:EntitiesDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata"
+    xmlns:ds="http://www.w3.org/2000/09/xmldsig#"
+    xmlns:shibmd="urn:mace:shibboleth:metadata:1.0"
+    xmlns:mdui="urn:oasis:names:tc:SAML:metadata:ui">
+  <md:EntityDescriptor entityID="https://sp.other.edu/shibboleth">
+    <md:SPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol"/>
+  </md:EntityDescriptor>
+  <md:EntitiesDescriptor>
+    <md:EntityDescriptor entityID="https://idp.uni.edu/idp/shibboleth">
+      <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+        <md:Extensions>
+          <shibmd:Scope regexp="false">uni.edu</shibmd:Scope>
+          <mdui:UIInfo>
+            <mdui:DisplayName xml:lang="fr">Université</mdui:DisplayName>
+            <mdui:DisplayName xml:lang="en">State University</mdui:DisplayName>
+          </mdui:UIInfo>
+        </md:Extensions>
+        <md:KeyDescriptor use="signing">
+          <ds:KeyInfo><ds:X509Data><ds:X509Certificate>
+            %s
+          </ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+        </md:KeyDescriptor>
+        <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.uni.edu/idp/profile/SAML2/Redirect/SSO"/>
+      </md:IDPSSODescriptor>
+    </md:EntityDescriptor>
+    <md:EntityDescriptor entityID="https://idp.college.edu/idp">
+      <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol"/>
+    </md:EntityDescriptor>
+  </md:EntitiesDescriptor>
+</md:EntitiesDescriptor>`
+
+func TestFindIdP(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	data := []byte(fmt.Sprintf(aggregate, certificate(t, now.AddDate(0, 0, 10))))
+
+	if _, err := FindIdP(data, ""); !errors.Is(err, ErrNoIdP) {
+		t.Errorf("FindIdP() with two IdPs error = %v, want ErrNoIdP", err)
+	}
+	idp, err := FindIdP(data, "https://idp.uni.edu/idp/shibboleth")
+	if err != nil {
+		t.Fatalf("FindIdP() error = %v", err)
+	}
+	if idp.DisplayName != "State University" || !slices.Equal(idp.Scopes, []string{"uni.edu"}) || len(idp.Certificates) != 1 {
+		t.Errorf("FindIdP() = %+v", idp)
+	}
+	if got := idp.Check(now); len(got) != 1 {
+		t.Errorf("Check() = %v, want one expiry warning", got)
+	}
+
+	other, err := FindIdP(data, "https://idp.college.edu/idp")
+	if err != nil {
+		t.Fatalf("FindIdP() error = %v", err)
+	}
+	if got := other.Check(now); len(got) != 2 {
+		t.Errorf("Check() = %v, want missing endpoint and certificate", got)
+	}
+}
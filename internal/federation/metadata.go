@@ -0,0 +1,226 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package federation
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SAML bindings Cognito can use to send authentication requests.
+const (
+	BindingRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	BindingPOST     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+)
+
+// ErrNoIdP is returned when metadata describes no matching identity
+// provider.
+var ErrNoIdP = errors.New("no matching identity provider in metadata")
+
+// IdP is an identity provider described by SAML metadata.
+type IdP struct {
+	// EntityID identifies the identity provider
+	EntityID string `json:"entityId"`
+
+	// DisplayName is the provider's name for people, if published
+	DisplayName string `json:"displayName,omitempty"`
+
+	// Scopes are the domains the provider asserts scoped attributes for
+	Scopes []string `json:"scopes,omitempty"`
+
+	// SSO lists single sign-on endpoints by binding
+	SSO map[string]string `json:"sso"`
+
+	// Certificates are the provider's signing certificates
+	Certificates []*x509.Certificate `json:"-"`
+}
+
+// entitiesDescriptor and entityDescriptor are the subset of SAML
+// metadata used by Aperture. Aggregates (InCommon, eduGAIN) nest
+// EntityDescriptors in EntitiesDescriptors.
+type entitiesDescriptor struct {
+	Entities []entityDescriptor   `xml:"EntityDescriptor"`
+	Nested   []entitiesDescriptor `xml:"EntitiesDescriptor"`
+}
+
+type entityDescriptor struct {
+	EntityID string          `xml:"entityID,attr"`
+	IdP      *idpDescriptor  `xml:"IDPSSODescriptor"`
+	Org      []localizedName `xml:"Organization>OrganizationDisplayName"`
+}
+
+type idpDescriptor struct {
+	DisplayNames []localizedName `xml:"Extensions>UIInfo>DisplayName"`
+	Scopes       []string        `xml:"Extensions>Scope"`
+	Keys         []keyDescriptor `xml:"KeyDescriptor"`
+	SSO          []struct {
+		Binding  string `xml:"Binding,attr"`
+		Location string `xml:"Location,attr"`
+	} `xml:"SingleSignOnService"`
+}
+
+type localizedName struct {
+	Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Value string `xml:",chardata"`
+}
+
+type keyDescriptor struct {
+	Use         string `xml:"use,attr"`
+	Certificate string `xml:"KeyInfo>X509Data>X509Certificate"`
+}
+
+// ParseMetadata returns the identity providers described by a single
+// EntityDescriptor or an EntitiesDescriptor aggregate.
+func ParseMetadata(data []byte) ([]IdP, error) {
+	var root struct {
+		XMLName xml.Name
+		entityDescriptor
+		entitiesDescriptor
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse SAML metadata: %w", err)
+	}
+
+	var entities []entityDescriptor
+	switch root.XMLName.Local {
+	case "EntityDescriptor":
+		entities = []entityDescriptor{root.entityDescriptor}
+	case "EntitiesDescriptor":
+		entities = flatten(root.entitiesDescriptor)
+	default:
+		return nil, fmt.Errorf("failed to parse SAML metadata: unexpected root element %s", root.XMLName.Local)
+	}
+
+	var idps []IdP
+	for _, e := range entities {
+		if e.IdP == nil {
+			continue
+		}
+		idp, err := e.toIdP()
+		if err != nil {
+			return nil, err
+		}
+		idps = append(idps, *idp)
+	}
+	return idps, nil
+}
+
+// FindIdP returns the identity provider entityID from metadata, or the
+// only identity provider if entityID is empty.
+func FindIdP(data []byte, entityID string) (*IdP, error) {
+	idps, err := ParseMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+	if entityID == "" {
+		if len(idps) == 1 {
+			return &idps[0], nil
+		}
+		if len(idps) > 1 {
+			return nil, fmt.Errorf("%w: metadata describes %d identity providers; choose one by entity ID", ErrNoIdP, len(idps))
+		}
+		return nil, ErrNoIdP
+	}
+	for i := range idps {
+		if idps[i].EntityID == entityID {
+			return &idps[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoIdP, entityID)
+}
+
+func flatten(d entitiesDescriptor) []entityDescriptor {
+	out := d.Entities
+	for _, n := range d.Nested {
+		out = append(out, flatten(n)...)
+	}
+	return out
+}
+
+func (e *entityDescriptor) toIdP() (*IdP, error) {
+	idp := &IdP{
+		EntityID:    e.EntityID,
+		DisplayName: english(e.IdP.DisplayNames),
+		SSO:         make(map[string]string),
+	}
+	if idp.DisplayName == "" {
+		idp.DisplayName = english(e.Org)
+	}
+	for _, s := range e.IdP.Scopes {
+		if s = strings.TrimSpace(s); s != "" {
+			idp.Scopes = append(idp.Scopes, s)
+		}
+	}
+	for _, s := range e.IdP.SSO {
+		idp.SSO[s.Binding] = s.Location
+	}
+	for _, k := range e.IdP.Keys {
+		if k.Use == "encryption" || k.Certificate == "" {
+			continue
+		}
+		der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(k.Certificate), ""))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode certificate of %s: %w", e.EntityID, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate of %s: %w", e.EntityID, err)
+		}
+		idp.Certificates = append(idp.Certificates, cert)
+	}
+	return idp, nil
+}
+
+// english returns the English name, or the first name.
+func english(names []localizedName) string {
+	for _, n := range names {
+		if n.Lang == "en" {
+			return strings.TrimSpace(n.Value)
+		}
+	}
+	if len(names) > 0 {
+		return strings.TrimSpace(names[0].Value)
+	}
+	return ""
+}
+
+// Check returns the problems that would stop Cognito from federating
+// with idp at now: no usable single sign-on endpoint, or no signing
+// certificate valid for at least another 30 days. Certificate expiry
+// is not enforced by every IdP, but campus key rollovers that are
+// missed break sign-in for everyone.
+func (idp *IdP) Check(now time.Time) []string {
+	var problems []string
+	if idp.SSO[BindingRedirect] == "" && idp.SSO[BindingPOST] == "" {
+		problems = append(problems, "no HTTP-Redirect or HTTP-POST single sign-on endpoint")
+	}
+	if len(idp.Certificates) == 0 {
+		problems = append(problems, "no signing certificate")
+	}
+	for _, c := range idp.Certificates {
+		switch {
+		case now.After(c.NotAfter):
+			problems = append(problems, fmt.Sprintf("signing certificate %s expired on %s", c.Subject.CommonName, c.NotAfter.Format(time.DateOnly)))
+		case now.Add(30 * 24 * time.Hour).After(c.NotAfter):
+			problems = append(problems, fmt.Sprintf("signing certificate %s expires on %s", c.Subject.CommonName, c.NotAfter.Format(time.DateOnly)))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,719 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fixity verifies on a rolling schedule that stored objects
+// still hold the content their dataset manifests record.
+//
+// Each run verifies the objects whose last check is oldest, never
+// checked objects first, up to a limit, so that a daily run covers
+// the whole repository every Interval. An object is verified against
+// the SHA-256 checksum S3 stored with it when there is one, and by
+// reading it back and hashing it otherwise; a sample of objects with
+// stored checksums are read back too, to catch damage the stored
+// checksum would not. Objects whose manifests record a BLAKE3 digest
+// are read back with BLAKE3, which hashes large objects in parallel,
+// unless a check must use SHA-256 for an audit. The result of each
+// object's last check is kept, so corruption stays flagged until it is
+// repaired.
+package fixity
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/blake3"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/premis"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// Tables used in the state store.
+const (
+	resultsTable = "fixity"
+	runsTable    = "fixity-runs"
+	lastRunKey   = "last"
+)
+
+// DefaultInterval is how often each object is verified by default.
+const DefaultInterval = 90 * 24 * time.Hour
+
+// ObjectStore reads stored objects. *s3.Client implements it.
+type ObjectStore interface {
+	GetObjectAttributes(ctx context.Context, bucket, key string) (s3.Attributes, error)
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// EventLog records preservation events. *premis.Log implements it.
+type EventLog interface {
+	Record(ctx context.Context, events ...premis.Event) error
+}
+
+// Status is the outcome of an object's last check.
+type Status string
+
+// Statuses.
+const (
+	// StatusOK objects hold the recorded content
+	StatusOK Status = "ok"
+
+	// StatusCorrupt objects differ from the recorded size or digest
+	StatusCorrupt Status = "corrupt"
+
+	// StatusMissing objects no longer exist
+	StatusMissing Status = "missing"
+
+	// StatusUnverified objects could not be checked, e.g. because they
+	// are archived without a stored checksum or a request failed
+	StatusUnverified Status = "unverified"
+)
+
+// Failed reports whether s flags damage to the object.
+func (s Status) Failed() bool {
+	return s == StatusCorrupt || s == StatusMissing
+}
+
+// Method is how an object was verified.
+type Method string
+
+// Methods.
+const (
+	// MethodChecksum compares the checksum S3 stored with the object
+	MethodChecksum Method = "checksum"
+
+	// MethodRead reads the object back and hashes it
+	MethodRead Method = "read"
+
+	// MethodSize compares the size alone, for files recorded without a
+	// digest
+	MethodSize Method = "size"
+)
+
+// Algorithm is a digest algorithm objects are verified with.
+type Algorithm string
+
+// Algorithms.
+const (
+	DigestSHA256 Algorithm = "sha256"
+	DigestBLAKE3 Algorithm = "blake3"
+)
+
+// ParseAlgorithm returns the algorithm named s.
+func ParseAlgorithm(s string) (Algorithm, error) {
+	switch a := Algorithm(strings.ToLower(s)); a {
+	case DigestSHA256, DigestBLAKE3:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown digest algorithm %q: want sha256 or blake3", s)
+}
+
+// Ref is a dataset file stored in an object, or a preservation copy
+// of one made by Converter.
+type Ref struct {
+	Dataset   string `json:"dataset"`
+	Version   int    `json:"version"`
+	Path      string `json:"path"`
+	Converter string `json:"converter,omitempty"`
+}
+
+// Result is the last check of a stored object. Versions share
+// unchanged files, so one object may back several Refs.
+type Result struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+
+	// Size, SHA256, and BLAKE3 are the recorded size and hex digests
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+	BLAKE3 string `json:"blake3,omitempty"`
+
+	Refs []Ref `json:"refs"`
+
+	Status  Status    `json:"status"`
+	Method  Method    `json:"method,omitempty"`
+	Digest  Algorithm `json:"digest,omitempty"`
+	Checked time.Time `json:"checked"`
+
+	// Detail explains a failed or unverified check
+	Detail string `json:"detail,omitempty"`
+
+	// LastOK is when the object last verified
+	LastOK *time.Time `json:"lastOk,omitempty"`
+
+	// FailedSince is when the object was first found damaged
+	FailedSince *time.Time `json:"failedSince,omitempty"`
+
+	// Checks counts the object's checks
+	Checks int `json:"checks"`
+}
+
+// Options limit a run.
+type Options struct {
+	// Limit is the most objects to verify; 0 for no limit
+	Limit int
+
+	// MaxBytes is the most bytes to read back; once reached, only
+	// objects verifiable by stored checksum are verified; 0 for no
+	// limit
+	MaxBytes int64
+
+	// Dataset verifies the objects of one dataset, ID or persistent
+	// identifier, whether or not they are due
+	Dataset string
+}
+
+// Run summarizes a run.
+type Run struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+
+	Checked    int   `json:"checked"`
+	OK         int   `json:"ok"`
+	Corrupt    int   `json:"corrupt"`
+	Missing    int   `json:"missing"`
+	Unverified int   `json:"unverified"`
+	BytesRead  int64 `json:"bytesRead"`
+
+	// Backfilled counts objects whose BLAKE3 digests were recorded in
+	// their manifests
+	Backfilled int `json:"backfilled,omitempty"`
+
+	// Remaining counts objects due that the run did not reach
+	Remaining int `json:"remaining"`
+
+	// Failures are the objects found damaged that were not before
+	Failures []Result `json:"failures,omitempty"`
+}
+
+// Checker verifies stored objects.
+type Checker struct {
+	Objects  ObjectStore
+	Datasets *dataset.Store
+
+	// State holds the results of checks
+	State state.Store
+
+	// Interval is how old an object's last check must be for it to be
+	// due again; DefaultInterval if zero
+	Interval time.Duration
+
+	// Sample is the fraction of objects with stored checksums that are
+	// also read back
+	Sample float64
+
+	// Digest is the algorithm objects are read back with. Objects are
+	// read back with BLAKE3 when their manifests record a BLAKE3
+	// digest, and with SHA-256 otherwise, unless it is DigestSHA256
+	Digest Algorithm
+
+	// Backfill records in the manifests the BLAKE3 digest of objects
+	// read back with SHA-256 that verify and have none, so that later
+	// checks can use it
+	Backfill bool
+
+	// Events records a fixity check event for every object verified
+	// or found damaged; skipped if nil
+	Events EventLog
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// location identifies a stored object.
+type location struct{ bucket, key string }
+
+func (l location) id() string { return l.bucket + "/" + l.key }
+
+// Run verifies the objects that are due, oldest check first, within
+// opts, and records their results.
+func (c *Checker) Run(ctx context.Context, opts Options) (*Run, error) {
+	run := &Run{Started: c.now().UTC()}
+	objects, err := c.inventory(ctx, opts.Dataset)
+	if err != nil {
+		return nil, err
+	}
+	results, err := c.results(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := run.Started.Add(-cmp.Or(c.Interval, DefaultInterval))
+	var due []*Result
+	for loc, obj := range objects {
+		prev := results[loc]
+		if opts.Dataset == "" && prev != nil && prev.Checked.After(cutoff) {
+			continue
+		}
+		if prev != nil {
+			obj.Status, obj.Checked, obj.LastOK, obj.FailedSince, obj.Checks = prev.Status, prev.Checked, prev.LastOK, prev.FailedSince, prev.Checks
+		}
+		due = append(due, obj)
+	}
+	slices.SortFunc(due, func(a, b *Result) int {
+		return cmp.Or(a.Checked.Compare(b.Checked), cmp.Compare(a.Bucket, b.Bucket), cmp.Compare(a.Key, b.Key))
+	})
+
+	var backfill []*Result
+	for i, obj := range due {
+		if opts.Limit > 0 && run.Checked >= opts.Limit {
+			run.Remaining = len(due) - i
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		canRead := opts.MaxBytes == 0 || run.BytesRead+obj.Size <= opts.MaxBytes
+		prev, recorded := obj.Status, obj.BLAKE3
+		n, err := c.check(ctx, obj, canRead)
+		if err != nil {
+			return nil, err
+		}
+		if recorded == "" && obj.BLAKE3 != "" {
+			backfill = append(backfill, obj)
+		}
+		run.BytesRead += n
+		switch {
+		case obj.Status == StatusOK:
+			run.OK++
+		case obj.Status.Failed():
+			if !prev.Failed() {
+				run.Failures = append(run.Failures, *obj)
+			}
+			if obj.Status == StatusCorrupt {
+				run.Corrupt++
+			} else {
+				run.Missing++
+			}
+		default:
+			run.Unverified++
+		}
+		run.Checked++
+	}
+
+	if err := c.backfill(ctx, backfill); err != nil {
+		return nil, err
+	}
+	run.Backfilled = len(backfill)
+
+	if opts.Dataset == "" {
+		// Forget objects no manifest references any longer.
+		for loc := range results {
+			if _, ok := objects[loc]; !ok {
+				if err := c.State.Delete(ctx, resultsTable, loc.id()); err != nil {
+					return nil, fmt.Errorf("failed to forget fixity of s3://%s/%s: %w", loc.bucket, loc.key, err)
+				}
+			}
+		}
+	}
+	run.Finished = c.now().UTC()
+	if err := c.State.Put(ctx, runsTable, lastRunKey, run); err != nil {
+		return nil, fmt.Errorf("failed to record fixity run: %w", err)
+	}
+	return run, nil
+}
+
+// Recheck verifies the stored object at bucket/key now, whether or not
+// it is due, and records the result, e.g. to confirm a repair.
+func (c *Checker) Recheck(ctx context.Context, bucket, key string) (*Result, error) {
+	objects, err := c.inventory(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	loc := location{bucket, key}
+	obj := objects[loc]
+	if obj == nil {
+		return nil, fmt.Errorf("%w: no dataset stores s3://%s/%s", dataset.ErrNotFound, bucket, key)
+	}
+	var prev Result
+	err = c.State.Get(ctx, resultsTable, loc.id(), &prev)
+	switch {
+	case err == nil:
+		obj.Status, obj.Checked, obj.LastOK, obj.FailedSince, obj.Checks = prev.Status, prev.Checked, prev.LastOK, prev.FailedSince, prev.Checks
+	case !errors.Is(err, state.ErrNotFound):
+		return nil, fmt.Errorf("failed to read fixity of s3://%s/%s: %w", bucket, key, err)
+	}
+	if _, err := c.check(ctx, obj, true); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// check verifies obj, records its result and fixity check event, and
+// returns the number of bytes read.
+func (c *Checker) check(ctx context.Context, obj *Result, canRead bool) (int64, error) {
+	prev := obj.Status
+	n := c.verify(ctx, obj, canRead)
+	obj.Checked = c.now().UTC()
+	obj.Checks++
+	switch {
+	case obj.Status == StatusOK:
+		obj.LastOK, obj.FailedSince = &obj.Checked, nil
+	case obj.Status.Failed() && !prev.Failed():
+		obj.FailedSince = &obj.Checked
+	}
+	if err := c.event(ctx, obj); err != nil {
+		return n, err
+	}
+	if err := c.State.Put(ctx, resultsTable, location{obj.Bucket, obj.Key}.id(), obj); err != nil {
+		return n, fmt.Errorf("failed to record fixity of s3://%s/%s: %w", obj.Bucket, obj.Key, err)
+	}
+	return n, nil
+}
+
+// methodDetails describe the methods in fixity check events.
+var methodDetails = map[Method]string{
+	MethodChecksum: "SHA-256 compared with the checksum stored by S3",
+	MethodRead:     "SHA-256 of the object read back",
+	MethodSize:     "size compared with the manifest",
+}
+
+// event records the fixity check of obj, unless it was unverified.
+func (c *Checker) event(ctx context.Context, obj *Result) error {
+	if c.Events == nil || obj.Status == StatusUnverified {
+		return nil
+	}
+	e := premis.Event{
+		Type:          premis.TypeFixityCheck,
+		Time:          obj.Checked,
+		Bucket:        obj.Bucket,
+		Key:           obj.Key,
+		Detail:        methodDetails[obj.Method],
+		Outcome:       premis.OutcomeSuccess,
+		OutcomeDetail: obj.Detail,
+		Agent:         premis.SoftwareAgent,
+	}
+	if obj.Digest == DigestBLAKE3 {
+		e.Detail = "BLAKE3 of the object read back"
+	}
+	if obj.Status.Failed() {
+		e.Outcome = premis.OutcomeFailure
+	}
+	if obj.Status == StatusMissing {
+		e.OutcomeDetail = "object is missing"
+	}
+	return c.Events.Record(ctx, e)
+}
+
+// archived are the storage classes whose objects cannot be read
+// without a restore.
+var archived = []string{"GLACIER", "DEEP_ARCHIVE"}
+
+// verify checks obj, setting its status, method, and detail, and
+// returns the number of bytes read. Objects are read back only if
+// canRead is set.
+func (c *Checker) verify(ctx context.Context, obj *Result, canRead bool) int64 {
+	obj.Detail, obj.Digest = "", ""
+	attrs, err := c.Objects.GetObjectAttributes(ctx, obj.Bucket, obj.Key)
+	if errors.Is(err, s3.ErrNotFound) {
+		obj.Status, obj.Method = StatusMissing, ""
+		return 0
+	}
+	if err != nil {
+		obj.Status, obj.Method, obj.Detail = StatusUnverified, "", err.Error()
+		return 0
+	}
+	if attrs.ObjectSize != obj.Size {
+		obj.Status, obj.Method = StatusCorrupt, MethodSize
+		obj.Detail = fmt.Sprintf("size is %d bytes, want %d", attrs.ObjectSize, obj.Size)
+		return 0
+	}
+	if obj.SHA256 == "" && obj.BLAKE3 == "" {
+		obj.Status, obj.Method = StatusOK, MethodSize
+		return 0
+	}
+
+	stored := ""
+	if obj.SHA256 != "" {
+		stored = storedSHA256(attrs)
+	}
+	if stored != "" {
+		obj.Method, obj.Digest = MethodChecksum, DigestSHA256
+		if !strings.EqualFold(stored, obj.SHA256) {
+			obj.Status, obj.Detail = StatusCorrupt, "stored checksum is sha256:"+stored
+			return 0
+		}
+		obj.Status = StatusOK
+		if c.Sample <= 0 || rand.Float64() >= c.Sample || !canRead {
+			return 0
+		}
+	}
+	switch {
+	case slices.Contains(archived, attrs.StorageClass):
+		if stored == "" {
+			obj.Status, obj.Method, obj.Detail = StatusUnverified, "", "archived in "+attrs.StorageClass+" without a stored checksum"
+		}
+		return 0
+	case !canRead:
+		if stored == "" {
+			obj.Status, obj.Method, obj.Detail = StatusUnverified, "", "read limit reached"
+		}
+		return 0
+	}
+
+	body, err := c.Objects.GetObject(ctx, obj.Bucket, obj.Key)
+	if err != nil {
+		obj.Status, obj.Method, obj.Detail = StatusUnverified, "", err.Error()
+		return 0
+	}
+	defer body.Close()
+
+	// Hash with BLAKE3 alone when the manifest records its digest, and
+	// with SHA-256, and BLAKE3 too if backfilling, otherwise.
+	digest, want := DigestSHA256, obj.SHA256
+	if obj.BLAKE3 != "" && (c.Digest != DigestSHA256 || obj.SHA256 == "") {
+		digest, want = DigestBLAKE3, obj.BLAKE3
+	}
+	h, b3 := sha256.New(), blake3.New()
+	var w io.Writer = h
+	switch {
+	case digest == DigestBLAKE3:
+		h, w = b3, b3
+	case c.Backfill && obj.BLAKE3 == "":
+		w = io.MultiWriter(h, b3)
+	}
+	n, err := io.Copy(w, body)
+	if err != nil {
+		obj.Status, obj.Method, obj.Detail = StatusUnverified, "", err.Error()
+		return n
+	}
+	obj.Method, obj.Digest = MethodRead, digest
+	switch sum := hex.EncodeToString(h.Sum(nil)); {
+	case n != obj.Size:
+		obj.Status, obj.Detail = StatusCorrupt, fmt.Sprintf("read %d bytes, want %d", n, obj.Size)
+	case sum != strings.ToLower(want):
+		obj.Status, obj.Detail = StatusCorrupt, "content is "+string(digest)+":"+sum
+	default:
+		obj.Status = StatusOK
+		if digest == DigestSHA256 && c.Backfill && obj.BLAKE3 == "" {
+			obj.BLAKE3 = hex.EncodeToString(b3.Sum(nil))
+		}
+	}
+	return n
+}
+
+// backfill records the BLAKE3 digests of objs in the manifests of the
+// datasets referencing them.
+func (c *Checker) backfill(ctx context.Context, objs []*Result) error {
+	digests := make(map[string]map[location]string)
+	for _, obj := range objs {
+		for _, ref := range obj.Refs {
+			if digests[ref.Dataset] == nil {
+				digests[ref.Dataset] = make(map[location]string)
+			}
+			digests[ref.Dataset][location{obj.Bucket, obj.Key}] = obj.BLAKE3
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(digests)) {
+		d, err := c.Datasets.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		sums := digests[id]
+		for i := range d.Versions {
+			for j := range d.Versions[i].Files {
+				f := &d.Versions[i].Files[j]
+				f.BLAKE3 = cmp.Or(f.BLAKE3, sums[location{f.Bucket, f.Key}])
+				for k := range f.Derivatives {
+					dv := &f.Derivatives[k]
+					dv.BLAKE3 = cmp.Or(dv.BLAKE3, sums[location{dv.Bucket, dv.Key}])
+				}
+			}
+		}
+		if err := c.Datasets.Put(ctx, d); err != nil {
+			return fmt.Errorf("failed to record BLAKE3 digests of %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// storedSHA256 returns the hex SHA-256 digest S3 stored with an
+// object, or "" if it has none or only the checksum of a multipart
+// upload's parts.
+func storedSHA256(attrs s3.Attributes) string {
+	if attrs.Checksum.SHA256 == "" || strings.Contains(attrs.Checksum.SHA256, "-") {
+		return ""
+	}
+	sum, err := base64.StdEncoding.DecodeString(attrs.Checksum.SHA256)
+	if err != nil || len(sum) != sha256.Size {
+		return ""
+	}
+	return hex.EncodeToString(sum)
+}
+
+// inventory returns the objects backing the files, and their
+// preservation copies, of every dataset that is not tombstoned, or of
+// the dataset ref.
+func (c *Checker) inventory(ctx context.Context, ref string) (map[location]*Result, error) {
+	var datasets []*dataset.Dataset
+	if ref != "" {
+		d, err := c.Datasets.Resolve(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		datasets = []*dataset.Dataset{d}
+	} else {
+		var err error
+		if datasets, err = c.Datasets.List(ctx); err != nil {
+			return nil, err
+		}
+	}
+	objects := make(map[location]*Result)
+	add := func(bucket, key string, size int64, sum, b3 string, ref Ref) {
+		loc := location{bucket, key}
+		obj := objects[loc]
+		if obj == nil {
+			obj = &Result{Bucket: bucket, Key: key, Size: size}
+			objects[loc] = obj
+		}
+		obj.SHA256 = cmp.Or(obj.SHA256, sum)
+		obj.BLAKE3 = cmp.Or(obj.BLAKE3, b3)
+		obj.Refs = append(obj.Refs, ref)
+	}
+	for _, d := range datasets {
+		if d.State == dataset.StateTombstoned {
+			continue
+		}
+		for _, v := range d.Versions {
+			// Pruned files are deleted, or must be restored to be read.
+			if v.Pruned != nil {
+				continue
+			}
+			for _, f := range v.Files {
+				add(f.Bucket, f.Key, f.Size, f.SHA256, f.BLAKE3, Ref{Dataset: d.ID, Version: v.Number, Path: f.Path})
+				for _, dv := range f.Derivatives {
+					add(dv.Bucket, dv.Key, dv.Size, dv.SHA256, dv.BLAKE3, Ref{Dataset: d.ID, Version: v.Number, Path: f.Path, Converter: dv.Converter})
+				}
+			}
+		}
+	}
+	return objects, nil
+}
+
+// results returns the recorded results by object.
+func (c *Checker) results(ctx context.Context) (map[location]*Result, error) {
+	keys, err := c.State.Keys(ctx, resultsTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixity results: %w", err)
+	}
+	out := make(map[location]*Result, len(keys))
+	for _, k := range keys {
+		var r Result
+		if err := c.State.Get(ctx, resultsTable, k, &r); err != nil {
+			return nil, fmt.Errorf("failed to read fixity result %s: %w", k, err)
+		}
+		out[location{r.Bucket, r.Key}] = &r
+	}
+	return out, nil
+}
+
+// Report is the integrity of the repository: the outcome of the last
+// check of every stored object.
+type Report struct {
+	Generated time.Time `json:"generated"`
+
+	// Objects and Bytes count the stored objects of every dataset
+	Objects int   `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+
+	// Statuses counts objects by the status of their last check
+	Statuses map[Status]int `json:"statuses"`
+
+	// Unchecked counts objects never checked
+	Unchecked int `json:"unchecked"`
+
+	// Overdue counts objects whose last check is older than the
+	// interval
+	Overdue int `json:"overdue"`
+
+	// OldestCheck is the time of the least recent check
+	OldestCheck *time.Time `json:"oldestCheck,omitempty"`
+
+	// LastRun summarizes the last run, if any
+	LastRun *Run `json:"lastRun,omitempty"`
+
+	// Problems are the damaged and unverified objects, damaged first
+	Problems []Result `json:"problems"`
+}
+
+// Status returns the integrity report.
+func (c *Checker) Status(ctx context.Context) (*Report, error) {
+	objects, err := c.inventory(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	results, err := c.results(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := c.now().UTC()
+	rep := &Report{Generated: now, Statuses: make(map[Status]int), Problems: []Result{}}
+	cutoff := now.Add(-cmp.Or(c.Interval, DefaultInterval))
+	for loc, obj := range objects {
+		rep.Objects++
+		rep.Bytes += obj.Size
+		r := results[loc]
+		if r == nil {
+			rep.Unchecked++
+			continue
+		}
+		rep.Statuses[r.Status]++
+		if !r.Checked.After(cutoff) {
+			rep.Overdue++
+		}
+		if rep.OldestCheck == nil || r.Checked.Before(*rep.OldestCheck) {
+			rep.OldestCheck = &r.Checked
+		}
+		if r.Status != StatusOK {
+			r.Refs = obj.Refs
+			rep.Problems = append(rep.Problems, *r)
+		}
+	}
+	slices.SortFunc(rep.Problems, func(a, b Result) int {
+		if a.Status.Failed() != b.Status.Failed() {
+			if a.Status.Failed() {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(cmp.Compare(a.Bucket, b.Bucket), cmp.Compare(a.Key, b.Key))
+	})
+
+	var run Run
+	err = c.State.Get(ctx, runsTable, lastRunKey, &run)
+	switch {
+	case err == nil:
+		rep.LastRun = &run
+	case !errors.Is(err, state.ErrNotFound):
+		return nil, fmt.Errorf("failed to read last fixity run: %w", err)
+	}
+	return rep, nil
+}
+
+func (c *Checker) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
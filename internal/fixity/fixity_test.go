@@ -0,0 +1,281 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixity
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/blake3"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/premis"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// fakeObject is an object held by fakeStore.
+type fakeObject struct {
+	body         []byte
+	checksum     string
+	storageClass string
+}
+
+// fakeStore is an in-memory ObjectStore.
+type fakeStore struct {
+	objects map[string]fakeObject
+	reads   []string
+}
+
+func (f *fakeStore) GetObjectAttributes(_ context.Context, bucket, key string) (s3.Attributes, error) {
+	o, ok := f.objects[bucket+"/"+key]
+	if !ok {
+		return s3.Attributes{}, s3.ErrNotFound
+	}
+	a := s3.Attributes{ObjectSize: int64(len(o.body)), StorageClass: o.storageClass}
+	a.Checksum.SHA256 = o.checksum
+	return a, nil
+}
+
+func (f *fakeStore) GetObject(_ context.Context, bucket, key string) (io.ReadCloser, error) {
+	o, ok := f.objects[bucket+"/"+key]
+	if !ok {
+		return nil, s3.ErrNotFound
+	}
+	f.reads = append(f.reads, bucket+"/"+key)
+	return io.NopCloser(bytes.NewReader(o.body)), nil
+}
+
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func blake3Digest(b []byte) string {
+	sum := blake3.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	good, bad := []byte("good content"), []byte("bad content!")
+	file := func(path string, content []byte) dataset.File {
+		return dataset.File{Path: path, Bucket: "pub", Key: "ds-1/" + path, Size: int64(len(content)), SHA256: digest(content)}
+	}
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	err := datasets.Put(ctx, &dataset.Dataset{
+		ID: "ds-1",
+		Versions: []dataset.Version{
+			{Number: 1, Files: []dataset.File{file("a.csv", good)}},
+			{Number: 2, Files: []dataset.File{
+				file("a.csv", good),
+				file("summed.csv", good),
+				file("rotted.csv", good),
+				file("tampered.csv", good),
+				file("gone.csv", good),
+				file("frozen.csv", good),
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	objects := &fakeStore{objects: map[string]fakeObject{
+		"pub/ds-1/a.csv":        {body: good},
+		"pub/ds-1/summed.csv":   {body: good, checksum: checksum(good)},
+		"pub/ds-1/rotted.csv":   {body: bad},
+		"pub/ds-1/tampered.csv": {body: good, checksum: checksum(bad)},
+		"pub/ds-1/frozen.csv":   {body: good, storageClass: "DEEP_ARCHIVE"},
+	}}
+	c := &Checker{Objects: objects, Datasets: datasets, State: state.NewMemoryStore(), Now: func() time.Time { return now }}
+
+	run, err := c.Run(ctx, Options{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if run.Checked != 6 || run.OK != 2 || run.Corrupt != 2 || run.Missing != 1 || run.Unverified != 1 {
+		t.Errorf("Run() = %+v, want 6 checked: 2 ok, 2 corrupt, 1 missing, 1 unverified", run)
+	}
+	if len(run.Failures) != 3 {
+		t.Errorf("Failures = %d, want 3", len(run.Failures))
+	}
+	// Only the objects without stored checksums in readable storage
+	// are read back.
+	if len(objects.reads) != 2 || run.BytesRead != int64(2*len(good)) {
+		t.Errorf("reads = %v (%d bytes), want a.csv and rotted.csv", objects.reads, run.BytesRead)
+	}
+
+	rep, err := c.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if rep.Objects != 6 || rep.Unchecked != 0 || rep.Overdue != 0 || rep.LastRun == nil {
+		t.Errorf("Status() = %+v", rep)
+	}
+	if len(rep.Problems) != 4 || !rep.Problems[0].Status.Failed() || rep.Problems[3].Status != StatusUnverified {
+		t.Errorf("Problems = %+v, want 3 failures then 1 unverified", rep.Problems)
+	}
+	for _, p := range rep.Problems {
+		if p.Key == "ds-1/a.csv" {
+			t.Errorf("a.csv reported as a problem: %+v", p)
+		}
+	}
+
+	// Nothing is due again until the interval passes, and failures are
+	// reported only when first found.
+	if run, err = c.Run(ctx, Options{}); err != nil || run.Checked != 0 {
+		t.Errorf("second Run() = %+v, %v, want nothing checked", run, err)
+	}
+	now = now.Add(DefaultInterval + time.Hour)
+	run, err = c.Run(ctx, Options{Limit: 4})
+	if err != nil {
+		t.Fatalf("third Run() error = %v", err)
+	}
+	if run.Checked != 4 || run.Remaining != 2 || len(run.Failures) != 0 {
+		t.Errorf("third Run() = %+v, want 4 checked, 2 remaining, no new failures", run)
+	}
+
+	// Repairing an object clears its failure.
+	objects.objects["pub/ds-1/gone.csv"] = fakeObject{body: good}
+	run, err = c.Run(ctx, Options{Dataset: "ds-1"})
+	if err != nil {
+		t.Fatalf("Run(ds-1) error = %v", err)
+	}
+	if run.Checked != 6 || run.Missing != 0 {
+		t.Errorf("Run(ds-1) = %+v, want all 6 checked, none missing", run)
+	}
+	var r Result
+	if err := c.State.Get(ctx, resultsTable, "pub/ds-1/gone.csv", &r); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if r.Status != StatusOK || r.FailedSince != nil || r.LastOK == nil || r.Checks != 3 {
+		t.Errorf("repaired result = %+v", r)
+	}
+	if len(r.Refs) != 1 {
+		t.Errorf("Refs = %v, want 1", r.Refs)
+	}
+}
+
+func TestRunMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	body := []byte("0123456789")
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	err := datasets.Put(ctx, &dataset.Dataset{
+		ID: "ds-1",
+		Versions: []dataset.Version{{Number: 1, Files: []dataset.File{
+			{Path: "a", Bucket: "pub", Key: "a", Size: 10, SHA256: digest(body)},
+			{Path: "b", Bucket: "pub", Key: "b", Size: 10, SHA256: digest(body)},
+		}}},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	objects := &fakeStore{objects: map[string]fakeObject{"pub/a": {body: body}, "pub/b": {body: body}}}
+	events := &premis.Log{State: state.NewMemoryStore()}
+	c := &Checker{Objects: objects, Datasets: datasets, State: state.NewMemoryStore(), Events: events}
+
+	run, err := c.Run(ctx, Options{MaxBytes: 15})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if run.OK != 1 || run.Unverified != 1 || run.BytesRead != 10 {
+		t.Errorf("Run() = %+v, want 1 ok and 1 unverified after 10 bytes", run)
+	}
+
+	// Only the verified object has a fixity check event.
+	for key, want := range map[string]int{"a": 1, "b": 0} {
+		got, err := events.Events(ctx, "pub", key)
+		if err != nil {
+			t.Fatalf("Events() error = %v", err)
+		}
+		if len(got) != want || (want == 1 && (got[0].Type != premis.TypeFixityCheck || got[0].Outcome != premis.OutcomeSuccess)) {
+			t.Errorf("Events(%s) = %+v, want %d fixity checks", key, got, want)
+		}
+	}
+}
+
+func TestDigests(t *testing.T) {
+	ctx := context.Background()
+	good, bad := []byte("good content"), []byte("bad content!")
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	err := datasets.Put(ctx, &dataset.Dataset{
+		ID: "ds-1",
+		Versions: []dataset.Version{{Number: 1, Files: []dataset.File{
+			{Path: "fast.csv", Bucket: "pub", Key: "fast.csv", Size: 12, SHA256: digest(good), BLAKE3: blake3Digest(good)},
+			{Path: "old.csv", Bucket: "pub", Key: "old.csv", Size: 12, SHA256: digest(good)},
+		}}},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	objects := &fakeStore{objects: map[string]fakeObject{"pub/fast.csv": {body: good}, "pub/old.csv": {body: good}}}
+	events := &premis.Log{State: state.NewMemoryStore()}
+	c := &Checker{Objects: objects, Datasets: datasets, State: state.NewMemoryStore(), Events: events, Backfill: true}
+
+	run, err := c.Run(ctx, Options{Dataset: "ds-1"})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if run.OK != 2 || run.Backfilled != 1 {
+		t.Errorf("Run() = %+v, want 2 ok and 1 backfilled", run)
+	}
+	var r Result
+	if err := c.State.Get(ctx, resultsTable, "pub/fast.csv", &r); err != nil || r.Digest != DigestBLAKE3 {
+		t.Errorf("fast.csv result = %+v, %v, want read back with BLAKE3", r, err)
+	}
+	got, err := events.Events(ctx, "pub", "fast.csv")
+	if err != nil || len(got) != 1 || got[0].Detail != "BLAKE3 of the object read back" {
+		t.Errorf("Events(fast.csv) = %+v, %v", got, err)
+	}
+
+	// The backfilled digest is recorded, so the next check of the object
+	// uses BLAKE3, and damage is found with either algorithm.
+	d, err := datasets.Get(ctx, "ds-1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if f := d.Versions[0].Files[1]; f.BLAKE3 != blake3Digest(good) {
+		t.Errorf("old.csv BLAKE3 = %q, want backfilled", f.BLAKE3)
+	}
+	objects.objects["pub/old.csv"] = fakeObject{body: bad}
+	if run, err = c.Run(ctx, Options{Dataset: "ds-1"}); err != nil || run.OK != 1 || run.Corrupt != 1 || run.Backfilled != 0 {
+		t.Fatalf("second Run() = %+v, %v, want old.csv corrupt", run, err)
+	}
+	if f := run.Failures[0]; f.Digest != DigestBLAKE3 || f.Detail != "content is blake3:"+blake3Digest(bad) {
+		t.Errorf("failure = %+v, want found by BLAKE3", f)
+	}
+
+	// Audits can require SHA-256.
+	c.Digest = DigestSHA256
+	if run, err = c.Run(ctx, Options{Dataset: "ds-1"}); err != nil || run.Corrupt != 1 {
+		t.Fatalf("SHA-256 Run() = %+v, %v, want old.csv corrupt", run, err)
+	}
+	if err := c.State.Get(ctx, resultsTable, "pub/old.csv", &r); err != nil || r.Digest != DigestSHA256 || r.Detail != "content is sha256:"+digest(bad) {
+		t.Errorf("old.csv result = %+v, %v, want read back with SHA-256", r, err)
+	}
+}
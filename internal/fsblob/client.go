@@ -0,0 +1,511 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fsblob is a storage.Backend keeping objects as files under a
+// local directory, for pilot deployments in enclaves without network
+// access to a cloud or S3-compatible store. Each bucket is a
+// subdirectory of the root and each key a file path within it; the
+// content type, storage class, and SHA-256 digest of every object are
+// kept in a metadata file alongside, where fixity checks find the
+// digest. Presigned URLs are URLs of the Client's own HTTP handler,
+// signed with a shared secret, so that a lightweight file server takes
+// the place of S3 for downloads and browser uploads.
+package fsblob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// ErrNotFound is returned when an object does not exist. It is
+// s3.ErrNotFound, so that callers check for missing objects alike
+// whichever backend holds them.
+var ErrNotFound = s3.ErrNotFound
+
+// metaDir is the directory under the root holding the metadata files,
+// one tree per bucket.
+const metaDir = ".meta"
+
+// tmpPrefix prefixes the names of files being written, which listings
+// skip.
+const tmpPrefix = ".tmp-"
+
+// meta is the metadata of an object.
+type meta struct {
+	ContentType  string `json:"contentType,omitempty"`
+	StorageClass string `json:"storageClass,omitempty"`
+
+	// SHA256 is the base64-encoded digest of the content
+	SHA256 string `json:"sha256"`
+
+	RetainUntil *time.Time `json:"retainUntil,omitempty"`
+}
+
+// Options configures a Client.
+type Options struct {
+	// Root is the directory holding the buckets
+	Root string
+
+	// URL is the base URL the Client's handler is served at, which
+	// presigned URLs point to
+	URL string
+
+	// Secret signs presigned URLs
+	Secret string
+}
+
+// Client stores objects under a directory and serves its presigned
+// URLs.
+type Client struct {
+	root   string
+	url    *url.URL
+	secret []byte
+	now    func() time.Time
+}
+
+var _ storage.Backend = (*Client)(nil)
+
+// NewClient returns a client with the given options, creating the root
+// directory if needed.
+func NewClient(opts Options) (*Client, error) {
+	if opts.Root == "" {
+		return nil, errors.New("fsblob: no root directory")
+	}
+	if opts.Secret == "" {
+		return nil, errors.New("fsblob: no secret to sign URLs with")
+	}
+	u, err := url.Parse(strings.TrimRight(opts.URL, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("fsblob: invalid file server URL %q", opts.URL)
+	}
+	if err := os.MkdirAll(opts.Root, 0o700); err != nil {
+		return nil, fmt.Errorf("fsblob: %w", err)
+	}
+	return &Client{root: opts.Root, url: u, secret: []byte(opts.Secret), now: time.Now}, nil
+}
+
+// path returns the file of key in bucket, rejecting names that would
+// leave the bucket's directory.
+func (c *Client) path(bucket, key string) (string, error) {
+	if !validBucket(bucket) {
+		return "", fmt.Errorf("fsblob: invalid bucket name %q", bucket)
+	}
+	for seg := range strings.SplitSeq(key, "/") {
+		if seg == "" || seg == "." || seg == ".." || strings.HasPrefix(seg, tmpPrefix) || strings.Contains(seg, `\`) {
+			return "", fmt.Errorf("fsblob: invalid key %q", key)
+		}
+	}
+	return filepath.Join(c.root, bucket, filepath.FromSlash(key)), nil
+}
+
+// validBucket reports whether bucket names a directory of the root
+// other than metaDir.
+func validBucket(bucket string) bool {
+	return bucket != "" && !strings.HasPrefix(bucket, ".") && !strings.ContainsAny(bucket, `/\`)
+}
+
+// metaPath returns the metadata file of key in bucket.
+func (c *Client) metaPath(bucket, key string) string {
+	return filepath.Join(c.root, metaDir, bucket, filepath.FromSlash(key)+".json")
+}
+
+// PutObject stores body at key.
+func (c *Client) PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error {
+	_, err := c.Upload(ctx, bucket, key, bytes.NewReader(body), s3.PutOptions{ContentType: contentType})
+	return err
+}
+
+// Upload stores the content of r at key and returns the number of
+// bytes stored. The file is written under a temporary name and renamed,
+// so that readers never see a partial object. opts.StorageClass is
+// recorded but does not move the file; opts.RetainUntil makes
+// DeleteObject refuse to delete it until then.
+func (c *Client) Upload(ctx context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error) {
+	p, err := c.path(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	if m, err := c.meta(bucket, key); err == nil && m.RetainUntil != nil && c.now().Before(*m.RetainUntil) {
+		return 0, fmt.Errorf("fsblob: %s/%s is retained until %s", bucket, key, m.RetainUntil.Format(time.RFC3339))
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return 0, fmt.Errorf("fsblob: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), tmpPrefix+"*")
+	if err != nil {
+		return 0, fmt.Errorf("fsblob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	sum := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, sum), ctxReader{ctx, r})
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("fsblob: failed to write %s/%s: %w", bucket, key, err)
+	}
+
+	m := meta{ContentType: opts.ContentType, StorageClass: opts.StorageClass, SHA256: base64.StdEncoding.EncodeToString(sum.Sum(nil))}
+	if !opts.RetainUntil.IsZero() {
+		until := opts.RetainUntil.UTC()
+		m.RetainUntil = &until
+	}
+	if err := c.writeMeta(bucket, key, m); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return 0, fmt.Errorf("fsblob: failed to write %s/%s: %w", bucket, key, err)
+	}
+	return n, nil
+}
+
+// ctxReader fails reads once ctx is done, so that cancelling a request
+// stops an upload.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// meta returns the metadata of key in bucket, or ErrNotFound.
+func (c *Client) meta(bucket, key string) (meta, error) {
+	var m meta
+	b, err := os.ReadFile(c.metaPath(bucket, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, ErrNotFound
+	}
+	if err != nil {
+		return m, fmt.Errorf("fsblob: %w", err)
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return m, fmt.Errorf("fsblob: corrupt metadata of %s/%s: %w", bucket, key, err)
+	}
+	return m, nil
+}
+
+// writeMeta replaces the metadata of key in bucket.
+func (c *Client) writeMeta(bucket, key string, m meta) error {
+	p := c.metaPath(bucket, key)
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return fmt.Errorf("fsblob: %w", err)
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("fsblob: failed to write metadata of %s/%s: %w", bucket, key, err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return fmt.Errorf("fsblob: failed to write metadata of %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// GetObject returns the contents of an object. The caller must close
+// the returned reader.
+func (c *Client) GetObject(_ context.Context, bucket, key string) (io.ReadCloser, error) {
+	p, err := c.path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s/%s: %w", bucket, key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fsblob: %w", err)
+	}
+	return f, nil
+}
+
+// HeadObject returns the size, modification time, and metadata of an
+// object. Its ETag is the quoted hex SHA-256 digest.
+func (c *Client) HeadObject(_ context.Context, bucket, key string) (s3.ObjectInfo, error) {
+	p, err := c.path(bucket, key)
+	if err != nil {
+		return s3.ObjectInfo{}, err
+	}
+	st, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && st.IsDir() {
+		return s3.ObjectInfo{}, fmt.Errorf("%s/%s: %w", bucket, key, ErrNotFound)
+	}
+	if err != nil {
+		return s3.ObjectInfo{}, fmt.Errorf("fsblob: %w", err)
+	}
+	info := s3.ObjectInfo{Key: key, Size: st.Size(), LastModified: st.ModTime().UTC(), StorageClass: "STANDARD"}
+	m, err := c.meta(bucket, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return s3.ObjectInfo{}, err
+	}
+	info.ContentType = m.ContentType
+	if m.StorageClass != "" {
+		info.StorageClass = m.StorageClass
+	}
+	if b, err := base64.StdEncoding.DecodeString(m.SHA256); err == nil && len(b) > 0 {
+		info.ETag = `"` + hex.EncodeToString(b) + `"`
+	}
+	return info, nil
+}
+
+// GetObjectAttributes returns the size, storage class, and SHA-256
+// digest of an object without reading it. Files placed under the root
+// by other means have no digest.
+func (c *Client) GetObjectAttributes(ctx context.Context, bucket, key string) (s3.Attributes, error) {
+	info, err := c.HeadObject(ctx, bucket, key)
+	if err != nil {
+		return s3.Attributes{}, err
+	}
+	attrs := s3.Attributes{ETag: info.ETag, ObjectSize: info.Size, StorageClass: info.StorageClass}
+	if m, err := c.meta(bucket, key); err == nil {
+		attrs.Checksum.SHA256 = m.SHA256
+	}
+	return attrs, nil
+}
+
+// ListObjects calls fn for each object under prefix in bucket, in key
+// order.
+func (c *Client) ListObjects(ctx context.Context, bucket, prefix string, fn func(s3.ObjectInfo) error) error {
+	if !validBucket(bucket) {
+		return fmt.Errorf("fsblob: invalid bucket name %q", bucket)
+	}
+	dir := filepath.Join(c.root, bucket)
+	var keys []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tmpPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("fsblob: failed to list %s: %w", bucket, err)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := c.HeadObject(ctx, bucket, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyObject copies an object and its metadata.
+func (c *Client) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	info, err := c.HeadObject(ctx, srcBucket, srcKey)
+	if err != nil {
+		return err
+	}
+	body, err := c.GetObject(ctx, srcBucket, srcKey)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	_, err = c.Upload(ctx, dstBucket, dstKey, body, s3.PutOptions{ContentType: info.ContentType, StorageClass: info.StorageClass})
+	return err
+}
+
+// DeleteObject deletes an object and its metadata. Deleting a missing
+// object succeeds, as it does in S3; deleting a retained one fails.
+func (c *Client) DeleteObject(_ context.Context, bucket, key string) error {
+	p, err := c.path(bucket, key)
+	if err != nil {
+		return err
+	}
+	m, err := c.meta(bucket, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if m.RetainUntil != nil && c.now().Before(*m.RetainUntil) {
+		return fmt.Errorf("fsblob: %s/%s is retained until %s", bucket, key, m.RetainUntil.Format(time.RFC3339))
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("fsblob: %w", err)
+	}
+	if err := os.Remove(c.metaPath(bucket, key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("fsblob: %w", err)
+	}
+	return nil
+}
+
+// ListMultipartUploads returns no uploads: objects are written whole,
+// and the temporary files of interrupted uploads are removed as they
+// fail.
+func (c *Client) ListMultipartUploads(context.Context, string) ([]s3.Upload, error) {
+	return nil, nil
+}
+
+// AbortMultipartUpload does nothing, as there are no multipart uploads.
+func (c *Client) AbortMultipartUpload(context.Context, string, string, string) error {
+	return nil
+}
+
+// SetStorageClass records the storage class of an object. Files stay
+// where they are: the filesystem has one tier.
+func (c *Client) SetStorageClass(ctx context.Context, bucket, key, class string) error {
+	if _, err := c.HeadObject(ctx, bucket, key); err != nil {
+		return err
+	}
+	m, err := c.meta(bucket, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	m.StorageClass = class
+	return c.writeMeta(bucket, key, m)
+}
+
+// PresignGetObject returns a URL of the Client's handler that
+// downloads key from bucket until expires elapses.
+func (c *Client) PresignGetObject(bucket, key string, expires time.Duration) string {
+	return c.presign(http.MethodGet, bucket, key, expires)
+}
+
+// PresignPutObject returns a URL of the Client's handler that uploads
+// key to bucket until expires elapses.
+func (c *Client) PresignPutObject(bucket, key string, expires time.Duration) string {
+	return c.presign(http.MethodPut, bucket, key, expires)
+}
+
+// presign returns the URL of key signed for method.
+func (c *Client) presign(method, bucket, key string, expires time.Duration) string {
+	u := *c.url
+	u.Path = u.Path + "/" + bucket + "/" + key
+	u.RawPath = ""
+	exp := strconv.FormatInt(c.now().Add(expires).Unix(), 10)
+	u.RawQuery = url.Values{
+		"method":    {method},
+		"expires":   {exp},
+		"signature": {c.signature(method, bucket, key, exp)},
+	}.Encode()
+	return u.String()
+}
+
+// signature returns the hex HMAC-SHA256 of a presigned request.
+func (c *Client) signature(method, bucket, key, expires string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(method + "\n" + bucket + "/" + key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP serves the Client's presigned URLs under the path of its
+// URL: GET and HEAD download an object, with range requests, and PUT
+// uploads one. Requests without a valid, unexpired signature for their
+// method are refused.
+func (c *Client) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest, ok := strings.CutPrefix(r.URL.Path, c.url.Path+"/")
+	bucket, key, _ := strings.Cut(rest, "/")
+	if !ok || bucket == "" || key == "" {
+		http.NotFound(w, r)
+		return
+	}
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	q := r.URL.Query()
+	exp, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	switch {
+	case method != http.MethodGet && method != http.MethodPut:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	case q.Get("method") != method || err != nil ||
+		!hmac.Equal([]byte(q.Get("signature")), []byte(c.signature(method, bucket, key, q.Get("expires")))):
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	case c.now().Unix() > exp:
+		http.Error(w, "the URL has expired", http.StatusForbidden)
+		return
+	}
+
+	if method == http.MethodPut {
+		if _, err := c.Upload(r.Context(), bucket, key, r.Body, s3.PutOptions{ContentType: r.Header.Get("Content-Type")}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	info, err := c.HeadObject(r.Context(), bucket, key)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body, err := c.GetObject(r.Context(), bucket, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+	if info.ETag != "" {
+		w.Header().Set("ETag", info.ETag)
+	}
+	http.ServeContent(w, r, path.Base(key), info.LastModified, body.(io.ReadSeeker))
+}
@@ -0,0 +1,170 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsblob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/s3"
+)
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	c, err := NewClient(Options{Root: t.TempDir(), URL: "http://files.example/store", Secret: "s3cret"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return c
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	body := "id,temp\n1,14.2\n"
+	n, err := c.Upload(ctx, "ap-public-media", "datasets/ds-1/v1/a.csv", strings.NewReader(body), s3.PutOptions{ContentType: "text/csv", StorageClass: "STANDARD_IA"})
+	if err != nil || n != int64(len(body)) {
+		t.Fatalf("Upload() = %d, %v", n, err)
+	}
+	r, err := c.GetObject(ctx, "ap-public-media", "datasets/ds-1/v1/a.csv")
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	got, _ := io.ReadAll(r)
+	r.Close()
+	if string(got) != body {
+		t.Errorf("GetObject() = %q, want %q", got, body)
+	}
+
+	sum := sha256.Sum256([]byte(body))
+	attrs, err := c.GetObjectAttributes(ctx, "ap-public-media", "datasets/ds-1/v1/a.csv")
+	if err != nil {
+		t.Fatalf("GetObjectAttributes() error = %v", err)
+	}
+	if attrs.Checksum.SHA256 != base64.StdEncoding.EncodeToString(sum[:]) || attrs.ObjectSize != int64(len(body)) || attrs.StorageClass != "STANDARD_IA" {
+		t.Errorf("GetObjectAttributes() = %+v", attrs)
+	}
+
+	if err := c.CopyObject(ctx, "ap-public-media", "datasets/ds-1/v1/a.csv", "ap-public-media", "datasets/ds-1/v2/a.csv"); err != nil {
+		t.Fatalf("CopyObject() error = %v", err)
+	}
+	if err := c.SetStorageClass(ctx, "ap-public-media", "datasets/ds-1/v2/a.csv", "DEEP_ARCHIVE"); err != nil {
+		t.Fatalf("SetStorageClass() error = %v", err)
+	}
+	var listed []string
+	err = c.ListObjects(ctx, "ap-public-media", "datasets/ds-1/", func(o s3.ObjectInfo) error {
+		listed = append(listed, o.Key+" "+o.StorageClass)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ListObjects() error = %v", err)
+	}
+	if want := []string{"datasets/ds-1/v1/a.csv STANDARD_IA", "datasets/ds-1/v2/a.csv DEEP_ARCHIVE"}; strings.Join(listed, ",") != strings.Join(want, ",") {
+		t.Errorf("ListObjects() = %v, want %v", listed, want)
+	}
+
+	if err := c.DeleteObject(ctx, "ap-public-media", "datasets/ds-1/v1/a.csv"); err != nil {
+		t.Fatalf("DeleteObject() error = %v", err)
+	}
+	if _, err := c.HeadObject(ctx, "ap-public-media", "datasets/ds-1/v1/a.csv"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("HeadObject() after delete error = %v, want ErrNotFound", err)
+	}
+	if err := c.DeleteObject(ctx, "ap-public-media", "datasets/ds-1/v1/a.csv"); err != nil {
+		t.Errorf("DeleteObject() of a missing object error = %v", err)
+	}
+}
+
+func TestClientRejectsEscapingKeys(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	for _, loc := range [][2]string{{"ap", "../../etc/passwd"}, {"ap", "a//b"}, {".meta", "a"}, {"ap", "/abs"}} {
+		if err := c.PutObject(ctx, loc[0], loc[1], []byte("x"), ""); err == nil {
+			t.Errorf("PutObject(%q, %q) succeeded", loc[0], loc[1])
+		}
+	}
+}
+
+func TestClientRetention(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	until := time.Now().Add(time.Hour)
+	if _, err := c.Upload(ctx, "anchors", "2026/10/17.json", strings.NewReader("{}"), s3.PutOptions{RetainUntil: until}); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if err := c.DeleteObject(ctx, "anchors", "2026/10/17.json"); err == nil {
+		t.Error("DeleteObject() of a retained object succeeded")
+	}
+	c.now = func() time.Time { return until.Add(time.Second) }
+	if err := c.DeleteObject(ctx, "anchors", "2026/10/17.json"); err != nil {
+		t.Errorf("DeleteObject() after retention error = %v", err)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	local := func(u string) string { return strings.Replace(u, "http://files.example", srv.URL, 1) }
+
+	req, _ := http.NewRequest(http.MethodPut, local(c.PresignPutObject("ap-private-media", "up/b.txt", time.Minute)), strings.NewReader("hello"))
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("presigned PUT status = %d", resp.StatusCode)
+	}
+
+	get := c.PresignGetObject("ap-private-media", "up/b.txt", time.Minute)
+	resp, err = http.Get(local(get))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(got) != "hello" || resp.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("presigned GET = %d %q (%s)", resp.StatusCode, got, resp.Header.Get("Content-Type"))
+	}
+
+	for name, u := range map[string]string{
+		"other key":  strings.Replace(get, "b.txt", "c.txt", 1),
+		"PUT as GET": strings.Replace(get, "method=GET", "method=PUT", 1),
+		"expired":    c.PresignGetObject("ap-private-media", "up/b.txt", -time.Minute),
+	} {
+		resp, err := http.Get(local(u))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want 403", name, resp.StatusCode)
+		}
+	}
+	if _, err := c.HeadObject(ctx, "ap-private-media", "up/b.txt"); err != nil {
+		t.Errorf("HeadObject() of uploaded object error = %v", err)
+	}
+}
@@ -0,0 +1,652 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fsck checks a repository for inconsistencies between dataset
+// manifests, stored objects, DOI records, the DOI index, and landing
+// pages, along with access policy violations and metadata quality
+// issues, and reports them ranked by severity.
+package fsck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/gc"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/ror"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// Severity ranks findings.
+type Severity int
+
+// Severities, least severe first.
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+	SeverityCritical
+)
+
+var severityNames = []string{"info", "warning", "error", "critical"}
+
+// String returns the severity name.
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity parses a severity name.
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q (want info, warning, error, or critical)", name)
+}
+
+// FixLevel selects which findings are repaired.
+type FixLevel int
+
+// Fix levels. Safe fixes only rewrite derived state (the DOI index and
+// landing pages) and can always be re-run; FixAll also deletes orphaned
+// objects and aborts abandoned uploads.
+const (
+	FixNone FixLevel = iota
+	FixSafe
+	FixAll
+)
+
+var fixNames = []string{"none", "safe", "all"}
+
+// String returns the fix level name.
+func (l FixLevel) String() string {
+	if l < 0 || int(l) >= len(fixNames) {
+		return fmt.Sprintf("fix(%d)", int(l))
+	}
+	return fixNames[l]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l FixLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// ParseFixLevel parses a fix level name.
+func ParseFixLevel(name string) (FixLevel, error) {
+	for i, n := range fixNames {
+		if strings.EqualFold(name, n) {
+			return FixLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown fix level %q (want none, safe, or all)", name)
+}
+
+// Checks performed.
+const (
+	CheckManifest = "manifest"
+	CheckDOI      = "doi"
+	CheckIndex    = "index"
+	CheckLanding  = "landing"
+	CheckOrphan   = "orphan"
+	CheckPolicy   = "policy"
+	CheckMetadata = "metadata"
+)
+
+// Finding is a single problem.
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Check    string   `json:"check"`
+	Dataset  string   `json:"dataset,omitempty"`
+	Object   string   `json:"object,omitempty"`
+	Message  string   `json:"message"`
+
+	// Fix is the level that repairs the finding; FixNone if it needs
+	// manual attention
+	Fix FixLevel `json:"fix"`
+
+	// Fixed reports whether the finding was repaired in this run
+	Fixed bool `json:"fixed,omitempty"`
+
+	repair func(ctx context.Context) error
+}
+
+// Report is the result of a check.
+type Report struct {
+	Findings []Finding `json:"findings"`
+	Datasets int       `json:"datasets"`
+	Files    int       `json:"files"`
+}
+
+// Count returns the number of unfixed findings at or above min.
+func (r *Report) Count(min Severity) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Severity >= min && !f.Fixed {
+			n++
+		}
+	}
+	return n
+}
+
+// ObjectStore reads and cleans up stored objects.
+type ObjectStore interface {
+	gc.ObjectStore
+	HeadObject(ctx context.Context, bucket, key string) (s3.ObjectInfo, error)
+}
+
+// PageChecker inspects and repairs landing pages.
+type PageChecker interface {
+	Check(ctx context.Context, d *dataset.Dataset) (*landing.Change, error)
+	Publish(ctx context.Context, datasetID string) (*landing.Change, error)
+	Records(ctx context.Context) ([]landing.Record, error)
+	Forget(ctx context.Context, datasetID string) error
+}
+
+// DOIResolver looks up registered DOIs.
+type DOIResolver interface {
+	GetDOI(ctx context.Context, doi string) (*datacite.DOI, error)
+}
+
+// Checker runs consistency checks. Checks whose dependencies are nil
+// are skipped.
+type Checker struct {
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Objects is checked against manifests and scanned for orphans
+	Objects ObjectStore
+
+	// Buckets are scanned for orphans
+	Buckets []string
+
+	// Grace is the minimum age of orphans to report
+	Grace time.Duration
+
+	// Layout determines where each file should be stored
+	Layout storage.Layout
+
+	// Prefix and CloudPrefix prefix the names of the primary and cloud
+	// buckets of hybrid tiering; files tiered to the cloud belong in
+	// the cloud bucket of their layout location. Unset without tiering
+	Prefix      string
+	CloudPrefix string
+
+	// Pages checks landing pages
+	Pages PageChecker
+
+	// DOIs checks DataCite registration
+	DOIs DOIResolver
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Run checks the repository and applies fixes up to level fix.
+func (c *Checker) Run(ctx context.Context, fix FixLevel) (*Report, error) {
+	datasets, err := c.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{Datasets: len(datasets)}
+	for _, d := range datasets {
+		c.checkMetadata(r, d)
+		c.checkPolicy(r, d)
+		if c.Objects != nil {
+			if err := c.checkFiles(ctx, r, d); err != nil {
+				return nil, err
+			}
+		}
+		if c.DOIs != nil {
+			if err := c.checkDOIs(ctx, r, d); err != nil {
+				return nil, err
+			}
+		}
+		if c.Pages != nil {
+			if err := c.checkPage(ctx, r, d); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := c.checkIndex(ctx, r, datasets); err != nil {
+		return nil, err
+	}
+	if c.Pages != nil {
+		if err := c.checkPageRecords(ctx, r, datasets); err != nil {
+			return nil, err
+		}
+	}
+
+	var orphans *gc.Report
+	if c.Objects != nil && len(c.Buckets) > 0 {
+		if orphans, err = c.checkOrphans(ctx, r); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		a, b := r.Findings[i], r.Findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		if a.Check != b.Check {
+			return a.Check < b.Check
+		}
+		return a.Dataset < b.Dataset
+	})
+
+	if fix == FixNone {
+		return r, nil
+	}
+	return r, c.repair(ctx, r, fix, orphans)
+}
+
+// repair applies the fixes at or below level. Orphans are removed in a
+// single garbage collection pass, which re-reads manifests first.
+func (c *Checker) repair(ctx context.Context, r *Report, level FixLevel, orphans *gc.Report) error {
+	var errs []error
+	for i := range r.Findings {
+		f := &r.Findings[i]
+		if f.Fix == FixNone || f.Fix > level || f.repair == nil {
+			continue
+		}
+		if err := f.repair(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to fix %s: %w", f.Message, err))
+			continue
+		}
+		f.Fixed = true
+	}
+
+	if level >= FixAll && orphans != nil && len(orphans.Orphans)+len(orphans.Uploads) > 0 {
+		collector := &gc.Collector{Objects: c.Objects, Datasets: c.Datasets, Grace: c.Grace, Now: c.Now}
+		if _, err := collector.Apply(ctx, orphans); err != nil {
+			errs = append(errs, err)
+		} else {
+			for i := range r.Findings {
+				if r.Findings[i].Check == CheckOrphan {
+					r.Findings[i].Fixed = true
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Checker) add(r *Report, f Finding) {
+	r.Findings = append(r.Findings, f)
+}
+
+func (c *Checker) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// checkMetadata reports missing or malformed descriptive metadata.
+func (c *Checker) checkMetadata(r *Report, d *dataset.Dataset) {
+	published := d.State == dataset.StatePublished
+	issue := func(sev Severity, format string, args ...any) {
+		c.add(r, Finding{Severity: sev, Check: CheckMetadata, Dataset: d.ID, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if strings.TrimSpace(d.Title) == "" {
+		issue(SeverityError, "dataset has no title")
+	}
+	if len(d.Creators) == 0 {
+		issue(SeverityWarning, "dataset has no creators")
+	}
+	for _, cr := range d.Creators {
+		if cr.ORCID != "" && !identity.ValidORCID(cr.ORCID) {
+			issue(SeverityWarning, "creator %s has an invalid ORCID iD %s", cr.Name, cr.ORCID)
+		}
+		if cr.AffiliationROR != "" && !ror.Valid(cr.AffiliationROR) {
+			issue(SeverityWarning, "creator %s has an invalid affiliation ROR ID %s", cr.Name, cr.AffiliationROR)
+		}
+	}
+	if published && d.PublicationYear == 0 {
+		issue(SeverityWarning, "published dataset has no publication year")
+	}
+	if published && d.DOI == "" && d.ARK == "" && d.Handle == "" {
+		issue(SeverityWarning, "published dataset has no persistent identifier")
+	}
+	if strings.TrimSpace(d.Description) == "" {
+		issue(SeverityInfo, "dataset has no description")
+	}
+	if published && len(d.Versions) == 0 {
+		issue(SeverityError, "published dataset has no versions")
+	}
+	for _, v := range d.Versions {
+		seen := make(map[string]bool)
+		for _, f := range v.Files {
+			if seen[f.Path] {
+				issue(SeverityError, "version %d lists %s more than once", v.Number, f.Path)
+			}
+			seen[f.Path] = true
+		}
+	}
+}
+
+// checkPolicy reports files stored outside the location required by
+// the dataset's access level, overdue embargoes, and malformed access
+// control entries.
+func (c *Checker) checkPolicy(r *Report, d *dataset.Dataset) {
+	if d.Embargo != nil && !d.Embargoed(c.now()) {
+		c.add(r, Finding{
+			Severity: SeverityWarning,
+			Check:    CheckPolicy,
+			Dataset:  d.ID,
+			Message:  fmt.Sprintf("embargo ended %s but has not been released", d.Embargo.Until.Format(time.DateOnly)),
+		})
+	}
+	if d.Access == storage.AccessEmbargoed && d.Embargo == nil {
+		c.add(r, Finding{
+			Severity: SeverityError,
+			Check:    CheckPolicy,
+			Dataset:  d.ID,
+			Message:  "dataset is in the embargoed bucket but has no embargo date",
+		})
+	}
+	if d.ACL != nil {
+		for _, e := range append(d.ACL.Read, d.ACL.Manage...) {
+			if _, err := authz.ParseEntry(e); err != nil {
+				c.add(r, Finding{Severity: SeverityError, Check: CheckPolicy, Dataset: d.ID, Message: err.Error()})
+			}
+		}
+	}
+	if d.Restriction != nil {
+		if _, err := d.Restriction.Normalize(); err != nil {
+			c.add(r, Finding{Severity: SeverityError, Check: CheckPolicy, Dataset: d.ID, Message: "restriction: " + err.Error()})
+		}
+	}
+	if c.Layout == nil || !d.Access.Valid() {
+		return
+	}
+
+	for _, v := range d.Versions {
+		if v.Removed() {
+			continue
+		}
+		for _, f := range v.Files {
+			obj := storage.Object{Dataset: d.ID, Version: v.Number, File: f.Path, Collection: d.Collection, Access: d.Access, SHA256: f.SHA256}
+			want, err := c.locate(obj, f)
+			if err != nil {
+				continue
+			}
+			got := storage.Location{Bucket: f.Bucket, Key: f.Key}
+			if got == want {
+				continue
+			}
+			sev := SeverityError
+			msg := fmt.Sprintf("%s is stored at %s, want %s", f.Path, got, want)
+			obj.Access = storage.AccessPublic
+			if public, err := c.locate(obj, f); err == nil && got == public && d.Access != storage.AccessPublic {
+				sev = SeverityCritical
+				msg = fmt.Sprintf("%s dataset file %s is in the public location", d.Access, f.Path)
+			}
+			c.add(r, Finding{Severity: sev, Check: CheckPolicy, Dataset: d.ID, Object: got.String(), Message: msg})
+		}
+	}
+}
+
+// locate returns where the layout stores obj, in the cloud bucket of
+// its location if f was tiered to the cloud.
+func (c *Checker) locate(obj storage.Object, f dataset.File) (storage.Location, error) {
+	loc, err := c.Layout.Locate(obj)
+	if err != nil || f.Tier != dataset.TierCloud || c.CloudPrefix == "" {
+		return loc, err
+	}
+	if bucket, ok := storage.CloudBucket(c.Prefix, c.CloudPrefix, loc.Bucket); ok {
+		loc.Bucket = bucket
+	}
+	return loc, nil
+}
+
+// checkFiles verifies that every manifest entry exists with the
+// recorded size.
+func (c *Checker) checkFiles(ctx context.Context, r *Report, d *dataset.Dataset) error {
+	for _, v := range d.Versions {
+		if v.Removed() {
+			continue
+		}
+		for _, f := range v.Files {
+			r.Files++
+			loc := storage.Location{Bucket: f.Bucket, Key: f.Key}
+			info, err := c.Objects.HeadObject(ctx, f.Bucket, f.Key)
+			if errors.Is(err, s3.ErrNotFound) {
+				c.add(r, Finding{
+					Severity: SeverityCritical,
+					Check:    CheckManifest,
+					Dataset:  d.ID,
+					Object:   loc.String(),
+					Message:  fmt.Sprintf("version %d file %s is missing from storage", v.Number, f.Path),
+				})
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to check %s: %w", loc, err)
+			}
+			if f.Size != 0 && info.Size != f.Size {
+				c.add(r, Finding{
+					Severity: SeverityError,
+					Check:    CheckManifest,
+					Dataset:  d.ID,
+					Object:   loc.String(),
+					Message:  fmt.Sprintf("version %d file %s is %d bytes, manifest says %d", v.Number, f.Path, info.Size, f.Size),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// checkDOIs verifies that the DOIs of published datasets are
+// registered and findable.
+func (c *Checker) checkDOIs(ctx context.Context, r *Report, d *dataset.Dataset) error {
+	if d.State != dataset.StatePublished {
+		return nil
+	}
+	for _, doi := range d.DOIs() {
+		rec, err := c.DOIs.GetDOI(ctx, doi)
+		var apiErr *datacite.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == 404 {
+			c.add(r, Finding{
+				Severity: SeverityCritical,
+				Check:    CheckDOI,
+				Dataset:  d.ID,
+				Message:  fmt.Sprintf("DOI %s is not registered with DataCite", doi),
+			})
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to look up DOI %s: %w", doi, err)
+		}
+		if rec.Attributes.State != "" && rec.Attributes.State != "findable" {
+			c.add(r, Finding{
+				Severity: SeverityError,
+				Check:    CheckDOI,
+				Dataset:  d.ID,
+				Message:  fmt.Sprintf("DOI %s of a published dataset is %s, not findable", doi, rec.Attributes.State),
+			})
+		}
+	}
+	return nil
+}
+
+// checkIndex compares the DOI index with the DOIs datasets declare.
+func (c *Checker) checkIndex(ctx context.Context, r *Report, datasets []*dataset.Dataset) error {
+	index, err := c.Datasets.DOIIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	declared := make(map[string]string)
+	for _, d := range datasets {
+		for _, doi := range d.DOIs() {
+			doi = dataset.NormalizeDOI(doi)
+			if other, ok := declared[doi]; ok {
+				c.add(r, Finding{
+					Severity: SeverityCritical,
+					Check:    CheckIndex,
+					Dataset:  d.ID,
+					Message:  fmt.Sprintf("DOI %s is also claimed by %s", doi, other),
+				})
+				continue
+			}
+			declared[doi] = d.ID
+			if index[doi] == d.ID {
+				continue
+			}
+			id := d.ID
+			c.add(r, Finding{
+				Severity: SeverityError,
+				Check:    CheckIndex,
+				Dataset:  id,
+				Message:  fmt.Sprintf("DOI %s is not indexed to %s", doi, id),
+				Fix:      FixSafe,
+				repair: func(ctx context.Context) error {
+					d, err := c.Datasets.Get(ctx, id)
+					if err != nil {
+						return err
+					}
+					return c.Datasets.Put(ctx, d)
+				},
+			})
+		}
+	}
+
+	for doi, id := range index {
+		if _, ok := declared[doi]; ok {
+			continue
+		}
+		c.add(r, Finding{
+			Severity: SeverityWarning,
+			Check:    CheckIndex,
+			Dataset:  id,
+			Message:  fmt.Sprintf("index entry for DOI %s points to a dataset that does not declare it", doi),
+			Fix:      FixSafe,
+			repair:   func(ctx context.Context) error { return c.Datasets.Unindex(ctx, doi) },
+		})
+	}
+	return nil
+}
+
+// checkPage reports published datasets whose landing page is missing
+// or stale.
+func (c *Checker) checkPage(ctx context.Context, r *Report, d *dataset.Dataset) error {
+	if d.State != dataset.StatePublished {
+		return nil
+	}
+	change, err := c.Pages.Check(ctx, d)
+	if err != nil {
+		return err
+	}
+	if change == nil {
+		return nil
+	}
+	sev, msg := SeverityInfo, fmt.Sprintf("landing page is stale (%s changed)", change.Reason)
+	if change.Reason == landing.ReasonNew {
+		sev, msg = SeverityError, "landing page has never been published"
+	}
+	id := d.ID
+	c.add(r, Finding{
+		Severity: sev,
+		Check:    CheckLanding,
+		Dataset:  id,
+		Object:   change.Path,
+		Message:  msg,
+		Fix:      FixSafe,
+		repair: func(ctx context.Context) error {
+			_, err := c.Pages.Publish(ctx, id)
+			return err
+		},
+	})
+	return nil
+}
+
+// checkPageRecords reports landing pages tracked for datasets that no
+// longer exist or are not published.
+func (c *Checker) checkPageRecords(ctx context.Context, r *Report, datasets []*dataset.Dataset) error {
+	records, err := c.Pages.Records(ctx)
+	if err != nil {
+		return err
+	}
+	published := make(map[string]bool)
+	for _, d := range datasets {
+		published[d.ID] = d.State == dataset.StatePublished
+	}
+	for _, rec := range records {
+		if published[rec.DatasetID] {
+			continue
+		}
+		id := rec.DatasetID
+		c.add(r, Finding{
+			Severity: SeverityWarning,
+			Check:    CheckLanding,
+			Dataset:  id,
+			Object:   landing.PagePath(id),
+			Message:  "landing page is tracked for a dataset that is not published",
+			Fix:      FixSafe,
+			repair:   func(ctx context.Context) error { return c.Pages.Forget(ctx, id) },
+		})
+	}
+	return nil
+}
+
+// checkOrphans scans the buckets for unreferenced objects and
+// abandoned uploads.
+func (c *Checker) checkOrphans(ctx context.Context, r *Report) (*gc.Report, error) {
+	collector := &gc.Collector{Objects: c.Objects, Datasets: c.Datasets, Grace: c.Grace, Now: c.Now}
+	plan, err := collector.Plan(ctx, c.Buckets)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range plan.Orphans {
+		c.add(r, Finding{
+			Severity: SeverityInfo,
+			Check:    CheckOrphan,
+			Object:   storage.Location{Bucket: o.Bucket, Key: o.Key}.String(),
+			Message:  fmt.Sprintf("object is not referenced by any manifest (%d bytes)", o.Size),
+			Fix:      FixAll,
+		})
+	}
+	for _, u := range plan.Uploads {
+		c.add(r, Finding{
+			Severity: SeverityInfo,
+			Check:    CheckOrphan,
+			Object:   storage.Location{Bucket: u.Bucket, Key: u.Key}.String(),
+			Message:  fmt.Sprintf("multipart upload %s was started %s and never completed", u.UploadID, u.Initiated.Format(time.DateOnly)),
+			Fix:      FixAll,
+		})
+	}
+	return plan, nil
+}
@@ -0,0 +1,228 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsck
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// fakeObjects is an in-memory ObjectStore keyed by bucket then key.
+type fakeObjects map[string]map[string]s3.ObjectInfo
+
+func (f fakeObjects) ListObjects(_ context.Context, bucket, _ string, fn func(s3.ObjectInfo) error) error {
+	for _, o := range f[bucket] {
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f fakeObjects) HeadObject(_ context.Context, bucket, key string) (s3.ObjectInfo, error) {
+	o, ok := f[bucket][key]
+	if !ok {
+		return s3.ObjectInfo{}, s3.ErrNotFound
+	}
+	return o, nil
+}
+
+func (f fakeObjects) DeleteObject(_ context.Context, bucket, key string) error {
+	delete(f[bucket], key)
+	return nil
+}
+
+func (f fakeObjects) ListMultipartUploads(context.Context, string) ([]s3.Upload, error) {
+	return nil, nil
+}
+
+func (f fakeObjects) AbortMultipartUpload(context.Context, string, string, string) error {
+	return nil
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) PutObject(context.Context, string, string, []byte, string) error { return nil }
+
+func (nopPublisher) DeleteObject(context.Context, string, string) error { return nil }
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+
+	file := func(bucket, key string, size int64) dataset.File {
+		return dataset.File{Path: "a.csv", Bucket: bucket, Key: key, Size: size}
+	}
+	for _, d := range []*dataset.Dataset{
+		{
+			ID: "good", DOI: "10.5555/good", Title: "Good", Description: "Fine.", PublicationYear: 2025,
+			Creators: []dataset.Creator{{Name: "A", ORCID: "0000-0002-1825-0097"}},
+			Access:   storage.AccessPublic, State: dataset.StatePublished,
+			Versions: []dataset.Version{{Number: 1, Files: []dataset.File{file("ap-public-media", "datasets/good/v1/a.csv", 10)}}},
+		},
+		{
+			ID: "leaky", DOI: "10.5555/leaky", Title: "Leaky", Description: "Restricted.", PublicationYear: 2025,
+			Creators: []dataset.Creator{{Name: "B", ORCID: "0000-0002-1825-0098"}},
+			Access:   storage.AccessRestricted, State: dataset.StatePublished,
+			Versions: []dataset.Version{{Number: 1, Files: []dataset.File{file("ap-public-media", "datasets/leaky/v1/a.csv", 99)}}},
+		},
+		{
+			ID: "lost", Title: "", Access: storage.AccessPublic, State: dataset.StateDraft,
+			Versions: []dataset.Version{{Number: 1, Files: []dataset.File{file("ap-public-media", "datasets/lost/v1/a.csv", 5)}}},
+		},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	// A stale index entry and a missing one.
+	if err := s.Put(ctx, "dataset-dois", "10.5555/gone", "gone"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := datasets.Unindex(ctx, "10.5555/good"); err != nil {
+		t.Fatalf("Unindex() error = %v", err)
+	}
+
+	objects := fakeObjects{"ap-public-media": {
+		"datasets/good/v1/a.csv":  {Key: "datasets/good/v1/a.csv", Size: 10, LastModified: old},
+		"datasets/leaky/v1/a.csv": {Key: "datasets/leaky/v1/a.csv", Size: 10, LastModified: old},
+		"datasets/junk/v1/x.bin":  {Key: "datasets/junk/v1/x.bin", Size: 7, LastModified: old},
+	}}
+
+	renderer, err := landing.NewRenderer(landing.DefaultTemplates())
+	if err != nil {
+		t.Fatalf("NewRenderer() error = %v", err)
+	}
+	pages := &landing.Builder{Datasets: datasets, State: s, Renderer: renderer, Publisher: nopPublisher{}, Bucket: "frontend"}
+	if _, err := pages.Publish(ctx, "good"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	c := &Checker{
+		Datasets: datasets,
+		Objects:  objects,
+		Buckets:  []string{"ap-public-media"},
+		Grace:    7 * 24 * time.Hour,
+		Layout:   &storage.PurposeLayout{Prefix: "ap"},
+		Pages:    pages,
+	}
+	report, err := c.Run(ctx, FixNone)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	type key struct {
+		check, dataset string
+		severity       Severity
+	}
+	got := make(map[key]int)
+	for _, f := range report.Findings {
+		got[key{f.Check, f.Dataset, f.Severity}]++
+	}
+	for _, want := range []key{
+		{CheckPolicy, "leaky", SeverityCritical},
+		{CheckManifest, "lost", SeverityCritical},
+		{CheckManifest, "leaky", SeverityError},
+		{CheckMetadata, "lost", SeverityError},
+		{CheckMetadata, "leaky", SeverityWarning},
+		{CheckIndex, "good", SeverityError},
+		{CheckIndex, "gone", SeverityWarning},
+		{CheckLanding, "leaky", SeverityError},
+		{CheckOrphan, "", SeverityInfo},
+	} {
+		if got[want] != 1 {
+			t.Errorf("findings %+v = %d, want 1", want, got[want])
+		}
+	}
+	if report.Findings[0].Severity != SeverityCritical || report.Findings[len(report.Findings)-1].Severity != SeverityInfo {
+		t.Error("findings are not ranked by severity")
+	}
+
+	report, err = c.Run(ctx, FixSafe)
+	if err != nil {
+		t.Fatalf("Run(FixSafe) error = %v", err)
+	}
+	for _, f := range report.Findings {
+		if f.Fix == FixSafe && !f.Fixed {
+			t.Errorf("safe finding not fixed: %+v", f)
+		}
+		if f.Check == CheckOrphan && f.Fixed {
+			t.Error("FixSafe removed an orphan")
+		}
+	}
+
+	report, err = c.Run(ctx, FixNone)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, f := range report.Findings {
+		if f.Fix == FixSafe {
+			t.Errorf("finding remains after safe fix: %+v", f)
+		}
+	}
+	if _, ok := objects["ap-public-media"]["datasets/junk/v1/x.bin"]; !ok {
+		t.Error("orphan deleted without FixAll")
+	}
+
+	if _, err := c.Run(ctx, FixAll); err != nil {
+		t.Fatalf("Run(FixAll) error = %v", err)
+	}
+	if _, ok := objects["ap-public-media"]["datasets/junk/v1/x.bin"]; ok {
+		t.Error("orphan not deleted by FixAll")
+	}
+}
+
+func TestCheckerTiered(t *testing.T) {
+	ctx := context.Background()
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	d := &dataset.Dataset{
+		ID: "old", Access: storage.AccessPublic, State: dataset.StatePublished,
+		Versions: []dataset.Version{{Number: 1, Files: []dataset.File{
+			{Path: "a.csv", Bucket: "ap-cloud-public-media", Key: "datasets/old/v1/a.csv", Size: 10, Tier: dataset.TierCloud},
+		}}},
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	objects := fakeObjects{"ap-cloud-public-media": {
+		"datasets/old/v1/a.csv": {Key: "datasets/old/v1/a.csv", Size: 10},
+	}}
+
+	c := &Checker{
+		Datasets:    datasets,
+		Objects:     objects,
+		Layout:      &storage.PurposeLayout{Prefix: "ap"},
+		Prefix:      "ap",
+		CloudPrefix: "ap-cloud",
+	}
+	report, err := c.Run(ctx, FixNone)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, f := range report.Findings {
+		if f.Check == CheckPolicy || f.Check == CheckManifest {
+			t.Errorf("finding for a tiered file: %+v", f)
+		}
+	}
+}
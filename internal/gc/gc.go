@@ -0,0 +1,198 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gc finds and removes stored objects that no dataset manifest
+// references: leftovers from failed uploads, deleted drafts, and
+// abandoned multipart uploads. Objects are reference counted across
+// manifests, so an object shared by versions or datasets, as under the
+// content-addressed layout, is kept until its last reference is gone.
+package gc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// ObjectStore lists and deletes stored objects.
+type ObjectStore interface {
+	ListObjects(ctx context.Context, bucket, prefix string, fn func(s3.ObjectInfo) error) error
+	DeleteObject(ctx context.Context, bucket, key string) error
+	ListMultipartUploads(ctx context.Context, bucket string) ([]s3.Upload, error)
+	AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error
+}
+
+// Orphan is an unreferenced object.
+type Orphan struct {
+	Bucket       string    `json:"bucket"`
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// AbandonedUpload is an incomplete multipart upload.
+type AbandonedUpload struct {
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	UploadID  string    `json:"uploadId"`
+	Initiated time.Time `json:"initiated"`
+}
+
+// Report lists what a collection would remove.
+type Report struct {
+	// Orphans are unreferenced objects older than the grace period
+	Orphans []Orphan `json:"orphans"`
+
+	// Uploads are multipart uploads older than the grace period
+	Uploads []AbandonedUpload `json:"uploads"`
+
+	// InGrace counts unreferenced objects and uploads still within the
+	// grace period
+	InGrace int `json:"inGrace"`
+
+	// Scanned counts objects examined
+	Scanned int `json:"scanned"`
+
+	// Shared counts objects referenced by more than one manifest entry
+	Shared int `json:"shared"`
+
+	// SharedBytes is the storage that sharing saves: the size of each
+	// shared object for each reference beyond the first
+	SharedBytes int64 `json:"sharedBytes"`
+}
+
+// Bytes returns the total size of the orphaned objects.
+func (r *Report) Bytes() int64 {
+	var n int64
+	for _, o := range r.Orphans {
+		n += o.Size
+	}
+	return n
+}
+
+// Collector plans and applies garbage collection.
+type Collector struct {
+	// Objects is the object store to scan
+	Objects ObjectStore
+
+	// Datasets supplies the manifests whose objects are kept
+	Datasets *dataset.Store
+
+	// Grace is how old an unreferenced object must be before removal,
+	// so in-flight uploads are never collected
+	Grace time.Duration
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Plan scans buckets and returns the objects and uploads that would be
+// removed. Nothing is deleted.
+func (c *Collector) Plan(ctx context.Context, buckets []string) (*Report, error) {
+	referenced, err := c.Datasets.References(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if c.Now != nil {
+		now = c.Now()
+	}
+	cutoff := now.Add(-c.Grace)
+
+	r := &Report{}
+	for _, bucket := range buckets {
+		err := c.Objects.ListObjects(ctx, bucket, "", func(obj s3.ObjectInfo) error {
+			r.Scanned++
+			if n := referenced[storage.Location{Bucket: bucket, Key: obj.Key}]; n > 0 {
+				if n > 1 {
+					r.Shared++
+					r.SharedBytes += int64(n-1) * obj.Size
+				}
+				return nil
+			}
+			if obj.LastModified.After(cutoff) {
+				r.InGrace++
+				return nil
+			}
+			r.Orphans = append(r.Orphans, Orphan{
+				Bucket:       bucket,
+				Key:          obj.Key,
+				Size:         obj.Size,
+				LastModified: obj.LastModified,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", bucket, err)
+		}
+
+		uploads, err := c.Objects.ListMultipartUploads(ctx, bucket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list uploads in %s: %w", bucket, err)
+		}
+		for _, u := range uploads {
+			if u.Initiated.After(cutoff) {
+				r.InGrace++
+				continue
+			}
+			r.Uploads = append(r.Uploads, AbandonedUpload{
+				Bucket:    bucket,
+				Key:       u.Key,
+				UploadID:  u.UploadID,
+				Initiated: u.Initiated,
+			})
+		}
+	}
+
+	sort.Slice(r.Orphans, func(i, j int) bool {
+		if r.Orphans[i].Bucket != r.Orphans[j].Bucket {
+			return r.Orphans[i].Bucket < r.Orphans[j].Bucket
+		}
+		return r.Orphans[i].Key < r.Orphans[j].Key
+	})
+	return r, nil
+}
+
+// Apply removes the objects and uploads in r. Manifests are re-read
+// first so that an object referenced since the plan was made is kept.
+func (c *Collector) Apply(ctx context.Context, r *Report) (int, error) {
+	referenced, err := c.Datasets.References(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, o := range r.Orphans {
+		if referenced[storage.Location{Bucket: o.Bucket, Key: o.Key}] > 0 {
+			continue
+		}
+		if err := c.Objects.DeleteObject(ctx, o.Bucket, o.Key); err != nil {
+			return removed, fmt.Errorf("failed to delete s3://%s/%s: %w", o.Bucket, o.Key, err)
+		}
+		removed++
+	}
+	for _, u := range r.Uploads {
+		if err := c.Objects.AbortMultipartUpload(ctx, u.Bucket, u.Key, u.UploadID); err != nil {
+			return removed, fmt.Errorf("failed to abort upload %s of s3://%s/%s: %w", u.UploadID, u.Bucket, u.Key, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// fakeStore is an in-memory ObjectStore.
+type fakeStore struct {
+	objects map[string][]s3.ObjectInfo
+	uploads map[string][]s3.Upload
+	deleted []string
+	aborted []string
+}
+
+func (f *fakeStore) ListObjects(_ context.Context, bucket, _ string, fn func(s3.ObjectInfo) error) error {
+	for _, o := range f.objects[bucket] {
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) DeleteObject(_ context.Context, bucket, key string) error {
+	f.deleted = append(f.deleted, bucket+"/"+key)
+	return nil
+}
+
+func (f *fakeStore) ListMultipartUploads(_ context.Context, bucket string) ([]s3.Upload, error) {
+	return f.uploads[bucket], nil
+}
+
+func (f *fakeStore) AbortMultipartUpload(_ context.Context, bucket, key, uploadID string) error {
+	f.aborted = append(f.aborted, bucket+"/"+key+"#"+uploadID)
+	return nil
+}
+
+func TestCollector(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := now.Add(-30 * 24 * time.Hour)
+	recent := now.Add(-time.Hour)
+
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	err := datasets.Put(ctx, &dataset.Dataset{
+		ID: "ds-1",
+		Versions: []dataset.Version{{
+			Number: 1,
+			Files: []dataset.File{{
+				Path: "a.xlsx", Bucket: "pub", Key: "datasets/ds-1/v1/a.xlsx",
+				Derivatives: []dataset.Derivative{{Converter: "csv", Bucket: "pub", Key: "datasets/ds-1/v1/a.xlsx.csv.csv"}},
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	objects := &fakeStore{
+		objects: map[string][]s3.ObjectInfo{
+			"pub": {
+				{Key: "datasets/ds-1/v1/a.xlsx", Size: 10, LastModified: old},
+				{Key: "datasets/ds-1/v1/a.xlsx.csv.csv", Size: 8, LastModified: old},
+				{Key: "datasets/ds-9/v1/deleted-draft.csv", Size: 20, LastModified: old},
+				{Key: "datasets/ds-2/v1/uploading.csv", Size: 30, LastModified: recent},
+			},
+		},
+		uploads: map[string][]s3.Upload{
+			"pub": {
+				{Key: "datasets/ds-3/v1/big.tif", UploadID: "u1", Initiated: old},
+				{Key: "datasets/ds-4/v1/big.tif", UploadID: "u2", Initiated: recent},
+			},
+		},
+	}
+
+	c := &Collector{
+		Objects:  objects,
+		Datasets: datasets,
+		Grace:    7 * 24 * time.Hour,
+		Now:      func() time.Time { return now },
+	}
+
+	report, err := c.Plan(ctx, []string{"pub"})
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if report.Scanned != 4 {
+		t.Errorf("Scanned = %d, want 4", report.Scanned)
+	}
+	if len(report.Orphans) != 1 || report.Orphans[0].Key != "datasets/ds-9/v1/deleted-draft.csv" {
+		t.Errorf("Orphans = %+v, want the deleted draft only", report.Orphans)
+	}
+	if len(report.Uploads) != 1 || report.Uploads[0].UploadID != "u1" {
+		t.Errorf("Uploads = %+v, want u1 only", report.Uploads)
+	}
+	if report.InGrace != 2 {
+		t.Errorf("InGrace = %d, want 2", report.InGrace)
+	}
+	if report.Bytes() != 20 {
+		t.Errorf("Bytes() = %d, want 20", report.Bytes())
+	}
+	if len(objects.deleted) != 0 {
+		t.Fatalf("Plan() deleted objects: %v", objects.deleted)
+	}
+
+	// A manifest written after planning must protect its objects.
+	err = datasets.Put(ctx, &dataset.Dataset{
+		ID: "ds-9",
+		Versions: []dataset.Version{{
+			Number: 1,
+			Files:  []dataset.File{{Path: "x", Bucket: "pub", Key: "datasets/ds-9/v1/deleted-draft.csv"}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	removed, err := c.Apply(ctx, report)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if removed != 1 || len(objects.deleted) != 0 || len(objects.aborted) != 1 {
+		t.Errorf("Apply() removed %d, deleted %v, aborted %v", removed, objects.deleted, objects.aborted)
+	}
+}
+
+func TestCollectorCountsReferences(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	key := "content/sha256/e3/b0/e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	file := dataset.File{Path: "a.csv", Bucket: "pub", Key: key, Size: 10}
+
+	// Two versions of one dataset and a second dataset share the object.
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", Versions: []dataset.Version{{Number: 1, Files: []dataset.File{file}}, {Number: 2, Files: []dataset.File{file}}}},
+		{ID: "ds-2", Versions: []dataset.Version{{Number: 1, Files: []dataset.File{file}}}},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	objects := &fakeStore{objects: map[string][]s3.ObjectInfo{"pub": {{Key: key, Size: 10, LastModified: now.Add(-30 * 24 * time.Hour)}}}}
+	c := &Collector{Objects: objects, Datasets: datasets, Grace: time.Hour, Now: func() time.Time { return now }}
+
+	report, err := c.Plan(ctx, []string{"pub"})
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(report.Orphans) != 0 || report.Shared != 1 || report.SharedBytes != 20 {
+		t.Errorf("Plan() = %+v, want the object kept and shared", report)
+	}
+
+	// The object outlives all but its last reference.
+	if err := datasets.Delete(ctx, "ds-2"); err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := c.Plan(ctx, []string{"pub"}); len(report.Orphans) != 0 || report.SharedBytes != 10 {
+		t.Errorf("Plan() after deleting ds-2 = %+v, want the object kept", report)
+	}
+	if err := datasets.Delete(ctx, "ds-1"); err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := c.Plan(ctx, []string{"pub"}); len(report.Orphans) != 1 || report.Shared != 0 {
+		t.Errorf("Plan() after deleting both = %+v, want the object orphaned", report)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harvest
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PrefixDC is the metadata format harvested: unqualified Dublin Core,
+// which every OAI-PMH repository must disseminate.
+const PrefixDC = "oai_dc"
+
+// maxResponseBytes caps the size of one OAI-PMH response.
+const maxResponseBytes = 64 << 20
+
+// Query selects the records to list.
+type Query struct {
+	// Set is the setSpec to harvest; the whole repository if empty
+	Set string
+
+	// From and Until bound records' datestamps, as YYYY-MM-DD or the
+	// repository's full granularity; unbounded if empty
+	From  string
+	Until string
+}
+
+// Record is a harvested Dublin Core record.
+type Record struct {
+	// Identifier is the record's OAI identifier
+	Identifier string
+
+	// Datestamp is the record's last modification in the repository
+	Datestamp string
+
+	// Deleted reports whether the repository has deleted the record
+	Deleted bool
+
+	// Sets are the record's setSpecs
+	Sets []string
+
+	// DC holds the record's Dublin Core elements
+	DC DublinCore
+}
+
+// DublinCore holds the fifteen Dublin Core elements. Every element
+// may repeat.
+type DublinCore struct {
+	Title       []string `xml:"http://purl.org/dc/elements/1.1/ title"`
+	Creator     []string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Subject     []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+	Description []string `xml:"http://purl.org/dc/elements/1.1/ description"`
+	Publisher   []string `xml:"http://purl.org/dc/elements/1.1/ publisher"`
+	Contributor []string `xml:"http://purl.org/dc/elements/1.1/ contributor"`
+	Date        []string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Type        []string `xml:"http://purl.org/dc/elements/1.1/ type"`
+	Format      []string `xml:"http://purl.org/dc/elements/1.1/ format"`
+	Identifier  []string `xml:"http://purl.org/dc/elements/1.1/ identifier"`
+	Source      []string `xml:"http://purl.org/dc/elements/1.1/ source"`
+	Language    []string `xml:"http://purl.org/dc/elements/1.1/ language"`
+	Relation    []string `xml:"http://purl.org/dc/elements/1.1/ relation"`
+	Coverage    []string `xml:"http://purl.org/dc/elements/1.1/ coverage"`
+	Rights      []string `xml:"http://purl.org/dc/elements/1.1/ rights"`
+}
+
+// Error is an OAI-PMH protocol error returned by a repository.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return "OAI-PMH error " + e.Code
+	}
+	return "OAI-PMH error " + e.Code + ": " + e.Message
+}
+
+// ClientOptions configures a Client.
+type ClientOptions struct {
+	// Endpoint is the repository's OAI-PMH base URL
+	Endpoint string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client lists records from an OAI-PMH repository.
+type Client struct {
+	endpoint string
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts ClientOptions) *Client {
+	c := &Client{endpoint: opts.Endpoint, http: opts.HTTPClient}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Endpoint returns the repository's base URL.
+func (c *Client) Endpoint() string {
+	return c.endpoint
+}
+
+// response is an OAI-PMH response to ListRecords.
+type response struct {
+	XMLName xml.Name `xml:"http://www.openarchives.org/OAI/2.0/ OAI-PMH"`
+	Errors  []struct {
+		Code    string `xml:"code,attr"`
+		Message string `xml:",chardata"`
+	} `xml:"error"`
+	ListRecords struct {
+		Records []struct {
+			Header struct {
+				Status     string   `xml:"status,attr"`
+				Identifier string   `xml:"identifier"`
+				Datestamp  string   `xml:"datestamp"`
+				SetSpecs   []string `xml:"setSpec"`
+			} `xml:"header"`
+			Metadata struct {
+				DC DublinCore `xml:"http://www.openarchives.org/OAI/2.0/oai_dc/ dc"`
+			} `xml:"metadata"`
+		} `xml:"record"`
+		Token string `xml:"resumptionToken"`
+	} `xml:"ListRecords"`
+}
+
+// List calls fn with each record matching q, in the repository's
+// order, following resumption tokens until the list is complete. It
+// stops at the first error fn returns. A query matching no records is
+// not an error.
+func (c *Client) List(ctx context.Context, q Query, fn func(*Record) error) error {
+	params := url.Values{"verb": {"ListRecords"}, "metadataPrefix": {PrefixDC}}
+	if q.Set != "" {
+		params.Set("set", q.Set)
+	}
+	if q.From != "" {
+		params.Set("from", q.From)
+	}
+	if q.Until != "" {
+		params.Set("until", q.Until)
+	}
+	for {
+		resp, err := c.get(ctx, params)
+		if err != nil {
+			return err
+		}
+		if len(resp.Errors) > 0 {
+			e := resp.Errors[0]
+			if e.Code == "noRecordsMatch" {
+				return nil
+			}
+			return &Error{Code: e.Code, Message: strings.TrimSpace(e.Message)}
+		}
+		for _, r := range resp.ListRecords.Records {
+			rec := &Record{
+				Identifier: strings.TrimSpace(r.Header.Identifier),
+				Datestamp:  strings.TrimSpace(r.Header.Datestamp),
+				Deleted:    r.Header.Status == "deleted",
+				Sets:       r.Header.SetSpecs,
+				DC:         r.Metadata.DC,
+			}
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		token := strings.TrimSpace(resp.ListRecords.Token)
+		if token == "" {
+			return nil
+		}
+		// A resumption request carries the token as its only argument.
+		params = url.Values{"verb": {"ListRecords"}, "resumptionToken": {token}}
+	}
+}
+
+// get issues an OAI-PMH request and decodes the response.
+func (c *Client) get(ctx context.Context, params url.Values) (*response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/xml")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", c.endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", c.endpoint, resp.Status)
+	}
+	var r response
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to decode response from %s: %w", c.endpoint, err)
+	}
+	return &r, nil
+}
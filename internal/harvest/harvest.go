@@ -0,0 +1,558 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package harvest pulls records from other OAI-PMH repositories and
+// stages them as draft datasets.
+//
+// Migrating from an institutional repository such as DSpace or
+// EPrints is done in phases: its records are harvested as Dublin Core,
+// imported as drafts with their existing DOIs, ARKs, and handles, and
+// published once curators have reviewed them. Records already imported
+// are skipped, so a harvest can be repeated with a later --from date to
+// pick up what the old repository published in the meantime.
+package harvest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/blake3"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// MaxFileBytes caps the size of a fetched file.
+const MaxFileBytes = 1 << 30
+
+// Record statuses.
+const (
+	StatusCreated = "created"
+	StatusExists  = "exists"
+	StatusDeleted = "deleted"
+	StatusFailed  = "failed"
+)
+
+// errNotFile is returned when a record's URL is a web page rather than
+// a file.
+var errNotFile = errors.New("not a file")
+
+// errLimit stops a listing once enough records have been imported.
+var errLimit = errors.New("limit reached")
+
+// ObjectStore stores fetched files. *s3.Client implements it.
+type ObjectStore interface {
+	PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error
+	HeadObject(ctx context.Context, bucket, key string) (s3.ObjectInfo, error)
+}
+
+// Manager imports harvested records.
+type Manager struct {
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Objects stores fetched files; required to fetch files
+	Objects ObjectStore
+
+	// Layout locates stored files
+	Layout storage.Layout
+
+	// HTTPClient fetches files; http.DefaultClient if nil
+	HTTPClient *http.Client
+
+	// Log records imports; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Options are the options of Harvest.
+type Options struct {
+	Query
+
+	// Collection is the collection of imported datasets
+	Collection string
+
+	// Files fetches the files a record links to as the dataset's
+	// first version; records are imported as metadata only otherwise
+	Files bool
+
+	// Limit stops the harvest after importing this many records;
+	// unlimited if zero
+	Limit int
+
+	// DryRun reports what would be imported without saving anything
+	DryRun bool
+}
+
+// Outcome is the result of harvesting one record.
+type Outcome struct {
+	// Identifier is the record's OAI identifier
+	Identifier string `json:"identifier"`
+
+	// Dataset is the ID of the imported dataset, or of the dataset
+	// already holding the record
+	Dataset string `json:"dataset,omitempty"`
+
+	// Status is created, exists, deleted, or failed
+	Status string `json:"status"`
+
+	// Files is the number of files fetched
+	Files int `json:"files,omitempty"`
+
+	// Error says why the record failed
+	Error string `json:"error,omitempty"`
+}
+
+// Result is the result of a harvest.
+type Result struct {
+	// Endpoint is the harvested repository
+	Endpoint string `json:"endpoint"`
+
+	// Records lists the outcome of each record, in the repository's
+	// order
+	Records []Outcome `json:"records"`
+
+	// Created is the number of datasets imported
+	Created int `json:"created"`
+}
+
+// Harvest lists the records of c matching opts and imports each as a
+// draft dataset owned by the signed-in principal. Records the catalog
+// already holds, by dataset ID or persistent identifier, and records
+// deleted in the repository are skipped. A record that cannot be
+// imported is reported as failed without stopping the harvest.
+func (m *Manager) Harvest(ctx context.Context, c *Client, opts Options) (*Result, error) {
+	p := identity.FromContext(ctx)
+	if p.IsZero() {
+		return nil, fmt.Errorf("%w: harvested datasets must be created by a signed-in principal", authz.ErrForbidden)
+	}
+	if opts.Files && m.Objects == nil && !opts.DryRun {
+		return nil, fmt.Errorf("fetching files requires an object store")
+	}
+	res := &Result{Endpoint: c.Endpoint()}
+	err := c.List(ctx, opts.Query, func(r *Record) error {
+		o, err := m.stage(ctx, c.Endpoint(), r, opts)
+		if err != nil {
+			// Catalog errors affect every record, so they stop the harvest.
+			return err
+		}
+		res.Records = append(res.Records, o)
+		if o.Status == StatusCreated {
+			res.Created++
+			if opts.Limit > 0 && res.Created >= opts.Limit {
+				return errLimit
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimit) {
+		return res, fmt.Errorf("failed to harvest %s: %w", c.Endpoint(), err)
+	}
+	return res, nil
+}
+
+// stage imports r, returning its outcome. Errors are returned only if
+// the catalog cannot be read or written.
+func (m *Manager) stage(ctx context.Context, endpoint string, r *Record, opts Options) (Outcome, error) {
+	o := Outcome{Identifier: r.Identifier, Dataset: DatasetID(r.Identifier)}
+	if r.Deleted {
+		o.Status = StatusDeleted
+		return o, nil
+	}
+	if found, err := m.existing(ctx, o.Dataset, r); err != nil {
+		return o, err
+	} else if found != nil {
+		o.Dataset, o.Status = found.ID, StatusExists
+		return o, nil
+	}
+
+	d, err := NewDataset(o.Dataset, r, m.now())
+	if err != nil {
+		o.Status, o.Error = StatusFailed, err.Error()
+		return o, nil
+	}
+	p := identity.FromContext(ctx)
+	d.Collection = opts.Collection
+	d.Owner = identity.Normalize(p.ID)
+	d.ACL = &authz.ACL{Manage: []string{"user:" + identity.Normalize(p.ID)}}
+	if opts.DryRun {
+		o.Status = StatusCreated
+		return o, nil
+	}
+
+	if opts.Files {
+		files, err := m.fetchAll(ctx, d, r)
+		if err != nil {
+			o.Status, o.Error = StatusFailed, err.Error()
+			return o, nil
+		}
+		if len(files) > 0 {
+			d.Versions = []dataset.Version{{Number: 1, Files: files}}
+		}
+		o.Files = len(files)
+	}
+	details := map[string]string{"endpoint": endpoint, "identifier": r.Identifier, "datestamp": r.Datestamp}
+	d.History = append(d.History, dataset.Event{
+		Time:    m.now().UTC(),
+		Actor:   p.String(),
+		Action:  "harvest.import",
+		Details: details,
+	})
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return o, err
+	}
+	if m.Log != nil {
+		if err := audit.Record(ctx, m.Log, "harvest.import", d.ID, details); err != nil {
+			return o, err
+		}
+	}
+	o.Status = StatusCreated
+	return o, nil
+}
+
+// existing returns the dataset already holding r: the dataset with ID
+// id, or one registered under any of r's persistent identifiers. It
+// returns nil if there is none.
+func (m *Manager) existing(ctx context.Context, id string, r *Record) (*dataset.Dataset, error) {
+	lookups := []func() (*dataset.Dataset, error){
+		func() (*dataset.Dataset, error) { return m.Datasets.Get(ctx, id) },
+	}
+	doi, ark, handle := identifiers(r.DC.Identifier)
+	if doi != "" {
+		lookups = append(lookups, func() (*dataset.Dataset, error) { return m.Datasets.ByDOI(ctx, doi) })
+	}
+	if ark != "" {
+		lookups = append(lookups, func() (*dataset.Dataset, error) { return m.Datasets.ByARK(ctx, ark) })
+	}
+	if handle != "" {
+		lookups = append(lookups, func() (*dataset.Dataset, error) { return m.Datasets.ByHandle(ctx, handle) })
+	}
+	for _, lookup := range lookups {
+		d, err := lookup()
+		if err == nil {
+			return d, nil
+		}
+		if !errors.Is(err, dataset.ErrNotFound) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// NewDataset maps r's Dublin Core to a draft dataset with ID id.
+// Owner, ACL, and collection are left to the caller.
+func NewDataset(id string, r *Record, now time.Time) (*dataset.Dataset, error) {
+	dc := r.DC
+	title := first(dc.Title)
+	if title == "" {
+		return nil, fmt.Errorf("record %s has no title", r.Identifier)
+	}
+	d := &dataset.Dataset{
+		ID:              id,
+		Title:           title,
+		Description:     strings.Join(trimmed(dc.Description), "\n\n"),
+		PublicationYear: year(dc.Date),
+		ResourceType:    resourceType(dc.Type),
+		Subjects:        trimmed(dc.Subject),
+		License:         license(dc.Rights),
+		State:           dataset.StateDraft,
+	}
+	for _, name := range trimmed(dc.Creator) {
+		d.Creators = append(d.Creators, dataset.Creator{Name: name})
+	}
+	d.DOI, d.ARK, d.Handle = identifiers(dc.Identifier)
+	d.Access, d.Embargo = access(dc.Rights, now)
+	return d, nil
+}
+
+// DatasetID derives a dataset ID from an OAI identifier, e.g.
+// "oai:dspace.uni.edu:1234/56" becomes "dspace-uni-edu-1234-56".
+func DatasetID(identifier string) string {
+	s := strings.TrimPrefix(strings.TrimSpace(identifier), "oai:")
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '-'
+	}, s)
+	// Runs of separators collapse, so "a:/b" reads "a-b".
+	for strings.Contains(s, "--") {
+		s = strings.ReplaceAll(s, "--", "-")
+	}
+	return strings.Trim(s, "-")
+}
+
+// identifiers returns the DOI, ARK, and handle among a record's
+// dc:identifier values; each is "" if absent.
+func identifiers(values []string) (doi, ark, handle string) {
+	for _, v := range trimmed(values) {
+		lower := strings.ToLower(v)
+		switch {
+		case doi == "" && (strings.HasPrefix(lower, "doi:") || strings.Contains(lower, "doi.org/10.") || strings.HasPrefix(lower, "10.")):
+			if i := strings.Index(lower, "doi.org/"); i >= 0 {
+				v = v[i+len("doi.org/"):]
+			}
+			doi = dataset.NormalizeDOI(v)
+		case ark == "" && dataset.IsARK(v):
+			ark = dataset.NormalizeARK(v)
+		case handle == "" && (strings.HasPrefix(lower, "hdl:") || strings.Contains(lower, "hdl.handle.net/")):
+			if i := strings.Index(lower, "hdl.handle.net/"); i >= 0 {
+				v = v[i+len("hdl.handle.net/"):]
+			}
+			handle = dataset.NormalizeHandle(v)
+		}
+	}
+	return doi, ark, handle
+}
+
+// access maps info:eu-repo access rights, as the OpenAIRE guidelines
+// ask repositories to disseminate, to an access level. Records without
+// recognized rights are restricted, so that nothing is published more
+// openly than it was in the repository.
+func access(rights []string, now time.Time) (storage.Access, *dataset.Embargo) {
+	var level storage.Access
+	var until time.Time
+	for _, r := range trimmed(rights) {
+		switch {
+		case strings.HasSuffix(r, "/openAccess"):
+			level = storage.AccessPublic
+		case strings.HasSuffix(r, "/embargoedAccess"):
+			level = storage.AccessEmbargoed
+		case strings.HasSuffix(r, "/restrictedAccess"):
+			level = storage.AccessRestricted
+		case strings.HasSuffix(r, "/closedAccess"):
+			level = storage.AccessPrivate
+		case strings.HasPrefix(r, "info:eu-repo/date/embargoEnd/"):
+			until, _ = time.Parse("2006-01-02", strings.TrimPrefix(r, "info:eu-repo/date/embargoEnd/"))
+		}
+	}
+	switch {
+	case level == "":
+		return storage.AccessRestricted, nil
+	case level != storage.AccessEmbargoed:
+		return level, nil
+	case until.IsZero():
+		// An embargo with no end date cannot lift on its own.
+		return storage.AccessRestricted, nil
+	case !until.After(now):
+		return storage.AccessPublic, nil
+	}
+	return storage.AccessEmbargoed, &dataset.Embargo{Until: until.UTC(), ReleaseAccess: storage.AccessPublic}
+}
+
+// license returns the first dc:rights value naming a license by URL,
+// such as a Creative Commons deed, or "".
+func license(rights []string) string {
+	for _, r := range trimmed(rights) {
+		if strings.HasPrefix(r, "http://") || strings.HasPrefix(r, "https://") {
+			return r
+		}
+	}
+	return ""
+}
+
+// resourceType maps dc:type values to a DataCite resource type.
+func resourceType(types []string) string {
+	for _, t := range trimmed(types) {
+		switch strings.ToLower(path.Base(t)) {
+		case "software", "computer software":
+			return "Software"
+		case "dataset", "data set", "data":
+			return "Dataset"
+		}
+	}
+	return "Dataset"
+}
+
+// year returns the year of the first dc:date, or 0.
+func year(dates []string) int {
+	for _, d := range trimmed(dates) {
+		if len(d) >= 4 {
+			if y, err := strconv.Atoi(d[:4]); err == nil {
+				return y
+			}
+		}
+	}
+	return 0
+}
+
+// fetchAll fetches the files r links to and stores them as version 1
+// of d. Identifiers that are not http(s) URLs, resolve persistent
+// identifiers, or serve web pages are skipped.
+func (m *Manager) fetchAll(ctx context.Context, d *dataset.Dataset, r *Record) ([]dataset.File, error) {
+	var files []dataset.File
+	seen := make(map[string]bool)
+	for _, v := range trimmed(r.DC.Identifier) {
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || resolver(u.Host) {
+			continue
+		}
+		name, body, contentType, err := m.fetch(ctx, v)
+		if errors.Is(err, errNotFile) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			name = strconv.Itoa(len(files)+1) + "-" + name
+		}
+		seen[name] = true
+		f, err := m.store(ctx, d, name, body, contentType)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// resolver reports whether host is a persistent identifier resolver,
+// whose URLs lead to landing pages rather than files.
+func resolver(host string) bool {
+	switch strings.ToLower(host) {
+	case "doi.org", "dx.doi.org", "hdl.handle.net", "n2t.net", "arks.org":
+		return true
+	}
+	return false
+}
+
+// fetch downloads rawURL, returning its file name, content, and
+// media type. It returns errNotFile if the URL serves HTML.
+func (m *Manager) fetch(ctx context.Context, rawURL string) (string, []byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	client := m.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, "", fmt.Errorf("failed to fetch %s: %s", rawURL, resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil && (mt == "text/html" || mt == "application/xhtml+xml") {
+		return "", nil, "", errNotFile
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxFileBytes+1))
+	if err != nil {
+		return "", nil, "", fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	if len(body) > MaxFileBytes {
+		return "", nil, "", fmt.Errorf("%s exceeds %d bytes", rawURL, MaxFileBytes)
+	}
+	return fileName(resp), body, contentType, nil
+}
+
+// fileName returns the name of a fetched file: the Content-Disposition
+// filename if given, the last segment of the URL path otherwise.
+func fileName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := path.Base(strings.ReplaceAll(params["filename"], `\`, "/")); name != "." && name != "/" && name != "" {
+			return name
+		}
+	}
+	if name := path.Base(resp.Request.URL.Path); name != "." && name != "/" {
+		return name
+	}
+	return "file"
+}
+
+// store uploads a file of version 1 of d.
+func (m *Manager) store(ctx context.Context, d *dataset.Dataset, file string, body []byte, contentType string) (dataset.File, error) {
+	sum, b3 := sha256.Sum256(body), blake3.Sum256(body)
+	digest := hex.EncodeToString(sum[:])
+	loc, err := m.Layout.Locate(storage.Object{Dataset: d.ID, Version: 1, File: file, Collection: d.Collection, Access: d.Access, SHA256: digest})
+	if err != nil {
+		return dataset.File{}, err
+	}
+	// Content already stored under its digest is not uploaded again.
+	stored := false
+	if storage.ContentAddressed(m.Layout) {
+		_, err := m.Objects.HeadObject(ctx, loc.Bucket, loc.Key)
+		if err != nil && !errors.Is(err, s3.ErrNotFound) {
+			return dataset.File{}, fmt.Errorf("failed to check %s: %w", loc, err)
+		}
+		stored = err == nil
+	}
+	if !stored {
+		if err := m.Objects.PutObject(ctx, loc.Bucket, loc.Key, body, contentType); err != nil {
+			return dataset.File{}, fmt.Errorf("failed to store %s: %w", loc, err)
+		}
+	}
+	return dataset.File{
+		Path:        file,
+		Bucket:      loc.Bucket,
+		Key:         loc.Key,
+		Size:        int64(len(body)),
+		SHA256:      digest,
+		BLAKE3:      hex.EncodeToString(b3[:]),
+		ContentType: contentType,
+	}, nil
+}
+
+// first returns the first non-blank value, or "".
+func first(values []string) string {
+	if t := trimmed(values); len(t) > 0 {
+		return t[0]
+	}
+	return ""
+}
+
+// trimmed returns values with surrounding space removed and blank
+// values dropped.
+func trimmed(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (m *Manager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
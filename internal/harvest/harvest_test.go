@@ -0,0 +1,291 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harvest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+const page1 = `<?xml version="1.0" encoding="UTF-8"?>
+<OAI-PMH xmlns="http://www.openarchives.org/OAI/2.0/">
+  <ListRecords>
+    <record>
+      <header>
+        <identifier>oai:repo.uni.edu:1234/1</identifier>
+        <datestamp>2024-05-01</datestamp>
+        <setSpec>col_1234_9</setSpec>
+      </header>
+      <metadata>
+        <oai_dc:dc xmlns:oai_dc="http://www.openarchives.org/OAI/2.0/oai_dc/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+          <dc:title>Soil cores</dc:title>
+          <dc:creator>Lovelace, Ada</dc:creator>
+          <dc:creator>Hopper, Grace</dc:creator>
+          <dc:description>Cores from plot A.</dc:description>
+          <dc:date>2019-06-30T00:00:00Z</dc:date>
+          <dc:type>Dataset</dc:type>
+          <dc:identifier>https://doi.org/10.5555/Soil.1</dc:identifier>
+          <dc:identifier>%[1]s/items/1</dc:identifier>
+          <dc:identifier>%[1]s/files/cores.csv</dc:identifier>
+          <dc:subject>Soil science</dc:subject>
+          <dc:rights>info:eu-repo/semantics/openAccess</dc:rights>
+          <dc:rights>https://creativecommons.org/licenses/by/4.0/</dc:rights>
+        </oai_dc:dc>
+      </metadata>
+    </record>
+    <record>
+      <header status="deleted">
+        <identifier>oai:repo.uni.edu:1234/2</identifier>
+        <datestamp>2024-05-02</datestamp>
+      </header>
+    </record>
+    <resumptionToken cursor="0">page-2</resumptionToken>
+  </ListRecords>
+</OAI-PMH>`
+
+const page2 = `<?xml version="1.0" encoding="UTF-8"?>
+<OAI-PMH xmlns="http://www.openarchives.org/OAI/2.0/">
+  <ListRecords>
+    <record>
+      <header>
+        <identifier>oai:repo.uni.edu:1234/3</identifier>
+        <datestamp>2024-05-03</datestamp>
+      </header>
+      <metadata>
+        <oai_dc:dc xmlns:oai_dc="http://www.openarchives.org/OAI/2.0/oai_dc/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+          <dc:title>Model code</dc:title>
+          <dc:type>info:eu-repo/semantics/software</dc:type>
+          <dc:identifier>hdl:1234/3</dc:identifier>
+        </oai_dc:dc>
+      </metadata>
+    </record>
+    <record>
+      <header>
+        <identifier>oai:repo.uni.edu:1234/4</identifier>
+        <datestamp>2024-05-04</datestamp>
+      </header>
+      <metadata>
+        <oai_dc:dc xmlns:oai_dc="http://www.openarchives.org/OAI/2.0/oai_dc/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+          <dc:creator>Untitled, Record</dc:creator>
+        </oai_dc:dc>
+      </metadata>
+    </record>
+    <resumptionToken cursor="2"></resumptionToken>
+  </ListRecords>
+</OAI-PMH>`
+
+// newRepository serves a two-page OAI-PMH listing and the files its
+// records link to.
+func newRepository(t *testing.T) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /oai", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		w.Header().Set("Content-Type", "text/xml")
+		switch {
+		case q.Get("resumptionToken") == "page-2":
+			fmt.Fprint(w, page2)
+		case q.Get("metadataPrefix") == PrefixDC && q.Get("set") == "empty":
+			fmt.Fprint(w, `<OAI-PMH xmlns="http://www.openarchives.org/OAI/2.0/"><error code="noRecordsMatch"/></OAI-PMH>`)
+		case q.Get("metadataPrefix") == PrefixDC && q.Get("set") == "":
+			fmt.Fprintf(w, page1, srv.URL)
+		default:
+			fmt.Fprint(w, `<OAI-PMH xmlns="http://www.openarchives.org/OAI/2.0/"><error code="badArgument">bad set</error></OAI-PMH>`)
+		}
+	})
+	mux.HandleFunc("GET /items/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html></html>")
+	})
+	mux.HandleFunc("GET /files/cores.csv", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		fmt.Fprint(w, "depth,carbon\n")
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// fakeObjects records stored objects.
+type fakeObjects map[string][]byte
+
+func (f fakeObjects) PutObject(_ context.Context, bucket, key string, body []byte, _ string) error {
+	f[bucket+"/"+key] = body
+	return nil
+}
+
+func (f fakeObjects) HeadObject(_ context.Context, bucket, key string) (s3.ObjectInfo, error) {
+	body, ok := f[bucket+"/"+key]
+	if !ok {
+		return s3.ObjectInfo{}, s3.ErrNotFound
+	}
+	return s3.ObjectInfo{Key: key, Size: int64(len(body))}, nil
+}
+
+func newManager() (*Manager, fakeObjects) {
+	objects := fakeObjects{}
+	return &Manager{
+		Datasets: dataset.NewStore(state.NewMemoryStore()),
+		Objects:  objects,
+		Layout:   &storage.PurposeLayout{Prefix: "ap-prod"},
+		Now:      func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) },
+	}, objects
+}
+
+func statuses(res *Result) []string {
+	var out []string
+	for _, o := range res.Records {
+		out = append(out, o.Identifier+"="+o.Status)
+	}
+	return out
+}
+
+func TestHarvest(t *testing.T) {
+	srv := newRepository(t)
+	m, objects := newManager()
+	c := NewClient(ClientOptions{Endpoint: srv.URL + "/oai"})
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "curator@uni.edu"})
+
+	res, err := m.Harvest(ctx, c, Options{Collection: "legacy", Files: true})
+	if err != nil {
+		t.Fatalf("Harvest() error = %v", err)
+	}
+	want := []string{
+		"oai:repo.uni.edu:1234/1=created",
+		"oai:repo.uni.edu:1234/2=deleted",
+		"oai:repo.uni.edu:1234/3=created",
+		"oai:repo.uni.edu:1234/4=failed",
+	}
+	if got := statuses(res); fmt.Sprint(got) != fmt.Sprint(want) || res.Created != 2 {
+		t.Fatalf("Harvest() = %v (%d created), want %v", got, res.Created, want)
+	}
+
+	d, err := m.Datasets.Get(ctx, "repo-uni-edu-1234-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "Soil cores" || d.DOI != "10.5555/soil.1" || d.PublicationYear != 2019 || len(d.Creators) != 2 ||
+		fmt.Sprint(d.Subjects) != "[Soil science]" || d.License != "https://creativecommons.org/licenses/by/4.0/" ||
+		d.State != dataset.StateDraft || d.Access != storage.AccessPublic || d.Collection != "legacy" || d.Owner != "curator@uni.edu" {
+		t.Errorf("imported dataset = %+v", d)
+	}
+	if len(d.Versions) != 1 || len(d.Versions[0].Files) != 1 || d.Versions[0].Files[0].Path != "cores.csv" || d.Versions[0].PublishedAt != nil {
+		t.Errorf("imported versions = %+v", d.Versions)
+	}
+	if len(objects) != 1 || res.Records[0].Files != 1 {
+		t.Errorf("stored objects = %v", objects)
+	}
+	if len(d.History) != 1 || d.History[0].Action != "harvest.import" || d.History[0].Details["datestamp"] != "2024-05-01" {
+		t.Errorf("history = %+v", d.History)
+	}
+	sw, err := m.Datasets.ByHandle(ctx, "1234/3")
+	if err != nil || sw.ResourceType != "Software" || sw.Access != storage.AccessRestricted || len(sw.Versions) != 0 {
+		t.Errorf("software record = %+v, %v", sw, err)
+	}
+
+	// A repeated harvest finds every record already imported.
+	res, err = m.Harvest(ctx, c, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Created != 0 || res.Records[0].Status != StatusExists || res.Records[2].Status != StatusExists {
+		t.Errorf("repeated Harvest() = %v", statuses(res))
+	}
+}
+
+func TestHarvestOptions(t *testing.T) {
+	srv := newRepository(t)
+	c := NewClient(ClientOptions{Endpoint: srv.URL + "/oai"})
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "curator@uni.edu"})
+
+	m, _ := newManager()
+	res, err := m.Harvest(ctx, c, Options{DryRun: true})
+	if err != nil || res.Created != 2 {
+		t.Fatalf("dry run = %v, %v", res, err)
+	}
+	if all, _ := m.Datasets.List(ctx); len(all) != 0 {
+		t.Errorf("dry run saved %d datasets", len(all))
+	}
+
+	res, err = m.Harvest(ctx, c, Options{Limit: 1})
+	if err != nil || res.Created != 1 || len(res.Records) != 1 {
+		t.Errorf("limited Harvest() = %v, %v", res, err)
+	}
+
+	res, err = m.Harvest(ctx, c, Options{Query: Query{Set: "empty"}})
+	if err != nil || len(res.Records) != 0 {
+		t.Errorf("empty set = %v, %v", res, err)
+	}
+
+	var oaiErr *Error
+	if _, err := m.Harvest(ctx, c, Options{Query: Query{Set: "bogus"}}); !errors.As(err, &oaiErr) || oaiErr.Code != "badArgument" {
+		t.Errorf("bad set error = %v", err)
+	}
+
+	if _, err := m.Harvest(context.Background(), c, Options{}); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("anonymous Harvest() error = %v, want ErrForbidden", err)
+	}
+}
+
+func TestDatasetID(t *testing.T) {
+	tests := []struct {
+		identifier string
+		want       string
+	}{
+		{"oai:repo.uni.edu:1234/56", "repo-uni-edu-1234-56"},
+		{"oai:eprints.uni.ac.uk:789", "eprints-uni-ac-uk-789"},
+		{"Record_A", "record-a"},
+	}
+	for _, tt := range tests {
+		if got := DatasetID(tt.identifier); got != tt.want {
+			t.Errorf("DatasetID(%q) = %q, want %q", tt.identifier, got, tt.want)
+		}
+	}
+}
+
+func TestAccess(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		rights  []string
+		want    storage.Access
+		embargo bool
+	}{
+		{[]string{"info:eu-repo/semantics/openAccess"}, storage.AccessPublic, false},
+		{[]string{"info:eu-repo/semantics/closedAccess"}, storage.AccessPrivate, false},
+		{[]string{"info:eu-repo/semantics/embargoedAccess", "info:eu-repo/date/embargoEnd/2026-01-01"}, storage.AccessEmbargoed, true},
+		{[]string{"info:eu-repo/semantics/embargoedAccess", "info:eu-repo/date/embargoEnd/2024-01-01"}, storage.AccessPublic, false},
+		{[]string{"info:eu-repo/semantics/embargoedAccess"}, storage.AccessRestricted, false},
+		{[]string{"CC BY 4.0"}, storage.AccessRestricted, false},
+	}
+	for _, tt := range tests {
+		got, embargo := access(tt.rights, now)
+		if got != tt.want || (embargo != nil) != tt.embargo {
+			t.Errorf("access(%v) = %v, %v, want %v", tt.rights, got, embargo, tt.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package iam is a minimal AWS Identity and Access Management client
+// for finding out who the caller is and what they may do, and for
+// assuming roles. It calls STS for the caller's identity and roles, and
+// IAM for the rest.
+package iam
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+const (
+	iamVersion = "2010-05-08"
+	stsVersion = "2011-06-15"
+)
+
+// DecisionAllowed is the decision of a simulation allowing an action.
+const DecisionAllowed = "allowed"
+
+// ErrNotFound is returned when a role does not exist.
+var ErrNotFound = errors.New("iam: not found")
+
+// Options configures a Client.
+type Options struct {
+	// Endpoint overrides the global IAM endpoint
+	Endpoint string
+
+	// STSEndpoint overrides the global STS endpoint
+	STSEndpoint string
+
+	// Credentials sign requests
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is an IAM and STS client.
+type Client struct {
+	endpoint    string
+	stsEndpoint string
+	signer      *aws.Signer
+	stsSigner   *aws.Signer
+	http        *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	c := &Client{
+		endpoint:    strings.TrimRight(opts.Endpoint, "/") + "/",
+		stsEndpoint: strings.TrimRight(opts.STSEndpoint, "/") + "/",
+		// IAM and the global STS endpoint are signed in us-east-1.
+		signer:    &aws.Signer{Credentials: opts.Credentials, Region: "us-east-1", Service: "iam"},
+		stsSigner: &aws.Signer{Credentials: opts.Credentials, Region: "us-east-1", Service: "sts"},
+		http:      opts.HTTPClient,
+	}
+	if opts.Endpoint == "" {
+		c.endpoint = "https://iam.amazonaws.com/"
+	}
+	if opts.STSEndpoint == "" {
+		c.stsEndpoint = "https://sts.amazonaws.com/"
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Identity is the principal whose credentials sign requests.
+type Identity struct {
+	Account string `xml:"GetCallerIdentityResult>Account"`
+
+	// ARN is the ARN of the user, or of the assumed role's session
+	ARN string `xml:"GetCallerIdentityResult>Arn"`
+}
+
+// GetCallerIdentity returns the principal whose credentials sign
+// requests.
+func (c *Client) GetCallerIdentity(ctx context.Context) (*Identity, error) {
+	var out Identity
+	if err := c.do(ctx, c.stsEndpoint, c.stsSigner, stsVersion, "GetCallerIdentity", url.Values{}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AssumeRole returns temporary credentials of the role roleARN, for a
+// session named session that lasts an hour.
+func (c *Client) AssumeRole(ctx context.Context, roleARN, session string) (aws.Credentials, error) {
+	var out struct {
+		AccessKeyID     string `xml:"AssumeRoleResult>Credentials>AccessKeyId"`
+		SecretAccessKey string `xml:"AssumeRoleResult>Credentials>SecretAccessKey"`
+		SessionToken    string `xml:"AssumeRoleResult>Credentials>SessionToken"`
+	}
+	form := url.Values{"RoleArn": {roleARN}, "RoleSessionName": {session}, "DurationSeconds": {"3600"}}
+	if err := c.do(ctx, c.stsEndpoint, c.stsSigner, stsVersion, "AssumeRole", form, &out); err != nil {
+		return aws.Credentials{}, err
+	}
+	return aws.Credentials{AccessKeyID: out.AccessKeyID, SecretAccessKey: out.SecretAccessKey, SessionToken: out.SessionToken}, nil
+}
+
+// GetRole returns the ARN of the role name.
+func (c *Client) GetRole(ctx context.Context, name string) (string, error) {
+	var out struct {
+		ARN string `xml:"GetRoleResult>Role>Arn"`
+	}
+	form := url.Values{"RoleName": {name}}
+	if err := c.do(ctx, c.endpoint, c.signer, iamVersion, "GetRole", form, &out); err != nil {
+		return "", err
+	}
+	return out.ARN, nil
+}
+
+// Evaluation is the decision of a simulation for one action.
+type Evaluation struct {
+	Action string `xml:"EvalActionName"`
+
+	// Decision is allowed, explicitDeny, or implicitDeny
+	Decision string `xml:"EvalDecision"`
+}
+
+// SimulatePrincipalPolicy evaluates whether the policies of the user or
+// role principal allow actions on any resource.
+func (c *Client) SimulatePrincipalPolicy(ctx context.Context, principal string, actions []string) ([]Evaluation, error) {
+	var evals []Evaluation
+	marker := ""
+	for {
+		form := url.Values{"PolicySourceArn": {principal}}
+		for i, action := range actions {
+			form.Set("ActionNames.member."+strconv.Itoa(i+1), action)
+		}
+		if marker != "" {
+			form.Set("Marker", marker)
+		}
+		var out struct {
+			Results     []Evaluation `xml:"SimulatePrincipalPolicyResult>EvaluationResults>member"`
+			IsTruncated bool         `xml:"SimulatePrincipalPolicyResult>IsTruncated"`
+			Marker      string       `xml:"SimulatePrincipalPolicyResult>Marker"`
+		}
+		if err := c.do(ctx, c.endpoint, c.signer, iamVersion, "SimulatePrincipalPolicy", form, &out); err != nil {
+			return nil, err
+		}
+		evals = append(evals, out.Results...)
+		if !out.IsTruncated || out.Marker == "" {
+			return evals, nil
+		}
+		marker = out.Marker
+	}
+}
+
+// do calls action at endpoint with form as the request and decodes the
+// XML response into out.
+func (c *Client) do(ctx context.Context, endpoint string, signer *aws.Signer, version, action string, form url.Values, out any) error {
+	form.Set("Action", action)
+	form.Set("Version", version)
+	body := []byte(form.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", action, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signer.Sign(req, aws.HashPayload(body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("iam %s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode, Action: action}
+		_ = xml.Unmarshal(data, e)
+		return e
+	}
+	if err := xml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", action, err)
+	}
+	return nil
+}
+
+// Error is an IAM or STS error response.
+type Error struct {
+	StatusCode int
+	Action     string
+	Code       string `xml:"Error>Code"`
+	Message    string `xml:"Error>Message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("iam %s: HTTP %d %s: %s", e.Action, e.StatusCode, e.Code, e.Message)
+}
+
+// Unwrap maps missing roles to ErrNotFound.
+func (e *Error) Unwrap() error {
+	if e.Code == "NoSuchEntity" {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return NewClient(Options{
+		Endpoint:    srv.URL,
+		STSEndpoint: srv.URL + "/sts",
+		Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+}
+
+func TestGetCallerIdentity(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.URL.Path != "/sts/" || r.PostForm.Get("Action") != "GetCallerIdentity" || r.PostForm.Get("Version") != stsVersion ||
+			!strings.Contains(r.Header.Get("Authorization"), "/us-east-1/sts/aws4_request") {
+			t.Errorf("request = %s %v %q", r.URL.Path, r.PostForm, r.Header.Get("Authorization"))
+		}
+		fmt.Fprint(w, `<GetCallerIdentityResponse><GetCallerIdentityResult>
+			<Arn>arn:aws:sts::123456789012:assumed-role/deployer/alice</Arn><UserId>AROA:alice</UserId><Account>123456789012</Account>
+			</GetCallerIdentityResult></GetCallerIdentityResponse>`)
+	})
+	id, err := c.GetCallerIdentity(context.Background())
+	want := &Identity{Account: "123456789012", ARN: "arn:aws:sts::123456789012:assumed-role/deployer/alice"}
+	if err != nil || !reflect.DeepEqual(id, want) {
+		t.Errorf("GetCallerIdentity() = %+v, %v, want %+v", id, err, want)
+	}
+}
+
+func TestAssumeRole(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.URL.Path != "/sts/" || r.PostForm.Get("Action") != "AssumeRole" ||
+			r.PostForm.Get("RoleArn") != "arn:aws:iam::222222222222:role/aperture-deploy" || r.PostForm.Get("RoleSessionName") != "aperture-prod" {
+			t.Errorf("request = %s %v", r.URL.Path, r.PostForm)
+		}
+		fmt.Fprint(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials>
+			<AccessKeyId>ASIA2</AccessKeyId><SecretAccessKey>secret2</SecretAccessKey><SessionToken>token2</SessionToken>
+			<Expiration>2025-06-01T01:00:00Z</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>`)
+	})
+	creds, err := c.AssumeRole(context.Background(), "arn:aws:iam::222222222222:role/aperture-deploy", "aperture-prod")
+	want := aws.Credentials{AccessKeyID: "ASIA2", SecretAccessKey: "secret2", SessionToken: "token2"}
+	if err != nil || creds != want {
+		t.Errorf("AssumeRole() = %+v, %v, want %+v", creds, err, want)
+	}
+}
+
+func TestGetRole(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("RoleName") != "deployer" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<ErrorResponse><Error><Code>NoSuchEntity</Code><Message>no role</Message></Error></ErrorResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<GetRoleResponse><GetRoleResult><Role><Arn>arn:aws:iam::123456789012:role/ci/deployer</Arn></Role></GetRoleResult></GetRoleResponse>`)
+	})
+	ctx := context.Background()
+	if arn, err := c.GetRole(ctx, "deployer"); err != nil || arn != "arn:aws:iam::123456789012:role/ci/deployer" {
+		t.Errorf("GetRole() = %q, %v", arn, err)
+	}
+	if _, err := c.GetRole(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetRole() of a missing role error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSimulatePrincipalPolicy(t *testing.T) {
+	calls := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("PolicySourceArn") != "arn:aws:iam::123456789012:role/deployer" || r.PostForm.Get("ActionNames.member.2") != "iam:PassRole" {
+			t.Errorf("form = %v", r.PostForm)
+		}
+		calls++
+		if r.PostForm.Get("Marker") == "" {
+			fmt.Fprint(w, `<SimulatePrincipalPolicyResponse><SimulatePrincipalPolicyResult><EvaluationResults>
+				<member><EvalActionName>s3:CreateBucket</EvalActionName><EvalDecision>allowed</EvalDecision></member>
+				</EvaluationResults><IsTruncated>true</IsTruncated><Marker>m1</Marker></SimulatePrincipalPolicyResult></SimulatePrincipalPolicyResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<SimulatePrincipalPolicyResponse><SimulatePrincipalPolicyResult><EvaluationResults>
+			<member><EvalActionName>iam:PassRole</EvalActionName><EvalDecision>implicitDeny</EvalDecision></member>
+			</EvaluationResults><IsTruncated>false</IsTruncated></SimulatePrincipalPolicyResult></SimulatePrincipalPolicyResponse>`)
+	})
+	evals, err := c.SimulatePrincipalPolicy(context.Background(), "arn:aws:iam::123456789012:role/deployer", []string{"s3:CreateBucket", "iam:PassRole"})
+	if err != nil {
+		t.Fatalf("SimulatePrincipalPolicy() error = %v", err)
+	}
+	want := []Evaluation{{Action: "s3:CreateBucket", Decision: DecisionAllowed}, {Action: "iam:PassRole", Decision: "implicitDeny"}}
+	if calls != 2 || !reflect.DeepEqual(evals, want) {
+		t.Errorf("SimulatePrincipalPolicy() = %+v after %d calls, want %+v", evals, calls, want)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package identity carries the acting principal through a request.
+package identity
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// Principal is an authenticated user or service.
+type Principal struct {
+	// ID is the stable identifier (email for people)
+	ID string `json:"id"`
+
+	// Groups lists group memberships
+	Groups []string `json:"groups,omitempty"`
+
+	// ORCID is the principal's verified ORCID iD, if any
+	ORCID string `json:"orcid,omitempty"`
+
+	// Scopes limits a machine token to the listed operations; people
+	// have no scopes and are not limited
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// IsZero reports whether p is the anonymous principal.
+func (p Principal) IsZero() bool {
+	return p.ID == ""
+}
+
+// IsMachine reports whether p authenticated with a scoped machine
+// token.
+func (p Principal) IsMachine() bool {
+	return len(p.Scopes) > 0
+}
+
+// HasScope reports whether p may perform operations in scope. People
+// may perform any operation their access control lists allow.
+func (p Principal) HasScope(scope string) bool {
+	return !p.IsMachine() || slices.Contains(p.Scopes, scope)
+}
+
+// String returns the principal ID, or "anonymous".
+func (p Principal) String() string {
+	if p.IsZero() {
+		return "anonymous"
+	}
+	return p.ID
+}
+
+// Impersonation records an administrator acting as another user.
+type Impersonation struct {
+	// Admin is the administrator performing the actions
+	Admin Principal `json:"admin"`
+
+	// Subject is the user being impersonated
+	Subject Principal `json:"subject"`
+
+	// Justification is the recorded reason for the impersonation
+	Justification string `json:"justification"`
+
+	// Session identifies the impersonation session
+	Session string `json:"session"`
+}
+
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	impersonationKey
+)
+
+// WithPrincipal returns a context carrying p as the acting principal.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// WithImpersonation returns a context in which imp.Subject is the acting
+// principal and imp is available to audit logging.
+func WithImpersonation(ctx context.Context, imp Impersonation) context.Context {
+	ctx = context.WithValue(ctx, impersonationKey, imp)
+	return WithPrincipal(ctx, imp.Subject)
+}
+
+// FromContext returns the acting principal, which is the impersonated
+// user during an impersonation session.
+func FromContext(ctx context.Context) Principal {
+	p, _ := ctx.Value(principalKey).(Principal)
+	return p
+}
+
+// ImpersonationFromContext returns the active impersonation, if any.
+func ImpersonationFromContext(ctx context.Context) (Impersonation, bool) {
+	imp, ok := ctx.Value(impersonationKey).(Impersonation)
+	return imp, ok
+}
+
+// Normalize lowercases and trims an identifier so that email addresses
+// compare equal regardless of how they were typed.
+func Normalize(id string) string {
+	return strings.ToLower(strings.TrimSpace(id))
+}
+
+// ValidORCID reports whether id is a well-formed ORCID iD with a valid
+// ISO 7064 11,2 check digit.
+func ValidORCID(id string) bool {
+	id = strings.TrimPrefix(id, "https://orcid.org/")
+	digits := strings.ReplaceAll(id, "-", "")
+	if len(digits) != 16 || len(id) != 19 {
+		return false
+	}
+	total := 0
+	for _, ch := range digits[:15] {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+		total = (total + int(ch-'0')) * 2
+	}
+	check := (12 - total%11) % 11
+	want := byte('0' + check)
+	if check == 10 {
+		want = 'X'
+	}
+	return digits[15] == want
+}
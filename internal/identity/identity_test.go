@@ -0,0 +1,49 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package identity carries the acting principal through a request.
+package identity
+
+import "testing"
+
+func TestValidORCID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"0000-0002-1825-0097", true},
+		{"https://orcid.org/0000-0002-1825-0097", true},
+		{"0000-0001-5109-3700", true},
+		{"0000-0002-1694-233X", true},
+		{"0000-0002-1825-0098", false},
+		{"0000000218250097", false},
+		{"not-an-orcid", false},
+	}
+	for _, tt := range tests {
+		if got := ValidORCID(tt.id); got != tt.want {
+			t.Errorf("ValidORCID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	person := Principal{ID: "alice@uni.edu"}
+	machine := Principal{ID: "svc:ci", Scopes: []string{"upload"}}
+	if !person.HasScope("doi:write") || person.IsMachine() {
+		t.Error("people should not be limited by scopes")
+	}
+	if !machine.HasScope("upload") || machine.HasScope("doi:write") {
+		t.Errorf("HasScope() for %v scopes %v is wrong", machine, machine.Scopes)
+	}
+}
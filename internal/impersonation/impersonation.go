@@ -0,0 +1,160 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package impersonation lets administrators act as another user for
+// support purposes, with a recorded justification, notification of the
+// affected user, and audit entries tagged with both identities.
+package impersonation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/notify"
+)
+
+// MinJustificationLength is the shortest accepted justification.
+const MinJustificationLength = 10
+
+// ErrNotAdmin is returned when a non-administrator tries to impersonate.
+var ErrNotAdmin = errors.New("impersonation requires an administrator")
+
+// Options configures an impersonation session.
+type Options struct {
+	// Subject is the user to act as
+	Subject string
+
+	// Justification is the reason for the session
+	Justification string
+
+	// IsAdmin reports whether a principal may impersonate others
+	IsAdmin func(identity.Principal) bool
+
+	// Resolve returns the subject with the groups it holds, so that it
+	// has its own permissions during the session; without it the
+	// subject acts with none
+	Resolve func(context.Context, identity.Principal) (identity.Principal, error)
+
+	// Log receives the session's audit entries
+	Log audit.Log
+
+	// Notifier informs the subject of the session
+	Notifier notify.Notifier
+}
+
+// Session is an active impersonation.
+type Session struct {
+	imp identity.Impersonation
+	log audit.Log
+}
+
+// Start begins a session for the principal in ctx. The returned
+// context acts as the subject; audit entries recorded with it carry
+// both identities.
+func Start(ctx context.Context, opts Options) (context.Context, *Session, error) {
+	admin := identity.FromContext(ctx)
+	if admin.IsZero() {
+		return nil, nil, fmt.Errorf("%w: no authenticated user", ErrNotAdmin)
+	}
+	if opts.IsAdmin == nil || !opts.IsAdmin(admin) {
+		return nil, nil, fmt.Errorf("%w: %s is not an administrator", ErrNotAdmin, admin)
+	}
+
+	subject := identity.Normalize(opts.Subject)
+	if subject == "" {
+		return nil, nil, fmt.Errorf("impersonation subject cannot be empty")
+	}
+	if subject == identity.Normalize(admin.ID) {
+		return nil, nil, fmt.Errorf("cannot impersonate yourself")
+	}
+
+	justification := strings.TrimSpace(opts.Justification)
+	if len(justification) < MinJustificationLength {
+		return nil, nil, fmt.Errorf("a justification of at least %d characters is required", MinJustificationLength)
+	}
+
+	acting := identity.Principal{ID: subject}
+	if opts.Resolve != nil {
+		var err error
+		if acting, err = opts.Resolve(ctx, acting); err != nil {
+			return nil, nil, fmt.Errorf("failed to look up %s: %w", subject, err)
+		}
+	}
+
+	session, err := newSessionID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := &Session{
+		imp: identity.Impersonation{
+			Admin:         admin,
+			Subject:       acting,
+			Justification: justification,
+			Session:       session,
+		},
+		log: opts.Log,
+	}
+	ctx = identity.WithImpersonation(ctx, s.imp)
+
+	if err := audit.Record(ctx, s.log, "impersonation.start", subject, nil); err != nil {
+		return nil, nil, fmt.Errorf("failed to record impersonation: %w", err)
+	}
+
+	if opts.Notifier != nil {
+		msg := notify.Message{
+			To:      subject,
+			Subject: "An Aperture administrator accessed your account",
+			Body: fmt.Sprintf("%s is acting on your behalf in Aperture (session %s).\n\nReason: %s\n\n"+
+				"All actions taken are recorded in the audit log. Contact your repository administrators "+
+				"if you did not expect this.", admin, session, justification),
+		}
+		if err := opts.Notifier.Notify(ctx, msg); err != nil {
+			return nil, nil, fmt.Errorf("failed to notify %s: %w", subject, err)
+		}
+	}
+
+	return ctx, s, nil
+}
+
+// ID returns the session identifier.
+func (s *Session) ID() string {
+	return s.imp.Session
+}
+
+// End records the end of the session and the outcome of the
+// impersonated command.
+func (s *Session) End(ctx context.Context, cmdErr error) error {
+	details := map[string]string{"result": "success"}
+	if cmdErr != nil {
+		details["result"] = "error"
+		details["error"] = cmdErr.Error()
+	}
+	return audit.Record(ctx, s.log, "impersonation.end", s.imp.Subject.ID, details)
+}
+
+// newSessionID returns a random session identifier.
+func newSessionID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impersonation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/notify"
+)
+
+func isAdmin(p identity.Principal) bool {
+	return p.ID == "admin@uni.edu"
+}
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		name          string
+		actor         string
+		subject       string
+		justification string
+		wantErr       bool
+		wantNotAdmin  bool
+	}{
+		{
+			name:          "admin with justification",
+			actor:         "admin@uni.edu",
+			subject:       "Jane@Uni.edu",
+			justification: "fix broken draft upload (ticket 1234)",
+		},
+		{
+			name:          "not an admin",
+			actor:         "jane@uni.edu",
+			subject:       "bob@uni.edu",
+			justification: "fix broken draft upload",
+			wantErr:       true,
+			wantNotAdmin:  true,
+		},
+		{
+			name:          "anonymous",
+			subject:       "bob@uni.edu",
+			justification: "fix broken draft upload",
+			wantErr:       true,
+			wantNotAdmin:  true,
+		},
+		{
+			name:          "short justification",
+			actor:         "admin@uni.edu",
+			subject:       "jane@uni.edu",
+			justification: "fix",
+			wantErr:       true,
+		},
+		{
+			name:          "self",
+			actor:         "admin@uni.edu",
+			subject:       "ADMIN@uni.edu",
+			justification: "fix broken draft upload",
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &audit.MemoryLog{}
+			rec := &notify.Recorder{}
+			ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: tt.actor})
+
+			ctx, s, err := Start(ctx, Options{
+				Subject:       tt.subject,
+				Justification: tt.justification,
+				IsAdmin:       isAdmin,
+				Log:           log,
+				Notifier:      rec,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Start() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantNotAdmin && !errors.Is(err, ErrNotAdmin) {
+				t.Errorf("Start() error = %v, want ErrNotAdmin", err)
+			}
+			if tt.wantErr {
+				if entries, _ := log.Entries(ctx); len(entries) != 0 {
+					t.Errorf("rejected session wrote %d audit entries", len(entries))
+				}
+				return
+			}
+
+			if got := identity.FromContext(ctx).ID; got != "jane@uni.edu" {
+				t.Errorf("acting principal = %v, want jane@uni.edu", got)
+			}
+			if len(rec.Messages) != 1 || rec.Messages[0].To != "jane@uni.edu" {
+				t.Errorf("notifications = %+v, want one to jane@uni.edu", rec.Messages)
+			}
+
+			if err := audit.Record(ctx, log, "dataset.update", "ds-1", nil); err != nil {
+				t.Fatalf("Record() error = %v", err)
+			}
+			if err := s.End(ctx, nil); err != nil {
+				t.Fatalf("End() error = %v", err)
+			}
+
+			entries, _ := log.Entries(ctx)
+			if len(entries) != 3 {
+				t.Fatalf("got %d audit entries, want 3", len(entries))
+			}
+			for _, e := range entries {
+				if e.Actor != "jane@uni.edu" || e.Impersonator != "admin@uni.edu" {
+					t.Errorf("entry %s actor = %q impersonator = %q", e.Action, e.Actor, e.Impersonator)
+				}
+				if e.Session != s.ID() || e.Justification != tt.justification {
+					t.Errorf("entry %s session = %q justification = %q", e.Action, e.Session, e.Justification)
+				}
+			}
+		})
+	}
+}
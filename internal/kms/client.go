@@ -0,0 +1,161 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kms is a minimal AWS Key Management Service client for
+// signing with asymmetric keys.
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+// targetPrefix prefixes the X-Amz-Target of every operation.
+const targetPrefix = "TrentService."
+
+// SigningAlgorithm is the algorithm keys sign with: ECDSA on the NIST
+// P-256 curve over a SHA-256 digest, for ECC_NIST_P256 keys.
+const SigningAlgorithm = "ECDSA_SHA_256"
+
+// ErrNotFound is returned when a key does not exist.
+var ErrNotFound = errors.New("kms: not found")
+
+// Options configures a Client.
+type Options struct {
+	// Region is the AWS region of the keys
+	Region string
+
+	// Endpoint overrides the regional AWS endpoint
+	Endpoint string
+
+	// Credentials sign requests
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is a KMS client.
+type Client struct {
+	endpoint string
+	signer   *aws.Signer
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://kms." + opts.Region + ".amazonaws.com"
+	}
+	c := &Client{
+		endpoint: strings.TrimRight(endpoint, "/") + "/",
+		signer:   &aws.Signer{Credentials: opts.Credentials, Region: opts.Region, Service: "kms"},
+		http:     opts.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Sign signs a SHA-256 digest with the asymmetric key keyID, an ID,
+// ARN, or alias, and returns the DER-encoded signature.
+func (c *Client) Sign(ctx context.Context, keyID string, digest []byte) ([]byte, error) {
+	var out struct {
+		Signature []byte
+	}
+	err := c.do(ctx, "Sign", map[string]any{
+		"KeyId":            keyID,
+		"Message":          digest,
+		"MessageType":      "DIGEST",
+		"SigningAlgorithm": SigningAlgorithm,
+	}, &out)
+	return out.Signature, err
+}
+
+// GetPublicKey returns the DER-encoded SubjectPublicKeyInfo of the
+// asymmetric key keyID.
+func (c *Client) GetPublicKey(ctx context.Context, keyID string) ([]byte, error) {
+	var out struct {
+		PublicKey []byte
+	}
+	err := c.do(ctx, "GetPublicKey", map[string]any{"KeyId": keyID}, &out)
+	return out.PublicKey, err
+}
+
+// do calls operation with in as the JSON request and decodes the
+// response into out.
+func (c *Client) do(ctx context.Context, operation string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", operation, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", operation, err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", targetPrefix+operation)
+	c.signer.Sign(req, aws.HashPayload(body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("kms %s failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode, Operation: operation}
+		_ = json.Unmarshal(data, e)
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		return e
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", operation, err)
+	}
+	return nil
+}
+
+// Error is a KMS error response.
+type Error struct {
+	StatusCode int
+	Operation  string
+	Type       string `json:"__type"`
+	Message    string `json:"message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("kms %s: HTTP %d %s: %s", e.Operation, e.StatusCode, e.Type, e.Message)
+}
+
+// Unwrap maps missing keys to ErrNotFound.
+func (e *Error) Unwrap() error {
+	if e.Type == "NotFoundException" {
+		return ErrNotFound
+	}
+	return nil
+}
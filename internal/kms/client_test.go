@@ -0,0 +1,78 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return NewClient(Options{
+		Region:      "us-east-1",
+		Endpoint:    srv.URL,
+		Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+}
+
+func TestSign(t *testing.T) {
+	digest := bytes.Repeat([]byte{0xab}, 32)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Amz-Target"); got != "TrentService.Sign" {
+			t.Errorf("X-Amz-Target = %q", got)
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "/kms/aws4_request") {
+			t.Errorf("request not signed for kms: %q", r.Header.Get("Authorization"))
+		}
+		var in struct {
+			KeyId, MessageType, SigningAlgorithm string
+			Message                              []byte
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			t.Fatal(err)
+		}
+		if in.KeyId != "alias/seal" || in.MessageType != "DIGEST" || in.SigningAlgorithm != SigningAlgorithm || !bytes.Equal(in.Message, digest) {
+			t.Errorf("Sign request = %+v", in)
+		}
+		fmt.Fprint(w, `{"KeyId":"arn:aws:kms:us-east-1:1:key/k","Signature":"MEUCIQ=="}`)
+	})
+	sig, err := c.Sign(context.Background(), "alias/seal", digest)
+	if err != nil || !bytes.Equal(sig, []byte{0x30, 0x45, 0x02, 0x21}) {
+		t.Errorf("Sign() = %x, %v", sig, err)
+	}
+}
+
+func TestGetPublicKeyNotFound(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type":"NotFoundException","message":"Alias alias/none is not found."}`)
+	})
+	_, err := c.GetPublicKey(context.Background(), "alias/none")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPublicKey() error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lambda is a minimal AWS Lambda client for shifting the
+// traffic of function aliases between versions.
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+const apiVersion = "2015-03-31"
+
+// ErrNotFound is returned when a function or alias does not exist.
+var ErrNotFound = errors.New("lambda: not found")
+
+// Options configures a Client.
+type Options struct {
+	// Region is the AWS region of the functions
+	Region string
+
+	// Endpoint overrides the regional AWS endpoint
+	Endpoint string
+
+	// Credentials sign requests
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is a Lambda client.
+type Client struct {
+	endpoint string
+	signer   *aws.Signer
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://lambda." + opts.Region + ".amazonaws.com"
+	}
+	c := &Client{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		signer:   &aws.Signer{Credentials: opts.Credentials, Region: opts.Region, Service: "lambda"},
+		http:     opts.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Alias is a function alias: a name that routes invocations to a
+// version, and optionally part of them to a second one.
+type Alias struct {
+	Name string
+
+	// Version is the version receiving the rest of the traffic
+	Version string
+
+	// Weights maps a version receiving part of the traffic to its
+	// share, from 0 to 1
+	Weights map[string]float64
+}
+
+// alias is an alias as the API encodes it.
+type alias struct {
+	Name            string `json:"Name,omitempty"`
+	FunctionVersion string `json:"FunctionVersion"`
+	RoutingConfig   struct {
+		AdditionalVersionWeights map[string]float64 `json:"AdditionalVersionWeights"`
+	} `json:"RoutingConfig"`
+}
+
+// GetAlias returns the alias name of function.
+func (c *Client) GetAlias(ctx context.Context, function, name string) (*Alias, error) {
+	var out alias
+	if err := c.do(ctx, "GetAlias", http.MethodGet, aliasPath(function, name), nil, &out); err != nil {
+		return nil, err
+	}
+	return &Alias{Name: out.Name, Version: out.FunctionVersion, Weights: out.RoutingConfig.AdditionalVersionWeights}, nil
+}
+
+// UpdateAlias routes the alias name of function to a.Version, with
+// a.Weights of the traffic sent to other versions; no other version
+// receives any if a.Weights is empty.
+func (c *Client) UpdateAlias(ctx context.Context, function, name string, a Alias) error {
+	in := alias{FunctionVersion: a.Version}
+	in.RoutingConfig.AdditionalVersionWeights = a.Weights
+	if in.RoutingConfig.AdditionalVersionWeights == nil {
+		in.RoutingConfig.AdditionalVersionWeights = map[string]float64{}
+	}
+	return c.do(ctx, "UpdateAlias", http.MethodPut, aliasPath(function, name), in, nil)
+}
+
+// AccountSettings are the Lambda limits of an account in a region.
+type AccountSettings struct {
+	// ConcurrentExecutions is the account's concurrency limit
+	ConcurrentExecutions int
+
+	// UnreservedConcurrentExecutions is the part of it not reserved
+	// by any function
+	UnreservedConcurrentExecutions int
+}
+
+// GetAccountSettings returns the account's limits in the client's
+// region.
+func (c *Client) GetAccountSettings(ctx context.Context) (*AccountSettings, error) {
+	var out struct {
+		AccountLimit AccountSettings
+	}
+	if err := c.do(ctx, "GetAccountSettings", http.MethodGet, "/2016-08-19/account-settings", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out.AccountLimit, nil
+}
+
+func aliasPath(function, name string) string {
+	return "/" + apiVersion + "/functions/" + url.PathEscape(function) + "/aliases/" + url.PathEscape(name)
+}
+
+// do calls operation with in as the JSON request body, if any, and
+// decodes the response into out, if any.
+func (c *Client) do(ctx context.Context, operation, method, path string, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode %s request: %w", operation, err)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", operation, err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.signer.Sign(req, aws.HashPayload(body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("lambda %s failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode/100 != 2 {
+		e := &Error{StatusCode: resp.StatusCode, Operation: operation, Type: resp.Header.Get("X-Amzn-Errortype")}
+		_ = json.Unmarshal(data, e)
+		e.Type, _, _ = strings.Cut(e.Type, ":")
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", operation, err)
+	}
+	return nil
+}
+
+// Error is a Lambda error response.
+type Error struct {
+	StatusCode int
+	Operation  string
+	Type       string `json:"-"`
+	Message    string `json:"message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("lambda %s: HTTP %d %s: %s", e.Operation, e.StatusCode, e.Type, e.Message)
+}
+
+// Unwrap maps missing functions and aliases to ErrNotFound.
+func (e *Error) Unwrap() error {
+	if e.Type == "ResourceNotFoundException" {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return NewClient(Options{
+		Region:      "us-east-1",
+		Endpoint:    srv.URL,
+		Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+}
+
+func TestGetAlias(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "/lambda/aws4_request") {
+			t.Errorf("request not signed for lambda: %q", r.Header.Get("Authorization"))
+		}
+		if r.Method != http.MethodGet || r.URL.Path != "/2015-03-31/functions/aperture-prod-auth/aliases/live" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		fmt.Fprint(w, `{"AliasArn": "arn:aws:lambda:us-east-1:1:function:aperture-prod-auth:live", "Name": "live",
+			"FunctionVersion": "3", "RoutingConfig": {"AdditionalVersionWeights": {"4": 0.1}}}`)
+	})
+	a, err := c.GetAlias(context.Background(), "aperture-prod-auth", "live")
+	if err != nil {
+		t.Fatalf("GetAlias() error = %v", err)
+	}
+	want := &Alias{Name: "live", Version: "3", Weights: map[string]float64{"4": 0.1}}
+	if !reflect.DeepEqual(a, want) {
+		t.Errorf("GetAlias() = %+v, want %+v", a, want)
+	}
+}
+
+func TestUpdateAlias(t *testing.T) {
+	var got []map[string]any
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/2015-03-31/functions/aperture-prod-auth/aliases/live" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, body)
+		fmt.Fprint(w, `{}`)
+	})
+	ctx := context.Background()
+	if err := c.UpdateAlias(ctx, "aperture-prod-auth", "live", Alias{Version: "3", Weights: map[string]float64{"4": 0.5}}); err != nil {
+		t.Fatalf("UpdateAlias() error = %v", err)
+	}
+	// Without weights, the routing configuration is cleared.
+	if err := c.UpdateAlias(ctx, "aperture-prod-auth", "live", Alias{Version: "4"}); err != nil {
+		t.Fatalf("UpdateAlias() error = %v", err)
+	}
+	want := []map[string]any{
+		{"FunctionVersion": "3", "RoutingConfig": map[string]any{"AdditionalVersionWeights": map[string]any{"4": 0.5}}},
+		{"FunctionVersion": "4", "RoutingConfig": map[string]any{"AdditionalVersionWeights": map[string]any{}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestGetAccountSettings(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/2016-08-19/account-settings" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		fmt.Fprint(w, `{"AccountLimit": {"ConcurrentExecutions": 1000, "UnreservedConcurrentExecutions": 900,
+			"CodeSizeUnzipped": 262144000}, "AccountUsage": {"FunctionCount": 12}}`)
+	})
+	s, err := c.GetAccountSettings(context.Background())
+	if err != nil {
+		t.Fatalf("GetAccountSettings() error = %v", err)
+	}
+	want := &AccountSettings{ConcurrentExecutions: 1000, UnreservedConcurrentExecutions: 900}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("GetAccountSettings() = %+v, want %+v", s, want)
+	}
+}
+
+func TestError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-ErrorType", "ResourceNotFoundException:http://internal.amazon.com/coral/com.amazonaws.lambda/")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"Type": "User", "Message": "Alias not found: live"}`)
+	})
+	_, err := c.GetAlias(context.Background(), "aperture-prod-auth", "live")
+	if !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "Alias not found") {
+		t.Errorf("GetAlias() error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,482 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package landing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/counter"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+	"github.com/scttfrdmn/aperture/internal/trace"
+)
+
+// pagesTable holds one Record per rendered landing page.
+const pagesTable = "landing-pages"
+
+// maxInvalidationPaths is the number of individual paths above which a
+// single wildcard invalidation is issued instead.
+const maxInvalidationPaths = 1000
+
+// Reasons a page is re-rendered.
+const (
+	ReasonNew      = "new"
+	ReasonMetadata = "metadata"
+	ReasonStats    = "stats"
+	ReasonRelated  = "related"
+	ReasonCited    = "cited"
+	ReasonConcept  = "concept"
+	ReasonTemplate = "template"
+	ReasonForced   = "forced"
+)
+
+// Record tracks the inputs a landing page was last rendered from.
+type Record struct {
+	DatasetID    string    `json:"datasetId"`
+	Key          string    `json:"key"`
+	MetadataHash string    `json:"metadataHash"`
+	StatsHash    string    `json:"statsHash"`
+	RelatedHash  string    `json:"relatedHash,omitempty"`
+	CitedHash    string    `json:"citedHash,omitempty"`
+	TemplateHash string    `json:"templateHash"`
+	RenderedAt   time.Time `json:"renderedAt"`
+
+	// Concept is where the dataset's concept DOI resolved when the page
+	// was rendered; collection.ConceptLatest if empty
+	Concept string `json:"concept,omitempty"`
+}
+
+// Publisher stores rendered pages. *s3.Client implements it.
+type Publisher interface {
+	PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error
+	DeleteObject(ctx context.Context, bucket, key string) error
+}
+
+// Invalidator clears cached copies of changed pages.
+type Invalidator interface {
+	Invalidate(ctx context.Context, paths []string) (string, error)
+}
+
+// StatsSource supplies usage counters shown on landing pages.
+type StatsSource interface {
+	Stats(ctx context.Context, datasetID string) (map[string]int64, error)
+}
+
+// RelatedSource recommends datasets related to a dataset, linked from
+// its landing page. *search.Searcher implements it.
+type RelatedSource interface {
+	Related(ctx context.Context, d *dataset.Dataset) ([]Related, error)
+}
+
+// CitationSource lists the works citing a dataset, shown on its landing
+// page. *citation.Tracker implements it.
+type CitationSource interface {
+	Citations(ctx context.Context, d *dataset.Dataset) (*Citations, error)
+}
+
+// ConceptSource says where the concept DOIs of a collection's datasets
+// resolve. *collection.Registry implements it.
+type ConceptSource interface {
+	ConceptTarget(ctx context.Context, collection string) (string, error)
+}
+
+// Builder renders landing pages for datasets whose inputs changed.
+type Builder struct {
+	Datasets    *dataset.Store
+	State       state.Store
+	Renderer    *Renderer
+	Publisher   Publisher
+	Bucket      string
+	Invalidator Invalidator
+	Stats       StatsSource
+
+	// Related supplies the related datasets linked from pages; none are
+	// linked if nil
+	Related RelatedSource
+
+	// Citations supplies the works citing datasets; none are listed if
+	// nil
+	Citations CitationSource
+
+	// Concepts says which datasets' concept DOIs resolve to a listing
+	// of their versions, rendered beside their pages; every concept DOI
+	// resolves to the latest version if nil
+	Concepts ConceptSource
+
+	// DownloadURL is the base URL of the download redirect endpoint;
+	// files are not linked if empty
+	DownloadURL string
+
+	// SiteURL is the public base URL of landing pages, anchoring their
+	// linksets; anchors are relative if empty
+	SiteURL string
+}
+
+// RebuildOptions selects which pages a rebuild considers.
+type RebuildOptions struct {
+	// ChangedSince limits the rebuild to datasets updated at or after
+	// this time, plus pages never rendered or rendered with an older
+	// template
+	ChangedSince time.Time
+
+	// Force re-renders every selected page
+	Force bool
+
+	// DryRun reports what would change without rendering or uploading
+	DryRun bool
+}
+
+// Change is a page that was (or would be) re-rendered.
+type Change struct {
+	DatasetID string `json:"datasetId"`
+	Path      string `json:"path"`
+	Reason    string `json:"reason"`
+}
+
+// Result summarizes a rebuild.
+type Result struct {
+	Changes      []Change `json:"changes"`
+	Unchanged    int      `json:"unchanged"`
+	Invalidation string   `json:"invalidation,omitempty"`
+}
+
+// Rebuild re-renders the pages whose metadata, stats, or template
+// changed and invalidates them in the CDN.
+func (b *Builder) Rebuild(ctx context.Context, opts RebuildOptions) (_ *Result, err error) {
+	ctx, span := trace.Start(ctx, "landing.rebuild", trace.Bool("landing.force", opts.Force), trace.Bool("landing.dry_run", opts.DryRun))
+	defer func() { span.End(err) }()
+	datasets, err := b.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	for _, d := range datasets {
+		if d.State != dataset.StatePublished {
+			continue
+		}
+
+		prev, err := b.record(ctx, d.ID)
+		if err != nil {
+			return nil, err
+		}
+		templateChanged := prev != nil && prev.TemplateHash != b.Renderer.TemplateHash()
+		if !opts.ChangedSince.IsZero() && prev != nil && d.UpdatedAt.Before(opts.ChangedSince) && !templateChanged && !opts.Force {
+			res.Unchanged++
+			continue
+		}
+
+		change, err := b.build(ctx, d, prev, opts)
+		if err != nil {
+			return nil, err
+		}
+		if change == nil {
+			res.Unchanged++
+			continue
+		}
+		res.Changes = append(res.Changes, *change)
+	}
+
+	if opts.DryRun {
+		return res, nil
+	}
+	res.Invalidation, err = b.invalidate(ctx, res.Changes)
+	return res, err
+}
+
+// Publish renders a single dataset's page if its inputs changed, for
+// use after a metadata edit or stats refresh.
+func (b *Builder) Publish(ctx context.Context, datasetID string) (*Change, error) {
+	d, err := b.Datasets.Get(ctx, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := b.record(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	change, err := b.build(ctx, d, prev, RebuildOptions{})
+	if err != nil || change == nil {
+		return change, err
+	}
+	if _, err := b.invalidate(ctx, []Change{*change}); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// Check reports whether d's page is missing or stale without
+// rendering it. It returns nil if the page is current.
+func (b *Builder) Check(ctx context.Context, d *dataset.Dataset) (*Change, error) {
+	prev, err := b.record(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	return b.build(ctx, d, prev, RebuildOptions{DryRun: true})
+}
+
+// Records returns the tracking records of every rendered page.
+func (b *Builder) Records(ctx context.Context) ([]Record, error) {
+	return state.List[Record](ctx, b.State, pagesTable)
+}
+
+// Forget removes the tracking record of a dataset's page, so that the
+// page is treated as new if the dataset reappears.
+func (b *Builder) Forget(ctx context.Context, datasetID string) error {
+	return b.State.Delete(ctx, pagesTable, datasetID)
+}
+
+// build renders and uploads d's page if any input changed since prev.
+func (b *Builder) build(ctx context.Context, d *dataset.Dataset, prev *Record, opts RebuildOptions) (_ *Change, err error) {
+	ctx, span := trace.Start(ctx, "landing.build", trace.String("dataset.id", d.ID))
+	defer func() { span.End(err) }()
+	var stats map[string]int64
+	if b.Stats != nil {
+		var err error
+		if stats, err = b.Stats.Stats(ctx, d.ID); err != nil {
+			return nil, fmt.Errorf("failed to load stats for %s: %w", d.ID, err)
+		}
+	}
+
+	var related []Related
+	if b.Related != nil {
+		var err error
+		if related, err = b.Related.Related(ctx, d); err != nil {
+			return nil, fmt.Errorf("failed to find datasets related to %s: %w", d.ID, err)
+		}
+	}
+
+	var cited *Citations
+	if b.Citations != nil {
+		var err error
+		if cited, err = b.Citations.Citations(ctx, d); err != nil {
+			return nil, fmt.Errorf("failed to load citations of %s: %w", d.ID, err)
+		}
+		if cited != nil && cited.Count == 0 {
+			cited = nil
+		}
+	}
+
+	concept := collection.ConceptLatest
+	if b.Concepts != nil {
+		var err error
+		if concept, err = b.Concepts.ConceptTarget(ctx, d.Collection); err != nil {
+			return nil, fmt.Errorf("failed to read where the concept DOI of %s resolves: %w", d.ID, err)
+		}
+	}
+
+	rec := Record{
+		DatasetID:    d.ID,
+		Key:          PageKey(d.ID),
+		MetadataHash: metadataHash(d),
+		StatsHash:    digest(stats),
+		TemplateHash: b.Renderer.TemplateHash(),
+	}
+	if len(related) > 0 {
+		rec.RelatedHash = digest(related)
+	}
+	if cited != nil {
+		rec.CitedHash = digest(cited)
+	}
+	if concept != collection.ConceptLatest {
+		rec.Concept = concept
+	}
+
+	reason := changeReason(prev, &rec)
+	if reason == "" && opts.Force {
+		reason = ReasonForced
+	}
+	if reason == "" {
+		return nil, nil
+	}
+	change := &Change{DatasetID: d.ID, Path: PagePath(d.ID), Reason: reason}
+	span.SetAttrs(trace.String("landing.reason", reason))
+	if opts.DryRun {
+		return change, nil
+	}
+
+	downloads := DownloadLinks(b.DownloadURL, d, time.Now())
+	page := Page{Dataset: d, Version: d.Current(), Stats: stats, Downloads: downloads, Signposts: Signposts(d, downloads), Related: related, Cited: cited, Versions: PublishedVersions(d), Concept: concept}
+	html, err := b.Renderer.Render(page)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.listVersions(ctx, d, page, prev); err != nil {
+		return nil, err
+	}
+	linkset, err := Linkset(strings.TrimRight(b.SiteURL, "/")+PagePath(d.ID), page.Signposts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode linkset for %s: %w", d.ID, err)
+	}
+	if err := b.Publisher.PutObject(ctx, b.Bucket, LinksetKey(d.ID), linkset, LinksetType); err != nil {
+		return nil, fmt.Errorf("failed to upload linkset for %s: %w", d.ID, err)
+	}
+	if err := b.Publisher.PutObject(ctx, b.Bucket, rec.Key, html, "text/html; charset=utf-8"); err != nil {
+		return nil, fmt.Errorf("failed to upload landing page for %s: %w", d.ID, err)
+	}
+
+	rec.RenderedAt = time.Now().UTC()
+	if err := b.State.Put(ctx, pagesTable, d.ID, rec); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// listVersions uploads the listing of d's versions that its concept
+// DOI resolves to, or removes the listing rendered before if the
+// concept DOI now resolves to the latest version.
+func (b *Builder) listVersions(ctx context.Context, d *dataset.Dataset, page Page, prev *Record) error {
+	if page.Concept != collection.ConceptVersions {
+		if prev == nil || prev.Concept != collection.ConceptVersions {
+			return nil
+		}
+		if err := b.Publisher.DeleteObject(ctx, b.Bucket, VersionsKey(d.ID)); err != nil {
+			return fmt.Errorf("failed to remove the version listing of %s: %w", d.ID, err)
+		}
+		return nil
+	}
+	page.Listing = true
+	page.Downloads, page.Related, page.Cited = nil, nil, nil
+	page.Versions = publishedVersions(d)
+	html, err := b.Renderer.Render(page)
+	if err != nil {
+		return err
+	}
+	if err := b.Publisher.PutObject(ctx, b.Bucket, VersionsKey(d.ID), html, "text/html; charset=utf-8"); err != nil {
+		return fmt.Errorf("failed to upload the version listing of %s: %w", d.ID, err)
+	}
+	return nil
+}
+
+// DownloadLinks returns counted download links under base for the files
+// of d's current version, or nil if base is empty or d is not publicly
+// downloadable at time now.
+func DownloadLinks(base string, d *dataset.Dataset, now time.Time) map[string]string {
+	v := d.Current()
+	if base == "" || v == nil || d.Access != storage.AccessPublic || d.Embargoed(now) {
+		return nil
+	}
+	base = strings.TrimRight(base, "/")
+	links := make(map[string]string, len(v.Files))
+	for _, f := range v.Files {
+		links[f.Path] = base + counter.DownloadPath(d.ID, v.Number, f.Path)
+	}
+	return links
+}
+
+// invalidate clears the changed paths from the CDN, collapsing large
+// batches into a single wildcard.
+func (b *Builder) invalidate(ctx context.Context, changes []Change) (_ string, err error) {
+	if b.Invalidator == nil || len(changes) == 0 {
+		return "", nil
+	}
+	ctx, span := trace.Start(ctx, "landing.invalidate", trace.Int("landing.paths", int64(len(changes))))
+	defer func() { span.End(err) }()
+	paths := make([]string, 0, len(changes))
+	for _, c := range changes {
+		paths = append(paths, c.Path+"*")
+	}
+	if len(paths) > maxInvalidationPaths {
+		paths = []string{"/datasets/*"}
+	}
+	id, err := b.Invalidator.Invalidate(ctx, paths)
+	if err != nil {
+		return "", fmt.Errorf("failed to invalidate landing pages: %w", err)
+	}
+	return id, nil
+}
+
+// record returns the tracking record for a dataset's page, or nil if
+// the page has never been rendered.
+func (b *Builder) record(ctx context.Context, id string) (*Record, error) {
+	var rec Record
+	err := b.State.Get(ctx, pagesTable, id, &rec)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// changeReason returns why cur differs from prev, or "" if it does not.
+func changeReason(prev, cur *Record) string {
+	switch {
+	case prev == nil:
+		return ReasonNew
+	case prev.TemplateHash != cur.TemplateHash:
+		return ReasonTemplate
+	case prev.MetadataHash != cur.MetadataHash:
+		return ReasonMetadata
+	case prev.StatsHash != cur.StatsHash:
+		return ReasonStats
+	case prev.RelatedHash != cur.RelatedHash:
+		return ReasonRelated
+	case prev.CitedHash != cur.CitedHash:
+		return ReasonCited
+	case prev.Concept != cur.Concept:
+		return ReasonConcept
+	}
+	return ""
+}
+
+// PageKey returns the object key of a dataset's landing page.
+func PageKey(id string) string {
+	return "datasets/" + id + "/index.html"
+}
+
+// PagePath returns the URL path of a dataset's landing page.
+func PagePath(id string) string {
+	return "/datasets/" + id + "/"
+}
+
+// VersionsKey returns the object key of the listing of a dataset's
+// versions.
+func VersionsKey(id string) string {
+	return "datasets/" + id + "/versions/index.html"
+}
+
+// VersionsPath returns the URL path of the listing of a dataset's
+// versions, where its concept DOI resolves if its collection's
+// stewards chose collection.ConceptVersions.
+func VersionsPath(id string) string {
+	return PagePath(id) + "versions/"
+}
+
+// metadataHash digests the dataset fields shown on its page. The
+// update timestamp is excluded so that bookkeeping writes do not
+// trigger re-renders.
+func metadataHash(d *dataset.Dataset) string {
+	cp := *d
+	cp.UpdatedAt = time.Time{}
+	return digest(cp)
+}
+
+// digest returns the hex SHA-256 of v's JSON encoding.
+func digest(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,447 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package landing
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+type fakePublisher struct {
+	objects map[string]string
+}
+
+func (p *fakePublisher) PutObject(_ context.Context, bucket, key string, body []byte, _ string) error {
+	p.objects[bucket+"/"+key] = string(body)
+	return nil
+}
+
+func (p *fakePublisher) DeleteObject(_ context.Context, bucket, key string) error {
+	delete(p.objects, bucket+"/"+key)
+	return nil
+}
+
+type fakeInvalidator struct {
+	batches [][]string
+}
+
+func (f *fakeInvalidator) Invalidate(_ context.Context, paths []string) (string, error) {
+	f.batches = append(f.batches, paths)
+	return "I1", nil
+}
+
+type fakeStats map[string]map[string]int64
+
+func (f fakeStats) Stats(_ context.Context, id string) (map[string]int64, error) {
+	return f[id], nil
+}
+
+func newTestBuilder(t *testing.T) (*Builder, *fakePublisher, *fakeInvalidator, fakeStats) {
+	t.Helper()
+	ctx := context.Background()
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", Title: "Ocean Temperatures", State: dataset.StatePublished, DOI: "10.5555/ds-1"},
+		{ID: "ds-2", Title: "Bird Songs", State: dataset.StatePublished},
+		{ID: "ds-3", Title: "Draft", State: dataset.StateDraft},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+
+	r, err := NewRenderer(DefaultTemplates())
+	if err != nil {
+		t.Fatalf("NewRenderer() error = %v", err)
+	}
+	pub := &fakePublisher{objects: map[string]string{}}
+	inv := &fakeInvalidator{}
+	stats := fakeStats{}
+	return &Builder{
+		Datasets:    datasets,
+		State:       s,
+		Renderer:    r,
+		Publisher:   pub,
+		Bucket:      "frontend",
+		Invalidator: inv,
+		Stats:       stats,
+	}, pub, inv, stats
+}
+
+func reasons(res *Result) map[string]string {
+	out := map[string]string{}
+	for _, c := range res.Changes {
+		out[c.DatasetID] = c.Reason
+	}
+	return out
+}
+
+func TestRebuildOnlyChangedPages(t *testing.T) {
+	ctx := context.Background()
+	b, pub, inv, stats := newTestBuilder(t)
+
+	res, err := b.Rebuild(ctx, RebuildOptions{})
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if got := reasons(res); len(got) != 2 || got["ds-1"] != ReasonNew || got["ds-2"] != ReasonNew {
+		t.Fatalf("first Rebuild() changes = %v, want ds-1 and ds-2 new", got)
+	}
+	if !strings.Contains(pub.objects["frontend/datasets/ds-1/index.html"], "Ocean Temperatures") {
+		t.Error("ds-1 page missing title")
+	}
+	if !strings.Contains(pub.objects["frontend/"+LinksetKey("ds-1")], `"href": "https://doi.org/10.5555/ds-1"`) {
+		t.Errorf("ds-1 linkset = %s", pub.objects["frontend/"+LinksetKey("ds-1")])
+	}
+	if len(inv.batches) != 1 || len(inv.batches[0]) != 2 {
+		t.Errorf("invalidations = %v, want one batch of 2", inv.batches)
+	}
+
+	res, err = b.Rebuild(ctx, RebuildOptions{})
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if len(res.Changes) != 0 || res.Unchanged != 2 {
+		t.Errorf("unchanged Rebuild() = %+v, want no changes", res)
+	}
+
+	d, _ := b.Datasets.Get(ctx, "ds-1")
+	d.Title = "Ocean Temperatures 1990-2020"
+	if err := b.Datasets.Put(ctx, d); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	stats["ds-2"] = map[string]int64{"downloads": 5}
+
+	res, err = b.Rebuild(ctx, RebuildOptions{})
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if got := reasons(res); got["ds-1"] != ReasonMetadata || got["ds-2"] != ReasonStats {
+		t.Errorf("Rebuild() changes = %v, want ds-1 metadata and ds-2 stats", got)
+	}
+}
+
+type fakeRelated map[string][]Related
+
+func (f fakeRelated) Related(_ context.Context, d *dataset.Dataset) ([]Related, error) {
+	return f[d.ID], nil
+}
+
+func TestRebuildRelatedChange(t *testing.T) {
+	ctx := context.Background()
+	b, pub, _, _ := newTestBuilder(t)
+	related := fakeRelated{}
+	b.Related = related
+	if _, err := b.Rebuild(ctx, RebuildOptions{}); err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if strings.Contains(pub.objects["frontend/datasets/ds-1/index.html"], "Related datasets") {
+		t.Error("ds-1 page lists related datasets before any are recommended")
+	}
+
+	related["ds-1"] = []Related{{Title: "Bird Songs", URL: PagePath("ds-2")}}
+	res, err := b.Rebuild(ctx, RebuildOptions{})
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if got := reasons(res); len(got) != 1 || got["ds-1"] != ReasonRelated {
+		t.Errorf("Rebuild() changes = %v, want ds-1 related", got)
+	}
+	if !strings.Contains(pub.objects["frontend/datasets/ds-1/index.html"], `<li><a href="/datasets/ds-2/">Bird Songs</a></li>`) {
+		t.Errorf("ds-1 page = %s", pub.objects["frontend/datasets/ds-1/index.html"])
+	}
+}
+
+type fakeCitations map[string]*Citations
+
+func (f fakeCitations) Citations(_ context.Context, d *dataset.Dataset) (*Citations, error) {
+	return f[d.ID], nil
+}
+
+func TestRebuildCitedChange(t *testing.T) {
+	ctx := context.Background()
+	b, pub, _, _ := newTestBuilder(t)
+	cited := fakeCitations{"ds-2": {}}
+	b.Citations = cited
+	if _, err := b.Rebuild(ctx, RebuildOptions{}); err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if strings.Contains(pub.objects["frontend/datasets/ds-2/index.html"], "Cited by") {
+		t.Error("ds-2 page lists citations before any are found")
+	}
+
+	cited["ds-1"] = &Citations{Count: 1, Works: []Citation{{Title: "Soil respiration", Year: 2024, URL: "https://doi.org/10.1000/paper-a"}}}
+	res, err := b.Rebuild(ctx, RebuildOptions{})
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if got := reasons(res); len(got) != 1 || got["ds-1"] != ReasonCited {
+		t.Errorf("Rebuild() changes = %v, want ds-1 cited", got)
+	}
+	page := pub.objects["frontend/datasets/ds-1/index.html"]
+	if !strings.Contains(page, "Cited by 1 work<") || !strings.Contains(page, `<a href="https://doi.org/10.1000/paper-a">Soil respiration</a> <span class="year">(2024)</span>`) {
+		t.Errorf("ds-1 page = %s", page)
+	}
+}
+
+func TestPublishSupersededBanners(t *testing.T) {
+	ctx := context.Background()
+	b, pub, _, _ := newTestBuilder(t)
+	old, err := b.Datasets.Get(ctx, "ds-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.SupersededBy = &dataset.Supersession{Dataset: "ds-2", Title: "Bird Songs", URL: "https://hdl.handle.net/1234/2", Reason: "Recalibrated"}
+	repl, err := b.Datasets.Get(ctx, "ds-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repl.Supersedes = []dataset.Supersession{{Dataset: "ds-1", Title: "Ocean Temperatures", URL: "https://doi.org/10.5555/ds-1"}}
+	for _, d := range []*dataset.Dataset{old, repl} {
+		if err := b.Datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.Publish(ctx, d.ID); err != nil {
+			t.Fatalf("Publish(%s) error = %v", d.ID, err)
+		}
+	}
+	if page := pub.objects["frontend/datasets/ds-1/index.html"]; !strings.Contains(page,
+		`<p class="superseded" role="alert">This dataset has been superseded by <a href="https://hdl.handle.net/1234/2">Bird Songs</a>: Recalibrated</p>`) {
+		t.Errorf("superseded page = %s", page)
+	}
+	if page := pub.objects["frontend/datasets/ds-2/index.html"]; !strings.Contains(page,
+		`<p class="supersedes">This dataset replaces <a href="https://doi.org/10.5555/ds-1">Ocean Temperatures</a></p>`) {
+		t.Errorf("replacement page = %s", page)
+	}
+}
+
+func TestPublishPrunedVersions(t *testing.T) {
+	ctx := context.Background()
+	b, pub, _, _ := newTestBuilder(t)
+	d, err := b.Datasets.Get(ctx, "ds-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	d.Versions = []dataset.Version{
+		{Number: 1, PublishedAt: &t0, DOI: "10.5555/ds-1.v1", Pruned: &dataset.Pruning{Action: dataset.PruneDelete, Time: t0.AddDate(0, 3, 0)}},
+		{Number: 2, PublishedAt: &t0, Pruned: &dataset.Pruning{Action: dataset.PruneArchive, Time: t0}},
+		{Number: 3, PublishedAt: &t0},
+	}
+	if err := b.Datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Publish(ctx, d.ID); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	page := pub.objects["frontend/datasets/ds-1/index.html"]
+	for _, want := range []string{`<li id="v1">`, `(files removed 1 June 2025)`, `(archived)`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page lacks %q: %s", want, page)
+		}
+	}
+}
+
+type fakeConcepts map[string]string
+
+func (f fakeConcepts) ConceptTarget(_ context.Context, id string) (string, error) {
+	return cmp.Or(f[id], collection.ConceptLatest), nil
+}
+
+func TestPublishVersionListing(t *testing.T) {
+	ctx := context.Background()
+	b, pub, _, _ := newTestBuilder(t)
+	concepts := fakeConcepts{"sensors": collection.ConceptVersions}
+	b.Concepts = concepts
+	d, err := b.Datasets.Get(ctx, "ds-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	d.Collection = "sensors"
+	d.Versions = []dataset.Version{
+		{Number: 1, PublishedAt: &t0, DOI: "10.5555/ds-1.v1", Files: []dataset.File{{Path: "old.csv"}}},
+		{Number: 2, PublishedAt: &t0, DOI: "10.5555/ds-1.v2", Files: []dataset.File{{Path: "new.csv"}}},
+	}
+	if err := b.Datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Publish(ctx, d.ID); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	listing, ok := pub.objects["frontend/"+VersionsKey("ds-1")]
+	if !ok {
+		t.Fatal("version listing not uploaded")
+	}
+	for _, want := range []string{`<li id="v1">`, `<li id="v2">`, `href="../"`} {
+		if !strings.Contains(listing, want) {
+			t.Errorf("listing lacks %q: %s", want, listing)
+		}
+	}
+	if strings.Contains(listing, "new.csv") {
+		t.Errorf("listing lists files: %s", listing)
+	}
+	if page := pub.objects["frontend/datasets/ds-1/index.html"]; !strings.Contains(page, `href="versions/"`) || !strings.Contains(page, "new.csv") {
+		t.Errorf("page = %s", page)
+	}
+
+	concepts["sensors"] = collection.ConceptLatest
+	change, err := b.Publish(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if change == nil || change.Reason != ReasonConcept {
+		t.Errorf("Publish() change = %+v, want reason %q", change, ReasonConcept)
+	}
+	if _, ok := pub.objects["frontend/"+VersionsKey("ds-1")]; ok {
+		t.Error("version listing not removed")
+	}
+	if page := pub.objects["frontend/datasets/ds-1/index.html"]; strings.Contains(page, `href="versions/"`) {
+		t.Errorf("page still links the listing: %s", page)
+	}
+}
+
+func TestRebuildTemplateChange(t *testing.T) {
+	ctx := context.Background()
+	b, pub, _, _ := newTestBuilder(t)
+
+	if _, err := b.Rebuild(ctx, RebuildOptions{}); err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+
+	theme := fstest.MapFS{"dataset.html": {Data: []byte(`<h1 class="theme">{{.Dataset.Title}}</h1>`)}}
+	r, err := NewRenderer(theme)
+	if err != nil {
+		t.Fatalf("NewRenderer() error = %v", err)
+	}
+	b.Renderer = r
+
+	// Template changes apply even to datasets outside the window.
+	res, err := b.Rebuild(ctx, RebuildOptions{ChangedSince: time.Now().Add(time.Hour), DryRun: true})
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if got := reasons(res); got["ds-1"] != ReasonTemplate || got["ds-2"] != ReasonTemplate {
+		t.Fatalf("dry-run Rebuild() = %v, want template changes", got)
+	}
+	if strings.Contains(pub.objects["frontend/datasets/ds-1/index.html"], "theme") {
+		t.Fatal("dry run uploaded pages")
+	}
+
+	if _, err := b.Rebuild(ctx, RebuildOptions{}); err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if !strings.Contains(pub.objects["frontend/datasets/ds-1/index.html"], `class="theme"`) {
+		t.Error("page not re-rendered with new template")
+	}
+}
+
+func TestChangedSinceSkipsOldDatasets(t *testing.T) {
+	ctx := context.Background()
+	b, _, _, stats := newTestBuilder(t)
+
+	if _, err := b.Rebuild(ctx, RebuildOptions{}); err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	stats["ds-1"] = map[string]int64{"views": 1}
+
+	res, err := b.Rebuild(ctx, RebuildOptions{ChangedSince: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if len(res.Changes) != 0 || res.Unchanged != 2 {
+		t.Errorf("Rebuild() = %+v, want both skipped", res)
+	}
+}
+
+func TestDownloadLinks(t *testing.T) {
+	now := time.Now()
+	files := []dataset.Version{{Number: 2, Files: []dataset.File{{Path: "a b.csv"}}}}
+	drafted := []dataset.Version{{Number: 1, PublishedAt: &now, Files: []dataset.File{{Path: "a b.csv"}}}, {Number: 2}}
+	tests := []struct {
+		name string
+		d    *dataset.Dataset
+		want string
+	}{
+		{"public", &dataset.Dataset{ID: "ds-1", Access: storage.AccessPublic, Versions: files}, "https://dl.example.org/d/ds-1/v2/a%20b.csv"},
+		{"private", &dataset.Dataset{ID: "ds-1", Access: storage.AccessPrivate, Versions: files}, ""},
+		{"next version drafted", &dataset.Dataset{ID: "ds-1", Access: storage.AccessPublic, Versions: drafted}, "https://dl.example.org/d/ds-1/v1/a%20b.csv"},
+		{"embargoed", &dataset.Dataset{ID: "ds-1", Access: storage.AccessPublic, Versions: files, Embargo: &dataset.Embargo{Until: now.Add(time.Hour)}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DownloadLinks("https://dl.example.org/", tt.d, now)["a b.csv"]
+			if got != tt.want {
+				t.Errorf("DownloadLinks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignposts(t *testing.T) {
+	d := &dataset.Dataset{
+		ID:       "ds-1",
+		DOI:      "10.5555/ds-1",
+		Creators: []dataset.Creator{{Name: "Lovelace, Ada", ORCID: "0000-0002-1825-0097"}, {Name: "Babbage, Charles"}},
+		Software: &dataset.Software{Repository: "https://github.com/uni/tool", License: "MIT"},
+		Versions: []dataset.Version{{Number: 1, Files: []dataset.File{{Path: "a.csv", ContentType: "text/csv"}, {Path: "b.csv"}}}},
+	}
+	links := Signposts(d, map[string]string{"a.csv": "https://dl.example.org/d/ds-1/v1/a.csv"})
+	var got []string
+	for _, l := range links {
+		got = append(got, l.Rel+" "+l.Href+" "+l.Type)
+	}
+	want := []string{
+		"cite-as https://doi.org/10.5555/ds-1 ",
+		"describedby https://data.crosscite.org/application/vnd.datacite.datacite+json/10.5555/ds-1 application/vnd.datacite.datacite+json",
+		"type https://schema.org/SoftwareSourceCode ",
+		"type https://schema.org/AboutPage ",
+		"author https://orcid.org/0000-0002-1825-0097 ",
+		"license https://spdx.org/licenses/MIT ",
+		"item https://dl.example.org/d/ds-1/v1/a.csv text/csv",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Signposts() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	data, err := Linkset("https://data.uni.edu/datasets/ds-1/", links)
+	if err != nil {
+		t.Fatalf("Linkset() error = %v", err)
+	}
+	var set struct {
+		Linkset []map[string]json.RawMessage `json:"linkset"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil || len(set.Linkset) != 1 {
+		t.Fatalf("Linkset() = %s, %v", data, err)
+	}
+	var types []Link
+	if err := json.Unmarshal(set.Linkset[0]["type"], &types); err != nil || len(types) != 2 {
+		t.Errorf("Linkset() type = %s, %v", set.Linkset[0]["type"], err)
+	}
+	if string(set.Linkset[0]["anchor"]) != `"https://data.uni.edu/datasets/ds-1/"` {
+		t.Errorf("Linkset() anchor = %s", set.Linkset[0]["anchor"])
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package landing
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// versionPath is polled by preview pages to detect template changes.
+const versionPath = "/_preview/version"
+
+// reloadScript reloads the page when the template set changes.
+const reloadScript = `<script>
+(function () {
+  var seen = null;
+  setInterval(function () {
+    fetch("` + versionPath + `").then(function (r) { return r.text(); }).then(function (v) {
+      if (seen !== null && v !== seen) { location.reload(); }
+      seen = v;
+    }).catch(function () {});
+  }, 1000);
+})();
+</script>
+`
+
+// DatasetSource supplies the datasets shown by a preview server.
+type DatasetSource func(ctx context.Context) ([]*dataset.Dataset, error)
+
+// Preview serves landing pages rendered from a template directory that
+// is re-read on every request, so theme edits show up on reload. Pages
+// poll the server and reload themselves when a template changes.
+type Preview struct {
+	templates fs.FS
+	datasets  DatasetSource
+	sample    bool
+	mux       *http.ServeMux
+
+	mu       sync.Mutex
+	hash     string
+	renderer *Renderer
+	err      error
+}
+
+// NewPreview returns a preview server for the templates in fsys. If
+// datasets is nil the sample dataset is shown.
+func NewPreview(fsys fs.FS, datasets DatasetSource) *Preview {
+	p := &Preview{templates: fsys, datasets: datasets, mux: http.NewServeMux()}
+	if datasets == nil {
+		p.sample = true
+		p.datasets = func(context.Context) ([]*dataset.Dataset, error) {
+			return []*dataset.Dataset{SampleDataset()}, nil
+		}
+	}
+	p.mux.HandleFunc("GET /{$}", p.serveIndex)
+	p.mux.HandleFunc("GET /datasets/{id}/", p.servePage)
+	p.mux.HandleFunc("GET "+versionPath, p.serveVersion)
+	return p
+}
+
+// ServeHTTP implements http.Handler.
+func (p *Preview) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+// load returns the renderer for the current templates, re-parsing them
+// if any file changed since the last request.
+func (p *Preview) load() (*Renderer, string, error) {
+	hash, err := hashTemplates(p.templates)
+	if err != nil {
+		return nil, "", err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if hash != p.hash {
+		p.hash = hash
+		p.renderer, p.err = validatedRenderer(p.templates)
+	}
+	return p.renderer, p.hash, p.err
+}
+
+func (p *Preview) serveVersion(w http.ResponseWriter, _ *http.Request) {
+	_, hash, _ := p.load()
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(hash))
+}
+
+func (p *Preview) serveIndex(w http.ResponseWriter, r *http.Request) {
+	datasets, err := p.datasets(r.Context())
+	if err != nil {
+		p.serveError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := indexTemplate.Execute(&buf, datasets); err != nil {
+		p.serveError(w, http.StatusInternalServerError, err)
+		return
+	}
+	p.write(w, http.StatusOK, buf.Bytes())
+}
+
+func (p *Preview) servePage(w http.ResponseWriter, r *http.Request) {
+	datasets, err := p.datasets(r.Context())
+	if err != nil {
+		p.serveError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var d *dataset.Dataset
+	for _, c := range datasets {
+		if c.ID == r.PathValue("id") {
+			d = c
+			break
+		}
+	}
+	if d == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	renderer, _, err := p.load()
+	if err != nil {
+		p.serveError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var stats map[string]int64
+	if p.sample {
+		stats = SampleStats()
+	}
+	html, err := renderer.Render(Page{Dataset: d, Version: d.Latest(), Stats: stats, Signposts: Signposts(d, nil)})
+	if err != nil {
+		p.serveError(w, http.StatusInternalServerError, err)
+		return
+	}
+	p.write(w, http.StatusOK, html)
+}
+
+// serveError shows err in the browser; the page keeps polling so that
+// fixing the template reloads it.
+func (p *Preview) serveError(w http.ResponseWriter, status int, err error) {
+	var buf bytes.Buffer
+	_ = errorTemplate.Execute(&buf, strings.Split(err.Error(), "\n"))
+	p.write(w, status, buf.Bytes())
+}
+
+// write sends an HTML page with the reload script injected.
+func (p *Preview) write(w http.ResponseWriter, status int, html []byte) {
+	if i := bytes.LastIndex(html, []byte("</body>")); i >= 0 {
+		html = append(html[:i:i], append([]byte(reloadScript), html[i:]...)...)
+	} else {
+		html = append(html, reloadScript...)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(html)
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Landing page preview</title></head>
+<body>
+  <h1>Landing page preview</h1>
+  <ul>
+    {{- range .}}
+    <li><a href="/datasets/{{.ID}}/">{{.Title}}</a> <small>{{.ID}}</small></li>
+    {{- else}}
+    <li>No datasets</li>
+    {{- end}}
+  </ul>
+</body>
+</html>
+`))
+
+var errorTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Template error</title></head>
+<body>
+  <h1>Template error</h1>
+  <pre>{{range .}}{{.}}
+{{end}}</pre>
+</body>
+</html>
+`))
@@ -0,0 +1,102 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package landing
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidateTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		wantErr string
+	}{
+		{"default", "", ""},
+		{"allowed funcs", `{{if gt (len .Dataset.Creators) 1}}{{printf "%d" .Dataset.PublicationYear}}{{end}}`, ""},
+		{"call", `{{call .Dataset.Title}}`, `dataset.html:1:2: function "call" is not allowed`},
+		{"unknown func", `{{range .Dataset.Versions}}{{exec .Number}}{{end}}`, `function "exec" is not allowed`},
+		{"syntax", `{{if .Dataset.Title}}`, "unexpected EOF"},
+		{"missing field", `{{.Dataset.Nope}}`, "can't evaluate field Nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := DefaultTemplates()
+			if tt.tmpl != "" {
+				fsys = fstest.MapFS{"dataset.html": {Data: []byte(tt.tmpl)}}
+			}
+			err := ValidateTemplates(fsys)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateTemplates() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateTemplates() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func get(t *testing.T, srv *httptest.Server, path string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestPreviewLiveReload(t *testing.T) {
+	theme := fstest.MapFS{"dataset.html": {Data: []byte(`<body><h1>{{.Dataset.Title}}</h1></body>`)}}
+	srv := httptest.NewServer(NewPreview(theme, nil))
+	defer srv.Close()
+
+	status, body := get(t, srv, "/")
+	if status != http.StatusOK || !strings.Contains(body, `href="/datasets/sample/"`) {
+		t.Fatalf("GET / = %d %q, want link to sample", status, body)
+	}
+
+	status, body = get(t, srv, "/datasets/sample/")
+	if status != http.StatusOK || !strings.Contains(body, "<h1>Coastal Ocean") {
+		t.Fatalf("GET page = %d %q, want sample title", status, body)
+	}
+	if !strings.Contains(body, versionPath) {
+		t.Error("page missing live reload script")
+	}
+	_, v1 := get(t, srv, versionPath)
+
+	theme["dataset.html"] = &fstest.MapFile{Data: []byte(`<body>{{call .Dataset.Title}}</body>`)}
+	_, v2 := get(t, srv, versionPath)
+	if v1 == v2 {
+		t.Error("version unchanged after template edit")
+	}
+	status, body = get(t, srv, "/datasets/sample/")
+	if status != http.StatusInternalServerError || !strings.Contains(body, "is not allowed") || !strings.Contains(body, versionPath) {
+		t.Errorf("GET broken page = %d %q, want error page with reload script", status, body)
+	}
+
+	if status, _ := get(t, srv, "/datasets/missing/"); status != http.StatusNotFound {
+		t.Errorf("GET missing page = %d, want 404", status)
+	}
+}
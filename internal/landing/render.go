@@ -0,0 +1,218 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package landing renders and publishes dataset landing pages.
+package landing
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"sort"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+//go:embed templates
+var defaultTemplates embed.FS
+
+// pageTemplate is the template file rendered for each dataset.
+const pageTemplate = "dataset.html"
+
+// DefaultTemplates returns the built-in landing page templates.
+func DefaultTemplates() fs.FS {
+	sub, err := fs.Sub(defaultTemplates, "templates")
+	if err != nil {
+		panic(err) // the embedded directory always exists
+	}
+	return sub
+}
+
+// Page is the data passed to the landing page template.
+type Page struct {
+	// Dataset is the dataset being rendered
+	Dataset *dataset.Dataset
+
+	// Version is the version whose files are listed
+	Version *dataset.Version
+
+	// Stats holds usage counters (views, downloads, citations)
+	Stats map[string]int64
+
+	// Downloads maps file paths to their counted download links; empty
+	// if the files are not publicly downloadable
+	Downloads map[string]string
+
+	// Signposts are the page's typed links, emitted as link elements
+	Signposts []Link
+
+	// Related lists recommended datasets, best first
+	Related []Related
+
+	// Cited lists works citing the dataset; nil if none are known
+	Cited *Citations
+
+	// Versions lists the dataset's published versions, newest first;
+	// nil if it has fewer than two, except on the listing
+	Versions []dataset.Version
+
+	// Concept is where the dataset's concept DOI resolves,
+	// collection.ConceptLatest or collection.ConceptVersions
+	Concept string
+
+	// Listing is set when rendering the listing of every version that
+	// the concept DOI resolves to, in place of the latest version's files
+	Listing bool
+}
+
+// PublishedVersions returns d's published versions, newest first, or
+// nil if it has fewer than two.
+func PublishedVersions(d *dataset.Dataset) []dataset.Version {
+	versions := publishedVersions(d)
+	if len(versions) < 2 {
+		return nil
+	}
+	return versions
+}
+
+// publishedVersions returns d's published versions, newest first.
+func publishedVersions(d *dataset.Dataset) []dataset.Version {
+	var versions []dataset.Version
+	for _, v := range d.Versions {
+		if v.PublishedAt != nil {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Number > versions[j].Number })
+	return versions
+}
+
+// Citations are the works citing a dataset: how many, and the most
+// recent of them.
+type Citations struct {
+	Count int        `json:"count"`
+	Works []Citation `json:"works"`
+}
+
+// Citation is a citing work linked from a landing page.
+type Citation struct {
+	Title     string `json:"title"`
+	Container string `json:"container,omitempty"`
+	Year      int    `json:"year,omitempty"`
+	URL       string `json:"url"`
+}
+
+// Related is a recommended dataset linked from a landing page.
+type Related struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Renderer renders landing pages from a template set.
+type Renderer struct {
+	tmpl *template.Template
+	hash string
+}
+
+// NewRenderer parses the templates in fsys, which must contain
+// dataset.html and may call only the functions in AllowedFuncs.
+func NewRenderer(fsys fs.FS) (*Renderer, error) {
+	hash, err := hashTemplates(fsys)
+	if err != nil {
+		return nil, err
+	}
+	problems, err := checkFuncs(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) > 0 {
+		return nil, joinProblems(problems)
+	}
+
+	tmpl, err := template.New(pageTemplate).Funcs(funcs).ParseFS(fsys, "*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse landing page templates: %w", err)
+	}
+	if tmpl.Lookup(pageTemplate) == nil {
+		return nil, fmt.Errorf("landing page templates must include %s", pageTemplate)
+	}
+	return &Renderer{tmpl: tmpl, hash: hash}, nil
+}
+
+// TemplateHash identifies the template set; it changes whenever any
+// template file changes.
+func (r *Renderer) TemplateHash() string {
+	return r.hash
+}
+
+// Render returns the HTML for p.
+func (r *Renderer) Render(p Page) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.tmpl.ExecuteTemplate(&buf, pageTemplate, p); err != nil {
+		return nil, fmt.Errorf("failed to render landing page for %s: %w", p.Dataset.ID, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// funcs are the helper functions available to templates.
+var funcs = template.FuncMap{
+	"bytes": formatBytes,
+}
+
+// formatBytes formats n with a binary unit suffix.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// hashTemplates returns a digest of every file in fsys.
+func hashTemplates(fsys fs.FS) (string, error) {
+	var names []string
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			names = append(names, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read templates: %w", err)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read template %s: %w", name, err)
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,234 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package landing
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"text/template/parse"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// allowedBuiltins are the template builtins themes may use. call is
+// excluded because it invokes arbitrary function values reachable from
+// the page data, and the escaping builtins are left to html/template.
+var allowedBuiltins = map[string]bool{
+	"and": true, "or": true, "not": true,
+	"len": true, "index": true, "slice": true,
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+	"print": true, "printf": true, "println": true,
+}
+
+// AllowedFuncs returns the sorted names of the functions templates may
+// call.
+func AllowedFuncs() []string {
+	names := make([]string, 0, len(allowedBuiltins)+len(funcs))
+	for name := range allowedBuiltins {
+		names = append(names, name)
+	}
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Problem is a template that fails validation.
+type Problem struct {
+	// Location is the file and position (e.g. "dataset.html:12:5")
+	Location string
+
+	// Message describes the problem
+	Message string
+}
+
+// Error implements error.
+func (p Problem) Error() string {
+	if p.Location == "" {
+		return p.Message
+	}
+	return p.Location + ": " + p.Message
+}
+
+// ValidateTemplates checks a template set before it is used: every
+// template must parse, call only allowed functions, and render the
+// sample dataset. All problems found are returned joined.
+func ValidateTemplates(fsys fs.FS) error {
+	_, err := validatedRenderer(fsys)
+	return err
+}
+
+// validatedRenderer returns a renderer for fsys once it has rendered
+// the sample dataset successfully.
+func validatedRenderer(fsys fs.FS) (*Renderer, error) {
+	r, err := NewRenderer(fsys)
+	if err != nil {
+		return nil, err
+	}
+	d := SampleDataset()
+	if _, err := r.Render(Page{Dataset: d, Version: d.Latest(), Stats: SampleStats()}); err != nil {
+		return nil, Problem{Location: pageTemplate, Message: err.Error()}
+	}
+	return r, nil
+}
+
+// checkFuncs parses every template in fsys and reports calls to
+// functions outside the allowlist.
+func checkFuncs(fsys fs.FS) ([]Problem, error) {
+	names, err := fs.Glob(fsys, "*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+
+	var problems []Problem
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %w", name, err)
+		}
+
+		t := parse.New(name)
+		t.Mode = parse.SkipFuncCheck
+		trees := map[string]*parse.Tree{}
+		if _, err := t.Parse(string(data), "", "", trees); err != nil {
+			problems = append(problems, Problem{Message: err.Error()})
+			continue
+		}
+
+		for _, tree := range sortedTrees(trees) {
+			walk(tree.Root, func(n parse.Node) {
+				id, ok := n.(*parse.IdentifierNode)
+				if !ok {
+					return
+				}
+				if _, custom := funcs[id.Ident]; custom || allowedBuiltins[id.Ident] {
+					return
+				}
+				loc, _ := tree.ErrorContext(n)
+				problems = append(problems, Problem{
+					Location: loc,
+					Message:  fmt.Sprintf("function %q is not allowed", id.Ident),
+				})
+			})
+		}
+	}
+	return problems, nil
+}
+
+// sortedTrees returns trees in name order so that problems are
+// reported deterministically.
+func sortedTrees(trees map[string]*parse.Tree) []*parse.Tree {
+	out := make([]*parse.Tree, 0, len(trees))
+	for _, t := range trees {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// walk calls fn for n and every node beneath it.
+func walk(n parse.Node, fn func(parse.Node)) {
+	if n == nil {
+		return
+	}
+	fn(n)
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			walk(c, fn)
+		}
+	case *parse.ActionNode:
+		walk(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			walk(c, fn)
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			walk(a, fn)
+		}
+	case *parse.ChainNode:
+		walk(n.Node, fn)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.TemplateNode:
+		walk(n.Pipe, fn)
+	}
+}
+
+func walkBranch(b *parse.BranchNode, fn func(parse.Node)) {
+	walk(b.Pipe, fn)
+	walk(b.List, fn)
+	walk(b.ElseList, fn)
+}
+
+// joinProblems combines problems into a single error.
+func joinProblems(problems []Problem) error {
+	errs := make([]error, len(problems))
+	for i, p := range problems {
+		errs[i] = p
+	}
+	return errors.Join(errs...)
+}
+
+// SampleDataset returns a representative published dataset for
+// validating and previewing templates.
+func SampleDataset() *dataset.Dataset {
+	published := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+	return &dataset.Dataset{
+		ID:              "sample",
+		DOI:             "10.5555/sample",
+		Title:           "Coastal Ocean Temperature Observations, 1990-2020",
+		Description:     "Hourly sea surface temperature readings from moored buoys along the Pacific coast.",
+		PublicationYear: 2025,
+		Access:          storage.AccessPublic,
+		State:           dataset.StatePublished,
+		Creators: []dataset.Creator{
+			{Name: "Rivera, Ana", ORCID: "0000-0002-1825-0097", Affiliation: "Example University"},
+			{Name: "Chen, Wei", Affiliation: "Example Ocean Institute"},
+		},
+		Versions: []dataset.Version{{
+			Number:      1,
+			DOI:         "10.5555/sample.v1",
+			PublishedAt: &published,
+			Files: []dataset.File{
+				{Path: "README.md", Size: 4 << 10, ContentType: "text/markdown"},
+				{Path: "observations.csv", Size: 312 << 20, ContentType: "text/csv"},
+			},
+		}},
+		CreatedAt: published,
+		UpdatedAt: published,
+	}
+}
+
+// SampleStats returns usage counters for the sample dataset.
+func SampleStats() map[string]int64 {
+	return map[string]int64{"views": 1289, "downloads": 342, "citations": 7}
+}
@@ -0,0 +1,99 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package landing
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// LinksetType is the media type of linkset documents (RFC 9264).
+const LinksetType = "application/linkset+json"
+
+// LinksetHeader is the Link header added to landing pages by the CDN.
+// The target is relative, so one header serves every page: it resolves
+// to the linkset next to the page requested.
+const LinksetHeader = `<linkset.json>; rel="linkset"; type="` + LinksetType + `"`
+
+// Link is a typed link from a landing page, following FAIR Signposting.
+type Link struct {
+	// Href is the link target
+	Href string `json:"href"`
+
+	// Rel is the relation type, e.g. "cite-as" or "item"
+	Rel string `json:"-"`
+
+	// Type is the media type of the target, if known
+	Type string `json:"type,omitempty"`
+}
+
+// LinksetKey returns the object key of a dataset's linkset.
+func LinksetKey(id string) string {
+	return "datasets/" + id + "/linkset.json"
+}
+
+// Signposts returns the typed links of d's landing page: its persistent
+// identifier, metadata, type, authors, license, and the files linked in
+// downloads.
+func Signposts(d *dataset.Dataset, downloads map[string]string) []Link {
+	var links []Link
+	switch {
+	case d.DOI != "":
+		links = append(links,
+			Link{Rel: "cite-as", Href: "https://doi.org/" + d.DOI},
+			Link{Rel: "describedby", Href: "https://data.crosscite.org/application/vnd.datacite.datacite+json/" + d.DOI, Type: "application/vnd.datacite.datacite+json"},
+		)
+	case d.ARK != "":
+		links = append(links, Link{Rel: "cite-as", Href: "https://n2t.net/" + d.ARK})
+	case d.Handle != "":
+		links = append(links, Link{Rel: "cite-as", Href: "https://hdl.handle.net/" + d.Handle})
+	}
+
+	typ := "https://schema.org/Dataset"
+	if d.Software != nil {
+		typ = "https://schema.org/SoftwareSourceCode"
+	}
+	links = append(links, Link{Rel: "type", Href: typ}, Link{Rel: "type", Href: "https://schema.org/AboutPage"})
+
+	for _, c := range d.Creators {
+		if c.ORCID != "" {
+			links = append(links, Link{Rel: "author", Href: "https://orcid.org/" + strings.TrimPrefix(c.ORCID, "https://orcid.org/")})
+		}
+	}
+	if d.Software != nil && d.Software.License != "" {
+		links = append(links, Link{Rel: "license", Href: "https://spdx.org/licenses/" + d.Software.License})
+	}
+
+	if v := d.Current(); v != nil {
+		for _, f := range v.Files {
+			if href, ok := downloads[f.Path]; ok {
+				links = append(links, Link{Rel: "item", Href: href, Type: f.ContentType})
+			}
+		}
+	}
+	return links
+}
+
+// Linkset returns the linkset document of the page at anchor.
+func Linkset(anchor string, links []Link) ([]byte, error) {
+	ctx := map[string]any{"anchor": anchor}
+	for _, l := range links {
+		rel, _ := ctx[l.Rel].([]Link)
+		ctx[l.Rel] = append(rel, l)
+	}
+	return json.MarshalIndent(map[string]any{"linkset": []any{ctx}}, "", "  ")
+}
@@ -0,0 +1,190 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+// cloudWatchVersion is the CloudWatch query API version.
+const cloudWatchVersion = "2010-08-01"
+
+// Dashboard is a CloudWatch dashboard body.
+type Dashboard struct {
+	Widgets []Widget `json:"widgets"`
+}
+
+// Widget is a dashboard widget.
+type Widget struct {
+	Type       string           `json:"type"`
+	X          int              `json:"x"`
+	Y          int              `json:"y"`
+	Width      int              `json:"width"`
+	Height     int              `json:"height"`
+	Properties WidgetProperties `json:"properties"`
+}
+
+// WidgetProperties configure a metric widget.
+type WidgetProperties struct {
+	Title   string  `json:"title"`
+	Region  string  `json:"region"`
+	View    string  `json:"view"`
+	Stacked bool    `json:"stacked"`
+	Period  int     `json:"period"`
+	Metrics [][]any `json:"metrics"`
+}
+
+// search is one SEARCH expression of a widget: the metric name in the
+// dimension set dims of namespace, filtered to environment, as stat.
+type search struct {
+	metric, stat, label string
+	dims                []string
+}
+
+// NewDashboard returns the standard dashboard of the metrics emitted
+// in namespace by environment: HTTP latency, traffic, and errors per
+// service; command durations and failures; S3 upload throughput and
+// errors; and queue depths and ages.
+func NewDashboard(namespace, region, environment string) *Dashboard {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	service := []string{"Environment", "Service"}
+	command := []string{"Command", "Environment"}
+	transfer := []string{"Environment", "Operation"}
+	queue := []string{"Environment", "Queue"}
+	panels := []struct {
+		title    string
+		stacked  bool
+		searches []search
+	}{
+		{"Request latency (p50, p99)", false, []search{
+			{Latency, "p50", "p50", service},
+			{Latency, "p99", "p99", service},
+		}},
+		{"Requests", true, []search{{Requests, "Sum", "", service}}},
+		{"Server and client errors", false, []search{
+			{Errors, "Sum", "5xx", service},
+			{ClientErrors, "Sum", "4xx", service},
+		}},
+		{"Command duration (max)", false, []search{{CommandDuration, "Maximum", "", command}}},
+		{"Command failures", false, []search{{CommandErrors, "Sum", "", command}}},
+		{"Upload throughput", false, []search{
+			{UploadThroughput, "Average", "average", transfer},
+			{UploadBytes, "Sum", "bytes", transfer},
+		}},
+		{"S3 request latency (p99) and errors", false, []search{
+			{TransferLatency, "p99", "p99", transfer},
+			{TransferErrors, "Sum", "errors", transfer},
+		}},
+		{"Queue depth", false, []search{{QueueDepth, "Maximum", "", queue}}},
+		{"Queue age (max)", false, []search{{QueueAge, "Maximum", "", queue}}},
+	}
+
+	d := &Dashboard{}
+	for i, p := range panels {
+		w := Widget{
+			Type:  "metric",
+			X:     (i % 2) * 12,
+			Y:     (i / 2) * 6,
+			Width: 12, Height: 6,
+			Properties: WidgetProperties{Title: p.title, Region: region, View: "timeSeries", Stacked: p.stacked, Period: 300},
+		}
+		for j, s := range p.searches {
+			expr := fmt.Sprintf("SEARCH('{%s,%s} MetricName=\"%s\" Environment=\"%s\"', '%s', 300)",
+				namespace, strings.Join(s.dims, ","), s.metric, environment, s.stat)
+			opts := map[string]string{"expression": expr, "id": fmt.Sprintf("e%d", j+1)}
+			if s.label != "" {
+				opts["label"] = s.label
+			}
+			w.Properties.Metrics = append(w.Properties.Metrics, []any{opts})
+		}
+		d.Widgets = append(d.Widgets, w)
+	}
+	return d
+}
+
+// DashboardClient provisions CloudWatch dashboards.
+type DashboardClient struct {
+	// Endpoint overrides the regional CloudWatch endpoint
+	Endpoint string
+
+	signer *aws.Signer
+	http   *http.Client
+}
+
+// NewDashboardClient returns a client for region.
+func NewDashboardClient(region string, creds aws.Credentials) *DashboardClient {
+	return &DashboardClient{
+		Endpoint: "https://monitoring." + region + ".amazonaws.com",
+		signer:   &aws.Signer{Credentials: creds, Region: region, Service: "monitoring"},
+		http:     http.DefaultClient,
+	}
+}
+
+// Put creates or replaces the dashboard name. CloudWatch accepts
+// dashboards with warnings, which are returned.
+func (c *DashboardClient) Put(ctx context.Context, name string, d *Dashboard) ([]string, error) {
+	body, err := json.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode dashboard: %w", err)
+	}
+	form := url.Values{
+		"Action":        {"PutDashboard"},
+		"Version":       {cloudWatchVersion},
+		"DashboardName": {name},
+		"DashboardBody": {string(body)},
+	}
+	payload := []byte(form.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dashboard request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c.signer.Sign(req, aws.HashPayload(payload))
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("dashboard request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cloudwatch: HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	var out struct {
+		Messages []struct {
+			DataPath string `xml:"DataPath"`
+			Message  string `xml:"Message"`
+		} `xml:"PutDashboardResult>DashboardValidationMessages>member"`
+	}
+	if err := xml.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to decode dashboard response: %w", err)
+	}
+	var warnings []string
+	for _, m := range out.Messages {
+		warnings = append(warnings, strings.TrimSpace(m.DataPath+": "+m.Message))
+	}
+	return warnings, nil
+}
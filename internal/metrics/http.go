@@ -0,0 +1,71 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"time"
+)
+
+// Handler wraps h to record the latency, count, and errors of the
+// requests it serves, with a Service dimension naming it.
+func Handler(r Recorder, service string, h http.Handler) http.Handler {
+	dims := Dimensions{"Service": service}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, req)
+
+		var errs, clientErrs float64
+		switch {
+		case sw.status >= 500:
+			errs = 1
+		case sw.status >= 400:
+			clientErrs = 1
+		}
+		r.Record(dims,
+			Metric{Name: Latency, Value: Since(start), Unit: Milliseconds},
+			Metric{Name: Requests, Value: 1, Unit: Count},
+			Metric{Name: Errors, Value: errs, Unit: Count},
+			Metric{Name: ClientErrors, Value: clientErrs, Unit: Count},
+		)
+	})
+}
+
+// statusWriter records the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
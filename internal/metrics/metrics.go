@@ -0,0 +1,245 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics emits operational metrics to Amazon CloudWatch in
+// the embedded metric format (EMF).
+//
+// EMF records are JSON log lines that CloudWatch turns into metrics,
+// so emitting one needs no API call and cannot slow or fail the work
+// being measured. In Lambda, records written to standard error are
+// picked up from the function's log stream; elsewhere they are sent to
+// the CloudWatch agent's EMF listener over TCP or UDP.
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultNamespace is the CloudWatch namespace of Aperture's metrics.
+const DefaultNamespace = "Aperture"
+
+// Unit is a CloudWatch metric unit.
+type Unit string
+
+// Units.
+const (
+	Seconds        Unit = "Seconds"
+	Milliseconds   Unit = "Milliseconds"
+	Count          Unit = "Count"
+	Bytes          Unit = "Bytes"
+	BytesPerSecond Unit = "Bytes/Second"
+)
+
+// Metric names, shared by the emitters and the dashboard.
+const (
+	// Latency is the time to serve an HTTP request
+	Latency = "Latency"
+
+	// Requests counts HTTP requests
+	Requests = "Requests"
+
+	// Errors counts HTTP requests failing with a server error
+	Errors = "Errors"
+
+	// ClientErrors counts HTTP requests rejected with a client error
+	ClientErrors = "ClientErrors"
+
+	// CommandDuration is the time to run a CLI command, such as a
+	// scheduled job
+	CommandDuration = "CommandDuration"
+
+	// CommandErrors counts failed CLI commands
+	CommandErrors = "CommandErrors"
+
+	// TransferLatency is the time of one S3 request
+	TransferLatency = "TransferLatency"
+
+	// TransferErrors counts failed S3 requests
+	TransferErrors = "TransferErrors"
+
+	// UploadBytes is the number of bytes uploaded to S3
+	UploadBytes = "UploadBytes"
+
+	// UploadThroughput is the rate of one upload to S3
+	UploadThroughput = "UploadThroughput"
+
+	// QueueDepth is the number of items awaiting processing in a queue
+	QueueDepth = "QueueDepth"
+
+	// QueueAge is how long the oldest item in a queue has waited
+	QueueAge = "QueueAge"
+)
+
+// Metric is one measurement.
+type Metric struct {
+	Name  string
+	Value float64
+	Unit  Unit
+}
+
+// Dimensions qualify metrics, e.g. by the service emitting them.
+type Dimensions map[string]string
+
+// Recorder records metrics. *EMF implements it.
+type Recorder interface {
+	Record(dims Dimensions, ms ...Metric)
+}
+
+// EMF writes metrics as embedded metric format records.
+type EMF struct {
+	// Namespace is the CloudWatch namespace; DefaultNamespace if empty
+	Namespace string
+
+	// Dimensions are added to every record, e.g. the environment
+	Dimensions Dimensions
+
+	// OnError is called when a record cannot be written; errors are
+	// dropped if nil
+	OnError func(error)
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+
+	mu   sync.Mutex
+	w    io.Writer
+	dial func() (net.Conn, error)
+}
+
+// NewEMF returns an emitter writing records to w.
+func NewEMF(w io.Writer, namespace string, dims Dimensions) *EMF {
+	return &EMF{Namespace: namespace, Dimensions: dims, w: w}
+}
+
+// Open returns an emitter for target: "stderr", or the address of a
+// CloudWatch agent EMF listener as tcp://host:port or udp://host:port.
+// Connections are made on first use and remade after a write fails.
+func Open(target, namespace string, dims Dimensions) (*EMF, error) {
+	e := NewEMF(nil, namespace, dims)
+	if target == "stderr" {
+		e.w = os.Stderr
+		return e, nil
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "tcp" && u.Scheme != "udp") || u.Host == "" {
+		return nil, fmt.Errorf("invalid metrics target %q: use stderr, tcp://host:port, or udp://host:port", target)
+	}
+	e.dial = func() (net.Conn, error) { return net.DialTimeout(u.Scheme, u.Host, 2*time.Second) }
+	return e, nil
+}
+
+// Record implements Recorder, writing one record holding ms.
+func (e *EMF) Record(dims Dimensions, ms ...Metric) {
+	if len(ms) == 0 {
+		return
+	}
+	if err := e.write(e.record(dims, ms)); err != nil && e.OnError != nil {
+		e.OnError(err)
+	}
+}
+
+// record returns the EMF record of ms with dims.
+func (e *EMF) record(dims Dimensions, ms []Metric) map[string]any {
+	all := maps.Clone(e.Dimensions)
+	if all == nil {
+		all = Dimensions{}
+	}
+	maps.Copy(all, dims)
+	names := slices.Sorted(maps.Keys(all))
+
+	type definition struct {
+		Name string `json:"Name"`
+		Unit Unit   `json:"Unit"`
+	}
+	defs := make([]definition, len(ms))
+	rec := make(map[string]any, len(all)+len(ms)+1)
+	for k, v := range all {
+		rec[k] = v
+	}
+	for i, m := range ms {
+		defs[i] = definition{Name: m.Name, Unit: m.Unit}
+		rec[m.Name] = m.Value
+	}
+	namespace := e.Namespace
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	rec["_aws"] = map[string]any{
+		"Timestamp": e.now().UnixMilli(),
+		"CloudWatchMetrics": []map[string]any{{
+			"Namespace":  namespace,
+			"Dimensions": [][]string{names},
+			"Metrics":    defs,
+		}},
+	}
+	return rec
+}
+
+// write writes rec as one line, connecting first if needed.
+func (e *EMF) write(rec map[string]any) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+	line = append(line, '\n')
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.w == nil {
+		conn, err := e.dial()
+		if err != nil {
+			return fmt.Errorf("failed to connect to metrics agent: %w", err)
+		}
+		e.w = conn
+	}
+	if _, err := e.w.Write(line); err != nil {
+		if e.dial != nil {
+			e.w.(net.Conn).Close()
+			e.w = nil
+		}
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection to the agent, if there is one.
+func (e *EMF) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if conn, ok := e.w.(net.Conn); ok && e.dial != nil {
+		e.w = nil
+		return conn.Close()
+	}
+	return nil
+}
+
+func (e *EMF) now() time.Time {
+	if e.Now != nil {
+		return e.Now()
+	}
+	return time.Now()
+}
+
+// Since returns the time elapsed since start in milliseconds.
+func Since(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
@@ -0,0 +1,211 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+func TestEMF(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEMF(&buf, "", Dimensions{"Environment": "prod"})
+	e.Now = func() time.Time { return time.UnixMilli(1750000000000) }
+	e.Record(Dimensions{"Service": "oai"},
+		Metric{Name: Latency, Value: 12.5, Unit: Milliseconds},
+		Metric{Name: Requests, Value: 1, Unit: Count},
+	)
+	e.Record(nil) // no metrics, no record
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("records = %q, want one line", buf.String())
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"Environment": "prod",
+		"Service":     "oai",
+		"Latency":     12.5,
+		"Requests":    1.0,
+		"_aws": map[string]any{
+			"Timestamp": 1750000000000.0,
+			"CloudWatchMetrics": []any{map[string]any{
+				"Namespace":  "Aperture",
+				"Dimensions": []any{[]any{"Environment", "Service"}},
+				"Metrics": []any{
+					map[string]any{"Name": "Latency", "Unit": "Milliseconds"},
+					map[string]any{"Name": "Requests", "Unit": "Count"},
+				},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("record = %v, want %v", got, want)
+	}
+}
+
+func TestOpenAgent(t *testing.T) {
+	if _, err := Open("http://localhost:25888", "", nil); err == nil {
+		t.Error("Open(http://...) succeeded, want error")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				s := bufio.NewScanner(conn)
+				for s.Scan() {
+					lines <- s.Text()
+				}
+			}()
+		}
+	}()
+
+	e, err := Open("tcp://"+ln.Addr().String(), "Test", nil)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer e.Close()
+	e.OnError = func(err error) { t.Errorf("Record() error = %v", err) }
+	e.Record(Dimensions{"Queue": "q"}, Metric{Name: QueueDepth, Value: 3, Unit: Count})
+	select {
+	case line := <-lines:
+		if !strings.Contains(line, `"QueueDepth":3`) || !strings.Contains(line, `"Namespace":"Test"`) {
+			t.Errorf("agent received %s", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent received nothing")
+	}
+}
+
+type fakeRecorder struct {
+	dims    []Dimensions
+	metrics []map[string]float64
+}
+
+func (f *fakeRecorder) Record(dims Dimensions, ms ...Metric) {
+	m := make(map[string]float64)
+	for _, x := range ms {
+		m[x.Name] = x.Value
+	}
+	f.dims = append(f.dims, dims)
+	f.metrics = append(f.metrics, m)
+}
+
+func TestHandler(t *testing.T) {
+	rec := &fakeRecorder{}
+	h := Handler(rec, "share", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	for _, path := range []string{"/", "/missing", "/broken"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	want := []map[string]float64{
+		{Requests: 1, Errors: 0, ClientErrors: 0},
+		{Requests: 1, Errors: 0, ClientErrors: 1},
+		{Requests: 1, Errors: 1, ClientErrors: 0},
+	}
+	if len(rec.metrics) != len(want) {
+		t.Fatalf("recorded %d requests, want %d", len(rec.metrics), len(want))
+	}
+	for i, w := range want {
+		got := rec.metrics[i]
+		if rec.dims[i]["Service"] != "share" || got[Requests] != w[Requests] || got[Errors] != w[Errors] || got[ClientErrors] != w[ClientErrors] {
+			t.Errorf("request %d recorded %v %v, want %v", i, rec.dims[i], got, w)
+		}
+		if _, ok := got[Latency]; !ok {
+			t.Errorf("request %d recorded no latency", i)
+		}
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	d := NewDashboard("", "us-west-2", "prod")
+	if len(d.Widgets) != 9 {
+		t.Fatalf("widgets = %d, want 9", len(d.Widgets))
+	}
+	w := d.Widgets[0]
+	if w.Properties.Region != "us-west-2" || w.X != 0 || d.Widgets[1].X != 12 || d.Widgets[2].Y != 6 {
+		t.Errorf("layout = %+v", d.Widgets[:3])
+	}
+	expr := w.Properties.Metrics[0][0].(map[string]string)["expression"]
+	if expr != `SEARCH('{Aperture,Environment,Service} MetricName="Latency" Environment="prod"', 'p50', 300)` {
+		t.Errorf("expression = %s", expr)
+	}
+
+	var form url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "/us-west-2/monitoring/aws4_request") {
+			t.Errorf("request not signed for CloudWatch: %q", r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		form, _ = url.ParseQuery(string(body))
+		fmt.Fprint(w, `<PutDashboardResponse><PutDashboardResult><DashboardValidationMessages>
+<member><DataPath>/widgets/7</DataPath><Message>No metrics yet</Message></member>
+</DashboardValidationMessages></PutDashboardResult></PutDashboardResponse>`)
+	}))
+	defer srv.Close()
+	c := NewDashboardClient("us-west-2", aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	c.Endpoint = srv.URL
+
+	warnings, err := c.Put(context.Background(), "aperture-prod-operations", d)
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if len(warnings) != 1 || warnings[0] != "/widgets/7: No metrics yet" {
+		t.Errorf("Put() warnings = %v", warnings)
+	}
+	if form.Get("Action") != "PutDashboard" || form.Get("DashboardName") != "aperture-prod-operations" {
+		t.Errorf("form = %v", form)
+	}
+	var body Dashboard
+	if err := json.Unmarshal([]byte(form.Get("DashboardBody")), &body); err != nil || len(body.Widgets) != 9 {
+		t.Errorf("DashboardBody = %s (%v)", form.Get("DashboardBody"), err)
+	}
+}
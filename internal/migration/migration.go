@@ -0,0 +1,471 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package migration makes preservation copies of dataset files in
+// normalized formats, such as CSV from proprietary spreadsheets or TIFF
+// from camera RAW images.
+//
+// Conversion is done by converters: HTTP services, typically Lambda
+// function URLs or Fargate tasks behind a load balancer, that are
+// registered with the media types and extensions they accept. A
+// converter is sent a Request naming a presigned URL to read the
+// original from and one to write the copy to, and answers once the
+// copy is written. The pipeline then reads the copy back to record its
+// size and digest, adds it to the file's derivatives in the manifest,
+// stored alongside the original, and records the migration as a PREMIS
+// event of the original.
+package migration
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/blake3"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/premis"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// convertersTable holds the registered converters by name.
+const convertersTable = "converters"
+
+// DefaultExpiry is how long the presigned URLs sent to converters
+// remain valid.
+const DefaultExpiry = time.Hour
+
+// ErrNoConverter is returned for unknown converters.
+var ErrNoConverter = errors.New("no such converter")
+
+// namePattern matches converter names, which are used in object keys.
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Converter is a registered conversion service.
+type Converter struct {
+	// Name identifies the converter, e.g. xlsx-csv
+	Name string `json:"name"`
+
+	Description string `json:"description,omitempty"`
+
+	// From lists the media types, e.g. image/x-canon-cr2, and file
+	// extensions, e.g. .xlsx, of the files the converter accepts
+	From []string `json:"from"`
+
+	// To is the media type of the copies it makes, e.g. text/csv
+	To string `json:"to"`
+
+	// Extension is appended to the keys of copies, e.g. .csv
+	Extension string `json:"extension"`
+
+	// Endpoint is the URL requests are posted to
+	Endpoint string `json:"endpoint"`
+
+	// IAM signs requests with AWS Signature Version 4, as Lambda
+	// function URLs with AWS_IAM authentication require
+	IAM bool `json:"iam,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Accepts reports whether c converts f, by media type or extension.
+func (c *Converter) Accepts(f dataset.File) bool {
+	ext := strings.ToLower(path.Ext(f.Path))
+	for _, from := range c.From {
+		if strings.HasPrefix(from, ".") {
+			if ext != "" && strings.EqualFold(from, ext) {
+				return true
+			}
+		} else if f.ContentType != "" && strings.EqualFold(from, mediaType(f.ContentType)) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks that c can be registered.
+func (c *Converter) validate() error {
+	if !namePattern.MatchString(c.Name) {
+		return fmt.Errorf("invalid converter name %q: use lowercase letters, digits, and hyphens", c.Name)
+	}
+	if len(c.From) == 0 {
+		return fmt.Errorf("converter %s accepts no media types or extensions", c.Name)
+	}
+	if c.To == "" {
+		return fmt.Errorf("converter %s has no target media type", c.Name)
+	}
+	if !strings.HasPrefix(c.Extension, ".") || strings.Contains(c.Extension, "/") {
+		return fmt.Errorf("invalid extension %q: want e.g. .csv", c.Extension)
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q", c.Endpoint)
+	}
+	return nil
+}
+
+// Request is the body posted to a converter.
+type Request struct {
+	// Source is a presigned URL to GET the original from
+	Source string `json:"source"`
+
+	// Target is a presigned URL to PUT the copy to
+	Target string `json:"target"`
+
+	// Path and ContentType describe the original
+	Path        string `json:"path"`
+	ContentType string `json:"contentType,omitempty"`
+
+	// TargetType is the media type to convert to
+	TargetType string `json:"targetType"`
+}
+
+// ObjectStore reads originals and copies. *s3.Client implements it.
+type ObjectStore interface {
+	PresignGetObject(bucket, key string, expires time.Duration) string
+	PresignPutObject(bucket, key string, expires time.Duration) string
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// EventLog records preservation events. *premis.Log implements it.
+type EventLog interface {
+	Record(ctx context.Context, events ...premis.Event) error
+}
+
+// Pipeline registers converters and runs them over dataset files.
+type Pipeline struct {
+	// State holds the registered converters
+	State state.Store
+
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	Objects ObjectStore
+
+	// Events records migrations; skipped if nil
+	Events EventLog
+
+	// Client sends requests to converters; a client with a 15 minute
+	// timeout, the longest a Lambda function runs, if nil
+	Client *http.Client
+
+	// Signer signs requests to IAM converters; they fail if nil
+	Signer *aws.Signer
+
+	// Expiry is how long presigned URLs remain valid; DefaultExpiry if
+	// zero
+	Expiry time.Duration
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// SaveConverter registers c, replacing any converter of the same name.
+func (p *Pipeline) SaveConverter(ctx context.Context, c *Converter) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	c.CreatedAt = p.now().UTC()
+	return p.State.Put(ctx, convertersTable, c.Name, c)
+}
+
+// Converters returns the registered converters by name.
+func (p *Pipeline) Converters(ctx context.Context) ([]Converter, error) {
+	all, err := state.List[Converter](ctx, p.State, convertersTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list converters: %w", err)
+	}
+	slices.SortFunc(all, func(a, b Converter) int { return cmp.Compare(a.Name, b.Name) })
+	return all, nil
+}
+
+// RemoveConverter unregisters the converter name. Copies it made are
+// kept.
+func (p *Pipeline) RemoveConverter(ctx context.Context, name string) error {
+	var c Converter
+	err := p.State.Get(ctx, convertersTable, name, &c)
+	if errors.Is(err, state.ErrNotFound) {
+		return fmt.Errorf("%w: %s", ErrNoConverter, name)
+	}
+	if err != nil {
+		return err
+	}
+	return p.State.Delete(ctx, convertersTable, name)
+}
+
+// Options select the files a run converts.
+type Options struct {
+	// Dataset converts the files of one dataset, ID or persistent
+	// identifier; every dataset that is not tombstoned if empty
+	Dataset string
+
+	// Converter runs one converter; every converter if empty
+	Converter string
+
+	// Limit is the most conversions to run; 0 for no limit
+	Limit int
+
+	// Force converts files again that already have a copy
+	Force bool
+}
+
+// Result is the outcome of one conversion.
+type Result struct {
+	Dataset   string `json:"dataset"`
+	Version   int    `json:"version"`
+	Path      string `json:"path"`
+	Converter string `json:"converter"`
+
+	// Copy is the copy made, if the conversion succeeded
+	Copy *dataset.Derivative `json:"copy,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Run summarizes a run.
+type Run struct {
+	Converted int      `json:"converted"`
+	Failed    int      `json:"failed"`
+	Remaining int      `json:"remaining"`
+	Results   []Result `json:"results"`
+}
+
+// task is a file to convert.
+type task struct {
+	version int
+	file    dataset.File
+	conv    *Converter
+}
+
+// Run makes the copies that matching converters have not yet made,
+// within opts, and adds them to the manifests. A failed conversion is
+// reported in the run and does not stop it.
+func (p *Pipeline) Run(ctx context.Context, opts Options) (*Run, error) {
+	converters, err := p.Converters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Converter != "" {
+		i := slices.IndexFunc(converters, func(c Converter) bool { return c.Name == opts.Converter })
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNoConverter, opts.Converter)
+		}
+		converters = converters[i : i+1]
+	}
+	var datasets []*dataset.Dataset
+	if opts.Dataset != "" {
+		d, err := p.Datasets.Resolve(ctx, opts.Dataset)
+		if err != nil {
+			return nil, err
+		}
+		datasets = []*dataset.Dataset{d}
+	} else if datasets, err = p.Datasets.List(ctx); err != nil {
+		return nil, err
+	}
+
+	run := &Run{Results: []Result{}}
+	for _, d := range datasets {
+		if d.State == dataset.StateTombstoned {
+			continue
+		}
+		tasks := p.tasks(d, converters, opts.Force)
+		changed := false
+		for _, t := range tasks {
+			if opts.Limit > 0 && run.Converted+run.Failed >= opts.Limit {
+				run.Remaining++
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			res := Result{Dataset: d.ID, Version: t.version, Path: t.file.Path, Converter: t.conv.Name}
+			dv, err := p.convert(ctx, t.conv, t.file)
+			if err != nil {
+				res.Error = err.Error()
+				run.Failed++
+			} else {
+				res.Copy = &dv
+				run.Converted++
+				attach(d, t.file, dv)
+				changed = true
+			}
+			if err := p.event(ctx, t, dv, err); err != nil {
+				return nil, err
+			}
+			run.Results = append(run.Results, res)
+		}
+		if changed {
+			if err := p.Datasets.Put(ctx, d); err != nil {
+				return nil, fmt.Errorf("failed to record copies of %s: %w", d.ID, err)
+			}
+		}
+	}
+	return run, nil
+}
+
+// tasks returns the conversions due for d: each object, once, by
+// each converter accepting it that has not made a copy of it.
+func (p *Pipeline) tasks(d *dataset.Dataset, converters []Converter, force bool) []task {
+	var out []task
+	seen := make(map[string]bool)
+	for _, v := range d.Versions {
+		if v.Pruned != nil {
+			continue
+		}
+		for _, f := range v.Files {
+			for i := range converters {
+				c := &converters[i]
+				k := f.Bucket + "/" + f.Key + "#" + c.Name
+				if seen[k] || !c.Accepts(f) {
+					continue
+				}
+				seen[k] = true
+				if !force && slices.ContainsFunc(f.Derivatives, func(dv dataset.Derivative) bool { return dv.Converter == c.Name }) {
+					continue
+				}
+				out = append(out, task{version: v.Number, file: f, conv: c})
+			}
+		}
+	}
+	return out
+}
+
+// attach adds dv to every file of d stored in the object of f,
+// replacing an earlier copy by the same converter.
+func attach(d *dataset.Dataset, f dataset.File, dv dataset.Derivative) {
+	for i := range d.Versions {
+		for j := range d.Versions[i].Files {
+			g := &d.Versions[i].Files[j]
+			if g.Bucket != f.Bucket || g.Key != f.Key {
+				continue
+			}
+			g.Derivatives = slices.DeleteFunc(g.Derivatives, func(old dataset.Derivative) bool { return old.Converter == dv.Converter })
+			g.Derivatives = append(g.Derivatives, dv)
+		}
+	}
+}
+
+// CopyKey returns the key of the copy of the object key made by the
+// converter name, stored alongside the original.
+func CopyKey(key, name, extension string) string {
+	return key + "." + name + extension
+}
+
+// convert has c copy f and returns the copy.
+func (p *Pipeline) convert(ctx context.Context, c *Converter, f dataset.File) (dataset.Derivative, error) {
+	dv := dataset.Derivative{Converter: c.Name, Bucket: f.Bucket, Key: CopyKey(f.Key, c.Name, c.Extension), ContentType: c.To}
+	expiry := cmp.Or(p.Expiry, DefaultExpiry)
+	body, err := json.Marshal(Request{
+		Source:      p.Objects.PresignGetObject(f.Bucket, f.Key, expiry),
+		Target:      p.Objects.PresignPutObject(dv.Bucket, dv.Key, expiry),
+		Path:        f.Path,
+		ContentType: f.ContentType,
+		TargetType:  c.To,
+	})
+	if err != nil {
+		return dv, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return dv, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.IAM {
+		if p.Signer == nil {
+			return dv, fmt.Errorf("converter %s requires AWS credentials", c.Name)
+		}
+		p.Signer.Sign(req, aws.HashPayload(body))
+	}
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return dv, fmt.Errorf("converter %s failed: %w", c.Name, err)
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return dv, fmt.Errorf("converter %s failed: %s: %s", c.Name, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	r, err := p.Objects.GetObject(ctx, dv.Bucket, dv.Key)
+	if err != nil {
+		return dv, fmt.Errorf("failed to read the copy converter %s made: %w", c.Name, err)
+	}
+	defer r.Close()
+	h, b3 := sha256.New(), blake3.New()
+	if dv.Size, err = io.Copy(io.MultiWriter(h, b3), r); err != nil {
+		return dv, fmt.Errorf("failed to read the copy converter %s made: %w", c.Name, err)
+	}
+	if dv.Size == 0 {
+		return dv, fmt.Errorf("converter %s made an empty copy", c.Name)
+	}
+	dv.SHA256 = hex.EncodeToString(h.Sum(nil))
+	dv.BLAKE3 = hex.EncodeToString(b3.Sum(nil))
+	dv.CreatedAt = p.now().UTC()
+	return dv, nil
+}
+
+// event records the migration of t as a PREMIS event of the original.
+func (p *Pipeline) event(ctx context.Context, t task, dv dataset.Derivative, err error) error {
+	if p.Events == nil {
+		return nil
+	}
+	e := premis.Event{
+		Type:    premis.TypeMigration,
+		Bucket:  t.file.Bucket,
+		Key:     t.file.Key,
+		Detail:  fmt.Sprintf("converted to %s by %s", t.conv.To, t.conv.Name),
+		Outcome: premis.OutcomeSuccess,
+		Agent:   premis.SoftwareAgent,
+	}
+	if err != nil {
+		e.Outcome, e.OutcomeDetail = premis.OutcomeFailure, err.Error()
+	} else {
+		e.OutcomeDetail = "sha256:" + dv.SHA256
+		e.Links = []premis.Link{{Role: premis.RoleOutcome, URI: "s3://" + dv.Bucket + "/" + dv.Key}}
+	}
+	return p.Events.Record(ctx, e)
+}
+
+func (p *Pipeline) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return &http.Client{Timeout: 15 * time.Minute}
+}
+
+// mediaType strips parameters from a Content-Type.
+func mediaType(contentType string) string {
+	t, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(t)
+}
+
+func (p *Pipeline) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,199 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/premis"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// fakeStore is an in-memory ObjectStore whose presigned URLs name
+// objects as get:bucket/key and put:bucket/key.
+type fakeStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeStore) PresignGetObject(bucket, key string, _ time.Duration) string {
+	return "get:" + bucket + "/" + key
+}
+
+func (f *fakeStore) PresignPutObject(bucket, key string, _ time.Duration) string {
+	return "put:" + bucket + "/" + key
+}
+
+func (f *fakeStore) GetObject(_ context.Context, bucket, key string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.objects[bucket+"/"+key]
+	if !ok {
+		return nil, s3.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+// converter serves a converter that upper-cases its input, failing
+// for paths containing "broken".
+func converter(t *testing.T, objects *fakeStore) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("converter request: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(req.Path, "broken") {
+			http.Error(w, "unreadable workbook", http.StatusUnprocessableEntity)
+			return
+		}
+		objects.mu.Lock()
+		defer objects.mu.Unlock()
+		in := objects.objects[strings.TrimPrefix(req.Source, "get:")]
+		objects.objects[strings.TrimPrefix(req.Target, "put:")] = bytes.ToUpper(in)
+	}))
+}
+
+func TestPipeline(t *testing.T) {
+	ctx := context.Background()
+	s := state.NewMemoryStore()
+	objects := &fakeStore{objects: map[string][]byte{
+		"pub/ds-1/v1/table.xlsx":  []byte("a,b"),
+		"pub/ds-1/v1/broken.xlsx": []byte("?"),
+	}}
+	srv := converter(t, objects)
+	defer srv.Close()
+
+	datasets := dataset.NewStore(s)
+	table := dataset.File{Path: "table.xlsx", Bucket: "pub", Key: "ds-1/v1/table.xlsx"}
+	broken := dataset.File{Path: "broken.xlsx", Bucket: "pub", Key: "ds-1/v1/broken.xlsx"}
+	readme := dataset.File{Path: "README.md", Bucket: "pub", Key: "ds-1/v1/README.md", ContentType: "text/markdown"}
+	err := datasets.Put(ctx, &dataset.Dataset{
+		ID: "ds-1",
+		Versions: []dataset.Version{
+			{Number: 1, Files: []dataset.File{table, broken, readme}},
+			{Number: 2, Files: []dataset.File{table, readme}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	events := &premis.Log{State: s}
+	p := &Pipeline{State: s, Datasets: datasets, Objects: objects, Events: events, Client: srv.Client()}
+	if err := p.SaveConverter(ctx, &Converter{Name: "Bad Name", From: []string{".xlsx"}, To: "text/csv", Extension: ".csv", Endpoint: srv.URL}); err == nil {
+		t.Error("SaveConverter(Bad Name) succeeded, want an error")
+	}
+	err = p.SaveConverter(ctx, &Converter{
+		Name: "xlsx-csv", From: []string{".xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+		To: "text/csv", Extension: ".csv", Endpoint: srv.URL,
+	})
+	if err != nil {
+		t.Fatalf("SaveConverter() error = %v", err)
+	}
+
+	run, err := p.Run(ctx, Options{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if run.Converted != 1 || run.Failed != 1 || len(run.Results) != 2 {
+		t.Fatalf("Run() = %+v, want table.xlsx converted and broken.xlsx failed", run)
+	}
+	if res := run.Results[1]; res.Path != "broken.xlsx" || !strings.Contains(res.Error, "unreadable workbook") {
+		t.Errorf("failed result = %+v", res)
+	}
+
+	// The copy is recorded in every version sharing the original.
+	d, err := datasets.Get(ctx, "ds-1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	for _, v := range d.Versions {
+		dvs := v.Files[0].Derivatives
+		if len(dvs) != 1 || dvs[0].Key != "ds-1/v1/table.xlsx.xlsx-csv.csv" || dvs[0].Size != 3 || dvs[0].SHA256 == "" || dvs[0].ContentType != "text/csv" {
+			t.Errorf("version %d derivatives = %+v", v.Number, dvs)
+		}
+	}
+	if got := string(objects.objects["pub/ds-1/v1/table.xlsx.xlsx-csv.csv"]); got != "A,B" {
+		t.Errorf("copy = %q, want A,B", got)
+	}
+
+	history, err := events.Events(ctx, "pub", "ds-1/v1/table.xlsx")
+	if err != nil {
+		t.Fatalf("Events() error = %v", err)
+	}
+	// No observer records ingestion here, so the migration is the only
+	// event.
+	if len(history) != 1 || history[0].Type != premis.TypeMigration || history[0].Outcome != premis.OutcomeSuccess ||
+		len(history[0].Links) != 1 || history[0].Links[0].URI != "s3://pub/ds-1/v1/table.xlsx.xlsx-csv.csv" {
+		t.Errorf("table.xlsx events = %+v", history)
+	}
+	history, _ = events.Events(ctx, "pub", "ds-1/v1/broken.xlsx")
+	if len(history) != 1 || history[0].Outcome != premis.OutcomeFailure {
+		t.Errorf("broken.xlsx events = %+v", history)
+	}
+
+	// Converted files are not converted again unless forced.
+	if run, err = p.Run(ctx, Options{Dataset: "ds-1", Converter: "xlsx-csv"}); err != nil || run.Converted != 0 || run.Failed != 1 {
+		t.Errorf("second Run() = %+v, %v, want only broken.xlsx retried", run, err)
+	}
+	if run, err = p.Run(ctx, Options{Force: true, Limit: 1}); err != nil || run.Converted != 1 || run.Remaining != 1 {
+		t.Errorf("forced Run() = %+v, %v, want 1 converted and 1 remaining", run, err)
+	}
+	if d, _ = datasets.Get(ctx, "ds-1"); len(d.Versions[0].Files[0].Derivatives) != 1 {
+		t.Errorf("forced Run() left derivatives %+v, want the copy replaced", d.Versions[0].Files[0].Derivatives)
+	}
+
+	if _, err := p.Run(ctx, Options{Converter: "raw-tiff"}); !errors.Is(err, ErrNoConverter) {
+		t.Errorf("Run(raw-tiff) error = %v, want ErrNoConverter", err)
+	}
+	if err := p.RemoveConverter(ctx, "xlsx-csv"); err != nil {
+		t.Errorf("RemoveConverter() error = %v", err)
+	}
+	if err := p.RemoveConverter(ctx, "xlsx-csv"); !errors.Is(err, ErrNoConverter) {
+		t.Errorf("second RemoveConverter() error = %v, want ErrNoConverter", err)
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	c := &Converter{From: []string{".CR2", "image/x-canon-cr2"}}
+	tests := []struct {
+		file dataset.File
+		want bool
+	}{
+		{dataset.File{Path: "raw/IMG_0001.cr2"}, true},
+		{dataset.File{Path: "IMG_0001", ContentType: "image/x-canon-cr2; charset=binary"}, true},
+		{dataset.File{Path: "IMG_0001.jpg", ContentType: "image/jpeg"}, false},
+		{dataset.File{Path: "cr2"}, false},
+	}
+	for _, tt := range tests {
+		if got := c.Accepts(tt.file); got != tt.want {
+			t.Errorf("Accepts(%+v) = %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
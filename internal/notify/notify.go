@@ -0,0 +1,156 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify delivers notifications to users and stewards.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/queue"
+)
+
+// Message is a notification to one recipient.
+type Message struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	Time    time.Time `json:"time"`
+}
+
+// Notifier delivers messages.
+type Notifier interface {
+	Notify(ctx context.Context, m Message) error
+}
+
+// SMTP delivers messages through an SMTP relay, such as the SES SMTP
+// interface.
+type SMTP struct {
+	// Addr is the relay host:port
+	Addr string
+
+	// From is the sender address
+	From string
+
+	// Auth authenticates to the relay; may be nil
+	Auth smtp.Auth
+}
+
+// Notify implements Notifier.
+func (s *SMTP) Notify(_ context.Context, m Message) error {
+	if strings.ContainsAny(m.To+m.Subject, "\r\n") {
+		return fmt.Errorf("invalid header in message to %q", m.To)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", s.From, m.To, m.Subject, m.Body)
+	if err := smtp.SendMail(s.Addr, s.Auth, s.From, []string{m.To}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send mail to %s: %w", m.To, err)
+	}
+	return nil
+}
+
+// Outbox appends messages as JSON Lines to a local file for delivery
+// by an operator or a later relay. It is used when no mail relay is
+// configured.
+type Outbox struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewOutbox returns an outbox stored at path.
+func NewOutbox(path string) (*Outbox, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+	return &Outbox{path: path}, nil
+}
+
+// Notify implements Notifier.
+func (o *Outbox) Notify(_ context.Context, m Message) error {
+	if m.Time.IsZero() {
+		m.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	f, err := os.OpenFile(o.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return f.Close()
+}
+
+// Queued hands messages to the notify queue for a worker to deliver,
+// so that a slow or unavailable relay does not hold up the request that
+// sent them.
+type Queued struct {
+	Queue queue.Queue
+}
+
+// Notify implements Notifier.
+func (q *Queued) Notify(ctx context.Context, m Message) error {
+	if m.Time.IsZero() {
+		m.Time = time.Now().UTC()
+	}
+	body, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	if err := q.Queue.Send(ctx, queue.TopicNotify, body); err != nil {
+		return fmt.Errorf("failed to queue message to %s: %w", m.To, err)
+	}
+	return nil
+}
+
+// Handler returns a handler of the notify queue that delivers each
+// message with n.
+func Handler(n Notifier) queue.Handler {
+	return func(ctx context.Context, body []byte) error {
+		var m Message
+		if err := json.Unmarshal(body, &m); err != nil {
+			return fmt.Errorf("invalid queued message: %w", err)
+		}
+		return n.Notify(ctx, m)
+	}
+}
+
+// Recorder keeps messages in memory. It is intended for tests.
+type Recorder struct {
+	mu       sync.Mutex
+	Messages []Message
+}
+
+// Notify implements Notifier.
+func (r *Recorder) Notify(_ context.Context, m Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Messages = append(r.Messages, m)
+	return nil
+}
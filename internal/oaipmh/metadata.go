@@ -0,0 +1,466 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/ror"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// DataCite metadata schema, kernel 4.
+const (
+	dataciteNamespace = "http://datacite.org/schema/kernel-4"
+	dataciteSchema    = "http://schema.datacite.org/meta/kernel-4/metadata.xsd"
+)
+
+// response is an OAI-PMH response document. Exactly one of the error
+// list and the verb elements is set.
+type response struct {
+	XMLName             xml.Name             `xml:"http://www.openarchives.org/OAI/2.0/ OAI-PMH"`
+	XSI                 string               `xml:"xmlns:xsi,attr"`
+	SchemaLocation      string               `xml:"xsi:schemaLocation,attr"`
+	ResponseDate        string               `xml:"responseDate"`
+	Request             request              `xml:"request"`
+	Errors              []oaiError           `xml:"error"`
+	Identify            *identify            `xml:"Identify"`
+	ListMetadataFormats *listMetadataFormats `xml:"ListMetadataFormats"`
+	ListSets            *listSets            `xml:"ListSets"`
+	GetRecord           *getRecord           `xml:"GetRecord"`
+	ListIdentifiers     *listIdentifiers     `xml:"ListIdentifiers"`
+	ListRecords         *listRecords         `xml:"ListRecords"`
+}
+
+// fail reports a protocol error. The request's arguments are not
+// echoed for badVerb and badArgument errors.
+func (r *response) fail(code, msg string) {
+	if code == errBadVerb || code == errBadArgument {
+		r.Request = request{URL: r.Request.URL}
+	}
+	r.Errors = append(r.Errors, oaiError{Code: code, Message: msg})
+}
+
+// request echoes the request.
+type request struct {
+	Verb            string `xml:"verb,attr,omitempty"`
+	Identifier      string `xml:"identifier,attr,omitempty"`
+	MetadataPrefix  string `xml:"metadataPrefix,attr,omitempty"`
+	From            string `xml:"from,attr,omitempty"`
+	Until           string `xml:"until,attr,omitempty"`
+	Set             string `xml:"set,attr,omitempty"`
+	ResumptionToken string `xml:"resumptionToken,attr,omitempty"`
+	URL             string `xml:",chardata"`
+}
+
+type oaiError struct {
+	Code    string `xml:"code,attr"`
+	Message string `xml:",chardata"`
+}
+
+type identify struct {
+	RepositoryName    string `xml:"repositoryName"`
+	BaseURL           string `xml:"baseURL"`
+	ProtocolVersion   string `xml:"protocolVersion"`
+	AdminEmail        string `xml:"adminEmail"`
+	EarliestDatestamp string `xml:"earliestDatestamp"`
+	DeletedRecord     string `xml:"deletedRecord"`
+	Granularity       string `xml:"granularity"`
+}
+
+type metadataFormat struct {
+	Prefix    string `xml:"metadataPrefix"`
+	Schema    string `xml:"schema"`
+	Namespace string `xml:"metadataNamespace"`
+}
+
+type listMetadataFormats struct {
+	Formats []metadataFormat `xml:"metadataFormat"`
+}
+
+type set struct {
+	Spec string `xml:"setSpec"`
+	Name string `xml:"setName"`
+}
+
+type listSets struct {
+	Sets []set `xml:"set"`
+}
+
+type header struct {
+	Status     string   `xml:"status,attr,omitempty"`
+	Identifier string   `xml:"identifier"`
+	Datestamp  string   `xml:"datestamp"`
+	SetSpecs   []string `xml:"setSpec"`
+}
+
+type record struct {
+	Header   header    `xml:"header"`
+	Metadata *metadata `xml:"metadata"`
+}
+
+// metadata holds a record's metadata in one format.
+type metadata struct {
+	DC       *dublinCore  `xml:"oai_dc:dc"`
+	DataCite *resource    `xml:"resource"`
+	OpenAIRE *oaiDataCite `xml:"oai_datacite"`
+}
+
+type getRecord struct {
+	Record record `xml:"record"`
+}
+
+type resumptionToken struct {
+	CompleteListSize int    `xml:"completeListSize,attr"`
+	Cursor           int    `xml:"cursor,attr"`
+	Value            string `xml:",chardata"`
+}
+
+type listIdentifiers struct {
+	Headers         []header         `xml:"header"`
+	ResumptionToken *resumptionToken `xml:"resumptionToken"`
+}
+
+type listRecords struct {
+	Records         []record         `xml:"record"`
+	ResumptionToken *resumptionToken `xml:"resumptionToken"`
+}
+
+// dublinCore is an oai_dc record. encoding/xml does not write namespace
+// prefixes, so elements are named with theirs and the prefixes are
+// declared as attributes.
+type dublinCore struct {
+	NS             string   `xml:"xmlns:oai_dc,attr"`
+	DC             string   `xml:"xmlns:dc,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	Title          []string `xml:"dc:title"`
+	Creator        []string `xml:"dc:creator"`
+	Description    []string `xml:"dc:description"`
+	Publisher      []string `xml:"dc:publisher"`
+	Date           []string `xml:"dc:date"`
+	Type           []string `xml:"dc:type"`
+	Identifier     []string `xml:"dc:identifier"`
+	Relation       []string `xml:"dc:relation"`
+	Rights         []string `xml:"dc:rights"`
+}
+
+// dublinCore describes d in Dublin Core.
+func (h *Handler) dublinCore(d *dataset.Dataset) *dublinCore {
+	dc := &dublinCore{
+		NS:             "http://www.openarchives.org/OAI/2.0/oai_dc/",
+		DC:             "http://purl.org/dc/elements/1.1/",
+		SchemaLocation: "http://www.openarchives.org/OAI/2.0/oai_dc/ http://www.openarchives.org/OAI/2.0/oai_dc.xsd",
+		Title:          []string{d.Title},
+		Type:           []string{resourceType(d)},
+		Rights:         []string{rightsURI(d, h.now())},
+	}
+	for _, c := range d.Creators {
+		dc.Creator = append(dc.Creator, c.Name)
+	}
+	if d.Description != "" {
+		dc.Description = []string{d.Description}
+	}
+	if h.opts.Publisher != "" {
+		dc.Publisher = []string{h.opts.Publisher}
+	}
+	if d.PublicationYear != 0 {
+		dc.Date = []string{strconv.Itoa(d.PublicationYear)}
+	}
+	switch {
+	case d.DOI != "":
+		dc.Identifier = append(dc.Identifier, "https://doi.org/"+d.DOI)
+	case d.ARK != "":
+		dc.Identifier = append(dc.Identifier, "https://n2t.net/"+d.ARK)
+	case d.Handle != "":
+		dc.Identifier = append(dc.Identifier, "https://hdl.handle.net/"+d.Handle)
+	}
+	if u := h.pageURL(d); u != "" {
+		dc.Identifier = append(dc.Identifier, u)
+	}
+	for _, raid := range d.RAiDs {
+		dc.Relation = append(dc.Relation, "https://raid.org/"+raid)
+	}
+	return dc
+}
+
+// resource is a DataCite metadata record.
+type resource struct {
+	XMLName            xml.Name            `xml:"http://datacite.org/schema/kernel-4 resource"`
+	XSI                string              `xml:"xmlns:xsi,attr,omitempty"`
+	SchemaLocation     string              `xml:"xsi:schemaLocation,attr"`
+	Identifier         typedValue          `xml:"identifier"`
+	Creators           []creator           `xml:"creators>creator"`
+	Titles             []title             `xml:"titles>title"`
+	Publisher          string              `xml:"publisher"`
+	PublicationYear    int                 `xml:"publicationYear,omitempty"`
+	ResourceType       resourceTypeElem    `xml:"resourceType"`
+	AlternateIDs       *alternateIDs       `xml:"alternateIdentifiers"`
+	RelatedIdentifiers *relatedIdentifiers `xml:"relatedIdentifiers"`
+	Dates              *dates              `xml:"dates"`
+	Version            string              `xml:"version,omitempty"`
+	Rights             []rights            `xml:"rightsList>rights"`
+	Descriptions       *descriptions       `xml:"descriptions"`
+	FundingReferences  *fundingReferences  `xml:"fundingReferences"`
+}
+
+// The optional lists of a resource are pointers: encoding/xml writes
+// the wrapper element of an a>b field even when the slice is empty,
+// and the schema requires wrappers to hold at least one element.
+
+type alternateIDs struct {
+	Items []alternateID `xml:"alternateIdentifier"`
+}
+
+type relatedIdentifiers struct {
+	Items []relatedIdentifier `xml:"relatedIdentifier"`
+}
+
+type dates struct {
+	Items []date `xml:"date"`
+}
+
+type descriptions struct {
+	Items []description `xml:"description"`
+}
+
+type fundingReferences struct {
+	Items []fundingReference `xml:"fundingReference"`
+}
+
+type typedValue struct {
+	Type  string `xml:"identifierType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type title struct {
+	Lang  string `xml:"xml:lang,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type alternateID struct {
+	Type  string `xml:"alternateIdentifierType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type creator struct {
+	Name            string           `xml:"creatorName"`
+	NameIdentifiers []nameIdentifier `xml:"nameIdentifier"`
+	Affiliations    []affiliation    `xml:"affiliation"`
+}
+
+type nameIdentifier struct {
+	Scheme    string `xml:"nameIdentifierScheme,attr"`
+	SchemeURI string `xml:"schemeURI,attr"`
+	Value     string `xml:",chardata"`
+}
+
+type affiliation struct {
+	Identifier string `xml:"affiliationIdentifier,attr,omitempty"`
+	Scheme     string `xml:"affiliationIdentifierScheme,attr,omitempty"`
+	SchemeURI  string `xml:"schemeURI,attr,omitempty"`
+	Name       string `xml:",chardata"`
+}
+
+type resourceTypeElem struct {
+	General string `xml:"resourceTypeGeneral,attr"`
+	Value   string `xml:",chardata"`
+}
+
+type relatedIdentifier struct {
+	Type     string `xml:"relatedIdentifierType,attr"`
+	Relation string `xml:"relationType,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type date struct {
+	Type  string `xml:"dateType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type rights struct {
+	URI string `xml:"rightsURI,attr"`
+}
+
+type description struct {
+	Type  string `xml:"descriptionType,attr"`
+	Lang  string `xml:"xml:lang,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type fundingReference struct {
+	FunderName       string         `xml:"funderName"`
+	FunderIdentifier *typedFunderID `xml:"funderIdentifier"`
+	AwardNumber      *awardNumber   `xml:"awardNumber"`
+	AwardTitle       string         `xml:"awardTitle,omitempty"`
+}
+
+type typedFunderID struct {
+	Type  string `xml:"funderIdentifierType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type awardNumber struct {
+	URI   string `xml:"awardURI,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+// dataCite describes d, which must have a DOI, in the DataCite
+// metadata schema. It also returns the funding the record references.
+func (h *Handler) dataCite(ctx context.Context, d *dataset.Dataset) (*resource, []pid.Funding, error) {
+	res := &resource{
+		SchemaLocation:  dataciteNamespace + " " + dataciteSchema,
+		Identifier:      typedValue{Type: "DOI", Value: d.DOI},
+		Titles:          []title{{Lang: d.TitleLanguage, Value: d.Title}},
+		Publisher:       h.opts.Publisher,
+		PublicationYear: d.PublicationYear,
+		ResourceType:    resourceTypeElem{General: resourceType(d), Value: resourceType(d)},
+		Rights:          []rights{{URI: rightsURI(d, h.now())}},
+	}
+	for _, c := range d.Creators {
+		cr := creator{Name: c.Name}
+		if c.ORCID != "" {
+			cr.NameIdentifiers = []nameIdentifier{{
+				Scheme:    "ORCID",
+				SchemeURI: "https://orcid.org",
+				Value:     "https://orcid.org/" + strings.TrimPrefix(c.ORCID, "https://orcid.org/"),
+			}}
+		}
+		if c.Affiliation != "" {
+			a := affiliation{Name: c.Affiliation}
+			if c.AffiliationROR != "" {
+				a.Identifier, a.Scheme, a.SchemeURI = ror.Normalize(c.AffiliationROR), "ROR", "https://ror.org"
+			}
+			cr.Affiliations = []affiliation{a}
+		}
+		res.Creators = append(res.Creators, cr)
+	}
+	if u := h.pageURL(d); u != "" {
+		res.AlternateIDs = &alternateIDs{Items: []alternateID{{Type: "URL", Value: u}}}
+	}
+	var related []relatedIdentifier
+	for _, v := range d.Versions {
+		if v.DOI != "" && dataset.NormalizeDOI(v.DOI) != dataset.NormalizeDOI(d.DOI) {
+			related = append(related, relatedIdentifier{Type: "DOI", Relation: "HasVersion", Value: v.DOI})
+		}
+	}
+	for _, raid := range d.RAiDs {
+		related = append(related, relatedIdentifier{Type: "Handle", Relation: "IsPartOf", Value: raid})
+	}
+	for _, r := range d.Related {
+		related = append(related, relatedIdentifier{Type: r.Type, Relation: r.Relation, Value: r.Identifier})
+	}
+	res.addRelated(related...)
+	var ds []date
+	if v := d.Current(); v != nil {
+		res.Version = strconv.Itoa(v.Number)
+		if v.PublishedAt != nil {
+			ds = append(ds, date{Type: "Accepted", Value: v.PublishedAt.UTC().Format("2006-01-02")})
+		}
+	}
+	if d.Embargo != nil {
+		ds = append(ds, date{Type: "Available", Value: d.Embargo.Until.UTC().Format("2006-01-02")})
+	}
+	if len(ds) > 0 {
+		res.Dates = &dates{Items: ds}
+	}
+	if d.Description != "" {
+		res.Descriptions = &descriptions{Items: []description{{Type: "Abstract", Lang: d.DescriptionLanguage, Value: d.Description}}}
+	}
+	var funding []pid.Funding
+	if h.Funding != nil && len(d.Awards) > 0 {
+		var err error
+		var refs []fundingReference
+		if funding, err = h.Funding.Funding(ctx, d); err != nil {
+			return nil, nil, fmt.Errorf("failed to read funding of %s: %w", d.ID, err)
+		}
+		for _, f := range funding {
+			ref := fundingReference{FunderName: f.FunderName, AwardTitle: f.AwardTitle}
+			if f.FunderROR != "" {
+				ref.FunderIdentifier = &typedFunderID{Type: "ROR", Value: f.FunderROR}
+			}
+			if f.AwardNumber != "" {
+				ref.AwardNumber = &awardNumber{URI: f.AwardURI, Value: f.AwardNumber}
+			}
+			refs = append(refs, ref)
+		}
+		if len(refs) > 0 {
+			res.FundingReferences = &fundingReferences{Items: refs}
+		}
+	}
+	return res, funding, nil
+}
+
+// WriteDataCite encodes d, which must have a DOI, as a standalone
+// DataCite metadata record, the record served for the oai_datacite
+// prefix.
+func (h *Handler) WriteDataCite(ctx context.Context, e *xml.Encoder, d *dataset.Dataset) error {
+	res, _, err := h.dataCite(ctx, d)
+	if err != nil {
+		return err
+	}
+	res.XSI = "http://www.w3.org/2001/XMLSchema-instance"
+	return e.Encode(res)
+}
+
+// addRelated adds related identifiers to the resource.
+func (res *resource) addRelated(ids ...relatedIdentifier) {
+	if len(ids) == 0 {
+		return
+	}
+	if res.RelatedIdentifiers == nil {
+		res.RelatedIdentifiers = &relatedIdentifiers{}
+	}
+	res.RelatedIdentifiers.Items = append(res.RelatedIdentifiers.Items, ids...)
+}
+
+// pageURL returns the URL of d's landing page, or "" if the site URL
+// is not configured.
+func (h *Handler) pageURL(d *dataset.Dataset) string {
+	if h.opts.SiteURL == "" {
+		return ""
+	}
+	return strings.TrimRight(h.opts.SiteURL, "/") + landing.PagePath(d.ID)
+}
+
+// resourceType returns d's general resource type.
+func resourceType(d *dataset.Dataset) string {
+	if d.ResourceType != "" {
+		return d.ResourceType
+	}
+	return "Dataset"
+}
+
+// rightsURI returns the COAR access right of d's files at now, in the
+// info:eu-repo vocabulary harvesters such as OpenAIRE expect. Files
+// whose embargo has lapsed are reported by their access level.
+func rightsURI(d *dataset.Dataset, now time.Time) string {
+	switch {
+	case d.Embargoed(now) || d.Access == storage.AccessEmbargoed && d.Embargo == nil:
+		return "info:eu-repo/semantics/embargoedAccess"
+	case d.Access == storage.AccessPublic:
+		return "info:eu-repo/semantics/openAccess"
+	case d.Access == storage.AccessPrivate:
+		return "info:eu-repo/semantics/closedAccess"
+	}
+	return "info:eu-repo/semantics/restrictedAccess"
+}
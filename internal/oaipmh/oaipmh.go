@@ -0,0 +1,613 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oaipmh serves dataset metadata over OAI-PMH 2.0, so that
+// aggregators and library discovery layers can harvest the repository.
+//
+// Published datasets are served in Dublin Core (oai_dc) and, when they
+// have a DOI, in the DataCite metadata schema (datacite). Each
+// collection is a set. Tombstoned datasets remain as deleted records,
+// so harvesters remove them. List responses are paged with stateless
+// resumption tokens, which suit a handler run behind a load balancer or
+// as a function.
+package oaipmh
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/pid"
+)
+
+// DefaultPageSize is the number of records in each list response.
+const DefaultPageSize = 100
+
+// Metadata prefixes.
+const (
+	PrefixDC       = "oai_dc"
+	PrefixDataCite = "datacite"
+)
+
+// granularity is the datestamp format, at the finest granularity
+// OAI-PMH allows.
+const granularity = "2006-01-02T15:04:05Z"
+
+// OAI-PMH error codes.
+const (
+	errBadArgument             = "badArgument"
+	errBadResumptionToken      = "badResumptionToken"
+	errBadVerb                 = "badVerb"
+	errCannotDisseminateFormat = "cannotDisseminateFormat"
+	errIDDoesNotExist          = "idDoesNotExist"
+	errNoRecordsMatch          = "noRecordsMatch"
+	errNoSetHierarchy          = "noSetHierarchy"
+)
+
+// Options configures a Handler.
+type Options struct {
+	// BaseURL is the public URL of the endpoint
+	BaseURL string
+
+	// RepositoryName is the name reported by Identify
+	RepositoryName string
+
+	// AdminEmail is the contact address reported by Identify
+	AdminEmail string
+
+	// SiteURL is the public base URL of landing pages
+	SiteURL string
+
+	// Publisher is the institution publishing the datasets
+	Publisher string
+
+	// PageSize is the number of records in each list response;
+	// DefaultPageSize if zero
+	PageSize int
+}
+
+// Handler serves the OAI-PMH verbs at any path, by GET or by POST.
+type Handler struct {
+	datasets *dataset.Store
+	opts     Options
+	repoID   string
+
+	// Funding supplies datasets' funding references for DataCite
+	// records; skipped if nil
+	Funding pid.FundingSource
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// NewHandler returns a handler serving the datasets in datasets.
+func NewHandler(datasets *dataset.Store, opts Options) *Handler {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultPageSize
+	}
+	h := &Handler{datasets: datasets, opts: opts}
+	for _, u := range []string{opts.SiteURL, opts.BaseURL} {
+		if p, err := url.Parse(u); err == nil && p.Hostname() != "" {
+			h.repoID = p.Hostname()
+			break
+		}
+	}
+	if h.repoID == "" {
+		h.repoID = "localhost"
+	}
+	return h
+}
+
+// Identifier returns the OAI identifier of the dataset with ID id.
+func (h *Handler) Identifier(id string) string {
+	return "oai:" + h.repoID + ":" + id
+}
+
+// SetSpec returns the set of datasets in collection: the collection
+// name with characters not allowed in a setSpec replaced by hyphens.
+func SetSpec(collection string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("-_.!~*'()", r):
+			return r
+		}
+		return '-'
+	}, collection)
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := &response{
+		XSI:            "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: "http://www.openarchives.org/OAI/2.0/ http://www.openarchives.org/OAI/2.0/OAI-PMH.xsd",
+		ResponseDate:   h.now().UTC().Format(granularity),
+		Request:        request{URL: h.opts.BaseURL},
+	}
+	if err := h.handle(r, resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data, err := xml.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+}
+
+// verbs lists the arguments each verb accepts, by whether they are
+// required. resumptionToken is exclusive of every other argument.
+var verbs = map[string]map[string]bool{
+	"Identify":            {},
+	"ListMetadataFormats": {"identifier": false},
+	"ListSets":            {"resumptionToken": false},
+	"GetRecord":           {"identifier": true, "metadataPrefix": true},
+	"ListIdentifiers":     {"metadataPrefix": true, "from": false, "until": false, "set": false, "resumptionToken": false},
+	"ListRecords":         {"metadataPrefix": true, "from": false, "until": false, "set": false, "resumptionToken": false},
+}
+
+// handle fills resp for the request r. Protocol errors are reported in
+// resp; the returned error is for failures reading the catalog.
+func (h *Handler) handle(r *http.Request, resp *response) error {
+	verb := r.Form.Get("verb")
+	args, ok := verbs[verb]
+	if !ok || len(r.Form["verb"]) != 1 {
+		resp.fail(errBadVerb, "illegal or missing verb")
+		return nil
+	}
+	for name, values := range r.Form {
+		if name == "verb" {
+			continue
+		}
+		if _, ok := args[name]; !ok {
+			resp.fail(errBadArgument, "illegal argument "+name)
+			return nil
+		}
+		if len(values) != 1 {
+			resp.fail(errBadArgument, "repeated argument "+name)
+			return nil
+		}
+	}
+	if _, ok := r.Form["resumptionToken"]; ok {
+		if len(r.Form) != 2 {
+			resp.fail(errBadArgument, "resumptionToken is an exclusive argument")
+			return nil
+		}
+	} else {
+		for name, required := range args {
+			if required && r.Form.Get(name) == "" {
+				resp.fail(errBadArgument, "missing argument "+name)
+				return nil
+			}
+		}
+	}
+	resp.Request.Verb = verb
+	resp.Request.Identifier = r.Form.Get("identifier")
+	resp.Request.MetadataPrefix = r.Form.Get("metadataPrefix")
+	resp.Request.From = r.Form.Get("from")
+	resp.Request.Until = r.Form.Get("until")
+	resp.Request.Set = r.Form.Get("set")
+	resp.Request.ResumptionToken = r.Form.Get("resumptionToken")
+
+	switch verb {
+	case "Identify":
+		return h.identify(r, resp)
+	case "ListMetadataFormats":
+		return h.listMetadataFormats(r, resp)
+	case "ListSets":
+		return h.listSets(r, resp)
+	case "GetRecord":
+		return h.getRecord(r, resp)
+	default:
+		return h.list(r, resp, verb == "ListRecords")
+	}
+}
+
+func (h *Handler) identify(r *http.Request, resp *response) error {
+	all, err := h.catalog(r)
+	if err != nil {
+		return err
+	}
+	earliest := h.now()
+	for _, d := range all {
+		if d.UpdatedAt.Before(earliest) {
+			earliest = d.UpdatedAt
+		}
+	}
+	resp.Identify = &identify{
+		RepositoryName:    h.opts.RepositoryName,
+		BaseURL:           h.opts.BaseURL,
+		ProtocolVersion:   "2.0",
+		AdminEmail:        h.opts.AdminEmail,
+		EarliestDatestamp: earliest.UTC().Format(granularity),
+		DeletedRecord:     "persistent",
+		Granularity:       "YYYY-MM-DDThh:mm:ssZ",
+	}
+	return nil
+}
+
+func (h *Handler) listMetadataFormats(r *http.Request, resp *response) error {
+	formats := []metadataFormat{
+		{Prefix: PrefixDC, Schema: "http://www.openarchives.org/OAI/2.0/oai_dc.xsd", Namespace: "http://www.openarchives.org/OAI/2.0/oai_dc/"},
+		{Prefix: PrefixDataCite, Schema: dataciteSchema, Namespace: dataciteNamespace},
+		{Prefix: PrefixOpenAIRE, Schema: oaiDataCiteSchema, Namespace: oaiDataCiteNamespace},
+	}
+	if id := r.Form.Get("identifier"); id != "" {
+		d, err := h.record(r, id)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			resp.fail(errIDDoesNotExist, "unknown identifier "+id)
+			return nil
+		}
+		if d.DOI == "" {
+			formats = formats[:1]
+		}
+	}
+	resp.ListMetadataFormats = &listMetadataFormats{Formats: formats}
+	return nil
+}
+
+func (h *Handler) listSets(r *http.Request, resp *response) error {
+	if r.Form.Get("resumptionToken") != "" {
+		resp.fail(errBadResumptionToken, "ListSets responses are not paged")
+		return nil
+	}
+	all, err := h.catalog(r)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string)
+	for _, d := range all {
+		if d.Collection != "" {
+			names[SetSpec(d.Collection)] = d.Collection
+		}
+		if d.DOI != "" {
+			names[SetOpenAIRE] = "OpenAIRE data"
+		}
+	}
+	if len(names) == 0 {
+		resp.fail(errNoSetHierarchy, "the repository has no collections")
+		return nil
+	}
+	out := &listSets{}
+	for spec, name := range names {
+		out.Sets = append(out.Sets, set{Spec: spec, Name: name})
+	}
+	sort.Slice(out.Sets, func(i, j int) bool { return out.Sets[i].Spec < out.Sets[j].Spec })
+	resp.ListSets = out
+	return nil
+}
+
+func (h *Handler) getRecord(r *http.Request, resp *response) error {
+	prefix := r.Form.Get("metadataPrefix")
+	if !validPrefix(prefix) {
+		resp.fail(errCannotDisseminateFormat, "unsupported metadata format "+prefix)
+		return nil
+	}
+	d, err := h.record(r, r.Form.Get("identifier"))
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		resp.fail(errIDDoesNotExist, "unknown identifier "+r.Form.Get("identifier"))
+		return nil
+	}
+	if !disseminates(d, prefix) {
+		resp.fail(errCannotDisseminateFormat, d.ID+" has no DOI to describe in DataCite metadata")
+		return nil
+	}
+	rec, err := h.toRecord(r, d, prefix)
+	if err != nil {
+		return err
+	}
+	resp.GetRecord = &getRecord{Record: *rec}
+	return nil
+}
+
+// list serves ListIdentifiers, or ListRecords if records is set.
+func (h *Handler) list(r *http.Request, resp *response, records bool) error {
+	q := query{
+		Prefix: r.Form.Get("metadataPrefix"),
+		From:   r.Form.Get("from"),
+		Until:  r.Form.Get("until"),
+		Set:    r.Form.Get("set"),
+	}
+	if token := r.Form.Get("resumptionToken"); token != "" {
+		var ok bool
+		if q, ok = parseToken(token); !ok {
+			resp.fail(errBadResumptionToken, "invalid resumption token")
+			return nil
+		}
+	}
+	if !validPrefix(q.Prefix) {
+		resp.fail(errCannotDisseminateFormat, "unsupported metadata format "+q.Prefix)
+		return nil
+	}
+	from, until, msg := q.window()
+	if msg != "" {
+		resp.fail(errBadArgument, msg)
+		return nil
+	}
+
+	all, err := h.catalog(r)
+	if err != nil {
+		return err
+	}
+	var matched []*dataset.Dataset
+	for _, d := range all {
+		switch {
+		case !disseminates(d, q.Prefix):
+		case !from.IsZero() && d.UpdatedAt.Before(from):
+		case !until.IsZero() && d.UpdatedAt.After(until):
+		case q.Set != "" && !inSet(d, q.Set):
+		default:
+			matched = append(matched, d)
+		}
+	}
+	if q.Offset > len(matched) || (q.Offset > 0 && q.Offset == len(matched)) {
+		resp.fail(errBadResumptionToken, "resumption token is past the end of the list")
+		return nil
+	}
+	if len(matched) == 0 {
+		resp.fail(errNoRecordsMatch, "no records match the request")
+		return nil
+	}
+
+	end := min(q.Offset+h.opts.PageSize, len(matched))
+	var token *resumptionToken
+	if q.Offset > 0 || end < len(matched) {
+		token = &resumptionToken{CompleteListSize: len(matched), Cursor: q.Offset}
+		if end < len(matched) {
+			next := q
+			next.Offset = end
+			token.Value = next.token()
+		}
+	}
+	if records {
+		out := &listRecords{ResumptionToken: token}
+		for _, d := range matched[q.Offset:end] {
+			rec, err := h.toRecord(r, d, q.Prefix)
+			if err != nil {
+				return err
+			}
+			out.Records = append(out.Records, *rec)
+		}
+		resp.ListRecords = out
+	} else {
+		out := &listIdentifiers{ResumptionToken: token}
+		for _, d := range matched[q.Offset:end] {
+			out.Headers = append(out.Headers, h.header(d))
+		}
+		resp.ListIdentifiers = out
+	}
+	return nil
+}
+
+// catalog returns the datasets served: published and tombstoned
+// datasets, ordered by ID.
+func (h *Handler) catalog(r *http.Request) ([]*dataset.Dataset, error) {
+	all, err := h.datasets.List(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	out := all[:0]
+	for _, d := range all {
+		if served(d) {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// record returns the dataset with OAI identifier id, or nil if it is
+// not served.
+func (h *Handler) record(r *http.Request, id string) (*dataset.Dataset, error) {
+	prefix := "oai:" + h.repoID + ":"
+	if !strings.HasPrefix(id, prefix) {
+		return nil, nil
+	}
+	d, err := h.datasets.Get(r.Context(), strings.TrimPrefix(id, prefix))
+	if err != nil {
+		if errors.Is(err, dataset.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !served(d) {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// served reports whether d is visible to harvesters.
+func served(d *dataset.Dataset) bool {
+	return d.State == dataset.StatePublished || d.State == dataset.StateTombstoned
+}
+
+func validPrefix(prefix string) bool {
+	return prefix == PrefixDC || prefix == PrefixDataCite || prefix == PrefixOpenAIRE
+}
+
+// disseminates reports whether d can be described in format prefix.
+// The DataCite formats require a DOI.
+func disseminates(d *dataset.Dataset, prefix string) bool {
+	return prefix == PrefixDC || d.DOI != ""
+}
+
+// sets returns the specs of the sets d belongs to: its collection and,
+// if it has a DOI, the OpenAIRE set.
+func sets(d *dataset.Dataset) []string {
+	var specs []string
+	if d.Collection != "" {
+		specs = append(specs, SetSpec(d.Collection))
+	}
+	if d.DOI != "" {
+		specs = append(specs, SetOpenAIRE)
+	}
+	return specs
+}
+
+// inSet reports whether d belongs to the set spec.
+func inSet(d *dataset.Dataset, spec string) bool {
+	return slices.Contains(sets(d), spec)
+}
+
+// header returns d's record header.
+func (h *Handler) header(d *dataset.Dataset) header {
+	hd := header{
+		Identifier: h.Identifier(d.ID),
+		Datestamp:  d.UpdatedAt.UTC().Format(granularity),
+	}
+	hd.SetSpecs = sets(d)
+	if d.State == dataset.StateTombstoned {
+		hd.Status = "deleted"
+	}
+	return hd
+}
+
+// toRecord returns d's record in format prefix. Deleted records have
+// no metadata.
+func (h *Handler) toRecord(r *http.Request, d *dataset.Dataset, prefix string) (*record, error) {
+	rec := &record{Header: h.header(d)}
+	if d.State == dataset.StateTombstoned {
+		return rec, nil
+	}
+	rec.Metadata = &metadata{}
+	switch prefix {
+	case PrefixDC:
+		rec.Metadata.DC = h.dublinCore(d)
+	case PrefixOpenAIRE:
+		oai, err := h.openAIRE(r.Context(), d)
+		if err != nil {
+			return nil, err
+		}
+		rec.Metadata.OpenAIRE = oai
+	default:
+		res, _, err := h.dataCite(r.Context(), d)
+		if err != nil {
+			return nil, err
+		}
+		rec.Metadata.DataCite = res
+	}
+	return rec, nil
+}
+
+// query is the selection of a list request, carried in resumption
+// tokens.
+type query struct {
+	Prefix string
+	From   string
+	Until  string
+	Set    string
+	Offset int
+}
+
+// window parses the query's from and until arguments. until is
+// inclusive; a day-granularity until covers the whole day. A message is
+// returned if the arguments are invalid.
+func (q query) window() (from, until time.Time, msg string) {
+	var fromDay, untilDay bool
+	var err error
+	if q.From != "" {
+		if from, fromDay, err = parseDatestamp(q.From); err != nil {
+			return from, until, "invalid from datestamp " + q.From
+		}
+	}
+	if q.Until != "" {
+		if until, untilDay, err = parseDatestamp(q.Until); err != nil {
+			return from, until, "invalid until datestamp " + q.Until
+		}
+		if untilDay {
+			until = until.Add(24*time.Hour - time.Nanosecond)
+		} else {
+			until = until.Add(time.Second - time.Nanosecond)
+		}
+	}
+	if q.From != "" && q.Until != "" {
+		if fromDay != untilDay {
+			return from, until, "from and until have different granularities"
+		}
+		if until.Before(from) {
+			return from, until, "until is before from"
+		}
+	}
+	return from, until, ""
+}
+
+// parseDatestamp parses a day- or second-granularity datestamp.
+func parseDatestamp(s string) (t time.Time, day bool, err error) {
+	if t, err = time.Parse("2006-01-02", s); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(granularity, s)
+	return t, false, err
+}
+
+// token encodes q as a resumption token.
+func (q query) token() string {
+	v := url.Values{"metadataPrefix": {q.Prefix}, "offset": {strconv.Itoa(q.Offset)}}
+	for k, s := range map[string]string{"from": q.From, "until": q.Until, "set": q.Set} {
+		if s != "" {
+			v.Set(k, s)
+		}
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(v.Encode()))
+}
+
+// parseToken decodes a resumption token.
+func parseToken(token string) (query, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return query{}, false
+	}
+	v, err := url.ParseQuery(string(data))
+	if err != nil {
+		return query{}, false
+	}
+	q := query{Prefix: v.Get("metadataPrefix"), From: v.Get("from"), Until: v.Get("until"), Set: v.Get("set")}
+	if q.Offset, err = strconv.Atoi(v.Get("offset")); err != nil || q.Offset < 0 || q.Prefix == "" {
+		return query{}, false
+	}
+	return q, true
+}
+
+func (h *Handler) now() time.Time {
+	if h.Now != nil {
+		return h.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,303 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+type fakeFunding struct{}
+
+func (fakeFunding) Funding(_ context.Context, d *dataset.Dataset) ([]pid.Funding, error) {
+	return []pid.Funding{{FunderName: "NSF", FunderROR: "https://ror.org/021nxhr62", AwardNumber: "DEB-2145678"}}, nil
+}
+
+func newHandler(t *testing.T) *Handler {
+	t.Helper()
+	ctx := context.Background()
+	s := state.NewMemoryStore()
+	day := func(d int) time.Time { return time.Date(2025, 5, d, 12, 0, 0, 0, time.UTC) }
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", DOI: "10.1234/abc", Title: "Soil cores", TitleLanguage: "en", Collection: "Ecology Lab", State: dataset.StatePublished, Access: storage.AccessPublic,
+			Creators: []dataset.Creator{{Name: "Lovelace, Ada", ORCID: "0000-0002-1825-0097", Affiliation: "University of Oxford", AffiliationROR: "052gg0110"}},
+			Awards:   []string{"021nxhr62-deb-2145678"}, UpdatedAt: day(1)},
+		{ID: "ds-2", ARK: "ark:/99999/fk4xyz", Title: "Letters", State: dataset.StatePublished, Access: storage.AccessRestricted, UpdatedAt: day(2)},
+		{ID: "ds-3", DOI: "10.1234/gone", Title: "Withdrawn", Collection: "Ecology Lab", State: dataset.StateTombstoned, UpdatedAt: day(3)},
+		{ID: "ds-4", Title: "Draft", State: dataset.StateDraft, UpdatedAt: day(4)},
+	} {
+		// Stored directly, as dataset.Store.Put stamps the update time.
+		if err := s.Put(ctx, "datasets", d.ID, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewHandler(dataset.NewStore(s), Options{
+		BaseURL:        "https://data.uni.edu/oai",
+		RepositoryName: "Example University Data",
+		AdminEmail:     "data@uni.edu",
+		SiteURL:        "https://data.uni.edu",
+		Publisher:      "Example University",
+		PageSize:       1,
+	})
+	h.Funding = fakeFunding{}
+	return h
+}
+
+// harvest makes an OAI-PMH request and decodes the response.
+func harvest(t *testing.T, h *Handler, args url.Values) (*response, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/oai?"+args.Encode(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%v: status %d: %s", args, rec.Code, rec.Body)
+	}
+	var resp response
+	if err := xml.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: invalid XML: %v", args, err)
+	}
+	return &resp, rec.Body.String()
+}
+
+func TestErrors(t *testing.T) {
+	h := newHandler(t)
+	tests := []struct {
+		args string
+		want string
+	}{
+		{"", errBadVerb},
+		{"verb=Frobnicate", errBadVerb},
+		{"verb=Identify&verb=Identify", errBadVerb},
+		{"verb=Identify&set=x", errBadArgument},
+		{"verb=ListRecords", errBadArgument},
+		{"verb=ListRecords&metadataPrefix=oai_dc&metadataPrefix=datacite", errBadArgument},
+		{"verb=ListRecords&metadataPrefix=marc21", errCannotDisseminateFormat},
+		{"verb=ListRecords&metadataPrefix=oai_dc&from=2025-05-01&until=2025-05-02T00:00:00Z", errBadArgument},
+		{"verb=ListRecords&metadataPrefix=oai_dc&from=yesterday", errBadArgument},
+		{"verb=ListRecords&metadataPrefix=oai_dc&set=none", errNoRecordsMatch},
+		{"verb=ListRecords&resumptionToken=bogus", errBadResumptionToken},
+		{"verb=ListRecords&metadataPrefix=oai_dc&resumptionToken=" + query{Prefix: "oai_dc", Offset: 1}.token(), errBadArgument},
+		{"verb=ListRecords&resumptionToken=" + query{Prefix: "oai_dc", Offset: 3}.token(), errBadResumptionToken},
+		{"verb=GetRecord&metadataPrefix=oai_dc&identifier=oai:data.uni.edu:ds-4", errIDDoesNotExist},
+		{"verb=GetRecord&metadataPrefix=oai_dc&identifier=oai:elsewhere.edu:ds-1", errIDDoesNotExist},
+		{"verb=GetRecord&metadataPrefix=datacite&identifier=oai:data.uni.edu:ds-2", errCannotDisseminateFormat},
+	}
+	for _, tt := range tests {
+		args, _ := url.ParseQuery(tt.args)
+		resp, _ := harvest(t, h, args)
+		if len(resp.Errors) != 1 || resp.Errors[0].Code != tt.want {
+			t.Errorf("%s: errors = %+v, want %s", tt.args, resp.Errors, tt.want)
+		}
+		if (tt.want == errBadVerb || tt.want == errBadArgument) && resp.Request.Verb != "" {
+			t.Errorf("%s: request echoed arguments %+v", tt.args, resp.Request)
+		}
+	}
+}
+
+func TestIdentifyAndSets(t *testing.T) {
+	h := newHandler(t)
+	resp, _ := harvest(t, h, url.Values{"verb": {"Identify"}})
+	if id := resp.Identify; id == nil || id.EarliestDatestamp != "2025-05-01T12:00:00Z" || id.DeletedRecord != "persistent" || id.BaseURL != "https://data.uni.edu/oai" {
+		t.Errorf("Identify = %+v", resp.Identify)
+	}
+
+	resp, _ = harvest(t, h, url.Values{"verb": {"ListSets"}})
+	if resp.ListSets == nil || len(resp.ListSets.Sets) != 2 || resp.ListSets.Sets[0] != (set{Spec: "Ecology-Lab", Name: "Ecology Lab"}) || resp.ListSets.Sets[1].Spec != SetOpenAIRE {
+		t.Errorf("ListSets = %+v", resp.ListSets)
+	}
+
+	resp, _ = harvest(t, h, url.Values{"verb": {"ListMetadataFormats"}, "identifier": {"oai:data.uni.edu:ds-2"}})
+	if f := resp.ListMetadataFormats; f == nil || len(f.Formats) != 1 || f.Formats[0].Prefix != PrefixDC {
+		t.Errorf("ListMetadataFormats(ds-2) = %+v", f)
+	}
+}
+
+func TestListRecords(t *testing.T) {
+	h := newHandler(t)
+	tests := []struct {
+		args url.Values
+		want []string
+	}{
+		{url.Values{"metadataPrefix": {"oai_dc"}}, []string{"ds-1", "ds-2", "ds-3"}},
+		{url.Values{"metadataPrefix": {"datacite"}}, []string{"ds-1", "ds-3"}},
+		{url.Values{"metadataPrefix": {"oai_dc"}, "set": {"Ecology-Lab"}}, []string{"ds-1", "ds-3"}},
+		{url.Values{"metadataPrefix": {"oai_datacite"}, "set": {"openaire_data"}}, []string{"ds-1", "ds-3"}},
+		{url.Values{"metadataPrefix": {"oai_dc"}, "from": {"2025-05-02"}}, []string{"ds-2", "ds-3"}},
+		{url.Values{"metadataPrefix": {"oai_dc"}, "until": {"2025-05-01T12:00:00Z"}}, []string{"ds-1"}},
+		{url.Values{"metadataPrefix": {"oai_dc"}, "from": {"2025-05-02"}, "until": {"2025-05-02"}}, []string{"ds-2"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		args := tt.args
+		args.Set("verb", "ListIdentifiers")
+		for page := 0; ; page++ {
+			resp, _ := harvest(t, h, args)
+			if resp.ListIdentifiers == nil {
+				t.Fatalf("%v: errors = %+v", tt.args, resp.Errors)
+			}
+			for _, hd := range resp.ListIdentifiers.Headers {
+				got = append(got, strings.TrimPrefix(hd.Identifier, "oai:data.uni.edu:"))
+			}
+			token := resp.ListIdentifiers.ResumptionToken
+			if len(tt.want) > 1 && (token == nil || token.Cursor != page || token.CompleteListSize != len(tt.want)) {
+				t.Fatalf("%v: resumption token = %+v", tt.args, token)
+			}
+			if token == nil || token.Value == "" {
+				break
+			}
+			args = url.Values{"verb": {"ListIdentifiers"}, "resumptionToken": {token.Value}}
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%v: harvested %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRecords(t *testing.T) {
+	h := newHandler(t)
+	resp, body := harvest(t, h, url.Values{"verb": {"GetRecord"}, "metadataPrefix": {"oai_dc"}, "identifier": {"oai:data.uni.edu:ds-2"}})
+	if resp.GetRecord == nil {
+		t.Fatalf("GetRecord errors = %+v", resp.Errors)
+	}
+	for _, want := range []string{
+		`<oai_dc:dc xmlns:oai_dc="http://www.openarchives.org/OAI/2.0/oai_dc/" xmlns:dc="http://purl.org/dc/elements/1.1/"`,
+		`<dc:title>Letters</dc:title>`,
+		`<dc:identifier>https://n2t.net/ark:/99999/fk4xyz</dc:identifier>`,
+		`<dc:identifier>https://data.uni.edu/datasets/ds-2/</dc:identifier>`,
+		`<dc:rights>info:eu-repo/semantics/restrictedAccess</dc:rights>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("oai_dc record missing %s:\n%s", want, body)
+		}
+	}
+
+	_, body = harvest(t, h, url.Values{"verb": {"GetRecord"}, "metadataPrefix": {"datacite"}, "identifier": {"oai:data.uni.edu:ds-1"}})
+	for _, want := range []string{
+		`<resource xmlns="http://datacite.org/schema/kernel-4"`,
+		`<identifier identifierType="DOI">10.1234/abc</identifier>`,
+		`<title xml:lang="en">Soil cores</title>`,
+		`<nameIdentifier nameIdentifierScheme="ORCID" schemeURI="https://orcid.org">https://orcid.org/0000-0002-1825-0097</nameIdentifier>`,
+		`<affiliation affiliationIdentifier="https://ror.org/052gg0110" affiliationIdentifierScheme="ROR" schemeURI="https://ror.org">University of Oxford</affiliation>`,
+		`<setSpec>Ecology-Lab</setSpec>`,
+		`<funderIdentifier funderIdentifierType="ROR">https://ror.org/021nxhr62</funderIdentifier>`,
+		`<rights rightsURI="info:eu-repo/semantics/openAccess"></rights>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("datacite record missing %s:\n%s", want, body)
+		}
+	}
+
+	// Standalone records declare the namespace of their schema location.
+	d, err := h.datasets.Get(context.Background(), "ds-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := h.WriteDataCite(context.Background(), xml.NewEncoder(&buf), d); err != nil {
+		t.Fatalf("WriteDataCite() error = %v", err)
+	}
+	if want := `<resource xmlns="http://datacite.org/schema/kernel-4" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation=`; !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("WriteDataCite() = %s, want prefix %s", buf.String(), want)
+	}
+	if strings.Contains(body, `xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://datacite.org`) {
+		t.Errorf("datacite record in a response redeclares xsi:\n%s", body)
+	}
+
+	resp, _ = harvest(t, h, url.Values{"verb": {"GetRecord"}, "metadataPrefix": {"oai_dc"}, "identifier": {"oai:data.uni.edu:ds-3"}})
+	if r := resp.GetRecord; r == nil || r.Record.Header.Status != "deleted" || r.Record.Metadata != nil {
+		t.Errorf("GetRecord(ds-3) = %+v, want a deleted record", r)
+	}
+}
+
+func TestOpenAIRE(t *testing.T) {
+	h := newHandler(t)
+	ctx := context.Background()
+	published := time.Date(2025, 4, 2, 9, 0, 0, 0, time.UTC)
+	if err := h.datasets.Put(ctx, &dataset.Dataset{
+		ID: "ds-5", DOI: "10.1234/later", Title: "Sediment cores", State: dataset.StatePublished, Access: storage.AccessEmbargoed,
+		Embargo:  &dataset.Embargo{Until: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Versions: []dataset.Version{{Number: 1, PublishedAt: &published}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h.Now = func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	_, body := harvest(t, h, url.Values{"verb": {"GetRecord"}, "metadataPrefix": {"oai_datacite"}, "identifier": {"oai:data.uni.edu:ds-1"}})
+	for _, want := range []string{
+		`<oai_datacite xmlns="http://schema.datacite.org/oai/oai-1.1/" xsi:schemaLocation="http://schema.datacite.org/oai/oai-1.1/ http://schema.datacite.org/oai/oai-1.1/oai.xsd">`,
+		`<schemaVersion>4</schemaVersion>`,
+		`<payload>`,
+		`<relatedIdentifier relatedIdentifierType="info" relationType="IsReferencedBy">info:eu-repo/grantAgreement/NSF//DEB-2145678</relatedIdentifier>`,
+		`<setSpec>openaire_data</setSpec>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("oai_datacite record missing %s:\n%s", want, body)
+		}
+	}
+	// Lists without entries are left out, as the schema requires.
+	if strings.Contains(body, "<descriptions>") || strings.Contains(body, "<dates>") {
+		t.Errorf("oai_datacite record has empty lists:\n%s", body)
+	}
+
+	_, body = harvest(t, h, url.Values{"verb": {"GetRecord"}, "metadataPrefix": {"oai_datacite"}, "identifier": {"oai:data.uni.edu:ds-5"}})
+	for _, want := range []string{
+		`<rights rightsURI="info:eu-repo/semantics/embargoedAccess"></rights>`,
+		`<date dateType="Accepted">2025-04-02</date>`,
+		`<date dateType="Available">2026-01-01</date>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("embargoed record missing %s:\n%s", want, body)
+		}
+	}
+
+	// A lapsed embargo is reported by the files' access level.
+	h.Now = func() time.Time { return time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC) }
+	_, body = harvest(t, h, url.Values{"verb": {"GetRecord"}, "metadataPrefix": {"oai_datacite"}, "identifier": {"oai:data.uni.edu:ds-5"}})
+	if strings.Contains(body, "embargoedAccess") {
+		t.Errorf("record after the embargo reports embargoed access:\n%s", body)
+	}
+}
+
+func TestGrantAgreement(t *testing.T) {
+	tests := []struct {
+		name string
+		f    pid.Funding
+		want string
+	}{
+		{"horizon 2020", pid.Funding{FunderROR: "00k4n6c32", FundingStream: "H2020", AwardNumber: "101017536"}, "info:eu-repo/grantAgreement/EC/H2020/101017536"},
+		{"no stream", pid.Funding{FunderROR: "https://ror.org/021nxhr62", AwardNumber: "DEB-2145678"}, "info:eu-repo/grantAgreement/NSF//DEB-2145678"},
+		{"slash in number", pid.Funding{FunderROR: "029chgv08", AwardNumber: "222/Z/21/Z"}, "info:eu-repo/grantAgreement/WT//222%2FZ%2F21%2FZ"},
+		{"unknown funder", pid.Funding{FunderROR: "052gg0110", AwardNumber: "1"}, ""},
+		{"no number", pid.Funding{FunderROR: "00k4n6c32"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GrantAgreement(tt.f); got != tt.want {
+				t.Errorf("GrantAgreement() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
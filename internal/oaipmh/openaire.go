@@ -0,0 +1,94 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oaipmh
+
+import (
+	"context"
+	"encoding/xml"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/ror"
+)
+
+// OpenAIRE harvests data archives through the oai_datacite format and
+// the openaire_data set, following the OpenAIRE Guidelines for Data
+// Archives. Records carry info:eu-repo access rights, embargo dates,
+// and grant agreement identifiers, from which OpenAIRE links datasets
+// to the projects it monitors.
+const (
+	PrefixOpenAIRE = "oai_datacite"
+	SetOpenAIRE    = "openaire_data"
+)
+
+// oai_datacite wrapper schema.
+const (
+	oaiDataCiteNamespace = "http://schema.datacite.org/oai/oai-1.1/"
+	oaiDataCiteSchema    = "http://schema.datacite.org/oai/oai-1.1/oai.xsd"
+)
+
+// openAIREFunders maps funders' ROR IDs to the short names OpenAIRE
+// uses in grant agreement identifiers. Awards of other funders are
+// reported as funding references only.
+var openAIREFunders = map[string]string{
+	"https://ror.org/00k4n6c32": "EC",   // European Commission
+	"https://ror.org/021nxhr62": "NSF",  // U.S. National Science Foundation
+	"https://ror.org/01cwqze88": "NIH",  // U.S. National Institutes of Health
+	"https://ror.org/029chgv08": "WT",   // Wellcome Trust
+	"https://ror.org/00yjd3n13": "SNSF", // Swiss National Science Foundation
+}
+
+// oaiDataCite is the oai_datacite wrapper of a DataCite record.
+type oaiDataCite struct {
+	XMLName        xml.Name  `xml:"http://schema.datacite.org/oai/oai-1.1/ oai_datacite"`
+	SchemaLocation string    `xml:"xsi:schemaLocation,attr"`
+	SchemaVersion  string    `xml:"schemaVersion"`
+	Payload        *resource `xml:"payload>resource"`
+}
+
+// openAIRE describes d, which must have a DOI, for OpenAIRE: its
+// DataCite record with the award's grant agreement identifiers added.
+func (h *Handler) openAIRE(ctx context.Context, d *dataset.Dataset) (*oaiDataCite, error) {
+	res, funding, err := h.dataCite(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range funding {
+		if id := GrantAgreement(f); id != "" {
+			res.addRelated(relatedIdentifier{Type: "info", Relation: "IsReferencedBy", Value: id})
+		}
+	}
+	return &oaiDataCite{
+		SchemaLocation: oaiDataCiteNamespace + " " + oaiDataCiteSchema,
+		SchemaVersion:  "4",
+		Payload:        res,
+	}, nil
+}
+
+// GrantAgreement returns the info:eu-repo grant agreement identifier of
+// f, info:eu-repo/grantAgreement/FUNDER/STREAM/NUMBER, or "" if OpenAIRE
+// does not know the funder. The stream is empty if the award's
+// programme is not recorded.
+func GrantAgreement(f pid.Funding) string {
+	funder, ok := openAIREFunders[ror.Normalize(f.FunderROR)]
+	if !ok || f.AwardNumber == "" {
+		return ""
+	}
+	// Identifier segments are slash-separated; slashes in the stream or
+	// number are percent-encoded.
+	escape := strings.NewReplacer("%", "%25", "/", "%2F").Replace
+	return "info:eu-repo/grantAgreement/" + funder + "/" + escape(f.FundingStream) + "/" + escape(f.AwardNumber)
+}
@@ -0,0 +1,185 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Credentials are the stored result of a login.
+type Credentials struct {
+	// Issuer and ClientID identify where the tokens came from
+	Issuer   string `json:"issuer"`
+	ClientID string `json:"clientId"`
+
+	// Token holds the current tokens
+	Token Token `json:"token"`
+
+	// Claims are the identity claims of the latest ID token
+	Claims Claims `json:"claims"`
+}
+
+// Store keeps credentials in a file readable only by the user.
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewStore returns a store at path.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Load returns the stored credentials, or ErrNotLoggedIn.
+func (s *Store) Load() (*Credentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotLoggedIn
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+	var c Credentials
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials %s: %w", s.path, err)
+	}
+	return &c, nil
+}
+
+// Save replaces the stored credentials. The file is written with mode
+// 0600 and renamed into place so it is never partially written.
+func (s *Store) Save(c *Credentials) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".credentials-*")
+	if err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the stored credentials.
+func (s *Store) Delete() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove credentials: %w", err)
+	}
+	return nil
+}
+
+// Login saves the tokens from a completed flow with their claims.
+func (s *Store) Login(c *Client, t *Token) (*Credentials, error) {
+	claims, err := t.Claims()
+	if err != nil {
+		return nil, err
+	}
+	creds := &Credentials{Issuer: c.Provider.Issuer, ClientID: c.ClientID, Token: *t, Claims: *claims}
+	if err := s.Save(creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// TokenSource returns a valid access token, refreshing and saving the
+// stored credentials when the current one has expired.
+type TokenSource struct {
+	Client *Client
+	Store  *Store
+
+	mu sync.Mutex
+}
+
+// AccessToken returns a valid access token.
+func (ts *TokenSource) AccessToken(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	creds, err := ts.Store.Load()
+	if err != nil {
+		return "", err
+	}
+	if creds.Token.Valid(ts.Client.now()) {
+		return creds.Token.AccessToken, nil
+	}
+	if creds.Token.RefreshToken == "" {
+		return "", fmt.Errorf("session expired; run 'aperture login'")
+	}
+	t, err := ts.Client.Refresh(ctx, creds.Token.RefreshToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh session (run 'aperture login'): %w", err)
+	}
+	creds.Token = *t
+	if claims, err := t.Claims(); err == nil {
+		creds.Claims = *claims
+	}
+	if err := ts.Store.Save(creds); err != nil {
+		return "", err
+	}
+	return t.AccessToken, nil
+}
+
+// Transport adds the current access token to requests.
+type Transport struct {
+	// Source supplies access tokens
+	Source *TokenSource
+
+	// Base performs requests; http.DefaultTransport if nil
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Source.AccessToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,422 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oidc signs the CLI in to an OpenID Connect provider such as
+// Cognito.
+//
+// Two flows are supported: the authorization code flow with PKCE and a
+// localhost callback, which works with any provider including Cognito,
+// and the device authorization flow for machines without a browser,
+// where the provider supports it. Tokens are kept in a file readable
+// only by the user and refreshed as needed; Transport attaches the
+// current access token to API requests.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotLoggedIn is returned when no credentials are stored.
+	ErrNotLoggedIn = errors.New("not logged in; run 'aperture login'")
+
+	// ErrDeviceFlowUnsupported is returned when the provider has no
+	// device authorization endpoint.
+	ErrDeviceFlowUnsupported = errors.New("provider does not support the device authorization flow")
+)
+
+// DefaultScopes are requested when none are configured.
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+// Provider is an OpenID provider's metadata.
+type Provider struct {
+	Issuer                      string `json:"issuer"`
+	AuthorizationEndpoint       string `json:"authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint,omitempty"`
+	RevocationEndpoint          string `json:"revocation_endpoint,omitempty"`
+	JWKSURI                     string `json:"jwks_uri,omitempty"`
+}
+
+// Discover fetches the provider metadata for issuer.
+func Discover(ctx context.Context, hc *http.Client, issuer string) (*Provider, error) {
+	u := strings.TrimRight(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery request: %w", err)
+	}
+	resp, err := client(hc).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover %s: %w", issuer, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to discover %s: HTTP %d", issuer, resp.StatusCode)
+	}
+	var p Provider
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, fmt.Errorf("failed to decode provider metadata: %w", err)
+	}
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" {
+		return nil, fmt.Errorf("provider metadata for %s lacks authorization or token endpoint", issuer)
+	}
+	return &p, nil
+}
+
+// Token is a set of tokens from the token endpoint.
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	IDToken      string    `json:"id_token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	TokenType    string    `json:"token_type"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+// Valid reports whether the access token is present and not about to
+// expire.
+func (t *Token) Valid(now time.Time) bool {
+	return t != nil && t.AccessToken != "" && now.Add(time.Minute).Before(t.Expiry)
+}
+
+// Claims are the identity claims of an ID token used by Aperture.
+type Claims struct {
+	Subject string   `json:"sub"`
+	Email   string   `json:"email"`
+	Name    string   `json:"name,omitempty"`
+	Groups  []string `json:"cognito:groups,omitempty"`
+	ORCID   string   `json:"custom:orcid,omitempty"`
+
+	// EPPN and Affiliation are asserted by institutional SAML sign-in
+	EPPN        string `json:"custom:eppn,omitempty"`
+	Affiliation string `json:"custom:affiliation,omitempty"`
+}
+
+// Claims decodes the ID token's claims. The signature is not checked:
+// the token was received directly from the token endpoint over TLS,
+// which OpenID Connect Core (3.1.3.7) allows in place of signature
+// validation. Servers receiving ID tokens from clients use a Verifier.
+func (t *Token) Claims() (*Claims, error) {
+	parts := strings.Split(t.IDToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("no valid ID token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ID token: %w", err)
+	}
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, fmt.Errorf("failed to decode ID token claims: %w", err)
+	}
+	return &c, nil
+}
+
+// Client is an OAuth client registered with a provider.
+type Client struct {
+	// Provider is the provider's metadata
+	Provider *Provider
+
+	// ClientID is the public client ID
+	ClientID string
+
+	// Scopes are requested at login; DefaultScopes if empty
+	Scopes []string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// AuthCodeFlow is an authorization code request with PKCE.
+type AuthCodeFlow struct {
+	// URL is the authorization URL to open in a browser
+	URL string
+
+	state       string
+	verifier    string
+	redirectURI string
+}
+
+// StartAuthCode begins the authorization code flow returning to
+// redirectURI.
+func (c *Client) StartAuthCode(redirectURI string) (*AuthCodeFlow, error) {
+	state, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(c.scopes(), " ")},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(c.Provider.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return &AuthCodeFlow{
+		URL:         c.Provider.AuthorizationEndpoint + sep + q.Encode(),
+		state:       state,
+		verifier:    verifier,
+		redirectURI: redirectURI,
+	}, nil
+}
+
+// Exchange completes the flow with the query parameters the provider
+// sent to the redirect URI.
+func (c *Client) Exchange(ctx context.Context, f *AuthCodeFlow, callback url.Values) (*Token, error) {
+	if e := callback.Get("error"); e != "" {
+		return nil, fmt.Errorf("login failed: %s %s", e, callback.Get("error_description"))
+	}
+	if callback.Get("state") != f.state {
+		return nil, fmt.Errorf("login failed: state mismatch")
+	}
+	code := callback.Get("code")
+	if code == "" {
+		return nil, fmt.Errorf("login failed: no authorization code")
+	}
+	return c.token(ctx, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {f.redirectURI},
+		"code_verifier": {f.verifier},
+	})
+}
+
+// LoginBrowser runs the authorization code flow with a redirect to a
+// temporary server on addr (host:port), which must match a callback
+// URL registered for the client as http://localhost:PORT/callback.
+// open is called with the URL the user must visit.
+func (c *Client) LoginBrowser(ctx context.Context, addr string, open func(url string)) (*Token, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid callback address %q: %w", addr, err)
+	}
+	flow, err := c.StartAuthCode("http://localhost:" + port + "/callback")
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the login callback: %w", err)
+	}
+
+	got := make(chan url.Values, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "Aperture login complete. You can close this window.")
+		select {
+		case got <- r.URL.Query():
+		default:
+		}
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	open(flow.URL)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case q := <-got:
+		return c.Exchange(ctx, flow, q)
+	}
+}
+
+// DeviceFlow is a pending device authorization.
+type DeviceFlow struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval,omitempty"`
+}
+
+// StartDevice begins the device authorization flow.
+func (c *Client) StartDevice(ctx context.Context) (*DeviceFlow, error) {
+	if c.Provider.DeviceAuthorizationEndpoint == "" {
+		return nil, ErrDeviceFlowUnsupported
+	}
+	var f DeviceFlow
+	form := url.Values{"client_id": {c.ClientID}, "scope": {strings.Join(c.scopes(), " ")}}
+	if err := c.post(ctx, c.Provider.DeviceAuthorizationEndpoint, form, &f); err != nil {
+		return nil, err
+	}
+	if f.Interval <= 0 {
+		f.Interval = 5
+	}
+	return &f, nil
+}
+
+// PollDevice waits for the user to approve f and returns the tokens.
+func (c *Client) PollDevice(ctx context.Context, f *DeviceFlow) (*Token, error) {
+	interval := time.Duration(f.Interval) * time.Second
+	deadline := c.now().Add(time.Duration(f.ExpiresIn) * time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		t, err := c.token(ctx, url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {f.DeviceCode},
+		})
+		var oe *Error
+		switch {
+		case err == nil:
+			return t, nil
+		case errors.As(err, &oe) && oe.Code == "authorization_pending":
+		case errors.As(err, &oe) && oe.Code == "slow_down":
+			interval += 5 * time.Second
+		default:
+			return nil, err
+		}
+		if f.ExpiresIn > 0 && c.now().After(deadline) {
+			return nil, fmt.Errorf("login failed: device code expired")
+		}
+	}
+}
+
+// Refresh exchanges a refresh token for new tokens. Providers that do
+// not rotate refresh tokens omit one, so the old token is kept.
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	t, err := c.token(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if t.RefreshToken == "" {
+		t.RefreshToken = refreshToken
+	}
+	return t, nil
+}
+
+// token calls the token endpoint.
+func (c *Client) token(ctx context.Context, form url.Values) (*Token, error) {
+	form.Set("client_id", c.ClientID)
+	var out struct {
+		Token
+		ExpiresIn int `json:"expires_in"`
+	}
+	if err := c.post(ctx, c.Provider.TokenEndpoint, form, &out); err != nil {
+		return nil, err
+	}
+	t := out.Token
+	if t.TokenType == "" {
+		t.TokenType = "Bearer"
+	}
+	if out.ExpiresIn > 0 {
+		t.Expiry = c.now().Add(time.Duration(out.ExpiresIn) * time.Second).UTC()
+	}
+	return &t, nil
+}
+
+// Error is an OAuth error response.
+type Error struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth: HTTP %d %s: %s", e.StatusCode, e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth: HTTP %d %s", e.StatusCode, e.Code)
+}
+
+// post sends a form and decodes the JSON response into out.
+func (c *Client) post(ctx context.Context, endpoint string, form url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client(c.HTTPClient).Do(req)
+	if err != nil {
+		return fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(data, e)
+		return e
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode token response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) scopes() []string {
+	if len(c.Scopes) > 0 {
+		return c.Scopes
+	}
+	return DefaultScopes
+}
+
+func (c *Client) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+func client(hc *http.Client) *http.Client {
+	if hc != nil {
+		return hc
+	}
+	return http.DefaultClient
+}
+
+// randomString returns 32 random bytes, base64url encoded.
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProvider is a minimal OpenID provider.
+type fakeProvider struct {
+	srv       *httptest.Server
+	challenge string
+	refreshed int
+	pending   int
+	mu        sync.Mutex
+}
+
+func idToken(email string, groups ...string) string {
+	claims, _ := json.Marshal(map[string]any{"sub": "u-1", "email": email, "cognito:groups": groups})
+	return "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	p := &fakeProvider{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Provider{
+			Issuer:                      p.srv.URL,
+			AuthorizationEndpoint:       p.srv.URL + "/oauth2/authorize",
+			TokenEndpoint:               p.srv.URL + "/oauth2/token",
+			DeviceAuthorizationEndpoint: p.srv.URL + "/oauth2/device",
+		})
+	})
+	mux.HandleFunc("POST /oauth2/device", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(DeviceFlow{DeviceCode: "dc", UserCode: "ABCD-EFGH", VerificationURI: p.srv.URL + "/device", ExpiresIn: 60, Interval: 1})
+	})
+	mux.HandleFunc("POST /oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		r.ParseForm()
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			if r.Form.Get("code") != "good" || base64.RawURLEncoding.EncodeToString(sum[:]) != p.challenge {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+		case "refresh_token":
+			p.refreshed++
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			if p.pending > 0 {
+				p.pending--
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"authorization_pending"}`))
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "at",
+			"id_token":      idToken("jane@uni.edu", "curators"),
+			"refresh_token": "rt",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		})
+	})
+	p.srv = httptest.NewServer(mux)
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+func TestAuthCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	fp := newFakeProvider(t)
+	provider, err := Discover(ctx, nil, fp.srv.URL)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	c := &Client{Provider: provider, ClientID: "cli"}
+
+	flow, err := c.StartAuthCode("http://localhost:8976/callback")
+	if err != nil {
+		t.Fatalf("StartAuthCode() error = %v", err)
+	}
+	u, _ := url.Parse(flow.URL)
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "cli" {
+		t.Errorf("authorization URL = %s", flow.URL)
+	}
+	fp.challenge = q.Get("code_challenge")
+
+	if _, err := c.Exchange(ctx, flow, url.Values{"code": {"good"}, "state": {"forged"}}); err == nil {
+		t.Error("Exchange() accepted a mismatched state")
+	}
+	tok, err := c.Exchange(ctx, flow, url.Values{"code": {"good"}, "state": {q.Get("state")}})
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	claims, err := tok.Claims()
+	if err != nil || claims.Email != "jane@uni.edu" || len(claims.Groups) != 1 || claims.Groups[0] != "curators" {
+		t.Errorf("Claims() = %+v, %v", claims, err)
+	}
+}
+
+func TestDeviceFlow(t *testing.T) {
+	ctx := context.Background()
+	fp := newFakeProvider(t)
+	fp.pending = 1
+	provider, err := Discover(ctx, nil, fp.srv.URL)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	c := &Client{Provider: provider, ClientID: "cli"}
+
+	flow, err := c.StartDevice(ctx)
+	if err != nil {
+		t.Fatalf("StartDevice() error = %v", err)
+	}
+	if flow.UserCode != "ABCD-EFGH" {
+		t.Errorf("StartDevice() = %+v", flow)
+	}
+	tok, err := c.PollDevice(ctx, flow)
+	if err != nil || tok.AccessToken != "at" {
+		t.Errorf("PollDevice() = %+v, %v", tok, err)
+	}
+}
+
+func TestTransportRefreshes(t *testing.T) {
+	ctx := context.Background()
+	fp := newFakeProvider(t)
+	provider, _ := Discover(ctx, nil, fp.srv.URL)
+	c := &Client{Provider: provider, ClientID: "cli"}
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	store := NewStore(path)
+	if _, err := store.Load(); err != ErrNotLoggedIn {
+		t.Errorf("Load() before login error = %v, want ErrNotLoggedIn", err)
+	}
+	expired := &Token{AccessToken: "old", RefreshToken: "rt", IDToken: idToken("jane@uni.edu"), Expiry: time.Now().Add(-time.Hour)}
+	if _, err := store.Login(c, expired); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("credentials mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+	hc := &http.Client{Transport: &Transport{Source: &TokenSource{Client: c, Store: store}}}
+	for range 2 {
+		resp, err := hc.Get(api.URL)
+		if err != nil {
+			t.Fatalf("GET error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET status = %d, want 200", resp.StatusCode)
+		}
+	}
+	if fp.refreshed != 1 {
+		t.Errorf("refreshed %d times, want 1", fp.refreshed)
+	}
+	creds, _ := store.Load()
+	if creds.Claims.Groups[0] != "curators" {
+		t.Errorf("claims after refresh = %+v", creds.Claims)
+	}
+}
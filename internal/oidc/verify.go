@@ -0,0 +1,231 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidToken is returned for an ID token that fails verification.
+var ErrInvalidToken = errors.New("invalid ID token")
+
+// refetchInterval limits how often a Verifier fetches the provider's
+// keys to look for one it has not seen.
+const refetchInterval = 5 * time.Minute
+
+// leeway allows for clock skew when checking expiry.
+const leeway = time.Minute
+
+// Verifier checks ID tokens that clients present to a server: their
+// RS256 signature against the keys the provider publishes, their
+// issuer and audience, and their expiry. The keys are fetched on first
+// use and again when a token names a key not yet seen, so a rotated
+// key is picked up.
+type Verifier struct {
+	// Issuer is the provider's issuer URL
+	Issuer string
+
+	// ClientID is the client the tokens must be issued to
+	ClientID string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// Verify returns the claims of the ID token raw if it is valid, and
+// otherwise an error wrapping ErrInvalidToken.
+func (v *Verifier) Verify(ctx context.Context, raw string) (*Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a JWT", ErrInvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var registered struct {
+		Issuer    string   `json:"iss"`
+		Audience  audience `json:"aud"`
+		Expiry    int64    `json:"exp"`
+		NotBefore int64    `json:"nbf"`
+
+		// TokenUse distinguishes Cognito's ID and access tokens
+		TokenUse string `json:"token_use"`
+	}
+	if err := decodeSegment(parts[1], &registered); err != nil {
+		return nil, err
+	}
+	now := v.now()
+	switch {
+	case registered.Issuer != strings.TrimRight(v.Issuer, "/"):
+		return nil, fmt.Errorf("%w: issued by %q", ErrInvalidToken, registered.Issuer)
+	case !slices.Contains(registered.Audience, v.ClientID):
+		return nil, fmt.Errorf("%w: not issued to this client", ErrInvalidToken)
+	case registered.TokenUse != "" && registered.TokenUse != "id":
+		return nil, fmt.Errorf("%w: a %s token, not an ID token", ErrInvalidToken, registered.TokenUse)
+	case registered.Expiry == 0 || now.After(time.Unix(registered.Expiry, 0).Add(leeway)):
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	case registered.NotBefore != 0 && now.Add(leeway).Before(time.Unix(registered.NotBefore, 0)):
+		return nil, fmt.Errorf("%w: not yet valid", ErrInvalidToken)
+	}
+	var c Claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// key returns the provider's key kid, fetching the keys if they have
+// not been fetched or kid is new to them.
+func (v *Verifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if k, ok := v.keys[kid]; ok {
+		return k, nil
+	}
+	if v.keys != nil && v.now().Sub(v.fetched) < refetchInterval {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.keys, v.fetched = keys, v.now()
+	if k, ok := keys[kid]; ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+}
+
+// fetchKeys returns the RSA keys in the provider's JWK set by key ID.
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	p, err := Discover(ctx, v.HTTPClient, v.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	if p.JWKSURI == "" {
+		return nil, fmt.Errorf("provider metadata for %s lacks jwks_uri", v.Issuer)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.JWKSURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key set request: %w", err)
+	}
+	resp, err := client(v.HTTPClient).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the keys of %s: %w", v.Issuer, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch the keys of %s: HTTP %d", v.Issuer, resp.StatusCode)
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode the keys of %s: %w", v.Issuer, err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+// decodeSegment decodes a base64url JSON segment of a JWT into out.
+func decodeSegment(seg string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%w: malformed segment: %v", ErrInvalidToken, err)
+	}
+	return nil
+}
+
+// audience is the aud claim, which is a string or an array of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
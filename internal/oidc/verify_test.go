@@ -0,0 +1,159 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// keyProvider publishes RSA keys and signs ID tokens with them.
+type keyProvider struct {
+	srv  *httptest.Server
+	keys map[string]*rsa.PrivateKey
+}
+
+func newKeyProvider(t *testing.T) *keyProvider {
+	t.Helper()
+	p := &keyProvider{keys: map[string]*rsa.PrivateKey{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Provider{
+			Issuer:                p.srv.URL,
+			AuthorizationEndpoint: p.srv.URL + "/authorize",
+			TokenEndpoint:         p.srv.URL + "/token",
+			JWKSURI:               p.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		var set struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		for kid, k := range p.keys {
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(set)
+	})
+	p.srv = httptest.NewServer(mux)
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+// addKey generates and publishes a key.
+func (p *keyProvider) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.keys[kid] = k
+	return k
+}
+
+// sign returns a JWT of claims signed by k under kid.
+func sign(t *testing.T, k *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	enc := func(v any) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := enc(map[string]string{"alg": "RS256", "kid": kid}) + "." + enc(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerifier(t *testing.T) {
+	ctx := context.Background()
+	p := newKeyProvider(t)
+	key := p.addKey(t, "k1")
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	v := &Verifier{Issuer: p.srv.URL, ClientID: "web", Now: func() time.Time { return now }}
+
+	claims := func(changes map[string]any) map[string]any {
+		c := map[string]any{
+			"iss":            p.srv.URL,
+			"aud":            "web",
+			"exp":            now.Add(time.Hour).Unix(),
+			"token_use":      "id",
+			"email":          "cat@uni.edu",
+			"cognito:groups": []string{"curators"},
+		}
+		for k, v := range changes {
+			c[k] = v
+		}
+		return c
+	}
+
+	c, err := v.Verify(ctx, sign(t, key, "k1", claims(nil)))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if c.Email != "cat@uni.edu" || len(c.Groups) != 1 || c.Groups[0] != "curators" {
+		t.Errorf("Verify() = %+v", c)
+	}
+
+	forged := p.addKey(t, "forger")
+	delete(p.keys, "forger")
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"not a JWT", "opaque-machine-token"},
+		{"other audience", sign(t, key, "k1", claims(map[string]any{"aud": "cli"}))},
+		{"other issuer", sign(t, key, "k1", claims(map[string]any{"iss": "https://evil.example"}))},
+		{"expired", sign(t, key, "k1", claims(map[string]any{"exp": now.Add(-time.Hour).Unix()}))},
+		{"access token", sign(t, key, "k1", claims(map[string]any{"token_use": "access"}))},
+		{"signed by another key", sign(t, forged, "k1", claims(nil))},
+		{"unpublished key", sign(t, forged, "forger", claims(nil))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(ctx, tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify() error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+
+	// A rotated key is fetched once the previous fetch is old enough.
+	rotated := p.addKey(t, "k2")
+	token := sign(t, rotated, "k2", claims(map[string]any{"aud": []string{"web", "other"}}))
+	if _, err := v.Verify(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify() with a key published since the last fetch error = %v, want ErrInvalidToken until refetched", err)
+	}
+	now = now.Add(refetchInterval)
+	if _, err := v.Verify(ctx, token); err != nil {
+		t.Errorf("Verify() with a rotated key error = %v", err)
+	}
+}
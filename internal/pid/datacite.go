@@ -0,0 +1,184 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pid
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+)
+
+// DOIClient creates and updates DataCite DOIs. *datacite.Client
+// implements it.
+type DOIClient interface {
+	CreateDOI(ctx context.Context, attrs datacite.Attributes) (*datacite.DOI, error)
+	UpdateDOI(ctx context.Context, doi string, attrs datacite.Attributes) (*datacite.DOI, error)
+}
+
+// MediaClient registers the media of DataCite DOIs. *datacite.Client
+// implements it.
+type MediaClient interface {
+	SetMedia(ctx context.Context, doi string, media []datacite.Media) error
+}
+
+// DataCite mints findable DataCite DOIs.
+type DataCite struct {
+	// Client is the DataCite API client
+	Client DOIClient
+
+	// Media registers direct links to DOIs' files; skipped if nil
+	Media MediaClient
+
+	// Prefix is the DOI prefix; DataCite generates the suffix
+	Prefix string
+
+	// Synced, if set, is called with the outcome of each registration,
+	// e.g. to report the last successful sync on the status page
+	Synced func(ctx context.Context, err error)
+}
+
+// synced reports the outcome of a registration to Synced.
+func (m *DataCite) synced(ctx context.Context, err error) {
+	if m.Synced != nil {
+		m.Synced(ctx, err)
+	}
+}
+
+// Scheme implements Minter.
+func (m *DataCite) Scheme() Scheme {
+	return SchemeDOI
+}
+
+// Mint implements Minter.
+func (m *DataCite) Mint(ctx context.Context, r Record) (string, error) {
+	attrs := Attributes(r)
+	attrs.Prefix = m.Prefix
+	attrs.Event = "publish"
+	doi, err := m.Client.CreateDOI(ctx, attrs)
+	m.synced(ctx, err)
+	if err != nil {
+		return "", err
+	}
+	if doi.Attributes.DOI != "" {
+		return doi.Attributes.DOI, nil
+	}
+	return doi.ID, nil
+}
+
+// Update implements Minter.
+func (m *DataCite) Update(ctx context.Context, id string, r Record) error {
+	_, err := m.Client.UpdateDOI(ctx, id, Attributes(r))
+	m.synced(ctx, err)
+	return err
+}
+
+// RegisterMedia registers direct links to the files of d's latest
+// version with its DOI, so that content negotiation on the DOI returns
+// the files and not just the landing page. The links are the counted
+// download links under DownloadURL. DataCite resolves each media type
+// to one URL, so only the first file of each type is registered. It
+// returns the media registered: none if d has no DOI, its files are not
+// publicly downloadable, or the DOI minter cannot register media.
+func (r *Registrar) RegisterMedia(ctx context.Context, d *dataset.Dataset) ([]datacite.Media, error) {
+	m, ok := r.Minters[SchemeDOI].(*DataCite)
+	if !ok || m.Media == nil || d.DOI == "" {
+		return nil, nil
+	}
+	media := Media(d, r.DownloadURL, time.Now())
+	if len(media) == 0 {
+		return nil, nil
+	}
+	if err := m.Media.SetMedia(ctx, d.DOI, media); err != nil {
+		return nil, fmt.Errorf("failed to register media of %s: %w", d.DOI, err)
+	}
+	return media, nil
+}
+
+// Media returns the media of d at time now: the download link under
+// base of the first file of each media type in its current version,
+// ordered by media type.
+func Media(d *dataset.Dataset, base string, now time.Time) []datacite.Media {
+	links := landing.DownloadLinks(base, d, now)
+	if len(links) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var media []datacite.Media
+	for _, f := range d.Current().Files {
+		typ, _, _ := strings.Cut(f.ContentType, ";")
+		typ = strings.TrimSpace(typ)
+		if typ == "" || seen[typ] {
+			continue
+		}
+		seen[typ] = true
+		media = append(media, datacite.Media{MediaType: typ, URL: links[f.Path]})
+	}
+	sort.Slice(media, func(i, j int) bool { return media[i].MediaType < media[j].MediaType })
+	return media
+}
+
+// Attributes converts r to DataCite attributes.
+func Attributes(r Record) datacite.Attributes {
+	attrs := datacite.Attributes{
+		URL:             r.URL,
+		Titles:          []datacite.Title{{Title: r.Title, Lang: r.TitleLanguage}},
+		Publisher:       r.Publisher,
+		PublicationYear: r.Year,
+		Types:           &datacite.Types{ResourceTypeGeneral: r.ResourceType},
+		Version:         r.Version,
+	}
+	for _, rel := range r.Related {
+		attrs.RelatedIdentifiers = append(attrs.RelatedIdentifiers, datacite.RelatedIdentifier{
+			RelatedIdentifier:     rel.ID,
+			RelatedIdentifierType: rel.Type,
+			RelationType:          rel.Relation,
+		})
+	}
+	for _, f := range r.Funding {
+		ref := datacite.FundingReference{FunderName: f.FunderName, AwardNumber: f.AwardNumber, AwardTitle: f.AwardTitle, AwardURI: f.AwardURI}
+		if f.FunderROR != "" {
+			ref.FunderIdentifier = f.FunderROR
+			ref.FunderIdentifierType = "ROR"
+		}
+		attrs.FundingReferences = append(attrs.FundingReferences, ref)
+	}
+	for _, c := range r.Creators {
+		cr := datacite.Creator{Name: c.Name}
+		if c.ORCID != "" {
+			cr.NameIdentifiers = []datacite.NameIdentifier{{
+				NameIdentifier:       "https://orcid.org/" + strings.TrimPrefix(c.ORCID, "https://orcid.org/"),
+				NameIdentifierScheme: "ORCID",
+				SchemeURI:            "https://orcid.org",
+			}}
+		}
+		if c.Affiliation != "" {
+			a := datacite.Affiliation{Name: c.Affiliation}
+			if c.AffiliationROR != "" {
+				a.AffiliationIdentifier = c.AffiliationROR
+				a.AffiliationIdentifierScheme = "ROR"
+				a.SchemeURI = "https://ror.org"
+			}
+			cr.Affiliation = []datacite.Affiliation{a}
+		}
+		attrs.Creators = append(attrs.Creators, cr)
+	}
+	return attrs
+}
@@ -0,0 +1,174 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pid
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultEZIDURL is the EZID API.
+const DefaultEZIDURL = "https://ezid.cdlib.org"
+
+// Resolver is the N2T resolver ARKs are cited through.
+const Resolver = "https://n2t.net/"
+
+// EZIDOptions configures an EZID minter.
+type EZIDOptions struct {
+	// BaseURL is the EZID API root
+	BaseURL string
+
+	// Username and Password are the EZID account credentials
+	Username, Password string
+
+	// Shoulder is the ARK shoulder minted under (e.g. "ark:/99999/fk4")
+	Shoulder string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// EZID mints ARKs through the EZID API.
+type EZID struct {
+	baseURL  string
+	username string
+	password string
+	shoulder string
+	http     *http.Client
+}
+
+// NewEZID returns an EZID minter with the given options.
+func NewEZID(opts EZIDOptions) *EZID {
+	m := &EZID{
+		baseURL:  strings.TrimRight(opts.BaseURL, "/"),
+		username: opts.Username,
+		password: opts.Password,
+		shoulder: opts.Shoulder,
+		http:     opts.HTTPClient,
+	}
+	if m.baseURL == "" {
+		m.baseURL = DefaultEZIDURL
+	}
+	if m.http == nil {
+		m.http = http.DefaultClient
+	}
+	return m
+}
+
+// Scheme implements Minter.
+func (m *EZID) Scheme() Scheme {
+	return SchemeARK
+}
+
+// Mint implements Minter.
+func (m *EZID) Mint(ctx context.Context, r Record) (string, error) {
+	return m.do(ctx, "/shoulder/"+m.shoulder, r)
+}
+
+// Update implements Minter.
+func (m *EZID) Update(ctx context.Context, id string, r Record) error {
+	_, err := m.do(ctx, "/id/"+id, r)
+	return err
+}
+
+// do posts r's metadata to path and returns the identifier EZID
+// reports.
+func (m *EZID) do(ctx context.Context, path string, r Record) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+path, strings.NewReader(anvl(metadata(r))))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(m.username, m.password)
+	req.Header.Set("Content-Type", "text/plain; charset=UTF-8")
+	resp, err := m.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call EZID: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read EZID response: %w", err)
+	}
+	status, rest, _ := strings.Cut(strings.TrimSpace(string(body)), ":")
+	rest = strings.TrimSpace(rest)
+	if status != "success" {
+		if status == "error" {
+			return "", fmt.Errorf("EZID: %s", rest)
+		}
+		return "", fmt.Errorf("EZID: unexpected response (HTTP %d): %s", resp.StatusCode, body)
+	}
+	// Minting with a DOI shoulder also reports the shadow ARK:
+	// "success: doi:... | ark:/...".
+	id, _, _ := strings.Cut(rest, " ")
+	return id, nil
+}
+
+// metadata returns the EZID metadata elements for r, using the ERC
+// profile.
+func metadata(r Record) map[string]string {
+	names := make([]string, len(r.Creators))
+	for i, c := range r.Creators {
+		names[i] = c.Name
+	}
+	who := strings.Join(names, "; ")
+	if who == "" {
+		who = r.Publisher
+	}
+	md := map[string]string{
+		"_profile": "erc",
+		"_status":  "public",
+		"_target":  r.URL,
+		"erc.who":  who,
+		"erc.what": r.Title,
+	}
+	if r.Year != 0 {
+		md["erc.when"] = strconv.Itoa(r.Year)
+	}
+	return md
+}
+
+// anvl encodes md in EZID's ANVL request format, one "name: value"
+// line per element, escaping the characters that would break it.
+func anvl(md map[string]string) string {
+	names := make([]string, 0, len(md))
+	for n := range md {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, n := range names {
+		fmt.Fprintf(&b, "%s: %s\n", anvlEscape(n, true), anvlEscape(md[n], false))
+	}
+	return b.String()
+}
+
+// anvlEscape percent-encodes "%", line breaks, and in names ":".
+func anvlEscape(s string, name bool) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch {
+		case c == '%', c == '\n', c == '\r', name && c == ':':
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,230 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pid
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// HandleResolver is the Handle.Net proxy handles are cited through.
+const HandleResolver = "https://hdl.handle.net/"
+
+// Handle response codes (Handle.Net technical manual, section 14).
+const (
+	handleSuccess       = 1
+	handleAlreadyExists = 101
+)
+
+// maxCollisions is the number of freshly generated suffixes tried
+// before minting gives up.
+const maxCollisions = 3
+
+// suffixAlphabet omits characters that are easily confused when a
+// handle is read aloud or retyped.
+const suffixAlphabet = "0123456789bcdfghjkmnpqrstvwxz"
+
+// HandleOptions configures a handle minter.
+type HandleOptions struct {
+	// BaseURL is the handle server's REST API root, e.g.
+	// "https://hdl.example.edu:8000"
+	BaseURL string
+
+	// Prefix is the institution's handle prefix (e.g. "20.500.12345")
+	Prefix string
+
+	// Admin is the administrator handle index authenticating requests;
+	// "300:0.NA/<prefix>" if empty
+	Admin string
+
+	// Password is the administrator's secret key
+	Password string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Handle registers handles directly with an institution's handle
+// server through its REST admin API.
+type Handle struct {
+	baseURL  string
+	prefix   string
+	admin    string
+	password string
+	http     *http.Client
+}
+
+// NewHandle returns a handle minter with the given options.
+func NewHandle(opts HandleOptions) *Handle {
+	m := &Handle{
+		baseURL:  strings.TrimRight(opts.BaseURL, "/"),
+		prefix:   strings.Trim(opts.Prefix, "/"),
+		admin:    opts.Admin,
+		password: opts.Password,
+		http:     opts.HTTPClient,
+	}
+	if m.admin == "" {
+		m.admin = "300:0.NA/" + m.prefix
+	}
+	if m.http == nil {
+		m.http = http.DefaultClient
+	}
+	return m
+}
+
+// handleValue is one typed value of a handle record.
+type handleValue struct {
+	Index int    `json:"index"`
+	Type  string `json:"type"`
+	Data  struct {
+		Format string `json:"format"`
+		Value  any    `json:"value"`
+	} `json:"data"`
+}
+
+// handleResponse is the body of every handle API response.
+type handleResponse struct {
+	ResponseCode int    `json:"responseCode"`
+	Handle       string `json:"handle"`
+	Message      string `json:"message"`
+}
+
+// errHandleExists is returned when creating a handle that exists.
+var errHandleExists = errors.New("handle already exists")
+
+// Scheme implements Minter.
+func (m *Handle) Scheme() Scheme {
+	return SchemeHandle
+}
+
+// Mint implements Minter. Suffixes are random, retried on the rare
+// collision with an existing handle.
+func (m *Handle) Mint(ctx context.Context, r Record) (string, error) {
+	for range maxCollisions {
+		h := m.prefix + "/" + newSuffix()
+		err := m.put(ctx, h, m.values(r, true), false)
+		if errors.Is(err, errHandleExists) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return h, nil
+	}
+	return "", fmt.Errorf("no free handle under %s after %d attempts", m.prefix, maxCollisions)
+}
+
+// Update implements Minter. Only the values Aperture registered are
+// replaced; the administrator value is left alone.
+func (m *Handle) Update(ctx context.Context, id string, r Record) error {
+	return m.put(ctx, id, m.values(r, false), true)
+}
+
+// values returns the handle values for r: the landing page URL, a
+// description, and when creating, the administrator value.
+func (m *Handle) values(r Record, create bool) []handleValue {
+	value := func(index int, typ, format string, v any) handleValue {
+		hv := handleValue{Index: index, Type: typ}
+		hv.Data.Format, hv.Data.Value = format, v
+		return hv
+	}
+	vals := []handleValue{value(1, "URL", "string", r.URL)}
+	if r.Title != "" {
+		vals = append(vals, value(2, "DESC", "string", r.Title))
+	}
+	if create {
+		index, handle := splitAdmin(m.admin)
+		vals = append(vals, value(100, "HS_ADMIN", "admin", map[string]any{
+			"handle":      handle,
+			"index":       index,
+			"permissions": "011111110011",
+		}))
+	}
+	return vals
+}
+
+// put writes values to handle h. Without overwrite the handle must not
+// exist; with it, only the given indexes are replaced.
+func (m *Handle) put(ctx context.Context, h string, values []handleValue, overwrite bool) error {
+	body, err := json.Marshal(map[string]any{"values": values})
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	path := "/api/handles/" + h + "?overwrite=" + strconv.FormatBool(overwrite)
+	if overwrite {
+		for _, v := range values {
+			path += fmt.Sprintf("&index=%d", v.Index)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, m.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	// The administrator contains ":", so it is percent-encoded.
+	req.SetBasicAuth(url.QueryEscape(m.admin), m.password)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("handle request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read handle response: %w", err)
+	}
+	var out handleResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		return fmt.Errorf("handle server: HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	switch out.ResponseCode {
+	case handleSuccess:
+		return nil
+	case handleAlreadyExists:
+		return errHandleExists
+	}
+	if out.Message == "" {
+		out.Message = http.StatusText(resp.StatusCode)
+	}
+	return fmt.Errorf("handle server: %s (response code %d)", out.Message, out.ResponseCode)
+}
+
+// splitAdmin splits an "index:handle" administrator.
+func splitAdmin(admin string) (int, string) {
+	i, h, ok := strings.Cut(admin, ":")
+	if !ok {
+		return 0, admin
+	}
+	n, _ := strconv.Atoi(i)
+	return n, h
+}
+
+// newSuffix returns a random 10-character handle suffix.
+func newSuffix() string {
+	b := make([]byte, 10)
+	rand.Read(b)
+	for i := range b {
+		b[i] = suffixAlphabet[int(b[i])%len(suffixAlphabet)]
+	}
+	return string(b)
+}
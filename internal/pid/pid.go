@@ -0,0 +1,425 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pid mints persistent identifiers for published datasets.
+//
+// A Minter registers identifiers of one scheme: DataCite DOIs, ARKs
+// minted through EZID and resolved by N2T, or handles registered with
+// the institution's own handle server. A Registrar picks the
+// scheme for each dataset from its collection, so collections that do
+// not warrant a DOI, such as working data or digitized archives, can
+// be given ARKs instead.
+package pid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+)
+
+// Scheme is a kind of persistent identifier.
+type Scheme string
+
+// Identifier schemes.
+const (
+	SchemeDOI    Scheme = "doi"
+	SchemeARK    Scheme = "ark"
+	SchemeHandle Scheme = "handle"
+)
+
+// Schemes lists the supported schemes.
+var Schemes = []Scheme{SchemeDOI, SchemeARK, SchemeHandle}
+
+// ParseScheme returns the scheme named s.
+func ParseScheme(s string) (Scheme, error) {
+	for _, sc := range Schemes {
+		if strings.EqualFold(s, string(sc)) {
+			return sc, nil
+		}
+	}
+	return "", fmt.Errorf("unknown identifier scheme %q (want doi, ark, or handle)", s)
+}
+
+// ErrNotConfigured is returned when a dataset's scheme has no minter.
+var ErrNotConfigured = errors.New("identifier scheme not configured")
+
+// Record is the metadata registered with an identifier.
+type Record struct {
+	// Title is the dataset title
+	Title string
+
+	// TitleLanguage is the BCP 47 tag of the title's language, if known
+	TitleLanguage string
+
+	// Creators are the creators, in citation order
+	Creators []Creator
+
+	// Publisher is the institution publishing the dataset
+	Publisher string
+
+	// Year is the publication year
+	Year int
+
+	// URL is the landing page the identifier resolves to
+	URL string
+
+	// ResourceType is the general resource type, e.g. "Dataset"
+	ResourceType string
+
+	// Version is the version of a versioned resource
+	Version string
+
+	// Related lists related identifiers; minters that cannot register
+	// relations ignore it
+	Related []Related
+
+	// Funding lists the grants that funded the resource; minters that
+	// cannot register funding ignore it
+	Funding []Funding
+}
+
+// Funding is a grant that funded a resource.
+type Funding struct {
+	// FunderName is the funder's name
+	FunderName string
+
+	// FunderROR is the funder's ROR ID
+	FunderROR string
+
+	// AwardNumber is the funder's award number
+	AwardNumber string
+
+	// AwardTitle is the title of the funded project
+	AwardTitle string
+
+	// AwardURI is the award's grant DOI URL, if it has one
+	AwardURI string
+
+	// FundingStream is the funder's funding programme, if known
+	FundingStream string
+}
+
+// FundingSource returns the grants that funded a dataset.
+// *award.Registry implements it.
+type FundingSource interface {
+	Funding(ctx context.Context, d *dataset.Dataset) ([]Funding, error)
+}
+
+// Related is a relation to another identifier.
+type Related struct {
+	// Relation is the DataCite relation type, e.g. "IsVersionOf"
+	Relation string
+
+	// ID is the related identifier
+	ID string
+
+	// Type is the related identifier type, e.g. "DOI" or "URL"
+	Type string
+}
+
+// Creator is a creator of a registered dataset.
+type Creator struct {
+	// Name is the creator's name, family name first
+	Name string
+
+	// ORCID is the creator's ORCID iD
+	ORCID string
+
+	// Affiliation is the name of the creator's institution
+	Affiliation string
+
+	// AffiliationROR is the institution's ROR ID
+	AffiliationROR string
+}
+
+// Minter registers identifiers of one scheme.
+type Minter interface {
+	// Scheme returns the scheme of the identifiers minted
+	Scheme() Scheme
+
+	// Mint registers a new identifier for r and returns it
+	Mint(ctx context.Context, r Record) (string, error)
+
+	// Update replaces the metadata registered with id
+	Update(ctx context.Context, id string, r Record) error
+}
+
+// Registrar assigns identifiers to datasets.
+type Registrar struct {
+	// Default is the scheme of datasets outside Collections
+	Default Scheme
+
+	// Collections maps collection names to their scheme
+	Collections map[string]Scheme
+
+	// Minters holds the minter of each configured scheme
+	Minters map[Scheme]Minter
+
+	// SiteURL is the public base URL of landing pages
+	SiteURL string
+
+	// Publisher is registered as the publisher of every dataset
+	Publisher string
+
+	// Funding supplies datasets' funding references; skipped if nil
+	Funding FundingSource
+
+	// DownloadURL is the base URL of the counted download links
+	// registered as DOI media; no media is registered if empty
+	DownloadURL string
+
+	// Concepts says whether concept DOIs resolve to the latest version
+	// or to the version listing; the latest version if nil
+	Concepts landing.ConceptSource
+}
+
+// Scheme returns the scheme of datasets in collection.
+func (r *Registrar) Scheme(collection string) Scheme {
+	if s, ok := r.Collections[collection]; ok {
+		return s
+	}
+	if r.Default == "" {
+		return SchemeDOI
+	}
+	return r.Default
+}
+
+// Identifier returns d's identifier, or "" if it has none.
+func Identifier(d *dataset.Dataset) string {
+	switch {
+	case d.DOI != "":
+		return "doi:" + d.DOI
+	case d.ARK != "":
+		return d.ARK
+	case d.Handle != "":
+		return "hdl:" + d.Handle
+	}
+	return ""
+}
+
+// Assign mints an identifier for d in its collection's scheme, records
+// it on d, and returns it. d is not saved.
+func (r *Registrar) Assign(ctx context.Context, d *dataset.Dataset) (string, error) {
+	if id := Identifier(d); id != "" {
+		return "", fmt.Errorf("dataset %s already has identifier %s", d.ID, id)
+	}
+	m, err := r.minter(d)
+	if err != nil {
+		return "", err
+	}
+	rec, err := r.Record(ctx, d)
+	if err != nil {
+		return "", err
+	}
+	id, err := m.Mint(ctx, rec)
+	if err != nil {
+		return "", fmt.Errorf("failed to mint %s for %s: %w", m.Scheme(), d.ID, err)
+	}
+	switch m.Scheme() {
+	case SchemeDOI:
+		d.DOI = dataset.NormalizeDOI(id)
+	case SchemeHandle:
+		d.Handle = dataset.NormalizeHandle(id)
+	default:
+		d.ARK = dataset.NormalizeARK(id)
+	}
+	return Identifier(d), nil
+}
+
+// AssignVersion mints a DOI for version v of d, recorded on v and
+// returned. v is a version of d's concept DOI, which resolves to d's
+// current version; d is not saved. The DOI of the version before v is
+// not updated to point at the new DOI until d is saved and Update is
+// called.
+func (r *Registrar) AssignVersion(ctx context.Context, d *dataset.Dataset, v *dataset.Version) (string, error) {
+	if v.DOI != "" {
+		return "", fmt.Errorf("version %d of %s already has DOI %s", v.Number, d.ID, v.DOI)
+	}
+	if d.DOI == "" {
+		return "", fmt.Errorf("dataset %s has no concept DOI to version", d.ID)
+	}
+	m, ok := r.Minters[SchemeDOI]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotConfigured, SchemeDOI)
+	}
+	rec, err := r.VersionRecord(ctx, d, v)
+	if err != nil {
+		return "", err
+	}
+	id, err := m.Mint(ctx, rec)
+	if err != nil {
+		return "", fmt.Errorf("failed to mint DOI for version %d of %s: %w", v.Number, d.ID, err)
+	}
+	v.DOI = dataset.NormalizeDOI(id)
+	return v.DOI, nil
+}
+
+// Update re-registers the metadata of d's identifier and of its
+// versions' DOIs.
+func (r *Registrar) Update(ctx context.Context, d *dataset.Dataset) error {
+	var scheme Scheme
+	var id string
+	switch {
+	case d.DOI != "":
+		scheme, id = SchemeDOI, d.DOI
+	case d.ARK != "":
+		scheme, id = SchemeARK, d.ARK
+	case d.Handle != "":
+		scheme, id = SchemeHandle, d.Handle
+	default:
+		return fmt.Errorf("dataset %s has no identifier", d.ID)
+	}
+	m, ok := r.Minters[scheme]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotConfigured, scheme)
+	}
+	rec, err := r.Record(ctx, d)
+	if err != nil {
+		return err
+	}
+	if err := m.Update(ctx, id, rec); err != nil {
+		return fmt.Errorf("failed to update %s: %w", id, err)
+	}
+	for i := range d.Versions {
+		v := &d.Versions[i]
+		if v.DOI == "" || scheme != SchemeDOI {
+			continue
+		}
+		rec, err := r.VersionRecord(ctx, d, v)
+		if err != nil {
+			return err
+		}
+		if err := m.Update(ctx, v.DOI, rec); err != nil {
+			return fmt.Errorf("failed to update %s: %w", v.DOI, err)
+		}
+	}
+	return nil
+}
+
+// minter returns the minter for d's collection.
+func (r *Registrar) minter(d *dataset.Dataset) (Minter, error) {
+	scheme := r.Scheme(d.Collection)
+	m, ok := r.Minters[scheme]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s (collection %q)", ErrNotConfigured, scheme, d.Collection)
+	}
+	return m, nil
+}
+
+// Record returns the metadata registered with d's identifier, which
+// has each of d's version DOIs as a version. It resolves to the listing
+// of d's versions if d's collection chooses so.
+func (r *Registrar) Record(ctx context.Context, d *dataset.Dataset) (Record, error) {
+	rec, err := r.record(ctx, d)
+	if err != nil {
+		return Record{}, err
+	}
+	if r.Concepts != nil && len(d.Versions) > 0 {
+		target, err := r.Concepts.ConceptTarget(ctx, d.Collection)
+		if err != nil {
+			return Record{}, err
+		}
+		if target == collection.ConceptVersions {
+			rec.URL = strings.TrimRight(r.SiteURL, "/") + landing.VersionsPath(d.ID)
+		}
+	}
+	for _, v := range d.Versions {
+		if v.DOI != "" {
+			rec.Related = append(rec.Related, Related{Relation: "HasVersion", ID: v.DOI, Type: "DOI"})
+		}
+	}
+	return rec, nil
+}
+
+// VersionRecord returns the metadata registered with the DOI of version
+// v of d: a version of d's concept DOI following the version before it
+// and, once it has a DOI, followed by the version after it. The DOI of
+// a version whose files were deleted resolves to its tombstone, the
+// version's entry on d's landing page.
+func (r *Registrar) VersionRecord(ctx context.Context, d *dataset.Dataset, v *dataset.Version) (Record, error) {
+	rec, err := r.record(ctx, d)
+	if err != nil {
+		return Record{}, err
+	}
+	rec.Version = v.Tag
+	if rec.Version == "" {
+		rec.Version = fmt.Sprint(v.Number)
+	}
+	if v.PublishedAt != nil {
+		rec.Year = v.PublishedAt.Year()
+	}
+	if v.Removed() {
+		rec.URL += fmt.Sprintf("#v%d", v.Number)
+	}
+	rec.Related = append(rec.Related, Related{Relation: "IsVersionOf", ID: d.DOI, Type: "DOI"})
+	if prev := d.Version(v.Number - 1); prev != nil && prev.DOI != "" {
+		rec.Related = append(rec.Related, Related{Relation: "IsNewVersionOf", ID: prev.DOI, Type: "DOI"})
+	}
+	if next := d.Version(v.Number + 1); next != nil && next.DOI != "" {
+		rec.Related = append(rec.Related, Related{Relation: "IsPreviousVersionOf", ID: next.DOI, Type: "DOI"})
+	}
+	return rec, nil
+}
+
+// record returns the metadata shared by d's identifier and its version
+// DOIs.
+func (r *Registrar) record(ctx context.Context, d *dataset.Dataset) (Record, error) {
+	if r.SiteURL == "" {
+		return Record{}, fmt.Errorf("site URL not configured; set APERTURE_SITE_URL")
+	}
+	rec := Record{
+		Title:         d.Title,
+		TitleLanguage: d.TitleLanguage,
+		Publisher:     r.Publisher,
+		Year:          d.PublicationYear,
+		URL:           strings.TrimRight(r.SiteURL, "/") + landing.PagePath(d.ID),
+		ResourceType:  d.ResourceType,
+	}
+	if rec.ResourceType == "" {
+		rec.ResourceType = "Dataset"
+	}
+	for _, c := range d.Creators {
+		rec.Creators = append(rec.Creators, Creator{Name: c.Name, ORCID: c.ORCID, Affiliation: c.Affiliation, AffiliationROR: c.AffiliationROR})
+	}
+	for _, h := range d.RAiDs {
+		rec.Related = append(rec.Related, Related{Relation: "IsPartOf", ID: h, Type: "Handle"})
+	}
+	for _, rel := range d.Related {
+		rec.Related = append(rec.Related, Related{Relation: rel.Relation, ID: rel.Identifier, Type: rel.Type})
+	}
+	if r.Funding != nil && len(d.Awards) > 0 {
+		funding, err := r.Funding.Funding(ctx, d)
+		if err != nil {
+			return Record{}, fmt.Errorf("failed to read funding of %s: %w", d.ID, err)
+		}
+		rec.Funding = funding
+	}
+	return rec, nil
+}
+
+// Configured returns the collections with their own scheme, sorted.
+func (r *Registrar) Configured() []string {
+	names := make([]string, 0, len(r.Collections))
+	for c := range r.Collections {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	return names
+}
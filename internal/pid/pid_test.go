@@ -0,0 +1,410 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+type fakeDataCite struct {
+	created []datacite.Attributes
+	updated map[string]datacite.Attributes
+	media   map[string][]datacite.Media
+}
+
+func (f *fakeDataCite) SetMedia(_ context.Context, doi string, media []datacite.Media) error {
+	if f.media == nil {
+		f.media = make(map[string][]datacite.Media)
+	}
+	f.media[doi] = media
+	return nil
+}
+
+func (f *fakeDataCite) CreateDOI(_ context.Context, attrs datacite.Attributes) (*datacite.DOI, error) {
+	f.created = append(f.created, attrs)
+	doi := fmt.Sprintf("%s/ABC-%d", attrs.Prefix, 122+len(f.created))
+	return &datacite.DOI{ID: strings.ToLower(doi), Attributes: datacite.Attributes{DOI: doi}}, nil
+}
+
+func (f *fakeDataCite) UpdateDOI(_ context.Context, doi string, attrs datacite.Attributes) (*datacite.DOI, error) {
+	if f.updated == nil {
+		f.updated = make(map[string]datacite.Attributes)
+	}
+	f.updated[doi] = attrs
+	return &datacite.DOI{ID: doi}, nil
+}
+
+// fakeEZID serves the EZID API, recording request bodies by path.
+func fakeEZID(t *testing.T, requests map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "apitest" || p != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, "error: unauthorized")
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		requests[r.URL.Path] = string(body)
+		switch {
+		case r.URL.Path == "/shoulder/ark:/99999/fk4":
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, "success: ark:/99999/fk4xyz\n")
+		case strings.HasPrefix(r.URL.Path, "/id/ark:/99999/fk4"):
+			io.WriteString(w, "success: "+strings.TrimPrefix(r.URL.Path, "/id/")+"\n")
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, "error: bad request - no such shoulder\n")
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAssign(t *testing.T) {
+	ctx := context.Background()
+	requests := make(map[string]string)
+	srv := fakeEZID(t, requests)
+	dc := &fakeDataCite{}
+	r := &Registrar{
+		Default:     SchemeDOI,
+		Collections: map[string]Scheme{"archives": SchemeARK},
+		Minters: map[Scheme]Minter{
+			SchemeDOI: &DataCite{Client: dc, Prefix: "10.1234"},
+			SchemeARK: NewEZID(EZIDOptions{BaseURL: srv.URL, Username: "apitest", Password: "secret", Shoulder: "ark:/99999/fk4"}),
+		},
+		SiteURL:   "https://data.example.edu/",
+		Publisher: "Example University",
+	}
+
+	d := &dataset.Dataset{ID: "ds-1", Title: "Soil cores", PublicationYear: 2025, Creators: []dataset.Creator{{Name: "Lovelace, Ada", ORCID: "0000-0002-1825-0097", Affiliation: "University of Oxford", AffiliationROR: "https://ror.org/052gg0110"}}, RAiDs: []string{"10.80368/b1adfb3a"}}
+	id, err := r.Assign(ctx, d)
+	if err != nil {
+		t.Fatalf("Assign() error = %v", err)
+	}
+	if id != "doi:10.1234/abc-123" || d.DOI != "10.1234/abc-123" || d.ARK != "" {
+		t.Errorf("Assign() = %q (DOI %q, ARK %q), want a DataCite DOI", id, d.DOI, d.ARK)
+	}
+	if got := dc.created[0]; got.Event != "publish" || got.URL != "https://data.example.edu/datasets/ds-1/" || got.Publisher != "Example University" {
+		t.Errorf("CreateDOI() attributes = %+v", got)
+	}
+	if a := dc.created[0].Creators[0].Affiliation; len(a) != 1 || a[0].AffiliationIdentifier != "https://ror.org/052gg0110" || a[0].AffiliationIdentifierScheme != "ROR" {
+		t.Errorf("CreateDOI() creator affiliation = %+v", a)
+	}
+	if n := dc.created[0].Creators[0].NameIdentifiers; len(n) != 1 || n[0].NameIdentifier != "https://orcid.org/0000-0002-1825-0097" || n[0].NameIdentifierScheme != "ORCID" {
+		t.Errorf("CreateDOI() creator name identifiers = %+v", n)
+	}
+	if rel := dc.created[0].RelatedIdentifiers; len(rel) != 1 || rel[0].RelatedIdentifier != "10.80368/b1adfb3a" || rel[0].RelationType != "IsPartOf" {
+		t.Errorf("CreateDOI() related identifiers = %+v", rel)
+	}
+
+	a := &dataset.Dataset{ID: "ds-2", Title: "Letters\n1890%", Collection: "archives", PublicationYear: 2025}
+	id, err = r.Assign(ctx, a)
+	if err != nil {
+		t.Fatalf("Assign() error = %v", err)
+	}
+	if id != "ark:/99999/fk4xyz" || a.ARK != id || a.DOI != "" {
+		t.Errorf("Assign() = %q (DOI %q, ARK %q), want an ARK", id, a.DOI, a.ARK)
+	}
+	body := requests["/shoulder/ark:/99999/fk4"]
+	for _, want := range []string{"_target: https://data.example.edu/datasets/ds-2/\n", "erc.what: Letters%0A1890%25\n", "erc.who: Example University\n", "erc.when: 2025\n", "_profile: erc\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("EZID request missing %q:\n%s", want, body)
+		}
+	}
+
+	if _, err := r.Assign(ctx, a); err == nil {
+		t.Error("Assign() of an identified dataset succeeded, want error")
+	}
+
+	a.Title = "Letters, 1890"
+	if err := r.Update(ctx, a); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if !strings.Contains(requests["/id/ark:/99999/fk4xyz"], "erc.what: Letters, 1890\n") {
+		t.Errorf("Update() request = %q", requests["/id/ark:/99999/fk4xyz"])
+	}
+}
+
+func TestAssignErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := fakeEZID(t, make(map[string]string))
+	tests := []struct {
+		name string
+		r    *Registrar
+	}{
+		{"scheme not configured", &Registrar{Default: SchemeARK, Minters: map[Scheme]Minter{SchemeDOI: &DataCite{Client: &fakeDataCite{}}}, SiteURL: "https://x"}},
+		{"no site URL", &Registrar{Minters: map[Scheme]Minter{SchemeDOI: &DataCite{Client: &fakeDataCite{}}}}},
+		{"bad shoulder", &Registrar{Default: SchemeARK, Minters: map[Scheme]Minter{SchemeARK: NewEZID(EZIDOptions{BaseURL: srv.URL, Username: "apitest", Password: "secret", Shoulder: "ark:/1/x"})}, SiteURL: "https://x"}},
+		{"bad credentials", &Registrar{Default: SchemeARK, Minters: map[Scheme]Minter{SchemeARK: NewEZID(EZIDOptions{BaseURL: srv.URL, Shoulder: "ark:/99999/fk4"})}, SiteURL: "https://x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &dataset.Dataset{ID: "ds-1", Title: "T"}
+			if _, err := tt.r.Assign(ctx, d); err == nil {
+				t.Errorf("Assign() error = nil, want error")
+			}
+			if d.DOI != "" || d.ARK != "" {
+				t.Errorf("Assign() left identifier DOI %q ARK %q after failing", d.DOI, d.ARK)
+			}
+		})
+	}
+
+	r := &Registrar{Default: SchemeARK, Minters: map[Scheme]Minter{}, SiteURL: "https://x"}
+	if _, err := r.Assign(ctx, &dataset.Dataset{ID: "ds-1"}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Assign() error = %v, want ErrNotConfigured", err)
+	}
+}
+
+func TestAssignVersion(t *testing.T) {
+	ctx := context.Background()
+	dc := &fakeDataCite{}
+	r := &Registrar{Minters: map[Scheme]Minter{SchemeDOI: &DataCite{Client: dc, Prefix: "10.1234"}}, SiteURL: "https://data.example.edu"}
+	published := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	d := &dataset.Dataset{ID: "ds-1", Title: "Soil cores", PublicationYear: 2025, Versions: []dataset.Version{{Number: 1}, {Number: 2, PublishedAt: &published}}}
+	if _, err := r.AssignVersion(ctx, d, &d.Versions[0]); err == nil {
+		t.Error("AssignVersion() without a concept DOI succeeded, want error")
+	}
+	if _, err := r.Assign(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	for i := range d.Versions {
+		if _, err := r.AssignVersion(ctx, d, &d.Versions[i]); err != nil {
+			t.Fatalf("AssignVersion(%d) error = %v", i+1, err)
+		}
+	}
+	if d.DOI != "10.1234/abc-123" || d.Versions[0].DOI != "10.1234/abc-124" || d.Versions[1].DOI != "10.1234/abc-125" {
+		t.Fatalf("DOIs = %s, %s, %s", d.DOI, d.Versions[0].DOI, d.Versions[1].DOI)
+	}
+	v2 := dc.created[2]
+	if v2.Version != "2" || v2.PublicationYear != 2026 || len(v2.RelatedIdentifiers) != 2 ||
+		v2.RelatedIdentifiers[0].RelationType != "IsVersionOf" || v2.RelatedIdentifiers[0].RelatedIdentifier != d.DOI ||
+		v2.RelatedIdentifiers[1].RelationType != "IsNewVersionOf" || v2.RelatedIdentifiers[1].RelatedIdentifier != d.Versions[0].DOI {
+		t.Errorf("CreateDOI() of version 2 attributes = %+v", v2)
+	}
+	if _, err := r.AssignVersion(ctx, d, &d.Versions[1]); err == nil {
+		t.Error("AssignVersion() of a version with a DOI succeeded, want error")
+	}
+
+	if err := r.Update(ctx, d); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if rel := dc.updated[d.DOI].RelatedIdentifiers; len(rel) != 2 || rel[0].RelationType != "HasVersion" || rel[1].RelatedIdentifier != d.Versions[1].DOI {
+		t.Errorf("UpdateDOI() of concept related identifiers = %+v", rel)
+	}
+	if rel := dc.updated[d.Versions[0].DOI].RelatedIdentifiers; len(rel) != 2 || rel[1].RelationType != "IsPreviousVersionOf" || rel[1].RelatedIdentifier != d.Versions[1].DOI {
+		t.Errorf("UpdateDOI() of version 1 related identifiers = %+v", rel)
+	}
+
+	// The DOI of a version whose files were deleted resolves to its
+	// tombstone.
+	d.Versions[0].Pruned = &dataset.Pruning{Action: dataset.PruneDelete, Time: published}
+	if err := r.Update(ctx, d); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got := dc.updated[d.Versions[0].DOI].URL; got != "https://data.example.edu/datasets/ds-1/#v1" {
+		t.Errorf("URL of a deleted version = %q", got)
+	}
+	if got := dc.updated[d.Versions[1].DOI].URL; got != "https://data.example.edu/datasets/ds-1/" {
+		t.Errorf("URL of version 2 = %q", got)
+	}
+
+	// A collection may have its concept DOIs resolve to the version
+	// listing; version DOIs still resolve to the landing page.
+	r.Concepts = fakeConcepts{"soil": collection.ConceptVersions}
+	d.Collection = "soil"
+	if err := r.Update(ctx, d); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got := dc.updated[d.DOI].URL; got != "https://data.example.edu/datasets/ds-1/versions/" {
+		t.Errorf("URL of the concept DOI = %q", got)
+	}
+	if got := dc.updated[d.Versions[1].DOI].URL; got != "https://data.example.edu/datasets/ds-1/" {
+		t.Errorf("URL of version 2 = %q", got)
+	}
+}
+
+type fakeConcepts map[string]string
+
+func (f fakeConcepts) ConceptTarget(_ context.Context, id string) (string, error) {
+	if target, ok := f[id]; ok {
+		return target, nil
+	}
+	return collection.ConceptLatest, nil
+}
+
+func TestRegisterMedia(t *testing.T) {
+	ctx := context.Background()
+	dc := &fakeDataCite{}
+	r := &Registrar{
+		Minters:     map[Scheme]Minter{SchemeDOI: &DataCite{Client: dc, Media: dc, Prefix: "10.1234"}},
+		SiteURL:     "https://data.example.edu/",
+		DownloadURL: "https://dl.example.edu",
+	}
+	d := &dataset.Dataset{
+		ID: "ds-1", DOI: "10.1234/abc", Access: storage.AccessPublic,
+		Versions: []dataset.Version{{Number: 2, Files: []dataset.File{
+			{Path: "cores.csv", ContentType: "text/csv; charset=utf-8"},
+			{Path: "more.csv", ContentType: "text/csv"},
+			{Path: "grid.nc", ContentType: "application/x-netcdf"},
+			{Path: "README"},
+		}}},
+	}
+	media, err := r.RegisterMedia(ctx, d)
+	if err != nil {
+		t.Fatalf("RegisterMedia() error = %v", err)
+	}
+	want := []datacite.Media{
+		{MediaType: "application/x-netcdf", URL: "https://dl.example.edu/d/ds-1/v2/grid.nc"},
+		{MediaType: "text/csv", URL: "https://dl.example.edu/d/ds-1/v2/cores.csv"},
+	}
+	if !reflect.DeepEqual(media, want) || !reflect.DeepEqual(dc.media["10.1234/abc"], want) {
+		t.Errorf("RegisterMedia() = %+v, registered %+v, want %+v", media, dc.media, want)
+	}
+
+	// Files that are not publicly downloadable are not registered.
+	d.DOI, d.Embargo = "10.1234/later", &dataset.Embargo{Until: time.Now().Add(time.Hour)}
+	if media, err := r.RegisterMedia(ctx, d); err != nil || media != nil || dc.media["10.1234/later"] != nil {
+		t.Errorf("RegisterMedia() of an embargoed dataset = %+v, %v", media, err)
+	}
+}
+
+func TestParseScheme(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Scheme
+		wantErr bool
+	}{
+		{"doi", SchemeDOI, false},
+		{"ARK", SchemeARK, false},
+		{"handle", SchemeHandle, false},
+		{"urn", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseScheme(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseScheme(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestResolveARK(t *testing.T) {
+	ctx := context.Background()
+	st := dataset.NewStore(state.NewMemoryStore())
+	if err := st.Put(ctx, &dataset.Dataset{ID: "ds-1", Title: "T", ARK: "ark:/99999/fk4xyz"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []string{"ds-1", "ark:/99999/fk4xyz", "ark:99999/fk4xyz", "https://n2t.net/ark:/99999/fk4xyz"} {
+		d, err := st.Resolve(ctx, ref)
+		if err != nil || d.ID != "ds-1" {
+			t.Errorf("Resolve(%q) = %v, %v, want ds-1", ref, d, err)
+		}
+	}
+	if _, err := st.Resolve(ctx, "ark:/99999/FK4XYZ"); !errors.Is(err, dataset.ErrNotFound) {
+		t.Errorf("Resolve() of a differently cased ARK error = %v, want ErrNotFound", err)
+	}
+}
+
+// fakeHandleServer serves the handle REST API, keeping handles in
+// memory. The first suffix minted collides with an existing handle.
+type fakeHandleServer struct {
+	handles map[string][]handleValue
+	queries []string
+	collide bool
+}
+
+func (f *fakeHandleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, pass, _ := r.BasicAuth()
+	if user != "300%3A0.NA%2F20.500.12345" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"responseCode":402,"message":"Authentication needed"}`)
+		return
+	}
+	h := strings.TrimPrefix(r.URL.Path, "/api/handles/")
+	f.queries = append(f.queries, r.URL.RawQuery)
+	var in struct{ Values []handleValue }
+	json.NewDecoder(r.Body).Decode(&in)
+	if _, exists := f.handles[h]; r.URL.Query().Get("overwrite") == "false" && (exists || f.collide) {
+		f.collide = false
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, `{"responseCode":101,"handle":"`+h+`"}`)
+		return
+	}
+	f.handles[h] = in.Values
+	io.WriteString(w, `{"responseCode":1,"handle":"`+h+`"}`)
+}
+
+func TestHandle(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeHandleServer{handles: make(map[string][]handleValue), collide: true}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	r := &Registrar{
+		Default: SchemeHandle,
+		Minters: map[Scheme]Minter{SchemeHandle: NewHandle(HandleOptions{BaseURL: srv.URL, Prefix: "20.500.12345", Password: "secret"})},
+		SiteURL: "https://data.example.edu",
+	}
+
+	d := &dataset.Dataset{ID: "ds-1", Title: "Field notes"}
+	id, err := r.Assign(ctx, d)
+	if err != nil {
+		t.Fatalf("Assign() error = %v", err)
+	}
+	if !strings.HasPrefix(id, "hdl:20.500.12345/") || d.Handle != strings.TrimPrefix(id, "hdl:") || len(fake.handles) != 1 {
+		t.Fatalf("Assign() = %q, handle %q, %d handles", id, d.Handle, len(fake.handles))
+	}
+	vals := fake.handles[d.Handle]
+	if len(vals) != 3 || vals[0].Type != "URL" || vals[0].Data.Value != "https://data.example.edu/datasets/ds-1/" || vals[2].Type != "HS_ADMIN" {
+		t.Errorf("handle values = %+v", vals)
+	}
+
+	d.Title = "Field notes, 1923"
+	if err := r.Update(ctx, d); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if q := fake.queries[len(fake.queries)-1]; q != "overwrite=true&index=1&index=2" {
+		t.Errorf("Update() query = %q", q)
+	}
+
+	st := dataset.NewStore(state.NewMemoryStore())
+	if err := st.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []string{d.Handle, "hdl:" + strings.ToUpper(d.Handle), "https://hdl.handle.net/" + d.Handle} {
+		if got, err := st.Resolve(ctx, ref); err != nil || got.ID != "ds-1" {
+			t.Errorf("Resolve(%q) = %v, %v", ref, got, err)
+		}
+	}
+
+	bad := NewHandle(HandleOptions{BaseURL: srv.URL, Prefix: "20.500.12345", Password: "wrong"})
+	if _, err := bad.Mint(ctx, Record{URL: "https://x"}); err == nil || !strings.Contains(err.Error(), "Authentication needed") {
+		t.Errorf("Mint() with a bad key error = %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pidgraph exports the relationships between the persistent
+// identifiers of published datasets and the people, organizations, and
+// projects behind them.
+//
+// The graph is built from the catalog alone: datasets and their version
+// DOIs, creators' ORCID iDs, affiliations' ROR IDs, and linked RAiDs.
+// It is published as JSON nodes and edges following the DataCite PID
+// graph, and as Scholix links, the link exchange format harvested by
+// OpenAIRE's ScholeXplorer.
+package pidgraph
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/raid"
+	"github.com/scttfrdmn/aperture/internal/ror"
+)
+
+// orcidResolver is the resolver ORCID iDs are cited through.
+const orcidResolver = "https://orcid.org/"
+
+// Identifier schemes of graph nodes.
+const (
+	SchemeDOI    = "doi"
+	SchemeARK    = "ark"
+	SchemeHandle = "handle"
+	SchemeORCID  = "orcid"
+	SchemeROR    = "ror"
+	SchemeRAiD   = "raid"
+)
+
+// Node types.
+const (
+	TypePerson       = "Person"
+	TypeOrganization = "Organization"
+	TypeProject      = "Project"
+)
+
+// Edge relations. Relations between works use the DataCite relation
+// types; the others name the role of the person or organization.
+const (
+	RelHasVersion  = "HasVersion"
+	RelIsPartOf    = "IsPartOf"
+	RelCreator     = "creator"
+	RelAffiliation = "affiliation"
+)
+
+// Node is an entity identified by a persistent identifier.
+type Node struct {
+	// ID is the identifier's resolver URL, which identifies the node
+	// in edges
+	ID string `json:"id"`
+
+	// PID is the identifier as registered, e.g. "10.1234/abc"
+	PID string `json:"pid"`
+
+	// Scheme is the identifier scheme
+	Scheme string `json:"scheme"`
+
+	// Type is the DataCite resource type of works, or TypePerson,
+	// TypeOrganization, or TypeProject
+	Type string `json:"type"`
+
+	// Name is the title or name, if known
+	Name string `json:"name,omitempty"`
+}
+
+// Edge is a relation from the node Source to the node Target.
+type Edge struct {
+	Source   string `json:"source"`
+	Relation string `json:"relation"`
+	Target   string `json:"target"`
+
+	// Date is when the relation was last asserted
+	Date time.Time `json:"date"`
+}
+
+// Graph is the identifier graph of a catalog.
+type Graph struct {
+	Generated time.Time `json:"generated"`
+	Publisher string    `json:"publisher,omitempty"`
+	Nodes     []Node    `json:"nodes"`
+	Edges     []Edge    `json:"edges"`
+}
+
+// Build returns the graph of the published datasets among datasets.
+// Creators with an ORCID iD are linked to the dataset and to their
+// affiliation; the affiliations of creators without one are linked to
+// the dataset directly.
+func Build(datasets []*dataset.Dataset, publisher string, now time.Time) *Graph {
+	b := &builder{nodes: make(map[string]Node), edges: make(map[[3]string]Edge)}
+	for _, d := range datasets {
+		if d.State != dataset.StatePublished {
+			continue
+		}
+		work, ok := workNode(d)
+		if !ok {
+			continue
+		}
+		b.node(work)
+		date := d.UpdatedAt.UTC()
+		for _, v := range d.Versions {
+			if v.DOI == "" || dataset.NormalizeDOI(v.DOI) == dataset.NormalizeDOI(d.DOI) {
+				continue
+			}
+			name := d.Title
+			if v.Tag != "" {
+				name += " " + v.Tag
+			}
+			ver := doiNode(v.DOI, work.Type, name)
+			b.node(ver)
+			b.edge(work.ID, RelHasVersion, ver.ID, date)
+		}
+		for _, c := range d.Creators {
+			var org *Node
+			if c.AffiliationROR != "" {
+				id := ror.Normalize(c.AffiliationROR)
+				n := Node{ID: id, PID: strings.TrimPrefix(id, ror.Resolver), Scheme: SchemeROR, Type: TypeOrganization, Name: c.Affiliation}
+				b.node(n)
+				org = &n
+			}
+			if c.ORCID == "" {
+				if org != nil {
+					b.edge(work.ID, RelAffiliation, org.ID, date)
+				}
+				continue
+			}
+			orcid := strings.TrimPrefix(c.ORCID, orcidResolver)
+			person := Node{ID: orcidResolver + orcid, PID: orcid, Scheme: SchemeORCID, Type: TypePerson, Name: c.Name}
+			b.node(person)
+			b.edge(work.ID, RelCreator, person.ID, date)
+			if org != nil {
+				b.edge(person.ID, RelAffiliation, org.ID, date)
+			}
+		}
+		for _, h := range d.RAiDs {
+			project := Node{ID: raid.Resolver + h, PID: h, Scheme: SchemeRAiD, Type: TypeProject}
+			b.node(project)
+			b.edge(work.ID, RelIsPartOf, project.ID, date)
+		}
+	}
+	g := &Graph{Generated: now.UTC(), Publisher: publisher, Nodes: []Node{}, Edges: []Edge{}}
+	for _, n := range b.nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	for _, e := range b.edges {
+		g.Edges = append(g.Edges, e)
+	}
+	slices.SortFunc(g.Nodes, func(a, b Node) int { return cmp.Compare(a.ID, b.ID) })
+	slices.SortFunc(g.Edges, func(a, b Edge) int {
+		return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Relation, b.Relation), cmp.Compare(a.Target, b.Target))
+	})
+	return g
+}
+
+// Node returns the node with the given ID, or nil.
+func (g *Graph) Node(id string) *Node {
+	i, ok := slices.BinarySearchFunc(g.Nodes, id, func(n Node, id string) int { return cmp.Compare(n.ID, id) })
+	if !ok {
+		return nil
+	}
+	return &g.Nodes[i]
+}
+
+// builder collects deduplicated nodes and edges.
+type builder struct {
+	nodes map[string]Node
+	edges map[[3]string]Edge
+}
+
+// node adds n, filling in a name missing from an earlier mention.
+func (b *builder) node(n Node) {
+	if prev, ok := b.nodes[n.ID]; ok && (n.Name == "" || prev.Name != "") {
+		return
+	}
+	b.nodes[n.ID] = n
+}
+
+// edge adds a relation, keeping its latest date.
+func (b *builder) edge(source, relation, target string, date time.Time) {
+	k := [3]string{source, relation, target}
+	if prev, ok := b.edges[k]; ok && !date.After(prev.Date) {
+		return
+	}
+	b.edges[k] = Edge{Source: source, Relation: relation, Target: target, Date: date}
+}
+
+// workNode returns the node of d's own identifier.
+func workNode(d *dataset.Dataset) (Node, bool) {
+	typ := d.ResourceType
+	if typ == "" {
+		typ = "Dataset"
+	}
+	switch {
+	case d.DOI != "":
+		return doiNode(d.DOI, typ, d.Title), true
+	case d.ARK != "":
+		return Node{ID: pid.Resolver + d.ARK, PID: d.ARK, Scheme: SchemeARK, Type: typ, Name: d.Title}, true
+	case d.Handle != "":
+		return Node{ID: pid.HandleResolver + d.Handle, PID: d.Handle, Scheme: SchemeHandle, Type: typ, Name: d.Title}, true
+	}
+	return Node{}, false
+}
+
+func doiNode(doi, typ, name string) Node {
+	doi = dataset.NormalizeDOI(doi)
+	return Node{ID: "https://doi.org/" + doi, PID: doi, Scheme: SchemeDOI, Type: typ, Name: name}
+}
+
+// Keys of the published feeds in the site bucket.
+const (
+	GraphKey   = "pid-graph/graph.json"
+	ScholixKey = "pid-graph/scholix.json"
+)
+
+// ObjectStore stores published feeds. *s3.Client implements it.
+type ObjectStore interface {
+	PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error
+}
+
+// Publish writes the graph and its Scholix links to bucket, returning
+// the URL paths written.
+func (g *Graph) Publish(ctx context.Context, objects ObjectStore, bucket string) ([]string, error) {
+	feeds := []struct {
+		key string
+		v   any
+	}{{GraphKey, g}, {ScholixKey, g.Scholix()}}
+	var paths []string
+	for _, f := range feeds {
+		data, err := json.MarshalIndent(f.v, "", "  ")
+		if err != nil {
+			return paths, fmt.Errorf("failed to encode %s: %w", f.key, err)
+		}
+		if err := objects.PutObject(ctx, bucket, f.key, data, "application/json"); err != nil {
+			return paths, fmt.Errorf("failed to publish %s: %w", f.key, err)
+		}
+		paths = append(paths, "/"+f.key)
+	}
+	return paths, nil
+}
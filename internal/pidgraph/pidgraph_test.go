@@ -0,0 +1,134 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pidgraph
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+func catalog() []*dataset.Dataset {
+	updated := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	return []*dataset.Dataset{
+		{
+			ID: "ds-1", DOI: "10.1234/abc", Title: "Soil cores", State: dataset.StatePublished, UpdatedAt: updated,
+			Creators: []dataset.Creator{
+				{Name: "Lovelace, Ada", ORCID: "0000-0002-1825-0097", Affiliation: "University of Oxford", AffiliationROR: "052gg0110"},
+				{Name: "Babbage, Charles", Affiliation: "University of Cambridge", AffiliationROR: "https://ror.org/013meh722"},
+			},
+			RAiDs:    []string{"10.80368/b1adfb3a"},
+			Versions: []dataset.Version{{Number: 1, DOI: "10.1234/abc.v1"}, {Number: 2}},
+		},
+		{
+			ID: "ds-2", ARK: "ark:/99999/fk4xyz", Title: "Letters", State: dataset.StatePublished, UpdatedAt: updated,
+			Creators: []dataset.Creator{{Name: "Lovelace, Ada", ORCID: "https://orcid.org/0000-0002-1825-0097"}},
+			RAiDs:    []string{"10.80368/b1adfb3a"},
+		},
+		{ID: "ds-3", DOI: "10.1234/draft", Title: "Draft", State: dataset.StateDraft},
+		{ID: "ds-4", Title: "Unidentified", State: dataset.StatePublished},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	g := Build(catalog(), "Example University", time.Now())
+
+	nodes := map[string]string{
+		"https://doi.org/10.1234/abc":           "Dataset",
+		"https://doi.org/10.1234/abc.v1":        "Dataset",
+		"https://n2t.net/ark:/99999/fk4xyz":     "Dataset",
+		"https://orcid.org/0000-0002-1825-0097": TypePerson,
+		"https://ror.org/052gg0110":             TypeOrganization,
+		"https://ror.org/013meh722":             TypeOrganization,
+		"https://raid.org/10.80368/b1adfb3a":    TypeProject,
+	}
+	if len(g.Nodes) != len(nodes) {
+		t.Errorf("Build() nodes = %+v, want %d", g.Nodes, len(nodes))
+	}
+	for id, typ := range nodes {
+		if n := g.Node(id); n == nil || n.Type != typ {
+			t.Errorf("Node(%q) = %+v, want type %s", id, n, typ)
+		}
+	}
+
+	want := []Edge{
+		{Source: "https://doi.org/10.1234/abc", Relation: RelHasVersion, Target: "https://doi.org/10.1234/abc.v1"},
+		{Source: "https://doi.org/10.1234/abc", Relation: RelIsPartOf, Target: "https://raid.org/10.80368/b1adfb3a"},
+		{Source: "https://doi.org/10.1234/abc", Relation: RelAffiliation, Target: "https://ror.org/013meh722"},
+		{Source: "https://doi.org/10.1234/abc", Relation: RelCreator, Target: "https://orcid.org/0000-0002-1825-0097"},
+		{Source: "https://n2t.net/ark:/99999/fk4xyz", Relation: RelIsPartOf, Target: "https://raid.org/10.80368/b1adfb3a"},
+		{Source: "https://n2t.net/ark:/99999/fk4xyz", Relation: RelCreator, Target: "https://orcid.org/0000-0002-1825-0097"},
+		{Source: "https://orcid.org/0000-0002-1825-0097", Relation: RelAffiliation, Target: "https://ror.org/052gg0110"},
+	}
+	if len(g.Edges) != len(want) {
+		t.Fatalf("Build() edges = %+v, want %d", g.Edges, len(want))
+	}
+	for i, e := range g.Edges {
+		w := want[i]
+		if e.Source != w.Source || e.Relation != w.Relation || e.Target != w.Target {
+			t.Errorf("edge %d = %s %s %s, want %s %s %s", i, e.Source, e.Relation, e.Target, w.Source, w.Relation, w.Target)
+		}
+	}
+}
+
+func TestScholix(t *testing.T) {
+	links := Build(catalog(), "Example University", time.Now()).Scholix()
+	if len(links) != 3 {
+		t.Fatalf("Scholix() = %d links, want 3", len(links))
+	}
+	l := links[0]
+	if l.RelationshipType.Name != "IsRelatedTo" || l.RelationshipType.SubType != RelHasVersion || l.LinkProvider[0].Name != "Example University" {
+		t.Errorf("Scholix()[0] = %+v", l)
+	}
+	if id := l.Source.Identifier[0]; id.ID != "10.1234/abc" || id.IDScheme != "doi" || l.Source.Type.Name != "dataset" {
+		t.Errorf("Scholix()[0].Source = %+v", l.Source)
+	}
+	if id := links[1].Target.Identifier[0]; id.IDScheme != "handle" || id.IDURL != "https://raid.org/10.80368/b1adfb3a" || links[1].Target.Type.Name != "other" {
+		t.Errorf("Scholix()[1].Target = %+v", links[1].Target)
+	}
+	if l.LinkPublicationDate != "2025-05-01T00:00:00Z" {
+		t.Errorf("LinkPublicationDate = %q", l.LinkPublicationDate)
+	}
+}
+
+type fakeObjects map[string][]byte
+
+func (f fakeObjects) PutObject(_ context.Context, bucket, key string, body []byte, _ string) error {
+	f[bucket+"/"+key] = body
+	return nil
+}
+
+func TestPublish(t *testing.T) {
+	g := Build(catalog(), "", time.Now())
+	objects := fakeObjects{}
+	paths, err := g.Publish(context.Background(), objects, "site")
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if len(paths) != 2 || paths[0] != "/"+GraphKey || paths[1] != "/"+ScholixKey {
+		t.Errorf("Publish() = %v", paths)
+	}
+	var got Graph
+	if err := json.Unmarshal(objects["site/"+GraphKey], &got); err != nil || len(got.Nodes) != len(g.Nodes) {
+		t.Errorf("published graph = %+v, %v", got, err)
+	}
+	var links []ScholixLink
+	if err := json.Unmarshal(objects["site/"+ScholixKey], &links); err != nil || len(links) != 3 || links[0].LinkProvider[0].Name != "Aperture" {
+		t.Errorf("published links = %+v, %v", links, err)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pidgraph
+
+import (
+	"strings"
+	"time"
+)
+
+// ScholixLink is a Scholix link between two research objects.
+type ScholixLink struct {
+	LinkPublicationDate string              `json:"LinkPublicationDate"`
+	LinkProvider        []ScholixProvider   `json:"LinkProvider"`
+	RelationshipType    ScholixRelationship `json:"RelationshipType"`
+	Source              ScholixObject       `json:"Source"`
+	Target              ScholixObject       `json:"Target"`
+}
+
+// ScholixProvider is the source of a link.
+type ScholixProvider struct {
+	Name string `json:"Name"`
+}
+
+// ScholixRelationship is a link's relationship, with the DataCite
+// relation type it was derived from as its subtype.
+type ScholixRelationship struct {
+	Name          string `json:"Name"`
+	SubType       string `json:"SubType,omitempty"`
+	SubTypeSchema string `json:"SubTypeSchema,omitempty"`
+}
+
+// ScholixObject is an end of a link.
+type ScholixObject struct {
+	Identifier []ScholixIdentifier `json:"Identifier"`
+	Type       ScholixType         `json:"Type"`
+	Title      string              `json:"Title,omitempty"`
+}
+
+// ScholixIdentifier identifies a research object.
+type ScholixIdentifier struct {
+	ID       string `json:"ID"`
+	IDScheme string `json:"IDScheme"`
+	IDURL    string `json:"IDURL"`
+}
+
+// ScholixType is the type of a research object.
+type ScholixType struct {
+	Name string `json:"Name"`
+}
+
+// Scholix returns the links between the objects of g. Scholix covers
+// relations between research objects only, so people and
+// organizations are left out.
+func (g *Graph) Scholix() []ScholixLink {
+	provider := g.Publisher
+	if provider == "" {
+		provider = "Aperture"
+	}
+	links := []ScholixLink{}
+	for _, e := range g.Edges {
+		if e.Relation != RelHasVersion && e.Relation != RelIsPartOf {
+			continue
+		}
+		src, tgt := g.Node(e.Source), g.Node(e.Target)
+		if src == nil || tgt == nil {
+			continue
+		}
+		links = append(links, ScholixLink{
+			LinkPublicationDate: e.Date.UTC().Format(time.RFC3339),
+			LinkProvider:        []ScholixProvider{{Name: provider}},
+			RelationshipType:    ScholixRelationship{Name: "IsRelatedTo", SubType: e.Relation, SubTypeSchema: "DataCite"},
+			Source:              scholixObject(src),
+			Target:              scholixObject(tgt),
+		})
+	}
+	return links
+}
+
+func scholixObject(n *Node) ScholixObject {
+	scheme := n.Scheme
+	if scheme == SchemeRAiD {
+		// RAiDs are registered as handles.
+		scheme = SchemeHandle
+	}
+	typ := "other"
+	switch strings.ToLower(n.Type) {
+	case "dataset":
+		typ = "dataset"
+	case "software":
+		typ = "software"
+	}
+	return ScholixObject{
+		Identifier: []ScholixIdentifier{{ID: n.PID, IDScheme: scheme, IDURL: n.ID}},
+		Type:       ScholixType{Name: typ},
+		Title:      n.Name,
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/cloudfront"
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/iam"
+	"github.com/scttfrdmn/aperture/internal/lambda"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/servicequotas"
+	"github.com/scttfrdmn/aperture/internal/ses"
+)
+
+// DeployActions are the IAM actions deploying each layer of the stack
+// takes, one or more per kind of resource it creates, by layer.
+var DeployActions = map[string][]string{
+	"storage": {
+		"s3:CreateBucket",
+		"s3:PutBucketPolicy",
+		"s3:PutBucketVersioning",
+		"s3:PutEncryptionConfiguration",
+	},
+	"compute": {
+		"dynamodb:CreateTable",
+		"dynamodb:UpdateTable",
+		"lambda:CreateFunction",
+		"lambda:UpdateFunctionCode",
+		"lambda:PublishVersion",
+		"lambda:CreateAlias",
+		"lambda:UpdateAlias",
+		"iam:CreateRole",
+		"iam:PutRolePolicy",
+		"iam:PassRole",
+		"cognito-idp:CreateUserPool",
+		"apigateway:POST",
+		"cloudfront:CreateDistribution",
+		"events:PutRule",
+		"cloudwatch:PutMetricAlarm",
+		"cloudwatch:DescribeAlarms",
+		"logs:CreateLogGroup",
+	},
+	"logging": {
+		"s3:CreateBucket",
+		"s3:PutBucketPolicy",
+		"s3:PutBucketAcl",
+	},
+}
+
+// Buckets checks that the buckets named can be created, or, if the
+// environment was deployed before, that they are the environment's
+// own. Bucket names are global: one taken by another account, or in
+// another region, fails the deployment.
+func Buckets(client *s3.Client, names []string, deployed bool) Check {
+	return Check{Name: "S3 bucket names", Run: func(ctx context.Context) Finding {
+		var problems []string
+		for _, name := range names {
+			err := client.HeadBucket(ctx, name)
+			var s3Err *s3.Error
+			switch {
+			case errors.Is(err, s3.ErrNotFound):
+			case err == nil && !deployed:
+				problems = append(problems, name+" already exists in this account; delete it or import it into the stack")
+			case err == nil:
+			case errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusForbidden:
+				problems = append(problems, name+" is taken by another account")
+			case errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusMovedPermanently:
+				problems = append(problems, name+" exists in another region")
+			default:
+				return Finding{Status: Warn, Detail: fmt.Sprintf("could not check %s: %v", name, err)}
+			}
+		}
+		if len(problems) > 0 {
+			return Finding{Status: Fail, Detail: strings.Join(problems, "; ")}
+		}
+		if deployed {
+			return Finding{Status: Pass, Detail: fmt.Sprintf("%d buckets belong to this account or are free", len(names))}
+		}
+		return Finding{Status: Pass, Detail: fmt.Sprintf("%d bucket names are free", len(names))}
+	}}
+}
+
+// LambdaConcurrency checks that the account may run at least want
+// Lambda functions at once in the region. Accounts below the default
+// quota throttle API requests under load, so a lower limit warns.
+func LambdaConcurrency(client *lambda.Client, want int) Check {
+	return Check{Name: "Lambda concurrency", Run: func(ctx context.Context) Finding {
+		s, err := client.GetAccountSettings(ctx)
+		if err != nil {
+			return Finding{Status: Warn, Detail: fmt.Sprintf("could not read the account's Lambda limits: %v", err)}
+		}
+		if s.ConcurrentExecutions < want {
+			return Finding{Status: Warn, Detail: fmt.Sprintf(
+				"the account may run %d functions at once; API requests beyond that are throttled. Request an increase to at least %d through Service Quotas",
+				s.ConcurrentExecutions, want)}
+		}
+		return Finding{Status: Pass, Detail: fmt.Sprintf("%d concurrent executions, %d unreserved", s.ConcurrentExecutions, s.UnreservedConcurrentExecutions)}
+	}}
+}
+
+// CloudFrontDistributions checks that the account's quota of CloudFront
+// distributions leaves room for the need the stack creates.
+func CloudFrontDistributions(quotas *servicequotas.Client, client *cloudfront.Client, need int) Check {
+	return Check{Name: "CloudFront distributions", Run: func(ctx context.Context) Finding {
+		q, err := quotas.GetServiceQuota(ctx, "cloudfront", servicequotas.CloudFrontDistributions)
+		if err != nil {
+			return Finding{Status: Warn, Detail: fmt.Sprintf("could not read the distribution quota: %v", err)}
+		}
+		n, err := client.CountDistributions(ctx)
+		if err != nil {
+			return Finding{Status: Warn, Detail: fmt.Sprintf("could not count distributions: %v", err)}
+		}
+		limit := int(q.Value)
+		if n+need > limit {
+			return Finding{Status: Fail, Detail: fmt.Sprintf("the account has %d of %d distributions and the stack needs %d more; request an increase through Service Quotas", n, limit, need)}
+		}
+		return Finding{Status: Pass, Detail: fmt.Sprintf("%d of %d distributions used, %d needed", n, limit, need)}
+	}}
+}
+
+// SESRegion returns the region of smtpAddr if it is an Amazon SES SMTP
+// endpoint, e.g. email-smtp.us-east-1.amazonaws.com:587.
+func SESRegion(smtpAddr string) (string, bool) {
+	host, _, err := net.SplitHostPort(smtpAddr)
+	if err != nil {
+		host = smtpAddr
+	}
+	region, ok := strings.CutPrefix(host, "email-smtp.")
+	if !ok {
+		return "", false
+	}
+	if region, ok = strings.CutSuffix(region, ".amazonaws.com"); !ok || region == "" {
+		return "", false
+	}
+	return region, true
+}
+
+// Mail checks that notifications sent through the relay smtpAddr reach
+// depositors. Only SES relays, which client reads the account of, are
+// checked: an account in the SES sandbox only delivers to verified
+// addresses.
+func Mail(client *ses.Client, smtpAddr string) Check {
+	return Check{Name: "SES sending", Run: func(ctx context.Context) Finding {
+		switch {
+		case smtpAddr == "":
+			return Finding{Status: Skip, Detail: "no mail relay is configured; notifications are not sent"}
+		case client == nil:
+			return Finding{Status: Skip, Detail: "notifications go through " + smtpAddr + ", not SES"}
+		}
+		a, err := client.GetAccount(ctx)
+		switch {
+		case err != nil:
+			return Finding{Status: Warn, Detail: fmt.Sprintf("could not read the SES account: %v", err)}
+		case !a.SendingEnabled:
+			return Finding{Status: Warn, Detail: "sending is paused for the account; notifications are not delivered"}
+		case !a.ProductionAccessEnabled:
+			return Finding{Status: Warn, Detail: fmt.Sprintf("the account is in the SES sandbox: notifications only reach verified addresses, at most %.0f a day; request production access", a.Max24HourSend)}
+		}
+		return Finding{Status: Pass, Detail: fmt.Sprintf("production access, %.0f messages a day", a.Max24HourSend)}
+	}}
+}
+
+// DataCite checks that client's repository account accepts its
+// credentials and holds prefix, which DOIs are minted under.
+func DataCite(client *datacite.Client, repositoryID, prefix string) Check {
+	return Check{Name: "DataCite credentials", Run: func(ctx context.Context) Finding {
+		if repositoryID == "" {
+			return Finding{Status: Warn, Detail: "no DataCite repository account is configured; DOIs cannot be registered"}
+		}
+		prefixes, err := client.Prefixes(ctx)
+		var apiErr *datacite.APIError
+		switch {
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized:
+			return Finding{Status: Fail, Detail: "DataCite rejected the credentials of " + repositoryID}
+		case err != nil:
+			return Finding{Status: Warn, Detail: fmt.Sprintf("could not reach DataCite: %v", err)}
+		case prefix != "" && !slices.Contains(prefixes, prefix):
+			return Finding{Status: Fail, Detail: fmt.Sprintf("%s does not hold the prefix %s (it holds %s)", repositoryID, prefix, strings.Join(prefixes, ", "))}
+		}
+		return Finding{Status: Pass, Detail: fmt.Sprintf("%s holds %s", repositoryID, strings.Join(prefixes, ", "))}
+	}}
+}
+
+// Permissions checks that the policies of the principal whose
+// credentials client signs with allow actions. An assumed role's
+// policies are simulated; the account's root user, which may do
+// anything, warns, as deployments should not use it.
+func Permissions(client *iam.Client, actions []string) Check {
+	return Check{Name: "IAM permissions", Run: func(ctx context.Context) Finding {
+		id, err := client.GetCallerIdentity(ctx)
+		if err != nil {
+			return Finding{Status: Fail, Detail: fmt.Sprintf("the AWS credentials do not work: %v", err)}
+		}
+		principal, err := policySource(ctx, client, id)
+		switch {
+		case err != nil:
+			return Finding{Status: Warn, Detail: err.Error()}
+		case principal == "":
+			return Finding{Status: Warn, Detail: "deploying as the root user of account " + id.Account + "; use a role with only the permissions the stack needs"}
+		}
+		evals, err := client.SimulatePrincipalPolicy(ctx, principal, actions)
+		if err != nil {
+			return Finding{Status: Warn, Detail: fmt.Sprintf("could not simulate the policies of %s: %v", principal, err)}
+		}
+		var denied []string
+		for _, e := range evals {
+			if e.Decision != iam.DecisionAllowed {
+				denied = append(denied, e.Action)
+			}
+		}
+		if len(denied) > 0 {
+			return Finding{Status: Fail, Detail: fmt.Sprintf("%s may not %s", principal, strings.Join(denied, ", "))}
+		}
+		return Finding{Status: Pass, Detail: fmt.Sprintf("%s may take the %d actions deployment needs", principal, len(actions))}
+	}}
+}
+
+// policySource returns the ARN of the user or role whose policies
+// apply to id, or "" for the root user. The ARN of an assumed role's
+// session leaves out the role's path, so the role is looked up.
+func policySource(ctx context.Context, client *iam.Client, id *iam.Identity) (string, error) {
+	_, resource, _ := strings.Cut(id.ARN, ":"+id.Account+":")
+	switch kind, rest, _ := strings.Cut(resource, "/"); kind {
+	case "root":
+		return "", nil
+	case "user":
+		return id.ARN, nil
+	case "assumed-role":
+		role, _, _ := strings.Cut(rest, "/")
+		arn, err := client.GetRole(ctx, role)
+		if err != nil {
+			return "", fmt.Errorf("could not look up the role %s: %w", role, err)
+		}
+		return arn, nil
+	}
+	return "", fmt.Errorf("cannot simulate the policies of %s", id.ARN)
+}
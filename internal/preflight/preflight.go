@@ -0,0 +1,104 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package preflight checks the account-level prerequisites of a
+// deployment before it is applied: that the bucket names are free,
+// that the service quotas leave room for the stack, that mail can
+// reach depositors, that the DataCite account works, and that the
+// deploying principal may create what the stack holds. Problems found
+// here would otherwise surface half way through an apply.
+package preflight
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+// Statuses, from best to worst.
+const (
+	// Pass means the prerequisite is met
+	Pass Status = "pass"
+
+	// Skip means the check does not apply to the configuration
+	Skip Status = "skip"
+
+	// Warn means the deployment succeeds, but something will not work
+	// as expected until it is fixed
+	Warn Status = "warn"
+
+	// Fail means the deployment would fail
+	Fail Status = "fail"
+)
+
+// Check verifies one prerequisite.
+type Check struct {
+	Name string
+
+	// Run returns the outcome; its Check is filled in
+	Run func(ctx context.Context) Finding
+}
+
+// Finding is the outcome of a check.
+type Finding struct {
+	Check  string `json:"check"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Report is the outcome of a run of checks, in check order.
+type Report struct {
+	Findings []Finding `json:"findings"`
+}
+
+// Count returns the number of findings with status.
+func (r *Report) Count(status Status) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed reports whether a check found the deployment would fail.
+func (r *Report) Failed() bool {
+	return r.Count(Fail) > 0
+}
+
+// Timeout bounds each check.
+const Timeout = 30 * time.Second
+
+// Run runs checks concurrently, each within Timeout.
+func Run(ctx context.Context, checks []Check) *Report {
+	findings := make([]Finding, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, Timeout)
+			defer cancel()
+			f := c.Run(ctx)
+			f.Check = c.Name
+			findings[i] = f
+		}()
+	}
+	wg.Wait()
+	return &Report{Findings: findings}
+}
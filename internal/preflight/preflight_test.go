@@ -0,0 +1,205 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/cloudfront"
+	"github.com/scttfrdmn/aperture/internal/datacite"
+	"github.com/scttfrdmn/aperture/internal/iam"
+	"github.com/scttfrdmn/aperture/internal/lambda"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/servicequotas"
+	"github.com/scttfrdmn/aperture/internal/ses"
+)
+
+var creds = aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+
+func serve(t *testing.T, h http.HandlerFunc) string {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// run runs check and returns its finding.
+func run(c Check) Finding {
+	return Run(context.Background(), []Check{c}).Findings[0]
+}
+
+func TestRun(t *testing.T) {
+	r := Run(context.Background(), []Check{
+		{Name: "a", Run: func(context.Context) Finding { return Finding{Status: Pass} }},
+		{Name: "b", Run: func(context.Context) Finding { return Finding{Status: Warn} }},
+	})
+	if r.Findings[1].Check != "b" || r.Failed() || r.Count(Warn) != 1 {
+		t.Errorf("Run() = %+v", r)
+	}
+	r.Findings[0].Status = Fail
+	if !r.Failed() {
+		t.Error("Failed() = false with a failing check")
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	url := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/aperture-prod-logs":
+		case "/aperture-prod-frontend":
+			w.WriteHeader(http.StatusForbidden)
+		case "/aperture-prod-processing":
+			w.WriteHeader(http.StatusMovedPermanently)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	client, err := s3.NewClient(s3.Options{Region: "us-east-1", Endpoint: url, PathStyle: true, Credentials: creds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		names    []string
+		deployed bool
+		status   Status
+		detail   string
+	}{
+		{[]string{"aperture-prod-public-media", "aperture-prod-logs"}, true, Pass, "2 buckets"},
+		{[]string{"aperture-prod-logs"}, false, Fail, "aperture-prod-logs already exists in this account"},
+		{[]string{"aperture-prod-frontend", "aperture-prod-processing"}, true, Fail,
+			"aperture-prod-frontend is taken by another account; aperture-prod-processing exists in another region"},
+	} {
+		f := run(Buckets(client, tt.names, tt.deployed))
+		if f.Status != tt.status || !strings.Contains(f.Detail, tt.detail) {
+			t.Errorf("Buckets(%v, %t) = %+v, want %s with %q", tt.names, tt.deployed, f, tt.status, tt.detail)
+		}
+	}
+}
+
+func TestLambdaConcurrency(t *testing.T) {
+	url := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"AccountLimit": {"ConcurrentExecutions": 10, "UnreservedConcurrentExecutions": 10}}`)
+	})
+	client := lambda.NewClient(lambda.Options{Region: "us-east-1", Endpoint: url, Credentials: creds})
+	if f := run(LambdaConcurrency(client, 1000)); f.Status != Warn || !strings.Contains(f.Detail, "at least 1000") {
+		t.Errorf("LambdaConcurrency(1000) = %+v, want a warning", f)
+	}
+	if f := run(LambdaConcurrency(client, 10)); f.Status != Pass {
+		t.Errorf("LambdaConcurrency(10) = %+v, want pass", f)
+	}
+}
+
+func TestCloudFrontDistributions(t *testing.T) {
+	url := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			fmt.Fprint(w, `{"Quota": {"QuotaName": "Web distributions per AWS account", "Value": 200.0}}`)
+			return
+		}
+		fmt.Fprint(w, `<DistributionList><Quantity>199</Quantity><IsTruncated>false</IsTruncated></DistributionList>`)
+	})
+	quotas := servicequotas.NewClient(servicequotas.Options{Region: "us-east-1", Endpoint: url, Credentials: creds})
+	client := cloudfront.NewClient("", creds)
+	client.Endpoint = url
+	if f := run(CloudFrontDistributions(quotas, client, 2)); f.Status != Fail || !strings.Contains(f.Detail, "199 of 200") {
+		t.Errorf("CloudFrontDistributions(2) = %+v, want fail", f)
+	}
+	if f := run(CloudFrontDistributions(quotas, client, 0)); f.Status != Pass {
+		t.Errorf("CloudFrontDistributions(0) = %+v, want pass", f)
+	}
+}
+
+func TestSESRegion(t *testing.T) {
+	for addr, want := range map[string]string{
+		"email-smtp.eu-west-1.amazonaws.com:587": "eu-west-1",
+		"email-smtp.us-east-1.amazonaws.com":     "us-east-1",
+		"smtp.example.edu:25":                    "",
+		"email-smtp.example.edu:25":              "",
+	} {
+		if got, ok := SESRegion(addr); got != want || ok != (want != "") {
+			t.Errorf("SESRegion(%q) = %q, %t, want %q", addr, got, ok, want)
+		}
+	}
+}
+
+func TestMail(t *testing.T) {
+	url := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ProductionAccessEnabled": false, "SendingEnabled": true, "SendQuota": {"Max24HourSend": 200.0}}`)
+	})
+	client := ses.NewClient(ses.Options{Region: "us-east-1", Endpoint: url, Credentials: creds})
+	if f := run(Mail(client, "email-smtp.us-east-1.amazonaws.com:587")); f.Status != Warn || !strings.Contains(f.Detail, "sandbox") {
+		t.Errorf("Mail() = %+v, want a sandbox warning", f)
+	}
+	if f := run(Mail(nil, "smtp.example.edu:25")); f.Status != Skip {
+		t.Errorf("Mail() of another relay = %+v, want skip", f)
+	}
+}
+
+func TestDataCite(t *testing.T) {
+	url := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		if _, pass, _ := r.BasicAuth(); pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"10.5555","type":"prefixes"}]}`)
+	})
+	client := datacite.NewClient(datacite.Options{BaseURL: url, RepositoryID: "ABC.XYZ", Password: "secret"})
+	if f := run(DataCite(client, "ABC.XYZ", "10.5555")); f.Status != Pass {
+		t.Errorf("DataCite() = %+v, want pass", f)
+	}
+	if f := run(DataCite(client, "ABC.XYZ", "10.80000")); f.Status != Fail || !strings.Contains(f.Detail, "does not hold the prefix 10.80000") {
+		t.Errorf("DataCite() with another prefix = %+v, want fail", f)
+	}
+	bad := datacite.NewClient(datacite.Options{BaseURL: url, RepositoryID: "ABC.XYZ", Password: "wrong"})
+	if f := run(DataCite(bad, "ABC.XYZ", "10.5555")); f.Status != Fail || !strings.Contains(f.Detail, "rejected the credentials") {
+		t.Errorf("DataCite() with bad credentials = %+v, want fail", f)
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	caller := "arn:aws:sts::123456789012:assumed-role/deployer/alice"
+	url := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.PostForm.Get("Action") {
+		case "GetCallerIdentity":
+			fmt.Fprintf(w, `<GetCallerIdentityResponse><GetCallerIdentityResult><Arn>%s</Arn><Account>123456789012</Account></GetCallerIdentityResult></GetCallerIdentityResponse>`, caller)
+		case "GetRole":
+			fmt.Fprintf(w, `<GetRoleResponse><GetRoleResult><Role><Arn>arn:aws:iam::123456789012:role/ci/%s</Arn></Role></GetRoleResult></GetRoleResponse>`, r.PostForm.Get("RoleName"))
+		case "SimulatePrincipalPolicy":
+			if r.PostForm.Get("PolicySourceArn") != "arn:aws:iam::123456789012:role/ci/deployer" {
+				t.Errorf("simulated %s", r.PostForm.Get("PolicySourceArn"))
+			}
+			fmt.Fprint(w, `<SimulatePrincipalPolicyResponse><SimulatePrincipalPolicyResult><EvaluationResults>
+				<member><EvalActionName>s3:CreateBucket</EvalActionName><EvalDecision>allowed</EvalDecision></member>
+				<member><EvalActionName>iam:PassRole</EvalActionName><EvalDecision>implicitDeny</EvalDecision></member>
+				</EvaluationResults><IsTruncated>false</IsTruncated></SimulatePrincipalPolicyResult></SimulatePrincipalPolicyResponse>`)
+		}
+	})
+	client := iam.NewClient(iam.Options{Endpoint: url, STSEndpoint: url, Credentials: creds})
+	f := run(Permissions(client, []string{"s3:CreateBucket", "iam:PassRole"}))
+	if f.Status != Fail || !strings.HasSuffix(f.Detail, "role/ci/deployer may not iam:PassRole") {
+		t.Errorf("Permissions() = %+v, want fail", f)
+	}
+
+	caller = "arn:aws:iam::123456789012:root"
+	if f := run(Permissions(client, []string{"s3:CreateBucket"})); f.Status != Warn || !strings.Contains(f.Detail, "root user") {
+		t.Errorf("Permissions() as root = %+v, want a warning", f)
+	}
+}
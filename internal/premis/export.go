@@ -0,0 +1,317 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package premis
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// Formats are the export formats.
+var Formats = []string{"xml", "json"}
+
+// Identifier types used in exports.
+const (
+	// IdentifierURI identifies objects by their s3:// URI
+	IdentifierURI = "URI"
+
+	// IdentifierLocal identifies events and agents by Aperture's IDs
+	IdentifierLocal = "local"
+)
+
+// Export is the preservation history of a dataset's files.
+type Export struct {
+	Dataset   string    `json:"dataset"`
+	DOI       string    `json:"doi,omitempty"`
+	Generated time.Time `json:"generated"`
+	Objects   []Object  `json:"objects"`
+	Events    []Event   `json:"events"`
+	Agents    []string  `json:"agents"`
+}
+
+// Object is a stored object of a dataset and the files it backs.
+type Object struct {
+	URI    string `json:"uri"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+	BLAKE3 string `json:"blake3,omitempty"`
+	Format string `json:"format,omitempty"`
+
+	// Files are the dataset files the object backs
+	Files []File `json:"files"`
+
+	// Source is the URI of the original of a preservation copy, which
+	// Converter made
+	Source    string `json:"source,omitempty"`
+	Converter string `json:"converter,omitempty"`
+}
+
+// File is a file of a dataset version.
+type File struct {
+	Version int    `json:"version"`
+	Path    string `json:"path"`
+}
+
+// Export returns the preservation history of d's files and their
+// preservation copies: the stored objects and their events, oldest
+// first.
+func (l *Log) Export(ctx context.Context, d *dataset.Dataset) (*Export, error) {
+	ex := &Export{Dataset: d.ID, DOI: d.DOI, Generated: l.now().UTC(), Objects: []Object{}, Events: []Event{}, Agents: []string{}}
+	index := make(map[string]int)
+	agents := make(map[string]bool)
+	add := func(bucket, key string, obj Object, file File) error {
+		k := objectKey(bucket, key)
+		i, ok := index[k]
+		if !ok {
+			i = len(ex.Objects)
+			index[k] = i
+			obj.URI = "s3://" + k
+			ex.Objects = append(ex.Objects, obj)
+			events, err := l.Events(ctx, bucket, key)
+			if err != nil {
+				return err
+			}
+			for _, e := range events {
+				ex.Events = append(ex.Events, e)
+				if !agents[e.Agent] {
+					agents[e.Agent] = true
+					ex.Agents = append(ex.Agents, e.Agent)
+				}
+			}
+		}
+		ex.Objects[i].Files = append(ex.Objects[i].Files, file)
+		return nil
+	}
+	for _, v := range d.Versions {
+		for _, f := range v.Files {
+			file := File{Version: v.Number, Path: f.Path}
+			if err := add(f.Bucket, f.Key, Object{Size: f.Size, SHA256: f.SHA256, BLAKE3: f.BLAKE3, Format: f.ContentType}, file); err != nil {
+				return nil, err
+			}
+			for _, dv := range f.Derivatives {
+				obj := Object{
+					Size: dv.Size, SHA256: dv.SHA256, BLAKE3: dv.BLAKE3, Format: dv.ContentType,
+					Source: "s3://" + objectKey(f.Bucket, f.Key), Converter: dv.Converter,
+				}
+				if err := add(dv.Bucket, dv.Key, obj, file); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	slices.SortStableFunc(ex.Events, func(a, b Event) int {
+		return cmp.Or(a.Time.Compare(b.Time), cmp.Compare(a.ID, b.ID))
+	})
+	slices.Sort(ex.Agents)
+	return ex, nil
+}
+
+// WriteJSON writes ex as indented JSON.
+func WriteJSON(w io.Writer, ex *Export) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ex)
+}
+
+// premisXML and the types below are the subset of PREMIS 3 written.
+type premisXML struct {
+	XMLName xml.Name    `xml:"premis"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	XSI     string      `xml:"xmlns:xsi,attr"`
+	Schema  string      `xml:"xsi:schemaLocation,attr"`
+	Version string      `xml:"version,attr"`
+	Objects []objectXML `xml:"object"`
+	Events  []eventXML  `xml:"event"`
+	Agents  []agentXML  `xml:"agent"`
+}
+
+type objectXML struct {
+	Type            string      `xml:"xsi:type,attr"`
+	Identifier      objectIDXML `xml:"objectIdentifier"`
+	Characteristics charsXML    `xml:"objectCharacteristics"`
+	OriginalName    string      `xml:"originalName"`
+	Storage         storageXML  `xml:"storage"`
+	Relationships   []relXML    `xml:"relationship,omitempty"`
+	Events          []linkIDXML `xml:"linkingEventIdentifier,omitempty"`
+}
+
+type objectIDXML struct {
+	Type  string `xml:"objectIdentifierType"`
+	Value string `xml:"objectIdentifierValue"`
+}
+
+type charsXML struct {
+	Fixity []fixityXML `xml:"fixity,omitempty"`
+	Size   int64       `xml:"size"`
+	Format formatXML   `xml:"format"`
+}
+
+type fixityXML struct {
+	Algorithm  string `xml:"messageDigestAlgorithm"`
+	Digest     string `xml:"messageDigest"`
+	Originator string `xml:"messageDigestOriginator"`
+}
+
+type formatXML struct {
+	Name string `xml:"formatDesignation>formatName"`
+}
+
+type storageXML struct {
+	LocationType string `xml:"contentLocation>contentLocationType"`
+	Location     string `xml:"contentLocation>contentLocationValue"`
+}
+
+type relXML struct {
+	Type    string `xml:"relationshipType"`
+	SubType string `xml:"relationshipSubType"`
+	IDType  string `xml:"relatedObjectIdentifier>relatedObjectIdentifierType"`
+	ID      string `xml:"relatedObjectIdentifier>relatedObjectIdentifierValue"`
+}
+
+type linkIDXML struct {
+	Type  string `xml:"linkingEventIdentifierType"`
+	Value string `xml:"linkingEventIdentifierValue"`
+}
+
+type eventXML struct {
+	Identifier struct {
+		Type  string `xml:"eventIdentifierType"`
+		Value string `xml:"eventIdentifierValue"`
+	} `xml:"eventIdentifier"`
+	Type     string     `xml:"eventType"`
+	DateTime string     `xml:"eventDateTime"`
+	Detail   *detailXML `xml:"eventDetailInformation,omitempty"`
+	Outcome  struct {
+		Outcome string   `xml:"eventOutcome"`
+		Note    *noteXML `xml:"eventOutcomeDetail,omitempty"`
+	} `xml:"eventOutcomeInformation"`
+	Agent struct {
+		Type  string `xml:"linkingAgentIdentifierType"`
+		Value string `xml:"linkingAgentIdentifierValue"`
+	} `xml:"linkingAgentIdentifier"`
+	Objects []linkObjectXML `xml:"linkingObjectIdentifier"`
+}
+
+type detailXML struct {
+	Detail string `xml:"eventDetail"`
+}
+
+type noteXML struct {
+	Note string `xml:"eventOutcomeDetailNote"`
+}
+
+type linkObjectXML struct {
+	Type  string `xml:"linkingObjectIdentifierType"`
+	Value string `xml:"linkingObjectIdentifierValue"`
+	Role  string `xml:"linkingObjectRole,omitempty"`
+}
+
+type agentXML struct {
+	Identifier struct {
+		Type  string `xml:"agentIdentifierType"`
+		Value string `xml:"agentIdentifierValue"`
+	} `xml:"agentIdentifier"`
+	Name string `xml:"agentName"`
+	Type string `xml:"agentType"`
+}
+
+// WriteXML writes ex as a PREMIS 3 document: an object per stored
+// object, named after the first file it backs, an event per event, and
+// an agent per agent.
+func WriteXML(w io.Writer, ex *Export) error {
+	doc := premisXML{
+		XMLNS:   "http://www.loc.gov/premis/v3",
+		XSI:     "http://www.w3.org/2001/XMLSchema-instance",
+		Schema:  "http://www.loc.gov/premis/v3 https://www.loc.gov/standards/premis/premis.xsd",
+		Version: "3.0",
+	}
+	byObject := make(map[string][]linkIDXML)
+	for _, e := range ex.Events {
+		uri := "s3://" + objectKey(e.Bucket, e.Key)
+		byObject[uri] = append(byObject[uri], linkIDXML{IdentifierLocal, e.ID})
+
+		var ev eventXML
+		ev.Identifier.Type, ev.Identifier.Value = IdentifierLocal, e.ID
+		ev.Type = string(e.Type)
+		ev.DateTime = e.Time.Format(time.RFC3339)
+		if e.Detail != "" {
+			ev.Detail = &detailXML{e.Detail}
+		}
+		ev.Outcome.Outcome = string(e.Outcome)
+		if e.OutcomeDetail != "" {
+			ev.Outcome.Note = &noteXML{e.OutcomeDetail}
+		}
+		ev.Agent.Type, ev.Agent.Value = IdentifierLocal, e.Agent
+		ev.Objects = append(ev.Objects, linkObjectXML{Type: IdentifierURI, Value: uri})
+		for _, l := range e.Links {
+			ev.Objects = append(ev.Objects, linkObjectXML{Type: IdentifierURI, Value: l.URI, Role: string(l.Role)})
+			byObject[l.URI] = append(byObject[l.URI], linkIDXML{IdentifierLocal, e.ID})
+		}
+		doc.Events = append(doc.Events, ev)
+	}
+	dataset := objectIDXML{IdentifierLocal, ex.Dataset}
+	if ex.DOI != "" {
+		dataset = objectIDXML{"DOI", ex.DOI}
+	}
+	for _, o := range ex.Objects {
+		ob := objectXML{
+			Type:         "file",
+			Identifier:   objectIDXML{IdentifierURI, o.URI},
+			OriginalName: o.Files[0].Path,
+			Storage:      storageXML{IdentifierURI, o.URI},
+			Events:       byObject[o.URI],
+		}
+		ob.Characteristics.Size = o.Size
+		ob.Characteristics.Format.Name = cmp.Or(o.Format, "application/octet-stream")
+		if o.SHA256 != "" {
+			ob.Characteristics.Fixity = append(ob.Characteristics.Fixity, fixityXML{Algorithm: "SHA-256", Digest: o.SHA256, Originator: SoftwareAgent})
+		}
+		if o.BLAKE3 != "" {
+			ob.Characteristics.Fixity = append(ob.Characteristics.Fixity, fixityXML{Algorithm: "BLAKE3", Digest: o.BLAKE3, Originator: SoftwareAgent})
+		}
+		ob.Relationships = []relXML{{Type: "structural", SubType: "is included in", IDType: dataset.Type, ID: dataset.Value}}
+		if o.Source != "" {
+			ob.Relationships = append(ob.Relationships, relXML{Type: "derivation", SubType: "has source", IDType: IdentifierURI, ID: o.Source})
+		}
+		doc.Objects = append(doc.Objects, ob)
+	}
+	for _, a := range ex.Agents {
+		var ag agentXML
+		ag.Identifier.Type, ag.Identifier.Value = IdentifierLocal, a
+		ag.Name, ag.Type = a, "person"
+		if a == SoftwareAgent {
+			ag.Type = "software"
+		}
+		doc.Agents = append(doc.Agents, ag)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,297 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package premis records the preservation events of stored files and
+// exports them in PREMIS form, giving preservation partners an
+// auditable history of each file from ingest on.
+//
+// Events are kept per stored object, so a file carried unchanged into
+// later versions of a dataset shares its history with them. Ingestion
+// is recorded by a catalog observer the first time a manifest names an
+// object, fixity checks by the fixity subsystem, virus checks and
+// quarantines by malware scanning, recoveries by integrity repair, and
+// migrations and replications performed outside Aperture by operators.
+// Each event is also appended to the hash-chained audit log, which
+// makes any later change to an object's history detectable.
+package premis
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// eventsTable holds the events of each object, keyed by bucket/key.
+const eventsTable = "premis-events"
+
+// Type is a PREMIS event type, from the Library of Congress event type
+// vocabulary.
+type Type string
+
+// Event types.
+const (
+	TypeIngestion    Type = "ingestion"
+	TypeFixityCheck  Type = "fixity check"
+	TypeMigration    Type = "migration"
+	TypeReplication  Type = "replication"
+	TypeVirusCheck   Type = "virus check"
+	TypeQuarantine   Type = "quarantine"
+	TypeUnquarantine Type = "unquarantine"
+	TypeRecovery     Type = "recovery"
+)
+
+// Types lists the event types in the order they are documented.
+var Types = []Type{
+	TypeIngestion, TypeFixityCheck, TypeMigration, TypeReplication,
+	TypeVirusCheck, TypeQuarantine, TypeUnquarantine, TypeRecovery,
+}
+
+// ParseType returns the event type named s.
+func ParseType(s string) (Type, error) {
+	for _, t := range Types {
+		if string(t) == s {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown event type %q (want ingestion, fixity check, migration, replication, virus check, quarantine, unquarantine, or recovery)", s)
+}
+
+// Outcome is the outcome of an event.
+type Outcome string
+
+// Outcomes.
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Role is the role of an object linked to an event, from the Library
+// of Congress event related object role vocabulary.
+type Role string
+
+// Roles.
+const (
+	// RoleSource objects are the input of a migration or replication
+	RoleSource Role = "source"
+
+	// RoleOutcome objects are the output of a migration or replication
+	RoleOutcome Role = "outcome"
+)
+
+// Link is an object linked to an event, such as the copy a replication
+// made.
+type Link struct {
+	Role Role `json:"role"`
+
+	// URI locates the object, e.g. s3://bucket/key
+	URI string `json:"uri"`
+}
+
+// SoftwareAgent is the agent of events Aperture performs itself.
+const SoftwareAgent = "Aperture"
+
+// Event is a preservation event of a stored object.
+type Event struct {
+	// ID identifies the event
+	ID string `json:"id"`
+
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+
+	// Bucket and Key locate the object the event concerns
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+
+	// Detail describes the event, e.g. the method of a fixity check
+	Detail string `json:"detail,omitempty"`
+
+	Outcome Outcome `json:"outcome"`
+
+	// OutcomeDetail explains the outcome, e.g. the digest found by a
+	// failed fixity check
+	OutcomeDetail string `json:"outcomeDetail,omitempty"`
+
+	// Agent is the principal who performed the event, or SoftwareAgent
+	Agent string `json:"agent"`
+
+	// Links are the other objects involved
+	Links []Link `json:"links,omitempty"`
+}
+
+// Log records and returns events.
+type Log struct {
+	State state.Store
+
+	// Audit, if set, receives a copy of every event recorded
+	Audit audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Record adds events to the histories of their objects, filling in
+// missing IDs, times, outcomes, and agents in place. The agent is the
+// acting principal, or SoftwareAgent if there is none.
+func (l *Log) Record(ctx context.Context, events ...Event) error {
+	agent := cmp.Or(identity.FromContext(ctx).ID, SoftwareAgent)
+	for i := range events {
+		e := &events[i]
+		if e.Bucket == "" || e.Key == "" {
+			return fmt.Errorf("event has no object")
+		}
+		if _, err := ParseType(string(e.Type)); err != nil {
+			return err
+		}
+		if e.ID == "" {
+			id, err := newID()
+			if err != nil {
+				return err
+			}
+			e.ID = id
+		}
+		if e.Time.IsZero() {
+			e.Time = l.now()
+		}
+		e.Time = e.Time.UTC()
+		e.Agent = cmp.Or(e.Agent, agent)
+		e.Outcome = cmp.Or(e.Outcome, OutcomeSuccess)
+
+		history, err := l.Events(ctx, e.Bucket, e.Key)
+		if err != nil {
+			return err
+		}
+		history = append(history, *e)
+		if err := l.State.Put(ctx, eventsTable, objectKey(e.Bucket, e.Key), history); err != nil {
+			return fmt.Errorf("failed to record %s event of s3://%s/%s: %w", e.Type, e.Bucket, e.Key, err)
+		}
+		if l.Audit != nil {
+			if err := audit.Record(ctx, l.Audit, Action(e.Type), "s3://"+e.Bucket+"/"+e.Key, auditDetails(e)); err != nil {
+				return fmt.Errorf("failed to audit %s event of s3://%s/%s: %w", e.Type, e.Bucket, e.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Action returns the audit action of events of type t, e.g.
+// "premis.fixity-check".
+func Action(t Type) string {
+	return "premis." + strings.ReplaceAll(string(t), " ", "-")
+}
+
+// auditDetails returns the details of the audit entry of e.
+func auditDetails(e *Event) map[string]string {
+	details := map[string]string{"event": e.ID, "outcome": string(e.Outcome), "agent": e.Agent}
+	if e.Detail != "" {
+		details["detail"] = e.Detail
+	}
+	if e.OutcomeDetail != "" {
+		details["outcomeDetail"] = e.OutcomeDetail
+	}
+	for _, link := range e.Links {
+		details[string(link.Role)+"Object"] = link.URI
+	}
+	return details
+}
+
+// Events returns the events of the object bucket/key, oldest first.
+func (l *Log) Events(ctx context.Context, bucket, key string) ([]Event, error) {
+	var history []Event
+	err := l.State.Get(ctx, eventsTable, objectKey(bucket, key), &history)
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("failed to read events of s3://%s/%s: %w", bucket, key, err)
+	}
+	return history, nil
+}
+
+// Ingest records the ingestion of every object d's manifests name that
+// has no ingestion event yet, and returns the events recorded.
+func (l *Log) Ingest(ctx context.Context, d *dataset.Dataset) ([]Event, error) {
+	var out []Event
+	seen := make(map[string]bool)
+	for _, v := range d.Versions {
+		for _, f := range v.Files {
+			k := objectKey(f.Bucket, f.Key)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			history, err := l.Events(ctx, f.Bucket, f.Key)
+			if err != nil {
+				return out, err
+			}
+			if slices.ContainsFunc(history, func(e Event) bool { return e.Type == TypeIngestion }) {
+				continue
+			}
+			e := []Event{{
+				Type:   TypeIngestion,
+				Bucket: f.Bucket,
+				Key:    f.Key,
+				Detail: fmt.Sprintf("stored as %s of %s version %d", f.Path, d.ID, v.Number),
+			}}
+			if f.SHA256 != "" {
+				e[0].OutcomeDetail = "sha256:" + f.SHA256
+			}
+			if err := l.Record(ctx, e...); err != nil {
+				return out, err
+			}
+			out = append(out, e[0])
+		}
+	}
+	return out, nil
+}
+
+// Observer records the ingestion of the files of every dataset saved
+// to the catalog. It implements dataset.Observer.
+type Observer struct {
+	Log *Log
+}
+
+// Saved implements dataset.Observer. Objects whose ingestion fails to
+// be recorded are recorded the next time their dataset is saved.
+func (o *Observer) Saved(ctx context.Context, d *dataset.Dataset) {
+	_, _ = o.Log.Ingest(ctx, d)
+}
+
+// Deleted implements dataset.Observer. Events outlive the datasets
+// they concern.
+func (o *Observer) Deleted(context.Context, string) {}
+
+func objectKey(bucket, key string) string { return bucket + "/" + key }
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate event ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (l *Log) now() time.Time {
+	if l.Now != nil {
+		return l.Now()
+	}
+	return time.Now()
+}
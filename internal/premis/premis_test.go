@@ -0,0 +1,134 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package premis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func TestLog(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	s := state.NewMemoryStore()
+	trail := &audit.MemoryLog{}
+	log := &Log{State: s, Audit: trail, Now: func() time.Time { return now }}
+	datasets := dataset.NewStore(s)
+	datasets.Observe(&Observer{Log: log})
+
+	a := dataset.File{Path: "a.csv", Bucket: "pub", Key: "ds-1/a.csv", Size: 3, SHA256: "abc", BLAKE3: "def", ContentType: "text/csv"}
+	d := &dataset.Dataset{ID: "ds-1", DOI: "10.1234/ds-1", Versions: []dataset.Version{{Number: 1, Files: []dataset.File{a}}}}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// A new version carrying a.csv over ingests only the new file.
+	now = now.Add(time.Hour)
+	b := dataset.File{Path: "b.csv", Bucket: "pub", Key: "ds-1/b.csv", Size: 4}
+	d.Versions = append(d.Versions, dataset.Version{Number: 2, Files: []dataset.File{a, b}})
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	events, err := log.Events(ctx, "pub", "ds-1/a.csv")
+	if err != nil {
+		t.Fatalf("Events() error = %v", err)
+	}
+	if len(events) != 1 || events[0].Type != TypeIngestion || events[0].OutcomeDetail != "sha256:abc" || events[0].Agent != SoftwareAgent || events[0].ID == "" {
+		t.Errorf("Events(a.csv) = %+v, want one ingestion", events)
+	}
+
+	now = now.Add(time.Hour)
+	ctx = identity.WithPrincipal(ctx, identity.Principal{ID: "curator@uni.edu"})
+	err = log.Record(ctx, Event{
+		Type: TypeReplication, Bucket: "pub", Key: "ds-1/b.csv",
+		Links: []Link{{Role: RoleOutcome, URI: "s3://replica/ds-1/b.csv"}},
+	})
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := log.Record(ctx, Event{Type: "deletion", Bucket: "pub", Key: "ds-1/b.csv"}); err == nil {
+		t.Error("Record(deletion) succeeded, want an error")
+	}
+	entries, err := trail.Entries(ctx)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("audit Entries() = %d entries, %v, want 3", len(entries), err)
+	}
+	if e := entries[2]; e.Action != "premis.replication" || e.Target != "s3://pub/ds-1/b.csv" || e.Actor != "curator@uni.edu" || e.Details["outcome"] != "success" || e.Details["outcomeObject"] != "s3://replica/ds-1/b.csv" || e.Seq != 3 {
+		t.Errorf("audit entry = %+v, want the replication", e)
+	}
+
+	ex, err := log.Export(ctx, d)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if len(ex.Objects) != 2 || len(ex.Objects[0].Files) != 2 || len(ex.Events) != 3 {
+		t.Fatalf("Export() = %d objects, %d events, want 2 and 3", len(ex.Objects), len(ex.Events))
+	}
+	if last := ex.Events[2]; last.Type != TypeReplication || last.Agent != "curator@uni.edu" {
+		t.Errorf("last event = %+v, want the replication by the curator", last)
+	}
+	if len(ex.Agents) != 2 {
+		t.Errorf("Agents = %v, want Aperture and the curator", ex.Agents)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteXML(&buf, ex); err != nil {
+		t.Fatalf("WriteXML() error = %v", err)
+	}
+	var doc struct {
+		Objects []struct {
+			Identifier string   `xml:"objectIdentifier>objectIdentifierValue"`
+			Digests    []string `xml:"objectCharacteristics>fixity>messageDigest"`
+			Events     []string `xml:"linkingEventIdentifier>linkingEventIdentifierValue"`
+		} `xml:"object"`
+		Events []struct {
+			Type  string   `xml:"eventType"`
+			Roles []string `xml:"linkingObjectIdentifier>linkingObjectRole"`
+		} `xml:"event"`
+		Agents []string `xml:"agent>agentType"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("WriteXML() wrote invalid XML: %v\n%s", err, buf.String())
+	}
+	if len(doc.Objects) != 2 || doc.Objects[0].Identifier != "s3://pub/ds-1/a.csv" || !slices.Equal(doc.Objects[0].Digests, []string{"abc", "def"}) || len(doc.Objects[1].Events) != 2 {
+		t.Errorf("objects = %+v", doc.Objects)
+	}
+	if len(doc.Events) != 3 || doc.Events[2].Type != "replication" || len(doc.Events[2].Roles) != 1 || doc.Events[2].Roles[0] != "outcome" {
+		t.Errorf("events = %+v", doc.Events)
+	}
+	if !strings.Contains(buf.String(), `xmlns="http://www.loc.gov/premis/v3"`) {
+		t.Errorf("WriteXML() wrote no PREMIS namespace:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, ex); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	var back Export
+	if err := json.Unmarshal(buf.Bytes(), &back); err != nil || len(back.Events) != 3 {
+		t.Errorf("WriteJSON() round trip = %d events, %v", len(back.Events), err)
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pricing is a minimal AWS Price List Query API client for
+// looking up the on-demand prices of AWS products.
+package pricing
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+// targetPrefix prefixes the X-Amz-Target of every operation.
+const targetPrefix = "AWSPriceListService."
+
+// Region is the region whose endpoint serves the prices of every
+// region.
+const Region = "us-east-1"
+
+// ErrNotFound is returned when no product matches a query.
+var ErrNotFound = errors.New("pricing: not found")
+
+// Options configures a Client.
+type Options struct {
+	// Endpoint overrides the AWS endpoint
+	Endpoint string
+
+	// Credentials sign requests
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is a Price List Query API client.
+type Client struct {
+	endpoint string
+	signer   *aws.Signer
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	endpoint := cmp.Or(opts.Endpoint, "https://api.pricing."+Region+".amazonaws.com")
+	c := &Client{
+		endpoint: strings.TrimRight(endpoint, "/") + "/",
+		signer:   &aws.Signer{Credentials: opts.Credentials, Region: Region, Service: "pricing"},
+		http:     opts.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Product is a product and its on-demand prices.
+type Product struct {
+	Family     string
+	Attributes map[string]string
+	Prices     []Price
+}
+
+// Price is the on-demand price of a product, for usage in a range of
+// a tiered price.
+type Price struct {
+	// Unit is the unit priced, e.g. "GB-Mo" or "Requests"
+	Unit        string
+	Description string
+
+	// BeginRange and EndRange bound the usage the price applies to;
+	// EndRange is +Inf for the last tier
+	BeginRange float64
+	EndRange   float64
+
+	// USD is the price of one unit
+	USD float64
+}
+
+// GetProducts returns the products of service, e.g. "AmazonS3", whose
+// attributes have the values of filters.
+func (c *Client) GetProducts(ctx context.Context, service string, filters map[string]string) ([]Product, error) {
+	type filter struct {
+		Type  string
+		Field string
+		Value string
+	}
+	in := struct {
+		ServiceCode   string
+		Filters       []filter
+		FormatVersion string
+		NextToken     string `json:",omitempty"`
+	}{ServiceCode: service, FormatVersion: "aws_v1"}
+	for _, field := range slices.Sorted(maps.Keys(filters)) {
+		in.Filters = append(in.Filters, filter{Type: "TERM_MATCH", Field: field, Value: filters[field]})
+	}
+
+	var products []Product
+	for {
+		var out struct {
+			PriceList []string
+			NextToken string
+		}
+		if err := c.do(ctx, "GetProducts", in, &out); err != nil {
+			return nil, err
+		}
+		for _, item := range out.PriceList {
+			p, err := decodeProduct(item)
+			if err != nil {
+				return nil, err
+			}
+			products = append(products, p)
+		}
+		if out.NextToken == "" {
+			return products, nil
+		}
+		in.NextToken = out.NextToken
+	}
+}
+
+// decodeProduct decodes a price list entry.
+func decodeProduct(item string) (Product, error) {
+	var entry struct {
+		Product struct {
+			ProductFamily string            `json:"productFamily"`
+			Attributes    map[string]string `json:"attributes"`
+		} `json:"product"`
+		Terms struct {
+			OnDemand map[string]struct {
+				PriceDimensions map[string]struct {
+					Unit         string            `json:"unit"`
+					Description  string            `json:"description"`
+					BeginRange   string            `json:"beginRange"`
+					EndRange     string            `json:"endRange"`
+					PricePerUnit map[string]string `json:"pricePerUnit"`
+				} `json:"priceDimensions"`
+			} `json:"OnDemand"`
+		} `json:"terms"`
+	}
+	if err := json.Unmarshal([]byte(item), &entry); err != nil {
+		return Product{}, fmt.Errorf("failed to decode price list entry: %w", err)
+	}
+	p := Product{Family: entry.Product.ProductFamily, Attributes: entry.Product.Attributes}
+	for _, term := range entry.Terms.OnDemand {
+		for _, d := range term.PriceDimensions {
+			usd, err := strconv.ParseFloat(d.PricePerUnit["USD"], 64)
+			if err != nil {
+				continue
+			}
+			begin, _ := strconv.ParseFloat(d.BeginRange, 64)
+			end, err := strconv.ParseFloat(d.EndRange, 64)
+			if err != nil {
+				end = math.Inf(1)
+			}
+			p.Prices = append(p.Prices, Price{Unit: d.Unit, Description: d.Description, BeginRange: begin, EndRange: end, USD: usd})
+		}
+	}
+	slices.SortFunc(p.Prices, func(a, b Price) int { return cmp.Compare(a.BeginRange, b.BeginRange) })
+	return p, nil
+}
+
+// Price returns the first paid tier of the on-demand prices of the
+// products of service matching filters: the price of usage beyond any
+// free allowance. If several products match, the cheapest is returned.
+func (c *Client) Price(ctx context.Context, service string, filters map[string]string) (*Price, error) {
+	products, err := c.GetProducts(ctx, service, filters)
+	if err != nil {
+		return nil, err
+	}
+	var best *Price
+	for _, p := range products {
+		for _, price := range p.Prices {
+			if price.USD == 0 {
+				continue
+			}
+			if best == nil || price.USD < best.USD {
+				best = &price
+			}
+			break
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: no %s price for %v", ErrNotFound, service, filters)
+	}
+	return best, nil
+}
+
+// do calls operation with in as the JSON request and decodes the
+// response into out.
+func (c *Client) do(ctx context.Context, operation string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", operation, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", operation, err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", targetPrefix+operation)
+	c.signer.Sign(req, aws.HashPayload(body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("pricing %s failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode, Operation: operation}
+		_ = json.Unmarshal(data, e)
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		return e
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", operation, err)
+	}
+	return nil
+}
+
+// Error is a Price List Query API error response.
+type Error struct {
+	StatusCode int
+	Operation  string
+	Type       string `json:"__type"`
+	Message    string `json:"Message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("pricing %s: HTTP %d %s: %s", e.Operation, e.StatusCode, e.Type, e.Message)
+}
+
+// Unwrap maps unknown services to ErrNotFound.
+func (e *Error) Unwrap() error {
+	if e.Type == "NotFoundException" {
+		return ErrNotFound
+	}
+	return nil
+}
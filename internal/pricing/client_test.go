@@ -0,0 +1,125 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return NewClient(Options{
+		Endpoint:    srv.URL,
+		Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+}
+
+// entry returns a price list entry of a product with a tiered price.
+func entry(t *testing.T, family string, tiers ...string) string {
+	t.Helper()
+	dims := make(map[string]any)
+	for i := 0; i+2 < len(tiers); i += 3 {
+		dims[fmt.Sprint("SKU.TERM.", i)] = map[string]any{
+			"unit": "GB-Mo", "beginRange": tiers[i], "endRange": tiers[i+1],
+			"pricePerUnit": map[string]string{"USD": tiers[i+2]},
+		}
+	}
+	data, err := json.Marshal(map[string]any{
+		"product": map[string]any{"productFamily": family, "attributes": map[string]string{"regionCode": "us-west-2"}},
+		"terms":   map[string]any{"OnDemand": map[string]any{"SKU.TERM": map[string]any{"priceDimensions": dims}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestGetProducts(t *testing.T) {
+	var pages int
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			ServiceCode string
+			Filters     []struct{ Type, Field, Value string }
+			NextToken   string
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		if r.Header.Get("X-Amz-Target") != targetPrefix+"GetProducts" || in.ServiceCode != "AmazonS3" ||
+			len(in.Filters) != 2 || in.Filters[0].Field != "regionCode" || in.Filters[1] != (struct{ Type, Field, Value string }{"TERM_MATCH", "volumeType", "Standard"}) {
+			t.Errorf("request = %+v", in)
+		}
+		pages++
+		out := map[string]any{"PriceList": []string{entry(t, "Storage", "0", "51200", "0.0230000000", "51200", "Inf", "0.0220000000")}}
+		if in.NextToken == "" {
+			out["NextToken"] = "page-2"
+		}
+		json.NewEncoder(w).Encode(out)
+	})
+	products, err := c.GetProducts(context.Background(), "AmazonS3", map[string]string{"volumeType": "Standard", "regionCode": "us-west-2"})
+	if err != nil {
+		t.Fatalf("GetProducts() error = %v", err)
+	}
+	if pages != 2 || len(products) != 2 {
+		t.Fatalf("GetProducts() = %d products of %d pages", len(products), pages)
+	}
+	p := products[0]
+	if p.Family != "Storage" || p.Attributes["regionCode"] != "us-west-2" || len(p.Prices) != 2 ||
+		p.Prices[0].USD != 0.023 || p.Prices[0].EndRange != 51200 || !math.IsInf(p.Prices[1].EndRange, 1) {
+		t.Errorf("GetProducts()[0] = %+v", p)
+	}
+}
+
+func TestPrice(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// A free allowance, then the paid tier; and a dearer product.
+		json.NewEncoder(w).Encode(map[string]any{"PriceList": []string{
+			entry(t, "Database Storage", "0", "25", "0", "25", "Inf", "0.25"),
+			entry(t, "Database Storage", "0", "Inf", "0.30"),
+		}})
+	})
+	p, err := c.Price(context.Background(), "AmazonDynamoDB", nil)
+	if err != nil || p.USD != 0.25 || p.BeginRange != 25 {
+		t.Errorf("Price() = %+v, %v", p, err)
+	}
+
+	c = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"PriceList": []}`)
+	})
+	if _, err := c.Price(context.Background(), "AmazonES", map[string]string{"instanceType": "x.search"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Price() of no product error = %v", err)
+	}
+}
+
+func TestError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type": "com.amazonaws.pricing#NotFoundException", "Message": "unknown service"}`)
+	})
+	_, err := c.GetProducts(context.Background(), "AmazonNope", nil)
+	var e *Error
+	if !errors.As(err, &e) || e.Type != "NotFoundException" || !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetProducts() error = %v", err)
+	}
+}
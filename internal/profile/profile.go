@@ -0,0 +1,228 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package profile keeps depositor profiles: the name, affiliation, and
+// authenticated ORCID iD used when a depositor is credited as a
+// dataset creator.
+//
+// ORCID iDs are never typed into a profile. They are linked only from
+// an authenticated principal, i.e. after the user has signed in with
+// ORCID, so creator metadata built from profiles carries iDs the
+// creators have proven they hold.
+package profile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// profilesTable holds one Profile per principal ID.
+const profilesTable = "profiles"
+
+var (
+	// ErrNotFound is returned when a user has no profile.
+	ErrNotFound = errors.New("profile not found")
+
+	// ErrNoORCID is returned when linking an ORCID iD for a principal
+	// that did not authenticate with ORCID.
+	ErrNoORCID = errors.New("no authenticated ORCID iD; sign in with ORCID first")
+)
+
+// Profile is a depositor's profile.
+type Profile struct {
+	// ID is the principal ID (email)
+	ID string `json:"id"`
+
+	// Name is the name credited in creator metadata ("Family, Given")
+	Name string `json:"name,omitempty"`
+
+	// Affiliation is the institution credited in creator metadata
+	Affiliation string `json:"affiliation,omitempty"`
+
+	// ORCID is the authenticated ORCID iD, if linked
+	ORCID string `json:"orcid,omitempty"`
+
+	// ORCIDLinkedAt is when ORCID was linked
+	ORCIDLinkedAt *time.Time `json:"orcidLinkedAt,omitempty"`
+
+	// UpdatedAt is when the profile last changed
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Creator returns the creator metadata for p.
+func (p *Profile) Creator() dataset.Creator {
+	return dataset.Creator{Name: p.Name, ORCID: p.ORCID, Affiliation: p.Affiliation, User: p.ID}
+}
+
+// Registry stores profiles.
+type Registry struct {
+	s   state.Store
+	log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// NewRegistry returns a registry backed by s that records ORCID links
+// in log, which may be nil.
+func NewRegistry(s state.Store, log audit.Log) *Registry {
+	return &Registry{s: s, log: log}
+}
+
+// Get returns the profile of user.
+func (r *Registry) Get(ctx context.Context, user string) (*Profile, error) {
+	var p Profile
+	err := r.s.Get(ctx, profilesTable, identity.Normalize(user), &p)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("%s: %w", user, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Mine returns the acting principal's profile, or an empty one if it
+// has not been created.
+func (r *Registry) Mine(ctx context.Context) (*Profile, error) {
+	id := identity.FromContext(ctx).ID
+	if id == "" {
+		return nil, fmt.Errorf("sign in to use a depositor profile")
+	}
+	p, err := r.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return &Profile{ID: id}, nil
+	}
+	return p, err
+}
+
+// Update sets the name and affiliation of the acting principal's
+// profile. Empty values leave the current ones unchanged.
+func (r *Registry) Update(ctx context.Context, name, affiliation string) (*Profile, error) {
+	p, err := r.Mine(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if name = strings.TrimSpace(name); name != "" {
+		p.Name = name
+	}
+	if affiliation = strings.TrimSpace(affiliation); affiliation != "" {
+		p.Affiliation = affiliation
+	}
+	return p, r.put(ctx, p)
+}
+
+// LinkORCID attaches the acting principal's authenticated ORCID iD to
+// their profile. It returns the profile and whether the iD changed.
+func (r *Registry) LinkORCID(ctx context.Context) (*Profile, bool, error) {
+	orcid := strings.ToUpper(strings.TrimPrefix(identity.FromContext(ctx).ORCID, "https://orcid.org/"))
+	if orcid == "" {
+		return nil, false, ErrNoORCID
+	}
+	if !identity.ValidORCID(orcid) {
+		return nil, false, fmt.Errorf("authenticated ORCID iD %q is malformed", orcid)
+	}
+	p, err := r.Mine(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if p.ORCID == orcid {
+		return p, false, nil
+	}
+	now := r.now().UTC()
+	p.ORCID, p.ORCIDLinkedAt = orcid, &now
+	if err := r.put(ctx, p); err != nil {
+		return nil, false, err
+	}
+	return p, true, r.record(ctx, "profile.orcid.link", p.ID, map[string]string{"orcid": p.ORCID})
+}
+
+// UnlinkORCID removes the ORCID iD from the acting principal's profile.
+func (r *Registry) UnlinkORCID(ctx context.Context) (*Profile, error) {
+	p, err := r.Mine(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.ORCID == "" {
+		return p, nil
+	}
+	old := p.ORCID
+	p.ORCID, p.ORCIDLinkedAt = "", nil
+	if err := r.put(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, r.record(ctx, "profile.orcid.unlink", p.ID, map[string]string{"orcid": old})
+}
+
+// ApplyCreators fills the creators of d that are linked to profiles
+// with the profile's ORCID iD, replacing typed iDs, and with its name
+// and affiliation where those are empty. It returns the names of the
+// creators that changed.
+func (r *Registry) ApplyCreators(ctx context.Context, d *dataset.Dataset) ([]string, error) {
+	var changed []string
+	for i := range d.Creators {
+		c := &d.Creators[i]
+		if c.User == "" {
+			continue
+		}
+		p, err := r.Get(ctx, c.User)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		before := *c
+		if p.ORCID != "" {
+			c.ORCID = p.ORCID
+		}
+		if c.Name == "" {
+			c.Name = p.Name
+		}
+		if c.Affiliation == "" {
+			c.Affiliation = p.Affiliation
+		}
+		if *c != before {
+			changed = append(changed, c.Name)
+		}
+	}
+	return changed, nil
+}
+
+func (r *Registry) put(ctx context.Context, p *Profile) error {
+	p.UpdatedAt = r.now().UTC()
+	return r.s.Put(ctx, profilesTable, p.ID, p)
+}
+
+func (r *Registry) record(ctx context.Context, action, target string, details map[string]string) error {
+	if r.log == nil {
+		return nil
+	}
+	return audit.Record(ctx, r.log, action, target, details)
+}
+
+func (r *Registry) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
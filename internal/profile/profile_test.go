@@ -0,0 +1,95 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func TestLinkORCID(t *testing.T) {
+	log := &audit.MemoryLog{}
+	r := NewRegistry(state.NewMemoryStore(), log)
+
+	noORCID := identity.WithPrincipal(context.Background(), identity.Principal{ID: "jane@uni.edu"})
+	if _, _, err := r.LinkORCID(noORCID); !errors.Is(err, ErrNoORCID) {
+		t.Errorf("LinkORCID() without ORCID sign-in error = %v, want ErrNoORCID", err)
+	}
+	bad := identity.WithPrincipal(context.Background(), identity.Principal{ID: "jane@uni.edu", ORCID: "0000-0002-1825-0098"})
+	if _, _, err := r.LinkORCID(bad); err == nil {
+		t.Error("LinkORCID() accepted an iD with a bad check digit")
+	}
+
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "jane@uni.edu", ORCID: "0000-0002-1694-233x"})
+	if _, err := r.Update(ctx, "Doe, Jane", "State University"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	p, changed, err := r.LinkORCID(ctx)
+	if err != nil || !changed {
+		t.Fatalf("LinkORCID() = %v, %v", changed, err)
+	}
+	if p.ORCID != "0000-0002-1694-233X" || p.ORCIDLinkedAt == nil || p.Name != "Doe, Jane" {
+		t.Errorf("LinkORCID() = %+v", p)
+	}
+	if _, changed, _ := r.LinkORCID(ctx); changed {
+		t.Error("LinkORCID() again reported a change")
+	}
+	if entries, _ := log.Entries(ctx); len(entries) != 1 {
+		t.Errorf("audit entries = %d, want 1", len(entries))
+	}
+
+	p, err = r.UnlinkORCID(ctx)
+	if err != nil || p.ORCID != "" || p.ORCIDLinkedAt != nil {
+		t.Errorf("UnlinkORCID() = %+v, %v", p, err)
+	}
+}
+
+func TestApplyCreators(t *testing.T) {
+	r := NewRegistry(state.NewMemoryStore(), nil)
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "jane@uni.edu", ORCID: "0000-0002-1825-0097"})
+	if _, err := r.Update(ctx, "Doe, Jane", "State University"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.LinkORCID(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &dataset.Dataset{Creators: []dataset.Creator{
+		{Name: "Doe, J.", ORCID: "0000-0002-1825-0079", User: "Jane@uni.edu"},
+		{Name: "Roe, Richard", ORCID: "0000-0001-5109-3700"},
+		{Name: "Poe, Edgar", User: "edgar@uni.edu"},
+	}}
+	changed, err := r.ApplyCreators(ctx, d)
+	if err != nil {
+		t.Fatalf("ApplyCreators() error = %v", err)
+	}
+	if !slices.Equal(changed, []string{"Doe, J."}) {
+		t.Errorf("ApplyCreators() changed = %v", changed)
+	}
+	want := dataset.Creator{Name: "Doe, J.", ORCID: "0000-0002-1825-0097", Affiliation: "State University", User: "Jane@uni.edu"}
+	if d.Creators[0] != want {
+		t.Errorf("creator = %+v, want %+v", d.Creators[0], want)
+	}
+	if d.Creators[1].ORCID != "0000-0001-5109-3700" {
+		t.Errorf("unlinked creator changed: %+v", d.Creators[1])
+	}
+}
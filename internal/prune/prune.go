@@ -0,0 +1,350 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package prune applies collections' version retention policies (see
+// collection.VersionPolicy) to the old versions of their datasets.
+//
+// A scheduled job plans which published versions fall outside the
+// versions their collection keeps online, and then moves their files to
+// archival storage or deletes them. A stored object is archived only
+// once no online version refers to it, and deleted only once no version
+// that keeps its files does, so that content shared between versions
+// stays where the newest of them needs it. The manifest of a version
+// whose files are deleted remains, with its version DOI re-registered
+// to point at the version's tombstone on the landing page.
+package prune
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// DefaultStorageClass is the storage class archived files are moved to.
+const DefaultStorageClass = "DEEP_ARCHIVE"
+
+// ObjectStore changes and deletes stored objects. *s3.Client
+// implements it.
+type ObjectStore interface {
+	HeadObject(ctx context.Context, bucket, key string) (s3.ObjectInfo, error)
+	SetStorageClass(ctx context.Context, bucket, key, class string) error
+	DeleteObject(ctx context.Context, bucket, key string) error
+}
+
+// PagePublisher re-renders a dataset's landing page.
+type PagePublisher interface {
+	Publish(ctx context.Context, datasetID string) (*landing.Change, error)
+}
+
+// PIDUpdater re-registers the metadata of a dataset's identifier and
+// version DOIs. *pid.Registrar implements it.
+type PIDUpdater interface {
+	Update(ctx context.Context, d *dataset.Dataset) error
+}
+
+// Action is an old version to archive or delete.
+type Action struct {
+	Dataset    string `json:"dataset"`
+	Collection string `json:"collection"`
+	Version    int    `json:"version"`
+	DOI        string `json:"doi,omitempty"`
+
+	// Action is dataset.PruneArchive or dataset.PruneDelete
+	Action string `json:"action"`
+
+	// Policy describes the collection's policy
+	Policy string `json:"policy"`
+
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// Report is the outcome of planning.
+type Report struct {
+	Actions []Action `json:"actions"`
+
+	// Datasets counts the datasets in collections with a policy
+	Datasets int `json:"datasets"`
+}
+
+// Count returns how many versions r archives or deletes, by action,
+// and their bytes.
+func (r *Report) Count(action string) (versions int, bytes int64) {
+	for _, a := range r.Actions {
+		if a.Action == action {
+			versions++
+			bytes += a.Bytes
+		}
+	}
+	return versions, bytes
+}
+
+// Pruner applies version retention policies.
+type Pruner struct {
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Collections holds the policies
+	Collections *collection.Registry
+
+	// Objects archives and deletes the files
+	Objects ObjectStore
+
+	// StorageClass is the class archived files move to;
+	// DefaultStorageClass if empty
+	StorageClass string
+
+	// PIDs re-registers the version DOIs of deleted versions; skipped
+	// if nil
+	PIDs PIDUpdater
+
+	// Pages republishes landing pages listing the pruned versions;
+	// skipped if nil
+	Pages PagePublisher
+
+	// Log records pruned versions; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Plan returns the versions due to be archived or deleted. Nothing is
+// changed.
+func (p *Pruner) Plan(ctx context.Context) (*Report, error) {
+	all, err := p.Collections.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[string]*collection.VersionPolicy)
+	for _, c := range all {
+		if c.Versions != nil {
+			policies[c.ID] = c.Versions
+		}
+	}
+	datasets, err := p.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{Actions: []Action{}}
+	for _, d := range datasets {
+		policy := policies[d.Collection]
+		if policy == nil || d.State != dataset.StatePublished {
+			continue
+		}
+		r.Datasets++
+		for age, v := range published(d) {
+			action := policy.Action(age)
+			switch {
+			case action == "", v.Removed(), action == dataset.PruneArchive && v.Archived():
+				continue
+			}
+			a := Action{Dataset: d.ID, Collection: d.Collection, Version: v.Number, DOI: v.DOI, Action: action, Policy: policy.String(), Files: len(v.Files)}
+			for _, f := range v.Files {
+				a.Bytes += f.Size
+			}
+			r.Actions = append(r.Actions, a)
+		}
+	}
+	return r, nil
+}
+
+// published returns d's published versions, newest first.
+func published(d *dataset.Dataset) []*dataset.Version {
+	var out []*dataset.Version
+	for i := range d.Versions {
+		if d.Versions[i].PublishedAt != nil {
+			out = append(out, &d.Versions[i])
+		}
+	}
+	slices.SortFunc(out, func(a, b *dataset.Version) int { return cmp.Compare(b.Number, a.Number) })
+	return out
+}
+
+// Apply archives or deletes the files of the versions in r and records
+// each version's pruning, returning how many versions were pruned.
+// Manifests are re-read first so that objects an online version refers
+// to by then are kept. Each dataset is saved before its version DOIs
+// and landing page are updated; failures to update those are reported
+// with how to retry, after the remaining datasets are pruned.
+func (p *Pruner) Apply(ctx context.Context, r *Report) (int, error) {
+	planned := make(map[string]map[int]string)
+	for _, a := range r.Actions {
+		if planned[a.Dataset] == nil {
+			planned[a.Dataset] = make(map[int]string)
+		}
+		planned[a.Dataset][a.Version] = a.Action
+	}
+	online, kept, err := p.references(ctx, planned)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	var errs []error
+	for _, id := range slices.Sorted(maps.Keys(planned)) {
+		d, err := p.Datasets.Get(ctx, id)
+		if err != nil {
+			return pruned, err
+		}
+		var removed bool
+		for _, n := range slices.Sorted(maps.Keys(planned[id])) {
+			v, action := d.Version(n), planned[id][n]
+			if v == nil || v.PublishedAt == nil || v.Removed() || v == d.Current() {
+				continue
+			}
+			for _, loc := range locations(v) {
+				if action == dataset.PruneArchive && !online[loc] {
+					err = p.archive(ctx, loc)
+				} else if action == dataset.PruneDelete && !kept[loc] {
+					err = p.Objects.DeleteObject(ctx, loc.Bucket, loc.Key)
+				}
+				if err != nil {
+					return pruned, fmt.Errorf("failed to %s %s of %s v%d: %w", action, loc, d.ID, n, err)
+				}
+			}
+			if err := p.prune(ctx, d, v, action, r.policy(id)); err != nil {
+				return pruned, err
+			}
+			removed = removed || action == dataset.PruneDelete
+			pruned++
+		}
+		errs = append(errs, p.announce(ctx, d, removed))
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// policy returns the policy described in r for dataset id.
+func (r *Report) policy(id string) string {
+	for _, a := range r.Actions {
+		if a.Dataset == id {
+			return a.Policy
+		}
+	}
+	return ""
+}
+
+// references returns the objects referred to by versions that stay
+// online, and by versions that keep their files, once the planned
+// actions are applied.
+func (p *Pruner) references(ctx context.Context, planned map[string]map[int]string) (online, kept map[storage.Location]bool, err error) {
+	datasets, err := p.Datasets.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	online, kept = make(map[storage.Location]bool), make(map[storage.Location]bool)
+	for _, d := range datasets {
+		for i := range d.Versions {
+			v := &d.Versions[i]
+			action := planned[d.ID][v.Number]
+			if v.Pruned != nil && action == "" {
+				action = v.Pruned.Action
+			}
+			if action == dataset.PruneDelete {
+				continue
+			}
+			for _, loc := range locations(v) {
+				kept[loc] = true
+				if action == "" {
+					online[loc] = true
+				}
+			}
+		}
+	}
+	return online, kept, nil
+}
+
+// locations returns the objects storing the files of v and their
+// preservation copies.
+func locations(v *dataset.Version) []storage.Location {
+	var out []storage.Location
+	for _, f := range v.Files {
+		out = append(out, storage.Location{Bucket: f.Bucket, Key: f.Key})
+		for _, dv := range f.Derivatives {
+			out = append(out, storage.Location{Bucket: dv.Bucket, Key: dv.Key})
+		}
+	}
+	return out
+}
+
+// archive moves the object at loc to archival storage unless it is
+// there already.
+func (p *Pruner) archive(ctx context.Context, loc storage.Location) error {
+	class := cmp.Or(p.StorageClass, DefaultStorageClass)
+	info, err := p.Objects.HeadObject(ctx, loc.Bucket, loc.Key)
+	if err != nil || info.StorageClass == class {
+		return err
+	}
+	return p.Objects.SetStorageClass(ctx, loc.Bucket, loc.Key, class)
+}
+
+// prune records that v's files were archived or deleted and saves d.
+func (p *Pruner) prune(ctx context.Context, d *dataset.Dataset, v *dataset.Version, action, policy string) error {
+	now := p.now().UTC()
+	v.Pruned = &dataset.Pruning{Action: action, Time: now, Policy: policy}
+	details := map[string]string{"version": fmt.Sprint(v.Number), "action": action, "policy": policy, "files": fmt.Sprint(len(v.Files))}
+	if v.DOI != "" {
+		details["doi"] = v.DOI
+	}
+	d.History = append(d.History, dataset.Event{
+		Time:    now,
+		Actor:   identity.FromContext(ctx).String(),
+		Action:  "version.prune",
+		Details: details,
+	})
+	if err := p.Datasets.Put(ctx, d); err != nil {
+		return err
+	}
+	if p.Log == nil {
+		return nil
+	}
+	return audit.Record(ctx, p.Log, "version.prune", d.ID, details)
+}
+
+// announce re-registers the version DOIs of d if versions were deleted,
+// so that they resolve to their tombstones, and republishes its landing
+// page.
+func (p *Pruner) announce(ctx context.Context, d *dataset.Dataset, removed bool) error {
+	var errs []error
+	if p.PIDs != nil && removed && d.DOI != "" {
+		if err := p.PIDs.Update(ctx, d); err != nil {
+			errs = append(errs, fmt.Errorf("%s is pruned, but %w; retry with 'aperture pid update %s'", d.ID, err, d.ID))
+		}
+	}
+	if p.Pages != nil {
+		if _, err := p.Pages.Publish(ctx, d.ID); err != nil {
+			errs = append(errs, fmt.Errorf("%s is pruned, but %w; retry with 'aperture pages rebuild'", d.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Pruner) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
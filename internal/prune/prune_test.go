@@ -0,0 +1,166 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prune
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// fakeObjects is an in-memory ObjectStore keyed by bucket/key, holding
+// each object's storage class.
+type fakeObjects map[string]string
+
+func (f fakeObjects) HeadObject(_ context.Context, bucket, key string) (s3.ObjectInfo, error) {
+	class, ok := f[bucket+"/"+key]
+	if !ok {
+		return s3.ObjectInfo{}, s3.ErrNotFound
+	}
+	return s3.ObjectInfo{Key: key, StorageClass: class}, nil
+}
+
+func (f fakeObjects) SetStorageClass(_ context.Context, bucket, key, class string) error {
+	if _, ok := f[bucket+"/"+key]; !ok {
+		return s3.ErrNotFound
+	}
+	f[bucket+"/"+key] = class
+	return nil
+}
+
+func (f fakeObjects) DeleteObject(_ context.Context, bucket, key string) error {
+	delete(f, bucket+"/"+key)
+	return nil
+}
+
+type fakePIDs []string
+
+func (f *fakePIDs) Update(_ context.Context, d *dataset.Dataset) error {
+	*f = append(*f, d.ID)
+	return nil
+}
+
+// version returns published version n of a dataset, holding the
+// content-addressed objects keys.
+func version(n int, keys ...string) dataset.Version {
+	published := time.Date(2025, 1, n, 0, 0, 0, 0, time.UTC)
+	v := dataset.Version{Number: n, PublishedAt: &published, DOI: "10.5555/ds-1.v" + string(rune('0'+n))}
+	for _, k := range keys {
+		v.Files = append(v.Files, dataset.File{Path: k + ".csv", Bucket: "repo", Key: "cas/" + k, Size: 10})
+	}
+	return v
+}
+
+func TestPrune(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "root@uni.edu", Groups: []string{authz.AdminGroup}})
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	collections := &collection.Registry{State: s, Datasets: datasets}
+	if _, err := collections.Create(ctx, collection.Collection{ID: "geo", Kind: collection.KindCommunity, Name: "Geo"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collections.Create(ctx, collection.Collection{ID: "sensors", Kind: collection.KindCollection, Name: "Sensors", Parent: "geo",
+		Versions: &collection.VersionPolicy{Online: 2, Archived: 1, Delete: true}}); err != nil {
+		t.Fatal(err)
+	}
+	// Every version shares object a; b is shared by versions 1 and 2.
+	d := &dataset.Dataset{ID: "ds-1", DOI: "10.5555/ds-1", State: dataset.StatePublished, Collection: "sensors",
+		Versions: []dataset.Version{version(1, "a", "b"), version(2, "a", "b", "c"), version(3, "a", "d"), version(4, "a", "e")}}
+	other := &dataset.Dataset{ID: "ds-2", State: dataset.StatePublished,
+		Versions: []dataset.Version{version(1, "x"), version(2, "y")}}
+	for _, d := range []*dataset.Dataset{d, other} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	objects := fakeObjects{}
+	for _, k := range []string{"a", "b", "c", "d", "e", "x", "y"} {
+		objects["repo/cas/"+k] = "STANDARD"
+	}
+	var pids fakePIDs
+	log := &audit.MemoryLog{}
+	p := &Pruner{Datasets: datasets, Collections: collections, Objects: objects, PIDs: &pids, Log: log,
+		Now: func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) }}
+
+	r, err := p.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if r.Datasets != 1 || len(r.Actions) != 2 {
+		t.Fatalf("Plan() = %+v", r)
+	}
+	if a := r.Actions; a[0].Version != 2 || a[0].Action != dataset.PruneArchive || a[1].Version != 1 || a[1].Action != dataset.PruneDelete || a[1].Bytes != 20 {
+		t.Errorf("actions = %+v", a)
+	}
+	if n, bytes := r.Count(dataset.PruneArchive); n != 1 || bytes != 30 {
+		t.Errorf("Count(archive) = %d, %d", n, bytes)
+	}
+
+	n, err := p.Apply(ctx, r)
+	if err != nil || n != 2 {
+		t.Fatalf("Apply() = %d, %v", n, err)
+	}
+	// Objects of online versions stay; b is archived with version 2,
+	// not deleted with version 1.
+	want := map[string]string{"repo/cas/a": "STANDARD", "repo/cas/b": DefaultStorageClass, "repo/cas/c": DefaultStorageClass,
+		"repo/cas/d": "STANDARD", "repo/cas/e": "STANDARD", "repo/cas/x": "STANDARD", "repo/cas/y": "STANDARD"}
+	if len(objects) != len(want) {
+		t.Errorf("objects = %v, want %v", objects, want)
+	}
+	for k, class := range want {
+		if objects[k] != class {
+			t.Errorf("%s storage class = %q, want %q", k, objects[k], class)
+		}
+	}
+	d, err = datasets.Get(ctx, "ds-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Version(1).Removed() || !d.Version(2).Archived() || d.Version(3).Pruned != nil || d.Version(1).Pruned.Policy != "2 online, 1 archived, older deleted" {
+		t.Errorf("versions = %+v", d.Versions)
+	}
+	entries, _ := log.Entries(ctx)
+	if len(d.History) != 2 || d.History[0].Action != "version.prune" || len(entries) != 2 || len(pids) != 1 {
+		t.Errorf("history = %+v, audit = %d, pid updates = %v", d.History, len(entries), pids)
+	}
+
+	// Pruned versions are not planned again, and a new version moves
+	// the rest along.
+	if r, err := p.Plan(ctx); err != nil || len(r.Actions) != 0 {
+		t.Errorf("second Plan() = %+v, %v", r, err)
+	}
+	d.Versions = append(d.Versions, version(5, "a"))
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	r, err = p.Plan(ctx)
+	if err != nil || len(r.Actions) != 2 || r.Actions[0].Version != 3 || r.Actions[1].Version != 2 || r.Actions[1].Action != dataset.PruneDelete {
+		t.Fatalf("Plan() after a new version = %+v, %v", r, err)
+	}
+	if _, err := p.Apply(ctx, r); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if _, ok := objects["repo/cas/b"]; ok || objects["repo/cas/d"] != DefaultStorageClass || objects["repo/cas/a"] != "STANDARD" {
+		t.Errorf("objects = %v", objects)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// queued is a message in a Local queue.
+type queued struct {
+	Body     []byte    `json:"body"`
+	Sent     time.Time `json:"sent"`
+	Receives int       `json:"receives"`
+
+	// VisibleAt is when the message may next be received
+	VisibleAt time.Time `json:"visibleAt"`
+}
+
+// Local is a queue kept in a state store: in memory, for tests and a
+// single process, or in the file store, shared by the CLI and one
+// worker on the same machine. Each topic is a table of messages keyed
+// in the order they were sent.
+type Local struct {
+	State state.Store
+
+	// Visibility is how long a received message is hidden;
+	// DefaultVisibility if zero
+	Visibility time.Duration
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewMemory returns a queue kept in memory.
+func NewMemory() *Local {
+	return &Local{State: state.NewMemoryStore()}
+}
+
+// table returns the table of topic's messages.
+func table(topic string) string {
+	return "queue-" + topic
+}
+
+// deadTable returns the table of topic's dead-lettered messages.
+func deadTable(topic string) string {
+	return "queue-" + topic + "-dead"
+}
+
+// Send implements Queue.
+func (q *Local) Send(ctx context.Context, topic string, body []byte) error {
+	now := q.now().UTC()
+	var suffix [4]byte
+	rand.Read(suffix[:])
+	id := fmt.Sprintf("%020d-%s", now.UnixNano(), hex.EncodeToString(suffix[:]))
+	return q.State.Put(ctx, table(topic), id, queued{Body: body, Sent: now, VisibleAt: now})
+}
+
+// Receive implements Queue.
+func (q *Local) Receive(ctx context.Context, topic string, max int) ([]Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	keys, err := q.State.Keys(ctx, table(topic))
+	if err != nil {
+		return nil, err
+	}
+	now := q.now().UTC()
+	visibility := q.Visibility
+	if visibility == 0 {
+		visibility = DefaultVisibility
+	}
+	var msgs []Message
+	for _, key := range keys {
+		if len(msgs) == max {
+			break
+		}
+		var m queued
+		err := q.State.Get(ctx, table(topic), key, &m)
+		switch {
+		case errors.Is(err, state.ErrNotFound):
+			continue
+		case err != nil:
+			return nil, err
+		case m.VisibleAt.After(now):
+			continue
+		}
+		m.Receives++
+		m.VisibleAt = now.Add(visibility)
+		if err := q.State.Put(ctx, table(topic), key, m); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, Message{ID: key, Topic: topic, Body: m.Body, Receives: m.Receives, receipt: strconv.Itoa(m.Receives)})
+	}
+	return msgs, nil
+}
+
+// current returns the stored message m was received as, or nil if it
+// was deleted or received again since.
+func (q *Local) current(ctx context.Context, m Message) (*queued, error) {
+	var stored queued
+	err := q.State.Get(ctx, table(m.Topic), m.ID, &stored)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if strconv.Itoa(stored.Receives) != m.receipt {
+		return nil, nil
+	}
+	return &stored, nil
+}
+
+// Delete implements Queue. A message received again since m was is
+// left for its new receiver.
+func (q *Local) Delete(ctx context.Context, m Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stored, err := q.current(ctx, m)
+	if err != nil || stored == nil {
+		return err
+	}
+	return q.State.Delete(ctx, table(m.Topic), m.ID)
+}
+
+// DeadLetter implements Queue.
+func (q *Local) DeadLetter(ctx context.Context, m Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stored, err := q.current(ctx, m)
+	if err != nil || stored == nil {
+		return err
+	}
+	if err := q.State.Put(ctx, deadTable(m.Topic), m.ID, stored); err != nil {
+		return err
+	}
+	return q.State.Delete(ctx, table(m.Topic), m.ID)
+}
+
+// Depth implements Queue.
+func (q *Local) Depth(ctx context.Context, topic string) (int, error) {
+	all, err := state.List[queued](ctx, q.State, table(topic))
+	if err != nil {
+		return 0, err
+	}
+	now := q.now()
+	n := 0
+	for _, m := range all {
+		if !m.VisibleAt.After(now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (q *Local) now() time.Time {
+	if q.Now != nil {
+		return q.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,213 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package queue hands background work from the requests that cause it
+// to workers: search indexing, notification delivery, and the ingest of
+// access logs as they are written. In AWS the queues are Amazon SQS
+// queues, one per topic; in development they live in the state store,
+// or in memory, so that the same workers run against LocalStack or
+// without any AWS services at all.
+//
+// Messages are delivered at least once. A message whose handler fails,
+// or that is not deleted before its visibility timeout passes, is
+// delivered again, until it has been received MaxReceives times; it is
+// then moved to the topic's dead-letter queue. Handlers must therefore
+// be safe to run more than once for the same message.
+package queue
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/metrics"
+)
+
+// Topics of background work.
+const (
+	// TopicIndex carries changed dataset IDs for the search index
+	TopicIndex = "index"
+
+	// TopicNotify carries notifications to deliver
+	TopicNotify = "notify"
+
+	// TopicIngest carries S3 event notifications of access logs written
+	// to the logs bucket
+	TopicIngest = "ingest"
+)
+
+// Topics lists every topic.
+var Topics = []string{TopicIndex, TopicNotify, TopicIngest}
+
+// DefaultVisibility is how long a received message is hidden from
+// other receivers by default.
+const DefaultVisibility = 5 * time.Minute
+
+// DefaultMaxReceives is how many times a message is received by default
+// before it is moved to the dead-letter queue.
+const DefaultMaxReceives = 5
+
+// batch is the most messages received at once.
+const batch = 10
+
+// Message is a received message.
+type Message struct {
+	ID    string `json:"id"`
+	Topic string `json:"topic"`
+	Body  []byte `json:"body"`
+
+	// Receives is how many times the message was received, this time
+	// included
+	Receives int `json:"receives"`
+
+	// receipt identifies this receipt of the message
+	receipt string
+}
+
+// Queue is a set of work queues, one per topic.
+type Queue interface {
+	// Send adds a message with body to topic.
+	Send(ctx context.Context, topic string, body []byte) error
+
+	// Receive returns up to max messages of topic, hiding them from
+	// other receivers until they are deleted or their visibility
+	// timeout passes. It returns none if none are waiting.
+	Receive(ctx context.Context, topic string, max int) ([]Message, error)
+
+	// Delete removes a handled message.
+	Delete(ctx context.Context, m Message) error
+
+	// DeadLetter moves a message that failed too often to the topic's
+	// dead-letter queue.
+	DeadLetter(ctx context.Context, m Message) error
+
+	// Depth returns the number of messages of topic waiting to be
+	// received.
+	Depth(ctx context.Context, topic string) (int, error)
+}
+
+// Handler handles the body of a message.
+type Handler func(ctx context.Context, body []byte) error
+
+// Result counts the messages a worker handled.
+type Result struct {
+	Handled int `json:"handled"`
+
+	// Failed counts the messages whose handler failed, to be received
+	// again
+	Failed int `json:"failed"`
+
+	// DeadLettered counts the messages moved to a dead-letter queue
+	DeadLettered int `json:"deadLettered"`
+}
+
+// Worker receives the messages of topics and hands them to their
+// handlers.
+type Worker struct {
+	Queue Queue
+
+	// Handlers maps topics to their handlers
+	Handlers map[string]Handler
+
+	// MaxReceives is how many times a message is received before it
+	// is moved to the dead-letter queue; DefaultMaxReceives if zero
+	MaxReceives int
+
+	// Metrics records the depth of each queue after each pass;
+	// skipped if nil
+	Metrics metrics.Recorder
+
+	// OnError is told of each failed message; skipped if nil
+	OnError func(error)
+}
+
+// Drain handles the messages of every topic until none are left.
+func (w *Worker) Drain(ctx context.Context) (Result, error) {
+	var res Result
+	for _, topic := range slices.Sorted(maps.Keys(w.Handlers)) {
+		for {
+			if err := ctx.Err(); err != nil {
+				return res, err
+			}
+			msgs, err := w.Queue.Receive(ctx, topic, batch)
+			if err != nil {
+				return res, fmt.Errorf("failed to receive %s messages: %w", topic, err)
+			}
+			if len(msgs) == 0 {
+				break
+			}
+			for _, m := range msgs {
+				if err := w.handle(ctx, m, &res); err != nil {
+					return res, err
+				}
+			}
+		}
+		if w.Metrics != nil {
+			if depth, err := w.Queue.Depth(ctx, topic); err == nil {
+				w.Metrics.Record(metrics.Dimensions{"Queue": topic}, metrics.Metric{Name: metrics.QueueDepth, Value: float64(depth), Unit: metrics.Count})
+			}
+		}
+	}
+	return res, nil
+}
+
+// handle hands m to its handler and deletes it if it was handled,
+// returning only errors of the queue itself.
+func (w *Worker) handle(ctx context.Context, m Message, res *Result) error {
+	err := w.Handlers[m.Topic](ctx, m.Body)
+	if err == nil {
+		res.Handled++
+		return w.Queue.Delete(ctx, m)
+	}
+	res.Failed++
+	maxReceives := w.MaxReceives
+	if maxReceives == 0 {
+		maxReceives = DefaultMaxReceives
+	}
+	if m.Receives >= maxReceives {
+		res.DeadLettered++
+		err = fmt.Errorf("%w; moved to the dead-letter queue after %d attempts", err, m.Receives)
+		if dlErr := w.Queue.DeadLetter(ctx, m); dlErr != nil {
+			return dlErr
+		}
+	}
+	if w.OnError != nil {
+		w.OnError(fmt.Errorf("%s message %s: %w", m.Topic, m.ID, err))
+	}
+	return nil
+}
+
+// Run drains the queues until ctx is done, pausing for idle between
+// passes that found nothing to do.
+func (w *Worker) Run(ctx context.Context, idle time.Duration) error {
+	for {
+		res, err := w.Drain(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if res != (Result{}) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(idle):
+		}
+	}
+}
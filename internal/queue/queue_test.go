@@ -0,0 +1,121 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	q := &Local{State: state.NewMemoryStore(), Visibility: time.Minute, Now: func() time.Time { return now }}
+	for _, body := range []string{"a", "b", "c"} {
+		if err := q.Send(ctx, TopicIndex, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Millisecond)
+	}
+	if n, err := q.Depth(ctx, TopicIndex); err != nil || n != 3 {
+		t.Errorf("Depth() = %d, %v", n, err)
+	}
+
+	// Messages arrive in order and are hidden once received.
+	msgs, err := q.Receive(ctx, TopicIndex, 2)
+	if err != nil || len(msgs) != 2 || string(msgs[0].Body) != "a" || string(msgs[1].Body) != "b" || msgs[0].Receives != 1 {
+		t.Fatalf("Receive() = %+v, %v", msgs, err)
+	}
+	if rest, _ := q.Receive(ctx, TopicIndex, 10); len(rest) != 1 || string(rest[0].Body) != "c" {
+		t.Errorf("second Receive() = %+v", rest)
+	}
+	if err := q.Delete(ctx, msgs[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// An undeleted message is delivered again after its visibility
+	// timeout, and the earlier receipt can no longer delete it.
+	now = now.Add(2 * time.Minute)
+	again, err := q.Receive(ctx, TopicIndex, 10)
+	if err != nil || len(again) != 2 || string(again[0].Body) != "b" || again[0].Receives != 2 {
+		t.Fatalf("Receive() after timeout = %+v, %v", again, err)
+	}
+	if err := q.Delete(ctx, msgs[1]); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := q.State.Keys(ctx, table(TopicIndex)); len(n) != 2 {
+		t.Errorf("stale receipt deleted a redelivered message: %v left", n)
+	}
+	if err := q.DeadLetter(ctx, again[0]); err != nil {
+		t.Fatal(err)
+	}
+	if dead, _ := q.State.Keys(ctx, deadTable(TopicIndex)); len(dead) != 1 {
+		t.Errorf("dead letters = %v", dead)
+	}
+}
+
+func TestWorker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	q := &Local{State: state.NewMemoryStore(), Visibility: time.Minute, Now: func() time.Time { return now }}
+	var delivered []string
+	var failures []error
+	w := &Worker{
+		Queue: q,
+		Handlers: map[string]Handler{
+			TopicNotify: func(_ context.Context, body []byte) error {
+				if string(body) == "bad" {
+					return errors.New("relay refused")
+				}
+				delivered = append(delivered, string(body))
+				return nil
+			},
+		},
+		MaxReceives: 2,
+		OnError:     func(err error) { failures = append(failures, err) },
+	}
+	for _, body := range []string{"hello", "bad"} {
+		if err := q.Send(ctx, TopicNotify, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := w.Drain(ctx)
+	if err != nil || res != (Result{Handled: 1, Failed: 1}) || fmt.Sprint(delivered) != "[hello]" {
+		t.Errorf("Drain() = %+v, %v; delivered %v", res, err, delivered)
+	}
+	if n, _ := q.Depth(ctx, TopicNotify); n != 0 {
+		t.Errorf("Depth() while the failure is hidden = %d", n)
+	}
+
+	// The failed message is retried after its visibility timeout and
+	// dead-lettered once it has been received MaxReceives times.
+	now = now.Add(2 * time.Minute)
+	res, err = w.Drain(ctx)
+	if err != nil || res != (Result{Failed: 1, DeadLettered: 1}) {
+		t.Errorf("second Drain() = %+v, %v", res, err)
+	}
+	if keys, _ := q.State.Keys(ctx, table(TopicNotify)); len(keys) != 0 {
+		t.Errorf("messages left = %v", keys)
+	}
+	if len(failures) != 2 {
+		t.Errorf("failures = %v", failures)
+	}
+}
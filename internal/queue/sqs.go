@@ -0,0 +1,114 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/sqs"
+)
+
+// SQS is a queue of Amazon SQS queues, one per topic, named for the
+// topic after Prefix, such as aperture-prod-index. Each queue's redrive
+// policy moves messages received too often to its dead-letter queue.
+type SQS struct {
+	Client *sqs.Client
+
+	// Prefix prefixes the queue names
+	Prefix string
+
+	// Wait is how long Receive waits for a message to arrive; it
+	// returns at once if zero
+	Wait time.Duration
+
+	mu   sync.Mutex
+	urls map[string]string
+}
+
+// QueueName returns the name of the SQS queue of topic.
+func QueueName(prefix, topic string) string {
+	return prefix + "-" + topic
+}
+
+// url returns the URL of the queue of topic.
+func (q *SQS) url(ctx context.Context, topic string) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if url, ok := q.urls[topic]; ok {
+		return url, nil
+	}
+	url, err := q.Client.QueueURL(ctx, QueueName(q.Prefix, topic))
+	if err != nil {
+		return "", err
+	}
+	if q.urls == nil {
+		q.urls = make(map[string]string)
+	}
+	q.urls[topic] = url
+	return url, nil
+}
+
+// Send implements Queue.
+func (q *SQS) Send(ctx context.Context, topic string, body []byte) error {
+	url, err := q.url(ctx, topic)
+	if err != nil {
+		return err
+	}
+	_, err = q.Client.SendMessage(ctx, url, string(body))
+	return err
+}
+
+// Receive implements Queue.
+func (q *SQS) Receive(ctx context.Context, topic string, max int) ([]Message, error) {
+	url, err := q.url(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	received, err := q.Client.ReceiveMessage(ctx, url, max, q.Wait, 0)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]Message, 0, len(received))
+	for _, m := range received {
+		msgs = append(msgs, Message{ID: m.ID, Topic: topic, Body: []byte(m.Body), Receives: m.ReceiveCount, receipt: m.ReceiptHandle})
+	}
+	return msgs, nil
+}
+
+// Delete implements Queue.
+func (q *SQS) Delete(ctx context.Context, m Message) error {
+	url, err := q.url(ctx, m.Topic)
+	if err != nil {
+		return err
+	}
+	return q.Client.DeleteMessage(ctx, url, m.receipt)
+}
+
+// DeadLetter implements Queue. The message is left for the queue's
+// redrive policy, which moves it when it is next received.
+func (q *SQS) DeadLetter(context.Context, Message) error {
+	return nil
+}
+
+// Depth implements Queue.
+func (q *SQS) Depth(ctx context.Context, topic string) (int, error) {
+	url, err := q.url(ctx, topic)
+	if err != nil {
+		return 0, err
+	}
+	return q.Client.ApproximateDepth(ctx, url)
+}
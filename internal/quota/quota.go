@@ -0,0 +1,302 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package quota limits how much restricted data one user can download
+// per day.
+//
+// Each issued download URL is charged against the user's usage for the
+// current UTC day before it is returned, so a single account cannot
+// bulk-download a controlled collection. Usage is kept either in a
+// state.Store, for a single process, or in the download quotas
+// DynamoDB table, where each charge is one conditional update so that
+// concurrent presigned URL functions cannot together exceed the
+// limits.
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dynamodb"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// usageTable holds one Usage per user and day.
+const usageTable = "download-usage"
+
+// Default limits.
+const (
+	// DefaultBytes is the default daily byte limit (50 GiB)
+	DefaultBytes int64 = 50 << 30
+
+	// DefaultObjects is the default daily object limit
+	DefaultObjects = 1000
+)
+
+// ErrExceeded is returned when a download would exceed the quota.
+var ErrExceeded = errors.New("download quota exceeded")
+
+// Limits caps daily downloads. A zero field means no limit.
+type Limits struct {
+	// Bytes is the maximum bytes issued per day
+	Bytes int64
+
+	// Objects is the maximum objects issued per day
+	Objects int
+}
+
+// Usage is what a user has been issued on one day.
+type Usage struct {
+	User    string `json:"user"`
+	Day     string `json:"day"`
+	Bytes   int64  `json:"bytes"`
+	Objects int    `json:"objects"`
+}
+
+// Tracker records usage and enforces limits.
+type Tracker struct {
+	c      counter
+	limits Limits
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// counter keeps usage.
+type counter interface {
+	// get returns user's usage on day, which is zero if nothing has
+	// been recorded.
+	get(ctx context.Context, user, day string) (*Usage, error)
+
+	// add adds one object of size bytes to user's usage on day and
+	// returns the new usage. It returns ErrExceeded, and records
+	// nothing, if the new usage would exceed limits.
+	add(ctx context.Context, user, day string, size int64, limits Limits) (*Usage, error)
+}
+
+// NewTracker returns a tracker enforcing limits with usage stored in s.
+// Charges are serialized within this process only.
+func NewTracker(s state.Store, limits Limits) *Tracker {
+	return &Tracker{c: &storeCounter{s: s}, limits: limits}
+}
+
+// NewTableTracker returns a tracker enforcing limits with usage kept in
+// the download quotas table, whose hash key is user_id and range key
+// day. Charges are atomic across processes.
+func NewTableTracker(c *dynamodb.Client, table string, limits Limits) *Tracker {
+	return &Tracker{c: &tableCounter{c: c, table: table}, limits: limits}
+}
+
+// Limits returns the enforced limits.
+func (t *Tracker) Limits() Limits {
+	return t.limits
+}
+
+// Charge adds one object of size bytes to user's usage for today. It
+// returns an error wrapping ErrExceeded, and records nothing, if that
+// would exceed the limits.
+func (t *Tracker) Charge(ctx context.Context, user string, size int64) (*Usage, error) {
+	if _, err := t.Check(ctx, user, size); err != nil {
+		return nil, err
+	}
+	u, err := t.c.add(ctx, user, t.today(), size, t.limits)
+	if errors.Is(err, ErrExceeded) {
+		// Another charge got there first; report the usage it left
+		if _, err := t.Check(ctx, user, size); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %s has reached the download limits for today (UTC)", ErrExceeded, user)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Check returns user's usage for today, or an error wrapping
+// ErrExceeded if one more object of size bytes would exceed the limits.
+// Unlike Charge, it records nothing.
+func (t *Tracker) Check(ctx context.Context, user string, size int64) (*Usage, error) {
+	u, err := t.Usage(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if t.limits.Objects > 0 && u.Objects+1 > t.limits.Objects {
+		return nil, fmt.Errorf("%w: %s has downloaded %d of %d objects allowed today (UTC)",
+			ErrExceeded, user, u.Objects, t.limits.Objects)
+	}
+	if t.limits.Bytes > 0 && u.Bytes+size > t.limits.Bytes {
+		return nil, fmt.Errorf("%w: %s has downloaded %d of %d bytes allowed today (UTC) and this file is %d bytes",
+			ErrExceeded, user, u.Bytes, t.limits.Bytes, size)
+	}
+	return u, nil
+}
+
+// Usage returns user's usage for today.
+func (t *Tracker) Usage(ctx context.Context, user string) (*Usage, error) {
+	return t.c.get(ctx, user, t.today())
+}
+
+// today returns the current UTC day.
+func (t *Tracker) today() string {
+	return t.now().UTC().Format(time.DateOnly)
+}
+
+func (t *Tracker) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+	return time.Now()
+}
+
+// exceeds reports whether usage of bytes and objects is over limits.
+func (l Limits) exceeds(bytes int64, objects int) bool {
+	return (l.Objects > 0 && objects > l.Objects) || (l.Bytes > 0 && bytes > l.Bytes)
+}
+
+// storeCounter keeps usage in a state.Store.
+type storeCounter struct {
+	s state.Store
+
+	// mu serializes charges so that concurrent requests in this
+	// process cannot both fit under the limit
+	mu sync.Mutex
+}
+
+func (c *storeCounter) get(ctx context.Context, user, day string) (*Usage, error) {
+	u := &Usage{User: user, Day: day}
+	err := c.s.Get(ctx, usageTable, key(user, day), u)
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (c *storeCounter) add(ctx context.Context, user, day string, size int64, limits Limits) (*Usage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	u, err := c.get(ctx, user, day)
+	if err != nil {
+		return nil, err
+	}
+	if limits.exceeds(u.Bytes+size, u.Objects+1) {
+		return nil, ErrExceeded
+	}
+	u.Bytes += size
+	u.Objects++
+	if err := c.s.Put(ctx, usageTable, key(user, day), u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// key identifies a user's usage on a day.
+func key(user, day string) string {
+	return day + "/" + user
+}
+
+// Attributes of the download quotas table.
+const (
+	userAttr    = "user_id"
+	dayAttr     = "day"
+	bytesAttr   = "bytes"
+	objectsAttr = "objects"
+
+	// expiryAttr is the table's TTL attribute; usage is kept for a
+	// day after the day it counts
+	expiryAttr = "expiration_time"
+)
+
+// tableCounter keeps usage in the download quotas table.
+type tableCounter struct {
+	c     *dynamodb.Client
+	table string
+}
+
+func (c *tableCounter) get(ctx context.Context, user, day string) (*Usage, error) {
+	item, err := c.c.GetItem(ctx, c.table, itemKey(user, day))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read download usage of %s: %w", user, err)
+	}
+	return usageOf(user, day, item)
+}
+
+// add charges the usage with one update whose condition is that the
+// counters are still low enough to take it, so the check and the
+// charge cannot be interleaved with another charge.
+func (c *tableCounter) add(ctx context.Context, user, day string, size int64, limits Limits) (*Usage, error) {
+	if limits.exceeds(size, 1) {
+		return nil, ErrExceeded
+	}
+	start, err := time.Parse(time.DateOnly, day)
+	if err != nil {
+		return nil, err
+	}
+	u := dynamodb.Update{
+		Expression: "SET #e = :e ADD #b :size, #o :one",
+		Names:      map[string]string{"#b": bytesAttr, "#o": objectsAttr, "#e": expiryAttr},
+		Values: dynamodb.Item{
+			":size": {"N": strconv.FormatInt(size, 10)},
+			":one":  {"N": "1"},
+			":e":    {"N": strconv.FormatInt(start.AddDate(0, 0, 2).Unix(), 10)},
+		},
+	}
+	var conditions []string
+	if limits.Bytes > 0 {
+		conditions = append(conditions, "(attribute_not_exists(#b) OR #b <= :maxBytes)")
+		u.Values[":maxBytes"] = map[string]string{"N": strconv.FormatInt(limits.Bytes-size, 10)}
+	}
+	if limits.Objects > 0 {
+		conditions = append(conditions, "(attribute_not_exists(#o) OR #o < :maxObjects)")
+		u.Values[":maxObjects"] = map[string]string{"N": strconv.Itoa(limits.Objects)}
+	}
+	u.Condition = strings.Join(conditions, " AND ")
+
+	item, err := c.c.UpdateItem(ctx, c.table, itemKey(user, day), u)
+	if errors.Is(err, dynamodb.ErrConditionFailed) {
+		return nil, ErrExceeded
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to charge download usage of %s: %w", user, err)
+	}
+	return usageOf(user, day, item)
+}
+
+// itemKey returns the key of user's usage on day.
+func itemKey(user, day string) dynamodb.Item {
+	return dynamodb.Item{userAttr: {"S": user}, dayAttr: {"S": day}}
+}
+
+// usageOf decodes the usage in item, which is nil if none is recorded.
+func usageOf(user, day string, item dynamodb.Item) (*Usage, error) {
+	u := &Usage{User: user, Day: day}
+	var err error
+	if n := item[bytesAttr]["N"]; n != "" {
+		if u.Bytes, err = strconv.ParseInt(n, 10, 64); err != nil {
+			return nil, fmt.Errorf("corrupt download usage of %s: %w", user, err)
+		}
+	}
+	if n := item[objectsAttr]["N"]; n != "" {
+		if u.Objects, err = strconv.Atoi(n); err != nil {
+			return nil, fmt.Errorf("corrupt download usage of %s: %w", user, err)
+		}
+	}
+	return u, nil
+}
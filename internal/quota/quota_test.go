@@ -0,0 +1,189 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/dynamodb"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func TestCharge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 5, 1, 23, 0, 0, 0, time.UTC)
+	tr := NewTracker(state.NewMemoryStore(), Limits{Bytes: 100, Objects: 3})
+	tr.Now = func() time.Time { return now }
+
+	tests := []struct {
+		name    string
+		user    string
+		size    int64
+		wantErr bool
+	}{
+		{"first", "alice", 60, false},
+		{"too large", "alice", 41, true},
+		{"fits", "alice", 40, false},
+		{"other user", "bob", 100, false},
+		{"bytes exhausted", "alice", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tr.Charge(ctx, tt.user, tt.size)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrExceeded)) {
+				t.Errorf("Charge(%s, %d) error = %v, wantErr %v", tt.user, tt.size, err, tt.wantErr)
+			}
+		})
+	}
+
+	u, err := tr.Usage(ctx, "alice")
+	if err != nil || u.Bytes != 100 || u.Objects != 2 {
+		t.Errorf("Usage() = %+v, %v; want 100 bytes, 2 objects", u, err)
+	}
+
+	// Usage resets at midnight UTC.
+	now = now.Add(2 * time.Hour)
+	if _, err := tr.Charge(ctx, "alice", 100); err != nil {
+		t.Errorf("Charge() next day error = %v", err)
+	}
+
+	objects := NewTracker(state.NewMemoryStore(), Limits{Objects: 1})
+	if _, err := objects.Charge(ctx, "carol", 1<<40); err != nil {
+		t.Errorf("Charge() without byte limit error = %v", err)
+	}
+	if _, err := objects.Charge(ctx, "carol", 0); !errors.Is(err, ErrExceeded) {
+		t.Errorf("Charge() over object limit error = %v, want ErrExceeded", err)
+	}
+}
+
+// fakeQuotas serves GetItem and the conditional UpdateItem of
+// tableCounter against an in-memory download quotas table, applying
+// each update atomically as DynamoDB does.
+type fakeQuotas struct {
+	mu    sync.Mutex
+	items map[string]dynamodb.Item
+}
+
+func (f *fakeQuotas) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var in struct {
+		Key                       dynamodb.Item
+		ConditionExpression       string
+		ExpressionAttributeValues dynamodb.Item
+	}
+	json.NewDecoder(r.Body).Decode(&in)
+	id := in.Key[userAttr]["S"] + "/" + in.Key[dayAttr]["S"]
+	num := func(it dynamodb.Item, attr string) int64 {
+		n, _ := strconv.ParseInt(it[attr]["N"], 10, 64)
+		return n
+	}
+	item := f.items[id]
+
+	switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.") {
+	case "GetItem":
+		json.NewEncoder(w).Encode(map[string]any{"Item": item})
+	case "UpdateItem":
+		v := in.ExpressionAttributeValues
+		if (strings.Contains(in.ConditionExpression, ":maxBytes") && num(item, bytesAttr) > num(v, ":maxBytes")) ||
+			(strings.Contains(in.ConditionExpression, ":maxObjects") && num(item, objectsAttr) >= num(v, ":maxObjects")) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type": "com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException"}`))
+			return
+		}
+		item = dynamodb.Item{
+			userAttr:    in.Key[userAttr],
+			dayAttr:     in.Key[dayAttr],
+			bytesAttr:   {"N": strconv.FormatInt(num(item, bytesAttr)+num(v, ":size"), 10)},
+			objectsAttr: {"N": strconv.FormatInt(num(item, objectsAttr)+1, 10)},
+			expiryAttr:  v[":e"],
+		}
+		f.items[id] = item
+		json.NewEncoder(w).Encode(map[string]any{"Attributes": item})
+	default:
+		http.Error(w, `{"__type": "UnknownOperationException"}`, http.StatusBadRequest)
+	}
+}
+
+func TestTableTracker(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeQuotas{items: map[string]dynamodb.Item{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	c := dynamodb.NewClient(dynamodb.Options{
+		Region:      "us-east-1",
+		Endpoint:    srv.URL,
+		Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+	now := time.Date(2025, 5, 1, 23, 0, 0, 0, time.UTC)
+	tr := NewTableTracker(c, "aperture-download-quotas-dev", Limits{Bytes: 100, Objects: 5})
+	tr.Now = func() time.Time { return now }
+
+	// Concurrent charges, as from several presigned URL functions, fit
+	// under the limits together.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	charged := 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := tr.Charge(ctx, "alice", 30)
+			if err != nil && !errors.Is(err, ErrExceeded) {
+				t.Errorf("Charge() error = %v", err)
+			}
+			if err == nil {
+				mu.Lock()
+				charged++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if charged != 3 {
+		t.Errorf("%d concurrent charges of 30 bytes fit under 100, want 3", charged)
+	}
+	u, err := tr.Usage(ctx, "alice")
+	if err != nil || u.Bytes != 90 || u.Objects != 3 {
+		t.Errorf("Usage() = %+v, %v; want 90 bytes, 3 objects", u, err)
+	}
+	if exp := fake.items["alice/2025-05-01"][expiryAttr]["N"]; exp != strconv.FormatInt(now.Add(25*time.Hour).Unix(), 10) {
+		t.Errorf("expiration_time = %s, want the end of the next day", exp)
+	}
+
+	// The object limit applies independently of bytes.
+	for range 5 {
+		if _, err := tr.Charge(ctx, "bob", 0); err != nil {
+			t.Fatalf("Charge() error = %v", err)
+		}
+	}
+	if _, err := tr.Charge(ctx, "bob", 0); !errors.Is(err, ErrExceeded) {
+		t.Errorf("Charge() over object limit error = %v, want ErrExceeded", err)
+	}
+	if _, err := tr.Charge(ctx, "carol", 101); !errors.Is(err, ErrExceeded) {
+		t.Errorf("Charge() of a file over the byte limit error = %v, want ErrExceeded", err)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultURL is the RAiD demo API. Production deployments set
+// RAID_API_URL to their registration agency's endpoint.
+const DefaultURL = "https://api.demo.raid.org.au"
+
+// Vocabulary URIs used in related objects.
+const (
+	SchemaDOI          = "https://doi.org/"
+	TypeDataset        = "https://vocabulary.raid.org/relatedObject.type.schema/269"
+	TypeSchema         = "https://vocabulary.raid.org/relatedObject.type.schema/329"
+	CategoryOutput     = "https://vocabulary.raid.org/relatedObject.category.id/190"
+	CategorySchema     = "https://vocabulary.raid.org/relatedObject.category.schemaUri/386"
+	relatedObjectField = "relatedObject"
+)
+
+// Options configures a Client.
+type Options struct {
+	// BaseURL is the registration agency's API root
+	BaseURL string
+
+	// Token is the bearer token of the service point account
+	Token string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is a RAiD API client.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(opts.BaseURL, "/"),
+		token:   opts.Token,
+		http:    opts.HTTPClient,
+	}
+	if c.baseURL == "" {
+		c.baseURL = DefaultURL
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Record is a RAiD record. It is kept as decoded JSON so that updates
+// preserve the fields Aperture does not manage.
+type Record map[string]any
+
+// RelatedObject is an output, input, or other object linked to a RAiD.
+type RelatedObject struct {
+	ID        string       `json:"id"`
+	SchemaURI string       `json:"schemaUri"`
+	Type      Vocabulary   `json:"type"`
+	Category  []Vocabulary `json:"category"`
+}
+
+// Vocabulary is a controlled vocabulary term.
+type Vocabulary struct {
+	ID        string `json:"id"`
+	SchemaURI string `json:"schemaUri"`
+}
+
+// APIError is returned for non-2xx responses.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error implements error.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("raid: HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// Get returns the record of handle.
+func (c *Client) Get(ctx context.Context, handle string) (Record, error) {
+	var rec Record
+	if err := c.do(ctx, http.MethodGet, "/raid/"+handle, nil, &rec); err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, fmt.Errorf("raid: empty record for %s", handle)
+	}
+	return rec, nil
+}
+
+// Update replaces the record of handle.
+func (c *Client) Update(ctx context.Context, handle string, rec Record) error {
+	return c.do(ctx, http.MethodPut, "/raid/"+handle, rec, nil)
+}
+
+// AddDataset lists the dataset with doi as an output of handle, and
+// reports whether the record changed.
+func (c *Client) AddDataset(ctx context.Context, handle, doi string) (bool, error) {
+	rec, err := c.Get(ctx, handle)
+	if err != nil {
+		return false, err
+	}
+	id := SchemaDOI + doi
+	objects, _ := rec[relatedObjectField].([]any)
+	for _, o := range objects {
+		if m, ok := o.(map[string]any); ok && strings.EqualFold(fmt.Sprint(m["id"]), id) {
+			return false, nil
+		}
+	}
+	rec[relatedObjectField] = append(objects, RelatedObject{
+		ID:        id,
+		SchemaURI: SchemaDOI,
+		Type:      Vocabulary{ID: TypeDataset, SchemaURI: TypeSchema},
+		Category:  []Vocabulary{{ID: CategoryOutput, SchemaURI: CategorySchema}},
+	})
+	if err := c.Update(ctx, handle, rec); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// do sends a request and decodes the response into out.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("raid request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
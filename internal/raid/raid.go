@@ -0,0 +1,213 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package raid links datasets to RAiD research activity identifiers.
+//
+// A RAiD is a handle naming a research project, with a record listing
+// its people, organisations, and outputs. Datasets name the RAiDs of
+// the projects that produced them, and once a dataset has a DOI it is
+// added to each RAiD record as an output, so the project record stays
+// complete without anyone editing it by hand.
+package raid
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+)
+
+// Resolver is the resolver RAiDs are cited through.
+const Resolver = "https://raid.org/"
+
+var handleRE = regexp.MustCompile(`^10\.\d{4,}/\S+$`)
+
+// Normalize returns the handle ("prefix/suffix") of a RAiD given as a
+// handle or resolver URL.
+func Normalize(ref string) (string, error) {
+	h := strings.TrimSpace(ref)
+	for _, p := range []string{Resolver, "http://raid.org/", "https://hdl.handle.net/", "http://hdl.handle.net/"} {
+		if len(h) >= len(p) && strings.EqualFold(h[:len(p)], p) {
+			h = h[len(p):]
+			break
+		}
+	}
+	h = strings.TrimRight(h, "/")
+	if !handleRE.MatchString(h) {
+		return "", fmt.Errorf("invalid RAiD %q (want %s10.NNNNN/SUFFIX)", ref, Resolver)
+	}
+	return h, nil
+}
+
+// Registry updates RAiD records. *Client implements it.
+type Registry interface {
+	AddDataset(ctx context.Context, handle, doi string) (bool, error)
+}
+
+// Manager links datasets to RAiDs.
+type Manager struct {
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Registry updates RAiD records; links are only recorded if nil
+	Registry Registry
+
+	// Log records link changes; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Link records that the dataset ref is an output of the RAiD, and adds
+// it to the RAiD record if it already has a DOI and a registry is
+// configured.
+func (m *Manager) Link(ctx context.Context, ref, raid string) (*dataset.Dataset, error) {
+	h, err := Normalize(raid)
+	if err != nil {
+		return nil, err
+	}
+	d, err := m.managed(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(d.RAiDs, h) {
+		return d, nil
+	}
+	d.RAiDs = append(d.RAiDs, h)
+	m.note(ctx, d, "raid.link", h)
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return nil, err
+	}
+	if err := m.record(ctx, "raid.link", d.ID, h); err != nil {
+		return nil, err
+	}
+	if d.DOI != "" && m.Registry != nil {
+		if _, err := m.push(ctx, d, h); err != nil {
+			return d, err
+		}
+	}
+	return d, nil
+}
+
+// Unlink removes the link between the dataset ref and the RAiD. The
+// RAiD record is left as is: outputs are removed by its owners.
+func (m *Manager) Unlink(ctx context.Context, ref, raid string) (*dataset.Dataset, error) {
+	h, err := Normalize(raid)
+	if err != nil {
+		return nil, err
+	}
+	d, err := m.managed(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	i := slices.Index(d.RAiDs, h)
+	if i < 0 {
+		return nil, fmt.Errorf("%s is not linked to %s", d.ID, h)
+	}
+	d.RAiDs = slices.Delete(d.RAiDs, i, i+1)
+	m.note(ctx, d, "raid.unlink", h)
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, m.record(ctx, "raid.unlink", d.ID, h)
+}
+
+// Push adds the dataset ref to each linked RAiD record, returning the
+// RAiDs whose records changed.
+func (m *Manager) Push(ctx context.Context, ref string) ([]string, error) {
+	d, err := m.managed(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return m.Sync(ctx, d)
+}
+
+// Sync adds d to each linked RAiD record, returning the RAiDs whose
+// records changed. Datasets without a DOI are skipped.
+func (m *Manager) Sync(ctx context.Context, d *dataset.Dataset) ([]string, error) {
+	if d.DOI == "" || len(d.RAiDs) == 0 {
+		return nil, nil
+	}
+	var changed []string
+	for _, h := range d.RAiDs {
+		ok, err := m.push(ctx, d, h)
+		if err != nil {
+			return changed, err
+		}
+		if ok {
+			changed = append(changed, h)
+		}
+	}
+	return changed, nil
+}
+
+// push adds d to the record of h.
+func (m *Manager) push(ctx context.Context, d *dataset.Dataset, h string) (bool, error) {
+	if m.Registry == nil {
+		return false, fmt.Errorf("no RAiD registration agency is configured; set RAID_API_URL and RAID_TOKEN")
+	}
+	changed, err := m.Registry.AddDataset(ctx, h, d.DOI)
+	if err != nil {
+		return false, fmt.Errorf("failed to update RAiD %s: %w", h, err)
+	}
+	if changed {
+		return true, m.record(ctx, "raid.push", d.ID, h)
+	}
+	return false, nil
+}
+
+// managed resolves ref and checks that the acting principal may manage
+// the dataset.
+func (m *Manager) managed(ctx context.Context, ref string) (*dataset.Dataset, error) {
+	d, err := m.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// note appends a history event to d.
+func (m *Manager) note(ctx context.Context, d *dataset.Dataset, action, h string) {
+	d.History = append(d.History, dataset.Event{
+		Time:    m.now().UTC(),
+		Actor:   identity.FromContext(ctx).ID,
+		Action:  action,
+		Details: map[string]string{"raid": h},
+	})
+}
+
+func (m *Manager) record(ctx context.Context, action, target, h string) error {
+	if m.Log == nil {
+		return nil
+	}
+	return audit.Record(ctx, m.Log, action, target, map[string]string{"raid": h})
+}
+
+func (m *Manager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,162 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// fakeAgency serves RAiD records from memory.
+type fakeAgency struct {
+	mu      sync.Mutex
+	records map[string]Record
+	puts    int
+}
+
+func (f *fakeAgency) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	handle := r.URL.Path[len("/raid/"):]
+	rec, ok := f.records[handle]
+	if !ok {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(rec)
+	case http.MethodPut:
+		var in Record
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.records[handle] = in
+		f.puts++
+		json.NewEncoder(w).Encode(in)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"10.80368/b1adfb3a", "10.80368/b1adfb3a", false},
+		{"https://raid.org/10.80368/b1adfb3a/", "10.80368/b1adfb3a", false},
+		{"https://hdl.handle.net/10.80368/b1adfb3a", "10.80368/b1adfb3a", false},
+		{"b1adfb3a", "", true},
+		{"10.1/x", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Normalize(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestLinkAndSync(t *testing.T) {
+	agency := &fakeAgency{records: map[string]Record{
+		"10.80368/b1adfb3a": {"identifier": map[string]any{"id": "https://raid.org/10.80368/b1adfb3a"}, "title": []any{map[string]any{"text": "Soil carbon"}}},
+	}}
+	srv := httptest.NewServer(agency)
+	defer srv.Close()
+
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "lab@uni.edu"})
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", Title: "Cores", ACL: &authz.ACL{Manage: []string{"user:lab@uni.edu"}}},
+		{ID: "ds-2", Title: "Cores", DOI: "10.1234/abc", ACL: &authz.ACL{Manage: []string{"user:lab@uni.edu"}}},
+		{ID: "ds-3", Title: "Other"},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log := &audit.MemoryLog{}
+	m := &Manager{Datasets: datasets, Registry: NewClient(Options{BaseURL: srv.URL, Token: "secret"}), Log: log}
+
+	if _, err := m.Link(ctx, "ds-3", "10.80368/b1adfb3a"); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Link() of unmanaged dataset error = %v, want ErrForbidden", err)
+	}
+	d, err := m.Link(ctx, "ds-1", "https://raid.org/10.80368/b1adfb3a")
+	if err != nil {
+		t.Fatalf("Link() error = %v", err)
+	}
+	if len(d.RAiDs) != 1 || d.RAiDs[0] != "10.80368/b1adfb3a" || agency.puts != 0 {
+		t.Errorf("Link() without DOI = %v, %d updates", d.RAiDs, agency.puts)
+	}
+
+	// Publishing gives the dataset a DOI, and syncing adds it once.
+	d.DOI = "10.1234/xyz"
+	for range 2 {
+		if _, err := m.Sync(ctx, d); err != nil {
+			t.Fatalf("Sync() error = %v", err)
+		}
+	}
+	if _, err := m.Link(ctx, "ds-2", "10.80368/b1adfb3a"); err != nil {
+		t.Fatalf("Link() error = %v", err)
+	}
+	rec := agency.records["10.80368/b1adfb3a"]
+	objects, _ := rec["relatedObject"].([]any)
+	if len(objects) != 2 || agency.puts != 2 {
+		t.Fatalf("relatedObject = %v after %d updates, want both datasets once", objects, agency.puts)
+	}
+	if o := objects[0].(map[string]any); o["id"] != "https://doi.org/10.1234/xyz" || o["schemaUri"] != SchemaDOI {
+		t.Errorf("relatedObject[0] = %v", o)
+	}
+	if rec["title"] == nil {
+		t.Error("update dropped fields of the RAiD record")
+	}
+
+	if _, err := m.Link(ctx, "ds-2", "10.80368/missing"); err == nil {
+		t.Error("Link() to an unknown RAiD succeeded, want error")
+	}
+	if d, err = m.Unlink(ctx, "ds-1", "10.80368/b1adfb3a"); err != nil || len(d.RAiDs) != 0 {
+		t.Errorf("Unlink() = %v, %v", d, err)
+	}
+	if _, err := m.Unlink(ctx, "ds-1", "10.80368/b1adfb3a"); err == nil {
+		t.Error("Unlink() of an unlinked RAiD succeeded, want error")
+	}
+
+	entries, _ := log.Entries(ctx)
+	var pushes int
+	for _, e := range entries {
+		if e.Action == "raid.push" {
+			pushes++
+		}
+	}
+	if pushes != 2 {
+		t.Errorf("audited %d pushes, want 2", pushes)
+	}
+}
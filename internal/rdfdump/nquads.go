@@ -0,0 +1,86 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rdfdump
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Vocabularies used in the dump.
+const (
+	nsRDF    = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	nsXSD    = "http://www.w3.org/2001/XMLSchema#"
+	nsDCT    = "http://purl.org/dc/terms/"
+	nsDCAT   = "http://www.w3.org/ns/dcat#"
+	nsFOAF   = "http://xmlns.com/foaf/0.1/"
+	nsSchema = "https://schema.org/"
+	nsVoID   = "http://rdfs.org/ns/void#"
+)
+
+// term is an RDF term in N-Quads syntax.
+type term string
+
+// iri returns the IRI term of s.
+func iri(s string) term {
+	return term("<" + iriEscaper.Replace(s) + ">")
+}
+
+// literal returns a plain string literal.
+func literal(s string) term {
+	return term(`"` + literalEscaper.Replace(s) + `"`)
+}
+
+// typed returns a literal of the XML Schema datatype dt, e.g. "date".
+func typed(s, dt string) term {
+	return term(`"` + literalEscaper.Replace(s) + `"^^<` + nsXSD + dt + ">")
+}
+
+// literalEscaper escapes the characters N-Quads string literals cannot
+// hold.
+var literalEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+// iriEscaper escapes the characters IRIREF excludes as UCHARs.
+var iriEscaper = func() *strings.Replacer {
+	var pairs []string
+	for _, c := range " <>\"{}|^`\\" {
+		pairs = append(pairs, string(c), fmt.Sprintf("\\u%04X", c))
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// quadWriter writes quads to a named graph, counting them and keeping
+// the first error.
+type quadWriter struct {
+	w     io.Writer
+	graph term
+	n     int
+	err   error
+}
+
+// add writes the quad (s, p, o, graph). Nothing is written for an empty
+// object.
+func (q *quadWriter) add(s term, p string, o term) {
+	if q.err != nil || o == "" || o == `""` {
+		return
+	}
+	if q.graph == "" {
+		_, q.err = fmt.Fprintf(q.w, "%s <%s> %s .\n", s, p, o)
+	} else {
+		_, q.err = fmt.Fprintf(q.w, "%s <%s> %s %s .\n", s, p, o, q.graph)
+	}
+	q.n++
+}
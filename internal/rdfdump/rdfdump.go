@@ -0,0 +1,324 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rdfdump publishes the metadata of every published dataset as
+// an RDF dump, for triple stores and other semantic-web consumers.
+//
+// The dump is gzipped N-Quads. Each dataset is described with DCAT and
+// schema.org in its own named graph, the dataset's landing page, so a
+// consumer loading a newer dump can replace a dataset's triples by
+// dropping its graph. The default graph holds the DCAT catalog listing
+// every dataset. A VoID description at /.well-known/void points to the
+// dump, as RFC 5785 registers for dataset discovery. Dumps are full
+// rather than incremental; run them periodically, after ResourceSync.
+package rdfdump
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// Keys of the published documents in the site bucket.
+const (
+	DumpKey = "rdf/datasets.nq.gz"
+	VoIDKey = ".well-known/void"
+)
+
+// accessRights maps access levels to the EU access-right vocabulary
+// used by DCAT-AP.
+var accessRights = map[storage.Access]string{
+	storage.AccessPublic:     "http://publications.europa.eu/resource/authority/access-right/PUBLIC",
+	storage.AccessRestricted: "http://publications.europa.eu/resource/authority/access-right/RESTRICTED",
+	storage.AccessEmbargoed:  "http://publications.europa.eu/resource/authority/access-right/NON_PUBLIC",
+	storage.AccessPrivate:    "http://publications.europa.eu/resource/authority/access-right/NON_PUBLIC",
+}
+
+// ObjectStore stores published documents. *s3.Client implements it.
+type ObjectStore interface {
+	PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error
+}
+
+// Dumper writes RDF dumps of the catalog.
+type Dumper struct {
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Objects stores the dump in Bucket
+	Objects ObjectStore
+	Bucket  string
+
+	// SiteURL is the public base URL of landing pages and documents
+	SiteURL string
+
+	// Publisher is the institution publishing the datasets
+	Publisher string
+
+	// DownloadURL is the base URL of counted download links; files are
+	// not described if empty
+	DownloadURL string
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Result summarizes a dump.
+type Result struct {
+	Datasets int      `json:"datasets"`
+	Quads    int      `json:"quads"`
+	Bytes    int      `json:"bytes"`
+	Paths    []string `json:"paths"`
+}
+
+// Dump writes the dump and its VoID description, returning the URL
+// paths written. With dryRun, the dump is generated but not written.
+func (p *Dumper) Dump(ctx context.Context, dryRun bool) (*Result, error) {
+	if p.SiteURL == "" {
+		return nil, fmt.Errorf("site URL not configured; set APERTURE_SITE_URL")
+	}
+	now := p.now().UTC().Truncate(time.Second)
+	all, err := p.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var published []*dataset.Dataset
+	for _, d := range all {
+		if d.State == dataset.StatePublished {
+			published = append(published, d)
+		}
+	}
+	sort.Slice(published, func(i, j int) bool { return published[i].ID < published[j].ID })
+
+	var buf bytes.Buffer
+	n, err := p.Write(&buf, published, now)
+	if err != nil {
+		return nil, err
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to compress dump: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress dump: %w", err)
+	}
+	res := &Result{Datasets: len(published), Quads: n, Bytes: gz.Len()}
+	if dryRun {
+		return res, nil
+	}
+
+	docs := []struct {
+		key, contentType string
+		body             []byte
+	}{
+		// The dump is written first, so the description never points
+		// to a dump older than it describes.
+		{DumpKey, "application/gzip", gz.Bytes()},
+		{VoIDKey, "text/turtle; charset=utf-8", p.void(n, now)},
+	}
+	for _, d := range docs {
+		if err := p.Objects.PutObject(ctx, p.Bucket, d.key, d.body, d.contentType); err != nil {
+			return res, fmt.Errorf("failed to publish %s: %w", d.key, err)
+		}
+		res.Paths = append(res.Paths, "/"+d.key)
+	}
+	return res, nil
+}
+
+// Write writes the uncompressed N-Quads of datasets, returning the
+// number of quads written.
+func (p *Dumper) Write(w io.Writer, datasets []*dataset.Dataset, now time.Time) (int, error) {
+	catalog := iri(p.base() + "/")
+	q := &quadWriter{w: w}
+	q.add(catalog, nsRDF+"type", iri(nsDCAT+"Catalog"))
+	q.add(catalog, nsDCT+"title", literal(p.Publisher))
+	q.add(catalog, nsDCT+"modified", typed(now.Format(time.RFC3339), "dateTime"))
+	for _, d := range datasets {
+		q.add(catalog, nsDCAT+"dataset", iri(subject(p.pageURL(d), d)))
+	}
+	n := q.n
+	for _, d := range datasets {
+		dq := &quadWriter{w: w, graph: iri(p.pageURL(d))}
+		p.describe(dq, d, now)
+		if dq.err != nil {
+			return 0, fmt.Errorf("failed to write %s: %w", d.ID, dq.err)
+		}
+		n += dq.n
+	}
+	if q.err != nil {
+		return 0, fmt.Errorf("failed to write catalog: %w", q.err)
+	}
+	return n, nil
+}
+
+// describe writes d's DCAT and schema.org description to its graph.
+func (p *Dumper) describe(q *quadWriter, d *dataset.Dataset, now time.Time) {
+	page := p.pageURL(d)
+	s := iri(subject(page, d))
+	q.add(s, nsRDF+"type", iri(nsDCAT+"Dataset"))
+	if d.Software != nil {
+		q.add(s, nsRDF+"type", iri(nsSchema+"SoftwareSourceCode"))
+	} else {
+		q.add(s, nsRDF+"type", iri(nsSchema+"Dataset"))
+	}
+	q.add(s, nsDCT+"title", literal(d.Title))
+	q.add(s, nsSchema+"name", literal(d.Title))
+	q.add(s, nsDCT+"description", literal(d.Description))
+	q.add(s, nsSchema+"description", literal(d.Description))
+	if id := pid.Identifier(d); id != "" {
+		q.add(s, nsDCT+"identifier", literal(id))
+		q.add(s, nsSchema+"identifier", iri(subject(page, d)))
+	}
+	q.add(s, nsDCAT+"landingPage", iri(page))
+	q.add(s, nsSchema+"url", iri(page))
+	q.add(s, nsDCT+"accessRights", termOf(accessRights[d.Access]))
+	if p.Publisher != "" {
+		pub := iri(p.base() + "/#publisher")
+		q.add(s, nsDCT+"publisher", pub)
+		q.add(s, nsSchema+"publisher", pub)
+		q.add(pub, nsRDF+"type", iri(nsFOAF+"Organization"))
+		q.add(pub, nsFOAF+"name", literal(p.Publisher))
+	}
+	if d.PublicationYear != 0 {
+		q.add(s, nsDCT+"issued", typed(strconv.Itoa(d.PublicationYear), "gYear"))
+	}
+	q.add(s, nsDCT+"modified", typed(d.UpdatedAt.UTC().Format(time.RFC3339), "dateTime"))
+	if d.Software != nil {
+		q.add(s, nsSchema+"codeRepository", termOf(d.Software.Repository))
+		if d.Software.License != "" {
+			q.add(s, nsDCT+"license", iri("https://spdx.org/licenses/"+d.Software.License))
+		}
+	}
+	for i, c := range d.Creators {
+		creator := iri(page + "#creator-" + strconv.Itoa(i+1))
+		if c.ORCID != "" {
+			creator = iri("https://orcid.org/" + strings.TrimPrefix(c.ORCID, "https://orcid.org/"))
+		}
+		q.add(s, nsDCT+"creator", creator)
+		q.add(s, nsSchema+"creator", creator)
+		q.add(creator, nsRDF+"type", iri(nsFOAF+"Agent"))
+		q.add(creator, nsFOAF+"name", literal(c.Name))
+		if c.AffiliationROR != "" {
+			q.add(creator, nsSchema+"affiliation", iri("https://ror.org/"+strings.TrimPrefix(c.AffiliationROR, "https://ror.org/")))
+		}
+	}
+
+	v := d.Current()
+	if v == nil {
+		return
+	}
+	q.add(s, nsDCAT+"version", literal(strconv.Itoa(v.Number)))
+	q.add(s, nsSchema+"version", literal(strconv.Itoa(v.Number)))
+	if v.PublishedAt != nil {
+		q.add(s, nsSchema+"datePublished", typed(v.PublishedAt.UTC().Format("2006-01-02"), "date"))
+	}
+	downloads := landing.DownloadLinks(p.DownloadURL, d, now)
+	for i, f := range v.Files {
+		href, ok := downloads[f.Path]
+		if !ok {
+			continue
+		}
+		dist := iri(page + "#file-" + strconv.Itoa(i+1))
+		q.add(s, nsDCAT+"distribution", dist)
+		q.add(s, nsSchema+"distribution", dist)
+		q.add(dist, nsRDF+"type", iri(nsDCAT+"Distribution"))
+		q.add(dist, nsRDF+"type", iri(nsSchema+"DataDownload"))
+		q.add(dist, nsDCT+"title", literal(f.Path))
+		q.add(dist, nsDCAT+"downloadURL", iri(href))
+		q.add(dist, nsSchema+"contentUrl", iri(href))
+		q.add(dist, nsDCAT+"mediaType", termOf(mediaTypeIRI(f.ContentType)))
+		q.add(dist, nsSchema+"encodingFormat", literal(f.ContentType))
+		q.add(dist, nsDCAT+"byteSize", typed(strconv.FormatInt(f.Size, 10), "nonNegativeInteger"))
+		if f.SHA256 != "" {
+			q.add(dist, nsSchema+"sha256", literal(f.SHA256))
+		}
+	}
+}
+
+// void returns the VoID description of the dump, in Turtle.
+func (p *Dumper) void(quads int, now time.Time) []byte {
+	base := p.base()
+	var b strings.Builder
+	fmt.Fprintf(&b, "@prefix void: <%s> .\n", nsVoID)
+	fmt.Fprintf(&b, "@prefix dct: <%s> .\n", nsDCT)
+	fmt.Fprintf(&b, "@prefix xsd: <%s> .\n\n", nsXSD)
+	fmt.Fprintf(&b, "%s a void:Dataset ;\n", iri(base+"/"+VoIDKey+"#datasets"))
+	if p.Publisher != "" {
+		fmt.Fprintf(&b, "  dct:title %s ;\n", literal(p.Publisher+" datasets"))
+		fmt.Fprintf(&b, "  dct:publisher %s ;\n", literal(p.Publisher))
+	}
+	fmt.Fprintf(&b, "  dct:modified \"%s\"^^xsd:dateTime ;\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "  void:dataDump %s ;\n", iri(base+"/"+DumpKey))
+	fmt.Fprintf(&b, "  void:triples %d ;\n", quads)
+	fmt.Fprintf(&b, "  void:vocabulary <%s>, <%s>, <%s> .\n", nsDCAT, nsDCT, nsSchema)
+	return []byte(b.String())
+}
+
+// subject returns the IRI describing d: its persistent identifier's
+// resolver URL, or its landing page if it has none.
+func subject(page string, d *dataset.Dataset) string {
+	switch {
+	case d.DOI != "":
+		return "https://doi.org/" + d.DOI
+	case d.ARK != "":
+		return "https://n2t.net/" + d.ARK
+	case d.Handle != "":
+		return "https://hdl.handle.net/" + d.Handle
+	}
+	return page
+}
+
+// mediaTypeIRI returns the IANA registry IRI of a media type, as
+// dcat:mediaType expects, or "" if ct is empty.
+func mediaTypeIRI(ct string) string {
+	mt, _, _ := strings.Cut(ct, ";")
+	if mt = strings.TrimSpace(mt); mt == "" {
+		return ""
+	}
+	return "https://www.iana.org/assignments/media-types/" + mt
+}
+
+// termOf returns the IRI term of s, or "" if s is empty.
+func termOf(s string) term {
+	if s == "" {
+		return ""
+	}
+	return iri(s)
+}
+
+func (p *Dumper) base() string {
+	return strings.TrimRight(p.SiteURL, "/")
+}
+
+func (p *Dumper) pageURL(d *dataset.Dataset) string {
+	return p.base() + landing.PagePath(d.ID)
+}
+
+func (p *Dumper) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,130 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rdfdump
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+type fakeObjects map[string][]byte
+
+func (f fakeObjects) PutObject(_ context.Context, bucket, key string, body []byte, _ string) error {
+	f[bucket+"/"+key] = body
+	return nil
+}
+
+func TestDump(t *testing.T) {
+	ctx := context.Background()
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	published := time.Date(2025, 4, 2, 9, 0, 0, 0, time.UTC)
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", DOI: "10.1234/abc", Title: `Soil "cores"`, Description: "Line one\nline two", State: dataset.StatePublished,
+			Access: storage.AccessPublic, PublicationYear: 2025,
+			Creators: []dataset.Creator{{Name: "Lovelace, Ada", ORCID: "0000-0002-1825-0097"}, {Name: "Hopper, Grace"}},
+			Versions: []dataset.Version{{Number: 2, PublishedAt: &published, Files: []dataset.File{{Path: "cores.csv", Size: 2048, ContentType: "text/csv"}}}}},
+		{ID: "ds-2", Title: "Letters", State: dataset.StatePublished, Access: storage.AccessRestricted},
+		{ID: "ds-3", Title: "Draft", State: dataset.StateDraft},
+		{ID: "ds-4", Title: "Withdrawn", State: dataset.StateTombstoned},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	objects := fakeObjects{}
+	p := &Dumper{
+		Datasets:    datasets,
+		Objects:     objects,
+		Bucket:      "site",
+		SiteURL:     "https://data.uni.edu/",
+		Publisher:   "Example University",
+		DownloadURL: "https://dl.uni.edu",
+		Now:         func() time.Time { return time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC) },
+	}
+
+	res, err := p.Dump(ctx, true)
+	if err != nil || res.Datasets != 2 || len(res.Paths) != 0 || len(objects) != 0 {
+		t.Fatalf("dry run = %+v, %v; wrote %d objects", res, err, len(objects))
+	}
+	res, err = p.Dump(ctx, false)
+	if err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	if want := "/" + DumpKey + " /" + VoIDKey; strings.Join(res.Paths, " ") != want {
+		t.Errorf("Dump() paths = %v, want %s", res.Paths, want)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(objects["site/"+DumpKey]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := string(data)
+	if n := strings.Count(dump, "\n"); n != res.Quads {
+		t.Errorf("dump has %d lines, Result.Quads = %d", n, res.Quads)
+	}
+	for _, want := range []string{
+		`<https://data.uni.edu/> <http://www.w3.org/ns/dcat#dataset> <https://doi.org/10.1234/abc> .`,
+		`<https://data.uni.edu/> <http://www.w3.org/ns/dcat#dataset> <https://data.uni.edu/datasets/ds-2/> .`,
+		`<https://doi.org/10.1234/abc> <http://purl.org/dc/terms/title> "Soil \"cores\"" <https://data.uni.edu/datasets/ds-1/> .`,
+		`<https://doi.org/10.1234/abc> <http://purl.org/dc/terms/description> "Line one\nline two" <https://data.uni.edu/datasets/ds-1/> .`,
+		`<https://doi.org/10.1234/abc> <http://purl.org/dc/terms/creator> <https://orcid.org/0000-0002-1825-0097> <https://data.uni.edu/datasets/ds-1/> .`,
+		`<https://data.uni.edu/datasets/ds-1/#creator-2> <http://xmlns.com/foaf/0.1/name> "Hopper, Grace" <https://data.uni.edu/datasets/ds-1/> .`,
+		`<https://data.uni.edu/datasets/ds-1/#file-1> <http://www.w3.org/ns/dcat#downloadURL> <https://dl.uni.edu/d/ds-1/v2/cores.csv> <https://data.uni.edu/datasets/ds-1/> .`,
+		`<https://data.uni.edu/datasets/ds-1/#file-1> <http://www.w3.org/ns/dcat#byteSize> "2048"^^<http://www.w3.org/2001/XMLSchema#nonNegativeInteger> <https://data.uni.edu/datasets/ds-1/> .`,
+		`<https://data.uni.edu/datasets/ds-2/> <http://purl.org/dc/terms/accessRights> <http://publications.europa.eu/resource/authority/access-right/RESTRICTED> <https://data.uni.edu/datasets/ds-2/> .`,
+	} {
+		if !strings.Contains(dump, want+"\n") {
+			t.Errorf("dump lacks %s", want)
+		}
+	}
+	if strings.Contains(dump, "ds-3") || strings.Contains(dump, "ds-4") {
+		t.Errorf("dump describes unpublished datasets:\n%s", dump)
+	}
+
+	void := string(objects["site/"+VoIDKey])
+	if !strings.Contains(void, "void:dataDump <https://data.uni.edu/rdf/datasets.nq.gz>") || !strings.Contains(void, "void:triples ") {
+		t.Errorf("VoID description =\n%s", void)
+	}
+}
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		got  term
+		want term
+	}{
+		{iri("https://example.org/a b<c>"), `<https://example.org/a\u0020b\u003Cc\u003E>`},
+		{literal("tab\there \\ \"q\"\r\n"), `"tab` + "\t" + `here \\ \"q\"\r\n"`},
+		{typed("2025", "gYear"), `"2025"^^<http://www.w3.org/2001/XMLSchema#gYear>`},
+		{termOf(""), ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("term = %s, want %s", tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package regions serves the files of popular datasets from copies in
+// several AWS regions.
+//
+// Stewards list the regions a collection is read from in its Regions
+// setting. A Syncer copies the files of the current published version
+// of each dataset in such a collection to a bucket in each region,
+// named for the primary bucket with the region appended, such as
+// aperture-prod-public-media-eu-west-1, and records every copy. A
+// Router then picks, for each download, the copy nearest the requester:
+// the region in the requester's part of the world, from the country
+// CloudFront reports, or the nearest part of the world with a copy.
+// Requests from an unknown country, files not copied yet, and earlier
+// versions are served from the primary region. Copies of versions no
+// longer current, or in regions a collection stops using, are left for
+// the operator to remove.
+package regions
+
+import (
+	"slices"
+	"strings"
+)
+
+// area is a part of the world served by the same regions.
+type area string
+
+const (
+	northAmerica area = "north-america"
+	southAmerica area = "south-america"
+	europe       area = "europe"
+	middleEast   area = "middle-east"
+	africa       area = "africa"
+	asia         area = "asia"
+	oceania      area = "oceania"
+)
+
+// countries are the ISO 3166-1 alpha-2 codes of the countries in each
+// area.
+var countries = map[area]string{
+	northAmerica: "AG AI AW BB BL BM BQ BS BZ CA CR CU CW DM DO GD GL GP GT HN HT JM KN KY LC MF MQ MS MX NI PA PM PR SV SX TC TT US VC VG VI",
+	southAmerica: "AR BO BR CL CO EC FK GF GY PE PY SR UY VE",
+	europe:       "AD AL AT AX BA BE BG BY CH CY CZ DE DK EE ES FI FO FR GB GG GI GR HR HU IE IM IS IT JE LI LT LU LV MC MD ME MK MT NL NO PL PT RO RS RU SE SI SJ SK SM UA VA XK",
+	middleEast:   "AE BH IL IQ IR JO KW LB OM PS QA SA SY TR YE",
+	africa:       "AO BF BI BJ BW CD CF CG CI CM CV DJ DZ EG EH ER ET GA GH GM GN GQ GW KE KM LR LS LY MA MG ML MR MU MW MZ NA NE NG RE RW SC SD SH SL SN SO SS ST SZ TD TG TN TZ UG YT ZA ZM ZW",
+	asia:         "AF AM AZ BD BN BT CN GE HK ID IN JP KG KH KP KR KZ LA LK MM MN MO MV MY NP PH PK SG TH TJ TL TM TW UZ VN",
+	oceania:      "AS AU CK FJ FM GU KI MH MP NC NF NR NU NZ PF PG PW SB TO TV VU WS",
+}
+
+// nearest lists, for each area, the areas in order of distance.
+var nearest = map[area][]area{
+	northAmerica: {northAmerica, southAmerica, europe, oceania, asia, middleEast, africa},
+	southAmerica: {southAmerica, northAmerica, europe, africa, oceania, asia, middleEast},
+	europe:       {europe, middleEast, africa, northAmerica, asia, southAmerica, oceania},
+	middleEast:   {middleEast, europe, asia, africa, northAmerica, oceania, southAmerica},
+	africa:       {africa, europe, middleEast, southAmerica, northAmerica, asia, oceania},
+	asia:         {asia, oceania, middleEast, europe, northAmerica, africa, southAmerica},
+	oceania:      {oceania, asia, northAmerica, europe, southAmerica, middleEast, africa},
+}
+
+// oceaniaRegions are the Asia Pacific regions in Australia and New
+// Zealand.
+var oceaniaRegions = []string{"ap-southeast-2", "ap-southeast-4", "ap-southeast-6"}
+
+// countryArea returns the area of a country, or "" if it is unknown.
+func countryArea(country string) area {
+	country = strings.ToUpper(country)
+	if len(country) != 2 {
+		return ""
+	}
+	for a, codes := range countries {
+		if slices.Contains(strings.Fields(codes), country) {
+			return a
+		}
+	}
+	return ""
+}
+
+// regionArea returns the area of an AWS region, or "" if it is unknown.
+func regionArea(region string) area {
+	geo, _, _ := strings.Cut(region, "-")
+	switch geo {
+	case "us", "ca", "mx":
+		return northAmerica
+	case "sa":
+		return southAmerica
+	case "eu":
+		return europe
+	case "me", "il":
+		return middleEast
+	case "af":
+		return africa
+	case "cn":
+		return asia
+	case "ap":
+		if slices.Contains(oceaniaRegions, region) {
+			return oceania
+		}
+		return asia
+	}
+	return ""
+}
+
+// Nearest returns the region nearest a requester in country, an ISO
+// 3166-1 alpha-2 code, among home and regions: one in the requester's
+// part of the world if there is one, and otherwise one in the nearest
+// part with a region. Ties go to home, then to the earlier of regions.
+// It returns home if the country is unknown.
+func Nearest(home string, regions []string, country string) string {
+	order := nearest[countryArea(country)]
+	if order == nil {
+		return home
+	}
+	rank := func(region string) int {
+		if i := slices.Index(order, regionArea(region)); i >= 0 {
+			return i
+		}
+		return len(order)
+	}
+	best, bestRank := home, rank(home)
+	for _, region := range regions {
+		if r := rank(region); r < bestRank {
+			best, bestRank = region, r
+		}
+	}
+	return best
+}
+
+// Bucket returns the name of the bucket in region holding the copies of
+// the objects in bucket.
+func Bucket(bucket, region string) string {
+	return bucket + "-" + region
+}
@@ -0,0 +1,196 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/access"
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+func TestNearest(t *testing.T) {
+	regions := []string{"eu-west-1", "ap-southeast-2", "ap-northeast-1"}
+	tests := []struct {
+		country string
+		want    string
+	}{
+		{"US", "us-east-1"},
+		{"br", "us-east-1"},
+		{"DE", "eu-west-1"},
+		{"AE", "eu-west-1"},
+		{"KE", "eu-west-1"},
+		{"NZ", "ap-southeast-2"},
+		{"JP", "ap-northeast-1"},
+		{"IN", "ap-northeast-1"},
+		{"", "us-east-1"},
+		{"ZZ", "us-east-1"},
+	}
+	for _, tt := range tests {
+		if got := Nearest("us-east-1", regions, tt.country); got != tt.want {
+			t.Errorf("Nearest(%q) = %s, want %s", tt.country, got, tt.want)
+		}
+	}
+	if got := Nearest("eu-central-1", []string{"eu-west-1"}, "FR"); got != "eu-central-1" {
+		t.Errorf("Nearest() in the home area = %s, want home", got)
+	}
+}
+
+// fakeStore is an in-memory store keyed by bucket/key.
+type fakeStore map[string]string
+
+func (f fakeStore) GetObject(_ context.Context, bucket, key string) (io.ReadCloser, error) {
+	body, ok := f[bucket+"/"+key]
+	if !ok {
+		return nil, s3.ErrNotFound
+	}
+	return io.NopCloser(strings.NewReader(body)), nil
+}
+
+func (f fakeStore) Upload(_ context.Context, bucket, key string, r io.Reader, _ s3.PutOptions) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	f[bucket+"/"+key] = string(b)
+	return int64(len(b)), nil
+}
+
+// fakePresigner signs URLs naming its region.
+type fakePresigner string
+
+func (p fakePresigner) PresignGetObject(bucket, key string, _ time.Duration) string {
+	return "https://" + bucket + ".s3." + string(p) + ".amazonaws.com/" + key
+}
+
+func sum(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func TestSyncAndRoute(t *testing.T) {
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	collections := &collection.Registry{State: s, Datasets: datasets}
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "root@uni.edu", Groups: []string{authz.AdminGroup}})
+	if _, err := collections.Create(ctx, collection.Collection{ID: "climate", Kind: collection.KindCollection, Name: "Climate", Regions: []string{"eu-west-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	published := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	d := &dataset.Dataset{
+		ID:         "ds-1",
+		Title:      "Reanalysis",
+		State:      dataset.StatePublished,
+		Access:     storage.AccessPublic,
+		Collection: "climate",
+		Versions: []dataset.Version{
+			{Number: 1, PublishedAt: &published, Files: []dataset.File{
+				{Path: "a.nc", Size: 5, SHA256: sum("aaaaa"), Bucket: "ap-public-media", Key: "ds-1/v1/a.nc"},
+				{Path: "b.nc", Size: 5, SHA256: sum("bbbbb"), Bucket: "ap-public-media", Key: "ds-1/v1/b.nc"},
+			}},
+		},
+	}
+	other := &dataset.Dataset{
+		ID:     "ds-2",
+		Title:  "Unlisted",
+		State:  dataset.StatePublished,
+		Access: storage.AccessPublic,
+		Versions: []dataset.Version{
+			{Number: 1, PublishedAt: &published, Files: []dataset.File{
+				{Path: "c.nc", Size: 5, Bucket: "ap-public-media", Key: "ds-2/v1/c.nc"},
+			}},
+		},
+	}
+	for _, d := range []*dataset.Dataset{d, other} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The second file was changed since the manifest recorded it.
+	source := fakeStore{"ap-public-media/ds-1/v1/a.nc": "aaaaa", "ap-public-media/ds-1/v1/b.nc": "BBBBB"}
+	eu := fakeStore{}
+	log := &audit.MemoryLog{}
+	sy := &Syncer{
+		Collections: collections,
+		Source:      source,
+		Targets:     func(string) (Target, error) { return eu, nil },
+		State:       s,
+		Log:         log,
+	}
+	rep, err := sy.Plan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Copies) != 2 || rep.Bytes() != 10 || rep.Copies[0].To.Bucket != "ap-public-media-eu-west-1" {
+		t.Fatalf("Plan() = %+v", rep)
+	}
+	res, err := sy.Copy(ctx, rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Copied != 1 || len(res.Failed) != 1 || eu["ap-public-media-eu-west-1/ds-1/v1/a.nc"] != "aaaaa" {
+		t.Errorf("Copy() = %+v, copies %v", res, eu)
+	}
+	if entries, _ := log.Entries(ctx); len(entries) != 1 || entries[0].Action != "storage.regions" {
+		t.Errorf("audit log = %+v", entries)
+	}
+	if rep, err := sy.Plan(ctx); err != nil || len(rep.Copies) != 1 || rep.Copied != 1 {
+		t.Errorf("Plan() after copy = %+v, %v", rep, err)
+	}
+
+	r := &Router{
+		Home:        "us-east-1",
+		Collections: collections,
+		State:       s,
+		Presigners:  func(region string) (access.Presigner, error) { return fakePresigner(region), nil },
+	}
+	route := func(d *dataset.Dataset, f int, country string) string {
+		t.Helper()
+		p, loc, err := r.Route(ctx, d, &d.Versions[0].Files[f], authz.Client{Country: country})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p == nil {
+			return "home"
+		}
+		return p.PresignGetObject(loc.Bucket, loc.Key, time.Hour)
+	}
+	if got := route(d, 0, "FR"); got != "https://ap-public-media-eu-west-1.s3.eu-west-1.amazonaws.com/ds-1/v1/a.nc" {
+		t.Errorf("Route() from France = %s", got)
+	}
+	if got := route(d, 0, "US"); got != "home" {
+		t.Errorf("Route() from the home area = %s, want home", got)
+	}
+	if got := route(d, 1, "FR"); got != "home" {
+		t.Errorf("Route() of an uncopied file = %s, want home", got)
+	}
+	if got := route(other, 0, "FR"); got != "home" {
+		t.Errorf("Route() outside a collection = %s, want home", got)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regions
+
+import (
+	"context"
+	"errors"
+
+	"github.com/scttfrdmn/aperture/internal/access"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// Router picks the regional copy of a file to serve a download from.
+// It implements access.Router.
+type Router struct {
+	// Home is the region of the primary buckets
+	Home string
+
+	// Collections is the collection registry holding the read regions
+	Collections *collection.Registry
+
+	// State holds the records of the copies Syncer made
+	State state.Store
+
+	// Presigners returns the presigner of a region
+	Presigners func(region string) (access.Presigner, error)
+}
+
+// Route returns the presigner and location of the copy of f nearest
+// client, or a nil presigner to serve f from the primary region: if
+// home is nearest, d's collection has no read regions, or the nearest
+// region has no copy of f yet.
+func (r *Router) Route(ctx context.Context, d *dataset.Dataset, f *dataset.File, client authz.Client) (access.Presigner, storage.Location, error) {
+	loc := storage.Location{Bucket: f.Bucket, Key: f.Key}
+	if d.Collection == "" || client.Country == "" || f.Tier != "" {
+		return nil, loc, nil
+	}
+	c, err := r.Collections.Get(ctx, d.Collection)
+	if errors.Is(err, collection.ErrNotFound) {
+		return nil, loc, nil
+	}
+	if err != nil {
+		return nil, loc, err
+	}
+	region := Nearest(r.Home, c.Regions, client.Country)
+	if region == r.Home {
+		return nil, loc, nil
+	}
+	var rec copied
+	err = r.State.Get(ctx, copiesTable, copyKey(region, loc), &rec)
+	switch {
+	case errors.Is(err, state.ErrNotFound):
+		return nil, loc, nil
+	case err != nil:
+		return nil, loc, err
+	case !rec.holds(f):
+		return nil, loc, nil
+	}
+	p, err := r.Presigners(region)
+	if err != nil {
+		return nil, loc, err
+	}
+	return p, storage.Location{Bucket: Bucket(f.Bucket, region), Key: f.Key}, nil
+}
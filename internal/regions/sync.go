@@ -0,0 +1,268 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// copiesTable records the regional copies, keyed by region, bucket, and
+// key.
+const copiesTable = "region-copies"
+
+// Source reads the objects to copy. storage.Backend implementations
+// implement it.
+type Source interface {
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// Target stores the copies in one region. *s3.Client implements it.
+type Target interface {
+	Upload(ctx context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error)
+}
+
+// Copy is a file to copy to a region.
+type Copy struct {
+	Region      string           `json:"region"`
+	Dataset     string           `json:"dataset"`
+	From        storage.Location `json:"from"`
+	To          storage.Location `json:"to"`
+	Size        int64            `json:"size"`
+	SHA256      string           `json:"sha256,omitempty"`
+	ContentType string           `json:"contentType,omitempty"`
+}
+
+// copied is the record of a regional copy.
+type copied struct {
+	Size   int64     `json:"size"`
+	SHA256 string    `json:"sha256"`
+	Time   time.Time `json:"time"`
+}
+
+// holds reports whether the copy c holds f, whose digest is unknown for
+// files deposited before digests were recorded.
+func (c copied) holds(f *dataset.File) bool {
+	return c.Size == f.Size && (f.SHA256 == "" || c.SHA256 == f.SHA256)
+}
+
+// copyKey returns the key of the record of the copy of the object at
+// loc in region.
+func copyKey(region string, loc storage.Location) string {
+	return region + "/" + loc.Bucket + "/" + loc.Key
+}
+
+// Report describes the copies left to make.
+type Report struct {
+	Copies []Copy `json:"copies"`
+
+	// Copied counts the files already copied
+	Copied int `json:"copied"`
+}
+
+// Bytes returns the bytes left to copy.
+func (r *Report) Bytes() int64 {
+	var n int64
+	for _, c := range r.Copies {
+		n += c.Size
+	}
+	return n
+}
+
+// Result is the outcome of copying.
+type Result struct {
+	Copied int   `json:"copied"`
+	Bytes  int64 `json:"bytes"`
+
+	// Failed are the copies that failed, with why; syncing again
+	// retries them
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// Syncer copies the files of collections with read regions to those
+// regions.
+type Syncer struct {
+	// Collections is the collection registry, whose datasets are
+	// copied
+	Collections *collection.Registry
+
+	// Source reads the files
+	Source Source
+
+	// Targets returns the store of a region
+	Targets func(region string) (Target, error)
+
+	// State records the copies
+	State state.Store
+
+	// Log records each sync that copied files; skipped if nil
+	Log audit.Log
+}
+
+// Plan returns the files of the current versions of the published
+// datasets in collections with read regions that are not yet copied to
+// each region. Nothing is copied.
+func (s *Syncer) Plan(ctx context.Context) (*Report, error) {
+	collections, err := s.Collections.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rep := &Report{Copies: []Copy{}}
+	seen := make(map[string]bool)
+	for _, c := range collections {
+		if len(c.Regions) == 0 {
+			continue
+		}
+		members, err := s.Collections.Members(ctx, c.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range members {
+			v := d.Current()
+			if d.State != dataset.StatePublished || v == nil || v.PublishedAt == nil {
+				continue
+			}
+			for i := range v.Files {
+				f := &v.Files[i]
+				// Tiered files are served from the cloud tier.
+				if f.Tier != "" {
+					continue
+				}
+				from := storage.Location{Bucket: f.Bucket, Key: f.Key}
+				for _, region := range c.Regions {
+					key := copyKey(region, from)
+					if seen[key] {
+						continue
+					}
+					seen[key] = true
+					ok, err := s.copied(ctx, key, f)
+					if err != nil {
+						return nil, err
+					}
+					if ok {
+						rep.Copied++
+						continue
+					}
+					rep.Copies = append(rep.Copies, Copy{
+						Region:      region,
+						Dataset:     d.ID,
+						From:        from,
+						To:          storage.Location{Bucket: Bucket(f.Bucket, region), Key: f.Key},
+						Size:        f.Size,
+						SHA256:      f.SHA256,
+						ContentType: f.ContentType,
+					})
+				}
+			}
+		}
+	}
+	return rep, nil
+}
+
+// copied reports whether the copy recorded under key holds f.
+func (s *Syncer) copied(ctx context.Context, key string, f *dataset.File) (bool, error) {
+	var c copied
+	err := s.State.Get(ctx, copiesTable, key, &c)
+	if errors.Is(err, state.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return c.holds(f), nil
+}
+
+// Copy makes the copies of rep, recording each. A failed copy is
+// reported in the result and the rest are still made.
+func (s *Syncer) Copy(ctx context.Context, rep *Report) (*Result, error) {
+	res := &Result{}
+	targets := make(map[string]Target)
+	regions := make(map[string]bool)
+	for _, c := range rep.Copies {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		target, ok := targets[c.Region]
+		if !ok {
+			t, err := s.Targets(c.Region)
+			if err != nil {
+				return res, fmt.Errorf("failed to reach %s: %w", c.Region, err)
+			}
+			target, targets[c.Region] = t, t
+		}
+		sum, err := s.copy(ctx, target, c)
+		if err != nil {
+			if res.Failed == nil {
+				res.Failed = make(map[string]string)
+			}
+			res.Failed[c.Region+":"+c.From.String()] = err.Error()
+			continue
+		}
+		rec := copied{Size: c.Size, SHA256: sum, Time: time.Now().UTC()}
+		if err := s.State.Put(ctx, copiesTable, copyKey(c.Region, c.From), rec); err != nil {
+			return res, err
+		}
+		res.Copied++
+		res.Bytes += c.Size
+		regions[c.Region] = true
+	}
+	if s.Log != nil && res.Copied > 0 {
+		details := map[string]string{"regions": strings.Join(slices.Sorted(maps.Keys(regions)), ","), "files": fmt.Sprint(res.Copied), "bytes": fmt.Sprint(res.Bytes)}
+		if err := audit.Record(ctx, s.Log, "storage.regions", "", details); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// copy copies the object of c to target, checking it against the
+// file's digest on the way, and returns the hex-encoded SHA-256 digest
+// of the copy.
+func (s *Syncer) copy(ctx context.Context, target Target, c Copy) (string, error) {
+	body, err := s.Source.GetObject(ctx, c.From.Bucket, c.From.Key)
+	if err != nil {
+		return "", fmt.Errorf("failed to read: %w", err)
+	}
+	defer body.Close()
+	h := sha256.New()
+	opts := s3.PutOptions{ContentType: c.ContentType, Size: c.Size}
+	n, err := target.Upload(ctx, c.To.Bucket, c.To.Key, io.TeeReader(body, h), opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy to %s: %w", c.To, err)
+	}
+	if n != c.Size {
+		return "", fmt.Errorf("copied %d of %d bytes to %s", n, c.Size, c.To)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if c.SHA256 != "" && sum != c.SHA256 {
+		return "", fmt.Errorf("read SHA-256 %s from %s, want %s", sum, c.From, c.SHA256)
+	}
+	return sum, nil
+}
@@ -0,0 +1,420 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package relocation moves a repository's stored objects from one
+// storage backend to another, such as from S3 to Google Cloud Storage
+// when an institution changes cloud agreements.
+//
+// A move runs in two steps. Copy streams every object of the source
+// buckets to the target, hashing it on the way, and verifies the copy
+// against the digest the target stored or, where it stores none, by
+// reading the copy back. An object whose streamed digest differs from
+// the SHA-256 its manifest records is not recorded as copied, since the
+// source itself is damaged. Each verified copy is recorded, so that an
+// interrupted or partly failed run is resumed by running it again; an
+// object changed since its copy is copied anew. Once nothing is left to
+// copy, Rewrite points the manifests at the target buckets, which may be
+// named with a new prefix, and republishes the landing pages and
+// identifier records, whose URLs the caller configures for the new
+// deployment. The source objects are left in place until the operator
+// removes them.
+package relocation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// copiesTable records the verified copies, by source bucket and key.
+const copiesTable = "relocation-copies"
+
+// archived are the storage classes whose objects must be restored
+// before they can be read.
+var archived = []string{"GLACIER", "DEEP_ARCHIVE"}
+
+// Source lists and reads the objects to move. storage.Backend
+// implementations implement it.
+type Source interface {
+	ListObjects(ctx context.Context, bucket, prefix string, fn func(s3.ObjectInfo) error) error
+	HeadObject(ctx context.Context, bucket, key string) (s3.ObjectInfo, error)
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// Target stores and verifies the copies. storage.Backend
+// implementations implement it.
+type Target interface {
+	Upload(ctx context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error)
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	GetObjectAttributes(ctx context.Context, bucket, key string) (s3.Attributes, error)
+}
+
+// PagePublisher re-renders a dataset's landing page.
+type PagePublisher interface {
+	Publish(ctx context.Context, datasetID string) (*landing.Change, error)
+}
+
+// PIDUpdater re-registers the metadata of a dataset's identifiers,
+// including their URLs. *pid.Registrar implements it.
+type PIDUpdater interface {
+	Update(ctx context.Context, d *dataset.Dataset) error
+}
+
+// Copy is an object to copy to the target.
+type Copy struct {
+	From         storage.Location `json:"from"`
+	To           storage.Location `json:"to"`
+	Size         int64            `json:"size"`
+	ETag         string           `json:"etag,omitempty"`
+	StorageClass string           `json:"storageClass,omitempty"`
+
+	// SHA256 is the hex-encoded digest a manifest records for the
+	// object, if any
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// copied is the record of a verified copy.
+type copied struct {
+	To     storage.Location `json:"to"`
+	Size   int64            `json:"size"`
+	ETag   string           `json:"etag,omitempty"`
+	SHA256 string           `json:"sha256"`
+	Time   time.Time        `json:"time"`
+}
+
+// Report describes what is left to move.
+type Report struct {
+	Copies []Copy `json:"copies"`
+
+	// Copied counts the objects already copied and verified
+	Copied int `json:"copied"`
+
+	// Archived are objects in archival storage classes, which must be
+	// restored before they can be copied
+	Archived []storage.Location `json:"archived,omitempty"`
+}
+
+// Bytes returns the bytes left to copy.
+func (r *Report) Bytes() int64 {
+	var n int64
+	for _, c := range r.Copies {
+		n += c.Size
+	}
+	return n
+}
+
+// Result is the outcome of copying.
+type Result struct {
+	Copied int   `json:"copied"`
+	Bytes  int64 `json:"bytes"`
+
+	// Failed are the copies that failed, with why; running the move
+	// again retries them
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// Relocator moves objects between backends.
+type Relocator struct {
+	Source Source
+	Target Target
+
+	// Buckets are the source buckets to move
+	Buckets []string
+
+	// Prefix and TargetPrefix prefix the names of the source buckets
+	// and of the target buckets they move to; the target buckets keep
+	// the source names if TargetPrefix is empty
+	Prefix       string
+	TargetPrefix string
+
+	// Datasets is the catalog whose manifests Rewrite updates
+	Datasets *dataset.Store
+
+	// State records the verified copies
+	State state.Store
+
+	// Pages republishes landing pages; skipped if nil
+	Pages PagePublisher
+
+	// PIDs re-registers identifiers; skipped if nil
+	PIDs PIDUpdater
+
+	// Log records the move; skipped if nil
+	Log audit.Log
+}
+
+// bucket returns the target bucket of a source bucket.
+func (r *Relocator) bucket(source string) string {
+	if r.TargetPrefix == "" {
+		return source
+	}
+	if rest, ok := strings.CutPrefix(source, r.Prefix+"-"); ok {
+		return r.TargetPrefix + "-" + rest
+	}
+	return source
+}
+
+// Plan lists the source buckets and returns the objects not yet copied,
+// or changed since they were. Nothing is copied.
+func (r *Relocator) Plan(ctx context.Context) (*Report, error) {
+	rep := &Report{Copies: []Copy{}}
+	sums, err := r.digests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, bucket := range r.Buckets {
+		err := r.Source.ListObjects(ctx, bucket, "", func(o s3.ObjectInfo) error {
+			from := storage.Location{Bucket: bucket, Key: o.Key}
+			to := storage.Location{Bucket: r.bucket(bucket), Key: o.Key}
+			var c copied
+			err := r.State.Get(ctx, copiesTable, from.Bucket+"/"+from.Key, &c)
+			switch {
+			case err == nil && c.To == to && c.Size == o.Size && c.ETag == o.ETag:
+				rep.Copied++
+				return nil
+			case err != nil && !errors.Is(err, state.ErrNotFound):
+				return err
+			}
+			if slices.Contains(archived, o.StorageClass) {
+				info, err := r.Source.HeadObject(ctx, bucket, o.Key)
+				if err != nil {
+					return err
+				}
+				if !info.Restored() {
+					rep.Archived = append(rep.Archived, from)
+					return nil
+				}
+			}
+			rep.Copies = append(rep.Copies, Copy{From: from, To: to, Size: o.Size, ETag: o.ETag, StorageClass: o.StorageClass, SHA256: sums[from]})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", bucket, err)
+		}
+	}
+	return rep, nil
+}
+
+// digests returns the SHA-256 digests the manifests record for the
+// objects in the source buckets, by location.
+func (r *Relocator) digests(ctx context.Context) (map[storage.Location]string, error) {
+	sums := make(map[storage.Location]string)
+	if r.Datasets == nil {
+		return sums, nil
+	}
+	datasets, err := r.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	add := func(bucket, key, sum string) {
+		if sum != "" && slices.Contains(r.Buckets, bucket) {
+			sums[storage.Location{Bucket: bucket, Key: key}] = strings.ToLower(sum)
+		}
+	}
+	for _, d := range datasets {
+		for _, v := range d.Versions {
+			for _, f := range v.Files {
+				add(f.Bucket, f.Key, f.SHA256)
+				for _, dv := range f.Derivatives {
+					add(dv.Bucket, dv.Key, dv.SHA256)
+				}
+			}
+		}
+	}
+	return sums, nil
+}
+
+// Copy copies and verifies the objects of rep, recording each verified
+// copy. A failed copy is reported in the result and the rest are still
+// copied.
+func (r *Relocator) Copy(ctx context.Context, rep *Report) (*Result, error) {
+	res := &Result{}
+	for _, c := range rep.Copies {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		sum, err := r.copy(ctx, c)
+		if err != nil {
+			if res.Failed == nil {
+				res.Failed = make(map[string]string)
+			}
+			res.Failed[c.From.String()] = err.Error()
+			continue
+		}
+		rec := copied{To: c.To, Size: c.Size, ETag: c.ETag, SHA256: sum, Time: time.Now().UTC()}
+		if err := r.State.Put(ctx, copiesTable, c.From.Bucket+"/"+c.From.Key, rec); err != nil {
+			return res, err
+		}
+		res.Copied++
+		res.Bytes += c.Size
+	}
+	return res, nil
+}
+
+// copy copies the object of c and verifies the copy, returning its
+// base64-encoded SHA-256 digest.
+func (r *Relocator) copy(ctx context.Context, c Copy) (string, error) {
+	info, err := r.Source.HeadObject(ctx, c.From.Bucket, c.From.Key)
+	if err != nil {
+		return "", fmt.Errorf("failed to read: %w", err)
+	}
+	body, err := r.Source.GetObject(ctx, c.From.Bucket, c.From.Key)
+	if err != nil {
+		return "", fmt.Errorf("failed to read: %w", err)
+	}
+	defer body.Close()
+	// Restored copies of archived objects are stored in the target's
+	// default class.
+	class := c.StorageClass
+	if slices.Contains(archived, class) {
+		class = ""
+	}
+	h := sha256.New()
+	opts := s3.PutOptions{ContentType: info.ContentType, StorageClass: class, Size: c.Size}
+	n, err := r.Target.Upload(ctx, c.To.Bucket, c.To.Key, io.TeeReader(body, h), opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy to %s: %w", c.To, err)
+	}
+	if n != c.Size {
+		return "", fmt.Errorf("copied %d of %d bytes to %s", n, c.Size, c.To)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); c.SHA256 != "" && got != c.SHA256 {
+		return "", fmt.Errorf("the source has SHA-256 %s, but its manifest records %s", got, c.SHA256)
+	}
+	sum := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	if err := r.verify(ctx, c.To, sum); err != nil {
+		return "", err
+	}
+	return sum, nil
+}
+
+// verify checks that the object at loc has the digest sum: the digest
+// the target stored with it if it has one for the whole object, and
+// otherwise that of its content read back.
+func (r *Relocator) verify(ctx context.Context, loc storage.Location, sum string) error {
+	attrs, err := r.Target.GetObjectAttributes(ctx, loc.Bucket, loc.Key)
+	if err != nil {
+		return fmt.Errorf("failed to verify %s: %w", loc, err)
+	}
+	got := attrs.Checksum.SHA256
+	if got == "" || strings.Contains(got, "-") {
+		body, err := r.Target.GetObject(ctx, loc.Bucket, loc.Key)
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %w", loc, err)
+		}
+		defer body.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, body); err != nil {
+			return fmt.Errorf("failed to verify %s: %w", loc, err)
+		}
+		got = base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+	if got != sum {
+		return fmt.Errorf("the copy at %s has SHA-256 %s, want %s", loc, got, sum)
+	}
+	return nil
+}
+
+// Rewrite points the manifests' files and derivatives in the source
+// buckets at their copies, then republishes the landing pages and
+// identifier records of the published datasets, returning the number of
+// manifests changed. It refuses while objects are left to copy. Failures
+// to republish are reported with how to retry, after the remaining
+// datasets are updated.
+func (r *Relocator) Rewrite(ctx context.Context) (int, error) {
+	rep, err := r.Plan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if left := len(rep.Copies) + len(rep.Archived); left > 0 {
+		return 0, fmt.Errorf("%d objects are not copied yet; copy them before rewriting the manifests", left)
+	}
+
+	datasets, err := r.Datasets.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	var errs []error
+	for _, d := range datasets {
+		if r.rewrite(d) {
+			if err := r.Datasets.Put(ctx, d); err != nil {
+				return changed, err
+			}
+			changed++
+		}
+		if d.State != dataset.StatePublished {
+			continue
+		}
+		if r.PIDs != nil && (d.DOI != "" || d.ARK != "" || d.Handle != "") {
+			if err := r.PIDs.Update(ctx, d); err != nil {
+				errs = append(errs, fmt.Errorf("%s moved, but %w; retry with 'aperture pid update %s'", d.ID, err, d.ID))
+			}
+		}
+		if r.Pages != nil {
+			if _, err := r.Pages.Publish(ctx, d.ID); err != nil {
+				errs = append(errs, fmt.Errorf("%s moved, but %w; retry with 'aperture pages rebuild'", d.ID, err))
+			}
+		}
+	}
+	if r.Log != nil {
+		details := map[string]string{"buckets": strings.Join(r.Buckets, ","), "manifests": fmt.Sprint(changed)}
+		if r.TargetPrefix != "" {
+			details["prefix"] = r.TargetPrefix
+		}
+		if err := audit.Record(ctx, r.Log, "storage.migrate", "", details); err != nil {
+			return changed, err
+		}
+	}
+	return changed, errors.Join(errs...)
+}
+
+// rewrite points d's files in the source buckets at the target ones,
+// reporting whether any changed.
+func (r *Relocator) rewrite(d *dataset.Dataset) bool {
+	changed := false
+	move := func(bucket *string) {
+		if !slices.Contains(r.Buckets, *bucket) {
+			return
+		}
+		if to := r.bucket(*bucket); to != *bucket {
+			*bucket = to
+			changed = true
+		}
+	}
+	for i := range d.Versions {
+		for j := range d.Versions[i].Files {
+			f := &d.Versions[i].Files[j]
+			move(&f.Bucket)
+			for k := range f.Derivatives {
+				move(&f.Derivatives[k].Bucket)
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,225 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relocation
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/fsblob"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// recorder records the datasets whose pages and identifiers are
+// republished.
+type recorder struct {
+	pages, pids []string
+}
+
+func (r *recorder) Publish(_ context.Context, id string) (*landing.Change, error) {
+	r.pages = append(r.pages, id)
+	return &landing.Change{}, nil
+}
+
+func (r *recorder) Update(_ context.Context, d *dataset.Dataset) error {
+	r.pids = append(r.pids, d.ID)
+	return nil
+}
+
+// corrupting is a target that stores a damaged copy of every upload.
+type corrupting struct {
+	*fsblob.Client
+}
+
+func (c corrupting) Upload(ctx context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	damaged := bytes.Clone(b)
+	damaged[0] ^= 0xff
+	if _, err := c.Client.Upload(ctx, bucket, key, bytes.NewReader(damaged), opts); err != nil {
+		return 0, err
+	}
+	return int64(len(b)), nil
+}
+
+func newStore(t *testing.T) *fsblob.Client {
+	t.Helper()
+	c, err := fsblob.NewClient(fsblob.Options{Root: t.TempDir(), URL: "http://files.example", Secret: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRelocator(t *testing.T) {
+	ctx := context.Background()
+	source, target := newStore(t), newStore(t)
+	for key, body := range map[string]string{"ds-1/v1/a.csv": "a,b\n1,2\n", "ds-1/v1/b.txt": "notes"} {
+		if err := source.PutObject(ctx, "ap-public-media", key, []byte(body), "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	d := &dataset.Dataset{
+		ID: "ds-1", DOI: "10.5555/ds-1", State: dataset.StatePublished, Access: storage.AccessPublic,
+		Versions: []dataset.Version{{Number: 1, Files: []dataset.File{
+			{Path: "a.csv", Bucket: "ap-public-media", Key: "ds-1/v1/a.csv", Size: 8},
+			{Path: "b.txt", Bucket: "ap-public-media", Key: "ds-1/v1/b.txt", Size: 5},
+		}}},
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	rec := &recorder{}
+	log := &audit.MemoryLog{}
+	r := &Relocator{
+		Source: source, Target: target,
+		Buckets: []string{"ap-public-media"}, Prefix: "ap", TargetPrefix: "uni-ap",
+		Datasets: datasets, State: s, Pages: rec, PIDs: rec, Log: log,
+	}
+
+	rep, err := r.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(rep.Copies) != 2 || rep.Bytes() != 13 || rep.Copies[0].To.Bucket != "uni-ap-public-media" {
+		t.Fatalf("Plan() = %+v", rep)
+	}
+	if _, err := r.Rewrite(ctx); err == nil {
+		t.Error("Rewrite() before copying succeeded")
+	}
+
+	res, err := r.Copy(ctx, rep)
+	if err != nil || res.Copied != 2 || len(res.Failed) != 0 {
+		t.Fatalf("Copy() = %+v, %v", res, err)
+	}
+	if _, err := target.HeadObject(ctx, "uni-ap-public-media", "ds-1/v1/a.csv"); err != nil {
+		t.Errorf("copy missing from target: %v", err)
+	}
+
+	// A re-run copies only what changed since.
+	if err := source.PutObject(ctx, "ap-public-media", "ds-1/v1/b.txt", []byte("edited notes"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	rep, err = r.Plan(ctx)
+	if err != nil || len(rep.Copies) != 1 || rep.Copied != 1 || rep.Copies[0].From.Key != "ds-1/v1/b.txt" {
+		t.Fatalf("Plan() after a change = %+v, %v", rep, err)
+	}
+	if res, err := r.Copy(ctx, rep); err != nil || res.Copied != 1 {
+		t.Fatalf("Copy() = %+v, %v", res, err)
+	}
+
+	changed, err := r.Rewrite(ctx)
+	if err != nil || changed != 1 {
+		t.Fatalf("Rewrite() = %d, %v", changed, err)
+	}
+	got, err := datasets.Get(ctx, "ds-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range got.Versions[0].Files {
+		if f.Bucket != "uni-ap-public-media" {
+			t.Errorf("file %s is in %s after Rewrite()", f.Path, f.Bucket)
+		}
+	}
+	if len(rec.pages) != 1 || len(rec.pids) != 1 {
+		t.Errorf("republished pages %v and identifiers %v, want ds-1", rec.pages, rec.pids)
+	}
+	if entries, _ := log.Entries(ctx); len(entries) != 1 || entries[0].Action != "storage.migrate" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+}
+
+func TestRelocatorVerifies(t *testing.T) {
+	ctx := context.Background()
+	source := newStore(t)
+	if err := source.PutObject(ctx, "ap-public-media", "k", []byte("content"), ""); err != nil {
+		t.Fatal(err)
+	}
+	r := &Relocator{Source: source, Target: corrupting{newStore(t)}, Buckets: []string{"ap-public-media"}, State: state.NewMemoryStore()}
+	rep, err := r.Plan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.Copy(ctx, rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Copied != 0 || len(res.Failed) != 1 {
+		t.Errorf("Copy() to a corrupting target = %+v, want a failure", res)
+	}
+	if rep, _ := r.Plan(ctx); len(rep.Copies) != 1 {
+		t.Errorf("failed copy recorded as done: %+v", rep)
+	}
+}
+
+func TestRelocatorChecksManifest(t *testing.T) {
+	ctx := context.Background()
+	source, target := newStore(t), newStore(t)
+	// The source object was damaged after its manifest recorded the
+	// digest of "content".
+	if err := source.PutObject(ctx, "ap-public-media", "ds-1/v1/k", []byte("c0ntent"), ""); err != nil {
+		t.Fatal(err)
+	}
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	d := &dataset.Dataset{ID: "ds-1", Versions: []dataset.Version{{Number: 1, Files: []dataset.File{{
+		Path: "k", Bucket: "ap-public-media", Key: "ds-1/v1/k", Size: 7,
+		SHA256: "ED7002B439E9AC845F22357D822BAC1444730FBDB6016D3EC9432297B9EC9F73",
+	}}}}}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	r := &Relocator{Source: source, Target: target, Buckets: []string{"ap-public-media"}, Datasets: datasets, State: s}
+	rep, err := r.Plan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.Copy(ctx, rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Copied != 0 || len(res.Failed) != 1 {
+		t.Errorf("Copy() of a corrupt source = %+v, want a failure", res)
+	}
+	if rep, _ := r.Plan(ctx); len(rep.Copies) != 1 {
+		t.Errorf("corrupt copy recorded as done: %+v", rep)
+	}
+	if _, err := r.Rewrite(ctx); err == nil {
+		t.Error("Rewrite() with a corrupt object succeeded")
+	}
+
+	// Once the object is restored, it copies.
+	if err := source.PutObject(ctx, "ap-public-media", "ds-1/v1/k", []byte("content"), ""); err != nil {
+		t.Fatal(err)
+	}
+	rep, err = r.Plan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := r.Copy(ctx, rep); err != nil || res.Copied != 1 {
+		t.Errorf("Copy() of the restored object = %+v, %v", res, err)
+	}
+}
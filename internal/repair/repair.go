@@ -0,0 +1,440 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package repair replaces stored objects that fixity checks found
+// corrupt or missing with their offsite preservation copies.
+//
+// Each run works through the damaged objects. An object's encrypted
+// copy is read from the replica bucket; a copy archived in Glacier or
+// Glacier Deep Archive is restored first, which takes hours, so the
+// run requests the restore and a later run completes the repair. The
+// copy is decrypted and uploaded beside the damaged object, and only
+// once its size and digests match the manifest is it copied over the
+// damaged object. The repair is recorded as a PREMIS recovery event
+// linking the copy, and the object is checked again so that its fixity
+// result reflects the repair.
+package repair
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/blake3"
+	"github.com/scttfrdmn/aperture/internal/fixity"
+	"github.com/scttfrdmn/aperture/internal/premis"
+	"github.com/scttfrdmn/aperture/internal/replica"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// repairsTable holds the repair of each damaged object, keyed by
+// bucket/key.
+const repairsTable = "repairs"
+
+// stagingSuffix is appended to the key of a damaged object to stage
+// its restored copy.
+const stagingSuffix = ".aperture-repair"
+
+// DefaultRestoreDays is how long restored copies stay readable by
+// default.
+const DefaultRestoreDays = 7
+
+// archived are the storage classes whose objects cannot be read
+// without a restore.
+var archived = []string{"GLACIER", "DEEP_ARCHIVE"}
+
+// Archive reads copies from the replica bucket. *s3.Client implements
+// it.
+type Archive interface {
+	HeadObject(ctx context.Context, bucket, key string) (s3.ObjectInfo, error)
+	RestoreObject(ctx context.Context, bucket, key string, days int, tier string) error
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// Store replaces damaged objects in the repository. *s3.Client
+// implements it.
+type Store interface {
+	Upload(ctx context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error)
+	CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
+	DeleteObject(ctx context.Context, bucket, key string) error
+}
+
+// EventLog records preservation events. *premis.Log implements it.
+type EventLog interface {
+	Record(ctx context.Context, events ...premis.Event) error
+}
+
+// Status is the state of a repair.
+type Status string
+
+// Statuses.
+const (
+	// StatusNoCopy objects have no preservation copy to repair from
+	StatusNoCopy Status = "no copy"
+
+	// StatusRestore objects have an archived copy whose restore a run
+	// would request; only planned
+	StatusRestore Status = "restore"
+
+	// StatusRestoring objects wait for the restore of their copies
+	StatusRestoring Status = "restoring"
+
+	// StatusReady objects have a readable copy a run would repair
+	// them from; only planned
+	StatusReady Status = "ready"
+
+	// StatusRepaired objects were replaced by their copies and verify
+	StatusRepaired Status = "repaired"
+
+	// StatusFailed objects could not be repaired from their copies
+	StatusFailed Status = "failed"
+)
+
+// Repair is the repair of a damaged object.
+type Repair struct {
+	Bucket string       `json:"bucket"`
+	Key    string       `json:"key"`
+	Refs   []fixity.Ref `json:"refs"`
+
+	// Damage is the object's fixity status when the repair began
+	Damage fixity.Status `json:"damage"`
+
+	// Copy is the URI of the object's encrypted copy
+	Copy string `json:"copy,omitempty"`
+
+	Status Status `json:"status"`
+
+	// Detail explains a failed or impossible repair
+	Detail string `json:"detail,omitempty"`
+
+	// RestoreRequested is when the restore of an archived copy was
+	// requested
+	RestoreRequested *time.Time `json:"restoreRequested,omitempty"`
+
+	// Repaired is when the object was replaced
+	Repaired *time.Time `json:"repaired,omitempty"`
+
+	Updated time.Time `json:"updated"`
+}
+
+// Options select the objects to repair.
+type Options struct {
+	// Dataset repairs the objects of one dataset, ID or persistent
+	// identifier
+	Dataset string
+
+	// Bucket and Key repair one object
+	Bucket, Key string
+
+	// Limit is the most objects to replace; 0 for no limit
+	Limit int
+
+	// DryRun plans the repairs without requesting restores or
+	// replacing objects
+	DryRun bool
+}
+
+// Run summarizes a run.
+type Run struct {
+	Repairs []Repair `json:"repairs"`
+
+	Repaired  int `json:"repaired"`
+	Restoring int `json:"restoring"`
+	Failed    int `json:"failed"`
+	NoCopy    int `json:"noCopy"`
+
+	// Remaining counts the repairs the limit left for a later run
+	Remaining int `json:"remaining"`
+}
+
+// Repairer repairs damaged objects from their preservation copies.
+type Repairer struct {
+	// Checker finds the damaged objects and checks repaired ones
+	Checker *fixity.Checker
+
+	// Replica records the copies and holds the key decrypting them
+	Replica *replica.Replicator
+
+	// Archive reads the replica bucket; Store replaces objects
+	Archive Archive
+	Store   Store
+
+	// State holds the repairs
+	State state.Store
+
+	// Events records a recovery event for every replacement attempted;
+	// skipped if nil
+	Events EventLog
+
+	// Tier is the retrieval tier of restores; s3.TierStandard if empty
+	Tier string
+
+	// RestoreDays is how long restored copies stay readable;
+	// DefaultRestoreDays if zero
+	RestoreDays int
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Run repairs the damaged objects selected by opts, by bucket and key,
+// and returns their repairs.
+func (r *Repairer) Run(ctx context.Context, opts Options) (*Run, error) {
+	rep, err := r.Checker.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id := ""
+	if opts.Dataset != "" {
+		d, err := r.Checker.Datasets.Resolve(ctx, opts.Dataset)
+		if err != nil {
+			return nil, err
+		}
+		id = d.ID
+	}
+
+	run := &Run{Repairs: []Repair{}}
+	replaced := 0
+	for _, p := range rep.Problems {
+		if !p.Status.Failed() ||
+			(id != "" && !slices.ContainsFunc(p.Refs, func(ref fixity.Ref) bool { return ref.Dataset == id })) ||
+			(opts.Key != "" && (p.Bucket != opts.Bucket || p.Key != opts.Key)) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		rp, err := r.repair(ctx, p, opts.DryRun || (opts.Limit > 0 && replaced >= opts.Limit))
+		if err != nil {
+			return nil, err
+		}
+		switch rp.Status {
+		case StatusRepaired:
+			run.Repaired++
+			replaced++
+		case StatusFailed:
+			run.Failed++
+			replaced++
+		case StatusRestoring:
+			run.Restoring++
+		case StatusNoCopy:
+			run.NoCopy++
+		case StatusReady, StatusRestore:
+			if !opts.DryRun {
+				run.Remaining++
+			}
+		}
+		run.Repairs = append(run.Repairs, *rp)
+	}
+	if opts.Key != "" && len(run.Repairs) == 0 {
+		return nil, fmt.Errorf("s3://%s/%s is not recorded as corrupt or missing", opts.Bucket, opts.Key)
+	}
+	return run, nil
+}
+
+// repair advances the repair of the damaged object p as far as it
+// can, only planning it if plan is set, and records it.
+func (r *Repairer) repair(ctx context.Context, p fixity.Result, plan bool) (*Repair, error) {
+	rp := &Repair{Bucket: p.Bucket, Key: p.Key}
+	err := r.State.Get(ctx, repairsTable, p.Bucket+"/"+p.Key, rp)
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("failed to read repair of s3://%s/%s: %w", p.Bucket, p.Key, err)
+	}
+	if rp.Status == StatusRepaired || errors.Is(err, state.ErrNotFound) {
+		// Damaged again since its last repair: start afresh.
+		*rp = Repair{Bucket: p.Bucket, Key: p.Key}
+	}
+	rp.Refs, rp.Damage, rp.Detail = p.Refs, p.Status, ""
+
+	rp.Status, err = r.advance(ctx, p, rp, plan)
+	if err != nil {
+		rp.Status, rp.Detail = StatusFailed, err.Error()
+	}
+	if plan {
+		return rp, nil
+	}
+	rp.Updated = r.now().UTC()
+	if err := r.State.Put(ctx, repairsTable, p.Bucket+"/"+p.Key, rp); err != nil {
+		return nil, fmt.Errorf("failed to record repair of s3://%s/%s: %w", p.Bucket, p.Key, err)
+	}
+	return rp, nil
+}
+
+// advance finds the copy of p, restores it if it is archived, and
+// replaces p with it once it can be read, returning the status reached.
+func (r *Repairer) advance(ctx context.Context, p fixity.Result, rp *Repair, plan bool) (Status, error) {
+	c, err := r.Replica.Object(ctx, p.Bucket, p.Key)
+	if errors.Is(err, replica.ErrNotCopied) {
+		rp.Detail = "no preservation copy; replace the object from another source and check it with 'aperture fixity run --dataset " + p.Refs[0].Dataset + "'"
+		return StatusNoCopy, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	rp.Copy = r.Replica.URI(c.Copy)
+	if want := replica.KeyID(r.Replica.Key); c.KeyID != want {
+		return "", fmt.Errorf("the copy is encrypted with key %s, not the configured key %s", c.KeyID, want)
+	}
+
+	info, err := r.Archive.HeadObject(ctx, r.Replica.Bucket, c.Copy)
+	if errors.Is(err, s3.ErrNotFound) {
+		return "", fmt.Errorf("the copy is missing from the replica bucket")
+	}
+	if err != nil {
+		return "", err
+	}
+	if slices.Contains(archived, info.StorageClass) && !info.Restored() {
+		switch {
+		case info.Restoring():
+			return StatusRestoring, nil
+		case plan:
+			return StatusRestore, nil
+		}
+		tier := cmp.Or(r.Tier, s3.TierStandard)
+		if err := r.Archive.RestoreObject(ctx, r.Replica.Bucket, c.Copy, cmp.Or(r.RestoreDays, DefaultRestoreDays), tier); err != nil {
+			return "", fmt.Errorf("failed to request restore of the copy: %w", err)
+		}
+		now := r.now().UTC()
+		rp.RestoreRequested = &now
+		return StatusRestoring, nil
+	}
+	if plan {
+		return StatusReady, nil
+	}
+
+	replaceErr := r.replace(ctx, p, c)
+	if err := r.event(ctx, p, rp.Copy, replaceErr); err != nil {
+		return "", err
+	}
+	if replaceErr != nil {
+		return "", replaceErr
+	}
+	now := r.now().UTC()
+	rp.Repaired = &now
+
+	res, err := r.Checker.Recheck(ctx, p.Bucket, p.Key)
+	if err != nil {
+		return "", err
+	}
+	if res.Status != fixity.StatusOK {
+		return "", fmt.Errorf("the replaced object is %s after repair: %s", res.Status, res.Detail)
+	}
+	return StatusRepaired, nil
+}
+
+// replace decrypts the copy c of p beside p, and copies it over p once
+// it matches the manifest.
+func (r *Repairer) replace(ctx context.Context, p fixity.Result, c *replica.Object) error {
+	body, err := r.Archive.GetObject(ctx, r.Replica.Bucket, c.Copy)
+	if err != nil {
+		return fmt.Errorf("failed to read the copy: %w", err)
+	}
+	defer body.Close()
+	plain, err := replica.Decrypt(body, r.Replica.Key)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt the copy: %w", err)
+	}
+	contentType, err := r.contentType(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	staging := p.Key + stagingSuffix
+	h, b3 := sha256.New(), blake3.New()
+	n, err := r.Store.Upload(ctx, p.Bucket, staging, io.TeeReader(plain, io.MultiWriter(h, b3)), s3.PutOptions{ContentType: contentType, Size: p.Size})
+	if err != nil {
+		_ = r.Store.DeleteObject(ctx, p.Bucket, staging)
+		return fmt.Errorf("failed to stage the restored copy: %w", err)
+	}
+	sum, b3sum := hex.EncodeToString(h.Sum(nil)), hex.EncodeToString(b3.Sum(nil))
+	switch {
+	case n != p.Size:
+		err = fmt.Errorf("the restored copy is %d bytes, want %d", n, p.Size)
+	case p.SHA256 != "" && sum != strings.ToLower(p.SHA256):
+		err = fmt.Errorf("the restored copy does not match its recorded digest: content is sha256:%s", sum)
+	case p.BLAKE3 != "" && b3sum != strings.ToLower(p.BLAKE3):
+		err = fmt.Errorf("the restored copy does not match its recorded digest: content is blake3:%s", b3sum)
+	}
+	if err == nil {
+		if err = r.Store.CopyObject(ctx, p.Bucket, staging, p.Bucket, p.Key); err != nil {
+			err = fmt.Errorf("failed to replace the damaged object: %w", err)
+		}
+	}
+	if derr := r.Store.DeleteObject(ctx, p.Bucket, staging); derr != nil && err == nil {
+		err = fmt.Errorf("the object is repaired, but failed to delete s3://%s/%s: %w", p.Bucket, staging, derr)
+	}
+	return err
+}
+
+// contentType returns the media type recorded for the file p stores.
+func (r *Repairer) contentType(ctx context.Context, p fixity.Result) (string, error) {
+	for _, ref := range p.Refs {
+		d, err := r.Checker.Datasets.Get(ctx, ref.Dataset)
+		if err != nil {
+			return "", err
+		}
+		v := d.Version(ref.Version)
+		if v == nil {
+			continue
+		}
+		for _, f := range v.Files {
+			if f.Bucket == p.Bucket && f.Key == p.Key {
+				return f.ContentType, nil
+			}
+			for _, dv := range f.Derivatives {
+				if dv.Bucket == p.Bucket && dv.Key == p.Key {
+					return dv.ContentType, nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// event records the attempt to replace p with its copy, which failed
+// with err if it is not nil.
+func (r *Repairer) event(ctx context.Context, p fixity.Result, copyURI string, err error) error {
+	if r.Events == nil {
+		return nil
+	}
+	e := premis.Event{
+		Type:          premis.TypeRecovery,
+		Time:          r.now().UTC(),
+		Bucket:        p.Bucket,
+		Key:           p.Key,
+		Detail:        "replaced the " + string(p.Status) + " object with its decrypted preservation copy",
+		Outcome:       premis.OutcomeSuccess,
+		OutcomeDetail: "verified against the manifest before replacing",
+		Links:         []premis.Link{{Role: premis.RoleSource, URI: copyURI}},
+	}
+	if err != nil {
+		e.Outcome, e.OutcomeDetail = premis.OutcomeFailure, err.Error()
+	}
+	return r.Events.Record(ctx, e)
+}
+
+func (r *Repairer) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,207 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repair
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/fixity"
+	"github.com/scttfrdmn/aperture/internal/premis"
+	"github.com/scttfrdmn/aperture/internal/replica"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// fakeS3 is an in-memory S3 holding both the repository's buckets and
+// the replica bucket.
+type fakeS3 struct {
+	objects  map[string][]byte
+	types    map[string]string
+	classes  map[string]string
+	restores map[string]string
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: map[string][]byte{}, types: map[string]string{}, classes: map[string]string{}, restores: map[string]string{}}
+}
+
+func (f *fakeS3) GetObjectAttributes(_ context.Context, bucket, key string) (s3.Attributes, error) {
+	b, ok := f.objects[bucket+"/"+key]
+	if !ok {
+		return s3.Attributes{}, s3.ErrNotFound
+	}
+	return s3.Attributes{ObjectSize: int64(len(b))}, nil
+}
+
+func (f *fakeS3) HeadObject(_ context.Context, bucket, key string) (s3.ObjectInfo, error) {
+	b, ok := f.objects[bucket+"/"+key]
+	if !ok {
+		return s3.ObjectInfo{}, s3.ErrNotFound
+	}
+	return s3.ObjectInfo{Key: key, Size: int64(len(b)), StorageClass: f.classes[bucket+"/"+key], Restore: f.restores[bucket+"/"+key]}, nil
+}
+
+func (f *fakeS3) RestoreObject(_ context.Context, bucket, key string, days int, tier string) error {
+	f.restores[bucket+"/"+key] = `ongoing-request="true"`
+	return nil
+}
+
+func (f *fakeS3) GetObject(_ context.Context, bucket, key string) (io.ReadCloser, error) {
+	b, ok := f.objects[bucket+"/"+key]
+	if !ok {
+		return nil, s3.ErrNotFound
+	}
+	if f.classes[bucket+"/"+key] == "DEEP_ARCHIVE" && !strings.Contains(f.restores[bucket+"/"+key], `ongoing-request="false"`) {
+		return nil, &s3.Error{StatusCode: 403, Code: "InvalidObjectState"}
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (f *fakeS3) Upload(_ context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	f.objects[bucket+"/"+key], f.types[bucket+"/"+key], f.classes[bucket+"/"+key] = b, opts.ContentType, opts.StorageClass
+	return int64(len(b)), nil
+}
+
+func (f *fakeS3) CopyObject(_ context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	b, ok := f.objects[srcBucket+"/"+srcKey]
+	if !ok {
+		return s3.ErrNotFound
+	}
+	f.objects[dstBucket+"/"+dstKey], f.types[dstBucket+"/"+dstKey] = slices.Clone(b), f.types[srcBucket+"/"+srcKey]
+	return nil
+}
+
+func (f *fakeS3) DeleteObject(_ context.Context, bucket, key string) error {
+	delete(f.objects, bucket+"/"+key)
+	return nil
+}
+
+func file(path string, content []byte) dataset.File {
+	sum := sha256.Sum256(content)
+	return dataset.File{Path: path, Bucket: "pub", Key: "ds/" + path, Size: int64(len(content)), SHA256: hex.EncodeToString(sum[:]), ContentType: "text/csv"}
+}
+
+func TestRepairer(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	fs := newFakeS3()
+	a, b, c := []byte("a,b\n1,2\n"), []byte("b\n"), []byte("c\n")
+	fs.objects["pub/ds/a.csv"], fs.objects["pub/ds/b.csv"] = a, b
+	d := &dataset.Dataset{ID: "ds-1", State: dataset.StatePublished, CreatedAt: now, Versions: []dataset.Version{
+		{Number: 1, PublishedAt: &now, Files: []dataset.File{file("a.csv", a), file("b.csv", b)}},
+	}}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	events := &premis.Log{State: s}
+	key := bytes.Repeat([]byte{7}, replica.KeySize)
+	rep := &replica.Replicator{State: s, Datasets: datasets, Source: fs, Target: fs, Bucket: "offsite", StorageClass: "DEEP_ARCHIVE", Key: key, Now: func() time.Time { return now }}
+	if _, err := rep.Run(ctx, replica.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// c.csv is published after the copy was made, and a.csv, b.csv, and
+	// c.csv are then damaged.
+	fs.objects["pub/ds/c.csv"] = c
+	d.Versions = append(d.Versions, dataset.Version{Number: 2, PublishedAt: &now, Files: []dataset.File{file("a.csv", a), file("b.csv", b), file("c.csv", c)}})
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	fs.objects["pub/ds/a.csv"] = []byte("a,b\n1,3\n")
+	delete(fs.objects, "pub/ds/b.csv")
+	fs.objects["pub/ds/c.csv"] = []byte("x\n")
+	checker := &fixity.Checker{Objects: fs, Datasets: datasets, State: s, Events: events, Now: func() time.Time { return now }}
+	if run, err := checker.Run(ctx, fixity.Options{}); err != nil || len(run.Failures) != 3 {
+		t.Fatalf("fixity Run() = %+v, %v, want 3 failures", run, err)
+	}
+
+	r := &Repairer{Checker: checker, Replica: rep, Archive: fs, Store: fs, State: s, Events: events, Now: func() time.Time { return now }}
+	statuses := func(run *Run) []Status {
+		var out []Status
+		for _, rp := range run.Repairs {
+			out = append(out, rp.Status)
+		}
+		return out
+	}
+	run, err := r.Run(ctx, Options{DryRun: true})
+	if err != nil || !slices.Equal(statuses(run), []Status{StatusRestore, StatusRestore, StatusNoCopy}) || len(fs.restores) != 0 {
+		t.Fatalf("planned Run() = %+v, %v, want restores planned and c.csv without a copy", run, err)
+	}
+
+	// The archived copies are restored before they are read.
+	run, err = r.Run(ctx, Options{})
+	if err != nil || run.Restoring != 2 || run.NoCopy != 1 || run.Repairs[0].RestoreRequested == nil {
+		t.Fatalf("Run() = %+v, %v, want 2 restores requested", run, err)
+	}
+	if run, err = r.Run(ctx, Options{}); err != nil || run.Restoring != 2 {
+		t.Fatalf("Run() while restoring = %+v, %v", run, err)
+	}
+	for k := range fs.restores {
+		fs.restores[k] = `ongoing-request="false", expiry-date="Sun, 08 Jun 2025 00:00:00 GMT"`
+	}
+
+	run, err = r.Run(ctx, Options{Limit: 1})
+	if err != nil || run.Repaired != 1 || run.Remaining != 1 || run.Repairs[0].Status != StatusRepaired {
+		t.Fatalf("limited Run() = %+v, %v, want a.csv repaired", run, err)
+	}
+	if !bytes.Equal(fs.objects["pub/ds/a.csv"], a) || fs.types["pub/ds/a.csv"] != "text/csv" {
+		t.Errorf("a.csv = %q (%s), want the original content", fs.objects["pub/ds/a.csv"], fs.types["pub/ds/a.csv"])
+	}
+	if _, ok := fs.objects["pub/ds/a.csv"+stagingSuffix]; ok {
+		t.Error("staged copy of a.csv was left behind")
+	}
+	history, err := events.Events(ctx, "pub", "ds/a.csv")
+	if err != nil || len(history) < 2 {
+		t.Fatalf("a.csv events = %+v, %v", history, err)
+	}
+	if e := history[len(history)-2]; e.Type != premis.TypeRecovery || e.Outcome != premis.OutcomeSuccess || e.Links[0].URI != "s3://offsite/objects/pub/ds/a.csv" {
+		t.Errorf("recovery event = %+v", e)
+	}
+	if e := history[len(history)-1]; e.Type != premis.TypeFixityCheck || e.Outcome != premis.OutcomeSuccess {
+		t.Errorf("last event = %+v, want a passing fixity check", e)
+	}
+
+	// A damaged copy is refused and leaves the object as it was.
+	fs.objects["offsite/objects/pub/ds/b.csv"][len(fs.objects["offsite/objects/pub/ds/b.csv"])-1] ^= 1
+	run, err = r.Run(ctx, Options{Bucket: "pub", Key: "ds/b.csv"})
+	if err != nil || run.Failed != 1 || !strings.Contains(run.Repairs[0].Detail, replica.ErrCorrupt.Error()) {
+		t.Fatalf("Run(b.csv) = %+v, %v, want it failed", run, err)
+	}
+	if _, ok := fs.objects["pub/ds/b.csv"]; ok {
+		t.Error("b.csv was replaced from a damaged copy")
+	}
+	rep2, err := checker.Status(ctx)
+	if err != nil || rep2.Statuses[fixity.StatusOK] != 1 || rep2.Statuses[fixity.StatusMissing] != 1 || rep2.Statuses[fixity.StatusCorrupt] != 1 {
+		t.Errorf("fixity Status() = %+v, %v, want a.csv ok", rep2, err)
+	}
+	if _, err := r.Run(ctx, Options{Bucket: "pub", Key: "ds/a.csv"}); err == nil {
+		t.Error("Run() of a repaired object succeeded")
+	}
+}
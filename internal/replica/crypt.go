@@ -0,0 +1,199 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replica
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// KeySize is the size of the key copies are encrypted with.
+const KeySize = 32
+
+// Copies are encrypted in segments of segmentSize bytes with
+// AES-256-GCM under a key derived from the replica key and a random
+// salt, each segment's nonce counting segments and marking the last,
+// so that segments cannot be reordered or the copy truncated unnoticed.
+const (
+	segmentSize = 64 << 10
+	saltSize    = 16
+	keyIDSize   = 8
+	headerSize  = len(magic) + keyIDSize + saltSize
+	overhead    = 16
+)
+
+// magic begins every encrypted copy.
+const magic = "APREPL01"
+
+// ErrWrongKey is returned when decrypting a copy made with another
+// key.
+var ErrWrongKey = errors.New("copy was encrypted with another key")
+
+// ErrCorrupt is returned when an encrypted copy has been altered or
+// truncated.
+var ErrCorrupt = errors.New("encrypted copy is corrupt")
+
+// KeyID identifies key without revealing it: the hex of the first
+// bytes of its SHA-256 digest.
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:keyIDSize])
+}
+
+// EncryptedSize returns the size of the encrypted copy of n bytes.
+func EncryptedSize(n int64) int64 {
+	segments := max(1, (n+segmentSize-1)/segmentSize)
+	return int64(headerSize) + n + segments*overhead
+}
+
+// segmentCipher returns the cipher of the copy with salt.
+func segmentCipher(key, salt []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("replica key must be %d bytes", KeySize)
+	}
+	k, err := hkdf.Key(sha256.New, key, salt, "aperture replica", KeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// nonce returns the nonce of segment n.
+func nonce(n uint64, last bool) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b[3:11], n)
+	if last {
+		b[11] = 1
+	}
+	return b
+}
+
+// Encrypt returns a reader of the encrypted copy of r under key.
+func Encrypt(r io.Reader, key []byte) (io.Reader, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := segmentCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	header := append(append([]byte(magic), sum[:keyIDSize]...), salt...)
+	return &encrypter{r: r, aead: aead, in: make([]byte, segmentSize+1), out: header}, nil
+}
+
+// encrypter encrypts segment by segment, reading one byte ahead to
+// find the last segment.
+type encrypter struct {
+	r    io.Reader
+	aead cipher.AEAD
+	in   []byte
+	held int
+	n    uint64
+	out  []byte
+	done bool
+}
+
+func (e *encrypter) Read(p []byte) (int, error) {
+	for len(e.out) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		got, err := io.ReadFull(e.r, e.in[e.held:])
+		got += e.held
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return 0, err
+		}
+		size := min(got, segmentSize)
+		e.out = e.aead.Seal(e.out[:0], nonce(e.n, last), e.in[:size], nil)
+		e.held = copy(e.in, e.in[size:got])
+		e.n++
+		e.done = last
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+// Decrypt returns a reader of the content of the encrypted copy r. It
+// fails with ErrWrongKey if r was encrypted with another key, and with
+// ErrCorrupt once it reads a segment that was altered, or reaches the
+// end of a truncated copy.
+func Decrypt(r io.Reader, key []byte) (io.Reader, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(magic)]) != magic {
+		return nil, ErrCorrupt
+	}
+	sum := sha256.Sum256(key)
+	if !bytes.Equal(header[len(magic):len(magic)+keyIDSize], sum[:keyIDSize]) {
+		return nil, ErrWrongKey
+	}
+	aead, err := segmentCipher(key, header[len(magic)+keyIDSize:])
+	if err != nil {
+		return nil, err
+	}
+	return &decrypter{r: r, aead: aead, in: make([]byte, segmentSize+overhead+1)}, nil
+}
+
+// decrypter decrypts segment by segment, reading one byte ahead to
+// find the last segment.
+type decrypter struct {
+	r    io.Reader
+	aead cipher.AEAD
+	in   []byte
+	held int
+	n    uint64
+	out  []byte
+	done bool
+}
+
+func (d *decrypter) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		got, err := io.ReadFull(d.r, d.in[d.held:])
+		got += d.held
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return 0, err
+		}
+		size := min(got, segmentSize+overhead)
+		if d.out, err = d.aead.Open(d.out[:0], nonce(d.n, last), d.in[:size], nil); err != nil {
+			return 0, ErrCorrupt
+		}
+		d.held = copy(d.in, d.in[size:got])
+		d.n++
+		d.done = last
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replica
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func encrypt(t *testing.T, plain, key []byte) []byte {
+	t.Helper()
+	r, err := Encrypt(bytes.NewReader(plain), key)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading encrypted copy: %v", err)
+	}
+	return b
+}
+
+func decrypt(sealed, key []byte) ([]byte, error) {
+	r, err := Decrypt(bytes.NewReader(sealed), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestEncrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, KeySize)
+	for _, n := range []int{0, 1, segmentSize - 1, segmentSize, segmentSize + 1, 3*segmentSize + 17} {
+		plain := bytes.Repeat([]byte("abcdefg"), n/7+1)[:n]
+		sealed := encrypt(t, plain, key)
+		if int64(len(sealed)) != EncryptedSize(int64(n)) {
+			t.Errorf("len(Encrypt(%d bytes)) = %d, want %d", n, len(sealed), EncryptedSize(int64(n)))
+		}
+		got, err := decrypt(sealed, key)
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("Decrypt(Encrypt(%d bytes)) = %d bytes, %v", n, len(got), err)
+		}
+	}
+
+	plain := bytes.Repeat([]byte("x"), 2*segmentSize+5)
+	sealed := encrypt(t, plain, key)
+	if bytes.Equal(sealed, encrypt(t, plain, key)) {
+		t.Error("two copies of the same content are identical")
+	}
+	if _, err := decrypt(sealed, bytes.Repeat([]byte{8}, KeySize)); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Decrypt() with another key error = %v, want ErrWrongKey", err)
+	}
+	tampered := bytes.Clone(sealed)
+	tampered[headerSize+segmentSize+overhead+3] ^= 1
+	if _, err := decrypt(tampered, key); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Decrypt() of an altered copy error = %v, want ErrCorrupt", err)
+	}
+	if _, err := decrypt(sealed[:headerSize+2*(segmentSize+overhead)], key); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Decrypt() of a truncated copy error = %v, want ErrCorrupt", err)
+	}
+	if _, err := Encrypt(bytes.NewReader(plain), key[:16]); err == nil {
+		t.Error("Encrypt() with a short key succeeded")
+	}
+}
@@ -0,0 +1,548 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replica keeps a preservation copy of every published dataset
+// with an independent provider, for a 3-2-1 policy: a bucket in another
+// AWS account or region, typically in Glacier Deep Archive, or with an
+// S3-compatible provider such as Wasabi or Backblaze B2.
+//
+// Copies are encrypted before they leave the repository, with
+// AES-256-GCM under a key the provider never holds, and Decrypt
+// recovers them. Each stored object is copied once, to
+// objects/<bucket>/<key> in the replica bucket, however many versions
+// and datasets share it; once every object of a published version is
+// copied, the version's manifest, the dataset record, is copied to
+// datasets/<id>/v<n>.json, so the replica can be restored without the
+// repository's state.
+//
+// A dataset lags while a published version has no complete copy. Each
+// run records the number of lagging datasets and how long the oldest
+// has waited as the QueueDepth and QueueAge metrics of the "replicas"
+// queue, for a CloudWatch alarm, and Status reports the datasets
+// lagging longer than MaxLag.
+package replica
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/metrics"
+	"github.com/scttfrdmn/aperture/internal/premis"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// Tables used in the state store.
+const (
+	// objectsTable holds the copies of stored objects, keyed by
+	// bucket/key
+	objectsTable = "replica-objects"
+
+	// datasetsTable holds the copy of each dataset, keyed by ID
+	datasetsTable = "replicas"
+)
+
+// Queue is the queue name of the lag metrics.
+const Queue = "replicas"
+
+// DefaultMaxLag is how long a published version may go without a
+// complete copy by default.
+const DefaultMaxLag = 7 * 24 * time.Hour
+
+// ErrNotCopied is returned for a stored object without a copy.
+var ErrNotCopied = errors.New("object has no preservation copy")
+
+// Source reads the repository's stored objects. *s3.Client implements
+// it.
+type Source interface {
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// Target stores copies with the independent provider. *s3.Client
+// implements it.
+type Target interface {
+	Upload(ctx context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error)
+}
+
+// EventLog records preservation events. *premis.Log implements it.
+type EventLog interface {
+	Record(ctx context.Context, events ...premis.Event) error
+}
+
+// Object is the copy of a stored object.
+type Object struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Copy is the key of the encrypted copy in the replica bucket
+	Copy string `json:"copy"`
+
+	// KeyID identifies the key the copy is encrypted with
+	KeyID string `json:"keyId"`
+
+	CopiedAt time.Time `json:"copiedAt"`
+}
+
+// Copy is the state of a dataset's copy.
+type Copy struct {
+	Dataset string `json:"dataset"`
+
+	// Version is the latest version copied completely; 0 if none
+	Version int `json:"version"`
+
+	// CopiedAt is when Version was copied
+	CopiedAt *time.Time `json:"copiedAt,omitempty"`
+
+	// Error is why the last run failed to copy the next version
+	Error string `json:"error,omitempty"`
+}
+
+// Options limit a run.
+type Options struct {
+	// Dataset copies one dataset, ID or persistent identifier
+	Dataset string
+
+	// Limit is the most objects to copy; 0 for no limit
+	Limit int
+
+	// MaxBytes is the most bytes to copy; 0 for no limit
+	MaxBytes int64
+}
+
+// Run summarizes a run.
+type Run struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+
+	// Objects and Bytes count the objects copied
+	Objects int   `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+
+	// Versions counts the versions whose copies were completed
+	Versions int `json:"versions"`
+
+	// Failed counts the datasets whose copies failed
+	Failed int `json:"failed"`
+
+	// Failures are the copies that failed
+	Failures []Copy `json:"failures,omitempty"`
+
+	// Lagging counts the datasets still waiting for a complete copy
+	Lagging int `json:"lagging"`
+}
+
+// Replicator copies published datasets to the replica bucket.
+type Replicator struct {
+	// State holds the state of copies
+	State    state.Store
+	Datasets *dataset.Store
+
+	// Source reads the objects to copy; Target stores the copies
+	Source Source
+	Target Target
+
+	// Bucket is the replica bucket
+	Bucket string
+
+	// StorageClass is the storage class of copies, e.g. DEEP_ARCHIVE;
+	// the bucket's default if empty
+	StorageClass string
+
+	// Key encrypts copies; it must be KeySize bytes
+	Key []byte
+
+	// MaxLag is how long a published version may go without a complete
+	// copy; DefaultMaxLag if zero
+	MaxLag time.Duration
+
+	// Events records a replication event for every object copied;
+	// skipped if nil
+	Events EventLog
+
+	// Metrics records the lag after each run; skipped if nil
+	Metrics metrics.Recorder
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// URI returns the URI of the copy key in the replica bucket.
+func (r *Replicator) URI(key string) string {
+	return "s3://" + r.Bucket + "/" + key
+}
+
+// Run copies the objects and manifests of published versions not yet
+// copied, oldest dataset first, within opts, and records the lag.
+func (r *Replicator) Run(ctx context.Context, opts Options) (*Run, error) {
+	if len(r.Key) != KeySize {
+		return nil, fmt.Errorf("replica key must be %d bytes", KeySize)
+	}
+	run := &Run{Started: r.now().UTC()}
+	datasets, err := r.datasets(ctx, opts.Dataset)
+	if err != nil {
+		return nil, err
+	}
+	copied, err := r.objects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	budget := func(size int64) bool {
+		return (opts.Limit == 0 || run.Objects < opts.Limit) && (opts.MaxBytes == 0 || run.Bytes+size <= opts.MaxBytes)
+	}
+
+	for _, d := range datasets {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		c, err := r.copyOf(ctx, d.ID)
+		if err != nil {
+			return nil, err
+		}
+		c.Error = ""
+	versions:
+		for _, v := range pending(d, c) {
+			for _, obj := range stored(v) {
+				loc := obj.Bucket + "/" + obj.Key
+				if _, ok := copied[loc]; ok {
+					continue
+				}
+				if !budget(obj.Size) {
+					break versions
+				}
+				o, err := r.copy(ctx, obj)
+				if err != nil {
+					c.Error = fmt.Sprintf("version %d: %v", v.Number, err)
+					break versions
+				}
+				copied[loc] = o
+				run.Objects++
+				run.Bytes += o.Size
+			}
+			if err := r.manifest(ctx, d, v.Number); err != nil {
+				c.Error = fmt.Sprintf("version %d: %v", v.Number, err)
+				break
+			}
+			now := r.now().UTC()
+			c.Version, c.CopiedAt = v.Number, &now
+			run.Versions++
+		}
+		if c.Error != "" {
+			run.Failed++
+			run.Failures = append(run.Failures, *c)
+		}
+		if err := r.State.Put(ctx, datasetsTable, d.ID, c); err != nil {
+			return nil, fmt.Errorf("failed to record copy of %s: %w", d.ID, err)
+		}
+	}
+
+	rep, err := r.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	run.Lagging = len(rep.Pending)
+	if r.Metrics != nil {
+		r.Metrics.Record(metrics.Dimensions{"Queue": Queue},
+			metrics.Metric{Name: metrics.QueueDepth, Value: float64(len(rep.Pending)), Unit: metrics.Count},
+			metrics.Metric{Name: metrics.QueueAge, Value: rep.MaxLag.Seconds(), Unit: metrics.Seconds},
+		)
+	}
+	run.Finished = r.now().UTC()
+	return run, nil
+}
+
+// copy encrypts obj into the replica bucket, checking that the content
+// read matches its recorded digest, and records the copy.
+func (r *Replicator) copy(ctx context.Context, obj Object) (*Object, error) {
+	body, err := r.Source.GetObject(ctx, obj.Bucket, obj.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read s3://%s/%s: %w", obj.Bucket, obj.Key, err)
+	}
+	defer body.Close()
+	h := sha256.New()
+	enc, err := Encrypt(io.TeeReader(body, h), r.Key)
+	if err != nil {
+		return nil, err
+	}
+	obj.Copy = "objects/" + obj.Bucket + "/" + obj.Key
+	opts := s3.PutOptions{ContentType: "application/octet-stream", StorageClass: r.StorageClass, Size: EncryptedSize(obj.Size)}
+	n, err := r.Target.Upload(ctx, r.Bucket, obj.Copy, enc, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy s3://%s/%s: %w", obj.Bucket, obj.Key, err)
+	}
+	if n != EncryptedSize(obj.Size) {
+		return nil, fmt.Errorf("s3://%s/%s is not %d bytes as recorded", obj.Bucket, obj.Key, obj.Size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); obj.SHA256 != "" && sum != strings.ToLower(obj.SHA256) {
+		return nil, fmt.Errorf("s3://%s/%s does not match its recorded digest: content is sha256:%s", obj.Bucket, obj.Key, sum)
+	}
+	obj.KeyID, obj.CopiedAt = KeyID(r.Key), r.now().UTC()
+	if err := r.State.Put(ctx, objectsTable, obj.Bucket+"/"+obj.Key, obj); err != nil {
+		return nil, fmt.Errorf("failed to record copy of s3://%s/%s: %w", obj.Bucket, obj.Key, err)
+	}
+	if r.Events != nil {
+		err := r.Events.Record(ctx, premis.Event{
+			Type:          premis.TypeReplication,
+			Time:          obj.CopiedAt,
+			Bucket:        obj.Bucket,
+			Key:           obj.Key,
+			Detail:        "encrypted preservation copy with an independent provider",
+			Outcome:       premis.OutcomeSuccess,
+			OutcomeDetail: "key " + obj.KeyID,
+			Agent:         premis.SoftwareAgent,
+			Links:         []premis.Link{{Role: premis.RoleOutcome, URI: r.URI(obj.Copy)}},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &obj, nil
+}
+
+// manifest copies the record of d as the manifest of version n.
+func (r *Replicator) manifest(ctx context.Context, d *dataset.Dataset, n int) error {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	enc, err := Encrypt(bytes.NewReader(b), r.Key)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("datasets/%s/v%d.json", d.ID, n)
+	opts := s3.PutOptions{ContentType: "application/octet-stream", StorageClass: r.StorageClass}
+	if _, err := r.Target.Upload(ctx, r.Bucket, key, enc, opts); err != nil {
+		return fmt.Errorf("failed to copy manifest: %w", err)
+	}
+	return nil
+}
+
+// Lag is a dataset with a published version not yet copied.
+type Lag struct {
+	Dataset string `json:"dataset"`
+
+	// Version is the latest published version; Copied the latest
+	// copied completely
+	Version int `json:"version"`
+	Copied  int `json:"copied"`
+
+	// Since is when the oldest version not copied was published
+	Since time.Time     `json:"since"`
+	Lag   time.Duration `json:"lag"`
+
+	// Overdue is set if Lag exceeds the maximum
+	Overdue bool `json:"overdue"`
+
+	// Error is why the last run failed to copy it
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the state of the replica.
+type Report struct {
+	Generated time.Time `json:"generated"`
+
+	// Datasets counts published datasets; Copied those copied
+	// completely
+	Datasets int `json:"datasets"`
+	Copied   int `json:"copied"`
+
+	// Objects and Bytes count the objects copied
+	Objects int   `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+
+	// MaxLag is the longest lag
+	MaxLag time.Duration `json:"maxLag"`
+
+	// Overdue counts datasets lagging longer than the maximum
+	Overdue int `json:"overdue"`
+
+	// Pending are the lagging datasets, longest lag first
+	Pending []Lag `json:"pending"`
+}
+
+// Status returns the state of the replica.
+func (r *Replicator) Status(ctx context.Context) (*Report, error) {
+	datasets, err := r.datasets(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	objects, err := r.objects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := r.now().UTC()
+	rep := &Report{Generated: now, Datasets: len(datasets), Objects: len(objects), Pending: []Lag{}}
+	for _, o := range objects {
+		rep.Bytes += o.Size
+	}
+	maxLag := cmp.Or(r.MaxLag, DefaultMaxLag)
+	for _, d := range datasets {
+		c, err := r.copyOf(ctx, d.ID)
+		if err != nil {
+			return nil, err
+		}
+		versions := pending(d, c)
+		if len(versions) == 0 {
+			rep.Copied++
+			continue
+		}
+		since := *versions[0].PublishedAt
+		l := Lag{
+			Dataset: d.ID, Version: versions[len(versions)-1].Number, Copied: c.Version,
+			Since: since, Lag: now.Sub(since), Error: c.Error,
+		}
+		l.Overdue = l.Lag > maxLag
+		if l.Overdue {
+			rep.Overdue++
+		}
+		rep.MaxLag = max(rep.MaxLag, l.Lag)
+		rep.Pending = append(rep.Pending, l)
+	}
+	slices.SortFunc(rep.Pending, func(a, b Lag) int {
+		return cmp.Or(cmp.Compare(b.Lag, a.Lag), cmp.Compare(a.Dataset, b.Dataset))
+	})
+	return rep, nil
+}
+
+// Objects returns the copies of stored objects, by bucket and key.
+func (r *Replicator) Objects(ctx context.Context) ([]Object, error) {
+	copied, err := r.objects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Object, 0, len(copied))
+	for _, o := range copied {
+		out = append(out, *o)
+	}
+	slices.SortFunc(out, func(a, b Object) int {
+		return cmp.Or(cmp.Compare(a.Bucket, b.Bucket), cmp.Compare(a.Key, b.Key))
+	})
+	return out, nil
+}
+
+// Object returns the copy of the stored object at bucket/key, failing
+// with ErrNotCopied if it has none.
+func (r *Replicator) Object(ctx context.Context, bucket, key string) (*Object, error) {
+	var o Object
+	if err := r.State.Get(ctx, objectsTable, bucket+"/"+key, &o); err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return nil, fmt.Errorf("%w: s3://%s/%s", ErrNotCopied, bucket, key)
+		}
+		return nil, fmt.Errorf("failed to read copy of s3://%s/%s: %w", bucket, key, err)
+	}
+	return &o, nil
+}
+
+// datasets returns the dataset ref, or every dataset, that has a
+// published version and is not tombstoned, oldest first.
+func (r *Replicator) datasets(ctx context.Context, ref string) ([]*dataset.Dataset, error) {
+	var all []*dataset.Dataset
+	if ref != "" {
+		d, err := r.Datasets.Resolve(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		all = []*dataset.Dataset{d}
+	} else {
+		var err error
+		if all, err = r.Datasets.List(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var out []*dataset.Dataset
+	for _, d := range all {
+		if d.State == dataset.StateTombstoned {
+			continue
+		}
+		if slices.ContainsFunc(d.Versions, func(v dataset.Version) bool { return v.PublishedAt != nil }) {
+			out = append(out, d)
+		}
+	}
+	slices.SortFunc(out, func(a, b *dataset.Dataset) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return out, nil
+}
+
+// objects returns the copies of stored objects by bucket/key.
+func (r *Replicator) objects(ctx context.Context) (map[string]*Object, error) {
+	keys, err := r.State.Keys(ctx, objectsTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list copies: %w", err)
+	}
+	out := make(map[string]*Object, len(keys))
+	for _, k := range keys {
+		var o Object
+		if err := r.State.Get(ctx, objectsTable, k, &o); err != nil {
+			return nil, fmt.Errorf("failed to read copy %s: %w", k, err)
+		}
+		out[k] = &o
+	}
+	return out, nil
+}
+
+// copyOf returns the state of the copy of dataset id.
+func (r *Replicator) copyOf(ctx context.Context, id string) (*Copy, error) {
+	c := &Copy{Dataset: id}
+	if err := r.State.Get(ctx, datasetsTable, id, c); err != nil && !errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("failed to read copy of %s: %w", id, err)
+	}
+	return c, nil
+}
+
+// pending returns the published versions of d later than the version
+// c records, oldest first, leaving out versions whose files were
+// deleted.
+func pending(d *dataset.Dataset, c *Copy) []dataset.Version {
+	var out []dataset.Version
+	for _, v := range d.Versions {
+		if v.PublishedAt != nil && v.Number > c.Version && !v.Removed() {
+			out = append(out, v)
+		}
+	}
+	slices.SortFunc(out, func(a, b dataset.Version) int { return cmp.Compare(a.Number, b.Number) })
+	return out
+}
+
+// stored returns the objects storing the files of v and their
+// preservation copies.
+func stored(v dataset.Version) []Object {
+	var out []Object
+	for _, f := range v.Files {
+		out = append(out, Object{Bucket: f.Bucket, Key: f.Key, Size: f.Size, SHA256: f.SHA256})
+		for _, dv := range f.Derivatives {
+			out = append(out, Object{Bucket: dv.Bucket, Key: dv.Key, Size: dv.Size, SHA256: dv.SHA256})
+		}
+	}
+	return out
+}
+
+func (r *Replicator) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
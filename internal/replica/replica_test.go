@@ -0,0 +1,191 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replica
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/metrics"
+	"github.com/scttfrdmn/aperture/internal/premis"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+type fakeSource map[string][]byte
+
+func (f fakeSource) GetObject(_ context.Context, bucket, key string) (io.ReadCloser, error) {
+	b, ok := f[bucket+"/"+key]
+	if !ok {
+		return nil, s3.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+// fakeTarget is an in-memory replica bucket.
+type fakeTarget struct {
+	objects map[string][]byte
+	classes map[string]string
+}
+
+func (f *fakeTarget) Upload(_ context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	f.objects[bucket+"/"+key] = b
+	f.classes[bucket+"/"+key] = opts.StorageClass
+	return int64(len(b)), nil
+}
+
+type fakeRecorder struct{ metrics map[string]float64 }
+
+func (f *fakeRecorder) Record(dims metrics.Dimensions, ms ...metrics.Metric) {
+	if dims["Queue"] != Queue {
+		return
+	}
+	for _, m := range ms {
+		f.metrics[m.Name] = m.Value
+	}
+}
+
+func file(path string, content []byte) dataset.File {
+	sum := sha256.Sum256(content)
+	return dataset.File{Path: path, Bucket: "pub", Key: "ds/" + path, Size: int64(len(content)), SHA256: hex.EncodeToString(sum[:])}
+}
+
+func TestReplicator(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	ago := func(days int) *time.Time {
+		t := now.AddDate(0, 0, -days)
+		return &t
+	}
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	source := fakeSource{
+		"pub/ds/a.csv":     []byte("a,b\n1,2\n"),
+		"pub/ds/b.xlsx":    []byte("PK workbook"),
+		"pub/ds/b.csv":     []byte("b\n"),
+		"pub/ds/c.csv":     []byte("c\n"),
+		"pub/ds/rot.csv":   []byte("bit rot"),
+		"pub/ds/draft.csv": []byte("draft"),
+	}
+	a, c := file("a.csv", source["pub/ds/a.csv"]), file("c.csv", source["pub/ds/c.csv"])
+	b := file("b.xlsx", source["pub/ds/b.xlsx"])
+	b.Derivatives = []dataset.Derivative{{Converter: "xlsx2csv", Bucket: "pub", Key: "ds/b.csv", Size: 2, SHA256: file("", source["pub/ds/b.csv"]).SHA256}}
+	rot := file("rot.csv", []byte("content"))
+	rot.Size = 7
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", CreatedAt: now.AddDate(-1, 0, 0), Versions: []dataset.Version{
+			{Number: 1, PublishedAt: ago(10), Files: []dataset.File{a, b}},
+			{Number: 2, PublishedAt: ago(1), Files: []dataset.File{a, c}},
+			{Number: 3, Files: []dataset.File{a, c, file("draft.csv", source["pub/ds/draft.csv"])}},
+		}},
+		{ID: "ds-2", CreatedAt: now.AddDate(0, -1, 0), Versions: []dataset.Version{{Number: 1, Files: []dataset.File{a}}}},
+		{ID: "ds-3", CreatedAt: now.AddDate(0, -1, 0), Versions: []dataset.Version{{Number: 1, PublishedAt: ago(2), Files: []dataset.File{rot}}}},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+
+	key := bytes.Repeat([]byte{1}, KeySize)
+	target := &fakeTarget{objects: map[string][]byte{}, classes: map[string]string{}}
+	events := &premis.Log{State: s}
+	rec := &fakeRecorder{metrics: map[string]float64{}}
+	r := &Replicator{
+		State: s, Datasets: datasets, Source: source, Target: target, Bucket: "offsite",
+		StorageClass: "DEEP_ARCHIVE", Key: key, Events: events, Metrics: rec, Now: func() time.Time { return now },
+	}
+
+	// A limited run copies objects without completing a version.
+	run, err := r.Run(ctx, Options{Limit: 2})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if run.Objects != 2 || run.Versions != 0 || run.Lagging != 2 {
+		t.Errorf("limited Run() = %+v, want 2 objects copied, 2 datasets lagging", run)
+	}
+	rep, err := r.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if rep.Datasets != 2 || rep.Overdue != 1 || len(rep.Pending) != 2 || rep.Pending[0].Dataset != "ds-1" ||
+		!rep.Pending[0].Overdue || rep.Pending[0].Version != 2 || rep.MaxLag != 10*24*time.Hour {
+		t.Errorf("Status() = %+v", rep)
+	}
+
+	run, err = r.Run(ctx, Options{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if run.Objects != 2 || run.Versions != 2 || run.Failed != 1 || run.Lagging != 1 {
+		t.Errorf("Run() = %+v, want 2 objects and 2 versions copied, ds-3 failed", run)
+	}
+	if len(run.Failures) != 1 || !strings.Contains(run.Failures[0].Error, "does not match its recorded digest") {
+		t.Errorf("Failures = %+v, want rot.csv refused", run.Failures)
+	}
+	if rec.metrics[metrics.QueueDepth] != 1 || rec.metrics[metrics.QueueAge] != (2*24*time.Hour).Seconds() {
+		t.Errorf("metrics = %v, want ds-3 lagging 2 days", rec.metrics)
+	}
+
+	// Copies are encrypted and decrypt to the original, and each
+	// version's manifest is copied.
+	sealed := target.objects["offsite/objects/pub/ds/a.csv"]
+	if bytes.Contains(sealed, source["pub/ds/a.csv"]) || target.classes["offsite/objects/pub/ds/a.csv"] != "DEEP_ARCHIVE" {
+		t.Errorf("copy of a.csv is not encrypted in DEEP_ARCHIVE")
+	}
+	if got, err := decrypt(sealed, key); err != nil || !bytes.Equal(got, source["pub/ds/a.csv"]) {
+		t.Errorf("decrypted a.csv = %q, %v", got, err)
+	}
+	got, err := decrypt(target.objects["offsite/datasets/ds-1/v2.json"], key)
+	if err != nil {
+		t.Fatalf("decrypting manifest: %v", err)
+	}
+	var m dataset.Dataset
+	if err := json.Unmarshal(got, &m); err != nil || m.ID != "ds-1" {
+		t.Errorf("manifest = %s, %v", got, err)
+	}
+	if _, ok := target.objects["offsite/objects/pub/ds/draft.csv"]; ok {
+		t.Error("unpublished file copied")
+	}
+	objects, err := r.Objects(ctx)
+	if err != nil || len(objects) != 4 || objects[0].KeyID != KeyID(key) {
+		t.Errorf("Objects() = %+v, %v, want 4 copies", objects, err)
+	}
+	history, err := events.Events(ctx, "pub", "ds/b.csv")
+	if err != nil || len(history) != 1 || history[0].Type != premis.TypeReplication ||
+		history[0].Links[0].URI != "s3://offsite/objects/pub/ds/b.csv" {
+		t.Errorf("b.csv events = %+v, %v, want a replication", history, err)
+	}
+
+	// Nothing more is copied until the damage is repaired.
+	if run, err = r.Run(ctx, Options{}); err != nil || run.Objects != 0 || run.Failed != 1 {
+		t.Errorf("third Run() = %+v, %v", run, err)
+	}
+	source["pub/ds/rot.csv"] = []byte("content")
+	if run, err = r.Run(ctx, Options{Dataset: "ds-3"}); err != nil || run.Objects != 1 || run.Lagging != 0 {
+		t.Errorf("Run(ds-3) = %+v, %v, want rot.csv copied", run, err)
+	}
+}
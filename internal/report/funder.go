@@ -0,0 +1,226 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/citation"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// DimensionAward is the Dimension of the rows of a funder report
+// rolling up one award.
+const DimensionAward = "award"
+
+// FunderReport reports the deposits, storage, and downloads of funded
+// datasets over a period, by funder and award, for grants offices and
+// funder progress reports.
+type FunderReport struct {
+	// From and To bound the period; To is exclusive
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	Generated time.Time `json:"generated"`
+
+	// Repository names the repository in product listings
+	Repository string `json:"repository,omitempty"`
+
+	Funders []Funder `json:"funders"`
+}
+
+// Funder is the rollup of one funder's awards. A dataset funded by
+// several of its awards counts once in the funder's row.
+type Funder struct {
+	Row
+	Awards []AwardRow `json:"awards"`
+}
+
+// AwardRow is the rollup of the datasets of one award, which are
+// listed as its Products.
+type AwardRow struct {
+	Row
+	Number   string       `json:"number"`
+	Program  string       `json:"program,omitempty"`
+	Title    string       `json:"title,omitempty"`
+	GrantDOI string       `json:"grantDoi,omitempty"`
+	Products []DatasetRow `json:"products"`
+}
+
+// DatasetRow is one funded dataset held at the end of the period.
+type DatasetRow struct {
+	ID        string    `json:"id"`
+	DOI       string    `json:"doi,omitempty"`
+	Title     string    `json:"title"`
+	Creators  []string  `json:"creators,omitempty"`
+	Published time.Time `json:"published"`
+	Access    string    `json:"access"`
+
+	// Deposited reports whether the dataset was first published in the
+	// period
+	Deposited bool `json:"deposited"`
+
+	Bytes           int64 `json:"bytes"`
+	Downloads       int   `json:"downloads"`
+	UniqueDownloads int   `json:"uniqueDownloads"`
+	Citations       int   `json:"citations"`
+}
+
+// BuildFunders returns the funder report for the period from to to.
+// Citations are those first found in the period, since citing works
+// are dated only by year. With funders, only funders whose ROR ID
+// equals, or whose name contains, one of them are reported.
+func (b *Builder) BuildFunders(ctx context.Context, from, to time.Time, funders ...string) (*FunderReport, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("report period is empty: %s to %s", from.Format(time.DateOnly), to.Format(time.DateOnly))
+	}
+	awards, err := b.funders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := b.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	downloads, err := b.downloads(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	cited := func(rec *citation.Record) int { return foundBetween(rec, from, to) }
+
+	byFunder := make(map[string]*Funder)
+	byAward := make(map[string]*AwardRow)
+	for _, d := range datasets {
+		if d.State != dataset.StatePublished || len(d.Awards) == 0 {
+			continue
+		}
+		row, ok, err := b.datasetRow(ctx, d, from, to, downloads, cited)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		published, _ := firstPublished(d)
+		ds := DatasetRow{
+			ID: d.ID, DOI: d.DOI, Title: d.Title, Published: published, Access: string(d.Access),
+			Deposited: row.Deposits == 1, Bytes: row.Bytes,
+			Downloads: row.Downloads, UniqueDownloads: row.UniqueDownloads, Citations: row.Citations,
+		}
+		for _, c := range d.Creators {
+			ds.Creators = append(ds.Creators, c.Name)
+		}
+
+		seen := make(map[string]bool)
+		for _, id := range d.Awards {
+			a, ok := awards[id]
+			if !ok || !matchFunder(a.FunderROR, a.FunderName, funders) {
+				continue
+			}
+			ar := byAward[a.ID]
+			if ar == nil {
+				ar = &AwardRow{
+					Row:    Row{Dimension: DimensionAward, ID: a.ID, Name: a.Number},
+					Number: a.Number, Program: a.Program, Title: a.Title, GrantDOI: a.GrantDOI,
+				}
+				byAward[a.ID] = ar
+			}
+			ar.add(&row)
+			ar.Products = append(ar.Products, ds)
+
+			f := byFunder[a.FunderROR]
+			if f == nil {
+				f = &Funder{Row: Row{Dimension: DimensionFunder, ID: a.FunderROR, Name: cmp.Or(a.FunderName, a.FunderROR)}}
+				byFunder[a.FunderROR] = f
+			}
+			if !seen[a.FunderROR] {
+				seen[a.FunderROR] = true
+				f.add(&row)
+			}
+		}
+	}
+
+	for _, ar := range byAward {
+		f := byFunder[awards[ar.ID].FunderROR]
+		f.Awards = append(f.Awards, *ar)
+	}
+	rep := &FunderReport{From: from.UTC(), To: to.UTC(), Generated: b.now().UTC(), Repository: b.Repository}
+	for _, f := range byFunder {
+		slices.SortFunc(f.Awards, func(a, b AwardRow) int { return cmp.Or(cmp.Compare(a.Number, b.Number), cmp.Compare(a.ID, b.ID)) })
+		rep.Funders = append(rep.Funders, *f)
+	}
+	slices.SortFunc(rep.Funders, func(a, b Funder) int { return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID)) })
+	return rep, nil
+}
+
+// matchFunder reports whether a funder is selected by funders: all
+// are if it is empty.
+func matchFunder(ror, name string, funders []string) bool {
+	if len(funders) == 0 {
+		return true
+	}
+	for _, f := range funders {
+		if f == ror || (f != "" && strings.Contains(strings.ToLower(name), strings.ToLower(f))) {
+			return true
+		}
+	}
+	return false
+}
+
+// foundBetween returns the number of works in rec first found between
+// from and to.
+func foundBetween(rec *citation.Record, from, to time.Time) int {
+	n := 0
+	for _, c := range rec.Citations {
+		if !c.Found.Before(from) && c.Found.Before(to) {
+			n++
+		}
+	}
+	return n
+}
+
+// quarterPattern matches quarters such as 2025Q3 or 2025-Q3.
+var quarterPattern = regexp.MustCompile(`^(\d{4})-?[Qq]([1-4])$`)
+
+// Quarter returns the bounds of a calendar quarter written like 2025Q3.
+func Quarter(s string) (from, to time.Time, err error) {
+	m := quarterPattern.FindStringSubmatch(s)
+	if m == nil {
+		return from, to, fmt.Errorf("invalid quarter %q (want YYYYQn)", s)
+	}
+	year, _ := strconv.Atoi(m[1])
+	q, _ := strconv.Atoi(m[2])
+	from = time.Date(year, time.Month(3*q-2), 1, 0, 0, 0, 0, time.UTC)
+	return from, from.AddDate(0, 3, 0), nil
+}
+
+// LastQuarter returns the last calendar quarter to end before now, as
+// Quarter writes it.
+func LastQuarter(now time.Time) string {
+	now = now.UTC()
+	q := (int(now.Month())-1)/3 + 1
+	year := now.Year()
+	if q--; q == 0 {
+		year, q = year-1, 4
+	}
+	return fmt.Sprintf("%dQ%d", year, q)
+}
@@ -0,0 +1,184 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"embed"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed templates/institutional.html
+var templates embed.FS
+
+// page renders reports as HTML.
+var page = template.Must(template.New("institutional.html").Funcs(template.FuncMap{
+	"bytes":   formatBytes,
+	"section": func(title string, rows []Row) section { return section{title, rows} },
+}).ParseFS(templates, "templates/institutional.html"))
+
+// section is a titled rollup table of the HTML report.
+type section struct {
+	Title string
+	Rows  []Row
+}
+
+// csvHeader names the CSV columns.
+var csvHeader = []string{"year", "dimension", "id", "name", "deposits", "datasets", "bytes", "downloads", "unique_downloads", "citations"}
+
+// WriteCSV writes every row of r as CSV with a header.
+func WriteCSV(w io.Writer, r *Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	year := strconv.Itoa(r.Year)
+	for _, row := range r.Rows() {
+		rec := []string{
+			year, row.Dimension, row.ID, row.Name,
+			strconv.Itoa(row.Deposits),
+			strconv.Itoa(row.Datasets),
+			strconv.FormatInt(row.Bytes, 10),
+			strconv.Itoa(row.Downloads),
+			strconv.Itoa(row.UniqueDownloads),
+			strconv.Itoa(row.Citations),
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteHTML writes r as a standalone HTML document styled for print,
+// so that it can be saved as PDF from a browser.
+func WriteHTML(w io.Writer, r *Report) error {
+	if err := page.Execute(w, r); err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+	return nil
+}
+
+// formatBytes formats n with a binary unit suffix.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// funderHeader names the columns of WriteFunderCSV.
+var funderHeader = []string{"from", "to", "dimension", "funder_ror", "funder", "award_id", "award_number", "award_title", "deposits", "datasets", "bytes", "downloads", "unique_downloads", "citations"}
+
+// WriteFunderCSV writes a row for each funder of r followed by a row
+// for each of its awards, as CSV with a header.
+func WriteFunderCSV(w io.Writer, r *FunderReport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(funderHeader); err != nil {
+		return err
+	}
+	from, to := r.From.Format(time.DateOnly), r.To.AddDate(0, 0, -1).Format(time.DateOnly)
+	write := func(row *Row, f *Funder, a *AwardRow) error {
+		rec := []string{from, to, row.Dimension, f.ID, f.Name, "", "", ""}
+		if a != nil {
+			rec[5], rec[6], rec[7] = a.ID, a.Number, a.Title
+		}
+		rec = append(rec,
+			strconv.Itoa(row.Deposits),
+			strconv.Itoa(row.Datasets),
+			strconv.FormatInt(row.Bytes, 10),
+			strconv.Itoa(row.Downloads),
+			strconv.Itoa(row.UniqueDownloads),
+			strconv.Itoa(row.Citations),
+		)
+		return cw.Write(rec)
+	}
+	for i := range r.Funders {
+		f := &r.Funders[i]
+		if err := write(&f.Row, f, nil); err != nil {
+			return err
+		}
+		for j := range f.Awards {
+			if err := write(&f.Awards[j].Row, f, &f.Awards[j]); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ProductFormats are the funder product listings WriteProducts writes:
+// one row per award and dataset in the columns a funder's progress
+// report asks for, NIH's RPPR "Other products" (data or databases) and
+// NSF's Research.gov "Data and research materials".
+var ProductFormats = []string{"nih", "nsf"}
+
+// WriteProducts writes the datasets of every award in r as CSV in the
+// product listing format, one of ProductFormats.
+func WriteProducts(w io.Writer, r *FunderReport, format string) error {
+	var header []string
+	var row func(a *AwardRow, d *DatasetRow) []string
+	switch format {
+	case "nih":
+		header = []string{"Award Number", "Product Type", "Title", "Repository", "Persistent Identifier", "Date Shared", "Access", "Downloads", "Citations"}
+		row = func(a *AwardRow, d *DatasetRow) []string {
+			return []string{a.Number, "Data or Databases", d.Title, r.Repository, doiURL(d.DOI), d.Published.Format(time.DateOnly), d.Access, strconv.Itoa(d.Downloads), strconv.Itoa(d.Citations)}
+		}
+	case "nsf":
+		header = []string{"Award ID", "Product Type", "Title", "Authors", "Year", "Publisher", "DOI", "Downloads", "Citations"}
+		row = func(a *AwardRow, d *DatasetRow) []string {
+			return []string{a.Number, "Data Set", d.Title, strings.Join(d.Creators, "; "), strconv.Itoa(d.Published.Year()), r.Repository, d.DOI, strconv.Itoa(d.Downloads), strconv.Itoa(d.Citations)}
+		}
+	default:
+		return fmt.Errorf("unknown product format %q (want %s)", format, strings.Join(ProductFormats, " or "))
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, f := range r.Funders {
+		for i := range f.Awards {
+			a := &f.Awards[i]
+			for j := range a.Products {
+				if err := cw.Write(row(a, &a.Products[j])); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// doiURL returns the resolver URL of doi, or "" if there is none.
+func doiURL(doi string) string {
+	if doi == "" {
+		return ""
+	}
+	return "https://doi.org/" + doi
+}
@@ -0,0 +1,376 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package report compiles the repository's institutional report for a
+// calendar year: deposits, storage, downloads, and citations of
+// published datasets, in total and rolled up by department, collection,
+// and funder.
+//
+// A dataset's department is the top-level community above its
+// collection, or the collection itself if it is top-level; its funders
+// are those of the awards it names. A dataset funded by several awards
+// of one funder counts once for that funder. Datasets without a
+// collection or award are rolled up under Unassigned.
+//
+// The funder report covers any period, such as a quarter, and breaks
+// each funder down by award, listing the funded datasets so that they
+// can be exported in the product formats of funder progress reports.
+package report
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/award"
+	"github.com/scttfrdmn/aperture/internal/citation"
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/counter"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// Unassigned names the rollup of datasets outside any department,
+// collection, or award.
+const Unassigned = "Unassigned"
+
+// Rollup dimensions, as they appear in Row.Dimension.
+const (
+	DimensionTotal      = "total"
+	DimensionDepartment = "department"
+	DimensionCollection = "collection"
+	DimensionFunder     = "funder"
+)
+
+// Row is one line of a report: the counts of a group of datasets.
+type Row struct {
+	// Dimension is what the row rolls up by; see the Dimension
+	// constants
+	Dimension string `json:"dimension"`
+
+	// ID identifies the group (a collection ID or funder ROR ID); empty
+	// for the total and for Unassigned
+	ID string `json:"id,omitempty"`
+
+	// Name is the group's display name
+	Name string `json:"name"`
+
+	// Deposits is the number of datasets first published in the year
+	Deposits int `json:"deposits"`
+
+	// Datasets is the number of published datasets held at year end
+	Datasets int `json:"datasets"`
+
+	// Bytes is the size of their versions published by year end, each
+	// stored object counted once
+	Bytes int64 `json:"bytes"`
+
+	// Downloads is the number of COUNTER dataset requests in the year,
+	// excluding robots and double clicks
+	Downloads int `json:"downloads"`
+
+	// UniqueDownloads is the number of sessions requesting them
+	UniqueDownloads int `json:"uniqueDownloads"`
+
+	// Citations is the number of citing works dated in the year, or,
+	// if undated, first found in it
+	Citations int `json:"citations"`
+}
+
+// add adds the counts of o to r.
+func (r *Row) add(o *Row) {
+	r.Deposits += o.Deposits
+	r.Datasets += o.Datasets
+	r.Bytes += o.Bytes
+	r.Downloads += o.Downloads
+	r.UniqueDownloads += o.UniqueDownloads
+	r.Citations += o.Citations
+}
+
+// Report is an institutional report for one calendar year.
+type Report struct {
+	Year        int       `json:"year"`
+	Generated   time.Time `json:"generated"`
+	Total       Row       `json:"total"`
+	Departments []Row     `json:"departments"`
+	Collections []Row     `json:"collections"`
+	Funders     []Row     `json:"funders"`
+}
+
+// Rows returns every row of the report: the total, then departments,
+// collections, and funders.
+func (r *Report) Rows() []Row {
+	out := []Row{r.Total}
+	out = append(out, r.Departments...)
+	out = append(out, r.Collections...)
+	return append(out, r.Funders...)
+}
+
+// CitationSource returns the citations found for a dataset.
+// *citation.Tracker implements it.
+type CitationSource interface {
+	Get(ctx context.Context, ref string) (*citation.Record, error)
+}
+
+// Builder compiles institutional reports.
+type Builder struct {
+	Datasets    *dataset.Store
+	Collections *collection.Registry
+	Awards      *award.Registry
+
+	// Usage is the download event log; downloads are not counted if nil
+	Usage counter.Log
+
+	// Citations supplies citations; they are not counted if nil
+	Citations CitationSource
+
+	// Repository names the repository in funder product listings
+	Repository string
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Build returns the report for year.
+func (b *Builder) Build(ctx context.Context, year int) (*Report, error) {
+	if year < 1 || year > 9999 {
+		return nil, fmt.Errorf("invalid year %d", year)
+	}
+	from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+
+	datasets, err := b.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	departments, collections, err := b.collections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	funders, err := b.funders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	downloads, err := b.downloads(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := &Report{Year: year, Generated: b.now().UTC(), Total: Row{Dimension: DimensionTotal, Name: "All datasets"}}
+	byDepartment := make(map[string]*Row)
+	byCollection := make(map[string]*Row)
+	byFunder := make(map[string]*Row)
+	group := func(rows map[string]*Row, dim, id, name string) *Row {
+		r := rows[id]
+		if r == nil {
+			r = &Row{Dimension: dim, ID: id, Name: cmp.Or(name, id, Unassigned)}
+			rows[id] = r
+		}
+		return r
+	}
+
+	for _, d := range datasets {
+		if d.State != dataset.StatePublished {
+			continue
+		}
+		row, ok, err := b.datasetRow(ctx, d, from, to, downloads, func(rec *citation.Record) int { return citedIn(rec, year) })
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		rep.Total.add(&row)
+		c, dept := collections[d.Collection], departments[d.Collection]
+		if c == nil {
+			// A dataset naming a deleted collection is unassigned.
+			c, dept = &collection.Collection{}, &collection.Collection{}
+		}
+		group(byDepartment, DimensionDepartment, dept.ID, dept.Name).add(&row)
+		group(byCollection, DimensionCollection, c.ID, c.Name).add(&row)
+
+		seen := make(map[string]bool)
+		for _, id := range d.Awards {
+			a, ok := funders[id]
+			if !ok || seen[a.FunderROR] {
+				continue
+			}
+			seen[a.FunderROR] = true
+			group(byFunder, DimensionFunder, a.FunderROR, a.FunderName).add(&row)
+		}
+		if len(seen) == 0 {
+			group(byFunder, DimensionFunder, "", "").add(&row)
+		}
+	}
+
+	rep.Departments = sortRows(byDepartment)
+	rep.Collections = sortRows(byCollection)
+	rep.Funders = sortRows(byFunder)
+	return rep, nil
+}
+
+// downloads returns the COUNTER metrics of each dataset between from
+// and to, or none if the builder has no usage log.
+func (b *Builder) downloads(ctx context.Context, from, to time.Time) (map[string]counter.Metrics, error) {
+	downloads := make(map[string]counter.Metrics)
+	if b.Usage == nil {
+		return downloads, nil
+	}
+	events, err := b.Usage.Events(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range counter.Report(events, from, to) {
+		downloads[m.DatasetID] = m
+	}
+	return downloads, nil
+}
+
+// datasetRow returns the counts of published dataset d between from
+// and to, counting the citations cited picks from its record. It
+// returns false if d was not published by to.
+func (b *Builder) datasetRow(ctx context.Context, d *dataset.Dataset, from, to time.Time, downloads map[string]counter.Metrics, cited func(*citation.Record) int) (Row, bool, error) {
+	published, ok := firstPublished(d)
+	if !ok || !published.Before(to) {
+		return Row{}, false, nil
+	}
+	row := Row{Datasets: 1, Bytes: storedBytes(d, to)}
+	if !published.Before(from) {
+		row.Deposits = 1
+	}
+	if m, ok := downloads[d.ID]; ok {
+		row.Downloads, row.UniqueDownloads = m.TotalRequests, m.UniqueRequests
+	}
+	if b.Citations != nil {
+		rec, err := b.Citations.Get(ctx, d.ID)
+		if err != nil {
+			return Row{}, false, fmt.Errorf("failed to load citations of %s: %w", d.ID, err)
+		}
+		row.Citations = cited(rec)
+	}
+	return row, true, nil
+}
+
+// collections returns the collections by ID, and the department of
+// each.
+func (b *Builder) collections(ctx context.Context) (departments, collections map[string]*collection.Collection, err error) {
+	all, err := b.Collections.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	departments = make(map[string]*collection.Collection, len(all))
+	collections = make(map[string]*collection.Collection, len(all))
+	for i := range all {
+		c := &all[i]
+		collections[c.ID] = c
+		ancestors, err := b.Collections.Ancestors(ctx, c.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		departments[c.ID] = c
+		if len(ancestors) > 0 {
+			departments[c.ID] = &ancestors[0]
+		}
+	}
+	return departments, collections, nil
+}
+
+// funders returns the awards by ID.
+func (b *Builder) funders(ctx context.Context) (map[string]award.Award, error) {
+	if b.Awards == nil {
+		return nil, nil
+	}
+	all, err := b.Awards.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]award.Award, len(all))
+	for _, a := range all {
+		out[a.ID] = a
+	}
+	return out, nil
+}
+
+// firstPublished returns when d was first published: its earliest
+// version's publication time, or the start of its publication year for
+// records without version dates.
+func firstPublished(d *dataset.Dataset) (time.Time, bool) {
+	var first time.Time
+	for _, v := range d.Versions {
+		if v.PublishedAt != nil && (first.IsZero() || v.PublishedAt.Before(first)) {
+			first = *v.PublishedAt
+		}
+	}
+	if first.IsZero() && d.PublicationYear > 0 {
+		first = time.Date(d.PublicationYear, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return first, !first.IsZero()
+}
+
+// storedBytes returns the size of d's versions published before to.
+// Versions share unchanged files, so each object is counted once.
+func storedBytes(d *dataset.Dataset, to time.Time) int64 {
+	keys := make(map[string]bool)
+	var n int64
+	for _, v := range d.Versions {
+		if v.PublishedAt != nil && !v.PublishedAt.Before(to) {
+			continue
+		}
+		for _, f := range v.Files {
+			if !keys[f.Key] {
+				keys[f.Key] = true
+				n += f.Size
+			}
+		}
+	}
+	return n
+}
+
+// citedIn returns the number of works in rec citing in year.
+func citedIn(rec *citation.Record, year int) int {
+	n := 0
+	for _, c := range rec.Citations {
+		if c.Year == year || (c.Year == 0 && c.Found.Year() == year) {
+			n++
+		}
+	}
+	return n
+}
+
+// sortRows returns rows ordered by name, with Unassigned last.
+func sortRows(rows map[string]*Row) []Row {
+	out := make([]Row, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, *r)
+	}
+	slices.SortFunc(out, func(a, b Row) int {
+		if (a.ID == "") != (b.ID == "") {
+			if a.ID == "" {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+	return out
+}
+
+func (b *Builder) now() time.Time {
+	if b.Now != nil {
+		return b.Now()
+	}
+	return time.Now()
+}
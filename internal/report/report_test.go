@@ -0,0 +1,307 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/award"
+	"github.com/scttfrdmn/aperture/internal/citation"
+	"github.com/scttfrdmn/aperture/internal/collection"
+	"github.com/scttfrdmn/aperture/internal/counter"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+const (
+	nsf = "https://ror.org/021nxhr62"
+	nih = "https://ror.org/01cwqze88"
+)
+
+type fakeCitations map[string][]citation.Citation
+
+func (f fakeCitations) Get(_ context.Context, ref string) (*citation.Record, error) {
+	return &citation.Record{DatasetID: ref, Count: len(f[ref]), Citations: f[ref]}, nil
+}
+
+func newTestBuilder(t *testing.T) *Builder {
+	t.Helper()
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "root@uni.edu", Groups: []string{authz.AdminGroup}})
+	st := state.NewMemoryStore()
+	datasets := dataset.NewStore(st)
+	collections := &collection.Registry{State: st, Datasets: datasets}
+	for _, c := range []collection.Collection{
+		{ID: "geo", Kind: collection.KindCommunity, Name: "Geosciences"},
+		{ID: "soils", Kind: collection.KindCollection, Name: "Soil Lab", Parent: "geo"},
+		{ID: "climate", Kind: collection.KindCollection, Name: "Climate Group", Parent: "geo"},
+		{ID: "bio", Kind: collection.KindCollection, Name: "Biology"},
+	} {
+		if _, err := collections.Create(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	awards := &award.Registry{State: st, Datasets: datasets}
+	var ids []string
+	for _, a := range []award.Award{
+		{FunderROR: nsf, FunderName: "National Science Foundation", Number: "EAR-1"},
+		{FunderROR: nsf, FunderName: "National Science Foundation", Number: "EAR-2"},
+		{FunderROR: nih, FunderName: "National Institutes of Health", Number: "R01-1"},
+	} {
+		added, err := awards.Add(ctx, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, added.ID)
+	}
+
+	at := func(year int, month time.Month) *time.Time {
+		t := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	file := func(key string, size int64) dataset.File { return dataset.File{Path: key, Key: key, Size: size} }
+	for _, d := range []*dataset.Dataset{
+		// Deposited before the year, with a second version after it
+		{ID: "ds-1", Collection: "soils", Awards: []string{ids[0], ids[1]}, State: dataset.StatePublished, Versions: []dataset.Version{
+			{Number: 1, PublishedAt: at(2024, 5), Files: []dataset.File{file("a", 100)}},
+			{Number: 2, PublishedAt: at(2026, 2), Files: []dataset.File{file("a", 100), file("b", 50)}},
+		}},
+		// Deposited in the year
+		{ID: "ds-2", Collection: "climate", Awards: []string{ids[0], ids[2]}, State: dataset.StatePublished, Versions: []dataset.Version{
+			{Number: 1, PublishedAt: at(2025, 3), Files: []dataset.File{file("c", 1000)}},
+			{Number: 2, PublishedAt: at(2025, 9), Files: []dataset.File{file("c", 1000), file("d", 24)}},
+		}},
+		{ID: "ds-3", Collection: "bio", State: dataset.StatePublished, Versions: []dataset.Version{
+			{Number: 1, PublishedAt: at(2025, 7), Files: []dataset.File{file("e", 7)}},
+		}},
+		// Uncollected, dated by publication year only
+		{ID: "ds-4", PublicationYear: 2023, State: dataset.StatePublished, Versions: []dataset.Version{{Number: 1, Files: []dataset.File{file("f", 1)}}}},
+		// Deposited after the year, and never published
+		{ID: "ds-5", Collection: "bio", State: dataset.StatePublished, Versions: []dataset.Version{{Number: 1, PublishedAt: at(2026, 1)}}},
+		{ID: "ds-6", Collection: "bio", State: dataset.StateDraft},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage := &counter.MemoryLog{}
+	ip := netip.MustParseAddr("192.0.2.10")
+	for _, e := range []struct {
+		at time.Time
+		ds string
+		ua string
+	}{
+		{time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC), "ds-1", "Mozilla/5.0"},
+		{time.Date(2025, 4, 1, 10, 5, 0, 0, time.UTC), "ds-1", "Mozilla/5.0"},
+		{time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC), "ds-2", "Mozilla/5.0"},
+		{time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC), "ds-2", "Googlebot/2.1"},
+		{time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC), "ds-2", "Mozilla/5.0"},
+	} {
+		ev := counter.NewEvent(e.at, ip, e.ua)
+		ev.DatasetID, ev.Kind, ev.File = e.ds, counter.KindRequest, e.at.String()
+		if err := usage.Append(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cited := fakeCitations{
+		"ds-1": {
+			{DOI: "10.1000/a", Year: 2025},
+			{DOI: "10.1000/b", Year: 2024},
+			{DOI: "10.1000/c", Found: time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		"ds-3": {{DOI: "10.1000/d", Year: 2025}},
+	}
+	return &Builder{
+		Datasets:    datasets,
+		Collections: collections,
+		Awards:      awards,
+		Usage:       usage,
+		Citations:   cited,
+		Now:         func() time.Time { return time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC) },
+	}
+}
+
+func TestBuild(t *testing.T) {
+	rep, err := newTestBuilder(t).Build(context.Background(), 2025)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	want := Row{Dimension: DimensionTotal, Name: "All datasets", Deposits: 2, Datasets: 4, Bytes: 100 + 1024 + 7 + 1, Downloads: 3, UniqueDownloads: 2, Citations: 3}
+	if rep.Total != want {
+		t.Errorf("Total = %+v, want %+v", rep.Total, want)
+	}
+	check := func(name string, rows []Row, want map[string][3]int) {
+		t.Helper()
+		if len(rows) != len(want) {
+			t.Errorf("%s = %+v, want %d rows", name, rows, len(want))
+		}
+		for _, r := range rows {
+			w, ok := want[r.Name]
+			if !ok || r.Deposits != w[0] || r.Datasets != w[1] || r.Downloads != w[2] {
+				t.Errorf("%s row %+v, want deposits, datasets, downloads %v", name, r, w)
+			}
+		}
+		if last := rows[len(rows)-1]; want[Unassigned] != [3]int{} && last.Name != Unassigned {
+			t.Errorf("%s ends with %s, want %s", name, last.Name, Unassigned)
+		}
+	}
+	check("Departments", rep.Departments, map[string][3]int{
+		"Biology":     {1, 1, 0},
+		"Geosciences": {1, 2, 3},
+		Unassigned:    {0, 1, 0},
+	})
+	check("Collections", rep.Collections, map[string][3]int{
+		"Biology":       {1, 1, 0},
+		"Climate Group": {1, 1, 1},
+		"Soil Lab":      {0, 1, 2},
+		Unassigned:      {0, 1, 0},
+	})
+	// ds-1 has two NSF awards but counts once for NSF.
+	check("Funders", rep.Funders, map[string][3]int{
+		"National Institutes of Health": {1, 1, 1},
+		"National Science Foundation":   {1, 2, 3},
+		Unassigned:                      {1, 2, 0},
+	})
+	if rep.Funders[1].ID != nsf {
+		t.Errorf("NSF row ID = %q, want %q", rep.Funders[1].ID, nsf)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	rep, err := newTestBuilder(t).Build(context.Background(), 2025)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, rep); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV does not parse: %v", err)
+	}
+	if len(records) != 1+len(rep.Rows()) || strings.Join(records[1], ",") != "2025,total,,All datasets,2,4,1132,3,2,3" {
+		t.Errorf("CSV = %v", records)
+	}
+
+	buf.Reset()
+	if err := WriteHTML(&buf, rep); err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+	html := buf.String()
+	for _, want := range []string{
+		"<title>Research data repository report 2025</title>",
+		"<h2>By department</h2>",
+		"<tr><td>Geosciences</td><td class=\"n\">1</td><td class=\"n\">2</td><td class=\"n\">1.1 KiB</td><td class=\"n\">3</td>",
+		"<h2>By funder</h2>",
+		"Generated January 15, 2026",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML missing %q:\n%s", want, html)
+		}
+	}
+}
+
+func TestBuildFunders(t *testing.T) {
+	b := newTestBuilder(t)
+	b.Repository = "Example Data Repository"
+	from, to := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	rep, err := b.BuildFunders(context.Background(), from, to)
+	if err != nil {
+		t.Fatalf("BuildFunders() error = %v", err)
+	}
+
+	if len(rep.Funders) != 2 || rep.Funders[0].Name != "National Institutes of Health" || rep.Funders[1].ID != nsf {
+		t.Fatalf("Funders = %+v", rep.Funders)
+	}
+	// ds-1 is funded by both NSF awards but counts once for NSF.
+	nsfRow := rep.Funders[1]
+	if nsfRow.Deposits != 1 || nsfRow.Datasets != 2 || nsfRow.Downloads != 3 || nsfRow.Citations != 1 {
+		t.Errorf("NSF = %+v", nsfRow.Row)
+	}
+	if len(nsfRow.Awards) != 2 {
+		t.Fatalf("NSF awards = %+v", nsfRow.Awards)
+	}
+	if a := nsfRow.Awards[0]; a.Number != "EAR-1" || a.Dimension != DimensionAward || a.Products[0].ID != "ds-1" || len(a.Products) != 2 || a.Datasets != 2 || a.Downloads != 3 {
+		t.Errorf("EAR-1 = %+v", a)
+	}
+	if a := nsfRow.Awards[1]; a.Number != "EAR-2" || len(a.Products) != 1 || a.Products[0].Deposited || a.Products[0].Downloads != 2 {
+		t.Errorf("EAR-2 = %+v", a)
+	}
+
+	only, err := b.BuildFunders(context.Background(), from, to, "institutes of health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(only.Funders) != 1 || only.Funders[0].ID != nih || only.Funders[0].Awards[0].Products[0].ID != "ds-2" {
+		t.Errorf("BuildFunders(NIH) = %+v", only.Funders)
+	}
+	if _, err := b.BuildFunders(context.Background(), to, from); err == nil {
+		t.Error("BuildFunders() accepted an empty period")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteFunderCSV(&buf, rep); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV does not parse: %v", err)
+	}
+	if len(records) != 6 || strings.Join(records[3][:9], ",") != "2025-01-01,2025-09-30,funder,"+nsf+",National Science Foundation,,,,1" {
+		t.Errorf("CSV = %v", records)
+	}
+
+	buf.Reset()
+	if err := WriteProducts(&buf, only, "nih"); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ = csv.NewReader(&buf).ReadAll(); len(records) != 2 || records[0][0] != "Award Number" ||
+		strings.Join(records[1], ",") != "R01-1,Data or Databases,,Example Data Repository,,2025-03-01,,1,0" {
+		t.Errorf("NIH products = %v", records)
+	}
+	if err := WriteProducts(&buf, rep, "erc"); err == nil {
+		t.Error("WriteProducts() accepted an unknown format")
+	}
+}
+
+func TestQuarter(t *testing.T) {
+	from, to, err := Quarter("2025Q4")
+	if err != nil || !from.Equal(time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Quarter(2025Q4) = %v, %v, %v", from, to, err)
+	}
+	for _, s := range []string{"2025Q5", "2025", "Q1"} {
+		if _, _, err := Quarter(s); err == nil {
+			t.Errorf("Quarter(%q) accepted", s)
+		}
+	}
+	if q := LastQuarter(time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC)); q != "2025Q4" {
+		t.Errorf("LastQuarter() = %s, want 2025Q4", q)
+	}
+	if q := LastQuarter(time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)); q != "2025Q2" {
+		t.Errorf("LastQuarter() = %s, want 2025Q2", q)
+	}
+}
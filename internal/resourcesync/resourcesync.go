@@ -0,0 +1,357 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resourcesync publishes the repository's landing pages as
+// ResourceSync documents, so that mirrors and indexers can follow
+// changes without re-harvesting everything.
+//
+// Each sync compares the catalog with the state recorded by the
+// previous one and records a change for every dataset published,
+// edited, or tombstoned since. It then writes a source description, a
+// capability list, a resource list of every published dataset, and a
+// change list of the changes within the retention window. A mirror that
+// falls further behind than the window starts again from the resource
+// list.
+package resourcesync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// Tables used in the state store.
+const (
+	// resourcesTable holds one Resource per dataset synced, keyed by ID
+	resourcesTable = "resourcesync-resources"
+
+	// changesTable holds Changes keyed by time and dataset ID
+	changesTable = "resourcesync-changes"
+)
+
+// DefaultWindow is how long changes stay in the change list.
+const DefaultWindow = 90 * 24 * time.Hour
+
+// Keys of the published documents in the site bucket.
+const (
+	SourceDescriptionKey = ".well-known/resourcesync"
+	CapabilityListKey    = "resourcesync/capabilitylist.xml"
+	ResourceListKey      = "resourcesync/resourcelist.xml"
+	ChangeListKey        = "resourcesync/changelist.xml"
+)
+
+// Kinds of change.
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// Resource is the state of a dataset's landing page at the last sync.
+type Resource struct {
+	DatasetID string `json:"datasetId"`
+
+	// Hash digests the dataset record the page was published from
+	Hash string `json:"hash"`
+
+	// Deleted is set once the dataset is tombstoned or removed
+	Deleted bool `json:"deleted,omitempty"`
+
+	// Modified is when the change was detected
+	Modified time.Time `json:"modified"`
+}
+
+// Change is a change to a dataset's landing page.
+type Change struct {
+	DatasetID string    `json:"datasetId"`
+	Change    string    `json:"change"`
+	Time      time.Time `json:"time"`
+}
+
+// ObjectStore stores published documents. *s3.Client implements it.
+type ObjectStore interface {
+	PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error
+}
+
+// Publisher records changes and publishes the ResourceSync documents.
+type Publisher struct {
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// State holds the synced resources and their changes
+	State state.Store
+
+	// Objects stores the documents in Bucket
+	Objects ObjectStore
+	Bucket  string
+
+	// SiteURL is the public base URL of landing pages and documents
+	SiteURL string
+
+	// Window is how long changes stay in the change list;
+	// DefaultWindow if zero
+	Window time.Duration
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Result summarizes a sync.
+type Result struct {
+	Changes   []Change `json:"changes"`
+	Resources int      `json:"resources"`
+	Paths     []string `json:"paths"`
+}
+
+// Sync records the changes since the last sync and publishes the
+// documents, returning the new changes and the URL paths written. With
+// dryRun, changes are reported but nothing is recorded or written.
+func (p *Publisher) Sync(ctx context.Context, dryRun bool) (*Result, error) {
+	if p.SiteURL == "" {
+		return nil, fmt.Errorf("site URL not configured; set APERTURE_SITE_URL")
+	}
+	now := p.now().UTC().Truncate(time.Second)
+	datasets, err := p.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := state.List[Resource](ctx, p.State, resourcesTable)
+	if err != nil {
+		return nil, err
+	}
+	synced := make(map[string]Resource, len(prev))
+	for _, r := range prev {
+		synced[r.DatasetID] = r
+	}
+
+	res := &Result{}
+	var published []Resource
+	for _, d := range datasets {
+		r, seen := synced[d.ID]
+		delete(synced, d.ID)
+		switch d.State {
+		case dataset.StatePublished:
+			cur := Resource{DatasetID: d.ID, Hash: hash(d), Modified: now}
+			switch {
+			case !seen || r.Deleted:
+				res.Changes = append(res.Changes, Change{DatasetID: d.ID, Change: ChangeCreated, Time: now})
+			case r.Hash != cur.Hash:
+				res.Changes = append(res.Changes, Change{DatasetID: d.ID, Change: ChangeUpdated, Time: now})
+			default:
+				cur = r
+			}
+			published = append(published, cur)
+		case dataset.StateTombstoned:
+			if seen && !r.Deleted {
+				res.Changes = append(res.Changes, Change{DatasetID: d.ID, Change: ChangeDeleted, Time: now})
+			}
+		}
+	}
+	// Datasets removed from the catalog outright are deleted too.
+	for id, r := range synced {
+		if !r.Deleted {
+			res.Changes = append(res.Changes, Change{DatasetID: id, Change: ChangeDeleted, Time: now})
+		}
+	}
+	sort.Slice(res.Changes, func(i, j int) bool { return res.Changes[i].DatasetID < res.Changes[j].DatasetID })
+	res.Resources = len(published)
+	if dryRun {
+		return res, nil
+	}
+
+	for _, c := range res.Changes {
+		r := Resource{DatasetID: c.DatasetID, Modified: now, Deleted: c.Change == ChangeDeleted}
+		for _, cur := range published {
+			if cur.DatasetID == c.DatasetID {
+				r = cur
+			}
+		}
+		if err := p.State.Put(ctx, resourcesTable, r.DatasetID, r); err != nil {
+			return nil, err
+		}
+		if err := p.State.Put(ctx, changesTable, changeKey(c), c); err != nil {
+			return nil, err
+		}
+	}
+	changes, err := p.changes(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	res.Paths, err = p.publish(ctx, now, published, changes)
+	return res, err
+}
+
+// changes returns the changes within the window, oldest first, and
+// removes older ones.
+func (p *Publisher) changes(ctx context.Context, now time.Time) ([]Change, error) {
+	keys, err := p.State.Keys(ctx, changesTable)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	from := now.Add(-p.window())
+	var out []Change
+	for _, k := range keys {
+		var c Change
+		if err := p.State.Get(ctx, changesTable, k, &c); err != nil {
+			if errors.Is(err, state.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if c.Time.Before(from) {
+			if err := p.State.Delete(ctx, changesTable, k); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// publish writes the documents describing resources and changes.
+func (p *Publisher) publish(ctx context.Context, now time.Time, resources []Resource, changes []Change) ([]string, error) {
+	base := strings.TrimRight(p.SiteURL, "/")
+	at := now.Format(time.RFC3339)
+
+	description := newURLSet("description", "", nil)
+	description.URLs = []url{{Loc: base + "/" + CapabilityListKey, MD: &md{Capability: "capabilitylist"}}}
+
+	capabilities := newURLSet("capabilitylist", "", []ln{{Rel: "up", Href: base + "/" + SourceDescriptionKey}})
+	capabilities.URLs = []url{
+		{Loc: base + "/" + ResourceListKey, MD: &md{Capability: "resourcelist"}},
+		{Loc: base + "/" + ChangeListKey, MD: &md{Capability: "changelist"}},
+	}
+
+	up := []ln{{Rel: "up", Href: base + "/" + CapabilityListKey}}
+	list := newURLSet("resourcelist", "", up)
+	list.MD.At = at
+	for _, r := range resources {
+		list.URLs = append(list.URLs, url{Loc: p.pageURL(r.DatasetID), Lastmod: r.Modified.UTC().Format(time.RFC3339)})
+	}
+
+	changeList := newURLSet("changelist", now.Add(-p.window()).Format(time.RFC3339), up)
+	changeList.MD.Until = at
+	for _, c := range changes {
+		u := url{Loc: p.pageURL(c.DatasetID), MD: &md{Change: c.Change, Datetime: c.Time.UTC().Format(time.RFC3339)}}
+		if c.Change != ChangeDeleted {
+			u.Lastmod = u.MD.Datetime
+		}
+		changeList.URLs = append(changeList.URLs, u)
+	}
+
+	docs := []struct {
+		key string
+		doc *urlset
+	}{
+		{ResourceListKey, list},
+		{ChangeListKey, changeList},
+		{CapabilityListKey, capabilities},
+		{SourceDescriptionKey, description},
+	}
+	var paths []string
+	for _, d := range docs {
+		data, err := xml.MarshalIndent(d.doc, "", "  ")
+		if err != nil {
+			return paths, fmt.Errorf("failed to encode %s: %w", d.key, err)
+		}
+		data = append([]byte(xml.Header), data...)
+		if err := p.Objects.PutObject(ctx, p.Bucket, d.key, data, "application/xml"); err != nil {
+			return paths, fmt.Errorf("failed to publish %s: %w", d.key, err)
+		}
+		paths = append(paths, "/"+d.key)
+	}
+	return paths, nil
+}
+
+func (p *Publisher) pageURL(id string) string {
+	return strings.TrimRight(p.SiteURL, "/") + landing.PagePath(id)
+}
+
+func (p *Publisher) window() time.Duration {
+	if p.Window > 0 {
+		return p.Window
+	}
+	return DefaultWindow
+}
+
+func (p *Publisher) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+// changeKey orders changes by time, then dataset.
+func changeKey(c Change) string {
+	return c.Time.UTC().Format("20060102T150405Z") + "/" + c.DatasetID
+}
+
+// hash digests the dataset fields published on its page. The update
+// timestamp is excluded so that bookkeeping writes are not changes.
+func hash(d *dataset.Dataset) string {
+	cp := *d
+	cp.UpdatedAt = time.Time{}
+	data, _ := json.Marshal(cp)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// urlset is a ResourceSync document: a sitemap with rs:md and rs:ln
+// extensions. encoding/xml does not write namespace prefixes, so the
+// extension elements are named with theirs and the prefix is declared
+// as an attribute.
+type urlset struct {
+	XMLName xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	RS      string   `xml:"xmlns:rs,attr"`
+	LN      []ln     `xml:"rs:ln"`
+	MD      md       `xml:"rs:md"`
+	URLs    []url    `xml:"url"`
+}
+
+func newURLSet(capability, from string, links []ln) *urlset {
+	return &urlset{RS: "http://www.openarchives.org/rs/terms/", LN: links, MD: md{Capability: capability, From: from}}
+}
+
+type ln struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type md struct {
+	Capability string `xml:"capability,attr,omitempty"`
+	Change     string `xml:"change,attr,omitempty"`
+	Datetime   string `xml:"datetime,attr,omitempty"`
+	At         string `xml:"at,attr,omitempty"`
+	From       string `xml:"from,attr,omitempty"`
+	Until      string `xml:"until,attr,omitempty"`
+}
+
+type url struct {
+	Loc     string `xml:"loc"`
+	Lastmod string `xml:"lastmod,omitempty"`
+	MD      *md    `xml:"rs:md"`
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resourcesync
+
+import (
+	"context"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+type fakeObjects map[string][]byte
+
+func (f fakeObjects) PutObject(_ context.Context, bucket, key string, body []byte, _ string) error {
+	f[bucket+"/"+key] = body
+	return nil
+}
+
+// document is a published document as a harvester decodes it.
+type document struct {
+	MD struct {
+		From string `xml:"from,attr"`
+	} `xml:"http://www.openarchives.org/rs/terms/ md"`
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		Lastmod string `xml:"lastmod"`
+		MD      struct {
+			Change string `xml:"change,attr"`
+		} `xml:"http://www.openarchives.org/rs/terms/ md"`
+	} `xml:"url"`
+}
+
+func changes(res *Result) string {
+	var out []string
+	for _, c := range res.Changes {
+		out = append(out, c.Change+" "+c.DatasetID)
+	}
+	return strings.Join(out, ", ")
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	objects := fakeObjects{}
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := &Publisher{
+		Datasets: datasets,
+		State:    s,
+		Objects:  objects,
+		Bucket:   "site",
+		SiteURL:  "https://data.uni.edu/",
+		Window:   48 * time.Hour,
+		Now:      func() time.Time { return now },
+	}
+	put := func(d *dataset.Dataset) {
+		t.Helper()
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(&dataset.Dataset{ID: "ds-1", Title: "Soil cores", State: dataset.StatePublished})
+	put(&dataset.Dataset{ID: "ds-2", Title: "Letters", State: dataset.StatePublished})
+	put(&dataset.Dataset{ID: "ds-3", Title: "Draft", State: dataset.StateDraft})
+
+	res, err := p.Sync(ctx, false)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if got := changes(res); got != "created ds-1, created ds-2" || res.Resources != 2 || len(res.Paths) != 4 {
+		t.Errorf("Sync() = %s, %d resources, %v", got, res.Resources, res.Paths)
+	}
+
+	// Rewriting a record without changing it is not a change.
+	now = now.Add(24 * time.Hour)
+	d, _ := datasets.Get(ctx, "ds-1")
+	put(d)
+	if res, err = p.Sync(ctx, false); err != nil || len(res.Changes) != 0 {
+		t.Errorf("Sync() without changes = %s, %v", changes(res), err)
+	}
+
+	now = now.Add(24 * time.Hour)
+	d.Title = "Soil cores, 2024"
+	put(d)
+	d, _ = datasets.Get(ctx, "ds-2")
+	d.State = dataset.StateTombstoned
+	put(d)
+	put(&dataset.Dataset{ID: "ds-4", Title: "Software", State: dataset.StatePublished})
+	if res, err = p.Sync(ctx, true); err != nil || changes(res) != "updated ds-1, deleted ds-2, created ds-4" {
+		t.Errorf("Sync(dry run) = %s, %v", changes(res), err)
+	}
+	if res, err = p.Sync(ctx, false); err != nil || changes(res) != "updated ds-1, deleted ds-2, created ds-4" {
+		t.Errorf("Sync() = %s, %v", changes(res), err)
+	}
+	now = now.Add(24 * time.Hour)
+	if err := datasets.Delete(ctx, "ds-4"); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = p.Sync(ctx, false); err != nil || changes(res) != "deleted ds-4" {
+		t.Errorf("Sync() after removal = %s, %v", changes(res), err)
+	}
+
+	var list document
+	if err := xml.Unmarshal(objects["site/"+ResourceListKey], &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.URLs) != 1 || list.URLs[0].Loc != "https://data.uni.edu/datasets/ds-1/" || list.URLs[0].Lastmod != "2025-05-03T12:00:00Z" {
+		t.Errorf("resource list = %+v", list.URLs)
+	}
+
+	// The changes of the first sync have left the window.
+	var changeList document
+	if err := xml.Unmarshal(objects["site/"+ChangeListKey], &changeList); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, u := range changeList.URLs {
+		got = append(got, u.MD.Change+" "+strings.TrimPrefix(u.Loc, "https://data.uni.edu/datasets/"))
+	}
+	want := "updated ds-1/, deleted ds-2/, created ds-4/, deleted ds-4/"
+	if strings.Join(got, ", ") != want || changeList.MD.From != "2025-05-02T12:00:00Z" {
+		t.Errorf("change list = %v from %s, want %s", got, changeList.MD.From, want)
+	}
+
+	body := string(objects["site/"+CapabilityListKey])
+	for _, s := range []string{
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:rs="http://www.openarchives.org/rs/terms/">`,
+		`<rs:ln rel="up" href="https://data.uni.edu/.well-known/resourcesync"></rs:ln>`,
+		`<rs:md capability="changelist"></rs:md>`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("capability list missing %s:\n%s", s, body)
+		}
+	}
+}
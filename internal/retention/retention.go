@@ -0,0 +1,531 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retention de-accessions datasets whose retention period has
+// ended.
+//
+// A retention policy keeps a dataset for a number of years after it
+// was published or last accessed, for example to follow a funder's
+// data management rules. Datasets are assigned a policy by name. A
+// scheduled evaluation flags datasets whose period has ended for
+// review and notifies their stewards; nothing is removed until a
+// steward confirms the review with a reason, which tombstones the
+// dataset. A steward may instead retain the dataset for longer. Every
+// step is recorded in the audit log and the dataset's history.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/notify"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// Tables used in the state store.
+const (
+	policiesTable = "retention-policies"
+	reviewsTable  = "retention-reviews"
+)
+
+var (
+	// ErrNoPolicy is returned for an unknown policy name.
+	ErrNoPolicy = errors.New("retention policy not found")
+
+	// ErrNoReview is returned when a dataset has no pending review.
+	ErrNoReview = errors.New("no pending retention review")
+
+	// ErrNotDue is returned when confirming a review for a dataset whose
+	// retention period has not ended, for example because it was
+	// accessed after it was flagged.
+	ErrNotDue = errors.New("retention period has not ended")
+
+	// ErrReasonRequired is returned when a review is decided without a
+	// reason.
+	ErrReasonRequired = errors.New("a reason is required")
+)
+
+// Basis is the date a retention period counts from.
+type Basis string
+
+// Bases.
+const (
+	// BasisPublished counts from the first publication of the dataset
+	BasisPublished Basis = "published"
+
+	// BasisLastAccess counts from the last download of any file
+	BasisLastAccess Basis = "last-access"
+)
+
+// Policy keeps datasets for a fixed period.
+type Policy struct {
+	// Name identifies the policy, e.g. "nsf-5y"
+	Name string `json:"name"`
+
+	// Description explains where the rule comes from
+	Description string `json:"description,omitempty"`
+
+	// Years is the retention period
+	Years int `json:"years"`
+
+	// Basis is the date the period counts from
+	Basis Basis `json:"basis"`
+}
+
+// Validate reports whether p is well formed.
+func (p *Policy) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("retention policy has no name")
+	}
+	if p.Years <= 0 {
+		return fmt.Errorf("retention policy %s must keep datasets for at least one year", p.Name)
+	}
+	if p.Basis != BasisPublished && p.Basis != BasisLastAccess {
+		return fmt.Errorf("retention policy %s has unknown basis %q (want %s or %s)", p.Name, p.Basis, BasisPublished, BasisLastAccess)
+	}
+	return nil
+}
+
+// Status is the state of a review.
+type Status string
+
+// Review statuses.
+const (
+	// StatusPending awaits a steward's decision
+	StatusPending Status = "pending"
+
+	// StatusRetained means a steward chose to keep the dataset
+	StatusRetained Status = "retained"
+
+	// StatusCleared means the dataset was accessed after it was flagged
+	// and is no longer due
+	StatusCleared Status = "cleared"
+
+	// StatusTombstoned means a steward confirmed the de-accession
+	StatusTombstoned Status = "tombstoned"
+)
+
+// Review is the current retention review of one dataset.
+type Review struct {
+	DatasetID   string     `json:"datasetId"`
+	Policy      string     `json:"policy"`
+	Status      Status     `json:"status"`
+	DueAt       time.Time  `json:"dueAt"`
+	FlaggedAt   time.Time  `json:"flaggedAt"`
+	DecidedBy   string     `json:"decidedBy,omitempty"`
+	DecidedAt   *time.Time `json:"decidedAt,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+	RetainUntil *time.Time `json:"retainUntil,omitempty"`
+}
+
+// AccessLog reports when a dataset's files were last downloaded.
+type AccessLog interface {
+	// LastAccess returns the time of the most recent download of any
+	// file of the dataset, or the zero time if there has been none.
+	LastAccess(ctx context.Context, datasetID string) (time.Time, error)
+}
+
+// Manager evaluates and applies retention policies.
+type Manager struct {
+	// State stores policies and reviews
+	State state.Store
+
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Accesses supplies last-access dates; last-access policies count
+	// from publication if nil
+	Accesses AccessLog
+
+	// Notifier tells stewards about flagged datasets; skipped if nil
+	Notifier notify.Notifier
+
+	// Stewards receive notices for datasets that name no managing
+	// users in their access control list
+	Stewards []string
+
+	// Log records retention decisions; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// PutPolicy creates or replaces a policy.
+func (m *Manager) PutPolicy(ctx context.Context, p Policy) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if err := m.State.Put(ctx, policiesTable, p.Name, p); err != nil {
+		return err
+	}
+	return m.record(ctx, "retention.policy", p.Name, map[string]string{
+		"years": fmt.Sprint(p.Years),
+		"basis": string(p.Basis),
+	})
+}
+
+// Policy returns the named policy.
+func (m *Manager) Policy(ctx context.Context, name string) (*Policy, error) {
+	var p Policy
+	err := m.State.Get(ctx, policiesTable, name, &p)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrNoPolicy, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Policies returns all policies ordered by name.
+func (m *Manager) Policies(ctx context.Context) ([]Policy, error) {
+	policies, err := state.List[Policy](ctx, m.State, policiesTable)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+	return policies, nil
+}
+
+// Assign applies the named policy to a dataset, or removes its policy
+// if name is empty. The principal in ctx must be allowed to manage the
+// dataset.
+func (m *Manager) Assign(ctx context.Context, ref, name string) (*dataset.Dataset, error) {
+	d, err := m.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+		return nil, err
+	}
+	if name != "" {
+		if _, err := m.Policy(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+	details := map[string]string{"from": d.Retention, "policy": name}
+	d.Retention = name
+	m.note(ctx, d, "retention.assign", "", details)
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return nil, err
+	}
+	if err := m.record(ctx, "retention.assign", d.ID, details); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// DueAt returns when d's retention period under p ends.
+func (m *Manager) DueAt(ctx context.Context, d *dataset.Dataset, p *Policy) (time.Time, error) {
+	start := published(d)
+	if p.Basis == BasisLastAccess && m.Accesses != nil {
+		last, err := m.Accesses.LastAccess(ctx, d.ID)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to find last access of %s: %w", d.ID, err)
+		}
+		if last.After(start) {
+			start = last
+		}
+	}
+	return start.AddDate(p.Years, 0, 0).UTC(), nil
+}
+
+// Evaluate flags every published dataset whose retention period has
+// ended and that has no open review, notifying its stewards. Datasets a
+// steward chose to retain are flagged again once the retention ends.
+// It returns the newly flagged reviews, oldest due first.
+func (m *Manager) Evaluate(ctx context.Context) ([]Review, error) {
+	all, err := m.Datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	policies := make(map[string]*Policy)
+	var flagged []Review
+	var errs []error
+	for _, d := range all {
+		if d.State != dataset.StatePublished || d.Retention == "" {
+			continue
+		}
+		p, ok := policies[d.Retention]
+		if !ok {
+			if p, err = m.Policy(ctx, d.Retention); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", d.ID, err))
+				continue
+			}
+			policies[d.Retention] = p
+		}
+
+		due, err := m.DueAt(ctx, d, p)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if due.After(now) {
+			continue
+		}
+		prev, err := m.Review(ctx, d.ID)
+		if err != nil && !errors.Is(err, ErrNoReview) {
+			return nil, err
+		}
+		if prev != nil && (prev.Status == StatusPending ||
+			(prev.Status == StatusRetained && prev.RetainUntil != nil && prev.RetainUntil.After(now))) {
+			continue
+		}
+
+		rv := Review{DatasetID: d.ID, Policy: p.Name, Status: StatusPending, DueAt: due, FlaggedAt: now.UTC()}
+		if err := m.State.Put(ctx, reviewsTable, d.ID, rv); err != nil {
+			return nil, err
+		}
+		if err := m.record(ctx, "retention.flag", d.ID, map[string]string{
+			"policy": p.Name,
+			"due":    due.Format(time.RFC3339),
+		}); err != nil {
+			return nil, err
+		}
+		if err := m.notify(ctx, d, p, due); err != nil {
+			errs = append(errs, err)
+		}
+		flagged = append(flagged, rv)
+	}
+	sort.Slice(flagged, func(i, j int) bool { return flagged[i].DueAt.Before(flagged[j].DueAt) })
+	return flagged, errors.Join(errs...)
+}
+
+// Review returns the current review of a dataset, or ErrNoReview.
+func (m *Manager) Review(ctx context.Context, datasetID string) (*Review, error) {
+	var rv Review
+	err := m.State.Get(ctx, reviewsTable, datasetID, &rv)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("%w for %s", ErrNoReview, datasetID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rv, nil
+}
+
+// Reviews returns the reviews with the given status, or all reviews if
+// status is empty, oldest due first.
+func (m *Manager) Reviews(ctx context.Context, status Status) ([]Review, error) {
+	all, err := state.List[Review](ctx, m.State, reviewsTable)
+	if err != nil {
+		return nil, err
+	}
+	out := all[:0]
+	for _, rv := range all {
+		if status == "" || rv.Status == status {
+			out = append(out, rv)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DueAt.Before(out[j].DueAt) })
+	return out, nil
+}
+
+// Confirm tombstones a dataset with a pending review. The principal in
+// ctx must hold a role with the retention permission and be allowed to
+// manage the dataset. The retention period is
+// checked again first; if the dataset is no longer due the review is
+// cleared and ErrNotDue is returned.
+func (m *Manager) Confirm(ctx context.Context, ref, reason string) (*dataset.Dataset, error) {
+	d, rv, err := m.decide(ctx, ref, reason)
+	if err != nil {
+		return nil, err
+	}
+	p, err := m.Policy(ctx, rv.Policy)
+	if err != nil {
+		return nil, err
+	}
+	due, err := m.DueAt(ctx, d, p)
+	if err != nil {
+		return nil, err
+	}
+	if due.After(m.now()) {
+		rv.Status = StatusCleared
+		if err := m.State.Put(ctx, reviewsTable, d.ID, rv); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %s is now retained until %s; the review has been cleared",
+			ErrNotDue, d.ID, due.Format(time.DateOnly))
+	}
+
+	details := map[string]string{"policy": p.Name, "due": due.Format(time.RFC3339)}
+	d.State = dataset.StateTombstoned
+	m.note(ctx, d, "retention.tombstone", reason, details)
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return nil, err
+	}
+	rv.Status = StatusTombstoned
+	if err := m.State.Put(ctx, reviewsTable, d.ID, rv); err != nil {
+		return nil, err
+	}
+	details["reason"] = reason
+	if err := m.record(ctx, "retention.tombstone", d.ID, details); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Retain keeps a dataset with a pending review until the given time,
+// after which it is flagged again. The principal in ctx must hold a
+// role with the retention permission and be allowed to manage the
+// dataset.
+func (m *Manager) Retain(ctx context.Context, ref string, until time.Time, reason string) (*Review, error) {
+	if !until.After(m.now()) {
+		return nil, fmt.Errorf("retention date %s is not in the future", until.Format(time.DateOnly))
+	}
+	d, rv, err := m.decide(ctx, ref, reason)
+	if err != nil {
+		return nil, err
+	}
+	until = until.UTC()
+	rv.Status = StatusRetained
+	rv.RetainUntil = &until
+
+	details := map[string]string{"policy": rv.Policy, "until": until.Format(time.RFC3339)}
+	m.note(ctx, d, "retention.retain", reason, details)
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return nil, err
+	}
+	if err := m.State.Put(ctx, reviewsTable, d.ID, rv); err != nil {
+		return nil, err
+	}
+	details["reason"] = reason
+	if err := m.record(ctx, "retention.retain", d.ID, details); err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// decide loads the dataset and pending review for a steward decision
+// and stamps the review with the decision's actor, time, and reason.
+func (m *Manager) decide(ctx context.Context, ref, reason string) (*dataset.Dataset, *Review, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, nil, fmt.Errorf("%w to decide a retention review", ErrReasonRequired)
+	}
+	d, err := m.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := identity.FromContext(ctx)
+	if err := authz.RequirePermission(p, authz.PermRetention); err != nil {
+		return nil, nil, err
+	}
+	if err := authz.Require(p, d.Resource(), authz.ActionManage); err != nil {
+		return nil, nil, err
+	}
+	rv, err := m.Review(ctx, d.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rv.Status != StatusPending {
+		return nil, nil, fmt.Errorf("%w for %s (review is %s)", ErrNoReview, d.ID, rv.Status)
+	}
+	now := m.now().UTC()
+	rv.DecidedBy = p.String()
+	rv.DecidedAt = &now
+	rv.Reason = reason
+	return d, rv, nil
+}
+
+// notify tells d's stewards that it has been flagged.
+func (m *Manager) notify(ctx context.Context, d *dataset.Dataset, p *Policy, due time.Time) error {
+	if m.Notifier == nil {
+		return nil
+	}
+	var errs []error
+	for _, to := range m.stewards(d) {
+		err := m.Notifier.Notify(ctx, notify.Message{
+			To:      to,
+			Subject: fmt.Sprintf("Retention review: %s", d.ID),
+			Body: fmt.Sprintf("The retention period of %q (%s) under policy %s ended on %s.\n\n"+
+				"Run 'aperture retention confirm %s --reason TEXT' to de-accession it, or\n"+
+				"'aperture retention retain %s --until DATE --reason TEXT' to keep it.\n",
+				d.Title, d.ID, p.Name, due.Format(time.DateOnly), d.ID, d.ID),
+			Time: m.now().UTC(),
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stewards returns the users named in d's manage list, or the
+// configured stewards if there are none.
+func (m *Manager) stewards(d *dataset.Dataset) []string {
+	var out []string
+	for _, e := range d.ACL.Entries(authz.ActionManage) {
+		if user, ok := strings.CutPrefix(e, authz.KindUser+":"); ok {
+			out = append(out, user)
+		}
+	}
+	if len(out) == 0 {
+		out = m.Stewards
+	}
+	return out
+}
+
+// published returns when d was first published, or when it was created
+// if no version records a publication date.
+func published(d *dataset.Dataset) time.Time {
+	var first time.Time
+	for _, v := range d.Versions {
+		if v.PublishedAt != nil && (first.IsZero() || v.PublishedAt.Before(first)) {
+			first = *v.PublishedAt
+		}
+	}
+	if first.IsZero() {
+		return d.CreatedAt
+	}
+	return first
+}
+
+// note appends an event to d's history; the caller saves d.
+func (m *Manager) note(ctx context.Context, d *dataset.Dataset, action, reason string, details map[string]string) {
+	d.History = append(d.History, dataset.Event{
+		Time:    m.now().UTC(),
+		Actor:   identity.FromContext(ctx).String(),
+		Action:  action,
+		Reason:  strings.TrimSpace(reason),
+		Details: maps.Clone(details),
+	})
+}
+
+// record appends an audit entry if a log is configured.
+func (m *Manager) record(ctx context.Context, action, target string, details map[string]string) error {
+	if m.Log == nil {
+		return nil
+	}
+	return audit.Record(ctx, m.Log, action, target, details)
+}
+
+func (m *Manager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,169 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/notify"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+type fakeAccesses map[string]time.Time
+
+func (f fakeAccesses) LastAccess(_ context.Context, id string) (time.Time, error) {
+	return f[id], nil
+}
+
+type fakeNotifier struct{ sent []notify.Message }
+
+func (n *fakeNotifier) Notify(_ context.Context, m notify.Message) error {
+	n.sent = append(n.sent, m)
+	return nil
+}
+
+func TestEvaluateAndConfirm(t *testing.T) {
+	steward := identity.WithPrincipal(context.Background(), identity.Principal{ID: "steward@uni.edu", Groups: []string{"stewards"}})
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	published := now.AddDate(-6, 0, 0)
+
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	for _, d := range []*dataset.Dataset{
+		{ID: "old", Retention: "nsf-5y"},
+		{ID: "used", Retention: "idle-5y"},
+		{ID: "idle", Retention: "idle-5y"},
+		{ID: "none"},
+	} {
+		d.State = dataset.StatePublished
+		d.ACL = &authz.ACL{Manage: []string{"user:steward@uni.edu"}}
+		d.Versions = []dataset.Version{{Number: 1, PublishedAt: &published}}
+		if err := datasets.Put(steward, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	accesses := fakeAccesses{"used": now.AddDate(-1, 0, 0)}
+	notifier := &fakeNotifier{}
+	log := &audit.MemoryLog{}
+	m := &Manager{
+		State:    s,
+		Datasets: datasets,
+		Accesses: accesses,
+		Notifier: notifier,
+		Log:      log,
+		Now:      func() time.Time { return now },
+	}
+	for _, p := range []Policy{
+		{Name: "nsf-5y", Years: 5, Basis: BasisPublished},
+		{Name: "idle-5y", Years: 5, Basis: BasisLastAccess},
+	} {
+		if err := m.PutPolicy(steward, p); err != nil {
+			t.Fatalf("PutPolicy() error = %v", err)
+		}
+	}
+	if err := m.PutPolicy(steward, Policy{Name: "bad", Years: 1, Basis: "funder"}); err == nil {
+		t.Error("PutPolicy() accepted an unknown basis")
+	}
+
+	flagged, err := m.Evaluate(steward)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if len(flagged) != 2 || flagged[0].DatasetID != "idle" && flagged[0].DatasetID != "old" {
+		t.Fatalf("Evaluate() = %+v, want idle and old", flagged)
+	}
+	if len(notifier.sent) != 2 || notifier.sent[0].To != "steward@uni.edu" {
+		t.Errorf("notifications = %+v", notifier.sent)
+	}
+	if again, _ := m.Evaluate(steward); len(again) != 0 {
+		t.Errorf("second Evaluate() = %+v, want pending reviews left alone", again)
+	}
+
+	other := identity.WithPrincipal(context.Background(), identity.Principal{ID: "eve@uni.edu", Groups: []string{"stewards"}})
+	if _, err := m.Confirm(other, "old", "retention ended"); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Confirm() by another steward error = %v, want ErrForbidden", err)
+	}
+	manager := identity.WithPrincipal(context.Background(), identity.Principal{ID: "steward@uni.edu"})
+	if _, err := m.Confirm(manager, "old", "retention ended"); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Confirm() by manager without the steward role error = %v, want ErrForbidden", err)
+	}
+	if _, err := m.Confirm(steward, "old", " "); !errors.Is(err, ErrReasonRequired) {
+		t.Errorf("Confirm() without reason error = %v, want ErrReasonRequired", err)
+	}
+	d, err := m.Confirm(steward, "old", "NSF retention ended")
+	if err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	if d.State != dataset.StateTombstoned || d.History[len(d.History)-1].Reason != "NSF retention ended" {
+		t.Errorf("Confirm() = %+v", d)
+	}
+	if _, err := m.Confirm(steward, "old", "again"); !errors.Is(err, ErrNoReview) {
+		t.Errorf("second Confirm() error = %v, want ErrNoReview", err)
+	}
+
+	// A download after flagging clears the review.
+	accesses["idle"] = now.AddDate(0, 0, -1)
+	if _, err := m.Confirm(steward, "idle", "unused"); !errors.Is(err, ErrNotDue) {
+		t.Errorf("Confirm() after access error = %v, want ErrNotDue", err)
+	}
+	if rv, _ := m.Review(steward, "idle"); rv.Status != StatusCleared {
+		t.Errorf("review = %+v, want cleared", rv)
+	}
+
+	entries, _ := log.Entries(steward)
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	if len(actions) != 5 || actions[4] != "retention.tombstone" {
+		t.Errorf("audit actions = %v", actions)
+	}
+}
+
+func TestRetain(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "ops@uni.edu", Groups: []string{authz.AdminGroup}})
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	if err := datasets.Put(ctx, &dataset.Dataset{ID: "ds-1", State: dataset.StatePublished, Retention: "p", CreatedAt: now.AddDate(-3, 0, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	m := &Manager{State: s, Datasets: datasets, Now: func() time.Time { return now }}
+	if err := m.PutPolicy(ctx, Policy{Name: "p", Years: 2, Basis: BasisPublished}); err != nil {
+		t.Fatal(err)
+	}
+
+	if flagged, _ := m.Evaluate(ctx); len(flagged) != 1 {
+		t.Fatalf("Evaluate() = %+v, want ds-1", flagged)
+	}
+	if _, err := m.Retain(ctx, "ds-1", now.AddDate(1, 0, 0), "still cited"); err != nil {
+		t.Fatalf("Retain() error = %v", err)
+	}
+	if flagged, _ := m.Evaluate(ctx); len(flagged) != 0 {
+		t.Errorf("Evaluate() while retained = %+v, want none", flagged)
+	}
+	m.Now = func() time.Time { return now.AddDate(1, 0, 1) }
+	if flagged, _ := m.Evaluate(ctx); len(flagged) != 1 {
+		t.Errorf("Evaluate() after retention = %+v, want ds-1 again", flagged)
+	}
+}
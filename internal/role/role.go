@@ -0,0 +1,194 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package role records repository roles granted to principals.
+//
+// Roles and their permissions are defined by package authz, which
+// grants a role to every member of its group. Grants recorded here add
+// those groups to a principal when it is resolved, so roles can be
+// given to service accounts and to deployments without a user pool.
+package role
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// grantsTable holds one Grant per principal ID.
+const grantsTable = "role-grants"
+
+// Grant is the set of roles granted to a principal.
+type Grant struct {
+	// User is the principal ID
+	User string `json:"user"`
+
+	// Roles are the granted roles
+	Roles []authz.Role `json:"roles"`
+
+	// UpdatedAt is when the grant last changed
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// UpdatedBy is who last changed the grant
+	UpdatedBy string `json:"updatedBy"`
+}
+
+// Registry stores role grants.
+type Registry struct {
+	s   state.Store
+	log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// NewRegistry returns a registry backed by s that records grants and
+// revocations in log, which may be nil.
+func NewRegistry(s state.Store, log audit.Log) *Registry {
+	return &Registry{s: s, log: log}
+}
+
+// Roles returns the roles granted to user.
+func (r *Registry) Roles(ctx context.Context, user string) ([]authz.Role, error) {
+	g, err := r.get(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return g.Roles, nil
+}
+
+// List returns every grant, ordered by user.
+func (r *Registry) List(ctx context.Context) ([]Grant, error) {
+	grants, err := state.List[Grant](ctx, r.s, grantsTable)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].User < grants[j].User })
+	return grants, nil
+}
+
+// Apply returns p with the groups of its granted roles added.
+func (r *Registry) Apply(ctx context.Context, p identity.Principal) (identity.Principal, error) {
+	if p.IsZero() {
+		return p, nil
+	}
+	roles, err := r.Roles(ctx, p.ID)
+	if err != nil {
+		return p, err
+	}
+	for _, role := range roles {
+		if g := role.Group(); !slices.Contains(p.Groups, g) {
+			p.Groups = append(slices.Clip(p.Groups), g)
+		}
+	}
+	return p, nil
+}
+
+// Grant gives user role. It reports whether the grant changed. The
+// acting principal must be allowed to manage users.
+func (r *Registry) Grant(ctx context.Context, user string, role authz.Role) (bool, error) {
+	g, err := r.change(ctx, user, role)
+	if err != nil {
+		return false, err
+	}
+	if slices.Contains(g.Roles, role) {
+		return false, nil
+	}
+	g.Roles = append(g.Roles, role)
+	slices.SortFunc(g.Roles, func(a, b authz.Role) int {
+		return slices.Index(authz.Roles, a) - slices.Index(authz.Roles, b)
+	})
+	return true, r.put(ctx, g, "role.grant", role)
+}
+
+// Revoke removes role from user. It reports whether the grant changed.
+// The acting principal must be allowed to manage users and may not
+// revoke their own admin role, so a repository cannot lose its last
+// administrator by accident.
+func (r *Registry) Revoke(ctx context.Context, user string, role authz.Role) (bool, error) {
+	g, err := r.change(ctx, user, role)
+	if err != nil {
+		return false, err
+	}
+	if role == authz.RoleAdmin && g.User == identity.Normalize(identity.FromContext(ctx).ID) {
+		return false, fmt.Errorf("%w: administrators may not revoke their own admin role", authz.ErrForbidden)
+	}
+	i := slices.Index(g.Roles, role)
+	if i < 0 {
+		return false, nil
+	}
+	g.Roles = slices.Delete(g.Roles, i, i+1)
+	return true, r.put(ctx, g, "role.revoke", role)
+}
+
+// change checks that the acting principal may change user's roles and
+// returns user's grant.
+func (r *Registry) change(ctx context.Context, user string, role authz.Role) (*Grant, error) {
+	if err := authz.RequirePermission(identity.FromContext(ctx), authz.PermManageUsers); err != nil {
+		return nil, err
+	}
+	if _, err := authz.ParseRole(string(role)); err != nil {
+		return nil, err
+	}
+	if identity.Normalize(user) == "" {
+		return nil, fmt.Errorf("a user is required")
+	}
+	return r.get(ctx, user)
+}
+
+func (r *Registry) get(ctx context.Context, user string) (*Grant, error) {
+	id := identity.Normalize(user)
+	var g Grant
+	err := r.s.Get(ctx, grantsTable, id, &g)
+	if errors.Is(err, state.ErrNotFound) {
+		return &Grant{User: id}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (r *Registry) put(ctx context.Context, g *Grant, action string, role authz.Role) error {
+	g.UpdatedAt = r.now().UTC()
+	g.UpdatedBy = identity.FromContext(ctx).String()
+	var err error
+	if len(g.Roles) == 0 {
+		err = r.s.Delete(ctx, grantsTable, g.User)
+	} else {
+		err = r.s.Put(ctx, grantsTable, g.User, g)
+	}
+	if err != nil {
+		return err
+	}
+	if r.log == nil {
+		return nil
+	}
+	return audit.Record(ctx, r.log, action, g.User, map[string]string{"role": string(role)})
+}
+
+func (r *Registry) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package role
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func TestGrantAndRevoke(t *testing.T) {
+	log := &audit.MemoryLog{}
+	r := NewRegistry(state.NewMemoryStore(), log)
+	admin := identity.WithPrincipal(context.Background(), identity.Principal{ID: "root@uni.edu", Groups: []string{authz.AdminGroup}})
+	curator := identity.WithPrincipal(context.Background(), identity.Principal{ID: "cat@uni.edu", Groups: []string{"curators"}})
+
+	if _, err := r.Grant(curator, "jane@uni.edu", authz.RoleSteward); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Grant() by curator error = %v, want ErrForbidden", err)
+	}
+	if _, err := r.Grant(admin, "jane@uni.edu", "owner"); err == nil {
+		t.Error("Grant() accepted an unknown role")
+	}
+
+	for _, role := range []authz.Role{authz.RoleSteward, authz.RoleDepositor} {
+		if changed, err := r.Grant(admin, "Jane@Uni.edu", role); err != nil || !changed {
+			t.Fatalf("Grant(%s) = %v, %v", role, changed, err)
+		}
+	}
+	if changed, _ := r.Grant(admin, "jane@uni.edu", authz.RoleSteward); changed {
+		t.Error("Grant() again reported a change")
+	}
+	if got, _ := r.Roles(admin, "jane@uni.edu"); !slices.Equal(got, []authz.Role{authz.RoleDepositor, authz.RoleSteward}) {
+		t.Errorf("Roles() = %v, want depositor, steward", got)
+	}
+
+	p, err := r.Apply(admin, identity.Principal{ID: "jane@uni.edu", Groups: []string{"researchers", "reviewers"}})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !slices.Equal(p.Groups, []string{"researchers", "reviewers", "stewards"}) || !authz.Can(p, authz.PermRetention) {
+		t.Errorf("Apply() groups = %v", p.Groups)
+	}
+
+	if _, err := r.Revoke(admin, "root@uni.edu", authz.RoleAdmin); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Revoke() of own admin role error = %v, want ErrForbidden", err)
+	}
+	for _, role := range []authz.Role{authz.RoleSteward, authz.RoleDepositor} {
+		if changed, err := r.Revoke(admin, "jane@uni.edu", role); err != nil || !changed {
+			t.Fatalf("Revoke(%s) = %v, %v", role, changed, err)
+		}
+	}
+	if grants, _ := r.List(admin); len(grants) != 0 {
+		t.Errorf("List() after revoking every role = %+v", grants)
+	}
+	if entries, _ := log.Entries(admin); len(entries) != 4 {
+		t.Errorf("audit entries = %d, want 4", len(entries))
+	}
+}
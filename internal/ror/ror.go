@@ -0,0 +1,283 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ror resolves free-text affiliations to Research Organization
+// Registry (ROR) identifiers.
+//
+// Affiliations are matched with the ROR affiliation endpoint, which
+// tolerates abbreviations, word order, and typos. A match is accepted
+// without asking when ROR marks it as chosen or when one of the
+// organization's names, aliases, or acronyms is the affiliation up to
+// case and punctuation; otherwise the candidates are offered to the
+// person depositing for disambiguation.
+package ror
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DefaultURL is the ROR API.
+const DefaultURL = "https://api.ror.org"
+
+// Resolver is the ROR ID prefix.
+const Resolver = "https://ror.org/"
+
+// MinScore is the lowest match score offered as a candidate.
+const MinScore = 0.5
+
+// MaxCandidates caps the candidates offered for one affiliation.
+const MaxCandidates = 5
+
+// Options configures a Client.
+type Options struct {
+	// BaseURL is the ROR API root
+	BaseURL string
+
+	// ClientID identifies the repository to ROR for higher rate limits;
+	// optional
+	ClientID string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is a ROR API client.
+type Client struct {
+	baseURL  string
+	clientID string
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	c := &Client{
+		baseURL:  strings.TrimRight(opts.BaseURL, "/"),
+		clientID: opts.ClientID,
+		http:     opts.HTTPClient,
+	}
+	if c.baseURL == "" {
+		c.baseURL = DefaultURL
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Name is one of an organization's names.
+type Name struct {
+	Value string   `json:"value"`
+	Types []string `json:"types"`
+	Lang  string   `json:"lang,omitempty"`
+}
+
+// Location is where an organization is.
+type Location struct {
+	Details struct {
+		Name        string `json:"name"`
+		CountryName string `json:"country_name"`
+	} `json:"geonames_details"`
+}
+
+// Organization is a ROR record.
+type Organization struct {
+	ID        string     `json:"id"`
+	Names     []Name     `json:"names"`
+	Locations []Location `json:"locations"`
+	Status    string     `json:"status"`
+}
+
+// DisplayName returns the organization's display name.
+func (o *Organization) DisplayName() string {
+	for _, n := range o.Names {
+		for _, t := range n.Types {
+			if t == "ror_display" {
+				return n.Value
+			}
+		}
+	}
+	if len(o.Names) > 0 {
+		return o.Names[0].Value
+	}
+	return o.ID
+}
+
+// Place returns the organization's city and country.
+func (o *Organization) Place() string {
+	if len(o.Locations) == 0 {
+		return ""
+	}
+	d := o.Locations[0].Details
+	return strings.Trim(d.Name+", "+d.CountryName, ", ")
+}
+
+// Match is a candidate organization for an affiliation.
+type Match struct {
+	Organization Organization `json:"organization"`
+	Score        float64      `json:"score"`
+	MatchingType string       `json:"matching_type"`
+	Chosen       bool         `json:"chosen"`
+}
+
+// APIError is returned for non-2xx responses.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error implements error.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ror: HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// Match returns the organizations matching a free-text affiliation,
+// best first.
+func (c *Client) Match(ctx context.Context, affiliation string) ([]Match, error) {
+	var out struct {
+		Items []Match `json:"items"`
+	}
+	if err := c.get(ctx, "/v2/organizations?affiliation="+url.QueryEscape(affiliation), &out); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(out.Items, func(i, j int) bool { return out.Items[i].Score > out.Items[j].Score })
+	return out.Items, nil
+}
+
+// Get returns the organization with a ROR ID.
+func (c *Client) Get(ctx context.Context, id string) (*Organization, error) {
+	if !Valid(id) {
+		return nil, fmt.Errorf("invalid ROR ID %q", id)
+	}
+	var o Organization
+	if err := c.get(ctx, "/v2/organizations/"+strings.TrimPrefix(Normalize(id), Resolver), &o); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// get fetches path and decodes the response into out.
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.clientID != "" {
+		req.Header.Set("Client-Id", c.clientID)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("ror request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// Best returns the match that can be accepted without asking, or nil.
+func Best(affiliation string, matches []Match) *Organization {
+	for i := range matches {
+		if matches[i].Chosen {
+			return &matches[i].Organization
+		}
+	}
+	want := fold(affiliation)
+	var found *Organization
+	for i := range matches {
+		for _, n := range matches[i].Organization.Names {
+			if fold(n.Value) == want {
+				if found != nil && found.ID != matches[i].Organization.ID {
+					return nil
+				}
+				found = &matches[i].Organization
+			}
+		}
+	}
+	return found
+}
+
+// Candidates returns the matches worth offering for disambiguation.
+func Candidates(matches []Match) []Match {
+	var out []Match
+	for _, m := range matches {
+		if m.Score >= MinScore && m.Organization.Status != "withdrawn" {
+			out = append(out, m)
+		}
+		if len(out) == MaxCandidates {
+			break
+		}
+	}
+	return out
+}
+
+// fold lowercases s and drops punctuation, articles, and extra spaces,
+// so "The University of Oxford" and "University of Oxford." compare
+// equal.
+func fold(s string) string {
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if w != "the" {
+			words = append(words, w)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+var idRE = regexp.MustCompile(`^0[0-9a-hjkmnp-tv-z]{6}[0-9]{2}$`)
+
+// crockford is the Crockford base32 alphabet ROR IDs are written in.
+const crockford = "0123456789abcdefghjkmnpqrstvwxyz"
+
+// Normalize returns id as a ROR URL.
+func Normalize(id string) string {
+	id = strings.ToLower(strings.TrimSpace(id))
+	for _, p := range []string{Resolver, "http://ror.org/", "ror.org/"} {
+		id = strings.TrimPrefix(id, p)
+	}
+	return Resolver + id
+}
+
+// Valid reports whether id is a well-formed ROR ID with a correct
+// checksum.
+func Valid(id string) bool {
+	s := strings.TrimPrefix(Normalize(id), Resolver)
+	if !idRE.MatchString(s) {
+		return false
+	}
+	var n int64
+	for _, c := range s[:7] {
+		n = n*32 + int64(strings.IndexRune(crockford, c))
+	}
+	return fmt.Sprintf("%02d", 98-(n*100)%97) == s[7:]
+}
@@ -0,0 +1,118 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ror
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func org(id, name string, aliases ...string) Organization {
+	o := Organization{ID: id, Names: []Name{{Value: name, Types: []string{"ror_display", "label"}}}, Status: "active"}
+	for _, a := range aliases {
+		o.Names = append(o.Names, Name{Value: a, Types: []string{"alias"}})
+	}
+	return o
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"https://ror.org/03yrm5c26", true},
+		{"03yrm5c26", true},
+		{"ror.org/03YRM5C26", true},
+		{"https://ror.org/03yrm5c27", false},
+		{"https://ror.org/13yrm5c26", false},
+		{"https://ror.org/03yrl5c26", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := Valid(tt.id); got != tt.want {
+			t.Errorf("Valid(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestBest(t *testing.T) {
+	oxford := org("https://ror.org/052gg0110", "University of Oxford", "Oxford University")
+	brookes := org("https://ror.org/04v2twj65", "Oxford Brookes University")
+	tests := []struct {
+		name        string
+		affiliation string
+		matches     []Match
+		want        string
+	}{
+		{"chosen by ROR", "Univ. Oxford", []Match{{Organization: brookes, Score: 0.8}, {Organization: oxford, Score: 0.9, Chosen: true}}, oxford.ID},
+		{"alias up to case and punctuation", "the oxford university.", []Match{{Organization: brookes, Score: 0.8}, {Organization: oxford, Score: 0.8}}, oxford.ID},
+		{"ambiguous", "Oxford", []Match{{Organization: oxford, Score: 0.7}, {Organization: brookes, Score: 0.7}}, ""},
+		{"no matches", "Oxford", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Best(tt.affiliation, tt.matches)
+			if (got == nil && tt.want != "") || (got != nil && got.ID != tt.want) {
+				t.Errorf("Best() = %v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	withdrawn := org("https://ror.org/00x", "Old Institute")
+	withdrawn.Status = "withdrawn"
+	var matches []Match
+	for i := range 8 {
+		matches = append(matches, Match{Organization: org("https://ror.org/0"+string(rune('a'+i)), "Org"), Score: 0.9 - float64(i)*0.05})
+	}
+	matches = append([]Match{{Organization: withdrawn, Score: 1}}, matches...)
+	matches = append(matches, Match{Organization: org("https://ror.org/0z", "Far"), Score: 0.2})
+	got := Candidates(matches)
+	if len(got) != MaxCandidates || got[0].Organization.ID == withdrawn.ID {
+		t.Errorf("Candidates() = %d matches starting %s", len(got), got[0].Organization.ID)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/organizations" || r.URL.Query().Get("affiliation") != "Univ of Oxford" || r.Header.Get("Client-Id") != "repo" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"number_of_results":2,"items":[
+			{"score":0.62,"matching_type":"FUZZY","chosen":false,"organization":{"id":"https://ror.org/04v2twj65","names":[{"value":"Oxford Brookes University","types":["ror_display"]}],"status":"active"}},
+			{"score":0.9,"matching_type":"PHRASE","chosen":true,"organization":{"id":"https://ror.org/052gg0110","names":[{"value":"University of Oxford","types":["ror_display"]}],"locations":[{"geonames_details":{"name":"Oxford","country_name":"United Kingdom"}}],"status":"active"}}]}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(Options{BaseURL: srv.URL, ClientID: "repo"})
+	matches, err := c.Match(context.Background(), "Univ of Oxford")
+	if err != nil {
+		t.Fatalf("Match() error = %v", err)
+	}
+	if len(matches) != 2 || matches[0].Organization.ID != "https://ror.org/052gg0110" {
+		t.Fatalf("Match() = %+v, want best first", matches)
+	}
+	if o := matches[0].Organization; o.DisplayName() != "University of Oxford" || o.Place() != "Oxford, United Kingdom" {
+		t.Errorf("organization = %q in %q", o.DisplayName(), o.Place())
+	}
+	if _, err := c.Get(context.Background(), "not-an-id"); err == nil {
+		t.Error("Get() of an invalid ID succeeded, want error")
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// S3-compatible stores such as MinIO, Ceph, and Wasabi implement the
+// core of the API but not all of it: most keep one storage class, and
+// some lack object attributes, versioning, or Object Lock. The client
+// detects what an endpoint lacks from the errors it returns and falls
+// back rather than failing: an object is stored in the nearest storage
+// class the endpoint has, or its default one, and attributes are read
+// from the object's headers, without checksums. Probe detects the same
+// up front.
+
+// fallbacks lists, for each storage class, the class tried next when an
+// endpoint does not support it; the bucket's default follows the last.
+var fallbacks = map[string]string{
+	"DEEP_ARCHIVE":        "GLACIER",
+	"GLACIER":             "GLACIER_IR",
+	"GLACIER_IR":          "STANDARD_IA",
+	"ONEZONE_IA":          "STANDARD_IA",
+	"STANDARD_IA":         "STANDARD",
+	"INTELLIGENT_TIERING": "STANDARD",
+}
+
+// attributesFeature marks the GetObjectAttributes operation lacking.
+const attributesFeature = "attributes"
+
+// unsupported reports whether err is an endpoint's refusal of a feature
+// it does not implement.
+func unsupported(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.StatusCode == http.StatusNotImplemented || e.Code == "NotImplemented" || e.Code == "InvalidStorageClass"
+}
+
+// lacks reports whether requests found the endpoint lacking feature, a
+// storage class or attributesFeature.
+func (c *Client) lacks(feature string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lacking[feature]
+}
+
+// lack records that the endpoint lacks feature.
+func (c *Client) lack(feature string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lacking == nil {
+		c.lacking = map[string]bool{}
+	}
+	c.lacking[feature] = true
+}
+
+// storageClass returns class, or the nearest class to it the endpoint
+// is not known to lack; "" for the bucket's default.
+func (c *Client) storageClass(class string) string {
+	for class != "" && c.lacks(class) {
+		class = fallbacks[class]
+	}
+	return class
+}
+
+// withStorageClass calls f with class, or the nearest class the
+// endpoint supports, falling back further each time the endpoint
+// rejects one.
+func (c *Client) withStorageClass(class string, f func(class string) error) error {
+	for {
+		cls := c.storageClass(class)
+		err := f(cls)
+		if cls == "" || !unsupported(err) {
+			return err
+		}
+		c.lack(cls)
+	}
+}
+
+// setStorageClass sets the storage class header of h to class, or
+// removes it if class is empty.
+func setStorageClass(h http.Header, class string) {
+	if class == "" {
+		h.Del("X-Amz-Storage-Class")
+		return
+	}
+	h.Set("X-Amz-Storage-Class", class)
+}
+
+// Capabilities are the optional features of the S3 API an endpoint and
+// one of its buckets support.
+type Capabilities struct {
+	// StorageClasses are the storage classes objects can be stored in,
+	// STANDARD first
+	StorageClasses []string `json:"storageClasses"`
+
+	// ObjectAttributes reports whether GetObjectAttributes works,
+	// returning the checksums fixity checks verify without reading
+	// objects
+	ObjectAttributes bool `json:"objectAttributes"`
+
+	// Versioning reports whether the bucket has versioning enabled
+	Versioning bool `json:"versioning"`
+
+	// ObjectLock reports whether the bucket has Object Lock enabled
+	ObjectLock bool `json:"objectLock"`
+}
+
+// probedClasses are the storage classes Probe tries.
+var probedClasses = []string{"STANDARD_IA", "ONEZONE_IA", "INTELLIGENT_TIERING", "GLACIER_IR", "GLACIER", "DEEP_ARCHIVE"}
+
+// probeKey is the key of the objects Probe stores and deletes.
+const probeKey = ".aperture-probe"
+
+// Probe detects the capabilities of bucket and its endpoint by storing
+// and deleting a small object in each storage class, and records those
+// the endpoint lacks so that later requests fall back at once. A class
+// the endpoint accepts but does not report storing an object in counts
+// as lacking.
+func (c *Client) Probe(ctx context.Context, bucket string) (*Capabilities, error) {
+	caps := &Capabilities{StorageClasses: []string{"STANDARD"}}
+	body := []byte("aperture capability probe\n")
+	if err := c.PutObject(ctx, bucket, probeKey, body, "text/plain"); err != nil {
+		return nil, err
+	}
+	defer c.DeleteObject(context.WithoutCancel(ctx), bucket, probeKey)
+
+	_, err := c.GetObjectAttributes(ctx, bucket, probeKey)
+	if err != nil {
+		return nil, err
+	}
+	caps.ObjectAttributes = !c.lacks(attributesFeature)
+
+	for _, class := range probedClasses {
+		h := http.Header{"X-Amz-Storage-Class": {class}}
+		resp, err := c.send(ctx, http.MethodPut, bucket, probeKey, nil, h, body)
+		if err == nil {
+			err = checkResponse(resp)
+			resp.Body.Close()
+		}
+		if unsupported(err) {
+			c.lack(class)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		info, err := c.HeadObject(ctx, bucket, probeKey)
+		if err != nil {
+			return nil, err
+		}
+		if cmp.Or(info.StorageClass, "STANDARD") != class {
+			c.lack(class)
+			continue
+		}
+		caps.StorageClasses = append(caps.StorageClasses, class)
+	}
+
+	var versioning struct {
+		Status string `xml:"Status"`
+	}
+	if err := c.doXML(ctx, http.MethodGet, bucket, "", url.Values{"versioning": {""}}, nil, &versioning); err != nil && !unsupported(err) {
+		return nil, err
+	}
+	caps.Versioning = versioning.Status == "Enabled"
+
+	var lock struct {
+		ObjectLockEnabled string `xml:"ObjectLockEnabled"`
+	}
+	err = c.doXML(ctx, http.MethodGet, bucket, "", url.Values{"object-lock": {""}}, nil, &lock)
+	var e *Error
+	if err != nil && !unsupported(err) && !(errors.As(err, &e) && e.Code == "ObjectLockConfigurationNotFoundError") {
+		return nil, err
+	}
+	caps.ObjectLock = lock.ObjectLockEnabled == "Enabled"
+	return caps, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeCompatible is a store with the STANDARD and GLACIER_IR storage
+// classes that accepts INTELLIGENT_TIERING but ignores it, rejects the
+// other classes, and lacks GetObjectAttributes, as S3-compatible stores
+// do.
+type fakeCompatible struct {
+	classes  map[string]string
+	requests []string
+}
+
+func (f *fakeCompatible) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	class := r.Header.Get("X-Amz-Storage-Class")
+	f.requests = append(f.requests, strings.TrimSpace(r.Method+" "+class))
+	switch {
+	case q.Has("attributes"):
+		w.WriteHeader(http.StatusNotImplemented)
+		fmt.Fprint(w, `<Error><Code>NotImplemented</Code><Message>A header you provided implies functionality that is not implemented</Message></Error>`)
+	case q.Has("versioning"):
+		fmt.Fprint(w, `<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`)
+	case q.Has("object-lock"):
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `<Error><Code>ObjectLockConfigurationNotFoundError</Code></Error>`)
+	case r.Method == http.MethodPut && class != "" && class != "STANDARD" && class != "GLACIER_IR" && class != "INTELLIGENT_TIERING":
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `<Error><Code>InvalidStorageClass</Code><Message>Invalid storage class.</Message></Error>`)
+	case r.Method == http.MethodPut:
+		if class == "INTELLIGENT_TIERING" {
+			class = ""
+		}
+		f.classes[r.URL.Path] = class
+	case r.Method == http.MethodHead:
+		w.Header().Set("Content-Length", "5")
+		if c := f.classes[r.URL.Path]; c != "" {
+			w.Header().Set("X-Amz-Storage-Class", c)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.classes, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestStorageClassFallback(t *testing.T) {
+	f := &fakeCompatible{classes: map[string]string{}}
+	c := newTestClient(t, f.ServeHTTP)
+	ctx := context.Background()
+
+	if _, err := c.Upload(ctx, "media", "a", strings.NewReader("hello"), PutOptions{StorageClass: "DEEP_ARCHIVE"}); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if want := []string{"PUT DEEP_ARCHIVE", "PUT GLACIER", "PUT GLACIER_IR"}; !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %v, want %v", f.requests, want)
+	}
+	if f.classes["/media/a"] != "GLACIER_IR" {
+		t.Errorf("class = %q, want GLACIER_IR", f.classes["/media/a"])
+	}
+
+	// Later requests go to the nearest class at once.
+	f.requests = nil
+	if _, err := c.Upload(ctx, "media", "b", strings.NewReader("hello"), PutOptions{StorageClass: "GLACIER"}); err != nil || !slices.Equal(f.requests, []string{"PUT GLACIER_IR"}) {
+		t.Errorf("Upload() = %v; requests %v", err, f.requests)
+	}
+	f.requests = nil
+	if err := c.SetStorageClass(ctx, "media", "b", "DEEP_ARCHIVE"); err != nil || !slices.Equal(f.requests, []string{"HEAD"}) {
+		t.Errorf("SetStorageClass() to the class already held = %v; requests %v", err, f.requests)
+	}
+	f.requests = nil
+	if err := c.SetStorageClass(ctx, "media", "b", "ONEZONE_IA"); err != nil || !slices.Equal(f.requests, []string{"HEAD", "PUT ONEZONE_IA", "PUT STANDARD_IA", "PUT STANDARD"}) {
+		t.Errorf("SetStorageClass() = %v; requests %v", err, f.requests)
+	}
+}
+
+func TestObjectAttributesFallback(t *testing.T) {
+	f := &fakeCompatible{classes: map[string]string{"/media/a": "GLACIER_IR"}}
+	c := newTestClient(t, f.ServeHTTP)
+	ctx := context.Background()
+	for range 2 {
+		attrs, err := c.GetObjectAttributes(ctx, "media", "a")
+		if err != nil || attrs.ObjectSize != 5 || attrs.StorageClass != "GLACIER_IR" || attrs.Checksum.SHA256 != "" {
+			t.Errorf("GetObjectAttributes() = %+v, %v", attrs, err)
+		}
+	}
+	if want := []string{"GET", "HEAD", "HEAD"}; !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %v, want %v", f.requests, want)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	f := &fakeCompatible{classes: map[string]string{}}
+	c := newTestClient(t, f.ServeHTTP)
+	caps, err := c.Probe(context.Background(), "media")
+	if err != nil {
+		t.Fatalf("Probe() error = %v", err)
+	}
+	if !slices.Equal(caps.StorageClasses, []string{"STANDARD", "GLACIER_IR"}) || caps.ObjectAttributes || !caps.Versioning || caps.ObjectLock {
+		t.Errorf("Probe() = %+v", caps)
+	}
+	if len(f.classes) != 0 {
+		t.Errorf("Probe() left %v", f.classes)
+	}
+	if !c.lacks("INTELLIGENT_TIERING") || c.lacks("GLACIER_IR") {
+		t.Errorf("lacking = %v", c.lacking)
+	}
+}
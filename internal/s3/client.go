@@ -0,0 +1,854 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package s3 is a minimal Amazon S3 REST client.
+package s3
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/metrics"
+	"github.com/scttfrdmn/aperture/internal/trace"
+)
+
+// ErrNotFound is returned when an object or bucket does not exist.
+var ErrNotFound = errors.New("s3: not found")
+
+// Options configures a Client.
+type Options struct {
+	// Region is the AWS region
+	Region string
+
+	// Endpoint overrides the regional AWS endpoint
+	// (e.g. "http://localhost:9000")
+	Endpoint string
+
+	// PathStyle addresses buckets as a path segment rather than a
+	// subdomain; required for most non-AWS endpoints
+	PathStyle bool
+
+	// Credentials sign requests
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+
+	// Metrics records request latency, errors, and upload throughput;
+	// skipped if nil
+	Metrics metrics.Recorder
+}
+
+// Client is an S3 client.
+type Client struct {
+	endpoint  *url.URL
+	pathStyle bool
+	signer    *aws.Signer
+	http      *http.Client
+	metrics   metrics.Recorder
+
+	// lacking holds the features requests found the endpoint lacks
+	mu      sync.Mutex
+	lacking map[string]bool
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) (*Client, error) {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + opts.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+
+	c := &Client{
+		endpoint:  u,
+		pathStyle: opts.PathStyle,
+		signer:    &aws.Signer{Credentials: opts.Credentials, Region: opts.Region, Service: "s3"},
+		http:      opts.HTTPClient,
+		metrics:   opts.Metrics,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c, nil
+}
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+	StorageClass string    `xml:"StorageClass"`
+
+	// Restore is the restore status of an archived object, from
+	// HeadObject, e.g. `ongoing-request="false", expiry-date="..."`;
+	// empty if no restore was requested
+	Restore string `xml:"-"`
+
+	// VersionID is the version of the object, from HeadObject; empty,
+	// or "null", if its bucket is not versioned
+	VersionID string `xml:"-"`
+
+	// ContentType is the media type of the object, from HeadObject
+	ContentType string `xml:"-"`
+}
+
+// Restoring reports whether a restore of the archived object is in
+// progress.
+func (o ObjectInfo) Restoring() bool {
+	return strings.Contains(o.Restore, `ongoing-request="true"`)
+}
+
+// Restored reports whether the archived object has a restored copy
+// that can be read.
+func (o ObjectInfo) Restored() bool {
+	return strings.Contains(o.Restore, `ongoing-request="false"`)
+}
+
+// Upload is an in-progress multipart upload.
+type Upload struct {
+	Key       string    `xml:"Key"`
+	UploadID  string    `xml:"UploadId"`
+	Initiated time.Time `xml:"Initiated"`
+}
+
+// ListObjects calls fn for every object in bucket under prefix.
+func (c *Client) ListObjects(ctx context.Context, bucket, prefix string, fn func(ObjectInfo) error) error {
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}}
+		if prefix != "" {
+			q.Set("prefix", prefix)
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+
+		var out struct {
+			Contents              []ObjectInfo `xml:"Contents"`
+			IsTruncated           bool         `xml:"IsTruncated"`
+			NextContinuationToken string       `xml:"NextContinuationToken"`
+		}
+		if err := c.doXML(ctx, http.MethodGet, bucket, "", q, nil, &out); err != nil {
+			return err
+		}
+		for _, obj := range out.Contents {
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+		if !out.IsTruncated || out.NextContinuationToken == "" {
+			return nil
+		}
+		token = out.NextContinuationToken
+	}
+}
+
+// ListMultipartUploads returns the in-progress multipart uploads in
+// bucket.
+func (c *Client) ListMultipartUploads(ctx context.Context, bucket string) ([]Upload, error) {
+	var uploads []Upload
+	keyMarker, idMarker := "", ""
+	for {
+		q := url.Values{"uploads": {""}}
+		if keyMarker != "" {
+			q.Set("key-marker", keyMarker)
+			q.Set("upload-id-marker", idMarker)
+		}
+
+		var out struct {
+			Uploads            []Upload `xml:"Upload"`
+			IsTruncated        bool     `xml:"IsTruncated"`
+			NextKeyMarker      string   `xml:"NextKeyMarker"`
+			NextUploadIDMarker string   `xml:"NextUploadIdMarker"`
+		}
+		if err := c.doXML(ctx, http.MethodGet, bucket, "", q, nil, &out); err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, out.Uploads...)
+		if !out.IsTruncated {
+			return uploads, nil
+		}
+		keyMarker, idMarker = out.NextKeyMarker, out.NextUploadIDMarker
+	}
+}
+
+// AbortMultipartUpload aborts an in-progress upload and frees its parts.
+func (c *Client) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	return c.doXML(ctx, http.MethodDelete, bucket, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+}
+
+// DeleteObject deletes an object.
+func (c *Client) DeleteObject(ctx context.Context, bucket, key string) error {
+	return c.doXML(ctx, http.MethodDelete, bucket, key, nil, nil, nil)
+}
+
+// GetObject returns the contents of an object. The caller must close
+// the returned reader.
+func (c *Client) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	return c.GetObjectVersion(ctx, bucket, key, "")
+}
+
+// GetObjectVersion returns the contents of a version of an object, or
+// of its current version if versionID is empty. The caller must close
+// the returned reader.
+func (c *Client) GetObjectVersion(ctx context.Context, bucket, key, versionID string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, http.MethodGet, bucket, key, versionQuery(versionID), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// PutObject stores body at key.
+func (c *Client) PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error {
+	h := http.Header{}
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	resp, err := c.send(ctx, http.MethodPut, bucket, key, nil, h, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// PutOptions set the metadata of an uploaded object.
+type PutOptions struct {
+	ContentType string
+
+	// StorageClass is the storage class, e.g. DEEP_ARCHIVE; the
+	// bucket's default if empty
+	StorageClass string
+
+	// Size is the expected size, used to choose the part size of large
+	// uploads; if zero, objects up to 640 GiB can be uploaded
+	Size int64
+
+	// RetainUntil, if set, locks the object in compliance mode until
+	// then; the bucket must have Object Lock enabled
+	RetainUntil time.Time
+}
+
+// minPartSize is the part size of streamed uploads, raised for
+// objects too large to upload in maxParts parts.
+const minPartSize = 64 << 20
+
+// maxParts is the most parts of a multipart upload.
+const maxParts = 10000
+
+// Upload stores the content of r at key, streaming it in parts if it
+// is larger than one part, and returns the number of bytes stored.
+func (c *Client) Upload(ctx context.Context, bucket, key string, r io.Reader, opts PutOptions) (int64, error) {
+	h := http.Header{}
+	if opts.ContentType != "" {
+		h.Set("Content-Type", opts.ContentType)
+	}
+	locked := !opts.RetainUntil.IsZero()
+	if locked {
+		h.Set("X-Amz-Object-Lock-Mode", "COMPLIANCE")
+		h.Set("X-Amz-Object-Lock-Retain-Until-Date", opts.RetainUntil.UTC().Format(time.RFC3339))
+	}
+	buf := make([]byte, max(minPartSize, (opts.Size+maxParts-1)/maxParts))
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		if locked {
+			h.Set("Content-MD5", contentMD5(buf[:n]))
+		}
+		err := c.withStorageClass(opts.StorageClass, func(class string) error {
+			setStorageClass(h, class)
+			resp, err := c.send(ctx, http.MethodPut, bucket, key, nil, h, buf[:n])
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			return checkResponse(resp)
+		})
+		if err != nil {
+			return 0, err
+		}
+		return int64(n), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var uploadID string
+	err = c.withStorageClass(opts.StorageClass, func(class string) (err error) {
+		setStorageClass(h, class)
+		uploadID, err = c.createMultipartUpload(ctx, bucket, key, h)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	var parts []completedPart
+	var size int64
+	for num := 1; n > 0; num++ {
+		q := url.Values{"partNumber": {fmt.Sprint(num)}, "uploadId": {uploadID}}
+		ph := http.Header{}
+		if locked {
+			ph.Set("Content-MD5", contentMD5(buf[:n]))
+		}
+		resp, err := c.send(ctx, http.MethodPut, bucket, key, q, ph, buf[:n])
+		if err == nil {
+			err = checkResponse(resp)
+			resp.Body.Close()
+		}
+		if err != nil {
+			_ = c.AbortMultipartUpload(ctx, bucket, key, uploadID)
+			return 0, fmt.Errorf("failed to upload part %d: %w", num, err)
+		}
+		parts = append(parts, completedPart{PartNumber: num, ETag: resp.Header.Get("ETag")})
+		size += int64(n)
+		if n, err = io.ReadFull(r, buf); err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			_ = c.AbortMultipartUpload(ctx, bucket, key, uploadID)
+			return 0, err
+		}
+	}
+	if err := c.completeMultipartUpload(ctx, bucket, key, uploadID, parts); err != nil {
+		return 0, fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return size, nil
+}
+
+// contentMD5 returns the Content-MD5 header of body, which S3 requires
+// of uploads under Object Lock.
+func contentMD5(body []byte) string {
+	sum := md5.Sum(body)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// maxCopySize is the largest object CopyObject copies in one request;
+// larger objects are copied in parts.
+const maxCopySize = 5 << 30
+
+// copyPartSize is the part size for multipart copies.
+const copyPartSize = 512 << 20
+
+// HeadObject returns the metadata of an object.
+func (c *Client) HeadObject(ctx context.Context, bucket, key string) (ObjectInfo, error) {
+	return c.headObject(ctx, bucket, key, "")
+}
+
+// HeadBucket checks that bucket exists and is accessible. It returns
+// an error wrapping ErrNotFound if no account owns the bucket, and an
+// *Error with status 403 if another account does.
+func (c *Client) HeadBucket(ctx context.Context, bucket string) error {
+	resp, err := c.send(ctx, http.MethodHead, bucket, "", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// CreateBucket creates bucket in the client's region. A bucket the
+// account already owns is not an error.
+func (c *Client) CreateBucket(ctx context.Context, bucket string) error {
+	var body []byte
+	if region := c.signer.Region; region != "us-east-1" {
+		var cfg struct {
+			XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+			LocationConstraint string   `xml:"LocationConstraint"`
+		}
+		cfg.LocationConstraint = region
+		var err error
+		if body, err = xml.Marshal(cfg); err != nil {
+			return err
+		}
+	}
+	err := c.doXML(ctx, http.MethodPut, bucket, "", nil, body, nil)
+	var e *Error
+	if errors.As(err, &e) && e.Code == "BucketAlreadyOwnedByYou" {
+		return nil
+	}
+	return err
+}
+
+// headObject returns the metadata of a version of an object, or of its
+// current version if versionID is empty.
+func (c *Client) headObject(ctx context.Context, bucket, key, versionID string) (ObjectInfo, error) {
+	resp, err := c.send(ctx, http.MethodHead, bucket, key, versionQuery(versionID), nil, nil)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return ObjectInfo{}, err
+	}
+	info := ObjectInfo{
+		Key:          key,
+		Size:         resp.ContentLength,
+		ETag:         resp.Header.Get("ETag"),
+		StorageClass: resp.Header.Get("X-Amz-Storage-Class"),
+		Restore:      resp.Header.Get("X-Amz-Restore"),
+		VersionID:    resp.Header.Get("X-Amz-Version-Id"),
+		ContentType:  resp.Header.Get("Content-Type"),
+	}
+	info.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info, nil
+}
+
+// Restore tiers, fastest and most expensive first.
+const (
+	TierExpedited = "Expedited"
+	TierStandard  = "Standard"
+	TierBulk      = "Bulk"
+)
+
+// RestoreObject requests a temporary copy of an object archived in
+// Glacier or Glacier Deep Archive, readable for days once restored,
+// retrieved at tier. Requesting a restore already in progress is not
+// an error.
+func (c *Client) RestoreObject(ctx context.Context, bucket, key string, days int, tier string) error {
+	var req struct {
+		XMLName xml.Name `xml:"RestoreRequest"`
+		Days    int      `xml:"Days"`
+		Tier    string   `xml:"GlacierJobParameters>Tier"`
+	}
+	req.Days, req.Tier = days, tier
+	body, err := xml.Marshal(req)
+	if err != nil {
+		return err
+	}
+	err = c.doXML(ctx, http.MethodPost, bucket, key, url.Values{"restore": {""}}, body, nil)
+	var e *Error
+	if errors.As(err, &e) && e.Code == "RestoreAlreadyInProgress" {
+		return nil
+	}
+	return err
+}
+
+// Attributes are the attributes of an object returned by
+// GetObjectAttributes.
+type Attributes struct {
+	ETag         string `xml:"ETag"`
+	ObjectSize   int64  `xml:"ObjectSize"`
+	StorageClass string `xml:"StorageClass"`
+
+	// Checksum holds the base64-encoded checksums S3 stored with the
+	// object, if it was uploaded with one. Checksums of multipart
+	// uploads are checksums of the part checksums, with a "-N" suffix
+	// giving the number of parts.
+	Checksum struct {
+		CRC32  string `xml:"ChecksumCRC32"`
+		CRC32C string `xml:"ChecksumCRC32C"`
+		SHA1   string `xml:"ChecksumSHA1"`
+		SHA256 string `xml:"ChecksumSHA256"`
+	} `xml:"Checksum"`
+}
+
+// GetObjectAttributes returns the size, storage class, and stored
+// checksums of an object without reading it. At endpoints without the
+// operation, they are read from the object's headers, without
+// checksums.
+func (c *Client) GetObjectAttributes(ctx context.Context, bucket, key string) (Attributes, error) {
+	if c.lacks(attributesFeature) {
+		return c.headAttributes(ctx, bucket, key)
+	}
+	h := http.Header{"X-Amz-Object-Attributes": {"ETag,Checksum,ObjectSize,StorageClass"}}
+	resp, err := c.send(ctx, http.MethodGet, bucket, key, url.Values{"attributes": {""}}, h, nil)
+	if err != nil {
+		return Attributes{}, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		if unsupported(err) {
+			c.lack(attributesFeature)
+			return c.headAttributes(ctx, bucket, key)
+		}
+		return Attributes{}, err
+	}
+	var attrs Attributes
+	if err := xml.NewDecoder(resp.Body).Decode(&attrs); err != nil {
+		return Attributes{}, fmt.Errorf("failed to decode S3 response: %w", err)
+	}
+	return attrs, nil
+}
+
+// headAttributes returns the attributes of an object found in its
+// headers.
+func (c *Client) headAttributes(ctx context.Context, bucket, key string) (Attributes, error) {
+	info, err := c.HeadObject(ctx, bucket, key)
+	if err != nil {
+		return Attributes{}, err
+	}
+	return Attributes{ETag: info.ETag, ObjectSize: info.Size, StorageClass: info.StorageClass}, nil
+}
+
+// CopyObject copies an object, using a multipart copy for objects
+// larger than 5 GiB.
+func (c *Client) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	return c.CopyObjectVersion(ctx, srcBucket, srcKey, "", dstBucket, dstKey)
+}
+
+// CopyObjectVersion copies a version of an object, or its current
+// version if versionID is empty.
+func (c *Client) CopyObjectVersion(ctx context.Context, srcBucket, srcKey, versionID, dstBucket, dstKey string) error {
+	info, err := c.headObject(ctx, srcBucket, srcKey, versionID)
+	if err != nil {
+		return err
+	}
+	source := "/" + srcBucket + "/" + escapeKey(srcKey)
+	if versionID != "" {
+		source += "?versionId=" + url.QueryEscape(versionID)
+	}
+	if info.Size > maxCopySize {
+		return c.multipartCopy(ctx, source, info.Size, dstBucket, dstKey, nil)
+	}
+
+	h := http.Header{"X-Amz-Copy-Source": {source}}
+	resp, err := c.send(ctx, http.MethodPut, dstBucket, dstKey, nil, h, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// SetStorageClass moves an object to another storage class, e.g.
+// DEEP_ARCHIVE, by copying it onto itself. Objects copied in parts keep
+// their content type. At endpoints without the class, the object moves
+// to the nearest class they have, and stays where it is if it is
+// already there.
+func (c *Client) SetStorageClass(ctx context.Context, bucket, key, class string) error {
+	info, err := c.HeadObject(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	source := "/" + bucket + "/" + escapeKey(key)
+	return c.withStorageClass(class, func(class string) error {
+		if cmp.Or(class, "STANDARD") == cmp.Or(info.StorageClass, "STANDARD") {
+			return nil
+		}
+		h := http.Header{}
+		setStorageClass(h, class)
+		if info.Size > maxCopySize {
+			if info.ContentType != "" {
+				h.Set("Content-Type", info.ContentType)
+			}
+			return c.multipartCopy(ctx, source, info.Size, bucket, key, h)
+		}
+		h.Set("X-Amz-Copy-Source", source)
+		h.Set("X-Amz-Metadata-Directive", "COPY")
+		resp, err := c.send(ctx, http.MethodPut, bucket, key, nil, h, nil)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		return checkResponse(resp)
+	})
+}
+
+// versionQuery returns the query selecting versionID, or nil if it is
+// empty.
+func versionQuery(versionID string) url.Values {
+	if versionID == "" {
+		return nil
+	}
+	return url.Values{"versionId": {versionID}}
+}
+
+// multipartCopy copies a large object in parts to an object created
+// with headers h.
+func (c *Client) multipartCopy(ctx context.Context, source string, size int64, bucket, key string, h http.Header) error {
+	uploadID, err := c.createMultipartUpload(ctx, bucket, key, h)
+	if err != nil {
+		return fmt.Errorf("failed to start multipart copy: %w", err)
+	}
+
+	var parts []completedPart
+	for n, off := 1, int64(0); off < size; n, off = n+1, off+copyPartSize {
+		end := min(off+copyPartSize, size) - 1
+		h := http.Header{
+			"X-Amz-Copy-Source":       {source},
+			"X-Amz-Copy-Source-Range": {fmt.Sprintf("bytes=%d-%d", off, end)},
+		}
+		q := url.Values{"partNumber": {fmt.Sprint(n)}, "uploadId": {uploadID}}
+		resp, err := c.send(ctx, http.MethodPut, bucket, key, q, h, nil)
+		if err != nil {
+			_ = c.AbortMultipartUpload(ctx, bucket, key, uploadID)
+			return err
+		}
+		var out struct {
+			ETag string `xml:"ETag"`
+		}
+		err = checkResponse(resp)
+		if err == nil {
+			err = xml.NewDecoder(resp.Body).Decode(&out)
+		}
+		resp.Body.Close()
+		if err != nil {
+			_ = c.AbortMultipartUpload(ctx, bucket, key, uploadID)
+			return fmt.Errorf("failed to copy part %d: %w", n, err)
+		}
+		parts = append(parts, completedPart{PartNumber: n, ETag: out.ETag})
+	}
+	if err := c.completeMultipartUpload(ctx, bucket, key, uploadID, parts); err != nil {
+		return fmt.Errorf("failed to complete multipart copy: %w", err)
+	}
+	return nil
+}
+
+// completedPart is a part of a multipart upload.
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// createMultipartUpload starts a multipart upload of an object with
+// headers h and returns its ID.
+func (c *Client) createMultipartUpload(ctx context.Context, bucket, key string, h http.Header) (string, error) {
+	resp, err := c.send(ctx, http.MethodPost, bucket, key, url.Values{"uploads": {""}}, h, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return "", err
+	}
+	var created struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("failed to decode S3 response: %w", err)
+	}
+	return created.UploadID, nil
+}
+
+// completeMultipartUpload assembles the parts of an upload, aborting
+// it if that fails.
+func (c *Client) completeMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []completedPart) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	if err := c.doXML(ctx, http.MethodPost, bucket, key, url.Values{"uploadId": {uploadID}}, body, nil); err != nil {
+		_ = c.AbortMultipartUpload(ctx, bucket, key, uploadID)
+		return err
+	}
+	return nil
+}
+
+// PresignGetObject returns a URL that downloads key from bucket
+// without credentials until expires elapses.
+func (c *Client) PresignGetObject(bucket, key string, expires time.Duration) string {
+	return c.signer.Presign(http.MethodGet, c.objectURL(bucket, key, nil), expires).String()
+}
+
+// PresignPutObject returns a URL that uploads key to bucket without
+// credentials until expires elapses.
+func (c *Client) PresignPutObject(bucket, key string, expires time.Duration) string {
+	return c.signer.Presign(http.MethodPut, c.objectURL(bucket, key, nil), expires).String()
+}
+
+// objectURL returns the URL of key in bucket.
+func (c *Client) objectURL(bucket, key string, q url.Values) *url.URL {
+	u := *c.endpoint
+	u.Path, u.RawPath = "/"+key, "/"+escapeKey(key)
+	if c.pathStyle {
+		u.Path, u.RawPath = "/"+bucket+u.Path, "/"+bucket+u.RawPath
+		if key == "" {
+			u.Path, u.RawPath = "/"+bucket, "/"+bucket
+		}
+	} else {
+		u.Host = bucket + "." + u.Host
+	}
+	u.RawQuery = q.Encode()
+	return &u
+}
+
+// doXML sends a request and decodes an XML response into out.
+func (c *Client) doXML(ctx context.Context, method, bucket, key string, q url.Values, body []byte, out any) error {
+	resp, err := c.send(ctx, method, bucket, key, q, nil, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode S3 response: %w", err)
+	}
+	return nil
+}
+
+// send signs and sends a request.
+func (c *Client) send(ctx context.Context, method, bucket, key string, q url.Values, h http.Header, body []byte) (*http.Response, error) {
+	u := c.objectURL(bucket, key, q)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request: %w", err)
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	req.ContentLength = int64(len(body))
+	c.signer.Sign(req, aws.HashPayload(body))
+
+	op := operation(method, key, q, h)
+	_, span := trace.StartClient(ctx, "S3 "+op,
+		trace.String("aws.s3.bucket", bucket),
+		trace.String("aws.s3.key", key),
+		trace.Int("http.request.body.size", int64(len(body))),
+	)
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if c.metrics != nil {
+		c.record(op, start, len(body), resp, err)
+	}
+	if err != nil {
+		span.End(err)
+		return nil, fmt.Errorf("S3 %s s3://%s/%s failed: %w", method, bucket, key, err)
+	}
+	span.SetAttrs(trace.Int("http.response.status_code", int64(resp.StatusCode)))
+	span.End(statusError(resp))
+	return resp, nil
+}
+
+// record records the latency and outcome of an S3 request, and the
+// throughput of an upload.
+func (c *Client) record(op string, start time.Time, sent int, resp *http.Response, err error) {
+	elapsed := time.Since(start)
+	failed := 0.0
+	if err != nil || resp.StatusCode >= 500 {
+		failed = 1
+	}
+	ms := []metrics.Metric{
+		{Name: metrics.TransferLatency, Value: metrics.Since(start), Unit: metrics.Milliseconds},
+		{Name: metrics.TransferErrors, Value: failed, Unit: metrics.Count},
+	}
+	if (op == "PutObject" || op == "UploadPart") && sent > 0 && failed == 0 && elapsed > 0 {
+		ms = append(ms,
+			metrics.Metric{Name: metrics.UploadBytes, Value: float64(sent), Unit: metrics.Bytes},
+			metrics.Metric{Name: metrics.UploadThroughput, Value: float64(sent) / elapsed.Seconds(), Unit: metrics.BytesPerSecond},
+		)
+	}
+	c.metrics.Record(metrics.Dimensions{"Operation": op}, ms...)
+}
+
+// statusError returns an error describing resp if S3 failed with a
+// server error, for marking its span failed.
+func statusError(resp *http.Response) error {
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// operation names the S3 API operation of a request.
+func operation(method, key string, q url.Values, h http.Header) string {
+	copied := h.Get("X-Amz-Copy-Source") != ""
+	switch {
+	case method == http.MethodPut && q.Has("partNumber") && copied:
+		return "UploadPartCopy"
+	case method == http.MethodPut && q.Has("partNumber"):
+		return "UploadPart"
+	case method == http.MethodPut && copied:
+		return "CopyObject"
+	case method == http.MethodPut:
+		return "PutObject"
+	case method == http.MethodGet && q.Has("uploads"):
+		return "ListMultipartUploads"
+	case method == http.MethodGet && key == "":
+		return "ListObjects"
+	case method == http.MethodGet && q.Has("attributes"):
+		return "GetObjectAttributes"
+	case method == http.MethodGet:
+		return "GetObject"
+	case method == http.MethodHead:
+		return "HeadObject"
+	case method == http.MethodDelete && q.Has("uploadId"):
+		return "AbortMultipartUpload"
+	case method == http.MethodDelete:
+		return "DeleteObject"
+	case method == http.MethodPost && q.Has("restore"):
+		return "RestoreObject"
+	case method == http.MethodPost && q.Has("uploads"):
+		return "CreateMultipartUpload"
+	case method == http.MethodPost && q.Has("uploadId"):
+		return "CompleteMultipartUpload"
+	}
+	return method
+}
+
+// Error is an S3 error response.
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("s3: HTTP %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Unwrap maps missing objects and buckets to ErrNotFound.
+func (e *Error) Unwrap() error {
+	if e.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// checkResponse converts error statuses to *Error.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	defer resp.Body.Close()
+	e := &Error{StatusCode: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	_ = xml.Unmarshal(data, e)
+	return e
+}
+
+// escapeKey percent-encodes each segment of an object key.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,384 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/metrics"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(Options{
+		Region:      "us-east-1",
+		Endpoint:    srv.URL,
+		PathStyle:   true,
+		Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return c
+}
+
+func TestListObjectsPaginates(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bucket" {
+			t.Errorf("path = %s, want /bucket", r.URL.Path)
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			t.Errorf("request not signed: %q", r.Header.Get("Authorization"))
+		}
+		if r.URL.Query().Get("continuation-token") == "" {
+			fmt.Fprint(w, `<ListBucketResult><IsTruncated>true</IsTruncated><NextContinuationToken>t1</NextContinuationToken>
+<Contents><Key>a</Key><Size>1</Size><LastModified>2025-01-01T00:00:00.000Z</LastModified></Contents></ListBucketResult>`)
+			return
+		}
+		fmt.Fprint(w, `<ListBucketResult><IsTruncated>false</IsTruncated>
+<Contents><Key>b c</Key><Size>2</Size><LastModified>2025-01-02T00:00:00.000Z</LastModified></Contents></ListBucketResult>`)
+	})
+
+	var keys []string
+	err := c.ListObjects(context.Background(), "bucket", "", func(o ObjectInfo) error {
+		keys = append(keys, o.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ListObjects() error = %v", err)
+	}
+	if strings.Join(keys, ",") != "a,b c" {
+		t.Errorf("ListObjects() keys = %v", keys)
+	}
+}
+
+func TestDeleteObjectNotFound(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/bucket/datasets/a b.csv" {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>missing</Message></Error>`)
+	})
+
+	err := c.DeleteObject(context.Background(), "bucket", "datasets/a b.csv")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteObject() error = %v, want ErrNotFound", err)
+	}
+	var s3Err *Error
+	if !errors.As(err, &s3Err) || s3Err.Code != "NoSuchKey" {
+		t.Errorf("DeleteObject() error = %v, want NoSuchKey", err)
+	}
+}
+
+func TestHeadBucket(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		switch r.URL.Path {
+		case "/aperture-prod-public-media":
+		case "/aperture-prod-frontend":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	ctx := context.Background()
+	if err := c.HeadBucket(ctx, "aperture-prod-public-media"); err != nil {
+		t.Errorf("HeadBucket() error = %v", err)
+	}
+	if err := c.HeadBucket(ctx, "aperture-prod-logs"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("HeadBucket() of a missing bucket error = %v, want ErrNotFound", err)
+	}
+	var s3Err *Error
+	if err := c.HeadBucket(ctx, "aperture-prod-frontend"); !errors.As(err, &s3Err) || s3Err.StatusCode != http.StatusForbidden {
+		t.Errorf("HeadBucket() of another account's bucket error = %v, want 403", err)
+	}
+}
+
+func TestCreateBucket(t *testing.T) {
+	var created []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPut || len(body) != 0 {
+			t.Errorf("unexpected %s %s %s", r.Method, r.URL.Path, body)
+		}
+		if r.URL.Path == "/aperture-dev-logs" {
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, `<Error><Code>BucketAlreadyOwnedByYou</Code><Message>owned</Message></Error>`)
+			return
+		}
+		created = append(created, r.URL.Path)
+	})
+	ctx := context.Background()
+	if err := c.CreateBucket(ctx, "aperture-dev-public-media"); err != nil || len(created) != 1 {
+		t.Errorf("CreateBucket() = %v, created %v", err, created)
+	}
+	// An existing bucket of the account is left alone.
+	if err := c.CreateBucket(ctx, "aperture-dev-logs"); err != nil {
+		t.Errorf("CreateBucket() of an owned bucket error = %v", err)
+	}
+}
+
+func TestGetObjectAttributes(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !r.URL.Query().Has("attributes") || !strings.Contains(r.Header.Get("X-Amz-Object-Attributes"), "Checksum") {
+			t.Errorf("unexpected request %s %s", r.URL, r.Header)
+		}
+		fmt.Fprint(w, `<GetObjectAttributesResponse><ETag>abc</ETag><Checksum><ChecksumSHA256>n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=</ChecksumSHA256></Checksum>
+<ObjectSize>4</ObjectSize><StorageClass>STANDARD</StorageClass></GetObjectAttributesResponse>`)
+	})
+
+	attrs, err := c.GetObjectAttributes(context.Background(), "bucket", "a.csv")
+	if err != nil {
+		t.Fatalf("GetObjectAttributes() error = %v", err)
+	}
+	if attrs.ObjectSize != 4 || attrs.StorageClass != "STANDARD" || attrs.Checksum.SHA256 != "n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=" {
+		t.Errorf("GetObjectAttributes() = %+v", attrs)
+	}
+}
+
+func TestRestoreObject(t *testing.T) {
+	restoring := false
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead && restoring:
+			w.Header().Set("X-Amz-Storage-Class", "DEEP_ARCHIVE")
+			w.Header().Set("X-Amz-Restore", `ongoing-request="true"`)
+		case r.Method == http.MethodHead:
+			w.Header().Set("X-Amz-Storage-Class", "DEEP_ARCHIVE")
+		case r.Method == http.MethodPost && r.URL.Query().Has("restore") && restoring:
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `<Error><Code>RestoreAlreadyInProgress</Code><Message>Object restore is already in progress</Message></Error>`)
+		case r.Method == http.MethodPost && r.URL.Query().Has("restore"):
+			body, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(body), "<Days>7</Days>") || !strings.Contains(string(body), "<Tier>Bulk</Tier>") {
+				t.Errorf("RestoreObject body = %s", body)
+			}
+			restoring = true
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	ctx := context.Background()
+	info, err := c.HeadObject(ctx, "bucket", "a.csv")
+	if err != nil || info.Restoring() || info.Restored() {
+		t.Fatalf("HeadObject() = %+v, %v, want no restore", info, err)
+	}
+	for range 2 {
+		if err := c.RestoreObject(ctx, "bucket", "a.csv", 7, TierBulk); err != nil {
+			t.Fatalf("RestoreObject() error = %v", err)
+		}
+	}
+	if info, err = c.HeadObject(ctx, "bucket", "a.csv"); err != nil || !info.Restoring() {
+		t.Errorf("HeadObject() = %+v, %v, want a restore in progress", info, err)
+	}
+}
+
+func TestObjectVersions(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		v := r.URL.Query().Get("versionId")
+		switch {
+		case r.Method == http.MethodHead && v == "":
+			w.Header().Set("X-Amz-Version-Id", "v2")
+			w.Header().Set("Content-Length", "5")
+		case r.Method == http.MethodHead && v == "v1":
+			w.Header().Set("Content-Length", "3")
+		case r.Method == http.MethodGet && v == "v1":
+			fmt.Fprint(w, "old")
+		case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") == "/src/exports/a.csv?versionId=v1":
+			fmt.Fprint(w, `<CopyObjectResult/>`)
+		default:
+			t.Errorf("unexpected request %s %s %s", r.Method, r.URL, r.Header.Get("X-Amz-Copy-Source"))
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	ctx := context.Background()
+	if info, err := c.HeadObject(ctx, "src", "exports/a.csv"); err != nil || info.VersionID != "v2" {
+		t.Errorf("HeadObject() = %+v, %v, want version v2", info, err)
+	}
+	r, err := c.GetObjectVersion(ctx, "src", "exports/a.csv", "v1")
+	if err != nil {
+		t.Fatalf("GetObjectVersion() error = %v", err)
+	}
+	defer r.Close()
+	if body, _ := io.ReadAll(r); string(body) != "old" {
+		t.Errorf("GetObjectVersion() = %q, want old", body)
+	}
+	if err := c.CopyObjectVersion(ctx, "src", "exports/a.csv", "v1", "dst", "a.csv"); err != nil {
+		t.Errorf("CopyObjectVersion() error = %v", err)
+	}
+}
+
+func TestSetStorageClass(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead:
+			w.Header().Set("Content-Length", "5")
+		case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") == "/media/ds-1/v1/a%20b.csv" &&
+			r.Header.Get("X-Amz-Storage-Class") == "DEEP_ARCHIVE" && r.Header.Get("X-Amz-Metadata-Directive") == "COPY":
+			fmt.Fprint(w, `<CopyObjectResult/>`)
+		default:
+			t.Errorf("unexpected request %s %s %v", r.Method, r.URL, r.Header)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	if err := c.SetStorageClass(context.Background(), "media", "ds-1/v1/a b.csv", "DEEP_ARCHIVE"); err != nil {
+		t.Errorf("SetStorageClass() error = %v", err)
+	}
+}
+
+func TestVirtualHostedURL(t *testing.T) {
+	c, err := NewClient(Options{Region: "us-west-2"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	u := c.objectURL("my-bucket", "datasets/x y.csv", nil)
+	if got := u.String(); got != "https://my-bucket.s3.us-west-2.amazonaws.com/datasets/x%20y.csv" {
+		t.Errorf("objectURL() = %s", got)
+	}
+}
+
+type recorded struct {
+	dims    metrics.Dimensions
+	metrics map[string]float64
+}
+
+type fakeRecorder struct{ records []recorded }
+
+func (f *fakeRecorder) Record(dims metrics.Dimensions, ms ...metrics.Metric) {
+	r := recorded{dims: dims, metrics: make(map[string]float64)}
+	for _, m := range ms {
+		r.metrics[m.Name] = m.Value
+	}
+	f.records = append(f.records, r)
+}
+
+func TestMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	rec := &fakeRecorder{}
+	c, err := NewClient(Options{Region: "us-east-1", Endpoint: srv.URL, PathStyle: true, Metrics: rec})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	ctx := context.Background()
+	if err := c.PutObject(ctx, "bucket", "a.csv", []byte("hello"), "text/csv"); err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	_, _ = c.HeadObject(ctx, "bucket", "a.csv")
+	if len(rec.records) != 2 {
+		t.Fatalf("records = %+v, want 2", rec.records)
+	}
+	put, head := rec.records[0], rec.records[1]
+	if put.dims["Operation"] != "PutObject" || put.metrics[metrics.UploadBytes] != 5 || put.metrics[metrics.UploadThroughput] <= 0 || put.metrics[metrics.TransferErrors] != 0 {
+		t.Errorf("PutObject metrics = %+v", put)
+	}
+	if head.dims["Operation"] != "HeadObject" || head.metrics[metrics.TransferErrors] != 1 {
+		t.Errorf("HeadObject metrics = %+v, want an error", head)
+	}
+	if _, ok := head.metrics[metrics.UploadBytes]; ok {
+		t.Errorf("HeadObject recorded upload bytes")
+	}
+}
+
+// zeros reads zero bytes.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestUpload(t *testing.T) {
+	var parts []int64
+	var class string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPost && q.Has("uploads"):
+			class = r.Header.Get("X-Amz-Storage-Class")
+			fmt.Fprint(w, `<InitiateMultipartUploadResult><UploadId>u1</UploadId></InitiateMultipartUploadResult>`)
+		case r.Method == http.MethodPut && q.Get("uploadId") == "u1":
+			n, _ := io.Copy(io.Discard, r.Body)
+			parts = append(parts, n)
+			w.Header().Set("ETag", `"e`+q.Get("partNumber")+`"`)
+		case r.Method == http.MethodPost && q.Get("uploadId") == "u1":
+			body, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(body), `<Part><PartNumber>2</PartNumber><ETag>&#34;e2&#34;</ETag></Part>`) {
+				t.Errorf("CompleteMultipartUpload body = %s", body)
+			}
+		case r.Method == http.MethodPut:
+			class = r.Header.Get("X-Amz-Storage-Class")
+			n, _ := io.Copy(io.Discard, r.Body)
+			parts = append(parts, n)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL)
+		}
+	})
+	ctx := context.Background()
+	opts := PutOptions{StorageClass: "DEEP_ARCHIVE"}
+
+	n, err := c.Upload(ctx, "bucket", "small", strings.NewReader("hello"), opts)
+	if err != nil || n != 5 || len(parts) != 1 || class != "DEEP_ARCHIVE" {
+		t.Fatalf("Upload(small) = %d, %v; parts %v, class %q", n, err, parts, class)
+	}
+
+	parts, class = nil, ""
+	size := int64(minPartSize + 10)
+	n, err = c.Upload(ctx, "bucket", "large", io.LimitReader(zeros{}, size), opts)
+	if err != nil || n != size {
+		t.Fatalf("Upload(large) = %d, %v, want %d", n, err, size)
+	}
+	if len(parts) != 2 || parts[0] != minPartSize || parts[1] != 10 || class != "DEEP_ARCHIVE" {
+		t.Errorf("parts = %v, class %q, want a full part and 10 bytes", parts, class)
+	}
+}
+
+func TestUploadRetainUntil(t *testing.T) {
+	var h http.Header
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		h = r.Header
+	})
+	until := time.Date(2036, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := c.Upload(context.Background(), "bucket", "anchor.json", strings.NewReader("hello"), PutOptions{RetainUntil: until}); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if h.Get("X-Amz-Object-Lock-Mode") != "COMPLIANCE" || h.Get("X-Amz-Object-Lock-Retain-Until-Date") != "2036-01-02T03:04:05Z" {
+		t.Errorf("lock headers = %v", h)
+	}
+	if got := h.Get("Content-MD5"); got != "XUFAKrxLKna5cZ2REBfFkg==" {
+		t.Errorf("Content-MD5 = %q", got)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// ObjectEvent is an object named by an S3 event notification.
+type ObjectEvent struct {
+	Bucket string
+	Key    string
+	Size   int64
+}
+
+// ObjectEvents returns the objects named by an event notification
+// body: an S3 event notification, as S3 sends to SQS, whose keys are
+// URL-encoded, or an EventBridge event for S3. Test events name none.
+func ObjectEvents(body []byte) ([]ObjectEvent, error) {
+	var msg struct {
+		Records []struct {
+			S3 struct {
+				Bucket struct{ Name string }
+				Object struct {
+					Key  string
+					Size int64
+				}
+			}
+		}
+		Detail *struct {
+			Bucket struct{ Name string }
+			Object struct {
+				Key  string
+				Size int64
+			}
+		} `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("invalid S3 event: %w", err)
+	}
+	if d := msg.Detail; d != nil {
+		return []ObjectEvent{{Bucket: d.Bucket.Name, Key: d.Object.Key, Size: d.Object.Size}}, nil
+	}
+	var events []ObjectEvent
+	for _, r := range msg.Records {
+		key, err := url.QueryUnescape(r.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q in S3 event: %w", r.S3.Object.Key, err)
+		}
+		events = append(events, ObjectEvent{Bucket: r.S3.Bucket.Name, Key: key, Size: r.S3.Object.Size})
+	}
+	return events, nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestObjectEvents(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []ObjectEvent
+	}{
+		{"notification", `{"Records": [{"eventName": "ObjectCreated:Put", "s3": {"bucket": {"name": "ap-logs"}, "object": {"key": "cdn/E2X.2026-06-01-00.a1b2c3.gz", "size": 512}}}]}`,
+			[]ObjectEvent{{Bucket: "ap-logs", Key: "cdn/E2X.2026-06-01-00.a1b2c3.gz", Size: 512}}},
+		{"encoded key", `{"Records": [{"s3": {"bucket": {"name": "ap-logs"}, "object": {"key": "access+logs/2026%3A06"}}}]}`,
+			[]ObjectEvent{{Bucket: "ap-logs", Key: "access logs/2026:06"}}},
+		{"eventbridge", `{"detail-type": "Object Created", "detail": {"bucket": {"name": "ap-logs"}, "object": {"key": "s3/2026-06-01", "size": 9}}}`,
+			[]ObjectEvent{{Bucket: "ap-logs", Key: "s3/2026-06-01", Size: 9}}},
+		{"test event", `{"Service": "Amazon S3", "Event": "s3:TestEvent", "Bucket": "ap-logs"}`, nil},
+	}
+	for _, tt := range tests {
+		got, err := ObjectEvents([]byte(tt.body))
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ObjectEvents() = %+v, %v; want %+v", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := ObjectEvents([]byte("not json")); err == nil {
+		t.Error("ObjectEvents() of invalid JSON succeeded")
+	}
+}
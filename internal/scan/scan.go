@@ -0,0 +1,593 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scan checks uploaded dataset files for viruses and malware
+// before they are published.
+//
+// Scanning is done by a scanner: an HTTP service wrapping ClamAV,
+// typically a Lambda function with ClamAV and its signature database in
+// a layer, or a Fargate task behind a load balancer. The scanner is
+// sent a Request naming a presigned URL to read the object from and
+// answers with a Verdict. Verdicts are kept per stored object with the
+// digest scanned, so a file carried into later versions of a dataset is
+// scanned once, and a file replaced under the same key is scanned
+// again.
+//
+// An infected object is moved to the quarantine bucket, out of reach
+// of download links, and the stewards of its dataset are notified.
+// Publication checks Cleared first, so a dataset with a file that is
+// unscanned, infected, or could not be scanned stays in draft.
+package scan
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/notify"
+	"github.com/scttfrdmn/aperture/internal/premis"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// resultsTable holds the latest verdict on each object, keyed by
+// bucket/key.
+const resultsTable = "scans"
+
+// DefaultExpiry is how long the presigned URLs sent to the scanner
+// remain valid.
+const DefaultExpiry = time.Hour
+
+var (
+	// ErrNotCleared is returned when publishing a dataset with files
+	// that have not passed scanning.
+	ErrNotCleared = errors.New("files have not passed malware scanning")
+
+	// ErrNotQuarantined is returned when releasing an object that is
+	// not in quarantine.
+	ErrNotQuarantined = errors.New("object is not quarantined")
+)
+
+// Status is the outcome of scanning an object.
+type Status string
+
+// Statuses.
+const (
+	// StatusClean objects matched no signatures
+	StatusClean Status = "clean"
+
+	// StatusInfected objects matched a signature and were quarantined
+	StatusInfected Status = "infected"
+
+	// StatusError objects could not be scanned; they are scanned again
+	// on the next run
+	StatusError Status = "error"
+
+	// StatusReleased objects were quarantined and then restored by an
+	// operator as false positives
+	StatusReleased Status = "released"
+)
+
+// Cleared reports whether objects with status s may be published.
+func (s Status) Cleared() bool {
+	return s == StatusClean || s == StatusReleased
+}
+
+// Request is the body posted to the scanner.
+type Request struct {
+	// Source is a presigned URL to GET the object from
+	Source string `json:"source"`
+
+	// Bucket and Key locate the object, for the scanner's logs
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+
+	// Size is the object's size in bytes, if known
+	Size int64 `json:"size,omitempty"`
+}
+
+// Verdict is the scanner's answer.
+type Verdict struct {
+	Infected bool `json:"infected"`
+
+	// Signatures names the signatures the object matched, e.g.
+	// Win.Test.EICAR_HDB-1
+	Signatures []string `json:"signatures,omitempty"`
+
+	// Engine identifies the scanner and its signature database, e.g.
+	// ClamAV 1.4.1/27480
+	Engine string `json:"engine,omitempty"`
+}
+
+// Result is the latest verdict on a stored object.
+type Result struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+
+	// Dataset and Path name the first file found stored in the object
+	Dataset string `json:"dataset"`
+	Path    string `json:"path"`
+
+	// SHA256 is the manifest digest of the object scanned
+	SHA256 string `json:"sha256,omitempty"`
+
+	Status     Status    `json:"status"`
+	Signatures []string  `json:"signatures,omitempty"`
+	Engine     string    `json:"engine,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	ScannedAt  time.Time `json:"scannedAt"`
+
+	// Quarantine locates the quarantined copy of an infected object,
+	// as s3://bucket/key
+	Quarantine    string     `json:"quarantine,omitempty"`
+	QuarantinedAt *time.Time `json:"quarantinedAt,omitempty"`
+
+	// ReleasedBy and Reason record who restored a false positive, and
+	// why
+	ReleasedBy string `json:"releasedBy,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// URI returns the s3:// URI of the scanned object.
+func (r *Result) URI() string {
+	return "s3://" + r.Bucket + "/" + r.Key
+}
+
+// ObjectStore reads and quarantines objects. *s3.Client implements
+// it.
+type ObjectStore interface {
+	PresignGetObject(bucket, key string, expires time.Duration) string
+	CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
+	DeleteObject(ctx context.Context, bucket, key string) error
+}
+
+// EventLog records preservation events. *premis.Log implements it.
+type EventLog interface {
+	Record(ctx context.Context, events ...premis.Event) error
+}
+
+// Scanner scans dataset files and gates their publication.
+type Scanner struct {
+	// State holds verdicts
+	State state.Store
+
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	Objects ObjectStore
+
+	// Endpoint is the URL requests are posted to
+	Endpoint string
+
+	// Quarantine is the bucket infected objects are moved to, under
+	// their original bucket and key
+	Quarantine string
+
+	// Client sends requests to the scanner; a client with a 15 minute
+	// timeout, the longest a Lambda function runs, if nil
+	Client *http.Client
+
+	// Signer signs requests with AWS Signature Version 4, as Lambda
+	// function URLs with AWS_IAM authentication require; requests are
+	// unsigned if nil
+	Signer *aws.Signer
+
+	// Expiry is how long presigned URLs remain valid; DefaultExpiry if
+	// zero
+	Expiry time.Duration
+
+	// Events records virus checks and quarantines; skipped if nil
+	Events EventLog
+
+	// Notifier tells stewards about quarantined files; skipped if nil
+	Notifier notify.Notifier
+
+	// Stewards receive notices for datasets that name no managing
+	// users in their access control list
+	Stewards []string
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Options select the files a run scans.
+type Options struct {
+	// Dataset scans the files of one dataset, ID or persistent
+	// identifier; every dataset that is not tombstoned if empty
+	Dataset string
+
+	// Limit is the most objects to scan; 0 for no limit
+	Limit int
+}
+
+// Run summarizes a run.
+type Run struct {
+	Scanned   int      `json:"scanned"`
+	Clean     int      `json:"clean"`
+	Infected  int      `json:"infected"`
+	Failed    int      `json:"failed"`
+	Remaining int      `json:"remaining"`
+	Results   []Result `json:"results"`
+}
+
+// Run scans the objects of dataset files that have no verdict for
+// their current digest, or whose last scan failed, within opts.
+// Infected objects are quarantined and their stewards notified. A
+// failed scan is reported in the run and does not stop it.
+func (s *Scanner) Run(ctx context.Context, opts Options) (*Run, error) {
+	if s.Quarantine == "" {
+		return nil, fmt.Errorf("no quarantine bucket is configured")
+	}
+	var datasets []*dataset.Dataset
+	if opts.Dataset != "" {
+		d, err := s.Datasets.Resolve(ctx, opts.Dataset)
+		if err != nil {
+			return nil, err
+		}
+		datasets = []*dataset.Dataset{d}
+	} else {
+		var err error
+		if datasets, err = s.Datasets.List(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	run := &Run{Results: []Result{}}
+	seen := make(map[string]bool)
+	for _, d := range datasets {
+		if d.State == dataset.StateTombstoned {
+			continue
+		}
+		for _, v := range d.Versions {
+			if v.Pruned != nil {
+				continue
+			}
+			for _, f := range v.Files {
+				k := f.Bucket + "/" + f.Key
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+				due, err := s.due(ctx, f)
+				if err != nil {
+					return nil, err
+				}
+				if !due {
+					continue
+				}
+				if opts.Limit > 0 && run.Scanned >= opts.Limit {
+					run.Remaining++
+					continue
+				}
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				r, err := s.scan(ctx, d, f)
+				if err != nil {
+					return nil, err
+				}
+				run.Scanned++
+				switch r.Status {
+				case StatusClean:
+					run.Clean++
+				case StatusInfected:
+					run.Infected++
+				default:
+					run.Failed++
+				}
+				run.Results = append(run.Results, *r)
+			}
+		}
+	}
+	return run, nil
+}
+
+// due reports whether the object of f needs scanning.
+func (s *Scanner) due(ctx context.Context, f dataset.File) (bool, error) {
+	r, err := s.result(ctx, f.Bucket, f.Key)
+	if errors.Is(err, state.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if r.Status == StatusInfected {
+		// The object stays in quarantine until it is released or a
+		// new upload replaces it under a new digest.
+		return f.SHA256 != "" && r.SHA256 != "" && f.SHA256 != r.SHA256, nil
+	}
+	return r.Status == StatusError || f.SHA256 != r.SHA256, nil
+}
+
+// scan scans the object of f, quarantines it if it is infected, and
+// saves the verdict. Only failures to record the verdict are returned;
+// scanner and quarantine failures are recorded in the result.
+func (s *Scanner) scan(ctx context.Context, d *dataset.Dataset, f dataset.File) (*Result, error) {
+	now := s.now().UTC()
+	r := &Result{Bucket: f.Bucket, Key: f.Key, Dataset: d.ID, Path: f.Path, SHA256: f.SHA256, ScannedAt: now}
+	v, err := s.request(ctx, f)
+	switch {
+	case err != nil:
+		r.Status, r.Error = StatusError, err.Error()
+	case !v.Infected:
+		r.Status, r.Engine = StatusClean, v.Engine
+	default:
+		r.Status, r.Signatures, r.Engine = StatusInfected, v.Signatures, v.Engine
+		if err := s.quarantine(ctx, r); err != nil {
+			// Publication stays blocked either way; the object is
+			// scanned again on the next run, retrying the quarantine.
+			r.Status, r.Error = StatusError, err.Error()
+		}
+	}
+	if err := s.State.Put(ctx, resultsTable, r.Bucket+"/"+r.Key, r); err != nil {
+		return nil, fmt.Errorf("failed to record scan of %s: %w", r.URI(), err)
+	}
+	if err := s.events(ctx, r, v); err != nil {
+		return nil, err
+	}
+	if r.QuarantinedAt != nil {
+		if err := s.notify(ctx, d, r); err != nil {
+			return nil, fmt.Errorf("%s is quarantined, but %w", r.URI(), err)
+		}
+	}
+	return r, nil
+}
+
+// request posts the scanner a request for f and returns its verdict.
+func (s *Scanner) request(ctx context.Context, f dataset.File) (*Verdict, error) {
+	body, err := json.Marshal(Request{
+		Source: s.Objects.PresignGetObject(f.Bucket, f.Key, cmp.Or(s.Expiry, DefaultExpiry)),
+		Bucket: f.Bucket,
+		Key:    f.Key,
+		Size:   f.Size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Signer != nil {
+		s.Signer.Sign(req, aws.HashPayload(body))
+	}
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("scanner failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("scanner failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var v Verdict
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&v); err != nil {
+		return nil, fmt.Errorf("scanner returned an invalid verdict: %w", err)
+	}
+	return &v, nil
+}
+
+// quarantine moves the object of r to the quarantine bucket.
+func (s *Scanner) quarantine(ctx context.Context, r *Result) error {
+	key := r.Bucket + "/" + r.Key
+	if err := s.Objects.CopyObject(ctx, r.Bucket, r.Key, s.Quarantine, key); err != nil {
+		return fmt.Errorf("failed to quarantine %s: %w", r.URI(), err)
+	}
+	if err := s.Objects.DeleteObject(ctx, r.Bucket, r.Key); err != nil {
+		return fmt.Errorf("failed to remove %s after quarantining it: %w", r.URI(), err)
+	}
+	now := s.now().UTC()
+	r.Quarantine = "s3://" + s.Quarantine + "/" + key
+	r.QuarantinedAt = &now
+	return nil
+}
+
+// events records the virus check of r, and its quarantine, as PREMIS
+// events. Failed scans record nothing.
+func (s *Scanner) events(ctx context.Context, r *Result, v *Verdict) error {
+	if s.Events == nil || v == nil {
+		return nil
+	}
+	check := premis.Event{
+		Type:    premis.TypeVirusCheck,
+		Bucket:  r.Bucket,
+		Key:     r.Key,
+		Detail:  cmp.Or(v.Engine, "malware scan"),
+		Outcome: premis.OutcomeSuccess,
+		Agent:   premis.SoftwareAgent,
+	}
+	if v.Infected {
+		check.Outcome = premis.OutcomeFailure
+		check.OutcomeDetail = "matched " + strings.Join(v.Signatures, ", ")
+	}
+	events := []premis.Event{check}
+	if r.QuarantinedAt != nil {
+		events = append(events, premis.Event{
+			Type:    premis.TypeQuarantine,
+			Bucket:  r.Bucket,
+			Key:     r.Key,
+			Detail:  "moved to the quarantine bucket",
+			Outcome: premis.OutcomeSuccess,
+			Agent:   premis.SoftwareAgent,
+			Links:   []premis.Link{{Role: premis.RoleOutcome, URI: r.Quarantine}},
+		})
+	}
+	return s.Events.Record(ctx, events...)
+}
+
+// notify tells d's stewards that the object of r was quarantined.
+func (s *Scanner) notify(ctx context.Context, d *dataset.Dataset, r *Result) error {
+	if s.Notifier == nil {
+		return nil
+	}
+	var errs []error
+	for _, to := range s.stewards(d) {
+		err := s.Notifier.Notify(ctx, notify.Message{
+			To:      to,
+			Subject: fmt.Sprintf("Malware quarantined: %s", d.ID),
+			Body: fmt.Sprintf("The file %s of %q (%s) matched %s in a malware scan and has been\n"+
+				"moved to quarantine. The dataset cannot be published until the file is\n"+
+				"replaced.\n\n"+
+				"If this is a false positive, an operator can restore the file with\n"+
+				"'aperture scan release %s --reason TEXT'.\n",
+				r.Path, d.Title, d.ID, strings.Join(r.Signatures, ", "), r.URI()),
+			Time: s.now().UTC(),
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to notify stewards: %w", err)
+	}
+	return nil
+}
+
+// stewards returns the users named in d's manage list, or the
+// configured stewards if there are none.
+func (s *Scanner) stewards(d *dataset.Dataset) []string {
+	var out []string
+	for _, e := range d.ACL.Entries(authz.ActionManage) {
+		if user, ok := strings.CutPrefix(e, authz.KindUser+":"); ok {
+			out = append(out, user)
+		}
+	}
+	if len(out) == 0 {
+		out = s.Stewards
+	}
+	return out
+}
+
+// Release restores a quarantined object to its original location,
+// for a false positive, recording who released it and why.
+func (s *Scanner) Release(ctx context.Context, bucket, key, reason string) (*Result, error) {
+	r, err := s.result(ctx, bucket, key)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, fmt.Errorf("%w: s3://%s/%s has not been scanned", ErrNotQuarantined, bucket, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != StatusInfected || r.QuarantinedAt == nil {
+		return nil, fmt.Errorf("%w: %s is %s", ErrNotQuarantined, r.URI(), r.Status)
+	}
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("a reason is required to release %s", r.URI())
+	}
+	qkey := bucket + "/" + key
+	if err := s.Objects.CopyObject(ctx, s.Quarantine, qkey, bucket, key); err != nil {
+		return nil, fmt.Errorf("failed to restore %s: %w", r.URI(), err)
+	}
+	if err := s.Objects.DeleteObject(ctx, s.Quarantine, qkey); err != nil {
+		return nil, fmt.Errorf("failed to remove the quarantined copy of %s: %w", r.URI(), err)
+	}
+	r.Status = StatusReleased
+	r.ReleasedBy = identity.FromContext(ctx).String()
+	r.Reason = reason
+	if err := s.State.Put(ctx, resultsTable, qkey, r); err != nil {
+		return nil, fmt.Errorf("failed to record release of %s: %w", r.URI(), err)
+	}
+	if s.Events != nil {
+		err := s.Events.Record(ctx, premis.Event{
+			Type:          premis.TypeUnquarantine,
+			Bucket:        bucket,
+			Key:           key,
+			Detail:        "restored from quarantine as a false positive",
+			Outcome:       premis.OutcomeSuccess,
+			OutcomeDetail: reason,
+			Links:         []premis.Link{{Role: premis.RoleSource, URI: r.Quarantine}},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Cleared returns an error wrapping ErrNotCleared unless every file of
+// d's latest version has passed scanning at its current digest.
+func (s *Scanner) Cleared(ctx context.Context, d *dataset.Dataset) error {
+	v := d.Latest()
+	if v == nil {
+		return nil
+	}
+	var problems []string
+	for _, f := range v.Files {
+		r, err := s.result(ctx, f.Bucket, f.Key)
+		switch {
+		case errors.Is(err, state.ErrNotFound):
+			problems = append(problems, f.Path+" (not scanned)")
+		case err != nil:
+			return err
+		case f.SHA256 != r.SHA256:
+			problems = append(problems, f.Path+" (changed since scanned)")
+		case r.Status == StatusInfected:
+			problems = append(problems, fmt.Sprintf("%s (infected: %s)", f.Path, strings.Join(r.Signatures, ", ")))
+		case !r.Status.Cleared():
+			problems = append(problems, f.Path+" (scan failed)")
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %d files of %s: %s; run 'aperture scan run --dataset %s'",
+		ErrNotCleared, len(problems), len(v.Files), d.ID, strings.Join(problems, ", "), d.ID)
+}
+
+// Results returns the verdicts on every scanned object, by bucket and
+// key.
+func (s *Scanner) Results(ctx context.Context) ([]Result, error) {
+	all, err := state.List[Result](ctx, s.State, resultsTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scan results: %w", err)
+	}
+	slices.SortFunc(all, func(a, b Result) int {
+		return cmp.Or(cmp.Compare(a.Bucket, b.Bucket), cmp.Compare(a.Key, b.Key))
+	})
+	return all, nil
+}
+
+func (s *Scanner) result(ctx context.Context, bucket, key string) (*Result, error) {
+	var r Result
+	if err := s.State.Get(ctx, resultsTable, bucket+"/"+key, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *Scanner) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return &http.Client{Timeout: 15 * time.Minute}
+}
+
+func (s *Scanner) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
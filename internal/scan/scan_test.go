@@ -0,0 +1,220 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/notify"
+	"github.com/scttfrdmn/aperture/internal/premis"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// fakeStore is an in-memory ObjectStore whose presigned URLs name
+// objects as get:bucket/key.
+type fakeStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeStore) PresignGetObject(bucket, key string, _ time.Duration) string {
+	return "get:" + bucket + "/" + key
+}
+
+func (f *fakeStore) CopyObject(_ context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.objects[srcBucket+"/"+srcKey]
+	if !ok {
+		return s3.ErrNotFound
+	}
+	f.objects[dstBucket+"/"+dstKey] = b
+	return nil
+}
+
+func (f *fakeStore) DeleteObject(_ context.Context, bucket, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.objects, bucket+"/"+key)
+	return nil
+}
+
+type fakeNotifier struct{ sent []notify.Message }
+
+func (n *fakeNotifier) Notify(_ context.Context, m notify.Message) error {
+	n.sent = append(n.sent, m)
+	return nil
+}
+
+// scanner serves a scanner that flags objects containing EICAR and
+// fails for keys containing "locked".
+func scanner(t *testing.T, objects *fakeStore) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("scanner request: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(req.Key, "locked") {
+			http.Error(w, "archive is encrypted", http.StatusUnprocessableEntity)
+			return
+		}
+		objects.mu.Lock()
+		body := objects.objects[strings.TrimPrefix(req.Source, "get:")]
+		objects.mu.Unlock()
+		v := Verdict{Engine: "ClamAV 1.4.1/27480"}
+		if bytes.Contains(body, []byte("EICAR")) {
+			v.Infected, v.Signatures = true, []string{"Win.Test.EICAR_HDB-1"}
+		}
+		json.NewEncoder(w).Encode(v)
+	}))
+}
+
+func TestScanner(t *testing.T) {
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "ops@uni.edu"})
+	s := state.NewMemoryStore()
+	objects := &fakeStore{objects: map[string][]byte{
+		"pub/ds-1/a.csv":      []byte("a,b"),
+		"pub/ds-1/eicar.com":  []byte("X5O!P%@AP EICAR"),
+		"pub/ds-1/locked.zip": []byte("PK"),
+	}}
+	srv := scanner(t, objects)
+	defer srv.Close()
+
+	datasets := dataset.NewStore(s)
+	a := dataset.File{Path: "a.csv", Bucket: "pub", Key: "ds-1/a.csv", SHA256: "aa"}
+	eicar := dataset.File{Path: "eicar.com", Bucket: "pub", Key: "ds-1/eicar.com", SHA256: "ee"}
+	locked := dataset.File{Path: "locked.zip", Bucket: "pub", Key: "ds-1/locked.zip", SHA256: "ll"}
+	d := &dataset.Dataset{
+		ID:    "ds-1",
+		Title: "Soil cores",
+		ACL:   &authz.ACL{Manage: []string{"user:lab@uni.edu", "group:geo"}},
+		Versions: []dataset.Version{
+			{Number: 1, Files: []dataset.File{a}},
+			{Number: 2, Files: []dataset.File{a, eicar, locked}},
+		},
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	events := &premis.Log{State: s}
+	n := &fakeNotifier{}
+	sc := &Scanner{
+		State: s, Datasets: datasets, Objects: objects, Endpoint: srv.URL, Client: srv.Client(),
+		Quarantine: "quarantine", Events: events, Notifier: n,
+	}
+	if err := sc.Cleared(ctx, d); !errors.Is(err, ErrNotCleared) || !strings.Contains(err.Error(), "3 of 3 files") {
+		t.Errorf("Cleared() before scanning = %v, want 3 files not cleared", err)
+	}
+
+	run, err := sc.Run(ctx, Options{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if run.Scanned != 3 || run.Clean != 1 || run.Infected != 1 || run.Failed != 1 {
+		t.Fatalf("Run() = %+v, want 1 clean, 1 infected, 1 failed", run)
+	}
+
+	// The infected object is moved to quarantine and the managing user
+	// told.
+	if _, ok := objects.objects["pub/ds-1/eicar.com"]; ok {
+		t.Error("infected object left in place")
+	}
+	if _, ok := objects.objects["quarantine/pub/ds-1/eicar.com"]; !ok {
+		t.Error("infected object not quarantined")
+	}
+	if len(n.sent) != 1 || n.sent[0].To != "lab@uni.edu" || !strings.Contains(n.sent[0].Body, "Win.Test.EICAR_HDB-1") {
+		t.Errorf("notices = %+v, want one to lab@uni.edu", n.sent)
+	}
+	history, err := events.Events(ctx, "pub", "ds-1/eicar.com")
+	if err != nil {
+		t.Fatalf("Events() error = %v", err)
+	}
+	if len(history) != 2 || history[0].Type != premis.TypeVirusCheck || history[0].Outcome != premis.OutcomeFailure ||
+		history[1].Type != premis.TypeQuarantine || history[1].Links[0].URI != "s3://quarantine/pub/ds-1/eicar.com" {
+		t.Errorf("eicar.com events = %+v, want a failed virus check and a quarantine", history)
+	}
+	if history, _ = events.Events(ctx, "pub", "ds-1/locked.zip"); len(history) != 0 {
+		t.Errorf("locked.zip events = %+v, want none for a failed scan", history)
+	}
+
+	err = sc.Cleared(ctx, d)
+	if !errors.Is(err, ErrNotCleared) || !strings.Contains(err.Error(), "eicar.com (infected: Win.Test.EICAR_HDB-1)") ||
+		!strings.Contains(err.Error(), "locked.zip (scan failed)") || strings.Contains(err.Error(), "a.csv") {
+		t.Errorf("Cleared() = %v", err)
+	}
+
+	// Only the failed scan is retried; the quarantined object is not
+	// scanned again.
+	if run, err = sc.Run(ctx, Options{Dataset: "ds-1"}); err != nil || run.Scanned != 1 || run.Failed != 1 {
+		t.Errorf("second Run() = %+v, %v, want only locked.zip retried", run, err)
+	}
+
+	// A false positive is restored to its original location.
+	if _, err := sc.Release(ctx, "pub", "ds-1/a.csv", "fine"); !errors.Is(err, ErrNotQuarantined) {
+		t.Errorf("Release(a.csv) error = %v, want ErrNotQuarantined", err)
+	}
+	r, err := sc.Release(ctx, "pub", "ds-1/eicar.com", "test file in a teaching dataset")
+	if err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if r.Status != StatusReleased || r.ReleasedBy != "ops@uni.edu" {
+		t.Errorf("Release() = %+v", r)
+	}
+	if _, ok := objects.objects["pub/ds-1/eicar.com"]; !ok {
+		t.Error("released object not restored")
+	}
+
+	// A new version replacing the unscannable file is cleared once its
+	// files are scanned.
+	data := dataset.File{Path: "data.zip", Bucket: "pub", Key: "ds-1/v3/data.zip", SHA256: "dd"}
+	objects.objects["pub/ds-1/v3/data.zip"] = []byte("PK")
+	d.Versions = append(d.Versions, dataset.Version{Number: 3, Files: []dataset.File{a, eicar, data}})
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if run, err = sc.Run(ctx, Options{Limit: 1}); err != nil || run.Scanned != 1 || run.Remaining != 1 {
+		t.Errorf("limited Run() = %+v, %v, want 1 scanned and 1 remaining", run, err)
+	}
+	if err := sc.Cleared(ctx, d); !errors.Is(err, ErrNotCleared) || !strings.Contains(err.Error(), "data.zip (not scanned)") {
+		t.Errorf("Cleared() before scanning data.zip = %v", err)
+	}
+	if run, err = sc.Run(ctx, Options{}); err != nil || run.Clean != 1 {
+		t.Errorf("last Run() = %+v, %v, want data.zip clean", run, err)
+	}
+	if err := sc.Cleared(ctx, d); err != nil {
+		t.Errorf("Cleared() after scanning data.zip = %v", err)
+	}
+
+	results, err := sc.Results(ctx)
+	if err != nil || len(results) != 4 {
+		t.Errorf("Results() = %d results, %v, want 4", len(results), err)
+	}
+}
@@ -0,0 +1,599 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/cognito"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+// Prefix is the path the service is mounted at.
+const Prefix = "/scim/v2"
+
+// maxBody bounds request bodies.
+const maxBody = 1 << 20
+
+// Authenticator resolves a bearer token to the principal it belongs to.
+type Authenticator func(ctx context.Context, secret string) (identity.Principal, error)
+
+// Handler serves the SCIM 2.0 Users and Groups endpoints under Prefix.
+// Requests must carry a machine token with the users:write scope;
+// every change is recorded in the audit log as that service account.
+type Handler struct {
+	dir    Directory
+	groups []string
+	auth   Authenticator
+	log    audit.Log
+	mux    *http.ServeMux
+}
+
+// NewHandler returns a handler provisioning dir. Only the members of
+// groups are managed.
+func NewHandler(dir Directory, groups []string, auth Authenticator, log audit.Log) *Handler {
+	h := &Handler{dir: dir, groups: groups, auth: auth, log: log, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET "+Prefix+"/ServiceProviderConfig", h.serviceProviderConfig)
+	h.mux.HandleFunc("GET "+Prefix+"/ResourceTypes", h.resourceTypes)
+	h.mux.HandleFunc("GET "+Prefix+"/Users", h.listUsers)
+	h.mux.HandleFunc("POST "+Prefix+"/Users", h.createUser)
+	h.mux.HandleFunc("GET "+Prefix+"/Users/{id}", h.getUser)
+	h.mux.HandleFunc("PUT "+Prefix+"/Users/{id}", h.replaceUser)
+	h.mux.HandleFunc("PATCH "+Prefix+"/Users/{id}", h.patchUser)
+	h.mux.HandleFunc("DELETE "+Prefix+"/Users/{id}", h.deleteUser)
+	h.mux.HandleFunc("GET "+Prefix+"/Groups", h.listGroups)
+	h.mux.HandleFunc("POST "+Prefix+"/Groups", h.createGroup)
+	h.mux.HandleFunc("GET "+Prefix+"/Groups/{id}", h.getGroup)
+	h.mux.HandleFunc("PUT "+Prefix+"/Groups/{id}", h.replaceGroup)
+	h.mux.HandleFunc("PATCH "+Prefix+"/Groups/{id}", h.patchGroup)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		writeError(w, errorf(http.StatusUnauthorized, "", "a bearer token is required"))
+		return
+	}
+	p, err := h.auth(r.Context(), secret)
+	if err != nil {
+		writeError(w, errorf(http.StatusUnauthorized, "", "%v", err))
+		return
+	}
+	if !p.HasScope(token.ScopeUsersWrite) {
+		writeError(w, errorf(http.StatusForbidden, "", "%s lacks the %s scope", p, token.ScopeUsersWrite))
+		return
+	}
+	ctx := audit.WithJustification(identity.WithPrincipal(audit.WithRequest(r), p), "provisioned by the identity management system")
+	h.mux.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (h *Handler) serviceProviderConfig(w http.ResponseWriter, _ *http.Request) {
+	supported := func(ok bool) map[string]bool { return map[string]bool{"supported": ok} }
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schemas":        []string{SchemaConfig},
+		"patch":          supported(true),
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": MaxResults},
+		"changePassword": supported(false),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Machine token",
+			"description": "An Aperture machine token with the users:write scope",
+			"primary":     true,
+		}},
+	})
+}
+
+func (h *Handler) resourceTypes(w http.ResponseWriter, _ *http.Request) {
+	types := []any{
+		map[string]any{"schemas": []string{SchemaResourceType}, "id": "User", "name": "User", "endpoint": "/Users", "schema": SchemaUser},
+		map[string]any{"schemas": []string{SchemaResourceType}, "id": "Group", "name": "Group", "endpoint": "/Groups", "schema": SchemaGroup},
+	}
+	writeJSON(w, http.StatusOK, list(types, 1, len(types)))
+}
+
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if f != nil && f.attr != "username" && f.attr != "emails" && f.attr != "emails.value" {
+		writeError(w, errorf(http.StatusBadRequest, "invalidFilter", "users can only be filtered by userName or emails"))
+		return
+	}
+	users, err := h.dir.ListUsers(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var matched []any
+	for _, u := range users {
+		if f == nil || strings.EqualFold(u.Username, f.value) || strings.EqualFold(u.Email, f.value) {
+			matched = append(matched, toUser(u, nil))
+		}
+	}
+	writePage(w, r, matched)
+}
+
+func (h *Handler) getUser(w http.ResponseWriter, r *http.Request) {
+	u, err := h.user(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h.writeUser(w, r, http.StatusOK, u)
+}
+
+func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
+	var in User
+	if err := decode(r, &in); err != nil {
+		writeError(w, err)
+		return
+	}
+	email := identity.Normalize(in.email())
+	if !strings.Contains(email, "@") {
+		writeError(w, errorf(http.StatusBadRequest, "invalidValue", "userName or emails must hold an email address, got %q", email))
+		return
+	}
+	ctx := r.Context()
+	u, err := h.dir.CreateUser(ctx, email, in.displayName())
+	if errors.Is(err, cognito.ErrExists) {
+		writeError(w, errorf(http.StatusConflict, "uniqueness", "user %q already exists", email))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := audit.Record(ctx, h.log, "user.create", email, map[string]string{"name": in.displayName(), "via": "scim"}); err != nil {
+		writeError(w, err)
+		return
+	}
+	if in.Active != nil && !*in.Active {
+		if err := h.setActive(ctx, u, false); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	h.writeUser(w, r, http.StatusCreated, u)
+}
+
+// replaceUser handles PUT. The pool holds only the email address and
+// name, which are fixed once an account exists, so only active is
+// applied.
+func (h *Handler) replaceUser(w http.ResponseWriter, r *http.Request) {
+	var in User
+	if err := decode(r, &in); err != nil {
+		writeError(w, err)
+		return
+	}
+	u, err := h.user(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if in.Active != nil {
+		if err := h.setActive(r.Context(), u, *in.Active); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	h.writeUser(w, r, http.StatusOK, u)
+}
+
+// patchUser handles PATCH, applying changes to active and ignoring
+// the attributes the pool does not hold.
+func (h *Handler) patchUser(w http.ResponseWriter, r *http.Request) {
+	var in PatchRequest
+	if err := decode(r, &in); err != nil {
+		writeError(w, err)
+		return
+	}
+	u, err := h.user(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	for _, op := range in.Operations {
+		if o := strings.ToLower(op.Op); o != "replace" && o != "add" {
+			continue
+		}
+		value := op.Value
+		if op.Path == "" {
+			var attrs map[string]json.RawMessage
+			if err := json.Unmarshal(op.Value, &attrs); err != nil {
+				writeError(w, errorf(http.StatusBadRequest, "invalidValue", "operation without a path needs an object value"))
+				return
+			}
+			value = attrs["active"]
+		} else if !strings.EqualFold(op.Path, "active") {
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		active, err := activeValue(value)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := h.setActive(r.Context(), u, active); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	h.writeUser(w, r, http.StatusOK, u)
+}
+
+// deleteUser handles DELETE by deactivating the account.
+func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	u, err := h.user(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.setActive(r.Context(), u, false); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) listGroups(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if f != nil && f.attr != "displayname" && f.attr != "id" {
+		writeError(w, errorf(http.StatusBadRequest, "invalidFilter", "groups can only be filtered by displayName"))
+		return
+	}
+	var matched []any
+	for _, name := range h.groups {
+		if f != nil && !strings.EqualFold(name, f.value) {
+			continue
+		}
+		g, err := h.group(r.Context(), name, withMembers(r))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		matched = append(matched, g)
+	}
+	writePage(w, r, matched)
+}
+
+func (h *Handler) getGroup(w http.ResponseWriter, r *http.Request) {
+	g, err := h.group(r.Context(), r.PathValue("id"), withMembers(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, g)
+}
+
+func (h *Handler) createGroup(w http.ResponseWriter, _ *http.Request) {
+	writeError(w, errorf(http.StatusForbidden, "mutability", "groups are fixed; provision members of %s", strings.Join(h.groups, ", ")))
+}
+
+// replaceGroup handles PUT, making the members exactly those given.
+func (h *Handler) replaceGroup(w http.ResponseWriter, r *http.Request) {
+	var in Group
+	if err := decode(r, &in); err != nil {
+		writeError(w, err)
+		return
+	}
+	name := r.PathValue("id")
+	if err := h.setMembers(r.Context(), name, values(in.Members)); err != nil {
+		writeError(w, err)
+		return
+	}
+	h.writeGroup(w, r, name)
+}
+
+// patchGroup handles PATCH of members. Other attributes are read-only.
+func (h *Handler) patchGroup(w http.ResponseWriter, r *http.Request) {
+	var in PatchRequest
+	if err := decode(r, &in); err != nil {
+		writeError(w, err)
+		return
+	}
+	ctx := r.Context()
+	name := r.PathValue("id")
+	if _, err := h.group(ctx, name, false); err != nil {
+		writeError(w, err)
+		return
+	}
+	for _, op := range in.Operations {
+		if err := h.applyGroupOp(ctx, name, op); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	h.writeGroup(w, r, name)
+}
+
+// applyGroupOp applies one PATCH operation to the members of group.
+func (h *Handler) applyGroupOp(ctx context.Context, group string, op Operation) error {
+	var members []MultiValue
+	path := strings.ToLower(strings.TrimSpace(op.Path))
+	switch {
+	case path == "":
+		var attrs struct {
+			Members []MultiValue `json:"members"`
+		}
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return errorf(http.StatusBadRequest, "invalidValue", "operation without a path needs an object value")
+		}
+		members = attrs.Members
+	case path == "members":
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &members); err != nil {
+				return errorf(http.StatusBadRequest, "invalidValue", "members must be a list of {\"value\": id}")
+			}
+		}
+	case strings.HasPrefix(path, "members[") && strings.HasSuffix(path, "]"):
+		f, err := parseFilter(strings.TrimSuffix(op.Path[len("members["):], "]"))
+		if err != nil || f == nil || f.attr != "value" {
+			return errorf(http.StatusBadRequest, "invalidPath", "unsupported path %q", op.Path)
+		}
+		members = []MultiValue{{Value: f.value}}
+	default:
+		return errorf(http.StatusBadRequest, "mutability", "only the members of a group can be changed, not %q", op.Path)
+	}
+
+	switch strings.ToLower(op.Op) {
+	case "add":
+		for _, m := range members {
+			if err := h.addMember(ctx, group, m.Value); err != nil {
+				return err
+			}
+		}
+	case "remove":
+		if path == "members" && len(op.Value) == 0 {
+			return h.setMembers(ctx, group, nil)
+		}
+		for _, m := range members {
+			if err := h.removeMember(ctx, group, m.Value); err != nil {
+				return err
+			}
+		}
+	case "replace":
+		return h.setMembers(ctx, group, values(members))
+	default:
+		return errorf(http.StatusBadRequest, "invalidSyntax", "unknown operation %q", op.Op)
+	}
+	return nil
+}
+
+// user returns the account with username or email id.
+func (h *Handler) user(ctx context.Context, id string) (*cognito.User, error) {
+	users, err := h.dir.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if strings.EqualFold(users[i].Username, id) || strings.EqualFold(users[i].Email, id) {
+			return &users[i], nil
+		}
+	}
+	return nil, errorf(http.StatusNotFound, "", "user %q not found", id)
+}
+
+// setActive enables or disables u.
+func (h *Handler) setActive(ctx context.Context, u *cognito.User, active bool) error {
+	if u.Enabled == active {
+		return nil
+	}
+	action, set := "user.enable", h.dir.EnableUser
+	if !active {
+		action, set = "user.disable", h.dir.DisableUser
+	}
+	if err := set(ctx, u.Username); err != nil {
+		return notFound(err, "user", u.Username)
+	}
+	u.Enabled = active
+	return audit.Record(ctx, h.log, action, u.Username, map[string]string{"via": "scim"})
+}
+
+// group returns the group named name, with its members if members is
+// set.
+func (h *Handler) group(ctx context.Context, name string, members bool) (*Group, error) {
+	i := slices.IndexFunc(h.groups, func(g string) bool { return strings.EqualFold(g, name) })
+	if i < 0 {
+		return nil, errorf(http.StatusNotFound, "", "group %q not found", name)
+	}
+	name = h.groups[i]
+	g := &Group{
+		Schemas:     []string{SchemaGroup},
+		ID:          name,
+		DisplayName: name,
+		Meta:        &Meta{ResourceType: "Group", Location: Prefix + "/Groups/" + name},
+	}
+	if !members {
+		return g, nil
+	}
+	users, err := h.dir.ListUsersInGroup(ctx, name)
+	if err != nil {
+		return nil, notFound(err, "group", name)
+	}
+	for _, u := range users {
+		g.Members = append(g.Members, MultiValue{Value: u.Username, Display: u.Email, Ref: Prefix + "/Users/" + u.Username})
+	}
+	return g, nil
+}
+
+// setMembers makes the members of group exactly ids.
+func (h *Handler) setMembers(ctx context.Context, group string, ids []string) error {
+	g, err := h.group(ctx, group, true)
+	if err != nil {
+		return err
+	}
+	current := values(g.Members)
+	for _, id := range ids {
+		if !slices.ContainsFunc(current, func(c string) bool { return strings.EqualFold(c, id) }) {
+			if err := h.addMember(ctx, g.ID, id); err != nil {
+				return err
+			}
+		}
+	}
+	for _, c := range current {
+		if !slices.ContainsFunc(ids, func(id string) bool { return strings.EqualFold(c, id) }) {
+			if err := h.removeMember(ctx, g.ID, c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (h *Handler) addMember(ctx context.Context, group, id string) error {
+	if err := h.dir.AddUserToGroup(ctx, id, group); err != nil {
+		return notFound(err, "user", id)
+	}
+	return audit.Record(ctx, h.log, "user.group.add", id, map[string]string{"group": group, "via": "scim"})
+}
+
+func (h *Handler) removeMember(ctx context.Context, group, id string) error {
+	if err := h.dir.RemoveUserFromGroup(ctx, id, group); err != nil {
+		return notFound(err, "user", id)
+	}
+	return audit.Record(ctx, h.log, "user.group.remove", id, map[string]string{"group": group, "via": "scim"})
+}
+
+// writeUser writes u with the managed groups it belongs to.
+func (h *Handler) writeUser(w http.ResponseWriter, r *http.Request, status int, u *cognito.User) {
+	groups, err := h.dir.GroupsForUser(r.Context(), u.Username)
+	if err != nil {
+		writeError(w, notFound(err, "user", u.Username))
+		return
+	}
+	groups = slices.DeleteFunc(groups, func(g string) bool { return !slices.Contains(h.groups, g) })
+	writeJSON(w, status, toUser(*u, groups))
+}
+
+func (h *Handler) writeGroup(w http.ResponseWriter, r *http.Request, name string) {
+	g, err := h.group(r.Context(), name, true)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, g)
+}
+
+// toUser returns the SCIM representation of u.
+func toUser(u cognito.User, groups []string) *User {
+	active := u.Enabled
+	out := &User{
+		Schemas:     []string{SchemaUser},
+		ID:          u.Username,
+		UserName:    u.Username,
+		DisplayName: u.Name,
+		Emails:      []MultiValue{{Value: u.Email, Primary: true}},
+		Active:      &active,
+		Meta:        &Meta{ResourceType: "User", Location: Prefix + "/Users/" + u.Username},
+	}
+	if u.Name != "" {
+		out.Name = &Name{Formatted: u.Name}
+	}
+	if !u.Created.IsZero() {
+		out.Meta.Created = &u.Created
+	}
+	for _, g := range groups {
+		out.Groups = append(out.Groups, MultiValue{Value: g, Display: g, Ref: Prefix + "/Groups/" + g})
+	}
+	return out
+}
+
+// withMembers reports whether group members were asked for.
+func withMembers(r *http.Request) bool {
+	excluded := strings.ToLower(r.URL.Query().Get("excludedAttributes"))
+	return !slices.Contains(strings.Split(excluded, ","), "members")
+}
+
+// values returns the values of multi-valued attribute entries.
+func values(mv []MultiValue) []string {
+	out := make([]string, len(mv))
+	for i, m := range mv {
+		out[i] = m.Value
+	}
+	return out
+}
+
+// list returns a list response for one page of resources.
+func list(page []any, start, total int) *ListResponse {
+	if page == nil {
+		page = []any{}
+	}
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   start,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	}
+}
+
+// writePage writes the page of resources selected by the startIndex and
+// count query parameters.
+func writePage(w http.ResponseWriter, r *http.Request, resources []any) {
+	start, count := 1, MaxResults
+	if v, err := strconv.Atoi(r.URL.Query().Get("startIndex")); err == nil && v > 1 {
+		start = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("count")); err == nil && v >= 0 && v < MaxResults {
+		count = v
+	}
+	page := resources[min(start-1, len(resources)):]
+	writeJSON(w, http.StatusOK, list(page[:min(count, len(page))], start, len(resources)))
+}
+
+// decode reads a JSON request body into v.
+func decode(r *http.Request, v any) error {
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBody)).Decode(v); err != nil {
+		return errorf(http.StatusBadRequest, "invalidSyntax", "invalid request body: %v", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = errorf(http.StatusInternalServerError, "", "%v", err)
+	}
+	status, _ := strconv.Atoi(e.Status)
+	writeJSON(w, status, e)
+}
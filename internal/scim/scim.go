@@ -0,0 +1,231 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scim is a SCIM 2.0 (RFC 7643, RFC 7644) provisioning service
+// over the user pool, so a campus identity management system can
+// create and deactivate accounts and keep group memberships in sync.
+//
+// Users are identified by their pool username, which is their email
+// address. Accounts are never deleted, because datasets and the audit
+// log refer to them: DELETE deactivates. Groups are the fixed set the
+// service is configured with, normally the role groups, and only their
+// members may be changed.
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/cognito"
+)
+
+// Schema URNs.
+const (
+	SchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaConfig       = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+)
+
+// MaxResults is the largest page returned by a list request.
+const MaxResults = 200
+
+// Directory is the user pool provisioned through SCIM.
+// *cognito.Client implements it.
+type Directory interface {
+	CreateUser(ctx context.Context, email, name string) (*cognito.User, error)
+	ListUsers(ctx context.Context) ([]cognito.User, error)
+	ListUsersInGroup(ctx context.Context, group string) ([]cognito.User, error)
+	GroupsForUser(ctx context.Context, username string) ([]string, error)
+	DisableUser(ctx context.Context, username string) error
+	EnableUser(ctx context.Context, username string) error
+	AddUserToGroup(ctx context.Context, username, group string) error
+	RemoveUserFromGroup(ctx context.Context, username, group string) error
+}
+
+// Error is a SCIM error response.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return "scim: " + e.Detail
+}
+
+// errorf returns an Error with HTTP status and SCIM error type.
+func errorf(status int, scimType, format string, args ...any) *Error {
+	return &Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   fmt.Sprintf(format, args...),
+	}
+}
+
+// Meta is resource metadata.
+type Meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	Location     string     `json:"location,omitempty"`
+}
+
+// Name is a user's name.
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// MultiValue is an entry of a multi-valued attribute such as emails,
+// groups, or members.
+type MultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// User is a SCIM user resource.
+type User struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	UserName    string       `json:"userName"`
+	Name        *Name        `json:"name,omitempty"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Emails      []MultiValue `json:"emails,omitempty"`
+	Active      *bool        `json:"active,omitempty"`
+	Groups      []MultiValue `json:"groups,omitempty"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+// email returns the address to provision u with: its primary email,
+// or its userName.
+func (u *User) email() string {
+	for _, e := range u.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return u.UserName
+}
+
+// displayName returns the name to provision u with.
+func (u *User) displayName() string {
+	switch {
+	case u.DisplayName != "":
+		return u.DisplayName
+	case u.Name == nil:
+		return ""
+	case u.Name.Formatted != "":
+		return u.Name.Formatted
+	}
+	return strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+}
+
+// Group is a SCIM group resource.
+type Group struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id"`
+	DisplayName string       `json:"displayName"`
+	Members     []MultiValue `json:"members,omitempty"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+// ListResponse is a page of resources.
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+// PatchRequest is a PATCH request body.
+type PatchRequest struct {
+	Schemas    []string    `json:"schemas"`
+	Operations []Operation `json:"Operations"`
+}
+
+// Operation is one PATCH operation.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// filter is an equality filter, the only kind identity management
+// systems use to look up resources before provisioning them.
+type filter struct {
+	attr  string
+	value string
+}
+
+// parseFilter parses `attr eq "value"`. An empty expression matches
+// everything.
+func parseFilter(expr string) (*filter, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(expr, " ", 3)
+	if len(parts) != 3 || !strings.EqualFold(parts[1], "eq") {
+		return nil, errorf(http.StatusBadRequest, "invalidFilter", "unsupported filter %q: only 'attribute eq \"value\"' is supported", expr)
+	}
+	value, err := strconv.Unquote(strings.TrimSpace(parts[2]))
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "invalidFilter", "filter value in %q must be a quoted string", expr)
+	}
+	return &filter{attr: strings.ToLower(parts[0]), value: value}, nil
+}
+
+// activeValue decodes the value of the active attribute, which some
+// identity management systems send as a string.
+func activeValue(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	}
+	return false, errorf(http.StatusBadRequest, "invalidValue", "active must be a boolean, got %s", raw)
+}
+
+// notFound maps the directory's not-found error to a SCIM 404.
+func notFound(err error, kind, id string) error {
+	if errors.Is(err, cognito.ErrNotFound) {
+		return errorf(http.StatusNotFound, "", "%s %q not found", kind, id)
+	}
+	return err
+}
@@ -0,0 +1,291 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/cognito"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/token"
+)
+
+// fakeDirectory is an in-memory user pool.
+type fakeDirectory struct {
+	users  []cognito.User
+	groups map[string][]string
+}
+
+func (d *fakeDirectory) CreateUser(_ context.Context, email, name string) (*cognito.User, error) {
+	if _, err := d.find(email); err == nil {
+		return nil, cognito.ErrExists
+	}
+	d.users = append(d.users, cognito.User{Username: email, Email: email, Name: name, Enabled: true, Status: "FORCE_CHANGE_PASSWORD"})
+	u := d.users[len(d.users)-1]
+	return &u, nil
+}
+
+func (d *fakeDirectory) ListUsers(context.Context) ([]cognito.User, error) {
+	return slices.Clone(d.users), nil
+}
+
+func (d *fakeDirectory) ListUsersInGroup(_ context.Context, group string) ([]cognito.User, error) {
+	var out []cognito.User
+	for _, u := range d.users {
+		if slices.Contains(d.groups[u.Username], group) {
+			out = append(out, u)
+		}
+	}
+	return out, nil
+}
+
+func (d *fakeDirectory) GroupsForUser(_ context.Context, username string) ([]string, error) {
+	return d.groups[username], nil
+}
+
+func (d *fakeDirectory) DisableUser(_ context.Context, username string) error {
+	u, err := d.find(username)
+	if err == nil {
+		u.Enabled = false
+	}
+	return err
+}
+
+func (d *fakeDirectory) EnableUser(_ context.Context, username string) error {
+	u, err := d.find(username)
+	if err == nil {
+		u.Enabled = true
+	}
+	return err
+}
+
+func (d *fakeDirectory) AddUserToGroup(_ context.Context, username, group string) error {
+	if _, err := d.find(username); err != nil {
+		return err
+	}
+	if !slices.Contains(d.groups[username], group) {
+		d.groups[username] = append(d.groups[username], group)
+	}
+	return nil
+}
+
+func (d *fakeDirectory) RemoveUserFromGroup(_ context.Context, username, group string) error {
+	d.groups[username] = slices.DeleteFunc(d.groups[username], func(g string) bool { return g == group })
+	return nil
+}
+
+func (d *fakeDirectory) find(username string) (*cognito.User, error) {
+	for i := range d.users {
+		if d.users[i].Username == username {
+			return &d.users[i], nil
+		}
+	}
+	return nil, cognito.ErrNotFound
+}
+
+func newHandler() (*Handler, *fakeDirectory, *audit.MemoryLog) {
+	dir := &fakeDirectory{
+		users:  []cognito.User{{Username: "old@uni.edu", Email: "old@uni.edu", Enabled: true}},
+		groups: map[string][]string{"old@uni.edu": {"researchers"}},
+	}
+	log := &audit.MemoryLog{}
+	auth := func(_ context.Context, secret string) (identity.Principal, error) {
+		switch secret {
+		case "idm":
+			return identity.Principal{ID: "svc:idm", Scopes: []string{token.ScopeUsersWrite}}, nil
+		case "ci":
+			return identity.Principal{ID: "svc:ci", Scopes: []string{token.ScopeUpload}}, nil
+		}
+		return identity.Principal{}, token.ErrNotFound
+	}
+	return NewHandler(dir, []string{"researchers", "curators"}, auth, log), dir, log
+}
+
+func do(t *testing.T, h http.Handler, method, path, bearer, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if bearer != "" {
+		r.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthentication(t *testing.T) {
+	h, _, _ := newHandler()
+	tests := []struct {
+		bearer string
+		want   int
+	}{
+		{"", http.StatusUnauthorized},
+		{"bogus", http.StatusUnauthorized},
+		{"ci", http.StatusForbidden},
+		{"idm", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := do(t, h, http.MethodGet, Prefix+"/Users", tt.bearer, "").Code; got != tt.want {
+			t.Errorf("GET /Users with %q = %d, want %d", tt.bearer, got, tt.want)
+		}
+	}
+}
+
+func TestUserLifecycle(t *testing.T) {
+	h, dir, log := newHandler()
+
+	w := do(t, h, http.MethodPost, Prefix+"/Users", "idm", `{
+		"schemas": ["`+SchemaUser+`"],
+		"userName": "New.Hire@Uni.edu",
+		"name": {"givenName": "New", "familyName": "Hire"},
+		"emails": [{"value": "New.Hire@Uni.edu", "primary": true}],
+		"active": true
+	}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /Users = %d %s", w.Code, w.Body)
+	}
+	var u User
+	if err := json.Unmarshal(w.Body.Bytes(), &u); err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != "new.hire@uni.edu" || u.DisplayName != "New Hire" || u.Active == nil || !*u.Active {
+		t.Errorf("created user = %+v", u)
+	}
+	if w := do(t, h, http.MethodPost, Prefix+"/Users", "idm", `{"userName": "new.hire@uni.edu"}`); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "uniqueness") {
+		t.Errorf("second POST /Users = %d %s, want 409 uniqueness", w.Code, w.Body)
+	}
+
+	w = do(t, h, http.MethodGet, Prefix+`/Users?filter=userName+eq+"new.hire@uni.edu"`, "idm", "")
+	var page ListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || page.TotalResults != 1 {
+		t.Errorf("filtered GET /Users = %s", w.Body)
+	}
+	if w := do(t, h, http.MethodGet, Prefix+`/Users?filter=title+co+"x"`, "idm", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("unsupported filter = %d, want 400", w.Code)
+	}
+
+	// Entra ID deactivates with a string value.
+	w = do(t, h, http.MethodPatch, Prefix+"/Users/new.hire@uni.edu", "idm", `{
+		"schemas": ["`+SchemaPatchOp+`"],
+		"Operations": [{"op": "Replace", "path": "active", "value": "False"}]
+	}`)
+	if w.Code != http.StatusOK || dir.users[1].Enabled {
+		t.Errorf("PATCH active=false = %d %s, enabled %v", w.Code, w.Body, dir.users[1].Enabled)
+	}
+	w = do(t, h, http.MethodPatch, Prefix+"/Users/new.hire@uni.edu", "idm", `{
+		"Operations": [{"op": "replace", "value": {"active": true, "displayName": "N. Hire"}}]
+	}`)
+	if w.Code != http.StatusOK || !dir.users[1].Enabled {
+		t.Errorf("PATCH {active: true} = %d %s", w.Code, w.Body)
+	}
+
+	if w := do(t, h, http.MethodDelete, Prefix+"/Users/old@uni.edu", "idm", ""); w.Code != http.StatusNoContent || dir.users[0].Enabled {
+		t.Errorf("DELETE = %d, enabled %v; want 204 and deactivated", w.Code, dir.users[0].Enabled)
+	}
+	if w := do(t, h, http.MethodGet, Prefix+"/Users/nobody@uni.edu", "idm", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET unknown user = %d, want 404", w.Code)
+	}
+
+	entries, _ := log.Entries(context.Background())
+	var actions []string
+	for _, e := range entries {
+		if e.Actor != "svc:idm" {
+			t.Errorf("audit actor = %s, want svc:idm", e.Actor)
+		}
+		actions = append(actions, e.Action)
+	}
+	want := []string{"user.create", "user.disable", "user.enable", "user.disable"}
+	if !slices.Equal(actions, want) {
+		t.Errorf("audit actions = %v, want %v", actions, want)
+	}
+}
+
+func TestGroupMembership(t *testing.T) {
+	h, dir, _ := newHandler()
+	if _, err := dir.CreateUser(context.Background(), "cat@uni.edu", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	w := do(t, h, http.MethodGet, Prefix+`/Groups?filter=displayName+eq+"curators"&excludedAttributes=members`, "idm", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"totalResults":1`) {
+		t.Errorf("GET /Groups filtered = %d %s", w.Code, w.Body)
+	}
+
+	w = do(t, h, http.MethodPatch, Prefix+"/Groups/curators", "idm", `{
+		"Operations": [{"op": "add", "path": "members", "value": [{"value": "cat@uni.edu"}, {"value": "old@uni.edu"}]}]
+	}`)
+	if w.Code != http.StatusOK || !slices.Contains(dir.groups["cat@uni.edu"], "curators") {
+		t.Fatalf("PATCH add members = %d %s", w.Code, w.Body)
+	}
+	w = do(t, h, http.MethodPatch, Prefix+"/Groups/curators", "idm", `{
+		"Operations": [{"op": "remove", "path": "members[value eq \"old@uni.edu\"]"}]
+	}`)
+	if w.Code != http.StatusOK || slices.Contains(dir.groups["old@uni.edu"], "curators") {
+		t.Errorf("PATCH remove member = %d %s", w.Code, w.Body)
+	}
+
+	w = do(t, h, http.MethodPut, Prefix+"/Groups/researchers", "idm", `{"displayName": "researchers", "members": [{"value": "cat@uni.edu"}]}`)
+	var g Group
+	if err := json.Unmarshal(w.Body.Bytes(), &g); err != nil || len(g.Members) != 1 || g.Members[0].Value != "cat@uni.edu" {
+		t.Errorf("PUT /Groups/researchers = %d %s", w.Code, w.Body)
+	}
+
+	w = do(t, h, http.MethodGet, Prefix+"/Users/cat@uni.edu", "idm", "")
+	var u User
+	if err := json.Unmarshal(w.Body.Bytes(), &u); err != nil || len(u.Groups) != 2 {
+		t.Errorf("GET user groups = %s", w.Body)
+	}
+
+	if w := do(t, h, http.MethodGet, Prefix+"/Groups/admins", "idm", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET unmanaged group = %d, want 404", w.Code)
+	}
+	if w := do(t, h, http.MethodPatch, Prefix+"/Groups/curators", "idm", `{"Operations": [{"op": "replace", "path": "displayName", "value": "x"}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PATCH displayName = %d, want 400", w.Code)
+	}
+	if w := do(t, h, http.MethodPost, Prefix+"/Groups", "idm", `{"displayName": "x"}`); w.Code != http.StatusForbidden {
+		t.Errorf("POST /Groups = %d, want 403", w.Code)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    *filter
+		wantErr bool
+	}{
+		{"", nil, false},
+		{`userName eq "a@b.edu"`, &filter{"username", "a@b.edu"}, false},
+		{`displayName EQ "two words"`, &filter{"displayname", "two words"}, false},
+		{`userName sw "a"`, nil, true},
+		{`userName eq a`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseFilter(tt.expr)
+		if (err != nil) != tt.wantErr || (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("parseFilter(%q) = %v, %v; want %v, wantErr %v", tt.expr, got, err, tt.want, tt.wantErr)
+		}
+		var e *Error
+		if err != nil && !errors.As(err, &e) {
+			t.Errorf("parseFilter(%q) error %T is not a SCIM error", tt.expr, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package seal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// sealsMaxAge is how long clients and the CDN may cache seals, which
+// never change once made.
+const sealsMaxAge = "public, max-age=86400"
+
+// Path returns the path of the seal of version n of dataset id.
+func Path(id string, n int) string {
+	return fmt.Sprintf("/seals/%s/v%d", id, n)
+}
+
+// Handler serves seals:
+//
+//	GET /seals/key              the PEM public key that signs seals
+//	GET /seals/{id}/v{version}  the Seal of a published version
+type Handler struct {
+	sealer   *Sealer
+	datasets *dataset.Store
+	mux      *http.ServeMux
+}
+
+// NewHandler returns a handler serving the seals of s for published
+// datasets in datasets.
+func NewHandler(s *Sealer, datasets *dataset.Store) *Handler {
+	h := &Handler{sealer: s, datasets: datasets, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /seals/key", h.key)
+	h.mux.HandleFunc("GET /seals/{id}/{version}", h.get)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) key(w http.ResponseWriter, r *http.Request) {
+	_, pemKey, err := h.sealer.PublicKey(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Cache-Control", sealsMaxAge)
+	_, _ = w.Write([]byte(pemKey))
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	d, err := h.datasets.Get(ctx, r.PathValue("id"))
+	if errors.Is(err, dataset.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(r.PathValue("version"), "v"))
+	if err != nil || d.State != dataset.StatePublished {
+		http.NotFound(w, r)
+		return
+	}
+	seal, err := h.sealer.Get(ctx, d.ID, n)
+	if errors.Is(err, ErrNotSealed) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", sealsMaxAge)
+	_ = json.NewEncoder(w).Encode(seal)
+}
@@ -0,0 +1,307 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package seal signs the manifests of published dataset versions, so
+// that anyone can confirm that files match exactly what was published
+// under a DOI.
+//
+// A manifest lists a version's files by path with their sizes and
+// SHA-256 digests, under the dataset's ID, the version's DOI, and its
+// publication time. When the version is published, the SHA-256 digest
+// of the manifest's JSON is signed with an asymmetric AWS KMS key
+// (ECC_NIST_P256, signing with ECDSA_SHA_256), whose private half never
+// leaves KMS. A Seal carries the manifest's JSON as signed, the
+// signature, and the public key. Seals are served with the key, the
+// download endpoint refuses files whose records no longer match their
+// sealed manifests, and consumers check downloaded files against a
+// seal with `aperture seal verify` or any ECDSA implementation.
+package seal
+
+import (
+	"cmp"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/kms"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// sealsTable holds the seal of each published version, keyed by
+// <dataset>/v<n>.
+const sealsTable = "seals"
+
+var (
+	// ErrNotSealed is returned for a version without a seal, such as
+	// one published before sealing was configured.
+	ErrNotSealed = errors.New("version is not sealed")
+
+	// ErrInvalid is returned for a seal whose signature does not verify.
+	ErrInvalid = errors.New("seal signature is invalid")
+
+	// ErrMismatch is returned for a file that differs from its sealed
+	// manifest.
+	ErrMismatch = errors.New("file does not match the sealed manifest")
+)
+
+// File is a file listed in a manifest.
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest is the content of a published version.
+type Manifest struct {
+	Dataset   string    `json:"dataset"`
+	DOI       string    `json:"doi,omitempty"`
+	Version   int       `json:"version"`
+	Published time.Time `json:"published"`
+
+	// Files are sorted by path
+	Files []File `json:"files"`
+}
+
+// NewManifest returns the manifest of version v of d.
+func NewManifest(d *dataset.Dataset, v *dataset.Version) *Manifest {
+	m := &Manifest{Dataset: d.ID, DOI: cmp.Or(v.DOI, d.DOI), Version: v.Number, Files: []File{}}
+	if v.PublishedAt != nil {
+		m.Published = v.PublishedAt.UTC()
+	}
+	for _, f := range v.Files {
+		m.Files = append(m.Files, File{Path: f.Path, Size: f.Size, SHA256: strings.ToLower(f.SHA256)})
+	}
+	slices.SortFunc(m.Files, func(a, b File) int { return cmp.Compare(a.Path, b.Path) })
+	return m
+}
+
+// File returns the file at path, or nil if there is none.
+func (m *Manifest) File(path string) *File {
+	for i := range m.Files {
+		if m.Files[i].Path == path {
+			return &m.Files[i]
+		}
+	}
+	return nil
+}
+
+// Seal is the signed manifest of a published version.
+type Seal struct {
+	// Payload is the manifest's JSON, exactly as signed
+	Payload []byte `json:"payload"`
+
+	// Digest is the hex SHA-256 digest of Payload
+	Digest string `json:"digest"`
+
+	// Algorithm is the signing algorithm
+	Algorithm string `json:"algorithm"`
+
+	// Signature is the DER-encoded ECDSA signature of Digest
+	Signature []byte `json:"signature"`
+
+	// Key identifies the KMS key that signed; PublicKey is its PEM
+	// public key
+	Key       string `json:"key"`
+	PublicKey string `json:"publicKey"`
+
+	SignedAt time.Time `json:"signedAt"`
+}
+
+// Manifest decodes the signed manifest.
+func (s *Seal) Manifest() (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(s.Payload, &m); err != nil {
+		return nil, fmt.Errorf("invalid sealed manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// Verify checks that the seal was signed by pub, and returns its
+// manifest.
+func (s *Seal) Verify(pub *ecdsa.PublicKey) (*Manifest, error) {
+	sum := sha256.Sum256(s.Payload)
+	if hex.EncodeToString(sum[:]) != s.Digest || !ecdsa.VerifyASN1(pub, sum[:], s.Signature) {
+		return nil, ErrInvalid
+	}
+	return s.Manifest()
+}
+
+// ParsePublicKey parses a PEM-encoded ECDSA public key.
+func ParsePublicKey(b []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("no PEM public key found")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is %T, want ECDSA", pub)
+	}
+	return key, nil
+}
+
+// Fingerprint returns the hex SHA-256 digest of the DER encoding of
+// pub, for pinning the key that signs seals.
+func Fingerprint(pub *ecdsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyService signs with asymmetric keys. *kms.Client implements it.
+type KeyService interface {
+	Sign(ctx context.Context, keyID string, digest []byte) ([]byte, error)
+	GetPublicKey(ctx context.Context, keyID string) ([]byte, error)
+}
+
+// Sealer seals published versions and checks files against their
+// seals.
+type Sealer struct {
+	// State holds the seals
+	State state.Store
+
+	// KMS signs with the key KeyID, an ECC_NIST_P256 key's ID, ARN, or
+	// alias
+	KMS   KeyService
+	KeyID string
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+
+	mu     sync.Mutex
+	pub    *ecdsa.PublicKey
+	pubPEM string
+}
+
+// PublicKey returns the key that signs seals, and its PEM encoding.
+// It is fetched from KMS once.
+func (s *Sealer) PublicKey(ctx context.Context) (*ecdsa.PublicKey, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pub != nil {
+		return s.pub, s.pubPEM, nil
+	}
+	der, err := s.KMS.GetPublicKey(ctx, s.KeyID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get the sealing key: %w", err)
+	}
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	pub, err := ParsePublicKey([]byte(pemKey))
+	if err != nil {
+		return nil, "", fmt.Errorf("sealing key %s: %w", s.KeyID, err)
+	}
+	s.pub, s.pubPEM = pub, pemKey
+	return pub, pemKey, nil
+}
+
+// Seal signs the manifest of version v of d, which must be published,
+// and records the seal.
+func (s *Sealer) Seal(ctx context.Context, d *dataset.Dataset, v *dataset.Version) (*Seal, error) {
+	if v.PublishedAt == nil {
+		return nil, fmt.Errorf("%s v%d is not published", d.ID, v.Number)
+	}
+	_, pemKey, err := s.PublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(NewManifest(d, v))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(payload)
+	sig, err := s.KMS.Sign(ctx, s.KeyID, sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal %s v%d: %w", d.ID, v.Number, err)
+	}
+	seal := &Seal{
+		Payload:   payload,
+		Digest:    hex.EncodeToString(sum[:]),
+		Algorithm: kms.SigningAlgorithm,
+		Signature: sig,
+		Key:       s.KeyID,
+		PublicKey: pemKey,
+		SignedAt:  s.now().UTC(),
+	}
+	if err := s.State.Put(ctx, sealsTable, key(d.ID, v.Number), seal); err != nil {
+		return nil, fmt.Errorf("failed to record seal of %s v%d: %w", d.ID, v.Number, err)
+	}
+	return seal, nil
+}
+
+// Get returns the seal of version n of dataset id.
+func (s *Sealer) Get(ctx context.Context, id string, n int) (*Seal, error) {
+	var seal Seal
+	if err := s.State.Get(ctx, sealsTable, key(id, n), &seal); err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s v%d", ErrNotSealed, id, n)
+		}
+		return nil, err
+	}
+	return &seal, nil
+}
+
+// Check verifies the seal of version v of d with the sealing key and
+// checks that f, a file of v, matches the sealed manifest, returning
+// the sealed entry. It fails with ErrNotSealed if v has no seal.
+func (s *Sealer) Check(ctx context.Context, d *dataset.Dataset, v *dataset.Version, f *dataset.File) (*File, error) {
+	seal, err := s.Get(ctx, d.ID, v.Number)
+	if err != nil {
+		return nil, err
+	}
+	pub, _, err := s.PublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := seal.Verify(pub)
+	if err != nil {
+		return nil, fmt.Errorf("%s v%d: %w", d.ID, v.Number, err)
+	}
+	sealed := m.File(f.Path)
+	switch {
+	case sealed == nil:
+		return nil, fmt.Errorf("%w: %s is not in %s v%d", ErrMismatch, f.Path, d.ID, v.Number)
+	case sealed.Size != f.Size || sealed.SHA256 != strings.ToLower(f.SHA256):
+		return nil, fmt.Errorf("%w: %s in %s v%d", ErrMismatch, f.Path, d.ID, v.Number)
+	}
+	return sealed, nil
+}
+
+// key returns the state key of the seal of version n of dataset id.
+func key(id string, n int) string {
+	return fmt.Sprintf("%s/v%d", id, n)
+}
+
+func (s *Sealer) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,160 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package seal
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// fakeKMS signs with a local key.
+type fakeKMS struct {
+	key   *ecdsa.PrivateKey
+	calls int
+}
+
+func (f *fakeKMS) Sign(_ context.Context, _ string, digest []byte) ([]byte, error) {
+	f.calls++
+	return ecdsa.SignASN1(rand.Reader, f.key, digest)
+}
+
+func (f *fakeKMS) GetPublicKey(context.Context, string) ([]byte, error) {
+	return x509.MarshalPKIXPublicKey(&f.key.PublicKey)
+}
+
+func newFakeKMS(t *testing.T) *fakeKMS {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeKMS{key: key}
+}
+
+func TestSealer(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	k := newFakeKMS(t)
+	sealer := &Sealer{State: s, KMS: k, KeyID: "alias/seal", Now: func() time.Time { return now }}
+
+	d := &dataset.Dataset{ID: "ds-1", DOI: "10.1/ds-1", State: dataset.StatePublished, CreatedAt: now, Versions: []dataset.Version{
+		{Number: 1, DOI: "10.1/ds-1.v1", PublishedAt: &now, Files: []dataset.File{
+			{Path: "b.csv", Size: 2, SHA256: "BB"},
+			{Path: "a.csv", Size: 1, SHA256: "aa"},
+		}},
+		{Number: 2},
+	}}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	v := d.Version(1)
+	if _, err := sealer.Seal(ctx, d, d.Version(2)); err == nil {
+		t.Error("Seal() of an unpublished version succeeded")
+	}
+	seal, err := sealer.Seal(ctx, d, v)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	// The seal verifies with its own public key, and the manifest
+	// lists the files by path under the version DOI.
+	pub, err := ParsePublicKey([]byte(seal.PublicKey))
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+	m, err := seal.Verify(pub)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if m.DOI != "10.1/ds-1.v1" || len(m.Files) != 2 || m.Files[0].Path != "a.csv" || m.Files[1].SHA256 != "bb" {
+		t.Errorf("manifest = %+v", m)
+	}
+	if Fingerprint(pub) != Fingerprint(&k.key.PublicKey) {
+		t.Error("Fingerprint() differs for the same key")
+	}
+	forged := *seal
+	forged.Payload = []byte(`{"dataset":"ds-1","version":1,"files":[]}`)
+	if _, err := forged.Verify(pub); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Verify() of an altered manifest error = %v, want ErrInvalid", err)
+	}
+
+	if _, err := sealer.Check(ctx, d, v, &v.Files[0]); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	changed := v.Files[1]
+	changed.SHA256 = "cc"
+	if _, err := sealer.Check(ctx, d, v, &changed); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Check() of a changed file error = %v, want ErrMismatch", err)
+	}
+	if _, err := sealer.Check(ctx, d, v, &dataset.File{Path: "new.csv"}); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Check() of an added file error = %v, want ErrMismatch", err)
+	}
+	if _, err := sealer.Check(ctx, d, d.Version(2), &v.Files[0]); !errors.Is(err, ErrNotSealed) {
+		t.Errorf("Check() of an unsealed version error = %v, want ErrNotSealed", err)
+	}
+
+	// A seal replaced without the signing key is refused.
+	other := &Sealer{State: s, KMS: newFakeKMS(t), KeyID: "alias/other"}
+	if _, err := other.Seal(ctx, d, v); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sealer.Check(ctx, d, v, &v.Files[0]); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Check() of a seal signed by another key error = %v, want ErrInvalid", err)
+	}
+	if _, err := sealer.Seal(ctx, d, v); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler(sealer, datasets)
+	for _, tt := range []struct {
+		path string
+		code int
+	}{
+		{Path("ds-1", 1), http.StatusOK},
+		{Path("ds-1", 2), http.StatusNotFound},
+		{Path("ds-9", 1), http.StatusNotFound},
+		{"/seals/key", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("GET %s = %d, want %d", tt.path, w.Code, tt.code)
+			continue
+		}
+		if tt.path == Path("ds-1", 1) {
+			var got Seal
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := got.Verify(pub); err != nil {
+				t.Errorf("served seal does not verify: %v", err)
+			}
+		}
+	}
+}
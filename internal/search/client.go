@@ -0,0 +1,234 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+	"github.com/scttfrdmn/aperture/internal/trace"
+)
+
+// errNotFound is returned for 404 responses.
+var errNotFound = errors.New("not found")
+
+// ClientOptions configures a Client.
+type ClientOptions struct {
+	// Endpoint is the domain endpoint, e.g.
+	// https://search-aperture-xyz.us-east-1.es.amazonaws.com
+	Endpoint string
+
+	// Region is the domain's AWS region
+	Region string
+
+	// Credentials sign requests; requests are unsigned if empty, for
+	// domains behind a proxy or local development clusters
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is a minimal OpenSearch REST client, also compatible with
+// Elasticsearch.
+type Client struct {
+	endpoint string
+	signer   *aws.Signer
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts ClientOptions) *Client {
+	c := &Client{endpoint: strings.TrimRight(opts.Endpoint, "/"), http: opts.HTTPClient}
+	if opts.Credentials.AccessKeyID != "" {
+		// Amazon OpenSearch Service domains sign as "es".
+		c.signer = &aws.Signer{Credentials: opts.Credentials, Region: opts.Region, Service: "es"}
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// CreateIndex creates index with the given settings and mappings.
+func (c *Client) CreateIndex(ctx context.Context, index string, body any) error {
+	return c.do(ctx, http.MethodPut, "/"+url.PathEscape(index), body, nil)
+}
+
+// DeleteIndex deletes index.
+func (c *Client) DeleteIndex(ctx context.Context, index string) error {
+	return c.do(ctx, http.MethodDelete, "/"+url.PathEscape(index), nil, nil)
+}
+
+// Put indexes doc under id in index, which may be an alias.
+func (c *Client) Put(ctx context.Context, index, id string, doc any) error {
+	return c.do(ctx, http.MethodPut, "/"+url.PathEscape(index)+"/_doc/"+url.PathEscape(id), doc, nil)
+}
+
+// Delete removes the document id from index. Deleting a document that
+// is not indexed is not an error.
+func (c *Client) Delete(ctx context.Context, index, id string) error {
+	err := c.do(ctx, http.MethodDelete, "/"+url.PathEscape(index)+"/_doc/"+url.PathEscape(id), nil, nil)
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Count returns the number of documents in index.
+func (c *Client) Count(ctx context.Context, index string) (int, error) {
+	var out struct {
+		Count int `json:"count"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/"+url.PathEscape(index)+"/_count", nil, &out); err != nil {
+		return 0, err
+	}
+	return out.Count, nil
+}
+
+// Bulk indexes docs, keyed by ID, into index in one request.
+func (c *Client) Bulk(ctx context.Context, index string, docs map[string]any) error {
+	ids := make([]string, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, id := range ids {
+		action := map[string]any{"index": map[string]string{"_index": index, "_id": id}}
+		if err := enc.Encode(action); err != nil {
+			return fmt.Errorf("failed to encode bulk request: %w", err)
+		}
+		if err := enc.Encode(docs[id]); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", id, err)
+		}
+	}
+	var out struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := c.send(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", body.Bytes(), &out); err != nil {
+		return err
+	}
+	if !out.Errors {
+		return nil
+	}
+	var failed []string
+	for _, item := range out.Items {
+		for _, r := range item {
+			if r.Status >= 300 {
+				failed = append(failed, fmt.Sprintf("%s (HTTP %d: %s)", r.ID, r.Status, r.Error))
+			}
+		}
+	}
+	return fmt.Errorf("failed to index %d documents: %s", len(failed), strings.Join(failed, "; "))
+}
+
+// AliasTargets returns the indices alias points to, sorted, or none if
+// the alias does not exist.
+func (c *Client) AliasTargets(ctx context.Context, alias string) ([]string, error) {
+	var out map[string]json.RawMessage
+	err := c.do(ctx, http.MethodGet, "/_alias/"+url.PathEscape(alias), nil, &out)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	indices := make([]string, 0, len(out))
+	for index := range out {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
+
+// SwapAlias points alias at index alone, removing it from the indices
+// in old, in one atomic update.
+func (c *Client) SwapAlias(ctx context.Context, alias, index string, old []string) error {
+	actions := []map[string]any{{"add": map[string]string{"index": index, "alias": alias}}}
+	for _, o := range old {
+		actions = append(actions, map[string]any{"remove": map[string]string{"index": o, "alias": alias}})
+	}
+	return c.do(ctx, http.MethodPost, "/_aliases", map[string]any{"actions": actions}, nil)
+}
+
+// Search runs a search request against index, decoding the response
+// into out.
+func (c *Client) Search(ctx context.Context, index string, body, out any) error {
+	return c.do(ctx, http.MethodPost, "/"+url.PathEscape(index)+"/_search", body, out)
+}
+
+// do sends body, if any, as JSON and decodes the response into out, if
+// non-nil.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+	return c.send(ctx, method, path, "application/json", data, out)
+}
+
+// send issues a signed request, converting error statuses to errors.
+func (c *Client) send(ctx context.Context, method, path, contentType string, body []byte, out any) (err error) {
+	ctx, span := trace.StartClient(ctx, "OpenSearch "+method, trace.String("url.path", path))
+	defer func() { span.End(err) }()
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.signer != nil {
+		c.signer.Sign(req, aws.HashPayload(body))
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("opensearch request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("opensearch: %s %s: %w", method, path, errNotFound)
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("opensearch: %s %s: HTTP %d: %s", method, path, resp.StatusCode, bytes.TrimSpace(data))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats written by NewExporter.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// scanBatch is the number of documents Scan reads per request.
+const scanBatch = 500
+
+// csvColumns are the columns of CSV exports, named as Document's JSON
+// fields.
+var csvColumns = []string{
+	"id", "doi", "title", "languages", "creators", "orcids", "affiliations", "publicationYear", "published",
+	"resourceType", "collection", "access", "license", "subjects", "places", "awards", "version",
+	"formats", "size", "url",
+}
+
+// Scan calls fn with every dataset matching q, in ID order, until fn
+// returns an error. Unlike Search it is not limited to the first 10,000
+// matches: it pages with search_after on the dataset ID, so a dataset
+// indexed or removed while scanning is either exported once or not at
+// all. q's page, size, and relevance order are ignored.
+func (s *Searcher) Scan(ctx context.Context, q Query, fn func(*Document) error) error {
+	q.Page, q.Size = 1, MaxPageSize
+	if err := q.normalize(); err != nil {
+		return err
+	}
+	body := q.body()
+	delete(body, "aggs")
+	delete(body, "from")
+	body["size"] = scanBatch
+	body["track_total_hits"] = false
+	body["sort"] = []any{map[string]any{"id": "asc"}}
+	for {
+		var resp struct {
+			Hits struct {
+				Hits []struct {
+					Source Document `json:"_source"`
+					Sort   []any    `json:"sort"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		err := s.Index.Search(ctx, s.Alias, body, &resp)
+		if errors.Is(err, errNotFound) {
+			return ErrNoIndex
+		}
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", s.Alias, err)
+		}
+		hits := resp.Hits.Hits
+		for i := range hits {
+			if err := fn(&hits[i].Source); err != nil {
+				return err
+			}
+		}
+		if len(hits) < scanBatch {
+			return nil
+		}
+		body["search_after"] = hits[len(hits)-1].Sort
+	}
+}
+
+// Exporter writes documents in an export format.
+type Exporter interface {
+	// Write writes one document
+	Write(d *Document) error
+
+	// Close writes anything buffered; it does not close the underlying
+	// writer
+	Close() error
+}
+
+// NewExporter returns an exporter writing format to w.
+func NewExporter(format string, w io.Writer) (Exporter, error) {
+	switch format {
+	case FormatCSV:
+		return &csvExporter{w: csv.NewWriter(w)}, nil
+	case FormatJSONL:
+		return &jsonlExporter{enc: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %q: want %s or %s", format, FormatCSV, FormatJSONL)
+}
+
+// csvExporter writes a header row and one row per document.
+// Multi-valued fields are joined with "; ".
+type csvExporter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (e *csvExporter) Write(d *Document) error {
+	if !e.header {
+		e.header = true
+		if err := e.w.Write(csvColumns); err != nil {
+			return err
+		}
+	}
+	var published string
+	if d.Published != nil {
+		published = d.Published.UTC().Format(time.RFC3339)
+	}
+	join := func(v []string) string { return strings.Join(v, "; ") }
+	row := []string{
+		d.ID, d.DOI, d.Title, join(d.Languages), join(d.Creators), join(d.ORCIDs), join(d.Affiliations), itoa(d.PublicationYear), published,
+		d.ResourceType, d.Collection, d.Access, d.License, join(d.Subjects), join(d.Places), join(d.Awards), itoa(d.Version),
+		join(d.Formats), strconv.FormatInt(d.Size, 10), d.URL,
+	}
+	for i, v := range row {
+		row[i] = defuse(v)
+	}
+	return e.w.Write(row)
+}
+
+func (e *csvExporter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// itoa formats n, leaving zero blank.
+func itoa(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// defuse prefixes a value that a spreadsheet would read as a formula
+// with an apostrophe, so that opening an export cannot run one written
+// into a title by a depositor.
+func defuse(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// jsonlExporter writes one JSON document per line, without completion
+// inputs or the per-language copies of the title and description.
+type jsonlExporter struct {
+	enc *json.Encoder
+}
+
+func (e *jsonlExporter) Write(d *Document) error {
+	doc := *d
+	doc.TitleSuggest, doc.CreatorSuggest, doc.SubjectSuggest = nil, nil, nil
+	doc.TitleByLanguage, doc.DescriptionByLanguage = nil, nil
+	return e.enc.Encode(doc)
+}
+
+func (e *jsonlExporter) Close() error { return nil }
@@ -0,0 +1,140 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// Radii accepted by point-radius searches, in metres.
+const (
+	DefaultRadius = 10_000
+	MaxRadius     = 2_000_000
+)
+
+// earthRadius is the mean radius of the Earth in metres.
+const earthRadius = 6_371_008.8
+
+// circleSides is the number of sides of the polygon approximating a
+// search circle.
+const circleSides = 32
+
+// units are the distance units accepted by ParseDistance, in metres.
+var units = []struct {
+	suffix string
+	metres float64
+}{
+	{"km", 1000},
+	{"mi", 1609.344},
+	{"m", 1},
+}
+
+// Shape is a GeoJSON geometry as indexed by OpenSearch geo_shape
+// fields. Coordinates are longitude first.
+type Shape struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+func pointShape(p dataset.GeoPoint) Shape {
+	return Shape{Type: "point", Coordinates: []float64{p.Lon, p.Lat}}
+}
+
+// boxShape returns the envelope of b, given by its upper left and
+// lower right corners. OpenSearch reads an envelope whose west edge is
+// east of its east edge as crossing the antimeridian.
+func boxShape(b dataset.GeoBox) Shape {
+	return Shape{Type: "envelope", Coordinates: [][]float64{{b.West, b.North}, {b.East, b.South}}}
+}
+
+// circleShape returns a polygon approximating the circle of the given
+// radius around p. Circles that cross the antimeridian or enclose a
+// pole are approximated by their bounding box instead, which a polygon
+// cannot represent without splitting.
+func circleShape(p dataset.GeoPoint, radius float64) Shape {
+	d := radius / earthRadius
+	lat, lon := radians(p.Lat), radians(p.Lon)
+	if degrees(lat+d) > 90 || degrees(lat-d) < -90 {
+		return boxShape(circleBox(p, d))
+	}
+	ring := make([][]float64, 0, circleSides+1)
+	for i := range circleSides {
+		// Bearings decrease so that the ring runs counterclockwise,
+		// as GeoJSON requires of exterior rings.
+		bearing := -2 * math.Pi * float64(i) / circleSides
+		lat2 := math.Asin(math.Sin(lat)*math.Cos(d) + math.Cos(lat)*math.Sin(d)*math.Cos(bearing))
+		lon2 := lon + math.Atan2(math.Sin(bearing)*math.Sin(d)*math.Cos(lat), math.Cos(d)-math.Sin(lat)*math.Sin(lat2))
+		if degrees(lon2) < -180 || degrees(lon2) > 180 {
+			return boxShape(circleBox(p, d))
+		}
+		ring = append(ring, []float64{round(degrees(lon2)), round(degrees(lat2))})
+	}
+	ring = append(ring, ring[0])
+	return Shape{Type: "polygon", Coordinates: [][][]float64{ring}}
+}
+
+// circleBox returns the bounding box of the circle of angular radius d
+// around p.
+func circleBox(p dataset.GeoPoint, d float64) dataset.GeoBox {
+	b := dataset.GeoBox{South: math.Max(p.Lat-degrees(d), -90), North: math.Min(p.Lat+degrees(d), 90), West: -180, East: 180}
+	if b.South == -90 || b.North == 90 {
+		return b
+	}
+	dlon := degrees(math.Asin(math.Sin(d) / math.Cos(radians(p.Lat))))
+	b.West, b.East = wrap(p.Lon-dlon), wrap(p.Lon+dlon)
+	return b
+}
+
+// ParseDistance parses a distance such as "50km", "500m", or "10mi",
+// returning metres.
+func ParseDistance(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, u := range units {
+		if n, ok := strings.CutSuffix(s, u.suffix); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+			if err != nil || f <= 0 {
+				break
+			}
+			return f * u.metres, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid distance %q: want a positive number with a unit of m, km, or mi, e.g. 50km", s)
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
+
+// wrap returns lon within [-180, 180].
+func wrap(lon float64) float64 {
+	switch {
+	case lon < -180:
+		return lon + 360
+	case lon > 180:
+		return lon - 360
+	}
+	return lon
+}
+
+// round rounds to six decimal places, about 0.1 m, to keep requests
+// short.
+func round(f float64) float64 {
+	return math.Round(f*1e6) / 1e6
+}
@@ -0,0 +1,146 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// SuggestBudget is how long a suggest request may wait for the domain.
+// A search box completes as the user types, so a late answer is no use:
+// when the budget runs out no suggestions are returned rather than an
+// error.
+const SuggestBudget = 50 * time.Millisecond
+
+// relatedMaxAge is how long clients and the CDN may cache related
+// datasets, which change only as the catalog does.
+const relatedMaxAge = "public, max-age=3600"
+
+// suggestMaxAge is how long clients and the CDN may cache suggestions.
+const suggestMaxAge = "public, max-age=60"
+
+// Handler serves search as JSON:
+//
+//	GET /search          the Results of the query in the URL parameters (see ParseQuery)
+//	GET /suggest         completions of a prefix (see ParseSuggestQuery)
+//	GET /related/{ref}   datasets related to a published dataset, by ID or DOI (see Recommend)
+type Handler struct {
+	searcher *Searcher
+	mux      *http.ServeMux
+}
+
+// NewHandler returns a handler searching with s.
+func NewHandler(s *Searcher) *Handler {
+	h := &Handler{searcher: s, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /search", h.serveSearch)
+	h.mux.HandleFunc("GET /suggest", h.serveSuggest)
+	h.mux.HandleFunc("GET /related/{ref...}", h.serveRelated)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) serveSearch(w http.ResponseWriter, r *http.Request) {
+	q, err := ParseQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := h.searcher.Search(r.Context(), q)
+	if errors.Is(err, ErrNoIndex) {
+		http.Error(w, "search is not available", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (h *Handler) serveSuggest(w http.ResponseWriter, r *http.Request) {
+	q, err := ParseSuggestQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(r.Context(), SuggestBudget)
+	defer cancel()
+	out, err := h.searcher.Suggest(ctx, q)
+	cacheControl := suggestMaxAge
+	switch {
+	case errors.Is(err, ErrNoIndex):
+		http.Error(w, "search is not available", http.StatusServiceUnavailable)
+		return
+	case errors.Is(err, context.DeadlineExceeded) && r.Context().Err() == nil:
+		out, cacheControl = []Suggestion{}, "no-store"
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", cacheControl)
+	// Server-Timing lets the frontend and CloudFront logs measure the
+	// time spent in the domain against the budget.
+	w.Header().Set("Server-Timing", fmt.Sprintf("suggest;dur=%.1f", float64(time.Since(start).Microseconds())/1000))
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func (h *Handler) serveRelated(w http.ResponseWriter, r *http.Request) {
+	if h.searcher.Datasets == nil {
+		http.NotFound(w, r)
+		return
+	}
+	size := 0
+	if s := r.URL.Query().Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > MaxRelated {
+			http.Error(w, fmt.Sprintf("invalid size %q: must be between 1 and %d", s, MaxRelated), http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+	d, err := h.searcher.Datasets.Resolve(r.Context(), r.PathValue("ref"))
+	if errors.Is(err, dataset.ErrNotFound) || err == nil && d.State != dataset.StatePublished {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	out, err := h.searcher.Recommend(r.Context(), d, size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", relatedMaxAge)
+	_ = json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,67 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"sort"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// analyzers maps the languages whose text is analyzed with its own
+// stemming, stop words, and elision, by primary language subtag, to
+// OpenSearch's built-in analyzers. Text in other languages, or not
+// tagged with a language, is analyzed by the standard analyzer alone.
+var analyzers = map[string]string{
+	"ca": "catalan",
+	"de": "german",
+	"en": "english",
+	"es": "spanish",
+	"fr": "french",
+	"it": "italian",
+	"nl": "dutch",
+	"pt": "portuguese",
+}
+
+// Languages returns the primary language subtags analyzed for their
+// language, sorted.
+func Languages() []string {
+	out := make([]string, 0, len(analyzers))
+	for lang := range analyzers {
+		out = append(out, lang)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// languageMapping returns the mapping of an object holding a text
+// under its language subtag, each analyzed for its language.
+func languageMapping() map[string]any {
+	props := make(map[string]any, len(analyzers))
+	for lang, analyzer := range analyzers {
+		props[lang] = map[string]any{"type": "text", "analyzer": analyzer}
+	}
+	return map[string]any{"properties": props}
+}
+
+// byLanguage returns text under the primary subtag of tag, or nil if
+// the language has no analyzer.
+func byLanguage(text, tag string) map[string]string {
+	lang := dataset.PrimaryLanguage(tag)
+	if text == "" || analyzers[lang] == "" {
+		return nil
+	}
+	return map[string]string{lang: text}
+}
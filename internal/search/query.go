@@ -0,0 +1,437 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// Page sizes accepted by Search.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// maxWindow is the deepest result OpenSearch pages to by default
+// (index.max_result_window).
+const maxWindow = 10000
+
+// facets are the fields results are counted by, keyed by the name
+// reported in Results.Facets, with the number of values reported.
+var facets = []struct {
+	name, field string
+	size        int
+}{
+	{"collection", "collection", 20},
+	{"access", "access", 10},
+	{"resourceType", "resourceType", 20},
+	{"license", "license", 20},
+	{"subject", "subjects.raw", 20},
+	{"creator", "creators.raw", 20},
+	{"year", "publicationYear", 50},
+	{"language", "languages", 20},
+}
+
+// dateLayouts are the forms accepted for a date range bound.
+var dateLayouts = []string{"2006-01-02", "2006-01", "2006"}
+
+// Query is a search of the published datasets.
+type Query struct {
+	// Text is matched against titles, creators, subjects,
+	// descriptions, affiliations, places, and file names; every dataset
+	// matches if empty
+	Text string `json:"q,omitempty"`
+
+	// Creator matches datasets with a creator whose name contains the
+	// phrase
+	Creator string `json:"creator,omitempty"`
+
+	// Subject matches datasets with exactly this subject
+	Subject string `json:"subject,omitempty"`
+
+	// Language matches datasets whose title or description is tagged
+	// with this language, and matches Text with that language's
+	// analysis, e.g. stemming "datos" to match "dato"
+	Language string `json:"language,omitempty"`
+
+	// From and Until bound the publication date, inclusive, as
+	// YYYY, YYYY-MM, or YYYY-MM-DD; unbounded if empty
+	From  string `json:"from,omitempty"`
+	Until string `json:"until,omitempty"`
+
+	// BBox matches datasets with a location intersecting the box
+	BBox *dataset.GeoBox `json:"bbox,omitempty"`
+
+	// Near matches datasets with a location within Radius of the point
+	Near *dataset.GeoPoint `json:"near,omitempty"`
+
+	// Radius is the distance from Near in metres; DefaultRadius if zero
+	Radius float64 `json:"radius,omitempty"`
+
+	// License, Collection, and Access match any of the given values
+	License    []string `json:"license,omitempty"`
+	Collection []string `json:"collection,omitempty"`
+	Access     []string `json:"access,omitempty"`
+
+	// Page is the 1-based page of results; 1 if zero
+	Page int `json:"page,omitempty"`
+
+	// Size is the number of results per page; DefaultPageSize if zero
+	Size int `json:"size,omitempty"`
+}
+
+// ParseQuery reads a query from URL parameters named as Query's JSON
+// fields. Multi-valued filters may repeat or be comma-separated; bbox
+// is "west,south,east,north", near is "lat,lon", and radius is a
+// distance such as "50km".
+func ParseQuery(v url.Values) (*Query, error) {
+	q := &Query{
+		Text:       v.Get("q"),
+		Creator:    v.Get("creator"),
+		Subject:    v.Get("subject"),
+		Language:   v.Get("language"),
+		From:       v.Get("from"),
+		Until:      v.Get("until"),
+		License:    splitValues(v["license"]),
+		Collection: splitValues(v["collection"]),
+		Access:     splitValues(v["access"]),
+	}
+	var err error
+	if s := v.Get("bbox"); s != "" {
+		if q.BBox, err = dataset.ParseBox(s); err != nil {
+			return nil, err
+		}
+	}
+	if s := v.Get("near"); s != "" {
+		if q.Near, err = dataset.ParsePoint(s); err != nil {
+			return nil, err
+		}
+	}
+	if s := v.Get("radius"); s != "" {
+		if q.Radius, err = ParseDistance(s); err != nil {
+			return nil, err
+		}
+	}
+	for name, p := range map[string]*int{"page": &q.Page, "size": &q.Size} {
+		s := v.Get(name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", name, s)
+		}
+		*p = n
+	}
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// splitValues splits comma-separated values, dropping empty ones.
+func splitValues(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// normalize applies defaults and validates q.
+func (q *Query) normalize() error {
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	if q.Size == 0 {
+		q.Size = DefaultPageSize
+	}
+	if q.Page < 1 {
+		return fmt.Errorf("invalid page %d", q.Page)
+	}
+	if q.Size < 1 || q.Size > MaxPageSize {
+		return fmt.Errorf("invalid size %d: must be between 1 and %d", q.Size, MaxPageSize)
+	}
+	if q.Language != "" {
+		tag, err := dataset.NormalizeLanguage(q.Language)
+		if err != nil {
+			return err
+		}
+		q.Language = dataset.PrimaryLanguage(tag)
+	}
+	if q.Radius != 0 && q.Near == nil {
+		return fmt.Errorf("a radius needs a point to search near")
+	}
+	if q.Near != nil && q.Radius == 0 {
+		q.Radius = DefaultRadius
+	}
+	if q.Radius < 0 || q.Radius > MaxRadius {
+		return fmt.Errorf("invalid radius %gm: must be at most %dkm", q.Radius, MaxRadius/1000)
+	}
+	if q.BBox != nil {
+		if err := q.BBox.Validate(); err != nil {
+			return err
+		}
+	}
+	if q.Near != nil {
+		if err := q.Near.Validate(); err != nil {
+			return err
+		}
+	}
+	if q.Page*q.Size > maxWindow {
+		return fmt.Errorf("page %d is beyond the first %d results; narrow the search", q.Page, maxWindow)
+	}
+	var from, until time.Time
+	for _, b := range []struct {
+		name, value string
+		t           *time.Time
+	}{{"from", q.From, &from}, {"until", q.Until, &until}} {
+		if b.value == "" {
+			continue
+		}
+		t, err := parseDate(b.value)
+		if err != nil {
+			return fmt.Errorf("invalid %s date %q: want YYYY, YYYY-MM, or YYYY-MM-DD", b.name, b.value)
+		}
+		*b.t = t
+	}
+	if !from.IsZero() && !until.IsZero() && until.Before(from) {
+		return fmt.Errorf("until date %s is before from date %s", q.Until, q.From)
+	}
+	return nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("unrecognized date")
+}
+
+// roundDate returns date math rounding a validated date to its
+// precision.
+func roundDate(s string) string {
+	switch len(s) {
+	case len("2006"):
+		return s + "||/y"
+	case len("2006-01"):
+		return s + "||/M"
+	}
+	return s + "||/d"
+}
+
+// textFields returns the fields Text is matched against. Titles and
+// descriptions are matched both as analyzed for their language, which
+// stems words and ignores stop words and elisions such as the l' of
+// "l'eau", and by the standard analyzer, which matches untagged text.
+// A query for one language uses that language's fields alone.
+func (q *Query) textFields() []string {
+	byLanguage := "*"
+	if q.Language != "" {
+		byLanguage = q.Language
+	}
+	return []string{
+		"title^3", "titleByLanguage." + byLanguage + "^3", "creators^2", "subjects^2",
+		"description", "descriptionByLanguage." + byLanguage, "affiliations", "places", "files",
+	}
+}
+
+// body returns the OpenSearch request for q.
+func (q *Query) body() map[string]any {
+	var must any = map[string]any{"match_all": map[string]any{}}
+	if q.Text != "" {
+		must = map[string]any{"multi_match": map[string]any{
+			"query":  q.Text,
+			"fields": q.textFields(),
+		}}
+	}
+	filter := []any{}
+	if q.Creator != "" {
+		filter = append(filter, map[string]any{"match_phrase": map[string]any{"creators": q.Creator}})
+	}
+	if q.Subject != "" {
+		filter = append(filter, map[string]any{"term": map[string]any{"subjects.raw": q.Subject}})
+	}
+	if q.Language != "" {
+		filter = append(filter, map[string]any{"term": map[string]any{"languages": q.Language}})
+	}
+	if q.From != "" || q.Until != "" {
+		// Rounding to the date's precision makes the range cover whole
+		// days, months, or years: gte rounds down and lte rounds up.
+		r := map[string]any{}
+		if q.From != "" {
+			r["gte"] = roundDate(q.From)
+		}
+		if q.Until != "" {
+			r["lte"] = roundDate(q.Until)
+		}
+		filter = append(filter, map[string]any{"range": map[string]any{"published": r}})
+	}
+	if q.BBox != nil {
+		filter = append(filter, geoFilter(boxShape(*q.BBox)))
+	}
+	if q.Near != nil {
+		filter = append(filter, geoFilter(circleShape(*q.Near, q.Radius)))
+	}
+	for _, t := range []struct {
+		field  string
+		values []string
+	}{{"license", q.License}, {"collection", q.Collection}, {"access", q.Access}} {
+		if len(t.values) > 0 {
+			filter = append(filter, map[string]any{"terms": map[string]any{t.field: t.values}})
+		}
+	}
+
+	aggs := map[string]any{}
+	for _, f := range facets {
+		aggs[f.name] = map[string]any{"terms": map[string]any{"field": f.field, "size": f.size}}
+	}
+	body := map[string]any{
+		"query":            map[string]any{"bool": map[string]any{"must": must, "filter": filter}},
+		"aggs":             aggs,
+		"from":             (q.Page - 1) * q.Size,
+		"size":             q.Size,
+		"track_total_hits": true,
+		// Completion inputs are only used to suggest.
+		"_source": map[string]any{"excludes": suggestFieldNames()},
+	}
+	if q.Text == "" {
+		// Without a relevance score, newest first.
+		body["sort"] = []any{
+			map[string]any{"published": map[string]any{"order": "desc", "missing": "_last"}},
+			map[string]any{"id": "asc"},
+		}
+	}
+	return body
+}
+
+// geoFilter matches datasets with a location intersecting shape.
+func geoFilter(shape Shape) map[string]any {
+	return map[string]any{"geo_shape": map[string]any{"locations": map[string]any{"shape": shape, "relation": "intersects"}}}
+}
+
+// Results is a page of search results.
+type Results struct {
+	// Total is the number of matching datasets
+	Total int `json:"total"`
+
+	// Page and Size are the page returned and the page size
+	Page int `json:"page"`
+	Size int `json:"size"`
+
+	// Hits are the datasets on the page, best match first
+	Hits []Hit `json:"hits"`
+
+	// Facets count the matching datasets by each facet's values, most
+	// frequent first
+	Facets map[string][]Bucket `json:"facets"`
+}
+
+// Hit is a matching dataset.
+type Hit struct {
+	// Score is the relevance score; zero when results are sorted by
+	// date
+	Score float64 `json:"score,omitempty"`
+
+	Document
+}
+
+// Bucket is the number of matching datasets with a facet value.
+type Bucket struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Searcher searches the published datasets.
+type Searcher struct {
+	// Index is the search domain
+	Index Index
+
+	// Alias is the alias the index is read through
+	Alias string
+
+	// Datasets is the catalog related datasets are found in by
+	// co-citation; Recommend uses the index alone if nil
+	Datasets *dataset.Store
+}
+
+// Search returns the page of datasets matching q. It returns
+// ErrNoIndex if the index has not been built.
+func (s *Searcher) Search(ctx context.Context, q *Query) (*Results, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Score  *float64 `json:"_score"`
+				Source Document `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations map[string]struct {
+			Buckets []struct {
+				Key      json.RawMessage `json:"key"`
+				DocCount int             `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"aggregations"`
+	}
+	err := s.Index.Search(ctx, s.Alias, q.body(), &resp)
+	if errors.Is(err, errNotFound) {
+		return nil, ErrNoIndex
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %s: %w", s.Alias, err)
+	}
+
+	res := &Results{Total: resp.Hits.Total.Value, Page: q.Page, Size: q.Size, Hits: []Hit{}, Facets: map[string][]Bucket{}}
+	for _, h := range resp.Hits.Hits {
+		hit := Hit{Document: h.Source}
+		if h.Score != nil {
+			hit.Score = *h.Score
+		}
+		res.Hits = append(res.Hits, hit)
+	}
+	for _, f := range facets {
+		buckets := []Bucket{}
+		for _, b := range resp.Aggregations[f.name].Buckets {
+			// Numeric facets such as year have numeric keys.
+			var value string
+			if err := json.Unmarshal(b.Key, &value); err != nil {
+				value = string(b.Key)
+			}
+			buckets = append(buckets, Bucket{Value: value, Count: b.DocCount})
+		}
+		res.Facets[f.name] = buckets
+	}
+	return res, nil
+}
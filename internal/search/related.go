@@ -0,0 +1,271 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+)
+
+// Numbers of related datasets accepted by Recommend.
+const (
+	DefaultRelated = 5
+	MaxRelated     = 20
+)
+
+// Weights of the evidence that two datasets are related. Metadata
+// similarity contributes up to weightSimilar, scaled by the best match;
+// each related work both datasets share adds weightShared; and a
+// relation from one dataset to the other adds weightLinked.
+const (
+	weightSimilar = 1.0
+	weightShared  = 0.5
+	weightLinked  = 1.0
+)
+
+// Reasons a dataset is recommended.
+const (
+	ReasonSimilar = "similar"
+	ReasonCocited = "cocited"
+	ReasonLinked  = "linked"
+)
+
+// likeFields are the fields compared by the more-like-this query.
+var likeFields = []string{"title", "description", "subjects", "creators", "places"}
+
+// Recommendation is a dataset related to another.
+type Recommendation struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	DOI   string `json:"doi,omitempty"`
+
+	// Path is the URL path of the dataset's landing page
+	Path string `json:"path"`
+
+	// Score ranks the recommendation; higher is better
+	Score float64 `json:"score"`
+
+	// Reasons lists ReasonSimilar, ReasonCocited, and ReasonLinked as
+	// they apply
+	Reasons []string `json:"reasons"`
+
+	// Shared is the number of related works both datasets share
+	Shared int `json:"shared,omitempty"`
+}
+
+// Recommend returns up to size published datasets related to d, best
+// first. Datasets with similar titles, descriptions, subjects, creators,
+// and places are found with a more-like-this query; datasets related to
+// the same works as d (co-cited, e.g. by one paper) and datasets linked
+// to or from d are found in the catalog. Without an index, only the
+// catalog is used.
+func (s *Searcher) Recommend(ctx context.Context, d *dataset.Dataset, size int) ([]Recommendation, error) {
+	if size == 0 {
+		size = DefaultRelated
+	}
+	if size < 1 || size > MaxRelated {
+		return nil, fmt.Errorf("invalid size %d: must be between 1 and %d", size, MaxRelated)
+	}
+	recs := map[string]*Recommendation{}
+	add := func(id, title, doi string, score float64, reason string) *Recommendation {
+		r, ok := recs[id]
+		if !ok {
+			r = &Recommendation{ID: id, Title: title, DOI: doi, Path: landing.PagePath(id)}
+			recs[id] = r
+		}
+		r.Score += score
+		r.Reasons = append(r.Reasons, reason)
+		return r
+	}
+
+	if s.Index != nil {
+		hits, err := s.moreLikeThis(ctx, d, size)
+		if err != nil && !errors.Is(err, ErrNoIndex) {
+			return nil, err
+		}
+		hits = slices.DeleteFunc(hits, func(h Hit) bool { return h.ID == d.ID })
+		var best float64
+		for _, h := range hits {
+			best = max(best, h.Score)
+		}
+		for _, h := range hits {
+			if best > 0 {
+				add(h.ID, h.Title, h.DOI, weightSimilar*h.Score/best, ReasonSimilar)
+			}
+		}
+	}
+
+	if s.Datasets != nil {
+		all, err := s.Datasets.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		works := relatedKeys(d)
+		own := identifierKeys(d)
+		for _, o := range all {
+			if o.ID == d.ID || o.State != dataset.StatePublished {
+				continue
+			}
+			shared := 0
+			for k := range relatedKeys(o) {
+				if works[k] {
+					shared++
+				}
+			}
+			if shared > 0 {
+				add(o.ID, o.Title, o.DOI, weightShared*float64(shared), ReasonCocited).Shared = shared
+			}
+			if linked(works, identifierKeys(o)) || linked(relatedKeys(o), own) {
+				add(o.ID, o.Title, o.DOI, weightLinked, ReasonLinked)
+			}
+		}
+	}
+
+	out := make([]Recommendation, 0, len(recs))
+	for _, r := range recs {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].ID < out[j].ID
+	})
+	if len(out) > size {
+		out = out[:size]
+	}
+	return out, nil
+}
+
+// Related implements landing.RelatedSource, linking the default number
+// of recommendations from landing pages. If the index cannot be
+// searched, pages link the datasets found in the catalog alone rather
+// than failing to render; the next rebuild after the index recovers
+// adds the similar ones.
+func (s *Searcher) Related(ctx context.Context, d *dataset.Dataset) ([]landing.Related, error) {
+	recs, err := s.Recommend(ctx, d, DefaultRelated)
+	if err != nil && s.Index != nil && s.Datasets != nil {
+		catalog := &Searcher{Datasets: s.Datasets}
+		recs, err = catalog.Recommend(ctx, d, DefaultRelated)
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := make([]landing.Related, len(recs))
+	for i, r := range recs {
+		out[i] = landing.Related{Title: r.Title, URL: r.Path}
+	}
+	return out, nil
+}
+
+// moreLikeThis returns the indexed datasets whose metadata is most like
+// d's. d is given as an artificial document, so drafts have
+// recommendations too.
+func (s *Searcher) moreLikeThis(ctx context.Context, d *dataset.Dataset, size int) ([]Hit, error) {
+	doc := NewDocument(d, "")
+	like := map[string]any{}
+	if doc.Title != "" {
+		like["title"] = doc.Title
+	}
+	if doc.Description != "" {
+		like["description"] = doc.Description
+	}
+	for field, values := range map[string][]string{"subjects": doc.Subjects, "creators": doc.Creators, "places": doc.Places} {
+		if len(values) > 0 {
+			like[field] = values
+		}
+	}
+	if len(like) == 0 {
+		return nil, nil
+	}
+	body := map[string]any{
+		"size":    size + 1,
+		"_source": []string{"id", "title", "doi"},
+		"query": map[string]any{"bool": map[string]any{
+			"must": map[string]any{"more_like_this": map[string]any{
+				"fields": likeFields,
+				"like":   []any{map[string]any{"doc": like}},
+				// A catalog is small next to the corpora MLT's defaults
+				// are tuned for; a term used once is still telling.
+				"min_term_freq":   1,
+				"min_doc_freq":    1,
+				"max_query_terms": 25,
+			}},
+			"must_not": map[string]any{"ids": map[string]any{"values": []string{d.ID}}},
+		}},
+	}
+	var resp struct {
+		Hits struct {
+			Hits []struct {
+				Score  float64  `json:"_score"`
+				Source Document `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	err := s.Index.Search(ctx, s.Alias, body, &resp)
+	if errors.Is(err, errNotFound) {
+		return nil, ErrNoIndex
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find datasets like %s: %w", d.ID, err)
+	}
+	hits := make([]Hit, 0, len(resp.Hits.Hits))
+	for _, h := range resp.Hits.Hits {
+		hits = append(hits, Hit{Score: h.Score, Document: h.Source})
+	}
+	return hits, nil
+}
+
+// relatedKeys returns the keys of the works d is related to.
+func relatedKeys(d *dataset.Dataset) map[string]bool {
+	keys := make(map[string]bool, len(d.Related))
+	for _, r := range d.Related {
+		keys[r.Key()] = true
+	}
+	return keys
+}
+
+// identifierKeys returns the keys of d's own identifiers, as another
+// dataset's related identifiers would name them.
+func identifierKeys(d *dataset.Dataset) map[string]bool {
+	keys := map[string]bool{}
+	for _, doi := range d.DOIs() {
+		keys[dataset.RelatedIdentifier{Type: "DOI", Identifier: doi}.Key()] = true
+	}
+	if d.ARK != "" {
+		keys[dataset.RelatedIdentifier{Type: "ARK", Identifier: d.ARK}.Key()] = true
+	}
+	if d.Handle != "" {
+		keys[dataset.RelatedIdentifier{Type: "Handle", Identifier: d.Handle}.Key()] = true
+	}
+	return keys
+}
+
+// linked reports whether any of the related works is one of the
+// identifiers.
+func linked(works, ids map[string]bool) bool {
+	for k := range works {
+		if ids[k] {
+			return true
+		}
+	}
+	return false
+}
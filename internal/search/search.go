@@ -0,0 +1,585 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package search keeps an OpenSearch index of published datasets for
+// discovery.
+//
+// Writers and readers use an alias. Each index behind it is named
+// after the alias, the mapping version, and its creation time, e.g.
+// aperture-prod-datasets-v2-20250501120000, so a rebuild can load a new
+// index, with a new mapping if it changed, while the old one serves,
+// then swap the alias to it atomically.
+//
+// The Indexer observes the dataset store and updates the index on every
+// change: published datasets are indexed, and drafts, tombstoned, and
+// deleted datasets are removed. A change that cannot be indexed is
+// recorded as pending and retried later, so an unavailable domain never
+// fails a deposit. Where a worker does the indexing, Queued observes the
+// store instead and queues the ID of each changed dataset for the
+// Indexer's Handler.
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/metrics"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/queue"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/trace"
+)
+
+// MappingVersion is the version of Mapping. Increment it whenever the
+// mapping changes; the next rebuild creates an index with the new
+// mapping.
+const MappingVersion = 5
+
+// pendingTable holds Pending changes keyed by dataset ID.
+const pendingTable = "search-pending"
+
+// ErrNoIndex is returned when the alias does not exist yet.
+var ErrNoIndex = errors.New("search index not created; run 'aperture index rebuild'")
+
+// Document is the indexed form of a published dataset.
+type Document struct {
+	ID          string `json:"id"`
+	Identifier  string `json:"identifier,omitempty"`
+	DOI         string `json:"doi,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+
+	// TitleByLanguage and DescriptionByLanguage repeat the title and
+	// description under their language subtag, to be analyzed for that
+	// language; nil if untagged or in a language without an analyzer
+	TitleByLanguage       map[string]string `json:"titleByLanguage,omitempty"`
+	DescriptionByLanguage map[string]string `json:"descriptionByLanguage,omitempty"`
+
+	// Languages are the primary subtags of the title's and
+	// description's languages
+	Languages []string `json:"languages,omitempty"`
+
+	Creators        []string   `json:"creators,omitempty"`
+	ORCIDs          []string   `json:"orcids,omitempty"`
+	Affiliations    []string   `json:"affiliations,omitempty"`
+	RORs            []string   `json:"rors,omitempty"`
+	PublicationYear int        `json:"publicationYear,omitempty"`
+	Collection      string     `json:"collection,omitempty"`
+	ResourceType    string     `json:"resourceType"`
+	Access          string     `json:"access"`
+	Awards          []string   `json:"awards,omitempty"`
+	Subjects        []string   `json:"subjects,omitempty"`
+	License         string     `json:"license,omitempty"`
+	Places          []string   `json:"places,omitempty"`
+	Locations       []Shape    `json:"locations,omitempty"`
+	Version         int        `json:"version,omitempty"`
+	Files           []string   `json:"files,omitempty"`
+	Formats         []string   `json:"formats,omitempty"`
+	Size            int64      `json:"size"`
+	Published       *time.Time `json:"published,omitempty"`
+	Updated         time.Time  `json:"updated"`
+	URL             string     `json:"url"`
+
+	TitleSuggest   []Completion `json:"titleSuggest,omitempty"`
+	CreatorSuggest []Completion `json:"creatorSuggest,omitempty"`
+	SubjectSuggest []Completion `json:"subjectSuggest,omitempty"`
+}
+
+// NewDocument returns the document indexing d, whose landing page is
+// under siteURL.
+func NewDocument(d *dataset.Dataset, siteURL string) Document {
+	doc := Document{
+		ID:              d.ID,
+		Identifier:      pid.Identifier(d),
+		DOI:             d.DOI,
+		Title:           d.Title,
+		Description:     d.Description,
+		PublicationYear: d.PublicationYear,
+		Collection:      d.Collection,
+		ResourceType:    d.ResourceType,
+		Access:          string(d.Access),
+		Awards:          d.Awards,
+		Subjects:        d.Subjects,
+		License:         d.License,
+		Updated:         d.UpdatedAt.UTC(),
+		URL:             strings.TrimRight(siteURL, "/") + landing.PagePath(d.ID),
+	}
+	if doc.ResourceType == "" {
+		doc.ResourceType = "Dataset"
+	}
+	doc.TitleByLanguage = byLanguage(d.Title, d.TitleLanguage)
+	doc.DescriptionByLanguage = byLanguage(d.Description, d.DescriptionLanguage)
+	for _, tag := range []string{d.TitleLanguage, d.DescriptionLanguage} {
+		if lang := dataset.PrimaryLanguage(tag); lang != "" && !slices.Contains(doc.Languages, lang) {
+			doc.Languages = append(doc.Languages, lang)
+		}
+	}
+	if doc.License == "" && d.Software != nil {
+		doc.License = d.Software.License
+	}
+	for _, l := range d.GeoLocations {
+		if l.Place != "" {
+			doc.Places = append(doc.Places, l.Place)
+		}
+		if l.Point != nil {
+			doc.Locations = append(doc.Locations, pointShape(*l.Point))
+		}
+		if l.Box != nil {
+			doc.Locations = append(doc.Locations, boxShape(*l.Box))
+		}
+	}
+	for _, c := range d.Creators {
+		doc.Creators = append(doc.Creators, c.Name)
+		if c.ORCID != "" {
+			doc.ORCIDs = append(doc.ORCIDs, c.ORCID)
+		}
+		if c.Affiliation != "" {
+			doc.Affiliations = append(doc.Affiliations, c.Affiliation)
+		}
+		if c.AffiliationROR != "" {
+			doc.RORs = append(doc.RORs, c.AffiliationROR)
+		}
+	}
+	if v := d.Current(); v != nil {
+		doc.Version = v.Number
+		if v.PublishedAt != nil {
+			published := v.PublishedAt.UTC()
+			doc.Published = &published
+		}
+		formats := make(map[string]bool)
+		for _, f := range v.Files {
+			doc.Files = append(doc.Files, f.Path)
+			doc.Size += f.Size
+			if mt, _, _ := strings.Cut(f.ContentType, ";"); mt != "" && !formats[mt] {
+				formats[mt] = true
+				doc.Formats = append(doc.Formats, strings.TrimSpace(mt))
+			}
+		}
+		sort.Strings(doc.Formats)
+	}
+	doc.TitleSuggest = titleCompletions(doc.Title)
+	doc.CreatorSuggest = creatorCompletions(doc.Creators)
+	doc.SubjectSuggest = subjectCompletions(doc.Subjects)
+	return doc
+}
+
+// Mapping returns the settings and mappings of a new index. Mappings
+// are strict, so a document that no longer matches fails to index
+// rather than silently adding fields.
+func Mapping() map[string]any {
+	text := func() map[string]any {
+		return map[string]any{"type": "text", "fields": map[string]any{"raw": map[string]any{"type": "keyword", "ignore_above": 256}}}
+	}
+	keyword := map[string]any{"type": "keyword"}
+	// The standard analyzer keeps digits, which the default simple
+	// analyzer drops, so that "2020" completes "2020 census".
+	completion := map[string]any{"type": "completion", "analyzer": "standard"}
+	return map[string]any{
+		"settings": map[string]any{
+			"number_of_shards": 1,
+		},
+		"mappings": map[string]any{
+			"dynamic": "strict",
+			"_meta":   map[string]any{"version": MappingVersion},
+			"properties": map[string]any{
+				"id":                    keyword,
+				"identifier":            keyword,
+				"doi":                   keyword,
+				"title":                 text(),
+				"description":           map[string]any{"type": "text"},
+				"titleByLanguage":       languageMapping(),
+				"descriptionByLanguage": languageMapping(),
+				"languages":             keyword,
+				"creators":              text(),
+				"orcids":                keyword,
+				"affiliations":          text(),
+				"rors":                  keyword,
+				"publicationYear":       map[string]any{"type": "integer"},
+				"collection":            keyword,
+				"resourceType":          keyword,
+				"access":                keyword,
+				"awards":                keyword,
+				"subjects":              text(),
+				"license":               keyword,
+				"places":                text(),
+				"locations":             map[string]any{"type": "geo_shape"},
+				"version":               map[string]any{"type": "integer"},
+				"files":                 text(),
+				"formats":               keyword,
+				"size":                  map[string]any{"type": "long"},
+				"published":             map[string]any{"type": "date"},
+				"updated":               map[string]any{"type": "date"},
+				"url":                   map[string]any{"type": "keyword", "index": false},
+				"titleSuggest":          completion,
+				"creatorSuggest":        completion,
+				"subjectSuggest":        completion,
+			},
+		},
+	}
+}
+
+// Pending is a change that could not be indexed.
+type Pending struct {
+	DatasetID string    `json:"datasetId"`
+	Error     string    `json:"error"`
+	Time      time.Time `json:"time"`
+}
+
+// Index is the index API the Indexer and Searcher use. *Client
+// implements it.
+type Index interface {
+	CreateIndex(ctx context.Context, index string, body any) error
+	DeleteIndex(ctx context.Context, index string) error
+	Put(ctx context.Context, index, id string, doc any) error
+	Delete(ctx context.Context, index, id string) error
+	Count(ctx context.Context, index string) (int, error)
+	Bulk(ctx context.Context, index string, docs map[string]any) error
+	AliasTargets(ctx context.Context, alias string) ([]string, error)
+	SwapAlias(ctx context.Context, alias, index string, old []string) error
+	Search(ctx context.Context, index string, body, out any) error
+}
+
+// Indexer keeps the index in step with the dataset catalog. It
+// implements dataset.Observer.
+type Indexer struct {
+	// Index is the search domain
+	Index Index
+
+	// Alias is the alias readers and writers use
+	Alias string
+
+	// State records pending changes
+	State state.Store
+
+	// SiteURL is the public base URL of landing pages
+	SiteURL string
+
+	// Metrics records the depth of the pending change queue on each
+	// retry; skipped if nil
+	Metrics metrics.Recorder
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+
+	mu     sync.Mutex
+	exists bool
+}
+
+// Saved implements dataset.Observer.
+func (x *Indexer) Saved(ctx context.Context, d *dataset.Dataset) {
+	ctx, span := trace.Start(ctx, "search.index", trace.String("dataset.id", d.ID))
+	err := x.update(ctx, d)
+	span.End(err)
+	x.apply(ctx, d.ID, err)
+}
+
+// Deleted implements dataset.Observer.
+func (x *Indexer) Deleted(ctx context.Context, id string) {
+	ctx, span := trace.Start(ctx, "search.remove", trace.String("dataset.id", id))
+	err := x.remove(ctx, id)
+	span.End(err)
+	x.apply(ctx, id, err)
+}
+
+// apply records the outcome of indexing a change to dataset id: a
+// failure is left pending, and a success clears an earlier failure.
+// Failing to record the outcome is ignored; the next rebuild repairs
+// the index regardless.
+func (x *Indexer) apply(ctx context.Context, id string, err error) {
+	if err == nil {
+		_ = x.State.Delete(ctx, pendingTable, id)
+		return
+	}
+	_ = x.State.Put(ctx, pendingTable, id, Pending{DatasetID: id, Error: err.Error(), Time: x.now().UTC()})
+}
+
+// indexMessage is a message of the index queue.
+type indexMessage struct {
+	DatasetID string `json:"datasetId"`
+}
+
+// Queued observes the dataset store in place of an Indexer whose
+// changes a worker indexes: it queues the ID of each changed dataset. A
+// change that cannot be queued is recorded as pending with the Indexer,
+// as if indexing it had failed.
+type Queued struct {
+	Queue   queue.Queue
+	Indexer *Indexer
+}
+
+// Saved implements dataset.Observer.
+func (q *Queued) Saved(ctx context.Context, d *dataset.Dataset) {
+	q.send(ctx, d.ID)
+}
+
+// Deleted implements dataset.Observer.
+func (q *Queued) Deleted(ctx context.Context, id string) {
+	q.send(ctx, id)
+}
+
+func (q *Queued) send(ctx context.Context, id string) {
+	body, err := json.Marshal(indexMessage{DatasetID: id})
+	if err == nil {
+		err = q.Queue.Send(ctx, queue.TopicIndex, body)
+	}
+	if err != nil {
+		q.Indexer.apply(ctx, id, fmt.Errorf("failed to queue: %w", err))
+	}
+}
+
+// Handler returns a handler of the index queue that indexes the dataset
+// each message names as it is now in datasets, or removes it if it was
+// deleted. A failure is recorded as pending, and returned so that the
+// message is retried.
+func (x *Indexer) Handler(datasets *dataset.Store) queue.Handler {
+	return func(ctx context.Context, body []byte) error {
+		var m indexMessage
+		if err := json.Unmarshal(body, &m); err != nil || m.DatasetID == "" {
+			return fmt.Errorf("invalid index message %q", body)
+		}
+		d, err := datasets.Get(ctx, m.DatasetID)
+		switch {
+		case errors.Is(err, dataset.ErrNotFound):
+			ctx, span := trace.Start(ctx, "search.remove", trace.String("dataset.id", m.DatasetID))
+			err = x.remove(ctx, m.DatasetID)
+			span.End(err)
+		case err != nil:
+			return err
+		default:
+			ctx, span := trace.Start(ctx, "search.index", trace.String("dataset.id", m.DatasetID))
+			err = x.update(ctx, d)
+			span.End(err)
+		}
+		x.apply(ctx, m.DatasetID, err)
+		return err
+	}
+}
+
+// update indexes d if it is published and removes it otherwise.
+func (x *Indexer) update(ctx context.Context, d *dataset.Dataset) error {
+	if err := x.ready(ctx); err != nil {
+		return err
+	}
+	if d.State != dataset.StatePublished {
+		return x.Index.Delete(ctx, x.Alias, d.ID)
+	}
+	return x.Index.Put(ctx, x.Alias, d.ID, NewDocument(d, x.SiteURL))
+}
+
+func (x *Indexer) remove(ctx context.Context, id string) error {
+	if err := x.ready(ctx); err != nil {
+		return err
+	}
+	return x.Index.Delete(ctx, x.Alias, id)
+}
+
+// ready returns ErrNoIndex if the alias does not exist. Writing to a
+// missing alias would create an index of that name with a guessed
+// mapping, which a rebuild could then not replace with an alias.
+func (x *Indexer) ready(ctx context.Context) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.exists {
+		return nil
+	}
+	targets, err := x.Index.AliasTargets(ctx, x.Alias)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return ErrNoIndex
+	}
+	x.exists = true
+	return nil
+}
+
+// PendingChanges returns the changes not yet indexed, oldest first.
+func (x *Indexer) PendingChanges(ctx context.Context) ([]Pending, error) {
+	pending, err := state.List[Pending](ctx, x.State, pendingTable)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Time.Before(pending[j].Time) })
+	return pending, nil
+}
+
+// Retry re-indexes the datasets with pending changes, returning those
+// still pending.
+func (x *Indexer) Retry(ctx context.Context, datasets *dataset.Store) ([]Pending, error) {
+	pending, err := x.PendingChanges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pending {
+		d, err := datasets.Get(ctx, p.DatasetID)
+		switch {
+		case errors.Is(err, dataset.ErrNotFound):
+			x.Deleted(ctx, p.DatasetID)
+		case err != nil:
+			return nil, err
+		default:
+			x.Saved(ctx, d)
+		}
+	}
+	pending, err = x.PendingChanges(ctx)
+	if err == nil && x.Metrics != nil {
+		x.Metrics.Record(metrics.Dimensions{"Queue": pendingTable}, metrics.Metric{Name: metrics.QueueDepth, Value: float64(len(pending)), Unit: metrics.Count})
+	}
+	return pending, err
+}
+
+// RebuildResult summarizes a rebuild.
+type RebuildResult struct {
+	// Index is the new index behind the alias
+	Index string `json:"index"`
+
+	// Documents is the number of datasets indexed
+	Documents int `json:"documents"`
+
+	// Removed lists the indices the alias pointed to before
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Rebuild indexes every published dataset into a new index with the
+// current mapping, points the alias at it, and deletes the indices it
+// replaced. Changes made while the new index loads are caught up
+// before the swap completes. With dryRun, nothing is created.
+func (x *Indexer) Rebuild(ctx context.Context, datasets *dataset.Store, dryRun bool) (*RebuildResult, error) {
+	start := x.now().UTC()
+	res := &RebuildResult{Index: fmt.Sprintf("%s-v%d-%s", x.Alias, MappingVersion, start.Format("20060102150405"))}
+	all, err := datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]any)
+	for _, d := range all {
+		if d.State == dataset.StatePublished {
+			docs[d.ID] = NewDocument(d, x.SiteURL)
+		}
+	}
+	res.Documents = len(docs)
+	if dryRun {
+		return res, nil
+	}
+
+	old, err := x.Index.AliasTargets(ctx, x.Alias)
+	if err != nil {
+		return nil, err
+	}
+	if err := x.Index.CreateIndex(ctx, res.Index, Mapping()); err != nil {
+		return nil, fmt.Errorf("failed to create index %s: %w", res.Index, err)
+	}
+	if len(docs) > 0 {
+		if err := x.Index.Bulk(ctx, res.Index, docs); err != nil {
+			return nil, fmt.Errorf("failed to load index %s: %w", res.Index, err)
+		}
+	}
+	if err := x.Index.SwapAlias(ctx, x.Alias, res.Index, old); err != nil {
+		return nil, fmt.Errorf("failed to point %s at %s: %w", x.Alias, res.Index, err)
+	}
+	x.mu.Lock()
+	x.exists = true
+	x.mu.Unlock()
+
+	// Changes written through the alias while the new index loaded went
+	// to the old one; they are applied again to the new one.
+	now, err := datasets.List(ctx)
+	if err != nil {
+		return res, err
+	}
+	seen := make(map[string]bool, len(now))
+	for _, d := range now {
+		seen[d.ID] = true
+		if !d.UpdatedAt.Before(start) {
+			x.Saved(ctx, d)
+		}
+	}
+	for _, d := range all {
+		if !seen[d.ID] {
+			x.Deleted(ctx, d.ID)
+		}
+	}
+
+	// The rebuilt index reflects every pending change.
+	pending, err := x.PendingChanges(ctx)
+	if err != nil {
+		return res, err
+	}
+	for _, p := range pending {
+		if !p.Time.Before(start) {
+			continue
+		}
+		if err := x.State.Delete(ctx, pendingTable, p.DatasetID); err != nil {
+			return res, err
+		}
+	}
+
+	for _, o := range old {
+		if err := x.Index.DeleteIndex(ctx, o); err != nil {
+			return res, fmt.Errorf("failed to delete replaced index %s: %w", o, err)
+		}
+		res.Removed = append(res.Removed, o)
+	}
+	return res, nil
+}
+
+// Status describes the index.
+type Status struct {
+	Alias     string   `json:"alias"`
+	Indices   []string `json:"indices"`
+	Documents int      `json:"documents"`
+	Pending   int      `json:"pending"`
+
+	// Current reports whether the alias's index has the current
+	// mapping version
+	Current bool `json:"current"`
+}
+
+// Status returns the state of the alias and its index.
+func (x *Indexer) Status(ctx context.Context) (*Status, error) {
+	st := &Status{Alias: x.Alias}
+	var err error
+	if st.Indices, err = x.Index.AliasTargets(ctx, x.Alias); err != nil {
+		return nil, err
+	}
+	if len(st.Indices) > 0 {
+		if st.Documents, err = x.Index.Count(ctx, x.Alias); err != nil {
+			return nil, err
+		}
+		st.Current = strings.HasPrefix(st.Indices[0], x.Alias+"-v"+strconv.Itoa(MappingVersion)+"-")
+	}
+	pending, err := x.PendingChanges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	st.Pending = len(pending)
+	return st, nil
+}
+
+func (x *Indexer) now() time.Time {
+	if x.Now != nil {
+		return x.Now()
+	}
+	return time.Now()
+}
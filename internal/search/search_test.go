@@ -0,0 +1,977 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/queue"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// fakeDomain is an in-memory OpenSearch domain serving the APIs the
+// client uses.
+type fakeDomain struct {
+	mu      sync.Mutex
+	indices map[string]map[string]Document
+	aliases map[string]string
+	down    bool
+
+	// search is the body of the last search request
+	search map[string]any
+}
+
+func newDomain(t *testing.T) (*fakeDomain, *Client) {
+	t.Helper()
+	f := &fakeDomain{indices: map[string]map[string]Document{}, aliases: map[string]string{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, NewClient(ClientOptions{Endpoint: srv.URL})
+}
+
+func (f *fakeDomain) resolve(name string) string {
+	if index, ok := f.aliases[name]; ok {
+		return index
+	}
+	return name
+}
+
+// moreLikeThis returns the title of the artificial document of a
+// more-like-this query, or nil if body is not one.
+func moreLikeThis(body map[string]any) []string {
+	b, _ := json.Marshal(body)
+	var q struct {
+		Query struct {
+			Bool struct {
+				Must struct {
+					MLT *struct {
+						Like []struct {
+							Doc struct {
+								Title string `json:"title"`
+							} `json:"doc"`
+						} `json:"like"`
+					} `json:"more_like_this"`
+				} `json:"must"`
+			} `json:"bool"`
+		} `json:"query"`
+	}
+	_ = json.Unmarshal(b, &q)
+	if q.Query.Bool.Must.MLT == nil {
+		return nil
+	}
+	return strings.Fields(strings.ToLower(q.Query.Bool.Must.MLT.Like[0].Doc.Title))
+}
+
+// like answers a more-like-this query, scoring documents by the number
+// of title words they share with the artificial document.
+func (f *fakeDomain) like(docs map[string]Document, words []string) map[string]any {
+	hits := []map[string]any{}
+	for id, doc := range docs {
+		score := 0
+		for _, w := range strings.Fields(strings.ToLower(doc.Title)) {
+			if slices.Contains(words, w) {
+				score++
+			}
+		}
+		if score > 0 {
+			hits = append(hits, map[string]any{"_id": id, "_score": score, "_source": doc})
+		}
+	}
+	return map[string]any{"hits": map[string]any{"hits": hits}}
+}
+
+// suggest answers completion suggesters by matching each input's
+// start, case-insensitively, scoring by weight.
+func (f *fakeDomain) suggest(docs map[string]Document, suggesters map[string]any) map[string]any {
+	out := map[string]any{}
+	ids := make([]string, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for name, v := range suggesters {
+		req := v.(map[string]any)
+		prefix := strings.ToLower(req["prefix"].(string))
+		field := req["completion"].(map[string]any)["field"].(string)
+		options := []map[string]any{}
+		for _, id := range ids {
+			var completions []Completion
+			switch field {
+			case "titleSuggest":
+				completions = docs[id].TitleSuggest
+			case "creatorSuggest":
+				completions = docs[id].CreatorSuggest
+			case "subjectSuggest":
+				completions = docs[id].SubjectSuggest
+			}
+			for _, c := range completions {
+				for _, in := range c.Input {
+					if strings.HasPrefix(strings.ToLower(in), prefix) {
+						options = append(options, map[string]any{"text": in, "_id": id, "_score": c.Weight, "_source": docs[id]})
+					}
+				}
+			}
+		}
+		out[name] = []map[string]any{{"text": req["prefix"], "options": options}}
+	}
+	return out
+}
+
+func (f *fakeDomain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && parts[0] == "_alias":
+		index, ok := f.aliases[parts[1]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{%q: {"aliases": {%q: {}}}}`, index, parts[1])
+	case r.Method == http.MethodPost && parts[0] == "_aliases":
+		var req struct {
+			Actions []map[string]struct{ Index, Alias string } `json:"actions"`
+		}
+		_ = json.Unmarshal(body, &req)
+		for _, a := range req.Actions {
+			if add, ok := a["add"]; ok {
+				f.aliases[add.Alias] = add.Index
+			}
+		}
+		fmt.Fprint(w, `{"acknowledged": true}`)
+	case r.Method == http.MethodPost && parts[0] == "_bulk":
+		sc := bufio.NewScanner(bytes.NewReader(body))
+		for sc.Scan() {
+			var action struct {
+				Index struct {
+					Index string `json:"_index"`
+					ID    string `json:"_id"`
+				} `json:"index"`
+			}
+			_ = json.Unmarshal(sc.Bytes(), &action)
+			sc.Scan()
+			var doc Document
+			_ = json.Unmarshal(sc.Bytes(), &doc)
+			f.indices[action.Index.Index][action.Index.ID] = doc
+		}
+		fmt.Fprint(w, `{"errors": false, "items": []}`)
+	case len(parts) == 1 && r.Method == http.MethodPut:
+		f.indices[parts[0]] = map[string]Document{}
+		fmt.Fprint(w, `{"acknowledged": true}`)
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		delete(f.indices, parts[0])
+		fmt.Fprint(w, `{"acknowledged": true}`)
+	case len(parts) == 2 && parts[1] == "_search":
+		docs, ok := f.indices[f.resolve(parts[0])]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		f.search = nil
+		_ = json.Unmarshal(body, &f.search)
+		if like := moreLikeThis(f.search); like != nil {
+			_ = json.NewEncoder(w).Encode(f.like(docs, like))
+			return
+		}
+		if suggest, ok := f.search["suggest"].(map[string]any); ok {
+			_ = json.NewEncoder(w).Encode(map[string]any{"suggest": f.suggest(docs, suggest)})
+			return
+		}
+		// Every document matches, counted by collection and year.
+		var resp struct {
+			Hits struct {
+				Total struct {
+					Value int `json:"value"`
+				} `json:"total"`
+				Hits []map[string]any `json:"hits"`
+			} `json:"hits"`
+			Aggregations map[string]map[string][]map[string]any `json:"aggregations"`
+		}
+		resp.Hits.Total.Value = len(docs)
+		collections, years := map[string]int{}, map[int]int{}
+		ids := make([]string, 0, len(docs))
+		for id := range docs {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		if after, ok := f.search["search_after"].([]any); ok {
+			ids = slices.DeleteFunc(ids, func(id string) bool { return id <= after[0].(string) })
+		}
+		if f.search["track_total_hits"] == false {
+			// Scans page by ID; searches return every document.
+			ids = ids[:min(len(ids), int(f.search["size"].(float64)))]
+		}
+		for _, id := range ids {
+			resp.Hits.Hits = append(resp.Hits.Hits, map[string]any{"_id": id, "_score": 1.5, "_source": docs[id], "sort": []string{id}})
+			collections[docs[id].Collection]++
+			years[docs[id].PublicationYear]++
+		}
+		resp.Aggregations = map[string]map[string][]map[string]any{"collection": {}, "year": {}}
+		for c, n := range collections {
+			resp.Aggregations["collection"]["buckets"] = append(resp.Aggregations["collection"]["buckets"], map[string]any{"key": c, "doc_count": n})
+		}
+		for y, n := range years {
+			resp.Aggregations["year"]["buckets"] = append(resp.Aggregations["year"]["buckets"], map[string]any{"key": y, "doc_count": n})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	case len(parts) == 2 && parts[1] == "_count":
+		fmt.Fprintf(w, `{"count": %d}`, len(f.indices[f.resolve(parts[0])]))
+	case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodPut:
+		index := f.resolve(parts[0])
+		if f.indices[index] == nil {
+			f.indices[index] = map[string]Document{}
+		}
+		var doc Document
+		_ = json.Unmarshal(body, &doc)
+		f.indices[index][parts[2]] = doc
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"result": "created"}`)
+	case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodDelete:
+		index := f.resolve(parts[0])
+		if _, ok := f.indices[index][parts[2]]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(f.indices[index], parts[2])
+		fmt.Fprint(w, `{"result": "deleted"}`)
+	default:
+		http.Error(w, "unsupported "+r.Method+" "+r.URL.Path, http.StatusBadRequest)
+	}
+}
+
+// docs returns the IDs indexed behind alias.
+func (f *fakeDomain) docs(alias string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ids []string
+	for id := range f.indices[f.resolve(alias)] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (f *fakeDomain) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func TestIndexer(t *testing.T) {
+	ctx := context.Background()
+	domain, client := newDomain(t)
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	x := &Indexer{Index: client, Alias: "ap-prod-datasets", State: s, SiteURL: "https://data.uni.edu", Now: func() time.Time { return now }}
+	datasets.Observe(x)
+
+	put := func(d *dataset.Dataset) {
+		t.Helper()
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Before the first rebuild, changes are left pending rather than
+	// creating an index with a guessed mapping.
+	put(&dataset.Dataset{ID: "ds-1", Title: "Soil cores", State: dataset.StatePublished, Access: storage.AccessPublic})
+	if pending, _ := x.PendingChanges(ctx); len(pending) != 1 || !strings.Contains(pending[0].Error, "index rebuild") {
+		t.Fatalf("pending before rebuild = %+v", pending)
+	}
+	if len(domain.indices) != 0 {
+		t.Fatalf("indices created before rebuild: %v", domain.indices)
+	}
+	put(&dataset.Dataset{ID: "ds-2", Title: "Draft", State: dataset.StateDraft})
+
+	res, err := x.Rebuild(ctx, datasets, false)
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if res.Index != "ap-prod-datasets-v5-20250501120000" || res.Documents != 1 {
+		t.Errorf("Rebuild() = %+v", res)
+	}
+	if got := domain.docs(x.Alias); fmt.Sprint(got) != "[ds-1]" {
+		t.Errorf("indexed after rebuild = %v, want [ds-1]", got)
+	}
+	if pending, _ := x.PendingChanges(ctx); len(pending) != 0 {
+		t.Errorf("pending after rebuild = %+v", pending)
+	}
+
+	// Changes are indexed as they are saved.
+	put(&dataset.Dataset{ID: "ds-2", Title: "Published draft", State: dataset.StatePublished})
+	put(&dataset.Dataset{ID: "ds-1", Title: "Soil cores", State: dataset.StateTombstoned})
+	if got := domain.docs(x.Alias); fmt.Sprint(got) != "[ds-2]" {
+		t.Errorf("indexed after changes = %v, want [ds-2]", got)
+	}
+
+	// An unavailable domain leaves changes pending without failing
+	// the write, and a retry indexes them.
+	domain.setDown(true)
+	put(&dataset.Dataset{ID: "ds-3", Title: "Letters", State: dataset.StatePublished})
+	if err := datasets.Delete(ctx, "ds-2"); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := x.PendingChanges(ctx); len(pending) != 2 {
+		t.Errorf("pending while down = %+v", pending)
+	}
+	domain.setDown(false)
+	pending, err := x.Retry(ctx, datasets)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("Retry() = %+v, %v", pending, err)
+	}
+	if got := domain.docs(x.Alias); fmt.Sprint(got) != "[ds-3]" {
+		t.Errorf("indexed after retry = %v, want [ds-3]", got)
+	}
+
+	// A second rebuild swaps the alias and removes the old index.
+	now = now.Add(time.Hour)
+	res, err = x.Rebuild(ctx, datasets, false)
+	if err != nil || fmt.Sprint(res.Removed) != "[ap-prod-datasets-v5-20250501120000]" {
+		t.Fatalf("second Rebuild() = %+v, %v", res, err)
+	}
+	st, err := x.Status(ctx)
+	if err != nil || !st.Current || st.Documents != 1 || fmt.Sprint(st.Indices) != "[ap-prod-datasets-v5-20250501130000]" {
+		t.Errorf("Status() = %+v, %v", st, err)
+	}
+}
+
+func TestQueuedIndexer(t *testing.T) {
+	ctx := context.Background()
+	domain, client := newDomain(t)
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	x := &Indexer{Index: client, Alias: "ap-prod-datasets", State: s, SiteURL: "https://data.uni.edu"}
+	if _, err := x.Rebuild(ctx, datasets, false); err != nil {
+		t.Fatal(err)
+	}
+	q := queue.NewMemory()
+	datasets.Observe(&Queued{Queue: q, Indexer: x})
+	w := &queue.Worker{Queue: q, Handlers: map[string]queue.Handler{queue.TopicIndex: x.Handler(datasets)}}
+
+	// Changes are queued rather than indexed, until a worker runs.
+	if err := datasets.Put(ctx, &dataset.Dataset{ID: "ds-1", Title: "Soil cores", State: dataset.StatePublished, Access: storage.AccessPublic}); err != nil {
+		t.Fatal(err)
+	}
+	if got := domain.docs(x.Alias); len(got) != 0 {
+		t.Errorf("indexed before the worker ran = %v", got)
+	}
+	if res, err := w.Drain(ctx); err != nil || res.Handled != 1 {
+		t.Errorf("Drain() = %+v, %v", res, err)
+	}
+	if got := domain.docs(x.Alias); fmt.Sprint(got) != "[ds-1]" {
+		t.Errorf("indexed after the worker ran = %v, want [ds-1]", got)
+	}
+
+	// A failure is left pending and the message retried.
+	domain.setDown(true)
+	if err := datasets.Delete(ctx, "ds-1"); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := w.Drain(ctx); err != nil || res.Failed != 1 {
+		t.Errorf("Drain() while down = %+v, %v", res, err)
+	}
+	if pending, _ := x.PendingChanges(ctx); len(pending) != 1 {
+		t.Errorf("pending while down = %+v", pending)
+	}
+}
+
+func TestNewDocument(t *testing.T) {
+	published := time.Date(2025, 4, 2, 9, 0, 0, 0, time.UTC)
+	d := &dataset.Dataset{
+		ID: "ds-1", DOI: "10.1234/abc", Title: "Soil cores", State: dataset.StatePublished, Access: storage.AccessPublic,
+		Creators: []dataset.Creator{{Name: "Lovelace, Ada", ORCID: "0000-0002-1825-0097", Affiliation: "University of Oxford", AffiliationROR: "052gg0110"}},
+		Versions: []dataset.Version{{Number: 2, PublishedAt: &published, Files: []dataset.File{
+			{Path: "cores.csv", Size: 2048, ContentType: "text/csv; charset=utf-8"},
+			{Path: "more.csv", Size: 1024, ContentType: "text/csv"},
+		}}},
+	}
+	doc := NewDocument(d, "https://data.uni.edu/")
+	if doc.Identifier != "doi:10.1234/abc" || doc.URL != "https://data.uni.edu/datasets/ds-1/" || doc.ResourceType != "Dataset" ||
+		doc.Size != 3072 || fmt.Sprint(doc.Formats) != "[text/csv]" || doc.Version != 2 || !doc.Published.Equal(published) ||
+		fmt.Sprint(doc.RORs) != "[052gg0110]" {
+		t.Errorf("NewDocument() = %+v", doc)
+	}
+
+	// Every field is mapped, since mappings are strict.
+	data, _ := json.Marshal(Document{Published: &published, TitleByLanguage: map[string]string{"es": "Suelos"}, DescriptionByLanguage: map[string]string{"fr": "Sols"}, Languages: []string{"es"}})
+	var fields map[string]any
+	_ = json.Unmarshal(data, &fields)
+	props := Mapping()["mappings"].(map[string]any)["properties"].(map[string]any)
+	for name := range fields {
+		if _, ok := props[name]; !ok {
+			t.Errorf("field %q is not mapped", name)
+		}
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	tests := []struct {
+		title, description   string
+		wantTitle, wantDescr map[string]string
+		wantLanguages        []string
+	}{
+		{"", "", nil, nil, nil},
+		{"es", "es-MX", map[string]string{"es": "Núcleos de suelo"}, map[string]string{"es": "Muestras de los Andes"}, []string{"es"}},
+		{"en", "fr-CA", map[string]string{"en": "Núcleos de suelo"}, map[string]string{"fr": "Muestras de los Andes"}, []string{"en", "fr"}},
+		// Languages without an analyzer are tagged but not repeated.
+		{"", "qu", nil, nil, []string{"qu"}},
+	}
+	for _, tt := range tests {
+		d := &dataset.Dataset{ID: "ds-1", Title: "Núcleos de suelo", Description: "Muestras de los Andes", TitleLanguage: tt.title, DescriptionLanguage: tt.description}
+		doc := NewDocument(d, "")
+		if !reflect.DeepEqual(doc.TitleByLanguage, tt.wantTitle) || !reflect.DeepEqual(doc.DescriptionByLanguage, tt.wantDescr) || !reflect.DeepEqual(doc.Languages, tt.wantLanguages) {
+			t.Errorf("NewDocument(%q, %q) = %v, %v, %v, want %v, %v, %v", tt.title, tt.description,
+				doc.TitleByLanguage, doc.DescriptionByLanguage, doc.Languages, tt.wantTitle, tt.wantDescr, tt.wantLanguages)
+		}
+	}
+
+	props := Mapping()["mappings"].(map[string]any)["properties"].(map[string]any)
+	fr := props["descriptionByLanguage"].(map[string]any)["properties"].(map[string]any)["fr"]
+	if got := fmt.Sprint(fr); got != "map[analyzer:french type:text]" {
+		t.Errorf("descriptionByLanguage.fr mapping = %s", got)
+	}
+
+	for _, tt := range []struct {
+		language string
+		want     string
+		wantErr  bool
+	}{
+		{"", "", false},
+		{"ES", "es", false},
+		{"fr_CA", "fr", false},
+		{"français", "", true},
+		{"e", "", true},
+	} {
+		q := &Query{Text: "datos", Language: tt.language}
+		err := q.normalize()
+		if (err != nil) != tt.wantErr || q.Language != tt.want && err == nil {
+			t.Errorf("normalize(language %q) = %q, %v, want %q, wantErr %v", tt.language, q.Language, err, tt.want, tt.wantErr)
+		}
+	}
+	q := &Query{Text: "datos", Language: "es"}
+	_ = q.normalize()
+	data, _ := json.Marshal(q.body())
+	for _, want := range []string{`"titleByLanguage.es^3"`, `"descriptionByLanguage.es"`, `{"term":{"languages":"es"}}`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("search body lacks %s: %s", want, data)
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	domain, client := newDomain(t)
+	ctx := context.Background()
+	if err := client.Delete(ctx, "missing", "ds-1"); err != nil {
+		t.Errorf("Delete() of missing document = %v, want nil", err)
+	}
+	if targets, err := client.AliasTargets(ctx, "missing"); err != nil || targets != nil {
+		t.Errorf("AliasTargets() of missing alias = %v, %v", targets, err)
+	}
+	domain.setDown(true)
+	if err := client.Put(ctx, "idx", "ds-1", Document{}); err == nil || errors.Is(err, errNotFound) || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Put() while down = %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	domain, client := newDomain(t)
+	s := &Searcher{Index: client, Alias: "ap-prod-datasets"}
+	if _, err := s.Search(ctx, &Query{}); !errors.Is(err, ErrNoIndex) {
+		t.Fatalf("Search() before rebuild error = %v, want ErrNoIndex", err)
+	}
+
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", Title: "Soil cores", Collection: "geo", PublicationYear: 2024, State: dataset.StatePublished},
+		{ID: "ds-2", Title: "Letters", Collection: "history", PublicationYear: 2023, State: dataset.StatePublished},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	x := &Indexer{Index: client, Alias: s.Alias, State: state.NewMemoryStore()}
+	if _, err := x.Rebuild(ctx, datasets, false); err != nil {
+		t.Fatal(err)
+	}
+
+	q := &Query{Text: "soil", Creator: "Lovelace", Subject: "Soil science", From: "2024", Until: "2025-03", License: []string{"CC-BY-4.0"}, Access: []string{"public"}, Page: 2, Size: 1}
+	res, err := s.Search(ctx, q)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if res.Total != 2 || res.Page != 2 || res.Size != 1 || len(res.Hits) != 2 || res.Hits[0].ID != "ds-1" || res.Hits[0].Score != 1.5 {
+		t.Errorf("Search() = %+v", res)
+	}
+	if got := fmt.Sprint(res.Facets["year"]); !strings.Contains(got, "{2024 1}") {
+		t.Errorf("year facet = %v", got)
+	}
+	if got := res.Facets["license"]; got == nil || len(got) != 0 {
+		t.Errorf("license facet = %#v, want empty", got)
+	}
+
+	data, _ := json.Marshal(domain.search)
+	body := string(data)
+	for _, want := range []string{
+		`"from":1`, `"size":1`, `"multi_match":{"fields":["title^3",`, `"match_phrase":{"creators":"Lovelace"}`,
+		`"term":{"subjects.raw":"Soil science"}`, `"range":{"published":{"gte":"2024||/y","lte":"2025-03||/M"}}`,
+		`"terms":{"license":["CC-BY-4.0"]}`, `"terms":{"access":["public"]}`, `"creator":{"terms":{"field":"creators.raw"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("search body lacks %s: %s", want, body)
+		}
+	}
+	if strings.Contains(body, `"sort"`) || strings.Contains(body, `"collection":[`) {
+		t.Errorf("unexpected search body: %s", body)
+	}
+
+	// The handler serves the same search over HTTP.
+	srv := httptest.NewServer(NewHandler(s))
+	defer srv.Close()
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{
+		{"q=soil&collection=geo,history", http.StatusOK},
+		{"size=500", http.StatusBadRequest},
+		{"page=x", http.StatusBadRequest},
+		{"from=2025-13", http.StatusBadRequest},
+		{"from=2025&until=2024", http.StatusBadRequest},
+		{"page=200&size=100", http.StatusBadRequest},
+		{"near=52.2,0.12&radius=50km", http.StatusOK},
+		{"radius=50km", http.StatusBadRequest},
+		{"near=52.2,0.12&radius=5000km", http.StatusBadRequest},
+		{"bbox=-10,50,2", http.StatusBadRequest},
+		{"bbox=-10,60,2,50", http.StatusBadRequest},
+	} {
+		resp, err := http.Get(srv.URL + "/search?" + tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Results
+		_ = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET /search?%s status = %d, want %d", tt.query, resp.StatusCode, tt.want)
+		}
+		if tt.want == http.StatusOK && (got.Total != 2 || got.Size != DefaultPageSize) {
+			t.Errorf("GET /search?%s = %+v", tt.query, got)
+		}
+	}
+	data, _ = json.Marshal(domain.search)
+	if !strings.Contains(string(data), `"geo_shape":{"locations":{"relation":"intersects","shape":{"coordinates":[[[`) {
+		t.Errorf("handler search body = %s", data)
+	}
+
+	if _, err := s.Search(ctx, &Query{BBox: &dataset.GeoBox{West: 170, South: -50, East: -170, North: -30}}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = json.Marshal(domain.search)
+	if !strings.Contains(string(data), `"shape":{"coordinates":[[170,-30],[-170,-50]],"type":"envelope"}`) {
+		t.Errorf("bounding box search body = %s", data)
+	}
+}
+
+func TestGeo(t *testing.T) {
+	d := &dataset.Dataset{ID: "ds-1", GeoLocations: []dataset.GeoLocation{
+		{Place: "Cambridge", Point: &dataset.GeoPoint{Lat: 52.2, Lon: 0.12}},
+		{Box: &dataset.GeoBox{West: -10, South: 50, East: 2, North: 60}},
+	}}
+	doc := NewDocument(d, "")
+	data, _ := json.Marshal(doc.Locations)
+	if fmt.Sprint(doc.Places) != "[Cambridge]" || string(data) != `[{"type":"point","coordinates":[0.12,52.2]},{"type":"envelope","coordinates":[[-10,60],[2,50]]}]` {
+		t.Errorf("NewDocument() places = %v, locations = %s", doc.Places, data)
+	}
+
+	// A circle is a closed counterclockwise ring of points at the radius.
+	center := dataset.GeoPoint{Lat: 52.2, Lon: 0.12}
+	shape := circleShape(center, 50_000)
+	ring := shape.Coordinates.([][][]float64)[0]
+	if shape.Type != "polygon" || len(ring) != circleSides+1 || fmt.Sprint(ring[0]) != fmt.Sprint(ring[circleSides]) {
+		t.Fatalf("circleShape() = %+v", shape)
+	}
+	var area float64
+	for i, v := range ring[:circleSides] {
+		if km := haversine(center, dataset.GeoPoint{Lat: v[1], Lon: v[0]}) / 1000; math.Abs(km-50) > 0.01 {
+			t.Errorf("vertex %d is %.3fkm from the center, want 50km", i, km)
+		}
+		area += v[0]*ring[i+1][1] - ring[i+1][0]*v[1]
+	}
+	if area <= 0 {
+		t.Error("circleShape() ring is clockwise")
+	}
+
+	// Circles across the antimeridian or around a pole become boxes.
+	tests := []struct {
+		p    dataset.GeoPoint
+		want string
+	}{
+		{dataset.GeoPoint{Lat: 0, Lon: 179.9}, "[[179.45 0.45] [-179.65 -0.45]]"},
+		{dataset.GeoPoint{Lat: 89.9, Lon: 10}, "[[-180.00 90.00] [180.00 89.45]]"},
+	}
+	for _, tt := range tests {
+		shape := circleShape(tt.p, 50_000)
+		got := fmt.Sprintf("%.2f", shape.Coordinates)
+		if shape.Type != "envelope" || got != tt.want {
+			t.Errorf("circleShape(%v) = %s %s, want envelope %s", tt.p, shape.Type, got, tt.want)
+		}
+	}
+}
+
+// haversine returns the great-circle distance between a and b in
+// metres.
+func haversine(a, b dataset.GeoPoint) float64 {
+	dlat, dlon := radians(b.Lat-a.Lat), radians(b.Lon-a.Lon)
+	h := math.Pow(math.Sin(dlat/2), 2) + math.Cos(radians(a.Lat))*math.Cos(radians(b.Lat))*math.Pow(math.Sin(dlon/2), 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+func TestParseDistance(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"50km", 50_000, true},
+		{"500 m", 500, true},
+		{"10mi", 16_093.44, true},
+		{"1.5KM", 1500, true},
+		{"50", 0, false},
+		{"-5km", 0, false},
+		{"km", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseDistance(tt.in)
+		if (err == nil) != tt.ok || math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("ParseDistance(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	ctx := context.Background()
+	domain, client := newDomain(t)
+	s := &Searcher{Index: client, Alias: "ap-prod-datasets"}
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", Title: "Arctic soil cores", Creators: []dataset.Creator{{Name: "Lovelace, Ada"}}, Subjects: []string{"Soil science"}, State: dataset.StatePublished},
+		{ID: "ds-2", Title: "Soil moisture 2020", Creators: []dataset.Creator{{Name: "Somerville, Mary"}}, State: dataset.StatePublished},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	x := &Indexer{Index: client, Alias: s.Alias, State: state.NewMemoryStore()}
+	if _, err := x.Rebuild(ctx, datasets, false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q    SuggestQuery
+		want string
+	}{
+		// Titles starting with the prefix rank above titles matched by a
+		// later word, and ties rank titles, creators, then subjects.
+		{SuggestQuery{Prefix: "so"}, "[{Soil moisture 2020 title 2} {Somerville, Mary creator 2} {Soil science subject 2} {Arctic soil cores title 1}]"},
+		{SuggestQuery{Prefix: "So", Types: []string{SuggestCreator}}, "[{Somerville, Mary creator 2}]"},
+		{SuggestQuery{Prefix: "ada", Types: []string{SuggestCreator}}, "[{Lovelace, Ada creator 2}]"},
+		{SuggestQuery{Prefix: "so", Size: 2}, "[{Soil moisture 2020 title 2} {Somerville, Mary creator 2}]"},
+		{SuggestQuery{Prefix: "zz"}, "[]"},
+	}
+	for _, tt := range tests {
+		got, err := s.Suggest(ctx, &tt.q)
+		if err != nil || fmt.Sprint(got) != tt.want {
+			t.Errorf("Suggest(%+v) = %v, %v, want %s", tt.q, got, err, tt.want)
+		}
+	}
+	data, _ := json.Marshal(domain.search)
+	if !strings.Contains(string(data), `"completion":{"field":"titleSuggest","size":10,"skip_duplicates":true}`) {
+		t.Errorf("suggest body = %s", data)
+	}
+
+	// Completion inputs are kept out of search results.
+	if _, err := s.Search(ctx, &Query{}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := json.Marshal(domain.search); !strings.Contains(string(data), `"_source":{"excludes":["titleSuggest","creatorSuggest","subjectSuggest"]}`) {
+		t.Errorf("search body = %s", data)
+	}
+
+	srv := httptest.NewServer(NewHandler(s))
+	defer srv.Close()
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{
+		{"q=soil&type=title,subject", http.StatusOK},
+		{"q=", http.StatusBadRequest},
+		{"q=soil&type=award", http.StatusBadRequest},
+		{"q=soil&size=100", http.StatusBadRequest},
+	} {
+		resp, err := http.Get(srv.URL + "/suggest?" + tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []Suggestion
+		_ = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET /suggest?%s status = %d, want %d", tt.query, resp.StatusCode, tt.want)
+		}
+		if tt.want == http.StatusOK && (len(got) != 3 || resp.Header.Get("Cache-Control") != suggestMaxAge || !strings.HasPrefix(resp.Header.Get("Server-Timing"), "suggest;dur=")) {
+			t.Errorf("GET /suggest?%s = %v, headers %v", tt.query, got, resp.Header)
+		}
+	}
+
+	// A domain slower than the budget yields no suggestions, uncached.
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-release }))
+	defer slow.Close()
+	defer close(release)
+	srv2 := httptest.NewServer(NewHandler(&Searcher{Index: NewClient(ClientOptions{Endpoint: slow.URL}), Alias: s.Alias}))
+	defer srv2.Close()
+	resp, err := http.Get(srv2.URL + "/suggest?q=soil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "[]" || resp.Header.Get("Cache-Control") != "no-store" {
+		t.Errorf("GET /suggest from a slow domain = %d %s %v", resp.StatusCode, body, resp.Header)
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	got, _ := json.Marshal(NewDocument(&dataset.Dataset{
+		ID:       "ds-1",
+		Title:    "Survey of the Arctic",
+		Creators: []dataset.Creator{{Name: "Lovelace, Ada"}, {Name: "UNESCO"}},
+	}, ""))
+	for _, want := range []string{
+		`"titleSuggest":[{"input":["Survey of the Arctic"],"weight":2},{"input":["the Arctic","Arctic"],"weight":1}]`,
+		`"creatorSuggest":[{"input":["Lovelace, Ada","Ada Lovelace","UNESCO"],"weight":2}]`,
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("NewDocument() = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	ctx := context.Background()
+	_, client := newDomain(t)
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	s := &Searcher{Index: client, Alias: "ap-prod-datasets", Datasets: datasets}
+	rel := func(relation, id string) dataset.RelatedIdentifier {
+		r, err := dataset.NewRelatedIdentifier(relation, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	for _, d := range []*dataset.Dataset{
+		{ID: "ds-1", DOI: "10.5555/DS-1", Title: "Arctic soil cores", State: dataset.StatePublished, Related: []dataset.RelatedIdentifier{rel("IsCitedBy", "10.1000/paper")}},
+		{ID: "ds-2", Title: "Arctic soil moisture", State: dataset.StatePublished},
+		{ID: "ds-3", Title: "Letters", State: dataset.StatePublished, Related: []dataset.RelatedIdentifier{rel("iscitedby", "https://doi.org/10.1000/PAPER")}},
+		{ID: "ds-4", Title: "Census", State: dataset.StatePublished, Related: []dataset.RelatedIdentifier{rel("IsSupplementTo", "doi:10.5555/ds-1")}},
+		{ID: "ds-5", Title: "Arctic draft", State: dataset.StateDraft, Related: []dataset.RelatedIdentifier{rel("IsCitedBy", "10.1000/paper")}},
+		{ID: "ds-6", Title: "Maps", State: dataset.StatePublished},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := dataset.NewRelatedIdentifier("Likes", "10.1000/paper"); err == nil {
+		t.Error("NewRelatedIdentifier() with an unknown relation succeeded")
+	}
+	d, _ := datasets.Get(ctx, "ds-1")
+
+	// Before the index is built, only the catalog is used.
+	got, err := s.Recommend(ctx, d, 0)
+	if err != nil || fmt.Sprint(got) != "[{ds-4 Census  /datasets/ds-4/ 1 [linked] 0} {ds-3 Letters  /datasets/ds-3/ 0.5 [cocited] 1}]" {
+		t.Errorf("Recommend() without an index = %v, %v", got, err)
+	}
+
+	x := &Indexer{Index: client, Alias: s.Alias, State: state.NewMemoryStore()}
+	if _, err := x.Rebuild(ctx, datasets, false); err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.Recommend(ctx, d, 0)
+	if err != nil || fmt.Sprint(got) != "[{ds-2 Arctic soil moisture  /datasets/ds-2/ 1 [similar] 0} {ds-4 Census  /datasets/ds-4/ 1 [linked] 0} {ds-3 Letters  /datasets/ds-3/ 0.5 [cocited] 1}]" {
+		t.Errorf("Recommend() = %v, %v", got, err)
+	}
+	if got, _ := s.Recommend(ctx, d, 1); len(got) != 1 || got[0].ID != "ds-2" {
+		t.Errorf("Recommend() with size 1 = %v", got)
+	}
+	links, err := s.Related(ctx, d)
+	if err != nil || len(links) != 3 || links[1] != (landing.Related{Title: "Census", URL: "/datasets/ds-4/"}) {
+		t.Errorf("Related() = %v, %v", links, err)
+	}
+
+	srv := httptest.NewServer(NewHandler(s))
+	defer srv.Close()
+	for _, tt := range []struct {
+		path string
+		want int
+		n    int
+	}{
+		{"/related/ds-1", http.StatusOK, 3},
+		{"/related/10.5555/ds-1?size=2", http.StatusOK, 2},
+		{"/related/ds-5", http.StatusNotFound, 0},
+		{"/related/ds-9", http.StatusNotFound, 0},
+		{"/related/ds-1?size=99", http.StatusBadRequest, 0},
+	} {
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []Recommendation
+		_ = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want || len(got) != tt.n {
+			t.Errorf("GET %s = %d %v, want %d with %d", tt.path, resp.StatusCode, got, tt.want, tt.n)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	domain, client := newDomain(t)
+	s := &Searcher{Index: client, Alias: "ap-prod-datasets"}
+	if err := s.Scan(ctx, Query{}, func(*Document) error { return nil }); !errors.Is(err, ErrNoIndex) {
+		t.Fatalf("Scan() before rebuild error = %v, want ErrNoIndex", err)
+	}
+
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	const n = 2*scanBatch + 1
+	for i := range n {
+		d := &dataset.Dataset{ID: fmt.Sprintf("ds-%04d", i), Title: "Soil cores", State: dataset.StatePublished}
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	x := &Indexer{Index: client, Alias: s.Alias, State: state.NewMemoryStore()}
+	if _, err := x.Rebuild(ctx, datasets, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	err := s.Scan(ctx, Query{Text: "soil", Page: 50, Size: 100}, func(d *Document) error {
+		ids = append(ids, d.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(ids) != n || !slices.IsSorted(ids) || ids[0] != "ds-0000" {
+		t.Errorf("Scan() returned %d datasets, want %d in ID order", len(ids), n)
+	}
+	data, _ := json.Marshal(domain.search)
+	body := string(data)
+	for _, want := range []string{`"search_after":["ds-0999"]`, `"sort":[{"id":"asc"}]`, `"size":500`, `"multi_match"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scan body lacks %s: %s", want, body)
+		}
+	}
+	if strings.Contains(body, `"aggs"`) || strings.Contains(body, `"from"`) {
+		t.Errorf("unexpected scan body: %s", body)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = s.Scan(ctx, Query{}, func(*Document) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Scan() stopping = %v after %d calls, want stop after 1", err, calls)
+	}
+	if err := s.Scan(ctx, Query{From: "last week"}, func(*Document) error { return nil }); err == nil {
+		t.Error("Scan() with an invalid query succeeded")
+	}
+}
+
+func TestExporter(t *testing.T) {
+	published := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	docs := []Document{
+		{ID: "ds-1", DOI: "10.5555/ds-1", Title: "Soil, cores", Creators: []string{"Lovelace, Ada", "Babbage, Charles"}, PublicationYear: 2024, Published: &published, Size: 42, URL: "https://data.uni.edu/datasets/ds-1", TitleSuggest: []Completion{{Input: []string{"Soil"}}}},
+		{ID: "ds-2", Title: "=HYPERLINK(\"http://evil\")", Access: "public"},
+	}
+	tests := []struct {
+		format  string
+		want    []string
+		wantErr bool
+	}{
+		{format: FormatCSV, want: []string{
+			"id,doi,title,languages,creators,orcids,affiliations,publicationYear,published,resourceType,collection,access,license,subjects,places,awards,version,formats,size,url\n",
+			`ds-1,10.5555/ds-1,"Soil, cores",,"Lovelace, Ada; Babbage, Charles",,,2024,2024-05-01T12:00:00Z,,,,,,,,,,42,https://data.uni.edu/datasets/ds-1` + "\n",
+			`ds-2,,"'=HYPERLINK(""http://evil"")",,,,,,,,,public,,,,,,,0,` + "\n",
+		}},
+		{format: FormatJSONL, want: []string{
+			`{"id":"ds-1","doi":"10.5555/ds-1","title":"Soil, cores","creators":["Lovelace, Ada","Babbage, Charles"],"publicationYear":2024,"resourceType":"","access":"","size":42,"published":"2024-05-01T12:00:00Z","updated":"0001-01-01T00:00:00Z","url":"https://data.uni.edu/datasets/ds-1"}` + "\n",
+			`{"id":"ds-2","title":"=HYPERLINK(\"http://evil\")","resourceType":"","access":"public","size":0,"updated":"0001-01-01T00:00:00Z","url":""}` + "\n",
+		}},
+		{format: "xlsx", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			ex, err := NewExporter(tt.format, &buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewExporter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for i := range docs {
+				if err := ex.Write(&docs[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := ex.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := buf.String(), strings.Join(tt.want, ""); got != want {
+				t.Errorf("export = %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Suggestion types.
+const (
+	SuggestTitle   = "title"
+	SuggestCreator = "creator"
+	SuggestSubject = "subject"
+)
+
+// Numbers of suggestions accepted by Suggest.
+const (
+	DefaultSuggestions = 10
+	MaxSuggestions     = 25
+)
+
+// maxPrefix is the longest prefix completed, in characters. Completion
+// inputs are truncated to 50 characters by default, so longer prefixes
+// cannot match more precisely.
+const maxPrefix = 100
+
+// Completion weights. A prefix matching the start of a title, name, or
+// subject ranks above one matching a later word of a title.
+const (
+	weightStart = 2
+	weightWord  = 1
+)
+
+// titleWords is the number of words of a title after the first that
+// are also completed from, so that "soil" suggests "Arctic soil cores".
+const titleWords = 8
+
+// suggester is a completion field and the type of its suggestions.
+type suggester struct{ typ, field string }
+
+// suggesters are the completion fields, in the order their suggestions
+// rank on equal scores.
+var suggesters = []suggester{
+	{SuggestTitle, "titleSuggest"},
+	{SuggestCreator, "creatorSuggest"},
+	{SuggestSubject, "subjectSuggest"},
+}
+
+func suggestFieldNames() []string {
+	names := make([]string, len(suggesters))
+	for i, s := range suggesters {
+		names[i] = s.field
+	}
+	return names
+}
+
+// Completion is an input of a completion field with its weight.
+type Completion struct {
+	Input  []string `json:"input"`
+	Weight int      `json:"weight"`
+}
+
+// titleCompletions completes title from its start and from each of its
+// first few later words, skipping short ones such as "of" and "a".
+func titleCompletions(title string) []Completion {
+	if title == "" {
+		return nil
+	}
+	out := []Completion{{Input: []string{title}, Weight: weightStart}}
+	words := strings.Fields(title)
+	var inner []string
+	for i := 1; i < len(words) && i <= titleWords; i++ {
+		if utf8.RuneCountInString(words[i]) >= 3 {
+			inner = append(inner, strings.Join(words[i:], " "))
+		}
+	}
+	if len(inner) > 0 {
+		out = append(out, Completion{Input: inner, Weight: weightWord})
+	}
+	return out
+}
+
+// creatorCompletions completes each name as written and, for names
+// written "Family, Given", in the order "Given Family".
+func creatorCompletions(names []string) []Completion {
+	var inputs []string
+	for _, name := range names {
+		inputs = append(inputs, name)
+		if family, given, ok := strings.Cut(name, ","); ok && strings.TrimSpace(given) != "" {
+			inputs = append(inputs, strings.TrimSpace(given)+" "+strings.TrimSpace(family))
+		}
+	}
+	if len(inputs) == 0 {
+		return nil
+	}
+	return []Completion{{Input: inputs, Weight: weightStart}}
+}
+
+func subjectCompletions(subjects []string) []Completion {
+	if len(subjects) == 0 {
+		return nil
+	}
+	return []Completion{{Input: subjects, Weight: weightStart}}
+}
+
+// SuggestQuery asks for completions of a prefix typed into a search
+// box.
+type SuggestQuery struct {
+	// Prefix is the text typed so far
+	Prefix string `json:"q"`
+
+	// Types limits suggestions to titles, creators, or subjects; all
+	// if empty
+	Types []string `json:"type,omitempty"`
+
+	// Size is the number of suggestions; DefaultSuggestions if zero
+	Size int `json:"size,omitempty"`
+}
+
+// ParseSuggestQuery reads a suggest query from the URL parameters q,
+// type, and size. Types may repeat or be comma-separated.
+func ParseSuggestQuery(v url.Values) (*SuggestQuery, error) {
+	q := &SuggestQuery{Prefix: v.Get("q"), Types: splitValues(v["type"])}
+	if s := v.Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q", s)
+		}
+		q.Size = n
+	}
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// normalize applies defaults and validates q.
+func (q *SuggestQuery) normalize() error {
+	q.Prefix = strings.TrimLeft(q.Prefix, " \t")
+	if strings.TrimSpace(q.Prefix) == "" {
+		return fmt.Errorf("a prefix to complete is required")
+	}
+	if utf8.RuneCountInString(q.Prefix) > maxPrefix {
+		return fmt.Errorf("prefix is longer than %d characters", maxPrefix)
+	}
+	if q.Size == 0 {
+		q.Size = DefaultSuggestions
+	}
+	if q.Size < 1 || q.Size > MaxSuggestions {
+		return fmt.Errorf("invalid size %d: must be between 1 and %d", q.Size, MaxSuggestions)
+	}
+	for _, t := range q.Types {
+		if !slices.ContainsFunc(suggesters, func(s suggester) bool { return s.typ == t }) {
+			return fmt.Errorf("invalid suggestion type %q: want %s, %s, or %s", t, SuggestTitle, SuggestCreator, SuggestSubject)
+		}
+	}
+	return nil
+}
+
+// wants reports whether q asks for suggestions of type typ.
+func (q *SuggestQuery) wants(typ string) bool {
+	return len(q.Types) == 0 || slices.Contains(q.Types, typ)
+}
+
+// body returns the search request asking each wanted completion field
+// for Size suggestions. Only the fields needed to recover the text as
+// written are returned.
+func (q *SuggestQuery) body() map[string]any {
+	suggest := map[string]any{}
+	for _, s := range suggesters {
+		if q.wants(s.typ) {
+			suggest[s.typ] = map[string]any{
+				"prefix": q.Prefix,
+				"completion": map[string]any{
+					"field":           s.field,
+					"size":            q.Size,
+					"skip_duplicates": true,
+				},
+			}
+		}
+	}
+	return map[string]any{
+		"size":    0,
+		"_source": []string{"title", "creators", "subjects"},
+		"suggest": suggest,
+	}
+}
+
+// Suggestion is a completion of a prefix.
+type Suggestion struct {
+	// Text is the title, creator name, or subject as written
+	Text string `json:"text"`
+
+	// Type is SuggestTitle, SuggestCreator, or SuggestSubject
+	Type string `json:"type"`
+
+	// Score ranks the suggestion; higher is better
+	Score float64 `json:"score"`
+}
+
+// Suggest completes q's prefix from the titles, creators, and subjects
+// of published datasets, best first. A title matched by a later word or
+// a name matched in "Given Family" order is returned as written.
+func (s *Searcher) Suggest(ctx context.Context, q *SuggestQuery) ([]Suggestion, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Suggest map[string][]struct {
+			Options []struct {
+				Text   string   `json:"text"`
+				Score  float64  `json:"_score"`
+				Source Document `json:"_source"`
+			} `json:"options"`
+		} `json:"suggest"`
+	}
+	err := s.Index.Search(ctx, s.Alias, q.body(), &resp)
+	if errors.Is(err, errNotFound) {
+		return nil, ErrNoIndex
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to suggest from %s: %w", s.Alias, err)
+	}
+
+	out := []Suggestion{}
+	rank := map[string]int{}
+	seen := map[Suggestion]bool{}
+	for i, sg := range suggesters {
+		rank[sg.typ] = i
+		for _, entry := range resp.Suggest[sg.typ] {
+			for _, o := range entry.Options {
+				text := written(sg.typ, o.Text, &o.Source)
+				key := Suggestion{Text: text, Type: sg.typ}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				out = append(out, Suggestion{Text: text, Type: sg.typ, Score: o.Score})
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Type != b.Type {
+			return rank[a.Type] < rank[b.Type]
+		}
+		return len(a.Text) < len(b.Text)
+	})
+	if len(out) > q.Size {
+		out = out[:q.Size]
+	}
+	return out, nil
+}
+
+// written returns the title, name, or subject of doc that the matched
+// completion input was derived from.
+func written(typ, input string, doc *Document) string {
+	switch typ {
+	case SuggestTitle:
+		if doc.Title != "" {
+			return doc.Title
+		}
+	case SuggestCreator:
+		for _, name := range doc.Creators {
+			for _, c := range creatorCompletions([]string{name}) {
+				if slices.ContainsFunc(c.Input, func(in string) bool { return strings.EqualFold(in, input) }) {
+					return name
+				}
+			}
+		}
+	case SuggestSubject:
+		for _, subject := range doc.Subjects {
+			if strings.EqualFold(subject, input) {
+				return subject
+			}
+		}
+	}
+	return input
+}
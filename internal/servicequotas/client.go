@@ -0,0 +1,160 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package servicequotas is a minimal Service Quotas client for reading
+// the quotas an account has been granted.
+package servicequotas
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+// targetPrefix prefixes the X-Amz-Target of every operation.
+const targetPrefix = "ServiceQuotasV20190624."
+
+// CloudFrontDistributions is the code of the quota on CloudFront web
+// distributions per account, read in us-east-1.
+const CloudFrontDistributions = "L-24B04930"
+
+// ErrNotFound is returned when a quota does not exist.
+var ErrNotFound = errors.New("servicequotas: not found")
+
+// Options configures a Client.
+type Options struct {
+	// Region is the AWS region of the quotas
+	Region string
+
+	// Endpoint overrides the regional AWS endpoint
+	Endpoint string
+
+	// Credentials sign requests
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is a Service Quotas client.
+type Client struct {
+	endpoint string
+	signer   *aws.Signer
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://servicequotas." + opts.Region + ".amazonaws.com"
+	}
+	c := &Client{
+		endpoint: strings.TrimRight(endpoint, "/") + "/",
+		signer:   &aws.Signer{Credentials: opts.Credentials, Region: opts.Region, Service: "servicequotas"},
+		http:     opts.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Quota is a service quota applied to the account.
+type Quota struct {
+	Name  string `json:"QuotaName"`
+	Value float64
+}
+
+// GetServiceQuota returns the quota code of service, e.g. "lambda",
+// as applied to the account. Quotas the account never had raised are
+// returned at their default value.
+func (c *Client) GetServiceQuota(ctx context.Context, service, code string) (*Quota, error) {
+	in := map[string]string{"ServiceCode": service, "QuotaCode": code}
+	var out struct {
+		Quota Quota
+	}
+	err := c.do(ctx, "GetServiceQuota", in, &out)
+	if errors.Is(err, ErrNotFound) {
+		err = c.do(ctx, "GetAWSDefaultServiceQuota", in, &out)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &out.Quota, nil
+}
+
+// do calls operation with in as the JSON request and decodes the
+// response into out.
+func (c *Client) do(ctx context.Context, operation string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", operation, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", operation, err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", targetPrefix+operation)
+	c.signer.Sign(req, aws.HashPayload(body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("servicequotas %s failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode, Operation: operation}
+		_ = json.Unmarshal(data, e)
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		return e
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", operation, err)
+	}
+	return nil
+}
+
+// Error is a Service Quotas error response.
+type Error struct {
+	StatusCode int
+	Operation  string
+	Type       string `json:"__type"`
+	Message    string `json:"Message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("servicequotas %s: HTTP %d %s: %s", e.Operation, e.StatusCode, e.Type, e.Message)
+}
+
+// Unwrap maps missing quotas to ErrNotFound.
+func (e *Error) Unwrap() error {
+	if e.Type == "NoSuchResourceException" {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicequotas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return NewClient(Options{
+		Region:      "us-east-1",
+		Endpoint:    srv.URL,
+		Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+}
+
+func TestGetServiceQuota(t *testing.T) {
+	var targets []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		targets = append(targets, strings.TrimPrefix(r.Header.Get("X-Amz-Target"), targetPrefix))
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		if in["ServiceCode"] != "cloudfront" || in["QuotaCode"] != CloudFrontDistributions {
+			t.Errorf("request = %v", in)
+		}
+		if len(targets) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type": "com.amazonaws.servicequotas#NoSuchResourceException", "Message": "no quota"}`)
+			return
+		}
+		fmt.Fprint(w, `{"Quota": {"QuotaName": "Web distributions per AWS account", "Value": 200.0}}`)
+	})
+	// A quota never raised is only known by its default.
+	q, err := c.GetServiceQuota(context.Background(), "cloudfront", CloudFrontDistributions)
+	if err != nil {
+		t.Fatalf("GetServiceQuota() error = %v", err)
+	}
+	if q.Value != 200 || strings.Join(targets, ",") != "GetServiceQuota,GetAWSDefaultServiceQuota" {
+		t.Errorf("GetServiceQuota() = %+v after %v", q, targets)
+	}
+}
+
+func TestError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type": "com.amazonaws.servicequotas#AccessDeniedException", "Message": "not authorized"}`)
+	})
+	_, err := c.GetServiceQuota(context.Background(), "lambda", "L-B99A9384")
+	var e *Error
+	if !errors.As(err, &e) || e.Type != "AccessDeniedException" || errors.Is(err, ErrNotFound) {
+		t.Errorf("GetServiceQuota() error = %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ses is a minimal Amazon SES (API v2) client for reading the
+// sending status of an account.
+package ses
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+// Options configures a Client.
+type Options struct {
+	// Region is the AWS region mail is sent from
+	Region string
+
+	// Endpoint overrides the regional AWS endpoint
+	Endpoint string
+
+	// Credentials sign requests
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is an SES client.
+type Client struct {
+	endpoint string
+	signer   *aws.Signer
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://email." + opts.Region + ".amazonaws.com"
+	}
+	c := &Client{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		signer:   &aws.Signer{Credentials: opts.Credentials, Region: opts.Region, Service: "ses"},
+		http:     opts.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Account is the sending status of an account in a region.
+type Account struct {
+	// ProductionAccessEnabled is false while the account is in the
+	// sandbox, where mail is only delivered to verified addresses
+	ProductionAccessEnabled bool
+
+	// SendingEnabled is false if sending was paused
+	SendingEnabled bool
+
+	// Max24HourSend is the number of messages the account may send a
+	// day
+	Max24HourSend float64
+}
+
+// GetAccount returns the sending status of the account in the client's
+// region.
+func (c *Client) GetAccount(ctx context.Context) (*Account, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"/v2/email/account", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GetAccount request: %w", err)
+	}
+	c.signer.Sign(req, aws.HashPayload(nil))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ses GetAccount failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode, Operation: "GetAccount", Type: resp.Header.Get("X-Amzn-Errortype")}
+		_ = json.Unmarshal(data, e)
+		e.Type, _, _ = strings.Cut(e.Type, ":")
+		return nil, e
+	}
+	var out struct {
+		ProductionAccessEnabled bool
+		SendingEnabled          bool
+		SendQuota               struct{ Max24HourSend float64 }
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to decode GetAccount response: %w", err)
+	}
+	return &Account{
+		ProductionAccessEnabled: out.ProductionAccessEnabled,
+		SendingEnabled:          out.SendingEnabled,
+		Max24HourSend:           out.SendQuota.Max24HourSend,
+	}, nil
+}
+
+// Error is an SES error response.
+type Error struct {
+	StatusCode int
+	Operation  string
+	Type       string `json:"-"`
+	Message    string `json:"message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("ses %s: HTTP %d %s: %s", e.Operation, e.StatusCode, e.Type, e.Message)
+}
@@ -0,0 +1,144 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package share
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// URLExpiry is how long download URLs returned for a share link remain
+// valid.
+const URLExpiry = time.Hour
+
+// Presigner creates download URLs.
+type Presigner interface {
+	PresignGetObject(bucket, key string, expires time.Duration) string
+}
+
+// Response is returned when a share link is resolved.
+type Response struct {
+	DatasetID   string         `json:"datasetId"`
+	DOI         string         `json:"doi,omitempty"`
+	Title       string         `json:"title"`
+	Version     int            `json:"version"`
+	LinkExpires time.Time      `json:"linkExpires"`
+	Files       []ResponseFile `json:"files"`
+}
+
+// ResponseFile is one downloadable file.
+type ResponseFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	URL  string `json:"url"`
+}
+
+// Handler serves GET /share/{token}, returning the dataset's latest
+// files with short-lived download URLs.
+type Handler struct {
+	links     *Registry
+	datasets  *dataset.Store
+	presigner Presigner
+	log       audit.Log
+	mux       *http.ServeMux
+
+	// OriginSecret is the secret a CloudFront distribution in front of
+	// the handler sends with its origin requests; the viewer country is
+	// unknown without it (see authz.ClientOf)
+	OriginSecret string
+}
+
+// NewHandler returns a handler resolving links in links against
+// datasets. Resolved links are recorded in log, which may be nil.
+func NewHandler(links *Registry, datasets *dataset.Store, presigner Presigner, log audit.Log) *Handler {
+	h := &Handler{links: links, datasets: datasets, presigner: presigner, log: log, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /share/{token}", h.serveLink)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) serveLink(w http.ResponseWriter, r *http.Request) {
+	ctx := audit.WithRequest(r)
+	l, err := h.links.Resolve(ctx, r.PathValue("token"))
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+		return
+	case errors.Is(err, ErrExpired), errors.Is(err, ErrRevoked):
+		writeError(w, http.StatusGone, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	d, err := h.datasets.Get(ctx, l.DatasetID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if d.State == dataset.StateTombstoned {
+		writeError(w, http.StatusGone, fmt.Errorf("%s has been withdrawn", d.ID))
+		return
+	}
+	// A share link stands in for the ACL, but not for export controls.
+	if err := authz.CheckClient(d.Resource(), authz.ClientOf(r, h.OriginSecret)); err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+
+	resp := Response{DatasetID: d.ID, DOI: d.DOI, Title: d.Title, LinkExpires: l.Expires, Files: []ResponseFile{}}
+	if v := d.Current(); v != nil {
+		resp.Version = v.Number
+		for _, f := range v.Files {
+			resp.Files = append(resp.Files, ResponseFile{
+				Path: f.Path,
+				Size: f.Size,
+				URL:  h.presigner.PresignGetObject(f.Bucket, f.Key, URLExpiry),
+			})
+		}
+	}
+	if h.log != nil {
+		err := audit.Record(ctx, h.log, "share.resolve", d.ID, map[string]string{
+			"link":   l.ID,
+			"client": r.RemoteAddr,
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,250 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package share issues time-limited share links for datasets.
+//
+// A share link is an opaque token that lets whoever holds it download a
+// dataset's files until the link expires or is revoked, typically so
+// reviewers can see a dataset before it is published. Only a hash of
+// each token is stored; the token itself is shown once, when the link
+// is created.
+package share
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// linksTable holds one Link per token hash.
+const linksTable = "share-links"
+
+// DefaultExpiry is how long links remain valid if no expiry is given.
+const DefaultExpiry = 30 * 24 * time.Hour
+
+// idLength is the number of token hash characters used as a link ID.
+const idLength = 12
+
+var (
+	// ErrNotFound is returned for unknown tokens and link IDs.
+	ErrNotFound = errors.New("share link not found")
+
+	// ErrExpired is returned when resolving an expired link.
+	ErrExpired = errors.New("share link expired")
+
+	// ErrRevoked is returned when resolving a revoked link.
+	ErrRevoked = errors.New("share link revoked")
+)
+
+// Link is a stored share link.
+type Link struct {
+	ID          string     `json:"id"`
+	TokenSHA256 string     `json:"tokenSha256"`
+	DatasetID   string     `json:"datasetId"`
+	Note        string     `json:"note,omitempty"`
+	CreatedBy   string     `json:"createdBy"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	Expires     time.Time  `json:"expires"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+	RevokedBy   string     `json:"revokedBy,omitempty"`
+}
+
+// Status describes l at time now: "active", "expired", or "revoked".
+func (l *Link) Status(now time.Time) string {
+	switch {
+	case l.RevokedAt != nil:
+		return "revoked"
+	case !now.Before(l.Expires):
+		return "expired"
+	}
+	return "active"
+}
+
+// Registry stores share links.
+type Registry struct {
+	s   state.Store
+	log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// NewRegistry returns a registry backed by s that records link changes
+// in log, which may be nil.
+func NewRegistry(s state.Store, log audit.Log) *Registry {
+	return &Registry{s: s, log: log}
+}
+
+// Create returns a new token for d valid for ttl (DefaultExpiry if
+// zero), and the stored link.
+func (r *Registry) Create(ctx context.Context, d *dataset.Dataset, ttl time.Duration, note string) (string, *Link, error) {
+	if ttl < 0 {
+		return "", nil, fmt.Errorf("invalid expiry %s", ttl)
+	}
+	if ttl == 0 {
+		ttl = DefaultExpiry
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	hash := hashToken(token)
+
+	now := r.now().UTC()
+	l := &Link{
+		ID:          hash[:idLength],
+		TokenSHA256: hash,
+		DatasetID:   d.ID,
+		Note:        strings.TrimSpace(note),
+		CreatedBy:   identity.FromContext(ctx).String(),
+		CreatedAt:   now,
+		Expires:     now.Add(ttl),
+	}
+	if err := r.s.Put(ctx, linksTable, hash, l); err != nil {
+		return "", nil, err
+	}
+	if err := r.record(ctx, "share.create", l); err != nil {
+		return "", nil, err
+	}
+	return token, l, nil
+}
+
+// Resolve returns the active link for token, or an error wrapping
+// ErrNotFound, ErrExpired, or ErrRevoked.
+func (r *Registry) Resolve(ctx context.Context, token string) (*Link, error) {
+	var l Link
+	err := r.s.Get(ctx, linksTable, hashToken(strings.TrimSpace(token)), &l)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch l.Status(r.now()) {
+	case "revoked":
+		return nil, fmt.Errorf("%w on %s", ErrRevoked, l.RevokedAt.Format(time.DateOnly))
+	case "expired":
+		return nil, fmt.Errorf("%w on %s", ErrExpired, l.Expires.Format(time.DateOnly))
+	}
+	return &l, nil
+}
+
+// List returns links for datasetID, or for all datasets if it is
+// empty, newest first.
+func (r *Registry) List(ctx context.Context, datasetID string) ([]Link, error) {
+	all, err := state.List[Link](ctx, r.s, linksTable)
+	if err != nil {
+		return nil, err
+	}
+	out := all[:0]
+	for _, l := range all {
+		if datasetID == "" || l.DatasetID == datasetID {
+			out = append(out, l)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+// Get returns the link with the given ID, ID prefix, or token.
+func (r *Registry) Get(ctx context.Context, ref string) (*Link, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, ErrNotFound
+	}
+	var l Link
+	err := r.s.Get(ctx, linksTable, hashToken(ref), &l)
+	if err == nil {
+		return &l, nil
+	}
+	if !errors.Is(err, state.ErrNotFound) {
+		return nil, err
+	}
+
+	all, err := state.List[Link](ctx, r.s, linksTable)
+	if err != nil {
+		return nil, err
+	}
+	var match *Link
+	for i := range all {
+		if strings.HasPrefix(all[i].ID, strings.ToLower(ref)) {
+			if match != nil {
+				return nil, fmt.Errorf("share link ID %q is ambiguous", ref)
+			}
+			match = &all[i]
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+	}
+	return match, nil
+}
+
+// Revoke disables the link with the given ID, ID prefix, or token.
+// Revoking a revoked link is a no-op.
+func (r *Registry) Revoke(ctx context.Context, ref string) (*Link, error) {
+	l, err := r.Get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if l.RevokedAt != nil {
+		return l, nil
+	}
+	now := r.now().UTC()
+	l.RevokedAt = &now
+	l.RevokedBy = identity.FromContext(ctx).String()
+	if err := r.s.Put(ctx, linksTable, l.TokenSHA256, l); err != nil {
+		return nil, err
+	}
+	if err := r.record(ctx, "share.revoke", l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (r *Registry) record(ctx context.Context, action string, l *Link) error {
+	if r.log == nil {
+		return nil
+	}
+	return audit.Record(ctx, r.log, action, l.DatasetID, map[string]string{
+		"link":    l.ID,
+		"expires": l.Expires.Format(time.RFC3339),
+	})
+}
+
+func (r *Registry) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// hashToken returns the hex-encoded SHA-256 of token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,132 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package share
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+type fakePresigner struct{}
+
+func (fakePresigner) PresignGetObject(bucket, key string, expires time.Duration) string {
+	return "https://" + bucket + "/" + key
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	r := NewRegistry(state.NewMemoryStore(), nil)
+	r.Now = func() time.Time { return now }
+	d := &dataset.Dataset{ID: "ds-1"}
+
+	token, l, err := r.Create(ctx, d, 0, "reviewer 2")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !l.Expires.Equal(now.Add(DefaultExpiry)) || l.TokenSHA256 == token {
+		t.Errorf("Create() = %+v", l)
+	}
+	if _, err := r.Resolve(ctx, token); err != nil {
+		t.Errorf("Resolve() error = %v", err)
+	}
+	if _, err := r.Resolve(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve(unknown) error = %v, want ErrNotFound", err)
+	}
+
+	now = now.Add(DefaultExpiry)
+	if _, err := r.Resolve(ctx, token); !errors.Is(err, ErrExpired) {
+		t.Errorf("Resolve() after expiry error = %v, want ErrExpired", err)
+	}
+
+	token2, l2, err := r.Create(ctx, d, time.Hour, "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := r.Revoke(ctx, l2.ID[:8]); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if _, err := r.Resolve(ctx, token2); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Resolve() after revoke error = %v, want ErrRevoked", err)
+	}
+
+	list, err := r.List(ctx, "ds-1")
+	if err != nil || len(list) != 2 || list[0].ID != l2.ID {
+		t.Errorf("List() = %+v, %v", list, err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	s := state.NewMemoryStore()
+	datasets := dataset.NewStore(s)
+	err := datasets.Put(ctx, &dataset.Dataset{
+		ID:     "ds-1",
+		Title:  "Draft",
+		Access: storage.AccessRestricted,
+		State:  dataset.StateDraft,
+		Versions: []dataset.Version{{
+			Number: 1,
+			Files:  []dataset.File{{Path: "a.csv", Bucket: "b", Key: "datasets/ds-1/v1/a.csv", Size: 3}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	links := NewRegistry(s, nil)
+	d, _ := datasets.Get(ctx, "ds-1")
+	token, l, err := links.Create(ctx, d, time.Hour, "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	h := NewHandler(links, datasets, fakePresigner{}, nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/share/"+token, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.DatasetID != "ds-1" || len(resp.Files) != 1 || resp.Files[0].URL != "https://b/datasets/ds-1/v1/a.csv" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	if _, err := links.Revoke(ctx, l.ID); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/share/"+token, nil))
+	if rec.Code != http.StatusGone {
+		t.Errorf("GET revoked status = %d, want %d", rec.Code, http.StatusGone)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/share/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET unknown status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snapshot preserves datasets whose source keeps changing, such
+// as an S3 prefix that instruments keep writing to or the nightly
+// exports of a database, as they were at a point in time.
+//
+// A snapshot lists the objects under the source prefix, records each
+// object's S3 version ID, and copies that version of it into the
+// repository as the files of a new draft version, hashing it on the
+// way. Publishing the version then makes it immutable, so that "the
+// data as of the paper submission" stays citable however the source
+// changes afterwards. Sources in versioned buckets are copied exactly;
+// an unversioned object that changes while it is copied fails the
+// snapshot, which can be retried.
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/blake3"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// ErrEmpty is returned when a source holds no objects.
+var ErrEmpty = errors.New("source has no objects")
+
+// ObjectStore reads sources and stores their copies. *s3.Client
+// implements it.
+type ObjectStore interface {
+	ListObjects(ctx context.Context, bucket, prefix string, fn func(s3.ObjectInfo) error) error
+	HeadObject(ctx context.Context, bucket, key string) (s3.ObjectInfo, error)
+	GetObjectVersion(ctx context.Context, bucket, key, versionID string) (io.ReadCloser, error)
+	CopyObjectVersion(ctx context.Context, srcBucket, srcKey, versionID, dstBucket, dstKey string) error
+}
+
+// Manager takes snapshots of dataset sources.
+type Manager struct {
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Objects reads sources and stores the copies
+	Objects ObjectStore
+
+	// Layout locates stored files
+	Layout storage.Layout
+
+	// Log records snapshots; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Options are the options of Take.
+type Options struct {
+	// Source is the S3 location to copy, s3://bucket/prefix; the
+	// dataset's recorded source if empty. It is recorded as the
+	// dataset's source for later snapshots.
+	Source string
+
+	// Label describes the state captured, e.g. "as submitted to
+	// Nature"
+	Label string
+}
+
+// ParseSource parses an S3 location, s3://bucket/prefix, returning its
+// bucket and prefix. A prefix naming a directory ends in a slash.
+func ParseSource(source string) (bucket, prefix string, err error) {
+	rest, ok := strings.CutPrefix(source, "s3://")
+	if !ok {
+		return "", "", fmt.Errorf("invalid source %q: want s3://bucket/prefix", source)
+	}
+	bucket, prefix, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("invalid source %q: want s3://bucket/prefix", source)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return bucket, prefix, nil
+}
+
+// Take copies the current objects of the dataset ref's source into a
+// new draft version and returns it. The principal in ctx must be
+// allowed to manage the dataset. The version is published, and becomes
+// immutable, with 'aperture dataset publish'; a dataset whose draft
+// already has files must publish it first.
+func (m *Manager) Take(ctx context.Context, ref string, opts Options) (*dataset.Dataset, *dataset.Version, error) {
+	d, err := m.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+		return nil, nil, err
+	}
+	if d.State == dataset.StateTombstoned {
+		return nil, nil, fmt.Errorf("%s has been tombstoned", d.ID)
+	}
+	source := opts.Source
+	if source == "" {
+		source = d.Source
+	}
+	if source == "" {
+		return nil, nil, fmt.Errorf("%s has no source; name one with --source s3://bucket/prefix", d.ID)
+	}
+	bucket, prefix, err := ParseSource(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	source = "s3://" + bucket + "/" + prefix
+
+	v := d.Draft()
+	switch {
+	case v != nil && len(v.Files) > 0:
+		return nil, nil, fmt.Errorf("%s already has draft version %d with %d files; publish it before taking a snapshot", d.ID, v.Number, len(v.Files))
+	case v == nil:
+		n := 1
+		if latest := d.Latest(); latest != nil {
+			n = latest.Number + 1
+		}
+		d.Versions = append(d.Versions, dataset.Version{Number: n})
+		v = &d.Versions[len(d.Versions)-1]
+	}
+
+	var keys []string
+	err = m.Objects.ListObjects(ctx, bucket, prefix, func(o s3.ObjectInfo) error {
+		// Zero-byte keys ending in a slash are folder markers.
+		if !strings.HasSuffix(o.Key, "/") {
+			keys = append(keys, o.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list %s: %w", source, err)
+	}
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrEmpty, source)
+	}
+
+	now := m.now().UTC()
+	files := make([]dataset.File, 0, len(keys))
+	var bytes int64
+	for _, key := range keys {
+		f, err := m.copy(ctx, d, v.Number, bucket, key, strings.TrimPrefix(key, prefix))
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+		bytes += f.Size
+	}
+	v.Files = files
+	v.Snapshot = &dataset.Snapshot{Source: source, Time: now, Label: strings.TrimSpace(opts.Label)}
+	d.Source = source
+
+	details := map[string]string{
+		"version": fmt.Sprint(v.Number),
+		"source":  source,
+		"files":   fmt.Sprint(len(files)),
+		"bytes":   fmt.Sprint(bytes),
+	}
+	if v.Snapshot.Label != "" {
+		details["label"] = v.Snapshot.Label
+	}
+	d.History = append(d.History, dataset.Event{
+		Time:    now,
+		Actor:   identity.FromContext(ctx).String(),
+		Action:  "dataset.snapshot",
+		Details: details,
+	})
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return nil, nil, err
+	}
+	if m.Log != nil {
+		if err := audit.Record(ctx, m.Log, "dataset.snapshot", d.ID, details); err != nil {
+			return nil, nil, err
+		}
+	}
+	return d, d.Version(v.Number), nil
+}
+
+// copy stores the current version of the source object key as file of
+// version n of d. The version is read once to be hashed and then copied
+// within S3, so that the digests describe what was copied.
+func (m *Manager) copy(ctx context.Context, d *dataset.Dataset, n int, bucket, key, file string) (dataset.File, error) {
+	src := storage.Location{Bucket: bucket, Key: key}
+	info, err := m.Objects.HeadObject(ctx, bucket, key)
+	if err != nil {
+		return dataset.File{}, fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	versionID := info.VersionID
+	if versionID == "null" {
+		versionID = ""
+	}
+
+	r, err := m.Objects.GetObjectVersion(ctx, bucket, key, versionID)
+	if err != nil {
+		return dataset.File{}, fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	h, b3 := sha256.New(), blake3.New()
+	size, err := io.Copy(io.MultiWriter(h, b3), r)
+	r.Close()
+	if err != nil {
+		return dataset.File{}, fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+
+	loc, err := m.Layout.Locate(storage.Object{Dataset: d.ID, Version: n, File: file, Collection: d.Collection, Access: d.Access, SHA256: digest})
+	if err != nil {
+		return dataset.File{}, err
+	}
+	// Content already stored under its digest is not copied again.
+	stored := false
+	if storage.ContentAddressed(m.Layout) {
+		_, err := m.Objects.HeadObject(ctx, loc.Bucket, loc.Key)
+		if err != nil && !errors.Is(err, s3.ErrNotFound) {
+			return dataset.File{}, fmt.Errorf("failed to check %s: %w", loc, err)
+		}
+		stored = err == nil
+	}
+	if !stored {
+		if err := m.Objects.CopyObjectVersion(ctx, bucket, key, versionID, loc.Bucket, loc.Key); err != nil {
+			return dataset.File{}, fmt.Errorf("failed to copy %s to %s: %w", src, loc, err)
+		}
+	}
+
+	// Without versions, the object must not have changed since it was
+	// read.
+	if versionID == "" {
+		now, err := m.Objects.HeadObject(ctx, bucket, key)
+		if err != nil {
+			return dataset.File{}, fmt.Errorf("failed to read %s: %w", src, err)
+		}
+		if now.ETag != info.ETag || now.Size != size {
+			return dataset.File{}, fmt.Errorf("%s changed while it was copied; retry the snapshot, or enable versioning on %s", src, bucket)
+		}
+	}
+
+	return dataset.File{
+		Path:          file,
+		Bucket:        loc.Bucket,
+		Key:           loc.Key,
+		Size:          size,
+		SHA256:        digest,
+		BLAKE3:        hex.EncodeToString(b3.Sum(nil)),
+		ContentType:   mime.TypeByExtension(path.Ext(file)),
+		SourceVersion: versionID,
+	}, nil
+}
+
+func (m *Manager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,192 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// object is the current version of a fake object.
+type object struct {
+	body, version string
+}
+
+// fakeObjects is an in-memory versioned ObjectStore keyed by
+// bucket/key, keeping every version written.
+type fakeObjects struct {
+	current  map[string]object
+	versions map[string]string
+}
+
+func newFakeObjects() *fakeObjects {
+	return &fakeObjects{current: map[string]object{}, versions: map[string]string{}}
+}
+
+func (f *fakeObjects) put(bucket, key, body, version string) {
+	f.current[bucket+"/"+key] = object{body, version}
+	f.versions[bucket+"/"+key+"?"+version] = body
+}
+
+func (f *fakeObjects) ListObjects(_ context.Context, bucket, prefix string, fn func(s3.ObjectInfo) error) error {
+	var keys []string
+	for k := range f.current {
+		if key, ok := strings.CutPrefix(k, bucket+"/"); ok && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(s3.ObjectInfo{Key: key, Size: int64(len(f.current[bucket+"/"+key].body))}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeObjects) HeadObject(_ context.Context, bucket, key string) (s3.ObjectInfo, error) {
+	o, ok := f.current[bucket+"/"+key]
+	if !ok {
+		return s3.ObjectInfo{}, s3.ErrNotFound
+	}
+	return s3.ObjectInfo{Key: key, Size: int64(len(o.body)), ETag: o.body, VersionID: o.version}, nil
+}
+
+func (f *fakeObjects) GetObjectVersion(_ context.Context, bucket, key, versionID string) (io.ReadCloser, error) {
+	body, ok := f.versions[bucket+"/"+key+"?"+versionID]
+	if !ok {
+		return nil, s3.ErrNotFound
+	}
+	return io.NopCloser(strings.NewReader(body)), nil
+}
+
+func (f *fakeObjects) CopyObjectVersion(_ context.Context, srcBucket, srcKey, versionID, dstBucket, dstKey string) error {
+	body, ok := f.versions[srcBucket+"/"+srcKey+"?"+versionID]
+	if !ok {
+		return s3.ErrNotFound
+	}
+	f.put(dstBucket, dstKey, body, "")
+	return nil
+}
+
+func newManager(t *testing.T) (*Manager, *fakeObjects) {
+	t.Helper()
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	d := &dataset.Dataset{ID: "ds-1", Title: "Sensor readings", State: dataset.StateDraft, Access: storage.AccessPublic,
+		ACL: &authz.ACL{Manage: []string{"user:pi@uni.edu"}}}
+	if err := datasets.Put(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	objects := newFakeObjects()
+	return &Manager{
+		Datasets: datasets,
+		Objects:  objects,
+		Layout:   &storage.PurposeLayout{Prefix: "ap"},
+		Now:      func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) },
+	}, objects
+}
+
+func TestTake(t *testing.T) {
+	m, objects := newManager(t)
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "pi@uni.edu"})
+	objects.put("lab", "sensors/2025-05-31.csv", "a,b\n", "v1")
+	objects.put("lab", "sensors/raw/2025-05-31.bin", "raw", "v7")
+	objects.put("lab", "other/x.csv", "x", "v1")
+
+	d, v, err := m.Take(ctx, "ds-1", Options{Source: "s3://lab/sensors", Label: "as submitted"})
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	if v.Number != 1 || len(v.Files) != 2 || v.Snapshot == nil || v.Snapshot.Source != "s3://lab/sensors/" || v.Snapshot.Label != "as submitted" {
+		t.Fatalf("Take() version = %+v", v)
+	}
+	f := v.Files[0]
+	if f.Path != "2025-05-31.csv" || f.SourceVersion != "v1" || f.Size != 4 || f.SHA256 == "" || f.BLAKE3 == "" || v.Files[1].Path != "raw/2025-05-31.bin" {
+		t.Errorf("files = %+v", v.Files)
+	}
+	if got := objects.current[f.Bucket+"/"+f.Key].body; got != "a,b\n" {
+		t.Errorf("copy of %s = %q", f.Path, got)
+	}
+	if d.Source != "s3://lab/sensors/" || len(d.History) != 1 || d.History[0].Action != "dataset.snapshot" {
+		t.Errorf("dataset = %+v", d)
+	}
+
+	// The draft must be published before the next snapshot, which then
+	// copies the source as it is by then, from the recorded source.
+	if _, _, err := m.Take(ctx, "ds-1", Options{}); err == nil {
+		t.Error("Take() over a draft with files succeeded")
+	}
+	published := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	d.Versions[0].PublishedAt = &published
+	d.State = dataset.StatePublished
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	objects.put("lab", "sensors/2025-05-31.csv", "a,b\n1,2\n", "v2")
+	d, v, err = m.Take(ctx, "ds-1", Options{})
+	if err != nil {
+		t.Fatalf("second Take() error = %v", err)
+	}
+	if v.Number != 2 || v.Files[0].SourceVersion != "v2" || v.Files[0].Size != 8 || d.Version(1).Files[0].Size != 4 {
+		t.Errorf("second snapshot = %+v, first %+v", v.Files, d.Version(1).Files)
+	}
+}
+
+func TestTakeErrors(t *testing.T) {
+	m, objects := newManager(t)
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "pi@uni.edu"})
+	if _, _, err := m.Take(ctx, "ds-1", Options{}); err == nil {
+		t.Error("Take() without a source succeeded")
+	}
+	if _, _, err := m.Take(ctx, "ds-1", Options{Source: "s3://lab/empty"}); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Take() of an empty source error = %v, want ErrEmpty", err)
+	}
+	objects.put("lab", "sensors/a.csv", "a", "v1")
+	outsider := identity.WithPrincipal(context.Background(), identity.Principal{ID: "eve@evil.example"})
+	if _, _, err := m.Take(outsider, "ds-1", Options{Source: "s3://lab/sensors"}); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Take() by an outsider error = %v, want ErrForbidden", err)
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		source, bucket, prefix string
+		ok                     bool
+	}{
+		{"s3://lab/sensors", "lab", "sensors/", true},
+		{"s3://lab/sensors/", "lab", "sensors/", true},
+		{"s3://lab", "lab", "", true},
+		{"lab/sensors", "", "", false},
+		{"s3:///sensors", "", "", false},
+	}
+	for _, tt := range tests {
+		bucket, prefix, err := ParseSource(tt.source)
+		if (err == nil) != tt.ok || bucket != tt.bucket || prefix != tt.prefix {
+			t.Errorf("ParseSource(%q) = %q, %q, %v", tt.source, bucket, prefix, err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package software
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/dataset"
+)
+
+// CodeMetaContext is the JSON-LD context of generated codemeta.json
+// files.
+const CodeMetaContext = "https://w3id.org/codemeta/3.0"
+
+// CodeMeta is a CodeMeta software description.
+type CodeMeta struct {
+	Context        string           `json:"@context"`
+	Type           string           `json:"@type"`
+	Name           string           `json:"name"`
+	Description    string           `json:"description,omitempty"`
+	Version        string           `json:"version"`
+	Identifier     string           `json:"identifier,omitempty"`
+	CodeRepository string           `json:"codeRepository"`
+	License        string           `json:"license,omitempty"`
+	DatePublished  string           `json:"datePublished,omitempty"`
+	Keywords       []string         `json:"keywords,omitempty"`
+	ReleaseNotes   string           `json:"releaseNotes,omitempty"`
+	Author         []CodeMetaPerson `json:"author"`
+}
+
+// CodeMetaPerson is an author in a CodeMeta description.
+type CodeMetaPerson struct {
+	Type        string                `json:"@type"`
+	ID          string                `json:"@id,omitempty"`
+	Name        string                `json:"name"`
+	Affiliation *CodeMetaOrganization `json:"affiliation,omitempty"`
+}
+
+// CodeMetaOrganization is an author's affiliation.
+type CodeMetaOrganization struct {
+	Type string `json:"@type"`
+	ID   string `json:"@id,omitempty"`
+	Name string `json:"name"`
+}
+
+// NewCodeMeta describes release rel of repo, recorded as d. The
+// identifier is the DOI citing every version of d.
+func NewCodeMeta(d *dataset.Dataset, repo *Repository, rel *Release) *CodeMeta {
+	cm := &CodeMeta{
+		Context:        CodeMetaContext,
+		Type:           "SoftwareSourceCode",
+		Name:           d.Title,
+		Description:    d.Description,
+		Version:        rel.TagName,
+		CodeRepository: repo.HTMLURL,
+		Keywords:       repo.Topics,
+		ReleaseNotes:   rel.Body,
+		Author:         []CodeMetaPerson{},
+	}
+	if d.DOI != "" {
+		cm.Identifier = "https://doi.org/" + d.DOI
+	}
+	if spdx := repo.SPDX(); spdx != "" {
+		cm.License = "https://spdx.org/licenses/" + spdx
+	}
+	if !rel.PublishedAt.IsZero() {
+		cm.DatePublished = rel.PublishedAt.UTC().Format(time.DateOnly)
+	}
+	for _, c := range d.Creators {
+		p := CodeMetaPerson{Type: "Person", Name: c.Name}
+		if c.ORCID != "" {
+			p.ID = "https://orcid.org/" + c.ORCID
+		}
+		if c.Affiliation != "" {
+			p.Affiliation = &CodeMetaOrganization{Type: "Organization", ID: c.AffiliationROR, Name: c.Affiliation}
+		}
+		cm.Author = append(cm.Author, p)
+	}
+	return cm
+}
+
+// Marshal returns cm as indented JSON.
+func (cm *CodeMeta) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(cm, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode codemeta.json: %w", err)
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package software
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultGitHubURL is the GitHub REST API.
+const DefaultGitHubURL = "https://api.github.com"
+
+// MaxArchiveBytes caps the size of a captured release archive.
+const MaxArchiveBytes = 1 << 30
+
+// GitHubOptions configures a GitHub client.
+type GitHubOptions struct {
+	// BaseURL is the GitHub API root; GitHub Enterprise servers use
+	// https://<host>/api/v3
+	BaseURL string
+
+	// Token authenticates requests, raising rate limits and giving
+	// access to private repositories; optional
+	Token string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// GitHub reads repositories and releases from the GitHub REST API.
+type GitHub struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewGitHub returns a client with the given options.
+func NewGitHub(opts GitHubOptions) *GitHub {
+	c := &GitHub{
+		baseURL: strings.TrimRight(opts.BaseURL, "/"),
+		token:   opts.Token,
+		http:    opts.HTTPClient,
+	}
+	if c.baseURL == "" {
+		c.baseURL = DefaultGitHubURL
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Repository is a GitHub repository.
+type Repository struct {
+	FullName    string   `json:"full_name"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	HTMLURL     string   `json:"html_url"`
+	Topics      []string `json:"topics"`
+	License     *struct {
+		SPDXID string `json:"spdx_id"`
+	} `json:"license"`
+}
+
+// SPDX returns the SPDX identifier of the repository's license, or ""
+// if GitHub did not recognize one.
+func (r *Repository) SPDX() string {
+	if r.License == nil || r.License.SPDXID == "NOASSERTION" {
+		return ""
+	}
+	return r.License.SPDXID
+}
+
+// Release is a GitHub release.
+type Release struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Body        string    `json:"body"`
+	HTMLURL     string    `json:"html_url"`
+	TarballURL  string    `json:"tarball_url"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// ParseRepository returns the owner and name of a repository given as
+// "owner/name" or as its URL.
+func ParseRepository(ref string) (owner, name string, err error) {
+	s := strings.TrimSpace(ref)
+	if u, perr := url.Parse(s); perr == nil && u.Host != "" {
+		s = u.Path
+	}
+	s = strings.TrimSuffix(strings.Trim(s, "/"), ".git")
+	owner, name, ok := strings.Cut(s, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("invalid repository %q (want OWNER/NAME or its URL)", ref)
+	}
+	return owner, name, nil
+}
+
+// Repository returns a repository.
+func (c *GitHub) Repository(ctx context.Context, owner, name string) (*Repository, error) {
+	var r Repository
+	if err := c.get(ctx, "/repos/"+url.PathEscape(owner)+"/"+url.PathEscape(name), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Release returns the release tagged tag.
+func (c *GitHub) Release(ctx context.Context, owner, name, tag string) (*Release, error) {
+	var r Release
+	if err := c.get(ctx, "/repos/"+url.PathEscape(owner)+"/"+url.PathEscape(name)+"/releases/tags/"+url.PathEscape(tag), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Download returns the content at rawURL, a release archive.
+func (c *GitHub) Download(ctx context.Context, rawURL string) ([]byte, error) {
+	resp, err := c.do(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxArchiveBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	if len(data) > MaxArchiveBytes {
+		return nil, fmt.Errorf("release archive exceeds %d bytes", MaxArchiveBytes)
+	}
+	return data, nil
+}
+
+// get fetches an API path and decodes the response into out.
+func (c *GitHub) get(ctx context.Context, path string, out any) error {
+	resp, err := c.do(ctx, c.baseURL+path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// do issues an authenticated GET, converting error statuses to errors.
+func (c *GitHub) do(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("github request failed: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, rawURL)
+		}
+		return nil, fmt.Errorf("github: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,422 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package software archives source code releases and cites them with
+// DOIs.
+//
+// Capturing a GitHub release stores its source archive and a generated
+// codemeta.json as a new version of a software record. Like Zenodo, the
+// record has a concept DOI citing every release, and each release has
+// its own version DOI, chained to the concept DOI and to the release
+// before it through DataCite related identifiers.
+package software
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/blake3"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// ResourceType is the DataCite resource type of software records.
+const ResourceType = "Software"
+
+var (
+	// ErrNotFound is returned when a repository or release does not
+	// exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrCaptured is returned when capturing a release twice.
+	ErrCaptured = errors.New("release already captured")
+)
+
+// Source reads releases from a code hosting service. *GitHub
+// implements it.
+type Source interface {
+	Repository(ctx context.Context, owner, name string) (*Repository, error)
+	Release(ctx context.Context, owner, name, tag string) (*Release, error)
+	Download(ctx context.Context, url string) ([]byte, error)
+}
+
+// ObjectStore stores captured files. *s3.Client implements it.
+type ObjectStore interface {
+	PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error
+	HeadObject(ctx context.Context, bucket, key string) (s3.ObjectInfo, error)
+}
+
+// PagePublisher renders a dataset's landing page.
+type PagePublisher interface {
+	Publish(ctx context.Context, datasetID string) (*landing.Change, error)
+}
+
+// Manager captures releases as versions of software records.
+type Manager struct {
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Source reads releases
+	Source Source
+
+	// Objects stores release archives and codemeta.json
+	Objects ObjectStore
+
+	// Layout locates stored files
+	Layout storage.Layout
+
+	// DOIs mints concept and version DOIs
+	DOIs pid.Minter
+
+	// SiteURL is the public base URL of landing pages
+	SiteURL string
+
+	// Publisher is registered as the publisher of every release
+	Publisher string
+
+	// Pages renders landing pages; skipped if nil
+	Pages PagePublisher
+
+	// Log records captures; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// CaptureOptions are the options of Capture.
+type CaptureOptions struct {
+	// Dataset is the ID of the software record; derived from the
+	// repository name if empty and no record of the repository exists
+	Dataset string
+
+	// Creators are the creators of a new record, in citation order
+	Creators []dataset.Creator
+
+	// Collection is the collection of a new record
+	Collection string
+}
+
+// Capture archives the release tagged tag of repo, given as
+// "owner/name" or its URL, as a new version of the repository's
+// software record, and mints its version DOI. The record is created,
+// with a concept DOI, on the first capture.
+func (m *Manager) Capture(ctx context.Context, repo, tag string, opts CaptureOptions) (*dataset.Dataset, *dataset.Version, error) {
+	if m.SiteURL == "" {
+		return nil, nil, fmt.Errorf("site URL not configured; set APERTURE_SITE_URL")
+	}
+	owner, name, err := ParseRepository(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := m.Source.Repository(ctx, owner, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read repository %s/%s: %w", owner, name, err)
+	}
+	rel, err := m.Source.Release(ctx, owner, name, tag)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read release %s of %s: %w", tag, r.FullName, err)
+	}
+	if rel.Draft {
+		return nil, nil, fmt.Errorf("release %s of %s is a draft", tag, r.FullName)
+	}
+	d, err := m.find(ctx, r, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range d.Versions {
+		if v.Tag == rel.TagName {
+			return nil, nil, fmt.Errorf("%w: %s %s is version %d of %s", ErrCaptured, r.FullName, rel.TagName, v.Number, d.ID)
+		}
+	}
+
+	v := dataset.Version{Number: 1, Tag: rel.TagName}
+	prev := d.Latest()
+	if prev != nil {
+		v.Number = prev.Number + 1
+	}
+	published := rel.PublishedAt.UTC()
+	if published.IsZero() {
+		published = m.now().UTC()
+	}
+	v.PublishedAt = &published
+	archive, err := m.Source.Download(ctx, rel.TarballURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := m.store(ctx, d, v.Number, archiveName(name, rel.TagName), archive, "application/gzip")
+	if err != nil {
+		return nil, nil, err
+	}
+	v.Files = append(v.Files, f)
+
+	// The concept DOI is saved as soon as it is minted, so that a
+	// failed capture retried later does not mint another.
+	if d.DOI == "" {
+		doi, err := m.DOIs.Mint(ctx, m.conceptRecord(d))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to mint concept DOI for %s: %w", d.ID, err)
+		}
+		d.DOI = dataset.NormalizeDOI(doi)
+		if err := m.Datasets.Put(ctx, d); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	cm, err := NewCodeMeta(d, r, rel).Marshal()
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err = m.store(ctx, d, v.Number, "codemeta.json", cm, "application/json")
+	if err != nil {
+		return nil, nil, err
+	}
+	v.Files = append(v.Files, f)
+
+	doi, err := m.DOIs.Mint(ctx, m.versionRecord(d, &v, prev, ""))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to mint DOI for %s %s: %w", d.ID, rel.TagName, err)
+	}
+	v.DOI = dataset.NormalizeDOI(doi)
+	d.State = dataset.StatePublished
+	if d.PublicationYear == 0 {
+		d.PublicationYear = published.Year()
+	}
+	v.Metadata = d.Metadata()
+	d.Versions = append(d.Versions, v)
+	details := map[string]string{"tag": v.Tag, "version": fmt.Sprint(v.Number), "doi": v.DOI}
+	d.History = append(d.History, dataset.Event{
+		Time:    m.now().UTC(),
+		Actor:   identity.FromContext(ctx).String(),
+		Action:  "software.capture",
+		Details: details,
+	})
+	if err := m.Datasets.Put(ctx, d); err != nil {
+		return nil, nil, err
+	}
+	if err := m.record(ctx, d.ID, details); err != nil {
+		return nil, nil, err
+	}
+
+	// The new DOI is registered; the DOIs it relates to are updated to
+	// point back at it.
+	if prev != nil && prev.DOI != "" {
+		if err := m.DOIs.Update(ctx, prev.DOI, m.versionRecord(d, prev, d.Version(prev.Number-1), v.DOI)); err != nil {
+			return d, &v, fmt.Errorf("%s %s is captured, but failed to link it from %s: %w", d.ID, v.Tag, prev.DOI, err)
+		}
+	}
+	if err := m.DOIs.Update(ctx, d.DOI, m.conceptRecord(d)); err != nil {
+		return d, &v, fmt.Errorf("%s %s is captured, but failed to update concept DOI %s: %w", d.ID, v.Tag, d.DOI, err)
+	}
+	if m.Pages != nil {
+		if _, err := m.Pages.Publish(ctx, d.ID); err != nil {
+			return d, &v, fmt.Errorf("%s %s is captured, but failed to publish its landing page: %w", d.ID, v.Tag, err)
+		}
+	}
+	return d, &v, nil
+}
+
+// find returns the software record of r, or a new one if the
+// repository has not been captured before.
+func (m *Manager) find(ctx context.Context, r *Repository, opts CaptureOptions) (*dataset.Dataset, error) {
+	p := identity.FromContext(ctx)
+	var d *dataset.Dataset
+	if opts.Dataset != "" {
+		found, err := m.Datasets.Get(ctx, opts.Dataset)
+		if err != nil && !errors.Is(err, dataset.ErrNotFound) {
+			return nil, err
+		}
+		d = found
+	} else {
+		all, err := m.Datasets.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, ds := range all {
+			if ds.Software != nil && ds.Software.Repository == r.HTMLURL {
+				d = ds
+				break
+			}
+		}
+	}
+	if d != nil {
+		if d.Software == nil || d.Software.Repository != r.HTMLURL {
+			return nil, fmt.Errorf("%s is not the software record of %s", d.ID, r.HTMLURL)
+		}
+		if err := authz.Require(p, d.Resource(), authz.ActionManage); err != nil {
+			return nil, err
+		}
+		if spdx := r.SPDX(); spdx != "" {
+			d.Software.License = spdx
+		}
+		return d, nil
+	}
+
+	if p.IsZero() {
+		return nil, fmt.Errorf("%w: software records must be created by a signed-in principal", authz.ErrForbidden)
+	}
+	if len(opts.Creators) == 0 {
+		return nil, fmt.Errorf("%s has not been captured before; name its creators", r.FullName)
+	}
+	id := opts.Dataset
+	if id == "" {
+		id = recordID(r.FullName)
+		if _, err := m.Datasets.Get(ctx, id); err == nil {
+			return nil, fmt.Errorf("dataset %s already exists; choose another ID", id)
+		}
+	}
+	return &dataset.Dataset{
+		ID:           id,
+		Title:        r.Name,
+		Description:  r.Description,
+		Creators:     opts.Creators,
+		Collection:   opts.Collection,
+		ResourceType: ResourceType,
+		Software:     &dataset.Software{Repository: r.HTMLURL, License: r.SPDX()},
+		Owner:        identity.Normalize(p.ID),
+		Access:       storage.AccessPublic,
+		ACL:          &authz.ACL{Manage: []string{"user:" + identity.Normalize(p.ID)}},
+		State:        dataset.StateDraft,
+	}, nil
+}
+
+// store uploads a file of version n of d.
+func (m *Manager) store(ctx context.Context, d *dataset.Dataset, n int, file string, body []byte, contentType string) (dataset.File, error) {
+	sum, b3 := sha256.Sum256(body), blake3.Sum256(body)
+	digest := hex.EncodeToString(sum[:])
+	loc, err := m.Layout.Locate(storage.Object{Dataset: d.ID, Version: n, File: file, Collection: d.Collection, Access: d.Access, SHA256: digest})
+	if err != nil {
+		return dataset.File{}, err
+	}
+	// Content already stored under its digest is not uploaded again.
+	stored := false
+	if storage.ContentAddressed(m.Layout) {
+		_, err := m.Objects.HeadObject(ctx, loc.Bucket, loc.Key)
+		if err != nil && !errors.Is(err, s3.ErrNotFound) {
+			return dataset.File{}, fmt.Errorf("failed to check %s: %w", loc, err)
+		}
+		stored = err == nil
+	}
+	if !stored {
+		if err := m.Objects.PutObject(ctx, loc.Bucket, loc.Key, body, contentType); err != nil {
+			return dataset.File{}, fmt.Errorf("failed to store %s: %w", loc, err)
+		}
+	}
+	return dataset.File{
+		Path:        file,
+		Bucket:      loc.Bucket,
+		Key:         loc.Key,
+		Size:        int64(len(body)),
+		SHA256:      digest,
+		BLAKE3:      hex.EncodeToString(b3[:]),
+		ContentType: contentType,
+	}, nil
+}
+
+// conceptRecord returns the metadata of d's concept DOI, which has
+// every captured version.
+func (m *Manager) conceptRecord(d *dataset.Dataset) pid.Record {
+	rec := m.base(d)
+	for _, v := range d.Versions {
+		if v.DOI != "" {
+			rec.Related = append(rec.Related, pid.Related{Relation: "HasVersion", ID: v.DOI, Type: "DOI"})
+		}
+	}
+	return rec
+}
+
+// versionRecord returns the metadata of v's DOI: a version of the
+// concept DOI following prev and, once there is one, followed by the
+// version DOI next.
+func (m *Manager) versionRecord(d *dataset.Dataset, v, prev *dataset.Version, next string) pid.Record {
+	rec := m.base(d)
+	rec.Version = v.Tag
+	if v.PublishedAt != nil {
+		rec.Year = v.PublishedAt.Year()
+	}
+	rec.Related = append(rec.Related, pid.Related{Relation: "IsVersionOf", ID: d.DOI, Type: "DOI"})
+	if prev != nil && prev.DOI != "" {
+		rec.Related = append(rec.Related, pid.Related{Relation: "IsNewVersionOf", ID: prev.DOI, Type: "DOI"})
+	}
+	if next != "" {
+		rec.Related = append(rec.Related, pid.Related{Relation: "IsPreviousVersionOf", ID: next, Type: "DOI"})
+	}
+	if d.Software != nil {
+		release := d.Software.Repository + "/releases/tag/" + url.PathEscape(v.Tag)
+		rec.Related = append(rec.Related, pid.Related{Relation: "IsSupplementTo", ID: release, Type: "URL"})
+	}
+	return rec
+}
+
+// base returns the metadata shared by all of d's DOIs.
+func (m *Manager) base(d *dataset.Dataset) pid.Record {
+	rec := pid.Record{
+		Title:        d.Title,
+		Publisher:    m.Publisher,
+		Year:         d.PublicationYear,
+		URL:          strings.TrimRight(m.SiteURL, "/") + landing.PagePath(d.ID),
+		ResourceType: ResourceType,
+	}
+	if rec.Year == 0 {
+		rec.Year = m.now().UTC().Year()
+	}
+	for _, c := range d.Creators {
+		rec.Creators = append(rec.Creators, pid.Creator{Name: c.Name, ORCID: c.ORCID, Affiliation: c.Affiliation, AffiliationROR: c.AffiliationROR})
+	}
+	return rec
+}
+
+// recordID derives a dataset ID from a repository's full name.
+func recordID(fullName string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '-'
+	}, fullName)
+}
+
+// archiveName returns the file name of a release archive.
+func archiveName(repo, tag string) string {
+	return repo + "-" + strings.ReplaceAll(tag, "/", "-") + ".tar.gz"
+}
+
+func (m *Manager) record(ctx context.Context, target string, details map[string]string) error {
+	if m.Log == nil {
+		return nil
+	}
+	return audit.Record(ctx, m.Log, "software.capture", target, details)
+}
+
+func (m *Manager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
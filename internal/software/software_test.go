@@ -0,0 +1,258 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package software
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/pid"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// fakeGitHub serves one repository with releases v1.0.0 and v1.1.0.
+func fakeGitHub(t *testing.T) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/lab/sieve", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ghp_test" {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"full_name":"lab/sieve","name":"sieve","description":"Particle sieving","html_url":"https://github.com/lab/sieve","topics":["soil"],"license":{"spdx_id":"MIT"}}`)
+	})
+	mux.HandleFunc("GET /repos/lab/sieve/releases/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		tag := r.PathValue("tag")
+		if tag != "v1.0.0" && tag != "v1.1.0" && tag != "v2.0.0-draft" {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(Release{
+			TagName:     tag,
+			HTMLURL:     "https://github.com/lab/sieve/releases/tag/" + tag,
+			TarballURL:  srv.URL + "/tarball/" + tag,
+			Draft:       strings.HasSuffix(tag, "-draft"),
+			PublishedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		})
+	})
+	mux.HandleFunc("GET /tarball/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "archive of %s", r.PathValue("tag"))
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// fakeObjects records stored objects.
+type fakeObjects map[string][]byte
+
+func (f fakeObjects) PutObject(_ context.Context, bucket, key string, body []byte, _ string) error {
+	f[bucket+"/"+key] = body
+	return nil
+}
+
+func (f fakeObjects) HeadObject(_ context.Context, bucket, key string) (s3.ObjectInfo, error) {
+	body, ok := f[bucket+"/"+key]
+	if !ok {
+		return s3.ObjectInfo{}, s3.ErrNotFound
+	}
+	return s3.ObjectInfo{Key: key, Size: int64(len(body))}, nil
+}
+
+// fakeMinter mints sequential DOIs and records registered metadata.
+type fakeMinter struct {
+	records map[string]pid.Record
+	fail    bool
+}
+
+func (f *fakeMinter) Scheme() pid.Scheme { return pid.SchemeDOI }
+
+func (f *fakeMinter) Mint(_ context.Context, r pid.Record) (string, error) {
+	if f.fail {
+		return "", errors.New("datacite unavailable")
+	}
+	id := fmt.Sprintf("10.1234/sw%d", len(f.records)+1)
+	f.records[id] = r
+	return id, nil
+}
+
+func (f *fakeMinter) Update(_ context.Context, id string, r pid.Record) error {
+	if _, ok := f.records[id]; !ok {
+		return fmt.Errorf("unknown DOI %s", id)
+	}
+	f.records[id] = r
+	return nil
+}
+
+// relations returns the relations registered with id.
+func (f *fakeMinter) relations(id string) []string {
+	var out []string
+	for _, r := range f.records[id].Related {
+		out = append(out, r.Relation+" "+r.ID)
+	}
+	return out
+}
+
+func TestParseRepository(t *testing.T) {
+	tests := []struct {
+		in        string
+		wantOwner string
+		wantName  string
+		wantErr   bool
+	}{
+		{"lab/sieve", "lab", "sieve", false},
+		{"https://github.com/lab/sieve", "lab", "sieve", false},
+		{"https://github.com/lab/sieve.git", "lab", "sieve", false},
+		{"lab", "", "", true},
+		{"lab/sieve/tree/main", "", "", true},
+	}
+	for _, tt := range tests {
+		owner, name, err := ParseRepository(tt.in)
+		if (err != nil) != tt.wantErr || owner != tt.wantOwner || name != tt.wantName {
+			t.Errorf("ParseRepository(%q) = %q, %q, %v, want %q, %q", tt.in, owner, name, err, tt.wantOwner, tt.wantName)
+		}
+	}
+}
+
+func TestCapture(t *testing.T) {
+	srv := fakeGitHub(t)
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "dev@uni.edu"})
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	objects := fakeObjects{}
+	minter := &fakeMinter{records: map[string]pid.Record{}}
+	log := &audit.MemoryLog{}
+	m := &Manager{
+		Datasets:  datasets,
+		Source:    NewGitHub(GitHubOptions{BaseURL: srv.URL, Token: "ghp_test"}),
+		Objects:   objects,
+		Layout:    &storage.PurposeLayout{Prefix: "ap-prod"},
+		DOIs:      minter,
+		SiteURL:   "https://data.uni.edu",
+		Publisher: "Uni",
+		Log:       log,
+	}
+
+	if _, _, err := m.Capture(ctx, "lab/sieve", "v1.0.0", CaptureOptions{}); err == nil {
+		t.Error("Capture() of a new repository without creators succeeded, want error")
+	}
+	opts := CaptureOptions{Creators: []dataset.Creator{{Name: "Doe, Jane", ORCID: "0000-0002-1825-0097"}}}
+	d, v, err := m.Capture(ctx, "https://github.com/lab/sieve", "v1.0.0", opts)
+	if err != nil {
+		t.Fatalf("Capture() error = %v", err)
+	}
+	if d.ID != "lab-sieve" || d.ResourceType != ResourceType || d.State != dataset.StatePublished || d.Software.License != "MIT" {
+		t.Errorf("Capture() record = %+v", d)
+	}
+	if d.DOI != "10.1234/sw1" || v.DOI != "10.1234/sw2" || v.Tag != "v1.0.0" || len(v.Files) != 2 {
+		t.Errorf("Capture() = concept %s, version %+v", d.DOI, v)
+	}
+	if got := string(objects["ap-prod-public-media/datasets/lab-sieve/v1/sieve-v1.0.0.tar.gz"]); got != "archive of v1.0.0" {
+		t.Errorf("stored archive = %q", got)
+	}
+	var cm CodeMeta
+	if err := json.Unmarshal(objects["ap-prod-public-media/datasets/lab-sieve/v1/codemeta.json"], &cm); err != nil {
+		t.Fatalf("codemeta.json: %v", err)
+	}
+	if cm.Version != "v1.0.0" || cm.Identifier != "https://doi.org/10.1234/sw1" || cm.License != "https://spdx.org/licenses/MIT" || len(cm.Author) != 1 || cm.Author[0].ID != "https://orcid.org/0000-0002-1825-0097" {
+		t.Errorf("codemeta.json = %+v", cm)
+	}
+	if rec := minter.records[v.DOI]; rec.ResourceType != "Software" || rec.Version != "v1.0.0" || rec.Year != 2025 {
+		t.Errorf("version DOI record = %+v", rec)
+	}
+
+	if _, _, err := m.Capture(ctx, "lab/sieve", "v1.0.0", CaptureOptions{}); !errors.Is(err, ErrCaptured) {
+		t.Errorf("Capture() of a captured release error = %v, want ErrCaptured", err)
+	}
+	if _, _, err := m.Capture(ctx, "lab/sieve", "v2.0.0-draft", CaptureOptions{}); err == nil {
+		t.Error("Capture() of a draft release succeeded, want error")
+	}
+	if _, _, err := m.Capture(ctx, "lab/sieve", "v9", CaptureOptions{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Capture() of a missing release error = %v, want ErrNotFound", err)
+	}
+
+	// The next release is found by repository and chained to the first.
+	d, v, err = m.Capture(ctx, "lab/sieve", "v1.1.0", CaptureOptions{})
+	if err != nil {
+		t.Fatalf("Capture() error = %v", err)
+	}
+	if v.Number != 2 || v.DOI != "10.1234/sw3" || len(d.Versions) != 2 {
+		t.Fatalf("Capture() second release = %+v", v)
+	}
+	tests := []struct {
+		doi  string
+		want []string
+	}{
+		{"10.1234/sw1", []string{"HasVersion 10.1234/sw2", "HasVersion 10.1234/sw3"}},
+		{"10.1234/sw2", []string{"IsVersionOf 10.1234/sw1", "IsPreviousVersionOf 10.1234/sw3", "IsSupplementTo https://github.com/lab/sieve/releases/tag/v1.0.0"}},
+		{"10.1234/sw3", []string{"IsVersionOf 10.1234/sw1", "IsNewVersionOf 10.1234/sw2", "IsSupplementTo https://github.com/lab/sieve/releases/tag/v1.1.0"}},
+	}
+	for _, tt := range tests {
+		if got := minter.relations(tt.doi); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("relations of %s = %v, want %v", tt.doi, got, tt.want)
+		}
+	}
+	if got, err := datasets.ByDOI(ctx, "10.1234/sw3"); err != nil || got.ID != "lab-sieve" {
+		t.Errorf("ByDOI(version DOI) = %v, %v", got, err)
+	}
+	if entries, _ := log.Entries(ctx); len(entries) != 2 {
+		t.Errorf("audit entries = %d, want 2", len(entries))
+	}
+
+	other := identity.WithPrincipal(context.Background(), identity.Principal{ID: "someone@uni.edu"})
+	if _, _, err := m.Capture(other, "lab/sieve", "v1.0.0", CaptureOptions{}); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Capture() by a non-manager error = %v, want ErrForbidden", err)
+	}
+}
+
+func TestCaptureMintFailure(t *testing.T) {
+	srv := fakeGitHub(t)
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "dev@uni.edu"})
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	minter := &fakeMinter{records: map[string]pid.Record{}, fail: true}
+	m := &Manager{
+		Datasets: datasets,
+		Source:   NewGitHub(GitHubOptions{BaseURL: srv.URL, Token: "ghp_test"}),
+		Objects:  fakeObjects{},
+		Layout:   &storage.PurposeLayout{Prefix: "ap-prod"},
+		DOIs:     minter,
+		SiteURL:  "https://data.uni.edu",
+	}
+	opts := CaptureOptions{Dataset: "sieve", Creators: []dataset.Creator{{Name: "Doe, Jane"}}}
+	if _, _, err := m.Capture(ctx, "lab/sieve", "v1.0.0", opts); err == nil {
+		t.Fatal("Capture() with failing minter succeeded, want error")
+	}
+	if _, err := datasets.Get(ctx, "sieve"); !errors.Is(err, dataset.ErrNotFound) {
+		t.Errorf("Get() after failed capture error = %v, want ErrNotFound", err)
+	}
+	minter.fail = false
+	d, v, err := m.Capture(ctx, "lab/sieve", "v1.0.0", opts)
+	if err != nil || d.ID != "sieve" || v.Number != 1 {
+		t.Errorf("Capture() retry = %v, %v, %v", d, v, err)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqs is a minimal Amazon SQS client for the platform's work
+// queues, speaking the AWS JSON protocol.
+package sqs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+// targetPrefix prefixes the X-Amz-Target of every operation.
+const targetPrefix = "AmazonSQS."
+
+// ErrNoQueue is returned for a queue that does not exist.
+var ErrNoQueue = errors.New("sqs: queue does not exist")
+
+// Options configures a Client.
+type Options struct {
+	// Region is the AWS region of the queues
+	Region string
+
+	// Endpoint overrides the regional AWS endpoint
+	Endpoint string
+
+	// Credentials sign requests
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is an SQS client.
+type Client struct {
+	endpoint string
+	signer   *aws.Signer
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://sqs." + opts.Region + ".amazonaws.com"
+	}
+	c := &Client{
+		endpoint: strings.TrimRight(endpoint, "/") + "/",
+		signer:   &aws.Signer{Credentials: opts.Credentials, Region: opts.Region, Service: "sqs"},
+		http:     opts.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// Message is a received message.
+type Message struct {
+	ID   string
+	Body string
+
+	// ReceiptHandle identifies this receipt of the message, to delete
+	// it
+	ReceiptHandle string
+
+	// ReceiveCount is how many times the message was received,
+	// this time included
+	ReceiveCount int
+}
+
+// CreateQueue creates the queue name with attributes, such as
+// VisibilityTimeout or RedrivePolicy, and returns its URL. Creating a
+// queue that exists with the same attributes returns its URL.
+func (c *Client) CreateQueue(ctx context.Context, name string, attributes map[string]string) (string, error) {
+	in := map[string]any{"QueueName": name}
+	if len(attributes) > 0 {
+		in["Attributes"] = attributes
+	}
+	var out struct{ QueueUrl string }
+	if err := c.do(ctx, "CreateQueue", in, &out); err != nil {
+		return "", err
+	}
+	return out.QueueUrl, nil
+}
+
+// QueueURL returns the URL of the queue name. It returns an error
+// wrapping ErrNoQueue if there is none.
+func (c *Client) QueueURL(ctx context.Context, name string) (string, error) {
+	var out struct{ QueueUrl string }
+	if err := c.do(ctx, "GetQueueUrl", map[string]any{"QueueName": name}, &out); err != nil {
+		return "", err
+	}
+	return out.QueueUrl, nil
+}
+
+// SendMessage adds a message with body to the queue at url and returns
+// its ID.
+func (c *Client) SendMessage(ctx context.Context, url, body string) (string, error) {
+	var out struct{ MessageId string }
+	if err := c.do(ctx, "SendMessage", map[string]any{"QueueUrl": url, "MessageBody": body}, &out); err != nil {
+		return "", err
+	}
+	return out.MessageId, nil
+}
+
+// ReceiveMessage receives up to max messages from the queue at url,
+// waiting up to wait for one to arrive, and hides them from other
+// receivers for visibility, or the queue's visibility timeout if zero.
+func (c *Client) ReceiveMessage(ctx context.Context, url string, max int, wait, visibility time.Duration) ([]Message, error) {
+	in := map[string]any{
+		"QueueUrl":                    url,
+		"MaxNumberOfMessages":         max,
+		"WaitTimeSeconds":             int(wait / time.Second),
+		"AttributeNames":              []string{"ApproximateReceiveCount"},
+		"MessageSystemAttributeNames": []string{"ApproximateReceiveCount"},
+	}
+	if visibility > 0 {
+		in["VisibilityTimeout"] = int(visibility / time.Second)
+	}
+	var out struct {
+		Messages []struct {
+			MessageId     string
+			ReceiptHandle string
+			Body          string
+			Attributes    map[string]string
+		}
+	}
+	if err := c.do(ctx, "ReceiveMessage", in, &out); err != nil {
+		return nil, err
+	}
+	msgs := make([]Message, 0, len(out.Messages))
+	for _, m := range out.Messages {
+		count, _ := strconv.Atoi(m.Attributes["ApproximateReceiveCount"])
+		msgs = append(msgs, Message{ID: m.MessageId, Body: m.Body, ReceiptHandle: m.ReceiptHandle, ReceiveCount: count})
+	}
+	return msgs, nil
+}
+
+// DeleteMessage removes the message received with receiptHandle from the
+// queue at url.
+func (c *Client) DeleteMessage(ctx context.Context, url, receiptHandle string) error {
+	return c.do(ctx, "DeleteMessage", map[string]any{"QueueUrl": url, "ReceiptHandle": receiptHandle}, nil)
+}
+
+// ApproximateDepth returns the approximate number of messages waiting
+// in the queue at url, not counting those being processed.
+func (c *Client) ApproximateDepth(ctx context.Context, url string) (int, error) {
+	in := map[string]any{"QueueUrl": url, "AttributeNames": []string{"ApproximateNumberOfMessages"}}
+	var out struct{ Attributes map[string]string }
+	if err := c.do(ctx, "GetQueueAttributes", in, &out); err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(out.Attributes["ApproximateNumberOfMessages"])
+	if err != nil {
+		return 0, fmt.Errorf("invalid queue depth %q", out.Attributes["ApproximateNumberOfMessages"])
+	}
+	return n, nil
+}
+
+// do calls operation with in as the JSON request and decodes the
+// response into out, unless out is nil.
+func (c *Client) do(ctx context.Context, operation string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", operation, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", operation, err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", targetPrefix+operation)
+	c.signer.Sign(req, aws.HashPayload(body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("sqs %s failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode, Operation: operation}
+		_ = json.Unmarshal(data, e)
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", operation, err)
+	}
+	return nil
+}
+
+// Error is an SQS error response.
+type Error struct {
+	StatusCode int
+	Operation  string
+	Type       string `json:"__type"`
+	Message    string `json:"message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("sqs %s: HTTP %d %s: %s", e.Operation, e.StatusCode, e.Type, e.Message)
+}
+
+// Unwrap maps missing queues to ErrNoQueue.
+func (e *Error) Unwrap() error {
+	if e.Type == "QueueDoesNotExist" || e.Type == "AWS.SimpleQueueService.NonExistentQueue" {
+		return ErrNoQueue
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return NewClient(Options{
+		Region:      "us-east-1",
+		Endpoint:    srv.URL,
+		Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+}
+
+func TestMessages(t *testing.T) {
+	const url = "https://sqs.us-east-1.amazonaws.com/123456789012/aperture-dev-index"
+	var deleted string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]any
+		json.NewDecoder(r.Body).Decode(&in)
+		if in["QueueUrl"] != nil && in["QueueUrl"] != url {
+			t.Errorf("QueueUrl = %v", in["QueueUrl"])
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case targetPrefix + "SendMessage":
+			if in["MessageBody"] != `{"dataset":"ds-1"}` {
+				t.Errorf("MessageBody = %v", in["MessageBody"])
+			}
+			fmt.Fprint(w, `{"MessageId": "m-1"}`)
+		case targetPrefix + "ReceiveMessage":
+			if in["WaitTimeSeconds"] != 20.0 || in["VisibilityTimeout"] != 60.0 {
+				t.Errorf("ReceiveMessage request = %v", in)
+			}
+			fmt.Fprint(w, `{"Messages": [{"MessageId": "m-1", "ReceiptHandle": "h-1", "Body": "{}", "Attributes": {"ApproximateReceiveCount": "2"}}]}`)
+		case targetPrefix + "DeleteMessage":
+			deleted, _ = in["ReceiptHandle"].(string)
+		case targetPrefix + "GetQueueAttributes":
+			fmt.Fprint(w, `{"Attributes": {"ApproximateNumberOfMessages": "7"}}`)
+		default:
+			t.Errorf("unexpected target %s", r.Header.Get("X-Amz-Target"))
+		}
+	})
+	ctx := context.Background()
+	if id, err := c.SendMessage(ctx, url, `{"dataset":"ds-1"}`); err != nil || id != "m-1" {
+		t.Errorf("SendMessage() = %q, %v", id, err)
+	}
+	msgs, err := c.ReceiveMessage(ctx, url, 10, 20*time.Second, time.Minute)
+	if err != nil || len(msgs) != 1 || msgs[0].ReceiptHandle != "h-1" || msgs[0].ReceiveCount != 2 {
+		t.Fatalf("ReceiveMessage() = %+v, %v", msgs, err)
+	}
+	if err := c.DeleteMessage(ctx, url, msgs[0].ReceiptHandle); err != nil || deleted != "h-1" {
+		t.Errorf("DeleteMessage() error = %v, deleted %q", err, deleted)
+	}
+	if n, err := c.ApproximateDepth(ctx, url); err != nil || n != 7 {
+		t.Errorf("ApproximateDepth() = %d, %v", n, err)
+	}
+}
+
+func TestQueueURL(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ QueueName string }
+		json.NewDecoder(r.Body).Decode(&in)
+		if in.QueueName != "aperture-dev-index" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"__type": "com.amazonaws.sqs#QueueDoesNotExist", "message": "The specified queue does not exist."}`)
+			return
+		}
+		fmt.Fprint(w, `{"QueueUrl": "http://localhost:4566/000000000000/aperture-dev-index"}`)
+	})
+	ctx := context.Background()
+	if url, err := c.QueueURL(ctx, "aperture-dev-index"); err != nil || url != "http://localhost:4566/000000000000/aperture-dev-index" {
+		t.Errorf("QueueURL() = %q, %v", url, err)
+	}
+	if _, err := c.QueueURL(ctx, "aperture-dev-missing"); !errors.Is(err, ErrNoQueue) {
+		t.Errorf("QueueURL() of a missing queue error = %v, want ErrNoQueue", err)
+	}
+}
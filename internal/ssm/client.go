@@ -0,0 +1,174 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ssm is a minimal AWS Systems Manager client for reading and
+// writing Parameter Store parameters.
+package ssm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+// targetPrefix prefixes the X-Amz-Target of every operation.
+const targetPrefix = "AmazonSSM."
+
+// Options configures a Client.
+type Options struct {
+	// Region is the AWS region of the parameters
+	Region string
+
+	// Endpoint overrides the regional AWS endpoint
+	Endpoint string
+
+	// Credentials sign requests
+	Credentials aws.Credentials
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Client is a Systems Manager client.
+type Client struct {
+	endpoint string
+	signer   *aws.Signer
+	http     *http.Client
+}
+
+// NewClient returns a client with the given options.
+func NewClient(opts Options) *Client {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://ssm." + opts.Region + ".amazonaws.com"
+	}
+	c := &Client{
+		endpoint: strings.TrimRight(endpoint, "/") + "/",
+		signer:   &aws.Signer{Credentials: opts.Credentials, Region: opts.Region, Service: "ssm"},
+		http:     opts.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	return c
+}
+
+// PutParameter sets the String parameter name to value, creating it or
+// replacing its value.
+func (c *Client) PutParameter(ctx context.Context, name, value string) error {
+	return c.do(ctx, "PutParameter", map[string]any{
+		"Name":      name,
+		"Value":     value,
+		"Type":      "String",
+		"Overwrite": true,
+	}, &struct{}{})
+}
+
+// GetParametersByPath returns the values of the parameters under path,
+// e.g. "/aperture/prod/", and any below it, by name.
+func (c *Client) GetParametersByPath(ctx context.Context, path string) (map[string]string, error) {
+	params := make(map[string]string)
+	token := ""
+	for {
+		in := map[string]any{"Path": path, "Recursive": true}
+		if token != "" {
+			in["NextToken"] = token
+		}
+		var out struct {
+			Parameters []struct {
+				Name  string
+				Value string
+			}
+			NextToken string
+		}
+		if err := c.do(ctx, "GetParametersByPath", in, &out); err != nil {
+			return nil, err
+		}
+		for _, p := range out.Parameters {
+			params[p.Name] = p.Value
+		}
+		if token = out.NextToken; token == "" {
+			return params, nil
+		}
+	}
+}
+
+// DeleteParameters deletes the parameters named. Parameters that do
+// not exist are ignored.
+func (c *Client) DeleteParameters(ctx context.Context, names ...string) error {
+	// A call deletes at most 10 parameters.
+	for len(names) > 0 {
+		n := min(len(names), 10)
+		if err := c.do(ctx, "DeleteParameters", map[string]any{"Names": names[:n]}, &struct{}{}); err != nil {
+			return err
+		}
+		names = names[n:]
+	}
+	return nil
+}
+
+// do calls operation with in as the JSON request and decodes the
+// response into out.
+func (c *Client) do(ctx context.Context, operation string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", operation, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", operation, err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", targetPrefix+operation)
+	c.signer.Sign(req, aws.HashPayload(body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("ssm %s failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode, Operation: operation}
+		_ = json.Unmarshal(data, e)
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		return e
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", operation, err)
+	}
+	return nil
+}
+
+// Error is a Systems Manager error response.
+type Error struct {
+	StatusCode int
+	Operation  string
+	Type       string `json:"__type"`
+	Message    string `json:"message"`
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("ssm %s: HTTP %d %s: %s", e.Operation, e.StatusCode, e.Type, e.Message)
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aperture/internal/aws"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return NewClient(Options{
+		Region:      "us-east-1",
+		Endpoint:    srv.URL,
+		Credentials: aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+}
+
+func TestPutParameter(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "AmazonSSM.PutParameter" {
+			t.Errorf("target = %q", r.Header.Get("X-Amz-Target"))
+		}
+		var in map[string]any
+		json.NewDecoder(r.Body).Decode(&in)
+		want := map[string]any{"Name": "/aperture/prod/outputs/api_gateway_invoke_url", "Value": "https://api.example.edu/v1", "Type": "String", "Overwrite": true}
+		if !reflect.DeepEqual(in, want) {
+			t.Errorf("request = %v, want %v", in, want)
+		}
+		fmt.Fprint(w, `{"Version": 2, "Tier": "Standard"}`)
+	})
+	if err := c.PutParameter(context.Background(), "/aperture/prod/outputs/api_gateway_invoke_url", "https://api.example.edu/v1"); err != nil {
+		t.Errorf("PutParameter() error = %v", err)
+	}
+}
+
+func TestGetParametersByPath(t *testing.T) {
+	calls := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]any
+		json.NewDecoder(r.Body).Decode(&in)
+		if in["Path"] != "/aperture/prod/outputs/" || in["Recursive"] != true {
+			t.Errorf("request = %v", in)
+		}
+		calls++
+		if in["NextToken"] == nil {
+			fmt.Fprint(w, `{"Parameters": [{"Name": "/aperture/prod/outputs/a", "Value": "1"}], "NextToken": "t1"}`)
+			return
+		}
+		fmt.Fprint(w, `{"Parameters": [{"Name": "/aperture/prod/outputs/b", "Value": "2"}]}`)
+	})
+	got, err := c.GetParametersByPath(context.Background(), "/aperture/prod/outputs/")
+	want := map[string]string{"/aperture/prod/outputs/a": "1", "/aperture/prod/outputs/b": "2"}
+	if err != nil || calls != 2 || !reflect.DeepEqual(got, want) {
+		t.Errorf("GetParametersByPath() = %v, %v after %d calls, want %v", got, err, calls, want)
+	}
+}
+
+func TestDeleteParameters(t *testing.T) {
+	var batches []int
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ Names []string }
+		json.NewDecoder(r.Body).Decode(&in)
+		batches = append(batches, len(in.Names))
+		fmt.Fprint(w, `{"DeletedParameters": [], "InvalidParameters": []}`)
+	})
+	names := make([]string, 12)
+	for i := range names {
+		names[i] = fmt.Sprintf("/aperture/prod/outputs/%d", i)
+	}
+	if err := c.DeleteParameters(context.Background(), names...); err != nil || !reflect.DeepEqual(batches, []int{10, 2}) {
+		t.Errorf("DeleteParameters() = %v in batches %v", err, batches)
+	}
+}
+
+func TestError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type": "com.amazonaws.ssm#AccessDeniedException", "message": "not authorized"}`)
+	})
+	err := c.PutParameter(context.Background(), "/a", "b")
+	if err == nil || !strings.Contains(err.Error(), "AccessDeniedException: not authorized") {
+		t.Errorf("PutParameter() error = %v", err)
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package state provides a keyed document store for Aperture records.
+// Records are JSON documents grouped into tables.
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when a record does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// ErrExists is returned when creating a record that already exists.
+var ErrExists = errors.New("record exists")
+
+// Store is a keyed document store.
+type Store interface {
+	// Get decodes the record at table/key into v.
+	Get(ctx context.Context, table, key string, v any) error
+
+	// Put stores v at table/key, replacing any existing record.
+	Put(ctx context.Context, table, key string, v any) error
+
+	// Create stores v at table/key if there is no record there, and
+	// otherwise returns an error wrapping ErrExists. Of several
+	// processes sharing the store that create the same record at
+	// once, exactly one succeeds.
+	Create(ctx context.Context, table, key string, v any) error
+
+	// Delete removes table/key. Deleting a missing record is not an
+	// error.
+	Delete(ctx context.Context, table, key string) error
+
+	// Keys returns the keys in table in sorted order.
+	Keys(ctx context.Context, table string) ([]string, error)
+}
+
+// FileStore keeps each record as a JSON file under a directory, one
+// subdirectory per table.
+type FileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// NewFileStore returns a store rooted at dir.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Get implements Store.
+func (s *FileStore) Get(_ context.Context, table, key string, v any) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := os.ReadFile(s.path(table, key))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s %q: %w", table, key, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s %q: %w", table, key, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("corrupt %s record %q: %w", table, key, err)
+	}
+	return nil
+}
+
+// Put implements Store.
+func (s *FileStore) Put(_ context.Context, table, key string, v any) error {
+	return s.write(table, key, v, os.Rename)
+}
+
+// Create implements Store. The record is linked into place, which
+// fails if its file exists, so processes sharing the directory cannot
+// both create it.
+func (s *FileStore) Create(_ context.Context, table, key string, v any) error {
+	err := s.write(table, key, v, os.Link)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s %q: %w", table, key, ErrExists)
+	}
+	return err
+}
+
+// write stores v at table/key by writing a temporary file and moving
+// it into place with place, so readers never see a partially written
+// record.
+func (s *FileStore) write(table, key string, v any, place func(tmp, path string) error) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s %q: %w", table, key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := filepath.Join(s.dir, table)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", table, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s %q: %w", table, key, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s %q: %w", table, key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s %q: %w", table, key, err)
+	}
+	if err := place(tmp.Name(), s.path(table, key)); err != nil {
+		return fmt.Errorf("failed to write %s %q: %w", table, key, err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *FileStore) Delete(_ context.Context, table, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path(table, key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete %s %q: %w", table, key, err)
+	}
+	return nil
+}
+
+// Keys implements Store.
+func (s *FileStore) Keys(_ context.Context, table string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, table))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", table, err)
+	}
+
+	var keys []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		key, err := url.PathUnescape(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// path returns the file for table/key. Keys are escaped so that DOIs
+// and other keys containing slashes map to a single file.
+func (s *FileStore) path(table, key string) string {
+	return filepath.Join(s.dir, table, url.PathEscape(key)+".json")
+}
+
+// MemoryStore keeps records in memory. It is intended for tests and
+// short-lived processes.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	tables map[string]map[string][]byte
+}
+
+// NewMemoryStore returns an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tables: make(map[string]map[string][]byte)}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, table, key string, v any) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.tables[table][key]
+	if !ok {
+		return fmt.Errorf("%s %q: %w", table, key, ErrNotFound)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(_ context.Context, table, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s %q: %w", table, key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tables[table] == nil {
+		s.tables[table] = make(map[string][]byte)
+	}
+	s.tables[table][key] = data
+	return nil
+}
+
+// Create implements Store.
+func (s *MemoryStore) Create(_ context.Context, table, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s %q: %w", table, key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tables[table][key]; ok {
+		return fmt.Errorf("%s %q: %w", table, key, ErrExists)
+	}
+	if s.tables[table] == nil {
+		s.tables[table] = make(map[string][]byte)
+	}
+	s.tables[table][key] = data
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(_ context.Context, table, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tables[table], key)
+	return nil
+}
+
+// Keys implements Store.
+func (s *MemoryStore) Keys(_ context.Context, table string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.tables[table]))
+	for k := range s.tables[table] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// List decodes every record in table, in key order.
+func List[T any](ctx context.Context, s Store, table string) ([]T, error) {
+	keys, err := s.Keys(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]T, 0, len(keys))
+	for _, k := range keys {
+		var v T
+		if err := s.Get(ctx, table, k, &v); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type record struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestStores(t *testing.T) {
+	fs, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	stores := map[string]Store{
+		"file":   fs,
+		"memory": NewMemoryStore(),
+	}
+
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			var got record
+			if err := s.Get(ctx, "things", "missing", &got); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get() missing error = %v, want ErrNotFound", err)
+			}
+
+			if err := s.Put(ctx, "things", "10.5555/b", record{Name: "b", Count: 2}); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			if err := s.Create(ctx, "things", "a", record{Name: "a", Count: 1}); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			if err := s.Get(ctx, "things", "10.5555/b", &got); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.Name != "b" || got.Count != 2 {
+				t.Errorf("Get() = %+v", got)
+			}
+
+			if err := s.Create(ctx, "things", "a", record{Name: "again"}); !errors.Is(err, ErrExists) {
+				t.Errorf("Create() existing error = %v, want ErrExists", err)
+			}
+			if err := s.Get(ctx, "things", "a", &got); err != nil || got.Name != "a" {
+				t.Errorf("Get() after failed Create() = %+v, %v", got, err)
+			}
+
+			keys, err := s.Keys(ctx, "things")
+			if err != nil {
+				t.Fatalf("Keys() error = %v", err)
+			}
+			if len(keys) != 2 || keys[0] != "10.5555/b" || keys[1] != "a" {
+				t.Errorf("Keys() = %v", keys)
+			}
+
+			all, err := List[record](ctx, s, "things")
+			if err != nil || len(all) != 2 {
+				t.Fatalf("List() = %v, %v", all, err)
+			}
+
+			if err := s.Delete(ctx, "things", "a"); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if err := s.Delete(ctx, "things", "a"); err != nil {
+				t.Fatalf("Delete() missing error = %v", err)
+			}
+			if keys, _ := s.Keys(ctx, "things"); len(keys) != 1 {
+				t.Errorf("Keys() after delete = %v", keys)
+			}
+			if keys, _ := s.Keys(ctx, "empty"); len(keys) != 0 {
+				t.Errorf("Keys() on empty table = %v", keys)
+			}
+		})
+	}
+}
+
+// TestFileStoreCreate checks that of two stores over one directory, as
+// of two processes, creating the same record at once only one succeeds.
+func TestFileStoreCreate(t *testing.T) {
+	dir := t.TempDir()
+	var created atomic.Int32
+	var wg sync.WaitGroup
+	for i := range 8 {
+		s, err := NewFileStore(dir)
+		if err != nil {
+			t.Fatalf("NewFileStore() error = %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.Create(context.Background(), "log", "1", record{Count: i})
+			switch {
+			case err == nil:
+				created.Add(1)
+			case !errors.Is(err, ErrExists):
+				t.Errorf("Create() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := created.Load(); n != 1 {
+		t.Errorf("%d concurrent Create() calls succeeded, want 1", n)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, "log"))
+	if len(entries) != 1 {
+		t.Errorf("table holds %d files, want 1 with no temporary files left", len(entries))
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// SlowThreshold is the response time above which a service is
+// degraded.
+const SlowThreshold = 3 * time.Second
+
+// DataCiteSync is the name under which registrations with DataCite
+// are recorded with RecordRun.
+const DataCiteSync = "datacite"
+
+// Resolution checks that doi resolves through resolver, e.g.
+// https://doi.org, to a page under siteURL. The client must not follow
+// redirects. Without a published DOI to resolve, the result is unknown.
+func Resolution(client *http.Client, resolver, doi, siteURL string) Check {
+	return Check{Name: "DOI resolution", Group: GroupServices, Tracked: true, Probe: func(ctx context.Context) Result {
+		if doi == "" {
+			return Result{Level: Unknown, Detail: "no published DOI to resolve"}
+		}
+		resp, elapsed, err := get(ctx, client, strings.TrimSuffix(resolver, "/")+"/"+doi)
+		if err != nil {
+			return Result{Level: Outage, Detail: "resolver unreachable"}
+		}
+		loc := resp.Header.Get("Location")
+		switch {
+		case resp.StatusCode < 300 || resp.StatusCode >= 400:
+			return Result{Level: Outage, Detail: fmt.Sprintf("resolver answered HTTP %d", resp.StatusCode)}
+		case siteURL != "" && !strings.HasPrefix(loc, strings.TrimSuffix(siteURL, "/")+"/"):
+			return Result{Level: Outage, Detail: "DOIs resolve outside the repository"}
+		}
+		return timed(elapsed, "DOIs resolve to landing pages")
+	}}
+}
+
+// Endpoint checks that url answers with HTTP 200: a landing page on
+// the CDN, or the health endpoint of a server.
+func Endpoint(client *http.Client, name, url string) Check {
+	return Check{Name: name, Group: GroupServices, Tracked: true, Probe: func(ctx context.Context) Result {
+		resp, elapsed, err := get(ctx, client, url)
+		if err != nil {
+			return Result{Level: Outage, Detail: "unreachable"}
+		}
+		if resp.StatusCode != http.StatusOK {
+			return Result{Level: Outage, Detail: fmt.Sprintf("HTTP %d", resp.StatusCode)}
+		}
+		return timed(elapsed, "responding")
+	}}
+}
+
+// DataCite checks that the DataCite REST API at apiURL is up and
+// reports the last successful registration recorded in s under
+// DataCiteSync. Registrations failing since the last success degrade
+// the component even while the API answers.
+func DataCite(client *http.Client, apiURL string, s state.Store) Check {
+	return Check{Name: "DataCite", Group: GroupPartners, Tracked: true, Probe: func(ctx context.Context) Result {
+		run, err := LastRun(ctx, s, DataCiteSync)
+		if err != nil {
+			return Result{Level: Unknown, Detail: "sync history unavailable"}
+		}
+		var res Result
+		if run != nil {
+			res.LastSuccess = run.LastSuccess
+		}
+		resp, elapsed, err := get(ctx, client, strings.TrimSuffix(apiURL, "/")+"/heartbeat")
+		switch {
+		case err != nil:
+			res.Level, res.Detail = Outage, "DataCite API unreachable"
+		case resp.StatusCode != http.StatusOK:
+			res.Level, res.Detail = Outage, fmt.Sprintf("DataCite API answered HTTP %d", resp.StatusCode)
+		case run != nil && run.Failing():
+			res.Level, res.Detail = Degraded, "DOI registration failing since "+run.LastFailure.Format(time.RFC3339)
+		default:
+			t := timed(elapsed, "DataCite API responding")
+			res.Level, res.Detail = t.Level, t.Detail
+		}
+		return res
+	}}
+}
+
+// Job checks the runs of the scheduled job name, expected every
+// interval, recorded in s: it is degraded if its last run failed or it
+// has not succeeded within twice the interval.
+func Job(s state.Store, name, title string, every time.Duration, now func() time.Time) Check {
+	return Check{Name: title, Group: GroupJobs, Probe: func(ctx context.Context) Result {
+		run, err := LastRun(ctx, s, name)
+		switch {
+		case err != nil:
+			return Result{Level: Unknown, Detail: "run history unavailable"}
+		case run == nil:
+			return Result{Level: Unknown, Detail: "not run yet"}
+		}
+		res := Result{Level: Operational, LastSuccess: run.LastSuccess, Detail: "last ran " + run.LastRun.Format(time.RFC3339)}
+		switch {
+		case run.Failing():
+			res.Level, res.Detail = Degraded, "last run failed at "+run.LastFailure.Format(time.RFC3339)
+		case run.LastSuccess == nil || now().Sub(*run.LastSuccess) > 2*every:
+			res.Level, res.Detail = Degraded, "overdue; last ran "+run.LastRun.Format(time.RFC3339)
+		}
+		return res
+	}}
+}
+
+// NoRedirects returns a copy of client that does not follow
+// redirects, for Resolution.
+func NoRedirects(client *http.Client) *http.Client {
+	c := *client
+	c.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return &c
+}
+
+// get fetches url and discards the body, returning the response and
+// how long it took.
+func get(ctx context.Context, client *http.Client, url string) (*http.Response, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("User-Agent", "aperture-status")
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	return resp, time.Since(start), nil
+}
+
+// timed returns an operational result with detail, degraded if
+// elapsed exceeds SlowThreshold.
+func timed(elapsed time.Duration, detail string) Result {
+	if elapsed > SlowThreshold {
+		return Result{Level: Degraded, Detail: fmt.Sprintf("slow: %.1fs to respond", elapsed.Seconds())}
+	}
+	return Result{Level: Operational, Detail: detail}
+}
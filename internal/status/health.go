@@ -0,0 +1,73 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// HealthPath is the path of the health endpoint of every server.
+const HealthPath = "/healthz"
+
+// Probe checks one dependency of a server, returning nil if it is
+// healthy.
+type Probe func(ctx context.Context) error
+
+// Health wraps h to answer GET and HEAD requests for HealthPath with
+// the health of service: HTTP 200 if every probe passes, or 503
+// naming the failing ones. Other requests go to h.
+func Health(service string, probes map[string]Probe, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != HealthPath || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		body := struct {
+			Service string            `json:"service"`
+			Status  string            `json:"status"`
+			Checks  map[string]string `json:"checks,omitempty"`
+		}{Service: service, Status: "ok"}
+		code := http.StatusOK
+		for name, probe := range probes {
+			result := "ok"
+			if err := probe(ctx); err != nil {
+				result, body.Status, code = "failing", "failing", http.StatusServiceUnavailable
+			}
+			if body.Checks == nil {
+				body.Checks = make(map[string]string)
+			}
+			body.Checks[name] = result
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(body)
+	})
+}
+
+// StoreProbe checks that s can be read.
+func StoreProbe(s state.Store) Probe {
+	return func(ctx context.Context) error {
+		_, err := s.Keys(ctx, runsTable)
+		return err
+	}
+}
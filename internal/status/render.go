@@ -0,0 +1,60 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+)
+
+//go:embed templates/status.html
+var templates embed.FS
+
+// page renders reports as HTML.
+var page = template.Must(template.New("status.html").Funcs(template.FuncMap{
+	"groups":   func() []string { return Groups },
+	"headline": headline,
+	"percent":  func(f float64) string { return fmt.Sprintf("%.2f%%", 100*f) },
+}).ParseFS(templates, "templates/status.html"))
+
+// headline summarizes the overall level for the page banner.
+func headline(l Level) string {
+	switch l {
+	case Outage:
+		return "Some services are down"
+	case Degraded:
+		return "Some services are degraded"
+	}
+	return "All systems operational"
+}
+
+// WriteHTML writes r as a standalone public status page.
+func WriteHTML(w io.Writer, r *Report) error {
+	if err := page.Execute(w, r); err != nil {
+		return fmt.Errorf("failed to render status page: %w", err)
+	}
+	return nil
+}
+
+// WriteJSON writes r as indented JSON, for machine readers of the
+// status page.
+func WriteJSON(w io.Writer, r *Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,350 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package status checks the health of the repository's public
+// services, the partner services it depends on, and its background
+// jobs, and renders the results as a public status page.
+//
+// A Checker runs Checks: probes of identifier resolution, landing
+// pages on the CDN, and the health endpoints of the servers; the
+// reachability of DataCite and the outcome of the last registration
+// with it; and the last runs of scheduled jobs. Each published run is
+// tallied by day, so the page can show uptime over the last 90 days and
+// depositors can tell whether a problem is on the repository's side.
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// Tables used in the state store.
+const (
+	runsTable = "status-runs"
+	daysTable = "status-days"
+)
+
+// HistoryDays is the number of days of uptime shown.
+const HistoryDays = 90
+
+// Level is the health of a component.
+type Level string
+
+// Levels, from best to worst.
+const (
+	Operational Level = "operational"
+	Unknown     Level = "unknown"
+	Degraded    Level = "degraded"
+	Outage      Level = "outage"
+)
+
+// rank orders levels by severity.
+func (l Level) rank() int {
+	switch l {
+	case Operational:
+		return 0
+	case Unknown:
+		return 1
+	case Degraded:
+		return 2
+	}
+	return 3
+}
+
+// Groups of checks, in the order the page lists them.
+const (
+	// GroupServices are the repository's own public services
+	GroupServices = "Services"
+
+	// GroupPartners are external services the repository depends on
+	GroupPartners = "Partner services"
+
+	// GroupJobs are scheduled background jobs
+	GroupJobs = "Background jobs"
+)
+
+// Groups lists the groups in page order.
+var Groups = []string{GroupServices, GroupPartners, GroupJobs}
+
+// Check probes one component.
+type Check struct {
+	// Name is the component's public name
+	Name string
+
+	// Group is one of Groups
+	Group string
+
+	// Tracked checks are tallied for uptime
+	Tracked bool
+
+	// Probe returns the component's level and a short public
+	// explanation
+	Probe func(ctx context.Context) Result
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Name   string `json:"name"`
+	Group  string `json:"group"`
+	Level  Level  `json:"level"`
+	Detail string `json:"detail,omitempty"`
+
+	// LastSuccess is when the job or sync last succeeded
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+
+	// Uptime is the fraction of tracked checks over HistoryDays that
+	// found the component up; nil if it was never checked
+	Uptime *float64 `json:"uptime,omitempty"`
+
+	// History holds the daily tallies of a tracked component, oldest
+	// first
+	History []Tally `json:"history,omitempty"`
+}
+
+// Tally counts the checks of a component on one day.
+type Tally struct {
+	Date   string `json:"date"`
+	Checks int    `json:"checks"`
+	Up     int    `json:"up"`
+}
+
+// Level returns the health of the day: unknown if unchecked, an outage
+// if most checks failed, degraded if any did.
+func (t Tally) Level() Level {
+	switch {
+	case t.Checks == 0:
+		return Unknown
+	case t.Up == t.Checks:
+		return Operational
+	case t.Up*2 < t.Checks:
+		return Outage
+	}
+	return Degraded
+}
+
+// Report is the outcome of a run of checks.
+type Report struct {
+	Generated time.Time `json:"generated"`
+	Level     Level     `json:"level"`
+	Results   []Result  `json:"results"`
+}
+
+// Group returns the results of group, in check order.
+func (r *Report) Group(group string) []Result {
+	var out []Result
+	for _, res := range r.Results {
+		if res.Group == group {
+			out = append(out, res)
+		}
+	}
+	return out
+}
+
+// Checker runs checks.
+type Checker struct {
+	Checks []Check
+
+	// State holds uptime history; uptime is omitted if nil
+	State state.Store
+
+	// Timeout bounds each check; 10 seconds if zero
+	Timeout time.Duration
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Run runs every check concurrently and returns the results, with
+// the uptime history of tracked checks. It does not record them; see
+// Record.
+func (c *Checker) Run(ctx context.Context) (*Report, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	results := make([]Result, len(c.Checks))
+	var wg sync.WaitGroup
+	for i, ch := range c.Checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			res := ch.Probe(ctx)
+			res.Name, res.Group = ch.Name, ch.Group
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+
+	r := &Report{Generated: c.now().UTC(), Level: Operational, Results: results}
+	for _, res := range results {
+		if res.Level.rank() > r.Level.rank() && res.Level != Unknown {
+			r.Level = res.Level
+		}
+	}
+	if c.State == nil {
+		return r, nil
+	}
+	if err := c.fill(ctx, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// fill sets the uptime and history of the tracked results of r.
+func (c *Checker) fill(ctx context.Context, r *Report) error {
+	days, err := c.history(ctx, r.Generated)
+	if err != nil {
+		return err
+	}
+	for i, ch := range c.Checks {
+		if ch.Tracked {
+			r.Results[i].History, r.Results[i].Uptime = uptime(days, ch.Name)
+		}
+	}
+	return nil
+}
+
+// Record tallies the tracked results of r into the day they ran and
+// updates the uptime of r to include them. Unknown results are not
+// counted.
+func (c *Checker) Record(ctx context.Context, r *Report) error {
+	key := r.Generated.Format(time.DateOnly)
+	day := make(map[string]Tally)
+	if err := c.State.Get(ctx, daysTable, key, &day); err != nil && !errors.Is(err, state.ErrNotFound) {
+		return fmt.Errorf("failed to read status history: %w", err)
+	}
+	for i, ch := range c.Checks {
+		res := r.Results[i]
+		if !ch.Tracked || res.Level == Unknown {
+			continue
+		}
+		t := day[ch.Name]
+		t.Date = key
+		t.Checks++
+		if res.Level != Outage {
+			t.Up++
+		}
+		day[ch.Name] = t
+	}
+	if err := c.State.Put(ctx, daysTable, key, day); err != nil {
+		return fmt.Errorf("failed to record status history: %w", err)
+	}
+	return c.fill(ctx, r)
+}
+
+// day holds the tallies of every component on one date.
+type day struct {
+	date    string
+	tallies map[string]Tally
+}
+
+// history returns the tallies of the HistoryDays days ending at now,
+// oldest first.
+func (c *Checker) history(ctx context.Context, now time.Time) ([]day, error) {
+	days := make([]day, HistoryDays)
+	for i := range days {
+		d := day{date: now.AddDate(0, 0, i-HistoryDays+1).Format(time.DateOnly)}
+		if err := c.State.Get(ctx, daysTable, d.date, &d.tallies); err != nil && !errors.Is(err, state.ErrNotFound) {
+			return nil, fmt.Errorf("failed to read status history: %w", err)
+		}
+		days[i] = d
+	}
+	return days, nil
+}
+
+// uptime returns the daily tallies of the component name and the
+// fraction of its checks that found it up.
+func uptime(days []day, name string) ([]Tally, *float64) {
+	history := make([]Tally, len(days))
+	var checks, up int
+	for i, d := range days {
+		t := d.tallies[name]
+		t.Date = d.date
+		history[i] = t
+		checks += t.Checks
+		up += t.Up
+	}
+	if checks == 0 {
+		return history, nil
+	}
+	f := float64(up) / float64(checks)
+	return history, &f
+}
+
+func (c *Checker) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// Run is the record of the runs of a job, or of the registrations
+// with a partner service.
+type Run struct {
+	Name        string     `json:"name"`
+	LastRun     time.Time  `json:"lastRun"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+
+	// Error is the error of the last failure
+	Error string `json:"error,omitempty"`
+}
+
+// RecordRun records that name ran at t, failing with err if it is
+// non-nil.
+func RecordRun(ctx context.Context, s state.Store, name string, t time.Time, err error) error {
+	run, lerr := LastRun(ctx, s, name)
+	if lerr != nil {
+		return lerr
+	}
+	if run == nil {
+		run = &Run{Name: name}
+	}
+	t = t.UTC()
+	run.LastRun = t
+	if err != nil {
+		run.LastFailure, run.Error = &t, err.Error()
+	} else {
+		run.LastSuccess = &t
+	}
+	if err := s.Put(ctx, runsTable, name, run); err != nil {
+		return fmt.Errorf("failed to record run of %s: %w", name, err)
+	}
+	return nil
+}
+
+// LastRun returns the record of name's runs, or nil if it never ran.
+func LastRun(ctx context.Context, s state.Store, name string) (*Run, error) {
+	var run Run
+	err := s.Get(ctx, runsTable, name, &run)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runs of %s: %w", name, err)
+	}
+	return &run, nil
+}
+
+// Failing reports whether the last run failed.
+func (r *Run) Failing() bool {
+	return r.LastFailure != nil && (r.LastSuccess == nil || r.LastFailure.After(*r.LastSuccess))
+}
@@ -0,0 +1,222 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func fixed(level Level) func(context.Context) Result {
+	return func(context.Context) Result { return Result{Level: level} }
+}
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	level := Outage
+	c := &Checker{
+		Checks: []Check{
+			{Name: "API", Group: GroupServices, Tracked: true, Probe: func(context.Context) Result { return Result{Level: level} }},
+			{Name: "Ingest", Group: GroupJobs, Probe: fixed(Degraded)},
+			{Name: "Landing pages", Group: GroupServices, Tracked: true, Probe: fixed(Unknown)},
+		},
+		State: state.NewMemoryStore(),
+		Now:   func() time.Time { return now },
+	}
+
+	r, err := c.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Level != Outage || r.Results[0].Name != "API" || r.Results[1].Group != GroupJobs {
+		t.Errorf("Run() = %+v", r)
+	}
+	if r.Results[0].Uptime != nil || len(r.Results[0].History) != HistoryDays || r.Results[1].History != nil {
+		t.Errorf("uptime before any record = %v, history %d days", r.Results[0].Uptime, len(r.Results[0].History))
+	}
+	if err := c.Record(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	level = Operational
+	for range 3 {
+		r, err = c.Run(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Record(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now = now.AddDate(0, 0, 1)
+	if r, err = c.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Level != Degraded {
+		t.Errorf("Level = %s, want degraded from the job", r.Level)
+	}
+	api := r.Results[0]
+	if api.Uptime == nil || *api.Uptime != 0.75 {
+		t.Errorf("API uptime = %v, want 0.75", api.Uptime)
+	}
+	if d := api.History[HistoryDays-2]; d.Date != "2025-06-10" || d.Checks != 4 || d.Up != 3 || d.Level() != Degraded {
+		t.Errorf("API history on 2025-06-10 = %+v", d)
+	}
+	if d := api.History[HistoryDays-1]; d.Date != "2025-06-11" || d.Level() != Unknown {
+		t.Errorf("API history today = %+v", d)
+	}
+	if r.Results[2].Uptime != nil {
+		t.Errorf("unknown results counted toward uptime: %v", *r.Results[2].Uptime)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	ctx := context.Background()
+	s := state.NewMemoryStore()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/10.1234/ok":
+			http.Redirect(w, r, "https://data.example.edu/datasets/ok/", http.StatusFound)
+		case "/10.1234/elsewhere":
+			http.Redirect(w, r, "https://example.com/", http.StatusFound)
+		case "/heartbeat", "/healthz":
+			w.Write([]byte("OK"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	client := NoRedirects(srv.Client())
+	site := "https://data.example.edu"
+
+	run := func(c Check) Result {
+		return c.Probe(ctx)
+	}
+	if res := run(Resolution(client, srv.URL, "10.1234/ok", site)); res.Level != Operational {
+		t.Errorf("Resolution(ok) = %+v", res)
+	}
+	if res := run(Resolution(client, srv.URL, "10.1234/elsewhere", site)); res.Level != Outage {
+		t.Errorf("Resolution(elsewhere) = %+v", res)
+	}
+	if res := run(Resolution(client, srv.URL, "", site)); res.Level != Unknown {
+		t.Errorf("Resolution() without a DOI = %+v", res)
+	}
+	if res := run(Endpoint(client, "API", srv.URL+"/healthz")); res.Level != Operational {
+		t.Errorf("Endpoint(healthz) = %+v", res)
+	}
+	if res := run(Endpoint(client, "API", srv.URL+"/missing")); res.Level != Outage || res.Detail != "HTTP 404" {
+		t.Errorf("Endpoint(missing) = %+v", res)
+	}
+	if res := run(Endpoint(client, "API", "http://127.0.0.1:1/healthz")); res.Level != Outage {
+		t.Errorf("Endpoint(unreachable) = %+v", res)
+	}
+
+	ok := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	if err := RecordRun(ctx, s, DataCiteSync, ok, nil); err != nil {
+		t.Fatal(err)
+	}
+	if res := run(DataCite(client, srv.URL, s)); res.Level != Operational || !res.LastSuccess.Equal(ok) {
+		t.Errorf("DataCite() = %+v", res)
+	}
+	if err := RecordRun(ctx, s, DataCiteSync, ok.Add(time.Hour), errors.New("HTTP 500")); err != nil {
+		t.Fatal(err)
+	}
+	if res := run(DataCite(client, srv.URL, s)); res.Level != Degraded || !res.LastSuccess.Equal(ok) {
+		t.Errorf("DataCite() after a failed registration = %+v", res)
+	}
+
+	now := ok.Add(90 * time.Minute)
+	clock := func() time.Time { return now }
+	if res := run(Job(s, "alert run", "Alerts", time.Hour, clock)); res.Level != Unknown {
+		t.Errorf("Job() never run = %+v", res)
+	}
+	if err := RecordRun(ctx, s, "alert run", ok, nil); err != nil {
+		t.Fatal(err)
+	}
+	if res := run(Job(s, "alert run", "Alerts", time.Hour, clock)); res.Level != Operational {
+		t.Errorf("Job() = %+v", res)
+	}
+	now = ok.Add(3 * time.Hour)
+	if res := run(Job(s, "alert run", "Alerts", time.Hour, clock)); res.Level != Degraded || !strings.HasPrefix(res.Detail, "overdue") {
+		t.Errorf("Job() overdue = %+v", res)
+	}
+	if err := RecordRun(ctx, s, "alert run", now, errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+	if res := run(Job(s, "alert run", "Alerts", time.Hour, clock)); res.Level != Degraded || strings.Contains(res.Detail, "boom") {
+		t.Errorf("Job() failed = %+v", res)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	failing := errors.New("down")
+	var err error
+	h := Health("downloads", map[string]Probe{"state": func(context.Context) error { return err }}, next)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	if w := serve(http.MethodGet, "/healthz"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"ok"`) {
+		t.Errorf("healthy: %d %s", w.Code, w.Body)
+	}
+	err = failing
+	if w := serve(http.MethodGet, "/healthz"); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"state":"failing"`) {
+		t.Errorf("failing: %d %s", w.Code, w.Body)
+	}
+	if w := serve(http.MethodGet, "/d/ds-1"); w.Code != http.StatusTeapot {
+		t.Errorf("other paths answered %d", w.Code)
+	}
+	if w := serve(http.MethodPost, "/healthz"); w.Code != http.StatusTeapot {
+		t.Errorf("POST /healthz answered %d", w.Code)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	up := 0.995
+	r := &Report{
+		Generated: time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC),
+		Level:     Degraded,
+		Results: []Result{
+			{Name: "DOI resolution", Group: GroupServices, Level: Operational, Uptime: &up, History: []Tally{{Date: "2025-06-10", Checks: 2, Up: 2}}},
+			{Name: "Download statistics", Group: GroupJobs, Level: Degraded, Detail: "overdue"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Some services are degraded", "DOI resolution", "99.50% uptime", "Background jobs", "overdue"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+	if strings.Contains(buf.String(), GroupPartners) {
+		t.Error("page lists a group without results")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/s3"
+)
+
+// Backend is an object store holding the repository's files: their
+// uploads, presigned downloads and uploads, storage class transitions,
+// and the reads and stored checksums fixity checks verify. Buckets are
+// containers or directories in stores that have them. *s3.Client,
+// *azblob.Client, and *fsblob.Client implement it; all report missing
+// objects as s3.ErrNotFound.
+type Backend interface {
+	PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error
+	Upload(ctx context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error)
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	HeadObject(ctx context.Context, bucket, key string) (s3.ObjectInfo, error)
+	GetObjectAttributes(ctx context.Context, bucket, key string) (s3.Attributes, error)
+	ListObjects(ctx context.Context, bucket, prefix string, fn func(s3.ObjectInfo) error) error
+	CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
+	DeleteObject(ctx context.Context, bucket, key string) error
+	SetStorageClass(ctx context.Context, bucket, key, class string) error
+	PresignGetObject(bucket, key string, expires time.Duration) string
+	PresignPutObject(bucket, key string, expires time.Duration) string
+}
+
+// Backend names accepted by APERTURE_STORAGE_BACKEND.
+const (
+	BackendS3         = "s3"
+	BackendGCS        = "gcs"
+	BackendAzure      = "azure"
+	BackendFilesystem = "filesystem"
+)
+
+var _ Backend = (*s3.Client)(nil)
@@ -0,0 +1,269 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storage provides object storage addressing for Aperture.
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Access is the access level of a dataset, which determines the bucket
+// its files live in under the default layout.
+type Access string
+
+// Access levels, matching the per-purpose buckets created by Terraform.
+const (
+	AccessPublic     Access = "public"
+	AccessPrivate    Access = "private"
+	AccessRestricted Access = "restricted"
+	AccessEmbargoed  Access = "embargoed"
+)
+
+// Valid reports whether a is a known access level.
+func (a Access) Valid() bool {
+	switch a {
+	case AccessPublic, AccessPrivate, AccessRestricted, AccessEmbargoed:
+		return true
+	}
+	return false
+}
+
+// Layout names accepted by NewLayout.
+const (
+	LayoutPurpose    = "purpose"
+	LayoutCollection = "collection"
+	LayoutHashed     = "hashed"
+	LayoutContent    = "content"
+)
+
+// Object identifies a single file within a dataset version.
+type Object struct {
+	// Dataset is the dataset identifier
+	Dataset string
+
+	// Version is the dataset version number, starting at 1
+	Version int
+
+	// File is the file path relative to the dataset root
+	File string
+
+	// Collection is the collection the dataset belongs to, if any
+	Collection string
+
+	// Access is the dataset access level
+	Access Access
+
+	// SHA256 is the hex-encoded digest of the file's content, required
+	// by ContentLayout
+	SHA256 string
+}
+
+// Location is a concrete bucket and key.
+type Location struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+}
+
+// String returns the location as an s3:// URI.
+func (l Location) String() string {
+	return "s3://" + l.Bucket + "/" + l.Key
+}
+
+// Layout maps dataset objects to bucket and key locations.
+type Layout interface {
+	// Name returns the layout name as used in configuration.
+	Name() string
+
+	// Locate returns the location of obj.
+	Locate(obj Object) (Location, error)
+}
+
+// NewLayout returns the layout with the given name. Bucket names are
+// derived from prefix, which is "<project>-<environment>" for buckets
+// created by the Terraform modules.
+func NewLayout(name, prefix string) (Layout, error) {
+	if prefix == "" {
+		return nil, fmt.Errorf("bucket prefix cannot be empty")
+	}
+
+	switch name {
+	case LayoutPurpose, "":
+		return &PurposeLayout{Prefix: prefix}, nil
+	case LayoutCollection:
+		return &CollectionLayout{Prefix: prefix}, nil
+	case LayoutHashed:
+		return &HashedLayout{Prefix: prefix}, nil
+	case LayoutContent:
+		return &ContentLayout{Prefix: prefix}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage layout %q", name)
+	}
+}
+
+// PurposeLayout stores objects in the per-purpose buckets
+// (<prefix>-public-media, <prefix>-restricted-media, ...) keyed by
+// dataset, version, and file.
+type PurposeLayout struct {
+	Prefix string
+}
+
+// Name implements Layout.
+func (l *PurposeLayout) Name() string { return LayoutPurpose }
+
+// Locate implements Layout.
+func (l *PurposeLayout) Locate(obj Object) (Location, error) {
+	if err := obj.validate(); err != nil {
+		return Location{}, err
+	}
+	return Location{
+		Bucket: BucketForAccess(l.Prefix, obj.Access),
+		Key:    datasetKey(obj),
+	}, nil
+}
+
+// CollectionLayout stores each collection in its own bucket
+// (<prefix>-<collection>) with the access level as the first key
+// segment. Datasets without a collection fall back to PurposeLayout.
+type CollectionLayout struct {
+	Prefix string
+}
+
+// Name implements Layout.
+func (l *CollectionLayout) Name() string { return LayoutCollection }
+
+// Locate implements Layout.
+func (l *CollectionLayout) Locate(obj Object) (Location, error) {
+	if err := obj.validate(); err != nil {
+		return Location{}, err
+	}
+	if obj.Collection == "" {
+		return (&PurposeLayout{Prefix: l.Prefix}).Locate(obj)
+	}
+	return Location{
+		Bucket: l.Prefix + "-" + sanitize(obj.Collection),
+		Key:    string(obj.Access) + "/" + datasetKey(obj),
+	}, nil
+}
+
+// HashedLayout uses the per-purpose buckets but prefixes each key with
+// two levels of a hash of the dataset identifier. This spreads request
+// load across S3 partitions for datasets with very high request rates.
+type HashedLayout struct {
+	Prefix string
+}
+
+// Name implements Layout.
+func (l *HashedLayout) Name() string { return LayoutHashed }
+
+// Locate implements Layout.
+func (l *HashedLayout) Locate(obj Object) (Location, error) {
+	if err := obj.validate(); err != nil {
+		return Location{}, err
+	}
+	sum := sha256.Sum256([]byte(obj.Dataset))
+	h := hex.EncodeToString(sum[:2])
+	return Location{
+		Bucket: BucketForAccess(l.Prefix, obj.Access),
+		Key:    h[:2] + "/" + h[2:] + "/" + datasetKey(obj),
+	}, nil
+}
+
+// ContentLayout uses the per-purpose buckets but keys each object by the
+// SHA-256 digest of its content, so that a file unchanged between
+// versions, or shared between datasets of the same access level, is
+// stored once. An object is removed only when no manifest references
+// it.
+type ContentLayout struct {
+	Prefix string
+}
+
+// Name implements Layout.
+func (l *ContentLayout) Name() string { return LayoutContent }
+
+// Locate implements Layout.
+func (l *ContentLayout) Locate(obj Object) (Location, error) {
+	if err := obj.validate(); err != nil {
+		return Location{}, err
+	}
+	sum := strings.ToLower(obj.SHA256)
+	if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+		return Location{}, fmt.Errorf("file %s of dataset %s has no valid SHA-256 digest", obj.File, obj.Dataset)
+	}
+	return Location{
+		Bucket: BucketForAccess(l.Prefix, obj.Access),
+		Key:    ContentKey(sum),
+	}, nil
+}
+
+// ContentKey returns the key of the content-addressed object with the
+// hex SHA-256 digest sum.
+func ContentKey(sum string) string {
+	return "content/sha256/" + sum[:2] + "/" + sum[2:4] + "/" + sum
+}
+
+// ContentAddressed reports whether l stores identical content once, so
+// that an object already at a file's location need not be uploaded
+// again.
+func ContentAddressed(l Layout) bool {
+	_, ok := l.(*ContentLayout)
+	return ok
+}
+
+// BucketForAccess returns the per-purpose bucket for an access level.
+func BucketForAccess(prefix string, access Access) string {
+	return prefix + "-" + string(access) + "-media"
+}
+
+// validate checks that obj has enough information to be located.
+func (obj Object) validate() error {
+	if obj.Dataset == "" {
+		return fmt.Errorf("dataset cannot be empty")
+	}
+	if obj.Version < 1 {
+		return fmt.Errorf("invalid version %d for dataset %s", obj.Version, obj.Dataset)
+	}
+	if obj.File == "" {
+		return fmt.Errorf("file cannot be empty")
+	}
+	if !obj.Access.Valid() {
+		return fmt.Errorf("invalid access level %q", obj.Access)
+	}
+	return nil
+}
+
+// datasetKey returns the dataset-relative key shared by all layouts.
+func datasetKey(obj Object) string {
+	file := strings.TrimPrefix(path.Clean("/"+obj.File), "/")
+	return fmt.Sprintf("datasets/%s/v%d/%s", obj.Dataset, obj.Version, file)
+}
+
+// sanitize lowercases s and replaces characters not allowed in bucket
+// names with hyphens.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '-'
+		}
+	}, s)
+}
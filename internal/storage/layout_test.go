@@ -0,0 +1,166 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		layout   string
+		prefix   string
+		wantName string
+		wantErr  bool
+	}{
+		{name: "default", layout: "", prefix: "aperture-dev", wantName: LayoutPurpose},
+		{name: "purpose", layout: "purpose", prefix: "aperture-dev", wantName: LayoutPurpose},
+		{name: "collection", layout: "collection", prefix: "aperture-dev", wantName: LayoutCollection},
+		{name: "hashed", layout: "hashed", prefix: "aperture-dev", wantName: LayoutHashed},
+		{name: "content", layout: "content", prefix: "aperture-dev", wantName: LayoutContent},
+		{name: "unknown", layout: "flat", prefix: "aperture-dev", wantErr: true},
+		{name: "empty prefix", layout: "purpose", prefix: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewLayout(tt.layout, tt.prefix)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewLayout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Name() != tt.wantName {
+				t.Errorf("NewLayout() Name = %v, want %v", got.Name(), tt.wantName)
+			}
+		})
+	}
+}
+
+func TestLocate(t *testing.T) {
+	obj := Object{
+		Dataset:    "ds-123",
+		Version:    2,
+		File:       "images/scan.tif",
+		Collection: "Earth Sciences",
+		Access:     AccessRestricted,
+	}
+
+	tests := []struct {
+		name       string
+		layout     Layout
+		obj        Object
+		wantBucket string
+		wantKey    string
+		wantErr    bool
+	}{
+		{
+			name:       "purpose",
+			layout:     &PurposeLayout{Prefix: "aperture-dev"},
+			obj:        obj,
+			wantBucket: "aperture-dev-restricted-media",
+			wantKey:    "datasets/ds-123/v2/images/scan.tif",
+		},
+		{
+			name:       "collection",
+			layout:     &CollectionLayout{Prefix: "aperture-dev"},
+			obj:        obj,
+			wantBucket: "aperture-dev-earth-sciences",
+			wantKey:    "restricted/datasets/ds-123/v2/images/scan.tif",
+		},
+		{
+			name:       "collection without collection",
+			layout:     &CollectionLayout{Prefix: "aperture-dev"},
+			obj:        Object{Dataset: "ds-1", Version: 1, File: "a.csv", Access: AccessPublic},
+			wantBucket: "aperture-dev-public-media",
+			wantKey:    "datasets/ds-1/v1/a.csv",
+		},
+		{
+			name:       "file path is cleaned",
+			layout:     &PurposeLayout{Prefix: "aperture-dev"},
+			obj:        Object{Dataset: "ds-1", Version: 1, File: "../../etc/passwd", Access: AccessPublic},
+			wantBucket: "aperture-dev-public-media",
+			wantKey:    "datasets/ds-1/v1/etc/passwd",
+		},
+		{
+			name:       "content",
+			layout:     &ContentLayout{Prefix: "aperture-dev"},
+			obj:        Object{Dataset: "ds-1", Version: 3, File: "a.csv", Access: AccessPublic, SHA256: "E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855"},
+			wantBucket: "aperture-dev-public-media",
+			wantKey:    "content/sha256/e3/b0/e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+		{
+			name:    "content without digest",
+			layout:  &ContentLayout{Prefix: "aperture-dev"},
+			obj:     Object{Dataset: "ds-1", Version: 1, File: "a.csv", Access: AccessPublic, SHA256: "e3b0"},
+			wantErr: true,
+		},
+		{
+			name:    "missing version",
+			layout:  &PurposeLayout{Prefix: "aperture-dev"},
+			obj:     Object{Dataset: "ds-1", File: "a.csv", Access: AccessPublic},
+			wantErr: true,
+		},
+		{
+			name:    "invalid access",
+			layout:  &HashedLayout{Prefix: "aperture-dev"},
+			obj:     Object{Dataset: "ds-1", Version: 1, File: "a.csv", Access: "secret"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.layout.Locate(tt.obj)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Locate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Bucket != tt.wantBucket {
+				t.Errorf("Locate() Bucket = %v, want %v", got.Bucket, tt.wantBucket)
+			}
+			if got.Key != tt.wantKey {
+				t.Errorf("Locate() Key = %v, want %v", got.Key, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestHashedLayoutSpreadsKeys(t *testing.T) {
+	l := &HashedLayout{Prefix: "aperture-dev"}
+
+	a, err := l.Locate(Object{Dataset: "ds-a", Version: 1, File: "f", Access: AccessPublic})
+	if err != nil {
+		t.Fatalf("Locate() error = %v", err)
+	}
+	b, err := l.Locate(Object{Dataset: "ds-b", Version: 1, File: "f", Access: AccessPublic})
+	if err != nil {
+		t.Fatalf("Locate() error = %v", err)
+	}
+
+	if a.Key[:6] == b.Key[:6] {
+		t.Errorf("expected different hash prefixes, got %q and %q", a.Key, b.Key)
+	}
+	if !strings.HasSuffix(a.Key, "/datasets/ds-a/v1/f") {
+		t.Errorf("Locate() Key = %v, want dataset key suffix", a.Key)
+	}
+
+	again, _ := l.Locate(Object{Dataset: "ds-a", Version: 1, File: "f", Access: AccessPublic})
+	if again != a {
+		t.Errorf("Locate() not deterministic: %v != %v", again, a)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/s3"
+)
+
+// Tiered is a Backend of two tiers: a primary store, typically an
+// on-premises S3-compatible cluster holding recent data, and a cloud
+// store holding the buckets named with CloudPrefix, to which the files
+// of older versions are tiered. Each request goes to the tier of its
+// bucket, so that files are read and downloaded wherever their manifest
+// says they are.
+type Tiered struct {
+	Primary Backend
+	Cloud   Backend
+
+	// CloudPrefix prefixes the names of the cloud buckets
+	CloudPrefix string
+}
+
+var _ Backend = (*Tiered)(nil)
+
+// CloudBucket returns the cloud bucket that the primary bucket with the
+// given prefix tiers to, and false if bucket has another prefix.
+func CloudBucket(prefix, cloudPrefix, bucket string) (string, bool) {
+	rest, ok := strings.CutPrefix(bucket, prefix+"-")
+	if !ok {
+		return "", false
+	}
+	return cloudPrefix + "-" + rest, true
+}
+
+// InCloud reports whether bucket is one of the cloud buckets.
+func (t *Tiered) InCloud(bucket string) bool {
+	return strings.HasPrefix(bucket, t.CloudPrefix+"-")
+}
+
+// backend returns the tier holding bucket.
+func (t *Tiered) backend(bucket string) Backend {
+	if t.InCloud(bucket) {
+		return t.Cloud
+	}
+	return t.Primary
+}
+
+// PutObject implements Backend.
+func (t *Tiered) PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error {
+	return t.backend(bucket).PutObject(ctx, bucket, key, body, contentType)
+}
+
+// Upload implements Backend.
+func (t *Tiered) Upload(ctx context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error) {
+	return t.backend(bucket).Upload(ctx, bucket, key, r, opts)
+}
+
+// GetObject implements Backend.
+func (t *Tiered) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	return t.backend(bucket).GetObject(ctx, bucket, key)
+}
+
+// HeadObject implements Backend.
+func (t *Tiered) HeadObject(ctx context.Context, bucket, key string) (s3.ObjectInfo, error) {
+	return t.backend(bucket).HeadObject(ctx, bucket, key)
+}
+
+// GetObjectAttributes implements Backend.
+func (t *Tiered) GetObjectAttributes(ctx context.Context, bucket, key string) (s3.Attributes, error) {
+	return t.backend(bucket).GetObjectAttributes(ctx, bucket, key)
+}
+
+// ListObjects implements Backend.
+func (t *Tiered) ListObjects(ctx context.Context, bucket, prefix string, fn func(s3.ObjectInfo) error) error {
+	return t.backend(bucket).ListObjects(ctx, bucket, prefix, fn)
+}
+
+// CopyObject implements Backend. Objects are copied within a tier by
+// the store, and between tiers by streaming them through this process.
+func (t *Tiered) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	src, dst := t.backend(srcBucket), t.backend(dstBucket)
+	if src == dst {
+		return src.CopyObject(ctx, srcBucket, srcKey, dstBucket, dstKey)
+	}
+	info, err := src.HeadObject(ctx, srcBucket, srcKey)
+	if err != nil {
+		return err
+	}
+	body, err := src.GetObject(ctx, srcBucket, srcKey)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	_, err = dst.Upload(ctx, dstBucket, dstKey, body, s3.PutOptions{ContentType: info.ContentType, Size: info.Size})
+	return err
+}
+
+// DeleteObject implements Backend.
+func (t *Tiered) DeleteObject(ctx context.Context, bucket, key string) error {
+	return t.backend(bucket).DeleteObject(ctx, bucket, key)
+}
+
+// SetStorageClass implements Backend.
+func (t *Tiered) SetStorageClass(ctx context.Context, bucket, key, class string) error {
+	return t.backend(bucket).SetStorageClass(ctx, bucket, key, class)
+}
+
+// PresignGetObject implements Backend.
+func (t *Tiered) PresignGetObject(bucket, key string, expires time.Duration) string {
+	return t.backend(bucket).PresignGetObject(bucket, key, expires)
+}
+
+// PresignPutObject implements Backend.
+func (t *Tiered) PresignPutObject(bucket, key string, expires time.Duration) string {
+	return t.backend(bucket).PresignPutObject(bucket, key, expires)
+}
@@ -0,0 +1,96 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/s3"
+)
+
+// memBackend is an in-memory Backend of the methods Tiered routes in
+// these tests; the others panic.
+type memBackend struct {
+	Backend
+	name    string
+	objects map[string]string
+}
+
+func (m *memBackend) HeadObject(_ context.Context, bucket, key string) (s3.ObjectInfo, error) {
+	body, ok := m.objects[bucket+"/"+key]
+	if !ok {
+		return s3.ObjectInfo{}, s3.ErrNotFound
+	}
+	return s3.ObjectInfo{Key: key, Size: int64(len(body))}, nil
+}
+
+func (m *memBackend) GetObject(_ context.Context, bucket, key string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(m.objects[bucket+"/"+key])), nil
+}
+
+func (m *memBackend) Upload(_ context.Context, bucket, key string, r io.Reader, _ s3.PutOptions) (int64, error) {
+	b, err := io.ReadAll(r)
+	m.objects[bucket+"/"+key] = string(b)
+	return int64(len(b)), err
+}
+
+func (m *memBackend) CopyObject(_ context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	m.objects[dstBucket+"/"+dstKey] = m.objects[srcBucket+"/"+srcKey]
+	return nil
+}
+
+func (m *memBackend) PresignGetObject(bucket, key string, _ time.Duration) string {
+	return m.name + ":" + bucket + "/" + key
+}
+
+func TestTiered(t *testing.T) {
+	ctx := context.Background()
+	primary := &memBackend{name: "primary", objects: map[string]string{"ap-public-media/k": "data"}}
+	cloud := &memBackend{name: "cloud", objects: map[string]string{}}
+	tiered := &Tiered{Primary: primary, Cloud: cloud, CloudPrefix: "ap-cloud"}
+
+	if got := tiered.PresignGetObject("ap-public-media", "k", time.Minute); got != "primary:ap-public-media/k" {
+		t.Errorf("PresignGetObject(primary bucket) = %s", got)
+	}
+	if got := tiered.PresignGetObject("ap-cloud-public-media", "k", time.Minute); got != "cloud:ap-cloud-public-media/k" {
+		t.Errorf("PresignGetObject(cloud bucket) = %s", got)
+	}
+
+	if err := tiered.CopyObject(ctx, "ap-public-media", "k", "ap-cloud-public-media", "k"); err != nil {
+		t.Fatalf("CopyObject() between tiers error = %v", err)
+	}
+	if got := cloud.objects["ap-cloud-public-media/k"]; got != "data" {
+		t.Errorf("cloud copy = %q, want data", got)
+	}
+	if err := tiered.CopyObject(ctx, "ap-public-media", "k", "ap-public-media", "k2"); err != nil {
+		t.Fatalf("CopyObject() within a tier error = %v", err)
+	}
+	if _, ok := primary.objects["ap-public-media/k2"]; !ok {
+		t.Error("CopyObject() within the primary tier did not copy there")
+	}
+}
+
+func TestCloudBucket(t *testing.T) {
+	if got, ok := CloudBucket("ap", "ap-cloud", "ap-restricted-media"); !ok || got != "ap-cloud-restricted-media" {
+		t.Errorf("CloudBucket() = %q, %v", got, ok)
+	}
+	if _, ok := CloudBucket("ap", "ap-cloud", "other-public-media"); ok {
+		t.Error("CloudBucket() of another prefix's bucket = true")
+	}
+}
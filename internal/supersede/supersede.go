@@ -0,0 +1,226 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package supersede marks published datasets obsolete and links them to
+// the datasets replacing them. A superseded dataset stays published and
+// its identifier keeps resolving, since it may be cited, but its record
+// gains an IsObsoletedBy relation to the replacement, which gains an
+// Obsoletes relation back; both landing pages carry a banner linking
+// the other, and downloads of the superseded files may be preceded by a
+// warning page.
+package supersede
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/landing"
+)
+
+// ErrSuperseded is returned when superseding a dataset already
+// superseded by another, or by a dataset that is itself superseded.
+var ErrSuperseded = errors.New("dataset is already superseded")
+
+// PagePublisher re-renders a dataset's landing page.
+type PagePublisher interface {
+	Publish(ctx context.Context, datasetID string) (*landing.Change, error)
+}
+
+// PIDUpdater re-registers the metadata of a dataset's identifier.
+// *pid.Registrar implements it.
+type PIDUpdater interface {
+	Update(ctx context.Context, d *dataset.Dataset) error
+}
+
+// Options configure a supersession.
+type Options struct {
+	// Reason is shown on both landing pages
+	Reason string
+
+	// Warn shows a warning linking the replacement before each
+	// download of the superseded dataset's files
+	Warn bool
+}
+
+// Manager supersedes datasets.
+type Manager struct {
+	// Datasets is the dataset catalog
+	Datasets *dataset.Store
+
+	// Pages republishes landing pages; skipped if nil
+	Pages PagePublisher
+
+	// PIDs re-registers identifiers with the new relations; skipped if
+	// nil
+	PIDs PIDUpdater
+
+	// Log records supersessions; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Supersede marks the dataset old obsolete, replaced by the dataset
+// by, and returns both. Both must be published, have a persistent
+// identifier, and be managed by the principal in ctx. Superseding a
+// dataset again by the same replacement updates the reason and warning.
+// The datasets are saved before their identifiers and landing pages are
+// updated, and failures to update those are reported with how to retry.
+func (m *Manager) Supersede(ctx context.Context, oldRef, byRef string, opts Options) (*dataset.Dataset, *dataset.Dataset, error) {
+	old, err := m.managed(ctx, oldRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	by, err := m.managed(ctx, byRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case old.ID == by.ID:
+		return nil, nil, fmt.Errorf("%s cannot supersede itself", old.ID)
+	case old.SupersededBy != nil && old.SupersededBy.Dataset != by.ID:
+		return nil, nil, fmt.Errorf("%w: %s is superseded by %s", ErrSuperseded, old.ID, old.SupersededBy.Dataset)
+	case by.SupersededBy != nil:
+		return nil, nil, fmt.Errorf("%w: %s is itself superseded by %s; supersede %s with that instead",
+			ErrSuperseded, by.ID, by.SupersededBy.Dataset, old.ID)
+	}
+	obsoletedBy, byURL, err := relation("IsObsoletedBy", by)
+	if err != nil {
+		return nil, nil, err
+	}
+	obsoletes, oldURL, err := relation("Obsoletes", old)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := m.now().UTC()
+	reason := strings.TrimSpace(opts.Reason)
+	old.SupersededBy = &dataset.Supersession{Dataset: by.ID, Title: by.Title, URL: byURL, Reason: reason, Time: now, Warn: opts.Warn}
+	old.Amend(func(d *dataset.Dataset) { d.Related = relate(d.Related, obsoletedBy) })
+	by.Supersedes = slices.DeleteFunc(by.Supersedes, func(s dataset.Supersession) bool { return s.Dataset == old.ID })
+	by.Supersedes = append(by.Supersedes, dataset.Supersession{Dataset: old.ID, Title: old.Title, URL: oldURL, Reason: reason, Time: now})
+	by.Amend(func(d *dataset.Dataset) { d.Related = relate(d.Related, obsoletes) })
+
+	oldDetails := map[string]string{"supersededBy": by.ID, "identifier": obsoletedBy.Identifier, "warn": fmt.Sprint(opts.Warn)}
+	byDetails := map[string]string{"supersedes": old.ID, "identifier": obsoletes.Identifier}
+	m.note(ctx, old, "dataset.supersede", reason, oldDetails)
+	m.note(ctx, by, "dataset.supersede", reason, byDetails)
+	for _, d := range []*dataset.Dataset{old, by} {
+		if err := m.Datasets.Put(ctx, d); err != nil {
+			return nil, nil, err
+		}
+	}
+	if reason != "" {
+		oldDetails["reason"], byDetails["reason"] = reason, reason
+	}
+	if err := errors.Join(m.record(ctx, old, oldDetails), m.record(ctx, by, byDetails)); err != nil {
+		return nil, nil, err
+	}
+	return old, by, errors.Join(m.announce(ctx, old), m.announce(ctx, by))
+}
+
+// managed resolves ref to a published dataset the principal in ctx may
+// manage.
+func (m *Manager) managed(ctx context.Context, ref string) (*dataset.Dataset, error) {
+	d, err := m.Datasets.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Require(identity.FromContext(ctx), d.Resource(), authz.ActionManage); err != nil {
+		return nil, err
+	}
+	if d.State != dataset.StatePublished {
+		return nil, fmt.Errorf("%s is %s; only published datasets can be superseded or supersede", d.ID, d.State)
+	}
+	return d, nil
+}
+
+// relation returns a relation of type rel to d's persistent identifier
+// and the identifier's resolver URL.
+func relation(rel string, d *dataset.Dataset) (dataset.RelatedIdentifier, string, error) {
+	var id, url string
+	switch {
+	case d.DOI != "":
+		id, url = d.DOI, "https://doi.org/"+d.DOI
+	case d.ARK != "":
+		id, url = d.ARK, "https://n2t.net/"+d.ARK
+	case d.Handle != "":
+		id, url = "hdl:"+d.Handle, "https://hdl.handle.net/"+d.Handle
+	default:
+		return dataset.RelatedIdentifier{}, "", fmt.Errorf("%s has no persistent identifier to link", d.ID)
+	}
+	r, err := dataset.NewRelatedIdentifier(rel, id)
+	return r, url, err
+}
+
+// relate adds r to related, replacing any relation to the same
+// identifier.
+func relate(related []dataset.RelatedIdentifier, r dataset.RelatedIdentifier) []dataset.RelatedIdentifier {
+	related = slices.DeleteFunc(related, func(x dataset.RelatedIdentifier) bool { return x.Key() == r.Key() })
+	return append(related, r)
+}
+
+// announce re-registers d's identifier and republishes its landing
+// page. Failures leave d saved and are reported so the update can be
+// retried.
+func (m *Manager) announce(ctx context.Context, d *dataset.Dataset) error {
+	var errs []error
+	if m.PIDs != nil {
+		if err := m.PIDs.Update(ctx, d); err != nil {
+			errs = append(errs, fmt.Errorf("%s is saved, but %w; retry with 'aperture pid update %s'", d.ID, err, d.ID))
+		}
+	}
+	if m.Pages != nil {
+		if _, err := m.Pages.Publish(ctx, d.ID); err != nil {
+			errs = append(errs, fmt.Errorf("%s is saved, but %w; retry with 'aperture pages rebuild'", d.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// note appends an event to d's history; the caller saves d.
+func (m *Manager) note(ctx context.Context, d *dataset.Dataset, action, reason string, details map[string]string) {
+	d.History = append(d.History, dataset.Event{
+		Time:    m.now().UTC(),
+		Actor:   identity.FromContext(ctx).String(),
+		Action:  action,
+		Reason:  reason,
+		Details: maps.Clone(details),
+	})
+}
+
+// record appends an audit entry if a log is configured.
+func (m *Manager) record(ctx context.Context, d *dataset.Dataset, details map[string]string) error {
+	if m.Log == nil {
+		return nil
+	}
+	return audit.Record(ctx, m.Log, "dataset.supersede", d.ID, details)
+}
+
+func (m *Manager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,141 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supersede
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/landing"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// recorder records the datasets whose identifiers and pages were
+// updated.
+type recorder struct {
+	updated, published []string
+	err                error
+}
+
+func (r *recorder) Update(_ context.Context, d *dataset.Dataset) error {
+	r.updated = append(r.updated, d.ID)
+	return r.err
+}
+
+func (r *recorder) Publish(_ context.Context, id string) (*landing.Change, error) {
+	r.published = append(r.published, id)
+	return &landing.Change{DatasetID: id}, nil
+}
+
+func newManager(t *testing.T) (*Manager, *recorder, *audit.MemoryLog) {
+	t.Helper()
+	ctx := context.Background()
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	acl := &authz.ACL{Manage: []string{"user:pi@uni.edu"}}
+	for _, d := range []*dataset.Dataset{
+		{ID: "old", DOI: "10.5555/old", Title: "Ocean temperatures", State: dataset.StatePublished, Access: storage.AccessPublic, ACL: acl},
+		{ID: "new", DOI: "10.5555/new", Title: "Ocean temperatures, corrected", State: dataset.StatePublished, Access: storage.AccessPublic, ACL: acl},
+		{ID: "draft", DOI: "10.5555/draft", Title: "Next", State: dataset.StateDraft, Access: storage.AccessPublic, ACL: acl},
+		{ID: "other", DOI: "10.5555/other", Title: "Other", State: dataset.StatePublished, Access: storage.AccessPublic},
+	} {
+		if err := datasets.Put(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := &recorder{}
+	log := &audit.MemoryLog{}
+	return &Manager{
+		Datasets: datasets,
+		Pages:    r,
+		PIDs:     r,
+		Log:      log,
+		Now:      func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) },
+	}, r, log
+}
+
+func TestSupersede(t *testing.T) {
+	m, r, log := newManager(t)
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "pi@uni.edu"})
+
+	old, by, err := m.Supersede(ctx, "10.5555/old", "new", Options{Reason: "Calibration error", Warn: true})
+	if err != nil {
+		t.Fatalf("Supersede() error = %v", err)
+	}
+	if s := old.SupersededBy; s == nil || s.Dataset != "new" || s.URL != "https://doi.org/10.5555/new" || s.Title != by.Title || !s.Warn || s.Reason != "Calibration error" {
+		t.Errorf("SupersededBy = %+v", old.SupersededBy)
+	}
+	if len(by.Supersedes) != 1 || by.Supersedes[0].Dataset != "old" || by.Supersedes[0].URL != "https://doi.org/10.5555/old" {
+		t.Errorf("Supersedes = %+v", by.Supersedes)
+	}
+	if fmt.Sprint(old.Related) != "[{IsObsoletedBy 10.5555/new DOI}]" || fmt.Sprint(by.Related) != "[{Obsoletes 10.5555/old DOI}]" {
+		t.Errorf("related = %v and %v", old.Related, by.Related)
+	}
+	if fmt.Sprint(r.updated) != "[old new]" || fmt.Sprint(r.published) != "[old new]" {
+		t.Errorf("updated %v, published %v", r.updated, r.published)
+	}
+	if entries, _ := log.Entries(ctx); len(entries) != 2 || entries[0].Details["supersededBy"] != "new" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+	if saved, _ := m.Datasets.Get(ctx, "old"); saved.SupersededBy == nil || saved.State != dataset.StatePublished {
+		t.Errorf("saved = %+v", saved)
+	}
+
+	// Superseding again by the same dataset updates it; by another is
+	// refused, as is superseding by a superseded dataset.
+	old, by, err = m.Supersede(ctx, "old", "new", Options{})
+	if err != nil || old.SupersededBy.Warn || len(by.Supersedes) != 1 || len(old.Related) != 1 {
+		t.Errorf("repeated Supersede() = %+v, %+v, %v", old.SupersededBy, by.Supersedes, err)
+	}
+	if _, _, err := m.Supersede(ctx, "old", "draft", Options{}); err == nil {
+		t.Error("Supersede() by a draft succeeded")
+	}
+	acl := &authz.ACL{Manage: []string{"user:pi@uni.edu"}}
+	third := &dataset.Dataset{ID: "third", DOI: "10.5555/third", State: dataset.StatePublished, Access: storage.AccessPublic, ACL: acl}
+	if err := m.Datasets.Put(ctx, third); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Supersede(ctx, "old", "third", Options{}); !errors.Is(err, ErrSuperseded) {
+		t.Errorf("Supersede() of a superseded dataset error = %v, want ErrSuperseded", err)
+	}
+	if _, _, err := m.Supersede(ctx, "third", "old", Options{}); !errors.Is(err, ErrSuperseded) {
+		t.Errorf("Supersede() by a superseded dataset error = %v, want ErrSuperseded", err)
+	}
+}
+
+func TestSupersedeRequiresManagers(t *testing.T) {
+	m, r, _ := newManager(t)
+	ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: "pi@uni.edu"})
+	if _, _, err := m.Supersede(ctx, "other", "new", Options{}); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Supersede() of an unmanaged dataset error = %v, want ErrForbidden", err)
+	}
+	if _, _, err := m.Supersede(ctx, "old", "old", Options{}); err == nil {
+		t.Error("Supersede() by itself succeeded")
+	}
+
+	// Failed identifier updates leave the datasets superseded.
+	r.err = errors.New("DataCite unavailable")
+	old, _, err := m.Supersede(ctx, "old", "new", Options{})
+	if err == nil || old == nil || old.SupersededBy == nil {
+		t.Errorf("Supersede() with failing updates = %v, %v", old, err)
+	}
+}
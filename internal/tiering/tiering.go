@@ -0,0 +1,245 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tiering moves the files of older versions from the primary
+// store, typically an on-premises S3-compatible cluster holding recent
+// and frequently read data, to cloud buckets in an archival storage
+// class. A file is tiered once the version it belongs to has been
+// published for longer than the policy's age: its content is copied to
+// the cloud bucket of its primary bucket (see storage.CloudBucket), and
+// its manifest entry is updated to refer to the copy and record its
+// tier, so that downloads and fixity checks, which reach both tiers
+// through a storage.Tiered backend, find it there. The primary objects
+// are left for storage gc, which removes them once no manifest, such as
+// that of a newer version sharing the content, refers to them.
+package tiering
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// DefaultStorageClass is the storage class of tiered files: S3 Glacier
+// Instant Retrieval, whose objects are downloaded like any other.
+const DefaultStorageClass = "GLACIER_IR"
+
+// ObjectStore reads and stores objects of both tiers.
+// *storage.Tiered implements it.
+type ObjectStore interface {
+	HeadObject(ctx context.Context, bucket, key string) (s3.ObjectInfo, error)
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	Upload(ctx context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error)
+}
+
+// Move is a file to be tiered to the cloud.
+type Move struct {
+	Dataset string           `json:"dataset"`
+	Version int              `json:"version"`
+	Path    string           `json:"path"`
+	Size    int64            `json:"size"`
+	From    storage.Location `json:"from"`
+	To      storage.Location `json:"to"`
+
+	// Copy reports whether the content must be copied; false if another
+	// file already has it at To
+	Copy bool `json:"copy"`
+}
+
+// Report describes the files due to be tiered.
+type Report struct {
+	Moves []Move `json:"moves"`
+
+	// Files counts the files of published versions examined
+	Files int `json:"files"`
+
+	// Cloud counts the files already in the cloud tier
+	Cloud int `json:"cloud"`
+}
+
+// Bytes returns the bytes of content to be copied.
+func (r *Report) Bytes() int64 {
+	var n int64
+	for _, m := range r.Moves {
+		if m.Copy {
+			n += m.Size
+		}
+	}
+	return n
+}
+
+// Tierer applies the tiering policy.
+type Tierer struct {
+	Datasets *dataset.Store
+
+	// Objects reads the primary objects and stores their copies
+	Objects ObjectStore
+
+	// Prefix and CloudPrefix prefix the names of the primary and cloud
+	// buckets
+	Prefix      string
+	CloudPrefix string
+
+	// After is how long a version stays in the primary store after it
+	// is published
+	After time.Duration
+
+	// StorageClass is the class of the copies; DefaultStorageClass if
+	// empty
+	StorageClass string
+
+	// Log records tiered files; skipped if nil
+	Log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// Plan returns the files of versions published longer than After ago
+// that are still in the primary store. Nothing is copied or saved.
+func (t *Tierer) Plan(ctx context.Context) (*Report, error) {
+	datasets, err := t.Datasets.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dataset manifests: %w", err)
+	}
+	now := time.Now
+	if t.Now != nil {
+		now = t.Now
+	}
+	cutoff := now().Add(-t.After)
+	r := &Report{Moves: []Move{}}
+	planned := make(map[storage.Location]bool)
+	for _, d := range datasets {
+		for _, v := range d.Versions {
+			if v.PublishedAt == nil || v.Pruned != nil {
+				continue
+			}
+			for _, f := range v.Files {
+				r.Files++
+				if f.Tier == dataset.TierCloud {
+					r.Cloud++
+					continue
+				}
+				if v.PublishedAt.After(cutoff) {
+					continue
+				}
+				bucket, ok := storage.CloudBucket(t.Prefix, t.CloudPrefix, f.Bucket)
+				if !ok {
+					continue
+				}
+				to := storage.Location{Bucket: bucket, Key: f.Key}
+				r.Moves = append(r.Moves, Move{Dataset: d.ID, Version: v.Number, Path: f.Path, Size: f.Size, From: storage.Location{Bucket: f.Bucket, Key: f.Key}, To: to, Copy: !planned[to]})
+				planned[to] = true
+			}
+		}
+	}
+	return r, nil
+}
+
+// Apply copies the content of the moves in r to the cloud and updates
+// the manifests to refer to it, returning the number of files tiered.
+// Content already in the cloud is not copied again, so Apply may be
+// re-run after an interruption.
+func (t *Tierer) Apply(ctx context.Context, r *Report) (int, error) {
+	byDataset := make(map[string][]Move)
+	var order []string
+	for _, mv := range r.Moves {
+		if byDataset[mv.Dataset] == nil {
+			order = append(order, mv.Dataset)
+		}
+		byDataset[mv.Dataset] = append(byDataset[mv.Dataset], mv)
+	}
+
+	tiered := 0
+	for _, id := range order {
+		d, err := t.Datasets.Get(ctx, id)
+		if err != nil {
+			return tiered, err
+		}
+		n := 0
+		for _, mv := range byDataset[id] {
+			v := d.Version(mv.Version)
+			if v == nil {
+				continue
+			}
+			f := file(v, mv.Path)
+			// Skip files changed since the plan was made.
+			if f == nil || f.Tier != "" || f.Bucket != mv.From.Bucket || f.Key != mv.From.Key {
+				continue
+			}
+			if err := t.place(ctx, mv, f); err != nil {
+				return tiered, err
+			}
+			f.Bucket, f.Key, f.Tier = mv.To.Bucket, mv.To.Key, dataset.TierCloud
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		if err := t.Datasets.Put(ctx, d); err != nil {
+			return tiered, err
+		}
+		tiered += n
+		if t.Log != nil {
+			if err := audit.Record(ctx, t.Log, "storage.tier", d.ID, map[string]string{"files": fmt.Sprint(n)}); err != nil {
+				return tiered, err
+			}
+		}
+	}
+	return tiered, nil
+}
+
+// file returns the file of v at path, or nil.
+func file(v *dataset.Version, path string) *dataset.File {
+	for i := range v.Files {
+		if v.Files[i].Path == path {
+			return &v.Files[i]
+		}
+	}
+	return nil
+}
+
+// place copies the content of f to mv.To unless it is there, checking
+// that the copy has the size of the file.
+func (t *Tierer) place(ctx context.Context, mv Move, f *dataset.File) error {
+	_, err := t.Objects.HeadObject(ctx, mv.To.Bucket, mv.To.Key)
+	switch {
+	case err == nil:
+		return nil
+	case !errors.Is(err, s3.ErrNotFound):
+		return fmt.Errorf("failed to check %s: %w", mv.To, err)
+	}
+	body, err := t.Objects.GetObject(ctx, mv.From.Bucket, mv.From.Key)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", mv.From, err)
+	}
+	defer body.Close()
+	opts := s3.PutOptions{ContentType: f.ContentType, StorageClass: cmp.Or(t.StorageClass, DefaultStorageClass), Size: f.Size}
+	n, err := t.Objects.Upload(ctx, mv.To.Bucket, mv.To.Key, body, opts)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", mv.From, mv.To, err)
+	}
+	if n != f.Size {
+		return fmt.Errorf("copied %d bytes of %s to %s, want %d", n, mv.From, mv.To, f.Size)
+	}
+	return nil
+}
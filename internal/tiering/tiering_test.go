@@ -0,0 +1,142 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tiering
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/dataset"
+	"github.com/scttfrdmn/aperture/internal/s3"
+	"github.com/scttfrdmn/aperture/internal/state"
+	"github.com/scttfrdmn/aperture/internal/storage"
+)
+
+// fakeStore is an in-memory ObjectStore keyed by bucket/key.
+type fakeStore struct {
+	objects map[string]string
+	classes map[string]string
+}
+
+func (f *fakeStore) HeadObject(_ context.Context, bucket, key string) (s3.ObjectInfo, error) {
+	body, ok := f.objects[bucket+"/"+key]
+	if !ok {
+		return s3.ObjectInfo{}, s3.ErrNotFound
+	}
+	return s3.ObjectInfo{Key: key, Size: int64(len(body))}, nil
+}
+
+func (f *fakeStore) GetObject(_ context.Context, bucket, key string) (io.ReadCloser, error) {
+	body, ok := f.objects[bucket+"/"+key]
+	if !ok {
+		return nil, s3.ErrNotFound
+	}
+	return io.NopCloser(strings.NewReader(body)), nil
+}
+
+func (f *fakeStore) Upload(_ context.Context, bucket, key string, r io.Reader, opts s3.PutOptions) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	f.objects[bucket+"/"+key] = string(b)
+	f.classes[bucket+"/"+key] = opts.StorageClass
+	return int64(len(b)), nil
+}
+
+func TestTierer(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	old, recent := now.AddDate(-2, 0, 0), now.AddDate(0, -1, 0)
+	datasets := dataset.NewStore(state.NewMemoryStore())
+	d := &dataset.Dataset{
+		ID:     "ds-1",
+		Title:  "Ocean temperatures",
+		State:  dataset.StatePublished,
+		Access: storage.AccessPublic,
+		Versions: []dataset.Version{
+			{Number: 1, PublishedAt: &old, Files: []dataset.File{
+				{Path: "a.csv", Size: 5, Bucket: "ap-public-media", Key: "content/a"},
+				{Path: "b.csv", Size: 5, Bucket: "ap-public-media", Key: "ds-1/v1/b.csv"},
+			}},
+			{Number: 2, PublishedAt: &recent, Files: []dataset.File{
+				{Path: "a.csv", Size: 5, Bucket: "ap-public-media", Key: "content/a"},
+			}},
+			{Number: 3, Files: []dataset.File{
+				{Path: "c.csv", Size: 5, Bucket: "ap-public-media", Key: "ds-1/v3/c.csv"},
+			}},
+		},
+	}
+	if err := datasets.Put(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+
+	objects := &fakeStore{
+		objects: map[string]string{"ap-public-media/content/a": "aaaaa", "ap-public-media/ds-1/v1/b.csv": "bbbbb"},
+		classes: map[string]string{},
+	}
+	log := &audit.MemoryLog{}
+	tr := &Tierer{
+		Datasets:    datasets,
+		Objects:     objects,
+		Prefix:      "ap",
+		CloudPrefix: "ap-cloud",
+		After:       365 * 24 * time.Hour,
+		Log:         log,
+		Now:         func() time.Time { return now },
+	}
+	r, err := tr.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(r.Moves) != 2 || r.Files != 3 || r.Bytes() != 10 {
+		t.Fatalf("Plan() = %+v", r)
+	}
+	if r.Moves[0].To != (storage.Location{Bucket: "ap-cloud-public-media", Key: "content/a"}) {
+		t.Errorf("Plan() moves %s to %s", r.Moves[0].From, r.Moves[0].To)
+	}
+
+	tiered, err := tr.Apply(ctx, r)
+	if err != nil || tiered != 2 {
+		t.Fatalf("Apply() = %d, %v; want 2 tiered", tiered, err)
+	}
+	if got := objects.classes["ap-cloud-public-media/ds-1/v1/b.csv"]; got != DefaultStorageClass {
+		t.Errorf("storage class of copy = %q, want %s", got, DefaultStorageClass)
+	}
+	got, err := datasets.Get(ctx, "ds-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1, v2 := got.Version(1), got.Version(2)
+	if f := v1.Files[0]; f.Bucket != "ap-cloud-public-media" || f.Tier != dataset.TierCloud {
+		t.Errorf("tiered file = %+v", f)
+	}
+	// The newer version sharing the content still reads it on-premises.
+	if f := v2.Files[0]; f.Bucket != "ap-public-media" || f.Tier != "" {
+		t.Errorf("recent file = %+v", f)
+	}
+	if entries, _ := log.Entries(ctx); len(entries) != 1 || entries[0].Action != "storage.tier" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+
+	// Once tiered, nothing is left to move.
+	if r, err := tr.Plan(ctx); err != nil || len(r.Moves) != 0 || r.Cloud != 2 {
+		t.Errorf("Plan() after Apply() = %+v, %v", r, err)
+	}
+}
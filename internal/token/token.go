@@ -0,0 +1,384 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package token issues scoped machine tokens for service accounts.
+//
+// Instrument pipelines and CI systems authenticate with a token rather
+// than a person's credentials. Each token acts as the service account
+// "svc:<name>", which datasets grant access to like any other user
+// (user:svc:<name>), and is limited to the scopes it was issued with.
+// Only a hash of each token is stored; the token itself is shown once,
+// when it is created.
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+// tokensTable holds one Token per secret hash.
+const tokensTable = "machine-tokens"
+
+// accountsTable holds one Account per service account name.
+const accountsTable = "machine-accounts"
+
+// Prefix starts every token so that secret scanners can recognize
+// leaked ones.
+const Prefix = "apt_"
+
+// AccountPrefix starts the principal ID of every service account.
+const AccountPrefix = "svc:"
+
+// DefaultTTL is how long tokens remain valid if no lifetime is given.
+const DefaultTTL = 90 * 24 * time.Hour
+
+// MaxTTL is the longest lifetime a token may be issued with.
+const MaxTTL = 366 * 24 * time.Hour
+
+// idLength is the number of secret hash characters used as a token ID.
+const idLength = 12
+
+// Scopes.
+const (
+	ScopeUpload        = "upload"
+	ScopeDownload      = "download"
+	ScopeDatasetsRead  = "datasets:read"
+	ScopeDatasetsWrite = "datasets:write"
+	ScopeDOIRead       = "doi:read"
+	ScopeDOIWrite      = "doi:write"
+
+	// ScopeUsersWrite provisions accounts through SCIM; only user
+	// administrators may issue it
+	ScopeUsersWrite = "users:write"
+)
+
+// Scopes lists the valid scopes.
+var Scopes = []string{ScopeUpload, ScopeDownload, ScopeDatasetsRead, ScopeDatasetsWrite, ScopeDOIRead, ScopeDOIWrite, ScopeUsersWrite}
+
+var (
+	// ErrNotFound is returned for unknown tokens and token IDs.
+	ErrNotFound = errors.New("machine token not found")
+
+	// ErrExpired is returned when authenticating with an expired token.
+	ErrExpired = errors.New("machine token expired")
+
+	// ErrRevoked is returned when authenticating with a revoked token.
+	ErrRevoked = errors.New("machine token revoked")
+)
+
+// validName matches service account names.
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,62}$`)
+
+// Token is a stored machine token.
+type Token struct {
+	ID          string     `json:"id"`
+	TokenSHA256 string     `json:"tokenSha256"`
+	Name        string     `json:"name"`
+	Scopes      []string   `json:"scopes"`
+	Note        string     `json:"note,omitempty"`
+	CreatedBy   string     `json:"createdBy"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	Expires     time.Time  `json:"expires"`
+	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+	RevokedBy   string     `json:"revokedBy,omitempty"`
+}
+
+// Account is a service account, owned by the person who first issued a
+// token for it. Only its owner and user administrators may issue more.
+type Account struct {
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Status describes t at time now: "active", "expired", or "revoked".
+func (t *Token) Status(now time.Time) string {
+	switch {
+	case t.RevokedAt != nil:
+		return "revoked"
+	case !now.Before(t.Expires):
+		return "expired"
+	}
+	return "active"
+}
+
+// Principal returns the service account t acts as.
+func (t *Token) Principal() identity.Principal {
+	return identity.Principal{ID: AccountPrefix + t.Name, Scopes: slices.Clone(t.Scopes)}
+}
+
+// ParseScopes validates a comma-separated scope list and returns the
+// sorted, deduplicated scopes.
+func ParseScopes(list string) ([]string, error) {
+	var scopes []string
+	for _, s := range strings.Split(list, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+		if !slices.Contains(Scopes, s) {
+			return nil, fmt.Errorf("unknown scope %q (want %s)", s, strings.Join(Scopes, ", "))
+		}
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required (%s)", strings.Join(Scopes, ", "))
+	}
+	slices.Sort(scopes)
+	return scopes, nil
+}
+
+// Registry stores machine tokens.
+type Registry struct {
+	s   state.Store
+	log audit.Log
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// NewRegistry returns a registry backed by s that records token
+// changes in log, which may be nil.
+func NewRegistry(s state.Store, log audit.Log) *Registry {
+	return &Registry{s: s, log: log}
+}
+
+// Create issues a token for the service account name with scopes, valid
+// for ttl (DefaultTTL if zero). It returns the secret, which is not
+// stored, and the stored token. Machine tokens cannot create tokens,
+// and tokens for an existing account are issued only to its owner and
+// user administrators, since a token acts with every grant the account
+// holds.
+func (r *Registry) Create(ctx context.Context, name string, scopes []string, ttl time.Duration, note string) (string, *Token, error) {
+	p := identity.FromContext(ctx)
+	if p.IsZero() || p.IsMachine() {
+		return "", nil, fmt.Errorf("%w: machine tokens must be created by a signed-in person", authz.ErrForbidden)
+	}
+	name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, AccountPrefix)))
+	if !validName.MatchString(name) {
+		return "", nil, fmt.Errorf("invalid service account name %q (lowercase letters, digits, '.', '_', '-')", name)
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("at least one scope is required")
+	}
+	for _, s := range scopes {
+		if !slices.Contains(Scopes, s) {
+			return "", nil, fmt.Errorf("unknown scope %q", s)
+		}
+	}
+	if slices.Contains(scopes, ScopeUsersWrite) {
+		if err := authz.RequirePermission(p, authz.PermManageUsers); err != nil {
+			return "", nil, err
+		}
+	}
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	if ttl < 0 || ttl > MaxTTL {
+		return "", nil, fmt.Errorf("invalid lifetime %s (at most %d days)", ttl, int(MaxTTL.Hours()/24))
+	}
+	now := r.now().UTC()
+	if err := r.claim(ctx, p, name, now); err != nil {
+		return "", nil, err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	secret := Prefix + base64.RawURLEncoding.EncodeToString(buf)
+	hash := hashToken(secret)
+
+	t := &Token{
+		ID:          hash[:idLength],
+		TokenSHA256: hash,
+		Name:        name,
+		Scopes:      slices.Sorted(slices.Values(scopes)),
+		Note:        strings.TrimSpace(note),
+		CreatedBy:   p.String(),
+		CreatedAt:   now,
+		Expires:     now.Add(ttl),
+	}
+	if err := r.s.Put(ctx, tokensTable, hash, t); err != nil {
+		return "", nil, err
+	}
+	if err := r.record(ctx, "token.create", t); err != nil {
+		return "", nil, err
+	}
+	return secret, t, nil
+}
+
+// claim checks that p may issue tokens for the account name, recording
+// p as its owner if the account is new. Of several callers creating the
+// first token of an account at once, one becomes its owner.
+func (r *Registry) claim(ctx context.Context, p identity.Principal, name string, now time.Time) error {
+	a := Account{Name: name, Owner: p.String(), CreatedAt: now}
+	err := r.s.Create(ctx, accountsTable, name, &a)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, state.ErrExists) {
+		return err
+	}
+	if err := r.s.Get(ctx, accountsTable, name, &a); err != nil {
+		return err
+	}
+	if a.Owner != p.String() && authz.RequirePermission(p, authz.PermManageUsers) != nil {
+		return fmt.Errorf("%w: service account %s%s belongs to %s", authz.ErrForbidden, AccountPrefix, name, a.Owner)
+	}
+	return nil
+}
+
+// Authenticate returns the active token for secret, or an error
+// wrapping ErrNotFound, ErrExpired, or ErrRevoked. Use is recorded at
+// most hourly to keep authentication cheap.
+func (r *Registry) Authenticate(ctx context.Context, secret string) (*Token, error) {
+	var t Token
+	err := r.s.Get(ctx, tokensTable, hashToken(strings.TrimSpace(secret)), &t)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := r.now().UTC()
+	switch t.Status(now) {
+	case "revoked":
+		return nil, fmt.Errorf("%w on %s", ErrRevoked, t.RevokedAt.Format(time.DateOnly))
+	case "expired":
+		return nil, fmt.Errorf("%w on %s", ErrExpired, t.Expires.Format(time.DateOnly))
+	}
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= time.Hour {
+		t.LastUsedAt = &now
+		if err := r.s.Put(ctx, tokensTable, t.TokenSHA256, &t); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+// List returns the tokens created by the acting principal, or every
+// token for administrators when all is set, newest first.
+func (r *Registry) List(ctx context.Context, all bool) ([]Token, error) {
+	p := identity.FromContext(ctx)
+	if all && !slices.Contains(p.Groups, authz.AdminGroup) {
+		return nil, fmt.Errorf("%w: only administrators may list every machine token", authz.ErrForbidden)
+	}
+	tokens, err := state.List[Token](ctx, r.s, tokensTable)
+	if err != nil {
+		return nil, err
+	}
+	out := tokens[:0]
+	for _, t := range tokens {
+		if all || t.CreatedBy == p.String() {
+			out = append(out, t)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+// Get returns the token with the given ID or ID prefix.
+func (r *Registry) Get(ctx context.Context, ref string) (*Token, error) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	if ref == "" {
+		return nil, ErrNotFound
+	}
+	tokens, err := state.List[Token](ctx, r.s, tokensTable)
+	if err != nil {
+		return nil, err
+	}
+	var match *Token
+	for i := range tokens {
+		if strings.HasPrefix(tokens[i].ID, ref) {
+			if match != nil {
+				return nil, fmt.Errorf("machine token ID %q is ambiguous", ref)
+			}
+			match = &tokens[i]
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+	}
+	return match, nil
+}
+
+// Revoke disables the token with the given ID or ID prefix. Only its
+// creator and administrators may revoke a token; revoking a revoked
+// token is a no-op.
+func (r *Registry) Revoke(ctx context.Context, ref string) (*Token, error) {
+	t, err := r.Get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	p := identity.FromContext(ctx)
+	if t.CreatedBy != p.String() && !slices.Contains(p.Groups, authz.AdminGroup) {
+		return nil, fmt.Errorf("%w: %s did not create machine token %s", authz.ErrForbidden, p, t.ID)
+	}
+	if t.RevokedAt != nil {
+		return t, nil
+	}
+	now := r.now().UTC()
+	t.RevokedAt = &now
+	t.RevokedBy = p.String()
+	if err := r.s.Put(ctx, tokensTable, t.TokenSHA256, t); err != nil {
+		return nil, err
+	}
+	if err := r.record(ctx, "token.revoke", t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *Registry) record(ctx context.Context, action string, t *Token) error {
+	if r.log == nil {
+		return nil
+	}
+	return audit.Record(ctx, r.log, action, AccountPrefix+t.Name, map[string]string{
+		"token":   t.ID,
+		"scopes":  strings.Join(t.Scopes, ","),
+		"expires": t.Expires.Format(time.RFC3339),
+	})
+}
+
+func (r *Registry) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// hashToken returns the hex-encoded SHA-256 of secret.
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
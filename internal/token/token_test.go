@@ -0,0 +1,168 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package token
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aperture/internal/audit"
+	"github.com/scttfrdmn/aperture/internal/authz"
+	"github.com/scttfrdmn/aperture/internal/identity"
+	"github.com/scttfrdmn/aperture/internal/state"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{"upload,doi:read", []string{"doi:read", "upload"}, false},
+		{" upload , UPLOAD ", []string{"upload"}, false},
+		{"", nil, true},
+		{"upload,admin", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseScopes(tt.list)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("ParseScopes(%q) = %v, %v; want %v, wantErr %v", tt.list, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRegistry(state.NewMemoryStore(), &audit.MemoryLog{})
+	r.Now = func() time.Time { return now }
+	alice := identity.WithPrincipal(context.Background(), identity.Principal{ID: "alice@uni.edu"})
+
+	secret, tok, err := r.Create(alice, "Microscope-1", []string{ScopeUpload, ScopeDOIRead}, 0, "lab pipeline")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !strings.HasPrefix(secret, Prefix) || tok.Name != "microscope-1" || !tok.Expires.Equal(now.Add(DefaultTTL)) {
+		t.Errorf("Create() = %q, %+v", secret, tok)
+	}
+	if _, _, err := r.Create(alice, "bad name", []string{ScopeUpload}, 0, ""); err == nil {
+		t.Error("Create() accepted an invalid name")
+	}
+	if _, _, err := r.Create(alice, "ci", []string{ScopeUpload}, 400*24*time.Hour, ""); err == nil {
+		t.Error("Create() accepted a lifetime over MaxTTL")
+	}
+	if _, _, err := r.Create(alice, "idm", []string{ScopeUsersWrite}, 0, ""); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Create() of a %s token by a non-administrator error = %v, want ErrForbidden", ScopeUsersWrite, err)
+	}
+
+	got, err := r.Authenticate(context.Background(), secret)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	p := got.Principal()
+	if p.ID != "svc:microscope-1" || !p.HasScope(ScopeUpload) || p.HasScope(ScopeDatasetsWrite) {
+		t.Errorf("Principal() = %+v", p)
+	}
+	if got.LastUsedAt == nil {
+		t.Error("Authenticate() did not record use")
+	}
+	machine := identity.WithPrincipal(context.Background(), p)
+	if _, _, err := r.Create(machine, "child", []string{ScopeUpload}, 0, ""); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Create() by a machine token error = %v, want ErrForbidden", err)
+	}
+	if _, err := r.Authenticate(context.Background(), secret+"x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Authenticate() with a wrong secret error = %v, want ErrNotFound", err)
+	}
+
+	bob := identity.WithPrincipal(context.Background(), identity.Principal{ID: "bob@uni.edu"})
+	if list, _ := r.List(bob, false); len(list) != 0 {
+		t.Errorf("List() for bob = %v, want none", list)
+	}
+	if _, err := r.List(bob, true); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("List(all) by non-admin error = %v, want ErrForbidden", err)
+	}
+	if _, err := r.Revoke(bob, tok.ID); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Revoke() by bob error = %v, want ErrForbidden", err)
+	}
+	if _, err := r.Revoke(alice, tok.ID[:6]); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if _, err := r.Authenticate(context.Background(), secret); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Authenticate() after revoke error = %v, want ErrRevoked", err)
+	}
+
+	secret, _, _ = r.Create(alice, "ci", []string{ScopeDownload}, 24*time.Hour, "")
+	now = now.Add(25 * time.Hour)
+	if _, err := r.Authenticate(context.Background(), secret); !errors.Is(err, ErrExpired) {
+		t.Errorf("Authenticate() after expiry error = %v, want ErrExpired", err)
+	}
+}
+
+func TestAccountOwner(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := state.NewMemoryStore()
+	r := NewRegistry(s, nil)
+	r.Now = func() time.Time { return now }
+	alice := identity.WithPrincipal(context.Background(), identity.Principal{ID: "alice@uni.edu"})
+	bob := identity.WithPrincipal(context.Background(), identity.Principal{ID: "bob@uni.edu"})
+	admin := identity.WithPrincipal(context.Background(), identity.Principal{ID: "ops@uni.edu", Groups: []string{authz.AdminGroup}})
+
+	if _, _, err := r.Create(alice, "microscope-1", []string{ScopeUpload}, 0, ""); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, _, err := r.Create(bob, "svc:microscope-1", []string{ScopeDownload}, 0, ""); !errors.Is(err, authz.ErrForbidden) {
+		t.Errorf("Create() under alice's account by bob error = %v, want ErrForbidden", err)
+	}
+	if _, _, err := r.Create(alice, "microscope-1", []string{ScopeDownload}, 0, ""); err != nil {
+		t.Errorf("Create() by the owner error = %v", err)
+	}
+	if _, _, err := r.Create(admin, "microscope-1", []string{ScopeDownload}, 0, ""); err != nil {
+		t.Errorf("Create() by a user administrator error = %v", err)
+	}
+	if _, _, err := r.Create(bob, "microscope-2", []string{ScopeUpload}, 0, ""); err != nil {
+		t.Errorf("Create() of a new account by bob error = %v", err)
+	}
+
+	// Of several users creating the first token of an account at once,
+	// one owns it and the rest are refused.
+	users := []string{"carol@uni.edu", "dave@uni.edu", "erin@uni.edu", "frank@uni.edu"}
+	errs := make([]error, len(users))
+	var wg sync.WaitGroup
+	for i, u := range users {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := identity.WithPrincipal(context.Background(), identity.Principal{ID: u})
+			_, _, errs[i] = r.Create(ctx, "shared", []string{ScopeUpload}, 0, "")
+		}()
+	}
+	wg.Wait()
+	var a Account
+	if err := s.Get(context.Background(), accountsTable, "shared", &a); err != nil {
+		t.Fatal(err)
+	}
+	for i, u := range users {
+		switch {
+		case u == a.Owner && errs[i] != nil:
+			t.Errorf("Create() by the owner %s error = %v", u, errs[i])
+		case u != a.Owner && !errors.Is(errs[i], authz.ErrForbidden):
+			t.Errorf("Create() by %s under %s's account error = %v, want ErrForbidden", u, a.Owner, errs[i])
+		}
+	}
+}
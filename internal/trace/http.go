@@ -0,0 +1,91 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Handler wraps h to record a server span for each request it serves,
+// continuing the caller's trace when the request carries a W3C
+// traceparent header. The request context carries t, so the work done
+// for the request is traced as children of the span.
+func Handler(t *Tracer, service string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithTracer(r.Context(), t)
+		if tr, parent, ok := parseTraceparent(r.Header.Get("Traceparent")); ok {
+			ctx = withRemote(ctx, tr, parent)
+		}
+		ctx, span := start(ctx, Server, service+" "+r.Method, []Attr{
+			String("http.request.method", r.Method),
+			String("url.path", r.URL.Path),
+		})
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttrs(Int("http.response.status_code", int64(sw.status)))
+		var err error
+		if sw.status >= 500 {
+			err = fmt.Errorf("HTTP %d", sw.status)
+		}
+		span.End(err)
+	})
+}
+
+// parseTraceparent parses a version 00 traceparent header.
+func parseTraceparent(h string) (TraceID, SpanID, bool) {
+	var tr TraceID
+	var parent SpanID
+	parts := strings.Split(h, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return tr, parent, false
+	}
+	if _, err := hex.Decode(tr[:], []byte(parts[1])); err != nil {
+		return tr, parent, false
+	}
+	if _, err := hex.Decode(parent[:], []byte(parts[2])); err != nil {
+		return tr, parent, false
+	}
+	return tr, parent, tr.IsValid() && parent.IsValid()
+}
+
+// statusWriter records the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,129 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// scope names the instrumentation in exported spans.
+const scope = "github.com/scttfrdmn/aperture"
+
+// OTLP exports spans to an OpenTelemetry collector with the OTLP/HTTP
+// protocol, JSON encoded. The collector, such as the AWS Distro for
+// OpenTelemetry, forwards them to X-Ray, Jaeger, or another backend.
+type OTLP struct {
+	// Endpoint is the URL spans are posted to, conventionally
+	// ending in /v1/traces
+	Endpoint string
+
+	// Headers are added to every request, e.g. for authentication
+	Headers map[string]string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Export implements Exporter.
+func (o *OTLP) Export(ctx context.Context, resource []Attr, spans []Record) error {
+	body, err := json.Marshal(exportRequest(resource, spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.Headers {
+		req.Header.Set(k, v)
+	}
+	client := o.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("OTLP export failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	return nil
+}
+
+// exportRequest returns the OTLP ExportTraceServiceRequest of spans
+// in its protobuf JSON mapping, in which 64-bit integers are strings
+// and IDs are hex.
+func exportRequest(resource []Attr, spans []Record) map[string]any {
+	out := make([]map[string]any, len(spans))
+	for i, s := range spans {
+		span := map[string]any{
+			"traceId":           s.TraceID.String(),
+			"spanId":            s.SpanID.String(),
+			"name":              s.Name,
+			"kind":              s.Kind,
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.End.UnixNano(), 10),
+			"attributes":        attributes(s.Attrs),
+		}
+		if s.Parent.IsValid() {
+			span["parentSpanId"] = s.Parent.String()
+		}
+		if s.Error != "" {
+			span["status"] = map[string]any{"code": 2, "message": s.Error}
+		}
+		out[i] = span
+	}
+	return map[string]any{
+		"resourceSpans": []map[string]any{{
+			"resource": map[string]any{"attributes": attributes(resource)},
+			"scopeSpans": []map[string]any{{
+				"scope": map[string]string{"name": scope},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+// attributes returns attrs as OTLP key-value pairs.
+func attributes(attrs []Attr) []map[string]any {
+	out := make([]map[string]any, 0, len(attrs))
+	for _, a := range attrs {
+		var v map[string]any
+		switch x := a.Value.(type) {
+		case string:
+			v = map[string]any{"stringValue": x}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+		case float64:
+			v = map[string]any{"doubleValue": x}
+		case bool:
+			v = map[string]any{"boolValue": x}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(x)}
+		}
+		out = append(out, map[string]any{"key": a.Key, "value": v})
+	}
+	return out
+}
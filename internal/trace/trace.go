@@ -0,0 +1,297 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trace records OpenTelemetry spans and exports them over OTLP.
+//
+// A span times one step of the work, such as a command, an S3 request,
+// a DataCite call, or rendering a landing page. Spans started from a
+// context carrying another span become its children, so a slow publish
+// can be broken down into the steps that made it slow. Tracing is off
+// unless a Tracer is attached to the context: Start then returns a nil
+// span, whose methods do nothing.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Kind is the OpenTelemetry kind of a span.
+type Kind int
+
+// Kinds.
+const (
+	// Internal spans time work within the process
+	Internal Kind = 1
+
+	// Server spans time the handling of an incoming request
+	Server Kind = 2
+
+	// Client spans time an outgoing request
+	Client Kind = 3
+)
+
+// TraceID identifies a trace: all the spans of one operation.
+type TraceID [16]byte
+
+// String returns the ID in hex.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is not all zeros.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// String returns the ID in hex.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is not all zeros.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// Attr is a span or resource attribute.
+type Attr struct {
+	Key string
+
+	// Value is a string, int64, float64, or bool
+	Value any
+}
+
+// String returns a string attribute.
+func String(key, value string) Attr { return Attr{Key: key, Value: value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int64) Attr { return Attr{Key: key, Value: value} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attr { return Attr{Key: key, Value: value} }
+
+// Record is a finished span.
+type Record struct {
+	TraceID TraceID
+	SpanID  SpanID
+
+	// Parent is the span this one is a child of; invalid for the root
+	Parent SpanID
+
+	Name  string
+	Kind  Kind
+	Start time.Time
+	End   time.Time
+	Attrs []Attr
+
+	// Error is the error the span ended with; empty if it succeeded
+	Error string
+}
+
+// Span is a span in progress. A nil span, returned when tracing is
+// off, ignores every call.
+type Span struct {
+	tracer *Tracer
+
+	mu    sync.Mutex
+	rec   Record
+	ended bool
+}
+
+// SetAttrs adds attributes to s.
+func (s *Span) SetAttrs(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rec.Attrs = append(s.rec.Attrs, attrs...)
+}
+
+// End finishes s, marking it failed if err is non-nil, and queues it
+// for export. Calls after the first are ignored.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.rec.End = s.tracer.now()
+	if err != nil {
+		s.rec.Error = err.Error()
+	}
+	rec := s.rec
+	s.mu.Unlock()
+	s.tracer.add(rec)
+}
+
+// Exporter sends finished spans to a tracing backend. *OTLP
+// implements it.
+type Exporter interface {
+	Export(ctx context.Context, resource []Attr, spans []Record) error
+}
+
+// Tracer collects finished spans and exports them in batches: when
+// BatchSize spans are waiting, when FlushInterval has passed since the
+// last export, and on Flush.
+type Tracer struct {
+	// Exporter receives the spans
+	Exporter Exporter
+
+	// Resource describes the process, e.g. service.name
+	Resource []Attr
+
+	// OnError is called when a background export fails; errors are
+	// dropped if nil
+	OnError func(error)
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+
+	mu       sync.Mutex
+	batch    []Record
+	exported time.Time
+	pending  sync.WaitGroup
+}
+
+// Batching limits.
+const (
+	BatchSize     = 512
+	FlushInterval = 5 * time.Second
+
+	// exportTimeout bounds a background export
+	exportTimeout = 10 * time.Second
+)
+
+// New returns a tracer exporting spans to e, describing the process
+// with resource.
+func New(e Exporter, resource ...Attr) *Tracer {
+	return &Tracer{Exporter: e, Resource: resource, exported: time.Now()}
+}
+
+// add queues rec, exporting the batch in the background when it is
+// full or due.
+func (t *Tracer) add(rec Record) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.batch = append(t.batch, rec)
+	now := t.now()
+	if len(t.batch) < BatchSize && now.Sub(t.exported) < FlushInterval {
+		return
+	}
+	batch := t.batch
+	t.batch, t.exported = nil, now
+	t.pending.Add(1)
+	go func() {
+		defer t.pending.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+		if err := t.Exporter.Export(ctx, t.Resource, batch); err != nil && t.OnError != nil {
+			t.OnError(err)
+		}
+	}()
+}
+
+// Flush waits for background exports and exports the spans still
+// queued. Commands call it before exiting.
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.pending.Wait()
+	t.mu.Lock()
+	batch := t.batch
+	t.batch, t.exported = nil, t.now()
+	t.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	return t.Exporter.Export(ctx, t.Resource, batch)
+}
+
+func (t *Tracer) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+	return time.Now()
+}
+
+// spanContext identifies the current span of a context.
+type spanContext struct {
+	trace TraceID
+	span  SpanID
+}
+
+type (
+	tracerKey struct{}
+	spanKey   struct{}
+)
+
+// WithTracer returns a copy of ctx recording spans with t.
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// FromContext returns the tracer of ctx, or nil if tracing is off.
+func FromContext(ctx context.Context) *Tracer {
+	t, _ := ctx.Value(tracerKey{}).(*Tracer)
+	return t
+}
+
+// withRemote returns a copy of ctx whose spans continue the trace of
+// a span in another process.
+func withRemote(ctx context.Context, tr TraceID, parent SpanID) context.Context {
+	return context.WithValue(ctx, spanKey{}, spanContext{trace: tr, span: parent})
+}
+
+// Start starts an internal span named name as a child of the current
+// span of ctx, returning a context carrying the new span. It returns
+// ctx and a nil span if tracing is off.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return start(ctx, Internal, name, attrs)
+}
+
+// StartClient starts a span timing an outgoing request.
+func StartClient(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return start(ctx, Client, name, attrs)
+}
+
+func start(ctx context.Context, kind Kind, name string, attrs []Attr) (context.Context, *Span) {
+	t := FromContext(ctx)
+	if t == nil {
+		return ctx, nil
+	}
+	parent, _ := ctx.Value(spanKey{}).(spanContext)
+	s := &Span{tracer: t, rec: Record{
+		TraceID: parent.trace,
+		SpanID:  newSpanID(),
+		Parent:  parent.span,
+		Name:    name,
+		Kind:    kind,
+		Start:   t.now(),
+		Attrs:   attrs,
+	}}
+	if !s.rec.TraceID.IsValid() {
+		rand.Read(s.rec.TraceID[:])
+	}
+	return context.WithValue(ctx, spanKey{}, spanContext{trace: s.rec.TraceID, span: s.rec.SpanID}), s
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,205 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeExporter struct {
+	mu    sync.Mutex
+	spans []Record
+}
+
+func (f *fakeExporter) Export(ctx context.Context, resource []Attr, spans []Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.spans = append(f.spans, spans...)
+	return nil
+}
+
+func TestSpans(t *testing.T) {
+	ctx := context.Background()
+	if ctx2, span := Start(ctx, "off"); span != nil || ctx2 != ctx {
+		t.Fatal("Start() without a tracer returned a span")
+	}
+	var span *Span
+	span.SetAttrs(String("k", "v")) // nil spans ignore calls
+	span.End(nil)
+
+	exp := &fakeExporter{}
+	tr := New(exp, String("service.name", "aperture"))
+	ctx = WithTracer(ctx, tr)
+	ctx, root := Start(ctx, "aperture publish")
+	_, child := StartClient(ctx, "S3 PutObject", String("aws.s3.bucket", "b"))
+	child.End(errors.New("HTTP 503"))
+	root.End(nil)
+	root.End(errors.New("ignored"))
+	if err := tr.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exp.spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(exp.spans))
+	}
+	c, r := exp.spans[0], exp.spans[1]
+	if r.Name != "aperture publish" || r.Parent.IsValid() || r.Error != "" || r.Kind != Internal {
+		t.Errorf("root = %+v", r)
+	}
+	if c.TraceID != r.TraceID || c.Parent != r.SpanID || c.Kind != Client || c.Error != "HTTP 503" {
+		t.Errorf("child = %+v, want child of %s/%s", c, r.TraceID, r.SpanID)
+	}
+	if c.End.Before(c.Start) || len(c.Attrs) != 1 {
+		t.Errorf("child = %+v", c)
+	}
+}
+
+func TestBatching(t *testing.T) {
+	exp := &fakeExporter{}
+	tr := New(exp)
+	now := time.Now()
+	tr.Now = func() time.Time { return now }
+	ctx := WithTracer(context.Background(), tr)
+	for range BatchSize - 1 {
+		_, s := Start(ctx, "step")
+		s.End(nil)
+	}
+	tr.pending.Wait()
+	if len(exp.spans) != 0 {
+		t.Fatalf("exported %d spans before the batch filled", len(exp.spans))
+	}
+	now = now.Add(FlushInterval)
+	_, s := Start(ctx, "step")
+	s.End(nil)
+	tr.pending.Wait()
+	if len(exp.spans) != BatchSize {
+		t.Errorf("exported %d spans, want %d", len(exp.spans), BatchSize)
+	}
+}
+
+func TestOTLP(t *testing.T) {
+	var got struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []map[string]any `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []map[string]any `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("X-Api-Key") != "k" {
+			t.Errorf("request = %s %s %v", r.Method, r.URL, r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	start := time.Unix(1750000000, 0)
+	rec := Record{
+		TraceID: TraceID{1}, SpanID: SpanID{2}, Parent: SpanID{3},
+		Name: "DataCite PUT", Kind: Client, Start: start, End: start.Add(time.Second),
+		Attrs: []Attr{Int("http.response.status_code", 422)},
+		Error: "DataCite: HTTP 422",
+	}
+	o := &OTLP{Endpoint: srv.URL + "/v1/traces", Headers: map[string]string{"X-Api-Key": "k"}}
+	if err := o.Export(context.Background(), []Attr{String("service.name", "aperture")}, []Record{rec}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("request = %+v", got)
+	}
+	if a := got.ResourceSpans[0].Resource.Attributes; len(a) != 1 || a[0]["key"] != "service.name" {
+		t.Errorf("resource = %v", a)
+	}
+	span := got.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	want := map[string]any{
+		"traceId":           "01000000000000000000000000000000",
+		"spanId":            "0200000000000000",
+		"parentSpanId":      "0300000000000000",
+		"name":              "DataCite PUT",
+		"kind":              3.0,
+		"startTimeUnixNano": "1750000000000000000",
+		"endTimeUnixNano":   "1750000001000000000",
+	}
+	for k, v := range want {
+		if span[k] != v {
+			t.Errorf("span[%s] = %v, want %v", k, span[k], v)
+		}
+	}
+	attrs := span["attributes"].([]any)
+	if v := attrs[0].(map[string]any)["value"].(map[string]any)["intValue"]; v != "422" {
+		t.Errorf("status attribute = %v", attrs)
+	}
+	if status := span["status"].(map[string]any); status["code"] != 2.0 || status["message"] != "DataCite: HTTP 422" {
+		t.Errorf("status = %v", status)
+	}
+
+	fail := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer fail.Close()
+	o.Endpoint = fail.URL
+	if err := o.Export(context.Background(), nil, []Record{rec}); err == nil {
+		t.Error("Export() to a failing collector succeeded")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	exp := &fakeExporter{}
+	tr := New(exp)
+	h := Handler(tr, "downloads", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, s := StartClient(r.Context(), "S3 GetObject")
+		s.End(nil)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/d/ds-1", nil)
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if err := tr.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exp.spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(exp.spans))
+	}
+	c, s := exp.spans[0], exp.spans[1]
+	if s.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || s.Parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("server span %s/%s does not continue the caller's trace", s.TraceID, s.Parent)
+	}
+	if s.Name != "downloads GET" || s.Kind != Server || s.Error != "HTTP 502" {
+		t.Errorf("server span = %+v", s)
+	}
+	if c.Parent != s.SpanID || c.TraceID != s.TraceID {
+		t.Errorf("S3 span = %+v, want child of the server span", c)
+	}
+
+	for _, h := range []string{"", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "00-xyz-00f067aa0ba902b7-01"} {
+		if _, _, ok := parseTraceparent(h); ok {
+			t.Errorf("parseTraceparent(%q) accepted", h)
+		}
+	}
+}
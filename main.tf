@@ -20,9 +20,20 @@ terraform {
   }
 }
 
+# The compute layer (tables, functions, API, distributions) is deployed
+# with the default provider; the buckets and their logs may each be in
+# an account of their own, reached through a role in that account.
 provider "aws" {
   region = var.aws_region
 
+  dynamic "assume_role" {
+    for_each = var.compute_role_arn == "" ? [] : [var.compute_role_arn]
+    content {
+      role_arn     = assume_role.value
+      session_name = "${var.project_name}-${var.environment}-deploy"
+    }
+  }
+
   default_tags {
     tags = {
       Project     = "Academic Media Repository"
@@ -32,6 +43,68 @@ provider "aws" {
   }
 }
 
+provider "aws" {
+  alias  = "storage"
+  region = var.aws_region
+
+  dynamic "assume_role" {
+    for_each = var.storage_role_arn == "" ? [] : [var.storage_role_arn]
+    content {
+      role_arn     = assume_role.value
+      session_name = "${var.project_name}-${var.environment}-deploy"
+    }
+  }
+
+  default_tags {
+    tags = {
+      Project     = "Academic Media Repository"
+      Environment = var.environment
+      ManagedBy   = "Terraform"
+    }
+  }
+}
+
+provider "aws" {
+  alias  = "logging"
+  region = var.aws_region
+
+  dynamic "assume_role" {
+    for_each = var.logging_role_arn == "" ? [] : [var.logging_role_arn]
+    content {
+      role_arn     = assume_role.value
+      session_name = "${var.project_name}-${var.environment}-deploy"
+    }
+  }
+
+  default_tags {
+    tags = {
+      Project     = "Academic Media Repository"
+      Environment = var.environment
+      ManagedBy   = "Terraform"
+    }
+  }
+}
+
+data "aws_caller_identity" "compute" {}
+
+data "aws_caller_identity" "storage" {
+  provider = aws.storage
+}
+
+data "aws_caller_identity" "logging" {
+  provider = aws.logging
+}
+
+locals {
+  # Accounts other than the storage account that use its buckets
+  bucket_client_accounts = (
+    data.aws_caller_identity.compute.account_id == data.aws_caller_identity.storage.account_id
+    ? [] : [data.aws_caller_identity.compute.account_id]
+  )
+
+  separate_logging_account = data.aws_caller_identity.logging.account_id != data.aws_caller_identity.storage.account_id
+}
+
 # Variables
 variable "aws_region" {
   description = "AWS region"
@@ -74,6 +147,30 @@ variable "datacite_password" {
   sensitive   = true
 }
 
+variable "datacite_api_url" {
+  description = "DataCite REST API root the DOI function registers with"
+  type        = string
+  default     = "https://api.test.datacite.org"
+}
+
+variable "datacite_mds_url" {
+  description = "DataCite MDS API root the DOI function registers media with"
+  type        = string
+  default     = "https://mds.test.datacite.org"
+}
+
+variable "admins" {
+  description = "Principals the API functions treat as administrators"
+  type        = list(string)
+  default     = []
+}
+
+variable "opensearch_url" {
+  description = "OpenSearch endpoint of the dataset index the search function queries"
+  type        = string
+  default     = ""
+}
+
 variable "budget_alert_email" {
   description = "Email for budget alerts"
   type        = string
@@ -106,6 +203,25 @@ variable "orcid_environment" {
   default     = "sandbox"
 }
 
+# Institutional SAML Federation
+variable "saml_provider_name" {
+  description = "Name of the institutional SAML identity provider"
+  type        = string
+  default     = "Institution"
+}
+
+variable "saml_metadata_url" {
+  description = "Institutional IdP SAML metadata URL; SAML sign-in is disabled when empty"
+  type        = string
+  default     = ""
+}
+
+variable "saml_idp_identifiers" {
+  description = "Email domains routed to the institutional IdP"
+  type        = list(string)
+  default     = []
+}
+
 variable "cognito_callback_urls" {
   description = "Cognito OAuth callback URLs"
   type        = list(string)
@@ -118,15 +234,55 @@ variable "cors_allowed_origins" {
   default     = ["*"]
 }
 
+variable "storage_role_arn" {
+  description = "Role to assume in the account holding the buckets (empty to use the deploying credentials)"
+  type        = string
+  default     = ""
+}
+
+variable "compute_role_arn" {
+  description = "Role to assume in the account holding the tables, functions, API, and distributions (empty to use the deploying credentials)"
+  type        = string
+  default     = ""
+}
+
+variable "logging_role_arn" {
+  description = "Role to assume in the account holding the logs bucket (empty to use the deploying credentials)"
+  type        = string
+  default     = ""
+}
+
+variable "storage_kms_key_arn" {
+  description = "KMS key encrypting the buckets (empty for SSE-S3); functions in another account are granted its use"
+  type        = string
+  default     = ""
+}
+
+variable "force_destroy" {
+  description = "Delete buckets that still hold objects when the stack is destroyed; set by 'aperture destroy --force-delete-data'"
+  type        = bool
+  default     = false
+}
+
 # S3 Buckets
 module "s3_buckets" {
   source = "./infrastructure/terraform/modules/s3"
 
+  providers = {
+    aws         = aws.storage
+    aws.logging = aws.logging
+  }
+
   project_name = var.project_name
   environment  = var.environment
 
   enable_versioning = true
   enable_logging    = true
+  force_destroy     = var.force_destroy
+  kms_key_id        = var.storage_kms_key_arn
+
+  client_account_ids    = local.bucket_client_accounts
+  cross_account_logging = local.separate_logging_account
 
   cors_allowed_origins = var.cors_allowed_origins
 
@@ -151,6 +307,11 @@ module "dynamodb" {
 module "cloudfront" {
   source = "./infrastructure/terraform/modules/cloudfront"
 
+  providers = {
+    aws         = aws
+    aws.storage = aws.storage
+  }
+
   project_name = var.project_name
   environment  = var.environment
 
@@ -165,6 +326,8 @@ module "cloudfront" {
 
   logs_bucket_domain_name = module.s3_buckets.logs_bucket_domain_name
 
+  bucket_client_account_ids = local.bucket_client_accounts
+
   # Cost optimization
   price_class = "PriceClass_100" # North America and Europe only
 
@@ -186,6 +349,12 @@ module "cognito" {
   orcid_client_secret = var.orcid_client_secret
   orcid_environment   = var.orcid_environment
 
+  # Institutional SAML Configuration
+  enable_saml          = var.saml_metadata_url != ""
+  saml_provider_name   = var.saml_provider_name
+  saml_metadata_url    = var.saml_metadata_url
+  saml_idp_identifiers = var.saml_idp_identifiers
+
   # OAuth Configuration
   callback_urls = var.cognito_callback_urls
   logout_urls   = var.cognito_callback_urls
@@ -223,6 +392,8 @@ module "lambda_functions" {
   embargoed_media_bucket_arn   = module.s3_buckets.embargoed_media_bucket_arn
 
   # DynamoDB Tables
+  state_table_name                     = module.dynamodb.state_table_name
+  state_table_arn                      = module.dynamodb.state_table_arn
   doi_registry_table_name              = module.dynamodb.doi_registry_table_name
   doi_registry_table_arn               = module.dynamodb.doi_registry_table_arn
   users_table_name                     = module.dynamodb.users_table_name
@@ -233,13 +404,21 @@ module "lambda_functions" {
   budget_tracking_table_arn            = module.dynamodb.budget_tracking_table_arn
   knowledge_base_embeddings_table_name = module.dynamodb.knowledge_base_embeddings_table_name
   knowledge_base_embeddings_table_arn  = module.dynamodb.knowledge_base_embeddings_table_arn
+  download_quotas_table_name           = module.dynamodb.download_quotas_table_name
+  download_quotas_table_arn            = module.dynamodb.download_quotas_table_arn
 
   # DataCite Configuration
+  datacite_api_url  = var.datacite_api_url
+  datacite_mds_url  = var.datacite_mds_url
   datacite_username = var.datacite_username
   datacite_password = var.datacite_password
   doi_prefix        = var.datacite_prefix
   repo_base_url     = "https://${var.domain_name}"
 
+  # Aperture Configuration
+  admins         = var.admins
+  opensearch_url = var.opensearch_url
+
   # API Gateway integration (will be added when API Gateway module is created)
   # api_gateway_execution_arn = module.api_gateway.execution_arn
 
@@ -248,6 +427,22 @@ module "lambda_functions" {
   }
 }
 
+# The functions that read and write the buckets may use the storage
+# account's key without their account's IAM policies naming it
+resource "aws_kms_grant" "storage" {
+  for_each = var.storage_kms_key_arn == "" ? {} : {
+    presigned_urls   = module.lambda_functions.presigned_urls_lambda_role_arn
+    doi_minting      = module.lambda_functions.doi_minting_lambda_role_arn
+    bedrock_analysis = module.lambda_functions.bedrock_analysis_lambda_role_arn
+  }
+  provider = aws.storage
+
+  name              = "${var.project_name}-${var.environment}-${each.key}"
+  key_id            = var.storage_kms_key_arn
+  grantee_principal = each.value
+  operations        = ["Decrypt", "Encrypt", "GenerateDataKey", "DescribeKey"]
+}
+
 # API Gateway
 module "api_gateway" {
   source = "./infrastructure/terraform/modules/api-gateway"
@@ -259,31 +454,37 @@ module "api_gateway" {
   cognito_user_pool_id  = module.cognito.user_pool_id
   cognito_user_pool_arn = module.cognito.user_pool_arn
   cognito_app_client_id = module.cognito.web_app_client_id
+  cognito_cli_client_id = module.cognito.cli_client_id
 
   # Auth Lambda
   auth_lambda_name       = module.lambda_functions.auth_lambda_name
   auth_lambda_arn        = module.lambda_functions.auth_lambda_arn
-  auth_lambda_invoke_arn = module.lambda_functions.auth_lambda_invoke_arn
+  auth_lambda_invoke_arn = module.lambda_functions.auth_lambda_alias_invoke_arn
 
   # Presigned URLs Lambda
   presigned_urls_lambda_name       = module.lambda_functions.presigned_urls_lambda_name
   presigned_urls_lambda_arn        = module.lambda_functions.presigned_urls_lambda_arn
-  presigned_urls_lambda_invoke_arn = module.lambda_functions.presigned_urls_lambda_invoke_arn
+  presigned_urls_lambda_invoke_arn = module.lambda_functions.presigned_urls_lambda_alias_invoke_arn
 
   # DOI Minting Lambda
   doi_minting_lambda_name       = module.lambda_functions.doi_minting_lambda_name
   doi_minting_lambda_arn        = module.lambda_functions.doi_minting_lambda_arn
-  doi_minting_lambda_invoke_arn = module.lambda_functions.doi_minting_lambda_invoke_arn
+  doi_minting_lambda_invoke_arn = module.lambda_functions.doi_minting_lambda_alias_invoke_arn
+
+  # Search Lambda
+  search_lambda_name       = module.lambda_functions.search_lambda_name
+  search_lambda_arn        = module.lambda_functions.search_lambda_arn
+  search_lambda_invoke_arn = module.lambda_functions.search_lambda_alias_invoke_arn
 
   # Bedrock Analysis Lambda
   bedrock_analysis_lambda_name       = module.lambda_functions.bedrock_analysis_lambda_name
   bedrock_analysis_lambda_arn        = module.lambda_functions.bedrock_analysis_lambda_arn
-  bedrock_analysis_lambda_invoke_arn = module.lambda_functions.bedrock_analysis_lambda_invoke_arn
+  bedrock_analysis_lambda_invoke_arn = module.lambda_functions.bedrock_analysis_lambda_alias_invoke_arn
 
   # RAG Knowledge Base Lambda
   rag_knowledge_base_lambda_name       = module.lambda_functions.rag_knowledge_base_lambda_name
   rag_knowledge_base_lambda_arn        = module.lambda_functions.rag_knowledge_base_lambda_arn
-  rag_knowledge_base_lambda_invoke_arn = module.lambda_functions.rag_knowledge_base_lambda_invoke_arn
+  rag_knowledge_base_lambda_invoke_arn = module.lambda_functions.rag_knowledge_base_lambda_alias_invoke_arn
 
   # CORS Configuration
   cors_allowed_origins = var.cors_allowed_origins
@@ -325,6 +526,22 @@ module "eventbridge" {
   }
 }
 
+# Work Queues for 'aperture worker'
+module "sqs" {
+  source = "./infrastructure/terraform/modules/sqs"
+
+  project_name = var.project_name
+  environment  = var.environment
+
+  # Access logs written by CloudFront are queued for ingest
+  logs_bucket_name  = module.s3_buckets.logs_bucket_id
+  access_log_prefix = "cloudfront/media/"
+
+  tags = {
+    Component = "Background Workers"
+  }
+}
+
 # Budget Alerts (TODO: Future)
 # module "budgets" {
 #   source = "./infrastructure/terraform/modules/budgets"
@@ -459,6 +676,11 @@ output "cognito_web_app_client_id" {
   value       = module.cognito.web_app_client_id
 }
 
+output "cognito_cli_client_id" {
+  description = "Cognito client ID for 'aperture login' (APERTURE_OIDC_CLIENT_ID)"
+  value       = module.cognito.cli_client_id
+}
+
 output "cognito_user_pool_domain" {
   description = "Cognito hosted UI domain"
   value       = module.cognito.user_pool_domain
@@ -485,6 +707,13 @@ output "doi_minting_lambda_arn" {
   value       = module.lambda_functions.doi_minting_lambda_arn
 }
 
+# Read by aperture deploy to shift the live aliases to the versions
+# each deployment publishes
+output "api_functions" {
+  description = "API functions with their published versions, live aliases, and error alarms"
+  value       = module.lambda_functions.api_functions
+}
+
 output "lambda_summary" {
   description = "Summary of Lambda functions"
   value       = module.lambda_functions.summary
@@ -531,3 +760,13 @@ output "api_gateway_summary" {
   description = "Summary of API Gateway resources"
   value       = module.api_gateway.summary
 }
+
+# Accounts
+output "deployment_accounts" {
+  description = "Account each layer of the stack is deployed to"
+  value = {
+    storage = data.aws_caller_identity.storage.account_id
+    compute = data.aws_caller_identity.compute.account_id
+    logging = data.aws_caller_identity.logging.account_id
+  }
+}
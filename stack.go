@@ -0,0 +1,34 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package aperture embeds the platform's Terraform stack and
+// CloudFormation template, so that the CLI deploys the infrastructure it
+// was built against.
+package aperture
+
+import "embed"
+
+// Stack holds the root Terraform module, the modules it uses, and the
+// sources of the Python Lambda functions, at the paths main.tf and the
+// modules refer to them by. The Go functions are built into lambdas/ at
+// deploy time.
+//
+//go:embed main.tf infrastructure/terraform/modules lambda
+var Stack embed.FS
+
+// CloudFormation holds the CloudFormation template of the platform's
+// storage and catalog, for institutions that do not allow Terraform.
+//
+//go:embed infrastructure/cloudformation/aperture.yaml
+var CloudFormation embed.FS
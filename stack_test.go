@@ -0,0 +1,50 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aperture
+
+import (
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestStackPackages checks that every directory the stack packages as a
+// Lambda function is embedded, or is one of the Go functions built at
+// deploy time, so that a deployment from the embedded stack can plan.
+func TestStackPackages(t *testing.T) {
+	sourceDir := regexp.MustCompile(`source_dir\s*=\s*"\$\{path\.root\}/([^"]+)"`)
+	err := fs.WalkDir(Stack, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".tf") {
+			return err
+		}
+		data, err := fs.ReadFile(Stack, path)
+		if err != nil {
+			return err
+		}
+		for _, m := range sourceDir.FindAllStringSubmatch(string(data), -1) {
+			if strings.HasPrefix(m[1], "lambdas/") {
+				continue
+			}
+			if _, err := fs.Stat(Stack, m[1]); err != nil {
+				t.Errorf("%s packages %s, which is not embedded: %v", path, m[1], err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}